@@ -0,0 +1,154 @@
+// Package analysis holds small numeric routines over robot time series:
+// resampling irregular samples onto a fixed grid and estimating how far
+// one signal lags another.
+package analysis
+
+import (
+	"errors"
+	"math"
+)
+
+// Lag estimation errors.
+var (
+	ErrTooShort     = errors.New("not enough samples")
+	ErrNoExcitation = errors.New("signal too flat to correlate")
+)
+
+// MinVariance is the smallest variance of either signal that is
+// correlated; below it there is no shape to match ((m/s)² for
+// velocities).
+const MinVariance = 1e-4
+
+// Resample puts the samples (times t, ascending, with values v) on n
+// steps of step from start: each step is the mean of the samples in
+// [start + i·step, start + (i+1)·step), or holds the previous step's
+// value when it has none. Steps before the first sample take its value.
+// A commanded signal published on change only is thereby held, as the
+// robot holds it.
+func Resample(t []int64, v []float64, start, step int64, n int) []float64 {
+	out := make([]float64, n)
+	if len(t) == 0 || n <= 0 || step <= 0 {
+		return out
+	}
+	j := 0
+	for j < len(t) && t[j] < start {
+		j++
+	}
+	// The value held into the grid is the last one before it
+	held := v[0]
+	if j > 0 {
+		held = v[j-1]
+	}
+	for i := range out {
+		end := start + int64(i+1)*step
+		sum, cnt := 0.0, 0
+		for ; j < len(t) && t[j] < end; j++ {
+			sum += v[j]
+			cnt++
+		}
+		if cnt > 0 {
+			held = sum / float64(cnt)
+		}
+		out[i] = held
+	}
+	return out
+}
+
+// Lag is the delay of one signal behind another, in steps.
+type Lag struct {
+	// Steps is the delay, fractional from interpolating around the
+	// peak.
+	Steps float64
+	// Correlation is the Pearson correlation at the best whole step,
+	// -1..1.
+	Correlation float64
+	// Confidence, 0..1, is the correlation at the peak scaled by how
+	// much it stands out from the other lags: a flat or negative
+	// correlation curve gives little.
+	Confidence float64
+	// Overlap is how many samples were compared at the peak.
+	Overlap int
+}
+
+// EstimateLag finds the delay of b behind a, between 0 and maxLag steps,
+// as the peak of their normalized cross-correlation: a[i] is compared
+// with b[i+k]. Both signals cover the same steps. It fails when the
+// signals are shorter than twice maxLag, or either is flat.
+func EstimateLag(a, b []float64, maxLag int) (Lag, error) {
+	n := min(len(a), len(b))
+	if maxLag < 0 || n < 2*maxLag || n < 4 {
+		return Lag{}, ErrTooShort
+	}
+	if variance(a[:n]) < MinVariance || variance(b[:n]) < MinVariance {
+		return Lag{}, ErrNoExcitation
+	}
+
+	corr := make([]float64, maxLag+1)
+	best := 0
+	for k := range corr {
+		corr[k] = pearson(a[:n-k], b[k:n])
+		if corr[k] > corr[best] {
+			best = k
+		}
+	}
+
+	lag := Lag{Steps: float64(best), Correlation: corr[best], Overlap: n - best}
+	// A parabola through the peak and its neighbours places it between
+	// steps
+	if best > 0 && best < maxLag {
+		l, c, r := corr[best-1], corr[best], corr[best+1]
+		if d := l - 2*c + r; d < 0 {
+			lag.Steps += 0.5 * (l - r) / d
+		}
+	}
+
+	// How far the peak stands out: against the mean of the curve
+	mean := 0.0
+	for _, c := range corr {
+		mean += c
+	}
+	mean /= float64(len(corr))
+	prominence := 1.0
+	if maxLag > 0 {
+		prominence = math.Min(1, math.Max(0, (corr[best]-mean)/(1-mean+1e-9))*4)
+	}
+	lag.Confidence = math.Max(0, corr[best]) * (0.5 + 0.5*prominence)
+	return lag, nil
+}
+
+// pearson returns the correlation coefficient of equally long x and y;
+// 0 when either is constant.
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	if n == 0 {
+		return 0
+	}
+	var sx, sy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+	}
+	mx, my := sx/n, sy/n
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+func variance(x []float64) float64 {
+	n := float64(len(x))
+	var s, ss float64
+	for _, v := range x {
+		s += v
+		ss += v * v
+	}
+	m := s / n
+	return ss/n - m*m
+}
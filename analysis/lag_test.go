@@ -0,0 +1,122 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	ts := []int64{5, 15, 18, 45}
+	vs := []float64{1, 2, 4, 8}
+	got := Resample(ts, vs, 10, 10, 5)
+	// [10,20): mean of 2 and 4; [20,30) and [30,40): held; [40,50): 8;
+	// [50,60): held
+	want := []float64{3, 3, 3, 8, 8}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("resampled %v, want %v", got, want)
+		}
+	}
+
+	// Steps before the first sample take its value
+	if got := Resample(ts, vs, 0, 2, 3); got[0] != 1 || got[1] != 1 || got[2] != 1 {
+		t.Errorf("before the first sample: %v", got)
+	}
+	if got := Resample(nil, nil, 0, 10, 3); len(got) != 3 || got[0] != 0 {
+		t.Errorf("no samples: %v", got)
+	}
+	if got := Resample(ts, vs, 0, 0, 3); got[2] != 0 {
+		t.Errorf("zero step: %v", got)
+	}
+}
+
+// steps returns n steps of a piecewise-constant command: a random level
+// held for 5 to 25 steps.
+func steps(rng *rand.Rand, n int) []float64 {
+	out := make([]float64, n)
+	level, left := 0.0, 0
+	for i := range out {
+		if left == 0 {
+			level, left = rng.Float64()-0.3, 5+rng.Intn(21)
+		}
+		out[i] = level
+		left--
+	}
+	return out
+}
+
+// respond returns cmd delayed by delay steps through a first-order lag
+// of time constant tau steps (0: none), plus Gaussian noise.
+func respond(rng *rand.Rand, cmd []float64, delay int, tau, noise float64) []float64 {
+	out := make([]float64, len(cmd))
+	y := cmd[0]
+	for i := range out {
+		u := cmd[max(0, i-delay)]
+		if tau > 0 {
+			y += (u - y) / tau
+		} else {
+			y = u
+		}
+		out[i] = y + rng.NormFloat64()*noise
+	}
+	return out
+}
+
+func TestEstimateLagDelay(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for delay := 0; delay <= 12; delay += 3 {
+		cmd := steps(rng, 300)
+		lag, err := EstimateLag(cmd, respond(rng, cmd, delay, 0, 0.01), 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(lag.Steps-float64(delay)) > 0.5 || lag.Correlation < 0.95 || lag.Confidence < 0.7 || lag.Overlap != 300-delay {
+			t.Errorf("delay %d: %+v", delay, lag)
+		}
+	}
+}
+
+// TestEstimateLagFirstOrder adds a first-order response: the estimate
+// falls between the pure delay and the delay plus the time constant.
+func TestEstimateLagFirstOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, delay := range []int{0, 3, 8} {
+		cmd := steps(rng, 300)
+		lag, err := EstimateLag(cmd, respond(rng, cmd, delay, 3, 0.02), 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lag.Steps < float64(delay) || lag.Steps > float64(delay)+3 || lag.Confidence < 0.5 {
+			t.Errorf("delay %d, tau 3: %+v", delay, lag)
+		}
+	}
+}
+
+func TestEstimateLagNoSignal(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	cmd := steps(rng, 300)
+	noise := respond(rng, make([]float64, 300), 0, 0, 0.3)
+	lag, err := EstimateLag(cmd, noise, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.Confidence > 0.3 {
+		t.Errorf("unrelated noise: %+v", lag)
+	}
+
+	flat := make([]float64, 300)
+	if _, err := EstimateLag(cmd, flat, 20); !errors.Is(err, ErrNoExcitation) {
+		t.Errorf("flat response: %v", err)
+	}
+	if _, err := EstimateLag(flat, cmd, 20); !errors.Is(err, ErrNoExcitation) {
+		t.Errorf("flat command: %v", err)
+	}
+	if _, err := EstimateLag(cmd[:30], cmd[:30], 20); !errors.Is(err, ErrTooShort) {
+		t.Errorf("short: %v", err)
+	}
+	if _, err := EstimateLag(cmd, cmd, -1); !errors.Is(err, ErrTooShort) {
+		t.Errorf("negative lag: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestBasePath(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	for v, want := range map[string]string{
+		"":               "",
+		"/":              "",
+		"robots":         "/robots",
+		"/robots/":       "/robots",
+		" /fleet//a/ ":   "/fleet/a",
+		"/a/../robots/.": "/robots",
+		"../..":          "",
+	} {
+		t.Setenv("BASE_PATH", v)
+		c, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.BasePath != want {
+			t.Errorf("BASE_PATH %q: %q, want %q", v, c.BasePath, want)
+		}
+	}
+}
@@ -1,46 +1,576 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Config holds application configuration.
+// Config holds application configuration: settings read once at startup
+// (changing them needs a restart) and the Dynamic settings, which
+// Reload re-reads and swaps in while the app runs. Each setting's tag
+// names its environment variable and config file key.
 type Config struct {
-	ListenAddr        string
-	RosbridgePort     int
-	WhisperBinPath    string
-	WhisperModelPath  string
-	SpeechLogDir      string
-	DefaultLinearMax  float64
-	DefaultAngularMax float64
+	ListenAddr        string  `config:"LISTEN_ADDR"`
+	RosbridgePort     int     `config:"-"`
+	MapThumbnailDir   string  `config:"MAP_THUMBNAIL_DIR"`
+	MapArchiveDir     string  `config:"MAP_ARCHIVE_DIR"`
+	WebhooksFile      string  `config:"WEBHOOKS_FILE"`
+	SpeechPhrasesFile string  `config:"SPEECH_PHRASES_FILE"`
+	TemplatesFile     string  `config:"SETTINGS_TEMPLATES_FILE"`
+	PointsDir         string  `config:"POINTS_DIR"`
+	UsageDir          string  `config:"USAGE_DIR"`
+	VisitsDir         string  `config:"VISITS_DIR"`
+	DefaultLinearMax  float64 `config:"-"`
+	DefaultAngularMax float64 `config:"-"`
+
+	// Robot discovery: the background mDNS browser.
+	DiscoveryMDNS        bool   `config:"DISCOVERY_MDNS"`
+	DiscoveryServiceType string `config:"DISCOVERY_MDNS_SERVICE"`
+
+	// Maximum age of map_bfp / TF accepted when teaching a nav point at
+	// the robot's current pose.
+	NavPoseMaxAge time.Duration `config:"NAV_POSE_MAX_AGE_MS"`
+
+	// Navigation points are written to PointsDir once unchanged for
+	// PointsSaveDelay.
+	PointsSaveDelay time.Duration `config:"POINTS_SAVE_DELAY_MS"`
+
+	// Longest odometry step counted as distance traveled; longer ones
+	// are localization jumps.
+	UsageJumpM float64 `config:"USAGE_JUMP_M"`
+
+	// A robot visits a point on coming within VisitRadiusM of it, and
+	// leaves it beyond VisitRadiusM+VisitHysteresisM.
+	VisitRadiusM     float64 `config:"VISIT_RADIUS_M"`
+	VisitHysteresisM float64 `config:"VISIT_HYSTERESIS_M"`
+
+	// Webhooks send battery_low when the charge on the robot's
+	// WebhookBatteryAlias extra topic falls below WebhookBatteryLowPct
+	// (0 disables it).
+	WebhookBatteryAlias  string  `config:"WEBHOOK_BATTERY_ALIAS"`
+	WebhookBatteryLowPct float64 `config:"WEBHOOK_BATTERY_LOW_PCT"`
+
+	// Upper bound for a navigation point's dwell_sec.
+	NavMaxDwellSec float64 `config:"NAV_MAX_DWELL_SEC"`
+
+	// Furthest the robot may be from a collection's first point when a
+	// go-all or patrol starts without force; 0 disables the limit.
+	NavGoAllMaxDistanceM float64 `config:"NAV_GO_ALL_MAX_DISTANCE_M"`
+
+	// Whether a patrol waits through a rosbridge drop and resumes, or
+	// aborts.
+	PatrolResumeOnReconnect bool `config:"PATROL_RESUME_ON_RECONNECT"`
+
+	// Robot clock skew (header stamp vs receive time) that raises a
+	// warning, and the sudden change that counts as a clock jump.
+	ClockSkewWarn time.Duration `config:"CLOCK_SKEW_WARN_MS"`
+	ClockSkewJump time.Duration `config:"CLOCK_SKEW_JUMP_MS"`
+
+	// Whether joystick input is rejected while a robot navigates,
+	// patrols or is manually autonomy-locked.
+	AutonomyGating bool `config:"AUTONOMY_GATING"`
+
+	// Joystick silence after which a manual control session ends and a
+	// still-moving robot is stopped.
+	JoystickDeadman time.Duration `config:"JOYSTICK_DEADMAN_MS"`
+
+	// Inactivity after which a control lease ends, and whether driving
+	// requires holding one.
+	ControlLeaseIdle     time.Duration `config:"CONTROL_LEASE_IDLE_S"`
+	ControlLeaseRequired bool          `config:"CONTROL_LEASE_REQUIRED"`
+
+	// Fleet proximity monitor: warning distance beyond the robots'
+	// extents, hysteresis, and whether a critical approach zeroes teleop
+	// velocity.
+	FleetProximityMargin     float64 `config:"FLEET_PROXIMITY_MARGIN_M"`
+	FleetProximityHysteresis float64 `config:"FLEET_PROXIMITY_HYSTERESIS_M"`
+	FleetProximityAutoStop   bool    `config:"FLEET_PROXIMITY_AUTO_STOP"`
+
+	// which_tasks request a robot answers with its task catalog, and the
+	// "name[:description]" tasks offered for robots that don't answer it.
+	TaskDiscoveryRequest string   `config:"TASK_DISCOVERY_REQUEST"`
+	StaticTasks          []string `config:"STATIC_TASKS"`
+
+	// which_tasks request a robot whose handshake carries no capability
+	// list is asked for one; "none" skips it.
+	CapabilitiesRequest string `config:"CAPABILITIES_REQUEST"`
+
+	// How long a map save may take, and the robot topic (relative to its
+	// namespace) publishing save percentage; empty if robots have none.
+	MapSaveTimeout       time.Duration `config:"MAP_SAVE_TIMEOUT_S"`
+	MapSaveProgressTopic string        `config:"MAP_SAVE_PROGRESS_TOPIC"`
+
+	// Mapping coverage is flagged as stabilized once the known area grows
+	// by less than MappingStableM2PerMin for MappingStableFor.
+	MappingStableM2PerMin float64       `config:"MAPPING_STABLE_M2_PER_MIN"`
+	MappingStableFor      time.Duration `config:"MAPPING_STABLE_S"`
+
+	// Retention of the map archive: versions kept per map name and
+	// total size; 0 = unlimited.
+	MapArchiveMaxVersions int `config:"MAP_ARCHIVE_MAX_VERSIONS"`
+	MapArchiveMaxMB       int `config:"MAP_ARCHIVE_MAX_MB"`
+
+	// How long the confirmation token of a power off, reboot or clear
+	// stays valid.
+	DestructiveConfirm time.Duration `config:"DESTRUCTIVE_CONFIRM_S"`
+
+	// How long a robot that acknowledged a reboot has to come back (and
+	// one told to power off to go down) before an alert.
+	PowerDowntime time.Duration `config:"POWER_DOWNTIME_S"`
+
+	// Global localization service (relative to the robot namespace)
+	// POST /api/robots/relocalize calls, and the in-place rotation that
+	// follows by default: its duration (0 skips it) and speed (rad/s).
+	RelocalizeService string        `config:"RELOCALIZE_SERVICE"`
+	RelocalizeRotate  time.Duration `config:"RELOCALIZE_ROTATE_S"`
+	RelocalizeAngular float64       `config:"RELOCALIZE_ANGULAR"`
+
+	// PoseWithCovarianceStamped topic localization quality is graded
+	// from (e.g. /amcl_pose); empty grades the odometry covariance.
+	LocalizationAMCLTopic string `config:"LOCALIZATION_AMCL_TOPIC"`
+
+	// std_msgs/String topic onboard software flags incident markers on
+	// (e.g. /incident_marker); empty if robots have none.
+	IncidentMarkerTopic string `config:"INCIDENT_MARKER_TOPIC"`
+
+	// New robots share one rosbridge connection per server instead of
+	// opening their own.
+	RosbridgeShared bool `config:"ROSBRIDGE_SHARED"`
+
+	// MQTT bridge, off without a broker URL: credentials, topic prefix,
+	// QoS (0 or 1), per-topic rate limit (Hz, 0 = none), the broadcast
+	// types mirrored (empty: the bridge's defaults) and whether the
+	// cmd/ subtree may stop and send robots.
+	MQTTBroker    string   `config:"MQTT_BROKER"`
+	MQTTClientID  string   `config:"MQTT_CLIENT_ID"`
+	MQTTUsername  string   `config:"MQTT_USERNAME"`
+	MQTTPassword  string   `config:"MQTT_PASSWORD,secret"`
+	MQTTPrefix    string   `config:"MQTT_TOPIC_PREFIX"`
+	MQTTQoS       int      `config:"MQTT_QOS"`
+	MQTTMaxRateHz float64  `config:"MQTT_MAX_RATE_HZ"`
+	MQTTTopics    []string `config:"MQTT_TOPICS"`
+	MQTTCommands  bool     `config:"MQTT_COMMANDS"`
+
+	// Browser origins allowed to call /api/ cross-origin (exact
+	// scheme://host[:port] or "*"), and whether cookies may be sent.
+	// Empty keeps CORS off and WebSocket origins unchecked.
+	CORSOrigins          []string `config:"CORS_ORIGINS"`
+	CORSAllowCredentials bool     `config:"CORS_ALLOW_CREDENTIALS"`
+
+	// Cache-Control max-age for unversioned static URLs (versioned
+	// ?v=<hash> URLs are always immutable).
+	StaticMaxAge time.Duration `config:"STATIC_MAX_AGE"`
+
+	// HTTPS: a certificate/key pair, or a self-signed pair generated
+	// into TLSDir (with extra TLSHosts names). With TLSListenAddr set,
+	// HTTPS is served there and ListenAddr only redirects to it;
+	// otherwise ListenAddr itself serves HTTPS.
+	TLSCertFile   string   `config:"TLS_CERT"`
+	TLSKeyFile    string   `config:"TLS_KEY,secret"`
+	TLSSelfSigned bool     `config:"TLS_SELF_SIGNED"`
+	TLSDir        string   `config:"TLS_DIR"`
+	TLSHosts      []string `config:"TLS_HOSTS"`
+	TLSListenAddr string   `config:"TLS_LISTEN_ADDR"`
+
+	// Optional listener serving only /api/, /metrics and /healthz, e.g.
+	// a localhost-only admin port; always plain HTTP.
+	AdminListenAddr string `config:"ADMIN_LISTEN_ADDR"`
+
+	// NoUI skips templates, static assets and the page, partial and
+	// dialog routes, for API-only instances.
+	NoUI bool `config:"NO_UI"`
+
+	// Deployment files (logo, ...) served under /branding/ in front of
+	// the embedded static files.
+	BrandingDir string `config:"BRANDING_DIR"`
+
+	// URL prefix the app is mounted at behind a reverse proxy
+	// ("/robots"), without a trailing slash; empty at the root.
+	BasePath string `config:"BASE_PATH"`
+
+	// File is the CONFIG_FILE merged over the environment, if any.
+	File string `config:"-"`
+
+	// overrides (command-line flags) win over the file and environment,
+	// on reload too.
+	overrides source
+
+	dynamic  atomic.Pointer[Dynamic]
+	reloadMu sync.Mutex
+}
+
+// Dynamic holds the settings Reload applies without a restart. Consumers
+// call Config.Dynamic on each use instead of keeping a copy.
+type Dynamic struct {
+	// Speech: the whisper.cpp binary and model, where recordings are
+	// kept, and the confidence (0..1) below which transcripts are
+	// reported as low_confidence instead of sent to the robot.
+	WhisperBinPath       string  `config:"WHISPER_BIN"`
+	WhisperModelPath     string  `config:"WHISPER_MODEL"`
+	SpeechLogDir         string  `config:"SPEECH_LOG_DIR"`
+	WhisperMinConfidence float64 `config:"WHISPER_MIN_CONFIDENCE"`
+
+	// Speech uploads: the largest accepted recording, how long ffmpeg may
+	// take to convert one, and how long recordings and whisper output
+	// are kept in SpeechLogDir (0 = forever).
+	SpeechMaxUploadMB   int           `config:"SPEECH_MAX_UPLOAD_MB"`
+	SpeechFFmpegTimeout time.Duration `config:"SPEECH_FFMPEG_TIMEOUT_S"`
+	SpeechRetention     time.Duration `config:"SPEECH_RETENTION_H"`
+
+	// Speech backend: whisper_cli runs whisper.cpp here, http posts the
+	// recording to the speech-to-text service at SpeechHTTPURL, with
+	// SpeechHTTPAuth as the Authorization header.
+	SpeechBackend     string        `config:"SPEECH_BACKEND"`
+	SpeechHTTPURL     string        `config:"SPEECH_HTTP_URL"`
+	SpeechHTTPAuth    string        `config:"SPEECH_HTTP_AUTH,secret"`
+	SpeechHTTPTimeout time.Duration `config:"SPEECH_HTTP_TIMEOUT_S"`
+
+	// Browser WS clients declaring an older protocol version only get
+	// status frames and an upgrade_required notice.
+	MinWSClientVersion int `config:"WS_MIN_CLIENT_VERSION"`
+
+	// Robot discovery scans: default subnets (empty = local interfaces)
+	// and dial concurrency.
+	DiscoverySubnets     []string `config:"DISCOVERY_SUBNETS"`
+	DiscoveryConcurrency int      `config:"DISCOVERY_CONCURRENCY"`
+
+	// Robot-side throttle rates (ms by rosbridge topic key) for every
+	// robot, overriding the built-in defaults; nil keeps those.
+	TopicThrottles map[string]int `config:"TOPIC_THROTTLES"`
+
+	// Idle policy: robots unused this long (0 = never) drop the topics
+	// in IdleDropTopics and slow the others to IdleThrottles; empty
+	// keeps the built-in sets (see rosbridge.DefaultIdleTopics).
+	IdleAfter      time.Duration  `config:"IDLE_AFTER_S"`
+	IdleDropTopics []string       `config:"IDLE_DROP_TOPICS"`
+	IdleThrottles  map[string]int `config:"IDLE_THROTTLES"`
+
+	// Ages (ms by stream: odom, velocity, tf, laser, map) past which
+	// snapshot values are flagged stale, overriding the built-in ones.
+	StaleThresholds map[string]int `config:"STALE_THRESHOLDS"`
+
+	// Range accepted for the joystick velocity ratios.
+	VelRatioMin float64 `config:"VEL_RATIO_MIN"`
+	VelRatioMax float64 `config:"VEL_RATIO_MAX"`
+
+	// Branding: the product name shown in the UI, the logo (a path
+	// under /branding/, empty for none), the accent color (#rgb or
+	// #rrggbb, empty for the stylesheet's) and the UI features
+	// (speech, mapping, poweroff, fleet) switched off.
+	BrandingProductName  string   `config:"BRANDING_PRODUCT_NAME"`
+	BrandingLogo         string   `config:"BRANDING_LOGO"`
+	BrandingPrimaryColor string   `config:"BRANDING_PRIMARY_COLOR"`
+	UIDisabledFeatures   []string `config:"UI_DISABLED_FEATURES"`
+
+	// Allows fault injection via POST /api/debug/chaos.
+	DebugChaos bool `config:"DEBUG_CHAOS"`
+
+	// Logs rosbridge diagnostics too chatty for normal operation, such
+	// as service replies arriving after their call timed out.
+	DebugRosbridge bool `config:"DEBUG_ROSBRIDGE"`
+
+	// Allows the tap_topic WS command, forwarding a robot topic's raw
+	// messages to one browser: at most TopicTapMaxRate per second, each
+	// cut to TopicTapMaxBytes, for TopicTapTTL.
+	DebugTopicTap    bool          `config:"DEBUG_TOPIC_TAP"`
+	TopicTapMaxRate  int           `config:"TOPIC_TAP_MAX_RATE"`
+	TopicTapMaxBytes int           `config:"TOPIC_TAP_MAX_BYTES"`
+	TopicTapTTL      time.Duration `config:"TOPIC_TAP_TTL_S"`
+
+	// Pre-shared token robot agents present on /robot_gateway; the
+	// gateway is off while it is empty.
+	RobotGatewayToken string `config:"ROBOT_GATEWAY_TOKEN,secret"`
+}
+
+// Dynamic returns the current hot-reloadable settings. The value is
+// shared: don't modify it.
+func (c *Config) Dynamic() *Dynamic {
+	return c.dynamic.Load()
+}
+
+// TLSEnabled reports whether the app serves HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSSelfSigned
+}
+
+// ValidateTLS checks that the TLS settings form a usable combination.
+func (c *Config) ValidateTLS() error {
+	switch {
+	case (c.TLSCertFile == "") != (c.TLSKeyFile == ""):
+		return fmt.Errorf("TLS_CERT and TLS_KEY must be set together")
+	case c.TLSCertFile != "" && c.TLSSelfSigned:
+		return fmt.Errorf("TLS_SELF_SIGNED cannot be combined with TLS_CERT/TLS_KEY")
+	case c.TLSListenAddr != "" && !c.TLSEnabled():
+		return fmt.Errorf("TLS_LISTEN_ADDR needs TLS_CERT/TLS_KEY or TLS_SELF_SIGNED")
+	case c.TLSListenAddr != "" && c.TLSListenAddr == c.ListenAddr:
+		return fmt.Errorf("TLS_LISTEN_ADDR must differ from LISTEN_ADDR")
+	}
+	return nil
+}
+
+// Load returns configuration from the environment or defaults, with the
+// CONFIG_FILE (if set) merged over it and overrides, settings by name
+// (typically from command-line flags), over both.
+func Load(overrides map[string]string) (*Config, error) {
+	file := os.Getenv("CONFIG_FILE")
+	src, err := readSource(file)
+	if err != nil {
+		return nil, err
+	}
+	src.merge(overrides)
+	c, d := src.build()
+	c.File = file
+	c.overrides = overrides
+	c.dynamic.Store(d)
+	return c, nil
 }
 
-// Load returns configuration from environment or defaults.
-func Load() *Config {
+// build reads every setting from src.
+func (src source) build() (*Config, *Dynamic) {
 	home := os.Getenv("HOME")
 	if home == "" {
 		home = "/home/data"
 	}
 
-	whisperBin := envOr("WHISPER_BIN", filepath.Join(home, "data/app/whisper.cpp/build/bin/whisper-cli"))
-	whisperModel := envOr("WHISPER_MODEL", filepath.Join(home, "data/app/whisper.cpp/models/ggml-base.en.bin"))
-	speechDir := envOr("SPEECH_LOG_DIR", filepath.Join(home, "data/log/wav"))
-
-	return &Config{
-		ListenAddr:        envOr("LISTEN_ADDR", ":8080"),
+	c := &Config{
+		ListenAddr:        src.str("LISTEN_ADDR", ":8080"),
 		RosbridgePort:     9090,
-		WhisperBinPath:    whisperBin,
-		WhisperModelPath:  whisperModel,
-		SpeechLogDir:      speechDir,
+		MapThumbnailDir:   src.str("MAP_THUMBNAIL_DIR", filepath.Join(home, "data/app/map_thumbnails")),
+		MapArchiveDir:     src.str("MAP_ARCHIVE_DIR", filepath.Join(home, "data/app/map_archive")),
+		WebhooksFile:      src.str("WEBHOOKS_FILE", filepath.Join(home, "data/app/webhooks.json")),
+		SpeechPhrasesFile: src.str("SPEECH_PHRASES_FILE", filepath.Join(home, "data/app/speech_phrases.json")),
+		TemplatesFile:     src.str("SETTINGS_TEMPLATES_FILE", filepath.Join(home, "data/app/settings_templates.json")),
+		PointsDir:         src.str("POINTS_DIR", filepath.Join(home, "data/app/points")),
+		UsageDir:          src.str("USAGE_DIR", filepath.Join(home, "data/app/usage")),
+		VisitsDir:         src.str("VISITS_DIR", filepath.Join(home, "data/app/visits")),
 		DefaultLinearMax:  1.0,
 		DefaultAngularMax: 1.0,
+
+		DiscoveryMDNS:        src.str("DISCOVERY_MDNS", "1") != "0",
+		DiscoveryServiceType: src.str("DISCOVERY_MDNS_SERVICE", "_rosbridge._tcp"),
+
+		NavPoseMaxAge:   time.Duration(src.int("NAV_POSE_MAX_AGE_MS", 3000)) * time.Millisecond,
+		PointsSaveDelay: time.Duration(src.int("POINTS_SAVE_DELAY_MS", 2000)) * time.Millisecond,
+		UsageJumpM:      src.float("USAGE_JUMP_M", 0.5),
+		NavMaxDwellSec:  float64(src.int("NAV_MAX_DWELL_SEC", 600)),
+
+		VisitRadiusM:     src.float("VISIT_RADIUS_M", 0.5),
+		VisitHysteresisM: src.float("VISIT_HYSTERESIS_M", 0.3),
+
+		WebhookBatteryAlias:  src.str("WEBHOOK_BATTERY_ALIAS", "battery"),
+		WebhookBatteryLowPct: src.float("WEBHOOK_BATTERY_LOW_PCT", 20),
+
+		NavGoAllMaxDistanceM: src.float("NAV_GO_ALL_MAX_DISTANCE_M", 50),
+
+		PatrolResumeOnReconnect: src.str("PATROL_RESUME_ON_RECONNECT", "1") != "0",
+
+		ClockSkewWarn: time.Duration(src.int("CLOCK_SKEW_WARN_MS", 2000)) * time.Millisecond,
+		ClockSkewJump: time.Duration(src.int("CLOCK_SKEW_JUMP_MS", 1000)) * time.Millisecond,
+
+		AutonomyGating: src.str("AUTONOMY_GATING", "1") != "0",
+
+		JoystickDeadman: time.Duration(src.int("JOYSTICK_DEADMAN_MS", 500)) * time.Millisecond,
+
+		ControlLeaseIdle:     time.Duration(src.int("CONTROL_LEASE_IDLE_S", 60)) * time.Second,
+		ControlLeaseRequired: src.str("CONTROL_LEASE_REQUIRED", "0") != "0",
+
+		FleetProximityMargin:     src.float("FLEET_PROXIMITY_MARGIN_M", 0.5),
+		FleetProximityHysteresis: src.float("FLEET_PROXIMITY_HYSTERESIS_M", 0.2),
+		FleetProximityAutoStop:   src.str("FLEET_PROXIMITY_AUTO_STOP", "0") != "0",
+
+		MQTTBroker:    src.get("MQTT_BROKER"),
+		MQTTClientID:  src.str("MQTT_CLIENT_ID", "rom_go_app"),
+		MQTTUsername:  src.get("MQTT_USERNAME"),
+		MQTTPassword:  src.get("MQTT_PASSWORD"),
+		MQTTPrefix:    src.str("MQTT_TOPIC_PREFIX", "rom"),
+		MQTTQoS:       src.int("MQTT_QOS", 0),
+		MQTTMaxRateHz: src.float("MQTT_MAX_RATE_HZ", 2),
+		MQTTTopics:    src.list("MQTT_TOPICS"),
+		MQTTCommands:  src.str("MQTT_COMMANDS", "0") != "0",
+
+		TaskDiscoveryRequest: src.str("TASK_DISCOVERY_REQUEST", "list_tasks"),
+		StaticTasks:          src.list("STATIC_TASKS"),
+
+		CapabilitiesRequest: src.str("CAPABILITIES_REQUEST", "get_capabilities"),
+
+		MapSaveTimeout:       time.Duration(src.int("MAP_SAVE_TIMEOUT_S", 120)) * time.Second,
+		MapSaveProgressTopic: src.get("MAP_SAVE_PROGRESS_TOPIC"),
+
+		MappingStableM2PerMin: src.float("MAPPING_STABLE_M2_PER_MIN", 1.0),
+		MappingStableFor:      time.Duration(src.int("MAPPING_STABLE_S", 60)) * time.Second,
+
+		MapArchiveMaxVersions: src.int("MAP_ARCHIVE_MAX_VERSIONS", 10),
+		MapArchiveMaxMB:       src.int("MAP_ARCHIVE_MAX_MB", 512),
+
+		DestructiveConfirm: time.Duration(src.int("DESTRUCTIVE_CONFIRM_S", 15)) * time.Second,
+		PowerDowntime:      time.Duration(src.int("POWER_DOWNTIME_S", 180)) * time.Second,
+
+		RelocalizeService: src.str("RELOCALIZE_SERVICE", "/reinitialize_global_localization"),
+		RelocalizeRotate:  time.Duration(src.int("RELOCALIZE_ROTATE_S", 20)) * time.Second,
+		RelocalizeAngular: src.float("RELOCALIZE_ANGULAR", 0.3),
+
+		LocalizationAMCLTopic: src.get("LOCALIZATION_AMCL_TOPIC"),
+
+		IncidentMarkerTopic: src.get("INCIDENT_MARKER_TOPIC"),
+
+		RosbridgeShared: src.str("ROSBRIDGE_SHARED", "0") != "0",
+
+		CORSOrigins:          src.list("CORS_ORIGINS"),
+		CORSAllowCredentials: src.str("CORS_ALLOW_CREDENTIALS", "0") != "0",
+
+		StaticMaxAge: time.Duration(src.int("STATIC_MAX_AGE", 300)) * time.Second,
+
+		TLSCertFile:   src.get("TLS_CERT"),
+		TLSKeyFile:    src.get("TLS_KEY"),
+		TLSSelfSigned: src.str("TLS_SELF_SIGNED", "0") != "0",
+		TLSDir:        src.str("TLS_DIR", filepath.Join(home, "data/app/tls")),
+		TLSHosts:      src.list("TLS_HOSTS"),
+		TLSListenAddr: src.get("TLS_LISTEN_ADDR"),
+
+		AdminListenAddr: src.get("ADMIN_LISTEN_ADDR"),
+		NoUI:            src.str("NO_UI", "0") != "0",
+
+		BrandingDir: src.str("BRANDING_DIR", filepath.Join(home, "data/app/branding")),
+		BasePath:    src.basePath("BASE_PATH"),
 	}
+
+	d := &Dynamic{
+		WhisperBinPath:       src.str("WHISPER_BIN", filepath.Join(home, "data/app/whisper.cpp/build/bin/whisper-cli")),
+		WhisperModelPath:     src.str("WHISPER_MODEL", filepath.Join(home, "data/app/whisper.cpp/models/ggml-base.en.bin")),
+		SpeechLogDir:         src.str("SPEECH_LOG_DIR", filepath.Join(home, "data/log/wav")),
+		WhisperMinConfidence: src.float("WHISPER_MIN_CONFIDENCE", 0.4),
+
+		SpeechMaxUploadMB:   src.int("SPEECH_MAX_UPLOAD_MB", 10),
+		SpeechFFmpegTimeout: time.Duration(src.int("SPEECH_FFMPEG_TIMEOUT_S", 30)) * time.Second,
+		SpeechRetention:     time.Duration(src.int("SPEECH_RETENTION_H", 168)) * time.Hour,
+
+		SpeechBackend:     src.str("SPEECH_BACKEND", "whisper_cli"),
+		SpeechHTTPURL:     src.get("SPEECH_HTTP_URL"),
+		SpeechHTTPAuth:    src.get("SPEECH_HTTP_AUTH"),
+		SpeechHTTPTimeout: time.Duration(src.int("SPEECH_HTTP_TIMEOUT_S", 60)) * time.Second,
+
+		MinWSClientVersion: src.int("WS_MIN_CLIENT_VERSION", 1),
+
+		DiscoverySubnets:     src.list("DISCOVERY_SUBNETS"),
+		DiscoveryConcurrency: src.int("DISCOVERY_CONCURRENCY", 64),
+
+		TopicThrottles: src.rates("TOPIC_THROTTLES"),
+
+		IdleAfter:      time.Duration(src.int("IDLE_AFTER_S", 600)) * time.Second,
+		IdleDropTopics: src.list("IDLE_DROP_TOPICS"),
+		IdleThrottles:  src.rates("IDLE_THROTTLES"),
+
+		StaleThresholds: src.rates("STALE_THRESHOLDS"),
+
+		VelRatioMin: src.float("VEL_RATIO_MIN", 0.05),
+		VelRatioMax: src.float("VEL_RATIO_MAX", 2.0),
+
+		BrandingProductName:  src.str("BRANDING_PRODUCT_NAME", "ROM Dynamics"),
+		BrandingLogo:         src.get("BRANDING_LOGO"),
+		BrandingPrimaryColor: src.get("BRANDING_PRIMARY_COLOR"),
+		UIDisabledFeatures:   src.list("UI_DISABLED_FEATURES"),
+
+		DebugChaos: src.str("DEBUG_CHAOS", "0") != "0",
+
+		DebugRosbridge: src.str("DEBUG_ROSBRIDGE", "0") != "0",
+
+		DebugTopicTap:    src.str("DEBUG_TOPIC_TAP", "0") != "0",
+		TopicTapMaxRate:  src.int("TOPIC_TAP_MAX_RATE", 10),
+		TopicTapMaxBytes: src.int("TOPIC_TAP_MAX_BYTES", 16384),
+		TopicTapTTL:      time.Duration(src.int("TOPIC_TAP_TTL_S", 300)) * time.Second,
+
+		RobotGatewayToken: src.get("ROBOT_GATEWAY_TOKEN"),
+	}
+	return c, d
+}
+
+// source looks settings up in the config file first, then the
+// environment.
+type source map[string]string
+
+// merge sets the given settings over src.
+func (src source) merge(settings map[string]string) {
+	for k, v := range settings {
+		src[k] = v
+	}
+}
+
+func (src source) get(key string) string {
+	if v, ok := src[key]; ok {
+		return v
+	}
+	return os.Getenv(key)
 }
 
-func envOr(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
+func (src source) str(key, fallback string) string {
+	if v := src.get(key); v != "" {
 		return v
 	}
 	return fallback
 }
+
+func (src source) int(key string, fallback int) int {
+	if v := src.get(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func (src source) float(key string, fallback float64) float64 {
+	if v := src.get(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func (src source) list(key string) []string {
+	var out []string
+	for _, v := range strings.Split(src.get(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// basePath reads a URL prefix as "/a/b": leading slash added, trailing
+// slashes and "." or ".." segments removed; "/" is the root, "".
+func (src source) basePath(key string) string {
+	v := strings.TrimSpace(src.get(key))
+	if v == "" {
+		return ""
+	}
+	v = path.Clean("/" + v)
+	if v == "/" {
+		return ""
+	}
+	return v
+}
+
+// rates parses "topic=ms" entries; nil if none are valid.
+func (src source) rates(key string) map[string]int {
+	var out map[string]int
+	for _, v := range src.list(key) {
+		topic, ms, _ := strings.Cut(v, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(ms))
+		if topic = strings.TrimSpace(topic); topic == "" || err != nil || n < 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]int)
+		}
+		out[topic] = n
+	}
+	return out
+}
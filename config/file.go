@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ──────────────────────────── Config file & reload
+//
+// CONFIG_FILE names an optional YAML or JSON file of settings keyed by
+// their environment variable names (any case, - or _): values there win
+// over the environment. Lists may be YAML sequences, TOPIC_THROTTLES a
+// mapping. Reload re-reads the environment and the file, swaps in the new
+// Dynamic settings and reports changed startup settings as needing a
+// restart.
+
+// ReloadResult reports what a reload changed.
+type ReloadResult struct {
+	File            string   `json:"file,omitempty"`
+	Applied         []string `json:"applied"`          // changed dynamic settings, now in effect
+	RestartRequired []string `json:"restart_required"` // changed startup settings, ignored until restart
+}
+
+// Settings is the effective configuration by setting name; secret
+// settings only show whether they are set.
+type Settings struct {
+	File    string                 `json:"file,omitempty"`
+	Static  map[string]interface{} `json:"static"`  // read at startup
+	Dynamic map[string]interface{} `json:"dynamic"` // applied by reload
+}
+
+// readSource parses the config file into a source; an empty path yields
+// an environment-only source.
+func readSource(path string) (source, error) {
+	src := source{}
+	if path == "" {
+		return src, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	known := settingKeys()
+	var unknown []string
+	for k, v := range raw {
+		key := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(k), "-", "_"))
+		if !known[key] {
+			unknown = append(unknown, k)
+			continue
+		}
+		src[key] = settingString(v)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("config file %s: unknown settings %s", path, strings.Join(unknown, ", "))
+	}
+	return src, nil
+}
+
+// settingString flattens a YAML value to the environment variable form.
+func settingString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = settingString(e)
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		parts := make([]string, 0, len(v))
+		for k, e := range v {
+			parts = append(parts, k+"="+settingString(e))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// settingKeys returns the names of every setting.
+func settingKeys() map[string]bool {
+	keys := map[string]bool{}
+	for _, t := range []reflect.Type{reflect.TypeOf((*Config)(nil)).Elem(), reflect.TypeOf((*Dynamic)(nil)).Elem()} {
+		for i := 0; i < t.NumField(); i++ {
+			if name, _, ok := settingTag(t.Field(i)); ok {
+				keys[name] = true
+			}
+		}
+	}
+	return keys
+}
+
+// settingTag returns a field's setting name and whether it is secret.
+func settingTag(f reflect.StructField) (name string, secret, ok bool) {
+	tag := f.Tag.Get("config")
+	if tag == "" || tag == "-" {
+		return "", false, false
+	}
+	name, opt, _ := strings.Cut(tag, ",")
+	return name, opt == "secret", true
+}
+
+// settingValues maps setting names to the values of struct v.
+func settingValues(v reflect.Value) map[string]interface{} {
+	out := map[string]interface{}{}
+	for i := 0; i < v.NumField(); i++ {
+		name, secret, ok := settingTag(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+		val := v.Field(i).Interface()
+		switch {
+		case secret:
+			val = !v.Field(i).IsZero()
+		case v.Field(i).Type() == reflect.TypeOf(time.Duration(0)):
+			val = val.(time.Duration).String()
+		}
+		out[name] = val
+	}
+	return out
+}
+
+// changedSettings lists the settings differing between structs a and b.
+func changedSettings(a, b reflect.Value) []string {
+	out := []string{}
+	for i := 0; i < a.NumField(); i++ {
+		name, _, ok := settingTag(a.Type().Field(i))
+		if ok && !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Effective returns the configuration in effect.
+func (c *Config) Effective() Settings {
+	return Settings{
+		File:    c.File,
+		Static:  settingValues(reflect.ValueOf(c).Elem()),
+		Dynamic: settingValues(reflect.ValueOf(c.Dynamic()).Elem()),
+	}
+}
+
+// Reload re-reads the environment and config file and applies the
+// dynamic settings. On error nothing changes.
+func (c *Config) Reload() (ReloadResult, error) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	src, err := readSource(c.File)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	src.merge(c.overrides)
+	next, d := src.build()
+	res := ReloadResult{
+		File:            c.File,
+		Applied:         changedSettings(reflect.ValueOf(c.Dynamic()).Elem(), reflect.ValueOf(d).Elem()),
+		RestartRequired: changedSettings(reflect.ValueOf(c).Elem(), reflect.ValueOf(next).Elem()),
+	}
+	c.dynamic.Store(d)
+	return res, nil
+}
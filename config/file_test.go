@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestReloadFromFile edits the config file between reloads: dynamic
+// settings take effect, startup ones are only reported, and a file that
+// doesn't parse changes nothing.
+func TestReloadFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(body string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("listen_addr: ':8080'\ndebug_chaos: false\ntopic_throttles:\n  odom: 100\n")
+	t.Setenv("CONFIG_FILE", path)
+	c, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ListenAddr != ":8080" || c.Dynamic().DebugChaos || c.Dynamic().TopicThrottles["odom"] != 100 {
+		t.Fatalf("loaded listen %q, dynamic %+v", c.ListenAddr, c.Dynamic())
+	}
+
+	write("listen-addr: ':9090'\nDEBUG_CHAOS: true\ntopic_throttles:\n  odom: 50\nws_min_client_version: 3\n")
+	res, err := c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ReloadResult{
+		File:            path,
+		Applied:         []string{"DEBUG_CHAOS", "TOPIC_THROTTLES", "WS_MIN_CLIENT_VERSION"},
+		RestartRequired: []string{"LISTEN_ADDR"},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("reload %+v, want %+v", res, want)
+	}
+	d := c.Dynamic()
+	if !d.DebugChaos || d.TopicThrottles["odom"] != 50 || d.MinWSClientVersion != 3 {
+		t.Errorf("dynamic after reload %+v", d)
+	}
+	if c.ListenAddr != ":8080" {
+		t.Errorf("listen address changed to %q before a restart", c.ListenAddr)
+	}
+
+	for name, body := range map[string]string{
+		"bad YAML":        "debug_chaos: false\ntopic_throttles: [\n",
+		"unknown setting": "debug_chaos: false\nlisten_adress: ':1'\n",
+	} {
+		write(body)
+		if _, err := c.Reload(); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: reload error %v", name, err)
+		}
+		if c.Dynamic() != d || !d.DebugChaos {
+			t.Errorf("%s: dynamic settings replaced", name)
+		}
+	}
+
+	os.Remove(path)
+	if _, err := c.Reload(); err == nil || c.Dynamic() != d {
+		t.Errorf("missing file: %v", err)
+	}
+}
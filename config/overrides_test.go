@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+// TestOverridesSurviveReload loads command-line overrides over the
+// environment; a reload keeps them and reports nothing changed.
+func TestOverridesSurviveReload(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LISTEN_ADDR", ":8080")
+	t.Setenv("NO_UI", "0")
+	c, err := Load(map[string]string{"LISTEN_ADDR": ":9000", "ADMIN_LISTEN_ADDR": "127.0.0.1:9100", "NO_UI": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ListenAddr != ":9000" || c.AdminListenAddr != "127.0.0.1:9100" || !c.NoUI {
+		t.Fatalf("overrides not applied: listen %q admin %q no_ui %v", c.ListenAddr, c.AdminListenAddr, c.NoUI)
+	}
+
+	res, err := c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.RestartRequired) != 0 || len(res.Applied) != 0 {
+		t.Errorf("reload reported changes: %+v", res)
+	}
+
+	// A setting not overridden still reports its change
+	t.Setenv("ADMIN_LISTEN_ADDR", ":1")
+	t.Setenv("MAP_ARCHIVE_DIR", "/tmp/elsewhere")
+	res, _ = c.Reload()
+	if len(res.RestartRequired) != 1 || res.RestartRequired[0] != "MAP_ARCHIVE_DIR" {
+		t.Errorf("restart required for %v, want MAP_ARCHIVE_DIR only", res.RestartRequired)
+	}
+
+	if c, _ := Load(nil); c.NoUI || c.AdminListenAddr != ":1" || c.ListenAddr != ":8080" {
+		t.Errorf("without overrides: listen %q admin %q no_ui %v", c.ListenAddr, c.AdminListenAddr, c.NoUI)
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateTLS goes through the listener settings: plain HTTP, HTTPS
+// on LISTEN_ADDR, and HTTPS on TLS_LISTEN_ADDR with LISTEN_ADDR
+// redirecting, with a certificate pair or a self-signed one.
+func TestValidateTLS(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	for _, tc := range []struct {
+		name     string
+		settings map[string]string
+		enabled  bool
+		err      string
+	}{
+		{"plain HTTP", map[string]string{}, false, ""},
+		{"certificate", map[string]string{"TLS_CERT": "c.pem", "TLS_KEY": "k.pem"}, true, ""},
+		{"self-signed", map[string]string{"TLS_SELF_SIGNED": "1"}, true, ""},
+		{"self-signed with redirect", map[string]string{"TLS_SELF_SIGNED": "1", "LISTEN_ADDR": ":8080", "TLS_LISTEN_ADDR": ":8443"}, true, ""},
+		{"certificate with redirect", map[string]string{"TLS_CERT": "c.pem", "TLS_KEY": "k.pem", "TLS_LISTEN_ADDR": ":443"}, true, ""},
+		{"cert without key", map[string]string{"TLS_CERT": "c.pem"}, true, "set together"},
+		{"key without cert", map[string]string{"TLS_KEY": "k.pem"}, false, "set together"},
+		{"both kinds", map[string]string{"TLS_CERT": "c.pem", "TLS_KEY": "k.pem", "TLS_SELF_SIGNED": "1"}, true, "cannot be combined"},
+		{"TLS listener without TLS", map[string]string{"TLS_LISTEN_ADDR": ":8443"}, false, "needs TLS_CERT"},
+		{"same listener twice", map[string]string{"TLS_SELF_SIGNED": "1", "LISTEN_ADDR": ":8443", "TLS_LISTEN_ADDR": ":8443"}, true, "must differ"},
+	} {
+		cfg, err := Load(tc.settings)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if cfg.TLSEnabled() != tc.enabled {
+			t.Errorf("%s: TLS enabled %v", tc.name, cfg.TLSEnabled())
+		}
+		err = cfg.ValidateTLS()
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheTTL is how long a scan result is reused before rescanning.
+const CacheTTL = time.Minute
+
+// ScanResult is a cached subnet scan.
+type ScanResult struct {
+	Subnets    []string    `json:"subnets"`
+	Port       int         `json:"port"`
+	Candidates []Candidate `json:"candidates"`
+	ScannedAt  time.Time   `json:"scanned_at"`
+	Duration   float64     `json:"duration_seconds"`
+	Cached     bool        `json:"cached"`
+}
+
+// Service runs scans with a short-lived cache and collects robots found
+// by the background mDNS browser.
+type Service struct {
+	mu          sync.Mutex
+	scanMu      sync.Mutex // one scan at a time
+	last        *ScanResult
+	lastKey     string
+	mdns        map[string]Candidate // by Key()
+	OnMDNSFound func(Candidate)
+}
+
+// NewService creates an empty discovery service.
+func NewService() *Service {
+	return &Service{mdns: map[string]Candidate{}}
+}
+
+// Scan returns the cached result for the same subnets/port if it is
+// younger than CacheTTL, otherwise runs a new scan.
+func (s *Service) Scan(ctx context.Context, opts ScanOptions, refresh bool) (*ScanResult, error) {
+	if opts.Port <= 0 {
+		opts.Port = 9090
+	}
+	key := strings.Join(opts.Subnets, ",") + "|" + strconv.Itoa(opts.Port)
+
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	if !refresh {
+		if res := s.cached(key); res != nil {
+			return res, nil
+		}
+	}
+
+	start := time.Now()
+	found, err := Scan(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	subnets := opts.Subnets
+	if len(subnets) == 0 {
+		subnets = LocalSubnets()
+	}
+	res := &ScanResult{
+		Subnets:    subnets,
+		Port:       opts.Port,
+		Candidates: found,
+		ScannedAt:  start,
+		Duration:   time.Since(start).Seconds(),
+	}
+
+	s.mu.Lock()
+	s.last, s.lastKey = res, key
+	s.mu.Unlock()
+
+	out := *res
+	out.Candidates = append([]Candidate(nil), res.Candidates...)
+	return &out, nil
+}
+
+func (s *Service) cached(key string) *ScanResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil || s.lastKey != key || time.Since(s.last.ScannedAt) > CacheTTL {
+		return nil
+	}
+	out := *s.last
+	out.Candidates = append([]Candidate(nil), s.last.Candidates...)
+	out.Cached = true
+	return &out
+}
+
+// Last returns the most recent scan if it is still within CacheTTL.
+func (s *Service) Last() *ScanResult {
+	s.mu.Lock()
+	key := s.lastKey
+	s.mu.Unlock()
+	return s.cached(key)
+}
+
+// MDNS returns robots announced over mDNS, sorted by IP.
+func (s *Service) MDNS() []Candidate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Candidate, 0, len(s.mdns))
+	for _, c := range s.mdns {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key() < out[j].Key() })
+	return out
+}
+
+// StartMDNS runs a Browser for serviceType in the background. New
+// instances are recorded and passed to OnMDNSFound.
+func (s *Service) StartMDNS(ctx context.Context, serviceType string) {
+	b := NewBrowser(serviceType, func(c Candidate) {
+		s.mu.Lock()
+		_, known := s.mdns[c.Key()]
+		s.mdns[c.Key()] = c
+		s.mu.Unlock()
+		if !known {
+			log.Printf("[discovery] mDNS: %s at %s", c.Name, c.Key())
+			if s.OnMDNSFound != nil {
+				s.OnMDNSFound(c)
+			}
+		}
+	})
+	go func() {
+		if err := b.Run(ctx); err != nil {
+			log.Printf("[discovery] mDNS browser stopped: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DefaultServiceType is the mDNS service type robots announce rosbridge
+// under.
+const DefaultServiceType = "_rosbridge._tcp"
+
+const mdnsQueryInterval = 30 * time.Second
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Browser listens for mDNS announcements of a service type and reports
+// each instance once it has resolved an address and port.
+type Browser struct {
+	service string // fully qualified, e.g. "_rosbridge._tcp.local."
+	onFound func(Candidate)
+
+	// Partially resolved records, keyed by DNS name.
+	instances map[string]bool    // PTR targets
+	srv       map[string]srvInfo // instance → target host, port
+	addrs     map[string]net.IP  // host → IPv4
+	reported  map[string]bool    // instance already passed to onFound
+}
+
+type srvInfo struct {
+	target string
+	port   int
+}
+
+// NewBrowser creates a Browser for serviceType (e.g. "_rosbridge._tcp").
+// onFound is called from the Run goroutine.
+func NewBrowser(serviceType string, onFound func(Candidate)) *Browser {
+	if serviceType == "" {
+		serviceType = DefaultServiceType
+	}
+	return &Browser{
+		service:   strings.TrimSuffix(serviceType, ".") + ".local.",
+		onFound:   onFound,
+		instances: map[string]bool{},
+		srv:       map[string]srvInfo{},
+		addrs:     map[string]net.IP{},
+		reported:  map[string]bool{},
+	}
+}
+
+// Run joins the mDNS group, queries periodically and processes responses
+// until ctx is cancelled.
+func (b *Browser) Run(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		t := time.NewTicker(mdnsQueryInterval)
+		defer t.Stop()
+		for {
+			b.query(conn)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		b.handlePacket(buf[:n])
+	}
+}
+
+func (b *Browser) query(conn *net.UDPConn) {
+	name, err := dnsmessage.NewName(b.service)
+	if err != nil {
+		return
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	pkt, err := msg.Pack()
+	if err != nil {
+		return
+	}
+	if _, err := conn.WriteToUDP(pkt, mdnsGroup); err != nil {
+		log.Printf("[discovery] mDNS query: %v", err)
+	}
+}
+
+func (b *Browser) handlePacket(pkt []byte) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(pkt); err != nil || !msg.Header.Response {
+		return
+	}
+
+	records := append(append(msg.Answers, msg.Authorities...), msg.Additionals...)
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header.Name.String())
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name == strings.ToLower(b.service) {
+				b.instances[strings.ToLower(body.PTR.String())] = true
+			}
+		case *dnsmessage.SRVResource:
+			b.srv[name] = srvInfo{target: strings.ToLower(body.Target.String()), port: int(body.Port)}
+		case *dnsmessage.AResource:
+			b.addrs[name] = net.IP(body.A[:])
+		}
+	}
+
+	for inst := range b.instances {
+		if b.reported[inst] {
+			continue
+		}
+		s, ok := b.srv[inst]
+		if !ok {
+			continue
+		}
+		ip, ok := b.addrs[s.target]
+		if !ok {
+			continue
+		}
+		b.reported[inst] = true
+		if b.onFound != nil {
+			b.onFound(Candidate{
+				IP:     ip.String(),
+				Port:   s.port,
+				Name:   instanceLabel(inst, b.service),
+				Source: "mdns",
+				SeenAt: time.Now(),
+			})
+		}
+	}
+}
+
+// instanceLabel strips the service suffix: "robot1._rosbridge._tcp.local."
+// → "robot1".
+func instanceLabel(instance, service string) string {
+	label := strings.TrimSuffix(instance, "."+strings.ToLower(service))
+	return strings.ReplaceAll(label, `\ `, " ")
+}
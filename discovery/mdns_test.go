@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// mdnsResponse packs a response with the records given.
+func mdnsResponse(t *testing.T, records ...dnsmessage.Resource) []byte {
+	t.Helper()
+	msg := dnsmessage.Message{Header: dnsmessage.Header{Response: true, Authoritative: true}, Answers: records}
+	pkt, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkt
+}
+
+func rr(name string, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: 120},
+		Body:   body,
+	}
+}
+
+// TestBrowser resolves an instance announced over several packets, in
+// the order PTR, SRV, A, and one announced in a single packet.
+func TestBrowser(t *testing.T) {
+	var found []Candidate
+	b := NewBrowser("", func(c Candidate) { found = append(found, c) })
+
+	ptr := rr("_rosbridge._tcp.local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(`AMR\ 1._rosbridge._tcp.local.`)})
+	srv := rr(`AMR\ 1._rosbridge._tcp.local.`, &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("amr1.local."), Port: 9090})
+	a := rr("amr1.local.", &dnsmessage.AResource{A: [4]byte{10, 0, 0, 7}})
+
+	b.handlePacket(mdnsResponse(t, ptr))
+	b.handlePacket(mdnsResponse(t, srv))
+	if len(found) != 0 {
+		t.Fatalf("reported before the address: %+v", found)
+	}
+	b.handlePacket(mdnsResponse(t, a))
+	if len(found) != 1 || found[0].IP != "10.0.0.7" || found[0].Port != 9090 || found[0].Name != "amr 1" || found[0].Source != "mdns" {
+		t.Fatalf("found %+v", found)
+	}
+	// Announced again: reported once
+	b.handlePacket(mdnsResponse(t, ptr, srv, a))
+	if len(found) != 1 {
+		t.Errorf("reported again: %+v", found)
+	}
+
+	// Another service type, a query and garbage are ignored
+	b.handlePacket(mdnsResponse(t,
+		rr("_http._tcp.local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("web._http._tcp.local.")}),
+		rr("web._http._tcp.local.", &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("amr1.local."), Port: 80})))
+	query, _ := (&dnsmessage.Message{Answers: []dnsmessage.Resource{
+		rr("_rosbridge._tcp.local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("fake._rosbridge._tcp.local.")}),
+	}}).Pack()
+	b.handlePacket(query)
+	b.handlePacket([]byte("not dns"))
+
+	b.handlePacket(mdnsResponse(t,
+		rr("_rosbridge._tcp.local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("amr2._rosbridge._tcp.local.")}),
+		rr("amr2._rosbridge._tcp.local.", &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("amr2.local."), Port: 9091}),
+		rr("amr2.local.", &dnsmessage.AResource{A: [4]byte{10, 0, 0, 8}})))
+	if len(found) != 2 || found[1].Name != "amr2" || found[1].Key() != "10.0.0.8:9091" {
+		t.Errorf("found %+v", found)
+	}
+}
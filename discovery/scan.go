@@ -0,0 +1,232 @@
+// Package discovery finds rosbridge servers on the local network, either
+// by scanning subnets for the rosbridge port or by listening for mDNS
+// announcements.
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// Limits that keep a mistyped CIDR from turning into a network sweep.
+const (
+	maxScanHosts       = 4096
+	defaultConcurrency = 64
+	defaultDialTimeout = 400 * time.Millisecond
+	probeTimeout       = 3 * time.Second
+)
+
+// Candidate is a host that answered on the rosbridge port.
+type Candidate struct {
+	IP         string    `json:"ip"`
+	Port       int       `json:"port"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	Diameter   float64   `json:"diameter,omitempty"`
+	Handshake  bool      `json:"handshake"`
+	Error      string    `json:"error,omitempty"`
+	Source     string    `json:"source"` // "scan" or "mdns"
+	Registered bool      `json:"registered"`
+	SeenAt     time.Time `json:"seen_at"`
+}
+
+// Key identifies a candidate by address.
+func (c Candidate) Key() string {
+	return net.JoinHostPort(c.IP, strconv.Itoa(c.Port))
+}
+
+// ScanOptions configures a subnet scan.
+type ScanOptions struct {
+	Subnets     []string // CIDRs; empty = local interface subnets
+	Port        int
+	Concurrency int
+	DialTimeout time.Duration
+	Handshake   bool // probe which_name for namespace / diameter
+}
+
+// Scan dials every host in the subnets on the rosbridge port with bounded
+// concurrency and returns the hosts that accepted, sorted by IP.
+func Scan(ctx context.Context, opts ScanOptions) ([]Candidate, error) {
+	if opts.Port <= 0 {
+		opts.Port = 9090
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaultDialTimeout
+	}
+
+	subnets := opts.Subnets
+	if len(subnets) == 0 {
+		subnets = LocalSubnets()
+		if len(subnets) == 0 {
+			return nil, fmt.Errorf("no local IPv4 subnets found; pass subnets explicitly")
+		}
+	}
+
+	var hosts []net.IP
+	for _, cidr := range subnets {
+		h, err := ExpandCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h...)
+		if len(hosts) > maxScanHosts {
+			return nil, fmt.Errorf("scan covers more than %d hosts", maxScanHosts)
+		}
+	}
+
+	var (
+		mu    sync.Mutex
+		found []Candidate
+		wg    sync.WaitGroup
+		sem   = make(chan struct{}, opts.Concurrency)
+	)
+	dialer := net.Dialer{Timeout: opts.DialTimeout}
+
+	for _, ip := range hosts {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return found, ctx.Err()
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			addr := net.JoinHostPort(ip, strconv.Itoa(opts.Port))
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return
+			}
+			conn.Close()
+
+			c := Candidate{IP: ip, Port: opts.Port, Name: ip, Source: "scan", SeenAt: time.Now()}
+			if opts.Handshake {
+				probe(&c)
+			}
+			mu.Lock()
+			found = append(found, c)
+			mu.Unlock()
+		}(ip.String())
+	}
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool {
+		return ipLess(net.ParseIP(found[i].IP), net.ParseIP(found[j].IP))
+	})
+	return found, nil
+}
+
+// probe fills namespace / diameter from a rosbridge handshake.
+func probe(c *Candidate) {
+	res, err := rosbridge.Probe(c.IP, c.Port, probeTimeout)
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	c.Handshake = true
+	c.Namespace = res.Namespace
+	c.Diameter = res.Diameter
+	if res.Namespace != "" {
+		c.Name = res.Namespace
+	}
+}
+
+// ExpandCIDR returns the usable host addresses of an IPv4 CIDR (network
+// and broadcast addresses excluded for prefixes shorter than /31). A bare
+// address is treated as /32.
+func ExpandCIDR(cidr string) ([]net.IP, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address %q", cidr)
+		}
+		return []net.IP{ip}, nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %q", cidr)
+	}
+	base := ipnet.IP.To4()
+	if base == nil {
+		return nil, fmt.Errorf("subnet %q is not IPv4", cidr)
+	}
+	ones, bits := ipnet.Mask.Size()
+	size := uint64(1) << uint(bits-ones)
+	if size > maxScanHosts {
+		return nil, fmt.Errorf("subnet %q is larger than %d hosts", cidr, maxScanHosts)
+	}
+
+	start := binary.BigEndian.Uint32(base)
+	first, last := uint64(0), size
+	if size > 2 {
+		first, last = 1, size-1
+	}
+	out := make([]net.IP, 0, last-first)
+	for i := first; i < last; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, start+uint32(i))
+		out = append(out, ip)
+	}
+	return out, nil
+}
+
+// LocalSubnets returns the IPv4 subnets of non-loopback interfaces that
+// are up. Subnets larger than /24 are narrowed to the /24 around the
+// interface address.
+func LocalSubnets() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			ones, _ := ipnet.Mask.Size()
+			if ones < 24 {
+				ones = 24
+			}
+			mask := net.CIDRMask(ones, 32)
+			n := net.IPNet{IP: ipnet.IP.To4().Mask(mask), Mask: mask}
+			if s := n.String(); !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func ipLess(a, b net.IP) bool {
+	a4, b4 := a.To4(), b.To4()
+	if a4 == nil || b4 == nil {
+		return a.String() < b.String()
+	}
+	return binary.BigEndian.Uint32(a4) < binary.BigEndian.Uint32(b4)
+}
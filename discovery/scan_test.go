@@ -0,0 +1,128 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestExpandCIDR(t *testing.T) {
+	for _, c := range []struct {
+		cidr        string
+		n           int
+		first, last string
+	}{
+		{"10.0.0.7", 1, "10.0.0.7", "10.0.0.7"},
+		{"10.0.0.7/32", 1, "10.0.0.7", "10.0.0.7"},
+		{"10.0.0.6/31", 2, "10.0.0.6", "10.0.0.7"},
+		{"10.0.0.0/30", 2, "10.0.0.1", "10.0.0.2"},
+		{"192.168.1.77/24", 254, "192.168.1.1", "192.168.1.254"},
+		{"10.0.0.0/20", 4094, "10.0.0.1", "10.0.15.254"},
+	} {
+		ips, err := ExpandCIDR(c.cidr)
+		if err != nil {
+			t.Errorf("%s: %v", c.cidr, err)
+			continue
+		}
+		if len(ips) != c.n || ips[0].String() != c.first || ips[len(ips)-1].String() != c.last {
+			t.Errorf("%s: %d hosts, %v to %v", c.cidr, len(ips), ips[0], ips[len(ips)-1])
+		}
+	}
+	for _, bad := range []string{"", "robot", "10.0.0.256", "10.0.0.0/33", "10.0.0.0/19", "fd00::/120", "fd00::1"} {
+		if _, err := ExpandCIDR(bad); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestIPLess(t *testing.T) {
+	ips := []string{"10.0.0.2", "10.0.0.10", "9.255.255.255"}
+	if !ipLess(net.ParseIP(ips[0]), net.ParseIP(ips[1])) || !ipLess(net.ParseIP(ips[2]), net.ParseIP(ips[0])) || ipLess(net.ParseIP(ips[1]), net.ParseIP(ips[0])) {
+		t.Error("not in numeric order")
+	}
+}
+
+// listen returns the port of a TCP listener on 127.0.0.1 that accepts
+// and drops connections.
+func listen(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestScan(t *testing.T) {
+	port := listen(t)
+	found, err := Scan(context.Background(), ScanOptions{Subnets: []string{"127.0.0.1", " 127.0.0.2 "}, Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only 127.0.0.1 listens
+	if len(found) != 1 || found[0].IP != "127.0.0.1" || found[0].Port != port || found[0].Source != "scan" || found[0].Handshake {
+		t.Fatalf("found %+v", found)
+	}
+
+	// Answers, but not as rosbridge
+	found, _ = Scan(context.Background(), ScanOptions{Subnets: []string{"127.0.0.1"}, Port: port, Handshake: true})
+	if len(found) != 1 || found[0].Handshake || found[0].Error == "" || found[0].Name != "127.0.0.1" {
+		t.Errorf("probed %+v", found)
+	}
+
+	if _, err := Scan(context.Background(), ScanOptions{Subnets: []string{"10.0.0.0/21", "10.1.0.0/21", "10.2.0.0/24"}, Port: port}); err == nil {
+		t.Error("scanned more than the host limit")
+	}
+	if _, err := Scan(context.Background(), ScanOptions{Subnets: []string{"robot"}}); err == nil {
+		t.Error("scanned an invalid subnet")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Scan(ctx, ScanOptions{Subnets: []string{"127.0.0.0/24"}, Port: port, Concurrency: 1}); err != context.Canceled {
+		t.Errorf("cancelled scan: %v", err)
+	}
+}
+
+func TestServiceScanCache(t *testing.T) {
+	port := listen(t)
+	s := NewService()
+	if s.Last() != nil {
+		t.Error("a result before any scan")
+	}
+	opts := ScanOptions{Subnets: []string{"127.0.0.1"}, Port: port}
+	first, err := s.Scan(context.Background(), opts, false)
+	if err != nil || first.Cached || len(first.Candidates) != 1 {
+		t.Fatalf("first scan %+v, %v", first, err)
+	}
+	again, _ := s.Scan(context.Background(), opts, false)
+	if !again.Cached || !again.ScannedAt.Equal(first.ScannedAt) {
+		t.Errorf("second scan %+v", again)
+	}
+	// A copy: changing it leaves the cache alone
+	again.Candidates[0].Name = "changed"
+	if last := s.Last(); !last.Cached || last.Candidates[0].Name != "127.0.0.1" {
+		t.Errorf("last %+v", last)
+	}
+
+	if fresh, _ := s.Scan(context.Background(), opts, true); fresh.Cached {
+		t.Error("refresh served from the cache")
+	}
+	other := ScanOptions{Subnets: []string{"127.0.0.2"}, Port: port}
+	if res, _ := s.Scan(context.Background(), other, false); res.Cached || len(res.Candidates) != 0 {
+		t.Errorf("other subnet %+v", res)
+	}
+	if res, _ := s.Scan(context.Background(), ScanOptions{Subnets: []string{"127.0.0.1"}}, false); res.Port != 9090 || fmt.Sprint(res.Subnets) != "[127.0.0.1]" {
+		t.Errorf("default port %+v", res)
+	}
+}
@@ -2,6 +2,9 @@ module rom_go_app
 
 go 1.21
 
-require github.com/gorilla/websocket v1.5.1
-
-require golang.org/x/net v0.17.0 // indirect
+require (
+	github.com/gorilla/websocket v1.5.1
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.13.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rom_go_app/robot"
+)
+
+// serveStatus returns the status mux answers GET target with.
+func serveStatus(h http.Handler, target string) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec.Code
+}
+
+// TestAdminRoutes checks the admin listener serves the API and probes
+// only, with or without the UI.
+func TestAdminRoutes(t *testing.T) {
+	for _, noUI := range []bool{false, true} {
+		s := newTestServer(t)
+		s.NoUI = noUI
+		routes := AdminRoutes(s.Routes())
+		for _, rt := range routes {
+			if !strings.HasPrefix(rt.Path, "/api/") && rt.Path != "/metrics" && rt.Path != "/healthz" {
+				t.Errorf("noUI=%v: admin route %s %s", noUI, rt.Method, rt.Path)
+			}
+		}
+		mux := http.NewServeMux()
+		Register(mux, routes)
+		for target, want := range map[string]int{
+			"/api/robots":           http.StatusOK,
+			"/healthz":              http.StatusOK,
+			"/metrics":              http.StatusOK,
+			"/":                     http.StatusNotFound,
+			"/static/css/style.css": http.StatusNotFound,
+			"/partial/robots":       http.StatusNotFound,
+			"/dialog/add_robot":     http.StatusNotFound,
+			"/ws":                   http.StatusNotFound,
+			"/readyz":               http.StatusNotFound,
+		} {
+			if got := serveStatus(mux, target); got != want {
+				t.Errorf("noUI=%v: admin GET %s = %d, want %d", noUI, target, got, want)
+			}
+		}
+	}
+}
+
+// TestNoUIServer wires a server as main does with --no-ui: no templates
+// or static assets, and no page routes.
+func TestNoUIServer(t *testing.T) {
+	s := &Server{Manager: robot.NewManager(), NavManager: robot.NewNavigationManager(), NoUI: true}
+	routes := s.Routes()
+	mux := http.NewServeMux()
+	Register(mux, routes)
+
+	for _, rt := range routes {
+		if isUIRoute(rt) {
+			t.Errorf("UI route %s %s registered", rt.Method, rt.Path)
+		}
+	}
+	for target, want := range map[string]int{
+		"/api/robots":           http.StatusOK,
+		"/healthz":              http.StatusOK,
+		"/readyz":               http.StatusOK,
+		"/":                     http.StatusNotFound,
+		"/static/css/style.css": http.StatusNotFound,
+		"/partial/settings":     http.StatusNotFound,
+		"/dialog/add_robot":     http.StatusNotFound,
+	} {
+		if got := serveStatus(mux, target); got != want {
+			t.Errorf("GET %s = %d, want %d", target, got, want)
+		}
+	}
+
+	// The spec lists the API only
+	rec := getReq(mux.ServeHTTP, "/api/spec")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"/partial/`) || !strings.Contains(rec.Body.String(), `"/api/robots"`) {
+		t.Errorf("spec: %d, lists UI routes or misses the API", rec.Code)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ──────────────────── Branding & UI features ────────────────────
+//
+// One binary serves several white-labelled deployments. Each sets its
+// product name, logo and accent color, and may switch UI features off
+// (UI_DISABLED_FEATURES). A disabled feature's controls are left out of
+// the page and its routes answer 403, so hiding a button isn't the only
+// protection. The logo and any other deployment files live in
+// BRANDING_DIR and are served under /branding/; names the directory
+// doesn't have come from the embedded static files.
+
+// UI features that can be switched off.
+const (
+	FeatureSpeech   = "speech"   // speech panel and transcription
+	FeatureMapping  = "mapping"  // mapping/remapping modes and sessions
+	FeaturePowerOff = "poweroff" // robot power off
+	FeatureFleet    = "fleet"    // fleet proximity monitor
+)
+
+// UIFeatures lists every feature, all enabled unless switched off.
+var UIFeatures = []string{FeatureSpeech, FeatureMapping, FeaturePowerOff, FeatureFleet}
+
+// UIConfig is the deployment's branding and enabled features, for the
+// pages and GET /api/ui_config.
+type UIConfig struct {
+	ProductName  string          `json:"product_name"`
+	LogoURL      string          `json:"logo_url,omitempty"`
+	PrimaryColor string          `json:"primary_color,omitempty"`
+	Features     map[string]bool `json:"features"`
+}
+
+// Enabled reports whether a feature is on.
+func (u UIConfig) Enabled(feature string) bool {
+	return u.Features[feature]
+}
+
+var cssColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// uiConfig returns the UI configuration for the current settings. A
+// color that isn't #rgb or #rrggbb is ignored.
+func (s *Server) uiConfig() UIConfig {
+	u := UIConfig{ProductName: "ROM Dynamics", Features: make(map[string]bool, len(UIFeatures))}
+	for _, f := range UIFeatures {
+		u.Features[f] = true
+	}
+	if s.Config == nil {
+		return u
+	}
+	d := s.Config.Dynamic()
+	u.ProductName = d.BrandingProductName
+	if logo := strings.TrimPrefix(path.Clean("/"+d.BrandingLogo), "/"); d.BrandingLogo != "" && logo != "" {
+		u.LogoURL = s.Config.BasePath + "/branding/" + logo
+	}
+	if cssColor.MatchString(d.BrandingPrimaryColor) {
+		u.PrimaryColor = d.BrandingPrimaryColor
+	}
+	for _, f := range d.UIDisabledFeatures {
+		if _, ok := u.Features[f]; ok {
+			u.Features[f] = false
+		}
+	}
+	return u
+}
+
+// requireFeature answers 403 instead of calling h while feature is off.
+func (s *Server) requireFeature(feature string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.uiConfig().Enabled(feature) {
+			jsonError(w, feature+" is disabled on this deployment", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// GetUIConfig handles GET /api/ui_config
+//
+// Product name, logo URL, accent color and which features are enabled,
+// for scripts deciding what to show.
+func (s *Server) GetUIConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jsonOK(w, s.uiConfig())
+}
+
+// overlayFS opens names in upper first and falls back to lower when
+// upper doesn't have them.
+type overlayFS struct {
+	upper, lower fs.FS
+}
+
+// NewOverlayFS returns a file system resolving names in upper, then in
+// lower. Either may be nil.
+func NewOverlayFS(upper, lower fs.FS) fs.FS {
+	return overlayFS{upper: upper, lower: lower}
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if o.upper != nil {
+		f, err := o.upper.Open(name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	if o.lower == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.lower.Open(name)
+}
+
+// brandingFiles serves the files of fsys (mount it behind
+// http.StripPrefix("/branding/", ...)) with a max-age; directories are
+// not listed.
+type brandingFiles struct {
+	fsys   fs.FS
+	maxAge string
+}
+
+func (b brandingFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if !fs.ValidPath(name) || name == "." {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := b.fsys.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		rs = bytes.NewReader(data)
+	}
+	w.Header().Set("Cache-Control", "public, max-age="+b.maxAge)
+	http.ServeContent(w, r, name, info.ModTime(), rs)
+}
+
+// brandingHandler serves Server.Branding, or nothing without it.
+func (s *Server) brandingHandler() http.Handler {
+	if s.Branding == nil {
+		return http.NotFoundHandler()
+	}
+	maxAge := 300
+	if s.Config != nil {
+		maxAge = int(s.Config.StaticMaxAge.Seconds())
+	}
+	return http.StripPrefix("/branding/", brandingFiles{fsys: s.Branding, maxAge: strconv.Itoa(maxAge)})
+}
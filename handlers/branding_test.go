@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"rom_go_app/config"
+	"rom_go_app/robot"
+)
+
+func TestOverlayFS(t *testing.T) {
+	upper := fstest.MapFS{
+		"logo.svg":      {Data: []byte("deployment logo")},
+		"js/speech.js":  {Data: []byte("deployment speech")},
+		"css/extra.css": {Data: []byte("extra")},
+	}
+	lower := fstest.MapFS{
+		"js/speech.js": {Data: []byte("embedded speech")},
+		"js/app.js":    {Data: []byte("embedded app")},
+	}
+	read := func(fsys fs.FS, name string) string {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	}
+
+	o := NewOverlayFS(upper, lower)
+	for name, want := range map[string]string{
+		"logo.svg":      "deployment logo",
+		"js/speech.js":  "deployment speech", // the directory wins
+		"js/app.js":     "embedded app",
+		"css/extra.css": "extra",
+	} {
+		if got := read(o, name); got != want {
+			t.Errorf("%s: %q, want %q", name, got, want)
+		}
+	}
+	if _, err := o.Open("missing.js"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing: %v", err)
+	}
+
+	// Either side may be missing
+	if got := read(NewOverlayFS(nil, lower), "js/speech.js"); got != "embedded speech" {
+		t.Errorf("no upper: %q", got)
+	}
+	if got := read(NewOverlayFS(upper, nil), "logo.svg"); got != "deployment logo" {
+		t.Errorf("no lower: %q", got)
+	}
+	if _, err := NewOverlayFS(upper, nil).Open("js/app.js"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("no lower, missing: %v", err)
+	}
+}
+
+func TestBrandingFiles(t *testing.T) {
+	s := &Server{Branding: NewOverlayFS(
+		fstest.MapFS{"logo.svg": {Data: []byte("<svg/>")}, "js/speech.js": {Data: []byte("deployment")}},
+		fstest.MapFS{"js/speech.js": {Data: []byte("embedded")}, "js/app.js": {Data: []byte("app")}},
+	)}
+	mux := http.NewServeMux()
+	mux.Handle("/branding/", s.brandingHandler())
+
+	for target, want := range map[string]string{
+		"/branding/logo.svg":     "<svg/>",
+		"/branding/js/speech.js": "deployment",
+		"/branding/js/app.js":    "app",
+	} {
+		rec := getReq(mux.ServeHTTP, target)
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("%s: %d %q, want %q", target, rec.Code, rec.Body, want)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+			t.Errorf("%s: Cache-Control %q", target, cc)
+		}
+	}
+	for _, target := range []string{"/branding/", "/branding/js", "/branding/js/", "/branding/missing.svg", "/branding/../go.mod"} {
+		if rec := getReq(mux.ServeHTTP, target); rec.Code != http.StatusNotFound && rec.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: %d", target, rec.Code)
+		} else if strings.Contains(rec.Body.String(), "speech.js") {
+			t.Errorf("%s: directory listed", target)
+		}
+	}
+
+	// Without a branding file system nothing is served
+	if rec := getReq((&Server{}).brandingHandler().ServeHTTP, "/branding/logo.svg"); rec.Code != http.StatusNotFound {
+		t.Errorf("no branding: %d", rec.Code)
+	}
+}
+
+// brandedServer returns a server with the given features switched off
+// through the environment, so a reload can change them.
+func brandedServer(t *testing.T, disabled string) *Server {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("UI_DISABLED_FEATURES", disabled)
+	t.Setenv("BRANDING_PRODUCT_NAME", "Acme Fleet")
+	t.Setenv("BRANDING_LOGO", "../img/logo.svg")
+	t.Setenv("BRANDING_PRIMARY_COLOR", "#0a7")
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.Config = cfg
+	s.NavManager = robot.NewNavigationManager()
+	return s
+}
+
+func TestFeatureGates(t *testing.T) {
+	s := brandedServer(t, "speech,poweroff,unknown")
+	routes := s.Routes()
+	mux := http.NewServeMux()
+	Register(mux, routes)
+
+	gated := map[string]bool{}
+	for _, rt := range routes {
+		if rt.Feature != "" {
+			gated[rt.Method+" "+rt.Path] = true
+			if rt.Errors[len(rt.Errors)-1] != http.StatusForbidden {
+				t.Errorf("%s %s: 403 not documented", rt.Method, rt.Path)
+			}
+		}
+	}
+	for _, route := range []string{"GET /api/speech/status", "POST /api/speech/transcribe", "POST /api/robots/poweroff",
+		"POST /api/mapping/start", "POST /api/mode/mapping", "GET /api/fleet/proximity"} {
+		if !gated[route] {
+			t.Errorf("%s not gated", route)
+		}
+	}
+
+	call := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+	for _, c := range []struct {
+		method, target string
+		forbidden      bool
+	}{
+		{"GET", "/api/speech/status", true},
+		{"POST", "/api/robots/poweroff", true},
+		{"GET", "/api/fleet/proximity", false},
+		{"GET", "/api/mapping/status", false},
+		{"GET", "/api/robots", false},
+	} {
+		rec := call(c.method, c.target)
+		if forbidden := rec.Code == http.StatusForbidden; forbidden != c.forbidden {
+			t.Errorf("%s %s: %d", c.method, c.target, rec.Code)
+		}
+		if c.forbidden {
+			var e errorResponse
+			decodeJSON(t, rec, &e)
+			if !strings.Contains(e.Error, "disabled on this deployment") {
+				t.Errorf("%s %s: %+v", c.method, c.target, e)
+			}
+		}
+	}
+
+	// ui_config and the page agree
+	var ui UIConfig
+	decodeJSON(t, call("GET", "/api/ui_config"), &ui)
+	if ui.ProductName != "Acme Fleet" || ui.LogoURL != "/branding/img/logo.svg" || ui.PrimaryColor != "#0a7" ||
+		ui.Enabled(FeatureSpeech) || ui.Enabled(FeaturePowerOff) || !ui.Enabled(FeatureMapping) || !ui.Enabled(FeatureFleet) || len(ui.Features) != len(UIFeatures) {
+		t.Errorf("ui_config %+v", ui)
+	}
+	page := call("GET", "/")
+	if body := page.Body.String(); page.Code != http.StatusOK || strings.Contains(body, `id="tab-speech"`) || !strings.Contains(body, "Acme Fleet") {
+		t.Errorf("page: %d, speech tab or product name wrong", page.Code)
+	}
+
+	// A reload switching the features back on applies at once
+	t.Setenv("UI_DISABLED_FEATURES", "")
+	if _, err := s.Config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if rec := call("GET", "/api/speech/status"); rec.Code == http.StatusForbidden {
+		t.Errorf("speech after reload: %d", rec.Code)
+	}
+	if body := call("GET", "/").Body.String(); !strings.Contains(body, `id="tab-speech"`) {
+		t.Error("speech tab missing after reload")
+	}
+}
+
+func TestUIConfigDefaults(t *testing.T) {
+	ui := (&Server{}).uiConfig()
+	if ui.ProductName == "" || ui.LogoURL != "" || len(ui.Features) != len(UIFeatures) {
+		t.Errorf("no config: %+v", ui)
+	}
+	for _, f := range UIFeatures {
+		if !ui.Enabled(f) {
+			t.Errorf("%s off by default", f)
+		}
+	}
+
+	s := brandedServer(t, "")
+	t.Setenv("BRANDING_PRIMARY_COLOR", "red; background: url(x)")
+	t.Setenv("BRANDING_LOGO", "/")
+	s.Config.Reload()
+	if ui := s.uiConfig(); ui.PrimaryColor != "" || ui.LogoURL != "" {
+		t.Errorf("invalid color or logo kept: %+v", ui)
+	}
+}
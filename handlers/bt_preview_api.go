@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────── Behavior tree preview ────────────────────
+
+// maxBTPreviewBytes caps the tree returned by a preview; generated trees
+// for long collections run to megabytes.
+const maxBTPreviewBytes = 256 << 10
+
+// BTPreview handles GET /api/nav/bt_preview?type=X[&bt_template=T][&id=X]
+//
+// The behavior tree the robot would build from the app's points of type
+// X with template T, without storing or running it, cut to
+// maxBTPreviewBytes (truncated is then set). Firmware without preview
+// gets 404.
+func (h *NavHandlers) BTPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := formParams(r)
+	pointType := p.pointType("type", false, "walls have no behavior tree")
+	if p.invalid(w) {
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	btTemplate := p.btTemplate("bt_template", rb, pointType)
+	if p.invalid(w) {
+		return
+	}
+
+	preview, err := h.Nav.PreviewBT(rb, pointType, btTemplate, maxBTPreviewBytes)
+	var u *robot.UnsupportedError
+	switch {
+	case errors.As(err, &u):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(unsupportedResponse{Error: err.Error(), Code: errorCode(http.StatusNotFound), Capability: u.Capability})
+		return
+	case errors.Is(err, rosbridge.ErrBTPreviewUnsupported):
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, robot.ErrNotConnected):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		jsonError(w, "preview failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, btPreviewResponse{Type: pointType, Template: btTemplate, Templates: rb.BTTemplates(), BTPreview: *preview})
+}
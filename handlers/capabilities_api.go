@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Capabilities ────────────────────
+
+// RobotCapabilities handles GET /api/robots/capabilities. refresh=1 asks
+// the robot again first; if that fails the previous answer is returned
+// with the error in it.
+func (h *RobotHandlers) RobotCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	if r.URL.Query().Get("refresh") == "1" {
+		caps, _ := rb.RefreshCapabilities()
+		jsonOK(w, caps)
+		return
+	}
+	jsonOK(w, rb.GetCapabilities())
+}
+
+// unsupported answers 501 naming the missing capability if err is a
+// robot.UnsupportedError, so the request fails at once instead of timing
+// out on the robot.
+func unsupported(w http.ResponseWriter, err error) bool {
+	var u *robot.UnsupportedError
+	if !errors.As(err, &u) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotImplemented)
+	json.NewEncoder(w).Encode(unsupportedResponse{Error: err.Error(), Code: errorCode(http.StatusNotImplemented), Capability: u.Capability})
+	return true
+}
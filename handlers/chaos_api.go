@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────── Chaos mode ────────────────────
+
+// Chaos handles GET/POST /api/debug/chaos
+//
+// GET shows the fault injection of every robot connection and of browser
+// WebSocket writes. POST sets it for one robot (id, default current) or,
+// with target=browser, for browser writes; unset parameters keep their
+// value. POST is refused unless DEBUG_CHAOS is on.
+func (s *Server) Chaos(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		jsonOK(w, s.chaosStatus())
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.Config.Dynamic().DebugChaos {
+		jsonError(w, "chaos mode disabled (set DEBUG_CHAOS=1)", http.StatusForbidden)
+		return
+	}
+
+	target := r.FormValue("target")
+	label := "browser"
+	var settings interface {
+		SetChaos(rosbridge.Chaos)
+		GetChaos() rosbridge.Chaos
+	}
+	switch target {
+	case "", "robot":
+		id := r.FormValue("id")
+		if id == "" {
+			id = s.Manager.GetCurrentRobotID()
+		}
+		rb := s.Manager.GetRobot(id)
+		if rb == nil || rb.Client == nil {
+			jsonError(w, "robot not found", http.StatusNotFound)
+			return
+		}
+		settings = rb.Client
+		label = "robot " + rb.ID
+	case "browser":
+		settings = browserChaos{&s.BrowserChaos}
+	default:
+		jsonError(w, "target must be robot or browser", http.StatusBadRequest)
+		return
+	}
+
+	ch := settings.GetChaos()
+	if v := r.FormValue("drop_rate"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			jsonError(w, "invalid drop_rate", http.StatusBadRequest)
+			return
+		}
+		ch.DropRate = f
+	}
+	for name, dst := range map[string]*int{
+		"added_latency_ms":   &ch.AddedLatencyMs,
+		"disconnect_every_s": &ch.DisconnectEveryS,
+	} {
+		if v := r.FormValue(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				jsonError(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+	if err := ch.Validate(); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	settings.SetChaos(ch)
+	log.Printf("[chaos] %s: drop_rate=%.2f added_latency_ms=%d disconnect_every_s=%d",
+		label, ch.DropRate, ch.AddedLatencyMs, ch.DisconnectEveryS)
+	jsonOK(w, s.chaosStatus())
+}
+
+// browserChaos adapts the browser settings to the robot client's
+// SetChaos/GetChaos.
+type browserChaos struct{ s *rosbridge.ChaosSettings }
+
+func (b browserChaos) SetChaos(ch rosbridge.Chaos) { b.s.Set(ch) }
+func (b browserChaos) GetChaos() rosbridge.Chaos   { return b.s.Get() }
+
+func (s *Server) chaosStatus() chaosResponse {
+	resp := chaosResponse{
+		Enabled: s.Config.Dynamic().DebugChaos,
+		Browser: s.BrowserChaos.Get(),
+		Robots:  map[string]rosbridge.Chaos{},
+	}
+	for _, rb := range s.Manager.GetAllRobots() {
+		if rb.Client != nil {
+			resp.Robots[rb.ID] = rb.Client.GetChaos()
+		}
+	}
+	return resp
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"reflect"
+
+	"rom_go_app/config"
+)
+
+// ──────────────────── Configuration ────────────────────
+
+// GetConfig handles GET /api/config
+//
+// Returns the effective configuration: startup settings and the dynamic
+// ones a reload applies, by environment variable name.
+func (s *Server) GetConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jsonOK(w, s.Config.Effective())
+}
+
+// ReloadConfig handles POST /api/config/reload, the same reload SIGHUP
+// triggers.
+func (s *Server) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	res, err := s.Reload()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonOK(w, res)
+}
+
+// Reload re-reads the configuration and applies what changed: throttle
+// rates go to every robot now; other dynamic settings are read on their
+// next use.
+func (s *Server) Reload() (config.ReloadResult, error) {
+	old := s.Config.Dynamic()
+	res, err := s.Config.Reload()
+	if err != nil {
+		log.Printf("[config] Reload failed: %v", err)
+		return res, err
+	}
+	if next := s.Config.Dynamic(); !reflect.DeepEqual(old.TopicThrottles, next.TopicThrottles) {
+		s.Manager.ApplyTopicThrottles(old.TopicThrottles, next.TopicThrottles)
+	}
+	log.Printf("[config] Reloaded: applied %v, restart required for %v", res.Applied, res.RestartRequired)
+	return res, nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"rom_go_app/config"
+	"rom_go_app/robot"
+)
+
+// TestReloadConfigAPI reloads an edited config file over HTTP, then a
+// broken one.
+func TestReloadConfigAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("map_archive_dir: /tmp/a\nws_min_client_version: 1\n"), 0644)
+	t.Setenv("CONFIG_FILE", path)
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Config: cfg, Manager: robot.NewManager()}
+	reload := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ReloadConfig(rec, httptest.NewRequest(http.MethodPost, "/api/config/reload", nil))
+		return rec
+	}
+
+	os.WriteFile(path, []byte("map_archive_dir: /tmp/b\nws_min_client_version: 3\n"), 0644)
+	rec := reload()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+	var res config.ReloadResult
+	decodeJSON(t, rec, &res)
+	if !reflect.DeepEqual(res.Applied, []string{"WS_MIN_CLIENT_VERSION"}) || !reflect.DeepEqual(res.RestartRequired, []string{"MAP_ARCHIVE_DIR"}) {
+		t.Errorf("reload %+v", res)
+	}
+	if cfg.Dynamic().MinWSClientVersion != 3 || cfg.MapArchiveDir != "/tmp/a" {
+		t.Errorf("after reload: min version %d, archive %q", cfg.Dynamic().MinWSClientVersion, cfg.MapArchiveDir)
+	}
+
+	os.WriteFile(path, []byte("ws_min_client_version: [\n"), 0644)
+	if rec := reload(); rec.Code != http.StatusBadRequest {
+		t.Errorf("broken file: %d %s", rec.Code, rec.Body)
+	}
+	if cfg.Dynamic().MinWSClientVersion != 3 {
+		t.Errorf("broken file applied: min version %d", cfg.Dynamic().MinWSClientVersion)
+	}
+}
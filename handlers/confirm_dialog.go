@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ──────────────────── Confirm dialog ────────────────────
+//
+// The generic confirm dialog is opened by a link, so nothing it does may
+// come from the link: the action is a name from confirmActions, each with
+// its endpoint, method and parameters fixed here, and anything else is
+// refused. The title, message and Confirm button are the action's own,
+// so a link can't pass one action off as another; a caller's note is
+// only shown under the message, capped and, like all template data,
+// HTML-escaped when rendered.
+// Power off, reboot and clears have their own token dialog (see
+// destructive_api.go) and aren't listed.
+
+// maxConfirmNote caps the caller's note, in characters.
+const maxConfirmNote = 300
+
+// confirmAction is an action the confirm dialog can send. A robot action
+// acts on the id parameter, or the current robot; %s in Message is the
+// robot's name.
+type confirmAction struct {
+	Path    string
+	Method  string     // DELETE, else POST
+	Vals    url.Values // fixed parameters
+	Robot   bool
+	Title   string
+	Message string
+	Label   string
+}
+
+var confirmActions = map[string]confirmAction{
+	"remove_robot": {Path: "/api/robots", Method: http.MethodDelete, Robot: true,
+		Title: "Remove robot", Message: "Remove %s? Its connection is closed and it leaves the list.", Label: "Remove"},
+	"mapping_abort": {Path: "/api/mapping/abort", Robot: true,
+		Title: "Abort mapping", Message: "Abort mapping on %s? The map built so far is discarded.", Label: "Abort mapping"},
+	"reset_stats": {Path: "/api/robots/stats/reset", Robot: true,
+		Title: "Reset statistics", Message: "Reset the distance and active-time counters of %s?", Label: "Reset statistics"},
+	"reset_odom": {Path: "/api/robots/reset_odom", Vals: url.Values{"confirm": {"1"}}, Robot: true,
+		Title: "Reset odometry", Message: "Reset the odometry and localization of %s?", Label: "Reset odometry"},
+	"config_reload": {Path: "/api/config/reload",
+		Title: "Reload configuration", Message: "Reload the configuration file? Changed settings apply at once.", Label: "Reload"},
+}
+
+// confirmActionNames returns the names of confirmActions, sorted.
+func confirmActionNames() []string {
+	names := make([]string, 0, len(confirmActions))
+	for name := range confirmActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfirmDialog handles GET /dialog/confirm?action=NAME[&id=X][&note=...]
+//
+// Renders the confirm dialog of a named action; an unknown name answers
+// 400. note is shown under the action's message.
+func (s *Server) ConfirmDialog(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	name := p.enum("action", "", confirmActionNames()...)
+	if name == "" {
+		p.fail("action", "required, one of "+strings.Join(confirmActionNames(), ", "))
+	}
+	note := confirmText(p.str("note"), maxConfirmNote)
+	if p.invalid(w) {
+		return
+	}
+
+	a := confirmActions[name]
+	vals := url.Values{}
+	for k, v := range a.Vals {
+		vals[k] = v
+	}
+	text := a.Message
+	if a.Robot {
+		rb := s.lookupRobot(w, p.str("id"))
+		if rb == nil {
+			return
+		}
+		vals.Set("id", rb.ID)
+		text = strings.ReplaceAll(text, "%s", rb.Name)
+	}
+	action := a.Path
+	if len(vals) > 0 {
+		action += "?" + vals.Encode()
+	}
+	s.render(w, r, "confirm.html", confirmView{Title: a.Title, Message: text, Note: note, Action: action, Method: a.Method, Label: a.Label})
+}
+
+// confirmText drops control characters from s and cuts it to max
+// characters.
+func confirmText(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if rs := []rune(s); len(rs) > max {
+		s = string(rs[:max-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func confirmDialog(s *Server, q url.Values) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ConfirmDialog(rec, httptest.NewRequest(http.MethodGet, "/dialog/confirm?"+q.Encode(), nil))
+	return rec
+}
+
+func TestConfirmDialogNamedActionsOnly(t *testing.T) {
+	s := newTestServer(t)
+	rb, err := s.Manager.AddRobot("", "Ada", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rb.Close)
+
+	for _, action := range []string{"", "poweroff", "/api/robots/poweroff", "remove_robot/../poweroff", "Remove_Robot"} {
+		rec := confirmDialog(s, url.Values{"action": {action}, "id": {rb.ID}})
+		if rec.Code != http.StatusBadRequest || strings.Contains(rec.Body.String(), "hx-") {
+			t.Errorf("action %q: %d %s", action, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := confirmDialog(s, url.Values{"action": {"remove_robot"}, "id": {"nope"}}); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown robot: %d", rec.Code)
+	}
+
+	// The request sent, the title and the message are the action's own
+	rec := confirmDialog(s, url.Values{"action": {"remove_robot"}, "id": {rb.ID},
+		"title": {"Rename robot"}, "message": {"Harmless, go ahead"}, "path": {"/api/robots/poweroff"}})
+	html := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("remove_robot: %d %s", rec.Code, html)
+	}
+	for _, want := range []string{`hx-delete="/api/robots?id=` + rb.ID + `"`, "<h3>Remove robot</h3>", "Remove Ada?", ">Remove</button>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("dialog lacks %s:\n%s", want, html)
+		}
+	}
+	for _, unwanted := range []string{"Rename robot", "Harmless", "poweroff", "hx-post"} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("dialog contains %q:\n%s", unwanted, html)
+		}
+	}
+}
+
+func TestConfirmDialogNote(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := confirmDialog(s, url.Values{"action": {"config_reload"},
+		"note": {`<script>alert(1)</script><button hx-post="/api/robots/poweroff">x</button>`}})
+	html := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, html)
+	}
+	if strings.Contains(html, "<script>alert") || strings.Contains(html, `<button hx-post="/api/robots/poweroff"`) {
+		t.Errorf("note rendered as markup:\n%s", html)
+	}
+	if !strings.Contains(html, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("note not shown escaped:\n%s", html)
+	}
+	if !strings.Contains(html, "Reload the configuration file?") || !strings.Contains(html, `hx-post="/api/config/reload"`) {
+		t.Errorf("the action's own message or request is missing:\n%s", html)
+	}
+
+	// Capped, with control characters dropped
+	long := strings.Repeat("é", maxConfirmNote+50)
+	html = confirmDialog(s, url.Values{"action": {"config_reload"}, "note": {"a\x00b\nc" + long}}).Body.String()
+	note := between(html, `<p class="dialog-note">`, "</p>")
+	if n := len([]rune(note)); n != maxConfirmNote || !strings.HasPrefix(note, "abc") || !strings.HasSuffix(note, "…") {
+		t.Errorf("note of %d characters: %q", n, note)
+	}
+
+	// Without a note there is no note paragraph
+	if html := confirmDialog(s, url.Values{"action": {"config_reload"}}).Body.String(); strings.Contains(html, "dialog-note") {
+		t.Errorf("empty note rendered:\n%s", html)
+	}
+}
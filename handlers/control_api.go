@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// ──────────────────── Control lease ────────────────────
+
+// RobotControl handles GET /api/robots/control[?id=X]
+//
+// Who holds the robot's control lease, since when and until when if
+// inactive, and the request waiting for it.
+func (h *RobotHandlers) RobotControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, rb.ControlLease())
+}
+
+// AnswerControl handles POST /api/robots/control/grant and
+// /api/robots/control/deny [?id=X][&client_id=ws-N]
+//
+// Grants or denies the pending control request in place of the holder;
+// client_id, if given, must name the requesting connection.
+func (h *RobotHandlers) AnswerControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	answer := rb.GrantControl
+	if r.URL.Path == "/api/robots/control/deny" {
+		answer = rb.DenyControl
+	}
+	ev, err := answer(nil, r.FormValue("client_id"))
+	if err != nil { // no such request
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	jsonOK(w, ev)
+}
+
+// ReleaseControl handles POST /api/robots/control/release[?id=X]
+//
+// Revokes the control lease whoever holds it, and drops a pending
+// request.
+func (h *RobotHandlers) ReleaseControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, controlReleaseResponse{Released: rb.RevokeControl(), Control: rb.ControlLease()})
+}
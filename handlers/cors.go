@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ──────────────────── CORS ────────────────────
+//
+// Dashboards on other origins call the JSON API directly. With no
+// allowed origins configured nothing changes: no CORS headers are sent
+// and WebSocket upgrades accept any origin.
+
+const (
+	corsExposed = "Allow, Content-Disposition, Retry-After" // readable by cross-origin scripts
+	corsMaxAge  = "600"                                     // preflight cache, seconds
+)
+
+// OriginPolicy is an allowlist of browser origins.
+type OriginPolicy struct {
+	origins     map[string]bool
+	any         bool // "*" listed
+	credentials bool
+}
+
+// errAnyOriginCredentials refuses "*" with credentials: any site could
+// then make calls carrying the operator's cookies.
+var errAnyOriginCredentials = errors.New(`CORS_ORIGINS "*" can't be combined with CORS_ALLOW_CREDENTIALS; list the origins`)
+
+// NewOriginPolicy returns the policy for origins (exact
+// scheme://host[:port] values, or "*" without credentials); nil when
+// origins is empty.
+func NewOriginPolicy(origins []string, credentials bool) (*OriginPolicy, error) {
+	if len(origins) == 0 {
+		return nil, nil
+	}
+	p := &OriginPolicy{origins: make(map[string]bool), credentials: credentials}
+	for _, o := range origins {
+		if o == "*" {
+			p.any = true
+			continue
+		}
+		p.origins[strings.TrimSuffix(strings.ToLower(o), "/")] = true
+	}
+	if p.any && credentials {
+		return nil, errAnyOriginCredentials
+	}
+	return p, nil
+}
+
+// Allows reports whether origin may call the API.
+func (p *OriginPolicy) Allows(origin string) bool {
+	return p.any || p.origins[strings.ToLower(origin)]
+}
+
+// CheckWSOrigin is a websocket.Upgrader CheckOrigin: requests without an
+// Origin (non-browser clients) and same-origin pages (X-Forwarded-Host
+// counting as the host behind a proxy) are always accepted, others only
+// when allowed. A nil policy accepts everything.
+func (p *OriginPolicy) CheckWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if p == nil || origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && (strings.EqualFold(u.Host, r.Host) || strings.EqualFold(u.Host, requestHost(r))) {
+		return true
+	}
+	return p.Allows(origin)
+}
+
+// CORS wraps next so /api/ routes answer preflights and carry CORS
+// headers for allowed origins. Preflight methods come from the route
+// table, the path resolved against its patterns as the mux does. With a
+// nil policy next is returned as is.
+func CORS(next http.Handler, p *OriginPolicy, routes []Route) http.Handler {
+	if p == nil {
+		return next
+	}
+	byPath := map[string][]string{}
+	for _, rt := range routes {
+		if strings.HasPrefix(rt.Path, "/api/") {
+			byPath[rt.Path] = append(byPath[rt.Path], rt.Method)
+		}
+	}
+	patterns := http.NewServeMux()
+	methods := make(map[string]string, len(byPath))
+	for path, m := range byPath {
+		sort.Strings(m)
+		methods[path] = strings.Join(append(m, http.MethodOptions), ", ")
+		patterns.Handle(path, http.NotFoundHandler())
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		allowed := p.Allows(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !allowed {
+			if preflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			// No CORS headers: the browser withholds the response
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		// A listed origin is echoed; "*" never comes with credentials
+		if p.any {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", corsExposed)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, pattern := patterns.Handler(r)
+		allow, ok := methods[pattern]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", allow)
+		if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+		h.Set("Access-Control-Max-Age", corsMaxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
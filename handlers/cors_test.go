@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testOrigin = "https://dash.example:3000"
+
+// corsRoutes is a route table with an exact, a two-method and a subtree
+// /api/ path, and a page.
+func corsRoutes() []Route {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	return []Route{
+		{Method: "GET", Path: "/api/robots", Handler: ok},
+		{Method: "POST", Path: "/api/robots", Handler: ok},
+		{Method: "DELETE", Path: "/api/maps/", Handler: ok},
+		{Method: "GET", Path: "/", Handler: ok},
+	}
+}
+
+func corsHandler(t *testing.T, origins []string, credentials bool) http.Handler {
+	t.Helper()
+	p, err := NewOriginPolicy(origins, credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	Register(mux, corsRoutes())
+	return CORS(mux, p, corsRoutes())
+}
+
+func corsRequest(h http.Handler, method, path, origin, preflightMethod string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflightMethod != "" {
+		req.Header.Set("Access-Control-Request-Method", preflightMethod)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	h := corsHandler(t, []string{testOrigin + "/"}, true)
+
+	for _, tc := range []struct{ path, methods string }{
+		{"/api/robots", "GET, POST, OPTIONS"},
+		{"/api/maps/", "DELETE, OPTIONS"},
+		{"/api/maps/office", "DELETE, OPTIONS"}, // in the subtree
+	} {
+		rec := corsRequest(h, http.MethodOptions, tc.path, testOrigin, "POST")
+		hdr := rec.Header()
+		if rec.Code != http.StatusNoContent || hdr.Get("Access-Control-Allow-Methods") != tc.methods {
+			t.Errorf("preflight %s: %d, methods %q, want %q", tc.path, rec.Code, hdr.Get("Access-Control-Allow-Methods"), tc.methods)
+		}
+		if hdr.Get("Access-Control-Allow-Origin") != testOrigin || hdr.Get("Access-Control-Allow-Credentials") != "true" ||
+			hdr.Get("Access-Control-Allow-Headers") != "Content-Type" || hdr.Get("Access-Control-Max-Age") != corsMaxAge {
+			t.Errorf("preflight %s headers: %v", tc.path, hdr)
+		}
+	}
+	if rec := corsRequest(h, http.MethodOptions, "/api/nope", testOrigin, "GET"); rec.Code != http.StatusNotFound {
+		t.Errorf("preflight of an unknown path: %d", rec.Code)
+	}
+
+	// A listed origin gets the response with CORS headers
+	rec := corsRequest(h, http.MethodGet, "/api/robots", testOrigin, "")
+	if rec.Body.String() != "ok" || rec.Header().Get("Access-Control-Allow-Origin") != testOrigin || rec.Header().Get("Access-Control-Expose-Headers") != corsExposed {
+		t.Errorf("GET: %d %v", rec.Code, rec.Header())
+	}
+	// Pages and same-site requests are left alone
+	for _, rec := range []*httptest.ResponseRecorder{
+		corsRequest(h, http.MethodGet, "/", testOrigin, ""),
+		corsRequest(h, http.MethodGet, "/api/robots", "", ""),
+	} {
+		if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("CORS headers outside cross-origin /api/: %v", rec.Header())
+		}
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	h := corsHandler(t, []string{testOrigin}, true)
+
+	if rec := corsRequest(h, http.MethodOptions, "/api/robots", "https://evil.example", "POST"); rec.Code != http.StatusForbidden {
+		t.Errorf("preflight: %d", rec.Code)
+	}
+	// The request runs (a simple request can't be stopped) but the browser
+	// gets nothing to let the page read it
+	rec := corsRequest(h, http.MethodGet, "/api/robots", "https://dash.example:3001", "")
+	for _, name := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials"} {
+		if v := rec.Header().Get(name); v != "" {
+			t.Errorf("%s: %q for a disallowed origin", name, v)
+		}
+	}
+
+	p, _ := NewOriginPolicy([]string{testOrigin}, false)
+	for origin, want := range map[string]bool{
+		"":                     true, // not a browser
+		"http://robots.local":  true, // same origin
+		"https://proxy.local":  true, // same origin behind the proxy
+		testOrigin:             true,
+		"https://evil.example": false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://robots.local/ws", nil)
+		req.Header.Set("X-Forwarded-Host", "proxy.local")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if got := p.CheckWSOrigin(req); got != want {
+			t.Errorf("WebSocket from %q: %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	if p, err := NewOriginPolicy([]string{testOrigin, "*"}, true); err == nil || p != nil {
+		t.Errorf(`"*" with credentials: %v %v`, p, err)
+	}
+	if p, err := NewOriginPolicy(nil, true); p != nil || err != nil {
+		t.Errorf("no origins: %v %v", p, err)
+	}
+
+	h := corsHandler(t, []string{"*"}, false)
+	for _, rec := range []*httptest.ResponseRecorder{
+		corsRequest(h, http.MethodGet, "/api/robots", "https://evil.example", ""),
+		corsRequest(h, http.MethodOptions, "/api/robots", "https://evil.example", "POST"),
+	} {
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Allow-Origin %q, want *", got)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("credentials allowed for any origin: %q", got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Destructive actions ────────────────────
+//
+// Power off, reboot and clearing a collection are confirmed in two steps
+// (see robot/destructive.go). Without a token the request only issues
+// one: JSON clients get 202 with the token, HTMX gets the confirm dialog
+// carrying it, whose Confirm button repeats the request with the token.
+
+// confirmView is the confirm dialog data. Token and the fields after it
+// are set for a destructive action awaiting its second request.
+type confirmView struct {
+	Title   string
+	Message string
+	Note    string // the caller's, shown under Message
+	Action  string // URL the Confirm button sends to
+	Method  string // of that request: DELETE, else POST
+	Label   string // of the Confirm button; empty: "Confirm"
+
+	Token        string
+	RobotID      string
+	Kind         string // robot.Action*
+	Vals         string // hx-vals JSON of the confirming request
+	SwapTarget   string // where the confirmed response goes; empty: nowhere
+	CountdownMs  int64
+	CountdownSec int64
+}
+
+// destructiveConfirmed reports whether the request brings a valid token
+// for action on rb, in which case the caller runs the action. Otherwise
+// it has answered: with a new token when there was none, else with 409.
+// target narrows the action (the point type of a clear); v describes it
+// for the dialog, vals the confirming request's parameters besides id
+// and token. c issues and checks the tokens; pages renders the dialog.
+func destructiveConfirmed(w http.ResponseWriter, r *http.Request, c confirmer, pages renderer, rb *robot.Robot, action, target string, v confirmView, vals map[string]string) bool {
+	token := r.FormValue("token")
+	if token == "" {
+		p := c.RequestDestructive(rb.ID, action, target, clientAddr(r))
+		left := time.Until(p.ExpiresAt)
+		if r.Header.Get("HX-Request") == "true" {
+			v.Token, v.RobotID, v.Kind = p.Token, rb.ID, action
+			v.CountdownMs, v.CountdownSec = left.Milliseconds(), int64(left.Round(time.Second)/time.Second)
+			if vals == nil {
+				vals = map[string]string{}
+			}
+			vals["id"], vals["token"] = rb.ID, p.Token
+			b, _ := json.Marshal(vals)
+			v.Vals = string(b)
+			pages.render(w, r, "confirm.html", v)
+			return false
+		}
+		jsonAccepted(w, destructivePendingResponse{Status: "confirm_required", PendingAction: p, ExpiresInMs: left.Milliseconds()})
+		return false
+	}
+
+	_, err := c.ConfirmDestructive(rb.ID, action, target, token)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, robot.ErrConfirmExpired):
+		jsonError(w, "the confirmation token expired: request the action again", http.StatusConflict)
+	default:
+		jsonError(w, "invalid confirmation token: it was not issued for this robot and action, or was already used or cancelled", http.StatusConflict)
+	}
+	return false
+}
+
+// CancelDestructive handles POST /api/robots/destructive/cancel?id=X&token=T
+//
+// Voids a pending destructive action's token.
+func (h *RobotHandlers) CancelDestructive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := formParams(r)
+	token := p.requiredStr("token")
+	if p.invalid(w) {
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		id = h.Robots.GetCurrentRobotID()
+	}
+	cancelled, err := h.Robots.CancelDestructive(id, token)
+	if err != nil {
+		jsonError(w, "no pending action with this token on this robot", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, destructiveCancelResponse{Status: "cancelled", Action: cancelled.Action, Target: cancelled.Target})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// newClearRobot returns a server whose current robot has two waypoints.
+func newClearRobot(t *testing.T) (*Server, *robot.Robot) {
+	t.Helper()
+	s := newTestServer(t)
+	s.NavManager = robot.NewNavigationManager()
+	rb, err := s.Manager.AddRobot("", "test", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rb.Close)
+	if err := s.Manager.SwitchRobot(rb.ID); err != nil {
+		t.Fatal(err)
+	}
+	rb.ApplyProfile(robot.Profile{Waypoints: []rosbridge.NavigationPoint{{Name: "a"}, {Name: "b"}}})
+	return s, rb
+}
+
+func postForm(h http.HandlerFunc, path string, form url.Values, htmx bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if htmx {
+		req.Header.Set("HX-Request", "true")
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func waypointCount(s *Server, rb *robot.Robot) int {
+	n, _, _, _, _ := s.NavManager.GetCounts(rb)
+	return n
+}
+
+func TestDestructiveConfirmedFlow(t *testing.T) {
+	s, rb := newClearRobot(t)
+	clear := url.Values{"type": {"waypoint"}}
+
+	rec := postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", clear, false)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("first request: %d %s", rec.Code, rec.Body.String())
+	}
+	var pending destructivePendingResponse
+	decodeJSON(t, rec, &pending)
+	if pending.Status != "confirm_required" || pending.Token == "" || pending.RobotID != rb.ID ||
+		pending.Target != "waypoint" || pending.ExpiresInMs <= 0 {
+		t.Fatalf("pending = %+v", pending)
+	}
+	if waypointCount(s, rb) != 2 {
+		t.Fatal("cleared without confirmation")
+	}
+
+	// A token for another point type doesn't clear this one
+	wrongType := url.Values{"type": {"service_point"}, "token": {pending.Token}}
+	if rec := postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", wrongType, false); rec.Code != http.StatusConflict {
+		t.Errorf("token of another type: %d", rec.Code)
+	}
+
+	confirm := url.Values{"type": {"waypoint"}, "token": {pending.Token}}
+	if rec := postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", confirm, false); rec.Code != http.StatusOK {
+		t.Fatalf("confirm: %d %s", rec.Code, rec.Body.String())
+	}
+	if n := waypointCount(s, rb); n != 0 {
+		t.Errorf("%d waypoints after confirming", n)
+	}
+
+	// Reuse is refused
+	rb.ApplyProfile(robot.Profile{Waypoints: []rosbridge.NavigationPoint{{Name: "c"}}})
+	rec = postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", confirm, false)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "already used") {
+		t.Errorf("reused token: %d %s", rec.Code, rec.Body.String())
+	}
+	if waypointCount(s, rb) != 1 {
+		t.Error("reused token cleared the waypoints")
+	}
+}
+
+func TestDestructiveConfirmedExpired(t *testing.T) {
+	s, rb := newClearRobot(t)
+	s.Manager.DestructiveConfirm = 30 * time.Millisecond
+
+	rec := postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", url.Values{"type": {"waypoint"}}, false)
+	var pending destructivePendingResponse
+	decodeJSON(t, rec, &pending)
+	time.Sleep(60 * time.Millisecond)
+
+	rec = postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", url.Values{"type": {"waypoint"}, "token": {pending.Token}}, false)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expired token: %d %s", rec.Code, rec.Body.String())
+	}
+	if waypointCount(s, rb) != 2 {
+		t.Error("expired token cleared the waypoints")
+	}
+}
+
+// TestDestructiveConfirmedWrongRobot confirms a power off on one robot
+// with the token issued for another: it is refused before anything is
+// sent to either robot.
+func TestDestructiveConfirmedWrongRobot(t *testing.T) {
+	s := newTestServer(t)
+	fa, fb := newFakeRosbridge(t), newFakeRosbridge(t)
+	a := connectRobot(t, s, fa)
+	b := connectRobot(t, s, fb)
+	h := s.robotHandlers()
+
+	rec := postForm(h.PowerOff, "/api/robots/poweroff", url.Values{"id": {a.ID}}, false)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("first request: %d %s", rec.Code, rec.Body.String())
+	}
+	var pending destructivePendingResponse
+	decodeJSON(t, rec, &pending)
+
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		id      string
+	}{
+		{"other robot", h.PowerOff, b.ID},
+		{"other action", h.Reboot, a.ID},
+	} {
+		rec := postForm(tc.handler, "/", url.Values{"id": {tc.id}, "token": {pending.Token}}, false)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s: %d %s", tc.name, rec.Code, rec.Body.String())
+		}
+	}
+	if sent := powerCalls(fa, fb); len(sent) != 0 {
+		t.Errorf("power tasks sent: %v", sent)
+	}
+
+	// Cancelling voids it
+	rec = postForm(h.CancelDestructive, "/api/robots/destructive/cancel", url.Values{"id": {a.ID}, "token": {pending.Token}}, false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = postForm(h.PowerOff, "/api/robots/poweroff", url.Values{"id": {a.ID}, "token": {pending.Token}}, false)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("cancelled token: %d", rec.Code)
+	}
+	if sent := powerCalls(fa, fb); len(sent) != 0 {
+		t.Errorf("power tasks sent: %v", sent)
+	}
+}
+
+func TestDestructiveConfirmedDialog(t *testing.T) {
+	s, rb := newClearRobot(t)
+	rec := postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", url.Values{"type": {"waypoint"}}, true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	html := rec.Body.String()
+	for _, want := range []string{
+		`data-confirm-robot="` + rb.ID + `"`, `data-confirm-action="clear_points"`,
+		`hx-post="/api/nav/clear"`, `hx-target="#nav-points-content"`, "confirm-countdown",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("dialog lacks %s", want)
+		}
+	}
+
+	// The Confirm button's values carry the token, which confirms
+	start := strings.Index(html, "hx-vals='") + len("hx-vals='")
+	vals := html[start : start+strings.Index(html[start:], "'")]
+	token := between(vals, `&#34;token&#34;:&#34;`, `&#34;`)
+	if token == "" {
+		t.Fatalf("no token in hx-vals %s", vals)
+	}
+	confirm := url.Values{"type": {"waypoint"}, "token": {token}}
+	if rec := postForm(s.navHandlers().ClearNavigationPoints, "/api/nav/clear", confirm, false); rec.Code != http.StatusOK {
+		t.Errorf("confirm with the dialog's token: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// powerCalls returns the service calls to any of fs that ask for a power
+// off or reboot.
+func powerCalls(fs ...*fakeRosbridge) []string {
+	var out []string
+	for _, f := range fs {
+		f.mu.Lock()
+		for _, op := range f.ops {
+			if op["op"] != "call_service" {
+				continue
+			}
+			b, _ := json.Marshal(op["args"])
+			if strings.Contains(string(b), "poweroff") || strings.Contains(string(b), "reboot") {
+				out = append(out, str(op["service"])+" "+string(b))
+			}
+		}
+		f.mu.Unlock()
+	}
+	return out
+}
+
+// between returns the text of s between the first prefix and the next
+// suffix after it.
+func between(s, prefix, suffix string) string {
+	i := strings.Index(s, prefix)
+	if i < 0 {
+		return ""
+	}
+	s = s[i+len(prefix):]
+	j := strings.Index(s, suffix)
+	if j < 0 {
+		return ""
+	}
+	return s[:j]
+}
+
+// TestRebootRefused confirms a reboot the robot refuses: 409 with the
+// robot's status and reason, and the robot's power state unchanged.
+func TestRebootRefused(t *testing.T) {
+	s := newTestServer(t)
+	f := newFakeRosbridge(t)
+	f.values["/which_tasks"] = map[string]interface{}{"task_name": "reboot", "status": 2, "response_settings": "charging"}
+	rb := connectRobot(t, s, f)
+	h := s.robotHandlers()
+
+	var pending destructivePendingResponse
+	decodeJSON(t, postForm(h.Reboot, "/api/robots/reboot", url.Values{"id": {rb.ID}}, false), &pending)
+	rec := postForm(h.Reboot, "/api/robots/reboot", url.Values{"id": {rb.ID}, "token": {pending.Token}}, false)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("refused reboot: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp refusedResponse
+	decodeJSON(t, rec, &resp)
+	if resp.Code != "robot_refused" || resp.Task != "reboot" || resp.Status != 2 || resp.Reason != "charging" {
+		t.Errorf("response %+v", resp)
+	}
+	if st := rb.PowerStatus(); st.State != robot.PowerNormal {
+		t.Errorf("power state %+v", st)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"rom_go_app/discovery"
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Robot discovery ────────────────────
+
+// DiscoverRobots handles GET/POST /api/robots/discover
+//
+// GET returns the cached scan (if younger than a minute) and robots seen
+// over mDNS. POST scans subnets (comma-separated CIDRs, default
+// DISCOVERY_SUBNETS or the local interfaces) for the rosbridge port.
+// Repeated POSTs within a minute reuse the cached result unless
+// refresh=1. With register=1 every new candidate that answered the
+// handshake is added and connected.
+func (h *RobotHandlers) DiscoverRobots(w http.ResponseWriter, r *http.Request) {
+	if h.Discovery == nil {
+		jsonError(w, "discovery disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		scan := h.Discovery.Last()
+		if scan != nil {
+			h.markRegistered(scan.Candidates)
+		}
+		mdns := h.Discovery.MDNS()
+		h.markRegistered(mdns)
+		jsonOK(w, map[string]interface{}{"scan": scan, "mdns": mdns})
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts := discovery.ScanOptions{Handshake: r.FormValue("handshake") != "0"}
+	if h.Config != nil {
+		d := h.Config.Dynamic()
+		opts.Port = h.Config.RosbridgePort
+		opts.Subnets = d.DiscoverySubnets
+		opts.Concurrency = d.DiscoveryConcurrency
+	}
+	if v := r.FormValue("subnets"); v != "" {
+		opts.Subnets = nil
+		for _, cidr := range strings.Split(v, ",") {
+			if cidr = strings.TrimSpace(cidr); cidr != "" {
+				opts.Subnets = append(opts.Subnets, cidr)
+			}
+		}
+	}
+	if v := r.FormValue("port"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil || p <= 0 || p > 65535 {
+			jsonError(w, "invalid port", http.StatusBadRequest)
+			return
+		}
+		opts.Port = p
+	}
+
+	scan, err := h.Discovery.Scan(r.Context(), opts, r.FormValue("refresh") == "1")
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.markRegistered(scan.Candidates)
+
+	registered := []string{}
+	if r.FormValue("register") == "1" {
+		for i, c := range scan.Candidates {
+			if c.Registered || c.Namespace == "" {
+				continue
+			}
+			rb, err := h.Robots.AddRobot(c.Namespace, c.Name, c.IP, c.Port)
+			if err != nil {
+				log.Printf("[discovery] register %s: %v", c.Key(), err)
+				continue
+			}
+			go h.connectRobot(rb)
+			scan.Candidates[i].Registered = true
+			registered = append(registered, rb.ID)
+		}
+	}
+
+	mdns := h.Discovery.MDNS()
+	h.markRegistered(mdns)
+	jsonOK(w, map[string]interface{}{
+		"scan":       scan,
+		"mdns":       mdns,
+		"registered": registered,
+	})
+}
+
+// markRegistered flags candidates whose address, and namespace when the
+// handshake gave one, match a known robot.
+func (h *RobotHandlers) markRegistered(cands []discovery.Candidate) {
+	robots := h.Robots.GetAllRobots()
+	for i, c := range cands {
+		cands[i].Registered = false
+		for _, rb := range robots {
+			if rb.IP == c.IP && rb.Port == c.Port && (c.Namespace == "" || robot.SameNamespace(rb.Namespace, c.Namespace)) {
+				cands[i].Registered = true
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ──────────────────── Recent errors ────────────────────
+
+// RecentErrors handles GET /api/errors[?id=X][&since=ms]
+//
+// Returns the retained notices of background operations (failed robot
+// connects, map list refreshes, voice commands), oldest first. id limits
+// them to one robot; since is a unix millisecond timestamp.
+func (s *Server) RecentErrors(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			jsonError(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		since = time.UnixMilli(n)
+	}
+	jsonOK(w, errorsResponse{Errors: s.Manager.RecentNotices(since, r.URL.Query().Get("id"))})
+}
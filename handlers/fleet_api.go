@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Fleet ────────────────────
+
+// FleetProximity handles GET/POST /api/fleet/proximity
+//
+// GET returns the distances between robots on the same map from the last
+// fleet monitor check. POST changes the monitor options: margin_m,
+// hysteresis_m and auto_stop (each optional).
+func (s *Server) FleetProximity(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		opts := s.Manager.FleetProximity().Options
+		for name, dst := range map[string]*float64{"margin_m": &opts.MarginM, "hysteresis_m": &opts.HysteresisM} {
+			if v := r.FormValue(name); v != "" {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					jsonError(w, "invalid "+name, http.StatusBadRequest)
+					return
+				}
+				*dst = f
+			}
+		}
+		if v := r.FormValue("auto_stop"); v != "" {
+			opts.AutoStop = v == "1" || v == "true"
+		}
+		if err := s.Manager.SetProximityOptions(opts); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jsonOK(w, s.Manager.FleetProximity())
+}
+
+// FleetMaps handles GET /api/fleet/maps[?expected=NAME]
+//
+// The robot × map matrix from the cached map lists, without asking the
+// robots; expected lists the robots missing that map.
+func (s *Server) FleetMaps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := formParams(r)
+	expected := p.mapName("expected")
+	if p.invalid(w) {
+		return
+	}
+	jsonOK(w, s.Manager.FleetMaps(expected))
+}
+
+// FleetMapsRefresh handles POST /api/fleet/maps/refresh[?expected=NAME]
+//
+// Asks every connected robot for its maps, broadcasting each result as
+// map_list_changed, and answers with the matrix. Robots that fail keep
+// their cached lists and report the error in their row.
+func (s *Server) FleetMapsRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := formParams(r)
+	expected := p.mapName("expected")
+	if p.invalid(w) {
+		return
+	}
+	jsonOK(w, s.Manager.RefreshFleetMaps(r.Context(), expected))
+}
+
+// FleetTimeline handles GET /api/fleet/timeline?from=MS&to=MS[&cursor=C][&limit=N][&robots=A,B]
+//
+// One stream, ordered by server time, of what the robots did between
+// from and to (unix ms; to defaults to now, from to ten minutes before
+// it). Notices, markers, mode, navigation and e-stop changes are in by
+// default, each with a flag to leave it out (event, marker, mode,
+// nav_status, estop=0); pose and velocity samples, one per sample_ms,
+// only when asked (pose=1, velocity=1). A page holds up to limit
+// entries; next, when set, is the cursor of the following page.
+func (s *Server) FleetTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := formParams(r)
+	now := time.Now().UnixMilli()
+	q := robot.TimelineQuery{Kinds: map[string]bool{}}
+	q.To = p.int64("to", now, 0, math.MaxInt64)
+	q.From = p.int64("from", q.To-robot.DefaultTimelineWindow.Milliseconds(), 0, math.MaxInt64)
+	q.Limit = p.integer("limit", robot.DefaultTimelineLimit, 1, robot.MaxTimelineLimit)
+	q.SampleMs = p.int64("sample_ms", robot.DefaultTimelineSample.Milliseconds(), robot.MinTimelineSample.Milliseconds(), math.MaxInt32)
+	for _, kind := range robot.TimelineKinds {
+		sampled := kind == robot.TimelinePose || kind == robot.TimelineVelocity
+		q.Kinds[kind] = p.boolean(kind, !sampled)
+	}
+	if v := p.str("robots"); v != "" {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				q.RobotIDs = append(q.RobotIDs, id)
+			}
+		}
+	}
+	cursor, err := robot.ParseTimelineCursor(p.str("cursor"))
+	if err != nil {
+		p.fail("cursor", "must be the next value of a previous page")
+	}
+	q.Cursor = cursor
+	if q.From > q.To {
+		p.fail("from", "must not be after to")
+	}
+	if p.invalid(w) {
+		return
+	}
+	jsonOK(w, s.Manager.Timeline(q))
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// TestFleetTimelineAPI lines up notices, a marker and e-stop changes of
+// a connected robot and narrows the stream by kind and robot.
+func TestFleetTimelineAPI(t *testing.T) {
+	s := newTestServer(t)
+	f := newFakeRosbridge(t)
+	rb := connectRobot(t, s, f)
+	from := time.Now().UnixMilli()
+
+	// A few ms apart, as entries at the same ms are ordered by kind
+	for _, do := range []func(){
+		func() { rb.SetEStop(true) },
+		func() { rb.AddMarker("test", "bumped", "") },
+		func() { s.Manager.Notify("warning", rb.ID, "test", "slow") },
+		func() { rb.SetEStop(false) },
+	} {
+		do()
+		time.Sleep(5 * time.Millisecond)
+	}
+	to := time.Now().UnixMilli() + 1000
+
+	timeline := func(q string) robot.TimelinePage {
+		t.Helper()
+		rec := getReq(s.FleetTimeline, fmt.Sprintf("/api/fleet/timeline?from=%d&to=%d&%s", from, to, q))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", q, rec.Code, rec.Body)
+		}
+		var page robot.TimelinePage
+		decodeJSON(t, rec, &page)
+		return page
+	}
+	kinds := func(page robot.TimelinePage) string {
+		var out []string
+		for _, e := range page.Entries {
+			out = append(out, e.Kind)
+		}
+		return fmt.Sprint(out)
+	}
+
+	// The marker comes with its own notice, at the same ms
+	page := timeline("")
+	if got := kinds(page); got != "[estop event marker event estop]" {
+		t.Errorf("kinds %s", got)
+	}
+	for i, e := range page.Entries {
+		if e.RobotID != rb.ID || (i > 0 && e.Time < page.Entries[i-1].Time) {
+			t.Errorf("entry %d: %+v", i, e)
+		}
+	}
+	if got := kinds(timeline("estop=0&marker=false")); got != "[event event]" {
+		t.Errorf("without e-stop and markers: %s", got)
+	}
+	if page := timeline("robots=nobody"); len(page.Entries) != 0 {
+		t.Errorf("another robot: %+v", page.Entries)
+	}
+
+	// Paged one at a time, the same five
+	var paged []string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		page := timeline("limit=1&cursor=" + cursor)
+		if len(page.Entries) != 1 {
+			t.Fatalf("page %d: %+v", i, page)
+		}
+		paged = append(paged, page.Entries[0].Kind)
+		cursor = page.Next
+	}
+	if fmt.Sprint(paged) != kinds(page) || cursor != "" {
+		t.Errorf("paged %v, next %q", paged, cursor)
+	}
+
+	for _, q := range []string{"cursor=x", "estop=maybe", "limit=0", "sample_ms=10", fmt.Sprintf("from=%d&to=%d", to, from)} {
+		if rec := getReq(s.FleetTimeline, "/api/fleet/timeline?"+q); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d", q, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Floors ────────────────────
+
+// Floors handles GET /api/maps/floors?id=X — floors with their maps, the
+// active floor and the current map.
+func (h *MapHandlers) Floors(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, floorsStatus(rb))
+}
+
+// AssignFloor handles POST /api/maps/assign_floor?id=X
+//
+// Body {map, floor}; an empty floor removes the map's assignment.
+func (h *MapHandlers) AssignFloor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req assignFloorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Map = strings.TrimSpace(req.Map)
+	req.Floor = strings.TrimSpace(req.Floor)
+	if err := robot.ValidateMapName(req.Map); err != nil {
+		jsonFieldErrors(w, fieldErrors{"map": err.Error()})
+		return
+	}
+
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	rb.AssignFloor(req.Map, req.Floor)
+	jsonOK(w, floorsStatus(rb))
+}
+
+// SwitchFloor handles POST /api/robots/floor?id=X&floor=Y
+//
+// Opens the floor's map on the robot (the current one if it is already
+// on that floor, else the floor's last used map) and swaps in that map's
+// navigation points.
+func (h *RobotHandlers) SwitchFloor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	floor := strings.TrimSpace(r.FormValue("floor"))
+	if floor == "" {
+		jsonError(w, "floor required", http.StatusBadRequest)
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	mapName, err := h.Floors.SwitchFloor(rb, floor, clientAddr(r))
+	switch {
+	case errors.Is(err, robot.ErrUnknownFloor):
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, robot.ErrNotConnected):
+		jsonError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, robot.ErrPatrolRunning):
+		jsonError(w, "cannot switch floors while a patrol runs", http.StatusConflict)
+		return
+	case unsupported(w, err):
+		return
+	case err != nil:
+		log.Printf("[map] switch floor %s: %v", floor, err)
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[map] %s switched to floor %s (map %s)", rb.ID, floor, mapName)
+	st := floorsStatus(rb)
+	h.Robots.Broadcast(robot.BroadcastMsg{Type: "floor", RobotID: rb.ID, Data: st})
+	jsonOK(w, st)
+}
+
+func floorsStatus(rb *robot.Robot) floorsResponse {
+	return floorsResponse{
+		ActiveFloor: rb.ActiveFloor(),
+		CurrentMap:  rb.CurrentMap(),
+		Floors:      rb.Floors(),
+	}
+}
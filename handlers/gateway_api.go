@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"rom_go_app/robot"
+
+	"github.com/gorilla/websocket"
+)
+
+// ──────────────────── Robot gateway ────────────────────
+
+// Agents ping-pong on the gateway connection; one silent for
+// gatewayTimeout is dropped, so a robot whose cellular link died
+// without a close shows as disconnected.
+const (
+	gatewayPingInterval = 20 * time.Second
+	gatewayTimeout      = 60 * time.Second
+)
+
+var gatewayUpgrader = websocket.Upgrader{
+	ReadBufferSize:  65536,
+	WriteBufferSize: 65536,
+	CheckOrigin:     func(r *http.Request) bool { return true }, // agents aren't browsers
+}
+
+// RobotGateway handles GET /robot_gateway?namespace=/x&name=X
+//
+// The WebSocket a robot agent behind NAT connects out to. The agent
+// presents ROBOT_GATEWAY_TOKEN (Authorization: Bearer, or token) and then
+// relays the rosbridge protocol between the connection and the robot. The
+// first connection for a namespace registers the robot with connection
+// type "reverse"; later ones, after a drop, reattach to it and replace
+// a connection still open. Without a token configured the gateway
+// answers 404.
+func (h *RobotHandlers) RobotGateway(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var token string
+	if h.Config != nil {
+		token = h.Config.Dynamic().RobotGatewayToken
+	}
+	if token == "" {
+		jsonError(w, "robot gateway disabled (ROBOT_GATEWAY_TOKEN not set)", http.StatusNotFound)
+		return
+	}
+	if !gatewayTokenValid(r, token) {
+		jsonError(w, "invalid or missing gateway token", http.StatusUnauthorized)
+		return
+	}
+
+	p := formParams(r)
+	ns := p.requiredStr("namespace")
+	name := p.str("name")
+	if p.invalid(w) {
+		return
+	}
+	if name == "" {
+		name = strings.Trim(ns, "/")
+	}
+
+	ws, err := gatewayUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has answered
+	}
+	addr := clientAddr(r)
+	rb, created := h.Robots.RegisterReverseRobot(ns, name, addr)
+	if err := rb.Client.AttachAgent(newGatewayConn(ws), addr); err != nil {
+		// Removed meanwhile
+		log.Printf("[gateway] Agent for %s from %s refused: %v", ns, addr, err)
+		ws.Close()
+		return
+	}
+	if created {
+		h.Robots.Notify(robot.NoticeInfo, rb.ID, "connect",
+			fmt.Sprintf("Robot %s registered through the gateway from %s", rb.Name, addr))
+	}
+	log.Printf("[gateway] Agent for %s (robot %s) connected from %s", ns, rb.ID, addr)
+	go h.handshake(rb)
+}
+
+// gatewayTokenValid reports whether the request carries token, as a
+// bearer token or the token parameter.
+func gatewayTokenValid(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		got = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// gatewayConn is an agent connection as the robot's rosbridge
+// connection: it keeps the agent pinged and says goodbye with a close
+// frame, so the agent can tell a closed session from a lost link.
+type gatewayConn struct {
+	*websocket.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func newGatewayConn(ws *websocket.Conn) *gatewayConn {
+	c := &gatewayConn{Conn: ws, done: make(chan struct{})}
+	ws.SetReadDeadline(time.Now().Add(gatewayTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(gatewayTimeout))
+	})
+	go c.ping()
+	return c
+}
+
+func (c *gatewayConn) ping() {
+	t := time.NewTicker(gatewayPingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			if c.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *gatewayConn) ReadMessage() (int, []byte, error) {
+	mt, p, err := c.Conn.ReadMessage()
+	if err == nil {
+		c.SetReadDeadline(time.Now().Add(gatewayTimeout))
+	}
+	return mt, p, err
+}
+
+func (c *gatewayConn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		c.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session closed"), time.Now().Add(time.Second))
+	})
+	return c.Conn.Close()
+}
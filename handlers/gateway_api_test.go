@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"rom_go_app/config"
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+const testGatewayToken = "s3cret"
+
+// newGatewayServer returns a server with the robot gateway enabled for
+// token (disabled when empty) and the gateway's URL.
+func newGatewayServer(t *testing.T, token string) (*Server, string) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	cfg, err := config.Load(map[string]string{"ROBOT_GATEWAY_TOKEN": token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Config: cfg, Manager: robot.NewManager()}
+	srv := httptest.NewServer(http.HandlerFunc(s.robotHandlers().RobotGateway))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() {
+		for _, rb := range s.Manager.GetAllRobots() {
+			s.Manager.RemoveRobot(rb.ID)
+		}
+	})
+	return s, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// fakeAgent is the agent of a robot behind NAT: it dials out to the
+// gateway and speaks rosbridge there, answering every service call with
+// an empty result and publishing canned odometry on the odom topic once
+// subscribed.
+type fakeAgent struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	ops    []map[string]interface{}
+	closed *websocket.CloseError // the gateway's close frame
+	done   chan struct{}
+}
+
+// dialAgent connects an agent for namespace ns with token as a bearer
+// token; the response is that of the upgrade.
+func dialAgent(t *testing.T, gateway, ns, token string) (*fakeAgent, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(gateway+"?"+url.Values{"namespace": {ns}}.Encode(), header)
+	if err != nil {
+		return nil, resp, err
+	}
+	a := &fakeAgent{conn: conn, done: make(chan struct{})}
+	t.Cleanup(func() { conn.Close() })
+	go a.serve(ns)
+	return a, resp, nil
+}
+
+func (a *fakeAgent) write(v interface{}) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+	return a.conn.WriteJSON(v)
+}
+
+func (a *fakeAgent) serve(ns string) {
+	defer close(a.done)
+	// Recorded without echoing it: the gateway hangs up right after, and
+	// a failed echo would hide the frame behind a write error
+	a.conn.SetCloseHandler(func(code int, text string) error {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.closed = &websocket.CloseError{Code: code, Text: text}
+		return nil
+	})
+	for {
+		_, data, err := a.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var op map[string]interface{}
+		if json.Unmarshal(data, &op) != nil {
+			continue
+		}
+		a.mu.Lock()
+		a.ops = append(a.ops, op)
+		a.mu.Unlock()
+
+		switch {
+		case op["op"] == "call_service":
+			a.write(map[string]interface{}{
+				"op": "service_response", "id": op["id"], "service": op["service"],
+				"values": map[string]interface{}{}, "result": true,
+			})
+		case op["op"] == "subscribe" && op["topic"] == ns+"/odom":
+			go a.publishOdom(ns + "/odom")
+		}
+	}
+}
+
+// publishOdom sends the robot at (1.5, -2) moving at 0.25 m/s until the
+// connection closes.
+func (a *fakeAgent) publishOdom(topic string) {
+	msg := map[string]interface{}{"op": "publish", "topic": topic, "msg": map[string]interface{}{
+		"header": map[string]interface{}{"frame_id": "odom"},
+		"pose": map[string]interface{}{"pose": map[string]interface{}{
+			"position":    map[string]float64{"x": 1.5, "y": -2},
+			"orientation": map[string]float64{"w": 1},
+		}},
+		"twist": map[string]interface{}{"twist": map[string]interface{}{"linear": map[string]float64{"x": 0.25}}},
+	}}
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+		if a.write(msg) != nil {
+			return
+		}
+	}
+}
+
+func (a *fakeAgent) subscribed(topic string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, op := range a.ops {
+		if op["op"] == "subscribe" && op["topic"] == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// closeFrame waits for the gateway to end the session and returns its
+// close frame, nil when the connection just dropped.
+func (a *fakeAgent) closeFrame(t *testing.T) *websocket.CloseError {
+	t.Helper()
+	select {
+	case <-a.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the gateway kept the agent's connection open")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closed
+}
+
+func TestRobotGatewayAuth(t *testing.T) {
+	_, off := newGatewayServer(t, "")
+	if _, resp, err := dialAgent(t, off, "/r1", testGatewayToken); err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("gateway without a token configured: %v %+v", err, resp)
+	}
+
+	s, gateway := newGatewayServer(t, testGatewayToken)
+	for _, token := range []string{"", "wrong", testGatewayToken + "x"} {
+		if _, resp, err := dialAgent(t, gateway, "/r1", token); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: %v %+v", token, err, resp)
+		}
+	}
+	if _, resp, err := dialAgent(t, gateway, "", testGatewayToken); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("no namespace: %v %+v", err, resp)
+	}
+	if n := len(s.Manager.GetAllRobots()); n != 0 {
+		t.Errorf("%d robots registered by refused agents", n)
+	}
+
+	// The token parameter works too
+	header := http.Header{}
+	conn, _, err := websocket.DefaultDialer.Dial(gateway+"?namespace=/r1&token="+testGatewayToken, header)
+	if err != nil {
+		t.Fatalf("token parameter: %v", err)
+	}
+	conn.Close()
+}
+
+// TestRobotGatewayAgent registers a robot through its agent and checks
+// that it works as a dialed one would: subscriptions go to the agent and
+// its topic traffic reaches the robot's state.
+func TestRobotGatewayAgent(t *testing.T) {
+	s, gateway := newGatewayServer(t, testGatewayToken)
+	agent, _, err := dialAgent(t, gateway, "/r1", testGatewayToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rb *robot.Robot
+	waitUntil(t, "registration", func() bool {
+		robots := s.Manager.GetAllRobots()
+		if len(robots) == 1 {
+			rb = robots[0]
+		}
+		// The snapshot follows the client's connect handlers
+		return rb != nil && rb.Client.IsConnected() && rb.GetSnapshot().Connected
+	})
+	if rb.Namespace != "/r1" || rb.Name != "r1" || rb.ConnectionType() != robot.ConnectionReverse || rb.IP != "127.0.0.1" {
+		t.Errorf("robot %s %q at %s, connection %s", rb.Namespace, rb.Name, rb.IP, rb.ConnectionType())
+	}
+	list := robotList(s.Manager.GetAllRobots(), "")
+	if len(list) != 1 || list[0].Connection != robot.ConnectionReverse || !list[0].Connected {
+		t.Errorf("robot list = %+v", list)
+	}
+
+	waitUntil(t, "odom subscription", func() bool { return agent.subscribed("/r1/odom") })
+	waitUntil(t, "odometry", func() bool {
+		o := rb.GetSnapshot().Odom
+		return o.PosX == 1.5 && o.PosY == -2 && o.LinearX == 0.25
+	})
+}
+
+// TestRobotGatewayReconnect reconnects the agent: the robot is the same,
+// the stale connection is closed and the new one carries the traffic.
+func TestRobotGatewayReconnect(t *testing.T) {
+	s, gateway := newGatewayServer(t, testGatewayToken)
+	first, _, err := dialAgent(t, gateway, "/r1", testGatewayToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "registration", func() bool { return len(s.Manager.GetAllRobots()) == 1 })
+	rb := s.Manager.GetAllRobots()[0]
+	waitUntil(t, "first connection", rb.Client.IsConnected)
+
+	second, _, err := dialAgent(t, gateway, "/r1/", testGatewayToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ce := first.closeFrame(t); ce == nil || ce.Code != websocket.CloseNormalClosure {
+		t.Errorf("replaced connection closed with %v", ce)
+	}
+	if robots := s.Manager.GetAllRobots(); len(robots) != 1 || robots[0] != rb {
+		t.Fatalf("robots after reconnecting: %v", robots)
+	}
+	waitUntil(t, "second connection", func() bool { return rb.Client.IsConnected() && second.subscribed("/r1/odom") })
+
+	// Another namespace is another robot
+	if _, _, err := dialAgent(t, gateway, "/r2", testGatewayToken); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "second robot", func() bool { return len(s.Manager.GetAllRobots()) == 2 })
+}
+
+// TestRobotGatewayRemove removes a reverse robot: the gateway closes the
+// agent's session with a close frame, and the next connection registers
+// the robot anew.
+func TestRobotGatewayRemove(t *testing.T) {
+	s, gateway := newGatewayServer(t, testGatewayToken)
+	agent, _, err := dialAgent(t, gateway, "/r1", testGatewayToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "registration", func() bool {
+		robots := s.Manager.GetAllRobots()
+		return len(robots) == 1 && robots[0].Client.IsConnected()
+	})
+	rb := s.Manager.GetAllRobots()[0]
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/robots?id="+rb.ID, nil)
+	rec := httptest.NewRecorder()
+	s.robotHandlers().RemoveRobot(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("remove: %d %s", rec.Code, rec.Body.String())
+	}
+	if ce := agent.closeFrame(t); ce == nil || ce.Code != websocket.CloseNormalClosure || ce.Text != "session closed" {
+		t.Errorf("session ended with %v, want a normal close", ce)
+	}
+
+	if _, _, err := dialAgent(t, gateway, "/r1", testGatewayToken); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "registration anew", func() bool {
+		robots := s.Manager.GetAllRobots()
+		return len(robots) == 1 && robots[0] != rb
+	})
+}
+
+// TestRobotGatewayAgentGone drops the agent: the robot stays registered
+// and waits for it, since only the agent can reconnect.
+func TestRobotGatewayAgentGone(t *testing.T) {
+	s, gateway := newGatewayServer(t, testGatewayToken)
+	agent, _, err := dialAgent(t, gateway, "/r1", testGatewayToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "registration", func() bool {
+		robots := s.Manager.GetAllRobots()
+		return len(robots) == 1 && robots[0].Client.IsConnected()
+	})
+	rb := s.Manager.GetAllRobots()[0]
+
+	agent.conn.Close()
+	waitUntil(t, "disconnect", func() bool { return !rb.Client.IsConnected() })
+	if st := rb.Client.ReconnectStatus(); st.State != rosbridge.ConnAwaitingAgent {
+		t.Errorf("reconnect state %q, want %q", st.State, rosbridge.ConnAwaitingAgent)
+	}
+	rec := postForm(s.robotHandlers().ConnectRobot, "/api/robots/connect?id="+rb.ID, nil, false)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("connect without the agent: %d %s", rec.Code, rec.Body.String())
+	}
+	if robots := s.Manager.GetAllRobots(); len(robots) != 1 {
+		t.Errorf("%d robots after the agent left", len(robots))
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Handler groups ────────────────────
+//
+// The robot, map, navigation, speech and WebSocket handlers form groups
+// (RobotHandlers, MapHandlers, NavHandlers, SpeechHandlers, WSHandlers).
+// Each gets only what it works with, through the small interfaces below
+// or its own, and declares its own routes, so a group can be tested with
+// fakes instead of a whole Server. Server builds the groups from its
+// fields when it assembles the route table, and keeps the pages, the
+// HTMX partials and the cross-cutting routes itself.
+
+// robotFinder resolves the robot a request is about; *robot.Manager is
+// one.
+type robotFinder interface {
+	GetRobot(id string) *robot.Robot
+	GetCurrentRobot() *robot.Robot
+	GetCurrentRobotID() string
+}
+
+// broadcaster sends an event to every browser.
+type broadcaster interface {
+	Broadcast(msg robot.BroadcastMsg)
+}
+
+// renderer renders a template as the response; *Server is one (see
+// respond.go).
+type renderer interface {
+	render(w http.ResponseWriter, r *http.Request, name string, data interface{})
+}
+
+// confirmer issues and checks the tokens of destructive actions (see
+// destructive_api.go).
+type confirmer interface {
+	RequestDestructive(robotID, action, target, by string) robot.PendingAction
+	ConfirmDestructive(robotID, action, target, token string) (robot.PendingAction, error)
+}
+
+// The groups below are built from the server's fields each time the
+// route table is assembled, so the Manager's Thumbnails, MapArchive and
+// Points must be set before Routes is called (see main.go).
+
+func (s *Server) speechHandlers() *SpeechHandlers {
+	h := &SpeechHandlers{Robots: s.Manager, Setup: s.speech}
+	// A nil *phrases.Store would make a non-nil phraseTable
+	if s.Phrases != nil {
+		h.Phrases = s.Phrases
+	}
+	return h
+}
+
+func (s *Server) mapHandlers() *MapHandlers {
+	return &MapHandlers{Robots: s.Manager, Thumbnails: s.Manager.Thumbnails, Archive: s.Manager.MapArchive}
+}
+
+func (s *Server) navHandlers() *NavHandlers {
+	return &NavHandlers{Robots: s.Manager, Nav: s.NavManager, Confirm: s.Manager, Pages: s,
+		Points: s.Manager.Points, Config: s.Config}
+}
+
+func (s *Server) robotHandlers() *RobotHandlers {
+	return &RobotHandlers{Robots: s.Manager, Floors: s.NavManager, Pages: s, Discovery: s.Discovery, Config: s.Config}
+}
+
+func (s *Server) wsHandlers() *WSHandlers {
+	return &WSHandlers{Robots: s.Manager, Config: s.Config, Origins: s.Origins, Chaos: &s.BrowserChaos, UI: s.uiConfig}
+}
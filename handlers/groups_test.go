@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"rom_go_app/phrases"
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// fakeRobots is a robotFinder and broadcaster over a fixed set of robots
+// that are never connected.
+type fakeRobots struct {
+	mu      sync.Mutex
+	robots  map[string]*robot.Robot
+	current string
+	sent    []robot.BroadcastMsg
+}
+
+func newFakeRobots(ids ...string) *fakeRobots {
+	f := &fakeRobots{robots: map[string]*robot.Robot{}}
+	for _, id := range ids {
+		f.robots[id] = robot.NewRobot(id, "/"+id, "robot "+id, "127.0.0.1", 9)
+	}
+	if len(ids) > 0 {
+		f.current = ids[0]
+	}
+	return f
+}
+
+func (f *fakeRobots) GetRobot(id string) *robot.Robot { return f.robots[id] }
+func (f *fakeRobots) GetCurrentRobot() *robot.Robot   { return f.robots[f.current] }
+func (f *fakeRobots) GetCurrentRobotID() string       { return f.current }
+
+func (f *fakeRobots) Broadcast(msg robot.BroadcastMsg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+}
+
+// fakePages stands in for the server's templates: it writes the name of
+// what it was asked to render.
+type fakePages struct{ rendered []string }
+
+func (p *fakePages) render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	p.rendered = append(p.rendered, name)
+	w.Write([]byte(name))
+}
+
+func (p *fakePages) NavPointsPartial(w http.ResponseWriter, r *http.Request) {
+	p.render(w, r, "nav_points.html", nil)
+}
+
+func (p *fakePages) RobotListPartial(w http.ResponseWriter, r *http.Request) {
+	p.render(w, r, "robot_panel.html", nil)
+}
+
+// fakeConfirmer issues one token per request and accepts it once for the
+// same robot, action and target.
+type fakeConfirmer struct {
+	issued map[string]robot.PendingAction
+	n      int
+}
+
+func (c *fakeConfirmer) RequestDestructive(robotID, action, target, by string) robot.PendingAction {
+	if c.issued == nil {
+		c.issued = map[string]robot.PendingAction{}
+	}
+	c.n++
+	p := robot.PendingAction{Token: "token-" + strconv.Itoa(c.n), RobotID: robotID, Action: action,
+		Target: target, By: by, ExpiresAt: time.Now().Add(time.Minute)}
+	c.issued[p.Token] = p
+	return p
+}
+
+func (c *fakeConfirmer) ConfirmDestructive(robotID, action, target, token string) (robot.PendingAction, error) {
+	p, ok := c.issued[token]
+	if !ok || p.RobotID != robotID || p.Action != action || p.Target != target {
+		return robot.PendingAction{}, robot.ErrConfirmToken
+	}
+	delete(c.issued, token)
+	return p, nil
+}
+
+func getReq(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+// ──────────────────── Speech ────────────────────
+
+// fakePhrases maps every text to its upper case.
+type fakePhrases struct {
+	table  phrases.Table
+	mapped []string
+}
+
+func (p *fakePhrases) Snapshot() phrases.Snapshot { return phrases.Snapshot{Table: p.table} }
+func (p *fakePhrases) Set(t phrases.Table) error  { p.table = t; return nil }
+
+func (p *fakePhrases) Map(text, lang string) phrases.Result {
+	p.mapped = append(p.mapped, lang+":"+text)
+	return phrases.Result{Original: text, Command: strings.ToUpper(text), Status: phrases.StatusMapped}
+}
+
+func TestSpeechHandlersPhrases(t *testing.T) {
+	h := &SpeechHandlers{Robots: newFakeRobots("a")}
+	if rec := getReq(h.SpeechPhrases, "/api/speech/phrases"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a phrase table: %d", rec.Code)
+	}
+	dir := t.TempDir()
+	if res := h.mapTranscript(filepath.Join(dir, "a.wav"), "go home", "en"); res.Command != "go home" {
+		t.Errorf("without a phrase table: %+v, want the text as spoken", res)
+	}
+
+	table := &fakePhrases{}
+	h.Phrases = table
+	body := `{"phrases":[{"phrase":"go home","command":"dock","lang":"en"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/speech/phrases", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.SetSpeechPhrases(rec, req)
+	if rec.Code != http.StatusOK || len(table.table.Phrases) != 1 {
+		t.Fatalf("set: %d %s, table %+v", rec.Code, rec.Body.String(), table.table)
+	}
+
+	var resp speechPhrasesResponse
+	decodeJSON(t, getReq(h.SpeechPhrases, "/api/speech/phrases?text=go+home&language=en"), &resp)
+	if resp.Preview == nil || resp.Preview.Command != "GO HOME" || len(resp.Phrases) != 1 {
+		t.Errorf("preview = %+v", resp)
+	}
+
+	// The decision is kept next to the recording
+	if res := h.mapTranscript(filepath.Join(dir, "b.wav"), "stop", "my"); res.Command != "STOP" {
+		t.Errorf("mapped = %+v", res)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "b.phrases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry phraseLogEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Command != "STOP" || entry.Language != "my" {
+		t.Errorf("sidecar = %s (%v)", data, err)
+	}
+	if want := []string{"en:go home", "my:stop"}; strings.Join(table.mapped, ",") != strings.Join(want, ",") {
+		t.Errorf("mapped %v, want %v", table.mapped, want)
+	}
+}
+
+// ──────────────────── Maps ────────────────────
+
+type fakeMapRobots struct {
+	*fakeRobots
+	archived []string
+}
+
+func (f *fakeMapRobots) ArchiveMap(rb *robot.Robot, name, source string) (robot.MapVersion, error) {
+	f.archived = append(f.archived, rb.ID+"/"+name)
+	return robot.MapVersion{}, nil
+}
+
+func TestMapHandlersOptionalStores(t *testing.T) {
+	h := &MapHandlers{Robots: &fakeMapRobots{fakeRobots: newFakeRobots("a")}}
+
+	if rec := getReq(h.MapThumbnail, "/api/maps/thumbnail?name=office"); rec.Code != http.StatusNotFound {
+		t.Errorf("thumbnail without a store: %d", rec.Code)
+	}
+	for name, handler := range map[string]http.HandlerFunc{
+		"list": h.ListMapArchive, "archive": h.ArchiveMap, "grid": h.MapArchiveGrid, "diff": h.MapArchiveDiff,
+	} {
+		if rec := getReq(handler, "/api/maps/archive?id=a&name=office"); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s without an archive: %d", name, rec.Code)
+		}
+	}
+}
+
+func TestMapHandlersFloors(t *testing.T) {
+	robots := newFakeRobots("a", "b")
+	h := &MapHandlers{Robots: &fakeMapRobots{fakeRobots: robots}}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/maps/assign_floor?id=b", strings.NewReader(`{"map":"lobby","floor":"1"}`))
+	rec := httptest.NewRecorder()
+	h.AssignFloor(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("assign: %d %s", rec.Code, rec.Body.String())
+	}
+	if got := robots.robots["b"].FloorOf("lobby"); got != "1" {
+		t.Errorf("robot b: lobby on floor %q", got)
+	}
+	if got := robots.robots["a"].FloorOf("lobby"); got != "" {
+		t.Errorf("current robot a: lobby on floor %q, want unassigned", got)
+	}
+
+	// Without an id the current robot answers
+	var floors floorsResponse
+	decodeJSON(t, getReq(h.Floors, "/api/maps/floors"), &floors)
+	if len(floors.Floors) != 0 {
+		t.Errorf("robot a floors = %+v", floors.Floors)
+	}
+	if rec := getReq(h.Floors, "/api/maps/floors?id=c"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown robot: %d", rec.Code)
+	}
+}
+
+// ──────────────────── Navigation ────────────────────
+
+type fakeNavRobots struct{ *fakeRobots }
+
+func (fakeNavRobots) PointPersistence() []robot.PersistStatus { return nil }
+
+// fakeNavigator records the point edits; the methods it doesn't define
+// panic through the nil navigator.
+type fakeNavigator struct {
+	navigator
+	calls []string
+}
+
+func (n *fakeNavigator) ClearPoints(rb *robot.Robot, pointType rosbridge.PointType) error {
+	n.calls = append(n.calls, "clear "+rb.ID+" "+string(pointType))
+	return nil
+}
+
+func (n *fakeNavigator) DeletePoint(rb *robot.Robot, pointType rosbridge.PointType, name string) error {
+	n.calls = append(n.calls, "delete "+rb.ID+" "+string(pointType)+" "+name)
+	if name == "missing" {
+		return errors.New("no such point")
+	}
+	return nil
+}
+
+func newFakeNav() (*NavHandlers, *fakeNavigator, *fakePages) {
+	nav, pages := &fakeNavigator{}, &fakePages{}
+	return &NavHandlers{Robots: fakeNavRobots{newFakeRobots("a")}, Nav: nav, Confirm: &fakeConfirmer{}, Pages: pages}, nav, pages
+}
+
+func TestNavHandlersClearConfirmed(t *testing.T) {
+	h, nav, pages := newFakeNav()
+
+	rec := postForm(h.ClearNavigationPoints, "/api/nav/clear", url.Values{"type": {"waypoint"}}, false)
+	var pending destructivePendingResponse
+	decodeJSON(t, rec, &pending)
+	if rec.Code != http.StatusAccepted || pending.Token == "" || len(nav.calls) != 0 {
+		t.Fatalf("first request: %d %+v, calls %v", rec.Code, pending, nav.calls)
+	}
+	if rec := postForm(h.ClearNavigationPoints, "/api/nav/clear", url.Values{"type": {"wall"}, "token": {pending.Token}}, false); rec.Code != http.StatusConflict {
+		t.Errorf("token of another type: %d", rec.Code)
+	}
+
+	rec = postForm(h.ClearNavigationPoints, "/api/nav/clear", url.Values{"type": {"waypoint"}, "token": {pending.Token}}, true)
+	if rec.Code != http.StatusOK || rec.Body.String() != "nav_points.html" {
+		t.Errorf("confirm: %d %s", rec.Code, rec.Body.String())
+	}
+	if want := "clear a waypoint"; len(nav.calls) != 1 || nav.calls[0] != want {
+		t.Errorf("calls %v, want [%s]", nav.calls, want)
+	}
+
+	// The HTMX dialog is the group's pages
+	postForm(h.ClearNavigationPoints, "/api/nav/clear", url.Values{"type": {"waypoint"}}, true)
+	if last := pages.rendered[len(pages.rendered)-1]; last != "confirm.html" {
+		t.Errorf("rendered %v", pages.rendered)
+	}
+}
+
+func TestNavHandlersDelete(t *testing.T) {
+	h, nav, _ := newFakeNav()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/nav/delete?type=service_point&name=dock", nil)
+	rec := httptest.NewRecorder()
+	h.DeleteNavPoint(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodDelete, "/api/nav/delete?type=waypoint&name=missing", nil)
+	rec = httptest.NewRecorder()
+	h.DeleteNavPoint(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("failed delete: %d", rec.Code)
+	}
+	if want := "delete a service_point dock,delete a waypoint missing"; strings.Join(nav.calls, ",") != want {
+		t.Errorf("calls %v", nav.calls)
+	}
+
+	var st persistenceStatusResponse
+	decodeJSON(t, getReq(h.NavPersistenceStatus, "/api/nav/persistence"), &st)
+	if st.Enabled || st.Robots == nil {
+		t.Errorf("persistence without a store = %+v", st)
+	}
+}
+
+// ──────────────────── Robots ────────────────────
+
+// fakeFleet is a robotFleet whose changes are recorded; the methods it
+// doesn't define panic through the nil robotFleet.
+type fakeFleet struct {
+	robotFleet
+	*fakeRobots
+	fakeConfirmer
+	switched []string
+}
+
+func (f *fakeFleet) GetRobot(id string) *robot.Robot  { return f.fakeRobots.GetRobot(id) }
+func (f *fakeFleet) GetCurrentRobot() *robot.Robot    { return f.fakeRobots.GetCurrentRobot() }
+func (f *fakeFleet) GetCurrentRobotID() string        { return f.fakeRobots.GetCurrentRobotID() }
+func (f *fakeFleet) Broadcast(msg robot.BroadcastMsg) { f.fakeRobots.Broadcast(msg) }
+
+func (f *fakeFleet) RequestDestructive(robotID, action, target, by string) robot.PendingAction {
+	return f.fakeConfirmer.RequestDestructive(robotID, action, target, by)
+}
+
+func (f *fakeFleet) ConfirmDestructive(robotID, action, target, token string) (robot.PendingAction, error) {
+	return f.fakeConfirmer.ConfirmDestructive(robotID, action, target, token)
+}
+
+func (f *fakeFleet) CancelDestructive(robotID, token string) (robot.PendingAction, error) {
+	p, ok := f.issued[token]
+	if !ok || p.RobotID != robotID {
+		return robot.PendingAction{}, robot.ErrConfirmToken
+	}
+	delete(f.issued, token)
+	return p, nil
+}
+
+func (f *fakeFleet) GetAllRobots() []*robot.Robot {
+	return []*robot.Robot{f.robots["a"], f.robots["b"]}
+}
+
+func (f *fakeFleet) SwitchRobot(id string) error {
+	if f.robots[id] == nil {
+		return errors.New("robot not found")
+	}
+	f.switched = append(f.switched, id)
+	f.current = id
+	return nil
+}
+
+func newFakeFleet() (*RobotHandlers, *fakeFleet, *fakePages) {
+	fleet, pages := &fakeFleet{fakeRobots: newFakeRobots("a", "b")}, &fakePages{}
+	return &RobotHandlers{Robots: fleet, Pages: pages}, fleet, pages
+}
+
+func TestRobotHandlersSwitchAndList(t *testing.T) {
+	h, fleet, _ := newFakeFleet()
+
+	if rec := postForm(h.SwitchRobot, "/api/robots/switch", url.Values{"id": {"c"}}, false); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown robot: %d", rec.Code)
+	}
+	rec := postForm(h.SwitchRobot, "/api/robots/switch", url.Values{"id": {"b"}}, true)
+	if rec.Code != http.StatusOK || rec.Body.String() != "robot_panel.html" {
+		t.Errorf("switch: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(fleet.switched) != 1 || fleet.switched[0] != "b" {
+		t.Errorf("switched %v", fleet.switched)
+	}
+
+	var list []robotListEntry
+	decodeJSON(t, getReq(h.ListRobots, "/api/robots"), &list)
+	if len(list) != 2 || list[0].ID != "a" || list[0].Current || !list[1].Current || list[1].Connected {
+		t.Errorf("list = %+v", list)
+	}
+}
+
+func TestRobotHandlersPowerOffConfirmed(t *testing.T) {
+	h, fleet, _ := newFakeFleet()
+
+	rec := postForm(h.PowerOff, "/api/robots/poweroff", url.Values{"id": {"a"}}, false)
+	var pending destructivePendingResponse
+	decodeJSON(t, rec, &pending)
+	if rec.Code != http.StatusAccepted || pending.Action != robot.ActionPowerOff || pending.RobotID != "a" {
+		t.Fatalf("first request: %d %+v", rec.Code, pending)
+	}
+	if rec := postForm(h.Reboot, "/api/robots/reboot", url.Values{"id": {"a"}, "token": {pending.Token}}, false); rec.Code != http.StatusConflict {
+		t.Errorf("power off token for a reboot: %d", rec.Code)
+	}
+
+	rec = postForm(h.CancelDestructive, "/api/robots/destructive/cancel", url.Values{"id": {"a"}, "token": {pending.Token}}, false)
+	var cancelled destructiveCancelResponse
+	decodeJSON(t, rec, &cancelled)
+	if cancelled.Action != robot.ActionPowerOff || len(fleet.issued) != 0 {
+		t.Errorf("cancel = %+v, pending %v", cancelled, fleet.issued)
+	}
+	if rec := postForm(h.CancelDestructive, "/api/robots/destructive/cancel", url.Values{"id": {"a"}, "token": {pending.Token}}, false); rec.Code != http.StatusNotFound {
+		t.Errorf("second cancel: %d", rec.Code)
+	}
+}
+
+// ──────────────────── WebSocket ────────────────────
+
+// fakeHub hands out one broadcast channel and records what the
+// connection leaves behind.
+type fakeHub struct {
+	wsHub
+	*fakeRobots
+	bcast chan robot.BroadcastMsg
+
+	mu           sync.Mutex
+	unsubscribed bool
+	released     []string
+}
+
+func (f *fakeHub) GetRobot(id string) *robot.Robot    { return f.fakeRobots.GetRobot(id) }
+func (f *fakeHub) GetCurrentRobot() *robot.Robot      { return f.fakeRobots.GetCurrentRobot() }
+func (f *fakeHub) GetCurrentRobotID() string          { return f.fakeRobots.GetCurrentRobotID() }
+func (f *fakeHub) GetAllRobots() []*robot.Robot       { return []*robot.Robot{f.robots["a"]} }
+func (f *fakeHub) Subscribe() chan robot.BroadcastMsg { return f.bcast }
+func (f *fakeHub) Sequences() map[string]map[string]uint64 {
+	return map[string]map[string]uint64{}
+}
+func (f *fakeHub) RecentNotices(since time.Time, robotID string) []robot.Notice { return nil }
+func (f *fakeHub) Unwatch(ids ...string)                                        {}
+
+func (f *fakeHub) Unsubscribe(ch chan robot.BroadcastMsg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unsubscribed = true
+}
+
+func (f *fakeHub) ReleaseControlClient(clientID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.released = append(f.released, clientID)
+}
+
+func TestWSHandlersHub(t *testing.T) {
+	hub := &fakeHub{fakeRobots: newFakeRobots("a"), bcast: make(chan robot.BroadcastMsg, 4)}
+	h := &WSHandlers{Robots: hub, Chaos: &rosbridge.ChaosSettings{}, UI: func() UIConfig { return UIConfig{} }}
+	srv := httptest.NewServer(http.HandlerFunc(h.WSHandler))
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := readFrame(t, conn)
+	b, _ := json.Marshal(msg.Data)
+	var hello WSHello
+	if err := json.Unmarshal(b, &hello); err != nil || msg.Type != "hello" {
+		t.Fatalf("first frame %s %s (%v)", msg.Type, b, err)
+	}
+	if len(hello.Robots) != 1 || hello.Robots[0].ID != "a" || !hello.Robots[0].Current || hello.ClientID == "" {
+		t.Errorf("hello = %+v", hello)
+	}
+	if got := readFrame(t, conn); got.Type != "stream_reset" {
+		t.Fatalf("frame %q, want stream_reset", got.Type)
+	}
+
+	// Broadcasts of the hub reach the browser
+	hub.bcast <- robot.BroadcastMsg{Type: "status", RobotID: "a"}
+	if got := readFrame(t, conn); got.Type != "status" || got.RobotID != "a" {
+		t.Errorf("frame = %+v", got)
+	}
+
+	conn.Close()
+	waitUntil(t, "cleanup", func() bool {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		return hub.unsubscribed && len(hub.released) == 1 && hub.released[0] == hello.ClientID
+	})
+}
+
+// waitUntil polls cond for up to two seconds.
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
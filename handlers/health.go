@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+	"rom_go_app/version"
+)
+
+// ──────────────────── Health & readiness ────────────────────
+
+var startTime = time.Now()
+
+// Healthz handles GET /healthz — process liveness plus build info.
+func (s *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"status":         "ok",
+		"build":          version.Get(),
+		"uptime_seconds": int(time.Since(startTime).Seconds()),
+	})
+}
+
+// readyCheck is a single readiness check result.
+type readyCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Readyz handles GET /readyz[?strict=1]
+//
+// The server is ready when templates are parsed and the static FS is
+// mounted. With strict=1, at least one robot must also be connected.
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checks := map[string]readyCheck{
+		"templates": s.checkTemplates(),
+		"static":    s.checkStatic(),
+	}
+	if r.URL.Query().Get("strict") == "1" {
+		checks["robots"] = s.checkRobots()
+	}
+
+	ready := true
+	for _, c := range checks {
+		if !c.OK {
+			ready = false
+		}
+	}
+
+	code := http.StatusOK
+	status := "ready"
+	if !ready {
+		code = http.StatusServiceUnavailable
+		status = "not_ready"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+func (s *Server) checkTemplates() readyCheck {
+	if s.NoUI {
+		return readyCheck{OK: true, Detail: "UI disabled"}
+	}
+	if s.Templates == nil {
+		return readyCheck{Detail: "templates not parsed"}
+	}
+	if s.Templates.Lookup("layout.html") == nil {
+		return readyCheck{Detail: "layout.html missing"}
+	}
+	// Broken fragments degrade single panels; report them but stay ready
+	if failed := s.Templates.Failed(); len(failed) > 0 {
+		return readyCheck{OK: true, Detail: "failed to parse: " + strings.Join(failed, ", ")}
+	}
+	return readyCheck{OK: true}
+}
+
+func (s *Server) checkStatic() readyCheck {
+	if s.NoUI {
+		return readyCheck{OK: true, Detail: "UI disabled"}
+	}
+	if s.Static == nil {
+		return readyCheck{Detail: "static FS not mounted"}
+	}
+	if _, err := fs.Stat(s.Static, "js/app.js"); err != nil {
+		return readyCheck{Detail: "static FS incomplete: " + err.Error()}
+	}
+	return readyCheck{OK: true}
+}
+
+func (s *Server) checkRobots() readyCheck {
+	robots := s.Manager.GetAllRobots()
+	if len(robots) == 0 {
+		return readyCheck{Detail: "no robots configured"}
+	}
+	for _, rb := range robots {
+		if rb.Client != nil && rb.Client.IsConnected() {
+			return readyCheck{OK: true}
+		}
+	}
+	return readyCheck{Detail: "no robot connected"}
+}
+
+// robotHealth is the per-robot entry of /api/robots/health.
+type robotHealth struct {
+	ID        string                  `json:"id"`
+	Name      string                  `json:"name"`
+	Connected bool                    `json:"connected"`
+	Healthy   bool                    `json:"healthy"`
+	Problems  []string                `json:"problems"`
+	Topics    []rosbridge.TopicHealth `json:"topics"`
+
+	// ClockSkewMs is the robot clock offset (positive: ahead), null until
+	// a stamped message arrives.
+	ClockSkewMs *float64 `json:"clock_skew_ms"`
+
+	// Localization is the pose covariance grade; poor is a problem.
+	Localization robot.Localization `json:"localization"`
+
+	// TFFrames reports whether the map, odom and base frames were seen;
+	// GET /api/robots/tf_tree has the full tree.
+	TFFrames []rosbridge.FrameCheck `json:"tf_frames"`
+}
+
+// RobotsHealth handles GET /api/robots/health
+//
+// Reports connection state and rosbridge status errors per subscribed
+// topic, e.g. "subscription to /robot1/scan failing: ...".
+func (s *Server) RobotsHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	robots := s.Manager.GetAllRobots()
+	out := make([]robotHealth, 0, len(robots))
+	for _, rb := range robots {
+		snap := rb.GetSnapshot()
+		h := robotHealth{
+			ID:        snap.ID,
+			Name:      snap.Name,
+			Connected: snap.Connected,
+			Problems:  []string{},
+			Topics:    []rosbridge.TopicHealth{},
+		}
+		if !snap.Connected {
+			h.Problems = append(h.Problems, "not connected")
+		}
+		if rb.Client != nil {
+			h.Topics = rb.Client.TopicHealth()
+			for _, t := range h.Topics {
+				if p := t.Problem(); p != "" {
+					h.Problems = append(h.Problems, p)
+				}
+			}
+			if sk := rb.Client.ClockSkew(); sk.Exceeded {
+				h.Problems = append(h.Problems, fmt.Sprintf("robot clock off by %.1f s", sk.SkewMs/1000))
+			}
+			tf := rb.Client.GetFrameTree()
+			h.TFFrames = tf.Checks
+			// Only judge the tree once transforms arrive; a silent /tf
+			// is a topic problem, reported above.
+			if snap.Connected && len(tf.Edges) > 0 {
+				if missing := tf.Missing(); len(missing) > 0 {
+					h.Problems = append(h.Problems, "tf frames not published: "+strings.Join(missing, ", ")+" (see /api/robots/tf_tree)")
+				} else if !tf.PoseChain {
+					h.Problems = append(h.Problems, "tf chain map→odom→base_footprint stale or broken (see /api/robots/tf_tree)")
+				}
+			}
+		}
+		h.ClockSkewMs = snap.ClockSkewMs
+		h.Localization = snap.Localization
+		if snap.Connected && snap.Localization.Quality == robot.LocalizationPoor {
+			h.Problems = append(h.Problems, fmt.Sprintf("localization poor (σxy %.2f m, σyaw %.2f rad)", snap.Localization.StdXYM, snap.Localization.StdYawRad))
+		}
+		h.Healthy = len(h.Problems) == 0
+		out = append(out, h)
+	}
+	jsonOK(w, out)
+}
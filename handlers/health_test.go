@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+type readyBody struct {
+	Status string                `json:"status"`
+	Checks map[string]readyCheck `json:"checks"`
+}
+
+func getReadyz(t *testing.T, s *Server, query string) (int, readyBody) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz"+query, nil))
+	var body readyBody
+	decodeJSON(t, rec, &body)
+	return rec.Code, body
+}
+
+func TestHealthz(t *testing.T) {
+	s := &Server{}
+	rec := httptest.NewRecorder()
+	s.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code = %d, want 200", rec.Code)
+	}
+	var body struct {
+		Status string                 `json:"status"`
+		Build  map[string]interface{} `json:"build"`
+		Uptime *int                   `json:"uptime_seconds"`
+	}
+	decodeJSON(t, rec, &body)
+	if body.Status != "ok" || body.Build == nil || body.Uptime == nil {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.Healthz(rec, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST code = %d, want 405", rec.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	s := newTestServer(t)
+	code, body := getReadyz(t, s, "")
+	if code != http.StatusOK || body.Status != "ready" {
+		t.Fatalf("got %d %+v, want 200 ready", code, body)
+	}
+	if _, ok := body.Checks["robots"]; ok {
+		t.Error("robots checked without strict=1")
+	}
+}
+
+func TestReadyzNotReady(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*Server)
+		check string
+	}{
+		{"no templates", func(s *Server) { s.Templates = nil }, "templates"},
+		{"no static", func(s *Server) { s.Static = nil }, "static"},
+		{"incomplete static", func(s *Server) { s.Static = fstest.MapFS{"css/app.css": {}} }, "static"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			tt.setup(s)
+			code, body := getReadyz(t, s, "")
+			if code != http.StatusServiceUnavailable || body.Status != "not_ready" {
+				t.Fatalf("got %d %q, want 503 not_ready", code, body.Status)
+			}
+			for name, c := range body.Checks {
+				if want := name != tt.check; c.OK != want {
+					t.Errorf("check %s ok = %v, want %v (%s)", name, c.OK, want, c.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestReadyzNoUI(t *testing.T) {
+	s := &Server{NoUI: true, Static: os.DirFS("/nonexistent")}
+	code, body := getReadyz(t, s, "")
+	if code != http.StatusOK {
+		t.Fatalf("code = %d, want 200: %+v", code, body)
+	}
+	if d := body.Checks["templates"].Detail; d != "UI disabled" {
+		t.Errorf("templates detail = %q", d)
+	}
+}
+
+func TestReadyzStrict(t *testing.T) {
+	s := newTestServer(t)
+	code, body := getReadyz(t, s, "?strict=1")
+	if code != http.StatusServiceUnavailable || body.Checks["robots"].Detail != "no robots configured" {
+		t.Fatalf("no robots: got %d %+v", code, body)
+	}
+
+	f := newFakeRosbridge(t)
+	host, port := f.addr(t)
+	rb, err := s.Manager.AddRobot("", "idle", host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rb.Client.Close)
+	code, body = getReadyz(t, s, "?strict=1")
+	if code != http.StatusServiceUnavailable || body.Checks["robots"].Detail != "no robot connected" {
+		t.Fatalf("disconnected: got %d %+v", code, body)
+	}
+	// Not strict: robots don't count
+	if code, _ := getReadyz(t, s, ""); code != http.StatusOK {
+		t.Fatalf("non-strict code = %d, want 200", code)
+	}
+
+	if err := rb.Client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	code, body = getReadyz(t, s, "?strict=1")
+	if code != http.StatusOK || !body.Checks["robots"].OK {
+		t.Fatalf("connected: got %d %+v", code, body)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"rom_go_app/robot"
+	"rom_go_app/units"
+)
+
+// newTestServer returns a Server over the repo's templates and static
+// files with an empty robot manager.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	funcs := units.FuncMap()
+	funcs["asset"] = func(p string) string { return "/static/" + p }
+	tmpl, err := ParseTemplates(os.DirFS(".."), funcs)
+	if err != nil {
+		t.Fatalf("ParseTemplates: %v", err)
+	}
+	return &Server{
+		Manager:   robot.NewManager(),
+		Templates: tmpl,
+		Static:    os.DirFS("../static"),
+	}
+}
+
+// fakeRosbridge is a rosbridge server that answers every service call
+// with the values set for its service, or an empty result.
+type fakeRosbridge struct {
+	srv *httptest.Server
+
+	mu     sync.Mutex
+	values map[string]interface{}
+	ops    []map[string]interface{}
+	conns  []*websocket.Conn
+
+	writeMu sync.Mutex // one writer per connection at a time
+}
+
+func newFakeRosbridge(t *testing.T) *fakeRosbridge {
+	t.Helper()
+	f := &fakeRosbridge{values: make(map[string]interface{})}
+	up := websocket.Upgrader{}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.mu.Unlock()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var op map[string]interface{}
+			if json.Unmarshal(data, &op) != nil {
+				continue
+			}
+			f.mu.Lock()
+			f.ops = append(f.ops, op)
+			values := f.values[str(op["service"])]
+			f.mu.Unlock()
+			if op["op"] != "call_service" {
+				continue
+			}
+			if values == nil {
+				values = map[string]interface{}{}
+			}
+			f.writeMu.Lock()
+			conn.WriteJSON(map[string]interface{}{
+				"op": "service_response", "id": op["id"], "service": op["service"],
+				"values": values, "result": true,
+			})
+			f.writeMu.Unlock()
+		}
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// addr returns the host and port robots should dial.
+func (f *fakeRosbridge) addr(t *testing.T) (string, int) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(f.srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+// calls returns the services called so far, in order.
+func (f *fakeRosbridge) calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, op := range f.ops {
+		if op["op"] == "call_service" {
+			out = append(out, str(op["service"]))
+		}
+	}
+	return out
+}
+
+// publish sends msg on topic to every connected client.
+func (f *fakeRosbridge) publish(topic string, msg interface{}) {
+	f.mu.Lock()
+	conns := append([]*websocket.Conn(nil), f.conns...)
+	f.mu.Unlock()
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+	for _, c := range conns {
+		c.WriteJSON(map[string]interface{}{"op": "publish", "topic": topic, "msg": msg})
+	}
+}
+
+// subscribed reports whether the last subscribe or unsubscribe of topic
+// was a subscribe.
+func (f *fakeRosbridge) subscribed(topic string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	on := false
+	for _, op := range f.ops {
+		if str(op["topic"]) == topic {
+			switch op["op"] {
+			case "subscribe":
+				on = true
+			case "unsubscribe":
+				on = false
+			}
+		}
+	}
+	return on
+}
+
+// connectRobot adds a robot on f to s and connects it.
+func connectRobot(t *testing.T, s *Server, f *fakeRosbridge) *robot.Robot {
+	t.Helper()
+	host, port := f.addr(t)
+	rb, err := s.Manager.AddRobot("", "test", host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rb.Client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(rb.Client.Close)
+	return rb
+}
+
+// decodeJSON decodes the recorded response body into v.
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────── Home position ────────────────────
+
+// RobotHome handles GET/POST /api/robots/home?id=X
+//
+// GET returns the home pose. POST sets it from x, y, theta (radians) and
+// map (default: the current map), or with here=1 from the robot's current
+// map pose; clear=1 removes it.
+func (h *RobotHandlers) RobotHome(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		switch {
+		case r.FormValue("clear") == "1":
+			rb.SetHome(nil)
+		case r.FormValue("here") == "1":
+			maxAge := 3 * time.Second
+			if h.Config != nil && h.Config.NavPoseMaxAge > 0 {
+				maxAge = h.Config.NavPoseMaxAge
+			}
+			if _, err := rb.SetHomeHere(maxAge); err != nil {
+				jsonError(w, err.Error(), http.StatusConflict)
+				return
+			}
+		default:
+			var pose rosbridge.Pose2D
+			for name, dst := range map[string]*float64{"x": &pose.X, "y": &pose.Y, "theta": &pose.Theta} {
+				f, err := strconv.ParseFloat(r.FormValue(name), 64)
+				if err != nil && (name != "theta" || r.FormValue(name) != "") {
+					jsonError(w, "invalid or missing "+name, http.StatusBadRequest)
+					return
+				}
+				*dst = f
+			}
+			h := robot.HomePose{Pose2D: pose, Map: r.FormValue("map")}
+			if h.Map == "" {
+				h.Map = rb.CurrentMap()
+			}
+			if err := rb.SetHome(&h); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jsonOK(w, homeResponse{Home: rb.GetHome()})
+}
+
+// GoHome handles POST /api/robots/go_home?id=X
+//
+// Sends the robot to its home pose. Refused with 409 when no home is set,
+// the robot is on another map, e-stopped or disconnected. Progress
+// arrives as home and nav_status WS messages.
+func (h *RobotHandlers) GoHome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	home, err := rb.GoHome()
+	switch {
+	case errors.Is(err, robot.ErrNoHome), errors.Is(err, robot.ErrHomeWrongMap),
+		errors.Is(err, robot.ErrEStopped), errors.Is(err, robot.ErrNotConnected):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, goHomeResponse{Status: "going_home", Home: home})
+}
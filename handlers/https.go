@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ──────────────────── HTTPS redirect ────────────────────
+
+// RedirectHTTPS serves the plain HTTP listener when HTTPS runs on
+// httpsAddr: every request is redirected to the same host and path on the
+// HTTPS port, except the health probes, which are passed to probes so
+// load balancers and scripts can keep checking over HTTP. 307 keeps the
+// method and body of API calls. A request that reached the app without
+// basePath, stripped by a proxy, is redirected to the path with it.
+func RedirectHTTPS(httpsAddr, basePath string, probes http.Handler) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, basePath)
+		if p == "/healthz" || p == "/readyz" {
+			BasePath(basePath, probes).ServeHTTP(w, r)
+			return
+		}
+		uri := r.URL.RequestURI()
+		if basePath != "" && r.URL.Path != basePath && !strings.HasPrefix(r.URL.Path, basePath+"/") {
+			uri = basePath + uri
+		}
+		host := requestHost(r)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+uri, http.StatusTemporaryRedirect)
+	})
+}
+
+// wsScheme is the WebSocket scheme matching how the page was served;
+// X-Forwarded-Proto covers a TLS-terminating proxy in front.
+func wsScheme(r *http.Request) string {
+	if requestScheme(r) == "https" {
+		return "wss"
+	}
+	return "ws"
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHTTPS(t *testing.T) {
+	probes := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("probe " + r.URL.Path)) })
+
+	for _, tc := range []struct {
+		name, httpsAddr, basePath string
+		target, forwardedHost     string
+		want                      string
+	}{
+		{"custom port", ":8443", "", "http://robots.local:8080/api/robots?id=1", "", "https://robots.local:8443/api/robots?id=1"},
+		{"default port", ":443", "", "http://robots.local/maps", "", "https://robots.local/maps"},
+		{"no port", "", "", "http://robots.local:8080/", "", "https://robots.local/"},
+		{"IPv6", ":443", "", "http://[fe80::1]:8080/", "", "https://[fe80::1]/"},
+		{"IPv6 with port", "0.0.0.0:8443", "", "http://[fe80::1]:8080/", "", "https://[fe80::1]:8443/"},
+		{"behind a proxy", ":8443", "", "http://10.0.0.5:8080/x", "robots.example", "https://robots.example:8443/x"},
+		{"base path kept", ":8443", "/robots", "http://h/robots/api/x", "", "https://h:8443/robots/api/x"},
+		{"base path stripped", ":8443", "/robots", "http://h/api/x?a=b", "", "https://h:8443/robots/api/x?a=b"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tc.target, nil)
+		if tc.forwardedHost != "" {
+			req.Header.Set("X-Forwarded-Host", tc.forwardedHost)
+		}
+		rec := httptest.NewRecorder()
+		RedirectHTTPS(tc.httpsAddr, tc.basePath, probes).ServeHTTP(rec, req)
+		if rec.Code != http.StatusTemporaryRedirect || rec.Header().Get("Location") != tc.want {
+			t.Errorf("%s: %d %q, want 307 %q", tc.name, rec.Code, rec.Header().Get("Location"), tc.want)
+		}
+	}
+
+	// Health probes stay on plain HTTP
+	for _, tc := range []struct{ basePath, path, body string }{
+		{"", "/healthz", "probe /healthz"},
+		{"", "/readyz", "probe /readyz"},
+		{"/robots", "/robots/healthz", "probe /healthz"},
+	} {
+		rec := httptest.NewRecorder()
+		RedirectHTTPS(":8443", tc.basePath, probes).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != tc.body {
+			t.Errorf("%s with base %q: %d %q", tc.path, tc.basePath, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestWSScheme(t *testing.T) {
+	for _, tc := range []struct {
+		target, proto, want string
+	}{
+		{"http://h/", "", "ws"},
+		{"https://h/", "", "wss"},
+		{"http://h/", "https", "wss"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		if got := wsScheme(req); got != tc.want {
+			t.Errorf("%s (forwarded %q): %s, want %s", tc.target, tc.proto, got, tc.want)
+		}
+	}
+}
@@ -2,18 +2,51 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
 )
 
+// ──────────────────── Map handlers ────────────────────
+
+// mapRobots is what the map handlers need of the fleet; *robot.Manager
+// is one.
+type mapRobots interface {
+	robotFinder
+	broadcaster
+	ArchiveMap(rb *robot.Robot, name, source string) (robot.MapVersion, error)
+}
+
+// MapHandlers serves maps, floors, the map archive, mapping sessions and
+// modes (see Routes). Thumbnails and Archive are nil when the app runs
+// without them.
+type MapHandlers struct {
+	Robots     mapRobots
+	Thumbnails *robot.ThumbnailStore
+	Archive    *robot.MapArchive
+}
+
+func (h *MapHandlers) lookupRobot(w http.ResponseWriter, id string) *robot.Robot {
+	return findRobot(w, h.Robots, id)
+}
+
 // ListMaps returns available maps from the current robot.
-func (s *Server) ListMaps(w http.ResponseWriter, r *http.Request) {
+func (h *MapHandlers) ListMaps(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no robot selected", http.StatusBadRequest)
 		return
@@ -21,37 +54,164 @@ func (s *Server) ListMaps(w http.ResponseWriter, r *http.Request) {
 
 	maps := rb.GetMapList()
 
-	// If map list is empty, try fetching from robot
-	if len(maps) == 0 && rb.Client != nil && rb.Client.IsConnected() {
-		names, err := rb.Client.RequestWhichMapsNames()
+	// If map list is empty or refresh=1, try fetching from robot; a
+	// requested refresh is queued while it is disconnected
+	refresh := r.URL.Query().Get("refresh") == "1"
+	var pending *robot.PendingCommand
+	if len(maps) == 0 || refresh {
+		names, err := rb.RefreshMapList()
 		if err == nil {
 			maps = names
-			rb.SetMapList(names)
+		} else if refresh {
+			cmd, ok := queueOffline(w, rb, err, robot.PendingMapList, "", "map list refresh", func() error {
+				_, err := rb.RefreshMapList()
+				return err
+			})
+			if ok && cmd == nil {
+				return
+			}
+			pending = cmd
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"maps": maps,
+	json.NewEncoder(w).Encode(mapsResponse{Maps: maps, Pending: pending})
+}
+
+// MapRenderHints handles GET /api/maps/render_hints?id=X
+func (h *MapHandlers) MapRenderHints(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"render_hints": rb.GetRenderHints(),
+		"palettes":     robot.MapPalettes,
 	})
 }
 
+// ExportMapPGM handles GET /api/maps/export?id=X — the current map as a
+// PGM image classified with the robot's render hints.
+func (h *MapHandlers) ExportMapPGM(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	frame := rb.GetMapFrame()
+	if frame.Width == 0 || frame.Height == 0 {
+		jsonError(w, "no map received yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/x-portable-graymap")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"map_%s.pgm\"", rb.ID))
+	w.Write(robot.EncodePGM(frame.MapData, frame.RenderHints))
+}
+
+// CurrentMapMeta handles GET /api/maps/current_meta?id=X — size, origin,
+// map_seq and checksum of the current map, for clients polling over HTTP
+// that only fetch the grid when map_seq changes.
+func (h *MapHandlers) CurrentMapMeta(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	meta := rb.GetMapMeta()
+	if meta.MapSeq == 0 {
+		jsonError(w, "no map received yet", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, meta)
+}
+
+// MapTransform handles GET /api/maps/transform?id=X — converts one
+// position on the current map between world coordinates (x, y, theta),
+// image pixels (px, py, image_theta_deg) and grid cells (col, row),
+// with the same code the server uses, and returns all three with the
+// cell's occupancy and the conventions. Give exactly one of the pairs.
+func (h *MapHandlers) MapTransform(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	p := formParams(r)
+	var from []string
+	for _, pair := range [][2]string{{"x", "y"}, {"px", "py"}, {"col", "row"}} {
+		if p.str(pair[0]) != "" || p.str(pair[1]) != "" {
+			from = append(from, pair[0])
+		}
+	}
+	if len(from) != 1 {
+		p.fail("x", "give x and y, px and py, or col and row")
+	}
+	if p.invalid(w) {
+		return
+	}
+
+	frame := rb.GetMapFrame()
+	t := robot.NewMapTransform(frame.MapData)
+	if !t.Valid() {
+		jsonError(w, "no map received yet", http.StatusNotFound)
+		return
+	}
+
+	const huge = math.MaxFloat64
+	var res mapTransformResponse
+	switch from[0] {
+	case "x":
+		res.From = "world"
+		res.World.X, res.World.Y = p.requiredFloat("x", -huge, huge), p.requiredFloat("y", -huge, huge)
+		res.World.ThetaRad = p.float("theta", 0, -2*math.Pi, 2*math.Pi)
+		res.Image.X, res.Image.Y = t.WorldToImagePx(res.World.X, res.World.Y)
+		res.Image.ThetaDeg = t.WorldToImageTheta(res.World.ThetaRad)
+		res.Cell.Col, res.Cell.Row, res.Cell.InBounds = t.WorldToCell(res.World.X, res.World.Y)
+	case "px":
+		res.From = "image"
+		res.Image.X, res.Image.Y = p.requiredFloat("px", -huge, huge), p.requiredFloat("py", -huge, huge)
+		res.Image.ThetaDeg = p.float("image_theta_deg", 0, -360, 360)
+		res.World.X, res.World.Y = t.ImagePxToWorld(res.Image.X, res.Image.Y)
+		res.World.ThetaRad = t.ImageThetaToWorld(res.Image.ThetaDeg)
+		res.Cell.Col, res.Cell.Row, res.Cell.InBounds = t.ImagePxToCell(res.Image.X, res.Image.Y)
+	case "col":
+		res.From = "cell"
+		res.Cell.Col = int(p.requiredInt64("col", math.MinInt32, math.MaxInt32))
+		res.Cell.Row = int(p.requiredInt64("row", math.MinInt32, math.MaxInt32))
+		res.Cell.InBounds = t.InBounds(res.Cell.Col, res.Cell.Row)
+		res.World.X, res.World.Y = t.CellToWorld(res.Cell.Col, res.Cell.Row)
+		res.Image.X, res.Image.Y = t.WorldToImagePx(res.World.X, res.World.Y)
+	}
+	if p.invalid(w) {
+		return
+	}
+
+	res.CellCenter.X, res.CellCenter.Y = t.CellToWorld(res.Cell.Col, res.Cell.Row)
+	if v, ok := robot.CellValue(frame.MapData, res.Cell.Col, res.Cell.Row); ok {
+		res.Cell.Value = &v
+		res.Cell.Class = frame.RenderHints.Classify(v).String()
+	}
+	res.Map = t
+	res.MapSeq = rb.GetMapMeta().MapSeq
+	res.Conventions = robot.Conventions
+	jsonOK(w, res)
+}
+
 // SaveMap saves the current map with a given name.
-func (s *Server) SaveMap(w http.ResponseWriter, r *http.Request) {
+func (h *MapHandlers) SaveMap(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
-		jsonError(w, "map name required", http.StatusBadRequest)
+	name, ok := mapNameBody(w, r)
+	if !ok {
 		return
 	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no robot selected", http.StatusBadRequest)
 		return
@@ -61,32 +221,60 @@ func (s *Server) SaveMap(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := rb.Client.SaveMap(req.Name)
-	if err != nil {
-		log.Printf("[map] save map error: %v", err)
-		jsonError(w, "save map failed: "+err.Error(), http.StatusInternalServerError)
+	// The robot can take a minute; progress and the result are broadcast
+	// as map_save and kept for /api/maps/save_status
+	op, err := rb.StartMapSave(name, clientAddr(r))
+	switch {
+	case errors.Is(err, robot.ErrMapSaveInProgress):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, robot.ErrNotConnected):
+		jsonError(w, "robot not connected", http.StatusServiceUnavailable)
+		return
+	case unsupported(w, err):
+		return
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	jsonOK(w, map[string]string{"status": "ok", "map": req.Name})
+	jsonOK(w, mapSaveResponse{Status: op.State, Map: op.MapName, Op: op.ID})
+}
+
+// MapSaveStatus handles GET /api/maps/save_status?op=ID[&id=X] — the
+// state of a recent map save.
+func (h *MapHandlers) MapSaveStatus(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	p := formParams(r)
+	opID := p.requiredStr("op")
+	if p.invalid(w) {
+		return
+	}
+	op, ok := rb.GetMapSave(opID)
+	if !ok {
+		jsonError(w, "save operation not found", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, op)
 }
 
 // OpenMap opens/selects a map by name.
-func (s *Server) OpenMap(w http.ResponseWriter, r *http.Request) {
+func (h *MapHandlers) OpenMap(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
-		jsonError(w, "map name required", http.StatusBadRequest)
+	name, ok := mapNameBody(w, r)
+	if !ok {
 		return
 	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no robot selected", http.StatusBadRequest)
 		return
@@ -96,24 +284,107 @@ func (s *Server) OpenMap(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := rb.Client.SelectMap(req.Name)
+	if unsupported(w, rb.Require(rosbridge.CapMapSelect)) {
+		return
+	}
+	_, err := rb.Client.SelectMap(name)
 	if err != nil {
 		log.Printf("[map] open map error: %v", err)
 		jsonError(w, "open map failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	rb.RecordMapEvent(robot.MapActionOpen, name, clientAddr(r))
+	// Opening swaps in the map's points; other clients refresh on this
+	h.Robots.Broadcast(robot.BroadcastMsg{Type: "floor", RobotID: rb.ID, Data: floorsStatus(rb)})
+	// Maps saved outside the app get a preview from their first frame
+	if th := h.Thumbnails; th != nil && !th.Exists(rb.StoreKey(), name) {
+		rb.WantMapThumbnail(name)
+	}
 
-	jsonOK(w, map[string]string{"status": "ok", "map": req.Name})
+	jsonOK(w, map[string]string{"status": "ok", "map": name})
+}
+
+// MapThumbnail handles GET /api/maps/thumbnail?name=X[&id=Y] — a PNG
+// preview of a saved map, or 404 when there is none.
+func (h *MapHandlers) MapThumbnail(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		id = h.Robots.GetCurrentRobotID()
+	}
+	p := formParams(r)
+	name := p.requiredStr("name")
+	if p.invalid(w) {
+		return
+	}
+
+	rb := h.Robots.GetRobot(id)
+	if rb == nil {
+		jsonError(w, "robot not found", http.StatusNotFound)
+		return
+	}
+	th := h.Thumbnails
+	if th == nil || !th.Exists(rb.StoreKey(), name) {
+		jsonError(w, "no thumbnail", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, th.Path(rb.StoreKey(), name))
+}
+
+// MapHistory handles GET /api/maps/history?id=X[&limit=N] — the loaded
+// map and recent saves/opens, newest first.
+func (h *MapHandlers) MapHistory(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	p := formParams(r)
+	limit := p.integer("limit", 20, 1, math.MaxInt32)
+	if p.invalid(w) {
+		return
+	}
+	jsonOK(w, mapHistoryResponse{CurrentMap: rb.CurrentMap(), History: rb.MapHistory(limit)})
+}
+
+// mapNameBody decodes a {"name": ...} request body, answering 400 when
+// it isn't JSON or the name isn't valid (see robot.ValidateMapName).
+func mapNameBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req mapNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return "", false
+	}
+	if err := robot.ValidateMapName(req.Name); err != nil {
+		jsonFieldErrors(w, fieldErrors{"name": err.Error()})
+		return "", false
+	}
+	return req.Name, true
+}
+
+// clientAddr identifies the requester for history entries: the remote
+// host, or the first X-Forwarded-For hop behind a proxy.
+func clientAddr(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		first, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(first)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
 }
 
 // SetNavigationMode requests navigation mode from the current robot.
-func (s *Server) SetNavigationMode(w http.ResponseWriter, r *http.Request) {
+func (h *MapHandlers) SetNavigationMode(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no robot selected", http.StatusBadRequest)
 		return
@@ -123,7 +394,7 @@ func (s *Server) SetNavigationMode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := rb.Client.RequestNavigationMode()
+	err := rb.SwitchMode(robot.ModeNavigation)
 	if err != nil {
 		jsonError(w, "set navigation mode failed: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -132,13 +403,13 @@ func (s *Server) SetNavigationMode(w http.ResponseWriter, r *http.Request) {
 }
 
 // SetMappingMode requests mapping mode from the current robot.
-func (s *Server) SetMappingMode(w http.ResponseWriter, r *http.Request) {
+func (h *MapHandlers) SetMappingMode(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no robot selected", http.StatusBadRequest)
 		return
@@ -148,7 +419,10 @@ func (s *Server) SetMappingMode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := rb.Client.RequestMappingMode()
+	err := rb.SwitchMode(robot.ModeMapping)
+	if unsupported(w, err) {
+		return
+	}
 	if err != nil {
 		jsonError(w, "set mapping mode failed: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -157,13 +431,13 @@ func (s *Server) SetMappingMode(w http.ResponseWriter, r *http.Request) {
 }
 
 // SetRemappingMode requests remapping mode from the current robot.
-func (s *Server) SetRemappingMode(w http.ResponseWriter, r *http.Request) {
+func (h *MapHandlers) SetRemappingMode(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no robot selected", http.StatusBadRequest)
 		return
@@ -173,7 +447,10 @@ func (s *Server) SetRemappingMode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := rb.Client.RequestRemappingMode()
+	err := rb.SwitchMode(robot.ModeRemapping)
+	if unsupported(w, err) {
+		return
+	}
 	if err != nil {
 		jsonError(w, "set remapping mode failed: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -181,11 +458,135 @@ func (s *Server) SetRemappingMode(w http.ResponseWriter, r *http.Request) {
 	jsonOK(w, map[string]string{"status": "ok", "mode": "remapping"})
 }
 
+// ──────────────────────────── Mapping sessions
+
+// MappingStart handles POST /api/mapping/start[?id=X] {name}
+//
+// Switches the robot to mapping and starts tracking coverage; name is the
+// map the session will be saved as.
+func (h *MapHandlers) MappingStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	name, ok := mapNameBody(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := rb.StartMapping(name)
+	switch {
+	case errors.Is(err, robot.ErrMappingActive):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, robot.ErrNotConnected):
+		jsonError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case unsupported(w, err):
+		return
+	case err != nil:
+		jsonError(w, "set mapping mode failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, session)
+}
+
+// MappingStatus handles GET /api/mapping/status[?id=X] — the active or
+// last session with its coverage statistics.
+func (h *MapHandlers) MappingStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	session := rb.GetMappingSession()
+	if session == nil {
+		jsonError(w, "no mapping session", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, session)
+}
+
+// MappingProgress handles GET /api/mapping/progress[?id=X] — coverage
+// growth of the map being built, as "mapping_progress" WS messages carry
+// it; active is false, with the last run's figures, when not mapping.
+func (h *MapHandlers) MappingProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, rb.MappingProgress())
+}
+
+// MappingFinish handles POST /api/mapping/finish[?id=X]
+//
+// Saves the map under the session's name, switches to navigation and
+// opens it. Returns the finished session as a summary.
+func (h *MapHandlers) MappingFinish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	session, err := rb.FinishMapping()
+	switch {
+	case errors.Is(err, robot.ErrNoMappingActive):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[map] finish mapping error: %v", err)
+		jsonError(w, "finish mapping failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, session)
+}
+
+// MappingAbort handles POST /api/mapping/abort[?id=X] — ends the session
+// without saving and restores the previous mode.
+func (h *MapHandlers) MappingAbort(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	session, err := rb.AbortMapping()
+	switch {
+	case errors.Is(err, robot.ErrNoMappingActive):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		jsonError(w, "restore mode failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, session)
+}
+
 // ──────────────────────────── Dialog handlers
 
 // SaveMapDialog renders the save map dialog fragment.
 func (s *Server) SaveMapDialog(w http.ResponseWriter, r *http.Request) {
-	s.render(w, "save_map.html", nil)
+	s.render(w, r, "save_map.html", nil)
 }
 
 // OpenMapDialog renders the open map dialog fragment.
@@ -195,26 +596,49 @@ func (s *Server) OpenMapDialog(w http.ResponseWriter, r *http.Request) {
 	rb := s.Manager.GetCurrentRobot()
 	if rb != nil {
 		maps = rb.GetMapList()
-		// Try refreshing from robot if connected
-		if rb.Client != nil && rb.Client.IsConnected() {
-			names, err := rb.Client.RequestWhichMapsNames()
-			if err == nil && len(names) > 0 {
-				maps = names
-				rb.SetMapList(names)
-			}
+		// Try refreshing from robot if connected; the dialog falls back
+		// to the cached list
+		names, err := rb.RefreshMapList()
+		switch {
+		case errors.Is(err, robot.ErrNotConnected):
+		case err != nil:
+			s.Manager.Notify(robot.NoticeWarn, rb.ID, "map",
+				fmt.Sprintf("Could not refresh the map list from %s, showing the last known maps: %v", rb.Name, err))
+		case len(names) > 0:
+			maps = names
 		}
 	}
-	s.render(w, "open_map.html", map[string]interface{}{"Maps": maps})
+	s.render(w, r, "open_map.html", map[string]interface{}{"Maps": mapEntries(s.Manager.Thumbnails, rb, maps)})
 }
 
-// ConfirmDialog renders a generic confirmation dialog.
-func (s *Server) ConfirmDialog(w http.ResponseWriter, r *http.Request) {
-	title := r.URL.Query().Get("title")
-	message := r.URL.Query().Get("message")
-	action := r.URL.Query().Get("action")
-	s.render(w, "confirm.html", map[string]interface{}{
-		"Title":   title,
-		"Message": message,
-		"Action":  action,
+// mapEntry is a map in the open-map dialog; Thumbnail is empty when no
+// preview is stored, Floor when the map isn't assigned to one.
+type mapEntry struct {
+	Name      string
+	Thumbnail string
+	Floor     string
+}
+
+// mapEntries lists names grouped by floor (floors by name, unassigned
+// maps last), keeping the given order within a floor.
+func mapEntries(th *robot.ThumbnailStore, rb *robot.Robot, names []string) []mapEntry {
+	out := make([]mapEntry, 0, len(names))
+	for _, name := range names {
+		e := mapEntry{Name: name}
+		if rb != nil {
+			e.Floor = rb.FloorOf(name)
+		}
+		if th != nil && rb != nil && th.Exists(rb.StoreKey(), name) {
+			e.Thumbnail = "/api/maps/thumbnail?" + url.Values{"id": {rb.ID}, "name": {name}}.Encode()
+		}
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].Floor, out[j].Floor
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		return a < b
 	})
+	return out
 }
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+// TestMapTransformAPI converts a position on a quarter-turned 4×3 map
+// given in each of the three frames.
+func TestMapTransformAPI(t *testing.T) {
+	s := newTestServer(t)
+	f := newFakeRosbridge(t)
+	rb := connectRobot(t, s, f)
+	h := s.mapHandlers().MapTransform
+
+	if rec := getReq(h, "/api/maps/transform?id="+rb.ID+"&x=1&y=2"); rec.Code != http.StatusNotFound {
+		t.Errorf("before a map: %d", rec.Code)
+	}
+	waitUntil(t, "the map subscription", func() bool { return f.subscribed("/map") })
+	f.publish("/map", map[string]interface{}{
+		"info": map[string]interface{}{
+			"width": 4, "height": 3, "resolution": 0.5,
+			"origin": map[string]interface{}{
+				"position":    map[string]float64{"x": 1, "y": 2},
+				"orientation": map[string]float64{"z": math.Sin(math.Pi / 4), "w": math.Cos(math.Pi / 4)},
+			},
+		},
+		"data": []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 100},
+	})
+	waitUntil(t, "the map", func() bool { return rb.GetMapFrame().Width == 4 })
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for _, q := range []string{"x=-0.25&y=3.75&theta=3.14159265358979", "px=3.5&py=0.5&image_theta_deg=90", "col=3&row=2"} {
+		rec := getReq(h, "/api/maps/transform?id="+rb.ID+"&"+q)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", q, rec.Code, rec.Body)
+		}
+		var res mapTransformResponse
+		decodeJSON(t, rec, &res)
+		if res.Cell.Col != 3 || res.Cell.Row != 2 || !res.Cell.InBounds || res.Cell.Value == nil || *res.Cell.Value != 100 || res.Cell.Class != "occupied" {
+			t.Errorf("%s: cell %+v", q, res.Cell)
+		}
+		if !near(res.World.X, -0.25) || !near(res.World.Y, 3.75) || !near(res.Image.X, 3.5) || !near(res.Image.Y, 0.5) {
+			t.Errorf("%s: world %+v, image %+v", q, res.World, res.Image)
+		}
+		if !near(res.CellCenter.X, -0.25) || !near(res.CellCenter.Y, 3.75) || !near(res.Map.OriginYaw, math.Pi/2) || res.Conventions.Image == "" {
+			t.Errorf("%s: %+v", q, res)
+		}
+	}
+
+	// Outside the grid: no occupancy
+	rec := getReq(h, "/api/maps/transform?id="+rb.ID+"&x=1.1&y=2.1")
+	var res mapTransformResponse
+	decodeJSON(t, rec, &res)
+	if res.From != "world" || res.Cell.InBounds || res.Cell.Value != nil || res.Cell.Row != -1 {
+		t.Errorf("outside: %+v", res.Cell)
+	}
+
+	for _, q := range []string{"", "x=1", "x=1&y=2&col=0&row=0", "x=a&y=2", "col=0.5&row=0"} {
+		if rec := getReq(h, "/api/maps/transform?id="+rb.ID+"&"+q); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: %d", q, rec.Code)
+		}
+	}
+}
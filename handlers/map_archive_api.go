@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Map archive ────────────────────
+
+// mapArchiveEnabled answers 503 when the app runs without a map archive.
+func (h *MapHandlers) mapArchiveEnabled(w http.ResponseWriter) bool {
+	if h.Archive == nil {
+		jsonError(w, "map archive disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// ListMapArchive handles GET /api/maps/archive?id=X[&name=N]
+//
+// The robot's archived map versions, newest first, and the archive's
+// disk use.
+func (h *MapHandlers) ListMapArchive(w http.ResponseWriter, r *http.Request) {
+	if !h.mapArchiveEnabled(w) {
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	versions, err := h.Archive.List(rb.StoreKey(), r.URL.Query().Get("name"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, mapArchiveResponse{RobotID: rb.ID, Versions: versions, Usage: h.Archive.Usage()})
+}
+
+// ArchiveMap handles POST /api/maps/archive?id=X[&name=N]
+//
+// Archives the robot's current map as a version of name (default: the
+// loaded map), as saving it through the app does.
+func (h *MapHandlers) ArchiveMap(w http.ResponseWriter, r *http.Request) {
+	if !h.mapArchiveEnabled(w) {
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		name = rb.CurrentMap()
+	}
+	if name == "" {
+		jsonError(w, "name required: no map loaded through the app", http.StatusBadRequest)
+		return
+	}
+	if !rb.GetSnapshot().MapReceived {
+		jsonError(w, "no map received yet", http.StatusNotFound)
+		return
+	}
+	v, err := h.Robots.ArchiveMap(rb, name, robot.MapArchiveManual)
+	if err != nil {
+		jsonError(w, "archiving failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, v)
+}
+
+// MapArchiveGrid handles GET /api/maps/archive/grid?id=X&name=N&version=V[&encoding=base64_rle]
+//
+// An archived map in the form map WS messages carry, so the canvas can
+// draw it over the current one.
+func (h *MapHandlers) MapArchiveGrid(w http.ResponseWriter, r *http.Request) {
+	if !h.mapArchiveEnabled(w) {
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	p := formParams(r)
+	name := p.requiredStr("name")
+	version := p.requiredInt64("version", 0, math.MaxInt64)
+	encoding := p.enum("encoding", "", "", EncodingBase64RLE)
+	if p.invalid(w) {
+		return
+	}
+
+	frame, v, ok := h.loadArchivedMap(w, rb, name, version)
+	if !ok {
+		return
+	}
+	var m interface{} = frame
+	if encoding == EncodingBase64RLE {
+		m = encodeMapRLE(frame)
+	}
+	jsonOK(w, mapArchiveGridResponse{Version: v, Map: m})
+}
+
+// MapArchiveDiff handles GET /api/maps/archive/diff?id=X&name=N&from=V[&to=V]
+//
+// Cell statistics between two archived versions of a map (to defaults
+// to the newest) and a one-line summary.
+func (h *MapHandlers) MapArchiveDiff(w http.ResponseWriter, r *http.Request) {
+	if !h.mapArchiveEnabled(w) {
+		return
+	}
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	p := formParams(r)
+	name := p.requiredStr("name")
+	from := p.requiredInt64("from", 0, math.MaxInt64)
+	to := p.int64("to", -1, 0, math.MaxInt64)
+	if p.invalid(w) {
+		return
+	}
+
+	if to < 0 {
+		versions, err := h.Archive.List(rb.StoreKey(), name)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(versions) == 0 {
+			jsonError(w, robot.ErrArchiveNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		to = versions[0].Version
+	}
+	before, fromV, ok := h.loadArchivedMap(w, rb, name, from)
+	if !ok {
+		return
+	}
+	after, toV, ok := h.loadArchivedMap(w, rb, name, to)
+	if !ok {
+		return
+	}
+	d, err := robot.DiffMaps(before, after)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	d.From, d.To = fromV, toV
+	jsonOK(w, d)
+}
+
+// loadArchivedMap loads a version, answering 404 or 500 on failure.
+func (h *MapHandlers) loadArchivedMap(w http.ResponseWriter, rb *robot.Robot, name string, version int64) (robot.MapFrame, robot.MapVersion, bool) {
+	frame, v, err := h.Archive.Load(rb.StoreKey(), name, version)
+	switch {
+	case errors.Is(err, robot.ErrArchiveNotFound):
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return frame, v, false
+	case err != nil:
+		jsonError(w, "reading archived map: "+err.Error(), http.StatusInternalServerError)
+		return frame, v, false
+	}
+	return frame, v, true
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Bandwidth & metrics ────────────────────
+
+// RobotBandwidth handles GET /api/robots/bandwidth?id=X
+//
+// Cumulative rosbridge bytes/messages in and out since the robot was
+// added (counters survive reconnects), rolling one-minute rates, and
+// inbound traffic per topic.
+func (h *RobotHandlers) RobotBandwidth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, rb.Bandwidth())
+}
+
+// RobotSubscriptions handles GET /api/robots/subscriptions?id=X
+//
+// The topics the robot's client has subscribed on its current rosbridge
+// connection, with their throttle and compression; each should appear
+// once however often the client reconnected.
+func (h *RobotHandlers) RobotSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, subscriptionsResponse{
+		Connected:     rb.Client.IsConnected(),
+		Split:         rb.Client.SplitEnabled(),
+		Subscriptions: rb.Client.Subscriptions(),
+	})
+}
+
+// Metrics handles GET /metrics in the Prometheus text format.
+func (s *Server) Metrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m := newMetricsWriter(w)
+	defer m.flush()
+
+	m.family("rom_uptime_seconds", "gauge", "Seconds since the server started.")
+	m.sample("rom_uptime_seconds", nil, time.Since(startTime).Seconds())
+
+	m.family("rom_broadcast_encodes_total", "counter", "Broadcast messages JSON-encoded; once per broadcast however many clients receive it.")
+	m.sample("rom_broadcast_encodes_total", nil, float64(robot.BroadcastEncodes()))
+	m.family("rom_ws_frames_sent_total", "counter", "Frames written to browser WebSockets.")
+	m.sample("rom_ws_frames_sent_total", nil, float64(wsFramesSent.Load()))
+	m.family("rom_ws_connection_encodes_total", "counter", "WebSocket frames encoded for a single connection: replies and base64_rle maps.")
+	m.sample("rom_ws_connection_encodes_total", nil, float64(wsConnectionEncodes.Load()))
+
+	robots := s.Manager.GetAllRobots()
+	m.family("rom_robot_connected", "gauge", "1 if the robot's rosbridge connection is up.")
+	for _, rb := range robots {
+		m.sample("rom_robot_connected", robotLabels(rb.ID, rb.Name), boolValue(rb.IsConnected()))
+	}
+
+	m.family("rom_robot_clock_skew_seconds", "gauge", "Robot clock offset from message stamps; positive means the robot is ahead.")
+	for _, rb := range robots {
+		if sk := rb.Client.ClockSkew(); sk.Valid {
+			m.sample("rom_robot_clock_skew_seconds", robotLabels(rb.ID, rb.Name), sk.SkewMs/1000)
+		}
+	}
+
+	report := s.Manager.BandwidthReport()
+	m.family("rom_rosbridge_connections_total", "counter", "Successful rosbridge dials, including reconnects and data-plane connections.")
+	for _, b := range report {
+		m.sample("rom_rosbridge_connections_total", robotLabels(b.ID, b.Name), float64(b.Connections))
+	}
+	m.family("rom_rosbridge_bytes_total", "counter", "Websocket payload bytes exchanged with rosbridge.")
+	for _, b := range report {
+		m.sample("rom_rosbridge_bytes_total", robotLabels(b.ID, b.Name, "direction", "in"), float64(b.BytesIn))
+		m.sample("rom_rosbridge_bytes_total", robotLabels(b.ID, b.Name, "direction", "out"), float64(b.BytesOut))
+	}
+	m.family("rom_rosbridge_messages_total", "counter", "Websocket messages exchanged with rosbridge.")
+	for _, b := range report {
+		m.sample("rom_rosbridge_messages_total", robotLabels(b.ID, b.Name, "direction", "in"), float64(b.MessagesIn))
+		m.sample("rom_rosbridge_messages_total", robotLabels(b.ID, b.Name, "direction", "out"), float64(b.MessagesOut))
+	}
+	m.family("rom_rosbridge_bytes_per_second", "gauge", "Rolling one-minute rosbridge byte rate.")
+	for _, b := range report {
+		m.sample("rom_rosbridge_bytes_per_second", robotLabels(b.ID, b.Name, "direction", "in"), b.RateIn)
+		m.sample("rom_rosbridge_bytes_per_second", robotLabels(b.ID, b.Name, "direction", "out"), b.RateOut)
+	}
+	m.family("rom_rosbridge_topic_bytes_total", "counter", "Inbound publish bytes per subscribed topic.")
+	for _, b := range report {
+		for _, t := range b.Topics {
+			m.sample("rom_rosbridge_topic_bytes_total", robotLabels(b.ID, b.Name, "topic", t.Topic), float64(t.Bytes))
+		}
+	}
+	m.family("rom_rosbridge_topic_messages_total", "counter", "Inbound publish messages per subscribed topic.")
+	for _, b := range report {
+		for _, t := range b.Topics {
+			m.sample("rom_rosbridge_topic_messages_total", robotLabels(b.ID, b.Name, "topic", t.Topic), float64(t.Messages))
+		}
+	}
+	m.family("rom_rosbridge_queue_depth", "gauge", "Received rosbridge frames waiting for their handler, per message class.")
+	for _, b := range report {
+		for _, q := range b.Queues {
+			m.sample("rom_rosbridge_queue_depth", robotLabels(b.ID, b.Name, "class", q.Class), float64(q.Depth))
+		}
+	}
+	m.family("rom_rosbridge_queue_dropped_total", "counter", "Map and scan frames replaced by a newer one before being handled.")
+	for _, b := range report {
+		for _, q := range b.Queues {
+			m.sample("rom_rosbridge_queue_dropped_total", robotLabels(b.ID, b.Name, "class", q.Class), float64(q.Dropped))
+		}
+	}
+	m.family("rom_rosbridge_queue_waits_total", "counter", "Frames the read loop held because their class queue was full.")
+	for _, b := range report {
+		for _, q := range b.Queues {
+			m.sample("rom_rosbridge_queue_waits_total", robotLabels(b.ID, b.Name, "class", q.Class), float64(q.Waits))
+		}
+	}
+	m.family("rom_rosbridge_fragmented_total", "counter", "Fragmented rosbridge messages by outcome: reassembled, or discarded as expired, over the buffer limit or invalid.")
+	for _, b := range report {
+		f := b.Fragments
+		for _, o := range []struct {
+			outcome string
+			n       uint64
+		}{{"reassembled", f.Reassembled}, {"expired", f.Expired}, {"overflow", f.Overflow}, {"invalid", f.Invalid}} {
+			m.sample("rom_rosbridge_fragmented_total", robotLabels(b.ID, b.Name, "outcome", o.outcome), float64(o.n))
+		}
+	}
+}
+
+// metricsWriter emits the Prometheus text exposition format.
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+func newMetricsWriter(w http.ResponseWriter) *metricsWriter {
+	return &metricsWriter{w: bufio.NewWriter(w)}
+}
+
+func (m *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes one line; labels are name/value pairs.
+func (m *metricsWriter) sample(name string, labels []string, v float64) {
+	m.w.WriteString(name)
+	if len(labels) > 0 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			fmt.Fprintf(m.w, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		m.w.WriteByte('}')
+	}
+	m.w.WriteByte(' ')
+	m.w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	m.w.WriteByte('\n')
+}
+
+func (m *metricsWriter) flush() { m.w.Flush() }
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func robotLabels(id, name string, extra ...string) []string {
+	return append([]string{"robot", id, "name", name}, extra...)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Relative moves & e-stop ────────────────────
+
+// MoveRelative handles POST/DELETE /api/robots/move_relative[?id=X]
+//
+// POST {distance_m, angle_deg, max_speed} starts a closed-loop nudge and
+// returns its move ID; progress arrives as move_progress WS messages.
+// While a control lease is held, client_id must name its holder. DELETE
+// cancels the active move and stops the robot.
+func (h *RobotHandlers) MoveRelative(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		cancelled := rb.CancelMove()
+		rb.SetVelocity(0, 0, 0)
+		jsonOK(w, map[string]bool{"cancelled": cancelled})
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := rb.CheckControl(r.URL.Query().Get("client_id")); err != nil {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	var req robot.RelativeMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	moveID, err := rb.MoveRelative(req)
+	switch {
+	case errors.Is(err, robot.ErrEStopped), errors.Is(err, robot.ErrNotConnected), errors.Is(err, robot.ErrNoOdom):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonOK(w, map[string]string{"move_id": moveID})
+}
+
+// EStop handles GET/POST /api/robots/estop[?id=X]
+//
+// POST engaged=1 engages the software e-stop (zero velocity, active moves
+// aborted, new moves refused); engaged=0 releases it.
+func (h *RobotHandlers) EStop(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		v := r.FormValue("engaged")
+		engaged := v == "" || v == "1" || v == "true"
+		rb.SetEStop(engaged)
+		h.Robots.Broadcast(robot.BroadcastMsg{Type: "estop", RobotID: rb.ID, Data: map[string]bool{"engaged": engaged}})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jsonOK(w, map[string]bool{"engaged": rb.EStopped()})
+}
+
+// AutonomyLock handles GET/POST /api/robots/autonomy_lock[?id=X]
+//
+// POST locked=1 rejects joystick input as if the robot were navigating;
+// locked=0 clears the manual lock (navigation and patrols still lock).
+// Changes are broadcast as "autonomy".
+func (h *RobotHandlers) AutonomyLock(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		jsonOK(w, rb.GetAutonomy())
+	case http.MethodPost:
+		v := r.FormValue("locked")
+		jsonOK(w, rb.SetAutonomyLock(v == "" || v == "1" || v == "true"))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
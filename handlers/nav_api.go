@@ -2,49 +2,104 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
+	"rom_go_app/config"
+	"rom_go_app/importer"
+	"rom_go_app/robot"
 	"rom_go_app/rosbridge"
 )
 
+// ──────────────────── Navigation handlers ────────────────────
+
+// navRobots is what the navigation handlers need of the fleet;
+// *robot.Manager is one.
+type navRobots interface {
+	robotFinder
+	broadcaster
+	PointPersistence() []robot.PersistStatus
+}
+
+// navigator edits, sends and runs navigation points;
+// *robot.NavigationManager is one.
+type navigator interface {
+	AddPoint(rb *robot.Robot, pointType rosbridge.PointType, p rosbridge.NavigationPoint) error
+	AddPoints(rb *robot.Robot, pointType rosbridge.PointType, pts []rosbridge.NavigationPoint) (int, []robot.PointError)
+	AddWallObstacle(rb *robot.Robot, name string, x1, y1, x2, y2 float64) error
+	ValidateApproach(rb *robot.Robot, p rosbridge.NavigationPoint) error
+	CheckReplace(rb *robot.Robot, pointType rosbridge.PointType, pts []rosbridge.NavigationPoint) error
+	DeletePoint(rb *robot.Robot, pointType rosbridge.PointType, name string) error
+	ClearPoints(rb *robot.Robot, pointType rosbridge.PointType) error
+	SendPointsWithTemplate(rb *robot.Robot, pointType rosbridge.PointType, btTemplate string) (*rosbridge.NavAck, error)
+	RequestPoints(rb *robot.Robot, pointType rosbridge.PointType) error
+	GoAll(rb *robot.Robot, pointType rosbridge.PointType, force bool) error
+	StartPatrol(rb *robot.Robot, q robot.PatrolRequest) (robot.PatrolStatus, error)
+	StopPatrol(rb *robot.Robot) (stopped bool, err error)
+	PreviewBT(rb *robot.Robot, pointType rosbridge.PointType, template string, maxBytes int) (*rosbridge.BTPreview, error)
+}
+
+// navPages renders the navigation dialogs and panel, which HTMX requests
+// get instead of JSON; *Server is one.
+type navPages interface {
+	renderer
+	NavPointsPartial(w http.ResponseWriter, r *http.Request)
+}
+
+// NavHandlers serves the current robot's navigation points, patrols,
+// visits and behavior tree previews (see Routes). Points is nil when
+// points aren't persisted.
+type NavHandlers struct {
+	Robots  navRobots
+	Nav     navigator
+	Confirm confirmer
+	Pages   navPages
+	Points  *robot.PointStore
+	Config  *config.Config
+}
+
+func (h *NavHandlers) lookupRobot(w http.ResponseWriter, id string) *robot.Robot {
+	return findRobot(w, h.Robots, id)
+}
+
 // ──────────────────── Navigation Point API ────────────────────
 
 // AddNavigationPoint handles POST /api/nav/add
-func (s *Server) AddNavigationPoint(w http.ResponseWriter, r *http.Request) {
-	pointType := r.FormValue("type") // waypoint, service_point, patrol_point, path_point, wall
-	name := r.FormValue("name")
-	xStr := r.FormValue("world_x")
-	yStr := r.FormValue("world_y")
-	thetaStr := r.FormValue("theta")
+func (h *NavHandlers) AddNavigationPoint(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	pointType := p.pointType("type", true, "")
+	name := p.requiredStr("name")
+	x := p.requiredFloat("world_x", math.Inf(-1), math.Inf(1))
+	y := p.requiredFloat("world_y", math.Inf(-1), math.Inf(1))
+	var x2, y2 float64
+	pt := rosbridge.NavigationPoint{Name: name, WorldXM: x, WorldYM: y}
+	if pointType == rosbridge.PointWall {
+		x2 = p.float("world_x2", 0, math.Inf(-1), math.Inf(1))
+		y2 = p.float("world_y2", 0, math.Inf(-1), math.Inf(1))
+	} else {
+		pt.WorldThetaRad = p.float("theta", 0, math.Inf(-1), math.Inf(1))
+		parseApproach(p, &pt)
+	}
+	if p.invalid(w) {
+		return
+	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no active robot", http.StatusBadRequest)
 		return
 	}
 
-	x, _ := strconv.ParseFloat(xStr, 64)
-	y, _ := strconv.ParseFloat(yStr, 64)
-	theta, _ := strconv.ParseFloat(thetaStr, 64)
-
 	var err error
-	switch pointType {
-	case "waypoint":
-		err = s.NavManager.AddWaypoint(rb, name, x, y, theta)
-	case "service_point":
-		err = s.NavManager.AddServicePoint(rb, name, x, y, theta)
-	case "patrol_point":
-		err = s.NavManager.AddPatrolPoint(rb, name, x, y, theta)
-	case "path_point":
-		err = s.NavManager.AddPathPoint(rb, name, x, y, theta)
-	case "wall":
-		x2, _ := strconv.ParseFloat(r.FormValue("world_x2"), 64)
-		y2, _ := strconv.ParseFloat(r.FormValue("world_y2"), 64)
-		err = s.NavManager.AddWallObstacle(rb, name, x, y, x2, y2)
-	default:
-		jsonError(w, "invalid point type", http.StatusBadRequest)
-		return
+	if pointType == rosbridge.PointWall {
+		err = h.Nav.AddWallObstacle(rb, name, x, y, x2, y2)
+	} else {
+		err = h.Nav.AddPoint(rb, pointType, pt)
 	}
 
 	if err != nil {
@@ -53,18 +108,78 @@ func (s *Server) AddNavigationPoint(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		s.NavPointsPartial(w, r)
+		h.Pages.NavPointsPartial(w, r)
 		return
 	}
 
 	jsonOK(w, map[string]string{"status": "added"})
 }
 
+// AddNavigationPointHere handles POST /api/nav/add_here
+//
+// Teaches a point at the robot's current map pose ("teach by driving"):
+// only type and name are given, x/y/theta come from map_bfp if fresh,
+// else from TF. Refuses when both are older than NAV_POSE_MAX_AGE_MS.
+func (h *NavHandlers) AddNavigationPointHere(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p := formParams(r)
+	pointType := p.pointType("type", false, "walls need two ends")
+	name := p.requiredStr("name")
+	var pt rosbridge.NavigationPoint
+	parseApproach(p, &pt)
+	if p.invalid(w) {
+		return
+	}
+
+	rb := h.Robots.GetCurrentRobot()
+	if rb == nil {
+		jsonError(w, "no active robot", http.StatusBadRequest)
+		return
+	}
+
+	maxAge := 3 * time.Second
+	if h.Config != nil && h.Config.NavPoseMaxAge > 0 {
+		maxAge = h.Config.NavPoseMaxAge
+	}
+	pose, source, err := rb.CurrentMapPose(maxAge)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	pt.Name, pt.WorldXM, pt.WorldYM, pt.WorldThetaRad = name, pose.X, pose.Y, pose.Theta
+	if err := h.Nav.AddPoint(rb, pointType, pt); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"status": "added",
+		"type":   pointType,
+		"name":   name,
+		"x":      pose.X,
+		"y":      pose.Y,
+		"theta":  pose.Theta,
+		"source": source,
+	})
+}
+
 // ListNavigationPoints handles GET /api/nav/list?type=X
-func (s *Server) ListNavigationPoints(w http.ResponseWriter, r *http.Request) {
-	pointType := r.URL.Query().Get("type")
+func (h *NavHandlers) ListNavigationPoints(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	var pointType rosbridge.PointType
+	if p.str("type") != "" {
+		pointType = p.pointType("type", true, "")
+	}
+	if p.invalid(w) {
+		return
+	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonOK(w, []interface{}{})
 		return
@@ -72,92 +187,168 @@ func (s *Server) ListNavigationPoints(w http.ResponseWriter, r *http.Request) {
 
 	snap := rb.GetSnapshot()
 
+	// ?units=imperial adds converted fields next to the SI ones; points
+	// carry last_visited once reached.
+	visits := rb.LastVisits()
+	list := func(t rosbridge.PointType, pts []rosbridge.NavigationPoint) interface{} {
+		return visitedPoints(pts, visits, t)
+	}
+	walls := func(ws []rosbridge.WallObstacle) interface{} { return ws }
+	if jsonImperial(r) {
+		list = func(t rosbridge.PointType, pts []rosbridge.NavigationPoint) interface{} {
+			out := imperialPoints(pts)
+			for i := range out {
+				out[i].LastVisited = lastVisited(visits, t, out[i].Name)
+			}
+			return out
+		}
+		walls = func(ws []rosbridge.WallObstacle) interface{} { return imperialWalls(ws) }
+	}
+
 	var points interface{}
 	switch pointType {
-	case "waypoint":
-		points = snap.Waypoints
-	case "service_point":
-		points = snap.ServicePoints
-	case "patrol_point":
-		points = snap.PatrolPoints
-	case "path_point":
-		points = snap.PathPoints
-	case "wall":
-		points = snap.WallObstacles
+	case rosbridge.PointWaypoint:
+		points = list(pointType, snap.Waypoints)
+	case rosbridge.PointService:
+		points = list(pointType, snap.ServicePoints)
+	case rosbridge.PointPatrol:
+		points = list(pointType, snap.PatrolPoints)
+	case rosbridge.PointPath:
+		points = list(pointType, snap.PathPoints)
+	case rosbridge.PointWall:
+		points = walls(snap.WallObstacles)
 	default:
 		points = map[string]interface{}{
-			"waypoints":      snap.Waypoints,
-			"service_points": snap.ServicePoints,
-			"patrol_points":  snap.PatrolPoints,
-			"path_points":    snap.PathPoints,
-			"wall_obstacles": snap.WallObstacles,
+			"waypoints":      list(rosbridge.PointWaypoint, snap.Waypoints),
+			"service_points": list(rosbridge.PointService, snap.ServicePoints),
+			"patrol_points":  list(rosbridge.PointPatrol, snap.PatrolPoints),
+			"path_points":    list(rosbridge.PointPath, snap.PathPoints),
+			"wall_obstacles": walls(snap.WallObstacles),
+			"sync":           rb.NavSyncAll(),
+		}
+	}
+
+	// A single collection stays a bare array; its sync state goes in
+	// headers.
+	if pointType != "" {
+		if st, err := rb.NavSync(pointType); err == nil {
+			w.Header().Set("X-Nav-Synced", strconv.FormatBool(st.Synced))
+			if st.LastSyncedAt != nil {
+				w.Header().Set("X-Nav-Last-Synced-At", st.LastSyncedAt.UTC().Format(time.RFC3339))
+			}
 		}
 	}
 
 	jsonOK(w, points)
 }
 
-// SendNavigationPoints handles POST /api/nav/send?type=X
-func (s *Server) SendNavigationPoints(w http.ResponseWriter, r *http.Request) {
-	pointType := r.FormValue("type")
+// SendNavigationPoints handles POST /api/nav/send?type=X[&bt_template=T]
+//
+// bt_template picks the behavior tree template the robot builds from
+// the points; without it the robot uses its default.
+//
+// Answers with the robot's acknowledgment: status "sent" when it kept
+// every point, "partial" (HTTP 207) when it refused some, and
+// "unverified" when its response doesn't say. The outcome is broadcast
+// as "nav_send". A disconnected robot with its offline queue on gets the
+// upload queued (status "queued", HTTP 202) and sent on reconnect.
+func (h *NavHandlers) SendNavigationPoints(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	pointType := p.pointType("type", true, "")
+	if p.invalid(w) {
+		return
+	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil || rb.Client == nil {
 		jsonError(w, "no active robot", http.StatusBadRequest)
 		return
 	}
-
-	var err error
-	switch pointType {
-	case "waypoint":
-		err = s.NavManager.SendWaypointsToRobot(rb)
-	case "service_point":
-		err = s.NavManager.SendServicePointsToRobot(rb)
-	case "patrol_point":
-		err = s.NavManager.SendPatrolPointsToRobot(rb)
-	case "path_point":
-		err = s.NavManager.SendPathPointsToRobot(rb)
-	case "wall":
-		err = s.NavManager.SendWallObstaclesToRobot(rb)
-	default:
-		jsonError(w, "invalid point type", http.StatusBadRequest)
+	btTemplate := p.btTemplate("bt_template", rb, pointType)
+	if p.invalid(w) {
 		return
 	}
 
+	ack, err := h.Nav.SendPointsWithTemplate(rb, pointType, btTemplate)
+	if cmd, ok := queueOffline(w, rb, err, robot.PendingNavSend, string(pointType), string(pointType)+" upload",
+		func() error { return h.sendQueuedPoints(rb, pointType, btTemplate) }); ok {
+		if cmd != nil {
+			jsonAccepted(w, navSendResponse{Status: "queued", Type: pointType, Pending: cmd})
+		}
+		return
+	}
+	if unsupported(w, err) {
+		return
+	}
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonOK(w, map[string]string{"status": "sent"})
+	resp := h.navSendDone(rb, pointType, ack)
+	if ack.Partial() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	jsonOK(w, resp)
 }
 
-// GoAllPoints handles POST /api/nav/go?type=X
-func (s *Server) GoAllPoints(w http.ResponseWriter, r *http.Request) {
-	pointType := r.FormValue("type")
+// navSendDone broadcasts an upload's outcome as "nav_send".
+func (h *NavHandlers) navSendDone(rb *robot.Robot, pointType rosbridge.PointType, ack *rosbridge.NavAck) navSendResponse {
+	resp := navSendResponse{Status: "sent", Type: pointType, NavAck: *ack}
+	switch {
+	case ack.Partial():
+		resp.Status = "partial"
+	case !ack.Verified:
+		resp.Status = "unverified"
+	}
+	h.Robots.Broadcast(robot.BroadcastMsg{Type: "nav_send", RobotID: rb.ID, Data: resp})
+	return resp
+}
 
-	rb := s.Manager.GetCurrentRobot()
-	if rb == nil || rb.Client == nil {
-		jsonError(w, "no active robot", http.StatusBadRequest)
+// sendQueuedPoints is a queued upload: the collection as it is when the
+// robot is back, not as it was when queued.
+func (h *NavHandlers) sendQueuedPoints(rb *robot.Robot, pointType rosbridge.PointType, btTemplate string) error {
+	ack, err := h.Nav.SendPointsWithTemplate(rb, pointType, btTemplate)
+	if err != nil {
+		return err
+	}
+	h.navSendDone(rb, pointType, ack)
+	if ack.Partial() {
+		return fmt.Errorf("robot refused %d of %d points", len(ack.Rejected), ack.Sent)
+	}
+	return nil
+}
+
+// GoAllPoints handles POST /api/nav/go?type=X[&force=true]
+//
+// Refused with 409 when the collection is empty, and (unless force) when
+// it has unsynced changes, or the robot has no fresh map pose or is
+// further than the configured distance from the first point.
+func (h *NavHandlers) GoAllPoints(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	pointType := p.pointType("type", false, "walls can't be navigated")
+	force := p.enum("force", "false", "true", "false", "1", "0")
+	if p.invalid(w) {
 		return
 	}
 
-	var err error
-	switch pointType {
-	case "waypoint":
-		err = s.NavManager.GoAllWaypoints(rb)
-	case "service_point":
-		err = s.NavManager.GoAllServicePoints(rb)
-	case "patrol_point":
-		err = s.NavManager.GoAllPatrolPoints(rb)
-	case "path_point":
-		err = s.NavManager.GoAllPathPoints(rb)
-	default:
-		jsonError(w, "invalid point type", http.StatusBadRequest)
+	rb := h.Robots.GetCurrentRobot()
+	if rb == nil || rb.Client == nil {
+		jsonError(w, "no active robot", http.StatusBadRequest)
 		return
 	}
 
-	if err != nil {
+	err := h.Nav.GoAll(rb, pointType, force == "true" || force == "1")
+	switch {
+	case errors.Is(err, robot.ErrNoPoints):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case goAllRefused(w, err), unsupported(w, err):
+		return
+	case err != nil:
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -165,34 +356,63 @@ func (s *Server) GoAllPoints(w http.ResponseWriter, r *http.Request) {
 	jsonOK(w, map[string]string{"status": "go_all_sent"})
 }
 
-// ClearNavigationPoints handles POST /api/nav/clear?type=X
-func (s *Server) ClearNavigationPoints(w http.ResponseWriter, r *http.Request) {
-	pointType := r.FormValue("type")
+// goAllRefused answers 409 with the refusal details if err is a go-all
+// proximity or unsynced-changes refusal, so the UI can offer to retry
+// with force.
+func goAllRefused(w http.ResponseWriter, err error) bool {
+	var refused *robot.GoAllRefusedError
+	if errors.Is(err, robot.ErrUnsynced) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "code": "unsynced_changes", "forceable": true})
+		return true
+	}
+	if !errors.As(err, &refused) {
+		return false
+	}
+	resp := map[string]interface{}{"error": err.Error(), "code": errorCode(http.StatusConflict), "forceable": true, "point": refused.Point}
+	if refused.Err == nil {
+		resp["distance_m"] = refused.DistanceM
+		resp["max_distance_m"] = refused.MaxM
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(resp)
+	return true
+}
+
+// ClearNavigationPoints handles POST /api/nav/clear?type=X[&token=T]
+//
+// Confirmed in two steps, as PowerOff; the token is for the type.
+func (h *NavHandlers) ClearNavigationPoints(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	pointType := p.pointType("type", true, "")
+	if p.invalid(w) {
+		return
+	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no active robot", http.StatusBadRequest)
 		return
 	}
 
-	switch pointType {
-	case "waypoint":
-		s.NavManager.ClearWaypoints(rb)
-	case "service_point":
-		s.NavManager.ClearServicePoints(rb)
-	case "patrol_point":
-		s.NavManager.ClearPatrolPoints(rb)
-	case "path_point":
-		s.NavManager.ClearPathPoints(rb)
-	case "wall":
-		_ = s.NavManager.ClearWallObstacles(rb)
-	default:
-		jsonError(w, "invalid point type", http.StatusBadRequest)
+	if !destructiveConfirmed(w, r, h.Confirm, h.Pages, rb, robot.ActionClearPoints, string(pointType), confirmView{
+		Title: "Clear", Message: "Clear all " + string(pointType) + "s of " + rb.Name + "?", Action: "/api/nav/clear",
+		SwapTarget: "#nav-points-content",
+	}, map[string]string{"type": string(pointType)}) {
+		return
+	}
+
+	// Only notifying the robot of cleared walls can fail; the local
+	// collection is cleared regardless
+	if err := h.Nav.ClearPoints(rb, pointType); err != nil && pointType != rosbridge.PointWall {
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		s.NavPointsPartial(w, r)
+		h.Pages.NavPointsPartial(w, r)
 		return
 	}
 
@@ -200,40 +420,172 @@ func (s *Server) ClearNavigationPoints(w http.ResponseWriter, r *http.Request) {
 }
 
 // RequestNavPointsFromRobot handles POST /api/nav/fetch?type=X
-func (s *Server) RequestNavPointsFromRobot(w http.ResponseWriter, r *http.Request) {
-	pointType := r.FormValue("type")
+func (h *NavHandlers) RequestNavPointsFromRobot(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	pointType := p.pointType("type", false, "walls can't be fetched from the robot")
+	if p.invalid(w) {
+		return
+	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil || rb.Client == nil {
 		jsonError(w, "no active robot", http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	switch pointType {
-	case "waypoint":
-		err = s.NavManager.RequestWaypoints(rb)
-	case "service_point":
-		err = s.NavManager.RequestServicePoints(rb)
-	case "patrol_point":
-		err = s.NavManager.RequestPatrolPoints(rb)
-	case "path_point":
-		err = s.NavManager.RequestPathPoints(rb)
-	default:
-		jsonError(w, "invalid point type", http.StatusBadRequest)
+	err := h.Nav.RequestPoints(rb, pointType)
+	switch {
+	case unsupported(w, err):
+		return
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	jsonOK(w, map[string]string{"status": "fetching"})
+}
 
+// ImportNavPoints handles POST /api/nav/import[?format=json|csv|yaml&type=X]
+//
+// JSON uploads ({type, points, walls}) replace the collection as before.
+// CSV (name,x,y,theta[,type]) and robot-native YAML are validated like
+// /api/nav/add_bulk and appended; the response summarizes imported and
+// skipped rows. Without a format parameter the body is sniffed.
+func (h *NavHandlers) ImportNavPoints(w http.ResponseWriter, r *http.Request) {
+	rb := h.Robots.GetCurrentRobot()
+	if rb == nil {
+		jsonError(w, "no active robot", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxImportBytes+1))
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		jsonError(w, "read body failed: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	jsonOK(w, map[string]string{"status": "fetching"})
+	if len(body) > maxImportBytes {
+		jsonError(w, "import too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	format := importer.Sniff(body, r.Header.Get("Content-Type"))
+	if v := r.URL.Query().Get("format"); v != "" {
+		if format, err = importer.ParseFormat(v); err != nil {
+			jsonFieldErrors(w, fieldErrors{"format": err.Error()})
+			return
+		}
+	}
+
+	if format == importer.FormatJSON {
+		var payload struct {
+			Type   string                      `json:"type"`
+			Points []rosbridge.NavigationPoint `json:"points"`
+			Walls  []rosbridge.WallObstacle    `json:"walls,omitempty"`
+		}
+		if err := json.Unmarshal(importer.Normalize(body), &payload); err != nil {
+			jsonError(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		pointType, err := rosbridge.ParsePointType(payload.Type)
+		if err != nil {
+			jsonFieldErrors(w, fieldErrors{"type": err.Error()})
+			return
+		}
+		for _, p := range payload.Points {
+			if err := h.Nav.ValidateApproach(rb, p); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := h.Nav.CheckReplace(rb, pointType, payload.Points); err != nil {
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err := rb.ImportPoints(pointType, payload.Points, payload.Walls); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonOK(w, map[string]string{"status": "imported"})
+		return
+	}
+
+	var rows []importer.Row
+	var skipped []importer.Skipped
+	if format == importer.FormatCSV {
+		rows, skipped, err = importer.ParseCSV(body)
+	} else {
+		rows, skipped, err = importer.ParseYAML(body)
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Group rows by type; rows without one use the type parameter.
+	defaultType := r.URL.Query().Get("type")
+	byType := map[rosbridge.PointType][]rosbridge.NavigationPoint{}
+	lines := map[rosbridge.PointType][]int{}
+	var order []rosbridge.PointType
+	for _, row := range rows {
+		name := row.Type
+		if name == "" {
+			name = defaultType
+		}
+		pt, err := rosbridge.ParsePointType(name)
+		if err == nil && !pt.Navigable() {
+			err = fmt.Errorf("%w %q: walls can't be imported as rows", rosbridge.ErrInvalidPointType, name)
+		}
+		if err != nil {
+			skipped = append(skipped, importer.Skipped{Line: row.Line, Name: row.Name, Reason: err.Error()})
+			continue
+		}
+		if _, ok := byType[pt]; !ok {
+			order = append(order, pt)
+		}
+		byType[pt] = append(byType[pt], rosbridge.NavigationPoint{
+			Name:            row.Name,
+			WorldXM:         row.X,
+			WorldYM:         row.Y,
+			WorldThetaRad:   row.Theta,
+			MaxSpeedMPS:     row.MaxSpeedMPS,
+			DwellSec:        row.DwellSec,
+			YawToleranceRad: row.YawToleranceRad,
+			OnArrivalTask:   row.OnArrivalTask,
+		})
+		lines[pt] = append(lines[pt], row.Line)
+	}
+
+	imported := 0
+	for _, pt := range order {
+		n, errs := h.Nav.AddPoints(rb, pt, byType[pt])
+		imported += n
+		for _, e := range errs {
+			sk := importer.Skipped{Name: e.Name, Reason: e.Reason}
+			if e.Index >= 0 {
+				sk.Line = lines[pt][e.Index]
+			}
+			skipped = append(skipped, sk)
+		}
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"status":   "imported",
+		"format":   format,
+		"imported": imported,
+		"skipped":  skipped,
+	})
 }
 
-// ImportNavPoints handles POST /api/nav/import (JSON upload)
-func (s *Server) ImportNavPoints(w http.ResponseWriter, r *http.Request) {
-	rb := s.Manager.GetCurrentRobot()
+// AddNavigationPointsBulk handles POST /api/nav/add_bulk
+//
+// Body: {"type": "waypoint", "points": [{"name", "world_x_m", ...}]}.
+// Invalid or duplicate points are skipped and reported.
+func (h *NavHandlers) AddNavigationPointsBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no active robot", http.StatusBadRequest)
 		return
@@ -242,23 +594,81 @@ func (s *Server) ImportNavPoints(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
 		Type   string                      `json:"type"`
 		Points []rosbridge.NavigationPoint `json:"points"`
-		Walls  []rosbridge.WallObstacle    `json:"walls,omitempty"`
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		jsonError(w, "invalid JSON", http.StatusBadRequest)
 		return
 	}
+	p := &params{get: func(string) string { return payload.Type }, errs: fieldErrors{}}
+	pointType := p.pointType("type", false, "add walls through /api/nav/add")
+	if p.invalid(w) {
+		return
+	}
 
-	rb.ImportPoints(payload.Type, payload.Points, payload.Walls)
+	added, skipped := h.Nav.AddPoints(rb, pointType, payload.Points)
+	jsonOK(w, map[string]interface{}{
+		"status":  "added",
+		"added":   added,
+		"skipped": skipped,
+	})
+}
 
-	jsonOK(w, map[string]string{"status": "imported"})
+// UndoNavEdit handles POST /api/nav/undo
+//
+// Reverts the current robot's most recent point edit (add, delete,
+// clear, import), restoring the collections exactly as they were; 409
+// when there is nothing to undo.
+func (h *NavHandlers) UndoNavEdit(w http.ResponseWriter, r *http.Request) {
+	h.navUndo(w, r, "undone", (*robot.Robot).UndoNavEdit)
 }
 
+// RedoNavEdit handles POST /api/nav/redo
+//
+// Reapplies the most recently undone point edit; 409 when there is none
+// (a new edit since the undo drops it).
+func (h *NavHandlers) RedoNavEdit(w http.ResponseWriter, r *http.Request) {
+	h.navUndo(w, r, "redone", (*robot.Robot).RedoNavEdit)
+}
+
+func (h *NavHandlers) navUndo(w http.ResponseWriter, r *http.Request, status string, apply func(*robot.Robot) (string, robot.NavUndoState, error)) {
+	rb := h.Robots.GetCurrentRobot()
+	if rb == nil {
+		jsonError(w, "no active robot", http.StatusBadRequest)
+		return
+	}
+
+	edit, st, err := apply(rb)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		h.Pages.NavPointsPartial(w, r)
+		return
+	}
+
+	jsonOK(w, navUndoResponse{Status: status, Edit: edit, NavUndoState: st})
+}
+
+// parseApproach reads the optional approach parameters (max_speed_mps,
+// dwell_sec, yaw_tolerance_rad, on_arrival_task) into pt. Limits that
+// depend on the robot are checked in the navigation manager.
+func parseApproach(p *params, pt *rosbridge.NavigationPoint) {
+	pt.MaxSpeedMPS = p.float("max_speed_mps", 0, 0, math.Inf(1))
+	pt.DwellSec = p.float("dwell_sec", 0, 0, math.Inf(1))
+	pt.YawToleranceRad = p.float("yaw_tolerance_rad", 0, 0, math.Pi)
+	pt.OnArrivalTask = p.str("on_arrival_task")
+}
+
+// maxImportBytes caps navigation point import uploads.
+const maxImportBytes = 5 << 20
+
 // NavPointsPartial renders the navigation points panel for HTMX.
 func (s *Server) NavPointsPartial(w http.ResponseWriter, r *http.Request) {
 	rb := s.Manager.GetCurrentRobot()
-	data := map[string]interface{}{}
+	u := displayUnits(r)
+	data := map[string]interface{}{"Units": u}
 	if rb != nil {
 		wp, sp, pp, pathP, walls := s.NavManager.GetCounts(rb)
 		data["Counts"] = map[string]int{
@@ -269,41 +679,107 @@ func (s *Server) NavPointsPartial(w http.ResponseWriter, r *http.Request) {
 			"wall_obstacles": walls,
 		}
 		snap := rb.GetSnapshot()
-		data["Waypoints"] = snap.Waypoints
-		data["ServicePoints"] = snap.ServicePoints
-		data["PatrolPoints"] = snap.PatrolPoints
-		data["PathPoints"] = snap.PathPoints
+		data["CurrentMap"] = snap.CurrentMap
+		data["ActiveFloor"] = snap.ActiveFloor
+		data["Floors"] = rb.Floors()
+		data["BTTemplates"] = rb.BTTemplates()
+		visits := rb.LastVisits()
+		data["Waypoints"] = pointViews(snap.Waypoints, u, visits, rosbridge.PointWaypoint)
+		data["ServicePoints"] = pointViews(snap.ServicePoints, u, visits, rosbridge.PointService)
+		data["PatrolPoints"] = pointViews(snap.PatrolPoints, u, visits, rosbridge.PointPatrol)
+		data["PathPoints"] = pointViews(snap.PathPoints, u, visits, rosbridge.PointPath)
 		data["WallObstacles"] = snap.WallObstacles
+		sync := map[string]robot.NavSyncStatus{}
+		for t, st := range rb.NavSyncAll() {
+			sync[string(t)] = st
+		}
+		data["Sync"] = sync
+		data["Undo"] = rb.NavUndoState()
 	}
-	s.render(w, "nav_points.html", data)
+	s.render(w, r, "nav_points.html", data)
 }
 
 // AddNavPointDialog renders the add-nav-point dialog for HTMX.
 func (s *Server) AddNavPointDialog(w http.ResponseWriter, r *http.Request) {
-	pointType := r.URL.Query().Get("type")
-	if pointType == "" {
-		pointType = "waypoint"
+	pointType := rosbridge.PointWaypoint
+	if v := r.URL.Query().Get("type"); v != "" {
+		t, err := rosbridge.ParsePointType(v)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pointType = t
+	}
+	var tasks []rosbridge.TaskInfo
+	if rb := s.Manager.GetCurrentRobot(); rb != nil {
+		tasks = rb.GetTaskCatalog().Tasks
+	}
+	s.render(w, r, "add_nav_point.html", map[string]interface{}{
+		"Type":  string(pointType),
+		"Tasks": tasks,
+	})
+}
+
+// NavConflicts handles GET /api/nav/conflicts
+//
+// Lists names used by more than one point type on the current robot;
+// these must be renamed before enforce_global_unique_names can be on.
+func (h *NavHandlers) NavConflicts(w http.ResponseWriter, r *http.Request) {
+	rb := h.Robots.GetCurrentRobot()
+	if rb == nil {
+		jsonError(w, "no active robot", http.StatusBadRequest)
+		return
 	}
-	s.render(w, "add_nav_point.html", map[string]interface{}{
-		"Type": pointType,
+	jsonOK(w, navConflictsResponse{
+		EnforceGlobalUniqueNames: rb.GetSettings().EnforceGlobalUniqueNames,
+		Conflicts:                rb.NameConflicts(),
+	})
+}
+
+// NavPersistenceStatus handles GET /api/nav/persistence_status
+//
+// Where each robot's points are saved, when they were last written and
+// the last write error, if any.
+func (h *NavHandlers) NavPersistenceStatus(w http.ResponseWriter, r *http.Request) {
+	store := h.Points
+	if store == nil {
+		jsonOK(w, persistenceStatusResponse{Robots: []robot.PersistStatus{}})
+		return
+	}
+	delay := store.Delay
+	if delay <= 0 {
+		delay = robot.DefaultPointSaveDelay
+	}
+	jsonOK(w, persistenceStatusResponse{
+		Enabled: true,
+		Dir:     store.Dir,
+		DelayMs: delay.Milliseconds(),
+		Robots:  h.Robots.PointPersistence(),
 	})
 }
 
 // DeleteNavPoint handles DELETE /api/nav/delete?type=X&name=Y
-func (s *Server) DeleteNavPoint(w http.ResponseWriter, r *http.Request) {
-	pointType := r.URL.Query().Get("type")
-	name := r.URL.Query().Get("name")
+func (h *NavHandlers) DeleteNavPoint(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	pointType := p.pointType("type", false, "walls have no names; clear them instead")
+	name := p.requiredStr("name")
+	if p.invalid(w) {
+		return
+	}
 
-	rb := s.Manager.GetCurrentRobot()
+	rb := h.Robots.GetCurrentRobot()
 	if rb == nil {
 		jsonError(w, "no active robot", http.StatusBadRequest)
 		return
 	}
 
-	s.NavManager.DeletePoint(rb, pointType, name)
+	if err := h.Nav.DeletePoint(rb, pointType, name); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		s.NavPointsPartial(w, r)
+		h.Pages.NavPointsPartial(w, r)
 		return
 	}
 
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rom_go_app/importer"
+	"rom_go_app/robot"
+)
+
+func TestImportNavPointsSkippedLines(t *testing.T) {
+	s := &Server{Manager: robot.NewManager(), NavManager: robot.NewNavigationManager()}
+	rb, err := s.Manager.AddRobot("", "test", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+	if err := s.Manager.SwitchRobot(rb.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Line 4 repeats a name and line 5 dwells too long: both are only
+	// rejected robot-side, after parsing.
+	body := "# exported\r\nname,x,y,theta,dwell_sec\r\na,1,2,0\r\na,3,4,0\r\nb,1,2,0,99999\r\nc,5,6,0\r\n"
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/nav/import?type=waypoint", strings.NewReader(body))
+	s.navHandlers().ImportNavPoints(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Format   string             `json:"format"`
+		Imported int                `json:"imported"`
+		Skipped  []importer.Skipped `json:"skipped"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Format != "csv" || resp.Imported != 2 {
+		t.Errorf("format %q imported %d, want csv 2", resp.Format, resp.Imported)
+	}
+	var lines []int
+	for _, sk := range resp.Skipped {
+		lines = append(lines, sk.Line)
+	}
+	if want := []int{4, 5}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("skipped lines = %v, want %v (%+v)", lines, want, resp.Skipped)
+	}
+}
+
+// TestUndoImport undoes and redoes an import through the API.
+func TestUndoImport(t *testing.T) {
+	s := &Server{Manager: robot.NewManager(), NavManager: robot.NewNavigationManager()}
+	rb, err := s.Manager.AddRobot("", "test", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+	s.Manager.SwitchRobot(rb.ID)
+	nav := s.navHandlers()
+
+	if rec := postForm(nav.UndoNavEdit, "/api/nav/undo", nil, false); rec.Code != http.StatusConflict {
+		t.Errorf("nothing to undo: %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/nav/import?type=waypoint", strings.NewReader("name,x,y,theta\na,1,2,0\nb,3,4,0\n"))
+	nav.ImportNavPoints(httptest.NewRecorder(), req)
+
+	var resp navUndoResponse
+	rec := postForm(nav.UndoNavEdit, "/api/nav/undo", nil, false)
+	decodeJSON(t, rec, &resp)
+	if rec.Code != http.StatusOK || resp.Status != "undone" || resp.Edit != "add 2 waypoints" || resp.CanUndo || !resp.CanRedo || len(rb.Waypoints) != 0 {
+		t.Errorf("undo: %d %+v, %d waypoints", rec.Code, resp, len(rb.Waypoints))
+	}
+	rec = postForm(nav.RedoNavEdit, "/api/nav/redo", nil, false)
+	decodeJSON(t, rec, &resp)
+	if resp.Status != "redone" || resp.CanRedo || len(rb.Waypoints) != 2 {
+		t.Errorf("redo: %+v, %d waypoints", resp, len(rb.Waypoints))
+	}
+	if rec := postForm(nav.RedoNavEdit, "/api/nav/redo", nil, false); rec.Code != http.StatusConflict {
+		t.Errorf("nothing to redo: %d", rec.Code)
+	}
+}
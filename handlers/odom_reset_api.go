@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────── Odometry reset ────────────────────
+
+// ResetOdometry handles POST /api/robots/reset_odom?id=X&confirm=1
+//
+// Resets the robot's odometry and localization after it was moved by
+// hand, by the method in its settings (odom_reset_method). x, y and
+// theta give where it now stands. Refused with 409 while disconnected or
+// while a navigation goal is active, unless force=1.
+func (h *RobotHandlers) ResetOdometry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+	if r.FormValue("confirm") != "1" {
+		jsonError(w, "resetting odometry discards the robot's pose: confirm=1 required", http.StatusBadRequest)
+		return
+	}
+
+	var pose *rosbridge.Pose2D
+	if r.FormValue("x") != "" || r.FormValue("y") != "" {
+		var p rosbridge.Pose2D
+		for name, dst := range map[string]*float64{"x": &p.X, "y": &p.Y, "theta": &p.Theta} {
+			f, err := strconv.ParseFloat(r.FormValue(name), 64)
+			if err != nil && (name != "theta" || r.FormValue(name) != "") {
+				jsonError(w, "invalid or missing "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = f
+		}
+		pose = &p
+	}
+	force := r.FormValue("force")
+
+	reset, err := rb.ResetOdometry(pose, force == "1" || force == "true")
+	switch {
+	case err == nil:
+	case errors.Is(err, robot.ErrNotConnected), errors.Is(err, robot.ErrNavActive):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, robot.ErrResetPoseRequired), errors.Is(err, robot.ErrInvalidPose):
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		jsonError(w, "odometry reset failed: "+err.Error(), taskErrorCode(err))
+		return
+	}
+	jsonOK(w, resetOdomResponse{Status: "reset", Reset: reset})
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"rom_go_app/version"
+)
+
+// ──────────────────── OpenAPI ────────────────────
+//
+// The document is generated from the route table: parameters from
+// Route.Params, bodies by reflecting over the Go types in Route.Body and
+// Route.Response. Named structs become components/schemas.
+
+// OpenAPI is an OpenAPI 3.0 document (the subset this app produces).
+type OpenAPI struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       OpenAPIInfo                     `json:"info"`
+	Tags       []OpenAPITag                    `json:"tags,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// OpenAPIInfo is the document's info object.
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPITag groups operations in viewers.
+type OpenAPITag struct {
+	Name string `json:"name"`
+}
+
+// Components holds the shared schemas.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Operation documents one method on a path.
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a query or path parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody lists the accepted request content types.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response documents one status code.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType binds a schema to a content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is an OpenAPI 3.0 schema object; the zero value means "any".
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// BuildSpec generates the OpenAPI document for routes.
+func BuildSpec(routes []Route) *OpenAPI {
+	g := &schemaGen{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+	errRef := g.schemaFor(reflect.TypeOf(errorResponse{}))
+
+	doc := &OpenAPI{
+		OpenAPI:    "3.0.3",
+		Info:       OpenAPIInfo{Title: "ROM Robotics web app API", Version: version.Version},
+		Paths:      map[string]map[string]Operation{},
+		Components: Components{Schemas: g.schemas},
+	}
+
+	tagSeen := map[string]bool{}
+	for _, rt := range routes {
+		if rt.Tag != "" && !tagSeen[rt.Tag] {
+			tagSeen[rt.Tag] = true
+			doc.Tags = append(doc.Tags, OpenAPITag{Name: rt.Tag})
+		}
+
+		path, pathParams := specPath(rt.Path)
+		op := Operation{
+			OperationID: operationID(rt.Method, path),
+			Summary:     rt.Summary,
+			Parameters:  pathParams,
+			Responses:   map[string]Response{},
+		}
+		if rt.Tag != "" {
+			op.Tags = []string{rt.Tag}
+		}
+		for _, p := range rt.Params {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        p.Name,
+				In:          "query",
+				Description: p.Description,
+				Required:    p.Required,
+				Schema:      &Schema{Type: p.Type},
+			})
+		}
+
+		if body := g.requestBody(rt); body != nil {
+			op.RequestBody = body
+		}
+
+		status := rt.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := Response{Description: http.StatusText(status)}
+		switch {
+		case rt.Response != nil:
+			ok.Content = map[string]MediaType{"application/json": {Schema: g.schemaFor(reflect.TypeOf(rt.Response))}}
+		case rt.Produces != "":
+			ok.Content = map[string]MediaType{rt.Produces: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		}
+		op.Responses[strconv.Itoa(status)] = ok
+		for _, code := range rt.Errors {
+			op.Responses[strconv.Itoa(code)] = Response{
+				Description: http.StatusText(code),
+				Content:     map[string]MediaType{"application/json": {Schema: errRef}},
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]Operation{}
+		}
+		doc.Paths[path][strings.ToLower(rt.Method)] = op
+	}
+	return doc
+}
+
+func (g *schemaGen) requestBody(rt Route) *RequestBody {
+	content := map[string]MediaType{}
+	if rt.Body != nil {
+		content["application/json"] = MediaType{Schema: g.schemaFor(reflect.TypeOf(rt.Body))}
+	}
+	for _, ct := range rt.RawBody {
+		content[ct] = MediaType{Schema: &Schema{Type: "string"}}
+	}
+	if rt.Upload != "" {
+		content["multipart/form-data"] = MediaType{Schema: &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{rt.Upload: {Type: "string", Format: "binary"}},
+			Required:   []string{rt.Upload},
+		}}
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	return &RequestBody{Required: true, Content: content}
+}
+
+// specPath turns a subtree pattern ("/static/") into a templated path
+// ("/static/{path}").
+func specPath(p string) (string, []Parameter) {
+	if p == "/" || !strings.HasSuffix(p, "/") {
+		return p, nil
+	}
+	return p + "{path}", []Parameter{{Name: "path", In: "path", Required: true, Schema: &Schema{Type: "string"}}}
+}
+
+// operationID derives e.g. "get_api_robots_status" from method and path.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		id += "_" + part
+	}
+	if path == "/" {
+		id += "_index"
+	}
+	return id
+}
+
+// ──────────────────── Schema generation ────────────────────
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type schemaGen struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// schemaFor returns the schema of t; named structs are added to the
+// components and referenced.
+func (g *schemaGen) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := &Schema{Type: "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			s.Format = "int64"
+		}
+		return s
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := g.name(t)
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = &Schema{} // placeholder for recursive types
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{} // interface{}: any value
+}
+
+// name picks a component name: the exported type name, qualified by
+// package when two packages use the same name.
+func (g *schemaGen) name(t reflect.Type) string {
+	if n, ok := g.names[t]; ok {
+		return n
+	}
+	base := t.Name()
+	n := strings.ToUpper(base[:1]) + base[1:]
+	for other := range g.names {
+		if g.names[other] == n {
+			pkg := t.PkgPath()
+			pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+			n = strings.ToUpper(pkg[:1]) + pkg[1:] + n
+			break
+		}
+	}
+	g.names[t] = n
+	return n
+}
+
+// structSchema follows encoding/json: exported fields, json tag names,
+// omitempty fields optional, embedded structs flattened.
+func (g *schemaGen) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				emb := g.structSchema(ft)
+				for k, v := range emb.Properties {
+					s.Properties[k] = v
+				}
+				s.Required = append(s.Required, emb.Required...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// ──────────────────── Handler ────────────────────
+
+// Spec handles GET /api/spec — the OpenAPI document of every route.
+func (s *Server) Spec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.specOnce.Do(func() {
+		s.specJSON, s.specErr = json.MarshalIndent(BuildSpec(s.Routes()), "", "  ")
+	})
+	if s.specErr != nil {
+		jsonError(w, s.specErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.specJSON)
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The checks below follow the OpenAPI 3.0 schema for the objects this
+// app emits; the document is validated as served, after JSON encoding.
+
+var (
+	specVersionRe  = regexp.MustCompile(`^3\.0\.\d+$`)
+	statusCodeRe   = regexp.MustCompile(`^[1-5](\d\d|XX)$|^default$`)
+	componentKeyRe = regexp.MustCompile(`^[a-zA-Z0-9.\-_]+$`)
+	templateRe     = regexp.MustCompile(`\{([^}]+)\}`)
+	specMethods    = map[string]bool{"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true, "trace": true}
+	schemaTypes    = map[string]bool{"": true, "object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true}
+	paramLocations = map[string]bool{"query": true, "header": true, "path": true, "cookie": true}
+)
+
+type specValidator struct {
+	doc  map[string]interface{}
+	errs []string
+}
+
+func (v *specValidator) errorf(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Sprintf(format, args...))
+}
+
+func obj(x interface{}) (map[string]interface{}, bool) {
+	m, ok := x.(map[string]interface{})
+	return m, ok
+}
+
+func (v *specValidator) validate() {
+	for _, key := range []string{"openapi", "info", "paths"} {
+		if _, ok := v.doc[key]; !ok {
+			v.errorf("missing required field %q", key)
+		}
+	}
+	for key := range v.doc {
+		switch key {
+		case "openapi", "info", "servers", "paths", "components", "security", "tags", "externalDocs":
+		default:
+			if !strings.HasPrefix(key, "x-") {
+				v.errorf("unknown top-level field %q", key)
+			}
+		}
+	}
+	if s, _ := v.doc["openapi"].(string); !specVersionRe.MatchString(s) {
+		v.errorf("openapi = %q, want 3.0.x", s)
+	}
+	info, _ := obj(v.doc["info"])
+	for _, key := range []string{"title", "version"} {
+		if s, _ := info[key].(string); s == "" {
+			v.errorf("info.%s is empty", key)
+		}
+	}
+
+	if comps, ok := obj(v.doc["components"]); ok {
+		schemas, _ := obj(comps["schemas"])
+		for name, s := range schemas {
+			if !componentKeyRe.MatchString(name) {
+				v.errorf("component name %q is not allowed", name)
+			}
+			v.schema("components.schemas."+name, s)
+		}
+	}
+
+	paths, _ := obj(v.doc["paths"])
+	opIDs := map[string]string{}
+	for p, item := range paths {
+		if !strings.HasPrefix(p, "/") {
+			v.errorf("path %q does not start with /", p)
+		}
+		ops, ok := obj(item)
+		if !ok {
+			v.errorf("path %q is not an object", p)
+			continue
+		}
+		for method, op := range ops {
+			where := method + " " + p
+			if !specMethods[method] {
+				v.errorf("%s: unknown method", where)
+				continue
+			}
+			o, _ := obj(op)
+			if id, _ := o["operationId"].(string); id != "" {
+				if other, dup := opIDs[id]; dup {
+					v.errorf("%s: operationId %q also used by %s", where, id, other)
+				}
+				opIDs[id] = where
+			}
+			v.operation(where, p, o)
+		}
+	}
+}
+
+func (v *specValidator) operation(where, path string, op map[string]interface{}) {
+	pathParams := map[string]bool{}
+	params, _ := op["parameters"].([]interface{})
+	seen := map[string]bool{}
+	for i, raw := range params {
+		p, _ := obj(raw)
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		if name == "" || !paramLocations[in] {
+			v.errorf("%s: parameter %d has name %q, in %q", where, i, name, in)
+		}
+		if seen[in+":"+name] {
+			v.errorf("%s: parameter %s in %s is listed twice", where, name, in)
+		}
+		seen[in+":"+name] = true
+		if in == "path" {
+			pathParams[name] = true
+			if req, _ := p["required"].(bool); !req {
+				v.errorf("%s: path parameter %s must be required", where, name)
+			}
+		}
+		if _, ok := p["schema"]; !ok {
+			v.errorf("%s: parameter %s has no schema", where, name)
+		}
+		v.schema(where+" parameter "+name, p["schema"])
+	}
+	for _, m := range templateRe.FindAllStringSubmatch(path, -1) {
+		if !pathParams[m[1]] {
+			v.errorf("%s: template {%s} has no path parameter", where, m[1])
+		}
+		delete(pathParams, m[1])
+	}
+	for name := range pathParams {
+		v.errorf("%s: path parameter %s is not in the path", where, name)
+	}
+
+	if body, ok := obj(op["requestBody"]); ok {
+		content, _ := obj(body["content"])
+		if len(content) == 0 {
+			v.errorf("%s: requestBody without content", where)
+		}
+		for ct, mt := range content {
+			m, _ := obj(mt)
+			v.schema(where+" request "+ct, m["schema"])
+		}
+	} else if _, present := op["requestBody"]; present {
+		v.errorf("%s: requestBody is not an object", where)
+	}
+
+	responses, _ := obj(op["responses"])
+	if len(responses) == 0 {
+		v.errorf("%s: no responses", where)
+	}
+	for code, raw := range responses {
+		if !statusCodeRe.MatchString(code) {
+			v.errorf("%s: response key %q is not a status code", where, code)
+		}
+		r, _ := obj(raw)
+		if _, ok := r["description"].(string); !ok {
+			v.errorf("%s: response %s has no description", where, code)
+		}
+		content, _ := obj(r["content"])
+		for ct, mt := range content {
+			m, _ := obj(mt)
+			v.schema(where+" response "+code+" "+ct, m["schema"])
+		}
+	}
+}
+
+func (v *specValidator) schema(where string, raw interface{}) {
+	s, ok := obj(raw)
+	if !ok {
+		v.errorf("%s: schema is not an object", where)
+		return
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		name, found := strings.CutPrefix(ref, "#/components/schemas/")
+		comps, _ := obj(v.doc["components"])
+		schemas, _ := obj(comps["schemas"])
+		if _, exists := schemas[name]; !found || !exists {
+			v.errorf("%s: $ref %q does not resolve", where, ref)
+		}
+		if len(s) != 1 {
+			v.errorf("%s: $ref has sibling fields", where)
+		}
+		return
+	}
+	typ, _ := s["type"].(string)
+	if !schemaTypes[typ] {
+		v.errorf("%s: type %q", where, typ)
+	}
+	if typ == "array" {
+		if _, ok := s["items"]; !ok {
+			v.errorf("%s: array without items", where)
+		}
+	}
+	if items, ok := s["items"]; ok {
+		v.schema(where+"[]", items)
+	}
+	props, _ := obj(s["properties"])
+	for name, p := range props {
+		v.schema(where+"."+name, p)
+	}
+	if req, ok := s["required"].([]interface{}); ok {
+		for _, r := range req {
+			if _, ok := props[r.(string)]; !ok {
+				v.errorf("%s: required %q is not a property", where, r)
+			}
+		}
+	}
+	if ap, ok := s["additionalProperties"]; ok {
+		v.schema(where+"{}", ap)
+	}
+}
+
+func fetchSpec(t *testing.T, s *Server) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Spec(rec, httptest.NewRequest(http.MethodGet, "/api/spec", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestSpecIsValidOpenAPI(t *testing.T) {
+	for _, noUI := range []bool{false, true} {
+		s := newTestServer(t)
+		s.NoUI = noUI
+		v := &specValidator{doc: fetchSpec(t, s)}
+		v.validate()
+		for _, e := range v.errs {
+			t.Errorf("noUI=%v: %s", noUI, e)
+		}
+	}
+}
+
+func TestSpecListsEveryRoute(t *testing.T) {
+	s := newTestServer(t)
+	routes := s.Routes()
+	if len(routes) == 0 {
+		t.Fatal("empty route table")
+	}
+	paths, _ := obj(fetchSpec(t, s)["paths"])
+
+	documented := 0
+	for _, item := range paths {
+		ops, _ := obj(item)
+		documented += len(ops)
+	}
+	if documented != len(routes) {
+		t.Errorf("spec documents %d operations, route table has %d", documented, len(routes))
+	}
+
+	for _, rt := range routes {
+		p, _ := specPath(rt.Path)
+		ops, ok := obj(paths[p])
+		if !ok {
+			t.Errorf("%s %s: path %q missing from spec", rt.Method, rt.Path, p)
+			continue
+		}
+		op, ok := obj(ops[strings.ToLower(rt.Method)])
+		if !ok {
+			t.Errorf("%s %s: method missing from spec", rt.Method, rt.Path)
+			continue
+		}
+		responses, _ := obj(op["responses"])
+		for _, code := range rt.Errors {
+			if _, ok := responses[fmt.Sprint(code)]; !ok {
+				t.Errorf("%s %s: error %d not documented", rt.Method, rt.Path, code)
+			}
+		}
+		for _, prm := range rt.Params {
+			found := false
+			params, _ := op["parameters"].([]interface{})
+			for _, raw := range params {
+				if m, _ := obj(raw); m["name"] == prm.Name {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s %s: parameter %s not documented", rt.Method, rt.Path, prm.Name)
+			}
+		}
+	}
+}
+
+func TestSpecRoutesAreServed(t *testing.T) {
+	s := newTestServer(t)
+	mux := http.NewServeMux()
+	Register(mux, s.Routes())
+	for _, rt := range s.Routes() {
+		req := httptest.NewRequest(rt.Method, rt.Path, nil)
+		if _, pattern := mux.Handler(req); pattern != rt.Path {
+			t.Errorf("%s %s is served by pattern %q", rt.Method, rt.Path, pattern)
+		}
+	}
+}
+
+func TestSpecValidatorRejectsBrokenDocuments(t *testing.T) {
+	tests := map[string]string{
+		"version":        `{"openapi":"2.0","info":{"title":"t","version":"1"},"paths":{}}`,
+		"info":           `{"openapi":"3.0.3","info":{"title":""},"paths":{}}`,
+		"dangling ref":   `{"openapi":"3.0.3","info":{"title":"t","version":"1"},"paths":{"/a":{"get":{"responses":{"200":{"description":"ok","content":{"application/json":{"schema":{"$ref":"#/components/schemas/Nope"}}}}}}}}}`,
+		"no responses":   `{"openapi":"3.0.3","info":{"title":"t","version":"1"},"paths":{"/a":{"get":{"responses":{}}}}}`,
+		"bad status":     `{"openapi":"3.0.3","info":{"title":"t","version":"1"},"paths":{"/a":{"get":{"responses":{"ok":{"description":"x"}}}}}}`,
+		"undeclared tpl": `{"openapi":"3.0.3","info":{"title":"t","version":"1"},"paths":{"/a/{id}":{"get":{"responses":{"200":{"description":"x"}}}}}}`,
+		"array no items": `{"openapi":"3.0.3","info":{"title":"t","version":"1"},"paths":{"/a":{"get":{"responses":{"200":{"description":"x","content":{"application/json":{"schema":{"type":"array"}}}}}}}}}`,
+	}
+	for name, raw := range tests {
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		v := &specValidator{doc: doc}
+		v.validate()
+		if len(v.errs) == 0 {
+			t.Errorf("%s: broken document passed validation", name)
+		}
+	}
+}
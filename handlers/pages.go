@@ -1,18 +1,47 @@
 package handlers
 
 import (
-	"html/template"
+	"io/fs"
 	"net/http"
+	"sync"
 
+	"rom_go_app/config"
+	"rom_go_app/discovery"
+	"rom_go_app/phrases"
 	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+	"rom_go_app/webhook"
 )
 
 // Server holds shared dependencies for all handlers.
 type Server struct {
+	Config     *config.Config
 	Manager    *robot.Manager
 	NavManager *robot.NavigationManager
-	Whisper    *WhisperRunner
-	Templates  *template.Template
+	Discovery  *discovery.Service
+	Webhooks   *webhook.Service
+	Phrases    *phrases.Store // nil: transcripts are sent as spoken
+	Templates  *Templates
+	Static     fs.FS
+	Assets     *StaticAssets
+	Branding   fs.FS         // BRANDING_DIR over Static, served under /branding/
+	Origins    *OriginPolicy // nil: no CORS, any WebSocket origin
+
+	// SettingsTemplates are shared by identical robots; nil answers
+	// /api/settings_templates with 503.
+	SettingsTemplates *robot.SettingsTemplates
+
+	// NoUI leaves the page, partial and dialog routes out of Routes;
+	// Templates and Assets may then be nil.
+	NoUI bool
+
+	// BrowserChaos injects faults into browser WebSocket writes; only
+	// settable with the DEBUG_CHAOS setting.
+	BrowserChaos rosbridge.ChaosSettings
+
+	specOnce sync.Once
+	specJSON []byte
+	specErr  error
 }
 
 // IndexPage renders the main application page.
@@ -26,14 +55,17 @@ func (s *Server) IndexPage(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
 		"Robots":    robots,
 		"CurrentID": s.Manager.GetCurrentRobotID(),
+		"Units":     displayUnits(r),
+		"WSScheme":  wsScheme(r),
+		"WSURL":     s.wsURL(r),
+		"BasePath":  s.basePath(),
+		"UI":        s.uiConfig(),
 	}
-	s.render(w, "layout.html", data)
+	s.render(w, r, "layout.html", data)
 }
 
-// render executes a template with common error handling.
-func (s *Server) render(w http.ResponseWriter, name string, data interface{}) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := s.Templates.ExecuteTemplate(w, name, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+// TemplateStatus handles GET /api/debug/templates — every template file
+// with the names it defines or its parse error.
+func (s *Server) TemplateStatus(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, templatesResponse{Files: s.Templates.Files(), Failed: s.Templates.Failed()})
 }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Patrol loops ────────────────────
+
+// PatrolStart handles POST /api/nav/patrol/start[?id=X]
+//
+// Optional body {laps, duration_minutes, force}; without limits the
+// patrol loops until stopped. Lap and finish events arrive as patrol WS
+// messages. Like /api/nav/go, the first lap is refused when the robot is
+// far from the first patrol point unless force is set.
+func (h *NavHandlers) PatrolStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	var req robot.PatrolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	st, err := h.Nav.StartPatrol(rb, req)
+	switch {
+	case errors.Is(err, robot.ErrEStopped), errors.Is(err, robot.ErrNotConnected), errors.Is(err, robot.ErrPatrolRunning),
+		errors.Is(err, robot.ErrNoPoints):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case goAllRefused(w, err), unsupported(w, err):
+		return
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonOK(w, st)
+}
+
+// PatrolStop handles POST /api/nav/patrol/stop[?id=X]
+//
+// Ends the patrol, if any, and cancels active navigation either way.
+func (h *NavHandlers) PatrolStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	stopped, err := h.Nav.StopPatrol(rb)
+	if err != nil && !errors.Is(err, robot.ErrNotConnected) {
+		jsonError(w, "cancel navigation: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, patrolStopResponse{
+		Stopped:             stopped,
+		NavigationCancelled: err == nil,
+		Patrol:              rb.GetPatrolStatus(),
+	})
+}
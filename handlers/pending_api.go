@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Offline command queue ────────────────────
+
+// PendingCommands handles GET and DELETE /api/robots/pending?id=X
+//
+// GET lists the commands queued while the robot was disconnected. DELETE
+// cancels the one given by entry, or all of them.
+func (h *RobotHandlers) PendingCommands(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		jsonOK(w, pendingResponse{Options: rb.OfflineQueue(), Pending: rb.PendingCommands()})
+	case http.MethodDelete:
+		entry := 0
+		if v := r.FormValue("entry"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				jsonError(w, "invalid entry "+strconv.Quote(v), http.StatusBadRequest)
+				return
+			}
+			entry = n
+		}
+		n := rb.CancelPending(entry)
+		if entry != 0 && n == 0 {
+			jsonError(w, "pending command not found", http.StatusNotFound)
+			return
+		}
+		jsonOK(w, cancelPendingResponse{Cancelled: n})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// FlushPending handles POST /api/robots/pending/flush?id=X
+//
+// Runs the queued commands now instead of waiting for the next connect
+// and answers with their outcomes; 409 while the robot is disconnected.
+func (h *RobotHandlers) FlushPending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	results, err := rb.ReplayPending()
+	if errors.Is(err, robot.ErrNotConnected) && len(results) == 0 {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if results == nil {
+		results = []robot.PendingCommand{}
+	}
+	jsonOK(w, flushPendingResponse{Results: results, Pending: rb.PendingCommands()})
+}
+
+// queueOffline queues run on rb's offline queue if err says the robot is
+// disconnected. It reports whether the command was queued; a full queue
+// is answered with 429 and also reported as handled.
+func queueOffline(w http.ResponseWriter, rb *robot.Robot, err error, kind, target, desc string, run func() error) (*robot.PendingCommand, bool) {
+	if !errors.Is(err, robot.ErrNotConnected) {
+		return nil, false
+	}
+	cmd, err := rb.QueueOffline(kind, target, desc, run)
+	switch {
+	case errors.Is(err, robot.ErrOfflineQueueOff):
+		return nil, false
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusTooManyRequests)
+		return nil, true
+	}
+	return &cmd, true
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// typeEndpoint calls one handler taking a point type.
+type typeEndpoint struct {
+	name  string
+	walls bool // walls are a valid type here
+	call  func(typ, name string) *httptest.ResponseRecorder
+}
+
+func typeEndpoints(s *Server) []typeEndpoint {
+	h := s.navHandlers()
+	post := func(handler http.HandlerFunc, path string, extra url.Values) func(typ, name string) *httptest.ResponseRecorder {
+		return func(typ, name string) *httptest.ResponseRecorder {
+			form := url.Values{"type": {typ}, "name": {name}}
+			for k, v := range extra {
+				form[k] = v
+			}
+			return postForm(handler, path, form, false)
+		}
+	}
+	get := func(handler http.HandlerFunc, path string) func(typ, name string) *httptest.ResponseRecorder {
+		return func(typ, name string) *httptest.ResponseRecorder {
+			return getReq(handler, path+"?"+url.Values{"type": {typ}, "name": {name}}.Encode())
+		}
+	}
+	body := func(handler http.HandlerFunc, path string) func(typ, name string) *httptest.ResponseRecorder {
+		return func(typ, name string) *httptest.ResponseRecorder {
+			data, _ := json.Marshal(map[string]interface{}{"type": typ, "points": []map[string]interface{}{{"name": name}}})
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(data)))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			return rec
+		}
+	}
+	coords := url.Values{"world_x": {"1"}, "world_y": {"2"}, "world_x2": {"3"}, "world_y2": {"4"}}
+
+	return []typeEndpoint{
+		{"add", true, post(h.AddNavigationPoint, "/api/nav/add", coords)},
+		{"add_here", false, post(h.AddNavigationPointHere, "/api/nav/add_here", nil)},
+		{"add_bulk", false, body(h.AddNavigationPointsBulk, "/api/nav/add_bulk")},
+		{"list", true, get(h.ListNavigationPoints, "/api/nav/list")},
+		{"send", true, post(h.SendNavigationPoints, "/api/nav/send", nil)},
+		{"bt_preview", false, get(h.BTPreview, "/api/nav/bt_preview")},
+		{"go", false, post(h.GoAllPoints, "/api/nav/go", nil)},
+		{"clear", true, post(h.ClearNavigationPoints, "/api/nav/clear", nil)},
+		{"fetch", false, post(h.RequestNavPointsFromRobot, "/api/nav/fetch", nil)},
+		{"import", true, body(h.ImportNavPoints, "/api/nav/import")},
+		{"visits", false, get(h.NavVisits, "/api/nav/visits")},
+		{"delete", false, func(typ, name string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.DeleteNavPoint(rec, httptest.NewRequest(http.MethodDelete, "/api/nav/delete?"+url.Values{"type": {typ}, "name": {name}}.Encode(), nil))
+			return rec
+		}},
+	}
+}
+
+// typeFieldError returns the error reported for the type parameter.
+func typeFieldError(rec *httptest.ResponseRecorder) string {
+	var resp errorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Fields["type"]
+}
+
+// TestPointTypeSpellings calls every endpoint taking a point type with
+// each legacy spelling, which must be accepted, and with invalid types,
+// which must be refused with 400 and a type field error.
+func TestPointTypeSpellings(t *testing.T) {
+	s, _ := newClearRobot(t)
+	navigable := []string{"waypoint", "waypoints", "Waypoints", "service_point", "servicepoints", "Service-Point",
+		"patrol_point", "patrolpoints", "path_point", "pathpoint", "PATH POINTS"}
+	walls := []string{"wall", "walls", "obstacles", "wall_obstacle"}
+
+	for i, ep := range typeEndpoints(s) {
+		for j, typ := range append(navigable, walls...) {
+			isWall := j >= len(navigable)
+			rec := ep.call(typ, "p"+string(rune('a'+i))+string(rune('a'+j)))
+			msg := typeFieldError(rec)
+			switch {
+			case isWall && !ep.walls:
+				if rec.Code != http.StatusBadRequest || msg == "" {
+					t.Errorf("%s with %q: %d %s, want 400 refusing walls", ep.name, typ, rec.Code, rec.Body.String())
+				}
+			case msg != "" || rec.Code == http.StatusBadRequest && strings.Contains(rec.Body.String(), "point type"):
+				t.Errorf("%s with %q: %d %s", ep.name, typ, rec.Code, rec.Body.String())
+			}
+		}
+
+		for _, typ := range []string{"", "point", "waypointss", "service__pointz"} {
+			rec := ep.call(typ, "x")
+			if typ == "" && (ep.name == "list" || ep.name == "visits" || ep.name == "import") {
+				continue // optional there: every type
+			}
+			if rec.Code != http.StatusBadRequest || typeFieldError(rec) == "" {
+				t.Errorf("%s with %q: %d %s, want 400 with a type error", ep.name, typ, rec.Code, rec.Body.String())
+			}
+		}
+	}
+
+	// The dialog takes the same spellings
+	for typ, code := range map[string]int{"servicepoints": http.StatusOK, "Patrol-Points": http.StatusOK, "bogus": http.StatusBadRequest} {
+		if rec := getReq(s.AddNavPointDialog, "/dialog/add_nav_point?type="+url.QueryEscape(typ)); rec.Code != code {
+			t.Errorf("dialog with %q: %d %s", typ, rec.Code, rec.Body.String())
+		}
+	}
+}
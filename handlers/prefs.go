@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rom_go_app/rosbridge"
+	"rom_go_app/units"
+)
+
+// ──────────────────── Display preferences ────────────────────
+//
+// Rendered partials follow the operator's unit system: the units query
+// parameter, else the "units" cookie the settings panel sets, else
+// metric. JSON APIs always carry SI values; ?units=imperial adds
+// converted fields next to them.
+
+const unitsCookie = "units"
+
+// displayUnits returns the unit system for rendering partials.
+func displayUnits(r *http.Request) units.System {
+	if v := r.URL.Query().Get("units"); v != "" {
+		return units.Parse(v)
+	}
+	if c, err := r.Cookie(unitsCookie); err == nil {
+		return units.Parse(c.Value)
+	}
+	return units.Metric
+}
+
+// jsonImperial reports whether a JSON response should add imperial
+// fields; only the query parameter counts, so API clients never get a
+// shape they didn't ask for.
+func jsonImperial(r *http.Request) bool {
+	return units.Parse(r.URL.Query().Get("units")) == units.Imperial
+}
+
+// pointView is a navigation point with the unit system it is rendered in.
+type pointView struct {
+	rosbridge.NavigationPoint
+	Units       units.System
+	LastVisited *time.Time
+}
+
+// pointViews returns the views of points of type t; visits is
+// Robot.LastVisits().
+func pointViews(pts []rosbridge.NavigationPoint, u units.System, visits map[rosbridge.PointType]map[string]time.Time, t rosbridge.PointType) []pointView {
+	out := make([]pointView, len(pts))
+	for i, p := range pts {
+		out[i] = pointView{p, u, lastVisited(visits, t, p.Name)}
+	}
+	return out
+}
+
+// imperialPoint is a navigation point with imperial fields added.
+type imperialPoint struct {
+	rosbridge.NavigationPoint
+	WorldXFt      float64    `json:"world_x_ft"`
+	WorldYFt      float64    `json:"world_y_ft"`
+	WorldThetaDeg float64    `json:"world_theta_deg"`
+	MaxSpeedMph   float64    `json:"max_speed_mph,omitempty"`
+	YawTolDeg     float64    `json:"yaw_tolerance_deg,omitempty"`
+	LastVisited   *time.Time `json:"last_visited,omitempty"`
+}
+
+func imperialPoints(pts []rosbridge.NavigationPoint) []imperialPoint {
+	out := make([]imperialPoint, len(pts))
+	for i, p := range pts {
+		out[i] = imperialPoint{
+			NavigationPoint: p,
+			WorldXFt:        units.MetersToFeet(p.WorldXM),
+			WorldYFt:        units.MetersToFeet(p.WorldYM),
+			WorldThetaDeg:   units.RadToDeg(p.WorldThetaRad),
+			MaxSpeedMph:     units.MpsToMph(p.MaxSpeedMPS),
+			YawTolDeg:       units.RadToDeg(p.YawToleranceRad),
+		}
+	}
+	return out
+}
+
+// imperialWall is a wall obstacle with imperial fields added.
+type imperialWall struct {
+	rosbridge.WallObstacle
+	WorldXFtStart float64 `json:"world_x_ft_start"`
+	WorldYFtStart float64 `json:"world_y_ft_start"`
+	WorldXFtEnd   float64 `json:"world_x_ft_end"`
+	WorldYFtEnd   float64 `json:"world_y_ft_end"`
+}
+
+func imperialWalls(walls []rosbridge.WallObstacle) []imperialWall {
+	out := make([]imperialWall, len(walls))
+	for i, w := range walls {
+		out[i] = imperialWall{
+			WallObstacle:  w,
+			WorldXFtStart: units.MetersToFeet(w.WorldXMStart),
+			WorldYFtStart: units.MetersToFeet(w.WorldYMStart),
+			WorldXFtEnd:   units.MetersToFeet(w.WorldXMEnd),
+			WorldYFtEnd:   units.MetersToFeet(w.WorldYMEnd),
+		}
+	}
+	return out
+}
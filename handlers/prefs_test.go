@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+	"rom_go_app/units"
+)
+
+func TestDisplayUnits(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		cookie string
+		want   units.System
+	}{
+		{"default", "", "", units.Metric},
+		{"cookie", "", "imperial", units.Imperial},
+		{"query", "units=imperial", "", units.Imperial},
+		{"query beats cookie", "units=metric", "imperial", units.Metric},
+		{"unknown is metric", "units=cubits", "", units.Metric},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/partial/status?"+tt.query, nil)
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: unitsCookie, Value: tt.cookie})
+		}
+		if got := displayUnits(r); got != tt.want {
+			t.Errorf("%s: displayUnits = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// JSON only converts on request
+	r := httptest.NewRequest(http.MethodGet, "/api/robots/status", nil)
+	r.AddCookie(&http.Cookie{Name: unitsCookie, Value: "imperial"})
+	if jsonImperial(r) {
+		t.Error("the cookie changed a JSON response")
+	}
+}
+
+// newUnitsRobot returns a server whose current robot has a radius,
+// velocity limits and an approach-tuned waypoint to render.
+func newUnitsRobot(t *testing.T) (*Server, *robot.Robot) {
+	t.Helper()
+	s := newTestServer(t)
+	s.NavManager = robot.NewNavigationManager()
+	rb, err := s.Manager.AddRobot("", "test", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rb.Close)
+	if err := s.Manager.SwitchRobot(rb.ID); err != nil {
+		t.Fatal(err)
+	}
+	rb.ApplyProfile(robot.Profile{
+		Settings: robot.ProfileSettings{LinearVelRatio: 0.5, AngularVelRatio: 0.5, Radius: 0.3, MaxLinearVel: 0.5, MaxAngularVel: 1},
+		Waypoints: []rosbridge.NavigationPoint{{
+			Name: "dock", WorldXM: 1.5, WorldYM: -2, MaxSpeedMPS: 0.5, YawToleranceRad: 0.1,
+		}},
+	})
+	return s, rb
+}
+
+func TestPartialsInBothUnitSystems(t *testing.T) {
+	s, _ := newUnitsRobot(t)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+		metric  []string
+		imp     []string
+	}{
+		{
+			name: "settings", handler: s.SettingsPartial, path: "/partial/settings",
+			metric: []string{"0.50 m/s · 1.00 rad/s", `value="metric" selected`},
+			imp:    []string{"1.12 mph · 57.3°/s", "≈ 11.8 in", `value="imperial" selected`},
+		},
+		{
+			name: "status", handler: s.StatusPartial, path: "/partial/status",
+			metric: []string{"0.00 m/s · 0.00 rad/s"},
+			imp:    []string{"0.00 mph · 0.0°/s"},
+		},
+		{
+			name: "nav points", handler: s.NavPointsPartial, path: "/partial/nav_points",
+			metric: []string{"(1.50 m, -2.00 m)", "≤0.50 m/s", "±0.10 rad"},
+			imp:    []string{"(4.92 ft, -6.56 ft)", "≤1.12 mph", "±5.7°"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			render := func(req *http.Request) string {
+				rec := httptest.NewRecorder()
+				tt.handler(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+				}
+				return rec.Body.String()
+			}
+			check := func(sys, html string, want, unwanted []string) {
+				for _, w := range want {
+					if !strings.Contains(html, w) {
+						t.Errorf("%s lacks %q", sys, w)
+					}
+				}
+				for _, w := range unwanted {
+					if strings.Contains(html, w) {
+						t.Errorf("%s shows %q", sys, w)
+					}
+				}
+			}
+
+			check("metric", render(httptest.NewRequest(http.MethodGet, tt.path, nil)), tt.metric, tt.imp)
+			check("imperial", render(httptest.NewRequest(http.MethodGet, tt.path+"?units=imperial", nil)), tt.imp, tt.metric)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.AddCookie(&http.Cookie{Name: unitsCookie, Value: "imperial"})
+			check("imperial cookie", render(req), tt.imp, tt.metric)
+		})
+	}
+}
+
+func TestStatusJSONKeepsSI(t *testing.T) {
+	s, rb := newUnitsRobot(t)
+
+	get := func(query string) map[string]json.RawMessage {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/robots/status?id="+rb.ID+query, nil)
+		req.AddCookie(&http.Cookie{Name: unitsCookie, Value: "imperial"})
+		s.robotHandlers().RobotStatus(rec, req)
+		var v map[string]json.RawMessage
+		decodeJSON(t, rec, &v)
+		return v
+	}
+
+	plain := get("")
+	if _, ok := plain["velocity_mph"]; ok {
+		t.Error("imperial fields without ?units=imperial")
+	}
+	if string(plain["units"]) != `"metric"` {
+		t.Errorf("units = %s", plain["units"])
+	}
+
+	imp := get("&units=imperial")
+	for _, k := range []string{"velocity_mph", "odom_x_ft", "odom_yaw_deg"} {
+		if _, ok := imp[k]; !ok {
+			t.Errorf("?units=imperial lacks %s", k)
+		}
+	}
+	// SI values stay alongside
+	for _, k := range []string{"velocity", "odom"} {
+		if string(imp[k]) != string(plain[k]) {
+			t.Errorf("%s changed with units: %s vs %s", k, imp[k], plain[k])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ──────────────────── Reverse proxy ────────────────────
+//
+// Behind a proxy the app may be mounted under a prefix (BASE_PATH, e.g.
+// /robots). Proxies differ in whether they strip it, so requests are
+// served with or without it; everything the app hands out — asset,
+// branding and WebSocket URLs, redirects — carries it. Absolute URLs are
+// built from X-Forwarded-Proto and X-Forwarded-Host when the proxy sets
+// them.
+
+// BasePath serves h under prefix as well as at the root: the prefix is
+// stripped from requests that have it, and the bare prefix redirects to
+// prefix + "/". An empty prefix returns h unchanged.
+func BasePath(prefix string, h http.Handler) http.Handler {
+	if prefix == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			target := prefix + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix+"/")
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + rest
+		r2.URL.RawPath = ""
+		if raw, ok := strings.CutPrefix(r.URL.RawPath, prefix+"/"); ok {
+			r2.URL.RawPath = "/" + raw
+		}
+		h.ServeHTTP(w, r2)
+	})
+}
+
+// forwarded returns the first value of a proxy header, which lists one
+// per hop.
+func forwarded(r *http.Request, header string) string {
+	v, _, _ := strings.Cut(r.Header.Get(header), ",")
+	return strings.TrimSpace(v)
+}
+
+// requestScheme is the scheme the client used: X-Forwarded-Proto behind
+// a proxy, else whether this connection is TLS.
+func requestScheme(r *http.Request) string {
+	if proto := strings.ToLower(forwarded(r, "X-Forwarded-Proto")); proto == "https" || proto == "http" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// requestHost is the host the client used: X-Forwarded-Host behind a
+// proxy, else the Host header.
+func requestHost(r *http.Request) string {
+	if host := forwarded(r, "X-Forwarded-Host"); host != "" {
+		return host
+	}
+	return r.Host
+}
+
+// basePath is the configured BASE_PATH.
+func (s *Server) basePath() string {
+	if s.Config == nil {
+		return ""
+	}
+	return s.Config.BasePath
+}
+
+// wsURL is the absolute URL of the browser WebSocket as the client
+// reaches it.
+func (s *Server) wsURL(r *http.Request) string {
+	return wsScheme(r) + "://" + requestHost(r) + s.basePath() + "/ws"
+}
+
+// PrefixedAsset is the templates' asset function: a's versioned URL for
+// a static path, under basePath.
+func PrefixedAsset(basePath string, a *StaticAssets) func(string) string {
+	return func(p string) string { return basePath + a.Asset(p) }
+}
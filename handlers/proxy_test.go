@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"rom_go_app/config"
+	"rom_go_app/robot"
+	"rom_go_app/units"
+)
+
+func TestBasePathStrip(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.URL.RawPath + " " + r.URL.RawQuery))
+	})
+	h := BasePath("/robots", echo)
+	for _, c := range []struct{ target, want string }{
+		{"/robots/", "/  "},
+		{"/robots/api/robots?id=1", "/api/robots  id=1"},
+		{"/api/robots?id=1", "/api/robots  id=1"}, // stripped by the proxy
+		{"/robots/maps/a%2Fb", "/maps/a/b /maps/a%2Fb "},
+		{"/robotsx/api", "/robotsx/api  "}, // not under the prefix
+	} {
+		rec := getReq(h.ServeHTTP, c.target)
+		if rec.Code != http.StatusOK || rec.Body.String() != c.want {
+			t.Errorf("%s: %d %q, want %q", c.target, rec.Code, rec.Body, c.want)
+		}
+	}
+	rec := getReq(h.ServeHTTP, "/robots?tab=maps")
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/robots/?tab=maps" {
+		t.Errorf("bare prefix: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	if rec := getReq(BasePath("", echo).ServeHTTP, "/robots/x"); rec.Body.String() != "/robots/x  " {
+		t.Errorf("no prefix: %q", rec.Body)
+	}
+}
+
+// proxiedServer returns the app as main mounts it, with BASE_PATH set to
+// basePath and the templates' asset URLs built as main builds them.
+func proxiedServer(t *testing.T, basePath string) http.Handler {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("BASE_PATH", basePath)
+	t.Setenv("BRANDING_LOGO", "img/logo.svg")
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets, err := NewStaticAssets(os.DirFS("../static"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funcs := units.FuncMap()
+	funcs["asset"] = PrefixedAsset(cfg.BasePath, assets)
+	tmpl, err := ParseTemplates(os.DirFS(".."), funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Manager: robot.NewManager(), Templates: tmpl, Assets: assets, Config: cfg, NavManager: robot.NewNavigationManager()}
+	mux := http.NewServeMux()
+	routes := s.Routes()
+	Register(mux, routes)
+	return BasePath(cfg.BasePath, CORS(mux, s.Origins, routes))
+}
+
+func TestBasePathServer(t *testing.T) {
+	h := proxiedServer(t, "robots/")
+	for _, target := range []string{"/", "/robots/", "/api/robots", "/robots/api/robots", "/static/js/app.js", "/robots/static/js/base_path.js"} {
+		if rec := getReq(h.ServeHTTP, target); rec.Code != http.StatusOK {
+			t.Errorf("%s: %d", target, rec.Code)
+		}
+	}
+	if rec := getReq(h.ServeHTTP, "/robots"); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/robots/" {
+		t.Errorf("/robots: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// The page, reached through a TLS-terminating proxy
+	req := httptest.NewRequest(http.MethodGet, "http://10.0.0.5:8080/robots/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "fleet.example.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<meta name="ws-url" content="wss://fleet.example.com/robots/ws">`,
+		`<meta name="base-path" content="/robots">`,
+		`src="/robots/static/js/base_path.js?v=`,
+		`href="/robots/static/css/style.css?v=`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %s", want)
+		}
+	}
+	if strings.Contains(body, `"/static/`) {
+		t.Error("page has a static URL without the prefix")
+	}
+
+	var ui UIConfig
+	decodeJSON(t, getReq(h.ServeHTTP, "/robots/api/ui_config"), &ui)
+	if ui.LogoURL != "/robots/branding/img/logo.svg" {
+		t.Errorf("logo %q", ui.LogoURL)
+	}
+}
+
+func TestBasePathServerRoot(t *testing.T) {
+	h := proxiedServer(t, "/")
+	if rec := getReq(h.ServeHTTP, "/robots/api/robots"); rec.Code == http.StatusOK && strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Error("prefixed API served without BASE_PATH")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://robots.local:8080/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<meta name="ws-url" content="ws://robots.local:8080/ws">`,
+		`<meta name="base-path" content="">`,
+		`src="/static/js/base_path.js?v=`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %s", want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Relocalization ────────────────────
+
+// maxRelocalizeRotate bounds duration_s.
+const maxRelocalizeRotate = 120
+
+// Relocalize handles POST /api/robots/relocalize?id=X[&rotate=0][&duration_s=N]
+//
+// Calls the robot's global localization service (RELOCALIZE_SERVICE),
+// then turns it in place for duration_s (RELOCALIZE_ROTATE_S by default)
+// unless rotate=0. Steps arrive as relocalize WS messages carrying the
+// localization quality. Refused with 409 while mapping, disconnected,
+// and for a rotation while e-stopped, navigating or too close to another
+// robot with fleet auto-stop on.
+func (h *RobotHandlers) Relocalize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	p := formParams(r)
+	rotate := p.enum("rotate", "1", "0", "1", "true", "false")
+	secs := p.integer("duration_s", int(h.Config.RelocalizeRotate/time.Second), 0, maxRelocalizeRotate)
+	speed := p.float("angular", h.Config.RelocalizeAngular, 0, 1)
+	if p.invalid(w) {
+		return
+	}
+	opts := robot.RelocalizeOptions{Service: h.Config.RelocalizeService, AngularSpeed: speed}
+	if rotate == "1" || rotate == "true" {
+		opts.Rotate = time.Duration(secs) * time.Second
+	}
+	if opts.Rotate > 0 && h.Robots.InCriticalProximity(rb.ID) {
+		jsonError(w, "too close to another robot to rotate in place: relocalize with rotate=0 or move it clear first", http.StatusConflict)
+		return
+	}
+
+	relocID, err := rb.Relocalize(opts)
+	switch {
+	case err == nil:
+	case errors.Is(err, robot.ErrNotConnected), errors.Is(err, robot.ErrMappingMode),
+		errors.Is(err, robot.ErrEStopped), errors.Is(err, robot.ErrNavActive):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		jsonError(w, "global localization failed: "+err.Error(), taskErrorCode(err))
+		return
+	}
+	jsonOK(w, relocalizeResponse{Status: "started", ID: relocID, RotateSec: opts.Rotate.Seconds()})
+}
+
+// CancelRelocalize handles POST /api/robots/relocalize/cancel?id=X
+//
+// Stops a relocalization rotation and the robot; localization keeps
+// converging on its own.
+func (h *RobotHandlers) CancelRelocalize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, map[string]bool{"cancelled": rb.CancelRelocalize()})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Responses ────────────────────
+//
+// Every handler answers through these: JSON bodies, the error shape,
+// rendered templates, and the robot a request is about.
+
+// jsonOK writes data as JSON with 200 OK.
+func jsonOK(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// jsonError writes the error shape with code.
+func jsonError(w http.ResponseWriter, msg string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(errorResponse{Error: msg, Code: errorCode(code)})
+}
+
+// jsonAccepted writes data with 202 Accepted.
+func jsonAccepted(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(data)
+}
+
+// render executes a template. Output is buffered so a failure never
+// leaves half a fragment; the error is logged, HTMX requests get a
+// placeholder fragment (htmx doesn't swap error responses) and others a
+// plain 500 without template internals.
+func (s *Server) render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := s.Templates.Execute(&buf, name, data); err != nil {
+		log.Printf("[templates] render %s (%s): %v", name, r.URL.Path, err)
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, brokenPartial)
+			return
+		}
+		http.Error(w, "internal error: page could not be rendered", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// lookupRobot returns the robot with id, or the current robot when id is
+// empty; otherwise it answers 404 and returns nil.
+func (s *Server) lookupRobot(w http.ResponseWriter, id string) *robot.Robot {
+	return findRobot(w, s.Manager, id)
+}
+
+// findRobot is lookupRobot for the handler groups, which find robots
+// through their own robotFinder.
+func findRobot(w http.ResponseWriter, robots robotFinder, id string) *robot.Robot {
+	if id == "" {
+		id = robots.GetCurrentRobotID()
+	}
+	rb := robots.GetRobot(id)
+	if rb == nil {
+		jsonError(w, "robot not found", http.StatusNotFound)
+	}
+	return rb
+}
@@ -2,120 +2,292 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+
+	"rom_go_app/config"
+	"rom_go_app/discovery"
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+	"rom_go_app/units"
 )
 
+// ──────────────────── Robot handlers ────────────────────
+
+// robotFleet is what the robot handlers need of the fleet;
+// *robot.Manager is one.
+type robotFleet interface {
+	robotFinder
+	broadcaster
+	confirmer
+	GetAllRobots() []*robot.Robot
+	AddRobot(ns, name, ip string, port int) (*robot.Robot, error)
+	RegisterReverseRobot(ns, name, addr string) (r *robot.Robot, created bool)
+	RemoveRobot(id string) error
+	SwitchRobot(id string) error
+	CancelDestructive(robotID, token string) (robot.PendingAction, error)
+	Notify(level, robotID, source, message string) robot.Notice
+	ReportError(robotID, source, message string) robot.Notice
+	InCriticalProximity(id string) bool
+	RebroadcastMap(r *robot.Robot)
+}
+
+// floorSwitcher opens a floor's map with its points;
+// *robot.NavigationManager is one.
+type floorSwitcher interface {
+	SwitchFloor(rb *robot.Robot, floor, client string) (string, error)
+}
+
+// robotPages renders the robot dialogs and list, which HTMX requests get
+// instead of JSON; *Server is one.
+type robotPages interface {
+	renderer
+	RobotListPartial(w http.ResponseWriter, r *http.Request)
+}
+
+// RobotHandlers serves robot management, status, settings, control and
+// motion (see Routes). Discovery is nil when scanning is off.
+type RobotHandlers struct {
+	Robots    robotFleet
+	Floors    floorSwitcher
+	Pages     robotPages
+	Discovery *discovery.Service
+	Config    *config.Config
+}
+
+func (h *RobotHandlers) lookupRobot(w http.ResponseWriter, id string) *robot.Robot {
+	return findRobot(w, h.Robots, id)
+}
+
 // ──────────────────── Robot CRUD ────────────────────
 
 // AddRobot handles POST /api/robots
-func (s *Server) AddRobot(w http.ResponseWriter, r *http.Request) {
+func (h *RobotHandlers) AddRobot(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	ns := r.FormValue("namespace")
-	name := r.FormValue("name")
-	ip := r.FormValue("ip")
-	portStr := r.FormValue("port")
-
-	if ns == "" || name == "" || ip == "" {
-		jsonError(w, "namespace, name, and ip are required", http.StatusBadRequest)
+	p := formParams(r)
+	ns := p.requiredStr("namespace")
+	name := p.requiredStr("name")
+	ip := p.requiredStr("ip")
+	port := p.integer("port", 9090, 1, 65535)
+	if p.invalid(w) {
 		return
 	}
 
-	port := 9090
-	if portStr != "" {
-		p, err := strconv.Atoi(portStr)
-		if err != nil {
-			jsonError(w, "invalid port", http.StatusBadRequest)
-			return
-		}
-		port = p
-	}
-
-	robot, err := s.Manager.AddRobot(ns, name, ip, port)
+	robot, err := h.Robots.AddRobot(ns, name, ip, port)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusConflict)
 		return
 	}
 
-	// Start connection in background
-	go func() {
-		if err := robot.Client.Connect(); err != nil {
-			log.Printf("[api] Robot connect error: %v", err)
-			return
-		}
-		// Handshake to get robot info
-		hs, err := robot.Client.Handshake()
-		if err != nil {
-			log.Printf("[api] Handshake failed for %s: %v", name, err)
-		} else {
-			log.Printf("[api] Handshake OK: ns=%s diameter=%.2f", hs.RobotNamespace, hs.RobotDiameter)
-			if hs.RobotDiameter > 0 {
-				robot.SetRadius(hs.RobotDiameter / 2.0)
-			}
-		}
-	}()
+	// Start connection in background; the robot is connecting from now,
+	// and its progress is broadcast as connect_phase
+	robot.BeginConnect()
+	go h.connectRobot(robot)
 
 	log.Printf("[api] Robot added: %s (%s:%d)", name, ip, port)
 
 	// If HTMX request, return the updated robot list partial
 	if r.Header.Get("HX-Request") == "true" {
-		s.RobotListPartial(w, r)
+		h.Pages.RobotListPartial(w, r)
 		return
 	}
 
-	jsonOK(w, map[string]interface{}{
-		"id":   robot.ID,
-		"name": robot.Name,
-		"ip":   robot.IP,
-	})
+	jsonOK(w, addRobotResponse{ID: robot.ID, Name: robot.Name, IP: robot.IP, ConnectPhase: robot.ConnectPhase()})
+}
+
+// connectRobot connects a newly added robot and applies its handshake
+// info. Failures are reported to the browser as toasts, since the request
+// that added the robot has already returned.
+func (h *RobotHandlers) connectRobot(rb *robot.Robot) {
+	if err := rb.Connect(); err != nil {
+		h.Robots.ReportError(rb.ID, "connect",
+			fmt.Sprintf("Could not connect to %s: %v", rb.Name, err))
+		return
+	}
+	h.handshake(rb)
+}
+
+// handshake asks a connected robot for its info and applies its size
+// and footprint.
+func (h *RobotHandlers) handshake(rb *robot.Robot) {
+	hs, err := rb.Handshake()
+	if err != nil {
+		h.Robots.ReportError(rb.ID, "connect",
+			fmt.Sprintf("Handshake with %s failed: %v", rb.Name, err))
+	} else {
+		log.Printf("[api] Handshake OK: ns=%s diameter=%.2f", hs.RobotNamespace, hs.RobotDiameter)
+	}
+}
+
+// ConnectRobot handles POST /api/robots/connect?id=X
+//
+// Dials the robot now. This is how a robot whose reconnect policy gave up
+// (state "suspended") or that was disconnected on request is resumed; the
+// attempt count restarts. A failed dial answers 502, saying what to
+// check, and is retried according to the policy. A reverse robot can't be dialed: without its
+// agent connected the answer is 409.
+func (h *RobotHandlers) ConnectRobot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	if err := rb.Connect(); errors.Is(err, rosbridge.ErrAwaitingAgent) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	jsonOK(w, rb.Client.ReconnectStatus())
 }
 
 // RemoveRobot handles DELETE /api/robots?id=X
-func (s *Server) RemoveRobot(w http.ResponseWriter, r *http.Request) {
+func (h *RobotHandlers) RemoveRobot(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	id := r.URL.Query().Get("id")
-	if id == "" {
-		jsonError(w, "id required", http.StatusBadRequest)
+	p := formParams(r)
+	id := p.requiredStr("id")
+	if p.invalid(w) {
 		return
 	}
 
-	if err := s.Manager.RemoveRobot(id); err != nil {
+	if err := h.Robots.RemoveRobot(id); err != nil {
 		jsonError(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		s.RobotListPartial(w, r)
+		h.Pages.RobotListPartial(w, r)
 		return
 	}
 
-	jsonOK(w, map[string]string{"status": "removed"})
+	jsonOK(w, removeRobotResponse{Status: "removed", CurrentID: h.Robots.GetCurrentRobotID()})
+}
+
+// ExportRobot handles GET /api/robots/export?id=X
+//
+// Returns the robot's profile (connection, settings, points, walls, map
+// list) as a downloadable JSON document for /api/robots/import.
+func (h *RobotHandlers) ExportRobot(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"robot_%s.json\"", rb.ID))
+	jsonOK(w, rb.ExportProfile())
+}
+
+// ImportRobot handles POST /api/robots/import[?existing_id=X]
+//
+// Creates a robot from an exported profile, or applies it to existing_id
+// (keeping that robot's connection). The response lists fields that were
+// not applied: unknown to this version, invalid, or a differing
+// connection on update.
+func (h *RobotHandlers) ImportRobot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxImportBytes+1))
+	if err != nil {
+		jsonError(w, "read body failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxImportBytes {
+		jsonError(w, "import too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	p, skipped, err := robot.ParseProfile(body)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn := p.Connection
+	created := false
+	var rb *robot.Robot
+	if id := r.URL.Query().Get("existing_id"); id != "" {
+		if rb = h.Robots.GetRobot(id); rb == nil {
+			jsonError(w, "robot not found", http.StatusNotFound)
+			return
+		}
+		if snap := rb.GetSnapshot(); snap.IP != conn.IP || snap.Port != conn.Port {
+			skipped = append(skipped, fmt.Sprintf("connection: robot stays at %s:%d", snap.IP, snap.Port))
+		}
+	} else {
+		fe := fieldErrors{}
+		if conn.Namespace == "" {
+			fe.add("connection.namespace", "required")
+		}
+		if conn.Name == "" {
+			fe.add("connection.name", "required")
+		}
+		if len(fe) > 0 {
+			jsonFieldErrors(w, fe)
+			return
+		}
+		if rb, err = h.Robots.AddRobot(conn.Namespace, conn.Name, conn.IP, conn.Port); err != nil {
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		created = true
+	}
+	skipped = append(skipped, rb.ApplyProfile(p)...)
+
+	if created {
+		go func() {
+			h.connectRobot(rb)
+			// The handshake reports the robot's own diameter (and maybe
+			// footprint); the imported ones were set deliberately, so
+			// keep them.
+			if p.Settings.Radius > 0 {
+				rb.SetRadius(p.Settings.Radius)
+			}
+			if p.Settings.Footprint != nil {
+				rb.SetFootprint(p.Settings.Footprint)
+			}
+		}()
+	}
+
+	log.Printf("[api] Robot profile imported: %s (%s:%d, created=%v, %d skipped)", rb.Name, conn.IP, conn.Port, created, len(skipped))
+	jsonOK(w, importRobotResponse{ID: rb.ID, Name: rb.Name, Created: created, Skipped: skipped})
 }
 
 // SwitchRobot handles POST /api/robots/switch?id=X
-func (s *Server) SwitchRobot(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
-	if id == "" {
-		id = r.URL.Query().Get("id")
+func (h *RobotHandlers) SwitchRobot(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	id := p.requiredStr("id")
+	if p.invalid(w) {
+		return
 	}
 
-	if err := s.Manager.SwitchRobot(id); err != nil {
+	if err := h.Robots.SwitchRobot(id); err != nil {
 		jsonError(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		s.RobotListPartial(w, r)
+		h.Pages.RobotListPartial(w, r)
 		return
 	}
 
@@ -123,182 +295,654 @@ func (s *Server) SwitchRobot(w http.ResponseWriter, r *http.Request) {
 }
 
 // ListRobots handles GET /api/robots
-func (s *Server) ListRobots(w http.ResponseWriter, r *http.Request) {
-	robots := s.Manager.GetAllRobots()
-	list := make([]map[string]interface{}, 0, len(robots))
-	currentID := s.Manager.GetCurrentRobotID()
+func (h *RobotHandlers) ListRobots(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, robotList(h.Robots.GetAllRobots(), h.Robots.GetCurrentRobotID()))
+}
 
-	for _, rb := range robots {
-		snap := rb.GetSnapshot()
-		list = append(list, map[string]interface{}{
-			"id":        snap.ID,
-			"namespace": snap.Namespace,
-			"name":      snap.Name,
-			"ip":        snap.IP,
-			"port":      snap.Port,
-			"connected": snap.Connected,
-			"current":   snap.ID == currentID,
-		})
+// RobotStatus handles GET /api/robots/status?id=X
+func (h *RobotHandlers) RobotStatus(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
 	}
 
-	jsonOK(w, list)
+	u := units.Metric
+	if jsonImperial(r) {
+		u = units.Imperial
+	}
+	jsonOK(w, statusView(rb, u, u == units.Imperial))
 }
 
-// RobotStatus handles GET /api/robots/status?id=X
-func (s *Server) RobotStatus(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	if id == "" {
-		id = s.Manager.GetCurrentRobotID()
+// GetVelocityHistory handles GET /api/robots/velocity_history?id=X[&since=ms][&until=ms][&resolution=R]
+//
+// Returns {"resolution", "commanded": [...], "measured": [...]}; since and
+// until are unix millisecond timestamps bounding the samples. resolution
+// is raw (last 30 s, every sample), 1s (1 Hz averages, last hour), 1m
+// (per-minute min/max/avg) or auto (default), which picks the finest
+// tier that covers since.
+func (h *RobotHandlers) GetVelocityHistory(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
 	}
 
-	rb := s.Manager.GetRobot(id)
-	if rb == nil {
-		jsonError(w, "robot not found", http.StatusNotFound)
+	p := formParams(r)
+	since := p.int64("since", 0, 0, math.MaxInt64)
+	until := p.int64("until", 0, 0, math.MaxInt64)
+	if until > 0 && until <= since {
+		p.fail("until", "must be after since")
+	}
+	resolution := p.enum("resolution", "",
+		robot.ResolutionRaw, robot.ResolutionSecond, robot.ResolutionMinute, robot.ResolutionAuto)
+	if p.invalid(w) {
 		return
 	}
 
-	snap := rb.GetSnapshot()
-	jsonOK(w, map[string]interface{}{
-		"id":        snap.ID,
-		"name":      snap.Name,
-		"connected": snap.Connected,
-		"odom":      snap.Odom,
-		"velocity":  snap.Velocity,
-		"map_hz":    snap.MapHz,
-		"tf_hz":     snap.TFHz,
-		"odom_hz":   snap.OdomHz,
-		"laser_hz":  snap.LaserHz,
-	})
+	jsonOK(w, rb.GetVelocityHistory(resolution, since, until))
 }
 
-// GetVelocityHistory handles GET /api/robots/velocity_history?id=X
-func (s *Server) GetVelocityHistory(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	if id == "" {
-		id = s.Manager.GetCurrentRobotID()
+// VelocityLag handles GET /api/robots/velocity_lag?id=X
+//
+// Estimates how far measured velocity trails the commanded one over the
+// raw history window; 409 while the robot hasn't moved enough to tell.
+func (h *RobotHandlers) VelocityLag(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+	lag, err := rb.EstimateVelocityLag()
+	if errors.Is(err, robot.ErrNoMotion) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	jsonOK(w, lag)
+}
 
-	rb := s.Manager.GetRobot(id)
+// MarkIncident handles POST /api/robots/mark?id=X&label=...
+//
+// Records an incident marker at the current time, so the moment can be
+// found again in the velocity history and the notice log.
+func (h *RobotHandlers) MarkIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
 	if rb == nil {
-		jsonError(w, "robot not found", http.StatusNotFound)
 		return
 	}
 
-	hist := rb.GetVelocityHistory()
-	jsonOK(w, hist)
+	jsonOK(w, rb.AddMarker(robot.MarkerFromOperator, r.FormValue("label"), clientAddr(r)))
 }
 
-// UpdateSettings handles POST /api/robots/settings
-func (s *Server) UpdateSettings(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
-	if id == "" {
-		id = s.Manager.GetCurrentRobotID()
+// GetVelocitySummary handles GET /api/robots/velocity_summary?id=X
+//
+// Returns distance traveled (integrated from odometry), top speed and
+// moving time since the server first received the robot's odometry.
+func (h *RobotHandlers) GetVelocitySummary(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
 	}
 
-	rb := s.Manager.GetRobot(id)
+	jsonOK(w, rb.GetVelocitySummary())
+}
+
+// TFTree handles GET /api/robots/tf_tree?id=X
+//
+// Returns every transform seen on /tf and /tf_static as parent→child
+// edges (sorted root-first) with age and staleness, plus whether the
+// map, odom and base_footprint frames were found.
+func (h *RobotHandlers) TFTree(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
 	if rb == nil {
-		jsonError(w, "robot not found", http.StatusNotFound)
 		return
 	}
 
-	if v := r.FormValue("linear_vel_ratio"); v != "" {
-		if f, err := strconv.ParseFloat(v, 64); err == nil {
-			rb.LinearVelRatio = f
+	jsonOK(w, rb.Client.GetFrameTree())
+}
+
+// ScanMask handles GET/POST /api/robots/scan_mask?id=X
+//
+// GET returns the masked laser sectors (raw=1 adds the latest unmasked
+// scan); POST replaces them with mask=[[start, end], ...] ([] clears).
+func (h *RobotHandlers) ScanMask(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		m, err := robot.ParseScanMask(r.FormValue("mask"))
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		rb.SetScanMask(m)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	if v := r.FormValue("angular_vel_ratio"); v != "" {
-		if f, err := strconv.ParseFloat(v, 64); err == nil {
-			rb.AngularVelRatio = f
+
+	resp := scanMaskResponse{ScanMask: rb.GetScanMask()}
+	if resp.ScanMask == nil {
+		resp.ScanMask = robot.ScanMask{}
+	}
+	if r.FormValue("raw") == "1" {
+		raw := rb.GetRawLaser()
+		resp.Raw = &raw
+	}
+	jsonOK(w, resp)
+}
+
+// UpdateSettings handles POST /api/robots/settings
+func (h *RobotHandlers) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	// Velocity ratios outside VEL_RATIO_MIN..MAX are refused before
+	// anything is applied
+	settings := rb.GetSettings()
+	bounds := rb.VelRatioBounds()
+	p := formParams(r)
+	settings.LinearVelRatio = p.float("linear_vel_ratio", settings.LinearVelRatio, bounds.Min, bounds.Max)
+	settings.AngularVelRatio = p.float("angular_vel_ratio", settings.AngularVelRatio, bounds.Min, bounds.Max)
+	if p.invalid(w) {
+		return
+	}
+
+	// Checked first so a refused switch leaves every other setting as is.
+	if v := r.FormValue("enforce_global_unique_names"); v != "" {
+		conflicts, err := rb.SetGlobalUniqueNames(v == "1" || v == "true" || v == "on")
+		if errors.Is(err, robot.ErrNameConflicts) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "code": errorCode(http.StatusConflict), "conflicts": conflicts})
+			return
 		}
 	}
+
+	rb.SetVelRatios(settings.LinearVelRatio, settings.AngularVelRatio)
+	if v := r.FormValue("holonomic"); v != "" {
+		rb.SetHolonomic(v == "1" || v == "true" || v == "on")
+	}
 	if v := r.FormValue("radius"); v != "" {
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
-			rb.Radius = f
+			settings.Radius = f
+			rb.SetRadius(f)
+		}
+	}
+	// footprint=[[x, y], ...] in the base frame; [] reverts to the radius
+	if v := r.FormValue("footprint"); v != "" {
+		fp, err := robot.ParseFootprint(v)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rb.SetFootprint(fp)
+	}
+	// scan_mask=[[start, end], ...] in laser-frame radians; [] clears it
+	if v := r.FormValue("scan_mask"); v != "" {
+		m, err := robot.ParseScanMask(v)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rb.SetScanMask(m)
+	}
+
+	hints := rb.GetRenderHints()
+	hintsChanged := false
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{{"occupied_threshold", &hints.OccupiedThreshold}, {"free_threshold", &hints.FreeThreshold}} {
+		if v := r.FormValue(f.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("invalid %s %q", f.name, v), http.StatusBadRequest)
+				return
+			}
+			*f.dst = n
+			hintsChanged = true
+		}
+	}
+	// The settings panel always sends invert=0 or 1, as for the other
+	// switches; a request without it leaves invert as is.
+	if v := r.FormValue("invert"); v != "" {
+		hints.Invert = v == "1" || v == "true" || v == "on"
+		hintsChanged = true
+	}
+	if v := r.FormValue("palette"); v != "" {
+		hints.Palette = v
+		hintsChanged = true
+	}
+	if hintsChanged {
+		if err := rb.SetRenderHints(hints); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.Robots.RebroadcastMap(rb)
+	}
+
+	// Reconnect policy: reconnect_enabled, reconnect_initial_delay_ms,
+	// reconnect_max_delay_ms, reconnect_max_attempts (0 = unlimited)
+	policy := rb.Client.ReconnectPolicy()
+	policyChanged := false
+	if v := r.FormValue("reconnect_enabled"); v != "" {
+		policy.Enabled = v == "1" || v == "true" || v == "on"
+		policyChanged = true
+	}
+	for _, f := range []struct {
+		name string
+		dst  *int64
+	}{{"reconnect_initial_delay_ms", &policy.InitialDelayMs}, {"reconnect_max_delay_ms", &policy.MaxDelayMs}} {
+		if v := r.FormValue(f.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("invalid %s %q", f.name, v), http.StatusBadRequest)
+				return
+			}
+			*f.dst = n
+			policyChanged = true
+		}
+	}
+	if v := r.FormValue("reconnect_max_attempts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("invalid reconnect_max_attempts %q", v), http.StatusBadRequest)
+			return
+		}
+		policy.MaxAttempts = n
+		policyChanged = true
+	}
+	if policyChanged {
+		if err := rb.SetReconnectPolicy(policy); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// cmd_vel publishing: cmdvel_rate_hz, cmdvel_change_only,
+	// cmdvel_keep_alive
+	cmdVel := rb.CmdVelOptions()
+	cmdVelChanged := false
+	if v := r.FormValue("cmdvel_rate_hz"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("invalid cmdvel_rate_hz %q", v), http.StatusBadRequest)
+			return
+		}
+		cmdVel.RateHz = f
+		cmdVelChanged = true
+	}
+	if v := r.FormValue("cmdvel_change_only"); v != "" {
+		cmdVel.ChangeOnly = v == "1" || v == "true" || v == "on"
+		cmdVelChanged = true
+	}
+	if v := r.FormValue("cmdvel_keep_alive"); v != "" {
+		cmdVel.KeepAlive = v == "1" || v == "true" || v == "on"
+		cmdVelChanged = true
+	}
+	if cmdVelChanged {
+		if err := rb.SetCmdVelOptions(cmdVel); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Localization grading: loc_fair_xy_m, loc_poor_xy_m,
+	// loc_fair_yaw_rad, loc_poor_yaw_rad, loc_hysteresis
+	loc := rb.LocalizationThresholds()
+	locChanged := false
+	for _, f := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"loc_fair_xy_m", &loc.FairXYM}, {"loc_poor_xy_m", &loc.PoorXYM},
+		{"loc_fair_yaw_rad", &loc.FairYawRad}, {"loc_poor_yaw_rad", &loc.PoorYawRad},
+		{"loc_hysteresis", &loc.Hysteresis},
+	} {
+		if v := r.FormValue(f.name); v != "" {
+			x, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("invalid %s %q", f.name, v), http.StatusBadRequest)
+				return
+			}
+			*f.dst = x
+			locChanged = true
+		}
+	}
+	if locChanged {
+		if err := rb.SetLocalizationThresholds(loc); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
 
-	// Send settings to robot if connected
-	if rb.Connected && rb.Client != nil {
-		args := map[string]interface{}{
-			"linear_vel_ratio":  rb.LinearVelRatio,
-			"angular_vel_ratio": rb.AngularVelRatio,
-			"radius":            rb.Radius,
+	// Offline queue: offline_queue, offline_queue_max, offline_queue_ttl_s
+	offline := rb.OfflineQueue()
+	offlineChanged := false
+	if v := r.FormValue("offline_queue"); v != "" {
+		offline.Enabled = v == "1" || v == "true" || v == "on"
+		offlineChanged = true
+	}
+	if v := r.FormValue("offline_queue_max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("invalid offline_queue_max %q", v), http.StatusBadRequest)
+			return
+		}
+		offline.MaxEntries = n
+		offlineChanged = true
+	}
+	if v := r.FormValue("offline_queue_ttl_s"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("invalid offline_queue_ttl_s %q", v), http.StatusBadRequest)
+			return
+		}
+		offline.TTLSec = f
+		offlineChanged = true
+	}
+	if offlineChanged {
+		if err := rb.SetOfflineQueue(offline); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		argsJSON, _ := json.Marshal(args)
-		_, _ = rb.Client.RequestSettingsSave(string(argsJSON))
 	}
 
-	jsonOK(w, map[string]string{"status": "updated"})
+	// Odometry reset: odom_reset_method, odom_reset_task,
+	// odom_reset_service, odom_reset_topic
+	odomReset := rb.OdomReset()
+	odomResetChanged := false
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{
+		{"odom_reset_method", &odomReset.Method}, {"odom_reset_task", &odomReset.Task},
+		{"odom_reset_service", &odomReset.Service}, {"odom_reset_topic", &odomReset.Topic},
+	} {
+		if v := r.FormValue(f.name); v != "" {
+			*f.dst = v
+			odomResetChanged = true
+		}
+	}
+	if odomResetChanged {
+		if err := rb.SetOdomReset(odomReset); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Robot-side subscription throttles: throttle_<topic>=<ms>, cbor=0|1
+	throttles := map[string]int{}
+	for _, key := range rosbridge.TopicKeys {
+		v := r.FormValue("throttle_" + key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			jsonError(w, fmt.Sprintf("invalid throttle_%s %q", key, v), http.StatusBadRequest)
+			return
+		}
+		throttles[key] = n
+	}
+	var cbor *bool
+	if v := r.FormValue("cbor"); v != "" {
+		b := v == "1" || v == "true" || v == "on"
+		cbor = &b
+	}
+	if len(throttles) > 0 || cbor != nil {
+		rb.SetSubscriptionSettings(throttles, cbor)
+	}
+	if v := r.FormValue("split_connections"); v != "" {
+		rb.SetSplitConnections(v == "1" || v == "true" || v == "on")
+	}
+	if v := r.FormValue("shared_connection"); v != "" {
+		rb.SetSharedConnection(v == "1" || v == "true" || v == "on")
+	}
+	// extra_topics=[{"topic", "type", "throttle_ms", "alias"}, ...]; []
+	// removes them all
+	if v := r.FormValue("extra_topics"); v != "" {
+		ts, err := robot.ParseExtraTopics(v)
+		if err == nil {
+			err = rb.SetExtraTopics(ts)
+		}
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Send settings to robot if connected, or queue them if it has its
+	// offline queue on
+	args := settingsSaveArgs(settings)
+	if rb.IsConnected() && rb.Client != nil {
+		if _, err := rb.RequestSettingsSave(args); errors.Is(err, robot.ErrRobotBusy) {
+			jsonError(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+	} else if cmd, ok := queueOffline(w, rb, robot.ErrNotConnected, robot.PendingSettingsSave, "", "settings save",
+		func() error {
+			_, err := rb.RequestSettingsSave(args)
+			return err
+		}); ok {
+		if cmd != nil {
+			jsonOK(w, settingsResponse{Status: "updated", Pending: cmd})
+		}
+		return
+	}
+
+	jsonOK(w, settingsResponse{Status: "updated"})
+}
+
+// settingsSaveArgs returns the settings_save task arguments for st.
+func settingsSaveArgs(st robot.Settings) string {
+	args := map[string]interface{}{
+		"linear_vel_ratio":  st.LinearVelRatio,
+		"angular_vel_ratio": st.AngularVelRatio,
+		"radius":            st.Radius,
+	}
+	argsJSON, _ := json.Marshal(args)
+	return string(argsJSON)
 }
 
 // ──────────────────── Task commands ────────────────────
 
-// RequestTask handles POST /api/robots/task
-func (s *Server) RequestTask(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
+// RequestTask handles POST /api/robots/task[?async=1]
+//
+// Tasks run sequentially per robot. By default the call waits for the
+// result; with async=1 it returns a task ID for /api/robots/task_status.
+func (h *RobotHandlers) RequestTask(w http.ResponseWriter, r *http.Request) {
+	p := formParams(r)
+	id := p.str("id")
 	if id == "" {
-		id = s.Manager.GetCurrentRobotID()
+		id = h.Robots.GetCurrentRobotID()
+	}
+	task := p.requiredStr("task")
+	async := p.enum("async", "0", "0", "1") == "1"
+	if p.invalid(w) {
+		return
 	}
-	task := r.FormValue("task")
 
-	rb := s.Manager.GetRobot(id)
+	rb := h.Robots.GetRobot(id)
 	if rb == nil || rb.Client == nil {
 		jsonError(w, "robot not found", http.StatusNotFound)
 		return
 	}
 
 	settings := r.FormValue("settings")
-	resp, err := rb.Client.RequestTask(task, settings)
+
+	if async {
+		taskID, pos, err := rb.RequestTaskAsync(task, settings)
+		if err != nil {
+			jsonError(w, err.Error(), taskErrorCode(err))
+			return
+		}
+		jsonOK(w, map[string]interface{}{"task_id": taskID, "position": pos})
+		return
+	}
+
+	resp, err := rb.RequestTask(task, settings)
 	if err != nil {
-		jsonError(w, fmt.Sprintf("task '%s' failed: %v", task, err), http.StatusInternalServerError)
+		jsonError(w, fmt.Sprintf("task '%s' failed: %v", task, err), taskErrorCode(err))
 		return
 	}
 
 	jsonOK(w, map[string]interface{}{"result": resp})
 }
 
-// PowerOff handles POST /api/robots/poweroff
-func (s *Server) PowerOff(w http.ResponseWriter, r *http.Request) {
+// TaskStatus handles GET /api/robots/task_status?id=X&task=T
+func (h *RobotHandlers) TaskStatus(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	p := formParams(r)
+	taskID := p.requiredStr("task")
+	if p.invalid(w) {
+		return
+	}
+	t, ok := rb.GetTask(taskID)
+	if !ok {
+		jsonError(w, "task not found", http.StatusNotFound)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"task":     t,
+		"position": rb.TaskPosition(taskID),
+	})
+}
+
+// TaskCatalog handles GET /api/robots/tasks. refresh=1 asks the robot
+// again first; if that fails the current catalog is returned with the
+// error in it.
+func (h *RobotHandlers) TaskCatalog(w http.ResponseWriter, r *http.Request) {
+	rb := h.lookupRobot(w, r.URL.Query().Get("id"))
+	if rb == nil {
+		return
+	}
+
+	if r.URL.Query().Get("refresh") == "1" {
+		catalog, _ := rb.RefreshTaskCatalog()
+		jsonOK(w, catalog)
+		return
+	}
+	jsonOK(w, rb.GetTaskCatalog())
+}
+
+// taskErrorCode maps task queue errors to HTTP status codes.
+func taskErrorCode(err error) int {
+	var u *robot.UnsupportedError
+	switch {
+	case errors.As(err, &u):
+		return http.StatusNotImplemented
+	case errors.Is(err, robot.ErrRobotBusy):
+		return http.StatusTooManyRequests
+	case errors.Is(err, robot.ErrTaskFlushed):
+		return http.StatusConflict
+	case errors.Is(err, robot.ErrTaskQueueStopped):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// PowerOff handles POST /api/robots/poweroff?id=X[&token=T]
+//
+// Confirmed in two steps: without a token it only issues one (see
+// destructive_api.go). The robot's answer decides the outcome: a refusal
+// answers 409 with its status and reason; an acknowledgment puts the
+// robot in shutting_down (see robot/power_state.go).
+func (h *RobotHandlers) PowerOff(w http.ResponseWriter, r *http.Request) {
 	id := r.FormValue("id")
 	if id == "" {
-		id = s.Manager.GetCurrentRobotID()
+		id = h.Robots.GetCurrentRobotID()
 	}
 
-	rb := s.Manager.GetRobot(id)
+	rb := h.Robots.GetRobot(id)
 	if rb == nil || rb.Client == nil {
 		jsonError(w, "robot not found", http.StatusNotFound)
 		return
 	}
 
-	_, err := rb.Client.RequestPowerOff()
+	if !destructiveConfirmed(w, r, h.Robots, h.Pages, rb, robot.ActionPowerOff, "", confirmView{
+		Title: "Power off", Message: "Power off " + rb.Name + "?", Action: "/api/robots/poweroff",
+	}, nil) {
+		return
+	}
+
+	_, err := rb.RequestPowerOff()
+	if refused(w, err) {
+		return
+	}
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		jsonError(w, err.Error(), taskErrorCode(err))
 		return
 	}
 
-	jsonOK(w, map[string]string{"status": "power_off_sent"})
+	jsonOK(w, powerResponse{Status: "power_off_sent", Power: rb.PowerStatus()})
 }
 
-// Reboot handles POST /api/robots/reboot
-func (s *Server) Reboot(w http.ResponseWriter, r *http.Request) {
+// Reboot handles POST /api/robots/reboot?id=X[&token=T]
+//
+// Confirmed in two steps, and answered, as PowerOff; an acknowledged
+// reboot puts the robot in rebooting until it is back.
+func (h *RobotHandlers) Reboot(w http.ResponseWriter, r *http.Request) {
 	id := r.FormValue("id")
 	if id == "" {
-		id = s.Manager.GetCurrentRobotID()
+		id = h.Robots.GetCurrentRobotID()
 	}
 
-	rb := s.Manager.GetRobot(id)
+	rb := h.Robots.GetRobot(id)
 	if rb == nil || rb.Client == nil {
 		jsonError(w, "robot not found", http.StatusNotFound)
 		return
 	}
 
-	_, err := rb.Client.RequestReboot()
+	if !destructiveConfirmed(w, r, h.Robots, h.Pages, rb, robot.ActionReboot, "", confirmView{
+		Title: "Reboot", Message: "Reboot " + rb.Name + "?", Action: "/api/robots/reboot",
+	}, nil) {
+		return
+	}
+
+	_, err := rb.RequestReboot()
+	if refused(w, err) {
+		return
+	}
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		jsonError(w, err.Error(), taskErrorCode(err))
 		return
 	}
 
-	jsonOK(w, map[string]string{"status": "reboot_sent"})
+	jsonOK(w, powerResponse{Status: "reboot_sent", Power: rb.PowerStatus()})
+}
+
+// refused answers 409 with the robot's status and reason if err is a
+// robot.TaskRefusedError.
+func refused(w http.ResponseWriter, err error) bool {
+	var tr *robot.TaskRefusedError
+	if !errors.As(err, &tr) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(refusedResponse{
+		Error: err.Error(), Code: "robot_refused", Task: tr.Task, Status: tr.Status, Reason: tr.Reason,
+	})
+	return true
 }
 
 // ──────────────────── HTMX Partials ────────────────────
@@ -309,35 +953,61 @@ func (s *Server) RobotListPartial(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
 		"Robots":    robots,
 		"CurrentID": s.Manager.GetCurrentRobotID(),
+		"Units":     displayUnits(r),
 	}
-	s.render(w, "robot_panel.html", data)
+	s.render(w, r, "robot_panel.html", data)
 }
 
 // AddRobotDialog renders the add-robot dialog fragment.
 func (s *Server) AddRobotDialog(w http.ResponseWriter, r *http.Request) {
-	s.render(w, "add_robot.html", nil)
+	s.render(w, r, "add_robot.html", nil)
+}
+
+// settingsView is the settings panel data: the robot snapshot plus the
+// unit system to display it in.
+type settingsView struct {
+	robot.Snapshot
+	RenderHints robot.MapRenderHints
+	Palettes    []string
+	Units       units.System
+	UI          UIConfig
+	RatioBounds robot.RatioBounds
+	Template    robot.TemplateStatus
 }
 
 // SettingsPartial renders the settings panel.
 func (s *Server) SettingsPartial(w http.ResponseWriter, r *http.Request) {
 	rb := s.Manager.GetCurrentRobot()
 	if rb == nil {
-		s.render(w, "settings_panel.html", nil)
+		s.render(w, r, "settings_panel.html", nil)
 		return
 	}
-	snap := rb.GetSnapshot()
-	s.render(w, "settings_panel.html", snap)
+	view := settingsView{
+		Snapshot:    rb.GetSnapshot(),
+		RenderHints: rb.GetRenderHints(),
+		Palettes:    robot.MapPalettes,
+		Units:       displayUnits(r),
+		UI:          s.uiConfig(),
+		RatioBounds: rb.VelRatioBounds(),
+	}
+	if s.SettingsTemplates != nil {
+		view.Template = s.SettingsTemplates.Status(rb)
+	}
+	s.render(w, r, "settings_panel.html", view)
 }
 
 // ──────────────────── Helpers ────────────────────
 
-func jsonOK(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
-}
-
-func jsonError(w http.ResponseWriter, msg string, code int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+// touchRobot marks the robot named by the id query parameter as in use
+// (see robot.Manager.CheckIdle); without one the current robot is meant,
+// which never idles. The form isn't parsed here so uploads stream as usual.
+func (s *Server) touchRobot(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.URL.Query().Get("id"); id != "" {
+			if rb := s.Manager.GetRobot(id); rb != nil {
+				rb.Touch()
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,1365 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"rom_go_app/config"
+	"rom_go_app/discovery"
+	"rom_go_app/importer"
+	"rom_go_app/phrases"
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+	"rom_go_app/version"
+	"rom_go_app/webhook"
+)
+
+// ──────────────────── Route table ────────────────────
+//
+// Every HTTP route is declared once here. Register builds the mux from
+// the table and /api/spec derives the OpenAPI document from it, so a
+// route cannot be served without being documented.
+
+// Route describes one method on one path.
+type Route struct {
+	Method  string
+	Path    string // mux pattern; a trailing "/" matches the subtree
+	Handler http.Handler
+	Tag     string
+	Summary string
+
+	Params   []Param
+	Body     interface{} // JSON request body (zero value of the Go type)
+	RawBody  []string    // other accepted request content types, sent as text
+	Upload   string      // multipart form field carrying a file
+	Response interface{} // JSON response body (zero value of the Go type)
+	Produces string      // non-JSON response content type
+	Status   int         // success status, default 200
+	Errors   []int       // status codes answered with the error shape
+
+	// UI feature the route belongs to; 403 while it is switched off.
+	Feature string
+}
+
+// Param is a query or form parameter. Handlers read them with
+// r.FormValue, so they may be sent either way.
+type Param struct {
+	Name        string
+	Type        string // string, integer, number, boolean
+	Description string
+	Required    bool
+}
+
+func param(name, typ, desc string) Param { return Param{Name: name, Type: typ, Description: desc} }
+
+func required(name, typ, desc string) Param {
+	return Param{Name: name, Type: typ, Description: desc, Required: true}
+}
+
+var (
+	robotIDParam      = param("id", "string", "Robot ID (default: current robot)")
+	pointTypeParam    = required("type", "string", "waypoint, service_point, patrol_point or path_point (robot spellings such as servicepoints are accepted too)")
+	wallTypeParam     = required("type", "string", "waypoint, service_point, patrol_point, path_point or wall (robot spellings such as servicepoints are accepted too)")
+	btTemplateParam   = param("bt_template", "string", "Behavior tree template the robot builds from the points: sequential, round_robin, with_retry or another it lists as bt_<name> (default: the robot's own)")
+	unitsParam        = param("units", "string", "imperial adds converted fields (ft, mph, deg) next to the SI values")
+	confirmTokenParam = param("token", "string", "Confirmation token from the first request; without one a token is issued and nothing else happens")
+	approachParams    = []Param{
+		param("max_speed_mps", "number", "Approach speed limit, at most the robot's max linear velocity"),
+		param("dwell_sec", "number", "Wait time at the point, at most NAV_MAX_DWELL_SEC"),
+		param("yaw_tolerance_rad", "number", "Accepted heading error on arrival (0..π)"),
+		param("on_arrival_task", "string", "which_tasks task run on arrival"),
+	}
+)
+
+// Register mounts routes on mux. Paths with several methods dispatch on
+// the request method and answer 405 otherwise; single-method paths are
+// mounted directly and keep the handler's own method checks.
+func Register(mux *http.ServeMux, routes []Route) {
+	byPath := map[string][]Route{}
+	var paths []string
+	for _, rt := range routes {
+		if _, ok := byPath[rt.Path]; !ok {
+			paths = append(paths, rt.Path)
+		}
+		byPath[rt.Path] = append(byPath[rt.Path], rt)
+	}
+
+	for _, p := range paths {
+		rs := byPath[p]
+		if len(rs) == 1 {
+			mux.Handle(p, rs[0].Handler)
+			continue
+		}
+		methods := map[string]http.Handler{}
+		allowed := make([]string, 0, len(rs))
+		for _, rt := range rs {
+			methods[rt.Method] = rt.Handler
+			allowed = append(allowed, rt.Method)
+		}
+		sort.Strings(allowed)
+		allow := strings.Join(allowed, ", ")
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			h, ok := methods[r.Method]
+			if !ok {
+				w.Header().Set("Allow", allow)
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isUIRoute reports whether rt serves the browser UI: pages, static
+// assets, partials and dialogs. The WebSocket is not one; scripts use it
+// too.
+func isUIRoute(rt Route) bool {
+	return rt.Tag == "pages" || rt.Produces == "text/html"
+}
+
+// apiRoutes drops the UI routes.
+func apiRoutes(routes []Route) []Route {
+	var out []Route
+	for _, rt := range routes {
+		if !isUIRoute(rt) {
+			out = append(out, rt)
+		}
+	}
+	return out
+}
+
+// AdminRoutes returns the routes served on the admin listener: /api/,
+// /metrics and /healthz.
+func AdminRoutes(routes []Route) []Route {
+	var out []Route
+	for _, rt := range routes {
+		if strings.HasPrefix(rt.Path, "/api/") || rt.Path == "/metrics" || rt.Path == "/healthz" {
+			out = append(out, rt)
+		}
+	}
+	return out
+}
+
+// Routes returns the route table of the application, assembled from the
+// feature groups below in a fixed order.
+func (s *Server) Routes() []Route {
+	routes := s.routeTable()
+
+	// Switched-off features refuse their routes
+	for i, rt := range routes {
+		if rt.Feature != "" {
+			routes[i].Handler = s.requireFeature(rt.Feature, rt.Handler)
+			routes[i].Errors = append(rt.Errors[:len(rt.Errors):len(rt.Errors)], http.StatusForbidden)
+		}
+	}
+
+	// Requests naming a robot keep it out of the idle state
+	for i, rt := range routes {
+		for _, p := range rt.Params {
+			if p == robotIDParam {
+				routes[i].Handler = s.touchRobot(rt.Handler)
+				break
+			}
+		}
+	}
+	return routes
+}
+
+// routeTable returns the routes as the groups declare them, before the
+// feature and idle wrappers.
+func (s *Server) routeTable() []Route {
+	var routes []Route
+	for _, group := range [][]Route{
+		s.pageRoutes(), s.healthRoutes(), s.fleetRoutes(), s.configRoutes(), s.robotHandlers().Routes(),
+		s.settingsTemplateRoutes(), s.mapHandlers().Routes(), s.navHandlers().Routes(), s.webhookRoutes(), s.speechHandlers().Routes(),
+		s.uiRoutes(), s.wsHandlers().Routes(),
+	} {
+		routes = append(routes, group...)
+	}
+	if s.NoUI {
+		routes = apiRoutes(routes)
+	}
+	return routes
+}
+
+// hf adapts a handler method for the route table.
+func hf(f http.HandlerFunc) http.Handler { return f }
+
+// pageRoutes returns the page and static file routes.
+func (s *Server) pageRoutes() []Route {
+	var static http.Handler = http.NotFoundHandler()
+	if s.Assets != nil {
+		static = http.StripPrefix("/static/", s.Assets)
+	}
+	return []Route{
+		{Method: "GET", Path: "/static/", Handler: static, Tag: "pages",
+			Summary: "Embedded static asset; ?v=<hash> URLs are cached as immutable", Produces: "application/octet-stream", Errors: []int{404}},
+		{Method: "GET", Path: "/branding/", Handler: s.brandingHandler(), Tag: "pages",
+			Summary: "Deployment file from BRANDING_DIR, else the embedded static file of that name", Produces: "application/octet-stream", Errors: []int{404}},
+		{Method: "GET", Path: "/", Handler: hf(s.IndexPage), Tag: "pages",
+			Summary: "Main application page", Produces: "text/html"},
+	}
+}
+
+// healthRoutes returns health, readiness and metrics.
+func (s *Server) healthRoutes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/healthz", Handler: hf(s.Healthz), Tag: "health",
+			Summary: "Liveness with build info", Response: healthzResponse{}},
+		{Method: "GET", Path: "/readyz", Handler: hf(s.Readyz), Tag: "health",
+			Summary:  "Readiness; 503 with per-check breakdown when not ready",
+			Params:   []Param{param("strict", "integer", "1 also requires a connected robot")},
+			Response: readyzResponse{}, Errors: []int{503}},
+		{Method: "GET", Path: "/api/robots/health", Handler: hf(s.RobotsHealth), Tag: "health",
+			Summary: "Per-robot connection state and topic errors", Response: []robotHealth{}},
+		{Method: "GET", Path: "/api/errors", Handler: hf(s.RecentErrors), Tag: "health",
+			Summary: "Recent failures of background operations, also broadcast as toast messages",
+			Params: []Param{param("id", "string", "Only this robot's notices"),
+				param("since", "integer", "Unix milliseconds; only newer notices")},
+			Response: errorsResponse{}, Errors: []int{400}},
+		{Method: "GET", Path: "/metrics", Handler: hf(s.Metrics), Tag: "health",
+			Summary: "Prometheus metrics", Produces: "text/plain"},
+		{Method: "GET", Path: "/api/spec", Handler: hf(s.Spec), Tag: "health",
+			Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+	}
+}
+
+// fleetRoutes returns fleet-wide views.
+func (s *Server) fleetRoutes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/api/fleet/proximity", Handler: hf(s.FleetProximity), Tag: "fleet", Feature: FeatureFleet,
+			Summary:  "Distances between connected robots on the same map, with their proximity state",
+			Response: robot.ProximityReport{}},
+		{Method: "POST", Path: "/api/fleet/proximity", Handler: hf(s.FleetProximity), Tag: "fleet", Feature: FeatureFleet,
+			Summary: "Change the fleet proximity monitor options",
+			Params: []Param{
+				param("margin_m", "number", "Warning distance beyond the sum of both robots' extents"),
+				param("hysteresis_m", "number", "Extra distance needed to leave a warning or critical state"),
+				param("auto_stop", "boolean", "Zero the teleop velocity of both robots when they come within their extents"),
+			},
+			Response: robot.ProximityReport{}, Errors: []int{400}},
+		{Method: "GET", Path: "/api/fleet/maps", Handler: hf(s.FleetMaps), Tag: "fleet", Feature: FeatureFleet,
+			Summary: "Robot × map presence matrix from the cached map lists",
+			Params: []Param{
+				param("expected", "string", "Map every robot should have; robots without it are listed in missing"),
+			},
+			Response: robot.FleetMaps{}, Errors: []int{400}},
+		{Method: "POST", Path: "/api/fleet/maps/refresh", Handler: hf(s.FleetMapsRefresh), Tag: "fleet", Feature: FeatureFleet,
+			Summary: "Refresh the map list of every connected robot (results broadcast as map_list_changed) and return the matrix",
+			Params: []Param{
+				param("expected", "string", "Map every robot should have; robots without it are listed in missing"),
+			},
+			Response: robot.FleetMaps{}, Errors: []int{400}},
+		{Method: "GET", Path: "/api/fleet/timeline", Handler: hf(s.FleetTimeline), Tag: "fleet", Feature: FeatureFleet,
+			Summary: "Notices, markers, mode, navigation and e-stop changes (and optionally pose and velocity samples) of every robot in one time-ordered stream",
+			Params: []Param{
+				param("from", "integer", "Start, unix ms (default: ten minutes before to)"),
+				param("to", "integer", "End, unix ms (default: now)"),
+				param("cursor", "string", "next of the previous page"),
+				param("limit", "integer", "Entries per page, 1-5000 (default 500)"),
+				param("robots", "string", "Comma-separated robot IDs (default: all)"),
+				param("event", "boolean", "Include notices (default 1)"),
+				param("marker", "boolean", "Include incident markers (default 1)"),
+				param("mode", "boolean", "Include mode changes (default 1)"),
+				param("nav_status", "boolean", "Include navigation status transitions (default 1)"),
+				param("estop", "boolean", "Include e-stop changes (default 1)"),
+				param("pose", "boolean", "Include 1 Hz map poses (default 0)"),
+				param("velocity", "boolean", "Include commanded and measured velocity samples (default 0)"),
+				param("sample_ms", "integer", "Spacing of pose and velocity samples, at least 100 (default 1000)"),
+			},
+			Response: robot.TimelinePage{}, Errors: []int{400}},
+	}
+}
+
+// configRoutes returns configuration and debugging.
+func (s *Server) configRoutes() []Route {
+	return []Route{
+		// Configuration
+		{Method: "GET", Path: "/api/config", Handler: hf(s.GetConfig), Tag: "config",
+			Summary: "Effective configuration by setting name (secrets only as set/unset)", Response: config.Settings{}},
+		{Method: "GET", Path: "/api/ui_config", Handler: hf(s.GetUIConfig), Tag: "config",
+			Summary: "Product name, logo, accent color and enabled UI features (speech, mapping, poweroff, fleet)", Response: UIConfig{}},
+		{Method: "POST", Path: "/api/config/reload", Handler: hf(s.ReloadConfig), Tag: "config",
+			Summary:  "Re-read the environment and CONFIG_FILE (as SIGHUP does); lists applied settings and those needing a restart",
+			Response: config.ReloadResult{}, Errors: []int{400}},
+
+		// Debugging
+		{Method: "GET", Path: "/api/debug/templates", Handler: hf(s.TemplateStatus), Tag: "debug",
+			Summary: "Parsed template files with their template names or parse errors", Response: templatesResponse{}},
+		{Method: "GET", Path: "/api/debug/chaos", Handler: hf(s.Chaos), Tag: "debug",
+			Summary: "Fault injection settings of robot connections and browser WebSocket writes", Response: chaosResponse{}},
+		{Method: "POST", Path: "/api/debug/chaos", Handler: hf(s.Chaos), Tag: "debug",
+			Summary: "Inject drops, latency and periodic disconnects; requires DEBUG_CHAOS=1",
+			Params: []Param{
+				param("target", "string", "robot (default) or browser"),
+				robotIDParam,
+				param("drop_rate", "number", "0..1, per message"),
+				param("added_latency_ms", "integer", "Delay per message"),
+				param("disconnect_every_s", "integer", "Cut the connection after this long; 0 = never"),
+			},
+			Response: chaosResponse{}, Errors: []int{400, 403, 404}},
+	}
+}
+
+// Routes returns robot management, status, settings and motion.
+func (h *RobotHandlers) Routes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/api/robots", Handler: hf(h.ListRobots), Tag: "robots",
+			Summary: "List robots", Response: []robotListEntry{}},
+		{Method: "POST", Path: "/api/robots", Handler: hf(h.AddRobot), Tag: "robots",
+			Summary: "Add a robot and connect in the background; progress is broadcast as connect_phase (connecting, connected, handshaking, ready, or failed with a category: refused, timeout, dns, unreachable, not_rosbridge, handshake or other)",
+			Params: []Param{
+				required("namespace", "string", "ROS namespace"),
+				required("name", "string", "Display name"),
+				required("ip", "string", "rosbridge host"),
+				param("port", "integer", "rosbridge port (default 9090)"),
+			},
+			Response: addRobotResponse{}, Errors: []int{400, 409}},
+		{Method: "DELETE", Path: "/api/robots", Handler: hf(h.RemoveRobot), Tag: "robots",
+			Summary:  "Remove a robot; removing the current one makes the lowest remaining ID current",
+			Params:   []Param{required("id", "string", "Robot ID")},
+			Response: removeRobotResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/robots/export", Handler: hf(h.ExportRobot), Tag: "robots",
+			Summary: "Download the robot profile: connection, settings, points, walls and map list",
+			Params:  []Param{robotIDParam}, Response: robot.Profile{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/import", Handler: hf(h.ImportRobot), Tag: "robots",
+			Summary: "Create a robot from an exported profile, or apply one to an existing robot",
+			Params: []Param{
+				param("existing_id", "string", "Update this robot instead of creating one; its connection is kept"),
+			},
+			Body: robot.Profile{}, Response: importRobotResponse{}, Errors: []int{400, 404, 409, 413}},
+		{Method: "GET", Path: "/api/robots/discover", Handler: hf(h.DiscoverRobots), Tag: "robots",
+			Summary: "Cached scan result and robots seen over mDNS", Response: discoverResponse{}, Errors: []int{503}},
+		{Method: "POST", Path: "/api/robots/discover", Handler: hf(h.DiscoverRobots), Tag: "robots",
+			Summary: "Scan subnets for rosbridge servers",
+			Params: []Param{
+				param("subnets", "string", "Comma-separated CIDRs (default DISCOVERY_SUBNETS or local interfaces)"),
+				param("port", "integer", "rosbridge port"),
+				param("handshake", "integer", "0 skips the which_name probe"),
+				param("refresh", "integer", "1 ignores the one-minute cache"),
+				param("register", "integer", "1 adds every new candidate that answered the handshake"),
+			},
+			Response: discoverResponse{}, Errors: []int{400, 503}},
+		{Method: "POST", Path: "/api/robots/switch", Handler: hf(h.SwitchRobot), Tag: "robots",
+			Summary: "Select the current robot", Params: []Param{required("id", "string", "Robot ID")},
+			Response: switchResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/robot_gateway", Handler: hf(h.RobotGateway), Tag: "robots",
+			Summary: "WebSocket for robot agents behind NAT: registers the robot (connection type reverse) and carries its rosbridge protocol",
+			Params: []Param{
+				required("namespace", "string", "ROS namespace; identifies the robot across reconnects"),
+				param("name", "string", "Display name when the robot is registered (default: the namespace)"),
+				param("token", "string", "ROBOT_GATEWAY_TOKEN, unless sent as Authorization: Bearer"),
+			},
+			Status: http.StatusSwitchingProtocols, Errors: []int{400, 401, 404}},
+		{Method: "POST", Path: "/api/robots/connect", Handler: hf(h.ConnectRobot), Tag: "robots",
+			Summary:  "Connect now; resumes a robot whose reconnect policy gave up (suspended)",
+			Params:   []Param{robotIDParam},
+			Response: rosbridge.ReconnectStatus{}, Errors: []int{404, 409, 502}},
+		{Method: "GET", Path: "/api/robots/status", Handler: hf(h.RobotStatus), Tag: "robots",
+			Summary:  "Connection, uptime, odometry, topic rates, last-message ages and staleness",
+			Params:   []Param{robotIDParam, unitsParam},
+			Response: StatusView{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/velocity_history", Handler: hf(h.GetVelocityHistory), Tag: "robots",
+			Summary: "Commanded and measured velocity samples at raw, 1 Hz or per-minute resolution, with the incident markers in their range",
+			Params: []Param{robotIDParam,
+				param("since", "integer", "Unix milliseconds; only newer samples"),
+				param("until", "integer", "Unix milliseconds; only samples up to this time"),
+				param("resolution", "string", "raw (last 30 s), 1s (last hour), 1m or auto (default: finest tier covering since)")},
+			Response: robot.VelocityHistory{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/robots/velocity_lag", Handler: hf(h.VelocityLag), Tag: "robots",
+			Summary:  "Estimated lag of measured behind commanded linear velocity over the last 30 s, by cross-correlation at 10 Hz, with a 0-1 confidence; 409 without enough motion. Broadcast as velocity_lag when it changes by 50 ms or 0.2 confidence",
+			Params:   []Param{robotIDParam},
+			Response: robot.VelocityLag{}, Errors: []int{404, 409, 500}},
+		{Method: "POST", Path: "/api/robots/mark", Handler: hf(h.MarkIncident), Tag: "robots",
+			Summary: "Mark an incident at the current time; broadcast as a marker event",
+			Params: []Param{robotIDParam,
+				param("label", "string", "What happened; longer than 200 characters is cut")},
+			Response: robot.Marker{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/velocity_summary", Handler: hf(h.GetVelocitySummary), Tag: "robots",
+			Summary:  "Distance traveled, top speed and moving time since odometry was first received",
+			Params:   []Param{robotIDParam},
+			Response: robot.VelocitySummary{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/tf_tree", Handler: hf(h.TFTree), Tag: "robots",
+			Summary: "Transforms seen on /tf and /tf_static, with staleness and map/odom/base frame checks",
+			Params:  []Param{robotIDParam}, Response: rosbridge.FrameTree{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/bandwidth", Handler: hf(h.RobotBandwidth), Tag: "robots",
+			Summary: "rosbridge traffic: cumulative bytes since the robot was added, one-minute rates, per-topic totals",
+			Params:  []Param{robotIDParam}, Response: robot.RobotBandwidth{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/subscriptions", Handler: hf(h.RobotSubscriptions), Tag: "robots",
+			Summary: "Topics subscribed on the robot's current rosbridge connection, with throttle and compression",
+			Params:  []Param{robotIDParam}, Response: subscriptionsResponse{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/settings", Handler: hf(h.UpdateSettings), Tag: "robots",
+			Summary: "Update robot settings; unset parameters are left unchanged",
+			Params: []Param{
+				robotIDParam,
+				param("linear_vel_ratio", "number", "Joystick linear scale, VEL_RATIO_MIN..VEL_RATIO_MAX (default 0.05..2); applies to a drive in progress"),
+				param("angular_vel_ratio", "number", "Joystick angular scale, same range"),
+				param("holonomic", "boolean", "Pass joystick linear_y (strafe) to cmd_vel; set on connect if the robot lists the holonomic capability"),
+				param("radius", "number", "Robot radius (m)"),
+				param("footprint", "string", "JSON [[x, y], ...] outline in the base frame (m, ≥3 vertices); [] reverts to the radius"),
+				param("scan_mask", "string", "JSON [[start, end], ...] laser-frame sectors (rad, within ±π; start > end wraps) hidden from scans; [] clears it"),
+				param("occupied_threshold", "integer", "Map render hint"),
+				param("free_threshold", "integer", "Map render hint"),
+				param("invert", "boolean", "Map render hint"),
+				param("palette", "string", "Map render hint"),
+				param("throttle_map", "integer", "Robot-side throttle (ms, 0 = off)"),
+				param("throttle_laser", "integer", "Robot-side throttle (ms, 0 = off)"),
+				param("throttle_odom", "integer", "Robot-side throttle (ms, 0 = off)"),
+				param("cbor", "boolean", "CBOR compression for rosbridge subscriptions"),
+				param("split_connections", "boolean", "Separate rosbridge data connection"),
+				param("shared_connection", "boolean", "Share one rosbridge connection with the robots of the same server (no separate data connection then)"),
+				param("extra_topics", "string", "JSON [{\"topic\", \"type\", \"throttle_ms\", \"alias\"}, ...] topics passed to the browser unparsed as custom:<alias> frames (at most 16; aliases [A-Za-z0-9_-]); [] removes them"),
+				param("reconnect_enabled", "boolean", "Reconnect automatically; off suspends the robot when its connection drops"),
+				param("reconnect_initial_delay_ms", "integer", "Delay before the first reconnect attempt, doubled per attempt"),
+				param("reconnect_max_delay_ms", "integer", "Upper bound of the reconnect delay"),
+				param("reconnect_max_attempts", "integer", "Attempts before suspending (0 = unlimited)"),
+				param("cmdvel_rate_hz", "number", "cmd_vel publish rate (1–100 Hz, default 20)"),
+				param("cmdvel_change_only", "boolean", "Publish a moving command only when it changes (default on)"),
+				param("cmdvel_keep_alive", "boolean", "Keep publishing zeros while idle (default off)"),
+				param("loc_fair_xy_m", "number", "Position spread √(var_x+var_y) graded fair from (default 0.25)"),
+				param("loc_poor_xy_m", "number", "Position spread graded poor from (default 0.5)"),
+				param("loc_fair_yaw_rad", "number", "Heading spread √var_yaw graded fair from (default 0.2)"),
+				param("loc_poor_yaw_rad", "number", "Heading spread graded poor from (default 0.4)"),
+				param("loc_hysteresis", "number", "Fraction below a threshold needed to improve a grade (0–1, default 0.2)"),
+				param("offline_queue", "boolean", "Queue nav uploads, settings saves and map list refreshes while disconnected (default off)"),
+				param("offline_queue_max", "integer", "Queued commands kept at most (1–100, default 16)"),
+				param("offline_queue_ttl_s", "number", "Queued commands not run within this are dropped (default 300)"),
+				param("odom_reset_method", "string", "How reset_odom resets odometry: task (default), service or initial_pose"),
+				param("odom_reset_task", "string", "which_tasks task of the task method (default reset_odometry)"),
+				param("odom_reset_service", "string", "Service of the service method (default /reset_odom)"),
+				param("odom_reset_topic", "string", "Pose topic of the initial_pose method (default /initialpose)"),
+				param("enforce_global_unique_names", "boolean", "Point names unique across all types; 409 lists conflicts"),
+			},
+			Response: settingsResponse{}, Errors: []int{400, 404, 409, 429}},
+		{Method: "GET", Path: "/api/robots/pending", Handler: hf(h.PendingCommands), Tag: "robots",
+			Summary:  "Commands queued while the robot is disconnected, run in order on reconnect",
+			Params:   []Param{robotIDParam},
+			Response: pendingResponse{}, Errors: []int{404}},
+		{Method: "DELETE", Path: "/api/robots/pending", Handler: hf(h.PendingCommands), Tag: "robots",
+			Summary:  "Cancel a queued command, or all of them",
+			Params:   []Param{robotIDParam, param("entry", "integer", "Queued command ID (default: all)")},
+			Response: cancelPendingResponse{}, Errors: []int{400, 404}},
+		{Method: "POST", Path: "/api/robots/pending/flush", Handler: hf(h.FlushPending), Tag: "robots",
+			Summary:  "Run the queued commands now; 409 while disconnected",
+			Params:   []Param{robotIDParam},
+			Response: flushPendingResponse{}, Errors: []int{404, 409}},
+		{Method: "POST", Path: "/api/robots/floor", Handler: hf(h.SwitchFloor), Tag: "robots",
+			Summary:  "Switch floors: open the floor's map and swap in its navigation points",
+			Params:   []Param{robotIDParam, required("floor", "string", "Floor name")},
+			Response: floorsResponse{}, Errors: []int{400, 404, 409, 500, 501, 503}},
+		{Method: "POST", Path: "/api/robots/task", Handler: hf(h.RequestTask), Tag: "robots",
+			Summary: "Run a which_tasks request; waits for the result unless async=1",
+			Params: []Param{
+				robotIDParam,
+				required("task", "string", "Task name"),
+				param("settings", "string", "Task settings"),
+				param("async", "integer", "1 returns a task ID for /api/robots/task_status"),
+			},
+			Response: taskResponse{}, Errors: []int{400, 404, 409, 429, 500, 501}},
+		{Method: "GET", Path: "/api/robots/task_status", Handler: hf(h.TaskStatus), Tag: "robots",
+			Summary:  "State of a queued task",
+			Params:   []Param{robotIDParam, required("task", "string", "Task ID")},
+			Response: taskStatusResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/robots/scan_mask", Handler: hf(h.ScanMask), Tag: "robots",
+			Summary: "Laser sectors hidden from scans before broadcast",
+			Params: []Param{
+				robotIDParam,
+				param("raw", "integer", "1 adds the latest scan before masking"),
+			},
+			Response: scanMaskResponse{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/scan_mask", Handler: hf(h.ScanMask), Tag: "robots",
+			Summary: "Replace the scan mask; broadcasts robot_config",
+			Params: []Param{
+				robotIDParam,
+				required("mask", "string", "JSON [[start, end], ...] laser-frame sectors (rad, within ±π; start > end wraps through ±π); [] clears"),
+			},
+			Response: scanMaskResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/robots/home", Handler: hf(h.RobotHome), Tag: "robots",
+			Summary:  "The robot's home pose and the map it is valid on",
+			Params:   []Param{robotIDParam},
+			Response: homeResponse{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/home", Handler: hf(h.RobotHome), Tag: "robots",
+			Summary: "Set the home pose from coordinates or the current pose, or clear it; broadcasts robot_config",
+			Params: []Param{
+				robotIDParam,
+				param("x", "number", "Map-frame x (m)"),
+				param("y", "number", "Map-frame y (m)"),
+				param("theta", "number", "Heading (rad), default 0"),
+				param("map", "string", "Map the pose is valid on, default the current map"),
+				param("here", "integer", "1 uses the robot's current map pose and map"),
+				param("clear", "integer", "1 removes the home"),
+			},
+			Response: homeResponse{}, Errors: []int{400, 404, 409}},
+		{Method: "POST", Path: "/api/robots/go_home", Handler: hf(h.GoHome), Tag: "robots",
+			Summary:  "Navigate to the home pose; refused (409) with no home, on another map, e-stopped or disconnected. Progress arrives as home WS messages",
+			Params:   []Param{robotIDParam},
+			Response: goHomeResponse{}, Errors: []int{404, 409, 500}},
+		{Method: "POST", Path: "/api/robots/reset_odom", Handler: hf(h.ResetOdometry), Tag: "robots",
+			Summary: "Reset odometry and localization after the robot was moved by hand, by the robot's odom_reset method; clears the velocity history and broadcasts pose_reset. Refused (409) while disconnected or navigating unless forced",
+			Params: []Param{robotIDParam,
+				required("confirm", "integer", "Must be 1"),
+				param("x", "number", "Map-frame x (m) where the robot stands; required by initial_pose without a home on the current map"),
+				param("y", "number", "Map-frame y (m)"),
+				param("theta", "number", "Heading (rad), default 0"),
+				param("force", "boolean", "Reset even while a navigation goal is active"),
+			},
+			Response: resetOdomResponse{}, Errors: []int{400, 404, 409, 429, 500, 501}},
+		{Method: "POST", Path: "/api/robots/relocalize", Handler: hf(h.Relocalize), Tag: "robots",
+			Summary: "Reinitialize global localization, then rotate in place so it converges; steps arrive as relocalize WS messages with the localization quality. Refused (409) while mapping or disconnected, and for a rotation while e-stopped, navigating or critically close to another robot",
+			Params: []Param{robotIDParam,
+				param("rotate", "boolean", "0 skips the rotation, default 1"),
+				param("duration_s", "integer", "Rotation time (0-120), default RELOCALIZE_ROTATE_S"),
+				param("angular", "number", "Rotation speed (rad/s, 0-1), default RELOCALIZE_ANGULAR"),
+			},
+			Response: relocalizeResponse{}, Errors: []int{400, 404, 409, 429, 500, 501}},
+		{Method: "POST", Path: "/api/robots/relocalize/cancel", Handler: hf(h.CancelRelocalize), Tag: "robots",
+			Summary: "Stop a relocalization rotation and the robot", Params: []Param{robotIDParam},
+			Response: cancelMoveResponse{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/stats", Handler: hf(h.UsageStats), Tag: "robots",
+			Summary:  "Distance traveled and active time since the last reset, with the last 30 days' daily usage and the latest velocity lag estimate; odometry jumps are not counted",
+			Params:   []Param{robotIDParam},
+			Response: robot.UsageReport{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/stats/reset", Handler: hf(h.ResetUsageStats), Tag: "robots",
+			Summary: "Zero the usage totals after maintenance; the daily history is kept. Broadcasts usage_reset",
+			Params: []Param{robotIDParam,
+				required("confirm", "integer", "Must be 1"),
+				param("note", "string", "Why, e.g. the maintenance done"),
+			},
+			Response: resetUsageResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/robots/tasks", Handler: hf(h.TaskCatalog), Tag: "robots",
+			Summary: "Tasks the robot accepts, discovered on connect or from the static list",
+			Params: []Param{
+				robotIDParam,
+				param("refresh", "integer", "1 asks the robot for its tasks again first"),
+			},
+			Response: robot.TaskCatalog{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/capabilities", Handler: hf(h.RobotCapabilities), Tag: "robots",
+			Summary: "Software version and capabilities the robot reported on connect; null capabilities means unknown (everything allowed)",
+			Params: []Param{
+				robotIDParam,
+				param("refresh", "integer", "1 asks the robot again first"),
+			},
+			Response: robot.Capabilities{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/move_relative", Handler: hf(h.MoveRelative), Tag: "motion",
+			Summary: "Start a closed-loop relative move; progress arrives as move_progress WS messages",
+			Params: []Param{
+				robotIDParam,
+				param("client_id", "string", "WS client_id of the caller; must hold the control lease if anyone does"),
+			},
+			Body: robot.RelativeMoveRequest{}, Response: moveResponse{}, Errors: []int{400, 404, 409}},
+		{Method: "DELETE", Path: "/api/robots/move_relative", Handler: hf(h.MoveRelative), Tag: "motion",
+			Summary: "Cancel the active relative move and stop", Params: []Param{robotIDParam},
+			Response: cancelMoveResponse{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/estop", Handler: hf(h.EStop), Tag: "motion",
+			Summary: "Software e-stop state", Params: []Param{robotIDParam},
+			Response: estopResponse{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/estop", Handler: hf(h.EStop), Tag: "motion",
+			Summary:  "Engage or release the software e-stop",
+			Params:   []Param{robotIDParam, param("engaged", "boolean", "Default true")},
+			Response: estopResponse{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/control", Handler: hf(h.RobotControl), Tag: "motion",
+			Summary: "Control lease: the connection allowed to drive and the pending request; transitions arrive as control_lease WS messages",
+			Params:  []Param{robotIDParam}, Response: robot.ControlLease{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/control/grant", Handler: hf(h.AnswerControl), Tag: "motion",
+			Summary: "Hand the control lease to the pending requester in place of the holder",
+			Params: []Param{
+				robotIDParam,
+				param("client_id", "string", "Requesting connection; refused if another one is pending"),
+			},
+			Response: robot.ControlLeaseEvent{}, Errors: []int{404, 409}},
+		{Method: "POST", Path: "/api/robots/control/deny", Handler: hf(h.AnswerControl), Tag: "motion",
+			Summary: "Deny the pending control request in place of the holder",
+			Params: []Param{
+				robotIDParam,
+				param("client_id", "string", "Requesting connection; refused if another one is pending"),
+			},
+			Response: robot.ControlLeaseEvent{}, Errors: []int{404, 409}},
+		{Method: "POST", Path: "/api/robots/control/release", Handler: hf(h.ReleaseControl), Tag: "motion",
+			Summary: "Force-release the control lease and drop a pending request",
+			Params:  []Param{robotIDParam}, Response: controlReleaseResponse{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/robots/autonomy_lock", Handler: hf(h.AutonomyLock), Tag: "motion",
+			Summary: "Autonomy lock state (joystick rejected while locked)", Params: []Param{robotIDParam},
+			Response: robot.Autonomy{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/autonomy_lock", Handler: hf(h.AutonomyLock), Tag: "motion",
+			Summary:  "Set or clear the manual autonomy lock",
+			Params:   []Param{robotIDParam, param("locked", "boolean", "Default true")},
+			Response: robot.Autonomy{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/robots/poweroff", Handler: hf(h.PowerOff), Tag: "robots", Feature: FeaturePowerOff,
+			Summary:  "Power off the robot. Without a token answers 202 with a confirmation token and broadcasts pending_destructive_action; repeat with the token before it expires. A used, cancelled, expired or other robot's token is refused (409), and so is a poweroff the robot refuses (409, code robot_refused, with its status and reason). Once acknowledged the robot is shutting_down, then powered_off when it drops; power_state events follow the transitions",
+			Params:   []Param{robotIDParam, confirmTokenParam},
+			Response: powerResponse{}, Errors: []int{404, 409, 429, 500, 501}},
+		{Method: "POST", Path: "/api/robots/reboot", Handler: hf(h.Reboot), Tag: "robots",
+			Summary:  "Reboot the robot; confirmed with a token, and refusals answered, as poweroff. Once acknowledged the robot is rebooting until it reconnects (then resynced), or unreachable, with an alert, if it isn't back within POWER_DOWNTIME_S",
+			Params:   []Param{robotIDParam, confirmTokenParam},
+			Response: powerResponse{}, Errors: []int{404, 409, 429, 500, 501}},
+		{Method: "POST", Path: "/api/robots/destructive/cancel", Handler: hf(h.CancelDestructive), Tag: "robots",
+			Summary:  "Void a pending destructive action's confirmation token",
+			Params:   []Param{robotIDParam, required("token", "string", "Token from the first request")},
+			Response: destructiveCancelResponse{}, Errors: []int{400, 404}},
+	}
+}
+
+// Routes returns maps, mapping sessions and modes.
+func (h *MapHandlers) Routes() []Route {
+	return []Route{
+		// Maps
+		{Method: "GET", Path: "/api/maps", Handler: hf(h.ListMaps), Tag: "maps",
+			Summary: "Maps stored on the current robot",
+			Params: []Param{
+				param("refresh", "integer", "1 asks the robot again; queued while disconnected if the offline queue is on"),
+			},
+			Response: mapsResponse{}, Errors: []int{400, 429}},
+		{Method: "POST", Path: "/api/maps/save", Handler: hf(h.SaveMap), Tag: "maps",
+			Summary: "Start saving the current map; progress arrives as map_save WS messages", Body: mapNameRequest{},
+			Response: mapSaveResponse{}, Errors: []int{400, 409, 501, 503}},
+		{Method: "GET", Path: "/api/maps/save_status", Handler: hf(h.MapSaveStatus), Tag: "maps",
+			Summary:  "State of a recent map save",
+			Params:   []Param{robotIDParam, required("op", "string", "Save operation ID")},
+			Response: robot.MapSaveOp{}, Errors: []int{400, 404}},
+		{Method: "POST", Path: "/api/maps/open", Handler: hf(h.OpenMap), Tag: "maps",
+			Summary: "Select a stored map", Body: mapNameRequest{},
+			Response: mapResponse{}, Errors: []int{400, 500, 501, 503}},
+		{Method: "GET", Path: "/api/maps/render_hints", Handler: hf(h.MapRenderHints), Tag: "maps",
+			Summary: "Map classification thresholds and palettes", Params: []Param{robotIDParam},
+			Response: renderHintsResponse{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/maps/current_meta", Handler: hf(h.CurrentMapMeta), Tag: "maps",
+			Summary: "Size, origin, map_seq and checksum of the current map", Params: []Param{robotIDParam},
+			Response: robot.MapMeta{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/maps/transform", Handler: hf(h.MapTransform), Tag: "maps",
+			Summary: "Convert a position on the current map between world coordinates, image pixels and grid cells; one pair is required",
+			Params: []Param{
+				robotIDParam,
+				param("x", "number", "World x (m), with y"),
+				param("y", "number", "World y (m)"),
+				param("theta", "number", "World heading (rad), with x and y"),
+				param("px", "number", "Image x (pixels from the left edge), with py"),
+				param("py", "number", "Image y (pixels from the top edge)"),
+				param("image_theta_deg", "number", "Image heading (degrees), with px and py"),
+				param("col", "integer", "Grid column, with row"),
+				param("row", "integer", "Grid row (0 is the bottom of the map)"),
+			},
+			Response: mapTransformResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/maps/export", Handler: hf(h.ExportMapPGM), Tag: "maps",
+			Summary: "Current map as a PGM image", Params: []Param{robotIDParam},
+			Produces: "image/x-portable-graymap", Errors: []int{404}},
+		{Method: "GET", Path: "/api/maps/history", Handler: hf(h.MapHistory), Tag: "maps",
+			Summary:  "Loaded map and recent saves/opens, newest first",
+			Params:   []Param{robotIDParam, param("limit", "integer", "Max entries (default 20, 50 kept)")},
+			Response: mapHistoryResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/maps/thumbnail", Handler: hf(h.MapThumbnail), Tag: "maps",
+			Summary:  "PNG preview of a saved map; 404 when none was captured",
+			Params:   []Param{robotIDParam, required("name", "string", "Map name")},
+			Produces: "image/png", Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/maps/floors", Handler: hf(h.Floors), Tag: "maps",
+			Summary: "Floors with their maps, the active floor and the current map", Params: []Param{robotIDParam},
+			Response: floorsResponse{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/maps/assign_floor", Handler: hf(h.AssignFloor), Tag: "maps",
+			Summary: "Assign a map to a floor; an empty floor removes the assignment",
+			Params:  []Param{robotIDParam}, Body: assignFloorRequest{},
+			Response: floorsResponse{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/maps/archive", Handler: hf(h.ListMapArchive), Tag: "maps",
+			Summary:  "Map versions archived on the server, newest first, with the archive's disk use",
+			Params:   []Param{robotIDParam, param("name", "string", "Only versions of this map")},
+			Response: mapArchiveResponse{}, Errors: []int{404, 503}},
+		{Method: "POST", Path: "/api/maps/archive", Handler: hf(h.ArchiveMap), Tag: "maps",
+			Summary:  "Archive the current map as a new version; saving a map through the app does this too",
+			Params:   []Param{robotIDParam, param("name", "string", "Map name (default: the loaded map)")},
+			Response: robot.MapVersion{}, Errors: []int{400, 404, 500, 503}},
+		{Method: "GET", Path: "/api/maps/archive/grid", Handler: hf(h.MapArchiveGrid), Tag: "maps",
+			Summary: "An archived map version in the map WS message format, for overlaying on the current map",
+			Params: []Param{
+				robotIDParam,
+				required("name", "string", "Map name"),
+				required("version", "integer", "Version from GET /api/maps/archive"),
+				param("encoding", "string", "base64_rle run-length encodes the grid"),
+			},
+			Response: mapArchiveGridResponse{}, Errors: []int{400, 404, 500, 503}},
+		{Method: "GET", Path: "/api/maps/archive/diff", Handler: hf(h.MapArchiveDiff), Tag: "maps",
+			Summary: "Cells changed between two archived versions of a map, with newly occupied and freed areas",
+			Params: []Param{
+				robotIDParam,
+				required("name", "string", "Map name"),
+				required("from", "integer", "Older version"),
+				param("to", "integer", "Newer version (default: the newest)"),
+			},
+			Response: robot.MapDiff{}, Errors: []int{400, 404, 422, 500, 503}},
+
+		// Mapping sessions
+		{Method: "POST", Path: "/api/mapping/start", Handler: hf(h.MappingStart), Tag: "mapping", Feature: FeatureMapping,
+			Summary: "Switch to mapping and start a guided session; state changes arrive as mapping_session WS messages",
+			Params:  []Param{robotIDParam}, Body: mapNameRequest{},
+			Response: robot.MappingSession{}, Errors: []int{400, 404, 409, 500, 501, 503}},
+		{Method: "GET", Path: "/api/mapping/status", Handler: hf(h.MappingStatus), Tag: "mapping", Feature: FeatureMapping,
+			Summary: "Active or last mapping session with coverage statistics", Params: []Param{robotIDParam},
+			Response: robot.MappingSession{}, Errors: []int{404}},
+		{Method: "GET", Path: "/api/mapping/progress", Handler: hf(h.MappingProgress), Tag: "mapping", Feature: FeatureMapping,
+			Summary: "Coverage growth while mapping; also sent every 5 s as mapping_progress WS messages", Params: []Param{robotIDParam},
+			Response: robot.MappingProgress{}, Errors: []int{404}},
+		{Method: "POST", Path: "/api/mapping/finish", Handler: hf(h.MappingFinish), Tag: "mapping", Feature: FeatureMapping,
+			Summary: "Save the map, switch to navigation and open it", Params: []Param{robotIDParam},
+			Response: robot.MappingSession{}, Errors: []int{404, 409, 500}},
+		{Method: "POST", Path: "/api/mapping/abort", Handler: hf(h.MappingAbort), Tag: "mapping", Feature: FeatureMapping,
+			Summary: "End the session without saving and restore the previous mode", Params: []Param{robotIDParam},
+			Response: robot.MappingSession{}, Errors: []int{404, 409, 500}},
+
+		// Modes
+		{Method: "POST", Path: "/api/mode/navigation", Handler: hf(h.SetNavigationMode), Tag: "modes",
+			Summary: "Switch the current robot to navigation", Response: modeResponse{}, Errors: []int{400, 500, 503}},
+		{Method: "POST", Path: "/api/mode/mapping", Handler: hf(h.SetMappingMode), Tag: "modes", Feature: FeatureMapping,
+			Summary: "Switch the current robot to mapping", Response: modeResponse{}, Errors: []int{400, 500, 501, 503}},
+		{Method: "POST", Path: "/api/mode/remapping", Handler: hf(h.SetRemappingMode), Tag: "modes", Feature: FeatureMapping,
+			Summary: "Switch the current robot to remapping", Response: modeResponse{}, Errors: []int{400, 500, 501, 503}},
+	}
+}
+
+// Routes returns the current robot's navigation points.
+func (h *NavHandlers) Routes() []Route {
+	return []Route{
+		{Method: "POST", Path: "/api/nav/add", Handler: hf(h.AddNavigationPoint), Tag: "navigation",
+			Summary: "Add a navigation point or wall",
+			Params: []Param{
+				wallTypeParam,
+				required("name", "string", ""),
+				required("world_x", "number", "m"),
+				required("world_y", "number", "m"),
+				param("theta", "number", "rad"),
+				param("world_x2", "number", "Wall end x (m)"),
+				param("world_y2", "number", "Wall end y (m)"),
+				approachParams[0], approachParams[1], approachParams[2], approachParams[3],
+			},
+			Response: statusResponse{}, Errors: []int{400}},
+		{Method: "POST", Path: "/api/nav/add_bulk", Handler: hf(h.AddNavigationPointsBulk), Tag: "navigation",
+			Summary: "Add many points; invalid or duplicate ones are skipped",
+			Body:    bulkPointsRequest{}, Response: bulkPointsResponse{}, Errors: []int{400}},
+		{Method: "POST", Path: "/api/nav/add_here", Handler: hf(h.AddNavigationPointHere), Tag: "navigation",
+			Summary:  "Add a point at the robot's current map pose",
+			Params:   append([]Param{pointTypeParam, required("name", "string", "")}, approachParams...),
+			Response: addHereResponse{}, Errors: []int{400, 409}},
+		{Method: "GET", Path: "/api/nav/list", Handler: hf(h.ListNavigationPoints), Tag: "navigation",
+			Summary:  "Points of one type, or all collections and their sync state when type is omitted",
+			Params:   []Param{param("type", "string", wallTypeParam.Description), unitsParam},
+			Response: navPointsResponse{}, Errors: []int{400}},
+		{Method: "POST", Path: "/api/nav/send", Handler: hf(h.SendNavigationPoints), Tag: "navigation",
+			Summary:  "Upload a collection to the robot; 207 with status partial when the robot refused some points, 202 with status queued when it is disconnected and its offline queue is on",
+			Params:   []Param{wallTypeParam, btTemplateParam},
+			Response: navSendResponse{}, Errors: []int{400, 429, 500, 501}},
+		{Method: "GET", Path: "/api/nav/bt_preview", Handler: hf(h.BTPreview), Tag: "navigation",
+			Summary:  "Behavior tree the robot would build from a collection, without running it; cut to 256 KiB (truncated set); 404 when the firmware has no preview",
+			Params:   []Param{pointTypeParam, btTemplateParam, robotIDParam},
+			Response: btPreviewResponse{}, Errors: []int{400, 404, 409, 500}},
+		{Method: "POST", Path: "/api/nav/go", Handler: hf(h.GoAllPoints), Tag: "navigation",
+			Summary:  "Visit every point of a collection; refused (409) when empty, or when it has unsynced changes or the robot pose is stale or far from the first point",
+			Params:   []Param{pointTypeParam, param("force", "boolean", "true skips the sync, pose and distance checks")},
+			Response: statusResponse{}, Errors: []int{400, 409, 500, 501}},
+		{Method: "POST", Path: "/api/nav/patrol/start", Handler: hf(h.PatrolStart), Tag: "navigation",
+			Summary: "Loop the patrol points until stopped or a lap/time limit is hit; events arrive as patrol WS messages",
+			Params:  []Param{robotIDParam}, Body: robot.PatrolRequest{},
+			Response: robot.PatrolStatus{}, Errors: []int{400, 404, 409, 501}},
+		{Method: "POST", Path: "/api/nav/patrol/stop", Handler: hf(h.PatrolStop), Tag: "navigation",
+			Summary: "Stop the patrol and cancel active navigation", Params: []Param{robotIDParam},
+			Response: patrolStopResponse{}, Errors: []int{404, 500}},
+		{Method: "POST", Path: "/api/nav/clear", Handler: hf(h.ClearNavigationPoints), Tag: "navigation",
+			Summary:  "Clear a collection; confirmed with a token, as /api/robots/poweroff",
+			Params:   []Param{wallTypeParam, confirmTokenParam},
+			Response: statusResponse{}, Errors: []int{400, 409}},
+		{Method: "POST", Path: "/api/nav/fetch", Handler: hf(h.RequestNavPointsFromRobot), Tag: "navigation",
+			Summary: "Request a collection from the robot", Params: []Param{pointTypeParam},
+			Response: statusResponse{}, Errors: []int{400, 500, 501}},
+		{Method: "POST", Path: "/api/nav/import", Handler: hf(h.ImportNavPoints), Tag: "navigation",
+			Summary: "Import points from JSON (replaces), CSV or YAML (appends)",
+			Params: []Param{
+				param("format", "string", "json, csv or yaml (default: sniffed)"),
+				param("type", "string", "Point type for CSV/YAML rows without one"),
+			},
+			Body: navImportRequest{}, RawBody: []string{"text/csv", "application/yaml"},
+			Response: navImportResponse{}, Errors: []int{400, 409, 413}},
+		{Method: "GET", Path: "/api/nav/visits", Handler: hf(h.NavVisits), Tag: "navigation",
+			Summary: "Visits to the robot's points, newest first, with per-point counts (in range and today), all-time totals and last visit; each new visit is broadcast as point_visited",
+			Params: []Param{robotIDParam,
+				param("since", "integer", "Unix milliseconds; only later visits"),
+				param("until", "integer", "Unix milliseconds; only visits up to this time"),
+				param("type", "string", "Point type"),
+				param("name", "string", "Point name"),
+				param("limit", "integer", "Newest visits returned (aggregates still count all); default all"),
+			},
+			Response: robot.VisitReport{}, Errors: []int{400, 404}},
+		{Method: "GET", Path: "/api/nav/conflicts", Handler: hf(h.NavConflicts), Tag: "navigation",
+			Summary:  "Names used by more than one point type",
+			Response: navConflictsResponse{}, Errors: []int{400}},
+		{Method: "GET", Path: "/api/nav/persistence_status", Handler: hf(h.NavPersistenceStatus), Tag: "navigation",
+			Summary:  "Each robot's point file: path, last write, last write error, changes waiting to be saved",
+			Response: persistenceStatusResponse{}},
+		{Method: "DELETE", Path: "/api/nav/delete", Handler: hf(h.DeleteNavPoint), Tag: "navigation",
+			Summary:  "Delete a point by name",
+			Params:   []Param{pointTypeParam, required("name", "string", "")},
+			Response: statusResponse{}, Errors: []int{400}},
+		{Method: "POST", Path: "/api/nav/undo", Handler: hf(h.UndoNavEdit), Tag: "navigation",
+			Summary:  "Revert the most recent point edit (add, delete, clear, import); 409 when there is none",
+			Response: navUndoResponse{}, Errors: []int{400, 409}},
+		{Method: "POST", Path: "/api/nav/redo", Handler: hf(h.RedoNavEdit), Tag: "navigation",
+			Summary:  "Reapply the most recently undone point edit; 409 when there is none",
+			Response: navUndoResponse{}, Errors: []int{400, 409}},
+	}
+}
+
+// settingsTemplateRoutes returns settings templates shared by robots.
+func (s *Server) settingsTemplateRoutes() []Route {
+	nameParam := required("name", "string", "Template name")
+	return []Route{
+		{Method: "GET", Path: "/api/settings_templates", Handler: hf(s.ListSettingsTemplates), Tag: "settings_templates",
+			Summary:  "Settings templates, and per robot the template it was given or matches and the fields changed since",
+			Response: settingsTemplatesResponse{}, Errors: []int{503}},
+		{Method: "POST", Path: "/api/settings_templates", Handler: hf(s.AddSettingsTemplate), Tag: "settings_templates",
+			Summary: "Add a settings template; fields left out are not touched when it is applied",
+			Body:    robot.SettingsTemplate{}, Response: settingsTemplateResponse{}, Errors: []int{400, 409, 500, 503}},
+		{Method: "PUT", Path: "/api/settings_templates", Handler: hf(s.UpdateSettingsTemplate), Tag: "settings_templates",
+			Summary: "Replace a settings template; a different name in the body renames it",
+			Params:  []Param{nameParam}, Body: robot.SettingsTemplate{},
+			Response: settingsTemplateResponse{}, Errors: []int{400, 404, 409, 500, 503}},
+		{Method: "DELETE", Path: "/api/settings_templates", Handler: hf(s.DeleteSettingsTemplate), Tag: "settings_templates",
+			Summary: "Remove a settings template", Params: []Param{nameParam},
+			Response: statusResponse{}, Errors: []int{404, 500, 503}},
+		{Method: "POST", Path: "/api/settings_templates/apply", Handler: hf(s.ApplySettingsTemplate), Tag: "settings_templates",
+			Summary: "Apply a template to robots ({template, ids} or {template, all: true}); the settings save is sent, queued offline or skipped per robot",
+			Body:    applyTemplateRequest{}, Response: applyTemplateResponse{}, Errors: []int{400, 503}},
+	}
+}
+
+// webhookRoutes returns webhook subscriptions.
+func (s *Server) webhookRoutes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/api/webhooks", Handler: hf(s.ListWebhooks), Tag: "webhooks",
+			Summary: "Webhook endpoints (secrets omitted) and the event types they can receive", Response: webhooksResponse{}, Errors: []int{503}},
+		{Method: "POST", Path: "/api/webhooks", Handler: hf(s.AddWebhook), Tag: "webhooks",
+			Summary: "Register a webhook endpoint",
+			Params: []Param{
+				required("url", "string", "http(s) URL receiving event POSTs"),
+				param("secret", "string", "Key for the X-Webhook-Signature HMAC-SHA256 of the body"),
+				param("events", "string", "Comma-separated event types (default: all)"),
+				param("enabled", "boolean", "Default on"),
+			},
+			Response: webhookResponse{}, Errors: []int{400, 503}},
+		{Method: "PUT", Path: "/api/webhooks", Handler: hf(s.UpdateWebhook), Tag: "webhooks",
+			Summary: "Change a webhook endpoint; unset parameters are left unchanged",
+			Params: []Param{
+				required("id", "string", "Webhook ID"),
+				param("url", "string", ""),
+				param("secret", "string", "Empty removes the signature"),
+				param("events", "string", "Comma-separated event types; empty receives all"),
+				param("enabled", "boolean", ""),
+			},
+			Response: webhookResponse{}, Errors: []int{400, 404, 503}},
+		{Method: "DELETE", Path: "/api/webhooks", Handler: hf(s.RemoveWebhook), Tag: "webhooks",
+			Summary:  "Remove a webhook endpoint",
+			Params:   []Param{required("id", "string", "Webhook ID")},
+			Response: statusResponse{}, Errors: []int{400, 404, 500, 503}},
+		{Method: "GET", Path: "/api/webhooks/deliveries", Handler: hf(s.WebhookDeliveries), Tag: "webhooks",
+			Summary:  "Recent delivery attempts, newest first, with status codes and errors",
+			Params:   []Param{param("id", "string", "Webhook ID (default: all)")},
+			Response: webhookDeliveriesResponse{}, Errors: []int{404, 503}},
+		{Method: "POST", Path: "/api/webhooks/test", Handler: hf(s.TestWebhook), Tag: "webhooks",
+			Summary:  "Post a test event to an endpoint once and report the attempt",
+			Params:   []Param{required("id", "string", "Webhook ID")},
+			Response: webhookTestResponse{}, Errors: []int{404, 503}},
+	}
+}
+
+// Routes returns speech transcription.
+func (h *SpeechHandlers) Routes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/api/speech/status", Handler: hf(h.SpeechStatus), Tag: "speech", Feature: FeatureSpeech,
+			Summary: "Active speech backend (SPEECH_BACKEND) and whether it is usable; the HTTP service is pinged", Response: speechStatusResponse{}},
+		{Method: "POST", Path: "/api/speech/transcribe", Handler: hf(h.SpeechTranscribe), Tag: "speech", Feature: FeatureSpeech,
+			Summary: "Transcribe audio and send it to the robot as a voice command unless confidence is below WHISPER_MIN_CONFIDENCE",
+			Params:  []Param{param("language", "string", "ISO 639-1 code of the spoken language (default: the backend's)")},
+			Upload:  "audio", Response: transcribeResponse{}, Errors: []int{400, 413, 415, 500, 502, 503}},
+		{Method: "GET", Path: "/api/speech/phrases", Handler: hf(h.SpeechPhrases), Tag: "speech", Feature: FeatureSpeech,
+			Summary: "Phrase table mapping localized transcripts to voice commands; with text, how it would be mapped",
+			Params: []Param{
+				param("text", "string", "Transcript to map as a preview"),
+				param("language", "string", "ISO 639-1 code of the text (default: phrases of any language)"),
+			},
+			Response: speechPhrasesResponse{}, Errors: []int{503}},
+		{Method: "POST", Path: "/api/speech/phrases", Handler: hf(h.SetSpeechPhrases), Tag: "speech", Feature: FeatureSpeech,
+			Summary: "Replace the phrase table (JSON {phrases: [{phrase, command, lang}]}); used from the next transcript",
+			Body:    phrases.Table{}, Response: speechPhrasesResponse{}, Errors: []int{400, 500, 503}},
+	}
+}
+
+// uiRoutes returns the HTMX fragments.
+func (s *Server) uiRoutes() []Route {
+	return []Route{
+		// HTMX partials & dialog fragments
+		{Method: "GET", Path: "/partial/robots", Handler: hf(s.RobotListPartial), Tag: "ui", Summary: "Robot list fragment", Produces: "text/html"},
+		{Method: "GET", Path: "/partial/settings", Handler: hf(s.SettingsPartial), Tag: "ui", Summary: "Settings panel fragment", Produces: "text/html"},
+		{Method: "GET", Path: "/partial/status", Handler: hf(s.StatusPartial), Tag: "ui", Summary: "Live diagnostics fragment (polled every 2 s)",
+			Params: []Param{robotIDParam, param("units", "string", "metric or imperial (default: units cookie)")}, Produces: "text/html"},
+		{Method: "GET", Path: "/partial/nav_points", Handler: hf(s.NavPointsPartial), Tag: "ui", Summary: "Navigation points fragment", Produces: "text/html"},
+		{Method: "GET", Path: "/dialog/add_robot", Handler: hf(s.AddRobotDialog), Tag: "ui", Summary: "Add-robot dialog", Produces: "text/html"},
+		{Method: "GET", Path: "/dialog/save_map", Handler: hf(s.SaveMapDialog), Tag: "ui", Summary: "Save-map dialog", Produces: "text/html"},
+		{Method: "GET", Path: "/dialog/open_map", Handler: hf(s.OpenMapDialog), Tag: "ui", Summary: "Open-map dialog", Produces: "text/html"},
+		{Method: "GET", Path: "/dialog/confirm", Handler: hf(s.ConfirmDialog), Tag: "ui", Summary: "Confirmation dialog of a named action",
+			Params: []Param{
+				param("action", "string", "remove_robot, mapping_abort, reset_stats, reset_odom or config_reload"),
+				robotIDParam,
+				param("note", "string", "Shown under the action's message (up to 300 characters)"),
+			},
+			Produces: "text/html", Errors: []int{400, 404}},
+		{Method: "GET", Path: "/dialog/add_nav_point", Handler: hf(s.AddNavPointDialog), Tag: "ui", Summary: "Add-navigation-point dialog",
+			Params: []Param{param("type", "string", "Point type (default waypoint)")}, Produces: "text/html"},
+	}
+}
+
+// Routes returns the WebSocket.
+func (h *WSHandlers) Routes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/ws", Handler: hf(h.WSHandler), Tag: "ui",
+			Summary: "WebSocket upgrade for live robot data", Status: http.StatusSwitchingProtocols},
+	}
+}
+
+// ──────────────────── Documented bodies ────────────────────
+//
+// JSON shapes of handlers that answer with map literals. They exist for
+// the OpenAPI document and must match the handlers.
+
+// errorResponse is the shape of every error; see validation.go.
+type errorResponse struct {
+	Error  string            `json:"error"`
+	Code   string            `json:"code"`             // invalid_params, or the status text in snake_case
+	Fields map[string]string `json:"fields,omitempty"` // invalid_params: what is wrong with each parameter
+}
+
+type scanMaskResponse struct {
+	ScanMask robot.ScanMask       `json:"scan_mask"`
+	Raw      *rosbridge.LaserData `json:"raw,omitempty"` // with raw=1
+}
+
+type homeResponse struct {
+	Home *robot.HomePose `json:"home"` // null when unset
+}
+
+type goHomeResponse struct {
+	Status string         `json:"status"`
+	Home   robot.HomePose `json:"home"`
+}
+
+type resetOdomResponse struct {
+	Status string          `json:"status"` // reset
+	Reset  robot.PoseReset `json:"reset"`
+}
+
+type relocalizeResponse struct {
+	Status    string  `json:"status"` // started
+	ID        string  `json:"id"`
+	RotateSec float64 `json:"rotate_s"` // 0 without rotation
+}
+
+type destructivePendingResponse struct {
+	Status string `json:"status"` // confirm_required
+	robot.PendingAction
+	ExpiresInMs int64 `json:"expires_in_ms"`
+}
+
+type destructiveCancelResponse struct {
+	Status string `json:"status"` // cancelled
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+}
+
+type resetUsageResponse struct {
+	Status string           `json:"status"` // reset
+	Reset  robot.UsageReset `json:"reset"`
+}
+
+type errorsResponse struct {
+	Errors []robot.Notice `json:"errors"`
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+type settingsResponse struct {
+	Status  string                `json:"status"`
+	Pending *robot.PendingCommand `json:"pending,omitempty"` // settings save queued while disconnected
+}
+
+type pendingResponse struct {
+	Options robot.OfflineQueueOptions `json:"options"`
+	Pending []robot.PendingCommand    `json:"pending"`
+}
+
+type cancelPendingResponse struct {
+	Cancelled int `json:"cancelled"`
+}
+
+// flushPendingResponse lists the outcomes of the commands run (expired
+// ones included) and what is still queued.
+type flushPendingResponse struct {
+	Results []robot.PendingCommand `json:"results"`
+	Pending []robot.PendingCommand `json:"pending"`
+}
+
+// unsupportedResponse is the 501 answer to a request the robot's
+// reported capabilities rule out.
+// powerResponse is an acknowledged poweroff or reboot.
+type powerResponse struct {
+	Status string            `json:"status"` // power_off_sent or reboot_sent
+	Power  robot.PowerStatus `json:"power"`
+}
+
+// refusedResponse is a task the robot refused; status is its answer's.
+type refusedResponse struct {
+	Error  string `json:"error"`
+	Code   string `json:"code"` // robot_refused
+	Task   string `json:"task"`
+	Status int    `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type unsupportedResponse struct {
+	Error      string `json:"error"`
+	Code       string `json:"code"`
+	Capability string `json:"capability"`
+}
+
+// navSendResponse is a point upload's outcome; status is sent, partial,
+// unverified or queued.
+type navSendResponse struct {
+	Status string              `json:"status"`
+	Type   rosbridge.PointType `json:"type"`
+	rosbridge.NavAck
+	Pending *robot.PendingCommand `json:"pending,omitempty"` // status queued
+}
+
+// btPreviewResponse is a previewed behavior tree and the templates the
+// robot offers.
+type btPreviewResponse struct {
+	Type      rosbridge.PointType `json:"type"`
+	Template  string              `json:"template"` // empty: the robot's default
+	Templates []string            `json:"templates"`
+	rosbridge.BTPreview
+}
+
+type healthzResponse struct {
+	Status        string       `json:"status"`
+	Build         version.Info `json:"build"`
+	UptimeSeconds int          `json:"uptime_seconds"`
+}
+
+type readyzResponse struct {
+	Status string                `json:"status"`
+	Checks map[string]readyCheck `json:"checks"`
+}
+
+type addRobotResponse struct {
+	ID           string             `json:"id"`
+	Name         string             `json:"name"`
+	IP           string             `json:"ip"`
+	ConnectPhase robot.ConnectPhase `json:"connect_phase"` // connecting; later phases are broadcast
+}
+
+type removeRobotResponse struct {
+	Status    string `json:"status"`
+	CurrentID string `json:"current_id"` // "" when no robots are left
+}
+
+type importRobotResponse struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Created bool     `json:"created"`
+	Skipped []string `json:"skipped"`
+}
+
+type switchResponse struct {
+	Status string `json:"status"`
+	ID     string `json:"id"`
+}
+
+type discoverResponse struct {
+	Scan       *discovery.ScanResult `json:"scan"`
+	MDNS       []discovery.Candidate `json:"mdns"`
+	Registered []string              `json:"registered,omitempty"`
+}
+
+// taskResponse carries result for synchronous tasks, task_id and
+// position for async=1.
+type taskResponse struct {
+	Result   *rosbridge.WhichTaskResponse `json:"result,omitempty"`
+	TaskID   string                       `json:"task_id,omitempty"`
+	Position int                          `json:"position,omitempty"`
+}
+
+type taskStatusResponse struct {
+	Task     robot.Task `json:"task"`
+	Position int        `json:"position"`
+}
+
+type moveResponse struct {
+	MoveID string `json:"move_id"`
+}
+
+type cancelMoveResponse struct {
+	Cancelled bool `json:"cancelled"`
+}
+
+type estopResponse struct {
+	Engaged bool `json:"engaged"`
+}
+
+type controlReleaseResponse struct {
+	Released bool               `json:"released"`
+	Control  robot.ControlLease `json:"control"`
+}
+
+type mapsResponse struct {
+	Maps    []string              `json:"maps"`
+	Pending *robot.PendingCommand `json:"pending,omitempty"` // refresh queued while disconnected
+}
+
+type mapNameRequest struct {
+	Name string `json:"name"`
+}
+
+type mapResponse struct {
+	Status string `json:"status"`
+	Map    string `json:"map"`
+}
+
+type mapSaveResponse struct {
+	Status string `json:"status"` // "saving"
+	Map    string `json:"map"`
+	Op     string `json:"op"` // for /api/maps/save_status
+}
+
+type mapTransformResponse struct {
+	From  string `json:"from"` // world, image or cell: what was given
+	World struct {
+		X        float64 `json:"x"`
+		Y        float64 `json:"y"`
+		ThetaRad float64 `json:"theta"`
+	} `json:"world"`
+	Image struct {
+		X        float64 `json:"px"`
+		Y        float64 `json:"py"`
+		ThetaDeg float64 `json:"image_theta_deg"`
+	} `json:"image"`
+	Cell struct {
+		Col      int    `json:"col"`
+		Row      int    `json:"row"`
+		InBounds bool   `json:"in_bounds"`
+		Value    *int8  `json:"value,omitempty"` // occupancy, when in bounds
+		Class    string `json:"class,omitempty"` // free, occupied or unknown by the render hints
+	} `json:"cell"`
+	CellCenter struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	} `json:"cell_center"` // world position of the cell's centre
+	Map         robot.MapTransform   `json:"map"`
+	MapSeq      uint64               `json:"map_seq"`
+	Conventions robot.MapConventions `json:"conventions"`
+}
+
+type renderHintsResponse struct {
+	RenderHints robot.MapRenderHints `json:"render_hints"`
+	Palettes    []string             `json:"palettes"`
+}
+
+type modeResponse struct {
+	Status string `json:"status"`
+	Mode   string `json:"mode"`
+}
+
+type bulkPointsRequest struct {
+	Type   string                      `json:"type"`
+	Points []rosbridge.NavigationPoint `json:"points"`
+}
+
+type bulkPointsResponse struct {
+	Status  string             `json:"status"`
+	Added   int                `json:"added"`
+	Skipped []robot.PointError `json:"skipped"`
+}
+
+type addHereResponse struct {
+	Status string  `json:"status"`
+	Type   string  `json:"type"`
+	Name   string  `json:"name"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Theta  float64 `json:"theta"`
+	Source string  `json:"source"`
+}
+
+// navPointsResponse is the /api/nav/list answer without a type; with a
+// type only the matching array is returned, its sync state in headers.
+type navPointsResponse struct {
+	Waypoints     []visitedPoint           `json:"waypoints"`
+	ServicePoints []visitedPoint           `json:"service_points"`
+	PatrolPoints  []visitedPoint           `json:"patrol_points"`
+	PathPoints    []visitedPoint           `json:"path_points"`
+	WallObstacles []rosbridge.WallObstacle `json:"wall_obstacles"`
+	// Sync says, per point type, whether the robot has the current
+	// collection.
+	Sync map[rosbridge.PointType]robot.NavSyncStatus `json:"sync"`
+}
+
+// navUndoResponse is the /api/nav/undo and /api/nav/redo answer: the
+// edit reverted or reapplied, and what undo and redo would do next.
+type navUndoResponse struct {
+	Status string `json:"status"`
+	Edit   string `json:"edit"`
+	robot.NavUndoState
+}
+
+type persistenceStatusResponse struct {
+	Enabled bool                  `json:"enabled"`
+	Dir     string                `json:"dir,omitempty"`
+	DelayMs int64                 `json:"delay_ms,omitempty"`
+	Robots  []robot.PersistStatus `json:"robots"`
+}
+
+type patrolStopResponse struct {
+	Stopped             bool                `json:"stopped"`
+	NavigationCancelled bool                `json:"navigation_cancelled"`
+	Patrol              *robot.PatrolStatus `json:"patrol,omitempty"`
+}
+
+type templatesResponse struct {
+	Files  []TemplateFile `json:"files"`
+	Failed []string       `json:"failed,omitempty"`
+}
+
+type chaosResponse struct {
+	Enabled bool                       `json:"enabled"` // DEBUG_CHAOS; settings can only be changed when set
+	Browser rosbridge.Chaos            `json:"browser"`
+	Robots  map[string]rosbridge.Chaos `json:"robots"` // by robot ID
+}
+
+type assignFloorRequest struct {
+	Map   string `json:"map"`
+	Floor string `json:"floor"`
+}
+
+type floorsResponse struct {
+	ActiveFloor string        `json:"active_floor"`
+	CurrentMap  string        `json:"current_map"`
+	Floors      []robot.Floor `json:"floors"`
+}
+
+type mapArchiveResponse struct {
+	RobotID  string                `json:"robot_id"`
+	Versions []robot.MapVersion    `json:"versions"`
+	Usage    robot.MapArchiveUsage `json:"usage"`
+}
+
+type mapArchiveGridResponse struct {
+	Version robot.MapVersion `json:"version"`
+	Map     interface{}      `json:"map"` // robot.MapFrame or EncodedMapFrame
+}
+
+type mapHistoryResponse struct {
+	CurrentMap string           `json:"current_map"`
+	History    []robot.MapEvent `json:"history"`
+}
+
+type navConflictsResponse struct {
+	EnforceGlobalUniqueNames bool                 `json:"enforce_global_unique_names"`
+	Conflicts                []robot.NameConflict `json:"conflicts"`
+}
+
+type navImportRequest struct {
+	Type   string                      `json:"type"`
+	Points []rosbridge.NavigationPoint `json:"points"`
+	Walls  []rosbridge.WallObstacle    `json:"walls,omitempty"`
+}
+
+type navImportResponse struct {
+	Status   string             `json:"status"`
+	Format   string             `json:"format,omitempty"`
+	Imported int                `json:"imported,omitempty"`
+	Skipped  []importer.Skipped `json:"skipped,omitempty"`
+}
+
+type settingsTemplatesResponse struct {
+	Templates []robot.SettingsTemplate `json:"templates"`
+	Robots    []robotTemplateStatus    `json:"robots"`
+}
+
+type robotTemplateStatus struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	robot.TemplateStatus
+}
+
+type settingsTemplateResponse struct {
+	Template robot.SettingsTemplate `json:"template"`
+}
+
+type applyTemplateRequest struct {
+	Template string   `json:"template"`
+	IDs      []string `json:"ids,omitempty"`
+	All      bool     `json:"all,omitempty"` // every robot
+}
+
+type applyTemplateResponse struct {
+	Template string           `json:"template"`
+	Results  []templateResult `json:"results"`
+}
+
+// templateResult is a robot's outcome of a template application.
+type templateResult struct {
+	ID      string                `json:"id"`
+	Name    string                `json:"name,omitempty"`
+	Status  string                `json:"status"` // applied, queued, skipped or failed
+	Error   string                `json:"error,omitempty"`
+	Pending *robot.PendingCommand `json:"pending,omitempty"` // queued settings save
+}
+
+type webhooksResponse struct {
+	Webhooks []webhook.Endpoint `json:"webhooks"`
+	Events   []string           `json:"events"`
+}
+
+type webhookResponse struct {
+	Webhook webhook.Endpoint `json:"webhook"`
+}
+
+type webhookDeliveriesResponse struct {
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+type webhookTestResponse struct {
+	Delivery webhook.Delivery `json:"delivery"`
+}
+
+type speechStatusResponse struct {
+	Available bool   `json:"available"`
+	Backend   string `json:"backend"`   // whisper_cli or http
+	Reachable bool   `json:"reachable"` // binary and model found, or the service answered
+	Error     string `json:"error,omitempty"`
+}
+
+type transcribeResponse struct {
+	Text       string  `json:"text"`
+	Status     string  `json:"status"` // ok or low_confidence (not sent to the robot)
+	Confidence float64 `json:"confidence"`
+	RawText    string  `json:"raw_text,omitempty"` // rejected text, for display
+	// Command is what was sent to the robot: Text with its phrases
+	// replaced by their commands, or Text itself when none matched.
+	Command string          `json:"command,omitempty"`
+	Mapping *phrases.Result `json:"mapping,omitempty"`
+}
+
+type speechPhrasesResponse struct {
+	phrases.Snapshot
+	Preview *phrases.Result `json:"preview,omitempty"` // mapping of the text parameter
+}
+
+type subscriptionsResponse struct {
+	Connected     bool                     `json:"connected"`
+	Split         bool                     `json:"split"` // subscriptions use the data-plane connection
+	Subscriptions []rosbridge.Subscription `json:"subscriptions"`
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// handlerName names a route's handler: the method a handler func was
+// made from, without its receiver, or the handler's type.
+func handlerName(h http.Handler) string {
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return fmt.Sprintf("%T", h)
+	}
+	name := runtime.FuncForPC(v.Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndex(name, ")."); i >= 0 {
+		name = name[i+2:]
+	}
+	return strings.TrimPrefix(name, "rom_go_app/handlers.")
+}
+
+// dumpRoutes writes one line per route with everything that decides how
+// it is served and documented: the handler as declared and the one
+// served, when it is wrapped.
+func dumpRoutes(declared, routes []Route) string {
+	var b strings.Builder
+	for i, rt := range routes {
+		fmt.Fprintf(&b, "%s %s -> %s", rt.Method, rt.Path, handlerName(declared[i].Handler))
+		if served := handlerName(rt.Handler); served != handlerName(declared[i].Handler) {
+			fmt.Fprintf(&b, " via %s", served)
+		}
+		fmt.Fprintf(&b, " [%s]", rt.Tag)
+		if rt.Feature != "" {
+			fmt.Fprintf(&b, " feature=%s", rt.Feature)
+		}
+		for _, p := range rt.Params {
+			req := ""
+			if p.Required {
+				req = "!"
+			}
+			fmt.Fprintf(&b, " %s:%s%s", p.Name, p.Type, req)
+		}
+		if rt.Body != nil {
+			fmt.Fprintf(&b, " body=%T", rt.Body)
+		}
+		if len(rt.RawBody) > 0 {
+			fmt.Fprintf(&b, " raw=%s", strings.Join(rt.RawBody, ","))
+		}
+		if rt.Upload != "" {
+			fmt.Fprintf(&b, " upload=%s", rt.Upload)
+		}
+		if rt.Response != nil {
+			fmt.Fprintf(&b, " response=%T", rt.Response)
+		}
+		if rt.Produces != "" {
+			fmt.Fprintf(&b, " produces=%s", rt.Produces)
+		}
+		if rt.Status != 0 {
+			fmt.Fprintf(&b, " status=%d", rt.Status)
+		}
+		if len(rt.Errors) > 0 {
+			fmt.Fprintf(&b, " errors=%v", rt.Errors)
+		}
+		fmt.Fprintf(&b, "  # %s\n", rt.Summary)
+	}
+	return b.String()
+}
+
+// TestRouteTableGolden locks the route table: paths, methods, handlers,
+// parameters and documented shapes. Run with -update after an intended
+// change and review the diff.
+func TestRouteTableGolden(t *testing.T) {
+	for _, noUI := range []bool{false, true} {
+		s := newTestServer(t)
+		s.NoUI = noUI
+		declared, routes := s.routeTable(), s.Routes()
+		if len(declared) != len(routes) {
+			t.Fatalf("%d routes declared, %d served", len(declared), len(routes))
+		}
+		got := dumpRoutes(declared, routes)
+
+		path := filepath.Join("testdata", "routes.golden")
+		if noUI {
+			path = filepath.Join("testdata", "routes_noui.golden")
+		}
+		if *updateGolden {
+			if err := os.MkdirAll("testdata", 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%v (run go test -update to create it)", err)
+		}
+		if got != string(want) {
+			gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+			for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+				var g, w string
+				if i < len(gotLines) {
+					g = gotLines[i]
+				}
+				if i < len(wantLines) {
+					w = wantLines[i]
+				}
+				if g != w {
+					t.Errorf("%s line %d:\n got: %s\nwant: %s", path, i+1, g, w)
+					break
+				}
+			}
+		}
+	}
+}
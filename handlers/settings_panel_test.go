@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"rom_go_app/robot"
+)
+
+func renderSettingsPanel(t *testing.T, s *Server, data interface{}) string {
+	t.Helper()
+	var b strings.Builder
+	if err := s.Templates.Execute(&b, "settings_panel.html", data); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestSettingsPanelConnectionOptions(t *testing.T) {
+	s := newTestServer(t)
+	rb, err := s.Manager.AddRobot("", "test", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+
+	snap := rb.GetSnapshot()
+	snap.TopicThrottles = nil // every throttle off
+	snap.UseCBOR = true
+	snap.SharedConnection = true
+	html := renderSettingsPanel(t, s, settingsView{Snapshot: snap})
+	for _, want := range []string{
+		`id="setting-throttle-map"`,
+		`id="setting-cbor" checked`,
+		`id="setting-split" >`,
+		`id="setting-shared" checked`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("panel lacks %s", want)
+		}
+	}
+
+	if html := renderSettingsPanel(t, s, settingsView{}); strings.Contains(html, "setting-cbor") {
+		t.Error("connection options shown without a robot")
+	}
+	if html := renderSettingsPanel(t, s, nil); !strings.Contains(html, "No robot selected") {
+		t.Errorf("nil view rendered %q", html)
+	}
+}
+
+func TestSettingsRenderHints(t *testing.T) {
+	s, rb := newClearRobot(t)
+	h := s.robotHandlers()
+
+	rec := postForm(h.UpdateSettings, "/api/robots/settings", url.Values{"id": {rb.ID}, "invert": {"1"}, "palette": {"grayscale"}}, false)
+	if rec.Code != http.StatusOK || !rb.GetRenderHints().Invert {
+		t.Fatalf("invert on: %d %s, %+v", rec.Code, rec.Body.String(), rb.GetRenderHints())
+	}
+	html := renderSettingsPanel(t, s, settingsView{Snapshot: rb.GetSnapshot(), RenderHints: rb.GetRenderHints(), Palettes: robot.MapPalettes})
+	for _, want := range []string{`id="setting-invert" checked`, `<option value="grayscale" selected>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("panel lacks %s", want)
+		}
+	}
+
+	// An unchecked box is sent as invert=0
+	rec = postForm(h.UpdateSettings, "/api/robots/settings", url.Values{"id": {rb.ID}, "invert": {"0"}}, false)
+	if rec.Code != http.StatusOK || rb.GetRenderHints().Invert {
+		t.Errorf("invert off: %d %s, %+v", rec.Code, rec.Body.String(), rb.GetRenderHints())
+	}
+
+	for _, name := range []string{"occupied_threshold", "free_threshold"} {
+		rec := postForm(h.UpdateSettings, "/api/robots/settings", url.Values{"id": {rb.ID}, name: {"high"}}, false)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), name) {
+			t.Errorf("%s=high: %d %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	if got := rb.GetRenderHints(); got.OccupiedThreshold != 65 || got.FreeThreshold != 25 {
+		t.Errorf("thresholds changed by bad values: %+v", got)
+	}
+}
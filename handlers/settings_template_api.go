@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Settings templates ────────────────────
+
+// templatesEnabled answers 503 when the app runs without settings
+// templates.
+func (s *Server) templatesEnabled(w http.ResponseWriter) bool {
+	if s.SettingsTemplates == nil {
+		jsonError(w, "settings templates disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// ListSettingsTemplates handles GET /api/settings_templates
+//
+// Lists the templates and, per robot, the template its settings follow.
+func (s *Server) ListSettingsTemplates(w http.ResponseWriter, r *http.Request) {
+	if !s.templatesEnabled(w) {
+		return
+	}
+	resp := settingsTemplatesResponse{Templates: s.SettingsTemplates.List(), Robots: []robotTemplateStatus{}}
+	for _, rb := range s.Manager.GetAllRobots() {
+		resp.Robots = append(resp.Robots, robotTemplateStatus{ID: rb.ID, Name: rb.Name, TemplateStatus: s.SettingsTemplates.Status(rb)})
+	}
+	sort.Slice(resp.Robots, func(i, j int) bool { return resp.Robots[i].ID < resp.Robots[j].ID })
+	jsonOK(w, resp)
+}
+
+// AddSettingsTemplate handles POST /api/settings_templates
+//
+// Body is the template; unset fields are left alone when it is applied.
+func (s *Server) AddSettingsTemplate(w http.ResponseWriter, r *http.Request) {
+	if !s.templatesEnabled(w) {
+		return
+	}
+	t, ok := decodeTemplate(w, r)
+	if !ok {
+		return
+	}
+	if err := t.Validate(); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := s.SettingsTemplates.Add(t)
+	if !templateStoreError(w, err) {
+		return
+	}
+	log.Printf("[settings] Template %q added", t.Name)
+	jsonOK(w, settingsTemplateResponse{Template: t})
+}
+
+// UpdateSettingsTemplate handles PUT /api/settings_templates?name=X
+//
+// Body replaces the template; its name may differ to rename it.
+func (s *Server) UpdateSettingsTemplate(w http.ResponseWriter, r *http.Request) {
+	if !s.templatesEnabled(w) {
+		return
+	}
+	name := r.URL.Query().Get("name")
+	t, ok := decodeTemplate(w, r)
+	if !ok {
+		return
+	}
+	if t.Name == "" {
+		t.Name = name
+	}
+	if err := t.Validate(); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := s.SettingsTemplates.Replace(name, t)
+	if !templateStoreError(w, err) {
+		return
+	}
+	log.Printf("[settings] Template %q updated", t.Name)
+	jsonOK(w, settingsTemplateResponse{Template: t})
+}
+
+// DeleteSettingsTemplate handles DELETE /api/settings_templates?name=X
+func (s *Server) DeleteSettingsTemplate(w http.ResponseWriter, r *http.Request) {
+	if !s.templatesEnabled(w) {
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if !templateStoreError(w, s.SettingsTemplates.Delete(name)) {
+		return
+	}
+	log.Printf("[settings] Template %q deleted", name)
+	jsonOK(w, statusResponse{Status: "deleted"})
+}
+
+// ApplySettingsTemplate handles POST /api/settings_templates/apply
+//
+// Body {template, ids} or {template, all: true}. Each robot gets the
+// template's fields through the same setters as POST /api/robots/settings,
+// and the settings save is sent to it when connected, queued on its
+// offline queue otherwise, or skipped when that is off. Robots are
+// handled in parallel; the response lists each robot's outcome.
+func (s *Server) ApplySettingsTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.templatesEnabled(w) {
+		return
+	}
+	var req applyTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	fe := fieldErrors{}
+	t, found := s.SettingsTemplates.Get(req.Template)
+	switch {
+	case req.Template == "":
+		fe.add("template", "required")
+	case !found:
+		fe.add("template", "no settings template "+strconv.Quote(req.Template))
+	}
+	switch {
+	case req.All && len(req.IDs) > 0:
+		fe.add("ids", "give ids or all, not both")
+	case !req.All && len(req.IDs) == 0:
+		fe.add("ids", "required unless all is true")
+	}
+	if len(fe) > 0 {
+		jsonFieldErrors(w, fe)
+		return
+	}
+
+	var robots []*robot.Robot
+	if req.All {
+		robots = s.Manager.GetAllRobots()
+		sort.Slice(robots, func(i, j int) bool { return robots[i].ID < robots[j].ID })
+	}
+	results := make([]templateResult, 0, len(req.IDs)+len(robots))
+	for _, id := range req.IDs {
+		rb := s.Manager.GetRobot(id)
+		if rb == nil {
+			results = append(results, templateResult{ID: id, Status: robot.TemplateFailed, Error: "robot not found"})
+			continue
+		}
+		robots = append(robots, rb)
+	}
+
+	client := clientAddr(r)
+	applied := make([]templateResult, len(robots))
+	var wg sync.WaitGroup
+	for i, rb := range robots {
+		wg.Add(1)
+		go func(i int, rb *robot.Robot) {
+			defer wg.Done()
+			res := applyTemplate(rb, t)
+			rb.RecordTemplateApplication(robot.TemplateApplication{Template: t.Name, Status: res.Status, Error: res.Error, Client: client})
+			s.Manager.Broadcast(robot.BroadcastMsg{Type: "settings_template", RobotID: rb.ID, Data: res})
+			applied[i] = res
+		}(i, rb)
+	}
+	wg.Wait()
+	results = append(results, applied...)
+
+	counts := map[string]int{}
+	for _, res := range results {
+		counts[res.Status]++
+	}
+	log.Printf("[settings] Template %q applied to %d robots by %s: %d applied, %d queued, %d skipped, %d failed",
+		t.Name, len(results), client, counts[robot.TemplateApplied], counts[robot.TemplateQueued],
+		counts[robot.TemplateSkipped], counts[robot.TemplateFailed])
+	jsonOK(w, applyTemplateResponse{Template: t.Name, Results: results})
+}
+
+// applyTemplate applies t to rb and pushes the resulting settings to the
+// robot.
+func applyTemplate(rb *robot.Robot, t robot.SettingsTemplate) templateResult {
+	res := templateResult{ID: rb.ID, Name: rb.Name}
+	if err := rb.ApplySettingsTemplate(t); err != nil {
+		res.Status, res.Error = robot.TemplateFailed, err.Error()
+		return res
+	}
+
+	args := settingsSaveArgs(rb.GetSettings())
+	if rb.IsConnected() && rb.Client != nil {
+		if _, err := rb.RequestSettingsSave(args); err != nil {
+			res.Status, res.Error = robot.TemplateFailed, "settings save: "+err.Error()
+			return res
+		}
+		res.Status = robot.TemplateApplied
+		return res
+	}
+	cmd, err := rb.QueueOffline(robot.PendingSettingsSave, "", "settings template "+t.Name, func() error {
+		_, err := rb.RequestSettingsSave(args)
+		return err
+	})
+	switch {
+	case errors.Is(err, robot.ErrOfflineQueueOff):
+		res.Status, res.Error = robot.TemplateSkipped, "not connected; settings save not sent"
+	case err != nil:
+		res.Status, res.Error = robot.TemplateFailed, err.Error()
+	default:
+		res.Status, res.Pending = robot.TemplateQueued, &cmd
+	}
+	return res
+}
+
+// decodeTemplate reads a template from the request body, answering 400
+// when it doesn't decode.
+func decodeTemplate(w http.ResponseWriter, r *http.Request) (robot.SettingsTemplate, bool) {
+	var t robot.SettingsTemplate
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		jsonError(w, "invalid template: "+err.Error(), http.StatusBadRequest)
+		return t, false
+	}
+	return t, true
+}
+
+// templateStoreError answers err of the template store: 404 for an
+// unknown name, 409 for a name in use or a full store, 500 when saving
+// failed. It reports whether err was nil.
+func templateStoreError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, robot.ErrTemplateNotFound):
+		jsonError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, robot.ErrTemplateExists), errors.Is(err, robot.ErrTooManyTemplates):
+		jsonError(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("[settings] Save templates: %v", err)
+		jsonError(w, "save failed: "+err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
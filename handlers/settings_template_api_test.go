@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rom_go_app/robot"
+)
+
+// TestApplySettingsTemplate applies a template to a connected robot, a
+// disconnected one with and without the offline queue, and an unknown
+// ID.
+func TestApplySettingsTemplate(t *testing.T) {
+	s := newTestServer(t)
+	st, err := robot.OpenSettingsTemplates(filepath.Join(t.TempDir(), "templates.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SettingsTemplates = st
+	radius := 0.45
+	if _, err := st.Add(robot.SettingsTemplate{Name: "tb3", Radius: &radius}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFakeRosbridge(t)
+	online := connectRobot(t, s, f)
+	skipped, _ := s.Manager.AddRobot("", "skipped", "127.0.0.1", 9)
+	queued, _ := s.Manager.AddRobot("", "queued", "127.0.0.1", 10)
+	opts := robot.DefaultOfflineQueue
+	opts.Enabled = true
+	if err := queued.SetOfflineQueue(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"template":"tb3","ids":["` + online.ID + `","` + skipped.ID + `","` + queued.ID + `","nope"]}`
+	rec := httptest.NewRecorder()
+	s.ApplySettingsTemplate(rec, httptest.NewRequest(http.MethodPost, "/api/settings_templates/apply", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+	var resp applyTemplateResponse
+	decodeJSON(t, rec, &resp)
+	status := map[string]templateResult{}
+	for _, res := range resp.Results {
+		status[res.ID] = res
+	}
+
+	if res := status[online.ID]; res.Status != robot.TemplateApplied {
+		t.Errorf("connected: %+v", res)
+	}
+	if calls := f.calls(); len(calls) == 0 || calls[len(calls)-1] != "/which_tasks" {
+		t.Errorf("settings save not sent: %v", calls)
+	}
+	if res := status[skipped.ID]; res.Status != robot.TemplateSkipped || res.Error == "" {
+		t.Errorf("disconnected without a queue: %+v", res)
+	}
+	if res := status[queued.ID]; res.Status != robot.TemplateQueued || res.Pending == nil {
+		t.Errorf("disconnected with a queue: %+v", res)
+	}
+	if n := len(queued.PendingCommands()); n != 1 {
+		t.Errorf("%d commands queued, want 1", n)
+	}
+	if res := status["nope"]; res.Status != robot.TemplateFailed {
+		t.Errorf("unknown robot: %+v", res)
+	}
+
+	// Every robot got the fields, saved or not
+	for _, rb := range []*robot.Robot{online, skipped, queued} {
+		if got := rb.GetSettings().Radius; got != radius {
+			t.Errorf("%s: radius %v", rb.Name, got)
+		}
+		if s := st.Status(rb); !s.Applied || !s.Matches {
+			t.Errorf("%s: status %+v", rb.Name, s)
+		}
+	}
+}
@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -17,140 +17,173 @@ import (
 type WhisperRunner struct {
 	BinPath   string
 	ModelPath string
-	LogDir    string
+
+	// ffmpeg deadline; zero means no limit.
+	FFmpegTimeout time.Duration
 }
 
 // NewWhisperRunner creates a WhisperRunner if paths exist.
-func NewWhisperRunner(binPath, modelPath, logDir string) *WhisperRunner {
+func NewWhisperRunner(binPath, modelPath string) *WhisperRunner {
 	return &WhisperRunner{
 		BinPath:   binPath,
 		ModelPath: modelPath,
-		LogDir:    logDir,
 	}
 }
 
 // Ready returns true if whisper binary and model exist.
 func (wr *WhisperRunner) Ready() bool {
+	return wr.Check(context.Background()) == nil
+}
+
+// Check reports a missing binary or model.
+func (wr *WhisperRunner) Check(ctx context.Context) error {
 	if wr == nil {
-		return false
+		return fmt.Errorf("whisper not configured")
 	}
 	if _, err := os.Stat(wr.BinPath); err != nil {
-		return false
+		return fmt.Errorf("whisper binary: %w", err)
 	}
 	if _, err := os.Stat(wr.ModelPath); err != nil {
-		return false
+		return fmt.Errorf("whisper model: %w", err)
 	}
-	return true
+	return nil
 }
 
-// Transcribe converts an audio file to text using whisper.cpp.
-func (wr *WhisperRunner) Transcribe(audioPath string) (string, error) {
+// Transcribe converts an audio file to text using whisper.cpp. The raw
+// JSON output is kept next to the audio (<name>.json) for review. Both
+// tools are killed when ctx ends; ffmpeg also after FFmpegTimeout.
+func (wr *WhisperRunner) Transcribe(ctx context.Context, audioPath, language string) (Transcript, error) {
 	if !wr.Ready() {
-		return "", fmt.Errorf("whisper not available")
+		return Transcript{}, fmt.Errorf("whisper not available")
 	}
 
-	// Convert to WAV 16kHz mono using ffmpeg
-	wavPath := strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + "_16k.wav"
-	ffmpegCmd := exec.Command("ffmpeg", "-y", "-i", audioPath, "-ar", "16000", "-ac", "1", "-f", "wav", wavPath)
-	if out, err := ffmpegCmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("ffmpeg failed: %w: %s", err, string(out))
+	wavPath, err := convertToWAV16k(ctx, audioPath, wr.FFmpegTimeout)
+	if err != nil {
+		return Transcript{}, err
 	}
 	defer os.Remove(wavPath)
 
-	// Run whisper.cpp
-	whisperCmd := exec.Command(wr.BinPath, "-m", wr.ModelPath, "-f", wavPath, "--no-timestamps", "-nt")
-	out, err := whisperCmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("whisper failed: %w: %s", err, string(out))
+	// Run whisper.cpp; -ojf adds per-token probabilities to the JSON
+	base := strings.TrimSuffix(audioPath, filepath.Ext(audioPath))
+	args := []string{"-m", wr.ModelPath, "-f", wavPath, "-np", "-oj", "-ojf", "-of", base}
+	if language != "" {
+		args = append(args, "-l", language)
+	}
+	whisperCmd := exec.CommandContext(ctx, wr.BinPath, args...)
+	if out, err := whisperCmd.CombinedOutput(); err != nil {
+		return Transcript{}, fmt.Errorf("whisper failed: %w: %s", err, string(out))
 	}
 
-	text := strings.TrimSpace(string(out))
-	return text, nil
+	raw, err := os.ReadFile(base + ".json")
+	if err != nil {
+		return Transcript{}, fmt.Errorf("whisper output missing: %w", err)
+	}
+	return ParseWhisperJSON(raw)
 }
 
 // ──────────────────────────── HTTP Handlers
 
-// SpeechStatus returns whether whisper is available.
-func (s *Server) SpeechStatus(w http.ResponseWriter, r *http.Request) {
+// SpeechHandlers serve /api/speech: transcription and the phrase table.
+type SpeechHandlers struct {
+	Robots  robotFinder         // the transcript goes to the current robot
+	Setup   func() *speechSetup // current speech settings (see speech_backend.go)
+	Phrases phraseTable         // nil: transcripts are sent as spoken
+}
+
+// SpeechStatus returns which speech backend is active and whether it
+// can be used: the whisper binary and model exist, or the speech service
+// answers within a few seconds.
+func (h *SpeechHandlers) SpeechStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	ready := s.Whisper != nil && s.Whisper.Ready()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"available": ready,
-	})
+	sp := h.Setup()
+	resp := speechStatusResponse{}
+	if sp != nil {
+		resp.Backend = sp.Backend
+	}
+	if !sp.Ready() {
+		resp.Error = sp.unavailable()
+		jsonOK(w, resp)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), speechCheckTimeout)
+	defer cancel()
+	if err := sp.Transcriber.Check(ctx); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Available = true
+		resp.Reachable = true
+	}
+	jsonOK(w, resp)
 }
 
 // SpeechTranscribe receives audio, transcribes it, and optionally sends as voice command.
-func (s *Server) SpeechTranscribe(w http.ResponseWriter, r *http.Request) {
+func (h *SpeechHandlers) SpeechTranscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if s.Whisper == nil || !s.Whisper.Ready() {
-		jsonError(w, "whisper not available", http.StatusServiceUnavailable)
+	sp := h.Setup()
+	if !sp.Ready() {
+		jsonError(w, sp.unavailable(), http.StatusServiceUnavailable)
 		return
 	}
 
-	// Parse multipart form (max 10 MB)
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		jsonError(w, "invalid form data: "+err.Error(), http.StatusBadRequest)
+	audioPath, err := sp.saveUpload(w, r)
+	switch {
+	case errors.Is(err, errUploadTooLarge):
+		jsonError(w, err.Error(), http.StatusRequestEntityTooLarge)
 		return
-	}
-
-	file, header, err := r.FormFile("audio")
-	if err != nil {
-		jsonError(w, "audio file required", http.StatusBadRequest)
+	case errors.Is(err, errNotAudio):
+		jsonError(w, err.Error(), http.StatusUnsupportedMediaType)
 		return
-	}
-	defer file.Close()
-
-	// Save uploaded audio to log directory
-	os.MkdirAll(s.Whisper.LogDir, 0755)
-	ts := time.Now().Format("20060102_150405")
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		ext = ".webm"
-	}
-	audioPath := filepath.Join(s.Whisper.LogDir, fmt.Sprintf("speech_%s%s", ts, ext))
-
-	dst, err := os.Create(audioPath)
-	if err != nil {
-		jsonError(w, "save audio failed: "+err.Error(), http.StatusInternalServerError)
+	case errors.Is(err, errBadUpload):
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
-	}
-	if _, err := io.Copy(dst, file); err != nil {
-		dst.Close()
+	case err != nil:
 		jsonError(w, "save audio failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	dst.Close()
 
 	// Transcribe
-	text, err := s.Whisper.Transcribe(audioPath)
-	if err != nil {
+	t, err := sp.Transcriber.Transcribe(r.Context(), audioPath, r.FormValue("language"))
+	switch {
+	case errors.Is(err, errSpeechService):
+		log.Printf("[speech] %s backend error: %v", sp.Backend, err)
+		jsonError(w, "transcription failed: "+err.Error(), http.StatusBadGateway)
+		return
+	case err != nil:
 		log.Printf("[speech] transcribe error: %v", err)
 		jsonError(w, "transcription failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[speech] Transcribed: %s", text)
+	resp := transcribeResponse{Text: t.Text, Status: "ok", Confidence: t.Confidence}
+	if t.Text != "" && t.Confidence < sp.MinConfidence {
+		log.Printf("[speech] Rejected (confidence %.2f < %.2f): %q", t.Confidence, sp.MinConfidence, t.RawText)
+		resp.Status = "low_confidence"
+		resp.RawText = t.RawText
+		resp.Text = ""
+		jsonOK(w, resp)
+		return
+	}
+
+	log.Printf("[speech] Transcribed (confidence %.2f): %s", t.Confidence, t.Text)
 
-	// Optionally send voice command to robot
-	if text != "" {
-		rb := s.Manager.GetCurrentRobot()
+	// Optionally send voice command to robot, as its canonical command
+	if t.Text != "" {
+		m := h.mapTranscript(audioPath, t.Text, r.FormValue("language"))
+		resp.Command = m.Command
+		resp.Mapping = &m
+		rb := h.Robots.GetCurrentRobot()
 		if rb != nil && rb.Client != nil && rb.Client.IsConnected() {
-			go rb.Client.SendVoiceCommand(text)
+			go rb.SendVoiceCommand(m.Command)
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"text":   text,
-		"status": "ok",
-	})
+	jsonOK(w, resp)
 }
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ──────────────────────────── Speech backends
+//
+// Recordings are transcribed by the backend SPEECH_BACKEND selects: the
+// whisper.cpp CLI on this machine (whisper_cli) or a speech-to-text
+// service reached over HTTP (http), for deployments without the CPU for
+// local inference. Both get the recording converted to 16 kHz mono WAV
+// by ffmpeg first and return the same Transcript, scored by
+// ParseWhisperJSON, so the confidence gate applies to either.
+
+// Speech backend names (SPEECH_BACKEND).
+const (
+	SpeechBackendWhisperCLI = "whisper_cli"
+	SpeechBackendHTTP       = "http"
+)
+
+// Transcriber turns a recording into text.
+type Transcriber interface {
+	// Transcribe transcribes the recording at audioPath. language is an
+	// ISO 639-1 code, or empty to let the backend decide.
+	Transcribe(ctx context.Context, audioPath, language string) (Transcript, error)
+	// Ready reports whether the backend is configured.
+	Ready() bool
+	// Check reports why the backend can't be used right now, if it can't.
+	Check(ctx context.Context) error
+}
+
+// speechCheckTimeout bounds a backend check made for the status endpoint.
+const speechCheckTimeout = 3 * time.Second
+
+// speechSetup is the speech settings a request works with: where uploads
+// are kept, how large they may be, the confidence floor and the backend.
+type speechSetup struct {
+	Backend     string
+	Transcriber Transcriber
+
+	LogDir string
+	// Transcripts scoring below this (0..1) are not sent to the robot.
+	MinConfidence float64
+	// Upload limit; zero means no limit.
+	MaxUploadBytes int64
+}
+
+// speech returns the setup for the current speech settings, which a
+// config reload may change; nil without a config. An unknown backend
+// leaves Transcriber nil.
+func (s *Server) speech() *speechSetup {
+	if s.Config == nil {
+		return nil
+	}
+	d := s.Config.Dynamic()
+	sp := &speechSetup{
+		Backend:        d.SpeechBackend,
+		LogDir:         d.SpeechLogDir,
+		MinConfidence:  d.WhisperMinConfidence,
+		MaxUploadBytes: int64(d.SpeechMaxUploadMB) << 20,
+	}
+	switch d.SpeechBackend {
+	case SpeechBackendWhisperCLI:
+		wr := NewWhisperRunner(d.WhisperBinPath, d.WhisperModelPath)
+		wr.FFmpegTimeout = d.SpeechFFmpegTimeout
+		sp.Transcriber = wr
+	case SpeechBackendHTTP:
+		ht := NewHTTPTranscriber(d.SpeechHTTPURL, d.SpeechHTTPAuth, d.SpeechHTTPTimeout)
+		ht.FFmpegTimeout = d.SpeechFFmpegTimeout
+		sp.Transcriber = ht
+	}
+	return sp
+}
+
+// Ready reports whether the configured backend is usable.
+func (sp *speechSetup) Ready() bool {
+	return sp != nil && sp.Transcriber != nil && sp.Transcriber.Ready()
+}
+
+// unavailable explains why Ready is false.
+func (sp *speechSetup) unavailable() string {
+	switch {
+	case sp == nil:
+		return "speech not configured"
+	case sp.Transcriber == nil:
+		return fmt.Sprintf("unknown speech backend %q", sp.Backend)
+	case sp.Backend == SpeechBackendHTTP:
+		return "speech service not configured (SPEECH_HTTP_URL)"
+	}
+	if err := sp.Transcriber.Check(context.Background()); err != nil {
+		return "whisper not available: " + err.Error()
+	}
+	return "whisper not available"
+}
+
+// convertToWAV16k converts a recording to 16 kHz mono WAV next to it
+// (<name>_16k.wav), as both backends expect, and returns the new file's
+// path; the caller removes it. ffmpeg is killed when ctx ends or after
+// timeout (zero: no limit).
+func convertToWAV16k(ctx context.Context, audioPath string, timeout time.Duration) (string, error) {
+	wavPath := strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + "_16k.wav"
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-nostdin", "-y", "-i", audioPath, "-ar", "16000", "-ac", "1", "-f", "wav", wavPath)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(wavPath)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("ffmpeg timed out after %s", timeout)
+		}
+		return "", fmt.Errorf("ffmpeg failed: %w: %s", err, string(out))
+	}
+	return wavPath, nil
+}
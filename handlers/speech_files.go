@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ──────────────────────────── Speech recordings on disk
+//
+// Uploads are capped before the body is read, and only kept when their
+// first bytes look like audio. The file name is generated here; its
+// extension follows the detected format, never the client's file name.
+// Recordings and whisper output older than SPEECH_RETENTION_H are
+// deleted by a background sweep.
+
+// Upload rejections.
+var (
+	errUploadTooLarge = errors.New("audio upload too large")
+	errNotAudio       = errors.New("upload is not a supported audio format")
+	errBadUpload      = errors.New("audio file required")
+)
+
+// audioExts maps the formats browsers record (as http.DetectContentType
+// names them) to the stored file's extension.
+var audioExts = map[string]string{
+	"video/webm":      ".webm", // MediaRecorder in Chrome; audio-only webm sniffs as video
+	"application/ogg": ".ogg",
+	"video/mp4":       ".m4a", // Safari
+	"audio/wave":      ".wav",
+	"audio/mpeg":      ".mp3",
+	"audio/aiff":      ".aiff",
+	"audio/basic":     ".au",
+}
+
+// speechFilePrefix starts every file the speech handlers write, so the
+// sweep never touches anything else in the directory.
+const speechFilePrefix = "speech_"
+
+// saveUpload stores the request's "audio" part in LogDir and returns its
+// path. Failures a client caused wrap errUploadTooLarge, errNotAudio or
+// errBadUpload.
+func (sp *speechSetup) saveUpload(w http.ResponseWriter, r *http.Request) (string, error) {
+	limit := sp.MaxUploadBytes
+	if limit > 0 {
+		// Form overhead is small next to the audio; allow a little for it.
+		limit += 64 << 10
+		if r.ContentLength > limit {
+			return "", fmt.Errorf("%w: %d bytes, limit is %d MB", errUploadTooLarge, r.ContentLength, sp.MaxUploadBytes>>20)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	// Parts beyond 1 MB spill to temporary files, removed on return
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return "", fmt.Errorf("%w: limit is %d MB", errUploadTooLarge, sp.MaxUploadBytes>>20)
+		}
+		return "", fmt.Errorf("%w: invalid form data: %v", errBadUpload, err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("audio")
+	if err != nil {
+		return "", errBadUpload
+	}
+	defer file.Close()
+	if sp.MaxUploadBytes > 0 && header.Size > sp.MaxUploadBytes {
+		return "", fmt.Errorf("%w: %d bytes, limit is %d MB", errUploadTooLarge, header.Size, sp.MaxUploadBytes>>20)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("%w: empty upload", errNotAudio)
+	}
+	head = head[:n]
+	kind := http.DetectContentType(head)
+	ext, ok := audioExts[kind]
+	if !ok {
+		log.Printf("[speech] Rejected upload %q: content is %s", header.Filename, kind)
+		return "", fmt.Errorf("%w (detected %s)", errNotAudio, kind)
+	}
+
+	if err := os.MkdirAll(sp.LogDir, 0755); err != nil {
+		return "", err
+	}
+	ts := time.Now().Format("20060102_150405")
+	dst, err := os.CreateTemp(sp.LogDir, speechFilePrefix+ts+"_*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, io.MultiReader(bytes.NewReader(head), file)); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// SpeechSweepInterval is how often old recordings are looked for.
+const SpeechSweepInterval = time.Hour
+
+// RunSpeechRetention deletes recordings and whisper output older than
+// SPEECH_RETENTION_H from SPEECH_LOG_DIR every interval until ctx ends.
+// Both settings are re-read on each pass.
+func (s *Server) RunSpeechRetention(ctx context.Context, interval time.Duration) {
+	if s.Config != nil {
+		d := s.Config.Dynamic()
+		log.Printf("[speech] Retention: sweeping %s every %s, keeping files for %s (0 = forever)",
+			d.SpeechLogDir, interval, d.SpeechRetention)
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if s.Config != nil {
+			d := s.Config.Dynamic()
+			if d.SpeechRetention > 0 {
+				sweepSpeechDir(d.SpeechLogDir, time.Now().Add(-d.SpeechRetention))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// sweepSpeechDir removes the speech files in dir last modified before
+// cutoff.
+func sweepSpeechDir(dir string, cutoff time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[speech] Retention sweep of %s failed: %v", dir, err)
+		}
+		return
+	}
+	removed, freed := 0, int64(0)
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !strings.HasPrefix(e.Name(), speechFilePrefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			log.Printf("[speech] Retention: %v", err)
+			continue
+		}
+		removed++
+		freed += info.Size()
+	}
+	if removed > 0 {
+		log.Printf("[speech] Retention: removed %d files (%d KB) older than %s from %s",
+			removed, freed>>10, cutoff.Format(time.RFC3339), dir)
+	}
+}
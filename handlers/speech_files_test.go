@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTranscriber records the recordings it was given.
+type fakeTranscriber struct{ paths []string }
+
+func (f *fakeTranscriber) Transcribe(_ context.Context, audioPath, _ string) (Transcript, error) {
+	f.paths = append(f.paths, audioPath)
+	return Transcript{Confidence: 1}, nil
+}
+
+func (f *fakeTranscriber) Ready() bool                 { return true }
+func (f *fakeTranscriber) Check(context.Context) error { return nil }
+
+// Recording headers as browsers produce them.
+var (
+	webmHead = []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81, 0x01}
+	wavHead  = []byte("RIFF\x24\x00\x00\x00WAVEfmt ")
+)
+
+// uploadBody returns a multipart body with content as its audio part.
+func uploadBody(t *testing.T, filename string, content []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("audio", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+	return &buf, mw.FormDataContentType()
+}
+
+func newUploadHandlers(t *testing.T, maxBytes int64) (*SpeechHandlers, *fakeTranscriber, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "speech")
+	tr := new(fakeTranscriber)
+	sp := &speechSetup{Backend: "fake", Transcriber: tr, LogDir: dir, MaxUploadBytes: maxBytes}
+	return &SpeechHandlers{Robots: newFakeRobots("a"), Setup: func() *speechSetup { return sp }}, tr, dir
+}
+
+func transcribe(h *SpeechHandlers, body io.Reader, contentType string, length int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/speech/transcribe", body)
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = length
+	rec := httptest.NewRecorder()
+	h.SpeechTranscribe(rec, req)
+	return rec
+}
+
+// storedFiles lists the files in dir.
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// TestSpeechUploadSniffed checks recordings are stored under a server
+// name with the extension of their content, whatever the client called
+// them, and that anything else is refused.
+func TestSpeechUploadSniffed(t *testing.T) {
+	h, tr, dir := newUploadHandlers(t, 1<<20)
+	audio := func(head []byte) []byte { return append(head, make([]byte, 1000)...) }
+
+	for _, tc := range []struct {
+		filename string
+		content  []byte
+		code     int
+		ext      string
+	}{
+		{"recording.webm", audio(webmHead), http.StatusOK, ".webm"},
+		{"cmd.sh", audio(webmHead), http.StatusOK, ".webm"}, // audio under a script's name
+		{"clip.webm", audio(wavHead), http.StatusOK, ".wav"},
+		{"../../etc/passwd", audio(wavHead), http.StatusOK, ".wav"},
+		{"evil.webm", []byte("#!/bin/sh\nrm -rf /\n"), http.StatusUnsupportedMediaType, ""},
+		{"page.ogg", []byte("<html><body>hi</body></html>"), http.StatusUnsupportedMediaType, ""},
+		{"empty.webm", nil, http.StatusUnsupportedMediaType, ""},
+	} {
+		before := len(tr.paths)
+		body, ct := uploadBody(t, tc.filename, tc.content)
+		rec := transcribe(h, body, ct, int64(body.Len()))
+		if rec.Code != tc.code {
+			t.Errorf("%s: %d %s", tc.filename, rec.Code, rec.Body.String())
+			continue
+		}
+		if tc.code != http.StatusOK {
+			if len(tr.paths) != before {
+				t.Errorf("%s: refused upload was transcribed", tc.filename)
+			}
+			continue
+		}
+		path := tr.paths[len(tr.paths)-1]
+		name := filepath.Base(path)
+		if filepath.Dir(path) != dir || !strings.HasPrefix(name, speechFilePrefix) || filepath.Ext(name) != tc.ext {
+			t.Errorf("%s: stored as %s", tc.filename, path)
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, tc.content) {
+			t.Errorf("%s: stored %d bytes, sent %d", tc.filename, len(got), len(tc.content))
+		}
+	}
+	if files := storedFiles(t, dir); len(files) != 4 {
+		t.Errorf("stored %v, want the 4 accepted recordings", files)
+	}
+
+	if rec := transcribe(h, strings.NewReader("not multipart"), "text/plain", 13); rec.Code != http.StatusBadRequest {
+		t.Errorf("no form: %d %s", rec.Code, rec.Body.String())
+	}
+	var other bytes.Buffer
+	mw := multipart.NewWriter(&other)
+	mw.WriteField("language", "en")
+	mw.Close()
+	if rec := transcribe(h, &other, mw.FormDataContentType(), int64(other.Len())); rec.Code != http.StatusBadRequest {
+		t.Errorf("no audio part: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestSpeechUploadTooLarge sends oversized uploads declared in
+// Content-Length and streamed without one.
+func TestSpeechUploadTooLarge(t *testing.T) {
+	h, tr, dir := newUploadHandlers(t, 1<<20)
+	big := append(append([]byte{}, webmHead...), make([]byte, 2<<20)...)
+
+	body, ct := uploadBody(t, "big.webm", big)
+	if rec := transcribe(h, body, ct, int64(body.Len())); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("declared: %d %s", rec.Code, rec.Body.String())
+	}
+	body, ct = uploadBody(t, "big.webm", big)
+	if rec := transcribe(h, io.MultiReader(body), ct, -1); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("streamed: %d %s", rec.Code, rec.Body.String())
+	}
+	// A Content-Length understating the body is cut off at the limit too
+	body, ct = uploadBody(t, "big.webm", big)
+	if rec := transcribe(h, body, ct, 1000); rec.Code == http.StatusOK {
+		t.Errorf("understated length: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(tr.paths) != 0 || len(storedFiles(t, dir)) != 0 {
+		t.Errorf("oversized uploads stored: %v", storedFiles(t, dir))
+	}
+
+	// Just under the limit
+	body, ct = uploadBody(t, "ok.webm", big[:1<<20])
+	if rec := transcribe(h, body, ct, int64(body.Len())); rec.Code != http.StatusOK {
+		t.Errorf("at the limit: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// No limit
+	h, _, _ = newUploadHandlers(t, 0)
+	body, ct = uploadBody(t, "big.webm", big)
+	if rec := transcribe(h, body, ct, int64(body.Len())); rec.Code != http.StatusOK {
+		t.Errorf("unlimited: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSweepSpeechDir(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for name, stale := range map[string]bool{
+		"speech_old.webm": true,
+		"speech_old.json": true,
+		"speech_new.webm": false,
+		"notes_old.txt":   true, // not ours
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if stale {
+			os.Chtimes(path, old, old)
+		}
+	}
+	os.Mkdir(filepath.Join(dir, "speech_dir"), 0755)
+	os.Chtimes(filepath.Join(dir, "speech_dir"), old, old)
+
+	sweepSpeechDir(dir, time.Now().Add(-24*time.Hour))
+	got := strings.Join(storedFiles(t, dir), " ")
+	if got != "notes_old.txt speech_dir speech_new.webm" {
+		t.Errorf("left %s", got)
+	}
+	sweepSpeechDir(filepath.Join(dir, "missing"), time.Now()) // no panic, no error
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errSpeechService wraps failures of the HTTP speech backend, which the
+// transcribe handler answers with 502 instead of 500.
+var errSpeechService = errors.New("speech service")
+
+// HTTPTranscriber sends recordings to a speech-to-text service. The
+// request follows the OpenAI transcription API that faster-whisper and
+// whisper.cpp's server speak: a multipart POST with the WAV as "file",
+// "response_format" verbose_json and an optional "language".
+type HTTPTranscriber struct {
+	URL string
+	// Auth is sent as the Authorization header when set ("Bearer ...").
+	Auth    string
+	Timeout time.Duration // per request; zero means no limit
+
+	// ffmpeg deadline; zero means no limit.
+	FFmpegTimeout time.Duration
+}
+
+// NewHTTPTranscriber creates an HTTPTranscriber for the service at url.
+func NewHTTPTranscriber(url, auth string, timeout time.Duration) *HTTPTranscriber {
+	return &HTTPTranscriber{URL: url, Auth: auth, Timeout: timeout}
+}
+
+// Ready returns true if a service URL is set.
+func (ht *HTTPTranscriber) Ready() bool {
+	return ht != nil && ht.URL != ""
+}
+
+// Check asks the service for its endpoint and reports a connection
+// failure, rejected credentials or a server error. Other answers (405 to
+// a GET on the transcription endpoint) mean it is up.
+func (ht *HTTPTranscriber) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ht.URL, nil)
+	if err != nil {
+		return fmt.Errorf("%w URL invalid: %v", errSpeechService, err)
+	}
+	ht.authorize(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w unreachable: %v", errSpeechService, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w rejected credentials (%d)", errSpeechService, resp.StatusCode)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%w returned %d", errSpeechService, resp.StatusCode)
+	}
+	return nil
+}
+
+// Transcribe converts the recording and posts it to the service. The
+// service's JSON answer is kept next to the audio (<name>.json), as
+// whisper.cpp's output is. Failures past the conversion wrap
+// errSpeechService.
+func (ht *HTTPTranscriber) Transcribe(ctx context.Context, audioPath, language string) (Transcript, error) {
+	if !ht.Ready() {
+		return Transcript{}, fmt.Errorf("%w not configured", errSpeechService)
+	}
+
+	wavPath, err := convertToWAV16k(ctx, audioPath, ht.FFmpegTimeout)
+	if err != nil {
+		return Transcript{}, err
+	}
+	defer os.Remove(wavPath)
+
+	body, contentType, err := transcriptionForm(wavPath, language)
+	if err != nil {
+		return Transcript{}, err
+	}
+	if ht.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ht.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ht.URL, body)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("%w URL invalid: %v", errSpeechService, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	ht.authorize(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Transcript{}, fmt.Errorf("%w timed out after %s", errSpeechService, ht.Timeout)
+		}
+		return Transcript{}, fmt.Errorf("%w unreachable: %v", errSpeechService, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return Transcript{}, fmt.Errorf("%w response unreadable: %v", errSpeechService, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(raw))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return Transcript{}, fmt.Errorf("%w returned %d: %s", errSpeechService, resp.StatusCode, msg)
+	}
+
+	base := strings.TrimSuffix(audioPath, filepath.Ext(audioPath))
+	os.WriteFile(base+".json", raw, 0644)
+	t, err := ParseWhisperJSON(raw)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("%w response invalid: %v", errSpeechService, err)
+	}
+	return t, nil
+}
+
+func (ht *HTTPTranscriber) authorize(req *http.Request) {
+	if ht.Auth != "" {
+		req.Header.Set("Authorization", ht.Auth)
+	}
+}
+
+// transcriptionForm builds the multipart request body for wavPath.
+func transcriptionForm(wavPath, language string) (io.Reader, string, error) {
+	f, err := os.Open(wavPath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("file", filepath.Base(wavPath))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return nil, "", err
+	}
+	mw.WriteField("response_format", "verbose_json")
+	if language != "" {
+		mw.WriteField("language", language)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, mw.FormDataContentType(), nil
+}
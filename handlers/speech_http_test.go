@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg puts an ffmpeg on PATH that copies its input to the output
+// path, its last argument.
+func fakeFFmpeg(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script ffmpeg")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nfor a; do out=$a; done\ncp \"$4\" \"$out\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// sttRequest is what the stub speech service received.
+type sttRequest struct {
+	method, auth, format, language, filename string
+	audio                                    []byte
+}
+
+// sttStub is a speech-to-text service answering with status and body.
+type sttStub struct {
+	srv *httptest.Server
+
+	mu     sync.Mutex
+	reqs   []sttRequest
+	status int
+	body   string
+	delay  time.Duration
+}
+
+func newSTTStub(t *testing.T) *sttStub {
+	t.Helper()
+	s := &sttStub{status: http.StatusOK}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := sttRequest{method: r.Method, auth: r.Header.Get("Authorization")}
+		if r.Method == http.MethodPost {
+			req.format, req.language = r.FormValue("response_format"), r.FormValue("language")
+			if f, fh, err := r.FormFile("file"); err == nil {
+				req.filename = fh.Filename
+				req.audio, _ = io.ReadAll(f)
+				f.Close()
+			}
+		}
+		s.mu.Lock()
+		s.reqs = append(s.reqs, req)
+		status, body, delay := s.status, s.body, s.delay
+		s.mu.Unlock()
+		if r.Method == http.MethodGet && status == http.StatusOK {
+			status = http.StatusMethodNotAllowed // as OpenAI-style servers answer a GET
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *sttStub) answer(status int, body string) {
+	s.mu.Lock()
+	s.status, s.body = status, body
+	s.mu.Unlock()
+}
+
+func (s *sttStub) last(t *testing.T) sttRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.reqs) == 0 {
+		t.Fatal("no request")
+	}
+	return s.reqs[len(s.reqs)-1]
+}
+
+// recording writes a recording to a temp dir and returns its path.
+func recording(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clip.webm")
+	if err := os.WriteFile(path, append(webmHead, "audio"...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const verboseJSON = `{"text": " go to the dock", "segments": [{"text": " go to the dock", "avg_logprob": -0.1, "no_speech_prob": 0.02}]}`
+
+func TestHTTPTranscriber(t *testing.T) {
+	fakeFFmpeg(t)
+	stt := newSTTStub(t)
+	ht := NewHTTPTranscriber(stt.srv.URL, "Bearer secret", 5*time.Second)
+
+	// verbose_json, kept next to the recording
+	stt.answer(http.StatusOK, verboseJSON)
+	audio := recording(t)
+	tr, err := ht.Transcribe(context.Background(), audio, "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := math.Exp(-0.1) * 0.98; tr.Text != "go to the dock" || math.Abs(tr.Confidence-want) > 1e-9 {
+		t.Errorf("transcript %+v, want confidence %.4f", tr, want)
+	}
+	req := stt.last(t)
+	if req.method != http.MethodPost || req.auth != "Bearer secret" || req.format != "verbose_json" || req.language != "de" {
+		t.Errorf("request %+v", req)
+	}
+	if req.filename != "clip_16k.wav" || string(req.audio) != string(webmHead)+"audio" {
+		t.Errorf("sent %q, %d bytes", req.filename, len(req.audio))
+	}
+	if kept, err := os.ReadFile(strings.TrimSuffix(audio, ".webm") + ".json"); err != nil || string(kept) != verboseJSON {
+		t.Errorf("kept answer %q, %v", kept, err)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(audio, ".webm") + "_16k.wav"); !os.IsNotExist(err) {
+		t.Error("converted file left behind")
+	}
+
+	// A bare text answer; no language; no Authorization
+	stt.answer(http.StatusOK, `{"text": "stop"}`)
+	ht.Auth = ""
+	if tr, err := ht.Transcribe(context.Background(), recording(t), ""); err != nil || tr.Text != "stop" || tr.Confidence != 1 {
+		t.Errorf("bare text: %+v, %v", tr, err)
+	}
+	if req := stt.last(t); req.language != "" || req.auth != "" {
+		t.Errorf("request %+v", req)
+	}
+
+	// Service failures wrap errSpeechService
+	for _, c := range []struct {
+		name, want string
+		setup      func()
+	}{
+		{"rejected", "returned 422: unsupported audio", func() { stt.answer(http.StatusUnprocessableEntity, "unsupported audio\n") }},
+		{"not JSON", "response invalid", func() { stt.answer(http.StatusOK, "go to the dock") }},
+		{"timeout", "timed out after 100ms", func() {
+			stt.answer(http.StatusOK, verboseJSON)
+			stt.mu.Lock()
+			stt.delay = time.Second
+			stt.mu.Unlock()
+			ht.Timeout = 100 * time.Millisecond
+		}},
+	} {
+		c.setup()
+		_, err := ht.Transcribe(context.Background(), recording(t), "")
+		if !errors.Is(err, errSpeechService) || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: %v, want %q", c.name, err, c.want)
+		}
+	}
+
+	// ffmpeg failing is a local error
+	if _, err := ht.Transcribe(context.Background(), filepath.Join(t.TempDir(), "missing.webm"), ""); err == nil || errors.Is(err, errSpeechService) {
+		t.Errorf("missing recording: %v", err)
+	}
+}
+
+func TestHTTPTranscriberCheck(t *testing.T) {
+	stt := newSTTStub(t)
+	ht := NewHTTPTranscriber(stt.srv.URL, "Bearer secret", 0)
+	if err := ht.Check(context.Background()); err != nil {
+		t.Errorf("up: %v", err)
+	}
+	if req := stt.last(t); req.method != http.MethodGet || req.auth != "Bearer secret" {
+		t.Errorf("check request %+v", req)
+	}
+	for status, want := range map[int]string{
+		http.StatusUnauthorized:       "rejected credentials (401)",
+		http.StatusForbidden:          "rejected credentials (403)",
+		http.StatusServiceUnavailable: "returned 503",
+	} {
+		stt.answer(status, "")
+		if err := ht.Check(context.Background()); !errors.Is(err, errSpeechService) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%d: %v", status, err)
+		}
+	}
+	stt.srv.Close()
+	if err := ht.Check(context.Background()); !errors.Is(err, errSpeechService) || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("down: %v", err)
+	}
+}
+
+// TestSpeechHTTPBackend goes through the handlers with the HTTP backend.
+func TestSpeechHTTPBackend(t *testing.T) {
+	fakeFFmpeg(t)
+	stt := newSTTStub(t)
+	sp := &speechSetup{
+		Backend:     SpeechBackendHTTP,
+		Transcriber: NewHTTPTranscriber(stt.srv.URL, "", time.Second),
+		LogDir:      t.TempDir(),
+	}
+	h := &SpeechHandlers{Robots: newFakeRobots("a"), Setup: func() *speechSetup { return sp }}
+	post := func() *httptest.ResponseRecorder {
+		body, ct := uploadBody(t, "clip.webm", append(webmHead, "audio"...))
+		return transcribe(h, body, ct, int64(body.Len()))
+	}
+	status := func() speechStatusResponse {
+		rec := httptest.NewRecorder()
+		h.SpeechStatus(rec, httptest.NewRequest(http.MethodGet, "/api/speech/status", nil))
+		var resp speechStatusResponse
+		decodeJSON(t, rec, &resp)
+		return resp
+	}
+
+	stt.answer(http.StatusOK, verboseJSON)
+	rec := post()
+	var resp transcribeResponse
+	decodeJSON(t, rec, &resp)
+	if rec.Code != http.StatusOK || resp.Text != "go to the dock" || resp.Status != "ok" {
+		t.Errorf("transcribed: %d %+v", rec.Code, resp)
+	}
+	if st := status(); !st.Available || !st.Reachable || st.Backend != SpeechBackendHTTP || st.Error != "" {
+		t.Errorf("status up: %+v", st)
+	}
+
+	stt.answer(http.StatusUnprocessableEntity, "bad audio")
+	if rec := post(); rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "speech service returned 422") {
+		t.Errorf("service error: %d %s", rec.Code, rec.Body)
+	}
+	stt.answer(http.StatusUnauthorized, "")
+	if st := status(); st.Available || !strings.Contains(st.Error, "rejected credentials") {
+		t.Errorf("status, wrong token: %+v", st)
+	}
+	stt.srv.Close()
+	if st := status(); st.Available || !strings.Contains(st.Error, "unreachable") {
+		t.Errorf("status, down: %+v", st)
+	}
+
+	// Not configured, and other backends
+	sp.Transcriber = NewHTTPTranscriber("", "", 0)
+	if rec := post(); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "SPEECH_HTTP_URL") {
+		t.Errorf("no URL: %d %s", rec.Code, rec.Body)
+	}
+	sp.Backend, sp.Transcriber = SpeechBackendWhisperCLI, NewWhisperRunner(filepath.Join(t.TempDir(), "whisper"), "model.bin")
+	if st := status(); st.Available || st.Backend != SpeechBackendWhisperCLI || !strings.Contains(st.Error, "whisper binary") {
+		t.Errorf("status, no whisper: %+v", st)
+	}
+	sp.Backend, sp.Transcriber = "vosk", nil
+	if st := status(); st.Available || st.Error != `unknown speech backend "vosk"` {
+		t.Errorf("status, unknown backend: %+v", st)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rom_go_app/phrases"
+)
+
+// ──────────────────── Speech phrases ────────────────────
+
+// phraseTable maps transcripts to commands; *phrases.Store is one.
+type phraseTable interface {
+	Snapshot() phrases.Snapshot
+	Map(text, lang string) phrases.Result
+	Set(t phrases.Table) error
+}
+
+// phrasesEnabled answers 503 when the app runs without a phrase table.
+func (h *SpeechHandlers) phrasesEnabled(w http.ResponseWriter) bool {
+	if h.Phrases == nil {
+		jsonError(w, "speech phrases disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// SpeechPhrases handles GET /api/speech/phrases[?text=...&language=xx]
+//
+// Returns the phrase table; with text, also how that text would be
+// mapped.
+func (h *SpeechHandlers) SpeechPhrases(w http.ResponseWriter, r *http.Request) {
+	if !h.phrasesEnabled(w) {
+		return
+	}
+	resp := speechPhrasesResponse{Snapshot: h.Phrases.Snapshot()}
+	if text := strings.TrimSpace(r.URL.Query().Get("text")); text != "" {
+		res := h.Phrases.Map(text, r.URL.Query().Get("language"))
+		resp.Preview = &res
+	}
+	jsonOK(w, resp)
+}
+
+// SetSpeechPhrases handles POST /api/speech/phrases
+//
+// Body {phrases: [{phrase, command, lang}]} replaces the table, saved to
+// SPEECH_PHRASES_FILE; the next transcript is mapped with it.
+func (h *SpeechHandlers) SetSpeechPhrases(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.phrasesEnabled(w) {
+		return
+	}
+
+	var t phrases.Table
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := t.Validate(); err != nil {
+		jsonFieldErrors(w, fieldErrors{"phrases": err.Error()})
+		return
+	}
+	if err := h.Phrases.Set(t); err != nil {
+		log.Printf("[speech] Save phrases: %v", err)
+		jsonError(w, "save failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[speech] Phrase table replaced: %d phrases", len(t.Phrases))
+	jsonOK(w, speechPhrasesResponse{Snapshot: h.Phrases.Snapshot()})
+}
+
+// mapTranscript maps a transcript of the recording at audioPath to the
+// command sent to the robot. The decision is logged and kept next to
+// the recording (<name>.phrases.json), so the retention sweep removes
+// it with the audio. Without a phrase table the text is sent as spoken.
+func (h *SpeechHandlers) mapTranscript(audioPath, text, language string) phrases.Result {
+	if h.Phrases == nil {
+		return phrases.Result{Original: text, Command: text, Status: phrases.StatusUnmapped}
+	}
+	res := h.Phrases.Map(text, language)
+	switch res.Status {
+	case phrases.StatusUnmapped:
+		log.Printf("[speech] No phrase matched, sending as spoken: %q", text)
+	case phrases.StatusPartial:
+		log.Printf("[speech] Mapped %q -> %q (left as spoken: %s)", text, res.Command, strings.Join(res.Unmapped, ", "))
+	default:
+		log.Printf("[speech] Mapped %q -> %q", text, res.Command)
+	}
+
+	entry := phraseLogEntry{Time: time.Now(), Language: language, Result: res}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		path := strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + ".phrases.json"
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("[speech] Save phrase mapping: %v", err)
+	}
+	return res
+}
+
+// phraseLogEntry is the mapping decision kept next to a recording.
+type phraseLogEntry struct {
+	Time     time.Time `json:"time"`
+	Language string    `json:"language,omitempty"`
+	phrases.Result
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rom_go_app/phrases"
+)
+
+// newPhrasesHandlers returns speech handlers mapping with a phrase table
+// saved in a temporary directory.
+func newPhrasesHandlers(t *testing.T) (*SpeechHandlers, *phrases.Store) {
+	t.Helper()
+	store, err := phrases.Open(filepath.Join(t.TempDir(), "phrases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SpeechHandlers{Robots: newFakeRobots("a"), Phrases: store}, store
+}
+
+func setPhrases(h *SpeechHandlers, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.SetSpeechPhrases(rec, httptest.NewRequest(http.MethodPost, "/api/speech/phrases", strings.NewReader(body)))
+	return rec
+}
+
+func TestSetSpeechPhrasesInvalid(t *testing.T) {
+	h, store := newPhrasesHandlers(t)
+	if rec := setPhrases(h, `{"phrases":[{"phrase":"ရှေ့","command":"forward","lang":"my"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("set: %d %s", rec.Code, rec.Body.String())
+	}
+
+	for name, body := range map[string]string{
+		"not JSON":  `{"phrases":`,
+		"duplicate": `{"phrases":[{"phrase":"stop","command":"stop"},{"phrase":"ＳＴＯＰ","command":"halt"}]}`,
+		"empty":     `{"phrases":[{"phrase":"\u200b။","command":"stop"}]}`,
+		"command":   `{"phrases":[{"phrase":"stop","command":" "}]}`,
+	} {
+		rec := setPhrases(h, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d %s", name, rec.Code, rec.Body.String())
+			continue
+		}
+		var resp errorResponse
+		decodeJSON(t, rec, &resp)
+		if name != "not JSON" && resp.Fields["phrases"] == "" {
+			t.Errorf("%s: no phrases field error in %+v", name, resp)
+		}
+	}
+	if snap := store.Snapshot(); len(snap.Phrases) != 1 || snap.Phrases[0].Command != "forward" {
+		t.Errorf("table after refused updates: %+v", snap.Phrases)
+	}
+}
+
+func TestSpeechPhrasesPreview(t *testing.T) {
+	h, _ := newPhrasesHandlers(t)
+	body := `{"phrases":[
+		{"phrase":"ရှေ့","command":"forward","lang":"my"},
+		{"phrase":"ရှေ့ဆက်","command":"continue forward","lang":"my"},
+		{"phrase":"မီတာ","command":"meters"},
+		{"phrase":"stop","command":"stop","lang":"en"}]}`
+	if rec := setPhrases(h, body); rec.Code != http.StatusOK {
+		t.Fatalf("set: %d %s", rec.Code, rec.Body.String())
+	}
+
+	for _, tc := range []struct{ text, lang, command, status string }{
+		{"ရှေ့ဆက် ၂ မီတာ။", "my", "continue forward 2 meters", phrases.StatusMapped},
+		{"ＳＴＯＰ ရှေ့", "my", "stop forward", phrases.StatusPartial}, // normalized, kept as spoken
+		{"ＳＴＯＰ ရှေ့", "", "stop forward", phrases.StatusMapped},
+		{"hello", "en", "hello", phrases.StatusUnmapped},
+	} {
+		var resp speechPhrasesResponse
+		q := url.Values{"text": {tc.text}, "language": {tc.lang}}
+		decodeJSON(t, getReq(h.SpeechPhrases, "/api/speech/phrases?"+q.Encode()), &resp)
+		if p := resp.Preview; p == nil || p.Original != tc.text || p.Command != tc.command || p.Status != tc.status {
+			t.Errorf("preview of %q in %q = %+v, want %q %s", tc.text, tc.lang, resp.Preview, tc.command, tc.status)
+		}
+		if len(resp.Phrases) != 4 {
+			t.Errorf("%d phrases listed", len(resp.Phrases))
+		}
+	}
+}
+
+func TestMapTranscriptSidecar(t *testing.T) {
+	h, _ := newPhrasesHandlers(t)
+	if rec := setPhrases(h, `{"phrases":[{"phrase":"ရှေ့","command":"forward"},{"phrase":"မီတာ","command":"meters"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("set: %d %s", rec.Code, rec.Body.String())
+	}
+
+	dir := t.TempDir()
+	for _, tc := range []struct{ name, text, command, status string }{
+		{"a", "ရှေ့ ၃ မီတာ", "forward 3 meters", phrases.StatusMapped},
+		{"b", "ရှေ့ fast", "forward fast", phrases.StatusPartial},
+		{"c", "ဘယ်", "ဘယ်", phrases.StatusUnmapped},
+	} {
+		res := h.mapTranscript(filepath.Join(dir, tc.name+".wav"), tc.text, "my")
+		if res.Command != tc.command || res.Status != tc.status {
+			t.Errorf("%q mapped to %q %s, want %q %s", tc.text, res.Command, res.Status, tc.command, tc.status)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, tc.name+".phrases.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entry phraseLogEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Original != tc.text || entry.Command != tc.command || entry.Status != tc.status {
+			t.Errorf("sidecar of %q = %s (%v)", tc.text, data, err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ──────────────────── Static assets ────────────────────
+//
+// The embedded static files are hashed and gzip-compressed once at
+// startup. URLs produced by Asset carry ?v=<hash> and are served as
+// immutable; anything else gets a short max-age. ETags are the content
+// hash, so unchanged files revalidate with a 304.
+
+const immutableMaxAge = 365 * 24 * time.Hour
+
+// compressibleExt lists the extensions worth gzipping.
+var compressibleExt = map[string]bool{
+	".js": true, ".css": true, ".html": true, ".svg": true, ".json": true, ".txt": true, ".map": true,
+}
+
+type staticFile struct {
+	data    []byte
+	gz      []byte // nil if not worth compressing
+	hash    string
+	modTime time.Time
+}
+
+// StaticAssets serves an embedded FS with cache headers, ETags and
+// pre-compressed gzip responses.
+type StaticAssets struct {
+	files    map[string]*staticFile
+	maxAge   time.Duration
+	fallback http.Handler
+}
+
+// NewStaticAssets hashes and compresses every file in fsys. maxAge is the
+// Cache-Control max-age for unversioned URLs.
+func NewStaticAssets(fsys fs.FS, maxAge time.Duration) (*StaticAssets, error) {
+	a := &StaticAssets{
+		files:    map[string]*staticFile{},
+		maxAge:   maxAge,
+		fallback: http.FileServer(http.FS(fsys)),
+	}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		f := &staticFile{data: data, hash: hex.EncodeToString(sum[:8])}
+		if info, err := d.Info(); err == nil {
+			f.modTime = info.ModTime()
+		}
+		if compressibleExt[path.Ext(p)] && len(data) > 512 {
+			var buf bytes.Buffer
+			zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+			zw.Write(data)
+			zw.Close()
+			if buf.Len() < len(data) {
+				f.gz = buf.Bytes()
+			}
+		}
+		a.files[p] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Asset returns the versioned URL for a static path, e.g.
+// "js/app.js" → "/static/js/app.js?v=3f2a…". Unknown paths are returned
+// unversioned.
+func (a *StaticAssets) Asset(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if f, ok := a.files[p]; ok {
+		return "/static/" + p + "?v=" + f.hash
+	}
+	return "/static/" + p
+}
+
+// ServeHTTP serves a file relative to the static root (mount it behind
+// http.StripPrefix("/static/", ...)).
+func (a *StaticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, ok := a.files[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		a.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	h := w.Header()
+	if r.URL.Query().Get("v") == f.hash {
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(immutableMaxAge.Seconds()))+", immutable")
+	} else {
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(a.maxAge.Seconds())))
+	}
+
+	useGzip := f.gz != nil && acceptsGzip(r.Header.Get("Accept-Encoding"))
+	etag := `"` + f.hash + `"`
+	if f.gz != nil {
+		h.Set("Vary", "Accept-Encoding")
+		if useGzip {
+			etag = `"` + f.hash + `-gz"`
+		}
+	}
+	h.Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if !useGzip {
+		http.ServeContent(w, r, r.URL.Path, f.modTime, bytes.NewReader(f.data))
+		return
+	}
+
+	if ct := mimeType(r.URL.Path); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Set("Content-Length", strconv.Itoa(len(f.gz)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(f.gz)
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		enc := strings.ToLower(strings.TrimSpace(fields[0]))
+		if enc != "gzip" && enc != "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// etagMatches implements weak If-None-Match comparison.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func mimeType(p string) string {
+	switch path.Ext(p) {
+	case ".js":
+		return "text/javascript; charset=utf-8"
+	case ".css":
+		return "text/css; charset=utf-8"
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".svg":
+		return "image/svg+xml"
+	case ".json", ".map":
+		return "application/json"
+	case ".txt":
+		return "text/plain; charset=utf-8"
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var staticJS = []byte(strings.Repeat("console.log('rom');\n", 100))
+
+func newTestAssets(t *testing.T) *StaticAssets {
+	t.Helper()
+	a, err := NewStaticAssets(fstest.MapFS{
+		"js/app.js": {Data: staticJS},
+		"img/a.png": {Data: []byte("not really a png")},
+		"css/s.css": {Data: []byte("body{}")}, // too small to compress
+	}, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func serveAsset(a *StaticAssets, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	http.StripPrefix("/static/", a).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStaticConditionalRequests(t *testing.T) {
+	a := newTestAssets(t)
+	etag := serveAsset(a, "/static/js/app.js", nil).Header().Get("ETag")
+	gzEtag := serveAsset(a, "/static/js/app.js", map[string]string{"Accept-Encoding": "gzip"}).Header().Get("ETag")
+	if etag == "" || gzEtag == "" || etag == gzEtag {
+		t.Fatalf("etags: identity %q, gzip %q", etag, gzEtag)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		encoding    string
+		want        int
+	}{
+		{"exact", etag, "", http.StatusNotModified},
+		{"weak", "W/" + etag, "", http.StatusNotModified},
+		{"list", `"nope", ` + etag, "", http.StatusNotModified},
+		{"star", "*", "", http.StatusNotModified},
+		{"gzip etag", gzEtag, "gzip", http.StatusNotModified},
+		{"identity etag for gzip response", etag, "gzip", http.StatusOK},
+		{"gzip etag for identity response", gzEtag, "", http.StatusOK},
+		{"mismatch", `"deadbeef"`, "", http.StatusOK},
+		{"absent", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := map[string]string{"If-None-Match": tt.ifNoneMatch, "Accept-Encoding": tt.encoding}
+			rec := serveAsset(a, "/static/js/app.js", h)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 carried a %d-byte body", rec.Body.Len())
+			}
+			if rec.Header().Get("ETag") == "" {
+				t.Error("missing ETag")
+			}
+		})
+	}
+}
+
+func TestStaticGzipNegotiation(t *testing.T) {
+	a := newTestAssets(t)
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+		wantVary       bool
+	}{
+		{"gzip", "/static/js/app.js", "gzip", true, true},
+		{"gzip among others", "/static/js/app.js", "br, gzip;q=0.8", true, true},
+		{"wildcard", "/static/js/app.js", "*", true, true},
+		{"gzip refused", "/static/js/app.js", "gzip;q=0", false, true},
+		{"identity only", "/static/js/app.js", "identity", false, true},
+		{"no header", "/static/js/app.js", "", false, true},
+		{"not compressible", "/static/img/a.png", "gzip", false, false},
+		{"too small", "/static/css/s.css", "gzip", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveAsset(a, tt.path, map[string]string{"Accept-Encoding": tt.acceptEncoding})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+				t.Errorf("gzip = %v, want %v", got, tt.wantGzip)
+			}
+			if got := rec.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("Vary = %q, want set=%v", rec.Header().Get("Vary"), tt.wantVary)
+			}
+			if !tt.wantGzip || tt.path != "/static/js/app.js" {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(body, staticJS) {
+				t.Error("decompressed body differs from the original file")
+			}
+		})
+	}
+}
+
+func TestStaticCacheControl(t *testing.T) {
+	a := newTestAssets(t)
+	versioned := a.Asset("js/app.js")
+	if !strings.HasPrefix(versioned, "/static/js/app.js?v=") {
+		t.Fatalf("Asset = %q", versioned)
+	}
+	if got := a.Asset("/missing.js"); got != "/static/missing.js" {
+		t.Errorf("Asset(missing) = %q", got)
+	}
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{versioned, "public, max-age=31536000, immutable"},
+		{"/static/js/app.js", "public, max-age=300"},
+		{"/static/js/app.js?v=stale", "public, max-age=300"},
+	}
+	for _, tt := range tests {
+		if got := serveAsset(a, tt.target, nil).Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"":              false,
+		"gzip":          true,
+		"GZIP":          true,
+		"deflate, gzip": true,
+		"gzip;q=0":      false,
+		"gzip; q=0.5":   true,
+		"*":             true,
+		"*;q=0":         false,
+		"identity":      false,
+		"br, deflate":   false,
+	}
+	for header, want := range tests {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"x", "abc"`, true},
+		{"*", true},
+		{`"abcd"`, false},
+		{`abc`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+	"rom_go_app/units"
+)
+
+// ──────────────────── Status view ────────────────────
+
+// StatusView is a robot's live diagnostics. GET /api/robots/status
+// encodes it and /partial/status renders it, so both always show the
+// same figures.
+type StatusView struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Connected bool                `json:"connected"`
+	Odom      rosbridge.OdomData  `json:"odom"`
+	Velocity  rosbridge.TwistData `json:"velocity"`
+	MapHz     int                 `json:"map_hz"`
+	TFHz      int                 `json:"tf_hz"`
+	OdomHz    int                 `json:"odom_hz"`
+	LaserHz   int                 `json:"laser_hz"`
+
+	// Seconds since the last message per topic; null if none arrived.
+	MapAgeSec   *float64 `json:"map_age_sec"`
+	TFAgeSec    *float64 `json:"tf_age_sec"`
+	OdomAgeSec  *float64 `json:"odom_age_sec"`
+	LaserAgeSec *float64 `json:"laser_age_sec"`
+
+	// UptimeSec is how long the current connection has been up; null
+	// while disconnected. Reconnects counts dials after the first.
+	UptimeSec  *float64 `json:"uptime_sec"`
+	Reconnects uint64   `json:"reconnects"`
+
+	// Reconnect is the reconnect policy, connection state (connected,
+	// reconnecting, suspended, disconnected) and failed attempt count.
+	Reconnect rosbridge.ReconnectStatus `json:"reconnect"`
+
+	// Stream ages in ms and which are stale, as in the snapshot; the
+	// partial greys out stale figures.
+	robot.Freshness
+
+	// Units is the system the partial renders in. With ?units=imperial
+	// the JSON also carries the converted fields of statusImperial.
+	Units units.System `json:"units"`
+	*statusImperial
+}
+
+// statusImperial holds the imperial twins of StatusView's SI figures.
+type statusImperial struct {
+	OdomXFt        float64 `json:"odom_x_ft"`
+	OdomYFt        float64 `json:"odom_y_ft"`
+	OdomYawDeg     float64 `json:"odom_yaw_deg"`
+	VelocityMph    float64 `json:"velocity_mph"`
+	AngularDegPerS float64 `json:"angular_deg_per_s"`
+}
+
+// statusView assembles the status of rb for display in u; imperial adds
+// the converted JSON fields.
+func statusView(rb *robot.Robot, u units.System, imperial bool) StatusView {
+	snap := rb.GetSnapshot()
+	act := rb.GetActivity()
+	now := time.Now()
+	since := func(t time.Time) *float64 {
+		if t.IsZero() {
+			return nil
+		}
+		sec := now.Sub(t).Seconds()
+		return &sec
+	}
+
+	v := StatusView{
+		ID:          snap.ID,
+		Name:        snap.Name,
+		Connected:   snap.Connected,
+		Odom:        snap.Odom,
+		Velocity:    snap.Velocity,
+		MapHz:       snap.MapHz,
+		TFHz:        snap.TFHz,
+		OdomHz:      snap.OdomHz,
+		LaserHz:     snap.LaserHz,
+		MapAgeSec:   since(act.LastMap),
+		TFAgeSec:    since(act.LastTF),
+		OdomAgeSec:  since(act.LastOdom),
+		LaserAgeSec: since(act.LastLaser),
+		UptimeSec:   since(act.ConnectedAt),
+		Reconnect:   rb.Client.ReconnectStatus(),
+		Freshness:   snap.Freshness,
+		Units:       u,
+	}
+	if n := rb.Client.Bandwidth().Connections; n > 1 {
+		v.Reconnects = n - 1
+	}
+	if imperial {
+		v.statusImperial = &statusImperial{
+			OdomXFt:        units.MetersToFeet(snap.Odom.PosX),
+			OdomYFt:        units.MetersToFeet(snap.Odom.PosY),
+			OdomYawDeg:     units.RadToDeg(snap.Odom.Yaw),
+			VelocityMph:    units.MpsToMph(snap.Velocity.LinearX),
+			AngularDegPerS: units.RadToDeg(snap.Velocity.AngularZ),
+		}
+	}
+	return v
+}
+
+// Seconds formats an optional duration for the template, e.g. "0.4 s",
+// "3m12s" or "—".
+func (StatusView) Seconds(sec *float64) string {
+	switch {
+	case sec == nil:
+		return "—"
+	case *sec < 60:
+		return fmt.Sprintf("%.1f s", *sec)
+	default:
+		return (time.Duration(*sec) * time.Second).String()
+	}
+}
+
+// Age formats a last-message age, e.g. "0.4 s ago" or "never".
+func (v StatusView) Age(sec *float64) string {
+	if sec == nil {
+		return "never"
+	}
+	return v.Seconds(sec) + " ago"
+}
+
+// StatusPartial renders the self-refreshing diagnostics panel for the
+// robot given by id (default: current robot).
+func (s *Server) StatusPartial(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		id = s.Manager.GetCurrentRobotID()
+	}
+
+	rb := s.Manager.GetRobot(id)
+	if rb == nil {
+		s.render(w, r, "status_panel.html", nil)
+		return
+	}
+	s.render(w, r, "status_panel.html", statusView(rb, displayUnits(r), false))
+}
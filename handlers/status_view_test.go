@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"text/template/parse"
+)
+
+// statusJSONOnly lists the StatusView fields the partial deliberately
+// leaves out. A new field must be shown by the partial or added here.
+var statusJSONOnly = map[string]string{
+	"Name":          "the panel sits under the robot's name",
+	"Odom":          "the pose is drawn on the map",
+	"OdomAgeMs":     "the partial shows OdomAgeSec",
+	"VelocityAgeMs": "shown through IsStale",
+	"TFAgeMs":       "the partial shows TFAgeSec",
+	"LaserAgeMs":    "the partial shows LaserAgeSec",
+	"MapAgeMs":      "the partial shows MapAgeSec",
+	"Stale":         "shown through IsStale",
+}
+
+// statusFields returns the exported fields of StatusView, with embedded
+// structs flattened as encoding/json and templates see them.
+// statusImperial is JSON-only; the partial converts with the units funcs.
+func statusFields(t reflect.Type) map[string]reflect.StructField {
+	out := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct {
+			if ft == reflect.TypeOf(statusImperial{}) {
+				continue
+			}
+			for k, v := range statusFields(ft) {
+				out[k] = v
+			}
+			continue
+		}
+		if f.IsExported() {
+			out[f.Name] = f
+		}
+	}
+	return out
+}
+
+// templateFields returns the names the status partial looks up on its
+// data, i.e. the first identifier of every .Field chain.
+func templateFields(t *testing.T) map[string]bool {
+	t.Helper()
+	src, err := os.ReadFile("../templates/partials/status_panel.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := parse.New("status")
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := tree.Parse(string(src), "{{", "}}", trees); err != nil {
+		t.Fatal(err)
+	}
+
+	refs := map[string]bool{}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		if reflect.ValueOf(n).IsNil() {
+			return
+		}
+		switch n := n.(type) {
+		case *parse.ListNode:
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.PipeNode:
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.FieldNode:
+			refs[n.Ident[0]] = true
+		}
+	}
+	for _, tree := range trees {
+		walk(tree.Root)
+	}
+	return refs
+}
+
+func TestStatusPartialAndJSONCannotDrift(t *testing.T) {
+	viewType := reflect.TypeOf(StatusView{})
+	fields := statusFields(viewType)
+	refs := templateFields(t)
+
+	// Every field is shown by the partial or declared JSON-only
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !refs[name] && statusJSONOnly[name] == "" {
+			t.Errorf("StatusView.%s is neither rendered by status_panel.html nor listed in statusJSONOnly", name)
+		}
+		if refs[name] && statusJSONOnly[name] != "" {
+			t.Errorf("StatusView.%s is rendered but listed in statusJSONOnly", name)
+		}
+	}
+	for name := range statusJSONOnly {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("statusJSONOnly lists %s, which StatusView does not have", name)
+		}
+	}
+
+	// Every name the partial uses is a field or method of the view
+	for ref := range refs {
+		if _, ok := fields[ref]; ok {
+			continue
+		}
+		if _, ok := viewType.MethodByName(ref); ok {
+			continue
+		}
+		t.Errorf("status_panel.html uses .%s, which StatusView does not have", ref)
+	}
+
+	// Every field is in the JSON, under its tag, and nothing else is
+	want := map[string]bool{}
+	for _, f := range fields {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			t.Errorf("StatusView.%s has no json name", f.Name)
+			continue
+		}
+		want[name] = true
+	}
+	for _, f := range statusFields(reflect.TypeOf(statusImperial{})) {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		want[name] = true
+	}
+
+	s := newTestServer(t)
+	rb, err := s.Manager.AddRobot("", "test", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+
+	rec := httptest.NewRecorder()
+	s.robotHandlers().RobotStatus(rec, httptest.NewRequest(http.MethodGet, "/api/robots/status?units=imperial&id="+rb.ID, nil))
+	var got map[string]json.RawMessage
+	decodeJSON(t, rec, &got)
+	for name := range want {
+		if _, ok := got[name]; !ok {
+			t.Errorf("JSON lacks %s", name)
+		}
+	}
+	for name := range got {
+		if !want[name] {
+			t.Errorf("JSON has %s, which StatusView does not declare", name)
+		}
+	}
+}
+
+func TestStatusPartialRenders(t *testing.T) {
+	s := newTestServer(t)
+	rec := httptest.NewRecorder()
+	s.StatusPartial(rec, httptest.NewRequest(http.MethodGet, "/partial/status", nil))
+	if !strings.Contains(rec.Body.String(), "No robot selected") {
+		t.Errorf("no robot: %q", rec.Body.String())
+	}
+
+	rb, err := s.Manager.AddRobot("", "test", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+
+	rec = httptest.NewRecorder()
+	s.StatusPartial(rec, httptest.NewRequest(http.MethodGet, "/partial/status?id="+rb.ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	html := rec.Body.String()
+	for _, want := range []string{"○ disconnected", "Uptime:</span> <span>—", "0 Hz · never", "Reconnects:</span> <span>0"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("partial lacks %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestStatusViewFormatting(t *testing.T) {
+	sec := func(v float64) *float64 { return &v }
+	tests := []struct {
+		in      *float64
+		seconds string
+		age     string
+	}{
+		{nil, "—", "never"},
+		{sec(0.44), "0.4 s", "0.4 s ago"},
+		{sec(59.9), "59.9 s", "59.9 s ago"},
+		{sec(192), "3m12s", "3m12s ago"},
+	}
+	var v StatusView
+	for _, tt := range tests {
+		if got := v.Seconds(tt.in); got != tt.seconds {
+			t.Errorf("Seconds = %q, want %q", got, tt.seconds)
+		}
+		if got := v.Age(tt.in); got != tt.age {
+			t.Errorf("Age = %q, want %q", got, tt.age)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ──────────────────── Templates ────────────────────
+//
+// Every template file is parsed on its own so one broken fragment only
+// disables itself: partials and dialogs are separate sets, and the page
+// (layout + index) is parsed together with the partials it embeds, with
+// a placeholder standing in for any that failed.
+
+// Template globs, by group.
+var (
+	pageTemplates    = []string{"templates/layout.html", "templates/index.html"}
+	partialTemplates = "templates/partials/*.html"
+	dialogTemplates  = "templates/dialogs/*.html"
+)
+
+// TemplateFile is the parse result of one template file.
+type TemplateFile struct {
+	File      string   `json:"file"`
+	Group     string   `json:"group"` // page, partial or dialog
+	Templates []string `json:"templates,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Templates holds the parsed template sets.
+type Templates struct {
+	byName map[string]*template.Template // executable name → set defining it
+	files  []TemplateFile
+}
+
+// brokenPartial replaces a partial that failed to parse inside the page.
+const brokenPartial = `<div class="template-error">Failed to load panel</div>`
+
+// ParseTemplates parses the templates in fsys, logging every file that
+// fails. It returns an error only when no template parsed at all.
+func ParseTemplates(fsys fs.FS, funcs template.FuncMap) (*Templates, error) {
+	t := &Templates{byName: make(map[string]*template.Template)}
+
+	partials, err := fs.Glob(fsys, partialTemplates)
+	if err != nil {
+		return nil, err
+	}
+	dialogs, err := fs.Glob(fsys, dialogTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Partials and dialogs stand alone; keep the good partials' sources
+	// for the page set.
+	var goodPartials []string
+	var brokenNames []string
+	for _, group := range []struct {
+		name  string
+		files []string
+	}{{"partial", partials}, {"dialog", dialogs}} {
+		for _, file := range group.files {
+			set, err := template.New("").Funcs(funcs).ParseFS(fsys, file)
+			if err == nil {
+				err = checkEscapable(set)
+			}
+			if err != nil {
+				t.fail(file, group.name, err)
+				if group.name == "partial" {
+					brokenNames = append(brokenNames, path.Base(file))
+				}
+				continue
+			}
+			t.add(file, group.name, set)
+			if group.name == "partial" {
+				goodPartials = append(goodPartials, file)
+			}
+		}
+	}
+
+	// The page embeds partials by file name; failed ones get the
+	// placeholder so the rest of the page still renders.
+	page := template.New("").Funcs(funcs)
+	for _, name := range brokenNames {
+		template.Must(page.New(name).Parse(brokenPartial))
+	}
+	page, err = page.ParseFS(fsys, append(append([]string{}, pageTemplates...), goodPartials...)...)
+	if err == nil {
+		err = checkEscapable(page)
+	}
+	if err != nil {
+		t.fail(strings.Join(pageTemplates, ", "), "page", err)
+	} else {
+		t.byName["layout.html"] = page
+		t.files = append(t.files, TemplateFile{File: strings.Join(pageTemplates, ", "), Group: "page", Templates: []string{"layout.html"}})
+	}
+
+	if len(t.byName) == 0 {
+		return t, fmt.Errorf("no templates parsed")
+	}
+	return t, nil
+}
+
+// checkEscapable runs html/template's contextual escaping of every
+// template in set, which otherwise first happens (and fails) on use.
+func checkEscapable(set *template.Template) error {
+	for _, tt := range set.Templates() {
+		if tt.Name() == "" || tt.Tree == nil {
+			continue
+		}
+		var escErr *template.Error
+		if err := tt.Execute(io.Discard, nil); errors.As(err, &escErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Templates) add(file, group string, set *template.Template) {
+	tf := TemplateFile{File: file, Group: group}
+	for _, tt := range set.Templates() {
+		if tt.Name() == "" {
+			continue
+		}
+		t.byName[tt.Name()] = set
+		tf.Templates = append(tf.Templates, tt.Name())
+	}
+	sort.Strings(tf.Templates)
+	t.files = append(t.files, tf)
+}
+
+func (t *Templates) fail(file, group string, err error) {
+	log.Printf("[templates] %s failed to parse, its routes will show an error: %v", file, err)
+	t.files = append(t.files, TemplateFile{File: file, Group: group, Error: err.Error()})
+}
+
+// Lookup returns the set that can execute name, or nil.
+func (t *Templates) Lookup(name string) *template.Template {
+	if t == nil {
+		return nil
+	}
+	return t.byName[name]
+}
+
+// Execute renders the named template.
+func (t *Templates) Execute(w io.Writer, name string, data interface{}) error {
+	set := t.Lookup(name)
+	if set == nil {
+		return fmt.Errorf("template %q not available", name)
+	}
+	return set.ExecuteTemplate(w, name, data)
+}
+
+// Files returns the parse result of every template file.
+func (t *Templates) Files() []TemplateFile {
+	if t == nil {
+		return nil
+	}
+	out := append([]TemplateFile(nil), t.files...)
+	sort.Slice(out, func(i, j int) bool { return out[i].File < out[j].File })
+	return out
+}
+
+// Failed returns the files that did not parse.
+func (t *Templates) Failed() []string {
+	var out []string
+	for _, f := range t.Files() {
+		if f.Error != "" {
+			out = append(out, f.File)
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// brokenTemplatesFS is a template tree with one partial that doesn't
+// parse, one that does, and a dialog that parses but can't be escaped.
+func brokenTemplatesFS() fstest.MapFS {
+	return fstest.MapFS{
+		"templates/layout.html":           {Data: []byte(`<main>{{template "broken.html" .}}|{{template "good.html" .}}|{{template "content" .}}</main>`)},
+		"templates/index.html":            {Data: []byte(`{{define "content"}}index{{end}}`)},
+		"templates/partials/broken.html":  {Data: []byte(`{{if .}}never closed`)},
+		"templates/partials/good.html":    {Data: []byte(`<b>{{.}}</b>`)},
+		"templates/dialogs/unescape.html": {Data: []byte(`{{if .}}<a href="{{end}}x">`)},
+		"templates/dialogs/ok.html":       {Data: []byte(`<dialog>{{.}}</dialog>`)},
+	}
+}
+
+func TestParseTemplatesBrokenFragments(t *testing.T) {
+	tmpl, err := ParseTemplates(brokenTemplatesFS(), template.FuncMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"templates/dialogs/unescape.html", "templates/partials/broken.html"}
+	if got := tmpl.Failed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("failed = %v, want %v", got, want)
+	}
+	for _, f := range tmpl.Files() {
+		if (f.Error != "") == (len(f.Templates) > 0) {
+			t.Errorf("%s: templates %v, error %q", f.File, f.Templates, f.Error)
+		}
+	}
+
+	// The page renders, the broken partial as a placeholder
+	var b strings.Builder
+	if err := tmpl.Execute(&b, "layout.html", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<main>" + brokenPartial + "|<b>ada</b>|index</main>"; b.String() != want {
+		t.Errorf("page = %s, want %s", b.String(), want)
+	}
+	if err := tmpl.Execute(&b, "broken.html", nil); err == nil {
+		t.Error("broken partial executed")
+	}
+
+	s := &Server{Templates: tmpl}
+	for _, tc := range []struct {
+		name   string
+		htmx   bool
+		code   int
+		substr string
+	}{
+		{"ok.html", false, http.StatusOK, "<dialog>&lt;x&gt;</dialog>"},
+		{"unescape.html", true, http.StatusOK, brokenPartial},
+		{"broken.html", true, http.StatusOK, brokenPartial},
+		{"unescape.html", false, http.StatusInternalServerError, "could not be rendered"},
+		{"missing.html", false, http.StatusInternalServerError, "could not be rendered"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/partials/x", nil)
+		if tc.htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		s.render(rec, req, tc.name, "<x>")
+		if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.substr) {
+			t.Errorf("render %s (htmx %v): %d %s", tc.name, tc.htmx, rec.Code, rec.Body.String())
+		}
+	}
+
+	// /api/debug/templates lists the failures
+	var resp templatesResponse
+	decodeJSON(t, getReq(s.TemplateStatus, "/api/debug/templates"), &resp)
+	if !reflect.DeepEqual(resp.Failed, want) || len(resp.Files) != 5 {
+		t.Errorf("template status = %+v", resp)
+	}
+}
+
+func TestParseTemplatesBrokenPage(t *testing.T) {
+	fsys := brokenTemplatesFS()
+	fsys["templates/index.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{end}`)}
+	tmpl, err := ParseTemplates(fsys, template.FuncMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Lookup("layout.html") != nil || tmpl.Lookup("good.html") == nil || tmpl.Lookup("ok.html") == nil {
+		t.Errorf("a broken page took the partials and dialogs with it: %+v", tmpl.Files())
+	}
+
+	// Nothing parsed at all
+	if _, err := ParseTemplates(fstest.MapFS{"templates/index.html": fsys["templates/index.html"]}, template.FuncMap{}); err == nil {
+		t.Error("no templates, no error")
+	}
+}
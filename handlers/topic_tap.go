@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── Topic taps ────────────────────
+//
+// With DEBUG_TOPIC_TAP on, a browser can see a robot topic's messages as
+// rosbridge sends them, instead of running a WebSocket client against
+// rosbridge itself: "tap_topic" {"topic": "/odom", "type": ""} starts a
+// tap for the command's robot, "untap_topic" {"topic": "/odom"} ends it.
+// Messages go to the requesting connection only, as raw_topic frames, at
+// most TOPIC_TAP_MAX_RATE a second (the others are counted as dropped)
+// and cut to TOPIC_TAP_MAX_BYTES. A tap expires after TOPIC_TAP_TTL_S
+// and ends when the connection closes. topic_tap frames report each tap
+// started, stopped, expired or refused.
+
+// maxTapsPerClient bounds the taps of one connection.
+const maxTapsPerClient = 8
+
+// minTapBytes is the smallest TOPIC_TAP_MAX_BYTES honoured.
+const minTapBytes = 256
+
+// defaultTapTTL is how long a tap runs when TOPIC_TAP_TTL_S isn't
+// positive.
+const defaultTapTTL = 5 * time.Minute
+
+// tapQueue is how many frames wait for a slow browser before more are
+// dropped; delivery never blocks the robot's message handling.
+const tapQueue = 4
+
+// RawTopicFrame is the "raw_topic" payload.
+type RawTopicFrame struct {
+	Topic     string          `json:"topic"`
+	Msg       json.RawMessage `json:"msg"`   // a JSON string ending in "…[truncated]" when cut
+	Bytes     int             `json:"bytes"` // payload size before truncation
+	Truncated bool            `json:"truncated,omitempty"`
+	Dropped   int             `json:"dropped,omitempty"` // skipped since the previous frame
+}
+
+// TopicTapStatus is the "topic_tap" payload.
+type TopicTapStatus struct {
+	Topic     string     `json:"topic"`
+	State     string     `json:"state"` // started, stopped, expired or refused
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	MaxRate   int        `json:"max_rate,omitempty"`
+	MaxBytes  int        `json:"max_bytes,omitempty"`
+}
+
+// tapKey identifies a tap of a connection.
+type tapKey struct {
+	robotID, topic string
+}
+
+// topicTap is one running tap.
+type topicTap struct {
+	key      tapKey
+	maxBytes int
+	limiter  tapLimiter
+	stop     func() // ends the rosbridge subscription
+	frames   chan RawTopicFrame
+	done     chan struct{}
+}
+
+// tapLimiter is a token bucket allowing rate messages a second, in
+// bursts of up to rate.
+type tapLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+func newTapLimiter(rate int) tapLimiter {
+	return tapLimiter{rate: float64(rate), tokens: float64(rate)}
+}
+
+// allow reports whether a message arriving at now may go out, and how
+// many were dropped since the last one that did.
+func (l *tapLimiter) allow(now time.Time) (ok bool, dropped int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		l.dropped++
+		return false, 0
+	}
+	l.tokens--
+	dropped, l.dropped = l.dropped, 0
+	return true, dropped
+}
+
+// drop counts a message that was allowed but couldn't be queued.
+func (l *tapLimiter) drop() {
+	l.mu.Lock()
+	l.dropped++
+	l.mu.Unlock()
+}
+
+// rawTopicFrame builds the frame of msg, cut to maxBytes.
+func rawTopicFrame(topic string, msg []byte, maxBytes, dropped int) RawTopicFrame {
+	f := RawTopicFrame{Topic: topic, Msg: json.RawMessage(msg), Bytes: len(msg), Dropped: dropped}
+	if len(msg) > maxBytes {
+		cut, _ := json.Marshal(fmt.Sprintf("%s…[truncated, %d bytes]", msg[:maxBytes], len(msg)))
+		f.Msg, f.Truncated = cut, true
+	}
+	return f
+}
+
+// receive runs on the robot's message handling: rate limit, cut and
+// queue msg without waiting on the browser.
+func (t *topicTap) receive(msg json.RawMessage) {
+	ok, dropped := t.limiter.allow(time.Now())
+	if !ok {
+		return
+	}
+	f := rawTopicFrame(t.key.topic, msg, t.maxBytes, dropped)
+	select {
+	case t.frames <- f:
+	default:
+		t.limiter.drop()
+	}
+}
+
+// tapTopic starts (or restarts) a tap of topic on robot robotID for
+// client.
+func (h *WSHandlers) tapTopic(client *wsClient, robotID string, data json.RawMessage) {
+	var req struct {
+		Topic string `json:"topic"`
+		Type  string `json:"type"`
+	}
+	json.Unmarshal(data, &req)
+	refuse := func(reason string) {
+		client.deliver(robot.BroadcastMsg{Type: "topic_tap", RobotID: robotID, TS: time.Now().UnixMilli(),
+			Data: TopicTapStatus{Topic: req.Topic, State: "refused", Reason: reason}})
+	}
+
+	d := h.Config.Dynamic()
+	if !d.DebugTopicTap {
+		refuse("topic taps are disabled (DEBUG_TOPIC_TAP)")
+		return
+	}
+	if !strings.HasPrefix(req.Topic, "/") || strings.ContainsAny(req.Topic, " \t\n") {
+		refuse("topic must be a ROS topic name starting with /")
+		return
+	}
+	rb := h.Robots.GetRobot(robotID)
+	if rb == nil || rb.Client == nil {
+		refuse("robot not found")
+		return
+	}
+
+	ttl := d.TopicTapTTL
+	if ttl <= 0 {
+		ttl = defaultTapTTL
+	}
+
+	key := tapKey{robotID, req.Topic}
+	client.endTap(key, "")
+	t := &topicTap{
+		key:      key,
+		maxBytes: max(d.TopicTapMaxBytes, minTapBytes),
+		limiter:  newTapLimiter(max(d.TopicTapMaxRate, 1)),
+		frames:   make(chan RawTopicFrame, tapQueue),
+		done:     make(chan struct{}),
+	}
+	t.stop = rb.Client.SubscribeRaw(req.Topic, req.Type, t.receive)
+
+	client.mu.Lock()
+	if client.tapsClosed {
+		client.mu.Unlock()
+		t.stop()
+		return
+	}
+	if len(client.taps) >= maxTapsPerClient {
+		client.mu.Unlock()
+		t.stop()
+		refuse(fmt.Sprintf("at most %d taps per connection", maxTapsPerClient))
+		return
+	}
+	if client.taps == nil {
+		client.taps = make(map[tapKey]*topicTap)
+	}
+	client.taps[key] = t
+	client.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	client.deliver(robot.BroadcastMsg{Type: "topic_tap", RobotID: robotID, TS: time.Now().UnixMilli(),
+		Data: TopicTapStatus{Topic: req.Topic, State: "started", ExpiresAt: &expires, MaxRate: int(t.limiter.rate), MaxBytes: t.maxBytes}})
+	go client.runTap(t, ttl)
+}
+
+// untapTopic ends client's tap of topic on robot robotID.
+func (h *WSHandlers) untapTopic(client *wsClient, robotID string, data json.RawMessage) {
+	var req struct {
+		Topic string `json:"topic"`
+	}
+	json.Unmarshal(data, &req)
+	client.endTap(tapKey{robotID, req.Topic}, "stopped")
+}
+
+// runTap forwards t's frames until it ends or ttl passes.
+func (c *wsClient) runTap(t *topicTap, ttl time.Duration) {
+	expire := time.NewTimer(ttl)
+	defer expire.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-expire.C:
+			c.endTap(t.key, "expired")
+			return
+		case f := <-t.frames:
+			c.deliver(robot.BroadcastMsg{Type: "raw_topic", RobotID: t.key.robotID, TS: time.Now().UnixMilli(), Data: f})
+		}
+	}
+}
+
+// endTap ends the tap of key, if running, reporting state unless empty.
+func (c *wsClient) endTap(key tapKey, state string) {
+	c.mu.Lock()
+	t := c.taps[key]
+	delete(c.taps, key)
+	c.mu.Unlock()
+	if t == nil {
+		return
+	}
+	t.stop()
+	close(t.done)
+	if state != "" {
+		c.deliver(robot.BroadcastMsg{Type: "topic_tap", RobotID: key.robotID, TS: time.Now().UnixMilli(),
+			Data: TopicTapStatus{Topic: key.topic, State: state}})
+	}
+}
+
+// endTaps ends every tap of the connection, silently; it is closing.
+func (c *wsClient) endTaps() {
+	c.mu.Lock()
+	taps := c.taps
+	c.taps, c.tapsClosed = nil, true
+	c.mu.Unlock()
+	for _, t := range taps {
+		t.stop()
+		close(t.done)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"rom_go_app/config"
+)
+
+func TestTapLimiter(t *testing.T) {
+	l := newTapLimiter(5)
+	t0 := time.Now()
+	for i := 0; i < 5; i++ {
+		if ok, _ := l.allow(t0); !ok {
+			t.Fatalf("burst message %d refused", i)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow(t0.Add(10 * time.Millisecond)); ok {
+			t.Fatal("allowed over the rate")
+		}
+	}
+	l.drop() // allowed but not queued
+	if ok, dropped := l.allow(t0.Add(300 * time.Millisecond)); !ok || dropped != 4 {
+		t.Errorf("after 300ms: %v, %d dropped, want 4", ok, dropped)
+	}
+	if ok, dropped := l.allow(t0.Add(500 * time.Millisecond)); !ok || dropped != 0 {
+		t.Errorf("second token: %v, %d", ok, dropped)
+	}
+	// Tokens don't pile up past the burst
+	n := 0
+	for ok, _ := l.allow(t0.Add(time.Hour)); ok; ok, _ = l.allow(t0.Add(time.Hour)) {
+		n++
+	}
+	if n != 5 {
+		t.Errorf("burst after an hour: %d", n)
+	}
+}
+
+func TestRawTopicFrame(t *testing.T) {
+	f := rawTopicFrame("/diag", []byte(`{"level":1}`), 256, 3)
+	if string(f.Msg) != `{"level":1}` || f.Bytes != 11 || f.Truncated || f.Dropped != 3 {
+		t.Errorf("small: %+v", f)
+	}
+	big := []byte(`{"data":"` + strings.Repeat("x", 600) + `"}`)
+	f = rawTopicFrame("/diag", big, 256, 0)
+	var cut string
+	if err := json.Unmarshal(f.Msg, &cut); err != nil {
+		t.Fatalf("cut message isn't a JSON string: %v", err)
+	}
+	if !f.Truncated || f.Bytes != len(big) || !strings.HasPrefix(cut, string(big[:256])) || !strings.HasSuffix(cut, "…[truncated, 611 bytes]") {
+		t.Errorf("big: %+v", f)
+	}
+}
+
+// tapFrame reads frames until one of type topic_tap or raw_topic and
+// decodes its data into v.
+func tapFrame(t *testing.T, conn *websocket.Conn, v interface{}) string {
+	t.Helper()
+	for {
+		msg := readFrame(t, conn)
+		if msg.Type != "topic_tap" && msg.Type != "raw_topic" {
+			continue
+		}
+		data, _ := json.Marshal(msg.Data)
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatal(err)
+		}
+		return msg.Type
+	}
+}
+
+// tapStatus reads the next topic_tap frame, skipping raw_topic ones.
+func tapStatus(t *testing.T, conn *websocket.Conn) TopicTapStatus {
+	t.Helper()
+	for {
+		var st TopicTapStatus
+		if tapFrame(t, conn, &st) == "topic_tap" {
+			return st
+		}
+	}
+}
+
+func TestTopicTap(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DEBUG_TOPIC_TAP", "1")
+	cfg, err := config.Load(map[string]string{"TOPIC_TAP_MAX_RATE": "5", "TOPIC_TAP_MAX_BYTES": "256", "TOPIC_TAP_TTL_S": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.Config = cfg
+	f := newFakeRosbridge(t)
+	rb := connectRobot(t, s, f)
+	conn := dialWSHello(t, s)
+	send := func(cmd, topic string) {
+		t.Helper()
+		if err := conn.WriteJSON(map[string]interface{}{"type": cmd, "robot_id": rb.ID, "data": map[string]string{"topic": topic}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("tap_topic", "/diag")
+	if st := tapStatus(t, conn); st.State != "started" || st.MaxRate != 5 || st.MaxBytes != 256 || st.ExpiresAt == nil {
+		t.Fatalf("tap: %+v", st)
+	}
+	waitUntil(t, "the subscription", func() bool { return f.subscribed("/diag") })
+
+	// A flood: every message is either forwarded or counted as dropped
+	for i := 0; i < 20; i++ {
+		f.publish("/diag", map[string]int{"seq": i})
+	}
+	time.Sleep(400 * time.Millisecond)
+	f.publish("/diag", map[string]interface{}{"data": strings.Repeat("x", 600)})
+	frames, dropped := 0, 0
+	for {
+		var fr RawTopicFrame
+		if tapFrame(t, conn, &fr) != "raw_topic" {
+			t.Fatal("tap ended during the flood")
+		}
+		frames++
+		dropped += fr.Dropped
+		if fr.Truncated {
+			if fr.Bytes != 611 || dropped == 0 {
+				t.Errorf("last frame %+v, %d dropped in all", fr, dropped)
+			}
+			break
+		}
+	}
+	if frames > 7 || frames+dropped != 21 {
+		t.Errorf("%d frames forwarded and %d dropped of 21", frames, dropped)
+	}
+
+	send("untap_topic", "/diag")
+	if st := tapStatus(t, conn); st.State != "stopped" {
+		t.Errorf("untap: %+v", st)
+	}
+	waitUntil(t, "the unsubscribe", func() bool { return !f.subscribed("/diag") })
+
+	// Expiry after TOPIC_TAP_TTL_S
+	send("tap_topic", "/diag")
+	tapStatus(t, conn)
+	start := time.Now()
+	if st := tapStatus(t, conn); st.State != "expired" || time.Since(start) < 900*time.Millisecond {
+		t.Errorf("expiry: %+v after %v", st, time.Since(start))
+	}
+	waitUntil(t, "the unsubscribe on expiry", func() bool { return !f.subscribed("/diag") })
+
+	// Refusals
+	for _, topic := range []string{"diag", "/a b"} {
+		send("tap_topic", topic)
+		if st := tapStatus(t, conn); st.State != "refused" || !strings.Contains(st.Reason, "ROS topic name") {
+			t.Errorf("%q: %+v", topic, st)
+		}
+	}
+	conn.WriteJSON(map[string]interface{}{"type": "tap_topic", "robot_id": "42", "data": map[string]string{"topic": "/diag"}})
+	if st := tapStatus(t, conn); st.State != "refused" || st.Reason != "robot not found" {
+		t.Errorf("unknown robot: %+v", st)
+	}
+
+	// Closing the connection ends its taps
+	send("tap_topic", "/scan_raw")
+	tapStatus(t, conn)
+	waitUntil(t, "the subscription", func() bool { return f.subscribed("/scan_raw") })
+	conn.Close()
+	waitUntil(t, "the unsubscribe on close", func() bool { return !f.subscribed("/scan_raw") && len(rb.Client.RawTopics()) == 0 })
+}
+
+func TestTopicTapDisabled(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DEBUG_TOPIC_TAP", "0")
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.Config = cfg
+	rb := connectRobot(t, s, newFakeRosbridge(t))
+	conn := dialWSHello(t, s)
+	conn.WriteJSON(map[string]interface{}{"type": "tap_topic", "robot_id": rb.ID, "data": map[string]string{"topic": "/diag"}})
+	if st := tapStatus(t, conn); st.State != "refused" || !strings.Contains(st.Reason, "DEBUG_TOPIC_TAP") {
+		t.Errorf("disabled: %+v", st)
+	}
+	if n := len(rb.Client.RawTopics()); n != 0 {
+		t.Errorf("%d raw topics", n)
+	}
+}
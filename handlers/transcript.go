@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// ──────────────────────────── Whisper JSON output
+//
+// Whisper hallucinates fluent text ("Thank you for watching!") on silent
+// or noisy clips, so transcriptions are scored before they reach the
+// robot. whisper.cpp (-oj -ojf) writes "transcription" segments whose
+// tokens carry a probability p; openai-whisper writes "segments" with
+// avg_logprob and no_speech_prob. Both are accepted, as is a bare
+// {"text": ...} from speech services that return no segments.
+
+// Transcript is a parsed whisper result.
+type Transcript struct {
+	Text       string  // cleaned text
+	RawText    string  // segments joined as whisper produced them
+	Confidence float64 // 0..1; 1 when the output carries no probabilities
+	Segments   int
+}
+
+type whisperJSON struct {
+	Text          string `json:"text"`
+	Transcription []struct {
+		Text   string `json:"text"`
+		Tokens []struct {
+			Text string   `json:"text"`
+			P    *float64 `json:"p"`
+		} `json:"tokens"`
+	} `json:"transcription"`
+	Segments []struct {
+		Text         string   `json:"text"`
+		AvgLogprob   *float64 `json:"avg_logprob"`
+		NoSpeechProb *float64 `json:"no_speech_prob"`
+	} `json:"segments"`
+}
+
+// annotationRe matches non-speech annotations: [BLANK_AUDIO], (music).
+var annotationRe = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)`)
+
+// CleanTranscript strips bracketed annotations and collapses whitespace.
+func CleanTranscript(s string) string {
+	return strings.Join(strings.Fields(annotationRe.ReplaceAllString(s, " ")), " ")
+}
+
+// ParseWhisperJSON scores and cleans whisper's JSON output. Segment
+// confidences are averaged weighted by the length of their cleaned
+// text, so annotation-only segments don't count. A bare text without
+// segments scores 1; no text at all yields an empty transcript with
+// confidence 0.
+func ParseWhisperJSON(data []byte) (Transcript, error) {
+	var doc whisperJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Transcript{}, fmt.Errorf("invalid whisper JSON: %w", err)
+	}
+
+	type segment struct {
+		text string
+		conf float64
+	}
+	var segs []segment
+	for _, s := range doc.Transcription {
+		var sum float64
+		var n int
+		for _, tok := range s.Tokens {
+			// Special tokens ([_BEG_], [_TT_150], <|endoftext|>) aren't speech
+			if tok.P == nil || strings.HasPrefix(tok.Text, "[_") || strings.HasPrefix(tok.Text, "<|") {
+				continue
+			}
+			sum += *tok.P
+			n++
+		}
+		conf := 1.0
+		if n > 0 {
+			conf = sum / float64(n)
+		}
+		segs = append(segs, segment{s.Text, conf})
+	}
+	for _, s := range doc.Segments {
+		conf := 1.0
+		if s.AvgLogprob != nil {
+			conf = math.Exp(*s.AvgLogprob)
+		}
+		if s.NoSpeechProb != nil {
+			conf *= 1 - *s.NoSpeechProb
+		}
+		segs = append(segs, segment{s.Text, conf})
+	}
+	if len(segs) == 0 && strings.TrimSpace(doc.Text) != "" {
+		segs = append(segs, segment{doc.Text, 1})
+	}
+
+	t := Transcript{Segments: len(segs)}
+	var raw []string
+	var weighted, weight float64
+	for _, s := range segs {
+		raw = append(raw, strings.TrimSpace(s.text))
+		n := float64(len(CleanTranscript(s.text)))
+		weighted += s.conf * n
+		weight += n
+	}
+	t.RawText = strings.Join(raw, " ")
+	t.Text = CleanTranscript(t.RawText)
+	if weight > 0 {
+		t.Confidence = math.Max(0, math.Min(1, weighted/weight))
+	}
+	return t, nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseWhisperJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name, json string
+		text, raw  string
+		conf       float64
+		segments   int
+	}{
+		{
+			name: "whisper.cpp tokens",
+			json: `{"transcription":[
+				{"text":" Go to the kitchen.","tokens":[
+					{"text":"[_BEG_]","p":0.1},{"text":" Go","p":0.9},{"text":" to","p":0.8},
+					{"text":" the kitchen","p":0.7},{"text":"<|endoftext|>","p":0.01}]}]}`,
+			text: "Go to the kitchen.", raw: "Go to the kitchen.", conf: 0.8, segments: 1,
+		},
+		{
+			name: "openai-whisper segments",
+			json: `{"segments":[{"text":" stop","avg_logprob":-0.5,"no_speech_prob":0.2}]}`,
+			text: "stop", raw: "stop", conf: math.Exp(-0.5) * 0.8, segments: 1,
+		},
+		{
+			name: "weighted by cleaned length",
+			json: `{"segments":[
+				{"text":"forward two","avg_logprob":0},
+				{"text":"[BLANK_AUDIO]","avg_logprob":-5},
+				{"text":"now","avg_logprob":-100}]}`,
+			text: "forward two now", raw: "forward two [BLANK_AUDIO] now", conf: 11.0 / 14, segments: 3,
+		},
+		{
+			name: "bare text",
+			json: `{"text":"  go home (music) "}`,
+			text: "go home", raw: "go home (music)", conf: 1, segments: 1,
+		},
+		{
+			name: "segment without tokens",
+			json: `{"transcription":[{"text":" left","tokens":[]}]}`,
+			text: "left", raw: "left", conf: 1, segments: 1,
+		},
+		{
+			name: "empty segments",
+			json: `{"transcription":[{"text":"","tokens":[]},{"text":" [BLANK_AUDIO]","tokens":[{"text":" [","p":0.9}]}]}`,
+			text: "", raw: " [BLANK_AUDIO]", conf: 0, segments: 2,
+		},
+		{
+			name: "empty transcription",
+			json: `{"transcription":[]}`,
+		},
+		{
+			name: "nothing",
+			json: `{"text":"   "}`,
+		},
+	} {
+		got, err := ParseWhisperJSON([]byte(tc.json))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got.Text != tc.text || got.RawText != tc.raw || got.Segments != tc.segments || math.Abs(got.Confidence-tc.conf) > 1e-9 {
+			t.Errorf("%s: %+v, want text %q raw %q confidence %.4f segments %d", tc.name, got, tc.text, tc.raw, tc.conf, tc.segments)
+		}
+	}
+
+	if _, err := ParseWhisperJSON([]byte(`{"transcription":`)); err == nil {
+		t.Error("truncated JSON parsed")
+	}
+}
+
+func TestCleanTranscript(t *testing.T) {
+	for in, want := range map[string]string{
+		"[BLANK_AUDIO]":                 "",
+		" (wind blowing)  go\n home ":   "go home",
+		"turn [inaudible] left (music)": "turn left",
+		"":                              "",
+	} {
+		if got := CleanTranscript(in); got != want {
+			t.Errorf("CleanTranscript(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ──────────────────── Usage statistics ────────────────────
+
+// UsageStats handles GET /api/robots/stats?id=X
+//
+// Distance traveled and active time since the last reset, with the daily
+// usage of the last 30 days, for maintenance scheduling.
+func (h *RobotHandlers) UsageStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+	jsonOK(w, rb.UsageReport())
+}
+
+// ResetUsageStats handles POST /api/robots/stats/reset?id=X&confirm=1
+//
+// Zeroes the distance and active-time totals, after maintenance. The
+// daily history is kept; who reset them, and the optional note, are
+// recorded.
+func (h *RobotHandlers) ResetUsageStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+	if r.FormValue("confirm") != "1" {
+		jsonError(w, "resetting usage discards the distance and active-time totals: confirm=1 required", http.StatusBadRequest)
+		return
+	}
+	reset := rb.ResetUsage(clientAddr(r), strings.TrimSpace(r.FormValue("note")))
+	jsonOK(w, resetUsageResponse{Status: "reset", Reset: reset})
+}
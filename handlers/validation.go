@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────── Request validation ────────────────────
+//
+// Every error response has the same shape: {error, code, fields}. error
+// is the message for people, code a stable snake_case identifier for
+// clients and fields, on validation failures only, names each offending
+// parameter with what is wrong with it. Handlers read their parameters
+// through a params value, which collects a failure per field instead of
+// stopping at the first, then answer 400 with all of them at once.
+
+// codeInvalidParams is the error code of validation failures.
+const codeInvalidParams = "invalid_params"
+
+// fieldErrors maps a parameter name to what is wrong with it.
+type fieldErrors map[string]string
+
+// add records msg for name unless name already failed.
+func (fe fieldErrors) add(name, msg string) {
+	if _, ok := fe[name]; !ok {
+		fe[name] = msg
+	}
+}
+
+// Error lists the failures by parameter name.
+func (fe fieldErrors) Error() string {
+	names := make([]string, 0, len(fe))
+	for name := range fe {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + fe[name]
+	}
+	return "invalid parameters: " + strings.Join(parts, "; ")
+}
+
+// errorCode is the code of a non-validation error with HTTP status
+// code: the status text in snake_case ("not_found", "conflict").
+func errorCode(code int) string {
+	text := http.StatusText(code)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// jsonFieldErrors answers 400 with the validation failures in fe.
+func jsonFieldErrors(w http.ResponseWriter, fe fieldErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(errorResponse{Error: fe.Error(), Code: codeInvalidParams, Fields: fe})
+}
+
+// params reads request parameters (query or form, like r.FormValue) and
+// collects what is wrong with them.
+type params struct {
+	get  func(string) string
+	errs fieldErrors
+}
+
+// formParams reads the parameters of r.
+func formParams(r *http.Request) *params {
+	return &params{get: r.FormValue, errs: fieldErrors{}}
+}
+
+// fail records a failure for name.
+func (p *params) fail(name, msg string) {
+	p.errs.add(name, msg)
+}
+
+// invalid answers 400 with the collected failures and reports whether
+// there were any.
+func (p *params) invalid(w http.ResponseWriter) bool {
+	if len(p.errs) == 0 {
+		return false
+	}
+	jsonFieldErrors(w, p.errs)
+	return true
+}
+
+// str returns the value of name, trimmed; empty if not given.
+func (p *params) str(name string) string {
+	return strings.TrimSpace(p.get(name))
+}
+
+// requiredStr returns the value of name, failing when it is empty.
+func (p *params) requiredStr(name string) string {
+	v := p.str(name)
+	if v == "" {
+		p.fail(name, "required")
+	}
+	return v
+}
+
+// float returns name as a number in [lo, hi], or def when not given.
+func (p *params) float(name string, def, lo, hi float64) float64 {
+	v := p.str(name)
+	if v == "" {
+		return def
+	}
+	return p.parseFloat(name, v, def, lo, hi)
+}
+
+// requiredFloat returns name as a number in [lo, hi], failing when it
+// is not given.
+func (p *params) requiredFloat(name string, lo, hi float64) float64 {
+	v := p.str(name)
+	if v == "" {
+		p.fail(name, "required")
+		return 0
+	}
+	return p.parseFloat(name, v, 0, lo, hi)
+}
+
+func (p *params) parseFloat(name, v string, def, lo, hi float64) float64 {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f != f {
+		p.fail(name, fmt.Sprintf("must be a number, got %q", v))
+		return def
+	}
+	if f < lo || f > hi {
+		p.fail(name, rangeMsg(lo, hi))
+		return def
+	}
+	return f
+}
+
+// integer returns name as an integer in [lo, hi], or def when not given.
+func (p *params) integer(name string, def, lo, hi int) int {
+	return int(p.int64(name, int64(def), int64(lo), int64(hi)))
+}
+
+// int64 is integer for 64-bit values such as unix millisecond times.
+func (p *params) int64(name string, def, lo, hi int64) int64 {
+	v := p.str(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		p.fail(name, fmt.Sprintf("must be an integer, got %q", v))
+		return def
+	}
+	if n < lo || n > hi {
+		p.fail(name, rangeMsg(float64(lo), float64(hi)))
+		return def
+	}
+	return n
+}
+
+// requiredInt64 returns name as an integer in [lo, hi], failing when it
+// is not given.
+func (p *params) requiredInt64(name string, lo, hi int64) int64 {
+	if p.str(name) == "" {
+		p.fail(name, "required")
+		return 0
+	}
+	return p.int64(name, 0, lo, hi)
+}
+
+// rangeMsg describes the bounds [lo, hi]; a bound beyond ±MaxInt32
+// stands for "none" and is left out.
+func rangeMsg(lo, hi float64) string {
+	const huge = math.MaxInt32
+	switch {
+	case hi >= huge:
+		return fmt.Sprintf("must be at least %g", lo)
+	case lo <= -huge:
+		return fmt.Sprintf("must be at most %g", hi)
+	default:
+		return fmt.Sprintf("must be between %g and %g", lo, hi)
+	}
+}
+
+// boolean returns name as 1/true or 0/false, or def when not given.
+func (p *params) boolean(name string, def bool) bool {
+	switch p.str(name) {
+	case "":
+		return def
+	case "1", "true":
+		return true
+	case "0", "false":
+		return false
+	}
+	p.fail(name, "must be 1, true, 0 or false")
+	return def
+}
+
+// enum returns name if it is one of allowed, or def when not given.
+func (p *params) enum(name, def string, allowed ...string) string {
+	v := p.str(name)
+	if v == "" {
+		return def
+	}
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	p.fail(name, "must be one of "+strings.Join(allowed, ", "))
+	return def
+}
+
+// mapName returns name as a map name (see robot.ValidateMapName), empty
+// when not given.
+func (p *params) mapName(name string) string {
+	v := p.str(name)
+	if v == "" {
+		return ""
+	}
+	if err := robot.ValidateMapName(v); err != nil {
+		p.fail(name, err.Error())
+		return ""
+	}
+	return v
+}
+
+// pointType returns name as a point type, failing when it is missing or
+// unknown, or is wall and walls aren't accepted (why says what they
+// can't do).
+func (p *params) pointType(name string, walls bool, why string) rosbridge.PointType {
+	v := p.str(name)
+	if v == "" {
+		p.fail(name, "required")
+		return ""
+	}
+	t, err := rosbridge.ParsePointType(v)
+	if err != nil {
+		p.fail(name, err.Error())
+		return ""
+	}
+	if !walls && !t.Navigable() {
+		p.fail(name, fmt.Sprintf("%s %q: %s", rosbridge.ErrInvalidPointType, v, why))
+		return ""
+	}
+	return t
+}
+
+// btTemplate returns name as a behavior tree template for rb's points of
+// type t (empty: the robot's default), failing for walls and for a
+// template the robot doesn't offer. A robot listing capabilities without
+// it is refused later as unsupported, not here.
+func (p *params) btTemplate(name string, rb *robot.Robot, t rosbridge.PointType) string {
+	v := p.str(name)
+	if v == "" {
+		return ""
+	}
+	if !t.Navigable() {
+		p.fail(name, "walls have no behavior tree")
+		return ""
+	}
+	if errors.Is(rb.CheckBTTemplate(v), robot.ErrUnknownBTTemplate) {
+		p.fail(name, "must be one of "+strings.Join(rb.BTTemplates(), ", "))
+		return ""
+	}
+	return v
+}
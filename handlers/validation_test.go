@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"rom_go_app/robot"
+)
+
+func testParams(values url.Values) *params {
+	return &params{get: values.Get, errs: fieldErrors{}}
+}
+
+// TestParamsCollect checks every failing field is reported, each once,
+// and valid or absent ones take their value or default.
+func TestParamsCollect(t *testing.T) {
+	p := testParams(url.Values{
+		"name":    {"  dock  "},
+		"x":       {"abc"},
+		"y":       {"NaN"},
+		"speed":   {"5"},
+		"count":   {"1.5"},
+		"force":   {"yes"},
+		"mode":    {"fast"},
+		"since":   {"1700000000000"},
+		"empty":   {"   "},
+		"lenient": {"true"},
+	})
+	if got := p.requiredStr("name"); got != "dock" {
+		t.Errorf("name %q", got)
+	}
+	p.requiredStr("empty")
+	p.requiredFloat("x", math.Inf(-1), math.Inf(1))
+	p.requiredFloat("x", 0, 1) // a second failure of x is not recorded
+	p.float("y", 0, math.Inf(-1), math.Inf(1))
+	if got := p.float("speed", 1, 0, 2); got != 1 {
+		t.Errorf("out-of-range speed read as %v, want the default", got)
+	}
+	p.integer("count", 0, 0, 10)
+	p.boolean("force", false)
+	if !p.boolean("lenient", false) || p.boolean("absent", false) {
+		t.Error("boolean")
+	}
+	p.enum("mode", "slow", "slow", "normal")
+	if got := p.enum("absent", "slow", "slow", "normal"); got != "slow" {
+		t.Errorf("enum default %q", got)
+	}
+	if got := p.int64("since", 0, 0, math.MaxInt64); got != 1700000000000 {
+		t.Errorf("since %d", got)
+	}
+	p.requiredInt64("op", 0, 10)
+
+	want := fieldErrors{
+		"empty": "required",
+		"x":     `must be a number, got "abc"`,
+		"y":     `must be a number, got "NaN"`,
+		"speed": "must be between 0 and 2",
+		"count": `must be an integer, got "1.5"`,
+		"force": "must be 1, true, 0 or false",
+		"mode":  "must be one of slow, normal",
+		"op":    "required",
+	}
+	if len(p.errs) != len(want) {
+		t.Errorf("failures %v", p.errs)
+	}
+	for name, msg := range want {
+		if p.errs[name] != msg {
+			t.Errorf("%s: %q, want %q", name, p.errs[name], msg)
+		}
+	}
+	if msg := p.errs.Error(); !strings.HasPrefix(msg, "invalid parameters: count: ") || !strings.HasSuffix(msg, `y: must be a number, got "NaN"`) {
+		t.Errorf("message %q, want fields sorted by name", msg)
+	}
+}
+
+func TestRangeMsg(t *testing.T) {
+	for _, c := range []struct {
+		lo, hi float64
+		want   string
+	}{
+		{0, 2, "must be between 0 and 2"},
+		{0.05, math.Inf(1), "must be at least 0.05"},
+		{math.Inf(-1), 10, "must be at most 10"},
+		{1, math.MaxInt64, "must be at least 1"},
+	} {
+		if got := rangeMsg(c.lo, c.hi); got != c.want {
+			t.Errorf("rangeMsg(%v, %v) = %q", c.lo, c.hi, got)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusBadRequest:            "bad_request",
+		http.StatusNotFound:              "not_found",
+		http.StatusConflict:              "conflict",
+		http.StatusTooManyRequests:       "too_many_requests",
+		http.StatusNotImplemented:        "not_implemented",
+		http.StatusServiceUnavailable:    "service_unavailable",
+		http.StatusRequestEntityTooLarge: "request_entity_too_large",
+		http.StatusTeapot:                "im_a_teapot",
+		599:                              "error",
+	} {
+		if got := errorCode(status); got != want {
+			t.Errorf("errorCode(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+// errorEnvelope returns the error response of rec, failing unless it has
+// status and code.
+func errorEnvelope(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) errorResponse {
+	t.Helper()
+	var e errorResponse
+	decodeJSON(t, rec, &e)
+	if rec.Code != status || e.Code != code || e.Error == "" {
+		t.Errorf("%d %+v, want %d %s", rec.Code, e, status, code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	return e
+}
+
+// TestErrorEnvelope sends bad requests to handlers across the API and
+// checks each answers {error, code} and, for validation failures, names
+// every bad field.
+func TestErrorEnvelope(t *testing.T) {
+	s := newTestServer(t)
+	s.NavManager = robot.NewNavigationManager()
+	rb, _ := s.Manager.AddRobot("", "a", "127.0.0.1", 9)
+	defer rb.Close()
+	mux := http.NewServeMux()
+	Register(mux, s.Routes())
+	do := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if method == http.MethodPost {
+			req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, target+"?"+form.Encode(), nil)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, c := range []struct {
+		method, path string
+		form         url.Values
+		fields       []string
+	}{
+		{"POST", "/api/nav/add", url.Values{"type": {"waypoints"}}, []string{"name", "world_x", "world_y"}},
+		{"POST", "/api/nav/add", url.Values{"type": {"rocket"}, "name": {"a"}, "world_x": {"one"}, "world_y": {"2"}, "dwell_sec": {"-1"}},
+			[]string{"type", "world_x", "dwell_sec"}},
+		{"POST", "/api/robots/switch", nil, []string{"id"}},
+		{"POST", "/api/robots/task", nil, []string{"task"}},
+		{"GET", "/api/maps/save_status", nil, []string{"op"}},
+		{"GET", "/api/robots/velocity_history", url.Values{"since": {"yesterday"}, "resolution": {"1h"}}, []string{"since", "resolution"}},
+	} {
+		rec := do(c.method, c.path, c.form)
+		e := errorEnvelope(t, rec, http.StatusBadRequest, codeInvalidParams)
+		if len(e.Fields) != len(c.fields) {
+			t.Errorf("%s %s: fields %v, want %v", c.method, c.path, e.Fields, c.fields)
+		}
+		for _, f := range c.fields {
+			if e.Fields[f] == "" {
+				t.Errorf("%s %s: %s not named in %v", c.method, c.path, f, e.Fields)
+			}
+		}
+	}
+
+	// Other failures: same shape, no fields
+	if rec := do("POST", "/api/nav/add", url.Values{"type": {"waypoints"}, "name": {"a"}, "world_x": {"1"}, "world_y": {"2"}}); rec.Code != http.StatusOK {
+		t.Fatalf("add: %d %s", rec.Code, rec.Body)
+	}
+	for _, c := range []struct {
+		method, path string
+		form         url.Values
+		status       int
+	}{
+		{"GET", "/api/robots/status", url.Values{"id": {"42"}}, http.StatusNotFound},
+		{"POST", "/api/robots/switch", url.Values{"id": {"42"}}, http.StatusNotFound},
+		{"POST", "/api/nav/add", url.Values{"type": {"waypoints"}, "name": {"a"}, "world_x": {"1"}, "world_y": {"2"}}, http.StatusBadRequest}, // the name is taken
+	} {
+		rec := do(c.method, c.path, c.form)
+		if e := errorEnvelope(t, rec, c.status, errorCode(c.status)); e.Fields != nil {
+			t.Errorf("%s %s: fields %v", c.method, c.path, e.Fields)
+		}
+	}
+}
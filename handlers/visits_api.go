@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────── Point visits ────────────────────
+
+// maxVisitsLimit bounds limit.
+const maxVisitsLimit = robot.VisitHistoryMax
+
+// NavVisits handles GET /api/nav/visits?id=X[&since=ms][&until=ms][&type=T][&name=N][&limit=N]
+//
+// The robot's visits to its points, newest first, and per point the
+// visits in the range (count, today), all-time total and last visit.
+func (h *NavHandlers) NavVisits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rb := h.lookupRobot(w, r.FormValue("id"))
+	if rb == nil {
+		return
+	}
+
+	p := formParams(r)
+	since := p.int64("since", 0, 0, math.MaxInt64)
+	until := p.int64("until", 0, 0, math.MaxInt64)
+	if until > 0 && until <= since {
+		p.fail("until", "must be after since")
+	}
+	var q robot.VisitQuery
+	if p.str("type") != "" {
+		q.Type = p.pointType("type", false, "walls have no visits")
+	}
+	q.Name = p.str("name")
+	q.Limit = p.integer("limit", 0, 0, maxVisitsLimit)
+	if p.invalid(w) {
+		return
+	}
+	if since > 0 {
+		q.Since = time.UnixMilli(since)
+	}
+	if until > 0 {
+		q.Until = time.UnixMilli(until)
+	}
+	jsonOK(w, rb.VisitReport(q))
+}
+
+// lastVisited returns when point name of type t was last reached, or
+// nil; visits is rb.LastVisits().
+func lastVisited(visits map[rosbridge.PointType]map[string]time.Time, t rosbridge.PointType, name string) *time.Time {
+	at, ok := visits[t][name]
+	if !ok {
+		return nil
+	}
+	return &at
+}
+
+// visitedPoint is a navigation point with its last visit.
+type visitedPoint struct {
+	rosbridge.NavigationPoint
+	LastVisited *time.Time `json:"last_visited,omitempty"`
+}
+
+func visitedPoints(pts []rosbridge.NavigationPoint, visits map[rosbridge.PointType]map[string]time.Time, t rosbridge.PointType) []visitedPoint {
+	out := make([]visitedPoint, len(pts))
+	for i, p := range pts {
+		out[i] = visitedPoint{p, lastVisited(visits, t, p.Name)}
+	}
+	return out
+}
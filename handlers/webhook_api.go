@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"rom_go_app/webhook"
+)
+
+// ──────────────────── Webhooks ────────────────────
+
+// webhooksEnabled answers 503 when the app runs without webhooks.
+func (s *Server) webhooksEnabled(w http.ResponseWriter) bool {
+	if s.Webhooks == nil {
+		jsonError(w, "webhooks disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// ListWebhooks handles GET /api/webhooks
+func (s *Server) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	jsonOK(w, webhooksResponse{Webhooks: s.Webhooks.List(), Events: webhook.Events})
+}
+
+// AddWebhook handles POST /api/webhooks
+//
+// Registers an endpoint for url, signed with secret if given, receiving
+// the comma-separated events (default: all); enabled=0 adds it paused.
+func (s *Server) AddWebhook(w http.ResponseWriter, r *http.Request) {
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	e := webhook.Endpoint{Enabled: true}
+	applyWebhookForm(r, &e)
+	e, err := s.Webhooks.Add(e)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonOK(w, webhookResponse{Webhook: e})
+}
+
+// UpdateWebhook handles PUT /api/webhooks?id=X
+//
+// Changes the given url, secret (empty removes it), events and enabled;
+// unset parameters are left unchanged.
+func (s *Server) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	e, err := s.Webhooks.Update(r.FormValue("id"), func(e *webhook.Endpoint) {
+		applyWebhookForm(r, e)
+	})
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonOK(w, webhookResponse{Webhook: e})
+}
+
+// applyWebhookForm copies the endpoint parameters present in r onto e.
+func applyWebhookForm(r *http.Request, e *webhook.Endpoint) {
+	r.ParseForm()
+	if _, ok := r.Form["url"]; ok {
+		e.URL = strings.TrimSpace(r.FormValue("url"))
+	}
+	if _, ok := r.Form["secret"]; ok {
+		e.Secret = r.FormValue("secret")
+	}
+	if _, ok := r.Form["events"]; ok {
+		e.Events = nil
+		for _, ev := range strings.Split(r.FormValue("events"), ",") {
+			if ev = strings.TrimSpace(ev); ev != "" {
+				e.Events = append(e.Events, ev)
+			}
+		}
+	}
+	if v := r.FormValue("enabled"); v != "" {
+		e.Enabled = v == "1" || v == "true" || v == "on"
+	}
+}
+
+// RemoveWebhook handles DELETE /api/webhooks?id=X
+func (s *Server) RemoveWebhook(w http.ResponseWriter, r *http.Request) {
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		jsonError(w, "id required", http.StatusBadRequest)
+		return
+	}
+	if err := s.Webhooks.Remove(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, webhook.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		jsonError(w, err.Error(), status)
+		return
+	}
+	jsonOK(w, statusResponse{Status: "removed"})
+}
+
+// WebhookDeliveries handles GET /api/webhooks/deliveries[?id=X]
+//
+// Recent delivery attempts, newest first, for one endpoint or all.
+func (s *Server) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	id := r.FormValue("id")
+	if id != "" {
+		if _, err := s.Webhooks.Get(id); err != nil {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	jsonOK(w, webhookDeliveriesResponse{Deliveries: s.Webhooks.Deliveries(id)})
+}
+
+// TestWebhook handles POST /api/webhooks/test?id=X
+//
+// Posts a test event to the endpoint once, without retries, and reports
+// the attempt; a failed delivery is still a 200 with ok=false.
+func (s *Server) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.webhooksEnabled(w) {
+		return
+	}
+	d, err := s.Webhooks.Test(r.Context(), r.FormValue("id"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	jsonOK(w, webhookTestResponse{Delivery: d})
+}
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"rom_go_app/config"
 	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
 
 	"github.com/gorilla/websocket"
 )
@@ -18,16 +23,161 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin:     func(r *http.Request) bool { return true },
 }
 
+// wsHub is what the WebSocket handler needs of the fleet: its robots,
+// broadcasts, idle watches and control leases; *robot.Manager is one.
+type wsHub interface {
+	robotFinder
+	GetAllRobots() []*robot.Robot
+	SwitchRobot(id string) error
+	Subscribe() chan robot.BroadcastMsg
+	Unsubscribe(ch chan robot.BroadcastMsg)
+	Sequences() map[string]map[string]uint64
+	Stamp(msg robot.BroadcastMsg) robot.BroadcastMsg
+	RecentNotices(since time.Time, robotID string) []robot.Notice
+	ReportError(robotID, source, message string) robot.Notice
+	Watch(ids ...string)
+	Unwatch(ids ...string)
+	ReleaseControlClient(clientID string)
+}
+
+// WSHandlers serves the browser WebSocket (see Routes). Chaos is the
+// browser fault injection, changed in place by the chaos API; UI reports
+// the enabled features.
+type WSHandlers struct {
+	Robots  wsHub
+	Config  *config.Config
+	Origins *OriginPolicy
+	Chaos   *rosbridge.ChaosSettings
+	UI      func() UIConfig
+}
+
+// wsClient is the per-connection state of a browser WebSocket.
+type wsClient struct {
+	driver  robot.Driver // identifies the connection's joystick input
+	conn    *websocket.Conn
+	out     rosbridge.Conn // conn behind the browser chaos shim
+	writeMu sync.Mutex
+
+	mu        sync.RWMutex
+	version   int      // negotiated protocol version (1 until hello)
+	reduced   bool     // below the minimum version (v1 until hello): status frames only
+	encoding  string   // map payload encoding
+	bandwidth bool     // opted in to "bandwidth" reports
+	watching  []string // robot IDs kept active for this client (see "watch")
+
+	taps       map[tapKey]*topicTap // see topic_tap.go
+	tapsClosed bool                 // the connection is closing
+}
+
+var wsClientSeq atomic.Uint64
+
+func newWSClient(conn *websocket.Conn, chaos *rosbridge.ChaosSettings, addr string) *wsClient {
+	return &wsClient{
+		driver:   robot.Driver{ID: fmt.Sprintf("ws-%d", wsClientSeq.Add(1)), Label: addr},
+		conn:     conn,
+		out:      rosbridge.NewChaosConn(conn, chaos),
+		version:  1,
+		encoding: EncodingPlain,
+	}
+}
+
+// WebSocket frame counters for /metrics: frames written, and frames
+// encoded for a single connection rather than once per broadcast.
+var wsFramesSent, wsConnectionEncodes atomic.Uint64
+
+// send encodes and writes a frame for this connection alone.
+func (c *wsClient) send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	wsConnectionEncodes.Add(1)
+	return c.write(data)
+}
+
+// sendFrame writes a broadcast frame with the encoding its copies share
+// (see robot.BroadcastMsg.JSON), unless this connection re-encodes it.
+func (c *wsClient) sendFrame(msg robot.BroadcastMsg) error {
+	if c.reencodes(msg) {
+		return c.send(c.prepare(msg))
+	}
+	data, err := msg.JSON()
+	if err != nil {
+		return err
+	}
+	return c.write(data)
+}
+
+// write writes an encoded frame; gorilla connections allow only one
+// writer at a time.
+func (c *wsClient) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	wsFramesSent.Add(1)
+	return c.out.WriteMessage(websocket.TextMessage, data)
+}
+
+// allows reports whether a message type may be delivered to this client.
+func (c *wsClient) allows(msgType string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.reduced {
+		return reducedModeTypes[msgType]
+	}
+	if msgType == "bandwidth" && !c.bandwidth {
+		return false
+	}
+	return messageVersion(msgType) <= c.version
+}
+
+// reencodes reports whether the connection's negotiated encoding changes
+// msg: map frames for base64_rle clients.
+func (c *wsClient) reencodes(msg robot.BroadcastMsg) bool {
+	if msg.Type != "map" {
+		return false
+	}
+	c.mu.RLock()
+	enc := c.encoding
+	c.mu.RUnlock()
+	_, ok := msg.Data.(robot.MapFrame)
+	return ok && enc == EncodingBase64RLE
+}
+
+// prepare applies the connection's negotiated encoding to a frame.
+func (c *wsClient) prepare(msg robot.BroadcastMsg) robot.BroadcastMsg {
+	if c.reencodes(msg) {
+		msg.Data = encodeMapRLE(msg.Data.(robot.MapFrame))
+	}
+	return msg
+}
+
+// deliver sends a frame if the client's capabilities allow it.
+func (c *wsClient) deliver(msg robot.BroadcastMsg) error {
+	if !c.allows(msg.Type) {
+		return nil
+	}
+	return c.send(c.prepare(msg))
+}
+
 // WSHandler upgrades HTTP to WebSocket and bridges browser  ↔  robot data.
-func (s *Server) WSHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func (h *WSHandlers) WSHandler(w http.ResponseWriter, r *http.Request) {
+	up := upgrader
+	up.CheckOrigin = h.Origins.CheckWSOrigin
+	conn, err := up.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("[ws] upgrade error: %v", err)
 		return
 	}
+	client := newWSClient(conn, h.Chaos, clientAddr(r))
+	// v1 clients never say hello, so until one arrives the connection is
+	// held to the minimum version like any other v1 client.
+	client.reduced = client.version < h.minWSClientVersion()
 
-	// Subscribe to robot manager broadcasts
-	bcast := s.Manager.Subscribe()
+	// Subscribe to robot manager broadcasts; every frame queued from here
+	// on is newer than the sequence numbers taken just before.
+	seqs := h.Robots.Sequences()
+	bcast := h.Robots.Subscribe()
 
 	done := make(chan struct{})
 	var closeOnce sync.Once
@@ -35,47 +185,83 @@ func (s *Server) WSHandler(w http.ResponseWriter, r *http.Request) {
 	cleanup := func() {
 		closeOnce.Do(func() {
 			close(done)
-			s.Manager.Unsubscribe(bcast)
-			conn.Close()
+			h.Robots.Unsubscribe(bcast)
+			client.mu.Lock()
+			h.Robots.Unwatch(client.watching...)
+			client.watching = nil
+			client.mu.Unlock()
+			client.endTaps()
+			h.Robots.ReleaseControlClient(client.driver.ID)
+			client.out.Close()
 		})
 	}
 	defer cleanup()
 
+	// Announce protocol version and capabilities before anything else.
+	hello := h.buildHello()
+	hello.ClientID = client.driver.ID
+	if err := client.send(robot.BroadcastMsg{Type: "hello", Data: hello}); err != nil {
+		log.Printf("[ws] hello write error: %v", err)
+		return
+	}
+	reset := robot.BroadcastMsg{Type: "stream_reset", TS: time.Now().UnixMilli(), Data: StreamReset{Seqs: seqs}}
+	if err := client.send(reset); err != nil {
+		log.Printf("[ws] stream_reset write error: %v", err)
+		return
+	}
+
 	// Writer goroutine: forward broadcast messages to browser
 	var lastMapSend time.Time
+	var heldMap *robot.BroadcastMsg // latest throttled map frame
+	var mapDue <-chan time.Time     // fires when heldMap may go out
 	go func() {
 		defer cleanup()
 		for {
+			var msg robot.BroadcastMsg
 			select {
 			case <-done:
 				return
-			case msg, ok := <-bcast:
+			case <-mapDue:
+				// A repeated grid sends no new map frame, so a throttled
+				// one must still go out
+				msg, heldMap, mapDue = *heldMap, nil, nil
+				lastMapSend = time.Now()
+			case m, ok := <-bcast:
 				if !ok {
 					return
 				}
+				msg = m
+				if !client.allows(msg.Type) {
+					continue
+				}
+
 				// Throttle map data to ~2 fps to browser (maps are large)
 				if msg.Type == "map" {
 					now := time.Now()
-					if now.Sub(lastMapSend) < 500*time.Millisecond {
+					if wait := 500*time.Millisecond - now.Sub(lastMapSend); wait > 0 {
+						if heldMap == nil {
+							mapDue = time.After(wait)
+						}
+						heldMap = &msg
 						continue
 					}
 					lastMapSend = now
+					heldMap, mapDue = nil, nil
 				}
 
 				// Throttle laser data to ~5 fps
 				if msg.Type == "laser" {
 					// Skip some laser frames to reduce bandwidth
 				}
+			}
 
-				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-				if err := conn.WriteJSON(msg); err != nil {
-					if !websocket.IsCloseError(err,
-						websocket.CloseNormalClosure,
-						websocket.CloseGoingAway) {
-						log.Printf("[ws] write error: %v", err)
-					}
-					return
+			if err := client.sendFrame(msg); err != nil {
+				if !websocket.IsCloseError(err,
+					websocket.CloseNormalClosure,
+					websocket.CloseGoingAway) {
+					log.Printf("[ws] write error: %v", err)
 				}
+				return
 			}
 		}
 	}()
@@ -98,7 +284,42 @@ func (s *Server) WSHandler(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		s.handleWSCommand(conn, cmd)
+		h.handleWSCommand(client, cmd)
+	}
+}
+
+// handleClientHello records the client's declared version and encoding.
+func (h *WSHandlers) handleClientHello(client *wsClient, hello WSClientHello) {
+	minVersion := h.minWSClientVersion()
+
+	client.mu.Lock()
+	client.version = hello.Version
+	if client.version < 1 {
+		client.version = 1
+	}
+	if client.version > WSProtocolVersion {
+		client.version = WSProtocolVersion
+	}
+	client.reduced = hello.Version < minVersion
+	switch hello.Encoding {
+	case EncodingBase64RLE:
+		client.encoding = EncodingBase64RLE
+	default:
+		client.encoding = EncodingPlain
+	}
+	reduced := client.reduced
+	client.mu.Unlock()
+
+	if reduced {
+		log.Printf("[ws] client version %d below minimum %d — reduced mode", hello.Version, minVersion)
+		client.send(robot.BroadcastMsg{
+			Type: "upgrade_required",
+			Data: map[string]interface{}{
+				"client_version":   hello.Version,
+				"min_version":      minVersion,
+				"protocol_version": WSProtocolVersion,
+			},
+		})
 	}
 }
 
@@ -109,35 +330,135 @@ type WSCommand struct {
 	Data    json.RawMessage `json:"data,omitempty"`
 }
 
-// JoystickData holds joystick velocity values.
+// JoystickData holds joystick velocity values; LinearY (strafe, positive
+// to the left) is ignored unless the robot is holonomic. Override drives
+// through the autonomy lock after a take_over; Takeover takes the robot
+// from another connection that is driving it.
 type JoystickData struct {
 	LinearX  float64 `json:"linear_x"`
+	LinearY  float64 `json:"linear_y"`
 	AngularZ float64 `json:"angular_z"`
+	Override bool    `json:"override,omitempty"`
+	Takeover bool    `json:"takeover,omitempty"`
 }
 
 // handleWSCommand processes a single WebSocket command from the browser
-func (s *Server) handleWSCommand(conn *websocket.Conn, cmd WSCommand) {
+func (h *WSHandlers) handleWSCommand(client *wsClient, cmd WSCommand) {
 	// Get target robot
 	robotID := cmd.RobotID
 	if robotID == "" {
-		robotID = s.Manager.GetCurrentRobotID()
+		robotID = h.Robots.GetCurrentRobotID()
+	} else if rb := h.Robots.GetRobot(robotID); rb != nil {
+		rb.Touch()
 	}
 
 	switch cmd.Type {
+	case "hello":
+		var hello WSClientHello
+		if err := json.Unmarshal(cmd.Data, &hello); err != nil {
+			return
+		}
+		h.handleClientHello(client, hello)
+
+	case "bandwidth":
+		// Opt in/out of the periodic bandwidth report: {"enabled": true}
+		var data struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.Unmarshal(cmd.Data, &data); err != nil {
+			return
+		}
+		client.mu.Lock()
+		client.bandwidth = data.Enabled
+		client.mu.Unlock()
+
+	case "watch":
+		// Keep robots active while shown, e.g. in a fleet overview:
+		// {"robot_ids": ["2", "3"]} replaces the client's watch list
+		var data struct {
+			RobotIDs []string `json:"robot_ids"`
+		}
+		if err := json.Unmarshal(cmd.Data, &data); err != nil {
+			return
+		}
+		client.mu.Lock()
+		prev := client.watching
+		client.watching = data.RobotIDs
+		client.mu.Unlock()
+		h.Robots.Watch(data.RobotIDs...)
+		h.Robots.Unwatch(prev...)
+
 	case "joystick":
 		var joy JoystickData
 		if err := json.Unmarshal(cmd.Data, &joy); err != nil {
 			return
 		}
-		rb := s.Manager.GetRobot(robotID)
-		if rb != nil {
-			rb.SetVelocity(joy.LinearX, joy.AngularZ)
+		rb := h.Robots.GetRobot(robotID)
+		if rb == nil {
+			return
+		}
+		if err := rb.Drive(client.driver, joy.LinearX, joy.LinearY, joy.AngularZ, joy.Override, joy.Takeover); err != nil {
+			data := map[string]interface{}{
+				"reason":   err.Error(),
+				"autonomy": rb.GetAutonomy(),
+			}
+			if errors.Is(err, robot.ErrDriverBusy) {
+				data["driver"] = rb.ManualDriver()
+			}
+			if errors.Is(err, robot.ErrControlHeld) || errors.Is(err, robot.ErrControlRequired) {
+				data["control"] = rb.ControlLease()
+			}
+			client.deliver(robot.BroadcastMsg{Type: "joystick_rejected", RobotID: robotID, Data: data})
+		}
+
+	case "adjust_ratio":
+		// Teleop speed buttons: {"ratio": "linear"|"angular", "delta":
+		// 0.1}. The new ratio is broadcast as settings_changed.
+		var data struct {
+			Ratio string  `json:"ratio"`
+			Delta float64 `json:"delta"`
+		}
+		if err := json.Unmarshal(cmd.Data, &data); err != nil {
+			return
+		}
+		rb := h.Robots.GetRobot(robotID)
+		if rb == nil {
+			return
 		}
+		if _, err := rb.AdjustVelRatio(data.Ratio, data.Delta); err != nil {
+			client.deliver(robot.BroadcastMsg{Type: "ratio_rejected", RobotID: robotID, Data: map[string]string{"reason": err.Error()}})
+		}
+
+	case "tap_topic":
+		h.tapTopic(client, robotID, cmd.Data)
+
+	case "untap_topic":
+		h.untapTopic(client, robotID, cmd.Data)
+
+	case "take_over":
+		// Operator confirmed taking manual control: cancel navigation
+		// and the patrol, accept override joystick input.
+		rb := h.Robots.GetRobot(robotID)
+		if rb == nil {
+			return
+		}
+		if err := rb.CheckControl(client.driver.ID); err != nil {
+			h.rejectControl(client, rb, err)
+			return
+		}
+		go rb.TakeOver()
+
+	case "request_control", "grant_control", "deny_control", "release_control":
+		rb := h.Robots.GetRobot(robotID)
+		if rb == nil {
+			return
+		}
+		h.handleControlCommand(client, rb, cmd)
 
 	case "stop":
-		rb := s.Manager.GetRobot(robotID)
+		rb := h.Robots.GetRobot(robotID)
 		if rb != nil {
-			rb.SetVelocity(0, 0)
+			rb.SetVelocity(0, 0, 0)
 		}
 
 	case "switch_robot":
@@ -145,52 +466,68 @@ func (s *Server) handleWSCommand(conn *websocket.Conn, cmd WSCommand) {
 			ID string `json:"id"`
 		}
 		if err := json.Unmarshal(cmd.Data, &data); err == nil {
-			s.Manager.SwitchRobot(data.ID)
+			h.Robots.SwitchRobot(data.ID)
 		}
 
 	case "request_map":
-		// Send current map data immediately
-		rb := s.Manager.GetRobot(robotID)
+		// Send current map metadata, then the data, immediately
+		rb := h.Robots.GetRobot(robotID)
 		if rb != nil {
-			mapData := rb.GetMap()
-			conn.WriteJSON(robot.BroadcastMsg{
+			frame := rb.GetMapFrame()
+			meta := rb.GetMapMeta()
+			meta.Repeat = false
+			client.deliver(h.Robots.Stamp(robot.BroadcastMsg{
+				Type:    "map_meta",
+				RobotID: robotID,
+				Data:    meta,
+			}))
+			client.deliver(h.Robots.Stamp(robot.BroadcastMsg{
 				Type:    "map",
 				RobotID: robotID,
-				Data:    mapData,
-			})
+				Data:    frame,
+			}))
 		}
 
 	case "request_status":
-		rb := s.Manager.GetRobot(robotID)
+		rb := h.Robots.GetRobot(robotID)
 		if rb != nil {
 			snap := rb.GetSnapshot()
-			conn.WriteJSON(robot.BroadcastMsg{
+			client.deliver(h.Robots.Stamp(robot.BroadcastMsg{
 				Type:    "status",
 				RobotID: robotID,
 				Data:    snap,
-			})
+			}))
 		}
 
 	case "voice_command":
 		var data struct {
 			Text string `json:"text"`
 		}
+		if !h.UI().Enabled(FeatureSpeech) {
+			h.Robots.ReportError(robotID, "voice", "Voice command was not sent: speech is disabled on this deployment")
+			return
+		}
 		if err := json.Unmarshal(cmd.Data, &data); err == nil {
-			rb := s.Manager.GetRobot(robotID)
+			rb := h.Robots.GetRobot(robotID)
 			if rb != nil && rb.Client != nil {
-				rb.Client.SendVoiceCommand(data.Text)
+				go func() {
+					if _, err := rb.SendVoiceCommand(data.Text); err != nil {
+						h.Robots.ReportError(rb.ID, "voice",
+							fmt.Sprintf("Voice command %q was not sent to %s: %v", data.Text, rb.Name, err))
+					}
+				}()
 			}
 		}
 
 	case "connect":
 		// Manual connect/reconnect
-		rb := s.Manager.GetRobot(robotID)
+		rb := h.Robots.GetRobot(robotID)
 		if rb != nil && rb.Client != nil && !rb.Client.IsConnected() {
-			go rb.Client.Connect()
+			go rb.Connect()
 		}
 
 	case "disconnect":
-		rb := s.Manager.GetRobot(robotID)
+		rb := h.Robots.GetRobot(robotID)
 		if rb != nil {
 			rb.StopConnection()
 		}
@@ -199,3 +536,41 @@ func (s *Server) handleWSCommand(conn *websocket.Conn, cmd WSCommand) {
 		log.Printf("[ws] unknown command type: %s", cmd.Type)
 	}
 }
+
+// handleControlCommand handles the control lease commands:
+// request_control, release_control, and grant_control / deny_control
+// {"client_id": "ws-3"} from the holder answering a request. Transitions
+// are broadcast as "control_lease"; refusals go to this client alone.
+func (h *WSHandlers) handleControlCommand(client *wsClient, rb *robot.Robot, cmd WSCommand) {
+	var data struct {
+		ClientID string `json:"client_id"`
+	}
+	if len(cmd.Data) > 0 {
+		if err := json.Unmarshal(cmd.Data, &data); err != nil {
+			return
+		}
+	}
+
+	var err error
+	switch cmd.Type {
+	case "request_control":
+		rb.RequestControl(client.driver)
+	case "grant_control":
+		_, err = rb.GrantControl(&client.driver, data.ClientID)
+	case "deny_control":
+		_, err = rb.DenyControl(&client.driver, data.ClientID)
+	case "release_control":
+		err = rb.ReleaseControl(client.driver)
+	}
+	if err != nil {
+		h.rejectControl(client, rb, err)
+	}
+}
+
+// rejectControl tells a client its control command was refused.
+func (h *WSHandlers) rejectControl(client *wsClient, rb *robot.Robot, err error) {
+	client.deliver(robot.BroadcastMsg{Type: "control_rejected", RobotID: rb.ID, Data: map[string]interface{}{
+		"reason":  err.Error(),
+		"control": rb.ControlLease(),
+	}})
+}
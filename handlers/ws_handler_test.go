@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"rom_go_app/config"
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// dialWS serves the WebSocket handler and connects to it, reading past
+// the hello and stream_reset frames.
+func dialWS(t *testing.T, s *Server) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(s.wsHandlers().WSHandler))
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	for _, want := range []string{"hello", "stream_reset"} {
+		if got := readFrame(t, conn); got.Type != want {
+			t.Fatalf("frame %q, want %q", got.Type, want)
+		}
+	}
+	return conn
+}
+
+// dialWSHello dials the WebSocket and says hello as a current client,
+// which newer frames such as topic_tap and control_lease need.
+func dialWSHello(t *testing.T, s *Server) *websocket.Conn {
+	t.Helper()
+	conn := dialWS(t, s)
+	if err := conn.WriteJSON(map[string]interface{}{"type": "hello", "data": WSClientHello{Version: WSProtocolVersion}}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) robot.BroadcastMsg {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg robot.BroadcastMsg
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+// awaitFrame broadcasts msgType until the client receives it.
+func awaitFrame(t *testing.T, s *Server, conn *websocket.Conn, msgType string) {
+	t.Helper()
+	got := make(chan string, 1)
+	go func() {
+		for {
+			var msg robot.BroadcastMsg
+			if conn.ReadJSON(&msg) != nil {
+				close(got)
+				return
+			}
+			if msg.Type == msgType {
+				got <- msg.Type
+				return
+			}
+		}
+	}()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		s.Manager.Broadcast(robot.BroadcastMsg{Type: msgType, RobotID: "1"})
+		select {
+		case _, ok := <-got:
+			if !ok {
+				t.Fatalf("%s frame never delivered", msgType)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func newMinVersionServer(t *testing.T, min string) *Server {
+	t.Setenv("CONFIG_FILE", "")
+	cfg, err := config.Load(map[string]string{"WS_MIN_CLIENT_VERSION": min})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{Config: cfg, Manager: robot.NewManager()}
+}
+
+func TestWSNoHelloIsReducedBelowMinimum(t *testing.T) {
+	s := newMinVersionServer(t, "2")
+	conn := dialWS(t, s)
+
+	// A v1 client that never says hello gets status but no heavy data
+	s.Manager.Broadcast(robot.BroadcastMsg{Type: "tf", RobotID: "1"})
+	s.Manager.Broadcast(robot.BroadcastMsg{Type: "status", RobotID: "1"})
+	if got := readFrame(t, conn); got.Type != "status" {
+		t.Fatalf("reduced client got %q, want status only", got.Type)
+	}
+
+	// Its hello lifts reduced mode
+	if err := conn.WriteJSON(map[string]interface{}{"type": "hello", "data": WSClientHello{Version: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	awaitFrame(t, s, conn, "tf")
+}
+
+func TestWSOldHelloGetsUpgradeRequired(t *testing.T) {
+	s := newMinVersionServer(t, "2")
+	conn := dialWS(t, s)
+	if err := conn.WriteJSON(map[string]interface{}{"type": "hello", "data": WSClientHello{Version: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrame(t, conn); got.Type != "upgrade_required" {
+		t.Fatalf("got %q, want upgrade_required", got.Type)
+	}
+}
+
+func TestWSNoHelloFullAtMinimumOne(t *testing.T) {
+	s := &Server{Manager: robot.NewManager()}
+	conn := dialWS(t, s)
+	awaitFrame(t, s, conn, "tf")
+}
+
+func TestEncodeMapRLE(t *testing.T) {
+	f := robot.MapFrame{MapData: rosbridge.MapData{
+		Width: 300, Height: 1, Resolution: 0.05, OriginX: 1, OriginY: -2, OriginYaw: 0.5,
+		Data: append(make([]int8, 260), -1, -1, 100),
+	}}
+	enc := encodeMapRLE(f)
+	if enc.OriginX != 1 || enc.OriginY != -2 || enc.OriginYaw != 0.5 || enc.Encoding != EncodingBase64RLE {
+		t.Errorf("header = %+v", enc)
+	}
+	runs, err := base64.StdEncoding.DecodeString(enc.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 255, 0, 5, 0xFF, 2, 100, 1}
+	if string(runs) != string(want) {
+		t.Errorf("runs = %v, want %v", runs, want)
+	}
+}
+
+// nextMap reads raw frames until a map frame of the given width.
+func nextMap(t *testing.T, conn *websocket.Conn, width int) []byte {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg struct {
+			Type string `json:"type"`
+			Data struct {
+				Width int `json:"width"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(data, &msg) == nil && msg.Type == "map" && msg.Data.Width == width {
+			return data
+		}
+	}
+}
+
+// TestWSSharedFrames sends one map broadcast to two plain clients and a
+// base64_rle one: the plain clients get the broadcast's shared bytes,
+// and only the base64_rle client has the frame encoded for it.
+func TestWSSharedFrames(t *testing.T) {
+	s := &Server{Manager: robot.NewManager()}
+	var conns []*websocket.Conn
+	for _, enc := range []string{EncodingPlain, EncodingPlain, EncodingBase64RLE} {
+		conn := dialWS(t, s)
+		if err := conn.WriteJSON(map[string]interface{}{"type": "hello", "data": WSClientHello{Version: 2, Encoding: enc}}); err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	frame := func(width int) robot.BroadcastMsg {
+		return robot.BroadcastMsg{Type: "map", RobotID: "1", Data: robot.MapFrame{MapData: rosbridge.MapData{
+			Width: width, Height: 1, Resolution: 0.05, Data: make([]int8, width),
+		}}}
+	}
+	// Until the hellos are handled
+	rle := conns[2]
+	rle.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		s.Manager.Broadcast(frame(3))
+		_, data, err := rle.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), `"encoding":"base64_rle"`) {
+			break
+		}
+	}
+	s.Manager.Broadcast(frame(4))
+	for _, conn := range conns {
+		nextMap(t, conn, 4)
+	}
+
+	sent, encoded := wsFramesSent.Load(), wsConnectionEncodes.Load()
+	s.Manager.Broadcast(frame(5))
+	a, b, c := nextMap(t, conns[0], 5), nextMap(t, conns[1], 5), nextMap(t, rle, 5)
+	if string(a) != string(b) {
+		t.Errorf("plain clients got different frames:\n%s\n%s", a, b)
+	}
+	var plain robot.BroadcastMsg
+	json.Unmarshal(a, &plain)
+	if plain.Seq == 0 || !strings.Contains(string(a), `"data":[0,0,0,0,0]`) {
+		t.Errorf("plain frame %s", a)
+	}
+	if !strings.Contains(string(c), `"encoding":"base64_rle"`) || !strings.Contains(string(c), fmt.Sprintf(`"seq":%d`, plain.Seq)) {
+		t.Errorf("base64_rle frame %s", c)
+	}
+	if n := wsFramesSent.Load() - sent; n != 3 {
+		t.Errorf("%d frames sent, want 3", n)
+	}
+	if n := wsConnectionEncodes.Load() - encoded; n != 1 {
+		t.Errorf("%d frames encoded per connection, want 1", n)
+	}
+}
+
+// frameOf reads frames until one of type msgType and decodes its data
+// into v.
+func frameOf(t *testing.T, conn *websocket.Conn, msgType string, v interface{}) {
+	t.Helper()
+	for {
+		msg := readFrame(t, conn)
+		if msg.Type != msgType {
+			continue
+		}
+		data, _ := json.Marshal(msg.Data)
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+}
+
+// TestWSControlLease takes the lease over the WebSocket: the other client
+// can't drive, and the lease goes when the holder disconnects.
+func TestWSControlLease(t *testing.T) {
+	s := newTestServer(t)
+	rb, err := s.Manager.AddRobot("", "lease", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Manager.RemoveRobot(rb.ID)
+	holder, other := dialWSHello(t, s), dialWSHello(t, s)
+	var rejected struct {
+		Reason  string             `json:"reason"`
+		Control robot.ControlLease `json:"control"`
+	}
+	// Nothing to release; the v2 refusal also shows the hello was taken
+	other.WriteJSON(WSCommand{Type: "release_control", RobotID: rb.ID})
+	frameOf(t, other, "control_rejected", &rejected)
+	if !strings.Contains(rejected.Reason, robot.ErrNotController.Error()) {
+		t.Errorf("release without the lease: %q", rejected.Reason)
+	}
+
+	holder.WriteJSON(WSCommand{Type: "request_control", RobotID: rb.ID})
+	var ev robot.ControlLeaseEvent
+	frameOf(t, other, "control_lease", &ev)
+	if ev.Event != robot.ControlGranted || ev.Lease.Holder == nil {
+		t.Fatalf("request: %+v", ev)
+	}
+	driver := ev.Driver
+
+	joy, _ := json.Marshal(JoystickData{LinearX: 0.2})
+	other.WriteJSON(WSCommand{Type: "joystick", RobotID: rb.ID, Data: joy})
+	frameOf(t, other, "joystick_rejected", &rejected)
+	if rejected.Control.Holder == nil || *rejected.Control.Holder != driver {
+		t.Errorf("rejected %+v", rejected)
+	}
+
+	holder.Close()
+	frameOf(t, other, "control_lease", &ev)
+	if ev.Event != robot.ControlReleased || ev.Reason != robot.ControlReasonDisconnected || ev.Driver != driver || rb.ControlLease().Holder != nil {
+		t.Errorf("holder gone: %+v", ev)
+	}
+}
+
+// TestWSMessageTypesListed finds every BroadcastMsg literal type in the
+// server's sources and checks wsMessageVersions lists it, so a new type
+// can't reach older clients through the default.
+func TestWSMessageTypesListed(t *testing.T) {
+	fset := token.NewFileSet()
+	for _, dir := range []string{".", "../robot", ".."} {
+		pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+			return !strings.HasSuffix(fi.Name(), "_test.go")
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pkg := range pkgs {
+			ast.Inspect(pkg, func(n ast.Node) bool {
+				lit, ok := n.(*ast.CompositeLit)
+				if !ok || !isBroadcastMsg(lit.Type) {
+					return true
+				}
+				for _, e := range lit.Elts {
+					kv, ok := e.(*ast.KeyValueExpr)
+					if !ok || fmt.Sprint(kv.Key) != "Type" {
+						continue
+					}
+					if v, ok := kv.Value.(*ast.BasicLit); ok {
+						typ, _ := strconv.Unquote(v.Value)
+						if _, listed := wsMessageVersions[typ]; !listed {
+							t.Errorf("%s: message type %q not in wsMessageVersions", fset.Position(v.Pos()), typ)
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	for typ, v := range wsMessageVersions {
+		if v < 1 || v > WSProtocolVersion {
+			t.Errorf("%s: version %d outside 1..%d", typ, v, WSProtocolVersion)
+		}
+	}
+}
+
+func isBroadcastMsg(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name == "BroadcastMsg"
+	case *ast.SelectorExpr:
+		return e.Sel.Name == "BroadcastMsg"
+	}
+	return false
+}
+
+// TestWSNewTypesHeldFromOlderClients checks a v2 client gets v1 frames
+// but none introduced later, while a current one gets both.
+func TestWSNewTypesHeldFromOlderClients(t *testing.T) {
+	s := &Server{Manager: robot.NewManager()}
+	old := dialWS(t, s)
+	if err := old.WriteJSON(map[string]interface{}{"type": "hello", "data": WSClientHello{Version: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	awaitFrame(t, s, old, "tf")
+	s.Manager.Broadcast(robot.BroadcastMsg{Type: "map_meta", RobotID: "1"})
+	s.Manager.Broadcast(robot.BroadcastMsg{Type: robot.ExtraTopicPrefix + "battery", RobotID: "1"})
+	s.Manager.Broadcast(robot.BroadcastMsg{Type: "status", RobotID: "1"})
+	for {
+		got := readFrame(t, old)
+		if got.Type == "status" {
+			break
+		}
+		if got.Type != "tf" {
+			t.Fatalf("v2 client got %q", got.Type)
+		}
+	}
+
+	current := dialWSHello(t, s)
+	awaitFrame(t, s, current, "map_meta")
+}
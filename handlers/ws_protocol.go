@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// ──────────────────── WS protocol versioning ────────────────────
+//
+// The browser and server evolve independently (and browsers cache old
+// JS), so every connection negotiates a protocol version. Frames are only
+// delivered if the client's version is at least the version that
+// introduced their type. Adding a message type bumps WSProtocolVersion
+// and lists the type under the new version in wsMessageVersions.
+
+// WSProtocolVersion is the protocol version spoken by this server.
+//
+//	1 — original message set (no hello)
+//	2 — hello / upgrade_required, base64_rle map encoding
+//	3 — map_meta, toasts, manual control, patrol, mapping, floors and
+//	    the other robot and fleet events, custom: extra topics
+const WSProtocolVersion = 3
+
+// Map payload encodings a client may request in its hello.
+const (
+	EncodingPlain     = "plain"
+	EncodingBase64RLE = "base64_rle"
+)
+
+var wsEncodings = []string{EncodingPlain, EncodingBase64RLE}
+
+// wsCommandTypes lists the commands accepted from the browser.
+var wsCommandTypes = []string{
+	"hello", "joystick", "stop", "switch_robot", "request_map",
+	"request_status", "voice_command", "connect", "disconnect",
+	"bandwidth", "take_over", "watch", "adjust_ratio",
+	"tap_topic", "untap_topic", "request_control", "grant_control",
+	"deny_control", "release_control",
+}
+
+// wsMessageVersions records the protocol version that introduced each
+// server→browser message type; TestWSMessageTypesListed fails for a type
+// the server sends that is missing here. Unlisted types default to
+// WSProtocolVersion, and custom: extra topic frames are version 3.
+var wsMessageVersions = map[string]int{
+	"map":                        1,
+	"tf":                         1,
+	"odom":                       1,
+	"ctrl_odom":                  1,
+	"laser":                      1,
+	"velocity":                   1,
+	"map_bfp":                    1,
+	"status":                     1,
+	"robot_added":                1,
+	"robot_removed":              1,
+	"robot_connected":            1,
+	"robot_disconnected":         1,
+	"robot_switched":             1,
+	"hello":                      2,
+	"upgrade_required":           2,
+	"autonomy":                   3,
+	"bandwidth":                  3,
+	"clock_skew":                 3,
+	"connect_phase":              3,
+	"control_lease":              3,
+	"control_rejected":           3,
+	"estop":                      3,
+	"fleet_proximity":            3,
+	"floor":                      3,
+	"home":                       3,
+	"joystick_rejected":          3,
+	"localization_quality":       3,
+	"manual_control":             3,
+	"map_list_changed":           3,
+	"map_meta":                   3,
+	"map_save":                   3,
+	"mapping_progress":           3,
+	"mapping_session":            3,
+	"marker":                     3,
+	"mode":                       3,
+	"move_progress":              3,
+	"nav_send":                   3,
+	"nav_status":                 3,
+	"patrol":                     3,
+	"pending_destructive_action": 3,
+	"point_visited":              3,
+	"pose_reset":                 3,
+	"power_state":                3,
+	"ratio_rejected":             3,
+	"raw_topic":                  3,
+	"relocalize":                 3,
+	"robot_activity":             3,
+	"robot_config":               3,
+	"robot_discovered":           3,
+	"rosbridge_status":           3,
+	"settings_changed":           3,
+	"settings_template":          3,
+	"stream_reset":               3,
+	"toast":                      3,
+	"topic_tap":                  3,
+	"usage_reset":                3,
+	"velocity_lag":               3,
+}
+
+// reducedModeTypes are the only frames sent to clients below the
+// configured minimum version: status and robot lifecycle, no heavy data.
+var reducedModeTypes = map[string]bool{
+	"status":             true,
+	"robot_added":        true,
+	"robot_removed":      true,
+	"robot_connected":    true,
+	"robot_disconnected": true,
+	"robot_switched":     true,
+	"upgrade_required":   true,
+}
+
+// messageVersion returns the protocol version that introduced msgType.
+func messageVersion(msgType string) int {
+	if v, ok := wsMessageVersions[msgType]; ok {
+		return v
+	}
+	if strings.HasPrefix(msgType, robot.ExtraTopicPrefix) {
+		return 3
+	}
+	return WSProtocolVersion
+}
+
+// WSHello is the capability announcement exchanged on connect.
+type WSHello struct {
+	ProtocolVersion  int               `json:"protocol_version"`
+	MinClientVersion int               `json:"min_client_version"`
+	Commands         []string          `json:"commands"`
+	MessageTypes     map[string]int    `json:"message_types"`
+	Encodings        []string          `json:"encodings"`
+	Robots           []robotListEntry  `json:"robots"`
+	CurrentID        string            `json:"current_id"`
+	RecentErrors     []robot.Notice    `json:"recent_errors"` // notices of the last helloNoticeWindow
+	ClientID         string            `json:"client_id"`     // this connection's driver ID in manual_control
+	FrameFields      map[string]string `json:"frame_fields"`  // meaning of the envelope fields
+}
+
+// wsFrameFields documents the frame envelope in the hello.
+var wsFrameFields = map[string]string{
+	"type":         "message type",
+	"robot_id":     "robot the frame is about; empty for fleet events",
+	"seq":          "per robot_id and type, from 1; assigned before throttling or drops, so a gap means missed frames and a lower seq than the last rendered is stale. Replies to request_map/request_status repeat the latest seq",
+	"ts":           "server time the frame was sent, Unix milliseconds",
+	"stream_reset": "sent after hello: data.seqs[robot_id][type] is the last seq issued before this connection subscribed; reset counters to it",
+}
+
+// StreamReset is the "stream_reset" payload.
+type StreamReset struct {
+	Seqs map[string]map[string]uint64 `json:"seqs"`
+}
+
+// WSClientHello is the hello sent back by the browser.
+type WSClientHello struct {
+	Version  int    `json:"version"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// robotListEntry is the compact robot summary used by the robot list API
+// and the WS hello.
+type robotListEntry struct {
+	ID         string              `json:"id"`
+	Namespace  string              `json:"namespace"`
+	Name       string              `json:"name"`
+	IP         string              `json:"ip"`
+	Port       int                 `json:"port"`
+	Connection string              `json:"connection_type"` // direct, shared or reverse (agent-initiated)
+	Connected  bool                `json:"connected"`
+	Power      string              `json:"power_state"` // normal, shutting_down, powered_off, rebooting or unreachable
+	Phase      robot.ConnectPhase  `json:"connect_phase"`
+	Current    bool                `json:"current"`
+	Patrol     *robot.PatrolStatus `json:"patrol,omitempty"`
+	CurrentMap string              `json:"current_map,omitempty"`
+	Activity   string              `json:"activity"`   // active or idle
+	DataStale  bool                `json:"data_stale"` // pose data stale or disconnected
+}
+
+// robotList returns the compact summary of robots; currentID is the
+// selected one.
+func robotList(robots []*robot.Robot, currentID string) []robotListEntry {
+	list := make([]robotListEntry, 0, len(robots))
+	for _, rb := range robots {
+		snap := rb.GetSnapshot()
+		list = append(list, robotListEntry{
+			ID:         snap.ID,
+			Namespace:  snap.Namespace,
+			Name:       snap.Name,
+			IP:         snap.IP,
+			Port:       snap.Port,
+			Connection: snap.ConnectionType,
+			Connected:  snap.Connected,
+			Power:      snap.Power.State,
+			Phase:      snap.ConnectPhase,
+			Current:    snap.ID == currentID,
+			Patrol:     snap.Patrol,
+			CurrentMap: snap.CurrentMap,
+			Activity:   snap.ActivityState,
+			DataStale:  snap.DataStale,
+		})
+	}
+	return list
+}
+
+// helloNoticeWindow is how far back the hello replays notices, so a page
+// opened just after a background failure still shows it.
+const helloNoticeWindow = time.Minute
+
+// buildHello assembles the server hello for a new connection.
+func (h *WSHandlers) buildHello() WSHello {
+	types := make(map[string]int, len(wsMessageVersions))
+	for t, v := range wsMessageVersions {
+		types[t] = v
+	}
+	return WSHello{
+		ProtocolVersion:  WSProtocolVersion,
+		MinClientVersion: h.minWSClientVersion(),
+		Commands:         wsCommandTypes,
+		MessageTypes:     types,
+		Encodings:        wsEncodings,
+		Robots:           robotList(h.Robots.GetAllRobots(), h.Robots.GetCurrentRobotID()),
+		CurrentID:        h.Robots.GetCurrentRobotID(),
+		RecentErrors:     h.Robots.RecentNotices(time.Now().Add(-helloNoticeWindow), ""),
+		FrameFields:      wsFrameFields,
+	}
+}
+
+func (h *WSHandlers) minWSClientVersion() int {
+	if h.Config != nil && h.Config.Dynamic().MinWSClientVersion > 0 {
+		return h.Config.Dynamic().MinWSClientVersion
+	}
+	return 1
+}
+
+// ──────────────────── base64_rle map encoding ────────────────────
+
+// EncodedMapFrame is a map frame whose grid is run-length encoded as
+// (value, count) byte pairs, base64-encoded. Runs longer than 255 are
+// split.
+type EncodedMapFrame struct {
+	Width       int                  `json:"width"`
+	Height      int                  `json:"height"`
+	Resolution  float64              `json:"resolution"`
+	OriginX     float64              `json:"origin_x"`
+	OriginY     float64              `json:"origin_y"`
+	OriginYaw   float64              `json:"origin_yaw"`
+	Encoding    string               `json:"encoding"`
+	Data        string               `json:"data"`
+	RenderHints robot.MapRenderHints `json:"render_hints"`
+	MapSeq      uint64               `json:"map_seq"`
+	Checksum    string               `json:"checksum"`
+}
+
+// encodeMapRLE converts a map frame to the base64_rle encoding.
+func encodeMapRLE(f robot.MapFrame) EncodedMapFrame {
+	out := make([]byte, 0, len(f.Data)/8+2)
+	for i := 0; i < len(f.Data); {
+		v := f.Data[i]
+		n := 1
+		for i+n < len(f.Data) && f.Data[i+n] == v && n < 255 {
+			n++
+		}
+		out = append(out, byte(v), byte(n))
+		i += n
+	}
+	return EncodedMapFrame{
+		Width:       f.Width,
+		Height:      f.Height,
+		Resolution:  f.Resolution,
+		OriginX:     f.OriginX,
+		OriginY:     f.OriginY,
+		OriginYaw:   f.OriginYaw,
+		Encoding:    EncodingBase64RLE,
+		Data:        base64.StdEncoding.EncodeToString(out),
+		RenderHints: f.RenderHints,
+		MapSeq:      f.MapSeq,
+		Checksum:    f.Checksum,
+	}
+}
@@ -0,0 +1,310 @@
+// Package importer parses navigation point lists from the formats field
+// technicians actually use: spreadsheet CSV exports and the robot-side
+// YAML written by construct_yaml_and_bt.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format identifies an import payload format.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatYAML Format = "yaml"
+)
+
+// ParseFormat validates an explicit format name.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "json":
+		return FormatJSON, nil
+	case "csv":
+		return FormatCSV, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unknown import format %q", s)
+	}
+}
+
+// Row is one parsed point. Type is empty when the source didn't say;
+// approach parameters are zero when absent.
+type Row struct {
+	Line  int
+	Name  string
+	Type  string
+	X     float64
+	Y     float64
+	Theta float64
+
+	MaxSpeedMPS     float64
+	DwellSec        float64
+	YawToleranceRad float64
+	OnArrivalTask   string
+}
+
+// Skipped describes a row that could not be parsed.
+type Skipped struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Normalize strips a UTF-8 BOM and converts CRLF / lone CR line endings
+// to LF.
+func Normalize(data []byte) []byte {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	return data
+}
+
+// Sniff guesses the payload format from the content type and the first
+// line that is neither blank nor a # comment, which both CSV and YAML
+// allow.
+func Sniff(data []byte, contentType string) Format {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		return FormatJSON
+	case strings.Contains(ct, "csv"):
+		return FormatCSV
+	case strings.Contains(ct, "yaml"):
+		return FormatYAML
+	}
+
+	var first string
+	for _, line := range strings.Split(string(Normalize(data)), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			first = line
+			break
+		}
+	}
+	if first == "" || first[0] == '{' || first[0] == '[' {
+		return FormatJSON
+	}
+	if strings.HasPrefix(first, "-") ||
+		(strings.HasSuffix(first, ":") && !strings.Contains(first, ",")) ||
+		strings.Contains(first, ": ") {
+		return FormatYAML
+	}
+	return FormatCSV
+}
+
+// ──────────────────────────── CSV
+
+// ParseCSV parses CSV with a header row containing name,x,y,theta and
+// optional type, max_speed_mps, dwell_sec, yaw_tolerance_rad and
+// on_arrival_task columns. Column order is taken from the header.
+func ParseCSV(data []byte) ([]Row, []Skipped, error) {
+	r := csv.NewReader(bytes.NewReader(Normalize(data)))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("empty CSV")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, req := range []string{"name", "x", "y", "theta"} {
+		if _, ok := cols[req]; !ok {
+			return nil, nil, fmt.Errorf("CSV header missing %q column (want name,x,y,theta[,type])", req)
+		}
+	}
+	typeCol, hasType := cols["type"]
+
+	var rows []Row
+	var skipped []Skipped
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// FieldPos is only valid after a successful Read
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return rows, skipped, fmt.Errorf("read CSV: %w", err)
+			}
+			skipped = append(skipped, Skipped{Line: pe.StartLine, Reason: pe.Err.Error()})
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+
+		get := func(col string) string {
+			i, ok := cols[col]
+			if !ok || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+
+		row := Row{Line: line, Name: get("name")}
+		if row.Name == "" {
+			skipped = append(skipped, Skipped{Line: line, Reason: "name is empty"})
+			continue
+		}
+		if hasType && typeCol < len(rec) {
+			row.Type = strings.TrimSpace(rec[typeCol])
+		}
+
+		row.OnArrivalTask = get("on_arrival_task")
+
+		var reason string
+		if row.X, reason = parseFloat("x", get("x")); reason == "" {
+			if row.Y, reason = parseFloat("y", get("y")); reason == "" {
+				row.Theta, reason = parseFloat("theta", get("theta"))
+			}
+		}
+		for _, opt := range []struct {
+			col string
+			dst *float64
+		}{
+			{"max_speed_mps", &row.MaxSpeedMPS},
+			{"dwell_sec", &row.DwellSec},
+			{"yaw_tolerance_rad", &row.YawToleranceRad},
+		} {
+			if reason != "" || get(opt.col) == "" {
+				continue
+			}
+			*opt.dst, reason = parseFloat(opt.col, get(opt.col))
+		}
+		if reason != "" {
+			skipped = append(skipped, Skipped{Line: line, Name: row.Name, Reason: reason})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, skipped, nil
+}
+
+func parseFloat(field, s string) (float64, string) {
+	if s == "" {
+		return 0, field + " is empty"
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Sprintf("invalid %s %q", field, s)
+	}
+	return f, ""
+}
+
+// ──────────────────────────── Robot-native YAML
+
+// yamlPoint is the construct_yaml_and_bt point format.
+type yamlPoint struct {
+	Name          *string  `yaml:"name"`
+	WorldXM       *float64 `yaml:"world_x_m"`
+	WorldYM       *float64 `yaml:"world_y_m"`
+	WorldThetaRad *float64 `yaml:"world_theta_rad"`
+
+	MaxSpeedMPS     float64 `yaml:"max_speed_mps"`
+	DwellSec        float64 `yaml:"dwell_sec"`
+	YawToleranceRad float64 `yaml:"yaw_tolerance_rad"`
+	OnArrivalTask   string  `yaml:"on_arrival_task"`
+}
+
+// yamlSectionTypes maps the robot's top-level keys to point types.
+var yamlSectionTypes = map[string]string{
+	"waypoints":     "waypoint",
+	"servicepoints": "service_point",
+	"patrolpoints":  "patrol_point",
+	"pathpoints":    "path_point",
+}
+
+// ParseYAML parses either a bare list of points or a document keyed by
+// collection (waypoints:, servicepoints:, ...), in which case each row's
+// Type is set from its section.
+func ParseYAML(data []byte) ([]Row, []Skipped, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(Normalize(data), &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil, fmt.Errorf("empty YAML document")
+	}
+
+	root := doc.Content[0]
+	var rows []Row
+	var skipped []Skipped
+
+	switch root.Kind {
+	case yaml.SequenceNode:
+		rows, skipped = parseYAMLList(root, "")
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key := root.Content[i].Value
+			val := root.Content[i+1]
+			if val.Kind != yaml.SequenceNode {
+				continue
+			}
+			r, s := parseYAMLList(val, yamlSectionTypes[key])
+			rows = append(rows, r...)
+			skipped = append(skipped, s...)
+		}
+	default:
+		return nil, nil, fmt.Errorf("YAML must be a list of points or a map of point lists")
+	}
+	return rows, skipped, nil
+}
+
+func parseYAMLList(list *yaml.Node, pointType string) ([]Row, []Skipped) {
+	var rows []Row
+	var skipped []Skipped
+	for _, item := range list.Content {
+		var p yamlPoint
+		if err := item.Decode(&p); err != nil {
+			skipped = append(skipped, Skipped{Line: item.Line, Reason: err.Error()})
+			continue
+		}
+		name := ""
+		if p.Name != nil {
+			name = strings.TrimSpace(*p.Name)
+		}
+		switch {
+		case name == "":
+			skipped = append(skipped, Skipped{Line: item.Line, Reason: "name is empty"})
+			continue
+		case p.WorldXM == nil || p.WorldYM == nil:
+			skipped = append(skipped, Skipped{Line: item.Line, Name: name, Reason: "world_x_m and world_y_m are required"})
+			continue
+		}
+		row := Row{
+			Line: item.Line, Name: name, Type: pointType, X: *p.WorldXM, Y: *p.WorldYM,
+			MaxSpeedMPS:     p.MaxSpeedMPS,
+			DwellSec:        p.DwellSec,
+			YawToleranceRad: p.YawToleranceRad,
+			OnArrivalTask:   strings.TrimSpace(p.OnArrivalTask),
+		}
+		if p.WorldThetaRad != nil {
+			row.Theta = *p.WorldThetaRad
+		}
+		rows = append(rows, row)
+	}
+	return rows, skipped
+}
@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name, body, contentType string
+		want                    Format
+	}{
+		{"content type wins", "name,x,y,theta", "application/json", FormatJSON},
+		{"yaml content type", "{}", "application/x-yaml", FormatYAML},
+		{"empty", "  \n", "", FormatJSON},
+		{"json object", `{"type":"waypoint"}`, "", FormatJSON},
+		{"json after BOM", "\xEF\xBB\xBF[]", "", FormatJSON},
+		{"csv", "name,x,y,theta\na,1,2,0\n", "", FormatCSV},
+		{"csv after comments", "# exported 2024-01-01\n\n# by tablet\nname,x,y,theta\n", "", FormatCSV},
+		{"csv CRLF comment", "# note\r\nname,x,y,theta\r\n", "", FormatCSV},
+		{"yaml list", "- name: a\n  world_x_m: 1\n", "", FormatYAML},
+		{"yaml sections after comment", "# robot export\nwaypoints:\n", "", FormatYAML},
+		{"yaml key value", "name: a\n", "", FormatYAML},
+		{"only comments", "# nothing\n", "", FormatJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff([]byte(tt.body), tt.contentType); got != tt.want {
+				t.Errorf("Sniff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		rows        []Row
+		skipped     []Skipped
+		errContains string
+	}{
+		{
+			name: "BOM and CRLF",
+			body: "\xEF\xBB\xBFname,x,y,theta\r\na,1,2,0.5\r\nb,3,4,0\r\n",
+			rows: []Row{{Line: 2, Name: "a", X: 1, Y: 2, Theta: 0.5}, {Line: 3, Name: "b", X: 3, Y: 4}},
+		},
+		{
+			name:    "mixed line endings keep line numbers",
+			body:    "name,x,y,theta\r\na,1,2,0\rb,1,2,0\nc,bad,2,0\r\n",
+			rows:    []Row{{Line: 2, Name: "a", X: 1, Y: 2}, {Line: 3, Name: "b", X: 1, Y: 2}},
+			skipped: []Skipped{{Line: 4, Name: "c", Reason: `invalid x "bad"`}},
+		},
+		{
+			name: "columns by header with optional fields",
+			body: "Theta, Y, X, Name, Type, dwell_sec, on_arrival_task\n1,2,3,a,patrol_point,5,beep\n",
+			rows: []Row{{Line: 2, Name: "a", Type: "patrol_point", X: 3, Y: 2, Theta: 1, DwellSec: 5, OnArrivalTask: "beep"}},
+		},
+		{
+			name: "bad rows",
+			body: "name,x,y,theta,max_speed_mps\n,1,2,0\nb,1,,0\nc,1,2\nd,1,2,0,fast\n# comment\n\ne,1,2,0\n",
+			rows: []Row{{Line: 8, Name: "e", X: 1, Y: 2}},
+			skipped: []Skipped{
+				{Line: 2, Reason: "name is empty"},
+				{Line: 3, Name: "b", Reason: "y is empty"},
+				{Line: 4, Name: "c", Reason: "theta is empty"},
+				{Line: 5, Name: "d", Reason: `invalid max_speed_mps "fast"`},
+			},
+		},
+		{
+			name:    "bare quote",
+			body:    "name,x,y,theta\nna\"me,1,2,3\nb,1,2,0\n",
+			rows:    []Row{{Line: 3, Name: "b", X: 1, Y: 2}},
+			skipped: []Skipped{{Line: 2, Reason: `bare " in non-quoted-field`}},
+		},
+		{
+			name:    "bad quote after a valid row",
+			body:    "name,x,y,theta\na,1,2,0\n\"b\"x,1,2,0\nc,1,2,0\n",
+			rows:    []Row{{Line: 2, Name: "a", X: 1, Y: 2}, {Line: 4, Name: "c", X: 1, Y: 2}},
+			skipped: []Skipped{{Line: 3, Reason: `extraneous or missing " in quoted-field`}},
+		},
+		{
+			name:    "unterminated quote",
+			body:    "name,x,y,theta\na,1,2,0\n\"b,1,2,0\nc,1,2,0\n",
+			rows:    []Row{{Line: 2, Name: "a", X: 1, Y: 2}},
+			skipped: []Skipped{{Line: 3, Reason: `extraneous or missing " in quoted-field`}},
+		},
+		{name: "empty", body: "", errContains: "empty CSV"},
+		{name: "missing column", body: "name,x,y\na,1,2\n", errContains: `missing "theta"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, skipped, err := ParseCSV([]byte(tt.body))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("err = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %+v\nwant   %+v", rows, tt.rows)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped = %+v\nwant      %+v", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		rows        []Row
+		skipped     []Skipped
+		errContains string
+	}{
+		{
+			name: "bare list with CRLF",
+			body: "- name: a\r\n  world_x_m: 1\r\n  world_y_m: 2\r\n  world_theta_rad: 0.5\r\n",
+			rows: []Row{{Line: 1, Name: "a", X: 1, Y: 2, Theta: 0.5}},
+		},
+		{
+			name: "sections with BOM and bad rows",
+			body: "\xEF\xBB\xBF# export\nwaypoints:\n  - name: w\n    world_x_m: 1\n    world_y_m: 2\n  - name: ''\n    world_x_m: 1\n    world_y_m: 2\nservicepoints:\n  - name: s\n    world_x_m: 1\n  - name: d\n    world_x_m: 1\n    world_y_m: 2\n    dwell_sec: 3\n",
+			rows: []Row{
+				{Line: 3, Name: "w", Type: "waypoint", X: 1, Y: 2},
+				{Line: 12, Name: "d", Type: "service_point", X: 1, Y: 2, DwellSec: 3},
+			},
+			skipped: []Skipped{
+				{Line: 6, Reason: "name is empty"},
+				{Line: 10, Name: "s", Reason: "world_x_m and world_y_m are required"},
+			},
+		},
+		{name: "scalar", body: "hello\n", errContains: "list of points"},
+		{name: "invalid", body: "- [\n", errContains: "parse YAML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, skipped, err := ParseYAML([]byte(tt.body))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("err = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %+v\nwant   %+v", rows, tt.rows)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped = %+v\nwant      %+v", skipped, tt.skipped)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"embed"
-	"html/template"
+	"flag"
 	"io/fs"
 	"log"
 	"net/http"
@@ -13,8 +13,16 @@ import (
 	"time"
 
 	"rom_go_app/config"
+	"rom_go_app/discovery"
 	"rom_go_app/handlers"
+	"rom_go_app/mqtt"
+	"rom_go_app/phrases"
 	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+	"rom_go_app/tlscert"
+	"rom_go_app/units"
+	"rom_go_app/version"
+	"rom_go_app/webhook"
 )
 
 //go:embed templates/*
@@ -23,124 +31,332 @@ var templateFS embed.FS
 //go:embed static/*
 var staticFS embed.FS
 
+// flagSettings maps command-line flags to the settings they override.
+var flagSettings = map[string]string{
+	"listen":       "LISTEN_ADDR",
+	"admin-listen": "ADMIN_LISTEN_ADDR",
+	"no-ui":        "NO_UI",
+	"base-path":    "BASE_PATH",
+}
+
+// parseFlags returns the settings given on the command line.
+func parseFlags() map[string]string {
+	flag.String("listen", "", "main listen address (overrides LISTEN_ADDR)")
+	flag.String("admin-listen", "", "extra plain-HTTP listener serving only /api/, /metrics and /healthz (ADMIN_LISTEN_ADDR)")
+	flag.Bool("no-ui", false, "API only: no templates, static assets or page routes (NO_UI)")
+	flag.String("base-path", "", "URL prefix the app is mounted at behind a reverse proxy, e.g. /robots (BASE_PATH)")
+	flag.Parse()
+
+	overrides := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		v := f.Value.String()
+		if g, ok := f.Value.(flag.Getter); ok {
+			if b, ok := g.Get().(bool); ok {
+				v = "0"
+				if b {
+					v = "1"
+				}
+			}
+		}
+		overrides[flagSettings[f.Name]] = v
+	})
+	return overrides
+}
+
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load(parseFlags())
+	if err != nil {
+		log.Fatalf("[server] Config: %v", err)
+	}
 
-	// Parse templates
-	tmpl := template.Must(template.ParseFS(templateFS,
-		"templates/layout.html",
-		"templates/index.html",
-		"templates/partials/*.html",
-		"templates/dialogs/*.html",
-	))
+	// Static assets (hashed + pre-compressed once at startup) and
+	// templates, where a broken file only disables the routes using it;
+	// an API-only instance needs neither
+	var staticSub fs.FS
+	var assets *handlers.StaticAssets
+	var tmpl *handlers.Templates
+	var branding fs.FS
+	if !cfg.NoUI {
+		staticSub, _ = fs.Sub(staticFS, "static")
+		assets, err = handlers.NewStaticAssets(staticSub, cfg.StaticMaxAge)
+		if err != nil {
+			log.Fatalf("[server] Static assets: %v", err)
+		}
+		funcs := units.FuncMap()
+		funcs["asset"] = handlers.PrefixedAsset(cfg.BasePath, assets)
+		tmpl, err = handlers.ParseTemplates(templateFS, funcs)
+		if err != nil {
+			log.Fatalf("[server] Templates: %v", err)
+		}
+		branding = handlers.NewOverlayFS(os.DirFS(cfg.BrandingDir), staticSub)
+	}
 
 	// Robot manager & navigation manager
 	mgr := robot.NewManager()
+	mgr.ClockSkewWarn = cfg.ClockSkewWarn
+	mgr.ClockSkewJump = cfg.ClockSkewJump
+	mgr.AutonomyGating = cfg.AutonomyGating
+	mgr.JoystickDeadman = cfg.JoystickDeadman
+	mgr.ControlLeaseIdle, mgr.ControlLeaseRequired = cfg.ControlLeaseIdle, cfg.ControlLeaseRequired
+	if err := mgr.SetProximityOptions(robot.ProximityOptions{
+		MarginM:     cfg.FleetProximityMargin,
+		HysteresisM: cfg.FleetProximityHysteresis,
+		AutoStop:    cfg.FleetProximityAutoStop,
+	}); err != nil {
+		log.Fatalf("[server] Fleet proximity: %v", err)
+	}
+	mgr.TaskDiscoveryRequest = cfg.TaskDiscoveryRequest
+	mgr.StaticTasks = rosbridge.ParseTaskSpecs(cfg.StaticTasks)
+	mgr.CapabilitiesRequest = cfg.CapabilitiesRequest
+	mgr.MapSaveTimeout = cfg.MapSaveTimeout
+	mgr.DestructiveConfirm = cfg.DestructiveConfirm
+	mgr.ExpectedDowntime = cfg.PowerDowntime
+	mgr.MapSaveProgressTopic = cfg.MapSaveProgressTopic
+	mgr.MappingStableM2PerMin, mgr.MappingStableFor = cfg.MappingStableM2PerMin, cfg.MappingStableFor
+	mgr.AMCLPoseTopic = cfg.LocalizationAMCLTopic
+	mgr.MarkerTopic = cfg.IncidentMarkerTopic
+	mgr.SharedConnections = cfg.RosbridgeShared
+	mgr.Thumbnails = robot.NewThumbnailStore(cfg.MapThumbnailDir)
+	mgr.MapArchive = robot.NewMapArchive(cfg.MapArchiveDir, cfg.MapArchiveMaxVersions, int64(cfg.MapArchiveMaxMB)<<20)
+	mgr.Points = robot.NewPointStore(cfg.PointsDir, cfg.PointsSaveDelay)
+	mgr.Usage = robot.NewUsageStore(cfg.UsageDir)
+	mgr.UsageJumpM = cfg.UsageJumpM
+	mgr.Visits = robot.NewVisitStore(cfg.VisitsDir)
+	mgr.VisitRadiusM, mgr.VisitHysteresisM = cfg.VisitRadiusM, cfg.VisitHysteresisM
+	rosbridge.Debug = func() bool { return cfg.Dynamic().DebugRosbridge }
+	mgr.TopicThrottles = func() map[string]int { return cfg.Dynamic().TopicThrottles }
+	mgr.StaleThresholds = func() map[string]int { return cfg.Dynamic().StaleThresholds }
+	mgr.VelRatioBounds = func() robot.RatioBounds {
+		d := cfg.Dynamic()
+		return robot.RatioBounds{Min: d.VelRatioMin, Max: d.VelRatioMax}
+	}
+	mgr.IdleOptions = func() robot.IdleOptions {
+		d := cfg.Dynamic()
+		topics := rosbridge.DefaultIdleTopics
+		if d.IdleDropTopics != nil {
+			topics.Drop = d.IdleDropTopics
+		}
+		if d.IdleThrottles != nil {
+			topics.Throttles = d.IdleThrottles
+		}
+		return robot.IdleOptions{After: d.IdleAfter, Topics: topics}
+	}
 	nav := robot.NewNavigationManager()
+	nav.MaxDwellSec = cfg.NavMaxDwellSec
+	nav.PatrolResumeOnReconnect = cfg.PatrolResumeOnReconnect
+	nav.GoAllMaxDistanceM = cfg.NavGoAllMaxDistanceM
+	nav.GoAllPoseMaxAge = cfg.NavPoseMaxAge
+
+	// Background work (mDNS discovery, reports) stops on shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+
+	// Robot discovery; mDNS announcements are pushed to the UI
+	disc := discovery.NewService()
+	disc.OnMDNSFound = func(c discovery.Candidate) {
+		mgr.Broadcast(robot.BroadcastMsg{Type: "robot_discovered", Data: c})
+	}
+	if cfg.DiscoveryMDNS {
+		disc.StartMDNS(bgCtx, cfg.DiscoveryServiceType)
+	}
+
+	// Periodic rosbridge bandwidth report for opted-in WS clients
+	go mgr.RunBandwidthReports(bgCtx, robot.BandwidthReportInterval)
+
+	// Unused robots drop their heavy subscriptions
+	go mgr.RunIdle(bgCtx, robot.IdleCheckInterval)
+
+	// Robot-to-robot proximity warnings
+	go mgr.RunFleetMonitor(bgCtx, robot.FleetMonitorInterval)
+
+	// Navigation points are saved to disk as they change
+	go mgr.RunPointPersistence(bgCtx, robot.PointPersistInterval)
+
+	// Distance and active-time counters are saved periodically
+	go mgr.RunUsagePersistence(bgCtx, robot.UsagePersistInterval)
+	go mgr.RunVisitMonitor(bgCtx, robot.VisitCheckInterval)
+	go mgr.RunVisitPersistence(bgCtx, robot.VisitPersistInterval)
+
+	// Coverage growth of mapping runs
+	go mgr.RunMappingProgress(bgCtx, robot.MappingProgressInterval)
+
+	// Webhooks: events from the manager are posted by a background worker
+	hooks, err := webhook.NewService(cfg.WebhooksFile)
+	if err != nil {
+		log.Fatalf("[server] Webhooks: %v", err)
+	}
+	hooks.BatteryAlias, hooks.BatteryLowPct = cfg.WebhookBatteryAlias, cfg.WebhookBatteryLowPct
+	go hooks.Run(bgCtx)
+	go hooks.Follow(bgCtx, mgr)
+
+	// Voice phrases are mapped to commands before they reach a robot
+	phraseStore, err := phrases.Open(cfg.SpeechPhrasesFile)
+	if err != nil {
+		log.Fatalf("[server] Speech phrases: %v", err)
+	}
+
+	// Settings templates shared by identical robots
+	templates, err := robot.OpenSettingsTemplates(cfg.TemplatesFile)
+	if err != nil {
+		log.Fatalf("[server] Settings templates: %v", err)
+	}
+
+	// MQTT bridge; its broker connection is independent of the robots
+	if cfg.MQTTBroker != "" {
+		topics := cfg.MQTTTopics
+		if len(topics) == 0 {
+			topics = mqtt.DefaultTopics
+		}
+		bridge, err := mqtt.NewBridge(mqtt.BridgeOptions{
+			Options: mqtt.Options{
+				Broker:   cfg.MQTTBroker,
+				ClientID: cfg.MQTTClientID,
+				Username: cfg.MQTTUsername,
+				Password: cfg.MQTTPassword,
+			},
+			Prefix:    cfg.MQTTPrefix,
+			QoS:       byte(cfg.MQTTQoS),
+			MaxRateHz: cfg.MQTTMaxRateHz,
+			Topics:    topics,
+			Commands:  cfg.MQTTCommands,
+		}, mgr, nav)
+		if err != nil {
+			log.Fatalf("[server] MQTT: %v", err)
+		}
+		go bridge.Run(bgCtx)
+	}
 
-	// Whisper runner (optional)
-	whisper := handlers.NewWhisperRunner(cfg.WhisperBinPath, cfg.WhisperModelPath, cfg.SpeechLogDir)
+	origins, err := handlers.NewOriginPolicy(cfg.CORSOrigins, cfg.CORSAllowCredentials)
+	if err != nil {
+		log.Fatalf("[server] CORS: %v", err)
+	}
 
 	// Handler server
 	srv := &handlers.Server{
+		Config:     cfg,
 		Manager:    mgr,
 		NavManager: nav,
-		Whisper:    whisper,
+		Discovery:  disc,
+		Webhooks:   hooks,
+		Phrases:    phraseStore,
 		Templates:  tmpl,
+		Static:     staticSub,
+		Assets:     assets,
+		Branding:   branding,
+		Origins:    origins,
+		NoUI:       cfg.NoUI,
+
+		SettingsTemplates: templates,
 	}
 
+	// Old speech recordings are deleted in the background
+	go srv.RunSpeechRetention(bgCtx, handlers.SpeechSweepInterval)
+
+	// Routes are declared in handlers.Routes, which also feeds /api/spec;
+	// the admin listener gets the API and probes from the same table
 	mux := http.NewServeMux()
+	routes := srv.Routes()
+	handlers.Register(mux, routes)
+	adminMux := http.NewServeMux()
+	adminRoutes := handlers.AdminRoutes(routes)
+	handlers.Register(adminMux, adminRoutes)
 
-	// Static files
-	staticSub, _ := fs.Sub(staticFS, "static")
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticSub))))
-
-	// Pages
-	mux.HandleFunc("/", srv.IndexPage)
-
-	// Robot API
-	mux.HandleFunc("/api/robots", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			srv.ListRobots(w, r)
-		case http.MethodPost:
-			srv.AddRobot(w, r)
-		case http.MethodDelete:
-			srv.RemoveRobot(w, r)
-		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	// HTTP(S) servers: with TLS_LISTEN_ADDR the plain listener only
+	// redirects; without it LISTEN_ADDR serves HTTPS when TLS is on.
+	if err := cfg.ValidateTLS(); err != nil {
+		log.Fatalf("[server] TLS config: %v", err)
+	}
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+	if cfg.TLSSelfSigned {
+		var generated bool
+		certFile, keyFile, generated, err = tlscert.EnsureSelfSigned(cfg.TLSDir, cfg.TLSHosts)
+		if err != nil {
+			log.Fatalf("[server] Self-signed certificate: %v", err)
 		}
-	})
-	mux.HandleFunc("/api/robots/switch", srv.SwitchRobot)
-	mux.HandleFunc("/api/robots/status", srv.RobotStatus)
-	mux.HandleFunc("/api/robots/velocity_history", srv.GetVelocityHistory)
-	mux.HandleFunc("/api/robots/settings", srv.UpdateSettings)
-	mux.HandleFunc("/api/robots/task", srv.RequestTask)
-	mux.HandleFunc("/api/robots/poweroff", srv.PowerOff)
-	mux.HandleFunc("/api/robots/reboot", srv.Reboot)
-
-	// Map API
-	mux.HandleFunc("/api/maps", srv.ListMaps)
-	mux.HandleFunc("/api/maps/save", srv.SaveMap)
-	mux.HandleFunc("/api/maps/open", srv.OpenMap)
-
-	// Mode API
-	mux.HandleFunc("/api/mode/navigation", srv.SetNavigationMode)
-	mux.HandleFunc("/api/mode/mapping", srv.SetMappingMode)
-	mux.HandleFunc("/api/mode/remapping", srv.SetRemappingMode)
-
-	// Navigation API
-	mux.HandleFunc("/api/nav/add", srv.AddNavigationPoint)
-	mux.HandleFunc("/api/nav/list", srv.ListNavigationPoints)
-	mux.HandleFunc("/api/nav/send", srv.SendNavigationPoints)
-	mux.HandleFunc("/api/nav/go", srv.GoAllPoints)
-	mux.HandleFunc("/api/nav/clear", srv.ClearNavigationPoints)
-	mux.HandleFunc("/api/nav/fetch", srv.RequestNavPointsFromRobot)
-	mux.HandleFunc("/api/nav/import", srv.ImportNavPoints)
-	mux.HandleFunc("/api/nav/delete", srv.DeleteNavPoint)
-
-	// Speech API
-	mux.HandleFunc("/api/speech/status", srv.SpeechStatus)
-	mux.HandleFunc("/api/speech/transcribe", srv.SpeechTranscribe)
-
-	// HTMX partials
-	mux.HandleFunc("/partial/robots", srv.RobotListPartial)
-	mux.HandleFunc("/partial/settings", srv.SettingsPartial)
-	mux.HandleFunc("/partial/nav_points", srv.NavPointsPartial)
-
-	// Dialog fragments
-	mux.HandleFunc("/dialog/add_robot", srv.AddRobotDialog)
-	mux.HandleFunc("/dialog/save_map", srv.SaveMapDialog)
-	mux.HandleFunc("/dialog/open_map", srv.OpenMapDialog)
-	mux.HandleFunc("/dialog/confirm", srv.ConfirmDialog)
-	mux.HandleFunc("/dialog/add_nav_point", srv.AddNavPointDialog)
-
-	// WebSocket
-	mux.HandleFunc("/ws", srv.WSHandler)
-
-	// HTTP Server
-	httpServer := &http.Server{
-		Addr:         cfg.ListenAddr,
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-
-	// Graceful shutdown
+		fp, _ := tlscert.Fingerprint(certFile)
+		if generated {
+			log.Printf("[server] Generated self-signed certificate %s", certFile)
+		}
+		log.Printf("[server] Certificate SHA-256 fingerprint: %s", fp)
+	}
+
+	appHandler := handlers.BasePath(cfg.BasePath, handlers.CORS(mux, srv.Origins, routes))
+	newServer := func(addr string, h http.Handler) *http.Server {
+		return &http.Server{
+			Addr:         addr,
+			Handler:      h,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+	}
+	type listener struct {
+		server *http.Server
+		tls    bool
+		what   string
+	}
+	var listeners []listener
+	switch {
+	case !cfg.TLSEnabled():
+		listeners = append(listeners, listener{newServer(cfg.ListenAddr, appHandler), false, "HTTP"})
+	case cfg.TLSListenAddr == "":
+		listeners = append(listeners, listener{newServer(cfg.ListenAddr, appHandler), true, "HTTPS"})
+	default:
+		listeners = append(listeners,
+			listener{newServer(cfg.TLSListenAddr, appHandler), true, "HTTPS"},
+			listener{newServer(cfg.ListenAddr, handlers.RedirectHTTPS(cfg.TLSListenAddr, cfg.BasePath, mux)), false, "HTTP redirect"})
+	}
+	if cfg.AdminListenAddr != "" {
+		listeners = append(listeners, listener{newServer(cfg.AdminListenAddr, handlers.BasePath(cfg.BasePath, handlers.CORS(adminMux, srv.Origins, adminRoutes))), false, "Admin HTTP"})
+	}
+	if cfg.NoUI {
+		log.Printf("[server] UI disabled: serving the API only")
+	}
+
+	// SIGHUP re-reads the configuration
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			srv.Reload()
+		}
+	}()
+
+	// Graceful shutdown of every listener
 	go func() {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		<-sigCh
 		log.Println("[server] Shutting down...")
+		stopBackground()
+		mgr.FlushPoints()
+		mgr.FlushUsage()
+		mgr.FlushVisits()
 		mgr.ClearAll()
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		httpServer.Shutdown(ctx)
+		for _, l := range listeners {
+			l.server.Shutdown(ctx)
+		}
 	}()
 
-	log.Printf("[server] Listening on %s", cfg.ListenAddr)
-	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
-		log.Fatalf("[server] Fatal: %v", err)
+	log.Printf("[server] %s (%s) starting", version.Version, version.Commit)
+	errCh := make(chan error, len(listeners))
+	for _, l := range listeners {
+		l := l
+		go func() {
+			log.Printf("[server] %s listening on %s", l.what, l.server.Addr)
+			if l.tls {
+				// ServeTLS negotiates HTTP/2 via ALPN; WebSockets stay on
+				// HTTP/1.1 upgrades.
+				errCh <- l.server.ListenAndServeTLS(certFile, keyFile)
+			} else {
+				errCh <- l.server.ListenAndServe()
+			}
+		}()
+	}
+	for range listeners {
+		if err := <-errCh; err != http.ErrServerClosed {
+			log.Fatalf("[server] Fatal: %v", err)
+		}
 	}
 }
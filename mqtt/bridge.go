@@ -0,0 +1,351 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Manager → MQTT bridge
+//
+// The bridge reads the manager's broadcasts from a subscription, like a
+// browser, and republishes them:
+//
+//	<prefix>/server/status         online / offline (retained; offline is the will)
+//	<prefix>/<robot_id>/status     connection, e-stop and mode (retained)
+//	<prefix>/<robot_id>/<type>     the broadcast frame for each mirrored type
+//	<prefix>/fleet/<type>          mirrored fleet-wide frames
+//
+// Mirrored topics are rate limited; a frame arriving too soon replaces
+// the pending one, so the latest state is always published. With
+// commands enabled, <prefix>/<robot_id>/cmd/stop and .../cmd/goto are
+// executed and answered on <prefix>/<robot_id>/cmd_result.
+//
+// The broker connection lives apart from robot handling: frames are
+// dropped while it is down and it is redialled with backoff.
+
+// Reconnect backoff.
+const (
+	reconnectMin = time.Second
+	reconnectMax = time.Minute
+)
+
+// DefaultTopics are the broadcast types mirrored by default.
+var DefaultTopics = []string{"odom", "map_bfp", "velocity", "nav_status", "estop", "mode", "home", "patrol", "autonomy"}
+
+// BridgeOptions configure the bridge.
+type BridgeOptions struct {
+	Options
+	Prefix    string
+	QoS       byte
+	MaxRateHz float64  // per mirrored topic; 0 = unlimited
+	Topics    []string // broadcast types to mirror
+	Commands  bool     // accept cmd/stop and cmd/goto
+}
+
+// Validate checks the broker and QoS.
+func (o BridgeOptions) Validate() error {
+	switch {
+	case o.Broker == "":
+		return errors.New("MQTT broker URL required")
+	case o.QoS > 1:
+		return errors.New("MQTT QoS must be 0 or 1")
+	case o.MaxRateHz < 0:
+		return errors.New("MQTT max rate must not be negative")
+	case strings.ContainsAny(o.Prefix, "+#"):
+		return errors.New("MQTT topic prefix must not contain wildcards")
+	}
+	return nil
+}
+
+// Bridge mirrors a manager to an MQTT broker.
+type Bridge struct {
+	opts BridgeOptions
+	mgr  *robot.Manager
+	nav  *robot.NavigationManager
+	want map[string]bool
+
+	mu   sync.Mutex
+	conn *Conn
+
+	// Rate limiting state, owned by the mirror goroutine
+	topics map[string]*topicState
+}
+
+// topicState is the last publish of a mirrored topic and the frame
+// waiting for its next slot.
+type topicState struct {
+	last    time.Time
+	pending []byte
+}
+
+// NewBridge returns a bridge for mgr; nav runs commands.
+func NewBridge(o BridgeOptions, mgr *robot.Manager, nav *robot.NavigationManager) (*Bridge, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	o.Prefix = strings.TrimSuffix(o.Prefix, "/")
+	b := &Bridge{opts: o, mgr: mgr, nav: nav, want: map[string]bool{}, topics: map[string]*topicState{}}
+	for _, t := range o.Topics {
+		b.want[t] = true
+	}
+	return b, nil
+}
+
+// Run mirrors broadcasts and keeps the broker connection up until ctx
+// ends, then marks the server offline.
+func (b *Bridge) Run(ctx context.Context) {
+	go b.mirror(ctx)
+
+	opts := b.opts.Options
+	opts.Will = &Message{Topic: b.topic("server", "status"), Payload: []byte("offline"), QoS: b.opts.QoS, Retain: true}
+	delay := reconnectMin
+	for ctx.Err() == nil {
+		conn, err := Dial(ctx, opts, b.onMessage)
+		if err != nil {
+			log.Printf("[mqtt] Connect to %s failed: %v; retrying in %s", b.opts.Broker, err, delay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = nextReconnectDelay(delay)
+			continue
+		}
+		delay = reconnectMin
+		log.Printf("[mqtt] Connected to %s", b.opts.Broker)
+		b.online(conn)
+
+		select {
+		case <-ctx.Done():
+			conn.Publish(Message{Topic: b.topic("server", "status"), Payload: []byte("offline"), QoS: b.opts.QoS, Retain: true})
+			b.setConn(nil)
+			conn.Close()
+			return
+		case <-conn.Done():
+			b.setConn(nil)
+			log.Printf("[mqtt] Connection lost: %v; reconnecting", conn.Err())
+		}
+	}
+}
+
+// nextReconnectDelay doubles the backoff after a failed dial, up to
+// reconnectMax.
+func nextReconnectDelay(d time.Duration) time.Duration {
+	return min(d*2, reconnectMax)
+}
+
+// online announces the server, republishes every robot's status and
+// subscribes to commands on a new connection.
+func (b *Bridge) online(conn *Conn) {
+	conn.Publish(Message{Topic: b.topic("server", "status"), Payload: []byte("online"), QoS: b.opts.QoS, Retain: true})
+	if b.opts.Commands {
+		if err := conn.Subscribe(b.topic("+", "cmd", "+"), b.opts.QoS); err != nil {
+			log.Printf("[mqtt] Subscribe to commands: %v", err)
+		}
+	}
+	b.setConn(conn)
+	for _, rb := range b.mgr.GetAllRobots() {
+		b.publishStatus(rb.ID)
+	}
+}
+
+func (b *Bridge) setConn(c *Conn) {
+	b.mu.Lock()
+	b.conn = c
+	b.mu.Unlock()
+}
+
+// publish sends to the current connection, if any.
+func (b *Bridge) publish(topic string, payload []byte, retain bool) {
+	b.mu.Lock()
+	c := b.conn
+	b.mu.Unlock()
+	if c == nil {
+		return
+	}
+	c.Publish(Message{Topic: topic, Payload: payload, QoS: b.opts.QoS, Retain: retain})
+}
+
+func (b *Bridge) topic(parts ...string) string {
+	if b.opts.Prefix == "" {
+		return strings.Join(parts, "/")
+	}
+	return b.opts.Prefix + "/" + strings.Join(parts, "/")
+}
+
+// ──────────────────────────── Mirroring
+
+// RobotStatus is the retained <robot_id>/status payload.
+type RobotStatus struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Namespace  string     `json:"namespace"`
+	Connected  bool       `json:"connected"`
+	EStop      bool       `json:"estop"`
+	Mode       robot.Mode `json:"mode,omitempty"`
+	CurrentMap string     `json:"current_map"`
+}
+
+func (b *Bridge) mirror(ctx context.Context) {
+	ch := b.mgr.Subscribe()
+	defer b.mgr.Unsubscribe(ch)
+	flush := time.NewTicker(50 * time.Millisecond)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-flush.C:
+			b.flushPending(now)
+		case msg := <-ch:
+			switch msg.Type {
+			case "robot_added", "robot_connected", "robot_disconnected", "estop", "mode":
+				b.publishStatus(msg.RobotID)
+			case "robot_removed":
+				b.publish(b.topic(msg.RobotID, "status"), nil, true) // clears the retained status
+			}
+			if !b.want[msg.Type] {
+				continue
+			}
+			data, err := msg.JSON() // shared with the WebSocket clients
+			if err != nil {
+				continue
+			}
+			scope := msg.RobotID
+			if scope == "" {
+				scope = "fleet"
+			}
+			b.limit(b.topic(scope, msg.Type), data, time.Now())
+		}
+	}
+}
+
+// limit publishes data now, or holds it until the topic's next slot.
+func (b *Bridge) limit(topic string, data []byte, now time.Time) {
+	st := b.topics[topic]
+	if st == nil {
+		st = &topicState{}
+		b.topics[topic] = st
+	}
+	if b.opts.MaxRateHz <= 0 || now.Sub(st.last) >= b.interval() {
+		st.last, st.pending = now, nil
+		b.publish(topic, data, false)
+		return
+	}
+	st.pending = data
+}
+
+func (b *Bridge) flushPending(now time.Time) {
+	for topic, st := range b.topics {
+		if st.pending != nil && now.Sub(st.last) >= b.interval() {
+			data := st.pending
+			st.last, st.pending = now, nil
+			b.publish(topic, data, false)
+		}
+	}
+}
+
+func (b *Bridge) interval() time.Duration {
+	return time.Duration(float64(time.Second) / b.opts.MaxRateHz)
+}
+
+func (b *Bridge) publishStatus(id string) {
+	rb := b.mgr.GetRobot(id)
+	if rb == nil {
+		return
+	}
+	s := rb.GetSnapshot()
+	data, _ := json.Marshal(RobotStatus{
+		ID: s.ID, Name: s.Name, Namespace: s.Namespace, Connected: s.Connected,
+		EStop: s.EStop, Mode: s.Mode, CurrentMap: s.CurrentMap,
+	})
+	b.publish(b.topic(id, "status"), data, true)
+}
+
+// ──────────────────────────── Commands
+
+// CommandResult is the <robot_id>/cmd_result payload.
+type CommandResult struct {
+	Command string `json:"command"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+// gotoCommand is the cmd/goto payload: {"target": "home"} or a point
+// type ("waypoint", "service_point", ...) sent like POST /api/nav/go. A
+// bare target string is accepted too.
+type gotoCommand struct {
+	Target string `json:"target"`
+	Force  bool   `json:"force"`
+}
+
+// onMessage runs on the connection's read goroutine; commands may wait
+// on the robot, so they run on their own.
+func (b *Bridge) onMessage(m Message) {
+	parts := strings.Split(strings.TrimPrefix(m.Topic, b.topic("")), "/")
+	if len(parts) != 3 || parts[1] != "cmd" {
+		return
+	}
+	go b.command(parts[0], parts[2], m.Payload)
+}
+
+func (b *Bridge) command(id, cmd string, payload []byte) {
+	res := CommandResult{Command: cmd}
+	if err := b.run(id, cmd, payload); err != nil {
+		res.Error = err.Error()
+	} else {
+		res.OK = true
+	}
+	log.Printf("[mqtt] Command %s for robot %s: ok=%v %s", cmd, id, res.OK, res.Error)
+	data, _ := json.Marshal(res)
+	b.publish(b.topic(id, "cmd_result"), data, false)
+}
+
+// run executes a command through the calls the HTTP handlers use.
+func (b *Bridge) run(id, cmd string, payload []byte) error {
+	rb := b.mgr.GetRobot(id)
+	if rb == nil {
+		return fmt.Errorf("robot %s not found", id)
+	}
+	switch cmd {
+	case "stop":
+		// DELETE /api/robots/move_relative + POST /api/nav/patrol/stop
+		rb.CancelMove()
+		_, err := b.nav.StopPatrol(rb)
+		return err
+	case "goto":
+		var g gotoCommand
+		if p := strings.TrimSpace(string(payload)); strings.HasPrefix(p, "{") {
+			if err := json.Unmarshal(payload, &g); err != nil {
+				return fmt.Errorf("invalid goto payload: %w", err)
+			}
+		} else {
+			g.Target = p
+		}
+		if g.Target == "home" {
+			// POST /api/robots/go_home
+			_, err := rb.GoHome()
+			return err
+		}
+		// POST /api/nav/go
+		pt, err := rosbridge.ParsePointType(g.Target)
+		if err == nil && !pt.Navigable() {
+			err = fmt.Errorf("%q can't be navigated", g.Target)
+		}
+		if err != nil {
+			return err
+		}
+		return b.nav.GoAll(rb, pt, g.Force)
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
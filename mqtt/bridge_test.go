@@ -0,0 +1,282 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// fakeBroker answers CONNECT with connack, records what clients publish
+// and acknowledge, and hands out their connections to publish to them.
+// The first refusals connections are refused as unavailable.
+type fakeBroker struct {
+	ln        net.Listener
+	connack   byte
+	refusals  atomic.Int32
+	connects  chan []byte
+	published chan Message
+	pubacks   chan uint16
+	conns     chan net.Conn
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{ln: ln, connects: make(chan []byte, 10), published: make(chan Message, 100), pubacks: make(chan uint16, 10), conns: make(chan net.Conn, 10)}
+	t.Cleanup(func() { ln.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *fakeBroker) url() string { return "tcp://" + b.ln.Addr().String() }
+
+func (b *fakeBroker) serve() {
+	for {
+		nc, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(nc)
+	}
+}
+
+func (b *fakeBroker) handle(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	p, err := readPacket(r)
+	if err != nil || p.kind != pktConnect {
+		return
+	}
+	b.connects <- p.body
+	rc := b.connack
+	if b.refusals.Add(-1) >= 0 {
+		rc = 3
+	}
+	nc.Write(encode(pktConnack, 0, []byte{0, rc}))
+	if rc != 0 {
+		return
+	}
+	b.conns <- nc
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case pktPublish:
+			m, _, err := parsePublish(p)
+			if err != nil {
+				return
+			}
+			b.published <- m
+		case pktPuback:
+			if len(p.body) == 2 {
+				b.pubacks <- binary.BigEndian.Uint16(p.body)
+			}
+		case pktSubscribe:
+			nc.Write(encode(pktSuback, 0, append(p.body[:2:2], 0)))
+		case pktPingreq:
+			nc.Write(encode(pktPingresp, 0, nil))
+		case pktDisconnect:
+			return
+		}
+	}
+}
+
+// next returns the next message published on topic, skipping others.
+func (b *fakeBroker) next(t *testing.T, topic string) Message {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case m := <-b.published:
+			if m.Topic == topic {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("nothing published on %s", topic)
+		}
+	}
+}
+
+// none checks nothing is published on topic for d.
+func (b *fakeBroker) none(t *testing.T, topic string, d time.Duration) {
+	t.Helper()
+	timeout := time.After(d)
+	for {
+		select {
+		case m := <-b.published:
+			if m.Topic == topic {
+				t.Errorf("published on %s: %s", topic, m.Payload)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// runBridge runs a bridge for mgr against broker until the test ends.
+func runBridge(t *testing.T, broker *fakeBroker, mgr *robot.Manager, o BridgeOptions) context.CancelFunc {
+	t.Helper()
+	o.Broker = broker.url()
+	o.ClientID = "rom-test"
+	if o.Topics == nil {
+		o.Topics = DefaultTopics
+	}
+	b, err := NewBridge(o, mgr, robot.NewNavigationManager())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { b.Run(ctx); close(done) }()
+	t.Cleanup(func() { cancel(); <-done })
+	return cancel
+}
+
+func TestBridgeMirror(t *testing.T) {
+	broker := newFakeBroker(t)
+	mgr := robot.NewManager()
+	rb, _ := mgr.AddRobot("amr", "amr", "127.0.0.1", 9)
+	defer rb.Close()
+	cancel := runBridge(t, broker, mgr, BridgeOptions{Prefix: "rom/"})
+
+	if m := broker.next(t, "rom/server/status"); string(m.Payload) != "online" || !m.Retain {
+		t.Errorf("server status %+v", m)
+	}
+	var st RobotStatus
+	m := broker.next(t, "rom/"+rb.ID+"/status")
+	if err := json.Unmarshal(m.Payload, &st); err != nil || !m.Retain || st.ID != rb.ID || st.Name != "amr" || st.Connected {
+		t.Errorf("robot status %s", m.Payload)
+	}
+
+	// A mirrored frame is the bytes the WebSocket clients get
+	sub := mgr.Subscribe()
+	defer mgr.Unsubscribe(sub)
+	mgr.Broadcast(robot.BroadcastMsg{Type: "tf", RobotID: rb.ID}) // not mirrored
+	mgr.Broadcast(robot.BroadcastMsg{Type: "odom", RobotID: rb.ID, Data: rosbridge.OdomData{PosX: 1.5}})
+	mgr.Broadcast(robot.BroadcastMsg{Type: "patrol", Data: "fleet"})
+	m = broker.next(t, "rom/"+rb.ID+"/odom")
+	<-sub
+	ws, _ := (<-sub).JSON()
+	if string(m.Payload) != string(ws) || m.Retain {
+		t.Errorf("odom %s, WebSocket clients get %s", m.Payload, ws)
+	}
+	if m := broker.next(t, "rom/fleet/patrol"); !strings.Contains(string(m.Payload), `"data":"fleet"`) {
+		t.Errorf("fleet frame %s", m.Payload)
+	}
+	broker.none(t, "rom/"+rb.ID+"/tf", 100*time.Millisecond)
+
+	// Removing the robot clears its retained status
+	mgr.RemoveRobot(rb.ID)
+	if m := broker.next(t, "rom/"+rb.ID+"/status"); len(m.Payload) != 0 || !m.Retain {
+		t.Errorf("status after removal %+v", m)
+	}
+
+	// Stopping marks the server offline; the will says the same
+	cancel()
+	if m := broker.next(t, "rom/server/status"); string(m.Payload) != "offline" || !m.Retain {
+		t.Errorf("stopped: %+v", m)
+	}
+	if body := <-broker.connects; !strings.Contains(string(body), "rom/server/status") || !strings.Contains(string(body), "offline") {
+		t.Errorf("CONNECT without the will: %q", body)
+	}
+}
+
+// TestBridgeRateLimit broadcasts a burst: the first frame goes out at
+// once and the last one at the next slot; those between are replaced.
+func TestBridgeRateLimit(t *testing.T) {
+	broker := newFakeBroker(t)
+	mgr := robot.NewManager()
+	runBridge(t, broker, mgr, BridgeOptions{MaxRateHz: 4, Topics: []string{"odom"}})
+	broker.next(t, "server/status")
+
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		mgr.Broadcast(robot.BroadcastMsg{Type: "odom", RobotID: "1", Data: rosbridge.OdomData{PosX: float64(i)}})
+	}
+	for _, want := range []string{`"pos_x":1,`, `"pos_x":5,`} {
+		if m := broker.next(t, "1/odom"); !strings.Contains(string(m.Payload), want) {
+			t.Errorf("published %s, want %s", m.Payload, want)
+		}
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("second frame after %v, within the 250ms interval", d)
+	}
+	broker.none(t, "1/odom", 400*time.Millisecond)
+}
+
+func TestBridgeCommands(t *testing.T) {
+	broker := newFakeBroker(t)
+	mgr := robot.NewManager()
+	rb, _ := mgr.AddRobot("amr", "", "127.0.0.1", 9)
+	defer rb.Close()
+	runBridge(t, broker, mgr, BridgeOptions{Prefix: "rom", Commands: true})
+	broker.next(t, "rom/server/status")
+	var nc net.Conn
+	select {
+	case nc = <-broker.conns:
+	case <-time.After(2 * time.Second):
+		t.Fatal("bridge never connected")
+	}
+
+	for _, c := range []struct {
+		id, cmd, payload, want string
+	}{
+		{rb.ID, "stop", "", "robot not connected"},
+		{rb.ID, "goto", "home", "no home position set"},
+		{rb.ID, "goto", `{"target": "waypoint"}`, "robot not connected"},
+		{rb.ID, "goto", "wall", "can't be navigated"},
+		{rb.ID, "goto", `{"target": `, "invalid goto payload"},
+		{rb.ID, "dance", "", `unknown command "dance"`},
+		{"42", "stop", "", "robot 42 not found"},
+	} {
+		nc.Write(publishPacket(Message{Topic: "rom/" + c.id + "/cmd/" + c.cmd, Payload: []byte(c.payload), QoS: 1}, 7))
+		var res CommandResult
+		m := broker.next(t, "rom/"+c.id+"/cmd_result")
+		if err := json.Unmarshal(m.Payload, &res); err != nil || res.Command != c.cmd || res.OK || !strings.Contains(res.Error, c.want) {
+			t.Errorf("%s %s %q: %s, want %s", c.id, c.cmd, c.payload, m.Payload, c.want)
+		}
+	}
+}
+
+func TestDialRefused(t *testing.T) {
+	broker := newFakeBroker(t)
+	broker.connack = 5
+	if _, err := Dial(context.Background(), Options{Broker: broker.url()}, nil); err == nil || err.Error() != "mqtt: not authorized" {
+		t.Errorf("refused: %v", err)
+	}
+	if _, err := Dial(context.Background(), Options{Broker: "http://" + broker.ln.Addr().String()}, nil); err == nil || !strings.Contains(err.Error(), "unsupported broker scheme") {
+		t.Errorf("http broker: %v", err)
+	}
+}
+
+func TestBridgeOptionsValidate(t *testing.T) {
+	for _, c := range []struct {
+		o    BridgeOptions
+		want string
+	}{
+		{BridgeOptions{Options: Options{Broker: "tcp://b"}, QoS: 1, MaxRateHz: 2, Prefix: "rom"}, ""},
+		{BridgeOptions{}, "broker URL required"},
+		{BridgeOptions{Options: Options{Broker: "tcp://b"}, QoS: 2}, "QoS must be 0 or 1"},
+		{BridgeOptions{Options: Options{Broker: "tcp://b"}, MaxRateHz: -1}, "must not be negative"},
+		{BridgeOptions{Options: Options{Broker: "tcp://b"}, Prefix: "rom/#"}, "wildcards"},
+	} {
+		err := c.o.Validate()
+		if c.want == "" && err != nil || c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+			t.Errorf("%+v: %v, want %q", c.o, err, c.want)
+		}
+	}
+}
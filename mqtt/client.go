@@ -0,0 +1,243 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ──────────────────────────── Broker connection
+//
+// A Conn is one session with a clean start; after it fails the caller
+// dials again. QoS 1 publishes are not stored for redelivery, so one
+// lost with the connection is gone, which suits telemetry that is
+// superseded by the next update anyway.
+
+// Message is a PUBLISH payload.
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte // 0 or 1
+	Retain  bool
+}
+
+// Options configure a connection.
+type Options struct {
+	// Broker is tcp://host:port or mqtt:// (default port 1883), or
+	// ssl://, tls:// or mqtts:// (default port 8883).
+	Broker    string
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration // default 30 s
+	Will      *Message      // published by the broker if the connection is lost
+}
+
+// writeTimeout bounds each packet write, so a stalled broker fails the
+// connection instead of blocking the publisher.
+const writeTimeout = 5 * time.Second
+
+// Conn is a connected MQTT session.
+type Conn struct {
+	nc        net.Conn
+	keepAlive time.Duration
+	onMessage func(Message)
+
+	wmu    sync.Mutex // serializes writes and packet IDs
+	nextID uint16
+
+	done     chan struct{}
+	doneOnce sync.Once
+	err      error
+}
+
+// Dial connects to the broker and completes the CONNECT handshake.
+// onMessage receives PUBLISH packets from subscriptions, on the read
+// goroutine.
+func Dial(ctx context.Context, o Options, onMessage func(Message)) (*Conn, error) {
+	u, err := url.Parse(o.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: broker URL: %w", err)
+	}
+	var secure bool
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		secure = true
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported broker scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if secure {
+			port = "8883"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var nc net.Conn
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	if secure {
+		td := &tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: u.Hostname()}}
+		nc, err = td.DialContext(ctx, "tcp", addr)
+	} else {
+		nc, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keepAlive := o.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = 30 * time.Second
+	}
+	c := &Conn{nc: nc, keepAlive: keepAlive, onMessage: onMessage, done: make(chan struct{})}
+	r := bufio.NewReader(nc)
+
+	nc.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := nc.Write(connectPacket(o, uint16(keepAlive/time.Second))); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	p, err := readPacket(r)
+	if err == nil && (p.kind != pktConnack || len(p.body) != 2) {
+		err = errors.New("mqtt: expected CONNACK")
+	}
+	if err == nil && p.body[1] != 0 {
+		err = connackError(p.body[1])
+	}
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+
+	go c.readLoop(r)
+	go c.pingLoop()
+	return c, nil
+}
+
+// Publish sends m.
+func (c *Conn) Publish(m Message) error {
+	if m.QoS > 1 {
+		return errors.New("mqtt: QoS 2 is not supported")
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	id := uint16(0)
+	if m.QoS > 0 {
+		id = c.packetIDLocked()
+	}
+	return c.writeLocked(publishPacket(m, id))
+}
+
+// Subscribe asks for messages matching filter.
+func (c *Conn) Subscribe(filter string, qos byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeLocked(subscribePacket(filter, qos, c.packetIDLocked()))
+}
+
+// Close ends the session cleanly; the broker discards the will.
+func (c *Conn) Close() error {
+	c.wmu.Lock()
+	c.writeLocked(encode(pktDisconnect, 0, nil))
+	c.wmu.Unlock()
+	c.fail(errors.New("mqtt: closed"))
+	return nil
+}
+
+// Done is closed when the connection ends.
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+// Err returns why the connection ended.
+func (c *Conn) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+func (c *Conn) packetIDLocked() uint16 {
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	return c.nextID
+}
+
+func (c *Conn) writeLocked(b []byte) error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	c.nc.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.nc.Write(b); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) fail(err error) {
+	c.doneOnce.Do(func() {
+		c.err = err
+		c.nc.Close()
+		close(c.done)
+	})
+}
+
+// readLoop handles incoming packets. The broker answers a ping within
+// the keep-alive period, so a silent connection is considered lost.
+func (c *Conn) readLoop(r *bufio.Reader) {
+	for {
+		c.nc.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		p, err := readPacket(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if p.kind != pktPublish {
+			continue // PUBACK, SUBACK, PINGRESP
+		}
+		m, id, err := parsePublish(p)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if m.QoS == 1 {
+			c.wmu.Lock()
+			c.writeLocked(encode(pktPuback, 0, binary.BigEndian.AppendUint16(nil, id)))
+			c.wmu.Unlock()
+		}
+		if c.onMessage != nil {
+			c.onMessage(m)
+		}
+	}
+}
+
+func (c *Conn) pingLoop() {
+	t := time.NewTicker(c.keepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			c.wmu.Lock()
+			c.writeLocked(encode(pktPingreq, 0, nil))
+			c.wmu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"rom_go_app/robot"
+)
+
+// TestConnPublishSubscribe exchanges messages with the fake broker:
+// CONNECT carries the options, publishes arrive, and a QoS 1 message
+// from the broker is delivered and acknowledged with its packet ID.
+func TestConnPublishSubscribe(t *testing.T) {
+	broker := newFakeBroker(t)
+	got := make(chan Message, 1)
+	c, err := Dial(context.Background(), Options{Broker: broker.url(), ClientID: "rom-test", KeepAlive: 20 * time.Second}, func(m Message) { got <- m })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	f := parseConnect(t, encode(pktConnect, 0, <-broker.connects))
+	if f.keepAlive != 20 || f.strings[0] != "rom-test" {
+		t.Errorf("CONNECT %+v", f)
+	}
+
+	if err := c.Subscribe("rom/+/cmd/+", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(Message{Topic: "rom/1/odom", Payload: []byte("x"), QoS: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if m := broker.next(t, "rom/1/odom"); string(m.Payload) != "x" || m.QoS != 1 {
+		t.Errorf("published %+v", m)
+	}
+	if err := c.Publish(Message{Topic: "t", QoS: 2}); err == nil {
+		t.Error("QoS 2 published")
+	}
+
+	nc := <-broker.conns
+	nc.Write(publishPacket(Message{Topic: "rom/1/cmd/stop", Payload: []byte("{}"), QoS: 1}, 42))
+	select {
+	case m := <-got:
+		if m.Topic != "rom/1/cmd/stop" || string(m.Payload) != "{}" {
+			t.Errorf("received %+v", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not delivered")
+	}
+	select {
+	case id := <-broker.pubacks:
+		if id != 42 {
+			t.Errorf("PUBACK for %d, want 42", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no PUBACK")
+	}
+
+	// The broker going away ends the connection
+	nc.Close()
+	select {
+	case <-c.Done():
+		if c.Err() == nil {
+			t.Error("no error after the broker closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection still up")
+	}
+	if err := c.Publish(Message{Topic: "t"}); err == nil {
+		t.Error("published on a closed connection")
+	}
+}
+
+// TestConnKeepAlive has a broker that accepts the connection but never
+// answers: the connection is dropped after 1.5 keep-alive periods.
+func TestConnKeepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		r := bufio.NewReader(nc)
+		readPacket(r)
+		nc.Write(encode(pktConnack, 0, []byte{0, 0}))
+		for {
+			if _, err := readPacket(r); err != nil { // pings, unanswered
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	c, err := Dial(context.Background(), Options{Broker: "tcp://" + ln.Addr().String(), KeepAlive: 100 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c.Done():
+		if d := time.Since(start); d < 150*time.Millisecond {
+			t.Errorf("dropped after %s, before 1.5 keep-alives", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("silent broker not detected")
+	}
+}
+
+// TestBridgeReconnect refuses the first connection, then drops the
+// second: the bridge retries after the backoff and redials at once after
+// a lost connection, announcing itself each time.
+func TestBridgeReconnect(t *testing.T) {
+	broker := newFakeBroker(t)
+	broker.refusals.Store(1)
+	runBridge(t, broker, robot.NewManager(), BridgeOptions{QoS: 1})
+
+	<-broker.connects
+	refused := time.Now()
+	<-broker.connects
+	if d := time.Since(refused); d < reconnectMin-50*time.Millisecond {
+		t.Errorf("redialled %s after a refusal, want the %s backoff", d, reconnectMin)
+	}
+	if m := broker.next(t, "server/status"); string(m.Payload) != "online" {
+		t.Errorf("status %q", m.Payload)
+	}
+
+	(<-broker.conns).Close()
+	select {
+	case <-broker.connects:
+	case <-time.After(time.Second):
+		t.Fatal("no redial after the connection was lost")
+	}
+	if m := broker.next(t, "server/status"); string(m.Payload) != "online" {
+		t.Errorf("status after reconnecting %q", m.Payload)
+	}
+}
+
+func TestNextReconnectDelay(t *testing.T) {
+	d := reconnectMin
+	var seen []time.Duration
+	for i := 0; i < 8; i++ {
+		seen = append(seen, d)
+		d = nextReconnectDelay(d)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("delays %v, want %v", seen, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package mqtt mirrors robot broadcasts to an MQTT broker. It carries
+// its own minimal MQTT 3.1.1 client.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ──────────────────────────── MQTT 3.1.1 packets
+//
+// Only what the bridge needs: CONNECT (with will and credentials),
+// PUBLISH at QoS 0 and 1 both ways, SUBSCRIBE, keep-alive pings and
+// DISCONNECT. Packets are a type/flags byte, a variable-length
+// "remaining length" and the body; strings are length-prefixed UTF-8.
+
+// Packet types (high nibble of the first byte).
+const (
+	pktConnect    = 1
+	pktConnack    = 2
+	pktPublish    = 3
+	pktPuback     = 4
+	pktSubscribe  = 8
+	pktSuback     = 9
+	pktPingreq    = 12
+	pktPingresp   = 13
+	pktDisconnect = 14
+)
+
+// maxPacketSize bounds incoming packets; the bridge only receives small
+// commands.
+const maxPacketSize = 1 << 20
+
+var errMalformed = errors.New("mqtt: malformed packet")
+
+// packet is a decoded fixed header and body.
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// encode frames body with the fixed header.
+func encode(kind, flags byte, body []byte) []byte {
+	out := []byte{kind<<4 | flags&0x0f}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+// readPacket reads one packet.
+func readPacket(r *bufio.Reader) (packet, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, errMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		n += int(b&0x7f) * mult
+		if b&0x80 == 0 {
+			break
+		}
+		mult *= 128
+	}
+	if n > maxPacketSize {
+		return packet{}, fmt.Errorf("mqtt: %d byte packet exceeds limit", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: h >> 4, flags: h & 0x0f, body: body}, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// readString splits a length-prefixed string off b.
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errMalformed
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errMalformed
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// connectPacket builds CONNECT with a clean session.
+func connectPacket(o Options, keepAliveS uint16) []byte {
+	flags := byte(0x02) // clean session
+	if o.Will != nil {
+		flags |= 0x04 | o.Will.QoS<<3
+		if o.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if o.Username != "" {
+		flags |= 0x80
+		if o.Password != "" {
+			flags |= 0x40
+		}
+	}
+	b := appendString(nil, "MQTT")
+	b = append(b, 4, flags)
+	b = binary.BigEndian.AppendUint16(b, keepAliveS)
+	b = appendString(b, o.ClientID)
+	if o.Will != nil {
+		b = appendString(b, o.Will.Topic)
+		b = appendBytes(b, o.Will.Payload)
+	}
+	if o.Username != "" {
+		b = appendString(b, o.Username)
+		if o.Password != "" {
+			b = appendString(b, o.Password)
+		}
+	}
+	return encode(pktConnect, 0, b)
+}
+
+// publishPacket builds PUBLISH; id is used for QoS 1.
+func publishPacket(m Message, id uint16) []byte {
+	flags := m.QoS << 1
+	if m.Retain {
+		flags |= 0x01
+	}
+	b := appendString(nil, m.Topic)
+	if m.QoS > 0 {
+		b = binary.BigEndian.AppendUint16(b, id)
+	}
+	return encode(pktPublish, flags, append(b, m.Payload...))
+}
+
+// parsePublish decodes an incoming PUBLISH, returning its packet ID (0
+// for QoS 0).
+func parsePublish(p packet) (Message, uint16, error) {
+	m := Message{QoS: p.flags >> 1 & 0x03, Retain: p.flags&0x01 != 0}
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return Message{}, 0, err
+	}
+	m.Topic = topic
+	var id uint16
+	if m.QoS > 0 {
+		if len(rest) < 2 {
+			return Message{}, 0, errMalformed
+		}
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	m.Payload = rest
+	return m, id, nil
+}
+
+// subscribePacket builds SUBSCRIBE for one filter.
+func subscribePacket(filter string, qos byte, id uint16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, id)
+	b = appendString(b, filter)
+	return encode(pktSubscribe, 0x02, append(b, qos))
+}
+
+// connackError explains a refused CONNACK return code.
+func connackError(rc byte) error {
+	switch rc {
+	case 1:
+		return errors.New("mqtt: broker refused the protocol version")
+	case 2:
+		return errors.New("mqtt: broker rejected the client ID")
+	case 3:
+		return errors.New("mqtt: broker unavailable")
+	case 4:
+		return errors.New("mqtt: bad user name or password")
+	case 5:
+		return errors.New("mqtt: not authorized")
+	}
+	return fmt.Errorf("mqtt: connection refused (code %d)", rc)
+}
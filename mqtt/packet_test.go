@@ -0,0 +1,162 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, b []byte) packet {
+	t.Helper()
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestRemainingLength round-trips bodies at the edges of each
+// remaining-length byte count.
+func TestRemainingLength(t *testing.T) {
+	for _, c := range []struct{ n, lenBytes int }{
+		{0, 1}, {127, 1}, {128, 2}, {16383, 2}, {16384, 3}, {maxPacketSize, 3},
+	} {
+		body := bytes.Repeat([]byte{0xab}, c.n)
+		out := encode(pktPublish, 0x03, body)
+		if got := len(out) - 1 - c.n; got != c.lenBytes {
+			t.Errorf("%d byte body: %d length bytes, want %d", c.n, got, c.lenBytes)
+		}
+		p := decode(t, out)
+		if p.kind != pktPublish || p.flags != 0x03 || !bytes.Equal(p.body, body) {
+			t.Errorf("%d byte body: decoded %d/%d, %d bytes", c.n, p.kind, p.flags, len(p.body))
+		}
+	}
+	// 2097152 needs four bytes: 0x80 0x80 0x80 0x01
+	if out := encode(pktPublish, 0, make([]byte, 2097152)); !bytes.Equal(out[1:5], []byte{0x80, 0x80, 0x80, 0x01}) {
+		t.Errorf("four byte length %x", out[1:5])
+	}
+
+	for name, b := range map[string][]byte{
+		"five length bytes": {pktPublish << 4, 0x80, 0x80, 0x80, 0x80, 0x01},
+		"over the limit":    encode(pktPublish, 0, make([]byte, maxPacketSize+1)),
+		"short body":        {pktPublish << 4, 0x05, 'a'},
+	} {
+		if _, err := readPacket(bufio.NewReader(bytes.NewReader(b))); err == nil {
+			t.Errorf("%s: no error", name)
+		} else if name == "five length bytes" && !errors.Is(err, errMalformed) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// connectFields decodes the CONNECT body built by connectPacket.
+type connectFields struct {
+	flags     byte
+	keepAlive uint16
+	strings   []string // client ID, will topic and payload, user, password
+}
+
+func parseConnect(t *testing.T, b []byte) connectFields {
+	t.Helper()
+	p := decode(t, b)
+	if p.kind != pktConnect {
+		t.Fatalf("packet type %d", p.kind)
+	}
+	proto, rest, err := readString(p.body)
+	if err != nil || proto != "MQTT" || rest[0] != 4 {
+		t.Fatalf("protocol %q level %d: %v", proto, rest[0], err)
+	}
+	f := connectFields{flags: rest[1], keepAlive: binary.BigEndian.Uint16(rest[2:])}
+	for rest = rest[4:]; len(rest) > 0; {
+		var s string
+		if s, rest, err = readString(rest); err != nil {
+			t.Fatal(err)
+		}
+		f.strings = append(f.strings, s)
+	}
+	return f
+}
+
+func TestConnectPacket(t *testing.T) {
+	f := parseConnect(t, connectPacket(Options{ClientID: "rom"}, 30))
+	if f.flags != 0x02 || f.keepAlive != 30 || !reflect.DeepEqual(f.strings, []string{"rom"}) {
+		t.Errorf("plain: %+v", f)
+	}
+
+	o := Options{
+		ClientID: "rom",
+		Username: "user",
+		Password: "secret",
+		Will:     &Message{Topic: "rom/server/status", Payload: []byte("offline"), QoS: 1, Retain: true},
+	}
+	f = parseConnect(t, connectPacket(o, 15))
+	// user, password, will retain, will QoS 1, will, clean session
+	if want := byte(0x80 | 0x40 | 0x20 | 0x08 | 0x04 | 0x02); f.flags != want {
+		t.Errorf("flags %08b, want %08b", f.flags, want)
+	}
+	if want := []string{"rom", "rom/server/status", "offline", "user", "secret"}; !reflect.DeepEqual(f.strings, want) {
+		t.Errorf("fields %q, want %q", f.strings, want)
+	}
+
+	// A password without a user name isn't sent
+	o = Options{ClientID: "rom", Password: "secret"}
+	if f = parseConnect(t, connectPacket(o, 15)); f.flags != 0x02 || len(f.strings) != 1 {
+		t.Errorf("password only: %+v", f)
+	}
+}
+
+func TestPublishRoundTrip(t *testing.T) {
+	for _, c := range []struct {
+		m  Message
+		id uint16
+	}{
+		{Message{Topic: "rom/1/odom", Payload: []byte(`{"x":1}`)}, 0},
+		{Message{Topic: "rom/1/status", Payload: []byte("online"), QoS: 1, Retain: true}, 513},
+		{Message{Topic: "rom/1/empty", QoS: 1}, 1},
+	} {
+		m, id, err := parsePublish(decode(t, publishPacket(c.m, c.id)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.m.Payload) == 0 {
+			c.m.Payload = []byte{}
+		}
+		if !reflect.DeepEqual(m, c.m) || id != c.id {
+			t.Errorf("%+v id %d round-tripped to %+v id %d", c.m, c.id, m, id)
+		}
+	}
+
+	for name, p := range map[string]packet{
+		"no topic":       {kind: pktPublish},
+		"short topic":    {kind: pktPublish, body: []byte{0, 5, 'a'}},
+		"QoS 1 no id":    {kind: pktPublish, flags: 0x02, body: []byte{0, 1, 'a', 0}},
+		"truncated name": {kind: pktPublish, body: []byte{0}},
+	} {
+		if _, _, err := parsePublish(p); !errors.Is(err, errMalformed) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestSubscribePacket(t *testing.T) {
+	p := decode(t, subscribePacket("rom/+/cmd/+", 1, 7))
+	if p.kind != pktSubscribe || p.flags != 0x02 {
+		t.Errorf("header %d/%d", p.kind, p.flags)
+	}
+	filter, rest, err := readString(p.body[2:])
+	if binary.BigEndian.Uint16(p.body) != 7 || err != nil || filter != "rom/+/cmd/+" || !bytes.Equal(rest, []byte{1}) {
+		t.Errorf("body %x", p.body)
+	}
+}
+
+func TestConnackError(t *testing.T) {
+	for rc, want := range map[byte]string{1: "protocol version", 2: "client ID", 3: "unavailable", 4: "password", 5: "not authorized", 9: "code 9"} {
+		if err := connackError(rc); !strings.Contains(err.Error(), want) {
+			t.Errorf("code %d: %v", rc, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package phrases
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ──────────────────────────── Normalization
+//
+// The same words reach the server in different code point sequences:
+// speech backends and keyboards differ in zero-width joiners, full-width
+// Latin, Myanmar or ASCII digits, punctuation, case, precomposed or
+// decomposed letters and the order of stacked Myanmar marks. Phrases and
+// transcripts are normalized alike before matching:
+//
+//   - format characters (zero-width space and joiners, BOM) are dropped
+//   - the text is put in NFKC: compatibility forms (full-width ASCII,
+//     ligatures) are folded, letters composed and marks canonically
+//     ordered
+//   - Myanmar and Shan digits become ASCII digits, which NFKC leaves
+//   - punctuation (။ and ၊ included) and any space become one space
+//   - letters are lower-cased
+//
+// Format characters go first because a joiner between two marks would
+// otherwise keep NFKC from reordering them.
+
+// Normalize returns s normalized for matching.
+func Normalize(s string) string {
+	s = norm.NFKC.String(strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s))
+	var b strings.Builder
+	b.Grow(len(s))
+	space := true // drops leading spaces and collapses runs
+	for _, r := range s {
+		switch {
+		case r >= '၀' && r <= '၉':
+			r = '0' + (r - '၀')
+		case r >= '႐' && r <= '႙':
+			r = '0' + (r - '႐')
+		}
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			if !space {
+				b.WriteByte(' ')
+				space = true
+			}
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+		space = false
+	}
+	return strings.TrimRight(b.String(), " ")
+}
@@ -0,0 +1,189 @@
+// Package phrases maps localized voice phrases to the canonical command
+// strings a robot's voice command parser understands.
+//
+// A transcript in the operator's language (Burmese script, say) is
+// normalized, then scanned left to right for the longest phrase of the
+// table at each position; matched spans are replaced by their commands
+// and the rest is kept, so "ရှေ့ ၂ မီတာ" can become "forward 2 meters".
+// A transcript with no phrase in it is forwarded as it was, flagged as
+// unmapped.
+package phrases
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Table limits.
+const (
+	MaxPhrases   = 1000
+	MaxPhraseLen = 200 // runes, of the phrase and of the command
+)
+
+// Mapping outcomes.
+const (
+	StatusMapped   = "mapped"   // every word was covered by a phrase
+	StatusPartial  = "partial"  // some words were left as spoken
+	StatusUnmapped = "unmapped" // no phrase matched; the text is sent as is
+)
+
+// Phrase maps a spoken phrase to a command.
+type Phrase struct {
+	Phrase  string `json:"phrase"`         // as spoken, in any script
+	Command string `json:"command"`        // what the robot receives instead
+	Lang    string `json:"lang,omitempty"` // ISO 639-1; empty: any language
+}
+
+// Table is a deployment's phrase list.
+type Table struct {
+	Phrases []Phrase `json:"phrases"`
+}
+
+// Validate checks that every phrase and command is set and not too long,
+// and that no phrase is listed twice for a language once normalized.
+func (t Table) Validate() error {
+	if len(t.Phrases) > MaxPhrases {
+		return fmt.Errorf("at most %d phrases, got %d", MaxPhrases, len(t.Phrases))
+	}
+	seen := make(map[string]int, len(t.Phrases))
+	for i, p := range t.Phrases {
+		norm := Normalize(p.Phrase)
+		switch {
+		case norm == "":
+			return fmt.Errorf("phrase %d: phrase is empty", i)
+		case strings.TrimSpace(p.Command) == "":
+			return fmt.Errorf("phrase %d: command is empty", i)
+		case len([]rune(p.Phrase)) > MaxPhraseLen || len([]rune(p.Command)) > MaxPhraseLen:
+			return fmt.Errorf("phrase %d: phrase and command must be at most %d characters", i, MaxPhraseLen)
+		}
+		key := p.Lang + "\x00" + norm
+		if j, dup := seen[key]; dup {
+			return fmt.Errorf("phrase %d: %q is phrase %d again", i, p.Phrase, j)
+		}
+		seen[key] = i
+	}
+	return nil
+}
+
+// Match is a phrase found in a transcript.
+type Match struct {
+	Phrase  string `json:"phrase"` // as listed in the table
+	Command string `json:"command"`
+	// Start and End are rune offsets in Result.Normalized.
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Result is the mapping of one transcript.
+type Result struct {
+	Original   string  `json:"original"`
+	Normalized string  `json:"normalized"`
+	Command    string  `json:"command"` // what is sent to the robot
+	Status     string  `json:"status"`  // mapped, partial or unmapped
+	Matches    []Match `json:"matches,omitempty"`
+	// Unmapped are the runs of words no phrase covered.
+	Unmapped []string `json:"unmapped,omitempty"`
+}
+
+// matcher is a table ready for matching: phrases by their first rune,
+// longest first, table order among equally long ones.
+type matcher struct {
+	byFirst map[rune][]entry
+}
+
+type entry struct {
+	runes []rune
+	p     Phrase
+}
+
+func newMatcher(t Table) *matcher {
+	m := &matcher{byFirst: map[rune][]entry{}}
+	for _, p := range t.Phrases {
+		rs := []rune(Normalize(p.Phrase))
+		if len(rs) == 0 {
+			continue
+		}
+		m.byFirst[rs[0]] = append(m.byFirst[rs[0]], entry{rs, p})
+	}
+	for _, es := range m.byFirst {
+		sort.SliceStable(es, func(i, j int) bool { return len(es[i].runes) > len(es[j].runes) })
+	}
+	return m
+}
+
+// Map maps text, spoken in lang (empty: unknown), with the phrases of
+// that language and those for any.
+func (m *matcher) Map(text, lang string) Result {
+	res := Result{Original: text, Normalized: Normalize(text)}
+	rs := []rune(res.Normalized)
+
+	var out, residual []string
+	var run []rune
+	flush := func() {
+		if w := strings.TrimSpace(string(run)); w != "" {
+			out = append(out, w)
+			if strings.IndexFunc(w, unicode.IsLetter) >= 0 {
+				residual = append(residual, w)
+			}
+		}
+		run = run[:0]
+	}
+	for i := 0; i < len(rs); {
+		if e, ok := m.longestAt(rs, i, lang); ok {
+			flush()
+			out = append(out, strings.TrimSpace(e.p.Command))
+			res.Matches = append(res.Matches, Match{Phrase: e.p.Phrase, Command: e.p.Command, Start: i, End: i + len(e.runes)})
+			i += len(e.runes)
+			continue
+		}
+		run = append(run, rs[i])
+		i++
+	}
+	flush()
+
+	switch {
+	case len(res.Matches) == 0:
+		res.Status = StatusUnmapped
+		res.Command = text
+		return res
+	case len(residual) > 0:
+		res.Status = StatusPartial
+		res.Unmapped = residual
+	default:
+		res.Status = StatusMapped
+	}
+	res.Command = strings.Join(out, " ")
+	return res
+}
+
+// longestAt returns the longest phrase of lang matching rs at i, whose
+// ends don't cut a word of a spaced script.
+func (m *matcher) longestAt(rs []rune, i int, lang string) (entry, bool) {
+	if i > 0 && inWord(rs[i-1]) && inWord(rs[i]) {
+		return entry{}, false
+	}
+	for _, e := range m.byFirst[rs[i]] {
+		if e.p.Lang != "" && lang != "" && e.p.Lang != lang {
+			continue
+		}
+		end := i + len(e.runes)
+		if end > len(rs) || string(rs[i:end]) != string(e.runes) {
+			continue
+		}
+		if end < len(rs) && inWord(rs[end-1]) && inWord(rs[end]) {
+			continue
+		}
+		return e, true
+	}
+	return entry{}, false
+}
+
+// inWord reports whether r belongs to a word of a script that separates
+// words with spaces (Latin, Cyrillic, Greek, digits): a phrase may not
+// start or end between two such runes. Scripts written without spaces,
+// Myanmar among them, match anywhere.
+func inWord(r rune) bool {
+	return unicode.IsDigit(r) || unicode.In(r, unicode.Latin, unicode.Cyrillic, unicode.Greek)
+}
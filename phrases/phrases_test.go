@@ -0,0 +1,174 @@
+package phrases
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"  Go   HOME!  ", "go home"},
+		{"ＧＯ　ｈｏｍｅ", "go home"},                   // full-width letters and space
+		{"go\u200b ho\u200dme\ufeff", "go home"}, // zero-width space, joiner, BOM
+		{"၂ မီတာ။", "2 မီတာ"},                    // Myanmar digit and full stop
+		{"ရှေ့၊ ၁၀", "ရှေ့ 10"},                  // little section
+		{"႑႒", "12"},                             // Shan digits
+		{"မ\u103a\u1037", "မ\u1037\u103a"},       // asat before dot below
+		{"မ\u103a\u200d\u1037", "မ\u1037\u103a"}, // ... with a joiner between them
+		{"\u1025\u102e", "\u1026"},               // decomposed ဦ
+		{"\u1026", "\u1026"},                     // precomposed ဦ
+		{"cafe\u0301", "caf\u00e9"},              // decomposed é
+		{"\ufb01x ①", "fix 1"},                   // ligature, circled digit
+		{"", ""},
+	} {
+		if got := Normalize(tc.in); got != tc.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ok := Table{Phrases: []Phrase{
+		{Phrase: "ရှေ့", Command: "forward", Lang: "my"},
+		{Phrase: "ရှေ့", Command: "forward"}, // any language: another key
+		{Phrase: "stop", Command: "stop", Lang: "en"},
+	}}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("valid table: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		t    Table
+		want string
+	}{
+		"empty phrase":    {Table{Phrases: []Phrase{{Phrase: " ။ ", Command: "stop"}}}, "phrase is empty"},
+		"empty command":   {Table{Phrases: []Phrase{{Phrase: "stop", Command: "  "}}}, "command is empty"},
+		"too long":        {Table{Phrases: []Phrase{{Phrase: strings.Repeat("á", MaxPhraseLen+1), Command: "x"}}}, "at most"},
+		"duplicate":       {Table{Phrases: []Phrase{{Phrase: "Stop", Command: "stop"}, {Phrase: "ＳＴＯＰ!", Command: "halt"}}}, "phrase 0 again"},
+		"duplicate in my": {Table{Phrases: []Phrase{{Phrase: "ရပ်", Command: "stop", Lang: "my"}, {Phrase: "ရ\u200bပ်", Command: "stop", Lang: "my"}}}, "phrase 0 again"},
+		"too many":        {Table{Phrases: make([]Phrase, MaxPhrases+1)}, "at most"},
+	} {
+		err := tc.t.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: %v, want an error with %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestMapLongestMatch(t *testing.T) {
+	m := newMatcher(Table{Phrases: []Phrase{
+		{Phrase: "ရှေ့", Command: "forward"},
+		{Phrase: "ရှေ့ဆက်", Command: "continue forward"},
+		{Phrase: "မီတာ", Command: "meters"},
+		{Phrase: "go", Command: "forward"},
+		{Phrase: "go home", Command: "dock"},
+	}})
+
+	for _, tc := range []struct {
+		in, command, status string
+		phrases             []string
+	}{
+		{"ရှေ့ ၂ မီတာ", "forward 2 meters", StatusMapped, []string{"ရှေ့", "မီတာ"}},
+		{"ရှေ့ဆက် ၃ မီတာ။", "continue forward 3 meters", StatusMapped, []string{"ရှေ့ဆက်", "မီတာ"}},
+		{"ရှေ့၂မီတာ", "forward 2 meters", StatusMapped, []string{"ရှေ့", "မီတာ"}}, // unspaced script
+		{"Go Home", "dock", StatusMapped, []string{"go home"}},
+		{"go homeward", "forward homeward", StatusPartial, []string{"go"}}, // a word is not cut
+		{"gogo home", "gogo home", StatusUnmapped, nil},
+	} {
+		res := m.Map(tc.in, "")
+		var got []string
+		for _, match := range res.Matches {
+			got = append(got, match.Phrase)
+		}
+		if res.Command != tc.command || res.Status != tc.status || !reflect.DeepEqual(got, tc.phrases) {
+			t.Errorf("Map(%q) = %q %s %v, want %q %s %v", tc.in, res.Command, res.Status, got, tc.command, tc.status, tc.phrases)
+		}
+		if res.Original != tc.in {
+			t.Errorf("Map(%q) original %q", tc.in, res.Original)
+		}
+	}
+
+	// Offsets are runes of the normalized text
+	res := m.Map("ရှေ့ဆက် ၃", "")
+	if n := len([]rune("ရှေ့ဆက်")); len(res.Matches) != 1 || res.Matches[0].Start != 0 || res.Matches[0].End != n {
+		t.Errorf("matches %+v, want [0, %d)", res.Matches, n)
+	}
+}
+
+func TestMapMixedScript(t *testing.T) {
+	m := newMatcher(Table{Phrases: []Phrase{
+		{Phrase: "ရှေ့", Command: "forward", Lang: "my"},
+		{Phrase: "ဘယ်", Command: "left"},
+		{Phrase: "dock", Command: "go home", Lang: "en"},
+		{Phrase: "stop", Command: "stop"},
+	}})
+
+	for _, tc := range []struct {
+		in, lang, command, status string
+		unmapped                  []string
+	}{
+		{"ＳＴＯＰ ရှေ့", "my", "stop forward", StatusMapped, nil},
+		{"ရှေ့\u200b dock", "my", "forward dock", StatusPartial, []string{"dock"}}, // en phrase in my
+		{"ရှေ့ dock", "en", "ရှေ့ go home", StatusPartial, []string{"ရှေ့"}},       // my phrase in en
+		{"ရှေ့ dock ဘယ်", "", "forward go home left", StatusMapped, nil},           // unknown: all
+		{"turn ဘယ် now", "", "turn left now", StatusPartial, []string{"turn", "now"}},
+		{"ကျေးဇူး ၅", "my", "ကျေးဇူး ၅", StatusUnmapped, nil}, // unmapped is sent as spoken
+	} {
+		res := m.Map(tc.in, tc.lang)
+		if res.Command != tc.command || res.Status != tc.status || !reflect.DeepEqual(res.Unmapped, tc.unmapped) {
+			t.Errorf("Map(%q, %q) = %q %s %q, want %q %s %q", tc.in, tc.lang, res.Command, res.Status, res.Unmapped, tc.command, tc.status, tc.unmapped)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf", "phrases.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap := s.Snapshot(); len(snap.Phrases) != 0 || snap.UpdatedAt != nil || snap.Path != path {
+		t.Errorf("new store: %+v", snap)
+	}
+	if res := s.Map("ရှေ့", "my"); res.Status != StatusUnmapped || res.Command != "ရှေ့" {
+		t.Errorf("empty table: %+v", res)
+	}
+
+	bad := Table{Phrases: []Phrase{{Phrase: "stop", Command: "stop"}, {Phrase: "Stop", Command: "halt"}}}
+	if err := s.Set(bad); err == nil {
+		t.Error("duplicate phrases saved")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("invalid table written: %v", err)
+	}
+
+	table := Table{Phrases: []Phrase{{Phrase: "ရှေ့", Command: "forward", Lang: "my"}}}
+	if err := s.Set(table); err != nil {
+		t.Fatal(err)
+	}
+	if res := s.Map("ရှေ့", "my"); res.Command != "forward" {
+		t.Errorf("after Set: %+v", res)
+	}
+
+	// Reopened, the table is the saved one
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap := reopened.Snapshot(); !reflect.DeepEqual(snap.Table, table) || snap.UpdatedAt == nil {
+		t.Errorf("reopened: %+v", snap)
+	}
+
+	// A file edited into an invalid table is refused
+	data, _ := json.Marshal(bad)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("invalid file opened: %v", err)
+	}
+}
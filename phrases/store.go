@@ -0,0 +1,101 @@
+package phrases
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store keeps the phrase table, persisted as JSON in one file, and maps
+// transcripts with it. The table is replaced whole by Set, taking effect
+// for the next transcript.
+type Store struct {
+	path string
+
+	mu      sync.RWMutex
+	table   Table
+	match   *matcher
+	updated time.Time
+}
+
+// Snapshot is the table with where it is kept and when it last changed.
+type Snapshot struct {
+	Table
+	Path      string     `json:"path"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // zero before the first save or load
+}
+
+// Open loads the table saved at path; a missing file starts empty.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, match: newMatcher(Table{})}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.table, s.match = t, newMatcher(t)
+	if info, err := os.Stat(path); err == nil {
+		s.updated = info.ModTime()
+	}
+	return s, nil
+}
+
+// Snapshot returns a copy of the table.
+func (s *Store) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := Snapshot{Table: Table{Phrases: append([]Phrase{}, s.table.Phrases...)}, Path: s.path}
+	if !s.updated.IsZero() {
+		at := s.updated
+		snap.UpdatedAt = &at
+	}
+	return snap
+}
+
+// Set validates t, saves it and makes it the table.
+func (s *Store) Set(t Table) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	t.Phrases = append([]Phrase{}, t.Phrases...)
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.table, s.match, s.updated = t, newMatcher(t), time.Now()
+	return nil
+}
+
+// Map maps a transcript in lang (ISO 639-1, empty: unknown) with the
+// current table.
+func (s *Store) Map(text, lang string) Result {
+	s.mu.RLock()
+	m := s.match
+	s.mu.RUnlock()
+	return m.Map(text, lang)
+}
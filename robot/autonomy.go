@@ -0,0 +1,118 @@
+package robot
+
+import (
+	"errors"
+	"time"
+)
+
+// ──────────────────────────── Autonomy lock
+//
+// While the robot drives itself (a navigation goal is active, a patrol is
+// running, or an operator set the manual lock) joystick input is
+// rejected, so a stray touch on a tablet can't fight Nav2 for cmd_vel.
+// Taking over cancels navigation and the patrol; until autonomy engages
+// again, joystick messages carrying the override flag are accepted even
+// if a stale goal status still reports navigation as active.
+
+// ErrAutonomyActive is returned for joystick input while autonomy holds
+// the lock.
+var ErrAutonomyActive = errors.New("rejected: autonomy active")
+
+// Autonomy is the robot's autonomy lock state.
+type Autonomy struct {
+	Locked     bool `json:"locked"`      // joystick input is rejected
+	ManualLock bool `json:"manual_lock"` // set via /api/robots/autonomy_lock
+	Navigating bool `json:"navigating"`  // a navigation goal is active
+	Patrolling bool `json:"patrolling"`
+	TakenOver  bool `json:"taken_over"` // override accepted since the last take-over
+	Gating     bool `json:"gating"`     // false: lock is reported but not enforced
+}
+
+// SetAutonomyGating enables or disables enforcing the lock.
+func (r *Robot) SetAutonomyGating(enabled bool) {
+	r.mu.Lock()
+	r.autonomyGating = enabled
+	r.mu.Unlock()
+	r.updateAutonomy()
+}
+
+// SetAutonomyLock sets or clears the manual autonomy lock.
+func (r *Robot) SetAutonomyLock(locked bool) Autonomy {
+	r.mu.Lock()
+	r.manualLock = locked
+	r.mu.Unlock()
+	return r.updateAutonomy()
+}
+
+// GetAutonomy returns the current lock state.
+func (r *Robot) GetAutonomy() Autonomy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.autonomyLocked()
+}
+
+// autonomyLocked computes the lock state. Caller holds r.mu.
+func (r *Robot) autonomyLocked() Autonomy {
+	a := Autonomy{
+		ManualLock: r.manualLock,
+		Navigating: r.navStatus.Active(),
+		Patrolling: r.patrol != nil,
+		TakenOver:  r.takenOver,
+		Gating:     r.autonomyGating,
+	}
+	a.Locked = a.ManualLock || a.Navigating || a.Patrolling
+	return a
+}
+
+// updateAutonomy re-evaluates the lock and reports a change. Autonomy
+// engaging again (a new goal, patrol or manual lock) ends a take-over.
+func (r *Robot) updateAutonomy() Autonomy {
+	r.mu.Lock()
+	a := r.autonomyLocked()
+	if a.Locked && !r.autonomy.Locked && r.takenOver && time.Since(r.takenOverAt) > takeOverGrace {
+		r.takenOver = false
+		a.TakenOver = false
+	}
+	changed := a != r.autonomy
+	r.autonomy = a
+	r.mu.Unlock()
+
+	if changed && r.OnAutonomy != nil {
+		r.OnAutonomy(a)
+	}
+	return a
+}
+
+// takeOverGrace is how long after a take-over a still-active goal status
+// is treated as stale rather than as a new goal.
+const takeOverGrace = 3 * time.Second
+
+// JoystickVelocity applies joystick input unless autonomy holds the lock.
+// override is honoured only after TakeOver.
+func (r *Robot) JoystickVelocity(linearX, linearY, angularZ float64, override bool) error {
+	r.mu.RLock()
+	a := r.autonomyLocked()
+	r.mu.RUnlock()
+	if a.Gating && a.Locked && !(override && a.TakenOver) {
+		return ErrAutonomyActive
+	}
+	r.SetVelocity(linearX, linearY, angularZ)
+	return nil
+}
+
+// TakeOver hands control to the operator: it stops the patrol and any
+// relative move, cancels navigation, clears the manual lock and accepts
+// override joystick input until autonomy engages again.
+func (r *Robot) TakeOver() Autonomy {
+	r.stopPatrol()
+	r.CancelMove()
+	if r.IsConnected() {
+		go r.Client.CancelNavigation()
+	}
+	r.mu.Lock()
+	r.manualLock = false
+	r.takenOver = true
+	r.takenOverAt = time.Now()
+	r.mu.Unlock()
+	return r.updateAutonomy()
+}
@@ -0,0 +1,55 @@
+package robot
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ──────────────────────────── Bandwidth reports
+
+// BandwidthReportInterval is how often the "bandwidth" summary is
+// broadcast.
+const BandwidthReportInterval = 10 * time.Second
+
+// RobotBandwidth is a robot's rosbridge traffic, the state of the queues
+// between reading it and handling it, and its fragmented messages.
+type RobotBandwidth struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	BandwidthStats
+	Queues    []InboundQueueStats `json:"queues"`
+	Fragments FragmentStats       `json:"fragments"`
+}
+
+// Bandwidth returns the robot's rosbridge traffic counters.
+func (r *Robot) Bandwidth() RobotBandwidth {
+	return RobotBandwidth{ID: r.ID, Name: r.Name, BandwidthStats: r.Client.Bandwidth(), Queues: r.Client.InboundQueues(), Fragments: r.Client.Fragments()}
+}
+
+// BandwidthReport returns the traffic of every robot, sorted by ID.
+func (m *Manager) BandwidthReport() []RobotBandwidth {
+	robots := m.GetAllRobots()
+	out := make([]RobotBandwidth, 0, len(robots))
+	for _, r := range robots {
+		out = append(out, r.Bandwidth())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// RunBandwidthReports broadcasts a "bandwidth" message every interval
+// until ctx is cancelled. Taking the report also records the samples the
+// rolling rates are computed from.
+func (m *Manager) RunBandwidthReports(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		m.Broadcast(BroadcastMsg{Type: "bandwidth", Data: m.BandwidthReport()})
+	}
+}
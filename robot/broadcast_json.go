@@ -0,0 +1,46 @@
+package robot
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+)
+
+// ──────────────────────────── Shared broadcast encoding
+//
+// A broadcast goes to every subscriber as a copy of the same message, and
+// most of them (the WebSocket writers, the MQTT mirror) send it as JSON.
+// Broadcast and BroadcastMust attach an encoding cache the copies share,
+// so JSON encodes the message once, on first use, however many clients
+// are connected. A copy whose fields were changed after the broadcast
+// must be encoded with json.Marshal instead: JSON would return the
+// original.
+
+// sharedEncoding is a broadcast's JSON, made once for all its copies.
+type sharedEncoding struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+var broadcastEncodes atomic.Uint64
+
+// JSON returns msg encoded; copies of one broadcast share the encoding.
+// A message that didn't come from Broadcast is encoded on every call.
+func (msg BroadcastMsg) JSON() ([]byte, error) {
+	enc := msg.enc
+	if enc == nil {
+		broadcastEncodes.Add(1)
+		return json.Marshal(msg)
+	}
+	enc.once.Do(func() {
+		broadcastEncodes.Add(1)
+		enc.data, enc.err = json.Marshal(msg)
+	})
+	return enc.data, enc.err
+}
+
+// BroadcastEncodes is how many times JSON encoded a message since start.
+func BroadcastEncodes() uint64 {
+	return broadcastEncodes.Load()
+}
@@ -0,0 +1,126 @@
+package robot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+// receive takes the next message from each subscriber.
+func receive(t *testing.T, subs []chan BroadcastMsg) []BroadcastMsg {
+	t.Helper()
+	var got []BroadcastMsg
+	for _, ch := range subs {
+		select {
+		case msg := <-ch:
+			got = append(got, msg)
+		default:
+			t.Fatal("subscriber got nothing")
+		}
+	}
+	return got
+}
+
+// TestBroadcastJSONShared checks every copy of a broadcast gets the bytes
+// json.Marshal makes for it, from one encode.
+func TestBroadcastJSONShared(t *testing.T) {
+	m := NewManager()
+	var subs []chan BroadcastMsg
+	for i := 0; i < 10; i++ {
+		subs = append(subs, m.Subscribe())
+	}
+	var handled []BroadcastMsg
+	m.AddBroadcastHandler(func(msg BroadcastMsg) { handled = append(handled, msg) })
+
+	for _, broadcast := range []func(BroadcastMsg){m.Broadcast, m.BroadcastMust} {
+		handled = nil
+		before := BroadcastEncodes()
+		broadcast(BroadcastMsg{Type: "odom", RobotID: "1", Data: rosbridge.OdomData{PosX: 1.5, Yaw: 0.25}})
+		copies := append(receive(t, subs), handled...)
+
+		want, err := json.Marshal(copies[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var first []byte
+		for i, msg := range copies {
+			data, err := msg.JSON()
+			if err != nil || !bytes.Equal(data, want) {
+				t.Fatalf("copy %d: %s, %v; want %s", i, data, err, want)
+			}
+			if first == nil {
+				first = data
+			} else if &data[0] != &first[0] {
+				t.Errorf("copy %d encoded apart", i)
+			}
+		}
+		if n := BroadcastEncodes() - before; n != 1 {
+			t.Errorf("%d encodes for %d copies", n, len(copies))
+		}
+	}
+
+	// A restamped copy and a message built outside Broadcast are encoded
+	// each time, as they are
+	m.Broadcast(BroadcastMsg{Type: "odom", RobotID: "1", Data: rosbridge.OdomData{PosX: 2}})
+	msg := receive(t, subs[:1])[0]
+	msg.JSON()
+	msg.Data = rosbridge.OdomData{PosX: 3}
+	for _, msg := range []BroadcastMsg{m.Stamp(msg), {Type: "pong", Data: 7}} {
+		before := BroadcastEncodes()
+		want, _ := json.Marshal(msg)
+		for i := 0; i < 2; i++ {
+			if data, _ := msg.JSON(); !bytes.Equal(data, want) {
+				t.Errorf("%s: %s, want %s", msg.Type, data, want)
+			}
+		}
+		if n := BroadcastEncodes() - before; n != 2 {
+			t.Errorf("%s: %d encodes for 2 calls", msg.Type, n)
+		}
+	}
+}
+
+// BenchmarkBroadcastFanout encodes one broadcast for 10 subscribers:
+// shared, as the WebSocket writers do, or once per subscriber as they did
+// before.
+func BenchmarkBroadcastFanout(b *testing.B) {
+	grid := make([]int8, 200*200)
+	for i := range grid {
+		grid[i] = int8(i % 101)
+	}
+	for _, c := range []struct {
+		name string
+		msg  BroadcastMsg
+	}{
+		{"odom", BroadcastMsg{Type: "odom", RobotID: "1", Data: rosbridge.OdomData{PosX: 1.5, PosY: -2, Yaw: 0.25, LinearX: 0.4}}},
+		{"map", BroadcastMsg{Type: "map", RobotID: "1", Data: MapFrame{MapData: rosbridge.MapData{Width: 200, Height: 200, Resolution: 0.05, Data: grid}}}},
+	} {
+		for _, shared := range []bool{true, false} {
+			b.Run(fmt.Sprintf("%s/shared=%v", c.name, shared), func(b *testing.B) {
+				m := NewManager()
+				var subs []chan BroadcastMsg
+				for i := 0; i < 10; i++ {
+					subs = append(subs, m.Subscribe())
+				}
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					m.Broadcast(c.msg)
+					for _, ch := range subs {
+						msg := <-ch
+						var err error
+						if shared {
+							_, err = msg.JSON()
+						} else {
+							_, err = json.Marshal(msg)
+						}
+						if err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package robot
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Behavior tree templates
+//
+// The robot builds a behavior tree from uploaded points after a template
+// (see rosbridge/bt.go). A robot reporting capabilities offers the
+// templates it lists as bt_<template>; one that reports nothing is
+// offered the templates of current firmware.
+
+// ErrUnknownBTTemplate is returned for a template the robot doesn't
+// offer and doesn't report capabilities.
+var ErrUnknownBTTemplate = errors.New("unknown behavior tree template")
+
+// BTTemplates returns the behavior tree templates the robot offers.
+func (r *Robot) BTTemplates() []string {
+	caps := r.GetCapabilities()
+	if !caps.Known() {
+		return slices.Clone(rosbridge.BTTemplates)
+	}
+	var out []string
+	for _, c := range caps.Capabilities {
+		if t, ok := strings.CutPrefix(c, "bt_"); ok && c != rosbridge.CapBTPreview {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// CheckBTTemplate checks that the robot offers template; empty (the
+// robot's default) always passes. A robot listing capabilities without
+// it gets an UnsupportedError, one listing none ErrUnknownBTTemplate for
+// a template current firmware doesn't have.
+func (r *Robot) CheckBTTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !r.GetCapabilities().Known() {
+		if !slices.Contains(rosbridge.BTTemplates, template) {
+			return ErrUnknownBTTemplate
+		}
+		return nil
+	}
+	return r.Require(rosbridge.BTTemplateCapability(template))
+}
+
+// PreviewBT asks the robot which behavior tree it would build from its
+// collection of pointType with template, without storing or running it;
+// the tree is cut to maxBytes (0: no limit). It fails with an
+// UnsupportedError when the robot lists capabilities without
+// bt_preview, and with rosbridge.ErrBTPreviewUnsupported when its answer
+// carries no tree.
+func (nm *NavigationManager) PreviewBT(rb *Robot, pointType rosbridge.PointType, template string, maxBytes int) (*rosbridge.BTPreview, error) {
+	rb.mu.RLock()
+	coll := rb.pointCollection(pointType)
+	var pts []rosbridge.NavigationPoint
+	if coll != nil {
+		pts = append(make([]rosbridge.NavigationPoint, 0, len(*coll)), *coll...)
+	}
+	client := rb.Client
+	rb.mu.RUnlock()
+
+	if coll == nil {
+		return nil, invalidPointType(pointType)
+	}
+	if client == nil || !client.IsConnected() {
+		return nil, ErrNotConnected
+	}
+	for _, c := range []string{pointType.Capability(), rosbridge.CapBTPreview} {
+		if err := rb.Require(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := rb.CheckBTTemplate(template); err != nil {
+		return nil, err
+	}
+	return client.GetBTPreview(pointType, pts, template, maxBytes)
+}
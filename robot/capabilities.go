@@ -0,0 +1,177 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Capabilities
+//
+// On every connect the robot's software version and capabilities are
+// read from the which_name handshake or, if it doesn't carry them, from
+// a which_tasks capabilities request (see rosbridge/capabilities.go).
+// Requests for features the robot didn't list fail at once with an
+// UnsupportedError instead of timing out on the robot. Robots that
+// report nothing are treated as supporting everything.
+
+// Capability sources.
+const (
+	CapSourceHandshake = "handshake" // which_name response
+	CapSourceTask      = "task"      // which_tasks capabilities request
+	CapSourceNone      = "none"      // not reported: everything allowed
+)
+
+// NoCapabilitiesRequest in SetCapabilityRequest skips the which_tasks
+// capabilities request.
+const NoCapabilitiesRequest = "none"
+
+// Capabilities is what the robot reported about itself.
+type Capabilities struct {
+	SoftwareVersion string     `json:"software_version,omitempty"`
+	Capabilities    []string   `json:"capabilities"` // null: unknown, everything allowed
+	Source          string     `json:"source"`       // handshake, task or none
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+	Error           string     `json:"error,omitempty"` // why the last refresh failed
+}
+
+// Known reports whether the robot listed its capabilities.
+func (c Capabilities) Known() bool { return c.Capabilities != nil }
+
+// Supports reports whether capability is listed, or the list is unknown.
+func (c Capabilities) Supports(capability string) bool {
+	if !c.Known() {
+		return true
+	}
+	for _, name := range c.Capabilities {
+		if name == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Lists reports whether capability is listed; unlike Supports, an
+// unknown list lists nothing.
+func (c Capabilities) Lists(capability string) bool {
+	return c.Known() && c.Supports(capability)
+}
+
+// UnsupportedError is returned for requests needing a capability the
+// robot didn't report.
+type UnsupportedError struct {
+	Capability string
+}
+
+func (e *UnsupportedError) Error() string {
+	return "robot does not support " + e.Capability
+}
+
+// SetCapabilityRequest sets the which_tasks request asking for
+// capabilities (empty for the default, NoCapabilitiesRequest to skip it).
+func (r *Robot) SetCapabilityRequest(request string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.capRequest = request
+}
+
+// GetCapabilities returns what the robot last reported.
+func (r *Robot) GetCapabilities() Capabilities {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.capabilitiesLocked()
+}
+
+func (r *Robot) capabilitiesLocked() Capabilities {
+	c := r.caps
+	c.Capabilities = append([]string(nil), r.caps.Capabilities...)
+	if r.caps.Capabilities != nil && c.Capabilities == nil {
+		c.Capabilities = []string{}
+	}
+	if c.Source == "" {
+		c.Source = CapSourceNone
+	}
+	if r.caps.UpdatedAt != nil {
+		at := *r.caps.UpdatedAt
+		c.UpdatedAt = &at
+	}
+	return c
+}
+
+// Require returns an UnsupportedError if the robot reported capabilities
+// without capability.
+func (r *Robot) Require(capability string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if capability == "" || r.caps.Supports(capability) {
+		return nil
+	}
+	return &UnsupportedError{Capability: capability}
+}
+
+// RefreshCapabilities repeats the handshake and, if it carries no
+// capability list, asks through the task queue. A robot answering
+// neither becomes unknown (everything allowed); if the robot can't be
+// asked at all the previous result stays and the error is recorded.
+func (r *Robot) RefreshCapabilities() (Capabilities, error) {
+	r.mu.RLock()
+	request := r.capRequest
+	r.mu.RUnlock()
+	if request == "" {
+		request = rosbridge.DefaultCapabilitiesRequest
+	}
+
+	hs, err := r.Client.Handshake()
+	if err != nil {
+		r.setCapabilitiesError(err)
+		return r.GetCapabilities(), fmt.Errorf("capabilities: %w", err)
+	}
+
+	next := Capabilities{SoftwareVersion: hs.SoftwareVersion, Source: CapSourceNone}
+	switch {
+	case hs.Capabilities != nil:
+		next.Capabilities = rosbridge.NormalizeCapabilities(hs.Capabilities)
+		next.Source = CapSourceHandshake
+	case request != NoCapabilitiesRequest:
+		// Bypasses Require: the robot may not list "tasks" yet
+		var resp *rosbridge.WhichTaskResponse
+		resp, err = r.tasks.Run(request, "")
+		var version string
+		var caps []string
+		if err == nil {
+			version, caps, err = rosbridge.ParseCapabilities(resp.ResponseSettings)
+		}
+		switch {
+		case err == nil:
+			// Answering through which_tasks implies having it
+			next.Capabilities = append(caps, rosbridge.CapTasks)
+			next.Capabilities = rosbridge.NormalizeCapabilities(next.Capabilities)
+			next.Source = CapSourceTask
+			if version != "" {
+				next.SoftwareVersion = version
+			}
+		case errors.Is(err, rosbridge.ErrCapabilitiesUnsupported):
+			err = nil
+		default:
+			// The robot may just be slow; keep what it said last time
+			r.setCapabilitiesError(err)
+			return r.GetCapabilities(), fmt.Errorf("capabilities: %w", err)
+		}
+	}
+
+	now := time.Now()
+	next.UpdatedAt = &now
+	r.mu.Lock()
+	r.caps = next
+	r.holonomicFromCapabilities(next)
+	r.mu.Unlock()
+	return r.GetCapabilities(), nil
+}
+
+func (r *Robot) setCapabilitiesError(err error) {
+	r.mu.Lock()
+	r.caps.Error = err.Error()
+	r.mu.Unlock()
+}
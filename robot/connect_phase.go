@@ -0,0 +1,112 @@
+package robot
+
+import (
+	"errors"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Connection progress
+//
+// Adding a robot returns before it is reached, so the robot keeps the
+// phase of its connection for the list to show, reported through
+// OnConnectPhase at every change:
+//
+//	connecting → connected → handshaking → ready
+//
+// or failed, with the category of the failure (the dial's, see
+// rosbridge.DialError, or "handshake"). A robot that drops is
+// disconnected; reconnected by its policy it is ready again if it ever
+// completed a handshake, else connected.
+
+// Connection phases.
+const (
+	PhaseConnecting   = "connecting"
+	PhaseConnected    = "connected"
+	PhaseHandshaking  = "handshaking"
+	PhaseReady        = "ready"
+	PhaseFailed       = "failed"
+	PhaseDisconnected = "disconnected"
+)
+
+// FailedHandshake is the failure category of a handshake that failed on
+// a connected robot.
+const FailedHandshake = "handshake"
+
+// ConnectPhase is where the robot's connection stands.
+type ConnectPhase struct {
+	Phase    string    `json:"phase"`
+	Category string    `json:"category,omitempty"` // failed: refused, timeout, dns, unreachable, not_rosbridge, handshake or other
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+}
+
+// Busy reports whether a connection attempt is under way, for a spinner.
+func (p ConnectPhase) Busy() bool {
+	return p.Phase == PhaseConnecting || p.Phase == PhaseConnected || p.Phase == PhaseHandshaking
+}
+
+// ConnectPhase returns the robot's connection phase.
+func (r *Robot) ConnectPhase() ConnectPhase {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.phase
+}
+
+// BeginConnect marks the robot as connecting, before the attempt runs in
+// the background.
+func (r *Robot) BeginConnect() {
+	r.setPhase(ConnectPhase{Phase: PhaseConnecting})
+}
+
+// Connect dials the robot, following the phases; the error of a failed
+// dial says what to check (see rosbridge.DialError). A reverse robot
+// isn't dialed: its phase follows its agent.
+func (r *Robot) Connect() error {
+	if r.Client.ReverseEnabled() {
+		return r.Client.Connect()
+	}
+	r.BeginConnect()
+	if err := r.Client.Connect(); err != nil {
+		if !errors.Is(err, rosbridge.ErrClientClosed) {
+			r.setPhase(ConnectPhase{Phase: PhaseFailed, Category: rosbridge.DialErrorCategory(err), Error: err.Error()})
+		}
+		return err
+	}
+	r.phaseConnected(true)
+	return nil
+}
+
+// phaseConnected follows a connection: connected, or ready again for a
+// robot that completed a handshake before. Unless explicit, a phase of
+// an attempt under way is left to it.
+func (r *Robot) phaseConnected(explicit bool) {
+	r.mu.Lock()
+	cur := r.phase.Phase
+	if !explicit && (cur == PhaseConnecting || cur == PhaseHandshaking || cur == PhaseReady) {
+		r.mu.Unlock()
+		return
+	}
+	next := ConnectPhase{Phase: PhaseConnected}
+	if r.handshaken {
+		next.Phase = PhaseReady
+	}
+	r.mu.Unlock()
+	r.setPhase(next)
+}
+
+// setPhase records p, stamped now, and reports it if it changed.
+func (r *Robot) setPhase(p ConnectPhase) {
+	r.mu.Lock()
+	if r.phase.Phase == p.Phase && r.phase.Category == p.Category && r.phase.Error == p.Error {
+		r.mu.Unlock()
+		return
+	}
+	p.Since = time.Now()
+	r.phase = p
+	r.mu.Unlock()
+	if r.OnConnectPhase != nil {
+		r.OnConnectPhase(p)
+	}
+}
@@ -0,0 +1,121 @@
+package robot
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+// phaseLog records a robot's connection phases.
+type phaseLog struct {
+	mu     sync.Mutex
+	phases []ConnectPhase
+}
+
+func logPhases(r *Robot) *phaseLog {
+	l := &phaseLog{}
+	r.OnConnectPhase = func(p ConnectPhase) {
+		l.mu.Lock()
+		l.phases = append(l.phases, p)
+		l.mu.Unlock()
+	}
+	return l
+}
+
+// names returns the phases recorded since the last call.
+func (l *phaseLog) names() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for _, p := range l.phases {
+		out = append(out, p.Phase)
+	}
+	l.phases = nil
+	return out
+}
+
+func samePhases(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestConnectPhasesReady(t *testing.T) {
+	stub := newRosbridgeStub(t)
+	host, port := stub.addr(t)
+	r := NewRobot("1", "", "amr", host, port)
+	defer r.Close()
+	l := logPhases(r)
+
+	if err := r.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.names(); !samePhases(got, PhaseConnecting, PhaseConnected) || !r.ConnectPhase().Busy() {
+		t.Errorf("connect: %v", got)
+	}
+	if _, err := r.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	p := r.ConnectPhase()
+	if got := l.names(); !samePhases(got, PhaseHandshaking, PhaseReady) || p.Busy() || p.Since.IsZero() {
+		t.Errorf("handshake: %v, %+v", got, p)
+	}
+
+	// Dropped, then back: ready again, as it was handshaken before
+	r.Client.Disconnect()
+	waitUntil(t, "the disconnect", func() bool { return r.ConnectPhase().Phase == PhaseDisconnected })
+	if err := r.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.names(); !samePhases(got, PhaseDisconnected, PhaseConnecting, PhaseReady) {
+		t.Errorf("reconnect: %v", got)
+	}
+}
+
+// TestConnectPhasesFailed dials what isn't a rosbridge server, one dial
+// error category at a time.
+func TestConnectPhasesFailed(t *testing.T) {
+	// Nothing listening
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	// An HTTP server that isn't a WebSocket one
+	web := httptest.NewServer(http.NotFoundHandler())
+	defer web.Close()
+	_, webPort, _ := net.SplitHostPort(web.Listener.Addr().String())
+	port, _ := strconv.Atoi(webPort)
+
+	for _, c := range []struct {
+		host     string
+		port     int
+		category string
+	}{
+		{"127.0.0.1", closed, rosbridge.DialRefused},
+		{"127.0.0.1", port, rosbridge.DialNotRosbridge},
+		{"no-such-robot.invalid", 9090, rosbridge.DialDNS},
+	} {
+		r := NewRobot("1", "", "amr", c.host, c.port)
+		l := logPhases(r)
+		err := r.Connect()
+		p := r.ConnectPhase()
+		if err == nil || p.Phase != PhaseFailed || p.Category != c.category || p.Error != err.Error() || p.Busy() {
+			t.Errorf("%s:%d: %+v, %v", c.host, c.port, p, err)
+		}
+		if got := l.names(); !samePhases(got, PhaseConnecting, PhaseFailed) {
+			t.Errorf("%s:%d: phases %v", c.host, c.port, got)
+		}
+		r.Close()
+	}
+}
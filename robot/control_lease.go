@@ -0,0 +1,345 @@
+package robot
+
+import (
+	"errors"
+	"time"
+)
+
+// ──────────────────────────── Control lease
+//
+// For training, driving can be restricted to one connection at a time.
+// A connection asks for the robot's control lease ("request_control");
+// when nobody holds it the lease is granted at once, otherwise the
+// holder, or the API, grants or denies the request. While the lease is
+// held only the holder's joystick input, take-overs and relative moves
+// are accepted; stopping stays open to everyone. The lease ends when the
+// holder releases it, disconnects or stays inactive for the idle
+// timeout, or when the API revokes it. Without a holder anyone may drive,
+// as before, unless the lease is required.
+
+// Control lease errors.
+var (
+	ErrControlHeld      = errors.New("rejected: another operator holds control of the robot")
+	ErrControlRequired  = errors.New("rejected: request control of the robot first")
+	ErrNotController    = errors.New("only the operator holding control can do this")
+	ErrNoControlRequest = errors.New("no pending control request")
+)
+
+// DefaultControlLeaseIdle is how long the holder may stay inactive
+// before the lease ends.
+const DefaultControlLeaseIdle = 60 * time.Second
+
+// controlRequestTimeout drops a request the holder didn't answer.
+const controlRequestTimeout = 30 * time.Second
+
+// Control lease events.
+const (
+	ControlRequested = "requested"
+	ControlGranted   = "granted"
+	ControlDenied    = "denied"
+	ControlReleased  = "released"
+)
+
+// Reasons a lease ends or a request is dropped.
+const (
+	ControlReasonReleased     = "released"     // by its holder
+	ControlReasonDisconnected = "disconnected" // the connection closed
+	ControlReasonIdle         = "idle"         // no input for the idle timeout
+	ControlReasonRevoked      = "revoked"      // through the API
+)
+
+// ControlRequest is a connection waiting for the lease.
+type ControlRequest struct {
+	Driver Driver    `json:"driver"`
+	At     time.Time `json:"at"`
+}
+
+// ControlLease is who may drive the robot. Holder is nil when nobody
+// holds the lease; then anyone may drive unless Required is set.
+type ControlLease struct {
+	Holder         *Driver         `json:"holder"`
+	Since          *time.Time      `json:"since,omitempty"`
+	LastActivity   *time.Time      `json:"last_activity,omitempty"`
+	ExpiresAt      *time.Time      `json:"expires_at,omitempty"` // if the holder stays inactive
+	Pending        *ControlRequest `json:"pending,omitempty"`
+	Required       bool            `json:"required"`
+	IdleTimeoutSec float64         `json:"idle_timeout_s"`
+}
+
+// ControlLeaseEvent reports a lease transition. Driver is the requester
+// (requested, denied), the new holder (granted) or the former holder
+// (released); By is the holder who granted or denied, nil when the API
+// or the server did.
+type ControlLeaseEvent struct {
+	Event  string       `json:"event"`
+	Reason string       `json:"reason,omitempty"` // released and denied
+	Driver Driver       `json:"driver"`
+	By     *Driver      `json:"by,omitempty"`
+	Lease  ControlLease `json:"lease"`
+}
+
+// controlLease is the held lease (guarded by Robot.mu).
+type controlLease struct {
+	holder       Driver
+	since        time.Time
+	lastActivity time.Time
+	idle         *time.Timer
+}
+
+// SetControlLeaseOptions sets the holder's idle timeout
+// (DefaultControlLeaseIdle if not positive) and whether driving requires
+// the lease.
+func (r *Robot) SetControlLeaseOptions(idle time.Duration, required bool) {
+	if idle <= 0 {
+		idle = DefaultControlLeaseIdle
+	}
+	r.mu.Lock()
+	r.controlIdle, r.controlRequired = idle, required
+	r.mu.Unlock()
+}
+
+// ControlLease returns who may drive the robot.
+func (r *Robot) ControlLease() ControlLease {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.controlLeaseLocked(time.Now())
+}
+
+// controlLeaseLocked describes the lease, dropping a stale request.
+// Caller holds r.mu.
+func (r *Robot) controlLeaseLocked(now time.Time) ControlLease {
+	l := ControlLease{Required: r.controlRequired, IdleTimeoutSec: r.controlIdleLocked().Seconds()}
+	if c := r.control; c != nil {
+		holder, since, last := c.holder, c.since, c.lastActivity
+		expires := last.Add(r.controlIdleLocked())
+		l.Holder, l.Since, l.LastActivity, l.ExpiresAt = &holder, &since, &last, &expires
+	}
+	if q := r.controlRequest; q != nil {
+		if now.Sub(q.At) > controlRequestTimeout {
+			r.controlRequest = nil
+		} else {
+			req := *q
+			l.Pending = &req
+		}
+	}
+	return l
+}
+
+func (r *Robot) controlHolderLocked() *Driver {
+	if r.control == nil {
+		return nil
+	}
+	d := r.control.holder
+	return &d
+}
+
+func (r *Robot) controlIdleLocked() time.Duration {
+	if r.controlIdle <= 0 {
+		return DefaultControlLeaseIdle
+	}
+	return r.controlIdle
+}
+
+// RequestControl asks for the lease on behalf of d: granted at once when
+// nobody holds it, otherwise left pending for the holder to answer
+// (replacing an earlier request). Asking while holding it counts as
+// activity.
+func (r *Robot) RequestControl(d Driver) ControlLeaseEvent {
+	now := time.Now()
+	r.mu.Lock()
+	var ev ControlLeaseEvent
+	switch c := r.control; {
+	case c == nil:
+		r.grantControlLocked(d, now)
+		ev = ControlLeaseEvent{Event: ControlGranted, Driver: d}
+	case c.holder.ID == d.ID:
+		c.lastActivity = now
+		ev = ControlLeaseEvent{Event: ControlGranted, Driver: d, By: &d}
+	default:
+		r.controlRequest = &ControlRequest{Driver: d, At: now}
+		ev = ControlLeaseEvent{Event: ControlRequested, Driver: d}
+	}
+	ev.Lease = r.controlLeaseLocked(now)
+	r.mu.Unlock()
+
+	r.emitControlLease(ev)
+	return ev
+}
+
+// GrantControl hands the lease to the pending requester; clientID, if
+// set, must name it. by is the holder answering, nil for the API.
+func (r *Robot) GrantControl(by *Driver, clientID string) (ControlLeaseEvent, error) {
+	now := time.Now()
+	r.mu.Lock()
+	q, err := r.answerableRequestLocked(by, clientID, now)
+	if err != nil {
+		r.mu.Unlock()
+		return ControlLeaseEvent{}, err
+	}
+	r.controlRequest = nil
+	if c := r.control; c != nil {
+		c.idle.Stop()
+	}
+	r.grantControlLocked(q.Driver, now)
+	ev := ControlLeaseEvent{Event: ControlGranted, Driver: q.Driver, By: by, Lease: r.controlLeaseLocked(now)}
+	r.mu.Unlock()
+
+	r.emitControlLease(ev)
+	return ev, nil
+}
+
+// DenyControl drops the pending request; clientID, if set, must name
+// it. by is the holder answering, nil for the API.
+func (r *Robot) DenyControl(by *Driver, clientID string) (ControlLeaseEvent, error) {
+	now := time.Now()
+	r.mu.Lock()
+	q, err := r.answerableRequestLocked(by, clientID, now)
+	if err != nil {
+		r.mu.Unlock()
+		return ControlLeaseEvent{}, err
+	}
+	r.controlRequest = nil
+	ev := ControlLeaseEvent{Event: ControlDenied, Driver: q.Driver, By: by, Lease: r.controlLeaseLocked(now)}
+	r.mu.Unlock()
+
+	r.emitControlLease(ev)
+	return ev, nil
+}
+
+// answerableRequestLocked returns the request by may answer. Caller
+// holds r.mu.
+func (r *Robot) answerableRequestLocked(by *Driver, clientID string, now time.Time) (ControlRequest, error) {
+	if by != nil && (r.control == nil || r.control.holder.ID != by.ID) {
+		return ControlRequest{}, ErrNotController
+	}
+	r.controlLeaseLocked(now) // drops a stale request
+	q := r.controlRequest
+	if q == nil || (clientID != "" && q.Driver.ID != clientID) {
+		return ControlRequest{}, ErrNoControlRequest
+	}
+	return *q, nil
+}
+
+// ReleaseControl gives up the lease d holds.
+func (r *Robot) ReleaseControl(d Driver) error {
+	r.mu.Lock()
+	if r.control == nil || r.control.holder.ID != d.ID {
+		r.mu.Unlock()
+		return ErrNotController
+	}
+	ev := r.endControlLocked(ControlReasonReleased)
+	r.mu.Unlock()
+
+	r.emitControlLease(ev)
+	return nil
+}
+
+// RevokeControl ends the lease whoever holds it, and drops a pending
+// request; it reports whether there was a lease.
+func (r *Robot) RevokeControl() bool {
+	r.mu.Lock()
+	if r.control == nil {
+		r.mu.Unlock()
+		return false
+	}
+	r.controlRequest = nil
+	ev := r.endControlLocked(ControlReasonRevoked)
+	r.mu.Unlock()
+
+	r.emitControlLease(ev)
+	return true
+}
+
+// dropControlClient ends the lease and drops the request of a closed
+// connection.
+func (r *Robot) dropControlClient(clientID string) {
+	var events []ControlLeaseEvent
+	r.mu.Lock()
+	if q := r.controlRequest; q != nil && q.Driver.ID == clientID {
+		r.controlRequest = nil
+		events = append(events, ControlLeaseEvent{Event: ControlDenied, Reason: ControlReasonDisconnected,
+			Driver: q.Driver, Lease: r.controlLeaseLocked(time.Now())})
+	}
+	if c := r.control; c != nil && c.holder.ID == clientID {
+		events = append(events, r.endControlLocked(ControlReasonDisconnected))
+	}
+	r.mu.Unlock()
+
+	for _, ev := range events {
+		r.emitControlLease(ev)
+	}
+}
+
+// grantControlLocked makes d the holder. Caller holds r.mu.
+func (r *Robot) grantControlLocked(d Driver, now time.Time) {
+	c := &controlLease{holder: d, since: now, lastActivity: now}
+	c.idle = time.AfterFunc(r.controlIdleLocked(), func() { r.controlIdleExpired(c) })
+	r.control = c
+}
+
+// endControlLocked clears the lease and returns the event announcing it.
+// Caller holds r.mu.
+func (r *Robot) endControlLocked(reason string) ControlLeaseEvent {
+	c := r.control
+	c.idle.Stop()
+	r.control = nil
+	return ControlLeaseEvent{Event: ControlReleased, Reason: reason, Driver: c.holder,
+		Lease: r.controlLeaseLocked(time.Now())}
+}
+
+// controlIdleExpired ends lease c if its holder stayed inactive, or
+// waits for the rest of the timeout.
+func (r *Robot) controlIdleExpired(c *controlLease) {
+	r.mu.Lock()
+	if r.control != c {
+		r.mu.Unlock()
+		return
+	}
+	if left := r.controlIdleLocked() - time.Since(c.lastActivity); left > 0 {
+		c.idle.Reset(left)
+		r.mu.Unlock()
+		return
+	}
+	ev := r.endControlLocked(ControlReasonIdle)
+	r.mu.Unlock()
+
+	r.emitControlLease(ev)
+}
+
+// useControl checks that clientID may drive and counts the input as the
+// holder's activity; holder reports whether clientID holds the lease.
+func (r *Robot) useControl(clientID string) (holder bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch c := r.control; {
+	case c == nil && r.controlRequired:
+		return false, ErrControlRequired
+	case c == nil:
+		return false, nil
+	case c.holder.ID != clientID:
+		return false, ErrControlHeld
+	}
+	r.control.lastActivity = time.Now()
+	return true, nil
+}
+
+// CheckControl checks that the connection clientID (its hello client_id,
+// empty for none) may drive the robot, as for a relative move.
+func (r *Robot) CheckControl(clientID string) error {
+	_, err := r.useControl(clientID)
+	return err
+}
+
+func (r *Robot) emitControlLease(ev ControlLeaseEvent) {
+	if r.OnControlLease != nil {
+		r.OnControlLease(ev)
+	}
+}
+
+// ReleaseControlClient ends the leases and drops the requests of a
+// closed connection on every robot.
+func (m *Manager) ReleaseControlClient(clientID string) {
+	for _, r := range m.GetAllRobots() {
+		r.dropControlClient(clientID)
+	}
+}
@@ -0,0 +1,200 @@
+package robot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leaseLog records a robot's control lease events.
+type leaseLog struct {
+	mu     sync.Mutex
+	events []ControlLeaseEvent
+}
+
+func logLease(r *Robot) *leaseLog {
+	l := &leaseLog{}
+	r.OnControlLease = func(ev ControlLeaseEvent) {
+		l.mu.Lock()
+		l.events = append(l.events, ev)
+		l.mu.Unlock()
+	}
+	return l
+}
+
+// last returns the latest event.
+func (l *leaseLog) last() ControlLeaseEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) == 0 {
+		return ControlLeaseEvent{}
+	}
+	return l.events[len(l.events)-1]
+}
+
+var (
+	alice = Driver{ID: "ws-1", Label: "10.0.0.1"}
+	bob   = Driver{ID: "ws-2", Label: "10.0.0.2"}
+	carol = Driver{ID: "ws-3", Label: "10.0.0.3"}
+)
+
+// holder returns the lease holder's ID, or "".
+func holder(r *Robot) string {
+	if h := r.ControlLease().Holder; h != nil {
+		return h.ID
+	}
+	return ""
+}
+
+func TestControlLeaseGrantDeny(t *testing.T) {
+	r := NewRobot("1", "", "lease", "127.0.0.1", 9)
+	defer r.Close()
+	l := logLease(r)
+
+	// Free: anyone may drive, and the first request is granted at once
+	if err := r.CheckControl(bob.ID); err != nil {
+		t.Errorf("no lease: %v", err)
+	}
+	if ev := r.RequestControl(alice); ev.Event != ControlGranted || ev.By != nil || holder(r) != alice.ID {
+		t.Fatalf("first request: %+v", ev)
+	}
+	if err := r.CheckControl(bob.ID); !errors.Is(err, ErrControlHeld) {
+		t.Errorf("bob drives alice's lease: %v", err)
+	}
+	if err := r.CheckControl(alice.ID); err != nil {
+		t.Errorf("alice: %v", err)
+	}
+
+	// Bob asks; only alice or the API may answer, and only bob's request
+	if ev := r.RequestControl(bob); ev.Event != ControlRequested || ev.Lease.Pending == nil || ev.Lease.Pending.Driver != bob {
+		t.Fatalf("second request: %+v", ev)
+	}
+	if _, err := r.GrantControl(&bob, ""); !errors.Is(err, ErrNotController) {
+		t.Errorf("bob grants himself: %v", err)
+	}
+	if _, err := r.GrantControl(&alice, carol.ID); !errors.Is(err, ErrNoControlRequest) {
+		t.Errorf("granted carol's request: %v", err)
+	}
+	ev, err := r.DenyControl(&alice, bob.ID)
+	if err != nil || ev.Event != ControlDenied || ev.Driver != bob || *ev.By != alice || ev.Lease.Pending != nil || holder(r) != alice.ID {
+		t.Fatalf("deny: %+v, %v", ev, err)
+	}
+	if _, err := r.GrantControl(&alice, ""); !errors.Is(err, ErrNoControlRequest) {
+		t.Errorf("granted a denied request: %v", err)
+	}
+
+	// A later request replaces an earlier one; alice grants it
+	r.RequestControl(bob)
+	r.RequestControl(carol)
+	ev, err = r.GrantControl(&alice, "")
+	if err != nil || ev.Event != ControlGranted || ev.Driver != carol || holder(r) != carol.ID {
+		t.Fatalf("grant: %+v, %v", ev, err)
+	}
+	if err := r.ReleaseControl(alice); !errors.Is(err, ErrNotController) {
+		t.Errorf("former holder released: %v", err)
+	}
+
+	// The API answers in place of the holder
+	r.RequestControl(bob)
+	if ev, err := r.GrantControl(nil, bob.ID); err != nil || ev.By != nil || holder(r) != bob.ID {
+		t.Errorf("API grant: %+v, %v", ev, err)
+	}
+	r.RequestControl(alice)
+	if !r.RevokeControl() || holder(r) != "" || r.ControlLease().Pending != nil {
+		t.Errorf("revoke: %+v", r.ControlLease())
+	}
+	if ev := l.last(); ev.Event != ControlReleased || ev.Reason != ControlReasonRevoked || ev.Driver != bob {
+		t.Errorf("revoke event %+v", ev)
+	}
+	if r.RevokeControl() {
+		t.Error("revoked with nobody holding the lease")
+	}
+
+	// An unanswered request goes stale
+	r.RequestControl(alice)
+	r.RequestControl(bob)
+	r.mu.Lock()
+	r.controlRequest.At = time.Now().Add(-controlRequestTimeout - time.Second)
+	r.mu.Unlock()
+	if p := r.ControlLease().Pending; p != nil {
+		t.Errorf("stale request kept: %+v", p)
+	}
+}
+
+func TestControlLeaseRequired(t *testing.T) {
+	r := NewRobot("1", "", "lease", "127.0.0.1", 9)
+	defer r.Close()
+	r.SetControlLeaseOptions(0, true)
+	if err := r.CheckControl(alice.ID); !errors.Is(err, ErrControlRequired) {
+		t.Errorf("required: %v", err)
+	}
+	if err := r.Drive(alice, 0.2, 0, 0, false, false); !errors.Is(err, ErrControlRequired) {
+		t.Errorf("joystick without the lease: %v", err)
+	}
+	r.RequestControl(alice)
+	if err := r.Drive(bob, 0.2, 0, 0, false, true); !errors.Is(err, ErrControlHeld) {
+		t.Errorf("takeover past the lease: %v", err)
+	}
+	if l := r.ControlLease(); !l.Required || l.IdleTimeoutSec != DefaultControlLeaseIdle.Seconds() {
+		t.Errorf("lease %+v", l)
+	}
+}
+
+func TestControlLeaseIdle(t *testing.T) {
+	r := NewRobot("1", "", "lease", "127.0.0.1", 9)
+	defer r.Close()
+	l := logLease(r)
+	r.SetControlLeaseOptions(200*time.Millisecond, false)
+
+	// Input postpones the expiry
+	start := time.Now()
+	r.RequestControl(alice)
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if err := r.CheckControl(alice.ID); err != nil {
+			t.Fatalf("lost the lease while active: %v", err)
+		}
+	}
+	waitUntil(t, "the idle expiry", func() bool { return holder(r) == "" })
+	if d := time.Since(start); d < 600*time.Millisecond {
+		t.Errorf("expired after %v despite activity", d)
+	}
+	if ev := l.last(); ev.Event != ControlReleased || ev.Reason != ControlReasonIdle || ev.Driver != alice {
+		t.Errorf("expiry event %+v", ev)
+	}
+
+	// A lease released early doesn't expire later
+	r.RequestControl(bob)
+	if err := r.ReleaseControl(bob); err != nil {
+		t.Fatal(err)
+	}
+	r.RequestControl(alice)
+	time.Sleep(100 * time.Millisecond)
+	if holder(r) != alice.ID {
+		t.Error("the released lease's timer ended the new one")
+	}
+}
+
+func TestControlLeaseDisconnect(t *testing.T) {
+	m := NewManager()
+	r, _ := m.AddRobot("amr", "", "127.0.0.1", 9)
+	defer m.RemoveRobot(r.ID)
+	l := logLease(r)
+
+	r.RequestControl(alice)
+	r.RequestControl(bob)
+	m.ReleaseControlClient(bob.ID) // the requester leaves
+	if ev := l.last(); ev.Event != ControlDenied || ev.Reason != ControlReasonDisconnected || ev.Driver != bob || holder(r) != alice.ID {
+		t.Errorf("requester gone: %+v", ev)
+	}
+	m.ReleaseControlClient(alice.ID) // the holder leaves
+	if ev := l.last(); ev.Event != ControlReleased || ev.Reason != ControlReasonDisconnected || holder(r) != "" {
+		t.Errorf("holder gone: %+v", ev)
+	}
+	n := len(l.events)
+	m.ReleaseControlClient(carol.ID)
+	if len(l.events) != n {
+		t.Error("a bystander leaving changed the lease")
+	}
+}
@@ -0,0 +1,170 @@
+package robot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+// ──────────────────────────── Destructive actions
+//
+// Powering off, rebooting and clearing a point collection take two
+// requests: the first returns a confirmation token and broadcasts a
+// pending_destructive_action event with a countdown; the action runs only
+// when a second request brings the token back before it expires. Tokens
+// are per robot and action (a new request replaces the robot's pending
+// one for that action), single-use and kept in memory only. A cancel
+// voids the token. The event carries no token, so other browsers see the
+// countdown but can't confirm it.
+
+// DefaultDestructiveConfirm is how long a confirmation token stays valid.
+const DefaultDestructiveConfirm = 15 * time.Second
+
+// Destructive actions.
+const (
+	ActionPowerOff    = "poweroff"
+	ActionReboot      = "reboot"
+	ActionClearPoints = "clear_points" // Target is the point type
+)
+
+// Confirmation token errors.
+var (
+	ErrConfirmToken   = errors.New("unknown confirmation token")
+	ErrConfirmExpired = errors.New("confirmation token expired")
+)
+
+// PendingAction is a destructive action waiting for its confirmation.
+type PendingAction struct {
+	Token     string    `json:"token,omitempty"`
+	RobotID   string    `json:"robot_id"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	By        string    `json:"by,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// destructiveKey identifies the pending action a token belongs to.
+type destructiveKey struct {
+	robotID, action, target string
+}
+
+// destructiveEvent is the pending_destructive_action payload.
+type destructiveEvent struct {
+	PendingAction
+	State       string `json:"state"` // pending, confirmed, cancelled or expired
+	CountdownMs int64  `json:"countdown_ms"`
+}
+
+func newConfirmToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// destructiveWindow is the token lifetime in force.
+func (m *Manager) destructiveWindow() time.Duration {
+	if m.DestructiveConfirm > 0 {
+		return m.DestructiveConfirm
+	}
+	return DefaultDestructiveConfirm
+}
+
+// RequestDestructive issues a token for action on robot robotID (target
+// narrows it, e.g. a point type), voiding the previous one, and broadcasts
+// the countdown. by records the requester.
+func (m *Manager) RequestDestructive(robotID, action, target, by string) PendingAction {
+	return m.requestDestructive(robotID, action, target, by, time.Now())
+}
+
+func (m *Manager) requestDestructive(robotID, action, target, by string, now time.Time) PendingAction {
+	key := destructiveKey{robotID, action, target}
+	p := PendingAction{
+		Token: newConfirmToken(), RobotID: robotID, Action: action, Target: target, By: by,
+		ExpiresAt: now.Add(m.destructiveWindow()),
+	}
+	m.destructiveMu.Lock()
+	if m.destructive == nil {
+		m.destructive = make(map[destructiveKey]PendingAction)
+	}
+	m.destructive[key] = p
+	m.destructiveMu.Unlock()
+
+	m.broadcastDestructive(p, "pending", p.ExpiresAt.Sub(now))
+	time.AfterFunc(p.ExpiresAt.Sub(now), func() { m.expireDestructive(key, p.Token) })
+	return p
+}
+
+// expireDestructive drops the token of key if it is still token, and
+// tells the browsers.
+func (m *Manager) expireDestructive(key destructiveKey, token string) {
+	m.destructiveMu.Lock()
+	p, ok := m.destructive[key]
+	if ok && p.Token == token {
+		delete(m.destructive, key)
+	}
+	m.destructiveMu.Unlock()
+	if ok && p.Token == token {
+		m.broadcastDestructive(p, "expired", 0)
+	}
+}
+
+// ConfirmDestructive consumes token for action on robot robotID. It fails
+// with ErrConfirmToken when the token wasn't issued for this robot and
+// action (or was already used or cancelled) and ErrConfirmExpired when
+// it ran out; either way the action must not run.
+func (m *Manager) ConfirmDestructive(robotID, action, target, token string) (PendingAction, error) {
+	return m.confirmDestructive(robotID, action, target, token, time.Now())
+}
+
+func (m *Manager) confirmDestructive(robotID, action, target, token string, now time.Time) (PendingAction, error) {
+	key := destructiveKey{robotID, action, target}
+	m.destructiveMu.Lock()
+	p, ok := m.destructive[key]
+	if !ok || token == "" || p.Token != token {
+		m.destructiveMu.Unlock()
+		return PendingAction{}, ErrConfirmToken
+	}
+	delete(m.destructive, key)
+	m.destructiveMu.Unlock()
+
+	if !now.Before(p.ExpiresAt) {
+		return PendingAction{}, ErrConfirmExpired
+	}
+	log.Printf("[destructive] %s on robot %s confirmed (requested by %s)", actionName(p), robotID, p.By)
+	m.broadcastDestructive(p, "confirmed", 0)
+	return p, nil
+}
+
+// CancelDestructive voids token, pending on robot robotID.
+func (m *Manager) CancelDestructive(robotID, token string) (PendingAction, error) {
+	m.destructiveMu.Lock()
+	var found PendingAction
+	for key, p := range m.destructive {
+		if key.robotID == robotID && token != "" && p.Token == token {
+			found = p
+			delete(m.destructive, key)
+			break
+		}
+	}
+	m.destructiveMu.Unlock()
+	if found.Token == "" {
+		return PendingAction{}, ErrConfirmToken
+	}
+	m.broadcastDestructive(found, "cancelled", 0)
+	return found, nil
+}
+
+func (m *Manager) broadcastDestructive(p PendingAction, state string, left time.Duration) {
+	p.Token = ""
+	m.BroadcastMust(BroadcastMsg{Type: "pending_destructive_action", RobotID: p.RobotID,
+		Data: destructiveEvent{PendingAction: p, State: state, CountdownMs: left.Milliseconds()}})
+}
+
+func actionName(p PendingAction) string {
+	if p.Target != "" {
+		return p.Action + " " + p.Target
+	}
+	return p.Action
+}
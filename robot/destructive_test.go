@@ -0,0 +1,159 @@
+package robot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// destructiveEvents collects the manager's pending_destructive_action
+// broadcasts.
+func destructiveEvents(t *testing.T, m *Manager) <-chan destructiveEvent {
+	ch := m.Subscribe()
+	out := make(chan destructiveEvent, 100)
+	go func() {
+		for msg := range ch {
+			if msg.Type == "pending_destructive_action" {
+				out <- msg.Data.(destructiveEvent)
+			}
+		}
+	}()
+	t.Cleanup(func() { m.Unsubscribe(ch) })
+	return out
+}
+
+func nextEvent(t *testing.T, events <-chan destructiveEvent) destructiveEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no pending_destructive_action broadcast")
+		return destructiveEvent{}
+	}
+}
+
+func TestDestructiveConfirm(t *testing.T) {
+	m := NewManager()
+	events := destructiveEvents(t, m)
+	now := time.Now()
+
+	p := m.requestDestructive("1", ActionPowerOff, "", "10.0.0.5", now)
+	if p.Token == "" || !p.ExpiresAt.Equal(now.Add(DefaultDestructiveConfirm)) {
+		t.Fatalf("pending = %+v", p)
+	}
+	ev := nextEvent(t, events)
+	if ev.State != "pending" || ev.Token != "" || ev.CountdownMs != DefaultDestructiveConfirm.Milliseconds() {
+		t.Errorf("pending event = %+v, want no token and the full countdown", ev)
+	}
+
+	got, err := m.confirmDestructive("1", ActionPowerOff, "", p.Token, now.Add(time.Second))
+	if err != nil || got.By != "10.0.0.5" {
+		t.Fatalf("confirm = %+v, %v", got, err)
+	}
+	if ev := nextEvent(t, events); ev.State != "confirmed" || ev.Token != "" {
+		t.Errorf("confirm event = %+v", ev)
+	}
+
+	// Single use
+	if _, err := m.confirmDestructive("1", ActionPowerOff, "", p.Token, now.Add(time.Second)); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("reused token: %v, want ErrConfirmToken", err)
+	}
+	if _, err := m.confirmDestructive("1", ActionPowerOff, "", "", now); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("empty token: %v, want ErrConfirmToken", err)
+	}
+}
+
+func TestDestructiveExpiry(t *testing.T) {
+	m := NewManager()
+	m.DestructiveConfirm = 5 * time.Second
+	now := time.Now()
+
+	p := m.requestDestructive("1", ActionReboot, "", "", now)
+	if !p.ExpiresAt.Equal(now.Add(5 * time.Second)) {
+		t.Errorf("expires at %v, want the configured window", p.ExpiresAt)
+	}
+	if _, err := m.confirmDestructive("1", ActionReboot, "", p.Token, now.Add(5*time.Second)); !errors.Is(err, ErrConfirmExpired) {
+		t.Errorf("confirm at expiry: %v, want ErrConfirmExpired", err)
+	}
+	// An expired token is gone, not waiting for a retry
+	if _, err := m.confirmDestructive("1", ActionReboot, "", p.Token, now); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("confirm after expiry: %v, want ErrConfirmToken", err)
+	}
+
+	// The timer drops the token and tells the browsers
+	m.DestructiveConfirm = 20 * time.Millisecond
+	events := destructiveEvents(t, m)
+	p = m.RequestDestructive("1", ActionReboot, "", "")
+	if ev := nextEvent(t, events); ev.State != "pending" {
+		t.Fatalf("event = %+v", ev)
+	}
+	if ev := nextEvent(t, events); ev.State != "expired" || ev.Action != ActionReboot || ev.CountdownMs != 0 {
+		t.Errorf("event = %+v, want expired", ev)
+	}
+	if _, err := m.ConfirmDestructive("1", ActionReboot, "", p.Token); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("confirm after the timer: %v, want ErrConfirmToken", err)
+	}
+}
+
+func TestDestructiveTokenScope(t *testing.T) {
+	m := NewManager()
+	now := time.Now()
+	p := m.requestDestructive("1", ActionClearPoints, "waypoint", "", now)
+
+	wrong := []struct {
+		name                    string
+		robotID, action, target string
+	}{
+		{"other robot", "2", ActionClearPoints, "waypoint"},
+		{"other action", "1", ActionPowerOff, ""},
+		{"other point type", "1", ActionClearPoints, "wall"},
+	}
+	for _, w := range wrong {
+		if _, err := m.confirmDestructive(w.robotID, w.action, w.target, p.Token, now); !errors.Is(err, ErrConfirmToken) {
+			t.Errorf("%s: %v, want ErrConfirmToken", w.name, err)
+		}
+	}
+	// Refusing a wrong use doesn't burn the token for the right one
+	if _, err := m.confirmDestructive("1", ActionClearPoints, "waypoint", p.Token, now); err != nil {
+		t.Errorf("right robot after wrong ones: %v", err)
+	}
+
+	// A new request replaces the pending token, per robot and action
+	first := m.requestDestructive("1", ActionPowerOff, "", "", now)
+	other := m.requestDestructive("2", ActionPowerOff, "", "", now)
+	second := m.requestDestructive("1", ActionPowerOff, "", "", now)
+	if _, err := m.confirmDestructive("1", ActionPowerOff, "", first.Token, now); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("replaced token: %v, want ErrConfirmToken", err)
+	}
+	if _, err := m.confirmDestructive("1", ActionPowerOff, "", second.Token, now); err != nil {
+		t.Errorf("replacing token: %v", err)
+	}
+	if _, err := m.confirmDestructive("2", ActionPowerOff, "", other.Token, now); err != nil {
+		t.Errorf("other robot's token: %v", err)
+	}
+}
+
+func TestDestructiveCancel(t *testing.T) {
+	m := NewManager()
+	events := destructiveEvents(t, m)
+	p := m.RequestDestructive("1", ActionPowerOff, "", "")
+	nextEvent(t, events)
+
+	if _, err := m.CancelDestructive("2", p.Token); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("cancel on another robot: %v", err)
+	}
+	got, err := m.CancelDestructive("1", p.Token)
+	if err != nil || got.Action != ActionPowerOff {
+		t.Fatalf("cancel = %+v, %v", got, err)
+	}
+	if ev := nextEvent(t, events); ev.State != "cancelled" {
+		t.Errorf("event = %+v, want cancelled", ev)
+	}
+	if _, err := m.ConfirmDestructive("1", ActionPowerOff, "", p.Token); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("confirm after cancel: %v", err)
+	}
+	if _, err := m.CancelDestructive("1", p.Token); !errors.Is(err, ErrConfirmToken) {
+		t.Errorf("second cancel: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ──────────────────────────── Extra topics
+//
+// A new sensor (an ultrasonic array, an IMU, a door switch) needn't mean
+// new client code: each robot lists extra topics in its settings, and
+// each is subscribed raw (see rosbridge/raw_topics.go) and passed to the
+// browser unparsed as "custom:<alias>" frames. A topic with a throttle
+// gets at most one frame per interval; the latest message skipped goes
+// out when the interval ends, so a value that stops changing is still
+// seen. The latest message of each alias is kept for snapshots (and so
+// request_status). A payload over MaxExtraTopicBytes isn't passed on:
+// its value records the size instead. Changing the list subscribes and
+// unsubscribes at once; the subscriptions follow reconnects like any
+// raw one.
+
+// Extra topic limits.
+const (
+	MaxExtraTopics     = 16
+	MaxExtraTopicBytes = 64 << 10
+	MaxExtraThrottleMs = 60000
+)
+
+// ExtraTopicPrefix starts the broadcast type of an extra topic's frames.
+const ExtraTopicPrefix = "custom:"
+
+var extraAliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ExtraTopic is a topic passed through to the browser.
+type ExtraTopic struct {
+	Topic      string `json:"topic"`          // without namespace
+	Type       string `json:"type,omitempty"` // empty: rosbridge tells
+	ThrottleMs int    `json:"throttle_ms,omitempty"`
+	Alias      string `json:"alias"` // frames are custom:<alias>
+}
+
+// ExtraTopics is a robot's list of extra topics.
+type ExtraTopics []ExtraTopic
+
+// ExtraTopicValue is the latest message of an extra topic.
+type ExtraTopicValue struct {
+	Alias    string          `json:"alias"`
+	Topic    string          `json:"topic"`
+	Msg      json.RawMessage `json:"msg,omitempty"` // absent when oversize
+	Bytes    int             `json:"bytes"`
+	Oversize bool            `json:"oversize,omitempty"`
+	Received time.Time       `json:"received"`
+	// Dropped counts messages throttled away since the previous frame.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// extraSub is a running extra topic subscription, guarded by r.mu.
+type extraSub struct {
+	topic    ExtraTopic
+	stop     func()
+	lastSent time.Time
+	timer    *time.Timer // sends the latest skipped message
+	skipped  int
+}
+
+// ParseExtraTopics decodes and validates a JSON list of extra topics.
+// "[]" yields nil, which removes them all.
+func ParseExtraTopics(s string) (ExtraTopics, error) {
+	var ts ExtraTopics
+	if err := json.Unmarshal([]byte(s), &ts); err != nil {
+		return nil, fmt.Errorf("extra topics must be a JSON array of {topic, type, throttle_ms, alias}: %w", err)
+	}
+	if len(ts) == 0 {
+		return nil, nil
+	}
+	if err := ts.Validate(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// Validate checks topic names, aliases (unique, [A-Za-z0-9_-], up to 32
+// characters), throttles and the count.
+func (ts ExtraTopics) Validate() error {
+	if len(ts) > MaxExtraTopics {
+		return fmt.Errorf("at most %d extra topics, got %d", MaxExtraTopics, len(ts))
+	}
+	seen := make(map[string]bool, len(ts))
+	for i, t := range ts {
+		if !strings.HasPrefix(t.Topic, "/") || strings.ContainsAny(t.Topic, " \t\n") {
+			return fmt.Errorf("extra topic %d: topic %q must be a ROS topic name starting with /", i, t.Topic)
+		}
+		if !extraAliasPattern.MatchString(t.Alias) {
+			return fmt.Errorf("extra topic %d: alias %q must be 1-32 letters, digits, _ or -", i, t.Alias)
+		}
+		if seen[t.Alias] {
+			return fmt.Errorf("extra topic %d: alias %q is used twice", i, t.Alias)
+		}
+		seen[t.Alias] = true
+		if t.ThrottleMs < 0 || t.ThrottleMs > MaxExtraThrottleMs {
+			return fmt.Errorf("extra topic %d: throttle_ms must be 0..%d", i, MaxExtraThrottleMs)
+		}
+	}
+	return nil
+}
+
+// String returns ts as JSON, empty for none.
+func (ts ExtraTopics) String() string {
+	if len(ts) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(ts)
+	return string(b)
+}
+
+// ExtraTopics returns the robot's extra topics.
+func (r *Robot) ExtraTopics() ExtraTopics {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.extraTopics)
+}
+
+// SetExtraTopics replaces the robot's extra topics: topics no longer
+// listed, or listed differently, are unsubscribed and their values
+// dropped; new ones are subscribed.
+func (r *Robot) SetExtraTopics(ts ExtraTopics) error {
+	if err := ts.Validate(); err != nil {
+		return err
+	}
+	ts = slices.Clone(ts)
+
+	// Subscribing sends on the connection, so it happens outside r.mu;
+	// extraMu keeps concurrent changes in order.
+	r.extraMu.Lock()
+	defer r.extraMu.Unlock()
+
+	r.mu.Lock()
+	keep := make(map[string]bool, len(ts))
+	for _, t := range ts {
+		if s := r.extraSubs[t.Alias]; s != nil && s.topic == t {
+			keep[t.Alias] = true
+		}
+	}
+	var stops []func()
+	for alias, s := range r.extraSubs {
+		if keep[alias] {
+			continue
+		}
+		stops = append(stops, r.dropExtraLocked(alias, s))
+	}
+	r.extraTopics = ts
+	r.mu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+	for _, t := range ts {
+		if !keep[t.Alias] {
+			r.subscribeExtra(t)
+		}
+	}
+	return nil
+}
+
+// subscribeExtra starts the subscription of t.
+func (r *Robot) subscribeExtra(t ExtraTopic) {
+	s := &extraSub{topic: t}
+	r.mu.Lock()
+	if r.extraSubs == nil {
+		r.extraSubs = make(map[string]*extraSub)
+	}
+	r.extraSubs[t.Alias] = s
+	r.mu.Unlock()
+	stop := r.Client.SubscribeRaw(t.Topic, t.Type, func(msg json.RawMessage) { r.receiveExtra(s, msg) })
+	r.mu.Lock()
+	s.stop = stop
+	r.mu.Unlock()
+}
+
+// dropExtraLocked forgets the subscription of alias and returns the
+// function ending it. Caller holds r.mu for writing.
+func (r *Robot) dropExtraLocked(alias string, s *extraSub) (stop func()) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	delete(r.extraSubs, alias)
+	delete(r.extraValues, alias)
+	if s.stop == nil {
+		return func() {}
+	}
+	return s.stop
+}
+
+// stopExtraTopics ends every extra topic subscription; the robot is
+// going away.
+func (r *Robot) stopExtraTopics() {
+	r.extraMu.Lock()
+	defer r.extraMu.Unlock()
+	r.mu.Lock()
+	var stops []func()
+	for alias, s := range r.extraSubs {
+		stops = append(stops, r.dropExtraLocked(alias, s))
+	}
+	r.mu.Unlock()
+	for _, stop := range stops {
+		stop()
+	}
+}
+
+// receiveExtra runs on the robot's message handling: keep msg as the
+// latest value of s and send it, or leave it for the end of the
+// throttle interval.
+func (r *Robot) receiveExtra(s *extraSub, msg json.RawMessage) {
+	v := ExtraTopicValue{Alias: s.topic.Alias, Topic: s.topic.Topic, Bytes: len(msg), Received: time.Now()}
+	if len(msg) > MaxExtraTopicBytes {
+		v.Oversize = true
+	} else {
+		v.Msg = msg
+	}
+
+	r.mu.Lock()
+	if r.extraSubs[v.Alias] != s {
+		r.mu.Unlock()
+		return // removed meanwhile
+	}
+	if r.extraValues == nil {
+		r.extraValues = make(map[string]ExtraTopicValue)
+	}
+	r.extraValues[v.Alias] = v
+	if wait := time.Duration(s.topic.ThrottleMs)*time.Millisecond - v.Received.Sub(s.lastSent); wait > 0 {
+		s.skipped++
+		if s.timer == nil {
+			s.timer = time.AfterFunc(wait, func() { r.flushExtra(s) })
+		}
+		r.mu.Unlock()
+		return
+	}
+	s.lastSent = v.Received
+	r.mu.Unlock()
+	r.emitExtra(v)
+}
+
+// flushExtra sends the latest message of s skipped by the throttle.
+func (r *Robot) flushExtra(s *extraSub) {
+	r.mu.Lock()
+	s.timer = nil
+	v, ok := r.extraValues[s.topic.Alias]
+	if r.extraSubs[s.topic.Alias] != s || s.skipped == 0 || !ok {
+		r.mu.Unlock()
+		return
+	}
+	v.Dropped = s.skipped - 1 // the one sent now was skipped too
+	s.skipped = 0
+	s.lastSent = time.Now()
+	r.mu.Unlock()
+	r.emitExtra(v)
+}
+
+func (r *Robot) emitExtra(v ExtraTopicValue) {
+	if r.OnExtraTopic != nil {
+		r.OnExtraTopic(v)
+	}
+}
+
+// extraValuesLocked returns a copy of the latest extra topic values.
+// Caller holds r.mu.
+func (r *Robot) extraValuesLocked() map[string]ExtraTopicValue {
+	out := make(map[string]ExtraTopicValue, len(r.extraValues))
+	for alias, v := range r.extraValues {
+		out[alias] = v
+	}
+	return out
+}
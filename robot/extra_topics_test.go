@@ -0,0 +1,158 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExtraTopics(t *testing.T) {
+	if ts, err := ParseExtraTopics(`[]`); ts != nil || err != nil {
+		t.Errorf("empty list: %v, %v", ts, err)
+	}
+	ts, err := ParseExtraTopics(`[{"topic":"/sonar","alias":"sonar"},{"topic":"/imu","type":"sensor_msgs/Imu","throttle_ms":200,"alias":"imu-1"}]`)
+	if err != nil || len(ts) != 2 || ts[1].ThrottleMs != 200 {
+		t.Fatalf("%+v, %v", ts, err)
+	}
+	if again, _ := ParseExtraTopics(ts.String()); len(again) != 2 || again[1] != ts[1] {
+		t.Errorf("round trip %+v", again)
+	}
+
+	many := make([]string, MaxExtraTopics+1)
+	for i := range many {
+		many[i] = fmt.Sprintf(`{"topic":"/t%d","alias":"t%d"}`, i, i)
+	}
+	for _, bad := range []string{
+		`{"topic":"/sonar"}`,
+		`[{"topic":"sonar","alias":"sonar"}]`,
+		`[{"topic":"/so nar","alias":"sonar"}]`,
+		`[{"topic":"/sonar","alias":"so nar"}]`,
+		`[{"topic":"/sonar","alias":""}]`,
+		`[{"topic":"/a","alias":"x"},{"topic":"/b","alias":"x"}]`,
+		`[{"topic":"/sonar","alias":"sonar","throttle_ms":60001}]`,
+		`[{"topic":"/sonar","alias":"sonar","throttle_ms":-1}]`,
+		"[" + strings.Join(many, ",") + "]",
+	} {
+		if _, err := ParseExtraTopics(bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+// extraFrames collects the manager's custom: broadcasts.
+func extraFrames(t *testing.T, m *Manager) chan ExtraTopicValue {
+	ch := m.Subscribe()
+	out := make(chan ExtraTopicValue, 100)
+	go func() {
+		for msg := range ch {
+			if strings.HasPrefix(msg.Type, ExtraTopicPrefix) {
+				v := msg.Data.(ExtraTopicValue)
+				if msg.Type != ExtraTopicPrefix+v.Alias {
+					t.Errorf("frame %s carries %s", msg.Type, v.Alias)
+				}
+				out <- v
+			}
+		}
+	}()
+	t.Cleanup(func() { m.Unsubscribe(ch) })
+	return out
+}
+
+// nextExtra returns the next extra topic frame.
+func nextExtra(t *testing.T, frames chan ExtraTopicValue) ExtraTopicValue {
+	t.Helper()
+	select {
+	case v := <-frames:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("no extra topic frame")
+		return ExtraTopicValue{}
+	}
+}
+
+// TestExtraTopics passes two synthetic topics from a fake rosbridge
+// server through to the manager's broadcasts.
+func TestExtraTopics(t *testing.T) {
+	stub := newRosbridgeStub(t)
+	host, port := stub.addr(t)
+	m := NewManager()
+	r, _ := m.AddRobot("", "amr", host, port)
+	defer m.RemoveRobot(r.ID)
+	if err := r.Client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	frames := extraFrames(t, m)
+
+	err := r.SetExtraTopics(ExtraTopics{
+		{Topic: "/sonar", Alias: "sonar"},
+		{Topic: "/imu", Type: "sensor_msgs/Imu", ThrottleMs: 200, Alias: "imu"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the subscriptions", func() bool { return stub.subscribed("/sonar") && stub.subscribed("/imu") })
+
+	// Unthrottled: every message, as it came
+	for i := 0; i < 3; i++ {
+		stub.publish("/sonar", fmt.Sprintf(`{"range":%d}`, i))
+		if v := nextExtra(t, frames); v.Alias != "sonar" || string(v.Msg) != fmt.Sprintf(`{"range":%d}`, i) || v.Bytes != len(v.Msg) {
+			t.Errorf("sonar frame %d: %+v", i, v)
+		}
+	}
+
+	// Throttled: the first of a burst at once, the last at the end of the
+	// interval with the others counted as dropped
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		stub.publish("/imu", fmt.Sprintf(`{"seq":%d}`, i))
+	}
+	if v := nextExtra(t, frames); string(v.Msg) != `{"seq":0}` || v.Dropped != 0 {
+		t.Errorf("first of the burst: %+v", v)
+	}
+	v := nextExtra(t, frames)
+	if string(v.Msg) != `{"seq":4}` || v.Dropped != 3 {
+		t.Errorf("end of the interval: %s, dropped %d", v.Msg, v.Dropped)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("throttled frame after %v", d)
+	}
+
+	// Oversize: the size only
+	stub.publish("/sonar", `{"data":"`+strings.Repeat("x", MaxExtraTopicBytes)+`"}`)
+	if v := nextExtra(t, frames); !v.Oversize || v.Msg != nil || v.Bytes <= MaxExtraTopicBytes {
+		t.Errorf("oversize frame: oversize %v, %d bytes", v.Oversize, v.Bytes)
+	}
+
+	snap := r.GetSnapshot()
+	if len(snap.ExtraTopics) != 2 || !snap.ExtraValues["sonar"].Oversize || string(snap.ExtraValues["imu"].Msg) != `{"seq":4}` {
+		t.Errorf("snapshot %+v, %+v", snap.ExtraTopics, snap.ExtraValues)
+	}
+	if p := r.ExportProfile(); len(p.Settings.ExtraTopics) != 2 {
+		t.Errorf("profile %+v", p.Settings.ExtraTopics)
+	}
+
+	// Removing a topic unsubscribes it and drops its value
+	r.SetExtraTopics(ExtraTopics{{Topic: "/sonar", Alias: "sonar"}})
+	waitUntil(t, "the unsubscribe", func() bool { return !stub.subscribed("/imu") })
+	if _, ok := r.GetSnapshot().ExtraValues["imu"]; ok || !stub.subscribed("/sonar") {
+		t.Error("removed topic kept")
+	}
+	stub.publish("/imu", `{"seq":5}`)
+	stub.publish("/sonar", `{"range":9}`)
+	if v := nextExtra(t, frames); v.Alias != "sonar" {
+		t.Errorf("removed topic passed on: %+v", v)
+	}
+	if err := r.SetExtraTopics(ExtraTopics{{Topic: "/a", Alias: "x"}, {Topic: "/b", Alias: "x"}}); err == nil || len(r.ExtraTopics()) != 1 {
+		t.Errorf("invalid list: %v, %+v", err, r.ExtraTopics())
+	}
+}
+
+// TestExtraTopicValueJSON checks an oversize value has no msg field.
+func TestExtraTopicValueJSON(t *testing.T) {
+	b, _ := json.Marshal(ExtraTopicValue{Alias: "a", Bytes: MaxExtraTopicBytes + 1, Oversize: true})
+	if strings.Contains(string(b), `"msg"`) {
+		t.Errorf("oversize value %s", b)
+	}
+}
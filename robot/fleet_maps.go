@@ -0,0 +1,148 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ──────────────────────────── Fleet map lists
+//
+// With maps named alike across robots (floor1_v3 everywhere), the fleet
+// is on the same version when every robot lists it. RefreshFleetMaps
+// asks every connected robot for its maps, FleetMapRefreshConcurrency at
+// a time and each within FleetMapRefreshTimeout, and broadcasts each
+// robot's result as "map_list_changed" when it arrives, so a slow robot
+// doesn't hold up the others. A robot that fails keeps its cached list
+// and reports the error; the refresh as a whole doesn't fail. FleetMaps
+// builds the same matrix from the cached lists without asking anyone.
+
+// Fleet map refresh limits.
+const (
+	FleetMapRefreshConcurrency = 4
+	FleetMapRefreshTimeout     = 5 * time.Second
+)
+
+// MapListStatus is a robot's cached map list and how its last refresh
+// went.
+type MapListStatus struct {
+	RobotID     string     `json:"robot_id"`
+	Name        string     `json:"name"`
+	Connected   bool       `json:"connected"`
+	Maps        []string   `json:"maps"`
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"` // last refresh attempt
+	Error       string     `json:"error,omitempty"`        // of the last refresh
+}
+
+// FleetMapRow is one robot of the fleet map matrix.
+type FleetMapRow struct {
+	MapListStatus
+	// Has tells, for each of FleetMaps.Maps in order, whether the robot
+	// lists it.
+	Has []bool `json:"has"`
+}
+
+// FleetMaps is the robot × map presence matrix.
+type FleetMaps struct {
+	Maps     []string      `json:"maps"` // every map listed by a robot, sorted
+	Robots   []FleetMapRow `json:"robots"`
+	Expected string        `json:"expected,omitempty"`
+	// Missing are the robots whose list lacks Expected, by ID.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// MapListStatus returns the robot's cached map list and last refresh.
+func (r *Robot) MapListStatus() MapListStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	st := MapListStatus{
+		RobotID:   r.ID,
+		Name:      r.Name,
+		Connected: r.connected,
+		Maps:      append([]string{}, r.MapList...),
+		Error:     r.mapListErr,
+	}
+	if !r.mapListAt.IsZero() {
+		at := r.mapListAt
+		st.RefreshedAt = &at
+	}
+	return st
+}
+
+// FleetMaps returns the matrix of the cached map lists; expected, when
+// set, is the map every robot should have.
+func (m *Manager) FleetMaps(expected string) FleetMaps {
+	robots := m.GetAllRobots()
+	sort.Slice(robots, func(i, j int) bool { return robots[i].ID < robots[j].ID })
+	statuses := make([]MapListStatus, len(robots))
+	for i, r := range robots {
+		statuses[i] = r.MapListStatus()
+	}
+	return fleetMapMatrix(statuses, expected)
+}
+
+// RefreshFleetMaps refreshes the map list of every connected robot and
+// returns the resulting matrix. Disconnected robots keep their cached
+// lists. It returns early, with what is known, when ctx ends.
+func (m *Manager) RefreshFleetMaps(ctx context.Context, expected string) FleetMaps {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, FleetMapRefreshConcurrency)
+	)
+refresh:
+	for _, r := range m.GetAllRobots() {
+		if !r.IsConnected() {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			break refresh
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(r *Robot) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, err := r.RefreshMapListTimeout(FleetMapRefreshTimeout)
+			if errors.Is(err, ErrNotConnected) {
+				return // dropped meanwhile; nothing was asked
+			}
+			m.Broadcast(BroadcastMsg{Type: "map_list_changed", RobotID: r.ID, Data: r.MapListStatus()})
+		}(r)
+	}
+	wg.Wait()
+	return m.FleetMaps(expected)
+}
+
+// fleetMapMatrix builds the matrix of statuses, in their order.
+func fleetMapMatrix(statuses []MapListStatus, expected string) FleetMaps {
+	seen := map[string]bool{}
+	fm := FleetMaps{Maps: []string{}, Robots: make([]FleetMapRow, len(statuses)), Expected: expected}
+	for _, st := range statuses {
+		for _, name := range st.Maps {
+			if !seen[name] {
+				seen[name] = true
+				fm.Maps = append(fm.Maps, name)
+			}
+		}
+	}
+	sort.Strings(fm.Maps)
+
+	for i, st := range statuses {
+		lists := make(map[string]bool, len(st.Maps))
+		for _, name := range st.Maps {
+			lists[name] = true
+		}
+		row := FleetMapRow{MapListStatus: st, Has: make([]bool, len(fm.Maps))}
+		for j, name := range fm.Maps {
+			row.Has[j] = lists[name]
+		}
+		fm.Robots[i] = row
+		if expected != "" && !lists[expected] {
+			fm.Missing = append(fm.Missing, st.RobotID)
+		}
+	}
+	return fm
+}
@@ -0,0 +1,248 @@
+package robot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// ──────────────────────────── Fleet proximity
+//
+// Robots sharing a map can't see each other. The fleet monitor compares
+// the map-frame poses of every pair of connected robots on the same
+// current map: closer than the sum of their extents (radius, or the
+// footprint's furthest vertex) is critical, closer than that plus the
+// margin is a warning. A state is left only once the distance exceeds
+// its threshold by the hysteresis, so a pair hovering at the boundary
+// doesn't flap. Each change is broadcast as "fleet_proximity"; with
+// auto-stop on, entering critical zeroes the velocity of both robots if
+// they are being driven by joystick or running a move, and no
+// relocalization rotation starts on a critical robot (InCriticalProximity).
+
+// Proximity states.
+const (
+	ProximityClear    = "clear"
+	ProximityWarning  = "warning"
+	ProximityCritical = "critical"
+)
+
+// FleetMonitorInterval is how often robot pairs are compared.
+const FleetMonitorInterval = 500 * time.Millisecond
+
+// fleetPoseMaxAge is the oldest pose the monitor trusts.
+const fleetPoseMaxAge = 2 * time.Second
+
+// ProximityOptions tune the fleet monitor.
+type ProximityOptions struct {
+	MarginM     float64 `json:"margin_m"`     // warning distance beyond the extents
+	HysteresisM float64 `json:"hysteresis_m"` // extra distance to leave a state
+	AutoStop    bool    `json:"auto_stop"`    // zero teleop velocity on critical
+}
+
+// DefaultProximityOptions warn within 0.5 m and don't stop robots.
+var DefaultProximityOptions = ProximityOptions{MarginM: 0.5, HysteresisM: 0.2}
+
+// Validate checks that the distances are finite and not negative.
+func (o ProximityOptions) Validate() error {
+	for _, v := range []float64{o.MarginM, o.HysteresisM} {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("proximity margin and hysteresis must be non-negative")
+		}
+	}
+	return nil
+}
+
+// ProximityPair is the distance between two robots on the same map.
+type ProximityPair struct {
+	RobotA    string    `json:"robot_a"`
+	RobotB    string    `json:"robot_b"`
+	Map       string    `json:"map"`
+	DistanceM float64   `json:"distance_m"` // centre to centre
+	CriticalM float64   `json:"critical_m"` // sum of extents
+	WarningM  float64   `json:"warning_m"`  // critical_m + margin
+	State     string    `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProximityReport is the monitor's options and current pairs.
+type ProximityReport struct {
+	Options ProximityOptions `json:"options"`
+	Pairs   []ProximityPair  `json:"pairs"`
+}
+
+// fleetProximity is the monitor state (guarded by Manager.proximityMu).
+type fleetProximity struct {
+	opts  ProximityOptions
+	pairs map[[2]string]ProximityPair
+}
+
+// SetProximityOptions replaces the monitor options.
+func (m *Manager) SetProximityOptions(o ProximityOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	m.proximityMu.Lock()
+	m.proximity.opts = o
+	m.proximityMu.Unlock()
+	return nil
+}
+
+// FleetProximity returns the options and the pairs of the last check,
+// sorted by distance.
+func (m *Manager) FleetProximity() ProximityReport {
+	m.proximityMu.Lock()
+	defer m.proximityMu.Unlock()
+	rep := ProximityReport{Options: m.proximity.opts, Pairs: []ProximityPair{}}
+	for _, p := range m.proximity.pairs {
+		rep.Pairs = append(rep.Pairs, p)
+	}
+	sort.Slice(rep.Pairs, func(i, j int) bool { return rep.Pairs[i].DistanceM < rep.Pairs[j].DistanceM })
+	return rep
+}
+
+// RunFleetMonitor checks robot pairs every interval until ctx is
+// cancelled.
+func (m *Manager) RunFleetMonitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		m.checkFleetProximity()
+	}
+}
+
+// fleetPose is a robot's position for one check.
+type fleetPose struct {
+	r      *Robot
+	x, y   float64
+	extent float64
+	mapID  string
+}
+
+func (m *Manager) checkFleetProximity() {
+	var poses []fleetPose
+	for _, r := range m.GetAllRobots() {
+		mapName := r.CurrentMap()
+		if !r.IsConnected() || mapName == "" {
+			continue
+		}
+		pose, _, err := r.CurrentMapPose(fleetPoseMaxAge)
+		if err != nil {
+			continue
+		}
+		poses = append(poses, fleetPose{r: r, x: pose.X, y: pose.Y, extent: r.extent(), mapID: mapName})
+	}
+	sort.Slice(poses, func(i, j int) bool { return poses[i].r.ID < poses[j].r.ID })
+
+	now := time.Now()
+	m.proximityMu.Lock()
+	opts := m.proximity.opts
+	prev := m.proximity.pairs
+	next := make(map[[2]string]ProximityPair)
+	var changed []ProximityPair
+	var stop [][2]*Robot
+	for i := range poses {
+		for j := i + 1; j < len(poses); j++ {
+			a, b := poses[i], poses[j]
+			if a.mapID != b.mapID {
+				continue
+			}
+			key := [2]string{a.r.ID, b.r.ID}
+			p := ProximityPair{
+				RobotA:    a.r.ID,
+				RobotB:    b.r.ID,
+				Map:       a.mapID,
+				DistanceM: math.Hypot(a.x-b.x, a.y-b.y),
+				CriticalM: a.extent + b.extent,
+				UpdatedAt: now,
+			}
+			p.WarningM = p.CriticalM + opts.MarginM
+			old := ProximityClear
+			if o, ok := prev[key]; ok {
+				old = o.State
+			}
+			p.State = proximityState(old, p.DistanceM, p.CriticalM, p.WarningM, opts.HysteresisM)
+			next[key] = p
+			if p.State != old {
+				changed = append(changed, p)
+				if p.State == ProximityCritical && opts.AutoStop {
+					stop = append(stop, [2]*Robot{a.r, b.r})
+				}
+			}
+		}
+	}
+	// Pairs no longer compared (disconnected, other map, stale pose)
+	for key, p := range prev {
+		if _, ok := next[key]; !ok && p.State != ProximityClear {
+			p.State = ProximityClear
+			p.UpdatedAt = now
+			changed = append(changed, p)
+		}
+	}
+	m.proximity.pairs = next
+	m.proximityMu.Unlock()
+
+	for _, p := range changed {
+		if p.State != ProximityClear {
+			log.Printf("[fleet] Robots %s and %s %.2f m apart on %q: %s", p.RobotA, p.RobotB, p.DistanceM, p.Map, p.State)
+		}
+		m.Broadcast(BroadcastMsg{Type: "fleet_proximity", Data: p})
+	}
+	for _, pair := range stop {
+		for _, r := range pair {
+			teleop := r.stopTeleop()
+			if moving := r.stopMove(); teleop || moving {
+				m.Notify("warn", r.ID, "fleet", fmt.Sprintf("Stopped %s: too close to another robot", r.Name))
+			}
+		}
+	}
+}
+
+// InCriticalProximity reports whether auto-stop is on and robot id is in
+// a critical pair as of the last check.
+func (m *Manager) InCriticalProximity(id string) bool {
+	m.proximityMu.Lock()
+	defer m.proximityMu.Unlock()
+	if !m.proximity.opts.AutoStop {
+		return false
+	}
+	for key, p := range m.proximity.pairs {
+		if (key[0] == id || key[1] == id) && p.State == ProximityCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// proximityState is the state for distance d given the previous state.
+func proximityState(prev string, d, critical, warning, hysteresis float64) string {
+	switch {
+	case d < critical || (prev == ProximityCritical && d <= critical+hysteresis):
+		return ProximityCritical
+	case d < warning || (prev != ProximityClear && d <= warning+hysteresis):
+		return ProximityWarning
+	}
+	return ProximityClear
+}
+
+// extent is the robot's reach from its centre: the furthest footprint
+// vertex, or the radius without a footprint.
+func (r *Robot) extent() float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.footprint == nil {
+		return r.radius
+	}
+	e := 0.0
+	for _, v := range r.footprint {
+		e = math.Max(e, math.Hypot(v[0], v[1]))
+	}
+	return e
+}
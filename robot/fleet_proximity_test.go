@@ -0,0 +1,182 @@
+package robot
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// placeOnMap marks r connected on mapName at (x, 0) with a fresh pose.
+func placeOnMap(r *Robot, mapName string, x float64) {
+	r.mu.Lock()
+	r.connected = true
+	r.currentMap = mapName
+	r.MapBfp = rosbridge.Pose2D{X: x}
+	r.lastMapBfpTime = time.Now()
+	r.mu.Unlock()
+}
+
+// drive opens a joystick session on r commanding linear velocity v.
+func drive(r *Robot, v float64) {
+	r.mu.Lock()
+	r.manual = &manualSession{state: ManualControl{Active: true, LinearX: v}}
+	r.mu.Unlock()
+	r.Client.SetDesiredCmdVel(rosbridge.TwistData{LinearX: v})
+}
+
+func stopNotices(m *Manager) int {
+	n := 0
+	for _, notice := range m.RecentNotices(time.Time{}, "") {
+		if notice.Source == "fleet" && strings.HasPrefix(notice.Message, "Stopped") {
+			n++
+		}
+	}
+	return n
+}
+
+// proximityEvents drains the fleet_proximity broadcasts from ch.
+func proximityEvents(ch chan BroadcastMsg) []string {
+	var states []string
+	for {
+		select {
+		case msg := <-ch:
+			if msg.Type == "fleet_proximity" {
+				states = append(states, msg.Data.(ProximityPair).State)
+			}
+		default:
+			return states
+		}
+	}
+}
+
+// TestFleetProximity moves robot b along the x axis away from and towards
+// robot a, both 0.3 m in radius: critical under 0.6 m, warning under
+// 1.1 m, each left 0.2 m beyond its threshold. Robot c is on another map
+// and never paired.
+func TestFleetProximity(t *testing.T) {
+	m := NewManager()
+	a, _ := m.AddRobot("", "a", "127.0.0.1", 9)
+	b, _ := m.AddRobot("", "b", "127.0.0.1", 10)
+	c, _ := m.AddRobot("", "c", "127.0.0.1", 11)
+	for _, r := range []*Robot{a, b, c} {
+		defer r.Close()
+		r.SetRadius(0.3)
+	}
+	opts := ProximityOptions{MarginM: 0.5, HysteresisM: 0.2, AutoStop: true}
+	if err := m.SetProximityOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	ch := m.Subscribe()
+	defer m.Unsubscribe(ch)
+	placeOnMap(a, "floor1", 0)
+	placeOnMap(c, "floor2", 0.1)
+
+	steps := []struct {
+		name   string
+		mapB   string
+		x      float64
+		state  string   // of the a-b pair; "" when not compared
+		events []string // fleet_proximity broadcasts
+		stops  int      // stop notices so far
+	}{
+		{"far apart", "floor1", 3, ProximityClear, nil, 0},
+		{"within the margin", "floor1", 1.0, ProximityWarning, []string{ProximityWarning}, 0},
+		{"warning held by hysteresis", "floor1", 1.25, ProximityWarning, nil, 0},
+		{"past the hysteresis", "floor1", 1.35, ProximityClear, []string{ProximityClear}, 0},
+		{"overlapping", "floor1", 0.5, ProximityCritical, []string{ProximityCritical}, 2},
+		{"critical held by hysteresis", "floor1", 0.75, ProximityCritical, nil, 2},
+		{"back to warning", "floor1", 0.9, ProximityWarning, []string{ProximityWarning}, 2},
+		{"critical again", "floor1", 0.4, ProximityCritical, []string{ProximityCritical}, 4},
+		{"other map", "floor2", 5, "", []string{ProximityClear}, 4},
+	}
+	for _, s := range steps {
+		placeOnMap(b, s.mapB, s.x)
+		drive(a, 0.4)
+		drive(b, 0.4)
+		m.checkFleetProximity()
+
+		rep := m.FleetProximity()
+		state := ""
+		for _, p := range rep.Pairs {
+			switch {
+			case p.RobotA == a.ID && p.RobotB == b.ID:
+				state = p.State
+				if math.Abs(p.CriticalM-0.6) > 1e-9 || math.Abs(p.WarningM-1.1) > 1e-9 {
+					t.Errorf("%s: thresholds %v/%v", s.name, p.CriticalM, p.WarningM)
+				}
+			case s.mapB == "floor2" && p.State != ProximityClear:
+				// b and c share floor2 but are 5 m apart
+				t.Errorf("%s: pair %s-%s %s", s.name, p.RobotA, p.RobotB, p.State)
+			case s.mapB == "floor1":
+				t.Errorf("%s: pair %s-%s across maps", s.name, p.RobotA, p.RobotB)
+			}
+		}
+		if state != s.state {
+			t.Errorf("%s: state %q, want %q", s.name, state, s.state)
+		}
+		if got := proximityEvents(ch); strings.Join(got, ",") != strings.Join(s.events, ",") {
+			t.Errorf("%s: broadcast %v, want %v", s.name, got, s.events)
+		}
+		if n := stopNotices(m); n != s.stops {
+			t.Errorf("%s: %d stop notices, want %d", s.name, n, s.stops)
+		}
+		stopped := s.state == ProximityCritical && len(s.events) > 0
+		for _, r := range []*Robot{a, b} {
+			if v := r.Client.DesiredCmdVel().LinearX; stopped != (v == 0) {
+				t.Errorf("%s: robot %s commanded %v", s.name, r.Name, v)
+			}
+		}
+		if got := m.InCriticalProximity(a.ID); got != (s.state == ProximityCritical) {
+			t.Errorf("%s: InCriticalProximity %v", s.name, got)
+		}
+	}
+
+	// Without auto-stop, critical is reported but nobody is stopped
+	opts.AutoStop = false
+	m.SetProximityOptions(opts)
+	placeOnMap(b, "floor1", 3)
+	m.checkFleetProximity()
+	placeOnMap(b, "floor1", 0.2)
+	drive(b, 0.4)
+	m.checkFleetProximity()
+	if got := proximityEvents(ch); strings.Join(got, ",") != ProximityCritical {
+		t.Errorf("without auto-stop: broadcast %v", got)
+	}
+	if v := b.Client.DesiredCmdVel().LinearX; v != 0.4 || stopNotices(m) != 4 || m.InCriticalProximity(a.ID) {
+		t.Errorf("without auto-stop: commanded %v, %d stop notices", v, stopNotices(m))
+	}
+
+	// A disconnected robot leaves its pairs
+	b.mu.Lock()
+	b.connected = false
+	b.mu.Unlock()
+	m.checkFleetProximity()
+	if got := proximityEvents(ch); strings.Join(got, ",") != ProximityClear || len(m.FleetProximity().Pairs) != 0 {
+		t.Errorf("after disconnect: broadcast %v, pairs %v", got, m.FleetProximity().Pairs)
+	}
+}
+
+func TestProximityState(t *testing.T) {
+	for _, tc := range []struct {
+		prev string
+		d    float64
+		want string
+	}{
+		{ProximityClear, 0.59, ProximityCritical},
+		{ProximityClear, 0.61, ProximityWarning},
+		{ProximityClear, 1.11, ProximityClear},
+		{ProximityWarning, 1.29, ProximityWarning},
+		{ProximityWarning, 1.31, ProximityClear},
+		{ProximityCritical, 0.79, ProximityCritical},
+		{ProximityCritical, 0.81, ProximityWarning},
+		{ProximityCritical, 1.29, ProximityWarning},
+		{ProximityCritical, 1.4, ProximityClear},
+	} {
+		if got := proximityState(tc.prev, tc.d, 0.6, 1.1, 0.2); got != tc.want {
+			t.Errorf("%s at %v m: %s, want %s", tc.prev, tc.d, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Floors and per-map points
+//
+// Navigation points and walls belong to the map they were taught on. The
+// robot's point fields hold the current map's collections; the other
+// maps' collections are kept aside and swapped in, under one lock, when
+// another map becomes current. Points that existed before any map was
+// known are adopted by the first map opened.
+//
+// Maps can be assigned to named floors. The active floor follows the
+// current map; switching floors opens that floor's map on the robot.
+
+// ErrUnknownFloor is returned when a floor has no maps assigned.
+var ErrUnknownFloor = errors.New("no maps assigned to floor")
+
+// MapPoints are the point collections and walls of one map.
+type MapPoints struct {
+	Waypoints     []rosbridge.NavigationPoint `json:"waypoints,omitempty"`
+	ServicePoints []rosbridge.NavigationPoint `json:"service_points,omitempty"`
+	PatrolPoints  []rosbridge.NavigationPoint `json:"patrol_points,omitempty"`
+	PathPoints    []rosbridge.NavigationPoint `json:"path_points,omitempty"`
+	WallObstacles []rosbridge.WallObstacle    `json:"wall_obstacles,omitempty"`
+}
+
+func (p MapPoints) empty() bool {
+	return len(p.Waypoints)+len(p.ServicePoints)+len(p.PatrolPoints)+len(p.PathPoints)+len(p.WallObstacles) == 0
+}
+
+func (p MapPoints) clone() MapPoints {
+	return MapPoints{
+		Waypoints:     append([]rosbridge.NavigationPoint(nil), p.Waypoints...),
+		ServicePoints: append([]rosbridge.NavigationPoint(nil), p.ServicePoints...),
+		PatrolPoints:  append([]rosbridge.NavigationPoint(nil), p.PatrolPoints...),
+		PathPoints:    append([]rosbridge.NavigationPoint(nil), p.PathPoints...),
+		WallObstacles: append([]rosbridge.WallObstacle(nil), p.WallObstacles...),
+	}
+}
+
+// Floor is a named group of maps.
+type Floor struct {
+	Name string   `json:"name"`
+	Maps []string `json:"maps"`
+}
+
+// livePointsLocked returns the current map's collections. Caller holds
+// r.mu.
+func (r *Robot) livePointsLocked() MapPoints {
+	return MapPoints{
+		Waypoints:     r.Waypoints,
+		ServicePoints: r.ServicePoints,
+		PatrolPoints:  r.PatrolPoints,
+		PathPoints:    r.PathPoints,
+		WallObstacles: r.WallObstacles,
+	}
+}
+
+func (r *Robot) setLivePointsLocked(p MapPoints) {
+	r.resetNavUndoLocked()
+	r.Waypoints = p.Waypoints
+	r.ServicePoints = p.ServicePoints
+	r.PatrolPoints = p.PatrolPoints
+	r.PathPoints = p.PathPoints
+	r.WallObstacles = p.WallObstacles
+}
+
+// saveMapLocked makes mapName current after a save: the live points now
+// belong to it, and the map saved from keeps a copy. Caller holds r.mu
+// for writing.
+func (r *Robot) saveMapLocked(mapName string) {
+	if r.currentMap != "" && r.currentMap != mapName {
+		if r.mapPoints == nil {
+			r.mapPoints = make(map[string]MapPoints)
+		}
+		r.mapPoints[r.currentMap] = r.livePointsLocked().clone()
+	}
+	// The robot's mapName was overwritten, so points kept for it are
+	// stale.
+	delete(r.mapPoints, mapName)
+	r.currentMap = mapName
+	r.activeFloor = r.floors[mapName]
+}
+
+// startFreshMap makes mapName, just created by a mapping session,
+// current with no points; the previous map keeps its own.
+func (r *Robot) startFreshMap(mapName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.mapPoints, mapName)
+	if r.currentMap == mapName {
+		r.setLivePointsLocked(MapPoints{})
+		return
+	}
+	if r.mapPoints == nil {
+		r.mapPoints = make(map[string]MapPoints)
+	}
+	r.mapPoints[mapName] = MapPoints{}
+	r.switchMapLocked(mapName)
+}
+
+// switchMapLocked makes mapName current, swapping the point collections.
+// Caller holds r.mu for writing.
+func (r *Robot) switchMapLocked(mapName string) {
+	if mapName == r.currentMap {
+		return
+	}
+	next, known := r.mapPoints[mapName]
+	if r.currentMap != "" || known {
+		if live := r.livePointsLocked(); !live.empty() || r.currentMap != "" {
+			if r.mapPoints == nil {
+				r.mapPoints = make(map[string]MapPoints)
+			}
+			r.mapPoints[r.currentMap] = live
+		}
+		delete(r.mapPoints, mapName)
+		r.setLivePointsLocked(next)
+	}
+	r.currentMap = mapName
+	r.activeFloor = r.floors[mapName]
+}
+
+// AssignFloor puts mapName on floor; an empty floor removes the
+// assignment.
+func (r *Robot) AssignFloor(mapName, floor string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if floor == "" {
+		delete(r.floors, mapName)
+	} else {
+		if r.floors == nil {
+			r.floors = make(map[string]string)
+		}
+		r.floors[mapName] = floor
+	}
+	if mapName == r.currentMap {
+		r.activeFloor = floor
+	}
+}
+
+// Floors returns the floors with their maps, sorted by name.
+func (r *Robot) Floors() []Floor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	byFloor := make(map[string][]string)
+	for m, f := range r.floors {
+		byFloor[f] = append(byFloor[f], m)
+	}
+	out := make([]Floor, 0, len(byFloor))
+	for f, maps := range byFloor {
+		sort.Strings(maps)
+		out = append(out, Floor{Name: f, Maps: maps})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// FloorOf returns the floor mapName is assigned to, or "".
+func (r *Robot) FloorOf(mapName string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.floors[mapName]
+}
+
+// ActiveFloor returns the floor of the current map, or "".
+func (r *Robot) ActiveFloor() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.activeFloor
+}
+
+// FloorMap picks the map to open for floor: the current map if it is on
+// that floor, else the floor's most recently saved or opened map, else
+// its first map by name.
+func (r *Robot) FloorMap(floor string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.floors[r.currentMap] == floor && r.currentMap != "" {
+		return r.currentMap, nil
+	}
+	for i := len(r.mapHistory) - 1; i >= 0; i-- {
+		if m := r.mapHistory[i].Map; r.floors[m] == floor {
+			return m, nil
+		}
+	}
+	var maps []string
+	for m, f := range r.floors {
+		if f == floor {
+			maps = append(maps, m)
+		}
+	}
+	if len(maps) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrUnknownFloor, floor)
+	}
+	sort.Strings(maps)
+	return maps[0], nil
+}
+
+// SwitchFloor opens floor's map on the robot and swaps in its points.
+// Refused while a patrol runs. Returns the map opened.
+func (nm *NavigationManager) SwitchFloor(rb *Robot, floor, client string) (string, error) {
+	mapName, err := rb.FloorMap(floor)
+	if err != nil {
+		return "", err
+	}
+	if !rb.IsConnected() {
+		return "", ErrNotConnected
+	}
+	if st := rb.GetPatrolStatus(); st != nil && st.Running {
+		return "", ErrPatrolRunning
+	}
+	if mapName == rb.CurrentMap() {
+		return mapName, nil
+	}
+	if err := rb.Require(rosbridge.CapMapSelect); err != nil {
+		return "", err
+	}
+	if _, err := rb.Client.SelectMap(mapName); err != nil {
+		return "", fmt.Errorf("open map %s: %w", mapName, err)
+	}
+	rb.RecordMapEvent(MapActionOpen, mapName, client)
+	return mapName, nil
+}
+
+// mapPointsCopy returns the collections kept aside for maps other than
+// the current one (profile export).
+func (r *Robot) mapPointsCopy() map[string]MapPoints {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.mapPoints) == 0 {
+		return nil
+	}
+	out := make(map[string]MapPoints, len(r.mapPoints))
+	for m, p := range r.mapPoints {
+		out[m] = p
+	}
+	return out
+}
+
+func (r *Robot) floorsCopy() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.floors) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(r.floors))
+	for m, f := range r.floors {
+		out[m] = f
+	}
+	return out
+}
+
+// setFloorsAndMapPoints replaces floor assignments and other maps'
+// collections (profile import); the current map's entry, if any, is
+// ignored since its points are the live ones.
+func (r *Robot) setFloorsAndMapPoints(floors map[string]string, points map[string]MapPoints) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.floors = make(map[string]string, len(floors))
+	for m, f := range floors {
+		if m != "" && f != "" {
+			r.floors[m] = f
+		}
+	}
+	r.mapPoints = make(map[string]MapPoints, len(points))
+	for m, p := range points {
+		if m != r.currentMap {
+			r.mapPoints[m] = p
+		}
+	}
+	r.activeFloor = r.floors[r.currentMap]
+}
@@ -0,0 +1,80 @@
+package robot
+
+import (
+	"errors"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+// selectMapCalls counts the which_maps calls the stub has answered.
+func selectMapCalls(s *rosbridgeStub) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, o := range s.ops {
+		if o.Op == "call_service" && o.Service == "/which_maps" {
+			n++
+		}
+	}
+	return n
+}
+
+// TestSwitchFloor moves between two floors: each switch opens the
+// floor's last used map and swaps in that map's points.
+func TestSwitchFloor(t *testing.T) {
+	r, stub := relocRobot(t)
+	nm := NewNavigationManager()
+
+	// Points taught before any map are adopted by the first one opened
+	r.mu.Lock()
+	r.Waypoints = []rosbridge.NavigationPoint{{Name: "lobby"}}
+	r.mu.Unlock()
+	r.RecordMapEvent(MapActionOpen, "ground-a", "")
+	r.AssignFloor("ground-a", "ground")
+	r.AssignFloor("ground-b", "ground")
+	r.AssignFloor("first-a", "first")
+	if f := r.ActiveFloor(); f != "ground" {
+		t.Errorf("active floor %q, want ground", f)
+	}
+
+	m, err := nm.SwitchFloor(r, "first", "")
+	if err != nil || m != "first-a" {
+		t.Fatalf("switch to first: %q %v", m, err)
+	}
+	if r.CurrentMap() != "first-a" || r.ActiveFloor() != "first" || len(waypointNames(r)) != 0 {
+		t.Errorf("on first: map %q floor %q points %v", r.CurrentMap(), r.ActiveFloor(), waypointNames(r))
+	}
+	r.mu.Lock()
+	r.Waypoints = []rosbridge.NavigationPoint{{Name: "office"}}
+	r.mu.Unlock()
+
+	// ground-a, not ground-b: the floor's most recently opened map
+	if m, err := nm.SwitchFloor(r, "ground", ""); err != nil || m != "ground-a" {
+		t.Fatalf("switch to ground: %q %v", m, err)
+	}
+	if names := waypointNames(r); len(names) != 1 || names[0] != "lobby" {
+		t.Errorf("ground points %v", names)
+	}
+	calls := selectMapCalls(stub)
+	if m, err := nm.SwitchFloor(r, "ground", ""); err != nil || m != "ground-a" || selectMapCalls(stub) != calls {
+		t.Errorf("switch to the current floor: %q %v, reopened the map", m, err)
+	}
+	if _, err := nm.SwitchFloor(r, "first", ""); err != nil {
+		t.Fatal(err)
+	}
+	if names := waypointNames(r); len(names) != 1 || names[0] != "office" {
+		t.Errorf("first floor points after returning %v", names)
+	}
+
+	if _, err := nm.SwitchFloor(r, "basement", ""); !errors.Is(err, ErrUnknownFloor) {
+		t.Errorf("unknown floor: %v", err)
+	}
+	if _, err := r.startPatrol(PatrolRequest{}, false, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	defer r.stopPatrol()
+	if _, err := nm.SwitchFloor(r, "ground", ""); !errors.Is(err, ErrPatrolRunning) || r.CurrentMap() != "first-a" {
+		t.Errorf("switch during a patrol: %v, map %q", err, r.CurrentMap())
+	}
+}
@@ -0,0 +1,186 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ──────────────────────────── Footprint
+//
+// A rectangular robot drawn as a circle either clips its corners or
+// overstates the clearance it needs. The footprint is the robot's outline
+// as polygon vertices in the base frame (x forward, y left, metres), the
+// same [[x, y], ...] form Nav2 uses. Without one the radius is used.
+
+// MaxFootprintExtentM bounds vertex coordinates; anything further from
+// the base frame origin is a unit or typing mistake.
+const MaxFootprintExtentM = 5.0
+
+// Footprint is a polygon in the robot's base frame.
+type Footprint [][2]float64
+
+// RobotConfig is the robot's outline and scan mask as broadcast in
+// "robot_config".
+type RobotConfig struct {
+	Radius    float64   `json:"radius"`
+	Footprint Footprint `json:"footprint,omitempty"` // nil: draw the radius
+	ScanMask  ScanMask  `json:"scan_mask,omitempty"`
+	Home      *HomePose `json:"home,omitempty"`
+}
+
+// ParseFootprint decodes and validates a JSON [[x, y], ...] footprint.
+// "[]" yields nil, which clears the footprint.
+func ParseFootprint(s string) (Footprint, error) {
+	var f Footprint
+	if err := json.Unmarshal([]byte(s), &f); err != nil {
+		return nil, fmt.Errorf("footprint must be a JSON array of [x, y] vertices: %w", err)
+	}
+	if len(f) == 0 {
+		return nil, nil
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Validate checks that f has at least 3 finite vertices within
+// MaxFootprintExtentM and encloses an area.
+func (f Footprint) Validate() error {
+	if len(f) < 3 {
+		return fmt.Errorf("footprint needs at least 3 vertices, got %d", len(f))
+	}
+	for i, v := range f {
+		for _, c := range v {
+			if math.IsNaN(c) || math.IsInf(c, 0) || math.Abs(c) > MaxFootprintExtentM {
+				return fmt.Errorf("footprint vertex %d (%v, %v) is outside ±%.0f m", i, v[0], v[1], MaxFootprintExtentM)
+			}
+		}
+	}
+	if math.Abs(f.area()) < 1e-4 {
+		return fmt.Errorf("footprint encloses no area")
+	}
+	return nil
+}
+
+// area is the signed shoelace area.
+func (f Footprint) area() float64 {
+	a := 0.0
+	for i := range f {
+		p, q := f[i], f[(i+1)%len(f)]
+		a += p[0]*q[1] - q[0]*p[1]
+	}
+	return a / 2
+}
+
+// Contains reports whether the base-frame point (x, y) lies inside the
+// polygon or within margin of its outline.
+func (f Footprint) Contains(x, y, margin float64) bool {
+	inside := false
+	for i, j := 0, len(f)-1; i < len(f); j, i = i, i+1 {
+		p, q := f[i], f[j]
+		if (p[1] > y) != (q[1] > y) && x < (q[0]-p[0])*(y-p[1])/(q[1]-p[1])+p[0] {
+			inside = !inside
+		}
+	}
+	if inside {
+		return true
+	}
+	if margin <= 0 {
+		return false
+	}
+	for i := range f {
+		if segmentDistance(x, y, f[i], f[(i+1)%len(f)]) <= margin {
+			return true
+		}
+	}
+	return false
+}
+
+// String is the JSON form, "" when unset (settings panel).
+func (f Footprint) String() string {
+	if len(f) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(f)
+	return string(b)
+}
+
+func (f Footprint) clone() Footprint {
+	if f == nil {
+		return nil
+	}
+	return append(Footprint(nil), f...)
+}
+
+// segmentDistance is the distance from (x, y) to the segment a–b.
+func segmentDistance(x, y float64, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t = math.Max(0, math.Min(1, ((x-a[0])*dx+(y-a[1])*dy)/l2))
+	}
+	return math.Hypot(x-(a[0]+t*dx), y-(a[1]+t*dy))
+}
+
+// SetFootprint sets the robot's outline; nil falls back to the radius.
+func (r *Robot) SetFootprint(f Footprint) error {
+	if f != nil {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	r.footprint = f.clone()
+	r.mu.Unlock()
+	r.emitConfig()
+	return nil
+}
+
+// GetFootprint returns a copy of the footprint, or nil.
+func (r *Robot) GetFootprint() Footprint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.footprint.clone()
+}
+
+// GetConfig returns the robot's radius, footprint, scan mask and home.
+func (r *Robot) GetConfig() RobotConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return RobotConfig{Radius: r.radius, Footprint: r.footprint.clone(), ScanMask: r.scanMask.clone(), Home: r.home.clone()}
+}
+
+// emitConfig broadcasts the outline, scan mask and home after a change.
+func (r *Robot) emitConfig() {
+	if r.OnConfig != nil {
+		r.OnConfig(r.GetConfig())
+	}
+}
+
+// FootprintContains reports whether the base-frame point (x, y) is
+// within margin of the robot: inside the footprint polygon, or the
+// radius circle when no footprint is set.
+func (r *Robot) FootprintContains(x, y, margin float64) bool {
+	r.mu.RLock()
+	f, radius := r.footprint, r.radius
+	r.mu.RUnlock()
+	if f != nil {
+		return f.Contains(x, y, margin)
+	}
+	return math.Hypot(x, y) <= radius+margin
+}
+
+// MapPointInFootprint is FootprintContains for a map-frame point, using
+// the robot's current map pose (no older than maxAge).
+func (r *Robot) MapPointInFootprint(x, y, margin float64, maxAge time.Duration) (bool, error) {
+	pose, _, err := r.CurrentMapPose(maxAge)
+	if err != nil {
+		return false, err
+	}
+	dx, dy := x-pose.X, y-pose.Y
+	cos, sin := math.Cos(pose.Theta), math.Sin(pose.Theta)
+	return r.FootprintContains(cos*dx+sin*dy, -sin*dx+cos*dy, margin), nil
+}
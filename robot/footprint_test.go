@@ -0,0 +1,108 @@
+package robot
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// rect is a 0.6 × 0.4 m robot, counter-clockwise.
+var rect = Footprint{{0.3, 0.2}, {-0.3, 0.2}, {-0.3, -0.2}, {0.3, -0.2}}
+
+func TestFootprintContains(t *testing.T) {
+	triangle := Footprint{{0.5, 0}, {-0.25, 0.3}, {-0.25, -0.3}}
+	// L shape, concave at (0, 0)
+	ell := Footprint{{-0.5, -0.5}, {0.5, -0.5}, {0.5, 0}, {0, 0}, {0, 0.5}, {-0.5, 0.5}}
+	clockwise := Footprint{rect[3], rect[2], rect[1], rect[0]}
+
+	for _, tc := range []struct {
+		name   string
+		f      Footprint
+		x, y   float64
+		margin float64
+		want   bool
+	}{
+		{"center", rect, 0, 0, 0, true},
+		{"inside near a corner", rect, 0.29, 0.19, 0, true},
+		{"outside beside", rect, 0, 0.25, 0, false},
+		{"edge within margin", rect, 0, 0.25, 0.05, true},
+		{"edge beyond margin", rect, 0, 0.26, 0.05, false},
+		{"corner diagonal within margin", rect, 0.33, 0.23, 0.05, true},  // 0.042 m from the corner
+		{"corner diagonal beyond margin", rect, 0.34, 0.24, 0.05, false}, // 0.057 m, though within 0.05 on each axis
+		{"clockwise", clockwise, 0.1, -0.1, 0, true},
+		{"triangle inside", triangle, 0.2, 0, 0, true},
+		{"triangle beside the tip", triangle, 0.4, 0.1, 0, false},
+		{"triangle behind", triangle, -0.3, 0, 0, false},
+		{"L arm", ell, -0.25, 0.25, 0, true},
+		{"L notch", ell, 0.25, 0.25, 0, false},
+		{"L notch within margin", ell, 0.05, 0.25, 0.06, true},
+	} {
+		if got := tc.f.Contains(tc.x, tc.y, tc.margin); got != tc.want {
+			t.Errorf("%s: Contains(%v, %v, %v) = %v", tc.name, tc.x, tc.y, tc.margin, got)
+		}
+	}
+}
+
+func TestParseFootprint(t *testing.T) {
+	f, err := ParseFootprint(rect.String())
+	if err != nil || len(f) != 4 || f[1] != rect[1] {
+		t.Errorf("round trip: %v %v", f, err)
+	}
+	if math.Abs(math.Abs(f.area())-0.24) > 1e-9 {
+		t.Errorf("area %v", f.area())
+	}
+	if f, err := ParseFootprint("[]"); f != nil || err != nil {
+		t.Errorf("empty: %v %v", f, err)
+	}
+	for s, want := range map[string]string{
+		`[[0,0],[1,0]]`:           "at least 3",
+		`[[0,0],[1,0],[2,0]]`:     "no area",
+		`[[0,0],[1,0],[0,6]]`:     "outside",
+		`[[0,0],[1,0],[0,1],[1]]`: "", // any error
+		`{"x":1}`:                 "JSON array",
+	} {
+		if _, err := ParseFootprint(s); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseFootprint(%s) = %v, want %q", s, err, want)
+		}
+	}
+}
+
+func TestMapPointInFootprint(t *testing.T) {
+	r := NewRobot("1", "", "test", "127.0.0.1", 9)
+	if _, err := r.MapPointInFootprint(0, 0, 0, time.Second); err != ErrPoseStale {
+		t.Errorf("without a pose: %v", err)
+	}
+
+	// Facing +y at (10, 5): the long side runs along the map's y axis
+	r.mu.Lock()
+	r.MapBfp = rosbridge.Pose2D{X: 10, Y: 5, Theta: math.Pi / 2}
+	r.lastMapBfpTime = time.Now()
+	r.mu.Unlock()
+
+	r.SetRadius(0.1)
+	if in, _ := r.MapPointInFootprint(10, 5.25, 0, time.Second); in {
+		t.Error("radius: 0.25 m ahead is inside 0.1 m")
+	}
+	if in, _ := r.MapPointInFootprint(10, 5.25, 0.2, time.Second); !in {
+		t.Error("radius: 0.25 m ahead is outside 0.1 m + 0.2 m margin")
+	}
+
+	if err := r.SetFootprint(rect); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		x, y float64
+		want bool
+	}{
+		{10, 5.25, true},  // 0.25 m ahead
+		{10.25, 5, false}, // 0.25 m to the right, the robot is 0.2 m wide
+		{9.85, 4.75, true},
+	} {
+		if in, err := r.MapPointInFootprint(tc.x, tc.y, 0, time.Second); err != nil || in != tc.want {
+			t.Errorf("(%v, %v): %v %v, want %v", tc.x, tc.y, in, err, tc.want)
+		}
+	}
+}
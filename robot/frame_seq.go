@@ -0,0 +1,69 @@
+package robot
+
+import "time"
+
+// ──────────────────────────── Frame sequence numbers
+//
+// Every broadcast is stamped with a server time (Unix ms) and a sequence
+// number counting that robot's frames of that type (robot_id "" for fleet
+// events), starting at 1. Numbers are assigned before a slow subscriber
+// may drop the frame, so a gap means frames were dropped or throttled on
+// the way, and a number lower than one already seen is an out-of-order
+// frame the client should discard. Replies sent to a single connection
+// carry the latest number of their stream without advancing it.
+
+// seqKey identifies a frame stream.
+type seqKey struct {
+	robotID, msgType string
+}
+
+// stamp assigns msg the next sequence number of its stream and the
+// current time.
+func (m *Manager) stamp(msg *BroadcastMsg) {
+	m.seqMu.Lock()
+	if m.seqs == nil {
+		m.seqs = make(map[seqKey]uint64)
+	}
+	k := seqKey{msg.RobotID, msg.Type}
+	m.seqs[k]++
+	msg.Seq = m.seqs[k]
+	m.seqMu.Unlock()
+	msg.TS = time.Now().UnixMilli()
+}
+
+// Stamp returns msg with the latest sequence number of its stream and
+// the current time, for a frame sent to one client outside Broadcast.
+func (m *Manager) Stamp(msg BroadcastMsg) BroadcastMsg {
+	m.seqMu.Lock()
+	msg.Seq = m.seqs[seqKey{msg.RobotID, msg.Type}]
+	m.seqMu.Unlock()
+	msg.TS = time.Now().UnixMilli()
+	msg.enc = nil // restamped: a broadcast's encoding no longer matches
+	return msg
+}
+
+// Sequences returns the latest sequence number of every stream, by robot
+// ID ("" for fleet events) and message type.
+func (m *Manager) Sequences() map[string]map[string]uint64 {
+	m.seqMu.Lock()
+	defer m.seqMu.Unlock()
+	out := make(map[string]map[string]uint64)
+	for k, n := range m.seqs {
+		if out[k.robotID] == nil {
+			out[k.robotID] = make(map[string]uint64)
+		}
+		out[k.robotID][k.msgType] = n
+	}
+	return out
+}
+
+// dropSequences forgets a removed robot's streams.
+func (m *Manager) dropSequences(robotID string) {
+	m.seqMu.Lock()
+	defer m.seqMu.Unlock()
+	for k := range m.seqs {
+		if k.robotID == robotID {
+			delete(m.seqs, k)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package robot
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestFrameSeqDropped broadcasts past a full subscriber's buffer: the
+// frames it drops leave a gap, and its numbers still only increase.
+func TestFrameSeqDropped(t *testing.T) {
+	m := NewManager()
+	slow := m.Subscribe()
+	fast := m.Subscribe()
+	defer m.Unsubscribe(slow)
+	defer m.Unsubscribe(fast)
+
+	var fastSeqs []uint64
+	broadcast := func(n int) {
+		for i := 0; i < n; i++ {
+			m.Broadcast(BroadcastMsg{Type: "odom", RobotID: "1"})
+			fastSeqs = append(fastSeqs, (<-fast).Seq) // keeps up
+		}
+	}
+
+	// The slow subscriber's buffer holds 100 frames
+	broadcast(150)
+	var slowSeqs []uint64
+	for len(slow) > 0 {
+		slowSeqs = append(slowSeqs, (<-slow).Seq)
+	}
+	broadcast(5)
+	for len(slow) > 0 {
+		slowSeqs = append(slowSeqs, (<-slow).Seq)
+	}
+
+	if len(slowSeqs) != 105 || slowSeqs[99] != 100 || slowSeqs[100] != 151 || slowSeqs[104] != 155 {
+		t.Errorf("slow subscriber got %d frames: ...%v", len(slowSeqs), slowSeqs[95:])
+	}
+	for i := 1; i < len(slowSeqs); i++ {
+		if slowSeqs[i] <= slowSeqs[i-1] {
+			t.Fatalf("slow subscriber: %d after %d", slowSeqs[i], slowSeqs[i-1])
+		}
+	}
+	for i, n := range fastSeqs {
+		if n != uint64(i+1) {
+			t.Fatalf("fast subscriber: frame %d numbered %d", i, n)
+		}
+	}
+	if len(fastSeqs) != 155 {
+		t.Errorf("fast subscriber got %d frames", len(fastSeqs))
+	}
+}
+
+// TestFrameSeqConcurrent checks numbers stay unique and gapless per
+// stream when many goroutines broadcast at once.
+func TestFrameSeqConcurrent(t *testing.T) {
+	m := NewManager()
+	ch := m.Subscribe()
+	defer m.Unsubscribe(ch)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				m.Broadcast(BroadcastMsg{Type: "tf", RobotID: "1"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool)
+	for len(ch) > 0 {
+		seen[(<-ch).Seq] = true
+	}
+	for n := uint64(1); n <= 80; n++ {
+		if !seen[n] {
+			t.Fatalf("sequence number %d missing: %v", n, seen)
+		}
+	}
+}
+
+func TestFrameSeqStreams(t *testing.T) {
+	m := NewManager()
+	m.Broadcast(BroadcastMsg{Type: "odom", RobotID: "1"})
+	m.Broadcast(BroadcastMsg{Type: "odom", RobotID: "1"})
+	m.Broadcast(BroadcastMsg{Type: "tf", RobotID: "1"})
+	m.Broadcast(BroadcastMsg{Type: "odom", RobotID: "2"})
+	m.Broadcast(BroadcastMsg{Type: "robot_added"})
+
+	seqs := m.Sequences()
+	if seqs["1"]["odom"] != 2 || seqs["1"]["tf"] != 1 || seqs["2"]["odom"] != 1 || seqs[""]["robot_added"] != 1 {
+		t.Errorf("sequences %v", seqs)
+	}
+
+	// A reply repeats the latest number without advancing it
+	if got := m.Stamp(BroadcastMsg{Type: "odom", RobotID: "1"}); got.Seq != 2 || got.TS == 0 {
+		t.Errorf("stamped reply %+v", got)
+	}
+	if got := m.Stamp(BroadcastMsg{Type: "map", RobotID: "1"}); got.Seq != 0 {
+		t.Errorf("reply on a new stream numbered %d", got.Seq)
+	}
+	if m.Sequences()["1"]["odom"] != 2 {
+		t.Error("Stamp advanced the stream")
+	}
+
+	m.dropSequences("1")
+	if seqs := m.Sequences(); seqs["1"] != nil || seqs["2"]["odom"] != 1 {
+		t.Errorf("after dropping robot 1: %v", seqs)
+	}
+}
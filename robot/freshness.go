@@ -0,0 +1,115 @@
+package robot
+
+import (
+	"sort"
+	"time"
+)
+
+// ──────────────────────────── Data freshness
+//
+// A snapshot keeps the last odometry, velocity, TF, scan and map after a
+// robot goes quiet, so it carries how old each of them is and which are
+// stale: older than their threshold, never received, or from a robot
+// that is disconnected. DataStale summarizes the pose streams (odometry
+// and TF), which a live robot publishes continuously; cmd_vel only
+// arrives while something commands the robot and the map is latched, so
+// those are listed in Stale but don't make the robot's data stale.
+
+// Freshness stream names, also the keys of the staleness thresholds.
+const (
+	StreamOdom     = "odom"
+	StreamVelocity = "velocity"
+	StreamTF       = "tf"
+	StreamLaser    = "laser"
+	StreamMap      = "map"
+)
+
+// DefaultStaleThresholds are the ages (ms) past which a stream is stale;
+// 0 judges a stream by connection only. Idle robots publish odometry and
+// TF at 1 Hz and drop the scans (rosbridge.DefaultIdleTopics).
+var DefaultStaleThresholds = map[string]int{
+	StreamOdom:     2000,
+	StreamVelocity: 2000,
+	StreamTF:       2000,
+	StreamLaser:    2000,
+	StreamMap:      0,
+}
+
+// dataStaleStreams are the streams DataStale is computed from.
+var dataStaleStreams = []string{StreamOdom, StreamTF}
+
+// Freshness is the age of each stream, in ms since it last arrived (nil
+// if it never did), and which streams are stale.
+type Freshness struct {
+	OdomAgeMs     *int64   `json:"odom_age_ms"`
+	VelocityAgeMs *int64   `json:"velocity_age_ms"`
+	TFAgeMs       *int64   `json:"tf_age_ms"`
+	LaserAgeMs    *int64   `json:"laser_age_ms"`
+	MapAgeMs      *int64   `json:"map_age_ms"`
+	Stale         []string `json:"stale"`      // stale streams, sorted
+	DataStale     bool     `json:"data_stale"` // disconnected, or odometry or TF stale
+}
+
+// IsStale reports whether stream is stale.
+func (f Freshness) IsStale(stream string) bool {
+	for _, s := range f.Stale {
+		if s == stream {
+			return true
+		}
+	}
+	return false
+}
+
+// freshness judges the streams received at the given times (zero: never)
+// against thresholds (ms by stream; missing entries use the defaults).
+func freshness(connected bool, received map[string]time.Time, thresholds map[string]int, now time.Time) Freshness {
+	ages := make(map[string]*int64, len(received))
+	stale := []string{}
+	for stream, t := range received {
+		limit, ok := thresholds[stream]
+		if !ok {
+			limit = DefaultStaleThresholds[stream]
+		}
+		var age *int64
+		if !t.IsZero() {
+			ms := now.Sub(t).Milliseconds()
+			age = &ms
+		}
+		ages[stream] = age
+		if !connected || age == nil || (limit > 0 && *age > int64(limit)) {
+			stale = append(stale, stream)
+		}
+	}
+	sort.Strings(stale)
+
+	f := Freshness{
+		OdomAgeMs:     ages[StreamOdom],
+		VelocityAgeMs: ages[StreamVelocity],
+		TFAgeMs:       ages[StreamTF],
+		LaserAgeMs:    ages[StreamLaser],
+		MapAgeMs:      ages[StreamMap],
+		Stale:         stale,
+		DataStale:     !connected,
+	}
+	for _, s := range dataStaleStreams {
+		if f.IsStale(s) {
+			f.DataStale = true
+		}
+	}
+	return f
+}
+
+// freshnessLocked judges the robot's streams now.
+func (r *Robot) freshnessLocked() Freshness {
+	var thresholds map[string]int
+	if r.StaleThresholds != nil {
+		thresholds = r.StaleThresholds()
+	}
+	return freshness(r.connected, map[string]time.Time{
+		StreamOdom:     r.lastOdomTime,
+		StreamVelocity: r.lastVelocityTime,
+		StreamTF:       r.lastTFTime,
+		StreamLaser:    r.lastLaserTime,
+		StreamMap:      r.lastMapTime,
+	}, thresholds, time.Now())
+}
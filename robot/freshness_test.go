@@ -0,0 +1,78 @@
+package robot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFreshness(t *testing.T) {
+	now := time.Now()
+	ago := func(ms int) time.Time { return now.Add(-time.Duration(ms) * time.Millisecond) }
+	received := func(odom, vel, tf, laser, mapT time.Time) map[string]time.Time {
+		return map[string]time.Time{StreamOdom: odom, StreamVelocity: vel, StreamTF: tf, StreamLaser: laser, StreamMap: mapT}
+	}
+	fresh := received(ago(100), ago(100), ago(100), ago(100), ago(60_000))
+
+	for _, c := range []struct {
+		name       string
+		connected  bool
+		received   map[string]time.Time
+		thresholds map[string]int
+		stale      string
+		dataStale  bool
+	}{
+		{"all fresh, latched map", true, fresh, nil, "", false},
+		{"at the threshold", true, received(ago(2000), ago(100), ago(100), ago(100), ago(1)), nil, "", false},
+		{"odometry past it", true, received(ago(2001), ago(100), ago(100), ago(100), ago(1)), nil, "odom", true},
+		{"tf past it", true, received(ago(100), ago(100), ago(5000), ago(100), ago(1)), nil, "tf", true},
+		{"velocity and scan only", true, received(ago(100), ago(5000), ago(100), ago(5000), ago(1)), nil, "laser,velocity", false},
+		{"never received", true, received(ago(100), time.Time{}, ago(100), ago(100), time.Time{}), nil, "map,velocity", false},
+		{"disconnected", false, fresh, nil, "laser,map,odom,tf,velocity", true},
+		{"lowered threshold", true, fresh, map[string]int{StreamOdom: 50}, "odom", true},
+		{"raised threshold", true, received(ago(2500), ago(100), ago(100), ago(100), ago(1)), map[string]int{StreamOdom: 3000}, "", false},
+		{"threshold 0", true, received(ago(100), ago(100), ago(100), ago(600_000), ago(1)), map[string]int{StreamLaser: 0}, "", false},
+		{"map threshold", true, fresh, map[string]int{StreamMap: 30_000}, "map", false},
+	} {
+		f := freshness(c.connected, c.received, c.thresholds, now)
+		if got := strings.Join(f.Stale, ","); got != c.stale || f.DataStale != c.dataStale {
+			t.Errorf("%s: stale %q data_stale %v, want %q %v", c.name, got, f.DataStale, c.stale, c.dataStale)
+		}
+	}
+
+	f := freshness(true, received(ago(1500), time.Time{}, ago(100), ago(100), ago(1)), nil, now)
+	if f.OdomAgeMs == nil || *f.OdomAgeMs != 1500 || f.VelocityAgeMs != nil || f.TFAgeMs == nil || *f.TFAgeMs != 100 {
+		t.Errorf("ages: odom %v velocity %v tf %v", f.OdomAgeMs, f.VelocityAgeMs, f.TFAgeMs)
+	}
+	if f.Stale == nil {
+		t.Error("nil stale list; the JSON must be []")
+	}
+}
+
+// TestSnapshotFreshness checks snapshots read the thresholds at every call,
+// so a reloaded value applies at once.
+func TestSnapshotFreshness(t *testing.T) {
+	m := NewManager()
+	limit := 2000
+	m.StaleThresholds = func() map[string]int { return map[string]int{StreamOdom: limit} }
+	r, _ := m.AddRobot("", "fresh", "127.0.0.1", 9)
+	defer r.Close()
+
+	snap := r.GetSnapshot()
+	if !snap.DataStale || !snap.IsStale(StreamOdom) || snap.OdomAgeMs != nil {
+		t.Errorf("new, disconnected robot: %+v", snap.Freshness)
+	}
+
+	r.mu.Lock()
+	r.connected = true
+	now := time.Now()
+	r.lastOdomTime, r.lastTFTime = now.Add(-1500*time.Millisecond), now
+	r.mu.Unlock()
+	if snap := r.GetSnapshot(); snap.IsStale(StreamOdom) || snap.DataStale {
+		t.Errorf("odometry at 1.5 s under 2 s: %+v", snap.Freshness)
+	}
+	limit = 1000
+	if snap := r.GetSnapshot(); !snap.IsStale(StreamOdom) || !snap.DataStale {
+		t.Errorf("odometry at 1.5 s over a reloaded 1 s: %+v", snap.Freshness)
+	}
+}
@@ -0,0 +1,101 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Go-all sanity check
+//
+// A go-all started while the robot is localized on the wrong map, or far
+// from where the points were taught, drives it through the walls of a
+// stale costmap. Before triggering one, the robot's current map pose is
+// compared with the first point of the collection, and the collection
+// must be the one last sent to the robot (see nav_sync.go); force skips
+// the sync, distance and pose checks, never the empty-collection one.
+
+// DefaultGoAllMaxDistanceM is the default distance from the robot to the
+// first point beyond which a go-all is refused.
+const DefaultGoAllMaxDistanceM = 50.0
+
+// DefaultGoAllPoseMaxAge is how recent the robot pose must be for the
+// check unless configured otherwise.
+const DefaultGoAllPoseMaxAge = 3 * time.Second
+
+// ErrNoPoints is returned when a go-all targets an empty collection.
+var ErrNoPoints = errors.New("no points to visit")
+
+// GoAllRefusedError explains why a go-all was refused; force=true
+// overrides it.
+type GoAllRefusedError struct {
+	PointType rosbridge.PointType
+	Point     string  // first point of the collection
+	DistanceM float64 // robot → first point; 0 when Err is set
+	MaxM      float64
+	Err       error // pose error (ErrPoseStale), if that was the reason
+}
+
+func (e *GoAllRefusedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("refusing to go to all %ss: %v (pass force=true to override)", e.PointType, e.Err)
+	}
+	return fmt.Sprintf("refusing to go to all %ss: robot is %.1f m from first point %q, more than %.0f m; "+
+		"is it localized on the right map? (pass force=true to override)", e.PointType, e.DistanceM, e.Point, e.MaxM)
+}
+
+func (e *GoAllRefusedError) Unwrap() error { return e.Err }
+
+// CheckGoAll checks that a go-all of pointType may start: the collection
+// is not empty and, unless force, it was sent to the robot unchanged and
+// the robot has a fresh map pose within GoAllMaxDistanceM of the first
+// point.
+func (nm *NavigationManager) CheckGoAll(rb *Robot, pointType rosbridge.PointType, force bool) error {
+	rb.mu.RLock()
+	coll := rb.pointCollection(pointType)
+	var first rosbridge.NavigationPoint
+	n := 0
+	if coll != nil {
+		n = len(*coll)
+		if n > 0 {
+			first = (*coll)[0]
+		}
+	}
+	rb.mu.RUnlock()
+
+	if coll == nil {
+		return invalidPointType(pointType)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: no %ss on this robot", ErrNoPoints, pointType)
+	}
+	if force {
+		return nil
+	}
+	if err := rb.checkSynced(pointType); err != nil {
+		return err
+	}
+
+	maxAge := nm.GoAllPoseMaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultGoAllPoseMaxAge
+	}
+	pose, _, poseErr := rb.CurrentMapPose(maxAge)
+	return checkGoAllStart(pointType, pose, poseErr, first, nm.GoAllMaxDistanceM)
+}
+
+// checkGoAllStart is the distance/staleness decision; maxM <= 0 disables
+// the distance limit but still requires a pose.
+func checkGoAllStart(pointType rosbridge.PointType, pose rosbridge.Pose2D, poseErr error, first rosbridge.NavigationPoint, maxM float64) error {
+	if poseErr != nil {
+		return &GoAllRefusedError{PointType: pointType, Point: first.Name, MaxM: maxM, Err: poseErr}
+	}
+	d := math.Hypot(first.WorldXM-pose.X, first.WorldYM-pose.Y)
+	if maxM > 0 && d > maxM {
+		return &GoAllRefusedError{PointType: pointType, Point: first.Name, DistanceM: d, MaxM: maxM}
+	}
+	return nil
+}
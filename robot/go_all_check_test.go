@@ -0,0 +1,111 @@
+package robot
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+func TestCheckGoAllStart(t *testing.T) {
+	first := rosbridge.NavigationPoint{Name: "dock", WorldXM: 3, WorldYM: 4}
+	for _, tc := range []struct {
+		name    string
+		pose    rosbridge.Pose2D
+		poseErr error
+		maxM    float64
+		refused bool
+		dist    float64
+	}{
+		{"on the point", rosbridge.Pose2D{X: 3, Y: 4}, nil, 50, false, 0},
+		{"at the limit", rosbridge.Pose2D{}, nil, 5, false, 0},
+		{"just beyond", rosbridge.Pose2D{X: -0.01}, nil, 5, true, math.Hypot(3.01, 4)},
+		{"far", rosbridge.Pose2D{X: 3, Y: -96}, nil, 50, true, 100},
+		{"no limit", rosbridge.Pose2D{X: 1e6}, nil, 0, false, 0},
+		{"stale pose", rosbridge.Pose2D{X: 3, Y: 4}, ErrPoseStale, 50, true, 0},
+		{"stale pose, no limit", rosbridge.Pose2D{}, ErrPoseStale, 0, true, 0},
+	} {
+		err := checkGoAllStart(rosbridge.PointWaypoint, tc.pose, tc.poseErr, first, tc.maxM)
+		var refused *GoAllRefusedError
+		if errors.As(err, &refused) != tc.refused {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if refused == nil {
+			continue
+		}
+		if math.Abs(refused.DistanceM-tc.dist) > 1e-9 || refused.Point != "dock" || refused.MaxM != tc.maxM {
+			t.Errorf("%s: %+v, want distance %.3f", tc.name, refused, tc.dist)
+		}
+		if errors.Is(err, ErrPoseStale) != (tc.poseErr != nil) {
+			t.Errorf("%s: %v doesn't wrap the pose error", tc.name, err)
+		}
+	}
+}
+
+func TestCheckGoAll(t *testing.T) {
+	nm := NewNavigationManager()
+	nm.GoAllMaxDistanceM = 10
+	nm.GoAllPoseMaxAge = time.Second
+	rb := NewRobot("1", "", "test", "127.0.0.1", 9)
+
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, true); !errors.Is(err, ErrNoPoints) {
+		t.Errorf("empty collection, forced: %v", err)
+	}
+	if err := nm.CheckGoAll(rb, rosbridge.PointType("wall"), true); !errors.Is(err, rosbridge.ErrInvalidPointType) {
+		t.Errorf("walls: %v", err)
+	}
+
+	// Sent to the robot, with the first point 5 m away
+	rb.mu.Lock()
+	rb.Waypoints = []rosbridge.NavigationPoint{{Name: "a", WorldXM: 5}, {Name: "b", WorldXM: 50}}
+	hash, _ := rb.collectionHash(rosbridge.PointWaypoint)
+	rb.mu.Unlock()
+	rb.markSynced(rosbridge.PointWaypoint, hash)
+
+	setPose := func(x float64, age time.Duration) {
+		rb.mu.Lock()
+		rb.MapBfp = rosbridge.Pose2D{X: x}
+		rb.lastMapBfpTime = time.Now().Add(-age)
+		rb.mu.Unlock()
+	}
+	var refused *GoAllRefusedError
+
+	setPose(0, 0)
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, false); err != nil {
+		t.Errorf("near, fresh pose: %v", err)
+	}
+	setPose(-6, 0)
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, false); !errors.As(err, &refused) || refused.DistanceM != 11 {
+		t.Errorf("far: %v", err)
+	}
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, true); err != nil {
+		t.Errorf("far, forced: %v", err)
+	}
+	setPose(0, 2*time.Second)
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, false); !errors.Is(err, ErrPoseStale) {
+		t.Errorf("stale pose: %v", err)
+	}
+
+	// A fresh TF stands in for a stale map_bfp
+	rb.mu.Lock()
+	rb.TF = rosbridge.TFData{MapOdomTx: 4, MapOdomRw: 1, BfpTx: 2}
+	rb.TFReceived, rb.lastTFTime = true, time.Now()
+	rb.mu.Unlock()
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, false); err != nil {
+		t.Errorf("fresh TF at 6 m: %v", err)
+	}
+
+	// Changed since it was sent
+	rb.mu.Lock()
+	rb.Waypoints[0].WorldXM = 5.5
+	rb.mu.Unlock()
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, false); !errors.Is(err, ErrUnsynced) {
+		t.Errorf("unsynced: %v", err)
+	}
+	if err := nm.CheckGoAll(rb, rosbridge.PointWaypoint, true); err != nil {
+		t.Errorf("unsynced, forced: %v", err)
+	}
+}
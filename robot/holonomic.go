@@ -0,0 +1,50 @@
+package robot
+
+import (
+	"math"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Holonomic drive
+//
+// Mecanum and omni-wheel bases can strafe, so their cmd_vel carries a
+// lateral velocity (linear.y). Joystick input has a y axis for them; on
+// any other robot it is zeroed before it reaches cmd_vel. The flag is a
+// robot setting, switched on when the robot lists the "holonomic"
+// capability. Lateral input is scaled by the linear ratio, and the
+// planar speed (x and y together) is clamped to the linear limit.
+
+// Holonomic reports whether lateral velocity is passed to the robot.
+func (r *Robot) Holonomic() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.holonomic
+}
+
+// SetHolonomic turns lateral velocity on or off.
+func (r *Robot) SetHolonomic(enabled bool) {
+	r.mu.Lock()
+	r.holonomic = enabled
+	r.mu.Unlock()
+}
+
+// holonomicFromCapabilities turns the flag on when caps list it (caller
+// holds r.mu). Robots that don't say are left as configured.
+func (r *Robot) holonomicFromCapabilities(caps Capabilities) {
+	if caps.Lists(rosbridge.CapHolonomic) {
+		r.holonomic = true
+	}
+}
+
+// clampPlanar scales (x, y) down to length limit, keeping its direction;
+// a non-positive limit disables it.
+func clampPlanar(x, y, limit float64) (float64, float64) {
+	if limit <= 0 {
+		return x, y
+	}
+	if n := math.Hypot(x, y); n > limit {
+		return x * limit / n, y * limit / n
+	}
+	return x, y
+}
@@ -0,0 +1,110 @@
+package robot
+
+import (
+	"math"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+// TestNonHolonomicIgnoresY checks lateral input never reaches cmd_vel on
+// a robot that can't strafe, through SetVelocity and Drive alike.
+func TestNonHolonomicIgnoresY(t *testing.T) {
+	r := NewRobot("1", "", "diff", "127.0.0.1", 9090)
+	defer r.Close()
+	if r.Holonomic() {
+		t.Fatal("holonomic by default")
+	}
+
+	r.SetVelocity(0.2, 0.5, 0.1)
+	if got := r.Client.DesiredCmdVel(); got.LinearX != 0.2 || got.LinearY != 0 || got.AngularZ != 0.1 {
+		t.Errorf("SetVelocity: %+v, want y dropped", got)
+	}
+
+	// Lateral input alone is no input: no session, nothing sent
+	driver := Driver{ID: "c1"}
+	r.SetVelocity(0, 0, 0)
+	if err := r.Drive(driver, 0, 0.5, 0, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Client.DesiredCmdVel(); got != (rosbridge.TwistData{}) {
+		t.Errorf("Drive, lateral only: %+v", got)
+	}
+	if m := r.ManualDriver(); m != nil {
+		t.Errorf("lateral input opened a session: %+v", m)
+	}
+
+	if err := r.Drive(driver, 0.3, 0.5, 0, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Client.DesiredCmdVel(); got.LinearX != 0.3 || got.LinearY != 0 {
+		t.Errorf("Drive: %+v, want y dropped", got)
+	}
+	if m := r.ManualDriver(); m == nil || m.LinearY != 0 {
+		t.Errorf("session echo %+v, want linear_y 0", m)
+	}
+	r.stopTeleop()
+}
+
+func TestHolonomicDrive(t *testing.T) {
+	r := NewRobot("1", "", "mecanum", "127.0.0.1", 9090)
+	defer r.Close()
+	r.SetHolonomic(true)
+
+	r.SetVelocity(0.6, 0.8, 0)
+	if got := r.Client.DesiredCmdVel(); got.LinearX != 0.6 || got.LinearY != 0.8 {
+		t.Errorf("as sent: %+v", got)
+	}
+
+	// The linear ratio scales y too; the limit caps the planar speed
+	if err := r.SetVelRatios(0.5, 1); err != nil {
+		t.Fatal(err)
+	}
+	r.SetVelocity(0.6, 0.8, 0)
+	if got := r.Client.DesiredCmdVel(); !near(got.LinearX, 0.3) || !near(got.LinearY, 0.4) {
+		t.Errorf("ratio 0.5: %+v", got)
+	}
+	r.SetVelRatios(1, 1)
+	r.SetMaxVelocities(1, 1)
+	r.SetVelocity(0.9, 0.9, 0)
+	if got := r.Client.DesiredCmdVel(); !near(got.LinearX, math.Sqrt2/2) || !near(got.LinearY, math.Sqrt2/2) {
+		t.Errorf("diagonal at 1 m/s: %+v", got)
+	}
+
+	if err := r.Drive(Driver{ID: "c1"}, 0, -0.4, 0, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if m := r.ManualDriver(); m == nil || m.LinearY != -0.4 {
+		t.Errorf("strafing session %+v", m)
+	}
+	r.stopTeleop()
+}
+
+func TestHolonomicFromCapabilities(t *testing.T) {
+	r := NewRobot("1", "", "caps", "127.0.0.1", 9090)
+	defer r.Close()
+	r.holonomicFromCapabilities(Capabilities{Capabilities: []string{"navigation"}})
+	if r.Holonomic() {
+		t.Error("turned on without the capability")
+	}
+	r.holonomicFromCapabilities(Capabilities{Capabilities: []string{rosbridge.CapHolonomic}})
+	if !r.Holonomic() {
+		t.Error("not turned on by the capability")
+	}
+}
+
+func TestClampPlanar(t *testing.T) {
+	for _, c := range []struct{ x, y, limit, wantX, wantY float64 }{
+		{0.3, 0.4, 1, 0.3, 0.4},
+		{3, 4, 1, 0.6, 0.8},
+		{-3, 4, 0.5, -0.3, 0.4},
+		{3, 4, 0, 3, 4}, // no limit
+		{0, 0, 1, 0, 0},
+	} {
+		if x, y := clampPlanar(c.x, c.y, c.limit); !near(x, c.wantX) || !near(y, c.wantY) {
+			t.Errorf("clampPlanar(%v, %v, %v) = %v, %v", c.x, c.y, c.limit, x, y)
+		}
+	}
+}
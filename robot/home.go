@@ -0,0 +1,172 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Home position
+//
+// A robot may have a home pose (its dock or charging spot), valid on one
+// map. Going home sends that pose as a single navigation goal, and only
+// while the robot is on the home's map. As with patrol laps, the trip
+// follows the first new goal the action status reports and ends when it
+// reaches a terminal state; each step is announced as a HomeEvent.
+
+// Home errors.
+var (
+	ErrNoHome       = errors.New("no home position set")
+	ErrHomeWrongMap = errors.New("robot is not on its home map")
+)
+
+// Home events; a finished trip reports the goal's terminal state
+// (succeeded, canceled or aborted) instead.
+const (
+	HomeEventStarted    = "started"
+	HomeEventSuperseded = "superseded" // another goal replaced the trip's
+)
+
+// HomePose is a map-frame pose and the map it is valid on.
+type HomePose struct {
+	rosbridge.Pose2D
+	Map string `json:"map"`
+}
+
+// Validate checks that the pose is finite and names a map.
+func (h HomePose) Validate() error {
+	for _, v := range []float64{h.X, h.Y, h.Theta} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("home pose must be finite")
+		}
+	}
+	if h.Map == "" {
+		return fmt.Errorf("home needs a map name")
+	}
+	return nil
+}
+
+// HomeEvent reports a go-home trip.
+type HomeEvent struct {
+	Event string   `json:"event"`
+	Home  HomePose `json:"home"`
+}
+
+// homeTrip is a go-home in progress (guarded by Robot.mu).
+type homeTrip struct {
+	home     HomePose
+	prevGoal string // newest goal before ours was sent
+	goal     string // ours, once reported
+}
+
+// SetHome sets the home pose; nil clears it.
+func (r *Robot) SetHome(h *HomePose) error {
+	if h != nil {
+		if err := h.Validate(); err != nil {
+			return err
+		}
+		c := *h
+		h = &c
+	}
+	r.mu.Lock()
+	r.home = h
+	r.mu.Unlock()
+	r.emitConfig()
+	return nil
+}
+
+// SetHomeHere makes the robot's current map pose (no older than maxAge)
+// on its current map the home.
+func (r *Robot) SetHomeHere(maxAge time.Duration) (HomePose, error) {
+	mapName := r.CurrentMap()
+	if mapName == "" {
+		return HomePose{}, fmt.Errorf("robot has no current map")
+	}
+	pose, _, err := r.CurrentMapPose(maxAge)
+	if err != nil {
+		return HomePose{}, err
+	}
+	h := HomePose{Pose2D: pose, Map: mapName}
+	return h, r.SetHome(&h)
+}
+
+// GetHome returns a copy of the home pose, or nil.
+func (r *Robot) GetHome() *HomePose {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.home.clone()
+}
+
+func (h *HomePose) clone() *HomePose {
+	if h == nil {
+		return nil
+	}
+	c := *h
+	return &c
+}
+
+// GoHome sends the robot to its home pose. It fails with ErrNoHome,
+// ErrHomeWrongMap, ErrEStopped or ErrNotConnected before anything is
+// sent.
+func (r *Robot) GoHome() (HomePose, error) {
+	r.mu.RLock()
+	home, client := r.home.clone(), r.Client
+	estop, connected, mapName := r.estop, r.connected, r.currentMap
+	prevGoal := r.navStatus.GoalID
+	r.mu.RUnlock()
+
+	switch {
+	case home == nil:
+		return HomePose{}, ErrNoHome
+	case mapName != home.Map:
+		return HomePose{}, fmt.Errorf("%w: home is on %q, current map is %q", ErrHomeWrongMap, home.Map, mapName)
+	case estop:
+		return HomePose{}, ErrEStopped
+	case !connected || client == nil:
+		return HomePose{}, ErrNotConnected
+	}
+	if err := client.SendNavGoal(home.Pose2D); err != nil {
+		return HomePose{}, err
+	}
+
+	r.mu.Lock()
+	r.homeTrip = &homeTrip{home: *home, prevGoal: prevGoal}
+	r.mu.Unlock()
+	r.emitHome(HomeEvent{Event: HomeEventStarted, Home: *home})
+	return *home, nil
+}
+
+// trackHome follows the go-home goal through the action status.
+func (r *Robot) trackHome(s rosbridge.NavStatus) {
+	r.mu.Lock()
+	t := r.homeTrip
+	if t == nil || (t.goal == "" && (s.GoalID == "" || s.GoalID == t.prevGoal)) {
+		r.mu.Unlock()
+		return
+	}
+	if t.goal == "" {
+		t.goal = s.GoalID
+	}
+	ev := HomeEvent{Home: t.home}
+	switch {
+	case s.GoalID != t.goal:
+		ev.Event = HomeEventSuperseded
+	case s.Terminal():
+		ev.Event = s.State
+	default:
+		r.mu.Unlock()
+		return
+	}
+	r.homeTrip = nil
+	r.mu.Unlock()
+	r.emitHome(ev)
+}
+
+func (r *Robot) emitHome(ev HomeEvent) {
+	if r.OnHome != nil {
+		r.OnHome(ev)
+	}
+}
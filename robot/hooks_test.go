@@ -0,0 +1,243 @@
+package robot
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// rosbridgeStub records the ops it receives, per connection, answers
+// service calls with an empty result, and publishes to its clients.
+type rosbridgeStub struct {
+	srv *httptest.Server
+
+	mu    sync.Mutex
+	conns int
+	ops   []stubOp
+	live  []*websocket.Conn
+
+	writeMu sync.Mutex // one writer per connection at a time
+}
+
+type stubOp struct {
+	Conn    int
+	Op      string `json:"op"`
+	ID      string `json:"id"`
+	Topic   string `json:"topic"`
+	Service string `json:"service"`
+}
+
+func newRosbridgeStub(t *testing.T) *rosbridgeStub {
+	t.Helper()
+	s := &rosbridgeStub{}
+	up := websocket.Upgrader{}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.mu.Lock()
+		idx := s.conns
+		s.conns++
+		s.live = append(s.live, conn)
+		s.mu.Unlock()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			op := stubOp{Conn: idx}
+			json.Unmarshal(data, &op)
+			s.mu.Lock()
+			s.ops = append(s.ops, op)
+			s.mu.Unlock()
+			if op.Op == "call_service" {
+				s.writeMu.Lock()
+				conn.WriteJSON(map[string]interface{}{"op": "service_response", "id": op.ID, "values": map[string]interface{}{}, "result": true})
+				s.writeMu.Unlock()
+			}
+		}
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *rosbridgeStub) addr(t *testing.T) (string, int) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(s.srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+// publish sends a raw message on topic to every client connected.
+func (s *rosbridgeStub) publish(topic string, msg string) {
+	s.mu.Lock()
+	conns := append([]*websocket.Conn(nil), s.live...)
+	s.mu.Unlock()
+	frame := `{"op":"publish","topic":"` + topic + `","msg":` + msg + `}`
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	for _, c := range conns {
+		c.WriteMessage(websocket.TextMessage, []byte(frame))
+	}
+}
+
+// subscribed reports whether the last subscribe or unsubscribe of topic
+// was a subscribe.
+func (s *rosbridgeStub) subscribed(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	on := false
+	for _, o := range s.ops {
+		if o.Topic == topic && (o.Op == "subscribe" || o.Op == "unsubscribe") {
+			on = o.Op == "subscribe"
+		}
+	}
+	return on
+}
+
+// perConn counts ops of kind op by connection and topic.
+func (s *rosbridgeStub) perConn(op string) map[int]map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[int]map[string]int{}
+	for _, o := range s.ops {
+		if o.Op != op {
+			continue
+		}
+		if out[o.Conn] == nil {
+			out[o.Conn] = map[string]int{}
+		}
+		out[o.Conn][o.Topic]++
+	}
+	return out
+}
+
+// broadcastCounter counts the manager's broadcasts by type.
+type broadcastCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	seen   chan string
+}
+
+func countBroadcasts(t *testing.T, m *Manager) *broadcastCounter {
+	ch := m.Subscribe()
+	b := &broadcastCounter{counts: map[string]int{}, seen: make(chan string, 1000)}
+	go func() {
+		for msg := range ch {
+			b.mu.Lock()
+			b.counts[msg.Type]++
+			b.mu.Unlock()
+			b.seen <- msg.Type
+		}
+	}()
+	t.Cleanup(func() { m.Unsubscribe(ch) })
+	return b
+}
+
+func (b *broadcastCounter) count(typ string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.counts[typ]
+}
+
+// await waits for the next broadcast of typ, then a little longer so a
+// duplicate would have arrived too.
+func (b *broadcastCounter) await(t *testing.T, typ string) {
+	t.Helper()
+	deadline := time.After(3 * time.Second)
+	for {
+		select {
+		case got := <-b.seen:
+			if got == typ {
+				time.Sleep(100 * time.Millisecond)
+				return
+			}
+		case <-deadline:
+			t.Fatalf("no %s broadcast", typ)
+		}
+	}
+}
+
+// TestConnectHooksFireOnce checks that NewRobot's state update and the
+// manager's broadcast each run exactly once per connect and disconnect,
+// including alongside a legacy OnConnected/OnDisconnected callback.
+func TestConnectHooksFireOnce(t *testing.T) {
+	stub := newRosbridgeStub(t)
+	host, port := stub.addr(t)
+	m := NewManager()
+	bc := countBroadcasts(t, m)
+	r, err := m.AddRobot("", "hooks", host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var legacyUp, legacyDown, addedUp atomic.Int32
+	r.Client.OnConnected = func() { legacyUp.Add(1) }
+	r.Client.OnDisconnected = func() { legacyDown.Add(1) }
+	r.Client.AddConnectHandler(func() { addedUp.Add(1) })
+
+	for round := 1; round <= 2; round++ {
+		if err := r.Client.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		bc.await(t, "robot_connected")
+		if got := bc.count("robot_connected"); got != round {
+			t.Errorf("round %d: %d robot_connected broadcasts", round, got)
+		}
+		if !r.GetSnapshot().Connected || r.GetActivity().ConnectedAt.IsZero() {
+			t.Errorf("round %d: robot state not updated on connect", round)
+		}
+		if got := r.GetSnapshot().ConnectPhase.Phase; got != PhaseConnected {
+			t.Errorf("round %d: phase = %q", round, got)
+		}
+		if legacyUp.Load() != int32(round) || addedUp.Load() != int32(round) {
+			t.Errorf("round %d: legacy callback ran %d times, added handler %d", round, legacyUp.Load(), addedUp.Load())
+		}
+
+		// SubscribeAllTopics and the cmd_vel advertise ran once on
+		// this connection
+		subs := stub.perConn("subscribe")[round-1]
+		if len(subs) == 0 {
+			t.Fatalf("round %d: no subscriptions", round)
+		}
+		for topic, n := range subs {
+			if n != 1 {
+				t.Errorf("round %d: %s subscribed %d times", round, topic, n)
+			}
+		}
+		for topic, n := range stub.perConn("advertise")[round-1] {
+			if n != 1 {
+				t.Errorf("round %d: %s advertised %d times", round, topic, n)
+			}
+		}
+
+		r.Client.Disconnect()
+		bc.await(t, "robot_disconnected")
+		if got := bc.count("robot_disconnected"); got != round {
+			t.Errorf("round %d: %d robot_disconnected broadcasts", round, got)
+		}
+		if r.GetSnapshot().Connected {
+			t.Errorf("round %d: robot still connected", round)
+		}
+		if got := r.GetSnapshot().ConnectPhase.Phase; got != PhaseDisconnected {
+			t.Errorf("round %d: phase = %q", round, got)
+		}
+		if legacyDown.Load() != int32(round) {
+			t.Errorf("round %d: legacy disconnect callback ran %d times", round, legacyDown.Load())
+		}
+	}
+}
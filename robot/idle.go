@@ -0,0 +1,193 @@
+package robot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Idle policy
+//
+// Robots nobody uses don't need their map and scans streamed. A robot is
+// in use while it is the current robot, a browser watches it (the WS
+// "watch" command), a mapping session or map save runs on it, or an
+// HTTP request or WS command for it arrived within IdleOptions.After.
+// Otherwise the manager switches its client to the idle subscription set
+// (rosbridge.IdleTopics); the connection stays up. Any use wakes it and
+// the full set is subscribed again at once.
+
+// IdleCheckInterval is how often RunIdle looks for unused robots.
+const IdleCheckInterval = 15 * time.Second
+
+// Activity states.
+const (
+	ActivityActive = "active"
+	ActivityIdle   = "idle"
+)
+
+// IdleOptions configure the idle policy.
+type IdleOptions struct {
+	After  time.Duration // unused this long goes idle; 0 disables idling
+	Topics rosbridge.IdleTopics
+}
+
+// ActivityState is the "robot_activity" payload.
+type ActivityState struct {
+	State     string     `json:"state"` // active or idle
+	IdleSince *time.Time `json:"idle_since,omitempty"`
+}
+
+// GetActivityState returns whether the robot is active or idle.
+func (r *Robot) GetActivityState() ActivityState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.activityLocked()
+}
+
+func (r *Robot) activityLocked() ActivityState {
+	if r.idleSince.IsZero() {
+		return ActivityState{State: ActivityActive}
+	}
+	since := r.idleSince
+	return ActivityState{State: ActivityIdle, IdleSince: &since}
+}
+
+// Touch records that the robot is in use and wakes it if idle.
+func (r *Robot) Touch() {
+	r.idleMu.Lock()
+	r.mu.Lock()
+	r.lastUsed = time.Now()
+	wasIdle := !r.idleSince.IsZero()
+	r.idleSince = time.Time{}
+	r.mu.Unlock()
+	if wasIdle {
+		r.Client.SetIdle(false, rosbridge.IdleTopics{})
+	}
+	r.idleMu.Unlock()
+
+	if wasIdle {
+		log.Printf("[robot %s] Active again; full subscriptions restored", r.ID)
+		r.emitActivity()
+	}
+}
+
+// goIdle switches the robot to the idle subscription set. Already idle
+// robots only pick up changed topics.
+func (r *Robot) goIdle(topics rosbridge.IdleTopics) {
+	r.idleMu.Lock()
+	r.mu.Lock()
+	wasIdle := !r.idleSince.IsZero()
+	if !wasIdle {
+		r.idleSince = time.Now()
+	}
+	r.mu.Unlock()
+	r.Client.SetIdle(true, topics)
+	r.idleMu.Unlock()
+
+	if !wasIdle {
+		log.Printf("[robot %s] Idle; dropped %v", r.ID, topics.Drop)
+		r.emitActivity()
+	}
+}
+
+// lastUse is when the robot was last touched.
+func (r *Robot) lastUse() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastUsed
+}
+
+// busy reports whether the robot runs something that needs its map: a
+// mapping session or a map save.
+func (r *Robot) busy() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	active := r.mapping != nil && r.mapping.EndedAt == nil
+	return active || r.mapSaveInProgressLocked()
+}
+
+func (r *Robot) emitActivity() {
+	if r.OnActivity != nil {
+		r.OnActivity(r.GetActivityState())
+	}
+}
+
+// Watch marks robots as watched by a browser, waking them. Calls nest:
+// each Watch needs its own Unwatch.
+func (m *Manager) Watch(ids ...string) {
+	m.watchMu.Lock()
+	for _, id := range ids {
+		m.watchers[id]++
+	}
+	m.watchMu.Unlock()
+	for _, id := range ids {
+		if r := m.GetRobot(id); r != nil {
+			r.Touch()
+		}
+	}
+}
+
+// Unwatch undoes Watch. The robots stay active until IdleOptions.After
+// has passed.
+func (m *Manager) Unwatch(ids ...string) {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+	for _, id := range ids {
+		if m.watchers[id] > 1 {
+			m.watchers[id]--
+		} else {
+			delete(m.watchers, id)
+		}
+	}
+	for _, id := range ids {
+		if r := m.GetRobot(id); r != nil {
+			// Watching counts as use up to the moment it ends
+			r.mu.Lock()
+			r.lastUsed = time.Now()
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (m *Manager) watched(id string) bool {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+	return m.watchers[id] > 0
+}
+
+// RunIdle applies the idle policy every interval until ctx is cancelled.
+func (m *Manager) RunIdle(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		m.CheckIdle(time.Now())
+	}
+}
+
+// CheckIdle sends unused robots idle and wakes idle ones that are in use
+// again (the current robot after a removal, say). With idling disabled
+// every robot is woken.
+func (m *Manager) CheckIdle(now time.Time) {
+	var opts IdleOptions
+	if m.IdleOptions != nil {
+		opts = m.IdleOptions()
+	}
+	current := m.GetCurrentRobotID()
+	for _, r := range m.GetAllRobots() {
+		inUse := opts.After <= 0 || r.ID == current || m.watched(r.ID) || r.busy() ||
+			now.Sub(r.lastUse()) < opts.After
+		switch {
+		case !inUse:
+			r.goIdle(opts.Topics)
+		case r.GetActivityState().State == ActivityIdle:
+			r.Touch()
+		}
+	}
+}
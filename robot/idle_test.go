@@ -0,0 +1,138 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// activityEvents returns the robot_activity broadcasts received within a
+// short wait, as "<robot>:<state>".
+func activityEvents(ch chan BroadcastMsg) []string {
+	var got []string
+	timeout := time.After(50 * time.Millisecond)
+	for {
+		select {
+		case msg := <-ch:
+			if msg.Type == "robot_activity" {
+				got = append(got, msg.RobotID+":"+msg.Data.(ActivityState).State)
+			}
+		case <-timeout:
+			return got
+		}
+	}
+}
+
+func sameEvents(a []string, b ...string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIdleTransitions(t *testing.T) {
+	m := NewManager()
+	m.IdleOptions = func() IdleOptions { return IdleOptions{After: time.Minute, Topics: rosbridge.DefaultIdleTopics} }
+	a, _ := m.AddRobot("", "a", "127.0.0.1", 9001) // current
+	b, _ := m.AddRobot("", "b", "127.0.0.1", 9002)
+	defer a.Close()
+	defer b.Close()
+	ch := m.Subscribe()
+	defer m.Unsubscribe(ch)
+	now := time.Now()
+
+	// Recently used: both active
+	m.CheckIdle(now.Add(30 * time.Second))
+	if got := activityEvents(ch); len(got) != 0 {
+		t.Errorf("within IdleAfter: %v", got)
+	}
+
+	// Unused past IdleAfter: b idles, the current robot never does
+	m.CheckIdle(now.Add(2 * time.Minute))
+	if got := activityEvents(ch); !sameEvents(got, "2:idle") {
+		t.Errorf("past IdleAfter: %v", got)
+	}
+	st := b.GetActivityState()
+	if st.State != ActivityIdle || st.IdleSince == nil || !b.Client.Idle() || a.Client.Idle() {
+		t.Errorf("b %+v, clients idle a=%v b=%v", st, a.Client.Idle(), b.Client.Idle())
+	}
+	m.CheckIdle(now.Add(3 * time.Minute))
+	if got := activityEvents(ch); len(got) != 0 {
+		t.Errorf("already idle: %v", got)
+	}
+
+	// A request naming it wakes it
+	b.Touch()
+	if got := activityEvents(ch); !sameEvents(got, "2:active") || b.Client.Idle() || b.GetActivityState().IdleSince != nil {
+		t.Errorf("touched: %v, client idle %v", got, b.Client.Idle())
+	}
+	b.Touch()
+	if got := activityEvents(ch); len(got) != 0 {
+		t.Errorf("touched while active: %v", got)
+	}
+
+	// Watched: stays active however long; once unwatched, for IdleAfter
+	m.Watch(b.ID)
+	m.Watch(b.ID)
+	m.CheckIdle(time.Now().Add(time.Hour))
+	m.Unwatch(b.ID)
+	m.CheckIdle(time.Now().Add(time.Hour))
+	if got := activityEvents(ch); len(got) != 0 {
+		t.Errorf("watched: %v", got)
+	}
+	m.Unwatch(b.ID)
+	m.CheckIdle(time.Now().Add(30 * time.Second))
+	if got := activityEvents(ch); len(got) != 0 {
+		t.Errorf("just unwatched: %v", got)
+	}
+	m.CheckIdle(time.Now().Add(2 * time.Minute))
+	if got := activityEvents(ch); !sameEvents(got, "2:idle") {
+		t.Errorf("unwatched past IdleAfter: %v", got)
+	}
+
+	// Switching wakes the new current robot; the old one idles later
+	if err := m.SwitchRobot(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := activityEvents(ch); !sameEvents(got, "2:active") {
+		t.Errorf("switched: %v", got)
+	}
+	m.CheckIdle(time.Now().Add(2 * time.Minute))
+	if got := activityEvents(ch); !sameEvents(got, "1:idle") {
+		t.Errorf("previous current robot: %v", got)
+	}
+
+	// Idling disabled: every robot wakes
+	m.IdleOptions = func() IdleOptions { return IdleOptions{} }
+	m.CheckIdle(time.Now().Add(time.Hour))
+	if got := activityEvents(ch); !sameEvents(got, "1:active") || a.Client.Idle() {
+		t.Errorf("disabled: %v", got)
+	}
+}
+
+// TestIdleWakesSuccessor removes the current robot: the idle robot that
+// takes over is woken.
+func TestIdleWakesSuccessor(t *testing.T) {
+	m := NewManager()
+	m.IdleOptions = func() IdleOptions { return IdleOptions{After: time.Minute} }
+	a, _ := m.AddRobot("", "a", "127.0.0.1", 9001)
+	b, _ := m.AddRobot("", "b", "127.0.0.1", 9002)
+	defer b.Close()
+	m.CheckIdle(time.Now().Add(2 * time.Minute))
+	if b.GetActivityState().State != ActivityIdle {
+		t.Fatal("b not idle")
+	}
+	ch := m.Subscribe()
+	defer m.Unsubscribe(ch)
+
+	m.RemoveRobot(a.ID)
+	if got := activityEvents(ch); !sameEvents(got, "2:active") || b.Client.Idle() {
+		t.Errorf("successor: %v", got)
+	}
+}
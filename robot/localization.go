@@ -0,0 +1,192 @@
+package robot
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Localization quality
+//
+// The pose covariance grows when AMCL loses track of the robot. Its
+// position spread (√(var_x + var_y)) and heading spread (√var_yaw) are
+// graded good, fair or poor against the robot's thresholds, taken from
+// amcl_pose when that topic is configured and from odometry otherwise.
+// A grade gets worse as soon as a spread reaches a threshold and better
+// only once it is Hysteresis (a fraction) below it, so a pose hovering at
+// a threshold doesn't flap. Grade changes are reported through
+// OnLocalization.
+
+// Localization grades.
+const (
+	LocalizationUnknown = "unknown" // no covariance received yet
+	LocalizationGood    = "good"
+	LocalizationFair    = "fair"
+	LocalizationPoor    = "poor"
+)
+
+// Localization covariance sources.
+const (
+	LocalizationFromAMCL = "amcl_pose"
+	LocalizationFromOdom = "odom"
+)
+
+// LocalizationThresholds grade the pose spread; per robot because maps
+// and sensors have different baselines.
+type LocalizationThresholds struct {
+	FairXYM    float64 `json:"fair_xy_m"`    // position spread from which the grade is fair
+	PoorXYM    float64 `json:"poor_xy_m"`    // ... and poor
+	FairYawRad float64 `json:"fair_yaw_rad"` // heading spread from which the grade is fair
+	PoorYawRad float64 `json:"poor_yaw_rad"` // ... and poor
+	Hysteresis float64 `json:"hysteresis"`   // fraction below a threshold needed to improve
+}
+
+// DefaultLocalizationThresholds suit a well-mapped indoor site.
+var DefaultLocalizationThresholds = LocalizationThresholds{
+	FairXYM:    0.25,
+	PoorXYM:    0.5,
+	FairYawRad: 0.2,
+	PoorYawRad: 0.4,
+	Hysteresis: 0.2,
+}
+
+// Validate checks that the thresholds are positive and ordered.
+func (t LocalizationThresholds) Validate() error {
+	switch {
+	case t.FairXYM <= 0 || t.FairYawRad <= 0:
+		return errors.New("localization thresholds must be positive")
+	case t.PoorXYM <= t.FairXYM || t.PoorYawRad <= t.FairYawRad:
+		return errors.New("poor localization thresholds must exceed the fair ones")
+	case t.Hysteresis < 0 || t.Hysteresis >= 1:
+		return errors.New("localization hysteresis must be in [0, 1)")
+	}
+	return nil
+}
+
+// rank grades the spreads with the thresholds scaled by scale: 0 good,
+// 1 fair, 2 poor.
+func (t LocalizationThresholds) rank(xy, yaw, scale float64) int {
+	switch {
+	case xy >= t.PoorXYM*scale || yaw >= t.PoorYawRad*scale:
+		return 2
+	case xy >= t.FairXYM*scale || yaw >= t.FairYawRad*scale:
+		return 1
+	}
+	return 0
+}
+
+var localizationGrades = []string{LocalizationGood, LocalizationFair, LocalizationPoor}
+
+// grade returns the grade following prev for the given spreads.
+func (t LocalizationThresholds) grade(prev string, xy, yaw float64) string {
+	next := t.rank(xy, yaw, 1)
+	for i, g := range localizationGrades {
+		if g == prev && next < i {
+			// Improving: judge against the lowered thresholds
+			next = min(i, t.rank(xy, yaw, 1-t.Hysteresis))
+		}
+	}
+	return localizationGrades[next]
+}
+
+// Localization is the robot's localization quality.
+type Localization struct {
+	Quality    string                  `json:"quality"`            // good, fair, poor or unknown
+	Previous   string                  `json:"previous,omitempty"` // grade before the last change
+	Source     string                  `json:"source,omitempty"`   // amcl_pose or odom
+	StdXYM     float64                 `json:"std_xy_m"`
+	StdYawRad  float64                 `json:"std_yaw_rad"`
+	Variance   *rosbridge.PoseVariance `json:"variance,omitempty"`
+	Since      *time.Time              `json:"since,omitempty"`      // when Quality was reached
+	UpdatedAt  *time.Time              `json:"updated_at,omitempty"` // last covariance
+	Thresholds LocalizationThresholds  `json:"thresholds"`
+}
+
+// GetLocalization returns the robot's localization quality.
+func (r *Robot) GetLocalization() Localization {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.localizationLocked()
+}
+
+func (r *Robot) localizationLocked() Localization {
+	l := r.loc
+	if l.Quality == "" {
+		l.Quality = LocalizationUnknown
+	}
+	if l.Variance != nil {
+		v := *l.Variance
+		l.Variance = &v
+	}
+	l.Thresholds = r.locThresholds
+	return l
+}
+
+// SetLocalizationThresholds changes the grading thresholds; the next
+// covariance is graded with them.
+func (r *Robot) SetLocalizationThresholds(t LocalizationThresholds) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.locThresholds = t
+	r.mu.Unlock()
+	return nil
+}
+
+// LocalizationThresholds returns the grading thresholds.
+func (r *Robot) LocalizationThresholds() LocalizationThresholds {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.locThresholds
+}
+
+// SetAMCLPoseTopic grades amcl_pose instead of odometry; empty goes back
+// to odometry. Applies from the next (re)subscribe.
+func (r *Robot) SetAMCLPoseTopic(topic string) {
+	r.mu.Lock()
+	r.locAMCL = topic != ""
+	r.mu.Unlock()
+	r.Client.SetAMCLPoseTopic(topic)
+}
+
+// observeVariance grades a covariance from source and reports a change
+// of grade.
+func (r *Robot) observeVariance(source string, v *rosbridge.PoseVariance) {
+	if v == nil {
+		return
+	}
+	xy := math.Sqrt(math.Max(v.X+v.Y, 0))
+	yaw := math.Sqrt(math.Max(v.Yaw, 0))
+
+	r.mu.Lock()
+	if (source == LocalizationFromAMCL) != r.locAMCL {
+		r.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	prev := r.loc.Quality
+	quality := r.locThresholds.grade(prev, xy, yaw)
+	vc := *v
+	r.loc.Source = source
+	r.loc.StdXYM, r.loc.StdYawRad = xy, yaw
+	r.loc.Variance = &vc
+	r.loc.UpdatedAt = &now
+	changed := quality != prev
+	if changed {
+		r.loc.Quality, r.loc.Previous = quality, prev
+		if prev == "" {
+			r.loc.Previous = LocalizationUnknown
+		}
+		r.loc.Since = &now
+	}
+	l := r.localizationLocked()
+	r.mu.Unlock()
+
+	// Reaching good at the first covariance isn't worth an event
+	if changed && !(prev == "" && quality == LocalizationGood) && r.OnLocalization != nil {
+		r.OnLocalization(l)
+	}
+}
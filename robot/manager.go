@@ -5,6 +5,7 @@ import (
 	"log"
 	"rom_go_app/rosbridge"
 	"sync"
+	"time"
 )
 
 // Manager manages the lifecycle of multiple robots.
@@ -14,16 +15,155 @@ type Manager struct {
 	currentID string
 	nextID    int
 
-	// Subscriber channels for real-time broadcast
+	// Subscriber channels for real-time broadcast, and handlers that
+	// see every frame (see AddBroadcastHandler)
 	broadcastMu sync.RWMutex
 	subscribers map[chan BroadcastMsg]struct{}
+	handlers    map[int]func(BroadcastMsg)
+	nextHandler int
+
+	// Latest sequence number per frame stream (see frame_seq.go)
+	seqMu sync.Mutex
+	seqs  map[seqKey]uint64
+
+	// Robot clock skew warning threshold and jump size applied to new
+	// robots; zero disables either.
+	ClockSkewWarn time.Duration
+	ClockSkewJump time.Duration
+
+	// AutonomyGating makes new robots reject joystick input while they
+	// navigate, patrol or are manually locked.
+	AutonomyGating bool
+
+	// JoystickDeadman is the joystick silence that ends a manual control
+	// session (0: DefaultJoystickDeadman).
+	JoystickDeadman time.Duration
+
+	// ControlLeaseIdle ends a control lease whose holder stays inactive
+	// (0: DefaultControlLeaseIdle); ControlLeaseRequired refuses driving
+	// without the lease.
+	ControlLeaseIdle     time.Duration
+	ControlLeaseRequired bool
+
+	// TopicThrottles returns the robot-side throttle rates (ms by topic
+	// key) applied to new robots over rosbridge.DefaultThrottles; nil or
+	// returning nil keeps the defaults.
+	TopicThrottles func() map[string]int
+
+	// Thumbnails stores map previews; nil disables them.
+	Thumbnails *ThumbnailStore
+
+	// MapArchive keeps earlier versions of saved maps (see
+	// map_archive.go); nil disables it.
+	MapArchive *MapArchive
+
+	// Points persists navigation points (see point_store.go); nil
+	// disables it.
+	Points *PointStore
+
+	// Usage saves the robots' usage counters (see usage_stats.go); nil
+	// keeps them in memory only. UsageJumpM is the longest odometry step
+	// counted as travel.
+	Usage      *UsageStore
+	UsageJumpM float64
+
+	// Visits saves the robots' point visits (see visits.go); nil keeps
+	// them in memory only. A robot visits a point on coming within
+	// VisitRadiusM and leaves it beyond VisitRadiusM+VisitHysteresisM
+	// (the defaults when <= 0).
+	Visits           *VisitStore
+	VisitRadiusM     float64
+	VisitHysteresisM float64
+
+	// TaskDiscoveryRequest is the which_tasks request listing a robot's
+	// tasks (empty: "list_tasks"); StaticTasks are offered for robots
+	// that don't answer it.
+	TaskDiscoveryRequest string
+	StaticTasks          []rosbridge.TaskInfo
+
+	// CapabilitiesRequest is the which_tasks request asking robots whose
+	// handshake lacks capabilities for them (empty: "get_capabilities",
+	// NoCapabilitiesRequest: don't ask).
+	CapabilitiesRequest string
+
+	// ExpectedDowntime is how long an acknowledged reboot has to bring a
+	// robot back, and a poweroff to take it down (0: the default; see
+	// power_state.go).
+	ExpectedDowntime time.Duration
+
+	// MapSaveTimeout bounds a map save service call (0: the default);
+	// MapSaveProgressTopic is the topic robots report save percentage on,
+	// empty if they don't.
+	MapSaveTimeout       time.Duration
+	MapSaveProgressTopic string
+
+	// Mapping coverage counts as stabilized once it grows by less than
+	// MappingStableM2PerMin for MappingStableFor (0: the defaults).
+	MappingStableM2PerMin float64
+	MappingStableFor      time.Duration
+
+	// AMCLPoseTopic is the PoseWithCovarianceStamped topic new robots
+	// grade localization from; empty uses odometry.
+	AMCLPoseTopic string
+
+	// MarkerTopic is the std_msgs/String topic new robots flag incident
+	// markers on; empty if they don't.
+	MarkerTopic string
+
+	// StaleThresholds returns the snapshot staleness thresholds (ms by
+	// stream) overriding robot.DefaultStaleThresholds; nil uses those.
+	StaleThresholds func() map[string]int
+
+	// VelRatioBounds returns the range new robots accept velocity ratios
+	// in; nil or an invalid range uses DefaultVelRatioMin..Max.
+	VelRatioBounds func() RatioBounds
+
+	// SharedConnections makes new robots share one rosbridge connection
+	// per server (see rosbridge/shared.go).
+	SharedConnections bool
+
+	// IdleOptions returns the idle policy (see idle.go); nil disables
+	// idling.
+	IdleOptions func() IdleOptions
+
+	// DestructiveConfirm is how long the confirmation token of a
+	// destructive action stays valid (see destructive.go; 0:
+	// DefaultDestructiveConfirm).
+	DestructiveConfirm time.Duration
+
+	// Pending destructive actions by robot and action
+	destructiveMu sync.Mutex
+	destructive   map[destructiveKey]PendingAction
+
+	// Browser watch counts by robot ID (see Watch)
+	watchMu  sync.Mutex
+	watchers map[string]int
+
+	// Fleet proximity monitor state (see fleet_proximity.go)
+	proximityMu sync.Mutex
+	proximity   fleetProximity
+
+	// Recent user-visible notices (see notices.go)
+	noticesMu    sync.Mutex
+	notices      []Notice
+	lastNoticeID int64
 }
 
+// Default clock skew limits.
+const (
+	DefaultClockSkewWarn = 2 * time.Second
+	DefaultClockSkewJump = time.Second
+)
+
 // BroadcastMsg is sent to all WebSocket subscribers.
 type BroadcastMsg struct {
 	Type    string      `json:"type"`
 	RobotID string      `json:"robot_id"`
+	Seq     uint64      `json:"seq,omitempty"` // per robot and type (see frame_seq.go)
+	TS      int64       `json:"ts,omitempty"`  // server time, Unix ms
 	Data    interface{} `json:"data"`
+
+	enc *sharedEncoding // see broadcast_json.go
 }
 
 // NewManager creates a new robot manager.
@@ -32,6 +172,14 @@ func NewManager() *Manager {
 		robots:      make(map[string]*Robot),
 		nextID:      1,
 		subscribers: make(map[chan BroadcastMsg]struct{}),
+		watchers:    make(map[string]int),
+
+		ClockSkewWarn: DefaultClockSkewWarn,
+		ClockSkewJump: DefaultClockSkewJump,
+
+		AutonomyGating: true,
+
+		proximity: fleetProximity{opts: DefaultProximityOptions},
 	}
 }
 
@@ -52,10 +200,36 @@ func (m *Manager) Unsubscribe(ch chan BroadcastMsg) {
 	close(ch)
 }
 
-// Broadcast sends a message to all subscribers.
+// AddBroadcastHandler calls fn with every broadcast, before subscribers
+// get it, and returns the function removing it. Unlike a subscription it
+// misses nothing, so fn runs on the broadcasting goroutine, possibly
+// with manager or robot locks held: it must return quickly and not call
+// back into the manager.
+func (m *Manager) AddBroadcastHandler(fn func(BroadcastMsg)) (remove func()) {
+	m.broadcastMu.Lock()
+	defer m.broadcastMu.Unlock()
+	if m.handlers == nil {
+		m.handlers = make(map[int]func(BroadcastMsg))
+	}
+	id := m.nextHandler
+	m.nextHandler++
+	m.handlers[id] = fn
+	return func() {
+		m.broadcastMu.Lock()
+		delete(m.handlers, id)
+		m.broadcastMu.Unlock()
+	}
+}
+
+// Broadcast stamps a message and sends it to all subscribers.
 func (m *Manager) Broadcast(msg BroadcastMsg) {
+	m.stamp(&msg)
+	msg.enc = new(sharedEncoding)
 	m.broadcastMu.RLock()
 	defer m.broadcastMu.RUnlock()
+	for _, fn := range m.handlers {
+		fn(msg)
+	}
 	for ch := range m.subscribers {
 		select {
 		case ch <- msg:
@@ -70,91 +244,274 @@ func (m *Manager) AddRobot(ns, name, ip string, port int) (*Robot, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Check duplicate IP
+	// Robots behind one rosbridge server differ by namespace
 	for _, r := range m.robots {
-		if r.IP == ip && r.Port == port {
-			return nil, fmt.Errorf("robot at %s:%d already exists", ip, port)
+		if r.IP == ip && r.Port == port && SameNamespace(r.Namespace, ns) {
+			return nil, fmt.Errorf("robot %q at %s:%d already exists", ns, ip, port)
 		}
 	}
+	return m.addRobotLocked(ns, name, ip, port, false), nil
+}
 
+// addRobotLocked creates, wires and registers a robot; reverse robots
+// wait for their agent instead of dialing. Caller holds m.mu.
+func (m *Manager) addRobotLocked(ns, name, ip string, port int, reverse bool) *Robot {
 	id := fmt.Sprintf("%d", m.nextID)
 	m.nextID++
 
 	r := NewRobot(id, ns, name, ip, port)
+	if reverse {
+		r.Client.SetReverse(true)
+	} else {
+		r.Client.SetShared(m.SharedConnections)
+	}
+	if m.Points != nil {
+		m.restorePoints(r)
+	}
+	r.UsageJumpM = m.UsageJumpM
+	if m.Usage != nil {
+		m.restoreUsage(r)
+	}
+	if m.Visits != nil {
+		m.restoreVisits(r)
+	}
 
-	// Wire up broadcast callbacks for real-time data
-	origOnMap := r.Client.OnMap
-	r.Client.OnMap = func(md MapData) {
-		if origOnMap != nil {
-			origOnMap(md)
+	// Broadcast real-time data; NewRobot's handlers run first
+	r.Client.AddMapHandler(func(MapData) {
+		meta := r.GetMapMeta()
+		m.Broadcast(BroadcastMsg{Type: "map_meta", RobotID: id, Data: meta})
+		if !meta.Repeat {
+			m.Broadcast(BroadcastMsg{Type: "map", RobotID: id, Data: r.GetMapFrame()})
 		}
-		m.Broadcast(BroadcastMsg{Type: "map", RobotID: id, Data: md})
-	}
+	})
 
-	origOnTF := r.Client.OnTF
-	r.Client.OnTF = func(tf TFData) {
-		if origOnTF != nil {
-			origOnTF(tf)
+	r.OnMapThumbnail = func(mapName string, f MapFrame) {
+		if m.Thumbnails == nil {
+			return
+		}
+		if err := m.Thumbnails.Save(r.StoreKey(), mapName, f); err != nil {
+			log.Printf("[map] thumbnail %q: %v", mapName, err)
 		}
+	}
+
+	r.Client.AddTFHandler(func(tf TFData) {
 		m.Broadcast(BroadcastMsg{Type: "tf", RobotID: id, Data: tf})
+	})
+
+	r.Client.AddOdomHandler(func(o OdomData) {
+		m.Broadcast(BroadcastMsg{Type: "odom", RobotID: id, Data: o})
+	})
+
+	r.Client.AddCtrlOdomHandler(func(o OdomData) {
+		m.Broadcast(BroadcastMsg{Type: "ctrl_odom", RobotID: id, Data: o})
+	})
+
+	r.Client.AddLaserHandler(func(l LaserData) {
+		m.Broadcast(BroadcastMsg{Type: "laser", RobotID: id, Data: l})
+	})
+
+	r.Client.AddTwistHandler(func(t TwistData) {
+		m.Broadcast(BroadcastMsg{Type: "velocity", RobotID: id, Data: t})
+	})
+
+	r.Client.AddMapBfpHandler(func(p Pose2D) {
+		m.Broadcast(BroadcastMsg{Type: "map_bfp", RobotID: id, Data: p})
+	})
+
+	r.OnMoveProgress = func(p MoveProgress) {
+		m.Broadcast(BroadcastMsg{Type: "move_progress", RobotID: id, Data: p})
 	}
 
-	origOnOdom := r.Client.OnOdom
-	r.Client.OnOdom = func(o OdomData) {
-		if origOnOdom != nil {
-			origOnOdom(o)
+	r.OnRelocalize = func(e RelocalizeEvent) {
+		m.Broadcast(BroadcastMsg{Type: "relocalize", RobotID: id, Data: e})
+		switch e.State {
+		case RelocalizeDone:
+			m.Notify(NoticeInfo, id, "relocalize", fmt.Sprintf("Relocalization of %s finished: localization %s", name, e.Localization.Quality))
+		case RelocalizeAborted:
+			m.Notify(NoticeWarn, id, "relocalize", fmt.Sprintf("Relocalization rotation of %s aborted: %s", name, e.Reason))
 		}
-		m.Broadcast(BroadcastMsg{Type: "odom", RobotID: id, Data: o})
 	}
 
-	origOnCtrlOdom := r.Client.OnCtrlOdom
-	r.Client.OnCtrlOdom = func(o OdomData) {
-		if origOnCtrlOdom != nil {
-			origOnCtrlOdom(o)
+	r.OnPatrolEvent = func(e PatrolEvent) {
+		m.Broadcast(BroadcastMsg{Type: "patrol", RobotID: id, Data: e})
+	}
+
+	r.OnHome = func(e HomeEvent) {
+		m.Broadcast(BroadcastMsg{Type: "home", RobotID: id, Data: e})
+	}
+
+	r.OnMode = func(c ModeChange) {
+		m.Broadcast(BroadcastMsg{Type: "mode", RobotID: id, Data: c})
+	}
+
+	r.SetTaskDiscovery(m.TaskDiscoveryRequest, m.StaticTasks)
+	r.SetCapabilityRequest(m.CapabilitiesRequest)
+
+	if m.TopicThrottles != nil {
+		if rates := m.TopicThrottles(); rates != nil {
+			r.SetSubscriptionSettings(rates, nil)
 		}
-		m.Broadcast(BroadcastMsg{Type: "ctrl_odom", RobotID: id, Data: o})
 	}
 
-	origOnLaser := r.Client.OnLaser
-	r.Client.OnLaser = func(l LaserData) {
-		if origOnLaser != nil {
-			origOnLaser(l)
+	r.SetAutonomyGating(m.AutonomyGating)
+	r.OnAutonomy = func(a Autonomy) {
+		m.Broadcast(BroadcastMsg{Type: "autonomy", RobotID: id, Data: a})
+	}
+
+	r.SetJoystickDeadman(m.JoystickDeadman)
+	r.OnManualControl = func(c ManualControl) {
+		m.Broadcast(BroadcastMsg{Type: "manual_control", RobotID: id, Data: c})
+	}
+
+	r.SetControlLeaseOptions(m.ControlLeaseIdle, m.ControlLeaseRequired)
+	r.OnControlLease = func(ev ControlLeaseEvent) {
+		m.Broadcast(BroadcastMsg{Type: "control_lease", RobotID: id, Data: ev})
+	}
+
+	r.Client.SetClockSkewLimits(m.ClockSkewWarn, m.ClockSkewJump)
+	r.Client.AddClockSkewHandler(func(ev ClockSkewEvent) {
+		log.Printf("[robot %s] %s", id, ev.Msg)
+		m.Broadcast(BroadcastMsg{Type: "clock_skew", RobotID: id, Data: ev})
+	})
+
+	r.OnMappingSession = func(s MappingSession) {
+		m.Broadcast(BroadcastMsg{Type: "mapping_session", RobotID: id, Data: s})
+	}
+
+	r.SetMapSaveOptions(m.MapSaveTimeout, m.MapSaveProgressTopic)
+	r.SetMappingProgressOptions(m.MappingStableM2PerMin, m.MappingStableFor)
+	r.OnMapSave = func(op MapSaveOp) {
+		switch op.State {
+		case MapSaveDone:
+			if m.Thumbnails != nil && r.GetSnapshot().MapReceived {
+				if err := m.Thumbnails.Save(r.StoreKey(), op.MapName, r.GetMapFrame()); err != nil {
+					log.Printf("[map] thumbnail %q: %v", op.MapName, err)
+				}
+			}
+			if m.MapArchive != nil && r.GetSnapshot().MapReceived {
+				if _, err := m.ArchiveMap(r, op.MapName, MapArchiveSave); err != nil {
+					m.ReportError(id, "map", fmt.Sprintf("Archiving map %q of %s failed: %v", op.MapName, name, err))
+				}
+			}
+		case MapSaveFailed:
+			m.ReportError(id, "map", fmt.Sprintf("Saving map %q on %s failed: %s", op.MapName, name, op.Error))
 		}
-		m.Broadcast(BroadcastMsg{Type: "laser", RobotID: id, Data: l})
+		m.Broadcast(BroadcastMsg{Type: "map_save", RobotID: id, Data: op})
+	}
+
+	r.OnActivity = func(a ActivityState) {
+		m.Broadcast(BroadcastMsg{Type: "robot_activity", RobotID: id, Data: a})
+	}
+
+	r.SetAMCLPoseTopic(m.AMCLPoseTopic)
+	r.OnLocalization = func(l Localization) {
+		log.Printf("[robot %s] Localization %s → %s (σxy %.2f m, σyaw %.2f rad)", id, l.Previous, l.Quality, l.StdXYM, l.StdYawRad)
+		m.Broadcast(BroadcastMsg{Type: "localization_quality", RobotID: id, Data: l})
 	}
 
-	origOnTwist := r.Client.OnTwist
-	r.Client.OnTwist = func(t TwistData) {
-		if origOnTwist != nil {
-			origOnTwist(t)
+	r.Client.SetMarkerTopic(m.MarkerTopic)
+	r.StaleThresholds = m.StaleThresholds
+	r.RatioBounds = m.VelRatioBounds
+	r.OnSettings = func(s Settings) {
+		m.Broadcast(BroadcastMsg{Type: "settings_changed", RobotID: id, Data: s})
+	}
+	r.OnMarker = func(mk Marker) {
+		m.BroadcastMust(BroadcastMsg{Type: "marker", RobotID: id, Data: mk})
+		msg := fmt.Sprintf("Incident marked on %s by the %s", name, mk.Source)
+		if mk.Label != "" {
+			msg += ": " + mk.Label
 		}
-		m.Broadcast(BroadcastMsg{Type: "velocity", RobotID: id, Data: t})
+		m.Notify(NoticeInfo, id, "marker", msg)
 	}
 
-	origOnMapBfp := r.Client.OnMapBfp
-	r.Client.OnMapBfp = func(p Pose2D) {
-		if origOnMapBfp != nil {
-			origOnMapBfp(p)
+	r.OnPoseReset = func(e PoseReset) {
+		m.BroadcastMust(BroadcastMsg{Type: "pose_reset", RobotID: id, Data: e})
+		msg := fmt.Sprintf("Odometry of %s reset (%s)", name, e.Method)
+		if e.Forced {
+			msg += " during navigation"
 		}
-		m.Broadcast(BroadcastMsg{Type: "map_bfp", RobotID: id, Data: p})
+		m.Notify(NoticeInfo, id, "pose_reset", msg)
 	}
 
-	r.Client.OnConnected = func() {
-		r.mu.Lock()
-		r.Connected = true
-		r.mu.Unlock()
-		r.Client.SubscribeAllTopics()
-		r.Client.SetCmdVelEnabled(true)
-		m.Broadcast(BroadcastMsg{Type: "robot_connected", RobotID: id})
+	r.OnVelocityLag = func(l VelocityLag) {
+		m.Broadcast(BroadcastMsg{Type: "velocity_lag", RobotID: id, Data: l})
+	}
+	r.OnUsageReset = func(e UsageReset) {
+		log.Printf("[usage] %s: counters reset by %s at %.0f m, %.1f h (%s)", name, e.By, e.Before.DistanceM, e.Before.ActiveHours, e.Note)
+		if m.Usage != nil {
+			m.saveUsage(r)
+		}
+		m.BroadcastMust(BroadcastMsg{Type: "usage_reset", RobotID: id, Data: e})
+		m.Notify(NoticeInfo, id, "usage_reset", fmt.Sprintf("Usage counters of %s reset by %s", name, e.By))
+	}
+
+	r.OnPending = func(c PendingCommand) {
+		switch c.Status {
+		case PendingDone:
+			m.Notify(NoticeInfo, id, "offline_queue", fmt.Sprintf("Ran queued %s on %s after reconnecting", c.Description, name))
+		case PendingFailed:
+			m.ReportError(id, "offline_queue", fmt.Sprintf("Queued %s failed on %s: %s", c.Description, name, c.Error))
+		case PendingExpired:
+			m.Notify(NoticeWarn, id, "offline_queue", fmt.Sprintf("Dropped queued %s for %s: %s", c.Description, name, c.Error))
+		}
+	}
+
+	r.OnConnectPhase = func(p ConnectPhase) {
+		m.Broadcast(BroadcastMsg{Type: "connect_phase", RobotID: id, Data: p})
+	}
+	r.OnExtraTopic = func(v ExtraTopicValue) {
+		m.Broadcast(BroadcastMsg{Type: ExtraTopicPrefix + v.Alias, RobotID: id, Data: v})
 	}
 
-	r.Client.OnDisconnected = func() {
-		r.mu.Lock()
-		r.Connected = false
-		r.mu.Unlock()
-		m.Broadcast(BroadcastMsg{Type: "robot_disconnected", RobotID: id})
+	r.SetExpectedDowntime(m.ExpectedDowntime)
+	r.OnPowerState = func(ev PowerEvent) {
+		m.BroadcastMust(BroadcastMsg{Type: "power_state", RobotID: id, Data: ev})
+		switch {
+		case ev.Alert:
+			m.Notify(NoticeWarn, id, "power", ev.Message)
+		case ev.State == PowerNormal:
+			m.Notify(NoticeInfo, id, "power", ev.Message)
+		}
 	}
 
+	r.OnConfig = func(c RobotConfig) {
+		m.Broadcast(BroadcastMsg{Type: "robot_config", RobotID: id, Data: c})
+	}
+
+	r.Client.AddNavStatusHandler(func(s NavStatus) {
+		m.Broadcast(BroadcastMsg{Type: "nav_status", RobotID: id, Data: s})
+		if s.Status == rosbridge.GoalSucceeded {
+			m.navArrival(r)
+		}
+	})
+
+	r.Client.AddStatusHandler(func(st StatusMessage) {
+		if st.Level == "error" || st.Level == "warning" {
+			m.Broadcast(BroadcastMsg{Type: "rosbridge_status", RobotID: id, Data: st})
+		}
+	})
+
+	// Runs after NewRobot's handlers have updated the robot's state
+	r.Client.AddConnectHandler(func() {
+		m.Broadcast(BroadcastMsg{Type: "robot_connected", RobotID: id})
+	})
+
+	// The power state tells a planned drop from a fault
+	r.Client.AddDisconnectHandler(func() {
+		m.Broadcast(BroadcastMsg{Type: "robot_disconnected", RobotID: id, Data: r.PowerStatus()})
+	})
+
+	r.Client.AddSuspendedHandler(func(st rosbridge.ReconnectStatus) {
+		if r.PowerStatus().Expected() {
+			return // down on request; not worth a warning
+		}
+		msg := fmt.Sprintf("Gave up reconnecting to %s after %d attempts; connect manually to resume", name, st.Attempts)
+		if !st.Policy.Enabled {
+			msg = fmt.Sprintf("Not connected to %s and automatic reconnect is off; connect manually", name)
+		}
+		m.Notify(NoticeWarn, id, "connect", msg)
+	})
+
 	m.robots[id] = r
 
 	// Auto-set as current if first
@@ -164,43 +521,114 @@ func (m *Manager) AddRobot(ns, name, ip string, port int) (*Robot, error) {
 
 	log.Printf("[manager] Robot added: id=%s name=%s ip=%s:%d", id, name, ip, port)
 	m.Broadcast(BroadcastMsg{Type: "robot_added", RobotID: id, Data: r.GetSnapshot()})
-	return r, nil
+	return r
+}
+
+// RebroadcastMap re-sends the robot's current map (with its latest render
+// hints), announced by its metadata, so canvases refresh without waiting
+// for the next SLAM frame.
+func (m *Manager) RebroadcastMap(r *Robot) {
+	frame := r.GetMapFrame()
+	if frame.Width == 0 || frame.Height == 0 {
+		return
+	}
+	meta := r.GetMapMeta()
+	meta.Repeat = false
+	m.Broadcast(BroadcastMsg{Type: "map_meta", RobotID: r.ID, Data: meta})
+	m.Broadcast(BroadcastMsg{Type: "map", RobotID: r.ID, Data: frame})
+}
+
+// RobotRemoved is the "robot_removed" payload: the robot that is current
+// after the removal ("" when none are left).
+type RobotRemoved struct {
+	CurrentID string `json:"current_id"`
 }
 
-// RemoveRobot disconnects and removes a robot.
+// RemoveRobot disconnects and removes a robot. Removing the current robot
+// makes the remaining robot with the lowest ID current (see
+// successorLocked) and broadcasts "robot_switched" after
+// "robot_removed"; its RobotID is "" when no robots are left.
+//
+// The robot leaves the map under m.mu; its pending changes are saved and
+// it is closed after, since closing waits for its patrol, tasks and
+// connection to stop.
 func (m *Manager) RemoveRobot(id string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	r, ok := m.robots[id]
 	if !ok {
+		m.mu.Unlock()
 		return fmt.Errorf("robot %s not found", id)
 	}
-
-	r.StopConnection()
 	delete(m.robots, id)
+	successor := ""
+	switched := m.currentID == id
+	if switched {
+		successor = m.successorLocked()
+		m.currentID = successor
+	}
+	m.mu.Unlock()
 
-	if m.currentID == id {
-		m.currentID = ""
-		for k := range m.robots {
-			m.currentID = k
-			break
-		}
+	// Changes still waiting for the save delay
+	if m.Points != nil {
+		m.Points.observe(r.ID, r.PersistedPoints(), time.Now(), true)
+	}
+	if m.Usage != nil {
+		m.saveUsage(r)
 	}
+	if m.Visits != nil {
+		m.saveVisits(r)
+	}
+	r.Close()
+	m.dropSequences(id)
 
-	m.Broadcast(BroadcastMsg{Type: "robot_removed", RobotID: id})
-	log.Printf("[manager] Robot removed: id=%s", id)
+	// Announced once closed, so no update of the robot follows. The
+	// current robot is read again: it may have been switched meanwhile.
+	m.mu.Lock()
+	current := m.currentID
+	m.Broadcast(BroadcastMsg{Type: "robot_removed", RobotID: id, Data: RobotRemoved{CurrentID: current}})
+	if switched && current == successor {
+		if next := m.robots[current]; next != nil {
+			next.Touch()
+		}
+		m.Broadcast(BroadcastMsg{Type: "robot_switched", RobotID: current})
+	}
+	m.mu.Unlock()
+	log.Printf("[manager] Robot removed: id=%s current=%q", id, current)
 	return nil
 }
 
+// successorLocked picks the robot to make current: the lowest ID (see
+// idLess), so the oldest robot, or "" when there are none. Caller holds
+// m.mu.
+func (m *Manager) successorLocked() string {
+	next := ""
+	for k := range m.robots {
+		if next == "" || idLess(k, next) {
+			next = k
+		}
+	}
+	return next
+}
+
+// idLess orders robot IDs shortest first, then bytewise: numeric order
+// for the decimal IDs AddRobot assigns, and one total order for any ID.
+func idLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
 // SwitchRobot sets the current active robot.
 func (m *Manager) SwitchRobot(id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.robots[id]; !ok {
+	r, ok := m.robots[id]
+	if !ok {
 		return fmt.Errorf("robot %s not found", id)
 	}
 	m.currentID = id
+	r.Touch()
 	m.Broadcast(BroadcastMsg{Type: "robot_switched", RobotID: id})
 	return nil
 }
@@ -252,7 +680,7 @@ func (m *Manager) ClearAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	for _, r := range m.robots {
-		r.StopConnection()
+		r.Close()
 	}
 	m.robots = make(map[string]*Robot)
 	m.currentID = ""
@@ -265,3 +693,9 @@ type OdomData = rosbridge.OdomData
 type LaserData = rosbridge.LaserData
 type TwistData = rosbridge.TwistData
 type Pose2D = rosbridge.Pose2D
+type StatusMessage = rosbridge.StatusMessage
+type NavStatus = rosbridge.NavStatus
+type ClockSkewEvent = rosbridge.ClockSkewEvent
+type BandwidthStats = rosbridge.BandwidthStats
+type InboundQueueStats = rosbridge.InboundQueueStats
+type FragmentStats = rosbridge.FragmentStats
@@ -0,0 +1,124 @@
+package robot
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// removalBroadcasts returns the robot_removed and robot_switched
+// broadcasts received within a short wait, in order.
+func removalBroadcasts(t *testing.T, ch chan BroadcastMsg) []BroadcastMsg {
+	t.Helper()
+	var got []BroadcastMsg
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case msg := <-ch:
+			if msg.Type == "robot_removed" || msg.Type == "robot_switched" {
+				got = append(got, msg)
+			}
+		case <-timeout:
+			return got
+		}
+	}
+}
+
+func TestRemoveRobot(t *testing.T) {
+	m := NewManager()
+	for i := 0; i < 3; i++ {
+		if _, err := m.AddRobot("", "r", "127.0.0.1", 9000+i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ch := m.Subscribe()
+	defer m.Unsubscribe(ch)
+	if got := m.GetCurrentRobotID(); got != "1" {
+		t.Fatalf("current %q, want the first robot", got)
+	}
+
+	// Not the current robot: only robot_removed
+	if err := m.RemoveRobot("2"); err != nil {
+		t.Fatal(err)
+	}
+	got := removalBroadcasts(t, ch)
+	if len(got) != 1 || got[0].Type != "robot_removed" || got[0].RobotID != "2" || got[0].Data.(RobotRemoved).CurrentID != "1" {
+		t.Errorf("non-current: %+v", got)
+	}
+	if m.GetCurrentRobotID() != "1" || m.GetRobot("2") != nil || m.GetRobotCount() != 2 {
+		t.Errorf("non-current: current %q, %d robots", m.GetCurrentRobotID(), m.GetRobotCount())
+	}
+
+	// The current robot: the lowest remaining ID takes over, announced
+	// after the removal
+	if err := m.RemoveRobot("1"); err != nil {
+		t.Fatal(err)
+	}
+	got = removalBroadcasts(t, ch)
+	if len(got) != 2 || got[0].Type != "robot_removed" || got[0].Data.(RobotRemoved).CurrentID != "3" ||
+		got[1].Type != "robot_switched" || got[1].RobotID != "3" {
+		t.Errorf("current: %+v", got)
+	}
+	if m.GetCurrentRobotID() != "3" {
+		t.Errorf("current: now %q", m.GetCurrentRobotID())
+	}
+
+	// The last robot: no current robot
+	if err := m.RemoveRobot("3"); err != nil {
+		t.Fatal(err)
+	}
+	got = removalBroadcasts(t, ch)
+	if len(got) != 2 || got[0].Type != "robot_removed" || got[0].Data.(RobotRemoved).CurrentID != "" ||
+		got[1].Type != "robot_switched" || got[1].RobotID != "" {
+		t.Errorf("last: %+v", got)
+	}
+	if m.GetCurrentRobotID() != "" || m.GetCurrentRobot() != nil || m.GetRobotCount() != 0 {
+		t.Errorf("last: current %q, %d robots", m.GetCurrentRobotID(), m.GetRobotCount())
+	}
+
+	if err := m.RemoveRobot("3"); err == nil {
+		t.Error("removed an unknown robot")
+	}
+	if got := removalBroadcasts(t, ch); len(got) != 0 {
+		t.Errorf("unknown: %+v", got)
+	}
+}
+
+func TestRemoveRobotSuccessorOrder(t *testing.T) {
+	m := NewManager()
+	for i := 0; i < 11; i++ {
+		if _, err := m.AddRobot("", "r", "127.0.0.1", 9000+i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// "10" sorts before "2" as a string, not as a number
+	for _, id := range []string{"1", "3", "4", "5", "6", "7", "8", "9"} {
+		m.RemoveRobot(id)
+	}
+	if err := m.SwitchRobot("10"); err != nil {
+		t.Fatal(err)
+	}
+	m.RemoveRobot("10")
+	if got := m.GetCurrentRobotID(); got != "2" {
+		t.Errorf("successor %q, want 2", got)
+	}
+}
+
+func TestIDLess(t *testing.T) {
+	ids := []string{"10", "b", "2", "a", "1", "ab", "11", "9"}
+	sort.Slice(ids, func(i, j int) bool { return idLess(ids[i], ids[j]) })
+	want := []string{"1", "2", "9", "a", "b", "10", "11", "ab"}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("sorted %v, want %v", ids, want)
+		}
+	}
+	// A strict order: never both ways, nor an ID before itself
+	for _, a := range ids {
+		for _, b := range ids {
+			if idLess(a, b) && idLess(b, a) || a == b && idLess(a, b) {
+				t.Errorf("idLess(%q, %q) inconsistent", a, b)
+			}
+		}
+	}
+}
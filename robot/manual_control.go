@@ -0,0 +1,205 @@
+package robot
+
+import (
+	"errors"
+	"time"
+)
+
+// ──────────────────────────── Manual control
+//
+// Watchers can't tell joystick motion from autonomous motion, so joystick
+// input from a browser connection opens a manual control session: it
+// starts with the first non-zero command, is echoed as "manual_control"
+// at most every manualEchoInterval while commands keep coming, and ends
+// after the deadman interval without one (the robot is stopped if it was
+// still commanded to move). Only the session's driver may drive; another
+// connection is rejected unless it sends the takeover flag. A held
+// control lease (see control_lease.go) comes first: only its holder may
+// drive, and its input takes over without the flag.
+
+// ErrDriverBusy is returned for joystick input from a connection other
+// than the one driving.
+var ErrDriverBusy = errors.New("rejected: another operator is driving")
+
+// DefaultJoystickDeadman is how long the driver may stay silent before
+// the session ends.
+const DefaultJoystickDeadman = 500 * time.Millisecond
+
+// manualEchoInterval throttles the echo to ~5 Hz.
+const manualEchoInterval = 200 * time.Millisecond
+
+// Driver identifies a browser connection sending joystick input.
+type Driver struct {
+	ID    string `json:"id"`    // unique per WebSocket connection
+	Label string `json:"label"` // remote address, for display
+}
+
+// ManualControl is a manual control session as echoed to watchers.
+type ManualControl struct {
+	Active   bool      `json:"active"`
+	Driver   Driver    `json:"driver"`
+	Since    time.Time `json:"since"`
+	LinearX  float64   `json:"linear_x"`
+	LinearY  float64   `json:"linear_y"` // holonomic robots only
+	AngularZ float64   `json:"angular_z"`
+	// Set on the event announcing a takeover: the driver it displaced.
+	TakenFrom *Driver `json:"taken_from,omitempty"`
+}
+
+// manualSession is the active session (guarded by Robot.mu).
+type manualSession struct {
+	state    ManualControl
+	lastCmd  time.Time
+	lastEcho time.Time
+	deadman  *time.Timer
+}
+
+// SetJoystickDeadman sets the silence that ends a manual control session
+// (DefaultJoystickDeadman if not positive).
+func (r *Robot) SetJoystickDeadman(d time.Duration) {
+	if d <= 0 {
+		d = DefaultJoystickDeadman
+	}
+	r.mu.Lock()
+	r.joystickDeadman = d
+	r.mu.Unlock()
+}
+
+// Drive applies joystick input from driver; linearY counts only on a
+// holonomic robot. It fails with ErrControlHeld or ErrControlRequired
+// unless the lease allows driver to drive, with ErrDriverBusy while
+// another connection drives, unless takeover is set, and with
+// ErrAutonomyActive as JoystickVelocity does.
+func (r *Robot) Drive(driver Driver, linearX, linearY, angularZ float64, override, takeover bool) error {
+	holder, err := r.useControl(driver.ID)
+	if err != nil {
+		return err
+	}
+	takeover = takeover || holder
+
+	r.mu.RLock()
+	s := r.manual
+	busy := s != nil && s.state.Driver.ID != driver.ID
+	if !r.holonomic {
+		linearY = 0
+	}
+	r.mu.RUnlock()
+	if busy && !takeover {
+		return ErrDriverBusy
+	}
+	if err := r.JoystickVelocity(linearX, linearY, angularZ, override); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	moving := linearX != 0 || linearY != 0 || angularZ != 0
+	var echo []ManualControl
+
+	r.mu.Lock()
+	s = r.manual
+	if s != nil && s.state.Driver.ID != driver.ID {
+		// Takeover: end the old session and tell its driver.
+		s.deadman.Stop()
+		ended := s.state
+		ended.Active = false
+		echo = append(echo, ended)
+		r.manual = nil
+		if moving {
+			r.manual = r.startManualLocked(driver, now)
+			taken := ended.Driver
+			r.manual.state.TakenFrom = &taken
+		}
+		s = r.manual
+	} else if s == nil && moving {
+		s = r.startManualLocked(driver, now)
+		r.manual = s
+	}
+	if s != nil {
+		s.lastCmd = now
+		s.state.LinearX, s.state.LinearY, s.state.AngularZ = linearX, linearY, angularZ
+		s.deadman.Reset(r.joystickDeadmanLocked())
+		if s.lastEcho.IsZero() || now.Sub(s.lastEcho) >= manualEchoInterval {
+			s.lastEcho = now
+			echo = append(echo, s.state)
+			s.state.TakenFrom = nil
+		}
+	}
+	r.mu.Unlock()
+
+	r.emitManualControl(echo...)
+	return nil
+}
+
+// startManualLocked opens a session for driver. Caller holds r.mu.
+func (r *Robot) startManualLocked(driver Driver, now time.Time) *manualSession {
+	s := &manualSession{state: ManualControl{Active: true, Driver: driver, Since: now}}
+	s.deadman = time.AfterFunc(r.joystickDeadmanLocked(), func() { r.deadmanExpired(s) })
+	return s
+}
+
+func (r *Robot) joystickDeadmanLocked() time.Duration {
+	if r.joystickDeadman <= 0 {
+		return DefaultJoystickDeadman
+	}
+	return r.joystickDeadman
+}
+
+// deadmanExpired ends session s after the driver went silent.
+func (r *Robot) deadmanExpired(s *manualSession) {
+	r.mu.Lock()
+	if r.manual != s || time.Since(s.lastCmd) < r.joystickDeadmanLocked() {
+		r.mu.Unlock()
+		return
+	}
+	r.manual = nil
+	ended := s.state
+	ended.Active = false
+	ended.TakenFrom = nil
+	r.mu.Unlock()
+
+	if ended.LinearX != 0 || ended.LinearY != 0 || ended.AngularZ != 0 {
+		r.commandVelocity(0, 0, 0)
+	}
+	r.emitManualControl(ended)
+}
+
+// stopTeleop zeroes the commanded velocity if a manual control session
+// is active and reports whether it was.
+func (r *Robot) stopTeleop() bool {
+	r.mu.Lock()
+	s := r.manual
+	if s != nil {
+		s.state.LinearX, s.state.LinearY, s.state.AngularZ = 0, 0, 0
+	}
+	r.mu.Unlock()
+	if s == nil {
+		return false
+	}
+	r.commandVelocity(0, 0, 0)
+	return true
+}
+
+// ManualDriver returns the active session, or nil.
+func (r *Robot) ManualDriver() *ManualControl {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.manualDriverLocked()
+}
+
+func (r *Robot) manualDriverLocked() *ManualControl {
+	if r.manual == nil {
+		return nil
+	}
+	c := r.manual.state
+	c.TakenFrom = nil
+	return &c
+}
+
+func (r *Robot) emitManualControl(events ...ManualControl) {
+	if r.OnManualControl == nil {
+		return
+	}
+	for _, ev := range events {
+		r.OnManualControl(ev)
+	}
+}
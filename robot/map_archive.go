@@ -0,0 +1,497 @@
+package robot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ──────────────────────────── Map archive
+//
+// Maps live on the robot, which keeps only the latest version of each.
+// The archive keeps earlier ones on the server so a re-mapped floor can
+// be compared with what it looked like before. Every map saved through
+// the app is archived, and the current map can be archived on demand.
+// A version is stored as <dir>/<robot>/<map>/<unix ms>.map.gz: a JSON
+// header line followed by the raw grid, gzip-compressed, so listing only
+// reads the headers. Retention keeps at most MaxVersions per map name
+// and MaxBytes in total, evicting the oldest versions first.
+
+// Map archive sources.
+const (
+	MapArchiveSave   = "save"   // archived when the map was saved
+	MapArchiveManual = "manual" // archived on request
+)
+
+const (
+	mapArchiveFormat = 1
+	mapArchiveExt    = ".map.gz"
+	// maxDiffCells bounds the comparison grid of two maps far apart.
+	maxDiffCells = 64 << 20
+)
+
+// ErrArchiveNotFound is returned for a map version that isn't archived.
+var ErrArchiveNotFound = errors.New("map version not archived")
+
+// MapVersion describes an archived map.
+type MapVersion struct {
+	Name       string    `json:"name"`
+	Version    int64     `json:"version"` // Unix ms of the archiving, unique per map name
+	ArchivedAt time.Time `json:"archived_at"`
+	Source     string    `json:"source"` // save or manual
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Resolution float64   `json:"resolution"`
+	OriginX    float64   `json:"origin_x"`
+	OriginY    float64   `json:"origin_y"`
+	OriginYaw  float64   `json:"origin_yaw"`
+	Checksum   string    `json:"checksum"`
+	Bytes      int64     `json:"bytes"` // compressed size on disk
+}
+
+// MapArchiveUsage is the archive's disk use and limits.
+type MapArchiveUsage struct {
+	Versions    int   `json:"versions"`
+	Bytes       int64 `json:"bytes"`
+	MaxVersions int   `json:"max_versions"` // per map name
+	MaxBytes    int64 `json:"max_bytes"`
+}
+
+// mapArchiveHeader is the first line of an archive file.
+type mapArchiveHeader struct {
+	Format int `json:"format"`
+	MapVersion
+	RenderHints MapRenderHints `json:"render_hints"`
+}
+
+// MapArchive stores map versions on disk.
+type MapArchive struct {
+	Dir         string
+	MaxVersions int   // per map name; 0 = unlimited
+	MaxBytes    int64 // all robots and maps; 0 = unlimited
+
+	mu sync.Mutex // serializes adds and evictions
+}
+
+// NewMapArchive returns an archive rooted at dir.
+func NewMapArchive(dir string, maxVersions int, maxBytes int64) *MapArchive {
+	return &MapArchive{Dir: dir, MaxVersions: maxVersions, MaxBytes: maxBytes}
+}
+
+func (a *MapArchive) mapDir(robotKey, name string) string {
+	return filepath.Join(a.Dir, safeFileName(robotKey), safeFileName(name))
+}
+
+func (a *MapArchive) path(robotKey, name string, version int64) string {
+	return filepath.Join(a.mapDir(robotKey, name), strconv.FormatInt(version, 10)+mapArchiveExt)
+}
+
+// Add archives f as a version of the robot's map name and applies the
+// retention limits; the new version itself is never evicted.
+func (a *MapArchive) Add(robotKey, name, source string, f MapFrame, at time.Time) (MapVersion, error) {
+	if name == "" {
+		return MapVersion{}, fmt.Errorf("map name required")
+	}
+	if f.Width <= 0 || f.Height <= 0 || len(f.Data) < f.Width*f.Height {
+		return MapVersion{}, fmt.Errorf("empty map")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Versions only grow, even for two in the same millisecond or after
+	// the clock went back.
+	version := at.UnixMilli()
+	if newest := a.newestVersion(robotKey, name); version <= newest {
+		version = newest + 1
+	}
+	h := mapArchiveHeader{
+		Format: mapArchiveFormat,
+		MapVersion: MapVersion{
+			Name:       name,
+			Version:    version,
+			ArchivedAt: at,
+			Source:     source,
+			Width:      f.Width,
+			Height:     f.Height,
+			Resolution: f.Resolution,
+			OriginX:    f.OriginX,
+			OriginY:    f.OriginY,
+			OriginYaw:  f.OriginYaw,
+			Checksum:   f.Checksum,
+		},
+		RenderHints: f.RenderHints,
+	}
+	data, err := encodeMapArchive(h, f)
+	if err != nil {
+		return MapVersion{}, err
+	}
+	path := a.path(robotKey, name, version)
+	if err := writeFileAtomic(path, data); err != nil {
+		return MapVersion{}, err
+	}
+	h.Bytes = int64(len(data))
+	if err := a.evictLocked(path); err != nil {
+		return h.MapVersion, fmt.Errorf("archived, but eviction failed: %w", err)
+	}
+	return h.MapVersion, nil
+}
+
+// newestVersion returns the highest version stored for the map, or 0.
+func (a *MapArchive) newestVersion(robotKey, name string) int64 {
+	var newest int64
+	entries, _ := os.ReadDir(a.mapDir(robotKey, name))
+	for _, e := range entries {
+		v, err := strconv.ParseInt(strings.TrimSuffix(e.Name(), mapArchiveExt), 10, 64)
+		if err == nil && strings.HasSuffix(e.Name(), mapArchiveExt) && v > newest {
+			newest = v
+		}
+	}
+	return newest
+}
+
+func encodeMapArchive(h mapArchiveHeader, f MapFrame) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	line, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	zw.Write(append(line, '\n'))
+	grid := make([]byte, f.Width*f.Height)
+	for i := range grid {
+		grid[i] = byte(f.Data[i])
+	}
+	zw.Write(grid)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// archiveFile is a version found on disk.
+type archiveFile struct {
+	path    string
+	group   string // map directory
+	version int64
+	size    int64
+}
+
+// scan lists every archived version below Dir.
+func (a *MapArchive) scan() ([]archiveFile, error) {
+	var files []archiveFile
+	err := filepath.WalkDir(a.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == a.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), mapArchiveExt) {
+			return nil
+		}
+		version, err := strconv.ParseInt(strings.TrimSuffix(d.Name(), mapArchiveExt), 10, 64)
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, archiveFile{path: path, group: filepath.Dir(path), version: version, size: info.Size()})
+		return nil
+	})
+	return files, err
+}
+
+// evictLocked removes the versions beyond MaxVersions per map, then the
+// oldest versions overall until the archive fits MaxBytes. keep is never
+// removed. Caller holds a.mu.
+func (a *MapArchive) evictLocked(keep string) error {
+	files, err := a.scan()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].version > files[j].version })
+
+	var kept []archiveFile
+	perGroup := map[string]int{}
+	var total int64
+	for _, f := range files {
+		perGroup[f.group]++
+		if a.MaxVersions > 0 && perGroup[f.group] > a.MaxVersions && f.path != keep {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, f)
+		total += f.size
+	}
+
+	// kept is newest first; drop from the end.
+	for i := len(kept) - 1; i >= 0 && a.MaxBytes > 0 && total > a.MaxBytes; i-- {
+		if kept[i].path == keep {
+			continue
+		}
+		if err := os.Remove(kept[i].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= kept[i].size
+	}
+	return nil
+}
+
+// List returns the robot's archived versions of map name, or of every
+// map when name is empty, newest first.
+func (a *MapArchive) List(robotKey, name string) ([]MapVersion, error) {
+	var dirs []string
+	if name != "" {
+		dirs = []string{a.mapDir(robotKey, name)}
+	} else {
+		entries, err := os.ReadDir(filepath.Join(a.Dir, safeFileName(robotKey)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				dirs = append(dirs, filepath.Join(a.Dir, safeFileName(robotKey), e.Name()))
+			}
+		}
+	}
+
+	out := []MapVersion{}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), mapArchiveExt) {
+				continue
+			}
+			h, err := readMapArchiveHeader(filepath.Join(dir, e.Name()))
+			if err != nil {
+				continue
+			}
+			// Different names can share a directory once made safe.
+			if name != "" && h.Name != name {
+				continue
+			}
+			out = append(out, h.MapVersion)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version > out[j].Version })
+	return out, nil
+}
+
+// Usage returns the archive's disk use.
+func (a *MapArchive) Usage() MapArchiveUsage {
+	u := MapArchiveUsage{MaxVersions: a.MaxVersions, MaxBytes: a.MaxBytes}
+	files, _ := a.scan()
+	for _, f := range files {
+		u.Versions++
+		u.Bytes += f.size
+	}
+	return u
+}
+
+func readMapArchiveHeader(path string) (mapArchiveHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return mapArchiveHeader{}, err
+	}
+	defer f.Close()
+	h, _, err := decodeMapArchiveHeader(f)
+	if err != nil {
+		return h, err
+	}
+	if info, err := f.Stat(); err == nil {
+		h.Bytes = info.Size()
+	}
+	return h, nil
+}
+
+func decodeMapArchiveHeader(r io.Reader) (mapArchiveHeader, *bufio.Reader, error) {
+	var h mapArchiveHeader
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return h, nil, err
+	}
+	br := bufio.NewReader(zr)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return h, nil, err
+	}
+	if err := json.Unmarshal(line, &h); err != nil {
+		return h, nil, err
+	}
+	if h.Format > mapArchiveFormat {
+		return h, nil, fmt.Errorf("archive format %d is newer than supported (%d)", h.Format, mapArchiveFormat)
+	}
+	return h, br, nil
+}
+
+// Load returns an archived version of the robot's map name.
+func (a *MapArchive) Load(robotKey, name string, version int64) (MapFrame, MapVersion, error) {
+	path := a.path(robotKey, name, version)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return MapFrame{}, MapVersion{}, ErrArchiveNotFound
+	}
+	if err != nil {
+		return MapFrame{}, MapVersion{}, err
+	}
+	defer f.Close()
+
+	h, br, err := decodeMapArchiveHeader(f)
+	if err != nil {
+		return MapFrame{}, MapVersion{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if h.Name != name {
+		return MapFrame{}, MapVersion{}, ErrArchiveNotFound
+	}
+	grid := make([]byte, h.Width*h.Height)
+	if _, err := io.ReadFull(br, grid); err != nil {
+		return MapFrame{}, MapVersion{}, fmt.Errorf("%s: truncated grid: %w", filepath.Base(path), err)
+	}
+	if info, err := f.Stat(); err == nil {
+		h.Bytes = info.Size()
+	}
+
+	frame := MapFrame{RenderHints: h.RenderHints, Checksum: h.Checksum}
+	frame.Width, frame.Height, frame.Resolution = h.Width, h.Height, h.Resolution
+	frame.OriginX, frame.OriginY, frame.OriginYaw = h.OriginX, h.OriginY, h.OriginYaw
+	frame.Data = make([]int8, len(grid))
+	for i, v := range grid {
+		frame.Data[i] = int8(v)
+	}
+	return frame, h.MapVersion, nil
+}
+
+// ──────────────────────────── Map diff
+
+// MapDiff compares two versions of a map cell by cell. The maps are
+// aligned by their origins and sampled at the resolution of the newer
+// one over the area both cover; cells unknown in both are skipped.
+// Areas are in m².
+type MapDiff struct {
+	From       MapVersion `json:"from"`
+	To         MapVersion `json:"to"`
+	Resolution float64    `json:"resolution"`
+	Cells      int        `json:"cells"` // known in either map
+
+	ChangedCells       int `json:"changed_cells"`        // classified differently
+	NewlyOccupiedCells int `json:"newly_occupied_cells"` // occupied now, not before
+	FreedCells         int `json:"freed_cells"`          // occupied before, free now
+	ExploredCells      int `json:"explored_cells"`       // unknown before, known now
+	LostCells          int `json:"lost_cells"`           // known before, unknown now
+
+	ChangedArea       float64 `json:"changed_m2"`
+	NewlyOccupiedArea float64 `json:"newly_occupied_m2"`
+	FreedArea         float64 `json:"freed_m2"`
+	ExploredArea      float64 `json:"explored_m2"`
+	LostArea          float64 `json:"lost_m2"`
+
+	Summary string `json:"summary"`
+}
+
+// DiffMaps compares from with to.
+func DiffMaps(from, to MapFrame) (MapDiff, error) {
+	res := to.Resolution
+	if res <= 0 || from.Resolution <= 0 {
+		return MapDiff{}, fmt.Errorf("map without resolution")
+	}
+	minX := math.Min(from.OriginX, to.OriginX)
+	minY := math.Min(from.OriginY, to.OriginY)
+	maxX := math.Max(from.OriginX+float64(from.Width)*from.Resolution, to.OriginX+float64(to.Width)*to.Resolution)
+	maxY := math.Max(from.OriginY+float64(from.Height)*from.Resolution, to.OriginY+float64(to.Height)*to.Resolution)
+	cols := int(math.Ceil((maxX - minX) / res))
+	rows := int(math.Ceil((maxY - minY) / res))
+	if cols*rows > maxDiffCells {
+		return MapDiff{}, fmt.Errorf("maps too far apart to compare (%d×%d cells)", cols, rows)
+	}
+
+	d := MapDiff{Resolution: res}
+	for row := 0; row < rows; row++ {
+		y := minY + (float64(row)+0.5)*res
+		for col := 0; col < cols; col++ {
+			x := minX + (float64(col)+0.5)*res
+			before, after := classifyAt(from, x, y), classifyAt(to, x, y)
+			if before == CellUnknown && after == CellUnknown {
+				continue
+			}
+			d.Cells++
+			if before == after {
+				continue
+			}
+			d.ChangedCells++
+			switch {
+			case before == CellUnknown:
+				d.ExploredCells++
+			case after == CellUnknown:
+				d.LostCells++
+			}
+			if after == CellOccupied {
+				d.NewlyOccupiedCells++
+			}
+			if before == CellOccupied && after == CellFree {
+				d.FreedCells++
+			}
+		}
+	}
+
+	cell := res * res
+	d.ChangedArea = float64(d.ChangedCells) * cell
+	d.NewlyOccupiedArea = float64(d.NewlyOccupiedCells) * cell
+	d.FreedArea = float64(d.FreedCells) * cell
+	d.ExploredArea = float64(d.ExploredCells) * cell
+	d.LostArea = float64(d.LostCells) * cell
+	d.Summary = d.summary()
+	return d, nil
+}
+
+// classifyAt classifies the cell of m containing world point (x, y);
+// outside the map is unknown.
+func classifyAt(m MapFrame, x, y float64) CellClass {
+	col, row, _ := NewMapTransform(m.MapData).WorldToCell(x, y)
+	v, ok := CellValue(m.MapData, col, row)
+	if !ok {
+		return CellUnknown
+	}
+	return m.RenderHints.Classify(v)
+}
+
+func (d MapDiff) summary() string {
+	if d.ChangedCells == 0 {
+		return "No changes."
+	}
+	pct := 0.0
+	if d.Cells > 0 {
+		pct = 100 * float64(d.ChangedCells) / float64(d.Cells)
+	}
+	return fmt.Sprintf("%d cells changed (%.1f m², %.1f%% of the mapped area): %.1f m² newly occupied, %.1f m² freed, %.1f m² newly explored, %.1f m² no longer mapped.",
+		d.ChangedCells, d.ChangedArea, pct, d.NewlyOccupiedArea, d.FreedArea, d.ExploredArea, d.LostArea)
+}
+
+// ──────────────────────────── Manager integration
+
+// ArchiveMap stores the robot's current map as a version of name.
+func (m *Manager) ArchiveMap(r *Robot, name, source string) (MapVersion, error) {
+	if m.MapArchive == nil {
+		return MapVersion{}, fmt.Errorf("map archive disabled")
+	}
+	return m.MapArchive.Add(r.StoreKey(), name, source, r.GetMapFrame(), time.Now())
+}
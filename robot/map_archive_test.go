@@ -0,0 +1,203 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// archiveFrame returns a w×h map of noise, which gzip can't shrink much.
+func archiveFrame(w, h int, seed int64) MapFrame {
+	rng := rand.New(rand.NewSource(seed))
+	data := make([]int8, w*h)
+	for i := range data {
+		data[i] = int8(rng.Intn(102) - 1)
+	}
+	return MapFrame{
+		MapData:     rosbridge.MapData{Width: w, Height: h, Resolution: 0.05, OriginX: -1, OriginY: 2, OriginYaw: 0.5, Data: data},
+		RenderHints: DefaultRenderHints(),
+		Checksum:    fmt.Sprintf("c%d", seed),
+	}
+}
+
+// versions lists the version numbers of the robot's map name.
+func versions(t *testing.T, a *MapArchive, name string) []int64 {
+	t.Helper()
+	list, err := a.List("amr", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []int64
+	for _, v := range list {
+		out = append(out, v.Version)
+	}
+	return out
+}
+
+func sameVersions(got []int64, want ...int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func int8Bytes(d []int8) []byte {
+	out := make([]byte, len(d))
+	for i, v := range d {
+		out[i] = byte(v)
+	}
+	return out
+}
+
+func TestMapArchiveAdd(t *testing.T) {
+	a := NewMapArchive(t.TempDir(), 0, 0)
+	t0 := time.UnixMilli(1_700_000_000_000)
+	f := archiveFrame(40, 30, 1)
+
+	// Three versions in one millisecond, then one with the clock gone back
+	for _, at := range []time.Time{t0, t0, t0, t0.Add(-time.Hour)} {
+		if _, err := a.Add("amr", "floor1", MapArchiveSave, f, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ms := t0.UnixMilli()
+	if got := versions(t, a, "floor1"); !sameVersions(got, ms+3, ms+2, ms+1, ms) {
+		t.Errorf("versions %v", got)
+	}
+
+	got, v, err := a.Load("amr", "floor1", ms+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "floor1" || v.Source != MapArchiveSave || v.Width != 40 || v.OriginYaw != 0.5 || v.Bytes == 0 {
+		t.Errorf("version %+v", v)
+	}
+	if got.Checksum != f.Checksum || got.RenderHints != f.RenderHints || got.OriginX != -1 || string(int8Bytes(got.Data)) != string(int8Bytes(f.Data)) {
+		t.Error("loaded map differs from the archived one")
+	}
+
+	a.Add("amr", "floor2", MapArchiveManual, f, t0.Add(time.Second))
+	if all, _ := a.List("amr", ""); len(all) != 5 || all[0].Name != "floor2" {
+		t.Errorf("all maps %+v", all)
+	}
+	if _, _, err := a.Load("amr", "floor1", 42); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("missing version: %v", err)
+	}
+	if _, err := a.Add("amr", "", MapArchiveSave, f, t0); err == nil {
+		t.Error("archived without a name")
+	}
+	if _, err := a.Add("amr", "floor1", MapArchiveSave, MapFrame{}, t0); err == nil {
+		t.Error("archived an empty map")
+	}
+}
+
+func TestMapArchiveMaxVersions(t *testing.T) {
+	a := NewMapArchive(t.TempDir(), 2, 0)
+	t0 := time.UnixMilli(1_700_000_000_000)
+	a.Add("amr", "floor2", MapArchiveSave, archiveFrame(10, 10, 9), t0)
+	for i := 0; i < 4; i++ {
+		if _, err := a.Add("amr", "floor1", MapArchiveSave, archiveFrame(10, 10, int64(i)), t0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Per map name; eviction doesn't free a version number for reuse
+	ms := t0.UnixMilli()
+	if got := versions(t, a, "floor1"); !sameVersions(got, ms+3, ms+2) {
+		t.Errorf("floor1 %v", got)
+	}
+	if got := versions(t, a, "floor2"); !sameVersions(got, ms) {
+		t.Errorf("floor2 %v", got)
+	}
+	if u := a.Usage(); u.Versions != 3 || u.MaxVersions != 2 {
+		t.Errorf("usage %+v", u)
+	}
+}
+
+func TestMapArchiveMaxBytes(t *testing.T) {
+	a := NewMapArchive(t.TempDir(), 0, 0)
+	t0 := time.UnixMilli(1_700_000_000_000)
+	v, _ := a.Add("amr", "floor1", MapArchiveSave, archiveFrame(50, 50, 1), t0)
+	a.MaxBytes = 2*v.Bytes + v.Bytes/2 // room for two versions
+
+	a.Add("amr", "floor2", MapArchiveSave, archiveFrame(50, 50, 2), t0.Add(time.Second))
+	a.Add("amr", "floor1", MapArchiveSave, archiveFrame(50, 50, 3), t0.Add(2*time.Second))
+	ms := t0.UnixMilli()
+	if got := versions(t, a, ""); !sameVersions(got, ms+2000, ms+1000) {
+		t.Errorf("oldest not evicted first: %v", got)
+	}
+	if u := a.Usage(); u.Versions != 2 || u.Bytes > a.MaxBytes {
+		t.Errorf("usage %+v", u)
+	}
+
+	// A version bigger than the limit evicts the rest but is kept
+	big, err := a.Add("amr", "floor3", MapArchiveManual, archiveFrame(200, 200, 4), t0.Add(3*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(t, a, ""); !sameVersions(got, big.Version) {
+		t.Errorf("after an oversized version: %v", got)
+	}
+}
+
+func TestMapArchiveDamagedFiles(t *testing.T) {
+	a := NewMapArchive(t.TempDir(), 0, 0)
+	t0 := time.UnixMilli(1_700_000_000_000)
+	a.Add("amr", "floor1", MapArchiveSave, archiveFrame(10, 10, 1), t0)
+	dir := a.mapDir("amr", "floor1")
+	os.WriteFile(filepath.Join(dir, "1.map.gz"), []byte("not gzip"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+
+	if got := versions(t, a, "floor1"); !sameVersions(got, t0.UnixMilli()) {
+		t.Errorf("corrupt file listed: %v", got)
+	}
+	if _, _, err := a.Load("amr", "floor1", 1); err == nil || errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("corrupt file loaded: %v", err)
+	}
+
+	// "floor/1" shares floor_1's directory but not its versions
+	a.Add("amr", "floor_1", MapArchiveSave, archiveFrame(10, 10, 2), t0)
+	if got := versions(t, a, "floor/1"); len(got) != 0 {
+		t.Errorf("floor/1 lists floor_1's versions: %v", got)
+	}
+	if _, _, err := a.Load("amr", "floor/1", t0.UnixMilli()); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("floor/1 loads floor_1's version: %v", err)
+	}
+}
+
+func TestDiffMaps(t *testing.T) {
+	frame := func(originX float64, data ...int8) MapFrame {
+		return MapFrame{MapData: rosbridge.MapData{Width: len(data), Height: 1, Resolution: 1, OriginX: originX, Data: data}, RenderHints: DefaultRenderHints()}
+	}
+
+	d, err := DiffMaps(frame(0, 0, 100, -1, 0, 0), frame(0, 100, 0, 0, -1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Cells != 5 || d.ChangedCells != 4 || d.NewlyOccupiedCells != 1 || d.FreedCells != 1 || d.ExploredCells != 1 || d.LostCells != 1 || d.ChangedArea != 4 {
+		t.Errorf("diff %+v", d)
+	}
+	if d, _ := DiffMaps(frame(0, 0, 100), frame(0, 0, 100)); d.ChangedCells != 0 || d.Summary != "No changes." {
+		t.Errorf("same map: %+v", d)
+	}
+
+	// Shifted by a cell: aligned by origin over the union of both
+	d, _ = DiffMaps(frame(0, 0, 100), frame(1, 100, 0))
+	if d.Cells != 3 || d.ChangedCells != 2 || d.LostCells != 1 || d.ExploredCells != 1 || d.NewlyOccupiedCells != 0 {
+		t.Errorf("shifted: %+v", d)
+	}
+
+	if _, err := DiffMaps(frame(0, 0), frame(1e8, 0)); err == nil {
+		t.Error("compared maps 100 000 km apart")
+	}
+}
@@ -0,0 +1,78 @@
+package robot
+
+import "time"
+
+// ──────────────────────────── Map history
+//
+// Which map is loaded, and when it changed. Every successful save or
+// open through the app is recorded; the robot itself doesn't report its
+// loaded map, so CurrentMap is the last map the app saved or opened.
+// Opening a map swaps in its navigation points (see floors.go); saving
+// keeps them, now under the saved name.
+
+// Map history actions.
+const (
+	MapActionSave = "save"
+	MapActionOpen = "open"
+)
+
+// mapHistoryLen is the number of map events kept per robot.
+const mapHistoryLen = 50
+
+// MapEvent is one successful map save or open.
+type MapEvent struct {
+	Action string    `json:"action"` // save or open
+	Map    string    `json:"map"`
+	Time   time.Time `json:"time"`
+	// Client is who asked: the requesting address for API calls (there
+	// are no user accounts), empty for app-initiated changes.
+	Client string `json:"client,omitempty"`
+}
+
+// RecordMapEvent records a successful save or open of mapName, which
+// becomes the current map.
+func (r *Robot) RecordMapEvent(action, mapName, client string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mapHistory = append(r.mapHistory, MapEvent{Action: action, Map: mapName, Time: time.Now(), Client: client})
+	if n := len(r.mapHistory); n > mapHistoryLen {
+		r.mapHistory = append([]MapEvent(nil), r.mapHistory[n-mapHistoryLen:]...)
+	}
+	if action == MapActionOpen {
+		r.switchMapLocked(mapName)
+	} else {
+		r.saveMapLocked(mapName)
+	}
+}
+
+// MapHistory returns up to limit recent map events, newest first; limit
+// <= 0 returns all kept.
+func (r *Robot) MapHistory(limit int) []MapEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := len(r.mapHistory)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	out := make([]MapEvent, 0, limit)
+	for i := n - 1; i >= n-limit; i-- {
+		out = append(out, r.mapHistory[i])
+	}
+	return out
+}
+
+// CurrentMap returns the name of the map last saved or opened.
+func (r *Robot) CurrentMap() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.currentMap
+}
+
+// SetCurrentMap restores the current map name (profile import) without
+// recording an event or swapping points.
+func (r *Robot) SetCurrentMap(mapName string) {
+	r.mu.Lock()
+	r.currentMap = mapName
+	r.activeFloor = r.floors[mapName]
+	r.mu.Unlock()
+}
@@ -0,0 +1,86 @@
+package robot
+
+import (
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Map metadata
+//
+// A large grid takes seconds to reach a browser on a bad link, so every
+// grid is announced first by a small "map_meta" frame: dimensions,
+// resolution, origin, the grid's map_seq and a checksum of its cells. The
+// "map" frame that follows (throttled and encoded per client) carries the
+// same map_seq and checksum. map_seq counts distinct grids: a grid equal
+// to the previous one (the static map republished in navigation mode)
+// keeps its map_seq and is only re-announced, with repeat set, and no
+// "map" frame follows.
+
+// MapMeta describes the robot's current grid without its cells.
+type MapMeta struct {
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Resolution float64   `json:"resolution"`
+	OriginX    float64   `json:"origin_x"`
+	OriginY    float64   `json:"origin_y"`
+	OriginYaw  float64   `json:"origin_yaw"`
+	MapSeq     uint64    `json:"map_seq"`  // distinct grids received, from 1
+	Checksum   string    `json:"checksum"` // CRC-32 (IEEE) of the cells, hex
+	Repeat     bool      `json:"repeat"`   // same grid as the previous announcement; no data follows
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// mapChecksum returns the hex CRC-32 of a grid's cells.
+func mapChecksum(data []int8) string {
+	var buf [4096]byte
+	h := crc32.NewIEEE()
+	for len(data) > 0 {
+		n := min(len(buf), len(data))
+		for i, v := range data[:n] {
+			buf[i] = byte(v)
+		}
+		h.Write(buf[:n])
+		data = data[n:]
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// observeMapLocked records the metadata of a received grid.
+func (r *Robot) observeMapLocked(m rosbridge.MapData, sum string, now time.Time) {
+	prev := r.mapMeta
+	changed := prev.MapSeq == 0 || sum != prev.Checksum ||
+		m.Width != prev.Width || m.Height != prev.Height || m.Resolution != prev.Resolution ||
+		m.OriginX != prev.OriginX || m.OriginY != prev.OriginY || m.OriginYaw != prev.OriginYaw
+	seq := prev.MapSeq
+	if changed {
+		seq++
+	}
+	r.mapMeta = MapMeta{
+		Width:      m.Width,
+		Height:     m.Height,
+		Resolution: m.Resolution,
+		OriginX:    m.OriginX,
+		OriginY:    m.OriginY,
+		OriginYaw:  m.OriginYaw,
+		MapSeq:     seq,
+		Checksum:   sum,
+		Repeat:     !changed,
+		ReceivedAt: now,
+	}
+}
+
+// GetMapMeta returns the metadata of the current grid; MapSeq is 0 until
+// a map was received.
+func (r *Robot) GetMapMeta() MapMeta {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mapMeta
+}
+
+// mapFrameLocked pairs a grid with the render hints and metadata.
+func (r *Robot) mapFrameLocked(m rosbridge.MapData) MapFrame {
+	return MapFrame{MapData: m, RenderHints: r.renderHints, MapSeq: r.mapMeta.MapSeq, Checksum: r.mapMeta.Checksum}
+}
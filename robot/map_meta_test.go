@@ -0,0 +1,93 @@
+package robot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// mapFrames returns the next map or map_meta broadcast from ch.
+func mapFrames(t *testing.T, ch chan BroadcastMsg, wait time.Duration) (BroadcastMsg, bool) {
+	t.Helper()
+	deadline := time.After(wait)
+	for {
+		select {
+		case msg := <-ch:
+			if msg.Type == "map" || msg.Type == "map_meta" {
+				return msg, true
+			}
+		case <-deadline:
+			return BroadcastMsg{}, false
+		}
+	}
+}
+
+// TestRepeatedGridSkipped publishes the same grid twice, then a changed
+// one and one moved: each is announced by map_meta, and only distinct
+// grids are followed by a map frame.
+func TestRepeatedGridSkipped(t *testing.T) {
+	stub := newRosbridgeStub(t)
+	host, port := stub.addr(t)
+	m := NewManager()
+	r, _ := m.AddRobot("", "meta", host, port)
+	t.Cleanup(r.Close)
+	ch := m.Subscribe()
+	defer m.Unsubscribe(ch)
+	if err := r.Client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the map subscription", func() bool { return stub.subscribed("/map") })
+
+	grid := func(originX float64, data []int) string {
+		b, _ := json.Marshal(map[string]interface{}{
+			"info": map[string]interface{}{
+				"width": 2, "height": 2, "resolution": 0.05,
+				"origin": map[string]interface{}{"position": map[string]float64{"x": originX}},
+			},
+			"data": data,
+		})
+		return string(b)
+	}
+	steps := []struct {
+		name   string
+		msg    string
+		seq    uint64
+		repeat bool
+	}{
+		{"first grid", grid(0, []int{0, 0, 100, -1}), 1, false},
+		{"same grid", grid(0, []int{0, 0, 100, -1}), 1, true},
+		{"changed cell", grid(0, []int{0, 100, 100, -1}), 2, false},
+		{"moved origin", grid(1, []int{0, 100, 100, -1}), 3, false},
+	}
+	var sums []string
+	for _, s := range steps {
+		stub.publish("/map", s.msg)
+		msg, ok := mapFrames(t, ch, 3*time.Second)
+		if !ok || msg.Type != "map_meta" {
+			t.Fatalf("%s: got %q, want map_meta first", s.name, msg.Type)
+		}
+		meta := msg.Data.(MapMeta)
+		if meta.MapSeq != s.seq || meta.Repeat != s.repeat || meta.Width != 2 {
+			t.Errorf("%s: meta %+v", s.name, meta)
+		}
+		sums = append(sums, meta.Checksum)
+
+		msg, ok = mapFrames(t, ch, 200*time.Millisecond)
+		switch {
+		case s.repeat && ok:
+			t.Errorf("%s: %s frame sent for a repeated grid", s.name, msg.Type)
+		case !s.repeat && !ok:
+			t.Errorf("%s: no map frame", s.name)
+		case !s.repeat:
+			if f := msg.Data.(MapFrame); msg.Type != "map" || f.MapSeq != s.seq || f.Checksum != meta.Checksum {
+				t.Errorf("%s: %s frame seq %d checksum %s", s.name, msg.Type, f.MapSeq, f.Checksum)
+			}
+		}
+	}
+	if sums[0] != sums[1] || sums[1] == sums[2] || sums[2] != sums[3] {
+		t.Errorf("checksums %v", sums)
+	}
+	if meta := r.GetMapMeta(); meta.MapSeq != 3 || meta.OriginX != 1 {
+		t.Errorf("current meta %+v", meta)
+	}
+}
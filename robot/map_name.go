@@ -0,0 +1,38 @@
+package robot
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ──────────────────────────── Map names
+//
+// Maps are compared by name across the fleet (floor1_v3 on every robot),
+// so every name the app sends to a robot goes through one check: saves,
+// mapping sessions, opens, floor assignments and the expected map of a
+// fleet refresh. Names the robots report are listed as they are.
+
+// MaxMapNameLen is the longest map name accepted, in bytes.
+const MaxMapNameLen = 64
+
+// ValidateMapName checks a map name: not empty, at most MaxMapNameLen
+// bytes, no leading or trailing spaces, no path separators or control
+// characters, and not "." or "..".
+func ValidateMapName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("map name required")
+	case len(name) > MaxMapNameLen:
+		return fmt.Errorf("map name must be at most %d characters", MaxMapNameLen)
+	case strings.TrimSpace(name) != name:
+		return fmt.Errorf("map name must not start or end with spaces")
+	case name == "." || name == "..":
+		return fmt.Errorf("map name %q is not allowed", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("map name must not contain / or \\")
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return fmt.Errorf("map name must not contain control characters")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Map saves
+//
+// Saving a large map takes the robot up to a minute, longer than an HTTP
+// request should wait. A save runs in the background as an operation:
+// only one per robot at a time, a heartbeat with the elapsed time (and
+// the percentage, when the robot publishes save progress) every
+// mapSaveHeartbeat, and a final saved or failed state. The last few
+// operations stay queryable by ID.
+
+// ErrMapSaveInProgress is returned when a save is started while another
+// one runs on the same robot.
+var ErrMapSaveInProgress = errors.New("map save already in progress")
+
+// Map save states.
+const (
+	MapSaveSaving = "saving"
+	MapSaveDone   = "saved"
+	MapSaveFailed = "failed"
+)
+
+// DefaultMapSaveTimeout is how long the save service call may take.
+const DefaultMapSaveTimeout = 2 * time.Minute
+
+const (
+	mapSaveHeartbeat = 2 * time.Second
+	maxMapSaveOps    = 20
+)
+
+var mapSaveSeq atomic.Uint64
+
+// MapSaveOp is one background map save.
+type MapSaveOp struct {
+	ID         string     `json:"op"`
+	RobotID    string     `json:"robot_id"`
+	MapName    string     `json:"map_name"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	ElapsedSec float64    `json:"elapsed_sec"`
+	TimeoutSec float64    `json:"timeout_sec"`
+	Percent    *float64   `json:"percent,omitempty"` // only from robots publishing save progress
+	Error      string     `json:"error,omitempty"`
+}
+
+// SetMapSaveOptions sets the save service timeout (DefaultMapSaveTimeout
+// if not positive) and the robot's save progress topic ("" if it has
+// none).
+func (r *Robot) SetMapSaveOptions(timeout time.Duration, progressTopic string) {
+	if timeout <= 0 {
+		timeout = DefaultMapSaveTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mapSaveTimeout = timeout
+	r.mapSaveTopic = progressTopic
+}
+
+// StartMapSave saves the current map as name in the background and
+// returns the new operation. requestedBy is recorded in the map history.
+func (r *Robot) StartMapSave(name, requestedBy string) (MapSaveOp, error) {
+	if err := ValidateMapName(name); err != nil {
+		return MapSaveOp{}, err
+	}
+	if err := r.Require(rosbridge.CapMapSave); err != nil {
+		return MapSaveOp{}, err
+	}
+	if !r.IsConnected() {
+		return MapSaveOp{}, ErrNotConnected
+	}
+
+	r.mu.Lock()
+	if r.mapSave != nil && r.mapSave.State == MapSaveSaving {
+		r.mu.Unlock()
+		return MapSaveOp{}, ErrMapSaveInProgress
+	}
+	timeout, topic := r.mapSaveTimeout, r.mapSaveTopic
+	if timeout <= 0 {
+		timeout = DefaultMapSaveTimeout
+	}
+	op := &MapSaveOp{
+		ID:         fmt.Sprintf("save-%d", mapSaveSeq.Add(1)),
+		RobotID:    r.ID,
+		MapName:    name,
+		State:      MapSaveSaving,
+		StartedAt:  time.Now(),
+		TimeoutSec: timeout.Seconds(),
+	}
+	r.mapSave = op
+	r.mapSaveOps = append(r.mapSaveOps, op)
+	if len(r.mapSaveOps) > maxMapSaveOps {
+		r.mapSaveOps = r.mapSaveOps[len(r.mapSaveOps)-maxMapSaveOps:]
+	}
+	r.mu.Unlock()
+
+	go r.runMapSave(op, requestedBy, timeout, topic)
+	return r.emitMapSave(op), nil
+}
+
+// runMapSave calls the save service and reports progress until it
+// returns.
+func (r *Robot) runMapSave(op *MapSaveOp, requestedBy string, timeout time.Duration, topic string) {
+	if topic != "" {
+		r.Client.SubscribeMapSaveProgress(topic)
+		defer r.Client.UnsubscribeMapSaveProgress()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Client.SaveMapTimeout(op.MapName, timeout)
+		done <- err
+	}()
+
+	tick := time.NewTicker(mapSaveHeartbeat)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				r.addMapName(op.MapName)
+				r.RecordMapEvent(MapActionSave, op.MapName, requestedBy)
+			}
+			r.endMapSave(op, err)
+			r.emitMapSave(op)
+			return
+		case <-tick.C:
+			r.emitMapSave(op)
+		}
+	}
+}
+
+func (r *Robot) endMapSave(op *MapSaveOp, err error) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	op.EndedAt = &now
+	op.State = MapSaveDone
+	if err != nil {
+		op.State = MapSaveFailed
+		op.Error = err.Error()
+	}
+}
+
+// setMapSaveProgress records a percentage from the robot's progress
+// topic on the running save.
+func (r *Robot) setMapSaveProgress(pct float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if op := r.mapSave; op != nil && op.State == MapSaveSaving {
+		op.Percent = &pct
+	}
+}
+
+// GetMapSave returns a recent save operation by ID.
+func (r *Robot) GetMapSave(id string) (MapSaveOp, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, op := range r.mapSaveOps {
+		if op.ID == id {
+			return mapSaveCopy(op), true
+		}
+	}
+	return MapSaveOp{}, false
+}
+
+// MapSaveInProgress reports whether a save is running.
+func (r *Robot) MapSaveInProgress() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mapSaveInProgressLocked()
+}
+
+func (r *Robot) mapSaveInProgressLocked() bool {
+	return r.mapSave != nil && r.mapSave.State == MapSaveSaving
+}
+
+// mapSaveCopy copies op with its elapsed time. Caller holds r.mu.
+func mapSaveCopy(op *MapSaveOp) MapSaveOp {
+	c := *op
+	end := time.Now()
+	if c.EndedAt != nil {
+		end = *c.EndedAt
+	}
+	c.ElapsedSec = end.Sub(c.StartedAt).Seconds()
+	if c.Percent != nil {
+		pct := *c.Percent
+		c.Percent = &pct
+	}
+	return c
+}
+
+// emitMapSave broadcasts the operation and returns the copy it sent.
+func (r *Robot) emitMapSave(op *MapSaveOp) MapSaveOp {
+	r.mu.RLock()
+	c := mapSaveCopy(op)
+	r.mu.RUnlock()
+	if r.OnMapSave != nil {
+		r.OnMapSave(c)
+	}
+	return c
+}
@@ -0,0 +1,148 @@
+package robot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Map thumbnails
+//
+// Small PNG previews for the open-map dialog, drawn with the same gray
+// levels as the PGM export. They are written when a map is saved through
+// the app, or from the first map received after opening a map that has
+// none yet (maps saved on the robot directly).
+
+// ThumbnailWidth is the width of generated thumbnails in pixels.
+const ThumbnailWidth = 200
+
+// EncodeThumbnailPNG renders m at most width pixels wide. Each pixel
+// covers a block of cells; an occupied cell in the block wins over a
+// free one, which wins over unknown, so thin walls survive the scaling.
+func EncodeThumbnailPNG(m rosbridge.MapData, h MapRenderHints, width int) ([]byte, error) {
+	if m.Width == 0 || m.Height == 0 {
+		return nil, fmt.Errorf("empty map")
+	}
+	step := (m.Width + width - 1) / width
+	if step < 1 {
+		step = 1
+	}
+	w := (m.Width + step - 1) / step
+	ht := (m.Height + step - 1) / step
+
+	img := image.NewGray(image.Rect(0, 0, w, ht))
+	for y := 0; y < ht; y++ {
+		for x := 0; x < w; x++ {
+			class := CellUnknown
+			for row := y * step; row < (y+1)*step && row < m.Height && class != CellOccupied; row++ {
+				for col := x * step; col < (x+1)*step && col < m.Width; col++ {
+					idx := row*m.Width + col
+					if idx >= len(m.Data) {
+						continue
+					}
+					if c := h.Classify(m.Data[idx]); c == CellOccupied || (c == CellFree && class == CellUnknown) {
+						class = c
+					}
+				}
+			}
+			// Row 0 of the grid is the bottom of the image
+			img.SetGray(x, ht-1-y, color.Gray{Y: class.gray()})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ThumbnailStore keeps thumbnails on disk as <dir>/<robot>/<map>.png.
+type ThumbnailStore struct {
+	Dir string
+}
+
+// NewThumbnailStore returns a store rooted at dir.
+func NewThumbnailStore(dir string) *ThumbnailStore {
+	return &ThumbnailStore{Dir: dir}
+}
+
+// Path returns where the thumbnail of robotKey's map is stored.
+func (s *ThumbnailStore) Path(robotKey, mapName string) string {
+	return filepath.Join(s.Dir, safeFileName(robotKey), safeFileName(mapName)+".png")
+}
+
+// Exists reports whether a thumbnail is stored.
+func (s *ThumbnailStore) Exists(robotKey, mapName string) bool {
+	_, err := os.Stat(s.Path(robotKey, mapName))
+	return err == nil
+}
+
+// Save renders f and stores it, replacing any previous thumbnail.
+func (s *ThumbnailStore) Save(robotKey, mapName string, f MapFrame) error {
+	data, err := EncodeThumbnailPNG(f.MapData, f.RenderHints, ThumbnailWidth)
+	if err != nil {
+		return err
+	}
+	path := s.Path(robotKey, mapName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// safeFileName replaces anything but letters, digits, '-', '_' and '.'
+// so names can't escape the store directory.
+func safeFileName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+	if s == "" || strings.Trim(s, ".") == "" {
+		return "_"
+	}
+	return s
+}
+
+// StoreKey identifies the robot in the on-disk stores (thumbnails,
+// navigation points). IDs are assigned per run, so the namespace (or
+// name, or address) is used.
+func (r *Robot) StoreKey() string {
+	switch {
+	case r.Namespace != "":
+		return r.Namespace
+	case r.Name != "":
+		return r.Name
+	}
+	return fmt.Sprintf("%s_%d", r.IP, r.Port)
+}
+
+// WantMapThumbnail asks for a thumbnail of mapName from the next map
+// received; see OnMapThumbnail.
+func (r *Robot) WantMapThumbnail(mapName string) {
+	r.mu.Lock()
+	r.thumbnailWanted = mapName
+	r.mu.Unlock()
+}
+
+// takeThumbnailWantedLocked returns and clears the pending thumbnail request.
+// Caller holds r.mu.
+func (r *Robot) takeThumbnailWantedLocked() string {
+	name := r.thumbnailWanted
+	r.thumbnailWanted = ""
+	return name
+}
@@ -0,0 +1,208 @@
+package robot
+
+import (
+	"math"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── World ↔ grid ↔ image coordinates
+//
+// One place for the conversions between the map frame and a grid, so
+// validation, the points sent to the robot, exports and the browser
+// (through GET /api/maps/transform) agree:
+//   - world: metres in the map frame, θ counterclockwise from +x;
+//   - grid: cell (col, row) covers [col, col+1) × [row, row+1) in cell
+//     units from the grid origin, along the origin's axes, which are
+//     rotated by its yaw; row 0 is the first row of the data, the
+//     bottom of the map;
+//   - image: pixels from the top-left corner of the rendered map, x to
+//     the right, y down, so rows are flipped: the centre of cell
+//     (col, row) is at (col + 0.5, height − row − 0.5).
+//
+// Image headings are degrees counterclockwise as seen on the image,
+// from its x axis.
+
+// MapConventions spells out the coordinate conventions for API clients.
+type MapConventions struct {
+	World      string `json:"world"`
+	Grid       string `json:"grid"`
+	Image      string `json:"image"`
+	ImageTheta string `json:"image_theta"`
+}
+
+// Conventions are the conventions of MapTransform.
+var Conventions = MapConventions{
+	World:      "metres in the map frame; x and y as in ROS, theta in radians counterclockwise from +x",
+	Grid:       "cell (col, row) covers [col, col+1) x [row, row+1) cells from the map origin along its axes, rotated by origin_yaw; row 0 is the first row of the data and the bottom of the map",
+	Image:      "pixels from the top-left corner of the rendered map, x to the right, y down; rows are flipped, so the centre of cell (col, row) is at (col + 0.5, height - row - 0.5)",
+	ImageTheta: "degrees counterclockwise as seen on the image, from its x axis (theta - origin_yaw), within (-180, 180]",
+}
+
+// MapTransform converts coordinates for one grid's geometry.
+type MapTransform struct {
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Resolution float64 `json:"resolution"`
+	OriginX    float64 `json:"origin_x"`
+	OriginY    float64 `json:"origin_y"`
+	OriginYaw  float64 `json:"origin_yaw"`
+}
+
+// NewMapTransform returns the transform of m's geometry.
+func NewMapTransform(m rosbridge.MapData) MapTransform {
+	return MapTransform{
+		Width: m.Width, Height: m.Height, Resolution: m.Resolution,
+		OriginX: m.OriginX, OriginY: m.OriginY, OriginYaw: m.OriginYaw,
+	}
+}
+
+// Valid reports whether the geometry can convert anything.
+func (t MapTransform) Valid() bool {
+	return t.Width > 0 && t.Height > 0 && t.Resolution > 0
+}
+
+// worldToGrid returns world point (x, y) in cell units from the grid
+// origin, along the grid axes.
+func (t MapTransform) worldToGrid(x, y float64) (gx, gy float64) {
+	dx, dy := x-t.OriginX, y-t.OriginY
+	s, c := math.Sincos(t.OriginYaw)
+	return (c*dx + s*dy) / t.Resolution, (-s*dx + c*dy) / t.Resolution
+}
+
+// gridToWorld is the inverse of worldToGrid.
+func (t MapTransform) gridToWorld(gx, gy float64) (x, y float64) {
+	gx, gy = gx*t.Resolution, gy*t.Resolution
+	s, c := math.Sincos(t.OriginYaw)
+	return t.OriginX + c*gx - s*gy, t.OriginY + s*gx + c*gy
+}
+
+// InBounds reports whether cell (col, row) is in the grid.
+func (t MapTransform) InBounds(col, row int) bool {
+	return col >= 0 && row >= 0 && col < t.Width && row < t.Height
+}
+
+// WorldToCell returns the cell containing world point (x, y); ok is
+// false when it lies outside the grid. A point on a cell edge belongs
+// to the cell above and to the right of it.
+func (t MapTransform) WorldToCell(x, y float64) (col, row int, ok bool) {
+	gx, gy := t.worldToGrid(x, y)
+	col, row = int(math.Floor(gx)), int(math.Floor(gy))
+	return col, row, t.InBounds(col, row)
+}
+
+// CellToWorld returns the world coordinates of the centre of cell
+// (col, row).
+func (t MapTransform) CellToWorld(col, row int) (x, y float64) {
+	return t.gridToWorld(float64(col)+0.5, float64(row)+0.5)
+}
+
+// WorldToImagePx returns the image position of world point (x, y).
+func (t MapTransform) WorldToImagePx(x, y float64) (px, py float64) {
+	gx, gy := t.worldToGrid(x, y)
+	return gx, float64(t.Height) - gy
+}
+
+// ImagePxToWorld is the inverse of WorldToImagePx.
+func (t MapTransform) ImagePxToWorld(px, py float64) (x, y float64) {
+	return t.gridToWorld(px, float64(t.Height)-py)
+}
+
+// ImagePxToCell returns the cell drawn at image pixel (px, py), for
+// integer pixel indices as well as positions: pixel (i, j) covers
+// [i, i+1) × [j, j+1).
+func (t MapTransform) ImagePxToCell(px, py float64) (col, row int, ok bool) {
+	col, row = int(math.Floor(px)), t.ImageRowOf(int(math.Floor(py)))
+	return col, row, t.InBounds(col, row)
+}
+
+// ImageRowOf returns the image row a grid row is drawn on.
+func (t MapTransform) ImageRowOf(row int) int {
+	return t.Height - 1 - row
+}
+
+// WorldToImageTheta converts a heading in the map frame to degrees on
+// the image, normalized to (-180, 180].
+func (t MapTransform) WorldToImageTheta(theta float64) float64 {
+	deg := (theta - t.OriginYaw) * 180 / math.Pi
+	deg = math.Mod(deg, 360)
+	switch {
+	case deg > 180:
+		deg -= 360
+	case deg <= -180:
+		deg += 360
+	}
+	return deg
+}
+
+// ImageThetaToWorld is the inverse of WorldToImageTheta, in radians
+// within ±π.
+func (t MapTransform) ImageThetaToWorld(deg float64) float64 {
+	return normalizeAngle(deg*math.Pi/180 + t.OriginYaw)
+}
+
+// CellValue returns the occupancy value of cell (col, row) of m; ok is
+// false outside the grid.
+func CellValue(m rosbridge.MapData, col, row int) (v int8, ok bool) {
+	if !NewMapTransform(m).InBounds(col, row) {
+		return -1, false
+	}
+	idx := row*m.Width + col
+	if idx >= len(m.Data) {
+		return -1, false
+	}
+	return m.Data[idx], true
+}
+
+// WithImagePx returns p with its image position and heading filled in
+// from its world pose.
+func (t MapTransform) WithImagePx(p rosbridge.NavigationPoint) rosbridge.NavigationPoint {
+	p.ImageXPx, p.ImageYPx = t.WorldToImagePx(p.WorldXM, p.WorldYM)
+	p.ImageThetaDeg = t.WorldToImageTheta(p.WorldThetaRad)
+	return p
+}
+
+// WallWithImagePx returns w with its image end points filled in from
+// its world ones.
+func (t MapTransform) WallWithImagePx(w rosbridge.WallObstacle) rosbridge.WallObstacle {
+	w.ImageXPxStart, w.ImageYPxStart = t.WorldToImagePx(w.WorldXMStart, w.WorldYMStart)
+	w.ImageXPxEnd, w.ImageYPxEnd = t.WorldToImagePx(w.WorldXMEnd, w.WorldYMEnd)
+	return w
+}
+
+// MapTransform returns the transform of the robot's current grid; ok is
+// false until a map was received.
+func (r *Robot) MapTransform() (t MapTransform, ok bool) {
+	r.mu.RLock()
+	t = NewMapTransform(r.Map)
+	r.mu.RUnlock()
+	return t, t.Valid()
+}
+
+// pointsWithImagePx returns copies of pts with image positions computed
+// from the robot's current map, as the robot expects them next to the
+// world pose; pts as they are before a map arrived.
+func (r *Robot) pointsWithImagePx(pts []rosbridge.NavigationPoint) []rosbridge.NavigationPoint {
+	t, ok := r.MapTransform()
+	if !ok {
+		return pts
+	}
+	out := make([]rosbridge.NavigationPoint, len(pts))
+	for i, p := range pts {
+		out[i] = t.WithImagePx(p)
+	}
+	return out
+}
+
+// wallsWithImagePx is pointsWithImagePx for walls.
+func (r *Robot) wallsWithImagePx(walls []rosbridge.WallObstacle) []rosbridge.WallObstacle {
+	t, ok := r.MapTransform()
+	if !ok {
+		return walls
+	}
+	out := make([]rosbridge.WallObstacle, len(walls))
+	for i, w := range walls {
+		out[i] = t.WallWithImagePx(w)
+	}
+	return out
+}
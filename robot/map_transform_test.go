@@ -0,0 +1,173 @@
+package robot
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+// transform4x3 is a 4×3 grid of 0.5 m cells with its origin at (1, 2).
+func transform4x3(yaw float64) MapTransform {
+	return MapTransform{Width: 4, Height: 3, Resolution: 0.5, OriginX: 1, OriginY: 2, OriginYaw: yaw}
+}
+
+func TestMapTransformEdges(t *testing.T) {
+	tr := transform4x3(0)
+	for _, c := range []struct {
+		x, y     float64
+		col, row int
+		ok       bool
+	}{
+		{1, 2, 0, 0, true},         // the origin corner
+		{1.5, 2.5, 1, 1, true},     // an inner corner: above and to the right
+		{2.999, 3.499, 3, 2, true}, // just inside the far corner
+		{3, 2, 4, 0, false},        // the right edge is outside
+		{1, 3.5, 0, 3, false},      // so is the top edge
+		{0.999, 2, -1, 0, false},   // just left of the origin
+		{1, 1.999, 0, -1, false},   // just below it
+		{-1000, 2000, -2002, 3996, false},
+	} {
+		col, row, ok := tr.WorldToCell(c.x, c.y)
+		if col != c.col || row != c.row || ok != c.ok {
+			t.Errorf("(%v, %v): cell (%d, %d) %v, want (%d, %d) %v", c.x, c.y, col, row, ok, c.col, c.row, c.ok)
+		}
+	}
+
+	// The image has row 0 at the bottom and y down
+	if px, py := tr.WorldToImagePx(1, 2); px != 0 || py != 3 {
+		t.Errorf("origin at pixel (%v, %v), want the bottom-left corner", px, py)
+	}
+	if px, py := tr.WorldToImagePx(tr.CellToWorld(0, 0)); px != 0.5 || py != 2.5 {
+		t.Errorf("cell (0, 0) centre at pixel (%v, %v)", px, py)
+	}
+	for _, c := range []struct {
+		px, py   float64
+		col, row int
+		ok       bool
+	}{
+		{0, 0, 0, 2, true},       // top-left pixel: the last row
+		{3.99, 2.99, 3, 0, true}, // bottom-right pixel: the first row
+		{4, 0, 4, 2, false},
+		{0, 3, 0, -1, false},
+		{-0.01, 1, -1, 1, false},
+	} {
+		col, row, ok := tr.ImagePxToCell(c.px, c.py)
+		if col != c.col || row != c.row || ok != c.ok {
+			t.Errorf("pixel (%v, %v): cell (%d, %d) %v, want (%d, %d) %v", c.px, c.py, col, row, ok, c.col, c.row, c.ok)
+		}
+	}
+	if tr.ImageRowOf(0) != 2 || tr.ImageRowOf(2) != 0 {
+		t.Errorf("image rows %d, %d", tr.ImageRowOf(0), tr.ImageRowOf(2))
+	}
+}
+
+// TestMapTransformRotated turns the origin a quarter turn: the grid's x
+// axis points along world +y and its y axis along world −x.
+func TestMapTransformRotated(t *testing.T) {
+	tr := transform4x3(math.Pi / 2)
+	if x, y := tr.CellToWorld(0, 0); !near(x, 0.75) || !near(y, 2.25) {
+		t.Errorf("cell (0, 0) centre at (%v, %v)", x, y)
+	}
+	if x, y := tr.CellToWorld(3, 2); !near(x, -0.25) || !near(y, 3.75) {
+		t.Errorf("cell (3, 2) centre at (%v, %v)", x, y)
+	}
+	// Up and left of the origin is in the grid; up and right isn't, as it
+	// would be unrotated
+	if col, row, ok := tr.WorldToCell(0.9, 2.1); !ok || col != 0 || row != 0 {
+		t.Errorf("(0.9, 2.1): cell (%d, %d) %v", col, row, ok)
+	}
+	if _, row, ok := tr.WorldToCell(1.1, 2.1); ok || row != -1 {
+		t.Errorf("(1.1, 2.1): row %d %v", row, ok)
+	}
+
+	// Headings on the image are relative to the grid's axes
+	for _, c := range []struct{ theta, deg float64 }{
+		{math.Pi / 2, 0},
+		{math.Pi, 90},
+		{0, -90},
+		{-math.Pi / 2, 180}, // -180 normalized
+		{5 * math.Pi / 2, 0},
+	} {
+		if deg := tr.WorldToImageTheta(c.theta); !near(deg, c.deg) {
+			t.Errorf("theta %v: %v°, want %v°", c.theta, deg, c.deg)
+		}
+	}
+	if theta := tr.ImageThetaToWorld(180); !near(theta, -math.Pi/2) {
+		t.Errorf("180° on the image: theta %v", theta)
+	}
+}
+
+func TestMapTransformRoundTrip(t *testing.T) {
+	for _, yaw := range []float64{0, math.Pi / 2, 0.3, -2.5, math.Pi} {
+		tr := transform4x3(yaw)
+		for row := 0; row < tr.Height; row++ {
+			for col := 0; col < tr.Width; col++ {
+				x, y := tr.CellToWorld(col, row)
+				if c, r, ok := tr.WorldToCell(x, y); !ok || c != col || r != row {
+					t.Errorf("yaw %v: cell (%d, %d) → world → (%d, %d) %v", yaw, col, row, c, r, ok)
+				}
+				px, py := tr.WorldToImagePx(x, y)
+				if c, r, ok := tr.ImagePxToCell(px, py); !ok || c != col || r != row {
+					t.Errorf("yaw %v: cell (%d, %d) → image → (%d, %d) %v", yaw, col, row, c, r, ok)
+				}
+				if wx, wy := tr.ImagePxToWorld(px, py); !near(wx, x) || !near(wy, y) {
+					t.Errorf("yaw %v: (%v, %v) → image → (%v, %v)", yaw, x, y, wx, wy)
+				}
+			}
+		}
+		for _, deg := range []float64{-179, -90, 0, 45, 180} {
+			if got := tr.WorldToImageTheta(tr.ImageThetaToWorld(deg)); !near(got, deg) {
+				t.Errorf("yaw %v: %v° → world → %v°", yaw, deg, got)
+			}
+		}
+	}
+}
+
+func TestCellValue(t *testing.T) {
+	m := rosbridge.MapData{Width: 2, Height: 2, Resolution: 1, Data: []int8{0, 100, -1, 50}}
+	if v, ok := CellValue(m, 1, 1); !ok || v != 50 {
+		t.Errorf("cell (1, 1) = %d %v", v, ok)
+	}
+	if v, ok := CellValue(m, 2, 0); ok || v != -1 {
+		t.Errorf("outside the grid = %d %v", v, ok)
+	}
+	m.Data = m.Data[:3] // shorter than the geometry says
+	if _, ok := CellValue(m, 1, 1); ok {
+		t.Error("read past the data")
+	}
+}
+
+// TestEncodePGM checks the PGM has the first grid row at the bottom.
+func TestEncodePGM(t *testing.T) {
+	m := rosbridge.MapData{Width: 2, Height: 2, Resolution: 0.05, OriginYaw: 0.5, Data: []int8{0, 100, -1, 0}}
+	out := EncodePGM(m, DefaultRenderHints())
+	i := bytes.Index(out, []byte("2 2\n255\n"))
+	if !bytes.HasPrefix(out, []byte("P5\n")) || i < 0 || !bytes.Contains(out[:i], []byte("0.5000")) {
+		t.Fatalf("header %q", out)
+	}
+	pixels := out[i+len("2 2\n255\n"):]
+	if want := []byte{pgmUnknown, pgmFree, pgmFree, pgmOccupied}; !bytes.Equal(pixels, want) {
+		t.Errorf("pixels %v, want %v", pixels, want)
+	}
+
+	// Invert is a display hint: the export is the same
+	inverted := DefaultRenderHints()
+	inverted.Invert = true
+	if again := EncodePGM(m, inverted); !bytes.Equal(again, out) {
+		t.Errorf("inverted export %v, want %v", again, out)
+	}
+}
+
+func TestWithImagePx(t *testing.T) {
+	tr := transform4x3(math.Pi / 2)
+	p := tr.WithImagePx(rosbridge.NavigationPoint{WorldXM: 0.75, WorldYM: 2.25, WorldThetaRad: math.Pi})
+	if !near(p.ImageXPx, 0.5) || !near(p.ImageYPx, 2.5) || !near(p.ImageThetaDeg, 90) {
+		t.Errorf("point at pixel (%v, %v) %v°", p.ImageXPx, p.ImageYPx, p.ImageThetaDeg)
+	}
+	w := tr.WallWithImagePx(rosbridge.WallObstacle{WorldXMStart: 1, WorldYMStart: 2, WorldXMEnd: 1, WorldYMEnd: 4})
+	if !near(w.ImageXPxStart, 0) || !near(w.ImageYPxStart, 3) || !near(w.ImageXPxEnd, 4) || !near(w.ImageYPxEnd, 3) {
+		t.Errorf("wall %+v", w)
+	}
+}
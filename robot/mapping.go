@@ -0,0 +1,322 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Modes
+
+// SwitchMode asks the robot to enter navigation, mapping or remapping
+// and records the mode on success.
+func (r *Robot) SwitchMode(m Mode) error {
+	if !r.IsConnected() {
+		return ErrNotConnected
+	}
+	var err error
+	switch m {
+	case ModeNavigation:
+		_, err = r.Client.RequestNavigationMode()
+	case ModeMapping:
+		if err = r.Require(rosbridge.CapMapping); err == nil {
+			_, err = r.Client.RequestMappingMode()
+		}
+	case ModeRemapping:
+		if err = r.Require(rosbridge.CapRemapping); err == nil {
+			_, err = r.Client.RequestRemappingMode()
+		}
+	default:
+		return fmt.Errorf("mode %q is not a robot mode", m)
+	}
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	prev := r.mode
+	r.mode = m
+	if prev != m {
+		r.recordEventLocked(TimelineMode, ModeChange{Mode: m, Previous: prev})
+	}
+	r.mu.Unlock()
+	if prev != m && r.OnMode != nil {
+		r.OnMode(ModeChange{Mode: m, Previous: prev})
+	}
+	return nil
+}
+
+// ModeChange reports a switch to another mode; Previous is "" when the
+// mode was unknown.
+type ModeChange struct {
+	Mode     Mode `json:"mode"`
+	Previous Mode `json:"previous"`
+}
+
+// GetMode returns the last mode switched to through this server, or ""
+// if unknown.
+func (r *Robot) GetMode() Mode {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mode
+}
+
+// ──────────────────────────── Mapping sessions
+//
+// A session wraps one mapping run: switch to mapping, track coverage from
+// incoming map frames, then either save the map, return to navigation and
+// open it (finish) or restore the previous mode without saving (abort).
+// One session runs per robot; the last one stays readable until the next
+// start.
+
+// Mapping session errors.
+var (
+	ErrMappingActive   = errors.New("mapping session already active")
+	ErrNoMappingActive = errors.New("no active mapping session")
+)
+
+// Mapping session states.
+const (
+	MappingActive   = "mapping"
+	MappingSaving   = "saving"
+	MappingFinished = "finished"
+	MappingAborted  = "aborted"
+	MappingFailed   = "failed"
+)
+
+// MappingStats is the coverage of the map being built. Known cells are
+// any observed value; free/occupied follow the robot's render hints.
+type MappingStats struct {
+	Updates        int       `json:"updates"`
+	KnownCells     int       `json:"known_cells"`
+	FreeCells      int       `json:"free_cells"`
+	OccupiedCells  int       `json:"occupied_cells"`
+	AreaM2         float64   `json:"area_m2"`           // known cells × resolution²
+	BoundsWidthM   float64   `json:"bounds_width_m"`    // bounding box of known cells
+	BoundsHeightM  float64   `json:"bounds_height_m"`   //
+	BoundsGrowthM2 float64   `json:"bounds_growth_m2"`  // box area gained since the first frame
+	GrowthM2PerMin float64   `json:"growth_m2_per_min"` // smoothed, see MappingProgress
+	Stabilized     bool      `json:"stabilized"`
+	MapWidth       int       `json:"map_width"`
+	MapHeight      int       `json:"map_height"`
+	Resolution     float64   `json:"resolution"`
+	LastUpdate     time.Time `json:"last_update"`
+
+	firstBoundsM2 float64
+}
+
+// MappingSession is a guided mapping run.
+type MappingSession struct {
+	RobotID      string       `json:"robot_id"`
+	MapName      string       `json:"map_name"`
+	State        string       `json:"state"`
+	PreviousMode Mode         `json:"previous_mode"`
+	StartedAt    time.Time    `json:"started_at"`
+	EndedAt      *time.Time   `json:"ended_at,omitempty"`
+	DurationSec  float64      `json:"duration_sec"`
+	Stats        MappingStats `json:"stats"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// StartMapping switches the robot to mapping and opens a session that
+// will be saved as mapName.
+func (r *Robot) StartMapping(mapName string) (MappingSession, error) {
+	if err := ValidateMapName(mapName); err != nil {
+		return MappingSession{}, err
+	}
+	// A session that can't be saved would be lost
+	if err := r.Require(rosbridge.CapMapSave); err != nil {
+		return MappingSession{}, err
+	}
+
+	r.mu.Lock()
+	if s := r.mapping; s != nil && (s.State == MappingActive || s.State == MappingSaving) {
+		r.mu.Unlock()
+		return MappingSession{}, ErrMappingActive
+	}
+	if !r.connected {
+		r.mu.Unlock()
+		return MappingSession{}, ErrNotConnected
+	}
+	s := &MappingSession{
+		RobotID:      r.ID,
+		MapName:      mapName,
+		State:        MappingActive,
+		PreviousMode: r.mode,
+		StartedAt:    time.Now(),
+	}
+	prev := r.mapping
+	r.mapping = s
+	r.mu.Unlock()
+
+	if err := r.SwitchMode(ModeMapping); err != nil {
+		r.mu.Lock()
+		if r.mapping == s {
+			r.mapping = prev
+		}
+		r.mu.Unlock()
+		return MappingSession{}, err
+	}
+	return r.emitMapping(), nil
+}
+
+// FinishMapping saves the map, switches to navigation and opens the new
+// map. A failed save leaves the session running so it can be retried;
+// a failure after the save ends the session as failed.
+func (r *Robot) FinishMapping() (MappingSession, error) {
+	s, err := r.setMappingState(MappingActive, MappingSaving)
+	if err != nil {
+		return MappingSession{}, err
+	}
+	r.emitMapping()
+
+	if _, err := r.Client.SaveMap(s.MapName); err != nil {
+		r.setMappingState(MappingSaving, MappingActive)
+		r.emitMapping()
+		return MappingSession{}, fmt.Errorf("save map: %w", err)
+	}
+	r.addMapName(s.MapName)
+	r.startFreshMap(s.MapName)
+	r.RecordMapEvent(MapActionSave, s.MapName, "")
+
+	err = r.SwitchMode(ModeNavigation)
+	if err == nil {
+		if _, serr := r.Client.SelectMap(s.MapName); serr != nil {
+			err = fmt.Errorf("open map: %w", serr)
+		} else {
+			r.RecordMapEvent(MapActionOpen, s.MapName, "")
+		}
+	} else {
+		err = fmt.Errorf("navigation mode: %w", err)
+	}
+
+	state := MappingFinished
+	if err != nil {
+		state = MappingFailed
+	}
+	r.endMapping(state, err)
+	return r.emitMapping(), err
+}
+
+// AbortMapping ends the session without saving and restores the mode the
+// robot was in before it started (navigation if unknown).
+func (r *Robot) AbortMapping() (MappingSession, error) {
+	s, err := r.setMappingState(MappingActive, MappingAborted)
+	if err != nil {
+		return MappingSession{}, err
+	}
+
+	restore := s.PreviousMode
+	if restore == "" {
+		restore = ModeNavigation
+	}
+	if restore != ModeMapping {
+		err = r.SwitchMode(restore)
+	}
+	r.endMapping(MappingAborted, err)
+	return r.emitMapping(), err
+}
+
+// GetMappingSession returns the active or last session, or nil.
+func (r *Robot) GetMappingSession() *MappingSession {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mappingLocked()
+}
+
+func (r *Robot) mappingLocked() *MappingSession {
+	if r.mapping == nil {
+		return nil
+	}
+	s := *r.mapping
+	if s.EndedAt == nil {
+		s.DurationSec = time.Since(s.StartedAt).Seconds()
+	}
+	return &s
+}
+
+// setMappingState moves the session from one state to another and
+// returns a copy.
+func (r *Robot) setMappingState(from, to string) (MappingSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.mapping
+	if s == nil || s.State != from {
+		if s != nil && s.State == MappingSaving {
+			return MappingSession{}, fmt.Errorf("mapping session is saving")
+		}
+		return MappingSession{}, ErrNoMappingActive
+	}
+	s.State = to
+	return *s, nil
+}
+
+func (r *Robot) endMapping(state string, err error) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.mapping
+	s.State = state
+	s.EndedAt = &now
+	s.DurationSec = now.Sub(s.StartedAt).Seconds()
+	if err != nil {
+		s.Error = err.Error()
+	}
+}
+
+// updateMappingStats records the coverage of a new map frame, as
+// tracked by the mapping progress, while a session is active.
+func (r *Robot) updateMappingStats(p MappingProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.mapping
+	if s == nil || s.State != MappingActive {
+		return
+	}
+	st := MappingStats{
+		Updates:        s.Stats.Updates + 1,
+		KnownCells:     p.KnownCells,
+		FreeCells:      p.FreeCells,
+		OccupiedCells:  p.OccupiedCells,
+		AreaM2:         p.AreaM2,
+		BoundsWidthM:   p.BoundsWidthM,
+		BoundsHeightM:  p.BoundsHeightM,
+		GrowthM2PerMin: p.GrowthM2PerMin,
+		Stabilized:     p.Stabilized,
+		MapWidth:       p.MapWidth,
+		MapHeight:      p.MapHeight,
+		Resolution:     p.Resolution,
+		LastUpdate:     p.LastUpdate,
+		firstBoundsM2:  s.Stats.firstBoundsM2,
+	}
+	boundsM2 := st.BoundsWidthM * st.BoundsHeightM
+	if st.Updates == 1 {
+		st.firstBoundsM2 = boundsM2
+	}
+	st.BoundsGrowthM2 = boundsM2 - st.firstBoundsM2
+	s.Stats = st
+}
+
+func (r *Robot) addMapName(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range r.MapList {
+		if n == name {
+			return
+		}
+	}
+	r.MapList = append(r.MapList, name)
+}
+
+// emitMapping broadcasts the session and returns the copy it sent.
+func (r *Robot) emitMapping() MappingSession {
+	s := r.GetMappingSession()
+	if s == nil {
+		return MappingSession{}
+	}
+	if r.OnMappingSession != nil {
+		r.OnMappingSession(*s)
+	}
+	return *s
+}
@@ -0,0 +1,353 @@
+package robot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Mapping progress
+//
+// While the robot maps, each new grid is compared with the previous one
+// to tell the operator how the map grows: known, free and occupied cells,
+// the bounding box of what is known, and the growth in known area per
+// minute, smoothed. Once growth stays below a threshold for a while the
+// coverage is flagged as stabilized, a hint that driving on adds little.
+//
+// Grids arrive whole, several times a second for large maps, so counts
+// are kept per row: a row whose cells are unchanged keeps its counts
+// and only changed rows are classified again. A change of size, origin,
+// resolution or render hints (the map grew past its edge) rescans the
+// whole grid.
+
+// MappingProgressInterval is how often "mapping_progress" is broadcast
+// while a robot maps.
+const MappingProgressInterval = 5 * time.Second
+
+// Mapping progress defaults.
+const (
+	DefaultMappingStableM2PerMin = 1.0
+	DefaultMappingStablePeriod   = 60 * time.Second
+)
+
+// mappingGrowthTau is the time constant of the smoothed growth rate.
+const mappingGrowthTau = 30 * time.Second
+
+// MappingProgress is the coverage of the map being built.
+type MappingProgress struct {
+	Active        bool      `json:"active"` // the robot is mapping
+	Updates       int       `json:"updates"`
+	FullScans     int       `json:"full_scans"` // grids whose geometry changed, counted from scratch
+	KnownCells    int       `json:"known_cells"`
+	FreeCells     int       `json:"free_cells"`
+	OccupiedCells int       `json:"occupied_cells"`
+	AreaM2        float64   `json:"area_m2"` // known cells × resolution²
+	BoundsWidthM  float64   `json:"bounds_width_m"`
+	BoundsHeightM float64   `json:"bounds_height_m"`
+	MapWidth      int       `json:"map_width"`
+	MapHeight     int       `json:"map_height"`
+	Resolution    float64   `json:"resolution"`
+	StartedAt     time.Time `json:"started_at"`
+	LastUpdate    time.Time `json:"last_update"`
+
+	// Known cells gained per minute, exponentially smoothed.
+	GrowthCellsPerMin float64 `json:"growth_cells_per_min"`
+	GrowthM2PerMin    float64 `json:"growth_m2_per_min"`
+
+	// Stabilized is set once growth stayed below StableM2PerMin for
+	// StablePeriodSec; StableForSec is how long it has been below.
+	Stabilized      bool    `json:"stabilized"`
+	StableForSec    float64 `json:"stable_for_s"`
+	StableM2PerMin  float64 `json:"stable_m2_per_min"`
+	StablePeriodSec float64 `json:"stable_period_s"`
+}
+
+// rowCoverage is the coverage of one grid row.
+type rowCoverage struct {
+	known, free, occupied int
+	minX, maxX            int // known cells; minX > maxX when none
+}
+
+// mappingTracker maintains MappingProgress from successive grids.
+type mappingTracker struct {
+	mu sync.Mutex
+	mappingState
+}
+
+// mappingState is a tracker's run, apart from its lock so reset can
+// clear it while the lock is held.
+type mappingState struct {
+	// Geometry of prev; a different one forces a full scan.
+	width, height    int
+	res, originX     float64
+	originY          float64
+	originYaw        float64
+	hints            MapRenderHints
+	prev             []int8
+	rows             []rowCoverage
+	known, free, occ int
+
+	// Growth
+	started    time.Time
+	last       time.Time
+	lastKnown  int
+	rate       float64 // cells per minute
+	belowSince time.Time
+	updates    int
+	fullScans  int
+	announced  bool // stabilization was reported
+	stableRate float64
+	stableFor  time.Duration
+}
+
+// reset forgets everything; the next grid starts a new run. Caller
+// holds t.mu.
+func (t *mappingTracker) reset() {
+	t.mappingState = mappingState{}
+}
+
+// update takes in a new grid. stableRate is in m² per minute.
+func (t *mappingTracker) update(m rosbridge.MapData, h MapRenderHints, now time.Time, stableRate float64, stableFor time.Duration) {
+	if m.Width <= 0 || m.Height <= 0 || len(m.Data) < m.Width*m.Height {
+		return
+	}
+	t.stableRate, t.stableFor = stableRate, stableFor
+
+	if t.prev == nil || m.Width != t.width || m.Height != t.height || m.Resolution != t.res ||
+		m.OriginX != t.originX || m.OriginY != t.originY || m.OriginYaw != t.originYaw || h != t.hints {
+		t.width, t.height, t.res = m.Width, m.Height, m.Resolution
+		t.originX, t.originY, t.originYaw, t.hints = m.OriginX, m.OriginY, m.OriginYaw, h
+		t.prev = make([]int8, m.Width*m.Height)
+		t.rows = make([]rowCoverage, m.Height)
+		t.known, t.free, t.occ = 0, 0, 0
+		for y := range t.rows {
+			row := m.Data[y*m.Width : (y+1)*m.Width]
+			t.rows[y] = countRow(row, h)
+			t.add(t.rows[y], 1)
+		}
+		t.fullScans++
+	} else {
+		for y := range t.rows {
+			row := m.Data[y*m.Width : (y+1)*m.Width]
+			if slices.Equal(row, t.prev[y*m.Width:(y+1)*m.Width]) {
+				continue
+			}
+			t.add(t.rows[y], -1)
+			t.rows[y] = countRow(row, h)
+			t.add(t.rows[y], 1)
+		}
+	}
+	copy(t.prev, m.Data[:m.Width*m.Height])
+
+	// Growth rate, smoothed over mappingGrowthTau
+	if t.updates == 0 {
+		t.started = now
+	} else if dt := now.Sub(t.last); dt > 0 {
+		inst := float64(t.known-t.lastKnown) / dt.Minutes()
+		alpha := 1 - math.Exp(-float64(dt)/float64(mappingGrowthTau))
+		if t.updates == 1 {
+			alpha = 1
+		}
+		t.rate += alpha * (inst - t.rate)
+		if t.rate*t.res*t.res < stableRate {
+			if t.belowSince.IsZero() {
+				t.belowSince = now
+			}
+		} else {
+			t.belowSince = time.Time{}
+			t.announced = false
+		}
+	}
+	t.updates++
+	t.last, t.lastKnown = now, t.known
+}
+
+func (t *mappingTracker) add(rc rowCoverage, sign int) {
+	t.known += sign * rc.known
+	t.free += sign * rc.free
+	t.occ += sign * rc.occupied
+}
+
+// countRow classifies the cells of one row.
+func countRow(row []int8, h MapRenderHints) rowCoverage {
+	rc := rowCoverage{minX: len(row), maxX: -1}
+	for x, v := range row {
+		if v < 0 {
+			continue
+		}
+		rc.known++
+		switch h.Classify(v) {
+		case CellFree:
+			rc.free++
+		case CellOccupied:
+			rc.occupied++
+		}
+		rc.minX, rc.maxX = min(rc.minX, x), max(rc.maxX, x)
+	}
+	return rc
+}
+
+// progress returns the current figures.
+func (t *mappingTracker) progress(now time.Time) MappingProgress {
+	p := MappingProgress{
+		Updates:         t.updates,
+		FullScans:       t.fullScans,
+		KnownCells:      t.known,
+		FreeCells:       t.free,
+		OccupiedCells:   t.occ,
+		MapWidth:        t.width,
+		MapHeight:       t.height,
+		Resolution:      t.res,
+		StartedAt:       t.started,
+		LastUpdate:      t.last,
+		StableM2PerMin:  t.stableRate,
+		StablePeriodSec: t.stableFor.Seconds(),
+	}
+	if t.updates == 0 {
+		return p
+	}
+	cell := t.res * t.res
+	p.AreaM2 = float64(t.known) * cell
+	p.GrowthCellsPerMin = t.rate
+	p.GrowthM2PerMin = t.rate * cell
+
+	minX, maxX, minY, maxY := t.width, -1, -1, -1
+	for y, rc := range t.rows {
+		if rc.known == 0 {
+			continue
+		}
+		if minY < 0 {
+			minY = y
+		}
+		maxY = y
+		minX, maxX = min(minX, rc.minX), max(maxX, rc.maxX)
+	}
+	if maxX >= 0 {
+		p.BoundsWidthM = float64(maxX-minX+1) * t.res
+		p.BoundsHeightM = float64(maxY-minY+1) * t.res
+	}
+	if !t.belowSince.IsZero() {
+		p.StableForSec = now.Sub(t.belowSince).Seconds()
+		p.Stabilized = now.Sub(t.belowSince) >= t.stableFor
+	}
+	return p
+}
+
+// mappingProgressActiveLocked reports whether progress is tracked: the robot is
+// in a mapping mode or has a mapping session running. Caller holds r.mu.
+func (r *Robot) mappingProgressActiveLocked() bool {
+	if r.mode == ModeMapping || r.mode == ModeRemapping {
+		return true
+	}
+	return r.mapping != nil && r.mapping.State == MappingActive
+}
+
+// SetMappingProgressOptions sets the growth (m² per minute) below which
+// coverage counts as stable and for how long it must stay there;
+// defaults when not positive.
+func (r *Robot) SetMappingProgressOptions(stableM2PerMin float64, stableFor time.Duration) {
+	if stableM2PerMin <= 0 {
+		stableM2PerMin = DefaultMappingStableM2PerMin
+	}
+	if stableFor <= 0 {
+		stableFor = DefaultMappingStablePeriod
+	}
+	r.mu.Lock()
+	r.mappingStableRate, r.mappingStableFor = stableM2PerMin, stableFor
+	r.mu.Unlock()
+}
+
+// trackMappingProgress takes in a received grid while the robot maps and
+// returns the progress; it forgets the last run's figures once the robot
+// no longer maps.
+func (r *Robot) trackMappingProgress(m rosbridge.MapData) (MappingProgress, bool) {
+	r.mu.RLock()
+	active := r.mappingProgressActiveLocked()
+	hints, rate, period := r.renderHints, r.mappingStableRate, r.mappingStableFor
+	r.mu.RUnlock()
+
+	t := &r.mappingProgress
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !active {
+		if t.updates > 0 {
+			t.reset()
+		}
+		return MappingProgress{}, false
+	}
+	now := time.Now()
+	t.update(m, hints, now, rate, period)
+	p := t.progress(now)
+	p.Active = true
+	return p, true
+}
+
+// MappingProgress returns the coverage of the map being built; Active
+// is false, with the figures of the last run, when the robot isn't
+// mapping.
+func (r *Robot) MappingProgress() MappingProgress {
+	r.mu.RLock()
+	active := r.mappingProgressActiveLocked()
+	rate, period := r.mappingStableRate, r.mappingStableFor
+	r.mu.RUnlock()
+
+	t := &r.mappingProgress
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.progress(time.Now())
+	p.Active = active
+	p.StableM2PerMin, p.StablePeriodSec = rate, period.Seconds()
+	return p
+}
+
+// takeStabilized reports, once per stable stretch, that coverage has
+// stabilized.
+func (r *Robot) takeStabilized(p MappingProgress) bool {
+	t := &r.mappingProgress
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !p.Stabilized || t.announced {
+		return false
+	}
+	t.announced = true
+	return true
+}
+
+// RunMappingProgress broadcasts "mapping_progress" for every mapping
+// robot each interval, and a notice when its coverage stabilizes, until
+// ctx is cancelled. One last message with active false follows the end
+// of a run.
+func (m *Manager) RunMappingProgress(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	sent := map[string]bool{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		for _, r := range m.GetAllRobots() {
+			p := r.MappingProgress()
+			if !p.Active || p.Updates == 0 {
+				if sent[r.ID] {
+					delete(sent, r.ID)
+					m.Broadcast(BroadcastMsg{Type: "mapping_progress", RobotID: r.ID, Data: p})
+				}
+				continue
+			}
+			sent[r.ID] = true
+			m.Broadcast(BroadcastMsg{Type: "mapping_progress", RobotID: r.ID, Data: p})
+			if r.takeStabilized(p) {
+				m.Notify(NoticeInfo, r.ID, "mapping", fmt.Sprintf(
+					"%s: map coverage stabilized at %.0f m² (under %.1f m²/min for %.0f s)",
+					r.Name, p.AreaM2, p.StableM2PerMin, p.StablePeriodSec))
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// exploredGrid returns a 100×100 grid at 0.1 m with the first rows rows
+// known: free, with an occupied wall along the left edge.
+func exploredGrid(rows int) rosbridge.MapData {
+	m := rosbridge.MapData{Width: 100, Height: 100, Resolution: 0.1, Data: make([]int8, 100*100)}
+	for i := range m.Data {
+		switch {
+		case i/100 >= rows:
+			m.Data[i] = -1
+		case i%100 == 0:
+			m.Data[i] = 100
+		}
+	}
+	return m
+}
+
+// checkRecount compares the tracker's counts with a count of the whole
+// grid.
+func checkRecount(t *testing.T, tr *mappingTracker, m rosbridge.MapData, h MapRenderHints) {
+	t.Helper()
+	all := countRow(m.Data, h)
+	p := tr.progress(time.Now())
+	if p.KnownCells != all.known || p.FreeCells != all.free || p.OccupiedCells != all.occupied {
+		t.Errorf("tracked %d/%d/%d known/free/occupied, recount %d/%d/%d",
+			p.KnownCells, p.FreeCells, p.OccupiedCells, all.known, all.free, all.occupied)
+	}
+}
+
+func TestMappingTrackerGrowth(t *testing.T) {
+	var tr mappingTracker
+	h := DefaultRenderHints()
+	t0 := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	// A row of 100 cells a second: 6000 cells, 60 m², a minute
+	for s := 1; s <= 20; s++ {
+		m := exploredGrid(s)
+		tr.update(m, h, t0.Add(time.Duration(s)*time.Second), 1, 30*time.Second)
+		checkRecount(t, &tr, m, h)
+	}
+	p := tr.progress(t0.Add(20 * time.Second))
+	if p.Updates != 20 || p.FullScans != 1 || p.KnownCells != 2000 || p.OccupiedCells != 20 || !near(p.AreaM2, 20) {
+		t.Errorf("after 20 s: %+v", p)
+	}
+	if !near(p.GrowthCellsPerMin, 6000) || !near(p.GrowthM2PerMin, 60) || p.StableForSec != 0 {
+		t.Errorf("growth %v cells, %v m² per minute", p.GrowthCellsPerMin, p.GrowthM2PerMin)
+	}
+	if !near(p.BoundsWidthM, 10) || !near(p.BoundsHeightM, 2) || !p.StartedAt.Equal(t0.Add(time.Second)) {
+		t.Errorf("bounds %v × %v m, started %v", p.BoundsWidthM, p.BoundsHeightM, p.StartedAt)
+	}
+
+	// Unchanged rows keep their counts: a row's counts spoiled on purpose
+	// survive a grid that leaves the row alone
+	tr.rows[0].known += 1000
+	tr.known += 1000
+	m := exploredGrid(21)
+	tr.update(m, h, t0.Add(21*time.Second), 1, 30*time.Second)
+	if p := tr.progress(t0); p.KnownCells != 2100+1000 {
+		t.Errorf("unchanged row recounted: %d known", p.KnownCells)
+	}
+	tr.rows[0].known -= 1000
+	tr.known -= 1000
+
+	// A grown map is counted from scratch
+	m = exploredGrid(21)
+	m.Width, m.Height = 50, 200
+	tr.update(m, h, t0.Add(22*time.Second), 1, 30*time.Second)
+	checkRecount(t, &tr, m, h)
+	if p := tr.progress(t0); p.FullScans != 2 || p.MapWidth != 50 || p.MapHeight != 200 || !near(p.BoundsWidthM, 5) || !near(p.BoundsHeightM, 4.2) {
+		t.Errorf("after a size change: %+v", p)
+	}
+	// So are new render hints
+	retuned := h
+	retuned.OccupiedThreshold = 90
+	tr.update(m, retuned, t0.Add(23*time.Second), 1, 30*time.Second)
+	checkRecount(t, &tr, m, retuned)
+	if p := tr.progress(t0); p.FullScans != 3 || p.OccupiedCells != 21 {
+		t.Errorf("after new hints: %+v", p)
+	}
+}
+
+func TestMappingTrackerStabilizes(t *testing.T) {
+	var tr mappingTracker
+	h := DefaultRenderHints()
+	t0 := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	tr.update(exploredGrid(10), h, at(0), 1, 30*time.Second)
+	tr.update(exploredGrid(20), h, at(1), 1, 30*time.Second) // 600 m²/min
+
+	// Nothing new: the smoothed rate decays below 1 m²/min, then has to
+	// stay there 30 s
+	m := exploredGrid(20)
+	below := -1
+	for s := 10; s <= 300; s += 10 {
+		tr.update(m, h, at(s), 1, 30*time.Second)
+		p := tr.progress(at(s))
+		if below < 0 && p.GrowthM2PerMin < 1 {
+			below = s
+		}
+		if below < 0 && (p.StableForSec != 0 || p.Stabilized) {
+			t.Fatalf("%d s: stable at %v m²/min", s, p.GrowthM2PerMin)
+		}
+		if below >= 0 && (p.StableForSec != float64(s-below) || p.Stabilized != (s-below >= 30)) {
+			t.Fatalf("%d s, below since %d s: %+v", s, below, p)
+		}
+	}
+	if below < 170 || below > 210 {
+		t.Errorf("below 1 m²/min after %d s, want about 190 (ln 600 × 30 s)", below)
+	}
+
+	// Growth again ends the stable stretch
+	tr.update(exploredGrid(60), h, at(310), 1, 30*time.Second)
+	if p := tr.progress(at(310)); p.Stabilized || p.StableForSec != 0 || p.GrowthM2PerMin < 1 {
+		t.Errorf("after new growth: %+v", p)
+	}
+}
+
+func TestMappingProgressRobot(t *testing.T) {
+	r := NewRobot("1", "", "mapper", "127.0.0.1", 9)
+	defer r.Close()
+	r.SetMappingProgressOptions(0, 0)
+
+	// Not mapping: nothing tracked
+	if _, ok := r.trackMappingProgress(exploredGrid(5)); ok {
+		t.Error("tracked while not mapping")
+	}
+	r.mu.Lock()
+	r.mode = ModeMapping
+	r.mu.Unlock()
+	p, ok := r.trackMappingProgress(exploredGrid(5))
+	if !ok || !p.Active || p.KnownCells != 500 || p.StableM2PerMin != DefaultMappingStableM2PerMin || p.StablePeriodSec != DefaultMappingStablePeriod.Seconds() {
+		t.Errorf("mapping: %+v", p)
+	}
+
+	// Stabilization is reported once per stable stretch
+	stable := MappingProgress{Stabilized: true}
+	if !r.takeStabilized(stable) || r.takeStabilized(stable) {
+		t.Error("stabilization not reported exactly once")
+	}
+
+	// The last run's figures stay readable until a grid arrives
+	// outside mapping
+	r.mu.Lock()
+	r.mode = ModeNavigation
+	r.mu.Unlock()
+	if p := r.MappingProgress(); p.Active || p.KnownCells != 500 {
+		t.Errorf("after mapping: %+v", p)
+	}
+	r.trackMappingProgress(exploredGrid(5))
+	if p := r.MappingProgress(); p.Updates != 0 || p.KnownCells != 0 {
+		t.Errorf("not reset: %+v", p)
+	}
+}
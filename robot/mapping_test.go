@@ -0,0 +1,116 @@
+package robot
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func mappingRobot(t *testing.T, mode Mode) *Robot {
+	t.Helper()
+	stub := newRosbridgeStub(t)
+	host, port := stub.addr(t)
+	r, _ := NewManager().AddRobot("", "mapper", host, port)
+	t.Cleanup(r.Close)
+	if err := r.Client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "connected", func() bool { return r.GetSnapshot().Connected })
+	if err := r.SwitchMode(mode); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestMappingSessionFinish(t *testing.T) {
+	r := mappingRobot(t, ModeNavigation)
+	var states []string
+	r.OnMappingSession = func(s MappingSession) { states = append(states, s.State) }
+
+	s, err := r.StartMapping("lab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != MappingActive || s.MapName != "lab" || s.PreviousMode != ModeNavigation || r.GetMode() != ModeMapping {
+		t.Errorf("started %+v in mode %q", s, r.GetMode())
+	}
+	if _, err := r.StartMapping("other"); !errors.Is(err, ErrMappingActive) {
+		t.Errorf("second session: %v", err)
+	}
+	if _, err := r.StartMapping("bad/name"); err == nil {
+		t.Error("invalid map name accepted")
+	}
+
+	s, err = r.FinishMapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != MappingFinished || s.EndedAt == nil || s.Error != "" || r.GetMode() != ModeNavigation {
+		t.Errorf("finished %+v in mode %q", s, r.GetMode())
+	}
+	found := false
+	for _, name := range r.GetMapList() {
+		found = found || name == "lab"
+	}
+	if !found {
+		t.Error("saved map not listed")
+	}
+	if want := []string{MappingActive, MappingSaving, MappingFinished}; !reflect.DeepEqual(states, want) {
+		t.Errorf("session events %v, want %v", states, want)
+	}
+
+	if _, err := r.FinishMapping(); !errors.Is(err, ErrNoMappingActive) {
+		t.Errorf("finish without a session: %v", err)
+	}
+	if _, err := r.AbortMapping(); !errors.Is(err, ErrNoMappingActive) {
+		t.Errorf("abort without a session: %v", err)
+	}
+	// The last session stays readable until the next one starts
+	if got := r.GetMappingSession(); got == nil || got.State != MappingFinished {
+		t.Errorf("last session %+v", got)
+	}
+	if _, err := r.StartMapping("lab2"); err != nil {
+		t.Errorf("start after finishing: %v", err)
+	}
+}
+
+func TestMappingSessionAbort(t *testing.T) {
+	r := mappingRobot(t, ModeRemapping)
+	if _, err := r.StartMapping("lab"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := r.AbortMapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != MappingAborted || s.EndedAt == nil || r.GetMode() != ModeRemapping {
+		t.Errorf("aborted %+v in mode %q, want remapping restored", s, r.GetMode())
+	}
+	for _, name := range r.GetMapList() {
+		if name == "lab" {
+			t.Error("aborted map listed")
+		}
+	}
+
+	// From an unknown mode, abort falls back to navigation
+	r.mu.Lock()
+	r.mode = ""
+	r.mu.Unlock()
+	if _, err := r.StartMapping("lab"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.AbortMapping(); err != nil || r.GetMode() != ModeNavigation {
+		t.Errorf("abort from an unknown mode: %v, mode %q", err, r.GetMode())
+	}
+}
+
+func TestMappingSessionNotConnected(t *testing.T) {
+	r, _ := NewManager().AddRobot("", "offline", "127.0.0.1", 9)
+	defer r.Close()
+	if _, err := r.StartMapping("lab"); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("start while disconnected: %v", err)
+	}
+	if r.GetMappingSession() != nil {
+		t.Error("session left behind")
+	}
+}
@@ -0,0 +1,120 @@
+package robot
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// ──────────────────────────── Incident markers
+//
+// When something odd happens during a run, an operator marks the moment
+// (POST /api/robots/mark) and onboard software can do the same through
+// the marker topic (see rosbridge/markers.go). Markers are kept per robot,
+// oldest first, up to maxMarkers; each is reported through OnMarker and
+// returned with the velocity history whose time range contains it.
+
+// Marker sources.
+const (
+	MarkerFromOperator = "operator"
+	MarkerFromRobot    = "robot"
+)
+
+// Marker retention and label length (runes).
+const (
+	maxMarkers     = 200
+	MaxMarkerLabel = 200
+)
+
+// Marker is a moment flagged for later review.
+type Marker struct {
+	ID     int    `json:"id"`
+	Time   int64  `json:"t"` // unix milliseconds, as history samples
+	Label  string `json:"label,omitempty"`
+	Source string `json:"source"` // operator or robot
+	// Client is the requesting address of an operator marker.
+	Client string `json:"client,omitempty"`
+}
+
+// AddMarker records a marker at the current time and reports it through
+// OnMarker. Labels longer than MaxMarkerLabel are cut.
+func (r *Robot) AddMarker(source, label, client string) Marker {
+	label = strings.TrimSpace(label)
+	if rs := []rune(label); len(rs) > MaxMarkerLabel {
+		label = string(rs[:MaxMarkerLabel])
+	}
+	r.mu.Lock()
+	r.lastMarkerID++
+	mk := Marker{ID: r.lastMarkerID, Time: timelineNow(), Label: label, Source: source, Client: client}
+	r.markers = append(r.markers, mk)
+	if n := len(r.markers); n > maxMarkers {
+		r.markers = append([]Marker(nil), r.markers[n-maxMarkers:]...)
+	}
+	r.mu.Unlock()
+
+	if r.OnMarker != nil {
+		r.OnMarker(mk)
+	}
+	return mk
+}
+
+// Markers returns the kept markers with from <= t <= to (unix ms; to 0
+// leaves that end open), oldest first.
+func (r *Robot) Markers(from, to int64) []Marker {
+	if to == 0 {
+		to = math.MaxInt64
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return markersBetween(r.markers, from, to)
+}
+
+// setMarkers replaces the markers, renumbered in time order, keeping the
+// newest maxMarkers.
+func (r *Robot) setMarkers(ms []Marker) {
+	ms = append([]Marker(nil), ms...)
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Time < ms[j].Time })
+	if len(ms) > maxMarkers {
+		ms = ms[len(ms)-maxMarkers:]
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range ms {
+		r.lastMarkerID++
+		ms[i].ID = r.lastMarkerID
+	}
+	r.markers = ms
+}
+
+// markersBetween returns a copy of the markers with from <= t <= to;
+// markers are sorted by time.
+func markersBetween(ms []Marker, from, to int64) []Marker {
+	lo := sort.Search(len(ms), func(i int) bool { return ms[i].Time >= from })
+	hi := sort.Search(len(ms), func(i int) bool { return ms[i].Time > to })
+	out := make([]Marker, 0, max(hi-lo, 0))
+	if lo < hi {
+		out = append(out, ms[lo:hi]...)
+	}
+	return out
+}
+
+// historySpan returns the time range (unix ms, inclusive) a velocity
+// history answer covers: after since and up to until (0: now), but not
+// before its oldest sample, since a tier may not reach back to since.
+// An answer without samples covers nothing (from > to).
+func historySpan(h VelocityHistory, since, until, now int64) (from, to int64) {
+	first := int64(math.MaxInt64)
+	for _, s := range [][]VelocitySample{h.Commanded, h.Measured} {
+		if len(s) > 0 {
+			first = min(first, s[0].Time)
+		}
+	}
+	if first == math.MaxInt64 {
+		return 1, 0
+	}
+	to = until
+	if to == 0 {
+		to = now
+	}
+	return max(since+1, first), to
+}
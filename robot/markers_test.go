@@ -0,0 +1,150 @@
+package robot
+
+import (
+	"strings"
+	"testing"
+)
+
+func markerTimes(ms []Marker) []int64 {
+	out := make([]int64, len(ms))
+	for i, m := range ms {
+		out[i] = m.Time
+	}
+	return out
+}
+
+func sameTimes(a []int64, b ...int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMarkersBetween(t *testing.T) {
+	ms := []Marker{{Time: 100}, {Time: 200}, {Time: 200}, {Time: 300}}
+	for _, c := range []struct {
+		from, to int64
+		want     []int64
+	}{
+		{0, 1000, []int64{100, 200, 200, 300}},
+		{100, 300, []int64{100, 200, 200, 300}}, // both ends inclusive
+		{101, 299, []int64{200, 200}},
+		{200, 200, []int64{200, 200}},
+		{301, 1000, nil},
+		{0, 99, nil},
+		{250, 150, nil}, // empty range
+	} {
+		got := markersBetween(ms, c.from, c.to)
+		if !sameTimes(markerTimes(got), c.want...) {
+			t.Errorf("[%d, %d]: %v, want %v", c.from, c.to, markerTimes(got), c.want)
+		}
+	}
+
+	// A copy: changing it leaves the kept markers alone
+	got := markersBetween(ms, 0, 1000)
+	got[0].Label = "changed"
+	if ms[0].Label != "" {
+		t.Error("markersBetween shares its result")
+	}
+}
+
+func TestHistorySpan(t *testing.T) {
+	samples := func(ts ...int64) []VelocitySample {
+		var out []VelocitySample
+		for _, t := range ts {
+			out = append(out, VelocitySample{Time: t})
+		}
+		return out
+	}
+	const now = 10_000
+	for _, c := range []struct {
+		name         string
+		h            VelocityHistory
+		since, until int64
+		from, to     int64
+	}{
+		{"no samples", VelocityHistory{}, 0, 0, 1, 0},
+		{"open ends", VelocityHistory{Commanded: samples(5000, 6000)}, 0, 0, 5000, now},
+		{"oldest of both series", VelocityHistory{Commanded: samples(5000), Measured: samples(4000, 7000)}, 0, 0, 4000, now},
+		{"since before the oldest sample", VelocityHistory{Measured: samples(5000)}, 2000, 0, 5000, now},
+		{"since after the oldest sample", VelocityHistory{Measured: samples(5000)}, 4000, 0, 5000, now},
+		{"samples after since, not at it", VelocityHistory{Measured: samples(3000)}, 6000, 0, 6001, now},
+		{"until", VelocityHistory{Measured: samples(5000)}, 0, 8000, 5000, 8000},
+	} {
+		from, to := historySpan(c.h, c.since, c.until, now)
+		if from != c.from || to != c.to {
+			t.Errorf("%s: [%d, %d], want [%d, %d]", c.name, from, to, c.from, c.to)
+		}
+	}
+}
+
+func TestAddMarker(t *testing.T) {
+	m := NewManager()
+	r, _ := m.AddRobot("", "mk", "127.0.0.1", 9)
+	defer r.Close()
+	var reported []Marker
+	r.OnMarker = func(mk Marker) { reported = append(reported, mk) }
+
+	mk := r.AddMarker(MarkerFromOperator, "  "+strings.Repeat("é", MaxMarkerLabel+5)+"  ", "10.0.0.7")
+	if n := len([]rune(mk.Label)); n != MaxMarkerLabel || mk.ID != 1 || mk.Client != "10.0.0.7" || mk.Time == 0 {
+		t.Errorf("marker %d runes, %+v", n, mk)
+	}
+	if len(reported) != 1 || reported[0].ID != mk.ID {
+		t.Errorf("reported %+v", reported)
+	}
+
+	for i := 0; i < maxMarkers+10; i++ {
+		r.AddMarker(MarkerFromRobot, "", "")
+	}
+	all := r.Markers(0, 0)
+	if len(all) != maxMarkers || all[0].ID != 12 || all[len(all)-1].ID != maxMarkers+11 {
+		t.Errorf("kept %d markers, ids %d..%d", len(all), all[0].ID, all[len(all)-1].ID)
+	}
+	if got := r.Markers(all[len(all)-1].Time+1, 0); len(got) != 0 {
+		t.Errorf("after the newest: %d markers", len(got))
+	}
+
+	// Imported markers are sorted and renumbered after the existing ones
+	r.setMarkers([]Marker{{ID: 9, Time: 300}, {ID: 3, Time: 100}, {ID: 1, Time: 200}})
+	got := r.Markers(0, 0)
+	if !sameTimes(markerTimes(got), 100, 200, 300) || got[0].ID != maxMarkers+12 || got[2].ID != maxMarkers+14 {
+		t.Errorf("imported %+v", got)
+	}
+}
+
+// TestHistoryMarkers checks velocity_history returns the markers within
+// the time range of its samples only.
+func TestHistoryMarkers(t *testing.T) {
+	m := NewManager()
+	r, _ := m.AddRobot("", "mk", "127.0.0.1", 9)
+	defer r.Close()
+
+	if h := r.GetVelocityHistory(ResolutionRaw, 0, 0); len(h.Markers) != 0 {
+		t.Errorf("no samples: %d markers", len(h.Markers))
+	}
+	r.mu.Lock()
+	r.commanded.add(VelocitySample{Time: 1000}, 0)
+	r.commanded.add(VelocitySample{Time: 2000}, 0)
+	r.markers = []Marker{{ID: 1, Time: 500}, {ID: 2, Time: 1000}, {ID: 3, Time: 1500}, {ID: 4, Time: 2500}}
+	r.mu.Unlock()
+
+	for _, c := range []struct {
+		since, until int64
+		want         []int64
+	}{
+		{0, 0, []int64{1000, 1500, 2500}}, // up to now
+		{0, 2000, []int64{1000, 1500}},
+		{1000, 0, []int64{2500}}, // from the oldest sample returned
+		{0, 900, nil},
+	} {
+		h := r.GetVelocityHistory(ResolutionRaw, c.since, c.until)
+		if !sameTimes(markerTimes(h.Markers), c.want...) {
+			t.Errorf("since %d until %d: %v, want %v", c.since, c.until, markerTimes(h.Markers), c.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package robot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Navigation sync
+//
+// Each successful upload records a hash of the collection that was sent,
+// per map and point type. A collection is synced while the hash of what
+// it holds now matches; any add, edit, delete, import or clear changes
+// the hash and so marks it dirty, without the mutators having to say so,
+// and undoing the edit makes it synced again. The hash is of the points'
+// canonical JSON (keys sorted), so it depends on their values and order
+// only. A collection that was never sent is synced only while empty.
+
+// ErrUnsynced is returned when a go-all targets a collection that was
+// changed since it was last sent to the robot.
+var ErrUnsynced = errors.New("unsynced changes")
+
+// NavSyncStatus says whether the robot has the current version of a
+// collection.
+type NavSyncStatus struct {
+	Synced       bool       `json:"synced"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+}
+
+// navSyncKey identifies a collection: points belong to a map.
+type navSyncKey struct {
+	mapName   string
+	pointType rosbridge.PointType
+}
+
+// navSync is the last successful upload of a collection.
+type navSync struct {
+	hash string
+	at   time.Time
+}
+
+// emptyPointsHash is the hash of an empty collection.
+var emptyPointsHash = hashPoints([]rosbridge.NavigationPoint{})
+
+// hashPoints returns the hash of pts (navigation points or walls): the
+// SHA-256 of their JSON re-encoded with sorted keys. A nil and an empty
+// collection hash alike.
+func hashPoints(pts interface{}) string {
+	raw, err := json.Marshal(pts)
+	if err != nil {
+		return ""
+	}
+	if bytes.Equal(raw, []byte("null")) {
+		raw = []byte("[]")
+	}
+	// Decoding into interface{} turns objects into maps, which encode
+	// with sorted keys; UseNumber keeps numbers exactly as written.
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return ""
+	}
+	canon, err := json.Marshal(generic)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(canon)
+	return hex.EncodeToString(sum[:])
+}
+
+// collectionHash returns the hash of the robot's collection of
+// pointType. The caller holds rb.mu.
+func (rb *Robot) collectionHash(pointType rosbridge.PointType) (string, error) {
+	if pointType == rosbridge.PointWall {
+		return hashPoints(rb.WallObstacles), nil
+	}
+	coll := rb.pointCollection(pointType)
+	if coll == nil {
+		return "", invalidPointType(pointType)
+	}
+	return hashPoints(*coll), nil
+}
+
+// markSynced records that the collection of pointType hashing to hash
+// was sent to the robot.
+func (rb *Robot) markSynced(pointType rosbridge.PointType, hash string) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.navSynced == nil {
+		rb.navSynced = make(map[navSyncKey]navSync)
+	}
+	rb.navSynced[navSyncKey{rb.currentMap, pointType}] = navSync{hash: hash, at: time.Now()}
+}
+
+// navSyncStatus is NavSync with rb.mu held.
+func (rb *Robot) navSyncStatus(pointType rosbridge.PointType) (NavSyncStatus, error) {
+	hash, err := rb.collectionHash(pointType)
+	if err != nil {
+		return NavSyncStatus{}, err
+	}
+	last, ok := rb.navSynced[navSyncKey{rb.currentMap, pointType}]
+	if !ok {
+		return NavSyncStatus{Synced: hash == emptyPointsHash}, nil
+	}
+	at := last.at
+	return NavSyncStatus{Synced: hash == last.hash, LastSyncedAt: &at}, nil
+}
+
+// NavSync reports whether the robot has the current collection of
+// pointType (walls included).
+func (rb *Robot) NavSync(pointType rosbridge.PointType) (NavSyncStatus, error) {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return rb.navSyncStatus(pointType)
+}
+
+// NavSyncAll is NavSync for every collection, walls included.
+func (rb *Robot) NavSyncAll() map[rosbridge.PointType]NavSyncStatus {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	out := make(map[rosbridge.PointType]NavSyncStatus, len(rosbridge.NavPointTypes)+1)
+	for _, t := range rosbridge.NavPointTypes {
+		out[t], _ = rb.navSyncStatus(t)
+	}
+	out[rosbridge.PointWall], _ = rb.navSyncStatus(rosbridge.PointWall)
+	return out
+}
+
+// checkSynced returns ErrUnsynced if the collection of pointType changed
+// since it was last sent.
+func (rb *Robot) checkSynced(pointType rosbridge.PointType) error {
+	st, err := rb.NavSync(pointType)
+	if err != nil {
+		return err
+	}
+	if !st.Synced {
+		return fmt.Errorf("%w: the %ss were changed since they were last sent to the robot (send them first, or pass force=true)", ErrUnsynced, pointType)
+	}
+	return nil
+}
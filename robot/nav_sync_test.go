@@ -0,0 +1,157 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+// TestHashPoints checks the hash depends on the points' values and order,
+// not on how their fields happen to be laid out.
+func TestHashPoints(t *testing.T) {
+	pts := []rosbridge.NavigationPoint{{Name: "dock", WorldXM: 1.5, WorldYM: -2}, {Name: "desk", DwellSec: 3}}
+	h := hashPoints(pts)
+	if h == "" || h != hashPoints(append([]rosbridge.NavigationPoint(nil), pts...)) {
+		t.Fatalf("hash %q not stable", h)
+	}
+
+	// The same points as JSON objects with their keys in another order
+	var shuffled []map[string]interface{}
+	raw := `[{"world_y_m": -2, "world_theta_rad": 0, "image_theta_deg": 0, "image_y_px": 0, "image_x_px": 0, "world_x_m": 1.5, "name": "dock"},
+		{"dwell_sec": 3, "world_theta_rad": 0, "world_y_m": 0, "world_x_m": 0, "image_theta_deg": 0, "image_y_px": 0, "image_x_px": 0, "name": "desk"}]`
+	if err := json.Unmarshal([]byte(raw), &shuffled); err != nil {
+		t.Fatal(err)
+	}
+	if got := hashPoints(shuffled); got != h {
+		t.Errorf("key order changed the hash: %s, want %s", got, h)
+	}
+
+	for name, other := range map[string][]rosbridge.NavigationPoint{
+		"moved":     {{Name: "dock", WorldXM: 1.5, WorldYM: -2.1}, {Name: "desk", DwellSec: 3}},
+		"renamed":   {{Name: "Dock", WorldXM: 1.5, WorldYM: -2}, {Name: "desk", DwellSec: 3}},
+		"reordered": {{Name: "desk", DwellSec: 3}, {Name: "dock", WorldXM: 1.5, WorldYM: -2}},
+		"dropped":   {{Name: "dock", WorldXM: 1.5, WorldYM: -2}},
+	} {
+		if hashPoints(other) == h {
+			t.Errorf("%s: same hash", name)
+		}
+	}
+	if hashPoints([]rosbridge.NavigationPoint(nil)) != emptyPointsHash || hashPoints([]rosbridge.WallObstacle{}) != emptyPointsHash {
+		t.Error("nil and empty collections hash differently")
+	}
+}
+
+// sent records an upload of the robot's current collection of pointType
+// that the robot answered with ack and err.
+func sent(rb *Robot, pointType rosbridge.PointType, ack *rosbridge.NavAck, err error) {
+	rb.mu.RLock()
+	var pts interface{}
+	if pointType == rosbridge.PointWall {
+		pts = append([]rosbridge.WallObstacle(nil), rb.WallObstacles...)
+	} else {
+		pts = append([]rosbridge.NavigationPoint(nil), *rb.pointCollection(pointType)...)
+	}
+	rb.mu.RUnlock()
+	syncSent(rb, pointType, pts, ack, err)
+}
+
+func synced(t *testing.T, rb *Robot, pointType rosbridge.PointType) bool {
+	t.Helper()
+	st, err := rb.NavSync(pointType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Synced
+}
+
+func TestNavSync(t *testing.T) {
+	nm := NewNavigationManager()
+	rb := NewRobot("1", "", "sync", "127.0.0.1", 9)
+	defer rb.Close()
+	rb.SetCurrentMap("floor1")
+	ok := &rosbridge.NavAck{Sent: 1, Accepted: 1, Verified: true}
+
+	// Never sent: synced only while empty
+	if st, _ := rb.NavSync(rosbridge.PointWaypoint); !st.Synced || st.LastSyncedAt != nil {
+		t.Errorf("empty, never sent: %+v", st)
+	}
+	nm.AddWaypoint(rb, "dock", 1, 2, 0)
+	if synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("added, never sent: synced")
+	}
+
+	// Refused, in part or whole: still unsynced
+	sent(rb, rosbridge.PointWaypoint, &rosbridge.NavAck{Sent: 1, Rejected: []rosbridge.RejectedPoint{{Name: "dock"}}}, nil)
+	sent(rb, rosbridge.PointWaypoint, nil, errors.New("timed out"))
+	if synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("synced by a partial or failed upload")
+	}
+
+	sent(rb, rosbridge.PointWaypoint, ok, nil)
+	st, _ := rb.NavSync(rosbridge.PointWaypoint)
+	if !st.Synced || st.LastSyncedAt == nil {
+		t.Fatalf("after an upload: %+v", st)
+	}
+
+	// Any edit dirties it; undoing the edit makes it synced again
+	nm.AddWaypoint(rb, "desk", 3, 4, 0)
+	if synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("add: synced")
+	}
+	if _, _, err := rb.UndoNavEdit(); err != nil {
+		t.Fatal(err)
+	}
+	if !synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("add undone: unsynced")
+	}
+	nm.DeletePoint(rb, rosbridge.PointWaypoint, "dock")
+	if synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("delete: synced")
+	}
+	rb.UndoNavEdit()
+	rb.mu.Lock()
+	rb.Waypoints[0].WorldThetaRad = 0.1
+	rb.mu.Unlock()
+	if synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("edit: synced")
+	}
+	rb.mu.Lock()
+	rb.Waypoints[0].WorldThetaRad = 0
+	rb.mu.Unlock()
+
+	// Other collections are tracked apart, walls included
+	nm.AddWallObstacle(rb, "w", 0, 0, 1, 1)
+	all := rb.NavSyncAll()
+	if !all[rosbridge.PointWaypoint].Synced || all[rosbridge.PointWall].Synced || !all[rosbridge.PointPatrol].Synced ||
+		len(all) != len(rosbridge.NavPointTypes)+1 {
+		t.Errorf("all %+v", all)
+	}
+	sent(rb, rosbridge.PointWall, ok, nil)
+	if !synced(t, rb, rosbridge.PointWall) {
+		t.Error("walls sent: unsynced")
+	}
+
+	// Per map: the same points on another map were never sent
+	rb.mu.Lock()
+	rb.switchMapLocked("floor2")
+	rb.mu.Unlock()
+	nm.AddWaypoint(rb, "dock", 1, 2, 0)
+	if synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("floor2: synced by floor1's upload")
+	}
+	rb.mu.Lock()
+	rb.switchMapLocked("floor1")
+	rb.mu.Unlock()
+	if !synced(t, rb, rosbridge.PointWaypoint) {
+		t.Error("back on floor1: unsynced")
+	}
+
+	if err := rb.checkSynced(rosbridge.PointWaypoint); err != nil {
+		t.Errorf("checkSynced: %v", err)
+	}
+	if _, err := rb.NavSync("rocket"); !errors.Is(err, rosbridge.ErrInvalidPointType) {
+		t.Errorf("unknown type: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Undo and redo of point edits
+//
+// Every edit of the current map's points (add, delete, clear, import)
+// records the collections it touched as they were before and after it,
+// under the same lock as the edit itself. Undoing puts the "before"
+// contents back exactly, a clear included; redoing puts the "after" ones
+// back. Since every edit is recorded in the order it was made, the most
+// recent one is always the last thing that happened to the points, and
+// undoing edits in reverse order walks back through states that really
+// existed, whoever made them. A new edit drops what could be redone.
+//
+// Replacing the points wholesale (switching maps, restoring the point
+// file, applying a profile) starts a new history: the recorded states
+// belong to points that are no longer there.
+
+// NavUndoDepth is how many edits a robot keeps for undo.
+const NavUndoDepth = 50
+
+// Errors for an empty undo or redo history.
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// navEdit is one recorded edit: the collections of types before and
+// after it.
+type navEdit struct {
+	label         string
+	types         []rosbridge.PointType
+	before, after MapPoints
+}
+
+// navUndo is a robot's edit history: done edits, newest last, and edits
+// undone since the last new one, most recently undone last.
+type navUndo struct {
+	done, undone []navEdit
+}
+
+// NavUndoState says what undo and redo would do, for enabling buttons.
+type NavUndoState struct {
+	CanUndo bool `json:"can_undo"`
+	CanRedo bool `json:"can_redo"`
+	// Undo and Redo describe the edit each would revert or reapply.
+	Undo string `json:"undo,omitempty"`
+	Redo string `json:"redo,omitempty"`
+}
+
+// NavUndoState returns the robot's undo and redo availability.
+func (r *Robot) NavUndoState() NavUndoState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.navUndoStateLocked()
+}
+
+func (r *Robot) navUndoStateLocked() NavUndoState {
+	var st NavUndoState
+	if n := len(r.navUndo.done); n > 0 {
+		st.CanUndo, st.Undo = true, r.navUndo.done[n-1].label
+	}
+	if n := len(r.navUndo.undone); n > 0 {
+		st.CanRedo, st.Redo = true, r.navUndo.undone[n-1].label
+	}
+	return st
+}
+
+// UndoNavEdit reverts the most recent point edit; it returns the edit's
+// description.
+func (r *Robot) UndoNavEdit() (string, NavUndoState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.navUndo.done)
+	if n == 0 {
+		return "", r.navUndoStateLocked(), ErrNothingToUndo
+	}
+	e := r.navUndo.done[n-1]
+	r.navUndo.done = r.navUndo.done[:n-1]
+	r.setEditPointsLocked(e.types, e.before)
+	r.navUndo.undone = append(r.navUndo.undone, e)
+	return e.label, r.navUndoStateLocked(), nil
+}
+
+// RedoNavEdit reapplies the most recently undone point edit; it returns
+// the edit's description.
+func (r *Robot) RedoNavEdit() (string, NavUndoState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.navUndo.undone)
+	if n == 0 {
+		return "", r.navUndoStateLocked(), ErrNothingToRedo
+	}
+	e := r.navUndo.undone[n-1]
+	r.navUndo.undone = r.navUndo.undone[:n-1]
+	r.setEditPointsLocked(e.types, e.after)
+	r.navUndo.done = append(r.navUndo.done, e)
+	return e.label, r.navUndoStateLocked(), nil
+}
+
+// editPointsLocked returns copies of the live collections of types.
+// Caller holds r.mu.
+func (r *Robot) editPointsLocked(types ...rosbridge.PointType) MapPoints {
+	var p MapPoints
+	for _, t := range types {
+		if t == rosbridge.PointWall {
+			p.WallObstacles = slices.Clone(r.WallObstacles)
+			continue
+		}
+		if coll := r.pointCollection(t); coll != nil {
+			*p.pointCollection(t) = slices.Clone(*coll)
+		}
+	}
+	return p
+}
+
+// setEditPointsLocked replaces the live collections of types with
+// copies of p's, so the recorded ones are never appended to. Caller
+// holds r.mu for writing.
+func (r *Robot) setEditPointsLocked(types []rosbridge.PointType, p MapPoints) {
+	for _, t := range types {
+		if t == rosbridge.PointWall {
+			r.WallObstacles = slices.Clone(p.WallObstacles)
+			continue
+		}
+		if coll := r.pointCollection(t); coll != nil {
+			*coll = slices.Clone(*p.pointCollection(t))
+		}
+	}
+}
+
+// recordEditLocked records an edit of types just made; before is what
+// editPointsLocked returned for them beforehand. Caller holds r.mu for
+// writing since before the edit.
+func (r *Robot) recordEditLocked(label string, before MapPoints, types ...rosbridge.PointType) {
+	r.navUndo.done = append(r.navUndo.done, navEdit{
+		label:  label,
+		types:  types,
+		before: before,
+		after:  r.editPointsLocked(types...),
+	})
+	if n := len(r.navUndo.done); n > NavUndoDepth {
+		r.navUndo.done = slices.Delete(r.navUndo.done, 0, n-NavUndoDepth)
+	}
+	r.navUndo.undone = nil
+}
+
+// resetNavUndoLocked forgets the edit history. Caller holds r.mu for
+// writing.
+func (r *Robot) resetNavUndoLocked() {
+	r.navUndo = navUndo{}
+}
+
+// pointCollection returns p's slice of points of pointType, or nil for
+// an unknown type.
+func (p *MapPoints) pointCollection(pointType rosbridge.PointType) *[]rosbridge.NavigationPoint {
+	switch pointType {
+	case rosbridge.PointWaypoint:
+		return &p.Waypoints
+	case rosbridge.PointService:
+		return &p.ServicePoints
+	case rosbridge.PointPatrol:
+		return &p.PatrolPoints
+	case rosbridge.PointPath:
+		return &p.PathPoints
+	}
+	return nil
+}
+
+// editLabel describes an edit of n points of pointType, naming the
+// point when there is one.
+func editLabel(op string, pointType rosbridge.PointType, n int, name string) string {
+	if n == 1 && name != "" {
+		return fmt.Sprintf("%s %s %s", op, pointType, name)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%s 1 %s", op, pointType)
+	}
+	return fmt.Sprintf("%s %d %ss", op, n, pointType)
+}
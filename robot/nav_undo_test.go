@@ -0,0 +1,184 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+// waypointNames lists the robot's waypoints by name, in order.
+func waypointNames(r *Robot) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var names []string
+	for _, p := range r.Waypoints {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestNavUndoClearRestore(t *testing.T) {
+	r := NewRobot("1", "", "amr", "127.0.0.1", 9)
+	defer r.Close()
+	nm := NewNavigationManager()
+	for i, name := range []string{"dock", "desk", "lift"} {
+		nm.AddWaypoint(r, name, float64(i), 1, 0.5)
+	}
+	nm.AddServicePoint(r, "charger", 5, 5, 0)
+	r.mu.RLock()
+	want := append([]rosbridge.NavigationPoint(nil), r.Waypoints...)
+	r.mu.RUnlock()
+
+	nm.ClearPoints(r, rosbridge.PointWaypoint)
+	if st := r.NavUndoState(); !st.CanUndo || st.CanRedo || st.Undo != "clear 3 waypoints" {
+		t.Errorf("after the clear: %+v", st)
+	}
+	label, st, err := r.UndoNavEdit()
+	if err != nil || label != "clear 3 waypoints" || !st.CanRedo || st.Redo != label {
+		t.Fatalf("undo: %q %+v %v", label, st, err)
+	}
+	if !reflect.DeepEqual(r.Waypoints, want) || len(r.ServicePoints) != 1 {
+		t.Errorf("restored %+v, want %+v", r.Waypoints, want)
+	}
+
+	// The restored slice is a copy: appending to it doesn't change what
+	// redo and undo put back
+	r.mu.Lock()
+	r.Waypoints = append(r.Waypoints[:1], rosbridge.NavigationPoint{Name: "stray"})
+	r.mu.Unlock()
+	r.RedoNavEdit()
+	if names := waypointNames(r); len(names) != 0 {
+		t.Errorf("redone clear left %v", names)
+	}
+	r.UndoNavEdit()
+	if !reflect.DeepEqual(r.Waypoints, want) {
+		t.Errorf("restored again %+v", r.Waypoints)
+	}
+
+	// A new edit drops what could be redone
+	nm.DeletePoint(r, rosbridge.PointWaypoint, "desk")
+	if _, _, err := r.RedoNavEdit(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("redo after a new edit: %v", err)
+	}
+	nm.ClearAllPoints(r)
+	r.UndoNavEdit()
+	if names := waypointNames(r); !reflect.DeepEqual(names, []string{"dock", "lift"}) || len(r.ServicePoints) != 1 {
+		t.Errorf("after undoing clear all: %v, %d service points", names, len(r.ServicePoints))
+	}
+	// The undone clear isn't in the history any more
+	for _, want := range []string{"delete waypoint desk", "add service_point charger", "add waypoint lift"} {
+		if label, _, _ := r.UndoNavEdit(); label != want {
+			t.Errorf("undid %q, want %q", label, want)
+		}
+	}
+	if names := waypointNames(r); !reflect.DeepEqual(names, []string{"dock", "desk"}) || len(r.ServicePoints) != 0 {
+		t.Errorf("unwound to %v", names)
+	}
+
+	// Nothing happened: nothing recorded
+	before := r.NavUndoState()
+	nm.DeletePoint(r, rosbridge.PointWaypoint, "nowhere")
+	nm.ClearPoints(r, rosbridge.PointPath)
+	if st := r.NavUndoState(); st != before {
+		t.Errorf("no-op edits recorded: %+v", st)
+	}
+}
+
+func TestNavUndoDepth(t *testing.T) {
+	r := NewRobot("1", "", "amr", "127.0.0.1", 9)
+	defer r.Close()
+	nm := NewNavigationManager()
+	for i := 0; i < NavUndoDepth+5; i++ {
+		nm.AddWaypoint(r, fmt.Sprintf("wp%d", i), float64(i), 0, 0)
+	}
+	undone := 0
+	for {
+		if _, _, err := r.UndoNavEdit(); errors.Is(err, ErrNothingToUndo) {
+			break
+		}
+		undone++
+	}
+	if names := waypointNames(r); undone != NavUndoDepth || len(names) != 5 || names[4] != "wp4" {
+		t.Errorf("undid %d edits, down to %v", undone, names)
+	}
+}
+
+// TestNavUndoReset checks replacing the points starts a new history.
+func TestNavUndoReset(t *testing.T) {
+	r := NewRobot("1", "", "amr", "127.0.0.1", 9)
+	defer r.Close()
+	nm := NewNavigationManager()
+	r.SetCurrentMap("floor1")
+	nm.AddWaypoint(r, "dock", 1, 2, 0)
+	r.mu.Lock()
+	r.switchMapLocked("floor2")
+	r.mu.Unlock()
+	if st := r.NavUndoState(); st.CanUndo || st.CanRedo {
+		t.Errorf("history kept across a map switch: %+v", st)
+	}
+	if _, _, err := r.UndoNavEdit(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("undo on the new map: %v", err)
+	}
+
+	// An import is an edit like any other
+	r.ImportPoints(rosbridge.PointWall, nil, []rosbridge.WallObstacle{{WorldXMEnd: 1}, {WorldYMEnd: 1}})
+	if label, _, err := r.UndoNavEdit(); err != nil || label != "import 2 walls" || len(r.WallObstacles) != 0 {
+		t.Errorf("undo import: %q %v, %d walls", label, err, len(r.WallObstacles))
+	}
+}
+
+// TestNavUndoConcurrent edits, undoes and redoes from several goroutines
+// at once, then unwinds the history: each edit must still lead from its
+// recorded before to its recorded after.
+func TestNavUndoConcurrent(t *testing.T) {
+	r := NewRobot("1", "", "amr", "127.0.0.1", 9)
+	defer r.Close()
+	nm := NewNavigationManager()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 40; i++ {
+				name := fmt.Sprintf("g%d-%d", g, i)
+				switch i % 5 {
+				case 0, 1:
+					nm.AddWaypoint(r, name, 0, 0, 0)
+				case 2:
+					nm.AddPatrolPoint(r, name, 0, 0, 0)
+				case 3:
+					nm.DeletePoint(r, rosbridge.PointWaypoint, fmt.Sprintf("g%d-%d", g, i-3))
+					r.UndoNavEdit()
+				case 4:
+					r.RedoNavEdit()
+					if g == 0 && i%20 == 4 {
+						nm.ClearAllPoints(r)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.navUndo.done) == 0 {
+		t.Fatal("nothing recorded")
+	}
+	for len(r.navUndo.done) > 0 {
+		e := r.navUndo.done[len(r.navUndo.done)-1]
+		if live := r.editPointsLocked(e.types...); !reflect.DeepEqual(live, e.after) {
+			t.Fatalf("%q: live points aren't its result", e.label)
+		}
+		r.mu.Unlock()
+		r.UndoNavEdit()
+		r.mu.Lock()
+		if live := r.editPointsLocked(e.types...); !reflect.DeepEqual(live, e.before) {
+			t.Fatalf("%q: undo didn't restore the points before it", e.label)
+		}
+	}
+}
@@ -1,81 +1,89 @@
 package robot
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"rom_go_app/rosbridge"
 )
 
+// DefaultMaxDwellSec caps a point's dwell time unless configured
+// otherwise.
+const DefaultMaxDwellSec = 600
+
 // NavigationManager handles navigation point operations across robots.
 type NavigationManager struct {
 	mu sync.RWMutex
+
+	// MaxDwellSec caps NavigationPoint.DwellSec.
+	MaxDwellSec float64
+
+	// PatrolResumeOnReconnect keeps a patrol waiting through a rosbridge
+	// drop instead of aborting it.
+	PatrolResumeOnReconnect bool
+
+	// GoAllMaxDistanceM is the furthest the robot may be from the first
+	// point when a go-all starts (0 = no limit), and GoAllPoseMaxAge how
+	// recent its pose must be.
+	GoAllMaxDistanceM float64
+	GoAllPoseMaxAge   time.Duration
 }
 
 // NewNavigationManager creates a NavigationManager.
 func NewNavigationManager() *NavigationManager {
-	return &NavigationManager{}
+	return &NavigationManager{
+		MaxDwellSec:             DefaultMaxDwellSec,
+		PatrolResumeOnReconnect: true,
+		GoAllMaxDistanceM:       DefaultGoAllMaxDistanceM,
+		GoAllPoseMaxAge:         DefaultGoAllPoseMaxAge,
+	}
 }
 
 // ──────────────────────────── Add points
 
 // AddWaypoint adds a waypoint to the robot, with validation.
 func (nm *NavigationManager) AddWaypoint(rb *Robot, name string, x, y, theta float64) error {
-	nm.mu.Lock()
-	defer nm.mu.Unlock()
-
-	pt, err := nm.validateAndCreate(rb, "waypoint", name, x, y, theta)
-	if err != nil {
-		return err
-	}
-	rb.mu.Lock()
-	rb.Waypoints = append(rb.Waypoints, pt)
-	rb.mu.Unlock()
-	return nil
+	return nm.addNamed(rb, rosbridge.PointWaypoint, name, x, y, theta)
 }
 
 // AddServicePoint adds a service point to the robot.
 func (nm *NavigationManager) AddServicePoint(rb *Robot, name string, x, y, theta float64) error {
-	nm.mu.Lock()
-	defer nm.mu.Unlock()
-
-	pt, err := nm.validateAndCreate(rb, "servicepoint", name, x, y, theta)
-	if err != nil {
-		return err
-	}
-	rb.mu.Lock()
-	rb.ServicePoints = append(rb.ServicePoints, pt)
-	rb.mu.Unlock()
-	return nil
+	return nm.addNamed(rb, rosbridge.PointService, name, x, y, theta)
 }
 
 // AddPatrolPoint adds a patrol point to the robot.
 func (nm *NavigationManager) AddPatrolPoint(rb *Robot, name string, x, y, theta float64) error {
-	nm.mu.Lock()
-	defer nm.mu.Unlock()
-
-	pt, err := nm.validateAndCreate(rb, "patrolpoint", name, x, y, theta)
-	if err != nil {
-		return err
-	}
-	rb.mu.Lock()
-	rb.PatrolPoints = append(rb.PatrolPoints, pt)
-	rb.mu.Unlock()
-	return nil
+	return nm.addNamed(rb, rosbridge.PointPatrol, name, x, y, theta)
 }
 
 // AddPathPoint adds a path point to the robot.
 func (nm *NavigationManager) AddPathPoint(rb *Robot, name string, x, y, theta float64) error {
+	return nm.addNamed(rb, rosbridge.PointPath, name, x, y, theta)
+}
+
+// addNamed adds a point of pointType at (x, y, theta), with validation.
+func (nm *NavigationManager) addNamed(rb *Robot, pointType rosbridge.PointType, name string, x, y, theta float64) error {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
 
-	pt, err := nm.validateAndCreate(rb, "pathpoint", name, x, y, theta)
+	pt, err := nm.validateAndCreate(rb, pointType, name, x, y, theta)
 	if err != nil {
 		return err
 	}
 	rb.mu.Lock()
-	rb.PathPoints = append(rb.PathPoints, pt)
-	rb.mu.Unlock()
+	defer rb.mu.Unlock()
+	coll := rb.pointCollection(pointType)
+	if coll == nil {
+		return invalidPointType(pointType)
+	}
+	before := rb.editPointsLocked(pointType)
+	*coll = append(*coll, pt)
+	rb.recordEditLocked(editLabel("add", pointType, 1, name), before, pointType)
 	return nil
 }
 
@@ -93,75 +101,195 @@ func (nm *NavigationManager) AddWallObstacle(rb *Robot, name string, x1, y1, x2,
 		WorldXMEnd: x2, WorldYMEnd: y2,
 	}
 	rb.mu.Lock()
+	before := rb.editPointsLocked(rosbridge.PointWall)
 	rb.WallObstacles = append(rb.WallObstacles, wall)
+	rb.recordEditLocked(editLabel("add", rosbridge.PointWall, 1, ""), before, rosbridge.PointWall)
 	rb.mu.Unlock()
 	return nil
 }
 
-// ──────────────────────────── Send points to robot via rosbridge
+// AddPoint validates and appends a single point, including its approach
+// parameters, to the collection of the given type.
+func (nm *NavigationManager) AddPoint(rb *Robot, pointType rosbridge.PointType, p rosbridge.NavigationPoint) error {
+	if _, errs := nm.AddPoints(rb, pointType, []rosbridge.NavigationPoint{p}); len(errs) > 0 {
+		return fmt.Errorf("%s", errs[0].Reason)
+	}
+	return nil
+}
 
-// SendWaypointsToRobot sends all waypoints to the robot's rosbridge.
-func (nm *NavigationManager) SendWaypointsToRobot(rb *Robot) error {
+// ValidateApproach checks a point's optional approach parameters against
+// the robot's velocity limit and the dwell cap.
+func (nm *NavigationManager) ValidateApproach(rb *Robot, p rosbridge.NavigationPoint) error {
 	rb.mu.RLock()
-	pts := make([]rosbridge.NavigationPoint, len(rb.Waypoints))
-	copy(pts, rb.Waypoints)
-	client := rb.Client
+	maxLin := rb.maxLinearVel
 	rb.mu.RUnlock()
+	return nm.validateApproach(p, maxLin)
+}
 
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
+func (nm *NavigationManager) validateApproach(p rosbridge.NavigationPoint, maxLin float64) error {
+	switch {
+	case p.MaxSpeedMPS < 0:
+		return fmt.Errorf("%s: max_speed_mps must be positive", p.Name)
+	case maxLin > 0 && p.MaxSpeedMPS > maxLin:
+		return fmt.Errorf("%s: max_speed_mps %.2f exceeds the robot's max %.2f m/s", p.Name, p.MaxSpeedMPS, maxLin)
+	case p.DwellSec < 0:
+		return fmt.Errorf("%s: dwell_sec must not be negative", p.Name)
+	case nm.MaxDwellSec > 0 && p.DwellSec > nm.MaxDwellSec:
+		return fmt.Errorf("%s: dwell_sec %.0f exceeds the limit of %.0f s", p.Name, p.DwellSec, nm.MaxDwellSec)
+	case p.YawToleranceRad < 0 || p.YawToleranceRad > math.Pi:
+		return fmt.Errorf("%s: yaw_tolerance_rad must be within [0, π]", p.Name)
 	}
-	_, err := client.AddWaypoints(pts)
-	return err
+	return nil
 }
 
-// SendServicePointsToRobot sends all service points.
-func (nm *NavigationManager) SendServicePointsToRobot(rb *Robot) error {
-	rb.mu.RLock()
-	pts := make([]rosbridge.NavigationPoint, len(rb.ServicePoints))
-	copy(pts, rb.ServicePoints)
-	client := rb.Client
-	rb.mu.RUnlock()
+// PointError describes a point rejected during a bulk operation.
+type PointError struct {
+	Line   int    `json:"line,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
 
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
-	}
-	_, err := client.AddServicePoints(pts)
-	return err
+	// Index is the point's position in the batch, -1 when the error is
+	// about the whole batch.
+	Index int `json:"-"`
 }
 
-// SendPatrolPointsToRobot sends all patrol points.
-func (nm *NavigationManager) SendPatrolPointsToRobot(rb *Robot) error {
-	rb.mu.RLock()
-	pts := make([]rosbridge.NavigationPoint, len(rb.PatrolPoints))
-	copy(pts, rb.PatrolPoints)
-	client := rb.Client
-	rb.mu.RUnlock()
+// AddPoints validates and appends a batch of points of one type
+// (waypoint, service_point, patrol_point, path_point). Points failing
+// validation (name, approach parameters) or duplicating an existing name
+// are skipped and reported; the rest are added.
+func (nm *NavigationManager) AddPoints(rb *Robot, pointType rosbridge.PointType, pts []rosbridge.NavigationPoint) (int, []PointError) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	coll := rb.pointCollection(pointType)
+	if coll == nil {
+		return 0, []PointError{{Reason: fmt.Sprintf("%v %q", rosbridge.ErrInvalidPointType, pointType), Index: -1}}
 	}
-	_, err := client.AddPatrolPoints(pts)
-	return err
+
+	// Names taken, with the type that owns them.
+	seen := rb.namesLocked(pointType)
+
+	before := rb.editPointsLocked(pointType)
+	added := 0
+	var skipped []PointError
+	var lastName string
+	for i, p := range pts {
+		approachErr := nm.validateApproach(p, rb.maxLinearVel)
+		switch {
+		case p.Name == "":
+			skipped = append(skipped, PointError{Reason: string(pointType) + " name cannot be empty", Index: i})
+		case seen[p.Name] != "":
+			skipped = append(skipped, PointError{Name: p.Name, Reason: duplicateName(pointType, p.Name, seen[p.Name]).Error(), Index: i})
+		case approachErr != nil:
+			skipped = append(skipped, PointError{Name: p.Name, Reason: approachErr.Error(), Index: i})
+		default:
+			seen[p.Name] = pointType
+			*coll = append(*coll, p)
+			added++
+			lastName = p.Name
+		}
+	}
+	if added > 0 {
+		rb.recordEditLocked(editLabel("add", pointType, added, lastName), before, pointType)
+	}
+	return added, skipped
 }
 
-// SendPathPointsToRobot sends all path points.
-func (nm *NavigationManager) SendPathPointsToRobot(rb *Robot) error {
+// pointCollection returns the slice holding points of the given type,
+// or nil for an unknown type. Caller holds rb.mu.
+func (rb *Robot) pointCollection(pointType rosbridge.PointType) *[]rosbridge.NavigationPoint {
+	switch pointType {
+	case rosbridge.PointWaypoint:
+		return &rb.Waypoints
+	case rosbridge.PointService:
+		return &rb.ServicePoints
+	case rosbridge.PointPatrol:
+		return &rb.PatrolPoints
+	case rosbridge.PointPath:
+		return &rb.PathPoints
+	}
+	return nil
+}
+
+// invalidPointType is the error for a type an operation doesn't take.
+func invalidPointType(pointType rosbridge.PointType) error {
+	return fmt.Errorf("%w %q", rosbridge.ErrInvalidPointType, string(pointType))
+}
+
+// ──────────────────────────── Send points to robot via rosbridge
+
+// SendPointsToRobot sends the robot's collection of pointType (walls
+// included) to its rosbridge and returns the robot's acknowledgment.
+func (nm *NavigationManager) SendPointsToRobot(rb *Robot, pointType rosbridge.PointType) (*rosbridge.NavAck, error) {
+	return nm.SendPointsWithTemplate(rb, pointType, "")
+}
+
+// SendPointsWithTemplate is SendPointsToRobot with the behavior tree
+// template the robot builds from the points (empty: its default; see
+// CheckBTTemplate). Walls take none.
+func (nm *NavigationManager) SendPointsWithTemplate(rb *Robot, pointType rosbridge.PointType, btTemplate string) (*rosbridge.NavAck, error) {
+	if pointType == rosbridge.PointWall {
+		return nm.SendWallObstaclesToRobot(rb)
+	}
 	rb.mu.RLock()
-	pts := make([]rosbridge.NavigationPoint, len(rb.PathPoints))
-	copy(pts, rb.PathPoints)
+	coll := rb.pointCollection(pointType)
+	var pts []rosbridge.NavigationPoint
+	if coll != nil {
+		pts = append(make([]rosbridge.NavigationPoint, 0, len(*coll)), *coll...)
+	}
 	client := rb.Client
 	rb.mu.RUnlock()
 
+	if coll == nil {
+		return nil, invalidPointType(pointType)
+	}
 	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
+		return nil, ErrNotConnected
+	}
+	if err := rb.Require(pointType.Capability()); err != nil {
+		return nil, err
+	}
+	if err := rb.CheckBTTemplate(btTemplate); err != nil {
+		return nil, err
+	}
+	ack, err := client.AddPointsWithTemplate(pointType, rb.pointsWithImagePx(pts), btTemplate)
+	syncSent(rb, pointType, pts, ack, err)
+	return ack, err
+}
+
+// syncSent records a successful upload of pts; one the robot refused
+// points of leaves the collection unsynced.
+func syncSent(rb *Robot, pointType rosbridge.PointType, pts interface{}, ack *rosbridge.NavAck, err error) {
+	if err == nil && ack != nil && !ack.Partial() {
+		rb.markSynced(pointType, hashPoints(pts))
 	}
-	_, err := client.AddPathPoints(pts)
-	return err
+}
+
+// SendWaypointsToRobot sends all waypoints to the robot's rosbridge.
+func (nm *NavigationManager) SendWaypointsToRobot(rb *Robot) (*rosbridge.NavAck, error) {
+	return nm.SendPointsToRobot(rb, rosbridge.PointWaypoint)
+}
+
+// SendServicePointsToRobot sends all service points.
+func (nm *NavigationManager) SendServicePointsToRobot(rb *Robot) (*rosbridge.NavAck, error) {
+	return nm.SendPointsToRobot(rb, rosbridge.PointService)
+}
+
+// SendPatrolPointsToRobot sends all patrol points.
+func (nm *NavigationManager) SendPatrolPointsToRobot(rb *Robot) (*rosbridge.NavAck, error) {
+	return nm.SendPointsToRobot(rb, rosbridge.PointPatrol)
+}
+
+// SendPathPointsToRobot sends all path points.
+func (nm *NavigationManager) SendPathPointsToRobot(rb *Robot) (*rosbridge.NavAck, error) {
+	return nm.SendPointsToRobot(rb, rosbridge.PointPath)
 }
 
 // SendWallObstaclesToRobot sends wall obstacles.
-func (nm *NavigationManager) SendWallObstaclesToRobot(rb *Robot) error {
+func (nm *NavigationManager) SendWallObstaclesToRobot(rb *Robot) (*rosbridge.NavAck, error) {
 	rb.mu.RLock()
 	walls := make([]rosbridge.WallObstacle, len(rb.WallObstacles))
 	copy(walls, rb.WallObstacles)
@@ -169,160 +297,190 @@ func (nm *NavigationManager) SendWallObstaclesToRobot(rb *Robot) error {
 	rb.mu.RUnlock()
 
 	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
+		return nil, ErrNotConnected
 	}
-	_, err := client.SaveWallObstacles(walls)
-	return err
+	if err := rb.Require(rosbridge.CapWallObstacles); err != nil {
+		return nil, err
+	}
+	ack, err := client.SaveWallObstacles(rb.wallsWithImagePx(walls))
+	syncSent(rb, rosbridge.PointWall, walls, ack, err)
+	return ack, err
 }
 
 // ──────────────────────────── Request points from robot
 
-// RequestWaypoints fetches waypoints from the robot.
-func (nm *NavigationManager) RequestWaypoints(rb *Robot) error {
+// RequestPoints asks the robot for its collection of pointType.
+func (nm *NavigationManager) RequestPoints(rb *Robot, pointType rosbridge.PointType) error {
+	if !pointType.Navigable() {
+		return invalidPointType(pointType)
+	}
 	rb.mu.RLock()
 	client := rb.Client
 	rb.mu.RUnlock()
 
 	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
+		return ErrNotConnected
+	}
+	if err := rb.Require(pointType.Capability()); err != nil {
+		return err
 	}
 	// The response is handled via service response — the caller
 	// would need to parse the result. For now, fire and forget.
-	_, err := client.GetWaypoints()
+	_, err := client.GetPoints(pointType)
 	return err
 }
 
+// RequestWaypoints fetches waypoints from the robot.
+func (nm *NavigationManager) RequestWaypoints(rb *Robot) error {
+	return nm.RequestPoints(rb, rosbridge.PointWaypoint)
+}
+
 // RequestServicePoints fetches service points from the robot.
 func (nm *NavigationManager) RequestServicePoints(rb *Robot) error {
-	rb.mu.RLock()
-	client := rb.Client
-	rb.mu.RUnlock()
-
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
-	}
-	_, err := client.GetServicePoints()
-	return err
+	return nm.RequestPoints(rb, rosbridge.PointService)
 }
 
 // RequestPatrolPoints fetches patrol points from the robot.
 func (nm *NavigationManager) RequestPatrolPoints(rb *Robot) error {
-	rb.mu.RLock()
-	client := rb.Client
-	rb.mu.RUnlock()
-
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
-	}
-	_, err := client.GetPatrolPoints()
-	return err
+	return nm.RequestPoints(rb, rosbridge.PointPatrol)
 }
 
 // RequestPathPoints fetches path points from the robot.
 func (nm *NavigationManager) RequestPathPoints(rb *Robot) error {
-	rb.mu.RLock()
-	client := rb.Client
-	rb.mu.RUnlock()
-
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
-	}
-	_, err := client.GetPathPoints()
-	return err
+	return nm.RequestPoints(rb, rosbridge.PointPath)
 }
 
 // ──────────────────────────── Go all points
 
-// GoAllWaypoints triggers the robot to navigate all waypoints.
-func (nm *NavigationManager) GoAllWaypoints(rb *Robot) error {
+// GoAll runs CheckGoAll, then makes the robot navigate through its
+// collection of pointType.
+func (nm *NavigationManager) GoAll(rb *Robot, pointType rosbridge.PointType, force bool) error {
+	if !pointType.Navigable() {
+		return invalidPointType(pointType)
+	}
 	rb.mu.RLock()
 	client := rb.Client
 	rb.mu.RUnlock()
 
 	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
+		return ErrNotConnected
+	}
+	if err := rb.Require(pointType.Capability()); err != nil {
+		return err
 	}
-	_, err := client.GoAllWaypoints()
+	if err := nm.CheckGoAll(rb, pointType, force); err != nil {
+		return err
+	}
+	_, err := client.GoAll(pointType)
 	return err
 }
 
-// GoAllServicePoints triggers navigation of all service points.
-func (nm *NavigationManager) GoAllServicePoints(rb *Robot) error {
-	rb.mu.RLock()
-	client := rb.Client
-	rb.mu.RUnlock()
+// GoAllWaypoints triggers the robot to navigate all waypoints.
+func (nm *NavigationManager) GoAllWaypoints(rb *Robot, force bool) error {
+	return nm.GoAll(rb, rosbridge.PointWaypoint, force)
+}
 
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
-	}
-	_, err := client.GoAllServicePoints()
-	return err
+// GoAllServicePoints triggers navigation of all service points.
+func (nm *NavigationManager) GoAllServicePoints(rb *Robot, force bool) error {
+	return nm.GoAll(rb, rosbridge.PointService, force)
 }
 
 // GoAllPatrolPoints triggers navigation of all patrol points.
-func (nm *NavigationManager) GoAllPatrolPoints(rb *Robot) error {
-	rb.mu.RLock()
-	client := rb.Client
-	rb.mu.RUnlock()
-
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
-	}
-	_, err := client.GoAllPatrolPoints()
-	return err
+func (nm *NavigationManager) GoAllPatrolPoints(rb *Robot, force bool) error {
+	return nm.GoAll(rb, rosbridge.PointPatrol, force)
 }
 
 // GoAllPathPoints triggers navigation of all path points.
-func (nm *NavigationManager) GoAllPathPoints(rb *Robot) error {
-	rb.mu.RLock()
-	client := rb.Client
-	rb.mu.RUnlock()
+func (nm *NavigationManager) GoAllPathPoints(rb *Robot, force bool) error {
+	return nm.GoAll(rb, rosbridge.PointPath, force)
+}
 
-	if client == nil || !client.IsConnected() {
-		return fmt.Errorf("robot not connected")
+// ──────────────────────────── Patrol loops
+
+// StartPatrol loops the robot's patrol points until the request's limits
+// are hit or StopPatrol is called.
+func (nm *NavigationManager) StartPatrol(rb *Robot, q PatrolRequest) (PatrolStatus, error) {
+	if err := rb.Require(rosbridge.CapPatrolPoints); err != nil {
+		return PatrolStatus{}, err
 	}
-	_, err := client.GoAllPathPoints()
-	return err
+	if err := nm.CheckGoAll(rb, rosbridge.PointPatrol, q.Force); err != nil {
+		return PatrolStatus{}, err
+	}
+	// Later laps start where the previous one ended, so only the first
+	// is checked.
+	return rb.startPatrol(q, nm.PatrolResumeOnReconnect, func() error {
+		return nm.GoAllPatrolPoints(rb, true)
+	})
+}
+
+// StopPatrol ends the robot's patrol, if any, and cancels active
+// navigation either way.
+func (nm *NavigationManager) StopPatrol(rb *Robot) (stopped bool, err error) {
+	stopped = rb.stopPatrol()
+	if !rb.IsConnected() {
+		return stopped, ErrNotConnected
+	}
+	_, err = rb.Client.CancelNavigation()
+	return stopped, err
 }
 
 // ──────────────────────────── Clear points
 
+// ClearPoints removes the robot's collection of pointType; clearing
+// walls also notifies the robot.
+func (nm *NavigationManager) ClearPoints(rb *Robot, pointType rosbridge.PointType) error {
+	if pointType == rosbridge.PointWall {
+		return nm.ClearWallObstacles(rb)
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	coll := rb.pointCollection(pointType)
+	if coll == nil {
+		return invalidPointType(pointType)
+	}
+	if n := len(*coll); n > 0 {
+		before := rb.editPointsLocked(pointType)
+		*coll = nil
+		rb.recordEditLocked(editLabel("clear", pointType, n, ""), before, pointType)
+	}
+	return nil
+}
+
 // ClearWaypoints removes all waypoints from the robot.
 func (nm *NavigationManager) ClearWaypoints(rb *Robot) {
-	rb.mu.Lock()
-	rb.Waypoints = nil
-	rb.mu.Unlock()
+	nm.ClearPoints(rb, rosbridge.PointWaypoint)
 }
 
 // ClearServicePoints removes all service points.
 func (nm *NavigationManager) ClearServicePoints(rb *Robot) {
-	rb.mu.Lock()
-	rb.ServicePoints = nil
-	rb.mu.Unlock()
+	nm.ClearPoints(rb, rosbridge.PointService)
 }
 
 // ClearPatrolPoints removes all patrol points.
 func (nm *NavigationManager) ClearPatrolPoints(rb *Robot) {
-	rb.mu.Lock()
-	rb.PatrolPoints = nil
-	rb.mu.Unlock()
+	nm.ClearPoints(rb, rosbridge.PointPatrol)
 }
 
 // ClearPathPoints removes all path points.
 func (nm *NavigationManager) ClearPathPoints(rb *Robot) {
-	rb.mu.Lock()
-	rb.PathPoints = nil
-	rb.mu.Unlock()
+	nm.ClearPoints(rb, rosbridge.PointPath)
 }
 
 // ClearWallObstacles removes all wall obstacles and notifies the robot.
 func (nm *NavigationManager) ClearWallObstacles(rb *Robot) error {
 	rb.mu.Lock()
-	rb.WallObstacles = nil
+	if n := len(rb.WallObstacles); n > 0 {
+		before := rb.editPointsLocked(rosbridge.PointWall)
+		rb.WallObstacles = nil
+		rb.recordEditLocked(editLabel("clear", rosbridge.PointWall, n, ""), before, rosbridge.PointWall)
+	}
 	client := rb.Client
 	rb.mu.Unlock()
 
 	if client != nil && client.IsConnected() {
+		if err := rb.Require(rosbridge.CapWallObstacles); err != nil {
+			return err
+		}
 		_, err := client.ClearWallObstacles()
 		return err
 	}
@@ -332,29 +490,36 @@ func (nm *NavigationManager) ClearWallObstacles(rb *Robot) error {
 // ClearAllPoints removes all navigation points from the robot.
 func (nm *NavigationManager) ClearAllPoints(rb *Robot) {
 	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.livePointsLocked().empty() {
+		return
+	}
+	types := append(slices.Clone(rosbridge.NavPointTypes), rosbridge.PointWall)
+	before := rb.editPointsLocked(types...)
 	rb.Waypoints = nil
 	rb.ServicePoints = nil
 	rb.PatrolPoints = nil
 	rb.PathPoints = nil
 	rb.WallObstacles = nil
-	rb.mu.Unlock()
+	rb.recordEditLocked("clear all points", before, types...)
 }
 
-// DeletePoint removes a single navigation point by name and type.
-func (nm *NavigationManager) DeletePoint(rb *Robot, pointType, name string) {
+// DeletePoint removes a single navigation point by name and type. Walls
+// have no names and can only be cleared.
+func (nm *NavigationManager) DeletePoint(rb *Robot, pointType rosbridge.PointType, name string) error {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	switch pointType {
-	case "waypoint":
-		rb.Waypoints = removeByName(rb.Waypoints, name)
-	case "service_point":
-		rb.ServicePoints = removeByName(rb.ServicePoints, name)
-	case "patrol_point":
-		rb.PatrolPoints = removeByName(rb.PatrolPoints, name)
-	case "path_point":
-		rb.PathPoints = removeByName(rb.PathPoints, name)
+	coll := rb.pointCollection(pointType)
+	if coll == nil {
+		return invalidPointType(pointType)
+	}
+	if kept := removeByName(*coll, name); len(kept) < len(*coll) {
+		before := rb.editPointsLocked(pointType)
+		*coll = kept
+		rb.recordEditLocked(editLabel("delete", pointType, 1, name), before, pointType)
 	}
+	return nil
 }
 
 func removeByName(pts []rosbridge.NavigationPoint, name string) []rosbridge.NavigationPoint {
@@ -376,30 +541,18 @@ func (nm *NavigationManager) GetCounts(rb *Robot) (waypoints, service, patrol, p
 
 // ──────────────────────────── Helpers
 
-func (nm *NavigationManager) validateAndCreate(rb *Robot, pointType, name string, x, y, theta float64) (rosbridge.NavigationPoint, error) {
+func (nm *NavigationManager) validateAndCreate(rb *Robot, pointType rosbridge.PointType, name string, x, y, theta float64) (rosbridge.NavigationPoint, error) {
 	if name == "" {
 		return rosbridge.NavigationPoint{}, fmt.Errorf("%s name cannot be empty", pointType)
 	}
 
-	// Check for duplicate names within the same type
+	// Check for duplicate names within the same type, or across all
+	// types in global uniqueness mode
 	rb.mu.RLock()
-	var existing []rosbridge.NavigationPoint
-	switch pointType {
-	case "waypoint":
-		existing = rb.Waypoints
-	case "servicepoint":
-		existing = rb.ServicePoints
-	case "patrolpoint":
-		existing = rb.PatrolPoints
-	case "pathpoint":
-		existing = rb.PathPoints
-	}
+	owner := rb.namesLocked(pointType)[name]
 	rb.mu.RUnlock()
-
-	for _, pt := range existing {
-		if pt.Name == name {
-			return rosbridge.NavigationPoint{}, fmt.Errorf("duplicate %s name: %s", pointType, name)
-		}
+	if owner != "" {
+		return rosbridge.NavigationPoint{}, duplicateName(pointType, name, owner)
 	}
 
 	return rosbridge.NavigationPoint{
@@ -409,3 +562,115 @@ func (nm *NavigationManager) validateAndCreate(rb *Robot, pointType, name string
 		WorldThetaRad: theta,
 	}, nil
 }
+
+// ──────────────────────────── Name uniqueness
+//
+// Point names are unique per type. With enforce_global_unique_names they
+// must be unique across all four point collections, because voice intents
+// and the robot-side behaviour tree generator look points up by name
+// alone.
+
+// namesLocked maps the names a new point of pointType may not take to
+// the type that owns them: its own collection, plus every other one in
+// global mode. Caller holds rb.mu.
+func (rb *Robot) namesLocked(pointType rosbridge.PointType) map[string]rosbridge.PointType {
+	names := make(map[string]rosbridge.PointType)
+	for _, t := range rosbridge.NavPointTypes {
+		if t != pointType && !rb.globalUniqueNames {
+			continue
+		}
+		if coll := rb.pointCollection(t); coll != nil {
+			for _, p := range *coll {
+				if _, ok := names[p.Name]; !ok || t == pointType {
+					names[p.Name] = t
+				}
+			}
+		}
+	}
+	return names
+}
+
+func duplicateName(pointType rosbridge.PointType, name string, owner rosbridge.PointType) error {
+	if owner == pointType {
+		return fmt.Errorf("duplicate %s name: %s", pointType, name)
+	}
+	return fmt.Errorf("duplicate name: %s is already a %s", name, owner)
+}
+
+// NameConflict is a name used by more than one point type.
+type NameConflict struct {
+	Name  string                `json:"name"`
+	Types []rosbridge.PointType `json:"types"`
+}
+
+// NameConflicts lists names shared across point types, sorted by name.
+func (rb *Robot) NameConflicts() []NameConflict {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return rb.nameConflictsLocked()
+}
+
+func (rb *Robot) nameConflictsLocked() []NameConflict {
+	owners := make(map[string][]rosbridge.PointType)
+	for _, t := range rosbridge.NavPointTypes {
+		seen := make(map[string]bool)
+		for _, p := range *rb.pointCollection(t) {
+			if !seen[p.Name] {
+				seen[p.Name] = true
+				owners[p.Name] = append(owners[p.Name], t)
+			}
+		}
+	}
+	out := []NameConflict{}
+	for name, types := range owners {
+		if len(types) > 1 {
+			out = append(out, NameConflict{Name: name, Types: types})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ErrNameConflicts is returned when global uniqueness can't be enabled
+// because names are already shared across types.
+var ErrNameConflicts = errors.New("points share names across types; rename them first (see /api/nav/conflicts)")
+
+// SetGlobalUniqueNames turns global name uniqueness on or off. Enabling
+// fails while cross-type duplicates exist; they are returned.
+func (rb *Robot) SetGlobalUniqueNames(enabled bool) ([]NameConflict, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if enabled {
+		if c := rb.nameConflictsLocked(); len(c) > 0 {
+			return c, ErrNameConflicts
+		}
+	}
+	rb.globalUniqueNames = enabled
+	return nil, nil
+}
+
+// CheckReplace validates a collection that will replace pointType's
+// points (JSON import): in global mode no name may belong to another
+// type.
+func (nm *NavigationManager) CheckReplace(rb *Robot, pointType rosbridge.PointType, pts []rosbridge.NavigationPoint) error {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	if !rb.globalUniqueNames {
+		return nil
+	}
+	for _, t := range rosbridge.NavPointTypes {
+		if t == pointType {
+			continue
+		}
+		names := make(map[string]bool)
+		for _, p := range *rb.pointCollection(t) {
+			names[p.Name] = true
+		}
+		for _, p := range pts {
+			if names[p.Name] {
+				return duplicateName(pointType, p.Name, t)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package robot
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// ──────────────────────────── User-visible notices
+//
+// Operations that run in the background (connecting a new robot,
+// refreshing the map list, forwarding a voice command) have nobody to
+// return an error to. They report it here instead: the notice is logged,
+// kept in a short recent list and broadcast as a "toast" that is not
+// dropped for slow subscribers, so the operator who started the
+// operation sees it failed.
+
+// Notice levels.
+const (
+	NoticeError = "error"
+	NoticeWarn  = "warn"
+	NoticeInfo  = "info"
+)
+
+// Recent notice retention.
+const (
+	maxNotices         = 100
+	mustDeliverTimeout = 2 * time.Second
+)
+
+// Notice is one user-visible report of a background operation.
+type Notice struct {
+	ID      int64     `json:"id"`
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	RobotID string    `json:"robot_id,omitempty"`
+	Source  string    `json:"source"` // operation that reported it, e.g. "connect", "map", "voice"
+	Message string    `json:"message"`
+}
+
+// Notify records a notice and broadcasts it as a must-deliver "toast".
+// robotID may be empty for notices not tied to a robot.
+func (m *Manager) Notify(level, robotID, source, message string) Notice {
+	m.noticesMu.Lock()
+	m.lastNoticeID++
+	n := Notice{
+		ID:      m.lastNoticeID,
+		Time:    time.UnixMilli(timelineNow()),
+		Level:   level,
+		RobotID: robotID,
+		Source:  source,
+		Message: message,
+	}
+	m.notices = append(m.notices, n)
+	if len(m.notices) > maxNotices {
+		m.notices = m.notices[len(m.notices)-maxNotices:]
+	}
+	m.noticesMu.Unlock()
+
+	log.Printf("[%s] %s (robot %q): %s", source, level, robotID, message)
+	m.BroadcastMust(BroadcastMsg{Type: "toast", RobotID: robotID, Data: n})
+	return n
+}
+
+// ReportError records an error notice; see Notify.
+func (m *Manager) ReportError(robotID, source, message string) Notice {
+	return m.Notify(NoticeError, robotID, source, message)
+}
+
+// RecentNotices returns the retained notices newer than since, oldest
+// first; robotID, if set, limits them to that robot.
+func (m *Manager) RecentNotices(since time.Time, robotID string) []Notice {
+	m.noticesMu.Lock()
+	defer m.noticesMu.Unlock()
+	out := make([]Notice, 0)
+	i := sort.Search(len(m.notices), func(i int) bool { return m.notices[i].Time.After(since) })
+	for _, n := range m.notices[i:] {
+		if robotID == "" || n.RobotID == robotID {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// NoticesBetween returns the retained notices with from <= t <= to (unix
+// ms), oldest first.
+func (m *Manager) NoticesBetween(from, to int64) []Notice {
+	m.noticesMu.Lock()
+	defer m.noticesMu.Unlock()
+	lo := sort.Search(len(m.notices), func(i int) bool { return m.notices[i].Time.UnixMilli() >= from })
+	hi := sort.Search(len(m.notices), func(i int) bool { return m.notices[i].Time.UnixMilli() > to })
+	return append([]Notice{}, m.notices[lo:max(lo, hi)]...)
+}
+
+// BroadcastMust sends a message to all subscribers, waiting up to
+// mustDeliverTimeout for a full subscriber instead of dropping it.
+func (m *Manager) BroadcastMust(msg BroadcastMsg) {
+	m.stamp(&msg)
+	msg.enc = new(sharedEncoding)
+	m.broadcastMu.RLock()
+	defer m.broadcastMu.RUnlock()
+	for _, fn := range m.handlers {
+		fn(msg)
+	}
+	deadline := time.NewTimer(mustDeliverTimeout)
+	defer deadline.Stop()
+	expired := false
+	for ch := range m.subscribers {
+		select {
+		case ch <- msg:
+			continue
+		default:
+		}
+		if !expired {
+			select {
+			case ch <- msg:
+				continue
+			case <-deadline.C:
+				expired = true
+			}
+		}
+		log.Printf("[manager] %s dropped: subscriber stalled", msg.Type)
+	}
+}
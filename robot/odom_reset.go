@@ -0,0 +1,182 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Odometry reset
+//
+// A robot carried somewhere by hand (to its charger) keeps wrong odometry
+// and localization until they are reset, and how to reset them depends on
+// its firmware, so each robot names its mechanism:
+//   - task: a which_tasks task, given the pose as settings when there is one;
+//   - service: a call to a reset service without arguments (std_srvs/Empty);
+//   - initial_pose: a pose estimate published for localization, at the
+//     requested pose or else the home pose on the current map.
+//
+// Resetting while a navigation goal is active is refused unless forced.
+// A successful reset drops the velocity history and session summary,
+// which came from the old odometry, and is reported through OnPoseReset.
+
+// Odometry reset methods.
+const (
+	OdomResetTask        = "task"
+	OdomResetService     = "service"
+	OdomResetInitialPose = "initial_pose"
+)
+
+// Odometry reset errors.
+var (
+	ErrNavActive         = errors.New("navigation goal active")
+	ErrResetPoseRequired = errors.New("a pose (x, y) is required: no home pose on the current map")
+	ErrInvalidPose       = errors.New("pose must be finite")
+)
+
+// OdomResetOptions configure how a robot's odometry is reset.
+type OdomResetOptions struct {
+	Method  string `json:"method"`  // task, service or initial_pose
+	Task    string `json:"task"`    // which_tasks task name
+	Service string `json:"service"` // reset service, without namespace
+	Topic   string `json:"topic"`   // initial pose topic, without namespace
+}
+
+// DefaultOdomReset runs the reset_odometry task.
+var DefaultOdomReset = OdomResetOptions{
+	Method:  OdomResetTask,
+	Task:    "reset_odometry",
+	Service: "/reset_odom",
+	Topic:   rosbridge.DefaultInitialPoseTopic,
+}
+
+// Validate checks the method and the setting it uses.
+func (o OdomResetOptions) Validate() error {
+	switch o.Method {
+	case OdomResetTask:
+		if o.Task == "" {
+			return errors.New("odometry reset task name required")
+		}
+	case OdomResetService:
+		if !strings.HasPrefix(o.Service, "/") {
+			return errors.New("odometry reset service must start with /")
+		}
+	case OdomResetInitialPose:
+		if !strings.HasPrefix(o.Topic, "/") {
+			return errors.New("initial pose topic must start with /")
+		}
+	default:
+		return fmt.Errorf("unknown odometry reset method %q (task, service or initial_pose)", o.Method)
+	}
+	return nil
+}
+
+// PoseReset reports a completed odometry reset.
+type PoseReset struct {
+	Method string            `json:"method"`
+	Pose   *rosbridge.Pose2D `json:"pose,omitempty"` // where the robot was placed, if given
+	Forced bool              `json:"forced"`         // a navigation goal was active
+	At     time.Time         `json:"at"`
+}
+
+// OdomReset returns the robot's odometry reset options.
+func (r *Robot) OdomReset() OdomResetOptions {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.odomReset
+}
+
+// SetOdomReset changes the odometry reset options.
+func (r *Robot) SetOdomReset(o OdomResetOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.odomReset = o
+	r.mu.Unlock()
+	return nil
+}
+
+// ResetOdometry resets the robot's odometry and localization by its
+// configured method; pose is where the robot now stands (map frame), nil
+// if unknown. It fails with ErrNotConnected, with ErrNavActive while a
+// goal runs unless force is set, with ErrInvalidPose, and with
+// ErrResetPoseRequired when initial_pose has no pose to publish.
+func (r *Robot) ResetOdometry(pose *rosbridge.Pose2D, force bool) (PoseReset, error) {
+	r.mu.RLock()
+	opts, client, connected := r.odomReset, r.Client, r.connected
+	navActive := r.navStatus.Active()
+	home, mapName := r.home.clone(), r.currentMap
+	r.mu.RUnlock()
+
+	if pose != nil {
+		for _, v := range []float64{pose.X, pose.Y, pose.Theta} {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return PoseReset{}, ErrInvalidPose
+			}
+		}
+	}
+	switch {
+	case !connected || client == nil:
+		return PoseReset{}, ErrNotConnected
+	case navActive && !force:
+		return PoseReset{}, fmt.Errorf("%w: stop it first or force the reset", ErrNavActive)
+	}
+
+	switch opts.Method {
+	case OdomResetTask:
+		settings := ""
+		if pose != nil {
+			b, _ := json.Marshal(pose)
+			settings = string(b)
+		}
+		if _, err := r.RequestTask(opts.Task, settings); err != nil {
+			return PoseReset{}, err
+		}
+	case OdomResetService:
+		raw, err := client.CallService(opts.Service, map[string]interface{}{}, 10*time.Second)
+		if err != nil {
+			return PoseReset{}, err
+		}
+		var resp struct {
+			Result *bool `json:"result"`
+		}
+		json.Unmarshal(raw, &resp)
+		if resp.Result != nil && !*resp.Result {
+			return PoseReset{}, fmt.Errorf("%s failed", opts.Service)
+		}
+	case OdomResetInitialPose:
+		if pose == nil && home != nil && home.Map == mapName {
+			p := home.Pose2D
+			pose = &p
+		}
+		if pose == nil {
+			return PoseReset{}, ErrResetPoseRequired
+		}
+		if err := client.PublishInitialPose(opts.Topic, *pose); err != nil {
+			return PoseReset{}, err
+		}
+	default:
+		return PoseReset{}, fmt.Errorf("unknown odometry reset method %q", opts.Method)
+	}
+
+	r.mu.Lock()
+	r.commanded = velocitySeries{}
+	r.measured = velocitySeries{}
+	r.velSummary = VelocitySummary{}
+	r.lastMeasured = time.Time{}
+	r.lastSpeed = 0
+	r.usage.have = false // the jump to the new pose isn't travel
+	r.mu.Unlock()
+
+	ev := PoseReset{Method: opts.Method, Pose: pose, Forced: navActive, At: time.Now()}
+	if r.OnPoseReset != nil {
+		r.OnPoseReset(ev)
+	}
+	return ev, nil
+}
@@ -0,0 +1,85 @@
+package robot
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+func serviceCalls(s *rosbridgeStub, service string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, o := range s.ops {
+		if o.Op == "call_service" && o.Service == service {
+			n++
+		}
+	}
+	return n
+}
+
+// TestResetOdometryGuard refuses a reset during a navigation goal unless
+// forced, and clears the velocity history only once the reset was sent.
+func TestResetOdometryGuard(t *testing.T) {
+	r, stub := relocRobot(t)
+	if err := r.SetOdomReset(OdomResetOptions{Method: OdomResetService, Service: "/reset_odom"}); err != nil {
+		t.Fatal(err)
+	}
+	var resets []PoseReset
+	r.OnPoseReset = func(ev PoseReset) { resets = append(resets, ev) }
+	setNav := func(status int) {
+		r.mu.Lock()
+		r.navStatus = rosbridge.NavStatus{GoalID: "g1", Status: status}
+		r.lastSpeed = 0.5
+		r.mu.Unlock()
+	}
+	lastSpeed := func() float64 {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.lastSpeed
+	}
+
+	setNav(rosbridge.GoalExecuting)
+	if _, err := r.ResetOdometry(nil, false); !errors.Is(err, ErrNavActive) {
+		t.Fatalf("during a goal: %v", err)
+	}
+	if n := serviceCalls(stub, "/reset_odom"); n != 0 || len(resets) != 0 || lastSpeed() != 0.5 {
+		t.Errorf("refused reset: %d calls, %d events, speed %v", n, len(resets), lastSpeed())
+	}
+
+	ev, err := r.ResetOdometry(&rosbridge.Pose2D{X: 1, Y: 2}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ev.Forced || ev.Method != OdomResetService || ev.Pose == nil || ev.Pose.X != 1 {
+		t.Errorf("forced reset %+v", ev)
+	}
+	if n := serviceCalls(stub, "/reset_odom"); n != 1 || len(resets) != 1 || lastSpeed() != 0 {
+		t.Errorf("forced reset: %d calls, %d events, speed %v", n, len(resets), lastSpeed())
+	}
+
+	setNav(rosbridge.GoalSucceeded)
+	if ev, err := r.ResetOdometry(nil, false); err != nil || ev.Forced {
+		t.Errorf("after the goal ended: %+v %v", ev, err)
+	}
+
+	if _, err := r.ResetOdometry(&rosbridge.Pose2D{X: math.NaN()}, true); !errors.Is(err, ErrInvalidPose) {
+		t.Errorf("NaN pose: %v", err)
+	}
+	r.SetOdomReset(OdomResetOptions{Method: OdomResetInitialPose, Topic: "/initialpose"})
+	if _, err := r.ResetOdometry(nil, false); !errors.Is(err, ErrResetPoseRequired) {
+		t.Errorf("initial pose without a pose or home: %v", err)
+	}
+
+	r.mu.Lock()
+	r.connected = false
+	r.mu.Unlock()
+	if _, err := r.ResetOdometry(nil, true); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("disconnected: %v", err)
+	}
+	if n := serviceCalls(stub, "/reset_odom"); n != 2 || len(resets) != 2 {
+		t.Errorf("%d calls, %d events after the refusals, want 2", n, len(resets))
+	}
+}
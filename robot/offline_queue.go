@@ -0,0 +1,272 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ──────────────────────────── Offline command queue
+//
+// During a short connection drop, requests that are safe to run late
+// (uploading a point collection, saving settings, refreshing the map
+// list) can be queued instead of failing, when the robot's offline queue
+// is on. On reconnect they run in the order they were queued; entries
+// older than their TTL are dropped instead. Every outcome is reported
+// through OnPending. Motion and power requests (cmd_vel, go-all, patrol,
+// poweroff, reboot) are never queued: they keep failing at once.
+
+// Offline queue errors.
+var (
+	ErrOfflineQueueOff  = errors.New("offline queue is off for this robot")
+	ErrOfflineQueueFull = errors.New("offline queue full")
+)
+
+// Kinds of queued commands.
+const (
+	PendingNavSend      = "nav_send"      // upload a point collection; Target is its type
+	PendingSettingsSave = "settings_save" // settings_save task
+	PendingMapList      = "map_list"      // map list refresh
+)
+
+// Pending command states.
+const (
+	PendingQueued    = "queued"
+	PendingDone      = "done"
+	PendingFailed    = "failed"
+	PendingExpired   = "expired"
+	PendingCancelled = "cancelled"
+)
+
+// OfflineQueueOptions configure a robot's offline queue.
+type OfflineQueueOptions struct {
+	Enabled    bool    `json:"enabled"`
+	MaxEntries int     `json:"max_entries"` // queued commands kept at most
+	TTLSec     float64 `json:"ttl_s"`       // a command not run within this is dropped
+}
+
+// DefaultOfflineQueue is off; turned on, it keeps 16 commands for five
+// minutes.
+var DefaultOfflineQueue = OfflineQueueOptions{MaxEntries: 16, TTLSec: 300}
+
+// Validate checks the bounds.
+func (o OfflineQueueOptions) Validate() error {
+	switch {
+	case o.MaxEntries < 1 || o.MaxEntries > 100:
+		return errors.New("offline queue max entries must be in [1, 100]")
+	case o.TTLSec <= 0 || o.TTLSec > 86400:
+		return errors.New("offline queue TTL must be in (0, 86400] s")
+	}
+	return nil
+}
+
+// PendingCommand is a command waiting for the robot to reconnect, or the
+// outcome of one.
+type PendingCommand struct {
+	ID          int       `json:"id"`
+	Kind        string    `json:"kind"`             // nav_send, settings_save or map_list
+	Target      string    `json:"target,omitempty"` // point type of a nav_send
+	Description string    `json:"description"`
+	QueuedAt    time.Time `json:"queued_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+
+	run func() error
+}
+
+// offlineQueue holds a robot's pending commands. replayMu lets one replay
+// run at a time; mu guards the entries.
+type offlineQueue struct {
+	replayMu sync.Mutex
+	mu       sync.Mutex
+	entries  []*PendingCommand
+	nextID   int
+}
+
+// OfflineQueue returns the robot's offline queue options.
+func (r *Robot) OfflineQueue() OfflineQueueOptions {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.offlineOpts
+}
+
+// SetOfflineQueue changes the offline queue options. Turning the queue
+// off cancels what it holds; a lower MaxEntries drops the newest entries.
+func (r *Robot) SetOfflineQueue(o OfflineQueueOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.offlineOpts = o
+	r.mu.Unlock()
+
+	if !o.Enabled {
+		r.CancelPending(0)
+		return nil
+	}
+	q := &r.offline
+	q.mu.Lock()
+	var dropped []PendingCommand
+	for len(q.entries) > o.MaxEntries {
+		last := q.entries[len(q.entries)-1]
+		q.entries = q.entries[:len(q.entries)-1]
+		last.Status = PendingCancelled
+		dropped = append(dropped, *last)
+	}
+	q.mu.Unlock()
+	r.reportPending(dropped...)
+	return nil
+}
+
+// QueueOffline queues run to be called, in order, once the robot is
+// connected again. A command of the same kind and target already queued
+// is replaced in place: these commands are idempotent, only the latest
+// one matters. run should return ErrNotConnected if the connection drops
+// again; the command then stays queued.
+func (r *Robot) QueueOffline(kind, target, desc string, run func() error) (PendingCommand, error) {
+	opts := r.OfflineQueue()
+	if !opts.Enabled {
+		return PendingCommand{}, ErrOfflineQueueOff
+	}
+	now := time.Now()
+	ttl := time.Duration(opts.TTLSec * float64(time.Second))
+
+	q := &r.offline
+	q.mu.Lock()
+	expired := q.expireLocked(now)
+	var cmd *PendingCommand
+	for _, c := range q.entries {
+		if c.Kind == kind && c.Target == target {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		if len(q.entries) >= opts.MaxEntries {
+			q.mu.Unlock()
+			r.reportPending(expired...)
+			return PendingCommand{}, ErrOfflineQueueFull
+		}
+		q.nextID++
+		cmd = &PendingCommand{ID: q.nextID, Kind: kind, Target: target, Status: PendingQueued}
+		q.entries = append(q.entries, cmd)
+	}
+	cmd.Description = desc
+	cmd.QueuedAt, cmd.ExpiresAt = now, now.Add(ttl)
+	cmd.run = run
+	out := *cmd
+	q.mu.Unlock()
+
+	r.reportPending(expired...)
+	return out, nil
+}
+
+// PendingCommands returns the queued commands, oldest first. Expired ones
+// are dropped and reported.
+func (r *Robot) PendingCommands() []PendingCommand {
+	q := &r.offline
+	q.mu.Lock()
+	expired := q.expireLocked(time.Now())
+	out := make([]PendingCommand, 0, len(q.entries))
+	for _, c := range q.entries {
+		out = append(out, *c)
+	}
+	q.mu.Unlock()
+
+	r.reportPending(expired...)
+	return out
+}
+
+// CancelPending removes the queued command id, or every queued command
+// when id is 0, and returns how many were removed.
+func (r *Robot) CancelPending(id int) int {
+	q := &r.offline
+	q.mu.Lock()
+	var cancelled []PendingCommand
+	kept := q.entries[:0]
+	for _, c := range q.entries {
+		if id == 0 || c.ID == id {
+			c.Status = PendingCancelled
+			cancelled = append(cancelled, *c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	q.entries = kept
+	q.mu.Unlock()
+
+	r.reportPending(cancelled...)
+	return len(cancelled)
+}
+
+// ReplayPending runs the queued commands in order and returns their
+// outcomes, expired ones included. It stops, leaving the rest queued, if
+// the robot disconnects. Runs on connect, and may be called at any time
+// to flush the queue.
+func (r *Robot) ReplayPending() ([]PendingCommand, error) {
+	q := &r.offline
+	q.replayMu.Lock()
+	defer q.replayMu.Unlock()
+
+	var results []PendingCommand
+	for {
+		if !r.IsConnected() {
+			return results, ErrNotConnected
+		}
+		q.mu.Lock()
+		expired := q.expireLocked(time.Now())
+		var cmd *PendingCommand
+		if len(q.entries) > 0 {
+			cmd = q.entries[0]
+			q.entries = q.entries[1:]
+		}
+		q.mu.Unlock()
+		r.reportPending(expired...)
+		results = append(results, expired...)
+		if cmd == nil {
+			return results, nil
+		}
+
+		err := cmd.run()
+		if errors.Is(err, ErrNotConnected) || (err != nil && !r.IsConnected()) {
+			// Lost the connection again: keep it first in line
+			q.mu.Lock()
+			q.entries = append([]*PendingCommand{cmd}, q.entries...)
+			q.mu.Unlock()
+			return results, ErrNotConnected
+		}
+		cmd.Status = PendingDone
+		if err != nil {
+			cmd.Status, cmd.Error = PendingFailed, err.Error()
+		}
+		r.reportPending(*cmd)
+		results = append(results, *cmd)
+	}
+}
+
+// expireLocked drops the entries past their TTL and returns them.
+func (q *offlineQueue) expireLocked(now time.Time) []PendingCommand {
+	var expired []PendingCommand
+	kept := q.entries[:0]
+	for _, c := range q.entries {
+		if now.After(c.ExpiresAt) {
+			c.Status = PendingExpired
+			c.Error = fmt.Sprintf("not run within %s", c.ExpiresAt.Sub(c.QueuedAt).Round(time.Second))
+			expired = append(expired, *c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	q.entries = kept
+	return expired
+}
+
+func (r *Robot) reportPending(cmds ...PendingCommand) {
+	if r.OnPending == nil {
+		return
+	}
+	for _, c := range cmds {
+		r.OnPending(c)
+	}
+}
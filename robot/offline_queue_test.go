@@ -0,0 +1,195 @@
+package robot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pendingLog records a robot's queued command outcomes and the order the
+// commands ran in.
+type pendingLog struct {
+	mu       sync.Mutex
+	ran      []string
+	outcomes []string
+}
+
+func logPending(r *Robot) *pendingLog {
+	l := &pendingLog{}
+	r.OnPending = func(c PendingCommand) {
+		l.mu.Lock()
+		l.outcomes = append(l.outcomes, c.Description+":"+c.Status)
+		l.mu.Unlock()
+	}
+	return l
+}
+
+// cmd returns a run func recording desc and returning err.
+func (l *pendingLog) cmd(desc string, err error) func() error {
+	return func() error {
+		l.mu.Lock()
+		l.ran = append(l.ran, desc)
+		l.mu.Unlock()
+		return err
+	}
+}
+
+func (l *pendingLog) snapshot() (ran, outcomes []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.ran...), append([]string(nil), l.outcomes...)
+}
+
+func pendingDescs(r *Robot) []string {
+	var out []string
+	for _, c := range r.PendingCommands() {
+		out = append(out, c.Description)
+	}
+	return out
+}
+
+func TestOfflineQueue(t *testing.T) {
+	m := NewManager()
+	r, _ := m.AddRobot("", "q", "127.0.0.1", 9)
+	defer r.Close()
+	l := logPending(r)
+
+	if _, err := r.QueueOffline(PendingMapList, "", "map list", l.cmd("map list", nil)); !errors.Is(err, ErrOfflineQueueOff) {
+		t.Fatalf("queue off: %v", err)
+	}
+	if err := r.SetOfflineQueue(OfflineQueueOptions{Enabled: true, MaxEntries: 3}); err == nil {
+		t.Error("zero TTL accepted")
+	}
+	if err := r.SetOfflineQueue(OfflineQueueOptions{Enabled: true, MaxEntries: 3, TTLSec: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.QueueOffline(PendingNavSend, "waypoints", "waypoints v1", l.cmd("waypoints v1", nil))
+	r.QueueOffline(PendingSettingsSave, "", "settings", l.cmd("settings", nil))
+	first, _ := r.QueueOffline(PendingNavSend, "zones", "zones", l.cmd("zones", nil))
+	// The same kind and target replaces the queued copy in place
+	again, err := r.QueueOffline(PendingNavSend, "waypoints", "waypoints v2", l.cmd("waypoints v2", nil))
+	if err != nil || again.ID != 1 {
+		t.Errorf("replaced: %+v, %v", again, err)
+	}
+	if _, err := r.QueueOffline(PendingMapList, "", "map list", l.cmd("map list", nil)); !errors.Is(err, ErrOfflineQueueFull) {
+		t.Errorf("full: %v", err)
+	}
+	if got := pendingDescs(r); !sameEvents(got, "waypoints v2", "settings", "zones") {
+		t.Errorf("queued %v", got)
+	}
+
+	// Disconnected: nothing runs
+	if _, err := r.ReplayPending(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("replay while disconnected: %v", err)
+	}
+	if ran, _ := l.snapshot(); len(ran) != 0 {
+		t.Errorf("ran %v while disconnected", ran)
+	}
+
+	// A lower limit drops the newest; cancelling one removes only it
+	r.SetOfflineQueue(OfflineQueueOptions{Enabled: true, MaxEntries: 2, TTLSec: 60})
+	if got := pendingDescs(r); !sameEvents(got, "waypoints v2", "settings") {
+		t.Errorf("after lowering the limit: %v", got)
+	}
+	if r.CancelPending(first.ID) != 0 || r.CancelPending(2) != 1 {
+		t.Error("cancel counts")
+	}
+	if _, outcomes := l.snapshot(); !sameEvents(outcomes, "zones:cancelled", "settings:cancelled") {
+		t.Errorf("outcomes %v", outcomes)
+	}
+
+	// Turning the queue off cancels the rest
+	r.SetOfflineQueue(OfflineQueueOptions{MaxEntries: 2, TTLSec: 60})
+	if got := pendingDescs(r); len(got) != 0 {
+		t.Errorf("queue off, still queued %v", got)
+	}
+}
+
+// TestOfflineQueueReplay queues commands while the robot is offline, lets
+// the oldest expire, and checks what runs, in which order, on reconnect.
+func TestOfflineQueueReplay(t *testing.T) {
+	stub := newRosbridgeStub(t)
+	host, port := stub.addr(t)
+	m := NewManager()
+	r, _ := m.AddRobot("", "q", host, port)
+	defer r.Close()
+	l := logPending(r)
+	if err := r.SetOfflineQueue(OfflineQueueOptions{Enabled: true, MaxEntries: 10, TTLSec: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.QueueOffline(PendingMapList, "", "stale", l.cmd("stale", nil))
+	time.Sleep(400 * time.Millisecond)
+	r.QueueOffline(PendingNavSend, "waypoints", "waypoints", l.cmd("waypoints", nil))
+	r.QueueOffline(PendingSettingsSave, "", "settings", l.cmd("settings", errors.New("rejected")))
+	r.QueueOffline(PendingNavSend, "zones", "zones", l.cmd("zones", nil))
+	time.Sleep(200 * time.Millisecond) // stale is past its TTL, the rest are not
+
+	if err := r.Client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntilPending(t, l, 4)
+	ran, outcomes := l.snapshot()
+	if !sameEvents(ran, "waypoints", "settings", "zones") {
+		t.Errorf("ran %v", ran)
+	}
+	if !sameEvents(outcomes, "stale:expired", "waypoints:done", "settings:failed", "zones:done") {
+		t.Errorf("outcomes %v", outcomes)
+	}
+	if got := pendingDescs(r); len(got) != 0 {
+		t.Errorf("still queued %v", got)
+	}
+
+	// Losing the connection mid-replay keeps the command first in line
+	// for the next reconnect
+	r.SetOfflineQueue(OfflineQueueOptions{Enabled: true, MaxEntries: 10, TTLSec: 60})
+	drops := 0
+	r.QueueOffline(PendingSettingsSave, "", "flaky", func() error {
+		l.mu.Lock()
+		l.ran = append(l.ran, "flaky")
+		l.mu.Unlock()
+		if drops++; drops == 1 {
+			r.Client.Disconnect()
+			return ErrNotConnected
+		}
+		return nil
+	})
+	r.QueueOffline(PendingMapList, "", "after", l.cmd("after", nil))
+	if _, err := r.ReplayPending(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("replay across a drop: %v", err)
+	}
+	if got := pendingDescs(r); !sameEvents(got, "flaky", "after") {
+		t.Errorf("after the drop, queued %v", got)
+	}
+	waitUntil(t, "disconnect", func() bool { return !r.IsConnected() })
+
+	if err := r.Client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntilPending(t, l, 6)
+	ran, outcomes = l.snapshot()
+	if !sameEvents(ran[3:], "flaky", "flaky", "after") || !sameEvents(outcomes[4:], "flaky:done", "after:done") {
+		t.Errorf("second replay: ran %v, outcomes %v", ran[3:], outcomes[4:])
+	}
+}
+
+func waitUntilPending(t *testing.T, l *pendingLog, n int) {
+	t.Helper()
+	waitUntil(t, "queued command outcomes", func() bool {
+		_, outcomes := l.snapshot()
+		return len(outcomes) >= n
+	})
+}
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
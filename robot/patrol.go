@@ -0,0 +1,341 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Patrol loops
+//
+// A patrol re-runs go_all_patrolpoints lap after lap. A lap ends when the
+// navigation goal it started reaches a terminal state on the NavAction
+// status topic. The patrol stops after the requested laps or duration, on
+// stop or e-stop, or when a lap is aborted. One patrol runs per robot.
+//
+// If rosbridge drops, the patrol either aborts or waits for the robot to
+// come back, per PatrolResumeOnReconnect. On resume it keeps following
+// the lap's goal if the robot reports it again; otherwise the lap is
+// re-run from the first patrol point.
+
+// ErrPatrolRunning is returned when a patrol is started twice.
+var ErrPatrolRunning = errors.New("patrol already running")
+
+// Patrol states reported in PatrolStatus.
+const (
+	PatrolRunning   = "running"
+	PatrolWaiting   = "waiting_reconnect"
+	PatrolCompleted = "completed"
+	PatrolStopped   = "stopped"
+	PatrolAborted   = "aborted"
+)
+
+// Patrol events broadcast as PatrolEvent.Event.
+const (
+	PatrolEventStarted  = "started"
+	PatrolEventLap      = "lap_completed"
+	PatrolEventFinished = "finished"
+)
+
+const (
+	patrolTick         = 500 * time.Millisecond
+	patrolStartTimeout = 30 * time.Second // trigger → goal reported
+	patrolResyncWait   = 5 * time.Second  // after reconnect, wait for the lap's goal
+	patrolMaxLaps      = 10000
+	patrolMaxMinutes   = 7 * 24 * 60
+)
+
+// PatrolRequest limits a patrol; zero means unlimited. Force skips the
+// go-all proximity check for the first lap.
+type PatrolRequest struct {
+	Laps            int     `json:"laps"`
+	DurationMinutes float64 `json:"duration_minutes"`
+	Force           bool    `json:"force,omitempty"`
+}
+
+// Validate checks the request bounds.
+func (q PatrolRequest) Validate() error {
+	switch {
+	case q.Laps < 0 || q.Laps > patrolMaxLaps:
+		return fmt.Errorf("laps must be within 0..%d", patrolMaxLaps)
+	case q.DurationMinutes < 0 || q.DurationMinutes > patrolMaxMinutes:
+		return fmt.Errorf("duration_minutes must be within 0..%d", patrolMaxMinutes)
+	}
+	return nil
+}
+
+// PatrolStatus is the state of a robot's current or last patrol.
+type PatrolStatus struct {
+	Running         bool      `json:"running"`
+	State           string    `json:"state"`
+	Lap             int       `json:"lap"` // 1-based, current or last lap
+	LapsCompleted   int       `json:"laps_completed"`
+	MaxLaps         int       `json:"max_laps,omitempty"`
+	DurationMinutes float64   `json:"duration_minutes,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	ElapsedSec      float64   `json:"elapsed_sec"`
+	Reason          string    `json:"reason,omitempty"`
+}
+
+// PatrolEvent is broadcast when a patrol starts, completes a lap or ends.
+type PatrolEvent struct {
+	Event  string       `json:"event"`
+	Patrol PatrolStatus `json:"patrol"`
+}
+
+// activePatrol is the running patrol of a robot.
+type activePatrol struct {
+	cancel context.CancelFunc
+	nav    chan rosbridge.NavStatus
+	done   chan struct{}
+}
+
+// startPatrol starts the patrol controller; trigger runs one lap.
+func (r *Robot) startPatrol(q PatrolRequest, resume bool, trigger func() error) (PatrolStatus, error) {
+	if err := q.Validate(); err != nil {
+		return PatrolStatus{}, err
+	}
+
+	r.mu.Lock()
+	switch {
+	case r.estop:
+		r.mu.Unlock()
+		return PatrolStatus{}, ErrEStopped
+	case !r.connected:
+		r.mu.Unlock()
+		return PatrolStatus{}, ErrNotConnected
+	case r.patrol != nil:
+		r.mu.Unlock()
+		return PatrolStatus{}, ErrPatrolRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &activePatrol{cancel: cancel, nav: make(chan rosbridge.NavStatus, 16), done: make(chan struct{})}
+	r.patrol = p
+	r.patrolStatus = &PatrolStatus{
+		Running:         true,
+		State:           PatrolRunning,
+		Lap:             1,
+		MaxLaps:         q.Laps,
+		DurationMinutes: q.DurationMinutes,
+		StartedAt:       time.Now(),
+	}
+	st := *r.patrolStatus
+	r.mu.Unlock()
+	r.updateAutonomy()
+
+	r.emitPatrol(PatrolEventStarted, st)
+	go r.runPatrol(ctx, p, q, resume, trigger)
+	return st, nil
+}
+
+// stopPatrol cancels the running patrol and waits for it to finish.
+func (r *Robot) stopPatrol() bool {
+	r.mu.RLock()
+	p := r.patrol
+	r.mu.RUnlock()
+	if p == nil {
+		return false
+	}
+	p.cancel()
+	<-p.done
+	return true
+}
+
+// GetPatrolStatus returns the current or last patrol, or nil if the robot
+// never patrolled.
+func (r *Robot) GetPatrolStatus() *PatrolStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.patrolStatusLocked()
+}
+
+func (r *Robot) patrolStatusLocked() *PatrolStatus {
+	if r.patrolStatus == nil {
+		return nil
+	}
+	st := *r.patrolStatus
+	if st.Running {
+		st.ElapsedSec = time.Since(st.StartedAt).Seconds()
+	}
+	return &st
+}
+
+func (r *Robot) runPatrol(ctx context.Context, p *activePatrol, q PatrolRequest, resume bool, trigger func() error) {
+	defer close(p.done)
+	ticker := time.NewTicker(patrolTick)
+	defer ticker.Stop()
+
+	var deadline time.Time
+	if q.DurationMinutes > 0 {
+		deadline = time.Now().Add(time.Duration(q.DurationMinutes * float64(time.Minute)))
+	}
+
+	var (
+		goal        string    // goal ID of the current lap, once reported
+		prevGoal    string    // newest goal before the lap was triggered
+		triggeredAt time.Time // zero while no trigger is outstanding
+		waiting     bool      // disconnected, waiting to resume
+		resyncUntil time.Time // after reconnect, how long to wait for goal
+	)
+
+	update := func(fn func(st *PatrolStatus)) PatrolStatus {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		fn(r.patrolStatus)
+		return *r.patrolStatusLocked()
+	}
+
+	finish := func(state, reason string, cancelNav bool) {
+		if cancelNav {
+			go r.Client.CancelNavigation()
+		}
+		r.mu.Lock()
+		if r.patrol == p {
+			r.patrol = nil
+		}
+		st := r.patrolStatus
+		st.Running = false
+		st.State = state
+		st.Reason = reason
+		st.ElapsedSec = time.Since(st.StartedAt).Seconds()
+		final := *st
+		r.mu.Unlock()
+		r.updateAutonomy()
+		p.cancel()
+		r.emitPatrol(PatrolEventFinished, final)
+	}
+
+	// startLap triggers go_all_patrolpoints; next is false for the first
+	// lap and when an interrupted lap is re-run.
+	startLap := func(next bool) error {
+		r.mu.RLock()
+		prevGoal = r.navStatus.GoalID
+		r.mu.RUnlock()
+		goal = ""
+		triggeredAt = time.Now()
+		update(func(st *PatrolStatus) {
+			if next {
+				st.Lap++
+			}
+			st.State = PatrolRunning
+		})
+		return trigger()
+	}
+
+	// lapEnded handles a terminal goal state and reports whether the
+	// patrol is over.
+	lapEnded := func(s rosbridge.NavStatus) bool {
+		switch s.Status {
+		case rosbridge.GoalCanceled:
+			finish(PatrolStopped, "navigation cancelled", false)
+			return true
+		case rosbridge.GoalAborted:
+			finish(PatrolAborted, "navigation aborted", false)
+			return true
+		}
+		st := update(func(st *PatrolStatus) { st.LapsCompleted++ })
+		r.emitPatrol(PatrolEventLap, st)
+		switch {
+		case q.Laps > 0 && st.LapsCompleted >= q.Laps:
+			finish(PatrolCompleted, "lap limit reached", false)
+			return true
+		case !deadline.IsZero() && time.Now().After(deadline):
+			finish(PatrolCompleted, "duration reached", false)
+			return true
+		}
+		if err := startLap(true); err != nil {
+			finish(PatrolAborted, "go_all_patrolpoints: "+err.Error(), false)
+			return true
+		}
+		return false
+	}
+
+	if err := startLap(false); err != nil {
+		finish(PatrolAborted, "go_all_patrolpoints: "+err.Error(), false)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			finish(PatrolStopped, "stopped", false)
+			return
+
+		case s := <-p.nav:
+			if waiting {
+				continue
+			}
+			if goal == "" {
+				if s.GoalID == prevGoal {
+					continue // status of a goal from before this lap
+				}
+				goal = s.GoalID
+				triggeredAt = time.Time{}
+				resyncUntil = time.Time{}
+			}
+			if s.GoalID != goal {
+				continue
+			}
+			resyncUntil = time.Time{}
+			if s.Terminal() && lapEnded(s) {
+				return
+			}
+
+		case <-ticker.C:
+			r.mu.RLock()
+			estop, connected := r.estop, r.connected
+			r.mu.RUnlock()
+
+			switch {
+			case estop:
+				finish(PatrolAborted, "e-stop engaged", true)
+				return
+			case !deadline.IsZero() && time.Now().After(deadline):
+				finish(PatrolCompleted, "duration reached", true)
+				return
+			case !connected && !resume:
+				finish(PatrolAborted, "robot disconnected", false)
+				return
+			case !connected:
+				if !waiting {
+					waiting = true
+					update(func(st *PatrolStatus) { st.State = PatrolWaiting })
+				}
+				continue
+			case waiting:
+				waiting = false
+				update(func(st *PatrolStatus) { st.State = PatrolRunning })
+				if goal != "" {
+					resyncUntil = time.Now().Add(patrolResyncWait)
+					continue
+				}
+				if err := startLap(false); err != nil {
+					finish(PatrolAborted, "go_all_patrolpoints: "+err.Error(), false)
+					return
+				}
+				continue
+			}
+
+			if !resyncUntil.IsZero() && time.Now().After(resyncUntil) {
+				resyncUntil = time.Time{}
+				if err := startLap(false); err != nil {
+					finish(PatrolAborted, "go_all_patrolpoints: "+err.Error(), false)
+					return
+				}
+			}
+			if !triggeredAt.IsZero() && time.Since(triggeredAt) > patrolStartTimeout {
+				finish(PatrolAborted, "navigation did not start", false)
+				return
+			}
+		}
+	}
+}
+
+func (r *Robot) emitPatrol(event string, st PatrolStatus) {
+	if r.OnPatrolEvent != nil {
+		r.OnPatrolEvent(PatrolEvent{Event: event, Patrol: st})
+	}
+}
@@ -0,0 +1,251 @@
+package robot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// patrolRig drives a patrol without rosbridge: the robot is marked
+// connected, trigger counts the laps started, and nav statuses are fed
+// the way the NavAction status handler feeds them.
+type patrolRig struct {
+	r        *Robot
+	mu       sync.Mutex
+	triggers int
+	events   []PatrolEvent
+}
+
+func newPatrolRig(t *testing.T) *patrolRig {
+	t.Helper()
+	m := NewManager()
+	r, _ := m.AddRobot("", "patrol", "127.0.0.1", 9)
+	t.Cleanup(r.Close)
+	g := &patrolRig{r: r}
+	r.OnPatrolEvent = func(e PatrolEvent) {
+		g.mu.Lock()
+		g.events = append(g.events, e)
+		g.mu.Unlock()
+	}
+	g.setConnected(true)
+	return g
+}
+
+func (g *patrolRig) setConnected(c bool) {
+	g.r.mu.Lock()
+	g.r.connected = c
+	g.r.mu.Unlock()
+}
+
+func (g *patrolRig) start(t *testing.T, q PatrolRequest, resume bool) {
+	t.Helper()
+	if _, err := g.r.startPatrol(q, resume, g.trigger); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (g *patrolRig) trigger() error {
+	g.mu.Lock()
+	g.triggers++
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *patrolRig) triggered() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.triggers
+}
+
+// nav records s as the robot's nav status and hands it to the patrol.
+func (g *patrolRig) nav(goal string, status int) {
+	s := rosbridge.NavStatus{GoalID: goal, Status: status}
+	g.r.mu.Lock()
+	g.r.navStatus = s
+	p := g.r.patrol
+	g.r.mu.Unlock()
+	if p != nil {
+		p.nav <- s
+	}
+}
+
+// lap runs one lap on goal: waits for its trigger, reports the goal
+// executing, then ending with status.
+func (g *patrolRig) lap(t *testing.T, n int, goal string, status int) {
+	t.Helper()
+	waitUntil(t, "lap trigger", func() bool { return g.triggered() >= n })
+	g.nav(goal, rosbridge.GoalExecuting)
+	g.nav(goal, status)
+}
+
+// finished waits for the patrol to end and returns its final status.
+func (g *patrolRig) finished(t *testing.T) PatrolStatus {
+	t.Helper()
+	waitUntil(t, "the patrol to finish", func() bool {
+		st := g.r.GetPatrolStatus()
+		return st != nil && !st.Running
+	})
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	last := g.events[len(g.events)-1]
+	if last.Event != PatrolEventFinished {
+		t.Errorf("last event %q, want finished", last.Event)
+	}
+	return last.Patrol
+}
+
+func (g *patrolRig) eventCount(event string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for _, e := range g.events {
+		if e.Event == event {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPatrolLapLimit(t *testing.T) {
+	g := newPatrolRig(t)
+	g.nav("old", rosbridge.GoalSucceeded) // before the patrol: ignored
+	g.start(t, PatrolRequest{Laps: 2}, false)
+
+	g.nav("old", rosbridge.GoalSucceeded)
+	g.lap(t, 1, "g1", rosbridge.GoalSucceeded)
+	g.lap(t, 2, "g2", rosbridge.GoalSucceeded)
+
+	st := g.finished(t)
+	if st.State != PatrolCompleted || st.Reason != "lap limit reached" || st.LapsCompleted != 2 || st.Lap != 2 {
+		t.Errorf("final %+v", st)
+	}
+	if n := g.triggered(); n != 2 {
+		t.Errorf("%d laps triggered, want 2", n)
+	}
+	if n := g.eventCount(PatrolEventLap); n != 2 {
+		t.Errorf("%d lap events, want 2", n)
+	}
+	g.r.mu.RLock()
+	defer g.r.mu.RUnlock()
+	if g.r.patrol != nil {
+		t.Error("patrol still registered")
+	}
+}
+
+func TestPatrolDurationLimit(t *testing.T) {
+	g := newPatrolRig(t)
+	g.start(t, PatrolRequest{DurationMinutes: 0.0005}, false) // 30 ms
+	time.Sleep(50 * time.Millisecond)
+	g.lap(t, 1, "g1", rosbridge.GoalSucceeded)
+
+	st := g.finished(t)
+	if st.State != PatrolCompleted || st.Reason != "duration reached" || st.LapsCompleted != 1 {
+		t.Errorf("final %+v", st)
+	}
+}
+
+func TestPatrolLapEnds(t *testing.T) {
+	for _, tc := range []struct {
+		status        int
+		state, reason string
+	}{
+		{rosbridge.GoalCanceled, PatrolStopped, "navigation cancelled"},
+		{rosbridge.GoalAborted, PatrolAborted, "navigation aborted"},
+	} {
+		g := newPatrolRig(t)
+		g.start(t, PatrolRequest{}, false)
+		g.lap(t, 1, "g1", tc.status)
+		if st := g.finished(t); st.State != tc.state || st.Reason != tc.reason || st.LapsCompleted != 0 {
+			t.Errorf("goal status %d: final %+v", tc.status, st)
+		}
+	}
+}
+
+func TestPatrolStopAndEStop(t *testing.T) {
+	g := newPatrolRig(t)
+	g.start(t, PatrolRequest{}, false)
+	if _, err := g.r.startPatrol(PatrolRequest{}, false, g.trigger); !errors.Is(err, ErrPatrolRunning) {
+		t.Errorf("second patrol: %v", err)
+	}
+	if !g.r.stopPatrol() {
+		t.Fatal("nothing to stop")
+	}
+	if st := g.finished(t); st.State != PatrolStopped || st.Reason != "stopped" {
+		t.Errorf("stopped: %+v", st)
+	}
+
+	g.start(t, PatrolRequest{}, false)
+	g.r.mu.Lock()
+	g.r.estop = true
+	g.r.mu.Unlock()
+	if st := g.finished(t); st.State != PatrolAborted || st.Reason != "e-stop engaged" {
+		t.Errorf("e-stop: %+v", st)
+	}
+	if _, err := g.r.startPatrol(PatrolRequest{}, false, g.trigger); !errors.Is(err, ErrEStopped) {
+		t.Errorf("start under e-stop: %v", err)
+	}
+}
+
+func TestPatrolDisconnectAborts(t *testing.T) {
+	g := newPatrolRig(t)
+	g.start(t, PatrolRequest{}, false)
+	g.setConnected(false)
+	if st := g.finished(t); st.State != PatrolAborted || st.Reason != "robot disconnected" {
+		t.Errorf("final %+v", st)
+	}
+	if _, err := g.r.startPatrol(PatrolRequest{}, false, g.trigger); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("start while disconnected: %v", err)
+	}
+}
+
+// TestPatrolResumesLap drops the connection mid-lap: the patrol waits,
+// ignores statuses meanwhile and, once back, keeps following the lap's
+// goal instead of re-running it.
+func TestPatrolResumesLap(t *testing.T) {
+	g := newPatrolRig(t)
+	g.start(t, PatrolRequest{Laps: 1}, true)
+	waitUntil(t, "lap trigger", func() bool { return g.triggered() == 1 })
+	g.nav("g1", rosbridge.GoalExecuting)
+
+	g.setConnected(false)
+	waitUntil(t, "waiting state", func() bool { return g.r.GetPatrolStatus().State == PatrolWaiting })
+	g.nav("g1", rosbridge.GoalSucceeded) // while waiting: ignored
+	if st := g.r.GetPatrolStatus(); st.LapsCompleted != 0 || !st.Running {
+		t.Errorf("counted a lap while waiting: %+v", st)
+	}
+
+	g.setConnected(true)
+	waitUntil(t, "running state", func() bool { return g.r.GetPatrolStatus().State == PatrolRunning })
+	g.nav("g1", rosbridge.GoalSucceeded)
+	st := g.finished(t)
+	if st.State != PatrolCompleted || st.LapsCompleted != 1 {
+		t.Errorf("final %+v", st)
+	}
+	if n := g.triggered(); n != 1 {
+		t.Errorf("lap triggered %d times, want once", n)
+	}
+}
+
+// TestPatrolRerunsUnstartedLap drops the connection before the lap's
+// goal was reported: the lap is triggered again on reconnect.
+func TestPatrolRerunsUnstartedLap(t *testing.T) {
+	g := newPatrolRig(t)
+	g.start(t, PatrolRequest{Laps: 1}, true)
+	waitUntil(t, "lap trigger", func() bool { return g.triggered() == 1 })
+
+	g.setConnected(false)
+	waitUntil(t, "waiting state", func() bool { return g.r.GetPatrolStatus().State == PatrolWaiting })
+	g.setConnected(true)
+	waitUntil(t, "lap re-run", func() bool { return g.triggered() == 2 })
+	if st := g.r.GetPatrolStatus(); st.Lap != 1 || st.State != PatrolRunning {
+		t.Errorf("after the re-run: %+v", st)
+	}
+
+	g.lap(t, 2, "g1", rosbridge.GoalSucceeded)
+	if st := g.finished(t); st.State != PatrolCompleted || st.LapsCompleted != 1 {
+		t.Errorf("final %+v", st)
+	}
+}
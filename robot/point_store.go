@@ -0,0 +1,331 @@
+package robot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ──────────────────────────── Point persistence
+//
+// Each robot's navigation points and walls, the current map's and the
+// other maps' (see floors.go), are kept in <dir>/<robot>.json so a
+// restart or crash doesn't lose them. The file is written once the
+// collections have stopped changing for the save delay, whatever changed
+// them: the persistence loop compares a hash of the robot's points with
+// the last one written (as the sync marks do, see nav_sync.go) instead of
+// every mutator reporting its edits. Files are written to a temporary
+// file and renamed over the old one, so a crash mid-write leaves the
+// previous version. A robot's file is loaded when it is added. The
+// file also carries the robot's home pose (see home.go), so it survives
+// a restart along with the points it usually sits among.
+
+// PointsFileVersion is the schema version of point files. Files without
+// one are version 1; newer versions are refused rather than misread.
+const PointsFileVersion = 1
+
+// PointPersistInterval is how often the persistence loop looks for
+// changed points.
+const PointPersistInterval = 500 * time.Millisecond
+
+// DefaultPointSaveDelay is how long points must stay unchanged before
+// they are written.
+const DefaultPointSaveDelay = 2 * time.Second
+
+// PointsFile is the on-disk form of a robot's points: the current map's
+// collections at the top level and the other maps' in OtherMaps.
+type PointsFile struct {
+	Version    int       `json:"version"`
+	Robot      string    `json:"robot"`
+	SavedAt    time.Time `json:"saved_at"`
+	CurrentMap string    `json:"current_map,omitempty"`
+	Home       *HomePose `json:"home,omitempty"`
+	MapPoints
+	OtherMaps map[string]MapPoints `json:"map_points,omitempty"`
+}
+
+// PersistStatus is the state of one robot's point file.
+type PersistStatus struct {
+	RobotID     string     `json:"robot_id,omitempty"`
+	Robot       string     `json:"robot"`
+	Path        string     `json:"path"`
+	Pending     bool       `json:"pending"` // changed, waiting for the save delay
+	LastWrite   *time.Time `json:"last_write,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// pointFileState tracks one robot's file: the hash last written or
+// loaded, and a change waiting for the save delay.
+type pointFileState struct {
+	written string
+	pending string
+	since   time.Time
+	status  PersistStatus
+}
+
+// PointStore keeps point files under Dir.
+type PointStore struct {
+	Dir   string
+	Delay time.Duration // DefaultPointSaveDelay when <= 0
+
+	mu    sync.Mutex
+	files map[string]*pointFileState // by robot store key
+}
+
+// NewPointStore returns a store writing under dir after delay.
+func NewPointStore(dir string, delay time.Duration) *PointStore {
+	return &PointStore{Dir: dir, Delay: delay, files: make(map[string]*pointFileState)}
+}
+
+// Path returns the point file of the robot with store key key.
+func (s *PointStore) Path(key string) string {
+	return filepath.Join(s.Dir, safeFileName(key)+".json")
+}
+
+// Load reads the point file of key; ok is false when there is none.
+func (s *PointStore) Load(key string) (f PointsFile, ok bool, err error) {
+	data, err := os.ReadFile(s.Path(key))
+	if os.IsNotExist(err) {
+		return PointsFile{}, false, nil
+	}
+	if err != nil {
+		return PointsFile{}, false, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return PointsFile{}, false, fmt.Errorf("%s: %w", s.Path(key), err)
+	}
+	switch {
+	case f.Version == 0:
+		f.Version = 1
+	case f.Version > PointsFileVersion:
+		return PointsFile{}, false, fmt.Errorf("%s: schema version %d is newer than %d", s.Path(key), f.Version, PointsFileVersion)
+	}
+	return f, true, nil
+}
+
+// Write stores f as the point file of key, atomically.
+func (s *PointStore) Write(key string, f PointsFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path(key), data)
+}
+
+// writeFileAtomic replaces path with data: written to a temporary file
+// in the same directory, synced, then renamed over it, so a crash leaves
+// either the old or the new file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *PointStore) stateLocked(key string) *pointFileState {
+	st := s.files[key]
+	if st == nil {
+		st = &pointFileState{status: PersistStatus{Robot: key, Path: s.Path(key)}}
+		s.files[key] = st
+	}
+	return st
+}
+
+// pointsHash is the hash of f's contents, whenever it was saved.
+func pointsHash(f PointsFile) string {
+	f.SavedAt = time.Time{}
+	return hashPoints(f)
+}
+
+// loaded records f as what the file of key holds.
+func (s *PointStore) loaded(key string, f PointsFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stateLocked(key)
+	st.written, st.pending = pointsHash(f), ""
+}
+
+// observe writes f, robot id's points, once they have been unchanged
+// for the save delay since they last differed from the file, or at once
+// with flush.
+func (s *PointStore) observe(id string, f PointsFile, now time.Time, flush bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stateLocked(f.Robot)
+	st.status.RobotID = id
+	h := pointsHash(f)
+	if h == st.written {
+		st.pending = ""
+		return
+	}
+	if h != st.pending {
+		st.pending, st.since = h, now
+	}
+	delay := s.Delay
+	if delay <= 0 {
+		delay = DefaultPointSaveDelay
+	}
+	if !flush && now.Sub(st.since) < delay {
+		return
+	}
+
+	f.Version, f.SavedAt = PointsFileVersion, now
+	if err := s.Write(f.Robot, f); err != nil {
+		// Retried after another delay
+		st.since = now
+		st.status.LastError, st.status.LastErrorAt = err.Error(), &now
+		return
+	}
+	st.written, st.pending = h, ""
+	st.status.LastWrite = &now
+}
+
+// Status returns the state of the point file of key.
+func (s *PointStore) Status(key string) PersistStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stateLocked(key)
+	out := st.status
+	out.Pending = st.pending != ""
+	return out
+}
+
+// ──────────────────────────── Robot side
+
+// PersistedPoints returns the robot's points as they are stored.
+func (r *Robot) PersistedPoints() PointsFile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f := PointsFile{
+		Version:    PointsFileVersion,
+		Robot:      r.StoreKey(),
+		CurrentMap: r.currentMap,
+		Home:       r.home.clone(),
+		MapPoints:  r.livePointsLocked().clone(),
+	}
+	if len(r.mapPoints) > 0 {
+		f.OtherMaps = make(map[string]MapPoints, len(r.mapPoints))
+		for name, p := range r.mapPoints {
+			f.OtherMaps[name] = p.clone()
+		}
+	}
+	return f
+}
+
+// RestorePoints replaces the robot's points, current map and home with
+// f's. A home that doesn't validate is dropped.
+func (r *Robot) RestorePoints(f PointsFile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.currentMap = f.CurrentMap
+	r.home = nil
+	if f.Home != nil && f.Home.Validate() == nil {
+		r.home = f.Home.clone()
+	}
+	r.activeFloor = r.floors[f.CurrentMap]
+	r.setLivePointsLocked(f.MapPoints.clone())
+	r.mapPoints = nil
+	for name, p := range f.OtherMaps {
+		if r.mapPoints == nil {
+			r.mapPoints = make(map[string]MapPoints, len(f.OtherMaps))
+		}
+		r.mapPoints[name] = p.clone()
+	}
+}
+
+// ──────────────────────────── Manager side
+
+// restorePoints loads r's point file, if any. Called by AddRobot.
+func (m *Manager) restorePoints(r *Robot) {
+	key := r.StoreKey()
+	f, ok, err := m.Points.Load(key)
+	switch {
+	case err != nil:
+		// Moved aside so the next write doesn't replace it
+		bad := m.Points.Path(key) + ".bad"
+		log.Printf("[points] %s: not restored: %v (moved to %s)", key, err, bad)
+		os.Rename(m.Points.Path(key), bad)
+		m.Points.loaded(key, r.PersistedPoints())
+	case !ok:
+		m.Points.loaded(key, r.PersistedPoints())
+	default:
+		r.RestorePoints(f)
+		m.Points.loaded(key, r.PersistedPoints())
+		n := len(f.Waypoints) + len(f.ServicePoints) + len(f.PatrolPoints) + len(f.PathPoints) + len(f.WallObstacles)
+		log.Printf("[points] %s: restored %d points and walls (%d other maps)", key, n, len(f.OtherMaps))
+	}
+}
+
+// RunPointPersistence writes changed points every interval until ctx is
+// cancelled. Without a store it returns at once.
+func (m *Manager) RunPointPersistence(ctx context.Context, interval time.Duration) {
+	if m.Points == nil {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		m.persistPoints(time.Now(), false)
+	}
+}
+
+// FlushPoints writes every robot's changed points now, without waiting
+// for the save delay; used on shutdown.
+func (m *Manager) FlushPoints() {
+	if m.Points != nil {
+		m.persistPoints(time.Now(), true)
+	}
+}
+
+func (m *Manager) persistPoints(now time.Time, flush bool) {
+	for _, r := range m.GetAllRobots() {
+		m.Points.observe(r.ID, r.PersistedPoints(), now, flush)
+	}
+}
+
+// PointPersistence returns the point file state of every robot, in
+// robot ID order.
+func (m *Manager) PointPersistence() []PersistStatus {
+	robots := m.GetAllRobots()
+	out := make([]PersistStatus, 0, len(robots))
+	for _, r := range robots {
+		st := m.Points.Status(r.StoreKey())
+		st.RobotID = r.ID
+		out = append(out, st)
+	}
+	// IDs are numbers
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].RobotID, out[j].RobotID
+		return len(a) < len(b) || len(a) == len(b) && a < b
+	})
+	return out
+}
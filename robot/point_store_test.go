@@ -0,0 +1,197 @@
+package robot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// pointsOf returns a points file of robot holding the named waypoints.
+func pointsOf(robot string, names ...string) PointsFile {
+	f := PointsFile{Robot: robot}
+	for i, n := range names {
+		f.Waypoints = append(f.Waypoints, rosbridge.NavigationPoint{Name: n, WorldXM: float64(i)})
+	}
+	return f
+}
+
+// dirEntries lists the names in dir.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// TestPointStoreDebounce drives observe with a fake clock: points are
+// written once unchanged for the delay, and every change restarts it.
+func TestPointStoreDebounce(t *testing.T) {
+	dir := t.TempDir()
+	s := NewPointStore(dir, time.Second)
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+	s.loaded("amr", pointsOf("amr"))
+
+	s.observe("1", pointsOf("amr", "dock"), at(0), false)
+	if st := s.Status("amr"); !st.Pending || st.LastWrite != nil || st.RobotID != "1" {
+		t.Errorf("changed: %+v", st)
+	}
+	s.observe("1", pointsOf("amr", "dock", "desk"), at(800*time.Millisecond), false)
+	s.observe("1", pointsOf("amr", "dock", "desk"), at(1500*time.Millisecond), false)
+	if _, ok, _ := s.Load("amr"); ok {
+		t.Fatal("written before the restarted delay ran out")
+	}
+	s.observe("1", pointsOf("amr", "dock", "desk"), at(1800*time.Millisecond), false)
+	f, ok, err := s.Load("amr")
+	if err != nil || !ok || len(f.Waypoints) != 2 || f.Version != PointsFileVersion || !f.SavedAt.Equal(at(1800*time.Millisecond)) {
+		t.Fatalf("written %+v, %v, %v", f, ok, err)
+	}
+	if st := s.Status("amr"); st.Pending || st.LastWrite == nil || st.Path != filepath.Join(dir, "amr.json") {
+		t.Errorf("after the write: %+v", st)
+	}
+	if names := dirEntries(t, dir); len(names) != 1 || names[0] != "amr.json" {
+		t.Errorf("files %v, want amr.json alone", names)
+	}
+
+	// Back to what was written: nothing pending, nothing written
+	s.observe("1", pointsOf("amr", "dock"), at(2*time.Second), false)
+	s.observe("1", pointsOf("amr", "dock", "desk"), at(2100*time.Millisecond), false)
+	if st := s.Status("amr"); st.Pending {
+		t.Error("pending after a change was undone")
+	}
+
+	// A flush writes at once
+	s.observe("1", pointsOf("amr"), at(3*time.Second), true)
+	if f, _, _ := s.Load("amr"); len(f.Waypoints) != 0 {
+		t.Errorf("flushed %d waypoints", len(f.Waypoints))
+	}
+}
+
+func TestPointStoreWriteError(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "points")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewPointStore(blocker, time.Second) // a file where the directory should be
+	t0 := time.Now()
+	s.loaded("amr", pointsOf("amr"))
+
+	s.observe("1", pointsOf("amr", "dock"), t0, true)
+	st := s.Status("amr")
+	if st.LastError == "" || st.LastErrorAt == nil || !st.Pending || st.LastWrite != nil {
+		t.Fatalf("failed write: %+v", st)
+	}
+
+	// Retried once another delay has passed
+	os.Remove(blocker)
+	s.observe("1", pointsOf("amr", "dock"), t0.Add(500*time.Millisecond), false)
+	if _, ok, _ := s.Load("amr"); ok {
+		t.Error("retried before the delay")
+	}
+	s.observe("1", pointsOf("amr", "dock"), t0.Add(1100*time.Millisecond), false)
+	if _, ok, err := s.Load("amr"); !ok || err != nil {
+		t.Errorf("retry: %v, %v", ok, err)
+	}
+}
+
+func TestPointStoreLoad(t *testing.T) {
+	s := NewPointStore(t.TempDir(), 0)
+	if _, ok, err := s.Load("none"); ok || err != nil {
+		t.Errorf("no file: %v, %v", ok, err)
+	}
+	for name, data := range map[string]string{
+		"v1":     `{"robot": "v1", "waypoints": [{"name": "dock"}]}`,
+		"newer":  `{"version": 2, "robot": "newer"}`,
+		"broken": `{"robot": `,
+	} {
+		os.WriteFile(s.Path(name), []byte(data), 0644)
+	}
+	if f, ok, err := s.Load("v1"); err != nil || !ok || f.Version != 1 || len(f.Waypoints) != 1 {
+		t.Errorf("no version: %+v, %v, %v", f, ok, err)
+	}
+	if _, _, err := s.Load("newer"); err == nil || !strings.Contains(err.Error(), "schema version 2") {
+		t.Errorf("newer schema: %v", err)
+	}
+	if _, _, err := s.Load("broken"); err == nil {
+		t.Error("broken file loaded")
+	}
+}
+
+// TestPointsRestoredOnAdd saves a robot's points through the manager and
+// adds the robot again.
+func TestPointsRestoredOnAdd(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager()
+	m.Points = NewPointStore(dir, time.Hour)
+	nm := NewNavigationManager()
+
+	r, _ := m.AddRobot("amr", "", "127.0.0.1", 9)
+	if st := m.PointPersistence(); len(st) != 1 || st[0].Pending || st[0].RobotID != r.ID {
+		t.Errorf("fresh robot: %+v", st)
+	}
+	r.SetCurrentMap("floor1")
+	nm.AddWaypoint(r, "dock", 1, 2, 0)
+	r.mu.Lock()
+	r.switchMapLocked("floor2")
+	r.mu.Unlock()
+	nm.AddWaypoint(r, "lift", 3, 4, 0)
+	home := HomePose{Pose2D: rosbridge.Pose2D{X: 1, Y: -2, Theta: 0.5}, Map: "floor2"}
+	if err := r.SetHome(&home); err != nil {
+		t.Fatal(err)
+	}
+	m.persistPoints(time.Now(), false)
+	if st := m.PointPersistence(); !st[0].Pending {
+		t.Errorf("not pending within the delay: %+v", st)
+	}
+	// Removing the robot writes what is pending
+	m.RemoveRobot(r.ID)
+	path := m.Points.Path("amr")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := m.Points.Status("amr").LastWrite
+
+	r, _ = m.AddRobot("amr", "", "127.0.0.1", 9)
+	defer m.RemoveRobot(r.ID)
+	if r.CurrentMap() != "floor2" || len(r.Waypoints) != 1 || r.Waypoints[0].Name != "lift" {
+		t.Errorf("restored %q %+v", r.CurrentMap(), r.Waypoints)
+	}
+	if other := r.mapPoints["floor1"]; len(other.Waypoints) != 1 || other.Waypoints[0].Name != "dock" {
+		t.Errorf("other maps %+v", r.mapPoints)
+	}
+	if h := r.GetHome(); h == nil || *h != home {
+		t.Errorf("restored home %+v, want %+v", h, home)
+	}
+	// Restored points are what the file holds: not written again
+	m.FlushPoints()
+	if again, _ := os.Stat(path); !again.ModTime().Equal(info.ModTime()) || m.PointPersistence()[0].LastWrite != written {
+		t.Error("restored points rewritten")
+	}
+}
+
+func TestPointsNewerSchemaMovedAside(t *testing.T) {
+	m := NewManager()
+	m.Points = NewPointStore(t.TempDir(), time.Hour)
+	path := m.Points.Path("amr")
+	os.WriteFile(path, []byte(`{"version": 99, "waypoints": [{"name": "dock"}]}`), 0644)
+
+	r, _ := m.AddRobot("amr", "", "127.0.0.1", 9)
+	defer m.RemoveRobot(r.ID)
+	if len(r.Waypoints) != 0 {
+		t.Errorf("newer schema read: %+v", r.Waypoints)
+	}
+	if data, err := os.ReadFile(path + ".bad"); err != nil || !strings.Contains(string(data), `"version": 99`) {
+		t.Errorf("not moved aside: %v", err)
+	}
+}
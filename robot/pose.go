@@ -0,0 +1,81 @@
+package robot
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ErrPoseStale is returned when neither map_bfp nor TF is recent enough
+// to trust as the robot's current map pose.
+var ErrPoseStale = errors.New("no fresh robot pose: neither map_bfp nor TF received recently")
+
+// Pose sources reported by CurrentMapPose.
+const (
+	PoseSourceMapBfp = "map_bfp"
+	PoseSourceTF     = "tf"
+)
+
+// CurrentMapPose returns the robot's map-frame pose, preferring map_bfp
+// and falling back to map→odom→base_footprint from TF. Data older than
+// maxAge is rejected.
+func (r *Robot) CurrentMapPose(maxAge time.Duration) (rosbridge.Pose2D, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	if !r.lastMapBfpTime.IsZero() && now.Sub(r.lastMapBfpTime) <= maxAge {
+		return r.MapBfp, PoseSourceMapBfp, nil
+	}
+	if r.TFReceived && !r.lastTFTime.IsZero() && now.Sub(r.lastTFTime) <= maxAge {
+		return mapPoseFromTF(r.TF), PoseSourceTF, nil
+	}
+	return rosbridge.Pose2D{}, "", ErrPoseStale
+}
+
+// mapPoseFromTF composes map→odom with odom→base_footprint.
+func mapPoseFromTF(tf rosbridge.TFData) rosbridge.Pose2D {
+	moYaw := math.Atan2(
+		2.0*(tf.MapOdomRw*tf.MapOdomRz+tf.MapOdomRx*tf.MapOdomRy),
+		1.0-2.0*(tf.MapOdomRy*tf.MapOdomRy+tf.MapOdomRz*tf.MapOdomRz),
+	)
+	cos, sin := math.Cos(moYaw), math.Sin(moYaw)
+	return rosbridge.Pose2D{
+		X:     tf.MapOdomTx + cos*tf.BfpTx - sin*tf.BfpTy,
+		Y:     tf.MapOdomTy + sin*tf.BfpTx + cos*tf.BfpTy,
+		Theta: normalizeAngle(moYaw + tf.BfpYaw),
+	}
+}
+
+// PointNear is a navigation point close to the robot.
+type PointNear struct {
+	Type      rosbridge.PointType `json:"type"`
+	Name      string              `json:"name"`
+	DistanceM float64             `json:"distance_m"`
+}
+
+// NearestPoint returns the waypoint, service, patrol or path point
+// closest to the robot's current map pose (no older than maxAge), if one
+// is within maxDist metres.
+func (r *Robot) NearestPoint(maxDist float64, maxAge time.Duration) (PointNear, bool) {
+	pose, _, err := r.CurrentMapPose(maxAge)
+	if err != nil {
+		return PointNear{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var best PointNear
+	found := false
+	for _, t := range rosbridge.NavPointTypes {
+		for _, p := range *r.pointCollection(t) {
+			d := math.Hypot(p.WorldXM-pose.X, p.WorldYM-pose.Y)
+			if d <= maxDist && (!found || d < best.DistanceM) {
+				best, found = PointNear{Type: t, Name: p.Name, DistanceM: d}, true
+			}
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,263 @@
+package robot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Power transitions
+//
+// A poweroff or reboot is only as good as the robot's answer: a refusal
+// (say, because it is charging) is returned as a TaskRefusedError and
+// changes nothing. An acknowledged one puts the robot in an explicit
+// state for the expected downtime, so the dropped connection reads as
+// planned rather than as a fault:
+//   - rebooting: until the robot is back. The client keeps retrying
+//     through the window whatever its reconnect policy, without the
+//     "gave up reconnecting" notice; on reconnect the state clears and
+//     the handshake is redone. Not back when the window ends:
+//     unreachable, with an alert.
+//   - shutting_down: until the connection drops, then powered_off until
+//     the robot is seen again. Still connected when the window ends: it
+//     didn't power off; alert and back to normal.
+//
+// Unreachable clears, like powered_off, when the robot connects again.
+
+// Power states.
+const (
+	PowerNormal       = "normal"
+	PowerShuttingDown = "shutting_down"
+	PowerOff          = "powered_off"
+	PowerRebooting    = "rebooting"
+	PowerUnreachable  = "unreachable"
+)
+
+// DefaultExpectedDowntime is how long a rebooting robot has to come back,
+// and a robot told to power off to go down, unless configured.
+const DefaultExpectedDowntime = 3 * time.Minute
+
+// TaskRefusedError is returned when the robot answered a task with a
+// refusal.
+type TaskRefusedError struct {
+	Task   string
+	Status int
+	Reason string // the robot's response_settings
+}
+
+func (e *TaskRefusedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("robot refused %s (status %d)", e.Task, e.Status)
+	}
+	return fmt.Sprintf("robot refused %s (status %d): %s", e.Task, e.Status, e.Reason)
+}
+
+// PowerStatus is the robot's power state.
+type PowerStatus struct {
+	State string     `json:"state"`
+	Since *time.Time `json:"since,omitempty"`
+	// Deadline is when the expected downtime ends, while one runs.
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// Expected reports whether the robot is down, or going down, on request.
+func (s PowerStatus) Expected() bool {
+	return s.State == PowerShuttingDown || s.State == PowerOff || s.State == PowerRebooting
+}
+
+// PowerEvent is a power state change.
+type PowerEvent struct {
+	PowerStatus
+	Previous string `json:"previous"`
+	Message  string `json:"message"`
+	// Alert is set when the robot didn't do what it acknowledged.
+	Alert bool `json:"alert"`
+}
+
+// powerTransition is the robot's power state, guarded by r.mu.
+type powerTransition struct {
+	state    string
+	since    time.Time
+	deadline time.Time
+	timer    *time.Timer
+	gen      int // invalidates the timers of earlier transitions
+	downtime time.Duration
+}
+
+// SetExpectedDowntime sets the window of later poweroffs and reboots
+// (0: DefaultExpectedDowntime).
+func (r *Robot) SetExpectedDowntime(d time.Duration) {
+	r.mu.Lock()
+	r.power.downtime = d
+	r.mu.Unlock()
+}
+
+// PowerStatus returns the robot's power state.
+func (r *Robot) PowerStatus() PowerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.powerStatusLocked()
+}
+
+func (r *Robot) powerStatusLocked() PowerStatus {
+	p := &r.power
+	if p.state == "" || p.state == PowerNormal {
+		return PowerStatus{State: PowerNormal}
+	}
+	st := PowerStatus{State: p.state}
+	since := p.since
+	st.Since = &since
+	if !p.deadline.IsZero() {
+		deadline := p.deadline
+		st.Deadline = &deadline
+	}
+	return st
+}
+
+// RequestPowerOff flushes pending tasks and queues a poweroff. Once the
+// robot acknowledges it, it is shutting down.
+func (r *Robot) RequestPowerOff() (*rosbridge.WhichTaskResponse, error) {
+	return r.requestPowerCycle("poweroff", PowerShuttingDown)
+}
+
+// RequestReboot flushes pending tasks and queues a reboot. Once the
+// robot acknowledges it, it is rebooting.
+func (r *Robot) RequestReboot() (*rosbridge.WhichTaskResponse, error) {
+	return r.requestPowerCycle("reboot", PowerRebooting)
+}
+
+func (r *Robot) requestPowerCycle(task, state string) (*rosbridge.WhichTaskResponse, error) {
+	r.tasks.Flush()
+	resp, err := r.RequestTask(task, "")
+	if err != nil {
+		return resp, err
+	}
+	if resp.Refused() {
+		return resp, &TaskRefusedError{Task: task, Status: resp.Status, Reason: strings.TrimSpace(resp.ResponseSettings)}
+	}
+	r.beginPowerTransition(state)
+	return resp, nil
+}
+
+// beginPowerTransition enters state for the expected downtime.
+func (r *Robot) beginPowerTransition(state string) {
+	r.mu.Lock()
+	p := &r.power
+	window := p.downtime
+	if window <= 0 {
+		window = DefaultExpectedDowntime
+	}
+	prev := r.powerStatusLocked().State
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.gen++
+	gen := p.gen
+	p.state, p.since, p.deadline = state, time.Now(), time.Now().Add(window)
+	p.timer = time.AfterFunc(window, func() { r.powerWindowEnded(gen) })
+	ev := PowerEvent{PowerStatus: r.powerStatusLocked(), Previous: prev}
+	deadline, client := p.deadline, r.Client
+	r.mu.Unlock()
+
+	if state == PowerRebooting {
+		client.ExpectDowntime(deadline)
+		ev.Message = fmt.Sprintf("%s is rebooting; expected back within %s", r.Name, window.Round(time.Second))
+	} else {
+		ev.Message = fmt.Sprintf("%s is powering off", r.Name)
+	}
+	log.Printf("[robot %s] %s", r.ID, ev.Message)
+	r.emitPower(ev)
+}
+
+// powerDisconnected follows the connection dropping: a robot shutting
+// down is now off.
+func (r *Robot) powerDisconnected() {
+	r.mu.Lock()
+	p := &r.power
+	if p.state != PowerShuttingDown {
+		r.mu.Unlock()
+		return
+	}
+	r.stopPowerTimerLocked()
+	p.state, p.since, p.deadline = PowerOff, time.Now(), time.Time{}
+	ev := PowerEvent{PowerStatus: r.powerStatusLocked(), Previous: PowerShuttingDown,
+		Message: fmt.Sprintf("%s powered off", r.Name)}
+	r.mu.Unlock()
+	r.emitPower(ev)
+}
+
+// powerConnected follows the robot connecting: one that was rebooting,
+// off or unreachable is back, and is resynced.
+func (r *Robot) powerConnected() {
+	r.mu.Lock()
+	p := &r.power
+	prev := p.state
+	switch prev {
+	case PowerRebooting, PowerOff, PowerUnreachable:
+	default:
+		r.mu.Unlock()
+		return
+	}
+	r.stopPowerTimerLocked()
+	p.state, p.since, p.deadline = PowerNormal, time.Now(), time.Time{}
+	ev := PowerEvent{PowerStatus: r.powerStatusLocked(), Previous: prev,
+		Message: fmt.Sprintf("%s is back", r.Name)}
+	if prev == PowerRebooting {
+		ev.Message = fmt.Sprintf("%s is back after rebooting", r.Name)
+	}
+	r.mu.Unlock()
+	r.emitPower(ev)
+
+	// A rebooted robot may come back with another size or footprint
+	go func() {
+		if _, err := r.Handshake(); err != nil {
+			log.Printf("[robot %s] Handshake after %s failed: %v", r.ID, prev, err)
+		}
+	}()
+}
+
+// powerWindowEnded ends the expected downtime of transition gen.
+func (r *Robot) powerWindowEnded(gen int) {
+	r.mu.Lock()
+	p := &r.power
+	if p.gen != gen || p.state == PowerNormal || p.state == PowerOff {
+		r.mu.Unlock()
+		return
+	}
+	p.timer = nil
+	ev := PowerEvent{Previous: p.state, Alert: true}
+	switch {
+	case p.state == PowerRebooting && !r.connected:
+		p.state = PowerUnreachable
+		ev.Message = fmt.Sprintf("%s did not come back within %s of rebooting", r.Name, p.deadline.Sub(p.since).Round(time.Second))
+	case p.state == PowerRebooting: // a reconnect would have cleared it
+		p.state = PowerNormal
+		ev.Message = fmt.Sprintf("%s acknowledged the reboot but never went down", r.Name)
+	default: // shutting down, still connected
+		p.state = PowerNormal
+		ev.Message = fmt.Sprintf("%s acknowledged the poweroff but is still on", r.Name)
+	}
+	p.since, p.deadline = time.Now(), time.Time{}
+	ev.PowerStatus = r.powerStatusLocked()
+	r.mu.Unlock()
+	log.Printf("[robot %s] %s", r.ID, ev.Message)
+	r.emitPower(ev)
+}
+
+// stopPowerTimerLocked cancels the window's timer. Caller holds r.mu.
+func (r *Robot) stopPowerTimerLocked() {
+	if r.power.timer != nil {
+		r.power.timer.Stop()
+		r.power.timer = nil
+	}
+	r.power.gen++
+}
+
+func (r *Robot) emitPower(ev PowerEvent) {
+	if r.OnPowerState != nil {
+		r.OnPowerState(ev)
+	}
+}
@@ -0,0 +1,147 @@
+package robot
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// powerRobot returns a robot whose tasks are answered with status and
+// reason, with a 150 ms expected downtime, and a log of its power events.
+func powerRobot(t *testing.T, status int, reason string) (*Robot, func() []PowerEvent) {
+	t.Helper()
+	r := NewRobot("1", "", "amr", "127.0.0.1", 9)
+	t.Cleanup(r.Close)
+	r.tasks.Stop()
+	r.tasks = NewTaskQueue(func(name, settings string) (*rosbridge.WhichTaskResponse, error) {
+		return &rosbridge.WhichTaskResponse{TaskName: name, Status: status, ResponseSettings: reason}, nil
+	})
+	r.SetExpectedDowntime(150 * time.Millisecond)
+
+	var mu sync.Mutex
+	var events []PowerEvent
+	r.OnPowerState = func(ev PowerEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	}
+	return r, func() []PowerEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]PowerEvent(nil), events...)
+	}
+}
+
+// lastPower returns the newest of events.
+func lastPower(events []PowerEvent) PowerEvent {
+	if len(events) == 0 {
+		return PowerEvent{}
+	}
+	return events[len(events)-1]
+}
+
+func TestPowerRefused(t *testing.T) {
+	r, events := powerRobot(t, 3, " charging \n")
+	_, err := r.RequestReboot()
+	var refused *TaskRefusedError
+	if !errors.As(err, &refused) || refused.Task != "reboot" || refused.Status != 3 || refused.Reason != "charging" {
+		t.Fatalf("refusal: %v", err)
+	}
+	if st := r.PowerStatus(); st.State != PowerNormal || st.Since != nil || len(events()) != 0 {
+		t.Errorf("after a refusal: %+v, %d events", st, len(events()))
+	}
+}
+
+func TestPowerRebootReturns(t *testing.T) {
+	r, events := powerRobot(t, rosbridge.TaskAccepted, "")
+	if _, err := r.RequestReboot(); err != nil {
+		t.Fatal(err)
+	}
+	st := r.PowerStatus()
+	if st.State != PowerRebooting || st.Deadline == nil || st.Deadline.Sub(*st.Since).Round(time.Millisecond) != 150*time.Millisecond || !st.Expected() {
+		t.Fatalf("after the ack: %+v", st)
+	}
+	if ev := lastPower(events()); ev.Previous != PowerNormal || ev.State != PowerRebooting || ev.Alert {
+		t.Errorf("ack event %+v", ev)
+	}
+
+	// Going down is what a reboot does; coming back ends it
+	r.powerDisconnected()
+	if r.PowerStatus().State != PowerRebooting {
+		t.Error("the dropped connection ended the reboot")
+	}
+	r.powerConnected()
+	if ev := lastPower(events()); ev.State != PowerNormal || ev.Previous != PowerRebooting || !strings.Contains(ev.Message, "back after rebooting") {
+		t.Errorf("return event %+v", ev)
+	}
+	// The window's timer doesn't fire on the robot that came back
+	n := len(events())
+	time.Sleep(250 * time.Millisecond)
+	if len(events()) != n || r.PowerStatus().State != PowerNormal {
+		t.Errorf("window ended after the robot returned: %+v", lastPower(events()))
+	}
+}
+
+func TestPowerRebootNeverReturns(t *testing.T) {
+	r, events := powerRobot(t, rosbridge.TaskAccepted, "")
+	r.RequestReboot()
+	r.powerDisconnected()
+	waitUntil(t, "the window to end", func() bool { return r.PowerStatus().State == PowerUnreachable })
+	if ev := lastPower(events()); !ev.Alert || ev.Previous != PowerRebooting || ev.Deadline != nil || !strings.Contains(ev.Message, "did not come back") {
+		t.Errorf("window event %+v", ev)
+	}
+	if r.PowerStatus().Expected() {
+		t.Error("an unreachable robot is down as expected")
+	}
+	r.powerConnected()
+	if ev := lastPower(events()); ev.State != PowerNormal || ev.Previous != PowerUnreachable || ev.Alert {
+		t.Errorf("late return %+v", ev)
+	}
+}
+
+func TestPowerOff(t *testing.T) {
+	r, events := powerRobot(t, rosbridge.TaskAccepted, "")
+	if _, err := r.RequestPowerOff(); err != nil {
+		t.Fatal(err)
+	}
+	if st := r.PowerStatus(); st.State != PowerShuttingDown || st.Deadline == nil {
+		t.Fatalf("after the ack: %+v", st)
+	}
+	r.powerDisconnected()
+	st := r.PowerStatus()
+	if st.State != PowerOff || st.Deadline != nil || lastPower(events()).Previous != PowerShuttingDown {
+		t.Fatalf("after dropping: %+v", st)
+	}
+	// Off stays off past the window, until the robot is seen again
+	time.Sleep(250 * time.Millisecond)
+	if r.PowerStatus().State != PowerOff {
+		t.Errorf("window ended a powered off robot: %+v", r.PowerStatus())
+	}
+	r.powerConnected()
+	if ev := lastPower(events()); ev.State != PowerNormal || ev.Previous != PowerOff {
+		t.Errorf("back on: %+v", ev)
+	}
+}
+
+// TestPowerAckedButStayedUp acknowledges both and never drops.
+func TestPowerAckedButStayedUp(t *testing.T) {
+	for _, c := range []struct {
+		request func(*Robot) (*rosbridge.WhichTaskResponse, error)
+		message string
+	}{
+		{(*Robot).RequestPowerOff, "acknowledged the poweroff but is still on"},
+		{(*Robot).RequestReboot, "acknowledged the reboot but never went down"},
+	} {
+		r, events := powerRobot(t, rosbridge.TaskAccepted, "")
+		r.setConnected(true)
+		c.request(r)
+		waitUntil(t, "the window to end", func() bool { return lastPower(events()).Alert })
+		if ev := lastPower(events()); ev.State != PowerNormal || !strings.Contains(ev.Message, c.message) {
+			t.Errorf("window event %+v", ev)
+		}
+	}
+}
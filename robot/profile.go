@@ -0,0 +1,311 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Robot profiles
+//
+// A profile is everything this app knows about a robot that isn't live
+// sensor state: connection parameters, user settings, navigation points,
+// walls, the cached map list and floor assignments. Exporting and importing one recreates a
+// robot after its computer is swapped.
+
+// ProfileVersion is the schema version written by ExportProfile. Import
+// accepts documents up to this version.
+const ProfileVersion = 1
+
+// Profile is an exported robot.
+type Profile struct {
+	Version       int                         `json:"version"`
+	ExportedAt    time.Time                   `json:"exported_at"`
+	Connection    ProfileConnection           `json:"connection"`
+	Settings      ProfileSettings             `json:"settings"`
+	Waypoints     []rosbridge.NavigationPoint `json:"waypoints"`
+	ServicePoints []rosbridge.NavigationPoint `json:"service_points"`
+	PatrolPoints  []rosbridge.NavigationPoint `json:"patrol_points"`
+	PathPoints    []rosbridge.NavigationPoint `json:"path_points"`
+	WallObstacles []rosbridge.WallObstacle    `json:"wall_obstacles"`
+	MapList       []string                    `json:"map_list"`
+	CurrentMap    string                      `json:"current_map,omitempty"`
+
+	// Floors maps map names to floors; MapPoints holds the points and
+	// walls of maps other than CurrentMap (the top-level ones).
+	Floors    map[string]string    `json:"floors,omitempty"`
+	MapPoints map[string]MapPoints `json:"map_points,omitempty"`
+
+	// Markers are the robot's incident markers, oldest first; IDs are
+	// reassigned on import.
+	Markers []Marker `json:"markers,omitempty"`
+}
+
+// ProfileConnection is how the robot is reached.
+type ProfileConnection struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	IP        string `json:"ip"`
+	Port      int    `json:"port"`
+}
+
+// ProfileSettings are the robot's user and subscription settings.
+type ProfileSettings struct {
+	LinearVelRatio   float64        `json:"linear_vel_ratio"`
+	AngularVelRatio  float64        `json:"angular_vel_ratio"`
+	Radius           float64        `json:"radius"`
+	Footprint        Footprint      `json:"footprint,omitempty"`
+	ScanMask         ScanMask       `json:"scan_mask,omitempty"`
+	Home             *HomePose      `json:"home,omitempty"`
+	MaxLinearVel     float64        `json:"max_linear_vel"`
+	MaxAngularVel    float64        `json:"max_angular_vel"`
+	TopicThrottles   map[string]int `json:"topic_throttles"`
+	UseCBOR          bool           `json:"use_cbor"`
+	SplitConnections bool           `json:"split_connections"`
+	SharedConnection bool           `json:"shared_connection"`
+	Holonomic        bool           `json:"holonomic"`
+	RenderHints      MapRenderHints `json:"render_hints"`
+
+	// Reconnect is absent in profiles exported before it existed.
+	Reconnect *rosbridge.ReconnectPolicy `json:"reconnect,omitempty"`
+	// CmdVel likewise.
+	CmdVel *rosbridge.CmdVelOptions `json:"cmd_vel,omitempty"`
+	// Localization likewise.
+	Localization *LocalizationThresholds `json:"localization,omitempty"`
+	// OfflineQueue likewise.
+	OfflineQueue *OfflineQueueOptions `json:"offline_queue,omitempty"`
+	// OdomReset likewise.
+	OdomReset *OdomResetOptions `json:"odom_reset,omitempty"`
+
+	ExtraTopics ExtraTopics `json:"extra_topics,omitempty"`
+
+	EnforceGlobalUniqueNames bool `json:"enforce_global_unique_names"`
+}
+
+// ExportProfile returns the robot's profile.
+func (r *Robot) ExportProfile() Profile {
+	s := r.GetSnapshot()
+	return Profile{
+		Version:    ProfileVersion,
+		ExportedAt: time.Now().UTC(),
+		Connection: ProfileConnection{Namespace: s.Namespace, Name: s.Name, IP: s.IP, Port: s.Port},
+		Settings: ProfileSettings{
+			LinearVelRatio:   s.LinearVelRatio,
+			AngularVelRatio:  s.AngularVelRatio,
+			Radius:           s.Radius,
+			Footprint:        s.Footprint,
+			ScanMask:         s.ScanMask,
+			Home:             s.Home,
+			MaxLinearVel:     s.MaxLinearVel,
+			MaxAngularVel:    s.MaxAngularVel,
+			TopicThrottles:   s.TopicThrottles,
+			UseCBOR:          s.UseCBOR,
+			SplitConnections: s.SplitConnections,
+			SharedConnection: s.SharedConnection,
+			Holonomic:        s.Holonomic,
+			RenderHints:      r.GetRenderHints(),
+			Reconnect:        &s.Reconnect,
+			CmdVel:           &s.CmdVel,
+			Localization:     &s.Localization.Thresholds,
+			OfflineQueue:     &s.OfflineQueue,
+			OdomReset:        &s.OdomReset,
+			ExtraTopics:      s.ExtraTopics,
+
+			EnforceGlobalUniqueNames: s.GlobalUniqueNames,
+		},
+		Waypoints:     nonNilPoints(s.Waypoints),
+		ServicePoints: nonNilPoints(s.ServicePoints),
+		PatrolPoints:  nonNilPoints(s.PatrolPoints),
+		PathPoints:    nonNilPoints(s.PathPoints),
+		WallObstacles: append([]rosbridge.WallObstacle{}, s.WallObstacles...),
+		MapList:       append([]string{}, s.MapList...),
+		CurrentMap:    s.CurrentMap,
+		Floors:        r.floorsCopy(),
+		MapPoints:     r.mapPointsCopy(),
+		Markers:       r.Markers(0, 0),
+	}
+}
+
+func nonNilPoints(pts []rosbridge.NavigationPoint) []rosbridge.NavigationPoint {
+	return append([]rosbridge.NavigationPoint{}, pts...)
+}
+
+// ParseProfile decodes and checks a profile document. The returned notes
+// list fields the document carries that this version does not know.
+func ParseProfile(data []byte) (Profile, []string, error) {
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Profile{}, nil, fmt.Errorf("invalid profile: %w", err)
+	}
+	switch {
+	case p.Version == 0:
+		return Profile{}, nil, fmt.Errorf("profile version missing")
+	case p.Version > ProfileVersion:
+		return Profile{}, nil, fmt.Errorf("profile version %d is newer than supported version %d", p.Version, ProfileVersion)
+	}
+	if p.Connection.IP == "" {
+		return Profile{}, nil, fmt.Errorf("connection.ip required")
+	}
+	if p.Connection.Port == 0 {
+		p.Connection.Port = 9090
+	}
+	return p, unknownFields("", data, reflect.TypeOf(p)), nil
+}
+
+// unknownFields lists JSON object keys in raw that t does not declare,
+// descending into nested structs.
+func unknownFields(prefix string, raw json.RawMessage, t reflect.Type) []string {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) != nil {
+		return nil
+	}
+	known := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known[name] = f.Type
+		}
+	}
+
+	var out []string
+	for k, v := range obj {
+		ft, ok := known[k]
+		switch {
+		case !ok:
+			out = append(out, prefix+k+": unknown field")
+		case ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}):
+			out = append(out, unknownFields(prefix+k+".", v, ft)...)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ApplyProfile sets the robot's settings, points, walls and map list from
+// p. Values that fail validation are left unchanged and reported.
+// Connection parameters are not touched; the caller picks the robot.
+func (r *Robot) ApplyProfile(p Profile) []string {
+	var skipped []string
+	ps := p.Settings
+
+	bounds := r.VelRatioBounds()
+	cur := r.GetSettings()
+	linear, angular := cur.LinearVelRatio, cur.AngularVelRatio
+	if err := bounds.check(RatioLinear, ps.LinearVelRatio); err != nil {
+		skipped = append(skipped, "settings.linear_vel_ratio: "+err.Error())
+	} else {
+		linear = ps.LinearVelRatio
+	}
+	if err := bounds.check(RatioAngular, ps.AngularVelRatio); err != nil {
+		skipped = append(skipped, "settings.angular_vel_ratio: "+err.Error())
+	} else {
+		angular = ps.AngularVelRatio
+	}
+	r.applyVelRatios(linear, angular)
+	r.SetHolonomic(ps.Holonomic)
+	if ps.Radius > 0 {
+		r.SetRadius(ps.Radius)
+	} else {
+		skipped = append(skipped, fmt.Sprintf("settings.radius: %v is not positive", ps.Radius))
+	}
+	if ps.Footprint != nil {
+		if err := r.SetFootprint(ps.Footprint); err != nil {
+			skipped = append(skipped, "settings.footprint: "+err.Error())
+		}
+	}
+	if ps.ScanMask != nil {
+		if err := r.SetScanMask(ps.ScanMask); err != nil {
+			skipped = append(skipped, "settings.scan_mask: "+err.Error())
+		}
+	}
+	if ps.Home != nil {
+		if err := r.SetHome(ps.Home); err != nil {
+			skipped = append(skipped, "settings.home: "+err.Error())
+		}
+	}
+	if ps.MaxLinearVel >= 0 && ps.MaxAngularVel >= 0 {
+		r.SetMaxVelocities(ps.MaxLinearVel, ps.MaxAngularVel)
+	} else {
+		skipped = append(skipped, "settings.max_linear_vel/max_angular_vel: must not be negative")
+	}
+
+	throttles := map[string]int{}
+	for k, v := range ps.TopicThrottles {
+		if !isTopicKey(k) {
+			skipped = append(skipped, fmt.Sprintf("settings.topic_throttles.%s: unknown topic", k))
+			continue
+		}
+		throttles[k] = v
+	}
+	cbor := ps.UseCBOR
+	r.SetSubscriptionSettings(throttles, &cbor)
+	r.SetSplitConnections(ps.SplitConnections)
+	r.SetSharedConnection(ps.SharedConnection)
+	if ps.Reconnect != nil {
+		if err := r.SetReconnectPolicy(*ps.Reconnect); err != nil {
+			skipped = append(skipped, "settings.reconnect: "+err.Error())
+		}
+	}
+	if ps.CmdVel != nil {
+		if err := r.SetCmdVelOptions(*ps.CmdVel); err != nil {
+			skipped = append(skipped, "settings.cmd_vel: "+err.Error())
+		}
+	}
+	if ps.Localization != nil {
+		if err := r.SetLocalizationThresholds(*ps.Localization); err != nil {
+			skipped = append(skipped, "settings.localization: "+err.Error())
+		}
+	}
+	if ps.OfflineQueue != nil {
+		if err := r.SetOfflineQueue(*ps.OfflineQueue); err != nil {
+			skipped = append(skipped, "settings.offline_queue: "+err.Error())
+		}
+	}
+	if ps.OdomReset != nil {
+		if err := r.SetOdomReset(*ps.OdomReset); err != nil {
+			skipped = append(skipped, "settings.odom_reset: "+err.Error())
+		}
+	}
+	if err := r.SetExtraTopics(ps.ExtraTopics); err != nil {
+		skipped = append(skipped, "settings.extra_topics: "+err.Error())
+	}
+	if err := r.SetRenderHints(ps.RenderHints); err != nil {
+		skipped = append(skipped, "settings.render_hints: "+err.Error())
+	}
+
+	// Replaced wholesale, so the edit history starts over.
+	r.mu.Lock()
+	r.setLivePointsLocked(MapPoints{
+		Waypoints:     p.Waypoints,
+		ServicePoints: p.ServicePoints,
+		PatrolPoints:  p.PatrolPoints,
+		PathPoints:    p.PathPoints,
+		WallObstacles: p.WallObstacles,
+	})
+	r.mu.Unlock()
+	r.SetMapList(append([]string{}, p.MapList...))
+	if p.CurrentMap != "" {
+		r.SetCurrentMap(p.CurrentMap)
+	}
+	r.setFloorsAndMapPoints(p.Floors, p.MapPoints)
+	r.setMarkers(p.Markers)
+	if conflicts, err := r.SetGlobalUniqueNames(ps.EnforceGlobalUniqueNames); err != nil {
+		skipped = append(skipped, fmt.Sprintf("settings.enforce_global_unique_names: %d names shared across point types", len(conflicts)))
+	}
+	return skipped
+}
+
+func isTopicKey(k string) bool {
+	for _, key := range rosbridge.TopicKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package robot
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// testProfile sets every field of a profile to a valid value other than
+// its default.
+func testProfile() Profile {
+	pt := func(name string, x float64) rosbridge.NavigationPoint {
+		return rosbridge.NavigationPoint{
+			Name: name, ImageXPx: 10 * x, ImageYPx: 20 * x, ImageThetaDeg: 45,
+			WorldXM: x, WorldYM: -x, WorldThetaRad: 0.785,
+		}
+	}
+	approach := pt("dock", 4)
+	approach.MaxSpeedMPS, approach.DwellSec, approach.YawToleranceRad, approach.OnArrivalTask = 0.3, 5, 0.1, "charge"
+	wall := rosbridge.WallObstacle{ImageXPxStart: 1, ImageYPxStart: 2, ImageXPxEnd: 3, ImageYPxEnd: 4,
+		WorldXMStart: 0.1, WorldYMStart: 0.2, WorldXMEnd: 0.3, WorldYMEnd: 0.4}
+
+	return Profile{
+		Version:    ProfileVersion,
+		Connection: ProfileConnection{Namespace: "src", Name: "Source", IP: "10.0.0.5", Port: 9091},
+		Settings: ProfileSettings{
+			LinearVelRatio:   0.6,
+			AngularVelRatio:  0.4,
+			Radius:           0.42,
+			Footprint:        Footprint{{0.3, 0.2}, {0.3, -0.2}, {-0.3, -0.2}, {-0.3, 0.2}},
+			ScanMask:         ScanMask{{-0.5, 0.5}, {2.5, 3}},
+			Home:             &HomePose{Pose2D: rosbridge.Pose2D{X: 1.5, Y: -2, Theta: 0.3}, Map: "floor1"},
+			MaxLinearVel:     0.8,
+			MaxAngularVel:    1.2,
+			TopicThrottles:   map[string]int{"map": 2000, "laser": 100},
+			UseCBOR:          true,
+			SplitConnections: true,
+			Holonomic:        true,
+			RenderHints:      MapRenderHints{OccupiedThreshold: 70, FreeThreshold: 20, Invert: true, Palette: "high_contrast"},
+			Reconnect:        &rosbridge.ReconnectPolicy{Enabled: true, InitialDelayMs: 1000, MaxDelayMs: 20000, MaxAttempts: 5},
+			CmdVel:           &rosbridge.CmdVelOptions{RateHz: 10, KeepAlive: true},
+			Localization:     &LocalizationThresholds{FairXYM: 0.3, PoorXYM: 0.6, FairYawRad: 0.25, PoorYawRad: 0.5, Hysteresis: 0.1},
+			OfflineQueue:     &OfflineQueueOptions{Enabled: true, MaxEntries: 8, TTLSec: 120},
+			OdomReset:        &OdomResetOptions{Method: OdomResetService, Task: "reset_odometry", Service: "/odom/reset", Topic: rosbridge.DefaultInitialPoseTopic},
+			ExtraTopics:      ExtraTopics{{Topic: "/battery_state", Type: "sensor_msgs/msg/BatteryState", ThrottleMs: 500, Alias: "battery"}},
+
+			EnforceGlobalUniqueNames: true,
+		},
+		Waypoints:     []rosbridge.NavigationPoint{pt("lobby", 1), pt("kitchen", 2)},
+		ServicePoints: []rosbridge.NavigationPoint{approach},
+		PatrolPoints:  []rosbridge.NavigationPoint{pt("p1", 5), pt("p2", 6)},
+		PathPoints:    []rosbridge.NavigationPoint{pt("via", 7)},
+		WallObstacles: []rosbridge.WallObstacle{wall},
+		MapList:       []string{"floor1", "floor2"},
+		CurrentMap:    "floor1",
+		Floors:        map[string]string{"floor1": "1", "floor2": "2"},
+		MapPoints: map[string]MapPoints{
+			"floor2": {Waypoints: []rosbridge.NavigationPoint{pt("upstairs", 8)}, WallObstacles: []rosbridge.WallObstacle{wall}},
+		},
+		Markers: []Marker{
+			{ID: 7, Time: 1700000000000, Label: "bump", Source: "operator", Client: "10.0.0.9"},
+			{ID: 9, Time: 1700000005000, Label: "estop", Source: "robot"},
+		},
+	}
+}
+
+// comparableSnapshot drops what a profile does not carry: identity,
+// connection and live state.
+func comparableSnapshot(r *Robot) Snapshot {
+	s := r.GetSnapshot()
+	s.ID, s.Namespace, s.Name, s.IP, s.Port = "", "", "", "", 0
+	s.IdleSince = nil
+	s.Freshness = Freshness{}
+	s.Localization.Since, s.Localization.UpdatedAt = nil, nil
+	s.ConnectPhase.Since = time.Time{}
+	return s
+}
+
+func TestProfileRoundTrip(t *testing.T) {
+	src := NewRobot("1", "src", "Source", "10.0.0.5", 9091)
+	defer src.Close()
+	if skipped := src.ApplyProfile(testProfile()); len(skipped) != 0 {
+		t.Fatalf("test profile not fully applied: %v", skipped)
+	}
+
+	exported := src.ExportProfile()
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, notes, err := ParseProfile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("own export has unknown fields: %v", notes)
+	}
+
+	dst := NewRobot("2", "dst", "Target", "10.0.0.6", 9090)
+	defer dst.Close()
+	if skipped := dst.ApplyProfile(parsed); len(skipped) != 0 {
+		t.Fatalf("import skipped: %v", skipped)
+	}
+
+	if got, want := comparableSnapshot(dst), comparableSnapshot(src); !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", " ")
+		wantJSON, _ := json.MarshalIndent(want, "", " ")
+		t.Errorf("snapshot after round trip differs\n got: %s\nwant: %s", gotJSON, wantJSON)
+	}
+
+	// A second export is the same document, bar the time and connection
+	again := dst.ExportProfile()
+	again.ExportedAt, again.Connection = exported.ExportedAt, exported.Connection
+	if !reflect.DeepEqual(again, exported) {
+		t.Errorf("re-export differs:\n got: %+v\nwant: %+v", again, exported)
+	}
+
+	// The round trip kept what was put in; throttles not in the profile
+	// keep their defaults
+	in := testProfile()
+	for k, v := range in.Settings.TopicThrottles {
+		if exported.Settings.TopicThrottles[k] != v {
+			t.Errorf("throttle %s = %d, want %d", k, exported.Settings.TopicThrottles[k], v)
+		}
+	}
+	in.Settings.TopicThrottles = exported.Settings.TopicThrottles
+	if !reflect.DeepEqual(exported.Settings, in.Settings) {
+		t.Errorf("settings = %+v\nwant %+v", exported.Settings, in.Settings)
+	}
+	if !reflect.DeepEqual(exported.MapPoints, in.MapPoints) || !reflect.DeepEqual(exported.Floors, in.Floors) {
+		t.Errorf("floors = %v, map points = %+v", exported.Floors, exported.MapPoints)
+	}
+	if len(exported.Markers) != 2 || exported.Markers[0].Label != "bump" || exported.Markers[1].Time != in.Markers[1].Time {
+		t.Errorf("markers = %+v", exported.Markers)
+	}
+}
+
+func TestParseProfileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"not JSON", `{`},
+		{"no version", `{"connection":{"ip":"10.0.0.1"}}`},
+		{"newer version", `{"version":99,"connection":{"ip":"10.0.0.1"}}`},
+		{"no ip", `{"version":1,"connection":{"name":"x"}}`},
+	}
+	for _, tt := range tests {
+		if _, _, err := ParseProfile([]byte(tt.doc)); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+
+	p, notes, err := ParseProfile([]byte(`{"version":1,"connection":{"ip":"10.0.0.1","colour":"red"},"settings":{"radius":0.3,"turbo":true},"annotations":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Connection.Port != 9090 {
+		t.Errorf("default port = %d", p.Connection.Port)
+	}
+	want := []string{"annotations: unknown field", "connection.colour: unknown field", "settings.turbo: unknown field"}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("notes = %v, want %v", notes, want)
+	}
+}
+
+func TestApplyProfileReportsInvalidFields(t *testing.T) {
+	r := NewRobot("1", "", "test", "127.0.0.1", 9090)
+	defer r.Close()
+	before := r.GetSettings()
+
+	p := testProfile()
+	p.Settings.Radius = 0
+	p.Settings.MaxLinearVel = -1
+	p.Settings.TopicThrottles = map[string]int{"bogus": 10}
+	p.Settings.RenderHints = MapRenderHints{OccupiedThreshold: 10, FreeThreshold: 50}
+	skipped := r.ApplyProfile(p)
+
+	want := []string{"settings.radius", "settings.max_linear_vel/max_angular_vel", "settings.topic_throttles.bogus", "settings.render_hints"}
+	for _, prefix := range want {
+		found := false
+		for _, s := range skipped {
+			if len(s) >= len(prefix) && s[:len(prefix)] == prefix {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no skip note for %s in %v", prefix, skipped)
+		}
+	}
+	after := r.GetSettings()
+	if after.Radius != before.Radius || after.MaxLinearVel != before.MaxLinearVel {
+		t.Errorf("invalid values applied: radius %v, max linear %v", after.Radius, after.MaxLinearVel)
+	}
+}
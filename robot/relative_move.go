@@ -0,0 +1,414 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Relative moves
+//
+// "Nudge" commands for docking alignment: rotate by an angle, then drive a
+// distance along the resulting heading, closed-loop on odometry. One move
+// runs per robot; a new move, joystick input, stop or e-stop cancels it.
+
+// Relative move errors.
+var (
+	ErrEStopped     = errors.New("e-stop engaged")
+	ErrNoOdom       = errors.New("no recent odometry")
+	ErrNotConnected = errors.New("robot not connected")
+)
+
+// Move states reported in MoveProgress.
+const (
+	MoveRunning   = "running"
+	MoveDone      = "done"
+	MoveCancelled = "cancelled"
+	MoveAborted   = "aborted"
+)
+
+const (
+	moveTick           = 50 * time.Millisecond
+	moveProgressEvery  = 4 // broadcast every Nth tick (~5 Hz)
+	moveStallTimeout   = 2 * time.Second
+	moveStallEpsilon   = 0.002 // m or rad of error reduction that counts as progress
+	moveOdomMaxAge     = time.Second
+	moveDistTolerance  = 0.01                // m
+	moveAngleTolerance = 1.0 * math.Pi / 180 // rad
+	moveDefaultSpeed   = 0.2                 // m/s
+	moveMaxAngular     = 0.6                 // rad/s
+	moveMinLinear      = 0.03                // m/s, overcomes drive deadband
+	moveMinAngular     = 0.08                // rad/s
+	moveMaxDistance    = 3.0                 // m
+	moveKpLinear       = 1.5
+	moveKpAngular      = 2.0
+)
+
+// RelativeMoveRequest is a nudge command. Positive angles turn left
+// (counter-clockwise), negative distances drive backwards.
+type RelativeMoveRequest struct {
+	DistanceM float64 `json:"distance_m"`
+	AngleDeg  float64 `json:"angle_deg"`
+	MaxSpeed  float64 `json:"max_speed"` // m/s, 0 = default
+}
+
+// Validate checks the request bounds.
+func (q RelativeMoveRequest) Validate() error {
+	switch {
+	case q.DistanceM == 0 && q.AngleDeg == 0:
+		return fmt.Errorf("distance_m or angle_deg required")
+	case math.Abs(q.DistanceM) > moveMaxDistance:
+		return fmt.Errorf("distance_m must be within ±%.1f m", moveMaxDistance)
+	case math.Abs(q.AngleDeg) > 360:
+		return fmt.Errorf("angle_deg must be within ±360")
+	case q.MaxSpeed < 0:
+		return fmt.Errorf("max_speed must be positive")
+	}
+	return nil
+}
+
+// MoveProgress is broadcast while a relative move runs.
+type MoveProgress struct {
+	ID       string  `json:"id"`
+	State    string  `json:"state"`
+	Phase    string  `json:"phase"` // "rotate" or "drive"
+	Progress float64 `json:"progress"`
+	Reason   string  `json:"reason,omitempty"`
+}
+
+// activeMove is the running move of a robot.
+type activeMove struct {
+	id     string
+	cancel context.CancelFunc
+	done   chan struct{} // closed once a relocalization rotation stopped; nil otherwise
+}
+
+var moveSeq atomic.Uint64
+
+// stopped waits, up to a second, for a relocalization rotation's loop to
+// exit, so a tick in flight can't turn the robot again after its cancel.
+// Relative moves don't wait.
+func (m *activeMove) stopped() {
+	if m.done == nil {
+		return
+	}
+	select {
+	case <-m.done:
+	case <-time.After(time.Second):
+	}
+}
+
+// moveController is the pure closed-loop controller: rotate in place to
+// the target angle, then drive the target distance along the heading held
+// at the start of the drive phase.
+type moveController struct {
+	distance, angle float64 // targets (m, rad)
+	maxLin, maxAng  float64
+
+	phase    string
+	lastYaw  float64
+	rotated  float64 // unwrapped rotation so far
+	driveX   float64
+	driveY   float64
+	driveYaw float64
+	traveled float64
+}
+
+func newMoveController(start rosbridge.OdomData, distance, angle, maxLin, maxAng float64) *moveController {
+	c := &moveController{
+		distance: distance,
+		angle:    angle,
+		maxLin:   maxLin,
+		maxAng:   maxAng,
+		phase:    "rotate",
+		lastYaw:  start.Yaw,
+	}
+	if math.Abs(angle) <= moveAngleTolerance {
+		c.startDrive(start)
+	}
+	return c
+}
+
+func (c *moveController) startDrive(pose rosbridge.OdomData) {
+	c.phase = "drive"
+	c.driveX, c.driveY, c.driveYaw = pose.PosX, pose.PosY, pose.Yaw
+}
+
+// remaining is the absolute error of the current phase plus the whole
+// drive distance while still rotating.
+func (c *moveController) remaining() float64 {
+	if c.phase == "rotate" {
+		return math.Abs(c.angle-c.rotated) + math.Abs(c.distance)
+	}
+	return math.Abs(c.distance - c.traveled)
+}
+
+// progress is the completed fraction, each non-empty phase weighted
+// equally.
+func (c *moveController) progress() float64 {
+	var parts, done float64
+	if c.angle != 0 {
+		parts++
+		done += math.Min(1, math.Max(0, c.rotated/c.angle))
+	}
+	if c.distance != 0 {
+		parts++
+		if c.phase == "drive" {
+			done += math.Min(1, math.Max(0, c.traveled/c.distance))
+		}
+	}
+	if parts == 0 {
+		return 1
+	}
+	return done / parts
+}
+
+// step updates the controller with the latest pose and returns the
+// velocity command, or done once both phases are within tolerance.
+func (c *moveController) step(pose rosbridge.OdomData) (lin, ang float64, done bool) {
+	c.rotated += normalizeAngle(pose.Yaw - c.lastYaw)
+	c.lastYaw = pose.Yaw
+
+	if c.phase == "rotate" {
+		err := c.angle - c.rotated
+		if math.Abs(err) > moveAngleTolerance {
+			return 0, limitCommand(moveKpAngular*err, moveMinAngular, c.maxAng), false
+		}
+		c.startDrive(pose)
+	}
+
+	dx, dy := pose.PosX-c.driveX, pose.PosY-c.driveY
+	c.traveled = dx*math.Cos(c.driveYaw) + dy*math.Sin(c.driveYaw)
+	err := c.distance - c.traveled
+	if math.Abs(err) <= moveDistTolerance {
+		return 0, 0, true
+	}
+	heading := normalizeAngle(c.driveYaw - pose.Yaw)
+	return limitCommand(moveKpLinear*err, moveMinLinear, c.maxLin), clamp(moveKpAngular*heading, c.maxAng), false
+}
+
+// stallDetector aborts a move whose remaining error stops shrinking.
+type stallDetector struct {
+	best     float64
+	lastGain time.Time
+}
+
+func newStallDetector(remaining float64, now time.Time) stallDetector {
+	return stallDetector{best: remaining, lastGain: now}
+}
+
+// stalled records the remaining error at now and reports whether it
+// hasn't dropped by moveStallEpsilon for moveStallTimeout.
+func (s *stallDetector) stalled(remaining float64, now time.Time) bool {
+	if remaining < s.best-moveStallEpsilon {
+		s.best, s.lastGain = remaining, now
+		return false
+	}
+	return now.Sub(s.lastGain) > moveStallTimeout
+}
+
+// limitCommand clamps v to ±max and lifts it to at least ±min.
+func limitCommand(v, min, max float64) float64 {
+	v = clamp(v, max)
+	if math.Abs(v) < min {
+		return math.Copysign(min, v)
+	}
+	return v
+}
+
+func normalizeAngle(a float64) float64 {
+	for a > math.Pi {
+		a -= 2 * math.Pi
+	}
+	for a < -math.Pi {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
+// MoveRelative starts a relative move and returns its ID. Any active move
+// is cancelled first.
+func (r *Robot) MoveRelative(q RelativeMoveRequest) (string, error) {
+	if err := q.Validate(); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	switch {
+	case r.estop:
+		r.mu.Unlock()
+		return "", ErrEStopped
+	case !r.connected:
+		r.mu.Unlock()
+		return "", ErrNotConnected
+	case r.lastOdomTime.IsZero() || time.Since(r.lastOdomTime) > moveOdomMaxAge:
+		r.mu.Unlock()
+		return "", ErrNoOdom
+	}
+	start := r.Odom
+
+	speed := q.MaxSpeed
+	if speed == 0 {
+		speed = moveDefaultSpeed
+	}
+	if r.maxLinearVel > 0 {
+		speed = math.Min(speed, r.maxLinearVel)
+	}
+	angSpeed := moveMaxAngular
+	if r.maxAngularVel > 0 {
+		angSpeed = math.Min(angSpeed, r.maxAngularVel)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &activeMove{id: fmt.Sprintf("move-%d", moveSeq.Add(1)), cancel: cancel}
+	prev := r.move
+	r.move = m
+	r.mu.Unlock()
+
+	if prev != nil {
+		prev.cancel()
+	}
+
+	angle := q.AngleDeg * math.Pi / 180
+	ctrl := newMoveController(start, q.DistanceM, angle, speed, angSpeed)
+
+	// Generous timeout: three times the nominal duration plus slack.
+	nominal := math.Abs(q.DistanceM)/speed + math.Abs(angle)/angSpeed
+	timeout := time.Duration(nominal*3*float64(time.Second)) + 5*time.Second
+
+	go r.runMove(ctx, m, ctrl, timeout)
+	return m.id, nil
+}
+
+// CancelMove stops the active move, relative or relocalization
+// rotation, if any.
+func (r *Robot) CancelMove() bool {
+	r.mu.Lock()
+	m := r.move
+	r.move = nil
+	r.mu.Unlock()
+	if m == nil {
+		return false
+	}
+	m.cancel()
+	m.stopped()
+	return true
+}
+
+// stopMove cancels the active move (relative or relocalization rotation)
+// and zeroes the velocity; reports whether one was running.
+func (r *Robot) stopMove() bool {
+	if !r.CancelMove() {
+		return false
+	}
+	r.commandVelocity(0, 0, 0)
+	return true
+}
+
+func (r *Robot) runMove(ctx context.Context, m *activeMove, ctrl *moveController, timeout time.Duration) {
+	ticker := time.NewTicker(moveTick)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(timeout)
+	stall := newStallDetector(ctrl.remaining(), time.Now())
+
+	finish := func(state, reason string) {
+		// A cancelling caller (joystick, new move, e-stop) owns the
+		// velocity from here on, so only stop the robot ourselves.
+		if state != MoveCancelled {
+			r.commandVelocity(0, 0, 0)
+		}
+		r.mu.Lock()
+		if r.move == m {
+			r.move = nil
+		}
+		r.mu.Unlock()
+		m.cancel()
+		progress := ctrl.progress()
+		if state == MoveDone {
+			progress = 1
+		}
+		r.emitMoveProgress(MoveProgress{ID: m.id, State: state, Phase: ctrl.phase, Progress: progress, Reason: reason})
+	}
+
+	r.emitMoveProgress(MoveProgress{ID: m.id, State: MoveRunning, Phase: ctrl.phase})
+	for tick := 1; ; tick++ {
+		select {
+		case <-ctx.Done():
+			finish(MoveCancelled, "cancelled")
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.RLock()
+		estop, connected := r.estop, r.connected
+		pose, odomAt := r.Odom, r.lastOdomTime
+		r.mu.RUnlock()
+
+		switch {
+		case estop:
+			finish(MoveAborted, "e-stop engaged")
+			return
+		case !connected:
+			finish(MoveAborted, "robot disconnected")
+			return
+		case time.Now().After(deadline):
+			finish(MoveAborted, "timeout")
+			return
+		case time.Since(odomAt) > moveOdomMaxAge:
+			finish(MoveAborted, "odometry stale")
+			return
+		}
+
+		lin, ang, done := ctrl.step(pose)
+		if done {
+			finish(MoveDone, "")
+			return
+		}
+
+		if stall.stalled(ctrl.remaining(), time.Now()) {
+			finish(MoveAborted, "stalled: no odometry progress")
+			return
+		}
+
+		r.commandVelocity(lin, 0, ang)
+		if tick%moveProgressEvery == 0 {
+			r.emitMoveProgress(MoveProgress{ID: m.id, State: MoveRunning, Phase: ctrl.phase, Progress: ctrl.progress()})
+		}
+	}
+}
+
+func (r *Robot) emitMoveProgress(p MoveProgress) {
+	if r.OnMoveProgress != nil {
+		r.OnMoveProgress(p)
+	}
+}
+
+// ──────────────────────────── E-stop
+
+// SetEStop engages or releases the software e-stop. While engaged all
+// velocity commands are forced to zero and relative moves are refused.
+func (r *Robot) SetEStop(engaged bool) {
+	r.mu.Lock()
+	if r.estop != engaged {
+		r.recordEventLocked(TimelineEStop, EStopChange{Engaged: engaged})
+	}
+	r.estop = engaged
+	r.mu.Unlock()
+	if engaged {
+		r.CancelMove()
+		r.Client.SetDesiredCmdVel(rosbridge.TwistData{})
+	}
+}
+
+// EStopped reports whether the e-stop is engaged.
+func (r *Robot) EStopped() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.estop
+}
@@ -0,0 +1,129 @@
+package robot
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// simOdom integrates velocity commands into a pose the way a diff-drive
+// base reports it, yaw wrapped to (-π, π]. slip scales the motion
+// actually achieved (1: none, 0: wheels spinning in place).
+type simOdom struct {
+	pose rosbridge.OdomData
+	slip float64
+}
+
+func (s *simOdom) apply(lin, ang float64, dt time.Duration) {
+	sec := dt.Seconds() * s.slip
+	s.pose.Yaw = normalizeAngle(s.pose.Yaw + ang*sec)
+	s.pose.PosX += lin * math.Cos(s.pose.Yaw) * sec
+	s.pose.PosY += lin * math.Sin(s.pose.Yaw) * sec
+}
+
+// runSim drives a controller from start until it is done, it stalls, or
+// maxTicks pass, returning the final pose, whether it finished, and
+// whether the stall detector aborted it.
+func runSim(t *testing.T, start rosbridge.OdomData, distance, angle, slip float64) (rosbridge.OdomData, bool, bool) {
+	t.Helper()
+	sim := &simOdom{pose: start, slip: slip}
+	ctrl := newMoveController(start, distance, angle, moveDefaultSpeed, moveMaxAngular)
+	now := time.Unix(0, 0)
+	stall := newStallDetector(ctrl.remaining(), now)
+	for tick := 0; tick < 2000; tick++ {
+		lin, ang, done := ctrl.step(sim.pose)
+		if done {
+			return sim.pose, true, false
+		}
+		now = now.Add(moveTick)
+		if stall.stalled(ctrl.remaining(), now) {
+			return sim.pose, false, true
+		}
+		if math.Abs(lin) > moveDefaultSpeed+1e-9 || math.Abs(ang) > moveMaxAngular+1e-9 {
+			t.Fatalf("command %v, %v exceeds the limits", lin, ang)
+		}
+		sim.apply(lin, ang, moveTick)
+	}
+	t.Fatalf("move didn't finish in 2000 ticks, pose %+v", sim.pose)
+	return sim.pose, false, false
+}
+
+func TestMoveControllerSim(t *testing.T) {
+	deg := math.Pi / 180
+	tests := []struct {
+		name            string
+		startYaw        float64
+		distance, angle float64
+	}{
+		{"forward", 0, 1, 0},
+		{"backward", 0.5, -0.6, 0},
+		{"turn left", 0, 0, 90 * deg},
+		{"turn right", 0, 0, -45 * deg},
+		{"left past π", 170 * deg, 0, 30 * deg},
+		{"right past -π", -170 * deg, 0, -30 * deg},
+		{"full turn", 0, 0, 360 * deg},
+		{"turn then drive", 3, 0.5, 90 * deg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := rosbridge.OdomData{PosX: 2, PosY: -1, Yaw: tt.startYaw}
+			end, done, stalled := runSim(t, start, tt.distance, tt.angle, 1)
+			if !done || stalled {
+				t.Fatalf("done %v stalled %v", done, stalled)
+			}
+
+			wantYaw := normalizeAngle(tt.startYaw + tt.angle)
+			if e := math.Abs(normalizeAngle(end.Yaw - wantYaw)); e > moveAngleTolerance+0.02 {
+				t.Errorf("yaw %.4f, want %.4f (error %.4f rad)", end.Yaw, wantYaw, e)
+			}
+			wantX := start.PosX + tt.distance*math.Cos(wantYaw)
+			wantY := start.PosY + tt.distance*math.Sin(wantYaw)
+			if e := math.Hypot(end.PosX-wantX, end.PosY-wantY); e > moveDistTolerance+0.01 {
+				t.Errorf("position (%.3f, %.3f), want (%.3f, %.3f)", end.PosX, end.PosY, wantX, wantY)
+			}
+		})
+	}
+}
+
+func TestMoveControllerStall(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		distance, angle float64
+	}{
+		{"drive", 0.5, 0},
+		{"rotate", 0, math.Pi / 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, done, stalled := runSim(t, rosbridge.OdomData{}, tt.distance, tt.angle, 0)
+			if done || !stalled {
+				t.Errorf("done %v stalled %v, want a stall abort", done, stalled)
+			}
+		})
+	}
+}
+
+func TestMoveControllerTolerance(t *testing.T) {
+	start := rosbridge.OdomData{}
+	ctrl := newMoveController(start, 0.5, 0, moveDefaultSpeed, moveMaxAngular)
+	if ctrl.phase != "drive" {
+		t.Fatalf("phase %q, want drive without an angle", ctrl.phase)
+	}
+	if _, _, done := ctrl.step(rosbridge.OdomData{PosX: 0.5 - 2*moveDistTolerance}); done {
+		t.Error("done outside the distance tolerance")
+	}
+	lin, ang, done := ctrl.step(rosbridge.OdomData{PosX: 0.5 - moveDistTolerance/2})
+	if !done || lin != 0 || ang != 0 {
+		t.Errorf("within tolerance: %v, %v, done %v", lin, ang, done)
+	}
+
+	// Small errors still get the minimum command past the deadband
+	ctrl = newMoveController(start, 0, 10*moveAngleTolerance, moveDefaultSpeed, moveMaxAngular)
+	if _, ang, _ := ctrl.step(rosbridge.OdomData{Yaw: 8 * moveAngleTolerance}); ang != moveMinAngular {
+		t.Errorf("angular command %v, want the minimum %v", ang, moveMinAngular)
+	}
+	if p := ctrl.progress(); math.Abs(p-0.8) > 1e-9 {
+		t.Errorf("progress %v, want 0.8", p)
+	}
+}
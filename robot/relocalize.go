@@ -0,0 +1,209 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ──────────────────────────── Global relocalization
+//
+// A robot that lost track of where it is can ask localization to start
+// over: the global localization service scatters its estimate over the
+// whole map. A slow in-place rotation afterwards shows the laser more of
+// the surroundings, so the estimate converges faster. The rotation takes
+// the relative move slot, so a joystick, a new move, a stop or the e-stop
+// cancels it like any nudge, and it aborts once the e-stop is engaged or
+// the robot disconnects. Each step is reported through OnRelocalize with
+// the localization quality at that moment.
+
+// ErrMappingMode refuses relocalizing while a map is being built.
+var ErrMappingMode = errors.New("robot is mapping")
+
+// Relocalization states reported in RelocalizeEvent.
+const (
+	RelocalizeStarted   = "started"
+	RelocalizeRotating  = "rotating"
+	RelocalizeDone      = MoveDone
+	RelocalizeCancelled = MoveCancelled
+	RelocalizeAborted   = MoveAborted
+)
+
+// Relocalization defaults.
+const (
+	DefaultRelocalizeRotate  = 20 * time.Second
+	DefaultRelocalizeAngular = 0.3 // rad/s
+)
+
+// relocalizeMovePrefix marks the move slot as held by a rotation.
+const relocalizeMovePrefix = "reloc-"
+
+// RelocalizeOptions configure a relocalization.
+type RelocalizeOptions struct {
+	Service      string        // global localization service, without namespace
+	Rotate       time.Duration // in-place rotation afterwards; 0 skips it
+	AngularSpeed float64       // rad/s, DefaultRelocalizeAngular if <= 0
+}
+
+// RelocalizeEvent is a relocalization step.
+type RelocalizeEvent struct {
+	ID           string       `json:"id"`
+	State        string       `json:"state"`
+	Progress     float64      `json:"progress"` // rotation time elapsed, 0 to 1
+	ElapsedSec   float64      `json:"elapsed_s"`
+	Localization Localization `json:"localization"`
+	Reason       string       `json:"reason,omitempty"`
+}
+
+// inMappingLocked reports whether the robot is in a mapping mode or has a
+// mapping session running.
+func (r *Robot) inMappingLocked() bool {
+	if r.mode == ModeMapping || r.mode == ModeRemapping {
+		return true
+	}
+	s := r.mapping
+	return s != nil && (s.State == MappingActive || s.State == MappingSaving)
+}
+
+// Relocalize calls the global localization service and, with
+// opts.Rotate, turns the robot in place for that long. It returns the
+// relocalization ID once the service answered; the rotation goes on in
+// the background. It fails with ErrNotConnected, ErrMappingMode, and
+// when rotating with ErrEStopped or ErrNavActive.
+func (r *Robot) Relocalize(opts RelocalizeOptions) (string, error) {
+	r.mu.RLock()
+	connected, client := r.connected, r.Client
+	mapping := r.inMappingLocked()
+	estop, navActive := r.estop, r.navStatus.Active()
+	r.mu.RUnlock()
+
+	switch {
+	case !connected || client == nil:
+		return "", ErrNotConnected
+	case mapping:
+		return "", fmt.Errorf("%w: finish or abort the mapping session first", ErrMappingMode)
+	case opts.Rotate > 0 && estop:
+		return "", ErrEStopped
+	case opts.Rotate > 0 && navActive:
+		return "", fmt.Errorf("%w: stop it before rotating", ErrNavActive)
+	}
+
+	if err := client.GlobalRelocalize(opts.Service); err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("%s%d", relocalizeMovePrefix, moveSeq.Add(1))
+	log.Printf("[relocalize] %s: global localization reinitialized (%s)", r.Name, id)
+	r.emitRelocalize(RelocalizeEvent{ID: id, State: RelocalizeStarted})
+
+	if opts.Rotate <= 0 {
+		r.emitRelocalize(RelocalizeEvent{ID: id, State: RelocalizeDone, Progress: 1})
+		return id, nil
+	}
+	speed := opts.AngularSpeed
+	if speed <= 0 {
+		speed = DefaultRelocalizeAngular
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &activeMove{id: id, cancel: cancel, done: make(chan struct{})}
+	r.mu.Lock()
+	prev := r.move
+	r.move = m
+	r.mu.Unlock()
+	if prev != nil {
+		prev.cancel()
+	}
+
+	go r.runRelocalizeRotation(ctx, m, opts.Rotate, speed)
+	return id, nil
+}
+
+// CancelRelocalize stops a relocalization rotation, if one runs, and
+// zeroes the velocity. Other moves are left alone.
+func (r *Robot) CancelRelocalize() bool {
+	r.mu.Lock()
+	m := r.move
+	if m == nil || !strings.HasPrefix(m.id, relocalizeMovePrefix) {
+		r.mu.Unlock()
+		return false
+	}
+	r.move = nil
+	r.mu.Unlock()
+	m.cancel()
+	m.stopped()
+	r.commandVelocity(0, 0, 0)
+	return true
+}
+
+// runRelocalizeRotation turns the robot at speed for d; a minimal timed
+// rotation, since localization, not the angle reached, is what matters.
+func (r *Robot) runRelocalizeRotation(ctx context.Context, m *activeMove, d time.Duration, speed float64) {
+	defer close(m.done)
+	ticker := time.NewTicker(moveTick)
+	defer ticker.Stop()
+	start := time.Now()
+
+	progress := func() (float64, float64) {
+		el := time.Since(start)
+		return min(1, float64(el)/float64(d)), el.Seconds()
+	}
+	finish := func(state, reason string) {
+		// As with relative moves, a cancelling caller owns the velocity.
+		if state != RelocalizeCancelled {
+			r.commandVelocity(0, 0, 0)
+		}
+		r.mu.Lock()
+		if r.move == m {
+			r.move = nil
+		}
+		r.mu.Unlock()
+		m.cancel()
+		p, el := progress()
+		if state == RelocalizeDone {
+			p = 1
+		}
+		r.emitRelocalize(RelocalizeEvent{ID: m.id, State: state, Progress: p, ElapsedSec: el, Reason: reason})
+	}
+
+	r.emitRelocalize(RelocalizeEvent{ID: m.id, State: RelocalizeRotating})
+	for tick := 1; ; tick++ {
+		select {
+		case <-ctx.Done():
+			finish(RelocalizeCancelled, "cancelled")
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.RLock()
+		estop, connected := r.estop, r.connected
+		r.mu.RUnlock()
+		switch {
+		case estop:
+			finish(RelocalizeAborted, "e-stop engaged")
+			return
+		case !connected:
+			finish(RelocalizeAborted, "robot disconnected")
+			return
+		case time.Since(start) >= d:
+			finish(RelocalizeDone, "")
+			return
+		}
+
+		r.commandVelocity(0, 0, speed)
+		if tick%moveProgressEvery == 0 {
+			p, el := progress()
+			r.emitRelocalize(RelocalizeEvent{ID: m.id, State: RelocalizeRotating, Progress: p, ElapsedSec: el})
+		}
+	}
+}
+
+// emitRelocalize stamps e with the current localization and reports it.
+func (r *Robot) emitRelocalize(e RelocalizeEvent) {
+	e.Localization = r.GetLocalization()
+	if r.OnRelocalize != nil {
+		r.OnRelocalize(e)
+	}
+}
@@ -0,0 +1,211 @@
+package robot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// relocLog records a robot's relocalize events.
+type relocLog struct {
+	mu     sync.Mutex
+	events []RelocalizeEvent
+}
+
+func logRelocalize(r *Robot) *relocLog {
+	l := &relocLog{}
+	r.OnRelocalize = func(e RelocalizeEvent) {
+		l.mu.Lock()
+		l.events = append(l.events, e)
+		l.mu.Unlock()
+	}
+	return l
+}
+
+// last returns the latest event's state and reason.
+func (l *relocLog) last() (string, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) == 0 {
+		return "", ""
+	}
+	e := l.events[len(l.events)-1]
+	return e.State, e.Reason
+}
+
+func (l *relocLog) states() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for _, e := range l.events {
+		if n := len(out); n == 0 || out[n-1] != e.State {
+			out = append(out, e.State)
+		}
+	}
+	return out
+}
+
+// relocRobot returns a robot connected to a rosbridge stub.
+func relocRobot(t *testing.T) (*Robot, *rosbridgeStub) {
+	t.Helper()
+	stub := newRosbridgeStub(t)
+	host, port := stub.addr(t)
+	m := NewManager()
+	r, _ := m.AddRobot("", "reloc", host, port)
+	t.Cleanup(r.Close)
+	if err := r.Client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "connected", func() bool { return r.GetSnapshot().Connected })
+	return r, stub
+}
+
+// rotating starts a long rotation and waits until it turns the robot.
+func rotating(t *testing.T, r *Robot) {
+	t.Helper()
+	if _, err := r.Relocalize(RelocalizeOptions{Rotate: time.Minute, AngularSpeed: 0.4}); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the rotation", func() bool { return r.Client.DesiredCmdVel().AngularZ == 0.4 })
+}
+
+// stoppedAfter checks the rotation ended with state, left the move slot
+// empty and the robot still, also a few ticks later.
+func stoppedAfter(t *testing.T, r *Robot, l *relocLog, state string) {
+	t.Helper()
+	waitUntil(t, "the "+state+" event", func() bool { s, _ := l.last(); return s == state })
+	time.Sleep(3 * moveTick)
+	r.mu.RLock()
+	m := r.move
+	r.mu.RUnlock()
+	if m != nil {
+		t.Errorf("%s: move slot still held by %s", state, m.id)
+	}
+	if v := r.Client.DesiredCmdVel(); v.AngularZ != 0 {
+		t.Errorf("%s: still turning at %v", state, v.AngularZ)
+	}
+}
+
+func TestRelocalizeRefused(t *testing.T) {
+	r := NewRobot("1", "", "reloc", "127.0.0.1", 9)
+	defer r.Close()
+	if _, err := r.Relocalize(RelocalizeOptions{}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("offline: %v", err)
+	}
+
+	r, _ = relocRobot(t)
+	r.mu.Lock()
+	mode := r.mode
+	r.mode = ModeMapping
+	r.mu.Unlock()
+	if _, err := r.Relocalize(RelocalizeOptions{}); !errors.Is(err, ErrMappingMode) {
+		t.Errorf("mapping: %v", err)
+	}
+	r.mu.Lock()
+	r.mode = mode
+	r.mu.Unlock()
+
+	r.SetEStop(true)
+	if _, err := r.Relocalize(RelocalizeOptions{Rotate: time.Second}); !errors.Is(err, ErrEStopped) {
+		t.Errorf("e-stopped, rotating: %v", err)
+	}
+	if _, err := r.Relocalize(RelocalizeOptions{}); err != nil {
+		t.Errorf("e-stopped, no rotation: %v", err)
+	}
+}
+
+func TestRelocalizeRotation(t *testing.T) {
+	r, stub := relocRobot(t)
+	l := logRelocalize(r)
+
+	// Without a rotation: the service call alone
+	if _, err := r.Relocalize(RelocalizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	stub.mu.Lock()
+	for _, op := range stub.ops {
+		called = called || op.Op == "call_service" && op.Service == rosbridge.DefaultGlobalLocalizationService
+	}
+	stub.mu.Unlock()
+	if !called {
+		t.Errorf("%s not called", rosbridge.DefaultGlobalLocalizationService)
+	}
+	if got := l.states(); !sameEvents(got, RelocalizeStarted, RelocalizeDone) {
+		t.Errorf("no rotation: %v", got)
+	}
+
+	// A short rotation runs to the end
+	l.events = nil
+	if _, err := r.Relocalize(RelocalizeOptions{Rotate: 300 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the rotation", func() bool { return r.Client.DesiredCmdVel().AngularZ == DefaultRelocalizeAngular })
+	stoppedAfter(t, r, l, RelocalizeDone)
+	if got := l.states(); !sameEvents(got, RelocalizeStarted, RelocalizeRotating, RelocalizeDone) {
+		t.Errorf("rotation: %v", got)
+	}
+}
+
+// TestRelocalizeCancel stops a rotation every way it can be stopped; each
+// leaves the slot empty, the robot still and a cancelled event.
+func TestRelocalizeCancel(t *testing.T) {
+	r, _ := relocRobot(t)
+	l := logRelocalize(r)
+
+	for _, c := range []struct {
+		name   string
+		cancel func()
+	}{
+		{"cancel", func() {
+			if !r.CancelRelocalize() {
+				t.Error("nothing cancelled")
+			}
+		}},
+		{"move cancel", func() { r.CancelMove(); r.SetVelocity(0, 0, 0) }}, // as DELETE /api/robots/move_relative does
+		{"auto-stop", func() { r.stopMove() }},
+		{"e-stop", func() { r.SetEStop(true) }},
+	} {
+		r.SetEStop(false)
+		rotating(t, r)
+		c.cancel()
+		stoppedAfter(t, r, l, RelocalizeCancelled)
+	}
+
+	// The joystick takes over: its command, not the rotation, stays
+	r.SetEStop(false)
+	rotating(t, r)
+	r.SetVelocity(0.2, 0, 0)
+	stoppedAfter(t, r, l, RelocalizeCancelled)
+	if v := r.Client.DesiredCmdVel(); v.LinearX != 0.2 {
+		t.Errorf("joystick command replaced: %+v", v)
+	}
+
+	// Nothing to cancel, or a relative move: left alone
+	if r.CancelRelocalize() {
+		t.Error("cancelled with no rotation")
+	}
+	move := &activeMove{id: "move-1", cancel: func() {}}
+	r.mu.Lock()
+	r.move = move
+	r.mu.Unlock()
+	if r.CancelRelocalize() || r.move != move {
+		t.Error("cancelled a relative move")
+	}
+}
+
+func TestRelocalizeAbortOnDisconnect(t *testing.T) {
+	r, _ := relocRobot(t)
+	l := logRelocalize(r)
+	rotating(t, r)
+	r.mu.Lock()
+	r.connected = false
+	r.mu.Unlock()
+	stoppedAfter(t, r, l, RelocalizeAborted)
+	if _, reason := l.last(); reason != "robot disconnected" {
+		t.Errorf("reason %q", reason)
+	}
+}
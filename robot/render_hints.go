@@ -0,0 +1,174 @@
+package robot
+
+import (
+	"fmt"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Map render hints
+//
+// Occupancy thresholds are per robot because SLAM stacks differ in how
+// they fill the 0–100 range. They are applied server-side (PGM export,
+// cell classification) and shipped with every map frame so the canvas
+// classifies cells the same way. Invert and Palette only change how the
+// canvas colors the classes: an inverted map still exports, diffs and
+// validates its occupied cells as occupied.
+
+// MapRenderHints controls how occupancy values are classified and drawn.
+type MapRenderHints struct {
+	OccupiedThreshold int    `json:"occupied_threshold"` // value >= threshold is occupied
+	FreeThreshold     int    `json:"free_threshold"`     // value <= threshold is free
+	Invert            bool   `json:"invert"`             // display only: free and occupied colors swapped
+	Palette           string `json:"palette,omitempty"`  // display only
+}
+
+// Known palette names understood by the frontend.
+var MapPalettes = []string{"default", "grayscale", "high_contrast"}
+
+// DefaultRenderHints matches the map_server defaults (0.65 / 0.25).
+func DefaultRenderHints() MapRenderHints {
+	return MapRenderHints{
+		OccupiedThreshold: 65,
+		FreeThreshold:     25,
+		Palette:           "default",
+	}
+}
+
+// Validate checks the thresholds and palette name.
+func (h MapRenderHints) Validate() error {
+	if h.FreeThreshold < 0 || h.FreeThreshold > 100 {
+		return fmt.Errorf("free_threshold must be in [0, 100]")
+	}
+	if h.OccupiedThreshold < 0 || h.OccupiedThreshold > 100 {
+		return fmt.Errorf("occupied_threshold must be in [0, 100]")
+	}
+	if h.FreeThreshold >= h.OccupiedThreshold {
+		return fmt.Errorf("free_threshold must be below occupied_threshold")
+	}
+	if h.Palette != "" {
+		known := false
+		for _, p := range MapPalettes {
+			if p == h.Palette {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown palette %q", h.Palette)
+		}
+	}
+	return nil
+}
+
+// CellClass is the classification of a single occupancy value.
+type CellClass int
+
+const (
+	CellUnknown CellClass = iota
+	CellFree
+	CellOccupied
+)
+
+func (c CellClass) String() string {
+	switch c {
+	case CellFree:
+		return "free"
+	case CellOccupied:
+		return "occupied"
+	default:
+		return "unknown"
+	}
+}
+
+// Classify maps an occupancy value to free/occupied/unknown. Values
+// between the two thresholds are treated as unknown; Invert plays no
+// part.
+func (h MapRenderHints) Classify(v int8) CellClass {
+	if v < 0 {
+		return CellUnknown
+	}
+	switch val := int(v); {
+	case val >= h.OccupiedThreshold:
+		return CellOccupied
+	case val <= h.FreeThreshold:
+		return CellFree
+	default:
+		return CellUnknown
+	}
+}
+
+// PGM gray levels, matching map_server's map_saver output.
+const (
+	pgmFree     = 254
+	pgmOccupied = 0
+	pgmUnknown  = 205
+)
+
+// GrayValue returns the PGM gray level for an occupancy value.
+func (h MapRenderHints) GrayValue(v int8) uint8 {
+	return h.Classify(v).gray()
+}
+
+func (c CellClass) gray() uint8 {
+	switch c {
+	case CellFree:
+		return pgmFree
+	case CellOccupied:
+		return pgmOccupied
+	default:
+		return pgmUnknown
+	}
+}
+
+// EncodePGM renders a map as a binary (P5) PGM image, laid out as in
+// MapTransform: row 0 of the occupancy grid is the bottom of the image.
+func EncodePGM(m rosbridge.MapData, h MapRenderHints) []byte {
+	header := fmt.Sprintf("P5\n# resolution %.4f origin %.4f %.4f %.4f\n%d %d\n255\n",
+		m.Resolution, m.OriginX, m.OriginY, m.OriginYaw, m.Width, m.Height)
+	t := NewMapTransform(m)
+	out := make([]byte, len(header)+m.Width*m.Height)
+	img := out[copy(out, header):]
+	for row := 0; row < m.Height; row++ {
+		line := img[t.ImageRowOf(row)*m.Width:]
+		for col := 0; col < m.Width; col++ {
+			v, _ := CellValue(m, col, row)
+			line[col] = h.GrayValue(v)
+		}
+	}
+	return out
+}
+
+// MapFrame is the map payload sent to the browser: the grid plus the
+// robot's render hints, and the map_seq and checksum of its MapMeta.
+type MapFrame struct {
+	rosbridge.MapData
+	RenderHints MapRenderHints `json:"render_hints"`
+	MapSeq      uint64         `json:"map_seq"`
+	Checksum    string         `json:"checksum"`
+}
+
+// GetRenderHints returns the robot's map render hints.
+func (r *Robot) GetRenderHints() MapRenderHints {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.renderHints
+}
+
+// SetRenderHints validates and stores new render hints.
+func (r *Robot) SetRenderHints(h MapRenderHints) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.renderHints = h
+	r.mu.Unlock()
+	return nil
+}
+
+// GetMapFrame returns the current map together with its render hints.
+func (r *Robot) GetMapFrame() MapFrame {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mapFrameLocked(r.Map)
+}
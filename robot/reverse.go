@@ -0,0 +1,46 @@
+package robot
+
+import "net"
+
+// ──────────────────────────── Reverse registration
+//
+// Robots behind NAT run an agent that connects to the server's robot
+// gateway instead of being dialed (see rosbridge/reverse.go). The first
+// connection of an agent registers its robot, keyed by namespace; later
+// ones reattach to it. The robot then works like any other, except that
+// reconnecting is up to the agent.
+
+// Connection types reported in snapshots.
+const (
+	ConnectionDirect  = "direct"  // the server dials the robot's rosbridge
+	ConnectionShared  = "shared"  // dialed, on a connection shared with the server's other robots
+	ConnectionReverse = "reverse" // the robot's agent connects to the gateway
+)
+
+// ConnectionType returns how the server reaches the robot.
+func (r *Robot) ConnectionType() string {
+	switch {
+	case r.Client.ReverseEnabled():
+		return ConnectionReverse
+	case r.Client.SharedEnabled():
+		return ConnectionShared
+	}
+	return ConnectionDirect
+}
+
+// RegisterReverseRobot returns the reverse robot with namespace ns,
+// registering it, named name, when there is none; created reports which.
+// addr is the agent's address, recorded as the robot's IP.
+func (m *Manager) RegisterReverseRobot(ns, name, addr string) (r *Robot, created bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.robots {
+		if r.Client.ReverseEnabled() && SameNamespace(r.Namespace, ns) {
+			return r, false
+		}
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return m.addRobotLocked(ns, name, addr, 0, true), true
+}
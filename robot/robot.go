@@ -1,6 +1,8 @@
 package robot
 
 import (
+	"log"
+	"math"
 	"sync"
 	"time"
 
@@ -28,12 +30,70 @@ type Robot struct {
 	IP        string `json:"ip"`
 	Port      int    `json:"port"`
 
-	Radius    float64 `json:"radius"`
-	Connected bool    `json:"connected"`
+	// Guarded by mu; use the accessors (IsConnected, GetSettings, ...)
+	radius    float64
+	footprint Footprint // nil: radius only (see footprint.go)
+	scanMask  ScanMask  // nil: nothing masked (see scan_mask.go)
+	connected bool
 
 	// ROS bridge client
 	Client *rosbridge.Client `json:"-"`
 
+	// Sequential which_tasks queue
+	tasks *TaskQueue
+
+	// Task catalog (guarded by mu; see tasks.go)
+	taskDiscoveryRequest string
+	staticTasks          []rosbridge.TaskInfo
+	taskCatalog          []rosbridge.TaskInfo // nil until discovered
+	taskCatalogAt        time.Time
+	taskCatalogErr       string
+
+	// Reported software version and capabilities (guarded by mu; see
+	// capabilities.go)
+	capRequest string
+	caps       Capabilities
+
+	// Idle policy state (guarded by mu; see idle.go). idleMu orders the
+	// transitions so the client's subscriptions match idleSince.
+	idleMu    sync.Mutex
+	lastUsed  time.Time
+	idleSince time.Time // zero while active
+
+	// OnActivity receives active/idle transitions; set by the manager.
+	OnActivity func(ActivityState) `json:"-"`
+
+	// Localization quality and its grading (guarded by mu; see
+	// localization.go). locAMCL grades amcl_pose instead of odometry.
+	loc           Localization
+	locThresholds LocalizationThresholds
+	locAMCL       bool
+
+	// OnLocalization receives localization grade changes; set by the
+	// manager.
+	OnLocalization func(Localization) `json:"-"`
+
+	// Offline command queue (see offline_queue.go); offlineOpts is
+	// guarded by mu, the queue has its own locks.
+	offlineOpts OfflineQueueOptions
+	offline     offlineQueue
+
+	// OnPending receives the outcome of queued commands: run, failed,
+	// expired or cancelled; set by the manager.
+	OnPending func(PendingCommand) `json:"-"`
+
+	// Incident markers, oldest first (guarded by mu; see markers.go)
+	markers      []Marker
+	lastMarkerID int
+
+	// OnMarker receives every new marker; set by the manager.
+	OnMarker func(Marker) `json:"-"`
+
+	// Mode, navigation and e-stop changes, and 1 Hz poses, oldest first
+	// (guarded by mu; see timeline.go)
+	events []timelineEvent
+	poses  []PoseSample
+
 	// Latest sensor data
 	Map            rosbridge.MapData   `json:"-"`
 	MapReceived    bool                `json:"-"`
@@ -41,15 +101,33 @@ type Robot struct {
 	ControllerOdom rosbridge.OdomData  `json:"controller_odom"`
 	TF             rosbridge.TFData    `json:"tf"`
 	TFReceived     bool                `json:"-"`
-	Laser          rosbridge.LaserData `json:"-"`
+	Laser          rosbridge.LaserData `json:"-"` // after the scan mask
 	MapBfp         rosbridge.Pose2D    `json:"map_bfp"`
 
+	// Metadata and checksum of Map (see map_meta.go)
+	mapMeta MapMeta
+
+	// Latest scan before the scan mask, for diagnostics
+	rawLaser rosbridge.LaserData
+
 	// Velocity from subscribed cmd_vel
 	Velocity rosbridge.TwistData `json:"velocity"`
 
-	// Velocity history for graphs (last N samples)
-	VelocityHistory []rosbridge.TwistData `json:"-"`
-	MaxHistory      int                   `json:"-"`
+	// Velocity history for graphs, tiered (see velocity_history.go);
+	// MaxHistory caps the raw tier per series. Guarded by mu.
+	commanded    velocitySeries
+	measured     velocitySeries
+	velSummary   VelocitySummary
+	lastMeasured time.Time
+	lastSpeed    float64
+	MaxHistory   int `json:"-"`
+
+	// Latest velocity lag estimate and the last one reported (guarded by
+	// mu; see velocity_lag.go). OnVelocityLag receives significant
+	// changes; set by the manager.
+	velLag         *VelocityLag
+	velLagReported *VelocityLag
+	OnVelocityLag  func(VelocityLag) `json:"-"`
 
 	// Navigation points
 	Waypoints     []rosbridge.NavigationPoint `json:"waypoints"`
@@ -58,12 +136,193 @@ type Robot struct {
 	PathPoints    []rosbridge.NavigationPoint `json:"path_points"`
 	WallObstacles []rosbridge.WallObstacle    `json:"wall_obstacles"`
 
-	// Map list cache
-	MapList []string `json:"map_list"`
-
-	// User settings
-	LinearVelRatio  float64 `json:"linear_vel_ratio"`
-	AngularVelRatio float64 `json:"angular_vel_ratio"`
+	// Map list cache, and the outcome of the last refresh (see
+	// fleet_maps.go)
+	MapList    []string `json:"map_list"`
+	mapListAt  time.Time
+	mapListErr string
+
+	// Loaded map and its save/open history (see map_history.go)
+	currentMap string
+	mapHistory []MapEvent
+
+	// Other maps' point collections, map → floor assignments and the
+	// current map's floor (see floors.go)
+	mapPoints   map[string]MapPoints
+	floors      map[string]string
+	activeFloor string
+
+	// Last successful upload of each collection (see nav_sync.go)
+	navSynced map[navSyncKey]navSync
+
+	// Point edits that can be undone and redone (see nav_undo.go)
+	navUndo navUndo
+
+	// User settings (guarded by mu; see GetSettings / SetVelRatios)
+	linearVelRatio  float64
+	angularVelRatio float64
+	maxLinearVel    float64 // 0: unclamped
+	maxAngularVel   float64 // 0: unclamped
+	renderHints     MapRenderHints
+	cmdVel          rosbridge.CmdVelOptions
+	holonomic       bool // lateral velocity honored (see holonomic.go)
+
+	// Last joystick input and its twist (guarded by mu; see
+	// vel_ratio.go)
+	joystick joystickDrive
+
+	// RatioBounds returns the accepted velocity ratio range; set by the
+	// manager. OnSettings receives the settings after a ratio change.
+	RatioBounds func() RatioBounds `json:"-"`
+	OnSettings  func(Settings)     `json:"-"`
+
+	// globalUniqueNames makes point names unique across all point types
+	// (see SetGlobalUniqueNames).
+	globalUniqueNames bool
+
+	// Power state after a poweroff or reboot (guarded by mu; see
+	// power_state.go). OnPowerState receives its changes; set by the
+	// manager.
+	power        powerTransition
+	OnPowerState func(PowerEvent) `json:"-"`
+
+	// Connection progress (guarded by mu; see connect_phase.go);
+	// handshaken is set by the first successful handshake.
+	// OnConnectPhase receives phase changes; set by the manager.
+	phase          ConnectPhase
+	handshaken     bool
+	OnConnectPhase func(ConnectPhase) `json:"-"`
+
+	// Software e-stop and the active relative move (guarded by mu)
+	estop bool
+	move  *activeMove
+
+	// OnMoveProgress receives relative move progress; set by the manager.
+	OnMoveProgress func(MoveProgress) `json:"-"`
+
+	// OnRelocalize receives relocalization steps; set by the manager.
+	OnRelocalize func(RelocalizeEvent) `json:"-"`
+
+	// Latest navigation goal status and the patrol loop (guarded by mu;
+	// patrolStatus outlives the patrol so the last result stays visible)
+	navStatus    rosbridge.NavStatus
+	patrol       *activePatrol
+	patrolStatus *PatrolStatus
+
+	// OnPatrolEvent receives patrol start, lap and finish events; set by
+	// the manager.
+	OnPatrolEvent func(PatrolEvent) `json:"-"`
+
+	// Home pose and the go-home trip in progress (guarded by mu; see
+	// home.go)
+	home     *HomePose
+	homeTrip *homeTrip
+
+	// OnHome receives go-home events; set by the manager.
+	OnHome func(HomeEvent) `json:"-"`
+
+	// How odometry is reset (guarded by mu; see odom_reset.go)
+	odomReset OdomResetOptions
+
+	// OnPoseReset receives completed odometry resets; set by the manager.
+	OnPoseReset func(PoseReset) `json:"-"`
+
+	// Topics passed through to the browser and their latest messages
+	// (guarded by mu; see extra_topics.go). extraMu orders changes of
+	// the list. OnExtraTopic receives the messages to broadcast; set by
+	// the manager.
+	extraTopics  ExtraTopics
+	extraSubs    map[string]*extraSub
+	extraValues  map[string]ExtraTopicValue
+	extraMu      sync.Mutex
+	OnExtraTopic func(ExtraTopicValue) `json:"-"`
+
+	// Name of the settings template last applied (guarded by mu; see
+	// settings_template.go).
+	settingsTemplate string
+
+	// Distance and active-time counters (guarded by mu; see
+	// usage_stats.go); UsageJumpM is the longest odometry step counted
+	// (DefaultUsageJumpM when <= 0). OnUsageReset receives resets; set
+	// by the manager.
+	usage        usageTracker
+	UsageJumpM   float64          `json:"-"`
+	OnUsageReset func(UsageReset) `json:"-"`
+
+	// Point visits (guarded by mu; see visits.go)
+	visits visitTracker
+
+	// OnMode receives mode changes made through SwitchMode; set by the
+	// manager.
+	OnMode func(ModeChange) `json:"-"`
+
+	// Autonomy lock (guarded by mu; autonomy is the last reported state)
+	manualLock     bool
+	autonomyGating bool
+	takenOver      bool
+	takenOverAt    time.Time
+	autonomy       Autonomy
+
+	// OnAutonomy receives autonomy lock changes; set by the manager.
+	OnAutonomy func(Autonomy) `json:"-"`
+
+	// Manual control session and its deadman interval (guarded by mu;
+	// see manual_control.go)
+	manual          *manualSession
+	joystickDeadman time.Duration
+
+	// OnManualControl receives manual control echoes; set by the manager.
+	OnManualControl func(ManualControl) `json:"-"`
+
+	// Control lease, the request waiting for it and its options (see
+	// control_lease.go)
+	control         *controlLease
+	controlRequest  *ControlRequest
+	controlIdle     time.Duration
+	controlRequired bool
+
+	// OnControlLease receives lease transitions; set by the manager.
+	OnControlLease func(ControlLeaseEvent) `json:"-"`
+
+	// Map name waiting for a thumbnail from the next map received, and
+	// where that map goes (set by the manager).
+	thumbnailWanted string
+	OnMapThumbnail  func(mapName string, f MapFrame) `json:"-"`
+
+	// Robot-side mode as last switched through this server, and the
+	// active or last mapping session (guarded by mu)
+	mode    Mode
+	mapping *MappingSession
+
+	// Coverage growth while mapping and its stabilization settings
+	// (see mapping_progress.go)
+	mappingProgress   mappingTracker
+	mappingStableRate float64
+	mappingStableFor  time.Duration
+
+	// OnMappingSession receives mapping session state changes; set by
+	// the manager.
+	OnMappingSession func(MappingSession) `json:"-"`
+
+	// Background map saves: the running or last one, recent ones by ID,
+	// and the save options (guarded by mu; see map_save.go)
+	mapSave        *MapSaveOp
+	mapSaveOps     []*MapSaveOp
+	mapSaveTimeout time.Duration
+	mapSaveTopic   string
+
+	// OnMapSave receives map save progress and completion; set by the
+	// manager.
+	OnMapSave func(MapSaveOp) `json:"-"`
+
+	// OnConfig receives the radius, footprint and scan mask after one
+	// changes;
+	// set by the manager.
+	OnConfig func(RobotConfig) `json:"-"`
+
+	// Robot-side subscription settings (throttle ms by topic key)
+	topicThrottles map[string]int
+	useCBOR        bool
 
 	// Frequency tracking
 	lastMapTime   time.Time
@@ -74,93 +333,182 @@ type Robot struct {
 	OdomHz        int `json:"odom_hz"`
 	lastLaserTime time.Time
 	LaserHz       int `json:"laser_hz"`
+
+	connectedAt time.Time // zero while disconnected
+
+	lastMapBfpTime   time.Time
+	lastVelocityTime time.Time
+
+	// StaleThresholds returns the staleness thresholds (ms by stream)
+	// overriding DefaultStaleThresholds; set by the manager.
+	StaleThresholds func() map[string]int `json:"-"`
 }
 
 // NewRobot creates a new Robot and its rosbridge client.
 func NewRobot(id, ns, name, ip string, port int) *Robot {
 	r := &Robot{
-		ID:              id,
-		Namespace:       ns,
-		Name:            name,
-		IP:              ip,
-		Port:            port,
-		Radius:          0.30,
-		MaxHistory:      100,
-		VelocityHistory: make([]rosbridge.TwistData, 0, 100),
-		LinearVelRatio:  1.0,
-		AngularVelRatio: 1.0,
+		ID:                id,
+		Namespace:         ns,
+		Name:              name,
+		IP:                ip,
+		Port:              port,
+		radius:            0.30,
+		MaxHistory:        1500,
+		linearVelRatio:    1.0,
+		angularVelRatio:   1.0,
+		renderHints:       DefaultRenderHints(),
+		mappingStableRate: DefaultMappingStableM2PerMin,
+		mappingStableFor:  DefaultMappingStablePeriod,
+		autonomyGating:    true,
+		cmdVel:            rosbridge.DefaultCmdVelOptions,
+		lastUsed:          time.Now(),
+		phase:             ConnectPhase{Phase: PhaseDisconnected, Since: time.Now()},
+		locThresholds:     DefaultLocalizationThresholds,
+		offlineOpts:       DefaultOfflineQueue,
+		odomReset:         DefaultOdomReset,
 	}
 
 	client := rosbridge.NewClient(ns, ip, port)
 
-	// Wire up callbacks
-	client.OnMap = func(m rosbridge.MapData) {
+	// Robot state follows the client's events; the manager adds its
+	// broadcasts as further handlers
+	client.AddMapHandler(func(m rosbridge.MapData) {
+		sum := mapChecksum(m.Data)
 		r.mu.Lock()
+		r.observeMapLocked(m, sum, time.Now())
 		r.Map = m
 		r.MapReceived = true
 		r.MapHz = r.measureHz(&r.lastMapTime)
+		thumb := r.takeThumbnailWantedLocked()
+		frame := r.mapFrameLocked(m)
 		r.mu.Unlock()
-	}
+		if p, ok := r.trackMappingProgress(m); ok {
+			r.updateMappingStats(p)
+		}
+		if thumb != "" && r.OnMapThumbnail != nil {
+			go r.OnMapThumbnail(thumb, frame)
+		}
+	})
 
-	client.OnTwist = func(t rosbridge.TwistData) {
+	client.AddTwistHandler(func(t rosbridge.TwistData) {
 		r.mu.Lock()
 		r.Velocity = t
-		r.VelocityHistory = append(r.VelocityHistory, t)
-		if len(r.VelocityHistory) > r.MaxHistory {
-			r.VelocityHistory = r.VelocityHistory[1:]
-		}
+		r.lastVelocityTime = time.Now()
 		r.mu.Unlock()
-	}
+	})
 
-	client.OnTF = func(tf rosbridge.TFData) {
+	client.AddTFHandler(func(tf rosbridge.TFData) {
 		r.mu.Lock()
 		r.TF = tf
 		r.TFReceived = true
 		r.TFHz = r.measureHz(&r.lastTFTime)
+		if time.Since(r.lastMapBfpTime) > timelinePoseFreshness {
+			r.recordPoseLocked(mapPoseFromTF(tf), PoseSourceTF)
+		}
 		r.mu.Unlock()
-	}
+	})
 
-	client.OnOdom = func(o rosbridge.OdomData) {
+	client.AddOdomHandler(func(o rosbridge.OdomData) {
 		r.mu.Lock()
 		r.Odom = o
 		r.OdomHz = r.measureHz(&r.lastOdomTime)
+		r.recordMeasured(o)
+		r.usage.step(o.PosX, o.PosY, math.Hypot(o.LinearX, o.LinearY), o.AngularZ, time.Now(), r.usageJumpM())
 		r.mu.Unlock()
-	}
+		r.observeVariance(LocalizationFromOdom, o.Variance)
+	})
+
+	client.AddAMCLPoseHandler(func(v rosbridge.PoseVariance) {
+		r.observeVariance(LocalizationFromAMCL, &v)
+	})
+
+	client.AddMarkerHandler(func(label string) {
+		r.AddMarker(MarkerFromRobot, label, "")
+	})
+
+	client.AddCmdVelPublishedHandler(r.recordCommanded)
 
-	client.OnCtrlOdom = func(o rosbridge.OdomData) {
+	client.AddCtrlOdomHandler(func(o rosbridge.OdomData) {
 		r.mu.Lock()
 		r.ControllerOdom = o
 		r.mu.Unlock()
-	}
+	})
 
-	client.OnLaser = func(l rosbridge.LaserData) {
+	client.SetLaserFilter(r.maskLaser)
+	client.AddLaserHandler(func(l rosbridge.LaserData) {
 		r.mu.Lock()
 		r.Laser = l
 		r.LaserHz = r.measureHz(&r.lastLaserTime)
 		r.mu.Unlock()
-	}
+	})
 
-	client.OnMapBfp = func(p rosbridge.Pose2D) {
+	client.AddMapBfpHandler(func(p rosbridge.Pose2D) {
 		r.mu.Lock()
 		r.MapBfp = p
+		r.lastMapBfpTime = time.Now()
+		r.recordPoseLocked(p, PoseSourceMapBfp)
 		r.mu.Unlock()
-	}
+	})
 
-	client.OnConnected = func() {
+	client.AddNavStatusHandler(func(s rosbridge.NavStatus) {
 		r.mu.Lock()
-		r.Connected = true
+		if prev := r.navStatus; s.GoalID != prev.GoalID || s.Status != prev.Status {
+			r.recordEventLocked(TimelineNav, NavTransition{NavStatus: s, PreviousState: prev.State})
+		}
+		r.navStatus = s
+		p := r.patrol
 		r.mu.Unlock()
+		r.updateAutonomy()
+		r.trackHome(s)
+		if p != nil {
+			select {
+			case p.nav <- s:
+			default:
+			}
+		}
+	})
+
+	client.AddMapSaveProgressHandler(r.setMapSaveProgress)
+
+	client.AddConnectHandler(func() {
+		r.setConnected(true)
+		r.phaseConnected(false)
+		r.powerConnected()
 		client.SubscribeAllTopics()
 		client.SetCmdVelEnabled(true)
-	}
+		// Off the handler goroutine: later connect handlers shouldn't
+		// wait for the robot's answer. Capabilities first, so the task
+		// catalog request is checked against them.
+		go func() {
+			if _, err := r.RefreshCapabilities(); err != nil {
+				log.Printf("[robot %s] %v", r.ID, err)
+			}
+			if _, err := r.ReplayPending(); err != nil {
+				log.Printf("[robot %s] Offline queue replay stopped: %v", r.ID, err)
+			}
+			if _, err := r.RefreshTaskCatalog(); err != nil {
+				log.Printf("[robot %s] %v", r.ID, err)
+			}
+		}()
+	})
 
-	client.OnDisconnected = func() {
-		r.mu.Lock()
-		r.Connected = false
-		r.mu.Unlock()
-	}
+	client.AddDisconnectHandler(func() {
+		r.setConnected(false)
+		r.setPhase(ConnectPhase{Phase: PhaseDisconnected})
+		r.powerDisconnected()
+	})
+
+	client.AddStatusHandler(func(st rosbridge.StatusMessage) {
+		subject := st.Topic
+		if subject == "" {
+			subject = st.Service
+		}
+		log.Printf("[robot %s] rosbridge %s: %s (%s)", r.ID, st.Level, st.Msg, subject)
+	})
 
 	r.Client = client
+	r.topicThrottles = client.Throttles()
+	r.tasks = NewTaskQueue(client.RequestTask)
 	return r
 }
 
@@ -185,49 +533,161 @@ func (r *Robot) GetMap() rosbridge.MapData {
 	return r.Map
 }
 
-// GetVelocityHistory returns a copy of velocity history.
-func (r *Robot) GetVelocityHistory() []rosbridge.TwistData {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	cp := make([]rosbridge.TwistData, len(r.VelocityHistory))
-	copy(cp, r.VelocityHistory)
-	return cp
+// Snapshot is a point-in-time copy of a robot's state, safe to read
+// without locking and to render or encode as JSON.
+type Snapshot struct {
+	ID                string                      `json:"id"`
+	Namespace         string                      `json:"namespace"`
+	Name              string                      `json:"name"`
+	IP                string                      `json:"ip"`
+	Port              int                         `json:"port"`
+	Radius            float64                     `json:"radius"`
+	Footprint         Footprint                   `json:"footprint,omitempty"`
+	ScanMask          ScanMask                    `json:"scan_mask,omitempty"`
+	Home              *HomePose                   `json:"home,omitempty"`
+	Connected         bool                        `json:"connected"`
+	MapReceived       bool                        `json:"-"`
+	Odom              rosbridge.OdomData          `json:"odom"`
+	ControllerOdom    rosbridge.OdomData          `json:"controller_odom"`
+	TF                rosbridge.TFData            `json:"tf"`
+	TFReceived        bool                        `json:"-"`
+	MapBfp            rosbridge.Pose2D            `json:"map_bfp"`
+	Velocity          rosbridge.TwistData         `json:"velocity"`
+	Waypoints         []rosbridge.NavigationPoint `json:"waypoints"`
+	ServicePoints     []rosbridge.NavigationPoint `json:"service_points"`
+	PatrolPoints      []rosbridge.NavigationPoint `json:"patrol_points"`
+	PathPoints        []rosbridge.NavigationPoint `json:"path_points"`
+	WallObstacles     []rosbridge.WallObstacle    `json:"wall_obstacles"`
+	MapList           []string                    `json:"map_list"`
+	CurrentMap        string                      `json:"current_map"`
+	ActiveFloor       string                      `json:"active_floor"`
+	LinearVelRatio    float64                     `json:"linear_vel_ratio"`
+	AngularVelRatio   float64                     `json:"angular_vel_ratio"`
+	MaxLinearVel      float64                     `json:"max_linear_vel"`
+	MaxAngularVel     float64                     `json:"max_angular_vel"`
+	TopicThrottles    map[string]int              `json:"topic_throttles"`
+	UseCBOR           bool                        `json:"use_cbor"`
+	EStop             bool                        `json:"estop"`
+	SplitConnections  bool                        `json:"split_connections"`
+	SharedConnection  bool                        `json:"shared_connection"`
+	ConnectionType    string                      `json:"connection_type"` // direct, shared or reverse
+	Power             PowerStatus                 `json:"power"`
+	ConnectPhase      ConnectPhase                `json:"connect_phase"`
+	Reconnect         rosbridge.ReconnectPolicy   `json:"reconnect"`
+	CmdVel            rosbridge.CmdVelOptions     `json:"cmd_vel"`
+	Holonomic         bool                        `json:"holonomic"`
+	SoftwareVersion   string                      `json:"software_version,omitempty"`
+	Capabilities      []string                    `json:"capabilities"`   // null: unknown
+	ActivityState     string                      `json:"activity_state"` // active or idle
+	IdleSince         *time.Time                  `json:"idle_since,omitempty"`
+	Localization      Localization                `json:"localization"`
+	Usage             UsageTotals                 `json:"usage"`
+	OfflineQueue      OfflineQueueOptions         `json:"offline_queue"`
+	OdomReset         OdomResetOptions            `json:"odom_reset"`
+	ExtraTopics       ExtraTopics                 `json:"extra_topics"`
+	ExtraValues       map[string]ExtraTopicValue  `json:"extra_values"` // latest message by alias
+	GlobalUniqueNames bool                        `json:"enforce_global_unique_names"`
+	ClockSkewMs       *float64                    `json:"clock_skew_ms"`
+	NavStatus         rosbridge.NavStatus         `json:"nav_status"`
+	Patrol            *PatrolStatus               `json:"patrol,omitempty"`
+	Autonomy          Autonomy                    `json:"autonomy"`
+	ManualDriver      *ManualControl              `json:"manual_driver,omitempty"`
+	ControlHolder     *Driver                     `json:"control_holder,omitempty"`
+	Mode              Mode                        `json:"mode,omitempty"`
+	Mapping           *MappingSession             `json:"mapping,omitempty"`
+	MapSaveInProgress bool                        `json:"map_save_in_progress"`
+	MapHz             int                         `json:"map_hz"`
+	TFHz              int                         `json:"tf_hz"`
+	OdomHz            int                         `json:"odom_hz"`
+	LaserHz           int                         `json:"laser_hz"`
+
+	// Freshness of the streaming values above (see freshness.go)
+	Freshness
 }
 
 // GetSnapshot returns a safe snapshot of the robot state.
-func (r *Robot) GetSnapshot() Robot {
+func (r *Robot) GetSnapshot() Snapshot {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return Robot{
-		ID:              r.ID,
-		Namespace:       r.Namespace,
-		Name:            r.Name,
-		IP:              r.IP,
-		Port:            r.Port,
-		Radius:          r.Radius,
-		Connected:       r.Connected,
-		MapReceived:     r.MapReceived,
-		Odom:            r.Odom,
-		ControllerOdom:  r.ControllerOdom,
-		TF:              r.TF,
-		TFReceived:      r.TFReceived,
-		MapBfp:          r.MapBfp,
-		Velocity:        r.Velocity,
-		Waypoints:       r.Waypoints,
-		ServicePoints:   r.ServicePoints,
-		PatrolPoints:    r.PatrolPoints,
-		PathPoints:      r.PathPoints,
-		WallObstacles:   r.WallObstacles,
-		MapList:         r.MapList,
-		LinearVelRatio:  r.LinearVelRatio,
-		AngularVelRatio: r.AngularVelRatio,
-		MapHz:           r.MapHz,
-		TFHz:            r.TFHz,
-		OdomHz:          r.OdomHz,
-		LaserHz:         r.LaserHz,
+	return Snapshot{
+		ID:                r.ID,
+		Namespace:         r.Namespace,
+		Name:              r.Name,
+		IP:                r.IP,
+		Port:              r.Port,
+		Radius:            r.radius,
+		Footprint:         r.footprint.clone(),
+		ScanMask:          r.scanMask.clone(),
+		Home:              r.home.clone(),
+		Connected:         r.connected,
+		MapReceived:       r.MapReceived,
+		Odom:              r.Odom,
+		ControllerOdom:    r.ControllerOdom,
+		TF:                r.TF,
+		TFReceived:        r.TFReceived,
+		MapBfp:            r.MapBfp,
+		Velocity:          r.Velocity,
+		Waypoints:         append([]rosbridge.NavigationPoint(nil), r.Waypoints...),
+		ServicePoints:     append([]rosbridge.NavigationPoint(nil), r.ServicePoints...),
+		PatrolPoints:      append([]rosbridge.NavigationPoint(nil), r.PatrolPoints...),
+		PathPoints:        append([]rosbridge.NavigationPoint(nil), r.PathPoints...),
+		WallObstacles:     append([]rosbridge.WallObstacle(nil), r.WallObstacles...),
+		MapList:           append([]string(nil), r.MapList...),
+		CurrentMap:        r.currentMap,
+		ActiveFloor:       r.activeFloor,
+		LinearVelRatio:    r.linearVelRatio,
+		AngularVelRatio:   r.angularVelRatio,
+		MaxLinearVel:      r.maxLinearVel,
+		MaxAngularVel:     r.maxAngularVel,
+		TopicThrottles:    copyThrottles(r.topicThrottles),
+		UseCBOR:           r.useCBOR,
+		EStop:             r.estop,
+		SplitConnections:  r.Client.SplitEnabled(),
+		SharedConnection:  r.Client.SharedEnabled(),
+		ConnectionType:    r.ConnectionType(),
+		Power:             r.powerStatusLocked(),
+		ConnectPhase:      r.phase,
+		Reconnect:         r.Client.ReconnectPolicy(),
+		CmdVel:            r.cmdVel,
+		Holonomic:         r.holonomic,
+		SoftwareVersion:   r.caps.SoftwareVersion,
+		Capabilities:      r.capabilitiesLocked().Capabilities,
+		ActivityState:     r.activityLocked().State,
+		IdleSince:         r.activityLocked().IdleSince,
+		Localization:      r.localizationLocked(),
+		Usage:             r.usageTotalsLocked(),
+		OfflineQueue:      r.offlineOpts,
+		OdomReset:         r.odomReset,
+		ExtraTopics:       append(ExtraTopics(nil), r.extraTopics...),
+		ExtraValues:       r.extraValuesLocked(),
+		GlobalUniqueNames: r.globalUniqueNames,
+		ClockSkewMs:       r.clockSkewMs(),
+		NavStatus:         r.navStatus,
+		Patrol:            r.patrolStatusLocked(),
+		Autonomy:          r.autonomyLocked(),
+		ManualDriver:      r.manualDriverLocked(),
+		ControlHolder:     r.controlHolderLocked(),
+		Mode:              r.mode,
+		Mapping:           r.mappingLocked(),
+		MapSaveInProgress: r.mapSaveInProgressLocked(),
+		MapHz:             r.MapHz,
+		TFHz:              r.TFHz,
+		OdomHz:            r.OdomHz,
+		LaserHz:           r.LaserHz,
+		Freshness:         r.freshnessLocked(),
 	}
 }
 
+// clockSkewMs is the robot clock offset estimate, or nil before the
+// first stamped message.
+func (r *Robot) clockSkewMs() *float64 {
+	sk := r.Client.ClockSkew()
+	if !sk.Valid {
+		return nil
+	}
+	return &sk.SkewMs
+}
+
 // GetMapList returns a copy of the robot's map list.
 func (r *Robot) GetMapList() []string {
 	r.mu.RLock()
@@ -244,17 +704,90 @@ func (r *Robot) SetMapList(maps []string) {
 	r.MapList = maps
 }
 
-// SetVelocity sets the desired velocity through the rosbridge client.
-func (r *Robot) SetVelocity(linearX, angularZ float64) {
+// RefreshMapList asks the robot for its maps and keeps a non-empty
+// answer as the map list.
+func (r *Robot) RefreshMapList() ([]string, error) {
+	return r.RefreshMapListTimeout(10 * time.Second)
+}
+
+// RefreshMapListTimeout is RefreshMapList waiting up to timeout for the
+// robot. The outcome is recorded for the fleet map matrix.
+func (r *Robot) RefreshMapListTimeout(timeout time.Duration) ([]string, error) {
+	if r.Client == nil || !r.Client.IsConnected() {
+		return nil, ErrNotConnected
+	}
+	names, err := r.Client.RequestWhichMapsNamesTimeout(timeout)
+	r.mu.Lock()
+	r.mapListAt = time.Now()
+	r.mapListErr = ""
+	if err != nil {
+		r.mapListErr = err.Error()
+	} else if len(names) > 0 {
+		r.MapList = names
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// SetVelocity sets the desired velocity through the rosbridge client,
+// scaled by the velocity ratios and clamped to the robot's limits when
+// they are set.
+// linearY is dropped unless the robot is holonomic. Manual input cancels
+// any active relative move.
+func (r *Robot) SetVelocity(linearX, linearY, angularZ float64) {
+	r.CancelMove()
+
 	r.mu.RLock()
-	lr := r.LinearVelRatio
-	ar := r.AngularVelRatio
+	lr := r.linearVelRatio
+	ar := r.angularVelRatio
+	if !r.holonomic {
+		linearY = 0
+	}
 	r.mu.RUnlock()
 
-	r.Client.SetDesiredCmdVel(rosbridge.TwistData{
-		LinearX:  linearX * lr,
-		AngularZ: angularZ * ar,
-	})
+	sent := r.commandVelocity(linearX*lr, linearY*lr, angularZ*ar)
+	r.mu.Lock()
+	r.joystick = joystickDrive{linearX: linearX, linearY: linearY, angularZ: angularZ, sent: sent}
+	r.mu.Unlock()
+}
+
+// commandVelocity publishes an absolute velocity clamped to the robot's
+// limits, or zero while the e-stop is engaged, and returns what it set.
+func (r *Robot) commandVelocity(linearX, linearY, angularZ float64) rosbridge.TwistData {
+	r.mu.RLock()
+	maxLin := r.maxLinearVel
+	maxAng := r.maxAngularVel
+	estop := r.estop
+	r.mu.RUnlock()
+
+	if estop {
+		linearX, linearY, angularZ = 0, 0, 0
+	}
+	linearX, linearY = clampPlanar(linearX, linearY, maxLin)
+	twist := rosbridge.TwistData{
+		LinearX:  linearX,
+		LinearY:  linearY,
+		AngularZ: clamp(angularZ, maxAng),
+	}
+	r.Client.SetDesiredCmdVel(twist)
+	return twist
+}
+
+// clamp limits v to [-limit, limit]; a non-positive limit disables it.
+func clamp(v, limit float64) float64 {
+	if limit <= 0 {
+		return v
+	}
+	if v > limit {
+		return limit
+	}
+	if v < -limit {
+		return -limit
+	}
+	return v
 }
 
 // StopConnection disconnects the robot.
@@ -263,27 +796,170 @@ func (r *Robot) StopConnection() {
 	r.Client.Disconnect()
 }
 
+// SetReconnectPolicy sets how the robot's connection is retried after
+// it drops or a dial fails.
+func (r *Robot) SetReconnectPolicy(p rosbridge.ReconnectPolicy) error {
+	return r.Client.SetReconnectPolicy(p)
+}
+
+// SetCmdVelOptions sets the robot's cmd_vel publish rate and idle
+// behaviour and applies them to its client.
+func (r *Robot) SetCmdVelOptions(o rosbridge.CmdVelOptions) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.Client.SetCmdVelOptions(o); err != nil {
+		return err
+	}
+	r.cmdVel = o
+	return nil
+}
+
+// CmdVelOptions returns the robot's cmd_vel publish options.
+func (r *Robot) CmdVelOptions() rosbridge.CmdVelOptions {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cmdVel
+}
+
+// Close disconnects the robot, cancels any pending reconnect and stops
+// its background workers.
+func (r *Robot) Close() {
+	r.CancelMove()
+	r.stopPatrol()
+	r.stopExtraTopics()
+	r.Client.UnsubscribeAll()
+	r.Client.Close()
+	r.tasks.Stop()
+}
+
 // SetRadius sets the robot's radius in meters.
 func (r *Robot) SetRadius(radius float64) {
 	r.mu.Lock()
-	r.Radius = radius
+	r.radius = radius
+	r.mu.Unlock()
+	r.emitConfig()
+}
+
+// Handshake asks the robot for its handshake and applies the size and
+// footprint it reports; the robot is handshaking meanwhile, then ready.
+func (r *Robot) Handshake() (*rosbridge.HandshakeResponse, error) {
+	r.setPhase(ConnectPhase{Phase: PhaseHandshaking})
+	hs, err := r.Client.Handshake()
+	if err != nil {
+		if r.Client.IsConnected() {
+			r.setPhase(ConnectPhase{Phase: PhaseFailed, Category: FailedHandshake, Error: err.Error()})
+		}
+		return nil, err
+	}
+	r.mu.Lock()
+	r.handshaken = true
+	r.mu.Unlock()
+	r.setPhase(ConnectPhase{Phase: PhaseReady})
+	if hs.RobotDiameter > 0 {
+		r.SetRadius(hs.RobotDiameter / 2.0)
+	}
+	if len(hs.RobotFootprint) > 0 {
+		if err := r.SetFootprint(hs.RobotFootprint); err != nil {
+			log.Printf("[robot %s] Handshake footprint ignored: %v", r.ID, err)
+		}
+	}
+	return hs, nil
+}
+
+// Settings are the user-adjustable robot settings.
+type Settings struct {
+	LinearVelRatio  float64   `json:"linear_vel_ratio"`
+	AngularVelRatio float64   `json:"angular_vel_ratio"`
+	Radius          float64   `json:"radius"`
+	Footprint       Footprint `json:"footprint,omitempty"`
+	MaxLinearVel    float64   `json:"max_linear_vel"`
+	MaxAngularVel   float64   `json:"max_angular_vel"`
+	Holonomic       bool      `json:"holonomic"`
+
+	EnforceGlobalUniqueNames bool `json:"enforce_global_unique_names"`
+}
+
+// GetSettings returns the current user settings.
+func (r *Robot) GetSettings() Settings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return Settings{
+		LinearVelRatio:  r.linearVelRatio,
+		AngularVelRatio: r.angularVelRatio,
+		Radius:          r.radius,
+		Footprint:       r.footprint.clone(),
+		MaxLinearVel:    r.maxLinearVel,
+		MaxAngularVel:   r.maxAngularVel,
+		Holonomic:       r.holonomic,
+
+		EnforceGlobalUniqueNames: r.globalUniqueNames,
+	}
+}
+
+// SetMaxVelocities sets the robot's velocity limits (m/s, rad/s); zero
+// leaves that axis unclamped.
+func (r *Robot) SetMaxVelocities(linear, angular float64) {
+	r.mu.Lock()
+	r.maxLinearVel = linear
+	r.maxAngularVel = angular
 	r.mu.Unlock()
 }
 
-// ImportPoints bulk-imports navigation points by type.
-func (r *Robot) ImportPoints(pointType string, points []rosbridge.NavigationPoint, walls []rosbridge.WallObstacle) {
+// IsConnected reports whether the rosbridge connection is up.
+func (r *Robot) IsConnected() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.connected
+}
+
+func (r *Robot) setConnected(connected bool) {
+	r.mu.Lock()
+	if connected && !r.connected {
+		r.connectedAt = time.Now()
+	} else if !connected {
+		r.connectedAt = time.Time{}
+	}
+	r.connected = connected
+	r.mu.Unlock()
+}
+
+// Activity is when the connection came up and when each frequency-tracked
+// topic last delivered a message; zero times mean never (or disconnected).
+type Activity struct {
+	ConnectedAt time.Time
+	LastMap     time.Time
+	LastTF      time.Time
+	LastOdom    time.Time
+	LastLaser   time.Time
+}
+
+// GetActivity returns connection and last-message times.
+func (r *Robot) GetActivity() Activity {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return Activity{
+		ConnectedAt: r.connectedAt,
+		LastMap:     r.lastMapTime,
+		LastTF:      r.lastTFTime,
+		LastOdom:    r.lastOdomTime,
+		LastLaser:   r.lastLaserTime,
+	}
+}
+
+// ImportPoints replaces the collection of pointType with points, or the
+// walls with walls.
+func (r *Robot) ImportPoints(pointType rosbridge.PointType, points []rosbridge.NavigationPoint, walls []rosbridge.WallObstacle) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	switch pointType {
-	case "waypoint":
-		r.Waypoints = points
-	case "service_point":
-		r.ServicePoints = points
-	case "patrol_point":
-		r.PatrolPoints = points
-	case "path_point":
-		r.PathPoints = points
-	case "wall":
-		r.WallObstacles = walls
+	before := r.editPointsLocked(pointType)
+	n := len(points)
+	if pointType == rosbridge.PointWall {
+		r.WallObstacles, n = walls, len(walls)
+	} else if coll := r.pointCollection(pointType); coll != nil {
+		*coll = points
+	} else {
+		return invalidPointType(pointType)
 	}
+	r.recordEditLocked(editLabel("import", pointType, n, ""), before, pointType)
+	return nil
 }
@@ -0,0 +1,158 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Scan mask
+//
+// A lidar that sees the robot's own mast or brackets reports permanent
+// obstacles at fixed angles. The scan mask lists angular sectors, as
+// [start, end] radians in the laser frame, whose ranges are zeroed (the
+// "no return" value) before anything downstream sees the scan. A sector
+// runs counter-clockwise from start to end, so start > end wraps through
+// ±π: [3, -3] is the 0.28 rad behind the robot. The raw scan is kept for
+// diagnostics.
+
+// MaxScanMaskSectors bounds the number of masked sectors.
+const MaxScanMaskSectors = 16
+
+// ScanMask is a list of [start, end] sectors in the laser frame.
+type ScanMask [][2]float64
+
+// ParseScanMask decodes and validates a JSON [[start, end], ...] mask.
+// "[]" yields nil, which clears the mask.
+func ParseScanMask(s string) (ScanMask, error) {
+	var raw [][]float64
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("scan mask must be a JSON array of [start, end] sectors in radians: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	m := make(ScanMask, len(raw))
+	for i, sec := range raw {
+		if len(sec) != 2 {
+			return nil, fmt.Errorf("scan mask sector %d needs [start, end], got %d values", i, len(sec))
+		}
+		m[i] = [2]float64{sec[0], sec[1]}
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate checks that m has at most MaxScanMaskSectors non-empty
+// sectors with finite bounds within ±π.
+func (m ScanMask) Validate() error {
+	if len(m) > MaxScanMaskSectors {
+		return fmt.Errorf("scan mask has %d sectors, at most %d allowed", len(m), MaxScanMaskSectors)
+	}
+	for i, sec := range m {
+		for _, a := range sec {
+			if math.IsNaN(a) || math.Abs(a) > math.Pi+1e-9 {
+				return fmt.Errorf("scan mask sector %d (%v, %v) is outside ±π", i, sec[0], sec[1])
+			}
+		}
+		if sec[0] == sec[1] {
+			return fmt.Errorf("scan mask sector %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the laser-frame angle lies in a sector. π and
+// -π are the same direction, so a sector ending at either holds both.
+func (m ScanMask) Contains(angle float64) bool {
+	a := math.Remainder(angle, 2*math.Pi)
+	for _, sec := range m {
+		if sectorContains(sec, a) || math.Abs(a) == math.Pi && sectorContains(sec, -a) {
+			return true
+		}
+	}
+	return false
+}
+
+// sectorContains reports whether the angle a, within ±π, lies in sec.
+func sectorContains(sec [2]float64, a float64) bool {
+	start, end := sec[0], sec[1]
+	if start <= end {
+		return a >= start && a <= end
+	}
+	return a >= start || a <= end
+}
+
+// Apply returns l with the ranges inside the mask set to 0. The ranges
+// are copied; l is not modified.
+func (m ScanMask) Apply(l rosbridge.LaserData) rosbridge.LaserData {
+	if len(m) == 0 {
+		return l
+	}
+	ranges := make([]float64, len(l.Ranges))
+	for i, rng := range l.Ranges {
+		if !m.Contains(l.AngleMin + float64(i)*l.AngleIncrement) {
+			ranges[i] = rng
+		}
+	}
+	l.Ranges = ranges
+	return l
+}
+
+// String is the JSON form, "" when unset (settings panel).
+func (m ScanMask) String() string {
+	if len(m) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+func (m ScanMask) clone() ScanMask {
+	if m == nil {
+		return nil
+	}
+	return append(ScanMask(nil), m...)
+}
+
+// SetScanMask sets the masked sectors; nil clears them.
+func (r *Robot) SetScanMask(m ScanMask) error {
+	if m != nil {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	r.scanMask = m.clone()
+	r.mu.Unlock()
+	r.emitConfig()
+	return nil
+}
+
+// GetScanMask returns a copy of the mask, or nil.
+func (r *Robot) GetScanMask() ScanMask {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.scanMask.clone()
+}
+
+// GetRawLaser returns the latest scan before masking.
+func (r *Robot) GetRawLaser() rosbridge.LaserData {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rawLaser
+}
+
+// maskLaser is the client's laser filter: it keeps the raw scan and
+// returns the masked one.
+func (r *Robot) maskLaser(l rosbridge.LaserData) rosbridge.LaserData {
+	r.mu.Lock()
+	r.rawLaser = l
+	m := r.scanMask
+	r.mu.Unlock()
+	return m.Apply(l)
+}
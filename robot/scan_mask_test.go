@@ -0,0 +1,93 @@
+package robot
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+func TestScanMaskContains(t *testing.T) {
+	behind := ScanMask{{3, -3}}              // wraps through ±π
+	left := ScanMask{{math.Pi / 2, math.Pi}} // up to the seam
+	both := ScanMask{{-0.2, 0.2}, {3, -3}}
+
+	for _, tc := range []struct {
+		name  string
+		m     ScanMask
+		angle float64
+		want  bool
+	}{
+		{"wrap: at π", behind, math.Pi, true},
+		{"wrap: at -π", behind, -math.Pi, true},
+		{"wrap: just inside start", behind, 3.01, true},
+		{"wrap: just inside end", behind, -3.01, true},
+		{"wrap: start", behind, 3, true},
+		{"wrap: end", behind, -3, true},
+		{"wrap: just outside start", behind, 2.99, false},
+		{"wrap: just outside end", behind, -2.99, false},
+		{"wrap: ahead", behind, 0, false},
+		{"wrap: angle past π", behind, math.Pi + 0.1, true},   // -π+0.1
+		{"wrap: angle past -π", behind, -math.Pi - 0.1, true}, // π-0.1
+		{"wrap: angle beyond 2π", behind, 2*math.Pi + 3.1, true},
+		{"wrap: 2π is ahead", behind, 2 * math.Pi, false},
+		{"to the seam: π", left, math.Pi, true},
+		{"to the seam: -π", left, -math.Pi, true}, // the same direction
+		{"to the seam: right side", left, -math.Pi / 2, false},
+		{"two sectors: ahead", both, 0.1, true},
+		{"two sectors: behind", both, -3.1, true},
+		{"two sectors: side", both, 1.5, false},
+		{"empty mask", nil, 0, false},
+	} {
+		if got := tc.m.Contains(tc.angle); got != tc.want {
+			t.Errorf("%s: Contains(%v) = %v", tc.name, tc.angle, got)
+		}
+	}
+}
+
+func TestScanMaskApply(t *testing.T) {
+	// 8 beams from -π, every π/4: -π, -3π/4, ..., 3π/4
+	scan := rosbridge.LaserData{AngleMin: -math.Pi, AngleIncrement: math.Pi / 4, Ranges: []float64{1, 2, 3, 4, 5, 6, 7, 8}}
+	masked := ScanMask{{2.3, -2.5}, {-0.1, 0.1}}.Apply(scan)
+	want := []float64{0, 2, 3, 4, 0, 6, 7, 0} // -π, 0 and 3π/4 (2.36) masked, -3π/4 not
+	for i := range want {
+		if masked.Ranges[i] != want[i] {
+			t.Fatalf("masked ranges %v, want %v", masked.Ranges, want)
+		}
+	}
+	if scan.Ranges[0] != 1 {
+		t.Error("Apply modified the raw scan")
+	}
+	if got := ScanMask(nil).Apply(scan); &got.Ranges[0] != &scan.Ranges[0] {
+		t.Error("an empty mask copied the scan")
+	}
+}
+
+func TestParseScanMask(t *testing.T) {
+	m, err := ParseScanMask(`[[3, -3], [-0.5, 0.5]]`)
+	if err != nil || len(m) != 2 || m[0] != [2]float64{3, -3} {
+		t.Errorf("parse: %v %v", m, err)
+	}
+	if back, err := ParseScanMask(m.String()); err != nil || len(back) != 2 || back[1] != m[1] {
+		t.Errorf("round trip: %v %v", back, err)
+	}
+	if m, err := ParseScanMask(`[]`); m != nil || err != nil {
+		t.Errorf("empty: %v %v", m, err)
+	}
+	if _, err := ParseScanMask(`[[-3.1415926536, 3.1415926536]]`); err != nil {
+		t.Errorf("the whole circle: %v", err)
+	}
+	for s, want := range map[string]string{
+		`[[0, 3.2]]`:   "outside ±π",
+		`[[1, 1]]`:     "empty",
+		`[[1]]`:        "needs [start, end]",
+		`[[1, 2, 3]]`:  "needs [start, end]",
+		`{"start": 1}`: "JSON array",
+		`[[0, 0.1]` + strings.Repeat(`, [0, 0.1]`, MaxScanMaskSectors) + `]`: "at most",
+	} {
+		if _, err := ParseScanMask(s); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseScanMask(%.40s) = %v, want %q", s, err, want)
+		}
+	}
+}
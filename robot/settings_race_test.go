@@ -0,0 +1,57 @@
+package robot
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSettingsConcurrentAccess is meant for -race: settings are written
+// while other goroutines read them, and every read must see a pair of
+// ratios written together.
+func TestSettingsConcurrentAccess(t *testing.T) {
+	r := NewRobot("1", "", "test", "127.0.0.1", 9)
+	defer r.Close()
+
+	const n = 500
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			v := 0.1 + float64(i%19)*0.1
+			if err := r.SetVelRatios(v, v); err != nil {
+				t.Error(err)
+				return
+			}
+			r.SetRadius(v)
+			r.SetMaxVelocities(v, v)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			r.setConnected(i%2 == 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if s := r.GetSettings(); s.LinearVelRatio != s.AngularVelRatio || s.MaxLinearVel != s.MaxAngularVel {
+				t.Errorf("torn settings: %+v", s)
+				return
+			}
+			snap := r.GetSnapshot()
+			if snap.LinearVelRatio != snap.AngularVelRatio {
+				t.Errorf("torn snapshot ratios: %v, %v", snap.LinearVelRatio, snap.AngularVelRatio)
+				return
+			}
+			r.IsConnected()
+		}
+	}()
+	wg.Wait()
+
+	r.setConnected(true)
+	if !r.IsConnected() {
+		t.Error("IsConnected = false after setConnected(true)")
+	}
+}
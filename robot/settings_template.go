@@ -0,0 +1,424 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Settings templates
+//
+// Identical robots are commissioned with the same settings. A settings
+// template is a named set of per-robot settings; fields it leaves unset
+// are not touched when it is applied. Applying goes through the same
+// setters, and the same checks, as the settings form of one robot.
+//
+// A robot remembers the template last applied to it. Its settings are
+// compared with that template field by field to tell whether they still
+// match or have drifted since; a robot without one is reported as
+// matching the first template all of whose fields it has.
+
+// Template name limits.
+const (
+	MaxTemplateNameLen   = 64
+	MaxSettingsTemplates = 100
+)
+
+// Template application outcomes.
+const (
+	TemplateApplied = "applied" // applied and saved on the robot
+	TemplateQueued  = "queued"  // applied; the save waits in the offline queue
+	TemplateSkipped = "skipped" // applied; not saved on the robot (disconnected, no offline queue)
+	TemplateFailed  = "failed"  // refused; see the error
+)
+
+// Errors of the template store.
+var (
+	ErrTemplateNotFound = errors.New("settings template not found")
+	ErrTemplateExists   = errors.New("settings template already exists")
+	ErrTooManyTemplates = fmt.Errorf("at most %d settings templates", MaxSettingsTemplates)
+)
+
+// SettingsTemplate is a named set of robot settings. Unset (nil) fields
+// are left as they are on the robots it is applied to.
+type SettingsTemplate struct {
+	Name            string                   `json:"name"`
+	LinearVelRatio  *float64                 `json:"linear_vel_ratio,omitempty"`
+	AngularVelRatio *float64                 `json:"angular_vel_ratio,omitempty"`
+	MaxLinearVel    *float64                 `json:"max_linear_vel,omitempty"`
+	MaxAngularVel   *float64                 `json:"max_angular_vel,omitempty"`
+	Radius          *float64                 `json:"radius,omitempty"`
+	Holonomic       *bool                    `json:"holonomic,omitempty"`
+	TopicThrottles  map[string]int           `json:"topic_throttles,omitempty"` // only the topics listed
+	UseCBOR         *bool                    `json:"use_cbor,omitempty"`
+	ExtraTopics     *ExtraTopics             `json:"extra_topics,omitempty"` // [] removes them all
+	CmdVel          *rosbridge.CmdVelOptions `json:"cmd_vel,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the name and the values that don't depend on a robot;
+// velocity ratios are checked against each robot's bounds on apply.
+func (t SettingsTemplate) Validate() error {
+	if err := validateTemplateName(t.Name); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		v    *float64
+	}{
+		{"linear_vel_ratio", t.LinearVelRatio}, {"angular_vel_ratio", t.AngularVelRatio},
+		{"radius", t.Radius},
+	} {
+		if f.v != nil && !(*f.v > 0 && !math.IsInf(*f.v, 0)) {
+			return fmt.Errorf("%s: %v is not positive", f.name, *f.v)
+		}
+	}
+	// A zero velocity limit turns that limit off
+	for _, f := range []struct {
+		name string
+		v    *float64
+	}{{"max_linear_vel", t.MaxLinearVel}, {"max_angular_vel", t.MaxAngularVel}} {
+		if f.v != nil && !(*f.v >= 0 && !math.IsInf(*f.v, 0)) {
+			return fmt.Errorf("%s: %v is negative or not finite", f.name, *f.v)
+		}
+	}
+	for k, v := range t.TopicThrottles {
+		if !isTopicKey(k) {
+			return fmt.Errorf("topic_throttles.%s: unknown topic", k)
+		}
+		if v < 0 {
+			return fmt.Errorf("topic_throttles.%s: %d is negative", k, v)
+		}
+	}
+	if t.ExtraTopics != nil {
+		if err := t.ExtraTopics.Validate(); err != nil {
+			return fmt.Errorf("extra_topics: %w", err)
+		}
+	}
+	if t.CmdVel != nil {
+		if t.CmdVel.RateHz < rosbridge.MinCmdVelRateHz || t.CmdVel.RateHz > rosbridge.MaxCmdVelRateHz {
+			return fmt.Errorf("cmd_vel.rate_hz: must be %v..%v Hz", rosbridge.MinCmdVelRateHz, rosbridge.MaxCmdVelRateHz)
+		}
+	}
+	return nil
+}
+
+func validateTemplateName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("name is required")
+	case len([]rune(name)) > MaxTemplateNameLen:
+		return fmt.Errorf("name must be at most %d characters", MaxTemplateNameLen)
+	case strings.TrimSpace(name) != name:
+		return fmt.Errorf("name must not start or end with spaces")
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return fmt.Errorf("name must not contain control characters")
+	}
+	return nil
+}
+
+// TemplateDrift lists the fields of t whose values differ on the robot,
+// by their JSON names; empty when the robot matches t.
+func (r *Robot) TemplateDrift(t SettingsTemplate) []string {
+	s := r.GetSnapshot()
+	var drift []string
+	float := func(name string, want *float64, have float64) {
+		if want != nil && math.Abs(*want-have) > 1e-9 {
+			drift = append(drift, name)
+		}
+	}
+	float("linear_vel_ratio", t.LinearVelRatio, s.LinearVelRatio)
+	float("angular_vel_ratio", t.AngularVelRatio, s.AngularVelRatio)
+	float("max_linear_vel", t.MaxLinearVel, s.MaxLinearVel)
+	float("max_angular_vel", t.MaxAngularVel, s.MaxAngularVel)
+	float("radius", t.Radius, s.Radius)
+	if t.Holonomic != nil && *t.Holonomic != s.Holonomic {
+		drift = append(drift, "holonomic")
+	}
+	for _, k := range sortedKeys(t.TopicThrottles) {
+		if s.TopicThrottles[k] != t.TopicThrottles[k] {
+			drift = append(drift, "topic_throttles."+k)
+		}
+	}
+	if t.UseCBOR != nil && *t.UseCBOR != s.UseCBOR {
+		drift = append(drift, "use_cbor")
+	}
+	if t.ExtraTopics != nil && !(len(*t.ExtraTopics) == 0 && len(s.ExtraTopics) == 0) &&
+		!reflect.DeepEqual(*t.ExtraTopics, s.ExtraTopics) {
+		drift = append(drift, "extra_topics")
+	}
+	if t.CmdVel != nil && *t.CmdVel != s.CmdVel {
+		drift = append(drift, "cmd_vel")
+	}
+	return drift
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ApplySettingsTemplate sets the fields t sets. Velocity ratios outside
+// the robot's bounds refuse the whole template; a later setter failing
+// leaves the fields before it applied. The robot then remembers t as its
+// template.
+func (r *Robot) ApplySettingsTemplate(t SettingsTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	cur := r.GetSettings()
+	linear, angular := cur.LinearVelRatio, cur.AngularVelRatio
+	if t.LinearVelRatio != nil {
+		linear = *t.LinearVelRatio
+	}
+	if t.AngularVelRatio != nil {
+		angular = *t.AngularVelRatio
+	}
+	if err := r.SetVelRatios(linear, angular); err != nil {
+		return err
+	}
+
+	if t.MaxLinearVel != nil || t.MaxAngularVel != nil {
+		maxLin, maxAng := cur.MaxLinearVel, cur.MaxAngularVel
+		if t.MaxLinearVel != nil {
+			maxLin = *t.MaxLinearVel
+		}
+		if t.MaxAngularVel != nil {
+			maxAng = *t.MaxAngularVel
+		}
+		r.SetMaxVelocities(maxLin, maxAng)
+	}
+	if t.Radius != nil {
+		r.SetRadius(*t.Radius)
+	}
+	if t.Holonomic != nil {
+		r.SetHolonomic(*t.Holonomic)
+	}
+	if len(t.TopicThrottles) > 0 || t.UseCBOR != nil {
+		r.SetSubscriptionSettings(t.TopicThrottles, t.UseCBOR)
+	}
+	if t.CmdVel != nil {
+		if err := r.SetCmdVelOptions(*t.CmdVel); err != nil {
+			return fmt.Errorf("cmd_vel: %w", err)
+		}
+	}
+	if t.ExtraTopics != nil {
+		if err := r.SetExtraTopics(*t.ExtraTopics); err != nil {
+			return fmt.Errorf("extra_topics: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	r.settingsTemplate = t.Name
+	r.mu.Unlock()
+	return nil
+}
+
+// SettingsTemplateName returns the name of the template last applied to
+// the robot; empty if none was.
+func (r *Robot) SettingsTemplateName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.settingsTemplate
+}
+
+// TemplateApplication is the outcome of applying a template to a robot,
+// kept in its event log (timeline kind "settings").
+type TemplateApplication struct {
+	Template string `json:"template"`
+	Status   string `json:"status"` // applied, queued, skipped or failed
+	Error    string `json:"error,omitempty"`
+	Client   string `json:"client,omitempty"` // requesting address
+}
+
+// RecordTemplateApplication adds a to the robot's event log.
+func (r *Robot) RecordTemplateApplication(a TemplateApplication) {
+	r.mu.Lock()
+	r.recordEventLocked(TimelineSettings, a)
+	r.mu.Unlock()
+}
+
+// TemplateStatus tells which template a robot's settings follow.
+type TemplateStatus struct {
+	Template string   `json:"template,omitempty"` // empty: none applied or matched
+	Applied  bool     `json:"applied"`            // Template was applied, not just matched
+	Matches  bool     `json:"matches"`
+	Drift    []string `json:"drift,omitempty"` // fields changed since Template was applied
+}
+
+// ──────────────────────────── Template store
+
+// SettingsTemplates keeps the settings templates, persisted as JSON in
+// one file.
+type SettingsTemplates struct {
+	path string
+
+	mu        sync.RWMutex
+	templates []SettingsTemplate // by name
+}
+
+// OpenSettingsTemplates loads the templates saved at path; a missing
+// file starts empty.
+func OpenSettingsTemplates(path string) (*SettingsTemplates, error) {
+	st := &SettingsTemplates{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &st.templates); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	st.sort()
+	return st, nil
+}
+
+// List returns the templates sorted by name.
+func (st *SettingsTemplates) List() []SettingsTemplate {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]SettingsTemplate{}, st.templates...)
+}
+
+// Get returns the template called name.
+func (st *SettingsTemplates) Get(name string) (SettingsTemplate, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i := st.indexLocked(name)
+	if i < 0 {
+		return SettingsTemplate{}, false
+	}
+	return st.templates[i], true
+}
+
+// Add stores a new template; a name in use is ErrTemplateExists.
+func (st *SettingsTemplates) Add(t SettingsTemplate) (SettingsTemplate, error) {
+	if err := t.Validate(); err != nil {
+		return SettingsTemplate{}, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.indexLocked(t.Name) >= 0 {
+		return SettingsTemplate{}, fmt.Errorf("%w: %s", ErrTemplateExists, t.Name)
+	}
+	if len(st.templates) >= MaxSettingsTemplates {
+		return SettingsTemplate{}, ErrTooManyTemplates
+	}
+	t.UpdatedAt = time.Now().UTC()
+	prev := st.templates
+	st.templates = append(append([]SettingsTemplate{}, prev...), t)
+	st.sort()
+	if err := st.saveLocked(); err != nil {
+		st.templates = prev
+		return SettingsTemplate{}, err
+	}
+	return t, nil
+}
+
+// Replace replaces the template called name with t, which may rename it.
+func (st *SettingsTemplates) Replace(name string, t SettingsTemplate) (SettingsTemplate, error) {
+	if err := t.Validate(); err != nil {
+		return SettingsTemplate{}, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.indexLocked(name)
+	if i < 0 {
+		return SettingsTemplate{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if t.Name != name && st.indexLocked(t.Name) >= 0 {
+		return SettingsTemplate{}, fmt.Errorf("%w: %s", ErrTemplateExists, t.Name)
+	}
+	t.UpdatedAt = time.Now().UTC()
+	prev := st.templates
+	st.templates = append([]SettingsTemplate{}, prev...)
+	st.templates[i] = t
+	st.sort()
+	if err := st.saveLocked(); err != nil {
+		st.templates = prev
+		return SettingsTemplate{}, err
+	}
+	return t, nil
+}
+
+// Delete removes the template called name.
+func (st *SettingsTemplates) Delete(name string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.indexLocked(name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	prev := st.templates
+	st.templates = append(append([]SettingsTemplate{}, prev[:i]...), prev[i+1:]...)
+	if err := st.saveLocked(); err != nil {
+		st.templates = prev
+		return err
+	}
+	return nil
+}
+
+// Status tells which template r follows: the one last applied to it,
+// matching or drifted, else the first one it matches.
+func (st *SettingsTemplates) Status(r *Robot) TemplateStatus {
+	if name := r.SettingsTemplateName(); name != "" {
+		if t, ok := st.Get(name); ok {
+			drift := r.TemplateDrift(t)
+			return TemplateStatus{Template: name, Applied: true, Matches: len(drift) == 0, Drift: drift}
+		}
+	}
+	for _, t := range st.List() {
+		if len(r.TemplateDrift(t)) == 0 {
+			return TemplateStatus{Template: t.Name, Matches: true}
+		}
+	}
+	return TemplateStatus{}
+}
+
+func (st *SettingsTemplates) indexLocked(name string) int {
+	for i, t := range st.templates {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (st *SettingsTemplates) sort() {
+	sort.Slice(st.templates, func(i, j int) bool { return st.templates[i].Name < st.templates[j].Name })
+}
+
+// saveLocked writes the templates to a temporary file and renames it
+// over the old one.
+func (st *SettingsTemplates) saveLocked() error {
+	data, err := json.MarshalIndent(st.templates, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0755); err != nil {
+		return err
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.path)
+}
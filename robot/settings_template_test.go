@@ -0,0 +1,154 @@
+package robot
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+func fp(v float64) *float64 { return &v }
+func bp(v bool) *bool       { return &v }
+
+func templateNames(ts []SettingsTemplate) []string {
+	var names []string
+	for _, t := range ts {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// TestSettingsTemplateStore adds, renames and deletes templates and
+// reopens the file after each change.
+func TestSettingsTemplateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	st, err := OpenSettingsTemplates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopen := func() *SettingsTemplates {
+		t.Helper()
+		st, err := OpenSettingsTemplates(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	for _, tmpl := range []SettingsTemplate{
+		{Name: "b-small", Radius: fp(0.2)},
+		{Name: "a-large", Radius: fp(0.4), Holonomic: bp(true)},
+	} {
+		if _, err := st.Add(tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.Add(SettingsTemplate{Name: "a-large"}); !errors.Is(err, ErrTemplateExists) {
+		t.Errorf("duplicate add: %v", err)
+	}
+	if _, err := st.Add(SettingsTemplate{Name: "bad", Radius: fp(-1)}); err == nil {
+		t.Error("negative radius added")
+	}
+	got := reopen()
+	if names := templateNames(got.List()); !reflect.DeepEqual(names, []string{"a-large", "b-small"}) {
+		t.Errorf("reopened %v", names)
+	}
+	if tmpl, _ := got.Get("a-large"); *tmpl.Radius != 0.4 || !*tmpl.Holonomic || tmpl.UpdatedAt.IsZero() {
+		t.Errorf("reopened a-large %+v", tmpl)
+	}
+
+	if _, err := st.Replace("b-small", SettingsTemplate{Name: "c-small", Radius: fp(0.25)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Replace("b-small", SettingsTemplate{Name: "b-small"}); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("replace a renamed template: %v", err)
+	}
+	if _, err := st.Replace("c-small", SettingsTemplate{Name: "a-large"}); !errors.Is(err, ErrTemplateExists) {
+		t.Errorf("rename onto another: %v", err)
+	}
+	got = reopen()
+	if names := templateNames(got.List()); !reflect.DeepEqual(names, []string{"a-large", "c-small"}) {
+		t.Errorf("after rename %v", names)
+	}
+	if tmpl, _ := got.Get("c-small"); *tmpl.Radius != 0.25 {
+		t.Errorf("renamed template %+v", tmpl)
+	}
+
+	if err := st.Delete("a-large"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete("a-large"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("second delete: %v", err)
+	}
+	if names := templateNames(reopen().List()); !reflect.DeepEqual(names, []string{"c-small"}) {
+		t.Errorf("after delete %v", names)
+	}
+}
+
+// TestSettingsTemplateApplyAndDrift applies a template, changes the
+// robot's settings after it and reads back the status.
+func TestSettingsTemplateApplyAndDrift(t *testing.T) {
+	st, err := OpenSettingsTemplates(filepath.Join(t.TempDir(), "templates.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := st.Add(SettingsTemplate{
+		Name:           "tb3",
+		LinearVelRatio: fp(0.5),
+		Radius:         fp(0.45),
+		Holonomic:      bp(true),
+		TopicThrottles: map[string]int{rosbridge.TopicOdom: 200},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager()
+	r, _ := m.AddRobot("", "tb3-1", "127.0.0.1", 9)
+	defer r.Close()
+	if s := st.Status(r); s.Template != "" || s.Matches {
+		t.Errorf("before applying: %+v", s)
+	}
+	if drift := r.TemplateDrift(tmpl); len(drift) != 4 {
+		t.Errorf("drift before applying %v", drift)
+	}
+
+	// Ratios out of bounds refuse the whole template
+	before := r.GetSettings()
+	if err := r.ApplySettingsTemplate(SettingsTemplate{Name: "fast", LinearVelRatio: fp(50), Radius: fp(1)}); err == nil {
+		t.Error("out of bounds ratio applied")
+	}
+	if after := r.GetSettings(); after.Radius != before.Radius || r.SettingsTemplateName() != "" {
+		t.Errorf("refused template changed the robot: radius %v, template %q", after.Radius, r.SettingsTemplateName())
+	}
+
+	if err := r.ApplySettingsTemplate(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if name := r.SettingsTemplateName(); name != "tb3" {
+		t.Errorf("template name %q", name)
+	}
+	if s := st.Status(r); !reflect.DeepEqual(s, TemplateStatus{Template: "tb3", Applied: true, Matches: true}) {
+		t.Errorf("after applying: %+v", s)
+	}
+
+	r.SetRadius(0.5)
+	r.SetSubscriptionSettings(map[string]int{rosbridge.TopicOdom: 50}, nil)
+	want := TemplateStatus{Template: "tb3", Applied: true, Drift: []string{"radius", "topic_throttles." + rosbridge.TopicOdom}}
+	if s := st.Status(r); !reflect.DeepEqual(s, want) {
+		t.Errorf("after changes: %+v, want %+v", s, want)
+	}
+
+	// A robot that never had it applied but has its values matches it
+	other, _ := m.AddRobot("", "tb3-2", "127.0.0.1", 10)
+	defer other.Close()
+	other.SetVelRatios(0.5, other.GetSettings().AngularVelRatio)
+	other.SetRadius(0.45)
+	other.SetHolonomic(true)
+	other.SetSubscriptionSettings(map[string]int{rosbridge.TopicOdom: 200}, nil)
+	if s := st.Status(other); !reflect.DeepEqual(s, TemplateStatus{Template: "tb3", Matches: true}) {
+		t.Errorf("matching robot: %+v", s)
+	}
+}
@@ -0,0 +1,93 @@
+package robot
+
+import (
+	"strings"
+
+	"rom_go_app/rosbridge"
+)
+
+// SetSubscriptionSettings updates the robot-side throttle rates (ms by
+// rosbridge topic key, 0 = unthrottled) and the CBOR flag, then
+// re-subscribes so rosbridge applies them immediately. A nil cbor leaves
+// the flag unchanged.
+func (r *Robot) SetSubscriptionSettings(throttles map[string]int, cbor *bool) {
+	r.mu.Lock()
+	for k, v := range throttles {
+		if v < 0 {
+			v = 0
+		}
+		r.topicThrottles[k] = v
+	}
+	if cbor != nil {
+		r.useCBOR = *cbor
+	}
+	useCBOR := r.useCBOR
+	r.mu.Unlock()
+
+	r.Client.SetThrottles(throttles)
+	r.Client.SetCBOR(useCBOR)
+	r.Client.Resubscribe()
+}
+
+// ApplyTopicThrottles applies a change of the configured throttle rates
+// to every robot: topics in next get its rate, topics only in prev go
+// back to rosbridge.DefaultThrottles (unthrottled if not listed there).
+// Rates tuned per robot for other topics are kept.
+func (m *Manager) ApplyTopicThrottles(prev, next map[string]int) {
+	rates := make(map[string]int, len(prev)+len(next))
+	for k := range prev {
+		rates[k] = rosbridge.DefaultThrottles[k]
+	}
+	for k, v := range next {
+		rates[k] = v
+	}
+	if len(rates) == 0 {
+		return
+	}
+	for _, r := range m.GetAllRobots() {
+		r.SetSubscriptionSettings(rates, nil)
+	}
+}
+
+// SetSplitConnections moves subscriptions to a separate rosbridge
+// connection (or back). A connected robot reconnects to apply it; a
+// reverse robot has no data plane.
+func (r *Robot) SetSplitConnections(enabled bool) {
+	if r.Client.SplitEnabled() == enabled {
+		return
+	}
+	r.Client.SetSplit(enabled)
+	if r.Client.IsConnected() && !r.Client.ReverseEnabled() {
+		r.Client.Disconnect()
+		go r.Client.Connect()
+	}
+}
+
+// SetSharedConnection makes the robot share one rosbridge connection with
+// the other robots of its server that do (or stop). A connected robot
+// reconnects to apply it; a reverse robot isn't dialed, so it doesn't
+// apply.
+func (r *Robot) SetSharedConnection(enabled bool) {
+	if r.Client.SharedEnabled() == enabled {
+		return
+	}
+	r.Client.SetShared(enabled)
+	if r.Client.IsConnected() && !r.Client.ReverseEnabled() {
+		r.Client.Disconnect()
+		go r.Client.Connect()
+	}
+}
+
+// SameNamespace reports whether two robot namespaces are the same,
+// ignoring surrounding slashes.
+func SameNamespace(a, b string) bool {
+	return strings.Trim(a, "/") == strings.Trim(b, "/")
+}
+
+func copyThrottles(m map[string]int) map[string]int {
+	out := make(map[string]int, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
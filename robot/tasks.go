@@ -0,0 +1,436 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── which_tasks queue
+//
+// The robot's which_tasks handler executes one task at a time, so every
+// task request goes through a per-robot FIFO worker instead of hitting
+// the service concurrently.
+
+// ErrRobotBusy is returned when the task queue is full.
+var ErrRobotBusy = errors.New("robot busy: task queue full")
+
+// ErrTaskFlushed is reported for tasks dropped by a poweroff/reboot flush.
+var ErrTaskFlushed = errors.New("task cancelled: queue flushed")
+
+// ErrTaskAbandoned is reported for waiting tasks whose caller gave up.
+var ErrTaskAbandoned = errors.New("task cancelled: caller timed out")
+
+// ErrTaskQueueStopped is returned by Enqueue after Stop.
+var ErrTaskQueueStopped = errors.New("task queue stopped")
+
+// Task status values.
+const (
+	TaskQueued    = "queued"
+	TaskRunning   = "running"
+	TaskDone      = "done"
+	TaskFailed    = "failed"
+	TaskCancelled = "cancelled"
+)
+
+const (
+	maxQueuedTasks  = 8
+	maxTaskHistory  = 50
+	taskCallTimeout = 30 * time.Second // the which_tasks call's own timeout
+	taskWaitMargin  = 5 * time.Second  // Run's wait beyond the calls it covers
+)
+
+// Task is a single queued which_tasks request.
+type Task struct {
+	ID         string                       `json:"id"`
+	Name       string                       `json:"task_name"`
+	Status     string                       `json:"status"`
+	Result     *rosbridge.WhichTaskResponse `json:"result,omitempty"`
+	Error      string                       `json:"error,omitempty"`
+	EnqueuedAt time.Time                    `json:"enqueued_at"`
+	StartedAt  *time.Time                   `json:"started_at,omitempty"`
+	FinishedAt *time.Time                   `json:"finished_at,omitempty"`
+
+	settings string
+	err      error
+	done     chan struct{}
+}
+
+// TaskQueue executes which_tasks requests sequentially.
+type TaskQueue struct {
+	mu      sync.Mutex
+	pending []*Task
+	running *Task
+	history map[string]*Task
+	order   []string
+	nextID  int
+	stopped bool
+
+	// callTimeout is Run's wait per task ahead and including its own;
+	// waitMargin is added once, so the wait outlasts the last call's own
+	// timeout and reports how it ended.
+	callTimeout time.Duration
+	waitMargin  time.Duration
+
+	exec   func(name, settings string) (*rosbridge.WhichTaskResponse, error)
+	wake   chan struct{}
+	stopCh chan struct{}
+	once   sync.Once
+}
+
+// NewTaskQueue creates a queue and starts its worker.
+func NewTaskQueue(exec func(name, settings string) (*rosbridge.WhichTaskResponse, error)) *TaskQueue {
+	q := &TaskQueue{
+		history:     make(map[string]*Task),
+		callTimeout: taskCallTimeout,
+		waitMargin:  taskWaitMargin,
+		exec:        exec,
+		wake:        make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+	}
+	go q.worker()
+	return q
+}
+
+// Enqueue adds a task and returns it with its queue position
+// (0 = next to run).
+func (q *TaskQueue) Enqueue(name, settings string) (*Task, int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		return nil, 0, ErrTaskQueueStopped
+	}
+	if len(q.pending) >= maxQueuedTasks {
+		return nil, 0, ErrRobotBusy
+	}
+
+	q.nextID++
+	t := &Task{
+		ID:         fmt.Sprintf("task_%d", q.nextID),
+		Name:       name,
+		Status:     TaskQueued,
+		EnqueuedAt: time.Now(),
+		settings:   settings,
+		done:       make(chan struct{}),
+	}
+	q.pending = append(q.pending, t)
+	q.remember(t)
+
+	pos := len(q.pending) - 1
+	if q.running != nil {
+		pos++
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return t, pos, nil
+}
+
+// Run enqueues a task and waits for it. The wait timeout grows with the
+// number of tasks ahead of it in the queue, plus a margin over the call
+// timeout so a call that times out itself reports its own error; a task
+// still waiting when it expires is cancelled, so it won't run for a
+// caller that is gone.
+func (q *TaskQueue) Run(name, settings string) (*rosbridge.WhichTaskResponse, error) {
+	t, pos, err := q.Enqueue(name, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(pos+1)*q.callTimeout + q.waitMargin
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-t.done:
+		return t.Result, t.err
+	case <-timer.C:
+	}
+	if q.cancel(t, ErrTaskAbandoned) {
+		return nil, fmt.Errorf("task '%s' timed out after %s (queue position %d); cancelled", name, timeout, pos)
+	}
+	return nil, fmt.Errorf("task '%s' timed out after %s (queue position %d); still running", name, timeout, pos)
+}
+
+// cancel removes t from the queue and finishes it with err. It reports
+// false when t is no longer waiting.
+func (q *TaskQueue) cancel(t *Task, err error) bool {
+	q.mu.Lock()
+	i := 0
+	for i < len(q.pending) && q.pending[i] != t {
+		i++
+	}
+	if i == len(q.pending) {
+		q.mu.Unlock()
+		return false
+	}
+	q.pending = append(q.pending[:i:i], q.pending[i+1:]...)
+	q.mu.Unlock()
+
+	q.finish(t, nil, err)
+	return true
+}
+
+// Flush cancels every task still waiting in the queue. The running task
+// (if any) is left to finish.
+func (q *TaskQueue) Flush() int {
+	q.mu.Lock()
+	flushed := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+
+	for _, t := range flushed {
+		q.finish(t, nil, ErrTaskFlushed)
+	}
+	return len(flushed)
+}
+
+// Get returns a copy of a known task by ID.
+func (q *TaskQueue) Get(id string) (Task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.history[id]
+	if !ok {
+		return Task{}, false
+	}
+	return *t, true
+}
+
+// Position returns how many tasks are ahead of the given one, or -1 if
+// it is no longer waiting.
+func (q *TaskQueue) Position(id string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, t := range q.pending {
+		if t.ID == id {
+			if q.running != nil {
+				return i + 1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// Depth returns the number of waiting tasks.
+func (q *TaskQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Stop terminates the worker and cancels pending tasks; later Enqueue
+// calls fail with ErrTaskQueueStopped.
+func (q *TaskQueue) Stop() {
+	q.once.Do(func() {
+		q.mu.Lock()
+		q.stopped = true
+		q.mu.Unlock()
+		close(q.stopCh)
+		q.Flush()
+	})
+}
+
+func (q *TaskQueue) worker() {
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case <-q.wake:
+		}
+
+		for {
+			q.mu.Lock()
+			if len(q.pending) == 0 {
+				q.mu.Unlock()
+				break
+			}
+			t := q.pending[0]
+			q.pending = q.pending[1:]
+			q.running = t
+			t.Status = TaskRunning
+			now := time.Now()
+			t.StartedAt = &now
+			q.mu.Unlock()
+
+			resp, err := q.exec(t.Name, t.settings)
+			q.finish(t, resp, err)
+
+			q.mu.Lock()
+			q.running = nil
+			q.mu.Unlock()
+
+			select {
+			case <-q.stopCh:
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (q *TaskQueue) finish(t *Task, resp *rosbridge.WhichTaskResponse, err error) {
+	q.mu.Lock()
+	t.Result = resp
+	t.err = err
+	now := time.Now()
+	t.FinishedAt = &now
+	switch {
+	case errors.Is(err, ErrTaskFlushed), errors.Is(err, ErrTaskAbandoned):
+		t.Status = TaskCancelled
+		t.Error = err.Error()
+	case err != nil:
+		t.Status = TaskFailed
+		t.Error = err.Error()
+	default:
+		t.Status = TaskDone
+	}
+	q.mu.Unlock()
+	close(t.done)
+}
+
+// remember records a task for later status queries. Caller holds q.mu.
+func (q *TaskQueue) remember(t *Task) {
+	q.history[t.ID] = t
+	q.order = append(q.order, t.ID)
+	for len(q.order) > maxTaskHistory {
+		old := q.history[q.order[0]]
+		if old != nil && (old.Status == TaskQueued || old.Status == TaskRunning) {
+			break
+		}
+		delete(q.history, q.order[0])
+		q.order = q.order[1:]
+	}
+}
+
+// ──────────────────────────── Robot task helpers
+
+// RequestTask runs a which_tasks request through the robot's queue and
+// waits for the result.
+func (r *Robot) RequestTask(name, settings string) (*rosbridge.WhichTaskResponse, error) {
+	if err := r.Require(rosbridge.CapTasks); err != nil {
+		return nil, err
+	}
+	return r.tasks.Run(name, settings)
+}
+
+// RequestTaskAsync queues a which_tasks request and returns immediately
+// with the task ID and its queue position.
+func (r *Robot) RequestTaskAsync(name, settings string) (string, int, error) {
+	if err := r.Require(rosbridge.CapTasks); err != nil {
+		return "", 0, err
+	}
+	t, pos, err := r.tasks.Enqueue(name, settings)
+	if err != nil {
+		return "", 0, err
+	}
+	return t.ID, pos, nil
+}
+
+// GetTask returns the state of a queued or recently finished task.
+func (r *Robot) GetTask(id string) (Task, bool) {
+	return r.tasks.Get(id)
+}
+
+// TaskPosition returns the queue position of a waiting task, or -1.
+func (r *Robot) TaskPosition(id string) int {
+	return r.tasks.Position(id)
+}
+
+// RequestSettingsSave queues a settings_save task.
+func (r *Robot) RequestSettingsSave(yaml string) (*rosbridge.WhichTaskResponse, error) {
+	return r.RequestTask("settings_save", yaml)
+}
+
+// SendVoiceCommand queues a voice_command task.
+func (r *Robot) SendVoiceCommand(cmd string) (*rosbridge.WhichTaskResponse, error) {
+	return r.RequestTask("voice_command", cmd)
+}
+
+// ──────────────────────────── Task catalog
+//
+// The tasks a robot accepts are discovered on every connect (see
+// rosbridge.ListTasks). Robots that can't list them get the configured
+// static list instead, so the UI always has names to offer.
+
+// Task catalog sources.
+const (
+	TaskSourceRobot  = "robot"
+	TaskSourceStatic = "static"
+)
+
+// TaskCatalog is the robot's known tasks and where they came from.
+type TaskCatalog struct {
+	Tasks     []rosbridge.TaskInfo `json:"tasks"`
+	Source    string               `json:"source"`               // robot or static
+	UpdatedAt *time.Time           `json:"updated_at,omitempty"` // last discovery from the robot
+	Error     string               `json:"error,omitempty"`      // why the static list is used
+}
+
+// SetTaskDiscovery sets the which_tasks request that lists tasks (empty
+// for the default) and the fallback list for robots that don't answer
+// it.
+func (r *Robot) SetTaskDiscovery(request string, static []rosbridge.TaskInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.taskDiscoveryRequest = request
+	r.staticTasks = append([]rosbridge.TaskInfo(nil), static...)
+}
+
+// RefreshTaskCatalog asks the robot for its tasks through the task
+// queue. On failure the previously discovered list, or else the static
+// one, stays in use and the error is recorded.
+func (r *Robot) RefreshTaskCatalog() (TaskCatalog, error) {
+	r.mu.RLock()
+	request := r.taskDiscoveryRequest
+	r.mu.RUnlock()
+	if request == "" {
+		request = rosbridge.DefaultTaskDiscoveryRequest
+	}
+
+	resp, err := r.RequestTask(request, "")
+	var tasks []rosbridge.TaskInfo
+	if err == nil {
+		tasks, err = rosbridge.ParseTaskList(resp.ResponseSettings)
+	}
+
+	r.mu.Lock()
+	if err != nil {
+		r.taskCatalogErr = err.Error()
+	} else {
+		r.taskCatalog = tasks
+		r.taskCatalogAt = time.Now()
+		r.taskCatalogErr = ""
+	}
+	r.mu.Unlock()
+	if err != nil {
+		err = fmt.Errorf("task discovery: %w", err)
+	}
+	return r.GetTaskCatalog(), err
+}
+
+// GetTaskCatalog returns the discovered tasks, or the static list if the
+// robot never listed any.
+func (r *Robot) GetTaskCatalog() TaskCatalog {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.taskCatalog != nil {
+		at := r.taskCatalogAt
+		return TaskCatalog{
+			Tasks:     append([]rosbridge.TaskInfo(nil), r.taskCatalog...),
+			Source:    TaskSourceRobot,
+			UpdatedAt: &at,
+			Error:     r.taskCatalogErr,
+		}
+	}
+	return TaskCatalog{
+		Tasks:  append([]rosbridge.TaskInfo{}, r.staticTasks...),
+		Source: TaskSourceStatic,
+		Error:  r.taskCatalogErr,
+	}
+}
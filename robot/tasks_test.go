@@ -0,0 +1,175 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// blockingExec runs tasks only when released and reports each start.
+type blockingExec struct {
+	started chan string
+	release chan struct{}
+}
+
+func newBlockingExec() *blockingExec {
+	return &blockingExec{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+func (b *blockingExec) exec(name, settings string) (*rosbridge.WhichTaskResponse, error) {
+	b.started <- name
+	<-b.release
+	return &rosbridge.WhichTaskResponse{TaskName: name}, nil
+}
+
+func TestTaskQueueRunTimeoutCancelsWaitingTask(t *testing.T) {
+	b := newBlockingExec()
+	q := NewTaskQueue(b.exec)
+	defer q.Stop()
+	q.callTimeout, q.waitMargin = 20*time.Millisecond, 10*time.Millisecond
+
+	first, _, err := q.Enqueue("first", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-b.started
+
+	_, err = q.Run("second", "")
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Run error = %v, want timeout with cancel", err)
+	}
+	if d := q.Depth(); d != 0 {
+		t.Fatalf("depth after timeout = %d, want 0", d)
+	}
+	second, ok := q.Get("task_2")
+	if !ok || second.Status != TaskCancelled || second.Error != ErrTaskAbandoned.Error() {
+		t.Fatalf("second = %+v, want cancelled", second)
+	}
+
+	close(b.release)
+	<-first.done
+	select {
+	case name := <-b.started:
+		t.Fatalf("abandoned task %q ran", name)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestTaskQueueRunTimeoutWhileRunning(t *testing.T) {
+	b := newBlockingExec()
+	q := NewTaskQueue(b.exec)
+	defer q.Stop()
+	q.callTimeout, q.waitMargin = 20*time.Millisecond, 10*time.Millisecond
+
+	_, err := q.Run("slow", "")
+	if err == nil || !strings.Contains(err.Error(), "still running") {
+		t.Fatalf("Run error = %v, want timeout while running", err)
+	}
+	close(b.release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		task, _ := q.Get("task_1")
+		if task.Status == TaskDone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %q, want the running task to finish", task.Status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestTaskQueueRunWaitMargin has the call take longer than the call
+// timeout but not the margin: Run reports the call's own outcome.
+func TestTaskQueueRunWaitMargin(t *testing.T) {
+	q := NewTaskQueue(func(name, _ string) (*rosbridge.WhichTaskResponse, error) {
+		time.Sleep(30 * time.Millisecond)
+		return nil, errors.New("service call timed out")
+	})
+	defer q.Stop()
+	q.callTimeout, q.waitMargin = 20*time.Millisecond, time.Second
+
+	if _, err := q.Run("slow", ""); err == nil || err.Error() != "service call timed out" {
+		t.Errorf("Run error = %v, want the call's own", err)
+	}
+}
+
+// TestTaskTimesOmitted checks a waiting task has no start or finish time
+// in its JSON, and a finished one has both.
+func TestTaskTimesOmitted(t *testing.T) {
+	b := newBlockingExec()
+	q := NewTaskQueue(b.exec)
+	defer q.Stop()
+
+	q.Enqueue("a", "")
+	<-b.started
+	q.Enqueue("b", "")
+	waiting, _ := q.Get("task_2")
+	data, err := json.Marshal(waiting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Contains(s, "started_at") || strings.Contains(s, "finished_at") {
+		t.Errorf("waiting task %s", s)
+	}
+
+	close(b.release)
+	done, _ := q.Run("c", "")
+	if done == nil {
+		t.Fatal("no result")
+	}
+	if task, _ := q.Get("task_3"); task.StartedAt == nil || task.FinishedAt == nil || task.FinishedAt.Before(*task.StartedAt) {
+		t.Errorf("finished task times %v, %v", task.StartedAt, task.FinishedAt)
+	}
+}
+
+func TestTaskQueueOrderAndFull(t *testing.T) {
+	b := newBlockingExec()
+	q := NewTaskQueue(b.exec)
+	defer q.Stop()
+
+	if _, pos, _ := q.Enqueue("a", ""); pos != 0 {
+		t.Fatalf("first position = %d", pos)
+	}
+	<-b.started
+	for i := 0; i < maxQueuedTasks; i++ {
+		_, pos, err := q.Enqueue("b", "")
+		if err != nil || pos != i+1 {
+			t.Fatalf("enqueue %d: pos %d err %v", i, pos, err)
+		}
+	}
+	if _, _, err := q.Enqueue("c", ""); !errors.Is(err, ErrRobotBusy) {
+		t.Fatalf("full queue error = %v, want ErrRobotBusy", err)
+	}
+	if p := q.Position("task_3"); p != 2 {
+		t.Errorf("position = %d, want 2", p)
+	}
+	if n := q.Flush(); n != maxQueuedTasks {
+		t.Errorf("flushed %d, want %d", n, maxQueuedTasks)
+	}
+	if task, _ := q.Get("task_2"); task.Status != TaskCancelled {
+		t.Errorf("flushed status = %q", task.Status)
+	}
+	close(b.release)
+}
+
+func TestTaskQueueEnqueueAfterStop(t *testing.T) {
+	q := NewTaskQueue(func(string, string) (*rosbridge.WhichTaskResponse, error) {
+		return &rosbridge.WhichTaskResponse{}, nil
+	})
+	if _, err := q.Run("ok", ""); err != nil {
+		t.Fatal(err)
+	}
+	q.Stop()
+	q.Stop()
+	if _, _, err := q.Enqueue("late", ""); !errors.Is(err, ErrTaskQueueStopped) {
+		t.Fatalf("Enqueue after Stop = %v, want ErrTaskQueueStopped", err)
+	}
+	if _, err := q.Run("late", ""); !errors.Is(err, ErrTaskQueueStopped) {
+		t.Fatalf("Run after Stop = %v, want ErrTaskQueueStopped", err)
+	}
+}
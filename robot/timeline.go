@@ -0,0 +1,428 @@
+package robot
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Fleet timeline
+//
+// Reconstructing what several robots did around one moment means lining
+// up their records: Timeline merges, for a time range, the notices,
+// incident markers, mode changes, navigation status transitions, e-stop
+// changes and settings template applications of every robot, and
+// optionally their pose and velocity samples thinned to one per
+// SampleMs, into one stream ordered by server time. Entries at the same
+// millisecond are ordered by robot and kind.
+//
+// Mode, navigation, e-stop and settings changes are kept per robot in an
+// event log of up to maxTimelineEvents, and poses at 1 Hz for
+// SecondHistoryWindow; both, like markers and notices, are stamped by
+// timelineNow, which never goes backwards, so the stream stays ordered
+// when the wall clock steps back. Every store is sorted by time and
+// answers a range by binary search, and each contributes at most the
+// entries a page can use. Pages continue from a TimelineCursor: the time
+// of the last entry and how many entries at that time were already
+// returned.
+
+// Timeline entry kinds.
+const (
+	TimelineEvent    = "event" // a notice
+	TimelineMarker   = "marker"
+	TimelineMode     = "mode"
+	TimelineNav      = "nav_status"
+	TimelineEStop    = "estop"
+	TimelineSettings = "settings" // a settings template applied
+	TimelinePose     = "pose"
+	TimelineVelocity = "velocity"
+)
+
+// TimelineKinds lists every kind; the sampled ones last.
+var TimelineKinds = []string{TimelineEvent, TimelineMarker, TimelineMode, TimelineNav, TimelineEStop, TimelineSettings, TimelinePose, TimelineVelocity}
+
+// Timeline page defaults and limits.
+const (
+	DefaultTimelineLimit  = 500
+	MaxTimelineLimit      = 5000
+	DefaultTimelineSample = time.Second
+	MinTimelineSample     = 100 * time.Millisecond
+	DefaultTimelineWindow = 10 * time.Minute
+)
+
+// Timeline stores.
+const (
+	maxTimelineEvents  = 2000
+	poseSampleInterval = time.Second
+	// A TF pose is kept only while map_bfp is older than this.
+	timelinePoseFreshness = 2 * time.Second
+)
+
+// Velocity series names and the cursor separator.
+const (
+	timelineVelocityCmd  = "commanded"
+	timelineVelocityOdom = "measured"
+	timelineCursorSep    = "."
+)
+
+// timelineClock is the last timelineNow, unix ms.
+var timelineClock atomic.Int64
+
+// timelineNow returns the current unix ms, never less than a previous
+// call's.
+func timelineNow() int64 {
+	for {
+		last := timelineClock.Load()
+		now := max(time.Now().UnixMilli(), last)
+		if timelineClock.CompareAndSwap(last, now) {
+			return now
+		}
+	}
+}
+
+// TimelineEntry is one record of the merged stream.
+type TimelineEntry struct {
+	Time    int64       `json:"t"` // unix ms, server clock
+	RobotID string      `json:"robot_id,omitempty"`
+	Kind    string      `json:"kind"`
+	Data    interface{} `json:"data"`
+}
+
+// NavTransition is a change of a robot's navigation goal or its status.
+type NavTransition struct {
+	rosbridge.NavStatus
+	PreviousState string `json:"previous_state,omitempty"`
+}
+
+// EStopChange is the software e-stop being engaged or released.
+type EStopChange struct {
+	Engaged bool `json:"engaged"`
+}
+
+// PoseSample is the robot's map pose at a time.
+type PoseSample struct {
+	Time   int64   `json:"t"` // unix ms
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Theta  float64 `json:"theta"`
+	Source string  `json:"source"` // map_bfp or tf
+}
+
+// TimelineVelocitySample is a velocity sample of one series.
+type TimelineVelocitySample struct {
+	Series string `json:"series"` // commanded or measured
+	VelocitySample
+}
+
+// timelineEvent is an entry of a robot's event log.
+type timelineEvent struct {
+	Time int64
+	Kind string
+	Data interface{}
+}
+
+// TimelineCursor is where a page continues: after the first Skip entries
+// at Time.
+type TimelineCursor struct {
+	Time int64
+	Skip int
+}
+
+// String encodes c for the next request; empty for the zero cursor.
+func (c TimelineCursor) String() string {
+	if c == (TimelineCursor{}) {
+		return ""
+	}
+	return strconv.FormatInt(c.Time, 10) + timelineCursorSep + strconv.Itoa(c.Skip)
+}
+
+// ParseTimelineCursor decodes a cursor from TimelineCursor.String.
+func ParseTimelineCursor(s string) (TimelineCursor, error) {
+	if s == "" {
+		return TimelineCursor{}, nil
+	}
+	ts, skip, ok := strings.Cut(s, timelineCursorSep)
+	t, err1 := strconv.ParseInt(ts, 10, 64)
+	n, err2 := strconv.Atoi(skip)
+	if !ok || err1 != nil || err2 != nil || t < 0 || n < 0 {
+		return TimelineCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return TimelineCursor{Time: t, Skip: n}, nil
+}
+
+// TimelineQuery selects a page of the timeline.
+type TimelineQuery struct {
+	From, To int64           // unix ms, inclusive
+	Kinds    map[string]bool // kinds included
+	RobotIDs []string        // robots included; empty: all
+	SampleMs int64           // pose and velocity spacing
+	Limit    int
+	Cursor   TimelineCursor
+}
+
+// TimelinePage is a page of the merged stream.
+type TimelinePage struct {
+	From    int64           `json:"from"`
+	To      int64           `json:"to"`
+	Entries []TimelineEntry `json:"entries"`
+	// Next continues after this page; empty when the range is exhausted.
+	Next string `json:"next,omitempty"`
+}
+
+// Timeline returns a page of the fleet's merged timeline.
+func (m *Manager) Timeline(q TimelineQuery) TimelinePage {
+	if q.Limit <= 0 {
+		q.Limit = DefaultTimelineLimit
+	}
+	if q.SampleMs <= 0 {
+		q.SampleMs = DefaultTimelineSample.Milliseconds()
+	}
+	from := q.From
+	if q.Cursor.Time > from {
+		from = q.Cursor.Time
+	}
+	skipped := 0 // entries at from already returned
+	if q.Cursor.Time == from {
+		skipped = q.Cursor.Skip
+	}
+	skip := skipped
+	// No stream can give more than the page uses, plus one to tell
+	// whether there is more
+	n := skipped + q.Limit + 1
+
+	robots := m.GetAllRobots()
+	sort.Slice(robots, func(i, j int) bool { return robots[i].ID < robots[j].ID })
+	wanted := map[string]bool{}
+	for _, id := range q.RobotIDs {
+		wanted[id] = true
+	}
+	var streams [][]TimelineEntry
+	for _, r := range robots {
+		if len(wanted) == 0 || wanted[r.ID] {
+			streams = append(streams, r.timelineStreams(q, from, n)...)
+		}
+	}
+	if q.Kinds[TimelineEvent] {
+		streams = append(streams, m.noticeStream(wanted, from, q.To, n))
+	}
+
+	merged := MergeTimeline(streams, n)
+	page := TimelinePage{From: q.From, To: q.To, Entries: []TimelineEntry{}}
+	for len(merged) > 0 && skip > 0 && merged[0].Time == from {
+		merged = merged[1:]
+		skip--
+	}
+	if len(merged) > q.Limit {
+		page.Entries = merged[:q.Limit]
+		last := page.Entries[len(page.Entries)-1].Time
+		next := TimelineCursor{Time: last}
+		if last == from {
+			next.Skip = skipped
+		}
+		for _, e := range page.Entries {
+			if e.Time == last {
+				next.Skip++
+			}
+		}
+		page.Next = next.String()
+	} else {
+		page.Entries = append(page.Entries, merged...)
+	}
+	return page
+}
+
+// MergeTimeline merges streams, each sorted by time, into one of at most
+// limit entries (limit <= 0: all). Entries at the same time are ordered
+// by robot, then kind, then stream.
+func MergeTimeline(streams [][]TimelineEntry, limit int) []TimelineEntry {
+	h := &timelineHeap{}
+	total := 0
+	for i, s := range streams {
+		if len(s) > 0 {
+			h.items = append(h.items, timelineCursorItem{stream: i, entries: s})
+			total += len(s)
+		}
+	}
+	if limit <= 0 || limit > total {
+		limit = total
+	}
+	heap.Init(h)
+	out := make([]TimelineEntry, 0, limit)
+	for len(out) < limit && h.Len() > 0 {
+		it := &h.items[0]
+		out = append(out, it.entries[0])
+		it.entries = it.entries[1:]
+		if len(it.entries) == 0 {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return out
+}
+
+// timelineCursorItem is a stream's remaining entries in the merge.
+type timelineCursorItem struct {
+	stream  int
+	entries []TimelineEntry
+}
+
+type timelineHeap struct{ items []timelineCursorItem }
+
+func (h *timelineHeap) Len() int { return len(h.items) }
+func (h *timelineHeap) Less(i, j int) bool {
+	a, b := h.items[i].entries[0], h.items[j].entries[0]
+	switch {
+	case a.Time != b.Time:
+		return a.Time < b.Time
+	case a.RobotID != b.RobotID:
+		return a.RobotID < b.RobotID
+	case a.Kind != b.Kind:
+		return a.Kind < b.Kind
+	}
+	return h.items[i].stream < h.items[j].stream
+}
+func (h *timelineHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *timelineHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(timelineCursorItem))
+}
+func (h *timelineHeap) Pop() interface{} {
+	it := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return it
+}
+
+// timelineStreams returns the robot's entries of the kinds queried with
+// from <= t <= q.To, one sorted stream per kind (and velocity series),
+// each of at most n entries.
+func (r *Robot) timelineStreams(q TimelineQuery, from int64, n int) [][]TimelineEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var streams [][]TimelineEntry
+	add := func(kind string, data interface{}, t int64, s *[]TimelineEntry) {
+		*s = append(*s, TimelineEntry{Time: t, RobotID: r.ID, Kind: kind, Data: data})
+	}
+
+	for _, kind := range []string{TimelineMode, TimelineNav, TimelineEStop, TimelineSettings} {
+		if !q.Kinds[kind] {
+			continue
+		}
+		var s []TimelineEntry
+		for _, e := range eventsBetween(r.events, from, q.To) {
+			if e.Kind == kind && len(s) < n {
+				add(kind, e.Data, e.Time, &s)
+			}
+		}
+		streams = append(streams, s)
+	}
+	if q.Kinds[TimelineMarker] {
+		var s []TimelineEntry
+		for _, mk := range markersBetween(r.markers, from, q.To) {
+			if len(s) == n {
+				break
+			}
+			add(TimelineMarker, mk, mk.Time, &s)
+		}
+		streams = append(streams, s)
+	}
+	if q.Kinds[TimelinePose] {
+		var s []TimelineEntry
+		for _, p := range thinSamples(posesBetween(r.poses, from, q.To), func(p PoseSample) int64 { return p.Time }, q.SampleMs, n) {
+			add(TimelinePose, p, p.Time, &s)
+		}
+		streams = append(streams, s)
+	}
+	if q.Kinds[TimelineVelocity] {
+		resolution := autoResolution(time.Now(), from)
+		for _, v := range []struct {
+			name   string
+			series *velocitySeries
+		}{{timelineVelocityCmd, &r.commanded}, {timelineVelocityOdom, &r.measured}} {
+			var s []TimelineEntry
+			samples := v.series.tier(resolution, from-1, q.To)
+			for _, vs := range thinSamples(samples, func(s VelocitySample) int64 { return s.Time }, q.SampleMs, n) {
+				add(TimelineVelocity, TimelineVelocitySample{Series: v.name, VelocitySample: vs}, vs.Time, &s)
+			}
+			streams = append(streams, s)
+		}
+	}
+	return streams
+}
+
+// noticeStream returns the notices with from <= t <= to of the robots
+// wanted (all when empty, with notices of no robot), at most n.
+func (m *Manager) noticeStream(wanted map[string]bool, from, to int64, n int) []TimelineEntry {
+	var s []TimelineEntry
+	for _, no := range m.NoticesBetween(from, to) {
+		if len(s) == n {
+			break
+		}
+		if len(wanted) > 0 && !wanted[no.RobotID] {
+			continue
+		}
+		s = append(s, TimelineEntry{Time: no.Time.UnixMilli(), RobotID: no.RobotID, Kind: TimelineEvent, Data: no})
+	}
+	return s
+}
+
+// thinSamples keeps the first sample of each step of stepMs, up to n.
+func thinSamples[S any](samples []S, at func(S) int64, stepMs int64, n int) []S {
+	out := make([]S, 0, min(len(samples), n))
+	bucket := int64(-1)
+	for _, s := range samples {
+		if len(out) == n {
+			break
+		}
+		if b := at(s) / stepMs; b != bucket {
+			bucket = b
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// recordEventLocked appends an event to the robot's log. Caller holds
+// r.mu for writing.
+func (r *Robot) recordEventLocked(kind string, data interface{}) {
+	r.events = append(r.events, timelineEvent{Time: timelineNow(), Kind: kind, Data: data})
+	if n := len(r.events); n > maxTimelineEvents {
+		r.events = append([]timelineEvent(nil), r.events[n-maxTimelineEvents:]...)
+	}
+}
+
+// recordPoseLocked keeps p as a pose sample unless one was kept within
+// poseSampleInterval, and drops those older than SecondHistoryWindow.
+// Caller holds r.mu for writing.
+func (r *Robot) recordPoseLocked(p rosbridge.Pose2D, source string) {
+	now := time.Now().UnixMilli()
+	if n := len(r.poses); n > 0 && now-r.poses[n-1].Time < poseSampleInterval.Milliseconds() {
+		return
+	}
+	r.poses = append(r.poses, PoseSample{Time: timelineNow(), X: p.X, Y: p.Y, Theta: p.Theta, Source: source})
+	cutoff := now - SecondHistoryWindow.Milliseconds()
+	if i := sort.Search(len(r.poses), func(i int) bool { return r.poses[i].Time > cutoff }); i > 0 {
+		r.poses = append([]PoseSample(nil), r.poses[i:]...)
+	}
+}
+
+// eventsBetween returns the events with from <= t <= to; events are
+// sorted by time.
+func eventsBetween(es []timelineEvent, from, to int64) []timelineEvent {
+	lo := sort.Search(len(es), func(i int) bool { return es[i].Time >= from })
+	hi := sort.Search(len(es), func(i int) bool { return es[i].Time > to })
+	return es[lo:max(lo, hi)]
+}
+
+// posesBetween returns the poses with from <= t <= to; poses are sorted
+// by time.
+func posesBetween(ps []PoseSample, from, to int64) []PoseSample {
+	lo := sort.Search(len(ps), func(i int) bool { return ps[i].Time >= from })
+	hi := sort.Search(len(ps), func(i int) bool { return ps[i].Time > to })
+	return ps[lo:max(lo, hi)]
+}
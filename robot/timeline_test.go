@@ -0,0 +1,177 @@
+package robot
+
+import (
+	"fmt"
+	"testing"
+)
+
+// entry is a timeline entry whose data names it.
+func entry(t int64, robotID, kind, name string) TimelineEntry {
+	return TimelineEntry{Time: t, RobotID: robotID, Kind: kind, Data: name}
+}
+
+func TestMergeTimeline(t *testing.T) {
+	streams := [][]TimelineEntry{
+		{entry(10, "b", TimelineMode, "b-mode-10"), entry(30, "b", TimelineMode, "b-mode-30")},
+		{entry(5, "a", TimelineNav, "a-nav-5"), entry(20, "a", TimelineNav, "a-nav-20"), entry(30, "a", TimelineNav, "a-nav-30")},
+		nil,
+		{entry(20, "a", TimelineEStop, "a-estop-20"), entry(30, "b", TimelineEStop, "b-estop-30")},
+		{entry(30, "a", TimelineNav, "a-nav-30-again")},
+	}
+	// Equal times: by robot, then kind, then stream
+	want := []string{"a-nav-5", "b-mode-10", "a-estop-20", "a-nav-20", "a-nav-30", "a-nav-30-again", "b-estop-30", "b-mode-30"}
+	got := timelineNames(MergeTimeline(streams, 0))
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("merged %v\nwant %v", got, want)
+	}
+	if got := timelineNames(MergeTimeline(streams, 3)); fmt.Sprint(got) != fmt.Sprint(want[:3]) {
+		t.Errorf("limited %v", got)
+	}
+	if got := MergeTimeline(nil, 5); len(got) != 0 {
+		t.Errorf("no streams: %v", got)
+	}
+	// The streams themselves are left as they were
+	if len(streams[1]) != 3 || streams[1][0].Data != "a-nav-5" {
+		t.Errorf("stream consumed: %v", streams[1])
+	}
+}
+
+func TestTimelineCursor(t *testing.T) {
+	c := TimelineCursor{Time: 1700000000123, Skip: 4}
+	if got, err := ParseTimelineCursor(c.String()); err != nil || got != c {
+		t.Errorf("round trip %q: %+v, %v", c.String(), got, err)
+	}
+	if (TimelineCursor{}).String() != "" {
+		t.Error("zero cursor encoded")
+	}
+	if got, err := ParseTimelineCursor(""); err != nil || got != (TimelineCursor{}) {
+		t.Errorf("empty: %+v, %v", got, err)
+	}
+	for _, bad := range []string{"12", "x.1", "12.x", "-1.0", "12.-1", "12.3.4"} {
+		if _, err := ParseTimelineCursor(bad); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestThinSamples(t *testing.T) {
+	at := func(v int64) int64 { return v }
+	got := thinSamples([]int64{0, 400, 999, 1000, 1500, 3200, 3300}, at, 1000, 10)
+	if fmt.Sprint(got) != "[0 1000 3200]" {
+		t.Errorf("thinned %v", got)
+	}
+	if got := thinSamples([]int64{0, 1000, 2000}, at, 1000, 2); len(got) != 2 {
+		t.Errorf("capped %v", got)
+	}
+}
+
+func TestTimelineNow(t *testing.T) {
+	// A clock ahead of the wall clock, as after it stepped back
+	ahead := timelineNow() + 100
+	timelineClock.Store(ahead)
+	if a, b := timelineNow(), timelineNow(); a != ahead || b != ahead {
+		t.Errorf("went back: %d, %d after %d", a, b, ahead)
+	}
+	waitUntil(t, "the wall clock to catch up", func() bool { return timelineNow() > ahead })
+}
+
+// timelineFleet returns a manager with two robots whose event logs and
+// markers are filled with dense, interleaved entries between 1000 and
+// 1100 ms, well before anything recorded by the robots themselves.
+func timelineFleet(t *testing.T) *Manager {
+	t.Helper()
+	m := NewManager()
+	for i, name := range []string{"amr1", "amr2"} {
+		r, err := m.AddRobot("", name, "127.0.0.1", 9+i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { m.RemoveRobot(r.ID) })
+		r.mu.Lock()
+		for ms := int64(1000); ms <= 1100; ms += 5 {
+			// Several entries at the same millisecond, across kinds
+			r.events = append(r.events,
+				timelineEvent{Time: ms, Kind: TimelineMode, Data: fmt.Sprintf("%s-mode-%d", name, ms)},
+				timelineEvent{Time: ms, Kind: TimelineNav, Data: fmt.Sprintf("%s-nav-%d", name, ms)})
+			if ms%10 == 0 {
+				r.events = append(r.events, timelineEvent{Time: ms, Kind: TimelineEStop, Data: fmt.Sprintf("%s-estop-%d", name, ms)})
+				r.markers = append(r.markers, Marker{Time: ms + 3, Label: fmt.Sprintf("%s-marker-%d", name, ms+3)})
+			}
+		}
+		r.mu.Unlock()
+	}
+	return m
+}
+
+// timelineNames returns what the entries are, markers by label.
+func timelineNames(entries []TimelineEntry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		if mk, ok := e.Data.(Marker); ok {
+			out[i] = mk.Label
+		} else {
+			out[i] = fmt.Sprint(e.Data)
+		}
+	}
+	return out
+}
+
+// TestTimelinePaging pages through a dense two-robot timeline 7 entries
+// at a time and checks the pages add up to one full request.
+func TestTimelinePaging(t *testing.T) {
+	m := timelineFleet(t)
+	q := TimelineQuery{From: 1000, To: 1100, Limit: MaxTimelineLimit,
+		Kinds: map[string]bool{TimelineMode: true, TimelineNav: true, TimelineEStop: true, TimelineMarker: true}}
+	full := m.Timeline(q)
+	// Per robot: 21 times with mode and nav, 11 with an e-stop, 10 markers
+	if len(full.Entries) != 2*(21*2+11+10) || full.Next != "" {
+		t.Fatalf("full: %d entries, next %q", len(full.Entries), full.Next)
+	}
+	for i := 1; i < len(full.Entries); i++ {
+		if full.Entries[i].Time < full.Entries[i-1].Time {
+			t.Fatalf("out of order at %d: %+v after %+v", i, full.Entries[i], full.Entries[i-1])
+		}
+	}
+
+	var paged []TimelineEntry
+	q.Limit = 7
+	for pages := 0; ; pages++ {
+		if pages > len(full.Entries) {
+			t.Fatal("paging never ends")
+		}
+		page := m.Timeline(q)
+		paged = append(paged, page.Entries...)
+		if page.Next == "" {
+			break
+		}
+		if len(page.Entries) != 7 {
+			t.Fatalf("page %d: %d entries", pages, len(page.Entries))
+		}
+		var err error
+		if q.Cursor, err = ParseTimelineCursor(page.Next); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := timelineNames(paged), timelineNames(full.Entries); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pages %v\nwant %v", got, want)
+	}
+}
+
+func TestTimelineFilters(t *testing.T) {
+	m := timelineFleet(t)
+	var amr2 string
+	for _, r := range m.GetAllRobots() {
+		if r.Name == "amr2" {
+			amr2 = r.ID
+		}
+	}
+	page := m.Timeline(TimelineQuery{From: 1010, To: 1020, RobotIDs: []string{amr2},
+		Kinds: map[string]bool{TimelineEStop: true, TimelineMarker: true}})
+	want := "[amr2-estop-1010 amr2-marker-1013 amr2-estop-1020]"
+	if got := timelineNames(page.Entries); fmt.Sprint(got) != want {
+		t.Errorf("filtered %v, want %s", got, want)
+	}
+	if page := m.Timeline(TimelineQuery{From: 1000, To: 1100, Kinds: map[string]bool{}}); len(page.Entries) != 0 || page.Next != "" {
+		t.Errorf("no kinds: %+v", page)
+	}
+}
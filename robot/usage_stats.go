@@ -0,0 +1,335 @@
+package robot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ──────────────────────────── Usage statistics
+//
+// Odometer-style counters for maintenance: the distance traveled, from
+// successive odometry positions, and the time spent moving (speed above
+// a small epsilon). A step longer than the jump threshold is an odometry
+// or localization jump, not travel, and is dropped; so is a pose reset.
+// Time across a gap in the odometry stream (a disconnect) isn't counted
+// as active. Each day's distance and active time are kept for
+// UsageDays days. The counters are saved to <dir>/<robot>.json every
+// UsagePersistInterval, when a robot is removed and on shutdown, and
+// loaded when it is added. A reset, after maintenance, zeroes the totals
+// (the daily history stays) and is recorded with who asked for it.
+
+// DefaultUsageJumpM is the default longest odometry step counted as
+// travel.
+const DefaultUsageJumpM = 0.5
+
+// A robot is active while moving faster than these.
+const (
+	UsageActiveLinear  = 0.01 // m/s
+	UsageActiveAngular = 0.02 // rad/s
+)
+
+// usageMaxGap is the longest interval between odometry messages counted
+// as active time.
+const usageMaxGap = 2 * time.Second
+
+// UsageDays is how many days of daily usage are kept.
+const UsageDays = 30
+
+// UsagePersistInterval is how often changed counters are saved.
+const UsagePersistInterval = time.Minute
+
+// usageFileVersion is the schema version of usage files.
+const usageFileVersion = 1
+
+// UsageTotals are the counters since the last reset.
+type UsageTotals struct {
+	DistanceM   float64   `json:"distance_m"`
+	ActiveSec   float64   `json:"active_sec"`
+	ActiveHours float64   `json:"active_hours"`
+	Since       time.Time `json:"since"` // first use or last reset
+}
+
+// UsageDay is one day's usage; Date is local, "2006-01-02".
+type UsageDay struct {
+	Date      string  `json:"date"`
+	DistanceM float64 `json:"distance_m"`
+	ActiveSec float64 `json:"active_sec"`
+}
+
+// UsageReset records a reset of the totals.
+type UsageReset struct {
+	At     time.Time   `json:"at"`
+	By     string      `json:"by"`
+	Note   string      `json:"note,omitempty"`
+	Before UsageTotals `json:"before"`
+}
+
+// UsageReport is a robot's usage: totals, dropped jumps, the last reset
+// and the last UsageDays days, oldest first, days without use included.
+type UsageReport struct {
+	RobotID string `json:"robot_id"`
+	UsageTotals
+	RejectedJumps int         `json:"rejected_jumps"`
+	LastReset     *UsageReset `json:"last_reset,omitempty"`
+	Daily         []UsageDay  `json:"daily"`
+	// VelocityLag is the latest estimate of GET
+	// /api/robots/velocity_lag, absent before one.
+	VelocityLag *VelocityLag `json:"velocity_lag,omitempty"`
+}
+
+// usageTracker integrates odometry into the counters. Guarded by the
+// robot's mu.
+type usageTracker struct {
+	totals    UsageTotals
+	rejected  int
+	lastReset *UsageReset
+	days      []UsageDay // days with use, oldest first
+
+	have         bool // lastX/lastY/lastAt hold the previous sample
+	lastX, lastY float64
+	lastAt       time.Time
+	dirty        bool // changed since last saved
+}
+
+// step adds the odometry sample at (x, y), moving at linear m/s and
+// angular rad/s, received at at. A step longer than jumpM is dropped.
+func (u *usageTracker) step(x, y, linear, angular float64, at time.Time, jumpM float64) {
+	if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+		return
+	}
+	if u.totals.Since.IsZero() {
+		u.totals.Since = at
+	}
+	prevX, prevY, prevAt, had := u.lastX, u.lastY, u.lastAt, u.have
+	u.lastX, u.lastY, u.lastAt, u.have = x, y, at, true
+	if !had {
+		return
+	}
+
+	var dist, active float64
+	if d := math.Hypot(x-prevX, y-prevY); d > jumpM {
+		u.rejected++
+		u.dirty = true
+	} else {
+		dist = d
+	}
+	if dt := at.Sub(prevAt); dt > 0 && dt <= usageMaxGap &&
+		(math.Abs(linear) > UsageActiveLinear || math.Abs(angular) > UsageActiveAngular) {
+		active = dt.Seconds()
+	}
+	if dist == 0 && active == 0 {
+		return
+	}
+	u.totals.DistanceM += dist
+	u.totals.ActiveSec += active
+	u.addDay(at, dist, active)
+	u.dirty = true
+}
+
+// addDay adds to the day of at, dropping days beyond UsageDays.
+func (u *usageTracker) addDay(at time.Time, dist, active float64) {
+	date := at.Format("2006-01-02")
+	if n := len(u.days); n > 0 && u.days[n-1].Date == date {
+		u.days[n-1].DistanceM += dist
+		u.days[n-1].ActiveSec += active
+		return
+	}
+	u.days = append(u.days, UsageDay{Date: date, DistanceM: dist, ActiveSec: active})
+	oldest := at.AddDate(0, 0, -(UsageDays - 1)).Format("2006-01-02")
+	for len(u.days) > 0 && u.days[0].Date < oldest {
+		u.days = u.days[1:]
+	}
+}
+
+// report returns the counters with the daily usage of the UsageDays days
+// up to now.
+func (u *usageTracker) report(now time.Time) UsageReport {
+	rep := UsageReport{UsageTotals: u.totals, RejectedJumps: u.rejected, Daily: make([]UsageDay, UsageDays)}
+	rep.ActiveHours = u.totals.ActiveSec / 3600
+	if u.lastReset != nil {
+		r := *u.lastReset
+		rep.LastReset = &r
+	}
+	byDate := make(map[string]UsageDay, len(u.days))
+	for _, d := range u.days {
+		byDate[d.Date] = d
+	}
+	for i := range rep.Daily {
+		date := now.AddDate(0, 0, i-(UsageDays-1)).Format("2006-01-02")
+		rep.Daily[i] = byDate[date]
+		rep.Daily[i].Date = date
+	}
+	return rep
+}
+
+// UsageReport returns the robot's usage statistics.
+func (r *Robot) UsageReport() UsageReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rep := r.usage.report(time.Now())
+	rep.RobotID = r.ID
+	rep.VelocityLag = r.velocityLagLocked()
+	return rep
+}
+
+func (r *Robot) usageJumpM() float64 {
+	if r.UsageJumpM > 0 {
+		return r.UsageJumpM
+	}
+	return DefaultUsageJumpM
+}
+
+// usageTotalsLocked returns the totals for snapshots. Caller holds r.mu.
+func (r *Robot) usageTotalsLocked() UsageTotals {
+	t := r.usage.totals
+	t.ActiveHours = t.ActiveSec / 3600
+	return t
+}
+
+// ResetUsage zeroes the totals, recording who asked and why, and reports
+// the reset through OnUsageReset.
+func (r *Robot) ResetUsage(by, note string) UsageReset {
+	now := time.Now()
+	r.mu.Lock()
+	reset := UsageReset{At: now, By: by, Note: note, Before: r.usageTotalsLocked()}
+	r.usage.totals = UsageTotals{Since: now}
+	r.usage.rejected = 0
+	r.usage.lastReset = &reset
+	r.usage.dirty = true
+	r.mu.Unlock()
+
+	if r.OnUsageReset != nil {
+		r.OnUsageReset(reset)
+	}
+	return reset
+}
+
+// ──────────────────────────── Usage store
+
+// usageFile is the on-disk form of a robot's counters.
+type usageFile struct {
+	Version       int         `json:"version"`
+	Robot         string      `json:"robot"`
+	SavedAt       time.Time   `json:"saved_at"`
+	Totals        UsageTotals `json:"totals"`
+	RejectedJumps int         `json:"rejected_jumps"`
+	LastReset     *UsageReset `json:"last_reset,omitempty"`
+	Days          []UsageDay  `json:"days,omitempty"`
+}
+
+// UsageStore keeps usage files under Dir.
+type UsageStore struct {
+	Dir string
+}
+
+// NewUsageStore returns a store rooted at dir.
+func NewUsageStore(dir string) *UsageStore {
+	return &UsageStore{Dir: dir}
+}
+
+// Path returns the usage file of the robot with store key key.
+func (s *UsageStore) Path(key string) string {
+	return filepath.Join(s.Dir, safeFileName(key)+".json")
+}
+
+func (s *UsageStore) load(key string) (f usageFile, ok bool, err error) {
+	data, err := os.ReadFile(s.Path(key))
+	if os.IsNotExist(err) {
+		return usageFile{}, false, nil
+	}
+	if err != nil {
+		return usageFile{}, false, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return usageFile{}, false, err
+	}
+	if f.Version > usageFileVersion {
+		return usageFile{}, false, fmt.Errorf("schema version %d is newer than %d", f.Version, usageFileVersion)
+	}
+	return f, true, nil
+}
+
+func (s *UsageStore) write(f usageFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path(f.Robot), data)
+}
+
+// restoreUsage loads r's counters, if saved. Called by AddRobot.
+func (m *Manager) restoreUsage(r *Robot) {
+	key := r.StoreKey()
+	f, ok, err := m.Usage.load(key)
+	if err != nil {
+		log.Printf("[usage] %s: not restored: %v", key, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	r.usage.totals = f.Totals
+	r.usage.rejected = f.RejectedJumps
+	r.usage.lastReset = f.LastReset
+	r.usage.days = f.Days
+	r.mu.Unlock()
+}
+
+// saveUsage writes r's counters if they changed since last saved.
+func (m *Manager) saveUsage(r *Robot) {
+	r.mu.Lock()
+	if !r.usage.dirty {
+		r.mu.Unlock()
+		return
+	}
+	u := &r.usage
+	f := usageFile{
+		Version: usageFileVersion, Robot: r.StoreKey(), SavedAt: time.Now(),
+		Totals: u.totals, RejectedJumps: u.rejected, LastReset: u.lastReset,
+		Days: append([]UsageDay(nil), u.days...),
+	}
+	u.dirty = false
+	r.mu.Unlock()
+
+	if err := m.Usage.write(f); err != nil {
+		log.Printf("[usage] %s: %v", f.Robot, err)
+		r.mu.Lock()
+		r.usage.dirty = true
+		r.mu.Unlock()
+	}
+}
+
+// RunUsagePersistence saves changed counters every interval until ctx is
+// cancelled. Without a store it returns at once.
+func (m *Manager) RunUsagePersistence(ctx context.Context, interval time.Duration) {
+	if m.Usage == nil {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		m.FlushUsage()
+	}
+}
+
+// FlushUsage saves every robot's changed counters now.
+func (m *Manager) FlushUsage() {
+	if m.Usage == nil {
+		return
+	}
+	for _, r := range m.GetAllRobots() {
+		m.saveUsage(r)
+	}
+}
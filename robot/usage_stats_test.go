@@ -0,0 +1,170 @@
+package robot
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestUsageIntegration drives the tracker around a 1 m square at 0.5 m/s,
+// 10 Hz, then through a jump, a gap and a stop.
+func TestUsageIntegration(t *testing.T) {
+	var u usageTracker
+	t0 := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+	at := t0
+	x, y := 0.0, 0.0
+	u.step(x, y, 0.5, 0, at, DefaultUsageJumpM)
+	for _, dir := range [][2]float64{{1, 0}, {0, 1}, {-1, 0}, {0, -1}} {
+		for i := 0; i < 20; i++ {
+			x, y, at = x+dir[0]*0.05, y+dir[1]*0.05, at.Add(100*time.Millisecond)
+			u.step(x, y, 0.5, 0, at, DefaultUsageJumpM)
+		}
+	}
+	if !near(math.Round(u.totals.DistanceM*1e6)/1e6, 4) || !near(math.Round(u.totals.ActiveSec*1e6)/1e6, 8) {
+		t.Errorf("square: %.6f m, %.6f s, want 4 m, 8 s", u.totals.DistanceM, u.totals.ActiveSec)
+	}
+	if !u.totals.Since.Equal(t0) || u.rejected != 0 || !u.dirty {
+		t.Errorf("totals %+v, rejected %d", u.totals, u.rejected)
+	}
+
+	// A localization jump is dropped; travel resumes from where it landed
+	before := u.totals
+	at = at.Add(100 * time.Millisecond)
+	u.step(10, 10, 0.5, 0, at, DefaultUsageJumpM)
+	if u.rejected != 1 || u.totals.DistanceM != before.DistanceM {
+		t.Errorf("jump: rejected %d, distance %v", u.rejected, u.totals.DistanceM)
+	}
+	at = at.Add(100 * time.Millisecond)
+	u.step(10.05, 10, 0.5, 0, at, DefaultUsageJumpM)
+	if d := u.totals.DistanceM - before.DistanceM; !near(math.Round(d*1e6)/1e6, 0.05) {
+		t.Errorf("after the jump: +%v m", d)
+	}
+
+	// A gap in the stream: the distance counts, the time doesn't
+	active := u.totals.ActiveSec
+	at = at.Add(5 * time.Second)
+	u.step(10.1, 10, 0.5, 0, at, DefaultUsageJumpM)
+	if u.totals.ActiveSec != active {
+		t.Errorf("gap counted: %v s", u.totals.ActiveSec-active)
+	}
+
+	// Turning in place is active; standing still and bad samples are not
+	at = at.Add(time.Second)
+	u.step(10.1, 10, 0, 0.5, at, DefaultUsageJumpM)
+	if u.totals.ActiveSec != active+1 {
+		t.Errorf("turning: %v s", u.totals.ActiveSec-active)
+	}
+	u.dirty = false
+	at = at.Add(time.Second)
+	u.step(10.1, 10, 0.005, 0.01, at, DefaultUsageJumpM)
+	u.step(math.NaN(), 10, 1, 0, at.Add(time.Second), DefaultUsageJumpM)
+	u.step(math.Inf(1), 10, 1, 0, at.Add(time.Second), DefaultUsageJumpM)
+	if u.dirty || u.totals.ActiveSec != active+1 {
+		t.Errorf("stopped or bad samples changed the totals: %+v", u.totals)
+	}
+}
+
+func TestUsageDays(t *testing.T) {
+	var u usageTracker
+	day := func(d, h int) time.Time { return time.Date(2026, 3, d, h, 0, 0, 0, time.Local) }
+
+	// Across midnight each day gets the steps ending in it
+	midnight := day(2, 0)
+	u.step(0, 0, 0.5, 0, midnight.Add(-time.Second), 1)
+	u.step(0.3, 0, 0.5, 0, midnight.Add(-500*time.Millisecond), 1)
+	u.step(0.6, 0, 0.5, 0, midnight.Add(500*time.Millisecond), 1)
+	if len(u.days) != 2 || u.days[0].Date != "2026-03-01" || !near(u.days[0].DistanceM, 0.3) || u.days[0].ActiveSec != 0.5 || u.days[1].ActiveSec != 1 {
+		t.Errorf("days %+v", u.days)
+	}
+
+	rep := u.report(day(10, 12))
+	if len(rep.Daily) != UsageDays || rep.Daily[UsageDays-1].Date != "2026-03-10" || rep.Daily[0].Date != "2026-02-09" {
+		t.Fatalf("daily %d days, %s..%s", len(rep.Daily), rep.Daily[0].Date, rep.Daily[len(rep.Daily)-1].Date)
+	}
+	if d := rep.Daily[UsageDays-9]; d.Date != "2026-03-02" || !near(d.DistanceM, 0.3) {
+		t.Errorf("March 2: %+v", d)
+	}
+	if d := rep.Daily[UsageDays-2]; d.DistanceM != 0 || d.ActiveSec != 0 {
+		t.Errorf("a day without use: %+v", d)
+	}
+
+	// Days older than UsageDays are dropped
+	u.step(0.7, 0, 0.5, 0, day(31, 12), 1)
+	if len(u.days) != 2 || u.days[0].Date != "2026-03-02" {
+		t.Errorf("after a month: %+v", u.days)
+	}
+}
+
+func TestResetUsage(t *testing.T) {
+	r := NewRobot("1", "", "usage", "127.0.0.1", 9)
+	defer r.Close()
+	var got []UsageReset
+	r.OnUsageReset = func(e UsageReset) { got = append(got, e) }
+	now := time.Now()
+	r.mu.Lock()
+	r.usage.step(0, 0, 0.5, 0, now.Add(-2*time.Second), 1)
+	r.usage.step(0.4, 0, 0.5, 0, now.Add(-time.Second), 1)
+	r.usage.step(5, 0, 0.5, 0, now, 1)
+	r.mu.Unlock()
+
+	// The jump's distance is dropped, not the time spent moving
+	reset := r.ResetUsage("alice", "new wheels")
+	if reset.By != "alice" || reset.Note != "new wheels" || !near(reset.Before.DistanceM, 0.4) || reset.Before.ActiveSec != 2 {
+		t.Errorf("reset %+v", reset)
+	}
+	if len(got) != 1 || got[0].At != reset.At {
+		t.Errorf("reported %+v", got)
+	}
+	rep := r.UsageReport()
+	if rep.DistanceM != 0 || rep.ActiveSec != 0 || rep.RejectedJumps != 0 || rep.LastReset == nil || !rep.Since.Equal(reset.At) {
+		t.Errorf("after the reset: %+v", rep)
+	}
+	if today := rep.Daily[UsageDays-1]; !near(today.DistanceM, 0.4) {
+		t.Errorf("daily history not kept: %+v", today)
+	}
+}
+
+// TestUsageStore saves a robot's counters through the manager and adds
+// the robot again.
+func TestUsageStore(t *testing.T) {
+	m := NewManager()
+	m.Usage = NewUsageStore(t.TempDir())
+	r, _ := m.AddRobot("amr", "", "127.0.0.1", 9)
+	now := time.Now()
+	r.mu.Lock()
+	r.usage.step(0, 0, 0.5, 0, now.Add(-time.Second), 1)
+	r.usage.step(0.25, 0, 0.5, 0, now, 1)
+	r.mu.Unlock()
+	r.ResetUsage("bob", "")
+	r.mu.Lock()
+	r.usage.step(0.5, 0, 0.5, 0, now.Add(time.Second), 1)
+	r.mu.Unlock()
+
+	m.FlushUsage()
+	path := m.Usage.Path("amr")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.FlushUsage() // unchanged: not written again
+	if again, _ := os.Stat(path); !again.ModTime().Equal(info.ModTime()) {
+		t.Error("unchanged counters rewritten")
+	}
+	m.RemoveRobot(r.ID)
+
+	r, _ = m.AddRobot("amr", "", "127.0.0.1", 9)
+	rep := r.UsageReport()
+	if !near(rep.DistanceM, 0.25) || rep.LastReset == nil || rep.LastReset.By != "bob" || !near(rep.Daily[UsageDays-1].DistanceM, 0.5) {
+		t.Errorf("restored %+v", rep)
+	}
+	m.RemoveRobot(r.ID)
+
+	// A newer schema is left alone
+	os.WriteFile(path, []byte(`{"version": 2, "robot": "amr", "totals": {"distance_m": 99}}`), 0644)
+	r, _ = m.AddRobot("amr", "", "127.0.0.1", 9)
+	defer m.RemoveRobot(r.ID)
+	if rep := r.UsageReport(); rep.DistanceM != 0 {
+		t.Errorf("newer schema read: %v m", rep.DistanceM)
+	}
+}
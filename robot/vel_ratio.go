@@ -0,0 +1,149 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Velocity ratios
+//
+// Joystick input is scaled by the linear and angular ratios before it is
+// clamped to the robot's limits. A ratio outside the configured range
+// (VEL_RATIO_MIN..VEL_RATIO_MAX) is refused rather than clamped, so a
+// typo can't make the next joystick touch fast; the teleop increment
+// buttons step within the range and stop at its ends. A new ratio
+// applies at once: while the operator is driving, the twist being
+// published is recomputed from the last joystick input, so the robot
+// changes speed without waiting for the stick to move.
+
+// Default range of the velocity ratios.
+const (
+	DefaultVelRatioMin = 0.05
+	DefaultVelRatioMax = 2.0
+)
+
+// Velocity ratio names, for AdjustVelRatio.
+const (
+	RatioLinear  = "linear"
+	RatioAngular = "angular"
+)
+
+// ErrVelRatio is returned for a ratio outside the accepted range.
+var ErrVelRatio = errors.New("velocity ratio out of range")
+
+// RatioBounds is the accepted range of the velocity ratios.
+type RatioBounds struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// valid reports whether b is a usable, non-empty positive range.
+func (b RatioBounds) valid() bool {
+	return b.Min > 0 && b.Max >= b.Min && !math.IsInf(b.Max, 1)
+}
+
+// check returns an error naming ratio unless v is within b.
+func (b RatioBounds) check(ratio string, v float64) error {
+	if math.IsNaN(v) || v < b.Min || v > b.Max {
+		return fmt.Errorf("%w: %s ratio %g not in %g..%g", ErrVelRatio, ratio, v, b.Min, b.Max)
+	}
+	return nil
+}
+
+// clamp limits v to b.
+func (b RatioBounds) clamp(v float64) float64 {
+	return math.Max(b.Min, math.Min(b.Max, v))
+}
+
+// roundRatio rounds v to 0.001, so repeated steps of 0.1 land on
+// 0.9 rather than 0.8999999.
+func roundRatio(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// joystickDrive is the last joystick input and the twist it was turned
+// into, kept so a ratio change can recompute it.
+type joystickDrive struct {
+	linearX, linearY, angularZ float64
+	sent                       rosbridge.TwistData
+}
+
+// VelRatioBounds returns the accepted ratio range: the manager's, or the
+// defaults when it has none or an invalid one.
+func (r *Robot) VelRatioBounds() RatioBounds {
+	if r.RatioBounds != nil {
+		if b := r.RatioBounds(); b.valid() {
+			return b
+		}
+	}
+	return RatioBounds{Min: DefaultVelRatioMin, Max: DefaultVelRatioMax}
+}
+
+// SetVelRatios sets the joystick velocity scaling ratios, refusing with
+// ErrVelRatio (and changing neither) if one is out of range.
+func (r *Robot) SetVelRatios(linear, angular float64) error {
+	b := r.VelRatioBounds()
+	if err := b.check(RatioLinear, linear); err != nil {
+		return err
+	}
+	if err := b.check(RatioAngular, angular); err != nil {
+		return err
+	}
+	r.applyVelRatios(linear, angular)
+	return nil
+}
+
+// AdjustVelRatio adds delta to the linear or angular ratio, stopping at
+// the ends of the range, and returns the settings with the new value.
+func (r *Robot) AdjustVelRatio(ratio string, delta float64) (Settings, error) {
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return Settings{}, fmt.Errorf("%w: step %g", ErrVelRatio, delta)
+	}
+	b := r.VelRatioBounds()
+	r.mu.RLock()
+	linear, angular := r.linearVelRatio, r.angularVelRatio
+	r.mu.RUnlock()
+	switch ratio {
+	case RatioLinear:
+		linear = b.clamp(roundRatio(linear + delta))
+	case RatioAngular:
+		angular = b.clamp(roundRatio(angular + delta))
+	default:
+		return Settings{}, fmt.Errorf("unknown ratio %q (want %s or %s)", ratio, RatioLinear, RatioAngular)
+	}
+	r.applyVelRatios(linear, angular)
+	return r.GetSettings(), nil
+}
+
+// applyVelRatios stores the ratios, recomputes the twist of an ongoing
+// joystick drive and reports the change.
+func (r *Robot) applyVelRatios(linear, angular float64) {
+	r.mu.Lock()
+	changed := r.linearVelRatio != linear || r.angularVelRatio != angular
+	r.linearVelRatio = linear
+	r.angularVelRatio = angular
+	joy := r.joystick
+	r.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	// Only while the published twist is still the joystick's: a stop,
+	// relative move or e-stop since then has replaced it.
+	moving := joy.linearX != 0 || joy.linearY != 0 || joy.angularZ != 0
+	if moving && r.Client.DesiredCmdVel() == joy.sent {
+		sent := r.commandVelocity(joy.linearX*linear, joy.linearY*linear, joy.angularZ*angular)
+		r.mu.Lock()
+		if r.joystick == joy {
+			r.joystick.sent = sent
+		}
+		r.mu.Unlock()
+	}
+
+	if r.OnSettings != nil {
+		r.OnSettings(r.GetSettings())
+	}
+}
@@ -0,0 +1,149 @@
+package robot
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"rom_go_app/rosbridge"
+)
+
+func ratios(r *Robot) (float64, float64) {
+	s := r.GetSettings()
+	return s.LinearVelRatio, s.AngularVelRatio
+}
+
+func TestVelRatioBounds(t *testing.T) {
+	r := NewRobot("1", "", "ratios", "127.0.0.1", 9090)
+	defer r.Close()
+
+	if err := r.SetVelRatios(0.5, 1.5); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][2]float64{{100, 1}, {0.5, 0}, {0.01, 1}, {math.NaN(), 1}, {1, math.Inf(1)}} {
+		if err := r.SetVelRatios(c[0], c[1]); !errors.Is(err, ErrVelRatio) {
+			t.Errorf("%v: %v", c, err)
+		}
+		if l, a := ratios(r); l != 0.5 || a != 1.5 {
+			t.Errorf("%v refused but ratios now %v, %v", c, l, a)
+		}
+	}
+	if err := r.SetVelRatios(DefaultVelRatioMin, DefaultVelRatioMax); err != nil {
+		t.Errorf("the bounds themselves: %v", err)
+	}
+
+	// The manager's range, unless it is unusable
+	bounds := RatioBounds{Min: 0.1, Max: 5}
+	r.RatioBounds = func() RatioBounds { return bounds }
+	if err := r.SetVelRatios(4, 4); err != nil {
+		t.Errorf("within the configured range: %v", err)
+	}
+	for _, b := range []RatioBounds{{Min: 0, Max: 1}, {Min: 2, Max: 1}, {Min: 0.1, Max: math.Inf(1)}} {
+		bounds = b
+		if got := r.VelRatioBounds(); got.Min != DefaultVelRatioMin || got.Max != DefaultVelRatioMax {
+			t.Errorf("%+v: bounds %+v, want the defaults", b, got)
+		}
+	}
+}
+
+func TestAdjustVelRatio(t *testing.T) {
+	r := NewRobot("1", "", "ratios", "127.0.0.1", 9090)
+	defer r.Close()
+	r.SetVelRatios(1, 1.7)
+
+	var s Settings
+	for i := 0; i < 5; i++ {
+		s, _ = r.AdjustVelRatio(RatioAngular, 0.1)
+	}
+	if s.AngularVelRatio != DefaultVelRatioMax || s.LinearVelRatio != 1 {
+		t.Errorf("angular +0.1 x5 from 1.7: %+v", s)
+	}
+	if s, _ = r.AdjustVelRatio(RatioLinear, -0.1); s.LinearVelRatio != 0.9 {
+		t.Errorf("linear -0.1 from 1: %v", s.LinearVelRatio)
+	}
+	if s, _ = r.AdjustVelRatio(RatioLinear, -1); s.LinearVelRatio != DefaultVelRatioMin {
+		t.Errorf("linear -1: %v", s.LinearVelRatio)
+	}
+	if _, err := r.AdjustVelRatio("lateral", 0.1); err == nil {
+		t.Error("unknown ratio accepted")
+	}
+	if _, err := r.AdjustVelRatio(RatioLinear, math.NaN()); !errors.Is(err, ErrVelRatio) {
+		t.Errorf("NaN step: %v", err)
+	}
+}
+
+// TestVelRatioRecompute changes the ratios during a joystick drive: the
+// twist is republished at once, but not after a stop or once something
+// else commanded the robot.
+func TestVelRatioRecompute(t *testing.T) {
+	r := NewRobot("1", "", "ratios", "127.0.0.1", 9090)
+	defer r.Close()
+	var notified []Settings
+	r.OnSettings = func(s Settings) { notified = append(notified, s) }
+
+	r.SetVelocity(0.5, 0, 0.4)
+	if err := r.SetVelRatios(0.5, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Client.DesiredCmdVel(); !near(got.LinearX, 0.25) || !near(got.AngularZ, 0.8) {
+		t.Errorf("recomputed %+v, want 0.25, 0.8", got)
+	}
+	if len(notified) != 1 || notified[0].LinearVelRatio != 0.5 || notified[0].AngularVelRatio != 2 {
+		t.Errorf("notified %+v", notified)
+	}
+	// The limits still apply to the recomputed twist
+	r.SetMaxVelocities(0.3, 0.5)
+	r.SetVelRatios(1, 2)
+	if got := r.Client.DesiredCmdVel(); !near(got.LinearX, 0.3) || !near(got.AngularZ, 0.5) {
+		t.Errorf("recomputed over the limits %+v", got)
+	}
+
+	// The same ratios again: nothing republished or reported
+	r.SetVelocity(0.5, 0, 0.4)
+	n := len(notified)
+	r.SetVelRatios(1, 2)
+	if len(notified) != n {
+		t.Error("unchanged ratios reported")
+	}
+
+	// Something else set the twist since: left alone
+	other := rosbridge.TwistData{LinearX: 0.1}
+	r.Client.SetDesiredCmdVel(other)
+	r.SetVelRatios(0.5, 0.5)
+	if got := r.Client.DesiredCmdVel(); got != other {
+		t.Errorf("replaced another command: %+v", got)
+	}
+
+	// Stopped: nothing published
+	r.SetVelocity(0, 0, 0)
+	r.SetVelRatios(1, 1)
+	if got := r.Client.DesiredCmdVel(); got != (rosbridge.TwistData{}) {
+		t.Errorf("after a stop: %+v", got)
+	}
+}
+
+func TestProfileSkipsBadRatios(t *testing.T) {
+	r := NewRobot("1", "", "ratios", "127.0.0.1", 9090)
+	defer r.Close()
+	r.SetVelRatios(0.8, 0.8)
+
+	p := r.ExportProfile()
+	p.Settings.LinearVelRatio, p.Settings.AngularVelRatio = 9, 1.2
+	skipped := r.ApplyProfile(p)
+	if l, a := ratios(r); l != 0.8 || a != 1.2 {
+		t.Errorf("ratios %v, %v; want linear kept, angular applied", l, a)
+	}
+	found := false
+	for _, s := range skipped {
+		if strings.HasPrefix(s, "settings.linear_vel_ratio: ") {
+			found = true
+		}
+		if strings.HasPrefix(s, "settings.angular_vel_ratio") {
+			t.Errorf("valid ratio skipped: %s", s)
+		}
+	}
+	if !found {
+		t.Errorf("skipped %v, want the linear ratio", skipped)
+	}
+}
@@ -0,0 +1,308 @@
+package robot
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Commanded vs measured velocity history
+//
+// Each series is kept in three tiers so a long run stays reviewable
+// without the buffers growing with it:
+//
+//   - raw:     every sample of the last RawHistoryWindow (capped at
+//     MaxHistory samples)
+//   - seconds: 1 Hz averages of the last SecondHistoryWindow
+//   - minutes: per-minute min/max/avg, up to MaxMinuteHistory buckets
+//
+// Samples are folded into the open second and minute buckets as they
+// arrive; a bucket is appended to its tier when the next one opens, so
+// recording stays O(1) under the robot lock.
+
+// History tiers.
+const (
+	ResolutionRaw    = "raw"
+	ResolutionSecond = "1s"
+	ResolutionMinute = "1m"
+	ResolutionAuto   = "auto"
+)
+
+// Tier spans.
+const (
+	RawHistoryWindow    = 30 * time.Second
+	SecondHistoryWindow = time.Hour
+	MaxMinuteHistory    = 24 * 60
+)
+
+// Summary integration limits: odometry gaps longer than maxOdomGap are
+// not integrated (link loss, not motion), and the robot counts as moving
+// above movingSpeedMPS or movingAngularRadS.
+const (
+	maxOdomGap        = time.Second
+	movingSpeedMPS    = 0.02
+	movingAngularRadS = 0.05
+)
+
+// Velocity is a planar twist.
+type Velocity struct {
+	LinearX  float64 `json:"linear_x"`
+	LinearY  float64 `json:"linear_y"`
+	AngularZ float64 `json:"angular_z"`
+}
+
+// VelocitySample is a timestamped velocity reading. In the downsampled
+// tiers it is the average of a bucket starting at Time, with the sample
+// count, and in the minute tier the per-field min and max.
+type VelocitySample struct {
+	Time     int64     `json:"t"` // unix milliseconds
+	LinearX  float64   `json:"linear_x"`
+	LinearY  float64   `json:"linear_y"`
+	AngularZ float64   `json:"angular_z"`
+	Samples  int       `json:"n,omitempty"`
+	Min      *Velocity `json:"min,omitempty"`
+	Max      *Velocity `json:"max,omitempty"`
+}
+
+// VelocityHistory holds the two synchronized velocity series at one
+// resolution.
+type VelocityHistory struct {
+	Resolution string           `json:"resolution"`
+	Commanded  []VelocitySample `json:"commanded"`
+	Measured   []VelocitySample `json:"measured"`
+	Markers    []Marker         `json:"markers"` // incident markers within the samples' time range
+}
+
+// VelocitySummary is the motion of the robot since odometry was first
+// received by this server.
+type VelocitySummary struct {
+	StartedAt       time.Time `json:"started_at"`
+	DurationSec     float64   `json:"duration_sec"`
+	DistanceM       float64   `json:"distance_m"` // integrated from odom linear velocity
+	MaxSpeedMPS     float64   `json:"max_speed_mps"`
+	MaxAngularRadS  float64   `json:"max_angular_rad_s"`
+	MovingTimeSec   float64   `json:"moving_time_sec"`
+	AvgMovingSpeed  float64   `json:"avg_moving_speed_mps"`
+	OdomSamples     int       `json:"odom_samples"`
+	IntegrationGaps int       `json:"integration_gaps"` // odom gaps over 1 s left out of the totals
+}
+
+func newVelocitySample(t time.Time, linearX, linearY, angularZ float64) VelocitySample {
+	return VelocitySample{
+		Time:     t.UnixMilli(),
+		LinearX:  linearX,
+		LinearY:  linearY,
+		AngularZ: angularZ,
+	}
+}
+
+// velocityBucket accumulates the samples of one second or minute.
+type velocityBucket struct {
+	start    int64 // unix ms
+	n        int
+	sum      Velocity
+	min, max Velocity
+}
+
+func (b *velocityBucket) add(s VelocitySample) {
+	if b.n == 0 {
+		b.min = Velocity{s.LinearX, s.LinearY, s.AngularZ}
+		b.max = b.min
+	} else {
+		b.min = Velocity{math.Min(b.min.LinearX, s.LinearX), math.Min(b.min.LinearY, s.LinearY), math.Min(b.min.AngularZ, s.AngularZ)}
+		b.max = Velocity{math.Max(b.max.LinearX, s.LinearX), math.Max(b.max.LinearY, s.LinearY), math.Max(b.max.AngularZ, s.AngularZ)}
+	}
+	b.sum.LinearX += s.LinearX
+	b.sum.LinearY += s.LinearY
+	b.sum.AngularZ += s.AngularZ
+	b.n++
+}
+
+// sample returns the bucket's average; withRange adds min and max.
+func (b *velocityBucket) sample(withRange bool) VelocitySample {
+	n := float64(b.n)
+	out := VelocitySample{
+		Time:     b.start,
+		LinearX:  b.sum.LinearX / n,
+		LinearY:  b.sum.LinearY / n,
+		AngularZ: b.sum.AngularZ / n,
+		Samples:  b.n,
+	}
+	if withRange {
+		lo, hi := b.min, b.max
+		out.Min, out.Max = &lo, &hi
+	}
+	return out
+}
+
+// velocitySeries is one series in its three tiers.
+type velocitySeries struct {
+	raw     []VelocitySample
+	seconds []VelocitySample
+	minutes []VelocitySample
+	sec     velocityBucket
+	min     velocityBucket
+}
+
+// add records s and trims each tier. maxRaw caps the raw tier.
+func (v *velocitySeries) add(s VelocitySample, maxRaw int) {
+	v.raw = append(v.raw, s)
+	v.raw = trimBefore(v.raw, s.Time-RawHistoryWindow.Milliseconds())
+	if maxRaw > 0 && len(v.raw) > maxRaw {
+		v.raw = v.raw[len(v.raw)-maxRaw:]
+	}
+
+	secStart := s.Time - s.Time%1000
+	if v.sec.n > 0 && v.sec.start != secStart {
+		v.seconds = append(v.seconds, v.sec.sample(false))
+		v.sec = velocityBucket{}
+	}
+	v.sec.start = secStart
+	v.sec.add(s)
+	v.seconds = trimBefore(v.seconds, s.Time-SecondHistoryWindow.Milliseconds())
+
+	minStart := s.Time - s.Time%60000
+	if v.min.n > 0 && v.min.start != minStart {
+		v.minutes = append(v.minutes, v.min.sample(true))
+		if len(v.minutes) > MaxMinuteHistory {
+			v.minutes = v.minutes[len(v.minutes)-MaxMinuteHistory:]
+		}
+		v.min = velocityBucket{}
+	}
+	v.min.start = minStart
+	v.min.add(s)
+}
+
+// tier returns a copy of one tier within (since, until], including the
+// open bucket. until 0 means no upper bound.
+func (v *velocitySeries) tier(resolution string, since, until int64) []VelocitySample {
+	var series []VelocitySample
+	switch resolution {
+	case ResolutionSecond:
+		series = v.seconds
+		if v.sec.n > 0 {
+			series = append(series[:len(series):len(series)], v.sec.sample(false))
+		}
+	case ResolutionMinute:
+		series = v.minutes
+		if v.min.n > 0 {
+			series = append(series[:len(series):len(series)], v.min.sample(true))
+		}
+	default:
+		series = v.raw
+	}
+	return samplesBetween(series, since, until)
+}
+
+// trimBefore drops the leading samples at or before cutoff.
+func trimBefore(series []VelocitySample, cutoff int64) []VelocitySample {
+	i := sort.Search(len(series), func(i int) bool { return series[i].Time > cutoff })
+	return series[i:]
+}
+
+// recordCommanded stores a cmd_vel value as published on the wire.
+func (r *Robot) recordCommanded(t rosbridge.TwistData) {
+	s := newVelocitySample(time.Now(), t.LinearX, t.LinearY, t.AngularZ)
+	r.mu.Lock()
+	r.commanded.add(s, r.MaxHistory)
+	r.mu.Unlock()
+}
+
+// recordMeasured stores the twist reported by odometry and integrates
+// the session summary. Caller holds r.mu.
+func (r *Robot) recordMeasured(o rosbridge.OdomData) {
+	now := time.Now()
+	r.measured.add(newVelocitySample(now, o.LinearX, o.LinearY, o.AngularZ), r.MaxHistory)
+
+	sum := &r.velSummary
+	speed := math.Hypot(o.LinearX, o.LinearY)
+	if sum.OdomSamples == 0 {
+		sum.StartedAt = now
+	} else if dt := now.Sub(r.lastMeasured); dt > maxOdomGap {
+		sum.IntegrationGaps++
+	} else {
+		// Trapezoid between the previous and this sample
+		sec := dt.Seconds()
+		sum.DistanceM += (speed + r.lastSpeed) / 2 * sec
+		if speed > movingSpeedMPS || math.Abs(o.AngularZ) > movingAngularRadS {
+			sum.MovingTimeSec += sec
+		}
+	}
+	sum.OdomSamples++
+	sum.MaxSpeedMPS = math.Max(sum.MaxSpeedMPS, speed)
+	sum.MaxAngularRadS = math.Max(sum.MaxAngularRadS, math.Abs(o.AngularZ))
+	r.lastMeasured = now
+	r.lastSpeed = speed
+}
+
+// GetVelocityHistory returns copies of both series at resolution (raw,
+// 1s, 1m or auto), limited to samples after since and up to until (unix
+// ms; 0 leaves that end open). auto picks the finest tier that still
+// covers since, and the raw window when since is 0. The incident markers
+// within the time range of the returned samples come with them.
+func (r *Robot) GetVelocityHistory(resolution string, since, until int64) VelocityHistory {
+	now := time.Now()
+	if resolution == "" || resolution == ResolutionAuto {
+		resolution = autoResolution(now, since)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h := VelocityHistory{
+		Resolution: resolution,
+		Commanded:  r.commanded.tier(resolution, since, until),
+		Measured:   r.measured.tier(resolution, since, until),
+	}
+	from, to := historySpan(h, since, until, now.UnixMilli())
+	h.Markers = markersBetween(r.markers, from, to)
+	return h
+}
+
+// ValidResolution reports whether s names a history tier or auto.
+func ValidResolution(s string) bool {
+	switch s {
+	case "", ResolutionRaw, ResolutionSecond, ResolutionMinute, ResolutionAuto:
+		return true
+	}
+	return false
+}
+
+func autoResolution(now time.Time, since int64) string {
+	switch {
+	case since == 0 || since >= now.Add(-RawHistoryWindow).UnixMilli():
+		return ResolutionRaw
+	case since >= now.Add(-SecondHistoryWindow).UnixMilli():
+		return ResolutionSecond
+	default:
+		return ResolutionMinute
+	}
+}
+
+// GetVelocitySummary returns distance, top speed and moving time since
+// odometry was first received.
+func (r *Robot) GetVelocitySummary() VelocitySummary {
+	r.mu.RLock()
+	s := r.velSummary
+	r.mu.RUnlock()
+	if s.OdomSamples > 0 {
+		s.DurationSec = time.Since(s.StartedAt).Seconds()
+	}
+	if s.MovingTimeSec > 0 {
+		s.AvgMovingSpeed = s.DistanceM / s.MovingTimeSec
+	}
+	return s
+}
+
+// samplesBetween returns a copy of the samples after since and up to
+// until (0: no upper bound); series is sorted by time.
+func samplesBetween(series []VelocitySample, since, until int64) []VelocitySample {
+	start := sort.Search(len(series), func(i int) bool { return series[i].Time > since })
+	end := len(series)
+	if until > 0 {
+		end = max(start, sort.Search(len(series), func(i int) bool { return series[i].Time > until }))
+	}
+	out := make([]VelocitySample, end-start)
+	copy(out, series[start:end])
+	return out
+}
@@ -0,0 +1,137 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"rom_go_app/analysis"
+)
+
+// ──────────────────────────── Commanded → measured velocity lag
+//
+// How long the base takes to follow a command, estimated on demand from
+// the raw velocity history (the last RawHistoryWindow): commanded and
+// measured linear velocity are averaged onto a LagStep grid (cmd_vel
+// held between messages, as the base holds it) and cross-correlated
+// over lags up to MaxVelocityLag (see the analysis package). A few
+// hundred points and a few dozen lags keep each estimate cheap, and one
+// is reused for lagReuse. The latest estimate is kept for the stats
+// endpoint and reported through OnVelocityLag when it is the first or
+// changes significantly.
+
+// Lag estimation parameters.
+const (
+	LagStep        = 100 * time.Millisecond // ~10 Hz
+	MaxVelocityLag = 2 * time.Second
+	lagReuse       = time.Second
+
+	// An estimate differing by lagChangeMs or lagChangeConfidence from
+	// the last one reported is reported.
+	lagChangeMs         = 50
+	lagChangeConfidence = 0.2
+)
+
+// ErrNoMotion is returned when the recent velocity history has too
+// little motion to estimate the lag.
+var ErrNoMotion = errors.New("not enough recent motion to estimate the velocity lag")
+
+// VelocityLag is an estimate of how far measured velocity trails the
+// commanded one.
+type VelocityLag struct {
+	LagMs float64 `json:"lag_ms"`
+	// Confidence is 0..1: the correlation at the estimated lag, scaled
+	// down when it hardly stands out from other lags.
+	Confidence  float64   `json:"confidence"`
+	Correlation float64   `json:"correlation"`
+	WindowSec   float64   `json:"window_sec"` // history correlated
+	Samples     int       `json:"samples"`    // grid points compared at the lag
+	ComputedAt  time.Time `json:"computed_at"`
+}
+
+// EstimateVelocityLag estimates the robot's velocity lag from its recent
+// history; it fails with ErrNoMotion while there is too little to go
+// on.
+func (r *Robot) EstimateVelocityLag() (VelocityLag, error) {
+	now := time.Now()
+	r.mu.RLock()
+	last := r.velLag
+	cmdT, cmdV := linearSeries(r.commanded.raw)
+	measT, measV := linearSeries(r.measured.raw)
+	r.mu.RUnlock()
+	if last != nil && now.Sub(last.ComputedAt) < lagReuse {
+		return *last, nil
+	}
+
+	lag, err := estimateLag(cmdT, cmdV, measT, measV, now)
+	if err != nil {
+		return VelocityLag{}, err
+	}
+
+	r.mu.Lock()
+	prev := r.velLagReported
+	r.velLag = &lag
+	report := prev == nil ||
+		math.Abs(lag.LagMs-prev.LagMs) >= lagChangeMs ||
+		math.Abs(lag.Confidence-prev.Confidence) >= lagChangeConfidence
+	if report {
+		r.velLagReported = &lag
+	}
+	r.mu.Unlock()
+
+	if report && r.OnVelocityLag != nil {
+		r.OnVelocityLag(lag)
+	}
+	return lag, nil
+}
+
+// velocityLagLocked returns the latest estimate, nil before one. Caller
+// holds r.mu.
+func (r *Robot) velocityLagLocked() *VelocityLag {
+	if r.velLag == nil {
+		return nil
+	}
+	lag := *r.velLag
+	return &lag
+}
+
+// estimateLag correlates the commanded and measured series over the
+// span both cover, up to now.
+func estimateLag(cmdT []int64, cmdV []float64, measT []int64, measV []float64, now time.Time) (VelocityLag, error) {
+	if len(cmdT) == 0 || len(measT) == 0 {
+		return VelocityLag{}, ErrNoMotion
+	}
+	step := LagStep.Milliseconds()
+	start := max(cmdT[0], measT[0], now.Add(-RawHistoryWindow).UnixMilli())
+	n := int((now.UnixMilli() - start) / step)
+	maxLag := int(MaxVelocityLag / LagStep)
+
+	cmd := analysis.Resample(cmdT, cmdV, start, step, n)
+	meas := analysis.Resample(measT, measV, start, step, n)
+	l, err := analysis.EstimateLag(cmd, meas, maxLag)
+	switch {
+	case errors.Is(err, analysis.ErrTooShort), errors.Is(err, analysis.ErrNoExcitation):
+		return VelocityLag{}, fmt.Errorf("%w (%v over %.0f s)", ErrNoMotion, err, float64(n)*LagStep.Seconds())
+	case err != nil:
+		return VelocityLag{}, err
+	}
+	return VelocityLag{
+		LagMs:       math.Round(l.Steps * float64(step)),
+		Confidence:  math.Round(l.Confidence*100) / 100,
+		Correlation: math.Round(l.Correlation*100) / 100,
+		WindowSec:   float64(n) * LagStep.Seconds(),
+		Samples:     l.Overlap,
+		ComputedAt:  now,
+	}, nil
+}
+
+// linearSeries returns the times and linear x velocities of samples.
+func linearSeries(samples []VelocitySample) ([]int64, []float64) {
+	t := make([]int64, len(samples))
+	v := make([]float64, len(samples))
+	for i, s := range samples {
+		t[i], v[i] = s.Time, s.LinearX
+	}
+	return t, v
+}
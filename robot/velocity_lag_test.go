@@ -0,0 +1,93 @@
+package robot
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestVelocityLag fills the robot's history with 20 Hz commands and
+// 50 Hz odometry following them 300 ms late.
+func TestVelocityLag(t *testing.T) {
+	r := NewRobot("1", "", "amr", "127.0.0.1", 9)
+	defer r.Close()
+	var reported []VelocityLag
+	r.OnVelocityLag = func(l VelocityLag) { reported = append(reported, l) }
+
+	if _, err := r.EstimateVelocityLag(); !errors.Is(err, ErrNoMotion) {
+		t.Errorf("no history: %v", err)
+	}
+
+	// A new random speed every 0.5 to 2 s over the last 25 s
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	start := now.Add(-25 * time.Second).UnixMilli()
+	var changes []int64
+	var speeds []float64
+	for at := start; at < now.UnixMilli(); at += 500 + rng.Int63n(1500) {
+		changes = append(changes, at)
+		speeds = append(speeds, rng.Float64()*0.8-0.2)
+	}
+	speedAt := func(ms int64) float64 {
+		v := 0.0
+		for i, at := range changes {
+			if at <= ms {
+				v = speeds[i]
+			}
+		}
+		return v
+	}
+	const lagMs = 300
+	r.mu.Lock()
+	for ms := start; ms < now.UnixMilli(); ms += 10 {
+		if (ms-start)%50 == 0 {
+			r.commanded.add(VelocitySample{Time: ms, LinearX: speedAt(ms)}, 0)
+		}
+		if (ms-start)%20 == 0 {
+			r.measured.add(VelocitySample{Time: ms, LinearX: speedAt(ms-lagMs) + rng.NormFloat64()*0.005}, 0)
+		}
+	}
+	r.mu.Unlock()
+
+	lag, err := r.EstimateVelocityLag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(lag.LagMs-lagMs) > 50 || lag.Confidence < 0.7 || lag.WindowSec < 24 || lag.Samples < 200 {
+		t.Errorf("estimate %+v, want %d ms", lag, lagMs)
+	}
+	if len(reported) != 1 || reported[0] != lag {
+		t.Errorf("reported %+v", reported)
+	}
+
+	// Reused within a second, and kept for the stats
+	if again, _ := r.EstimateVelocityLag(); !again.ComputedAt.Equal(lag.ComputedAt) || len(reported) != 1 {
+		t.Errorf("recomputed within a second: %+v", again)
+	}
+	r.mu.RLock()
+	kept := r.velocityLagLocked()
+	r.mu.RUnlock()
+	if kept == nil || *kept != lag {
+		t.Errorf("kept %+v", kept)
+	}
+}
+
+// TestVelocityLagStanding checks a robot that hasn't moved gives no
+// estimate.
+func TestVelocityLagStanding(t *testing.T) {
+	now := time.Now()
+	var cmdT, measT []int64
+	var cmdV, measV []float64
+	for ms := now.Add(-20 * time.Second).UnixMilli(); ms < now.UnixMilli(); ms += 50 {
+		cmdT, cmdV = append(cmdT, ms), append(cmdV, 0)
+		measT, measV = append(measT, ms), append(measV, 0.001)
+	}
+	if _, err := estimateLag(cmdT, cmdV, measT, measV, now); !errors.Is(err, ErrNoMotion) {
+		t.Errorf("standing: %v", err)
+	}
+	if _, err := estimateLag(cmdT[:10], cmdV[:10], measT[:10], measV[:10], now.Add(-19*time.Second)); !errors.Is(err, ErrNoMotion) {
+		t.Errorf("half a second of history: %v", err)
+	}
+}
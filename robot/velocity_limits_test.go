@@ -0,0 +1,25 @@
+package robot
+
+import "testing"
+
+func TestCommandVelocityLimits(t *testing.T) {
+	r := NewRobot("1", "", "test", "127.0.0.1", 9090)
+	defer r.Close()
+
+	if s := r.GetSettings(); s.MaxLinearVel != 0 || s.MaxAngularVel != 0 {
+		t.Fatalf("default limits = %v, %v; want 0 (off)", s.MaxLinearVel, s.MaxAngularVel)
+	}
+	if got := r.commandVelocity(3, 0, -4); got.LinearX != 3 || got.AngularZ != -4 {
+		t.Errorf("unlimited: got %+v, want unclamped", got)
+	}
+
+	r.SetMaxVelocities(0.5, 1)
+	if got := r.commandVelocity(3, 0, -4); got.LinearX != 0.5 || got.AngularZ != -1 {
+		t.Errorf("limited: got %+v, want 0.5, -1", got)
+	}
+
+	r.SetMaxVelocities(0, 1)
+	if got := r.commandVelocity(3, 0, -4); got.LinearX != 3 || got.AngularZ != -1 {
+		t.Errorf("angular only: got %+v, want 3, -1", got)
+	}
+}
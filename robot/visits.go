@@ -0,0 +1,484 @@
+package robot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Point visits
+//
+// When each waypoint, service, patrol or path point was last reached, and
+// how often. A succeeded navigation goal counts as a visit to the point
+// the robot stopped at; since the firmware doesn't report which points a
+// multi-point run passed, the visit monitor also compares every robot's
+// map pose with its points each VisitCheckInterval. Entering a point's
+// radius is a visit; the robot is only out again once it is the
+// hysteresis beyond the radius, so hovering at the boundary or arriving
+// by goal and proximity at once counts one visit. Each visit is broadcast
+// as "point_visited". The last VisitHistoryMax visits of a robot, and per
+// point the visit count and last visit, are saved to <dir>/<robot>.json
+// like the usage counters.
+
+// Visit detection defaults.
+const (
+	DefaultVisitRadiusM     = 0.5
+	DefaultVisitHysteresisM = 0.3
+)
+
+// VisitCheckInterval is how often robot poses are compared with points.
+const VisitCheckInterval = 500 * time.Millisecond
+
+// VisitPersistInterval is how often changed visits are saved.
+const VisitPersistInterval = time.Minute
+
+// VisitHistoryMax is how many visits are kept per robot.
+const VisitHistoryMax = 5000
+
+// visitPoseMaxAge is the oldest pose the monitor trusts.
+const visitPoseMaxAge = 2 * time.Second
+
+// visitFileVersion is the schema version of visit files.
+const visitFileVersion = 1
+
+// Visit sources.
+const (
+	VisitNavStatus = "nav_status" // a goal succeeded at the point
+	VisitProximity = "proximity"  // the pose came within the radius
+)
+
+// PointVisit is one arrival at a point.
+type PointVisit struct {
+	RobotID   string              `json:"robot_id"`
+	Type      rosbridge.PointType `json:"type"`
+	Name      string              `json:"name"`
+	At        time.Time           `json:"at"`
+	Pose      rosbridge.Pose2D    `json:"pose"` // map pose at arrival
+	Map       string              `json:"map,omitempty"`
+	DistanceM float64             `json:"distance_m"` // from the point
+	Source    string              `json:"source"`
+}
+
+// PointVisitStats aggregates a point's visits. Count and Today are over
+// the queried history; Total and LastVisited over all time.
+type PointVisitStats struct {
+	Type        rosbridge.PointType `json:"type"`
+	Name        string              `json:"name"`
+	Count       int                 `json:"count"`
+	Today       int                 `json:"today"`
+	Total       int                 `json:"total"`
+	LastVisited *time.Time          `json:"last_visited,omitempty"`
+}
+
+// VisitQuery filters visits; zero fields don't filter.
+type VisitQuery struct {
+	Since, Until time.Time
+	Type         rosbridge.PointType
+	Name         string
+	Limit        int // newest visits returned; 0: all matching
+}
+
+// VisitReport is a robot's visits, newest first, and per-point
+// aggregates, most recently visited first.
+type VisitReport struct {
+	RobotID string            `json:"robot_id"`
+	Visits  []PointVisit      `json:"visits"`
+	Points  []PointVisitStats `json:"points"`
+}
+
+// visitKey identifies a point.
+type visitKey struct {
+	Type rosbridge.PointType
+	Name string
+}
+
+// visitTotals are a point's all-time counters.
+type visitTotals struct {
+	Total int       `json:"total"`
+	Last  time.Time `json:"last"`
+}
+
+// visitCandidate is a point position for the detector.
+type visitCandidate struct {
+	key  visitKey
+	x, y float64
+}
+
+// visitDetector turns poses into visits with hysteresis: at holds the
+// point the robot is at, if any.
+type visitDetector struct {
+	at *visitKey
+}
+
+// step updates the detector with the pose (x, y) and returns the point
+// entered, if this pose makes a visit.
+func (d *visitDetector) step(points []visitCandidate, x, y, radius, hysteresis float64) (visitKey, float64, bool) {
+	if d.at != nil {
+		for _, p := range points {
+			if p.key == *d.at && math.Hypot(p.x-x, p.y-y) <= radius+hysteresis {
+				return visitKey{}, 0, false
+			}
+		}
+		d.at = nil // left it, or it was removed
+	}
+	best, bestD, found := visitKey{}, 0.0, false
+	for _, p := range points {
+		if dist := math.Hypot(p.x-x, p.y-y); dist <= radius && (!found || dist < bestD) {
+			best, bestD, found = p.key, dist, true
+		}
+	}
+	if !found {
+		return visitKey{}, 0, false
+	}
+	d.at = &best
+	return best, bestD, true
+}
+
+// arrive records a goal reaching k; it is a visit unless the robot was
+// already counted at k.
+func (d *visitDetector) arrive(k visitKey) bool {
+	if d.at != nil && *d.at == k {
+		return false
+	}
+	d.at = &k
+	return true
+}
+
+// visitTracker holds a robot's visits. Guarded by the robot's mu.
+type visitTracker struct {
+	det     visitDetector
+	history []PointVisit // oldest first
+	totals  map[visitKey]visitTotals
+	dirty   bool // changed since last saved
+}
+
+// add records v, dropping the oldest beyond VisitHistoryMax.
+func (t *visitTracker) add(v PointVisit) {
+	t.history = append(t.history, v)
+	if n := len(t.history) - VisitHistoryMax; n > 0 {
+		t.history = append([]PointVisit(nil), t.history[n:]...)
+	}
+	if t.totals == nil {
+		t.totals = make(map[visitKey]visitTotals)
+	}
+	k := visitKey{v.Type, v.Name}
+	tot := t.totals[k]
+	tot.Total++
+	tot.Last = v.At
+	t.totals[k] = tot
+	t.dirty = true
+}
+
+// report filters the history by q and aggregates it; now sets today.
+func (t *visitTracker) report(q VisitQuery, now time.Time) VisitReport {
+	y, mo, d := now.Date()
+	midnight := time.Date(y, mo, d, 0, 0, 0, 0, now.Location())
+	match := func(k visitKey) bool {
+		return (q.Type == "" || k.Type == q.Type) && (q.Name == "" || k.Name == q.Name)
+	}
+
+	rep := VisitReport{Visits: []PointVisit{}, Points: []PointVisitStats{}}
+	stats := make(map[visitKey]*PointVisitStats)
+	for k, tot := range t.totals {
+		if match(k) {
+			last := tot.Last
+			stats[k] = &PointVisitStats{Type: k.Type, Name: k.Name, Total: tot.Total, LastVisited: &last}
+		}
+	}
+	for i := len(t.history) - 1; i >= 0; i-- {
+		v := t.history[i]
+		k := visitKey{v.Type, v.Name}
+		if !match(k) || (!q.Since.IsZero() && v.At.Before(q.Since)) || (!q.Until.IsZero() && v.At.After(q.Until)) {
+			continue
+		}
+		if q.Limit <= 0 || len(rep.Visits) < q.Limit {
+			rep.Visits = append(rep.Visits, v)
+		}
+		if s := stats[k]; s != nil {
+			s.Count++
+			if !v.At.Before(midnight) {
+				s.Today++
+			}
+		}
+	}
+	for _, s := range stats {
+		rep.Points = append(rep.Points, *s)
+	}
+	sort.Slice(rep.Points, func(i, j int) bool {
+		a, b := rep.Points[i], rep.Points[j]
+		if !a.LastVisited.Equal(*b.LastVisited) {
+			return a.LastVisited.After(*b.LastVisited)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Name < b.Name
+	})
+	return rep
+}
+
+// VisitReport returns the robot's visits matching q.
+func (r *Robot) VisitReport(q VisitQuery) VisitReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rep := r.visits.report(q, time.Now())
+	rep.RobotID = r.ID
+	return rep
+}
+
+// LastVisits returns when each visited point was last reached, by type
+// and name.
+func (r *Robot) LastVisits() map[rosbridge.PointType]map[string]time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[rosbridge.PointType]map[string]time.Time)
+	for k, tot := range r.visits.totals {
+		if out[k.Type] == nil {
+			out[k.Type] = make(map[string]time.Time)
+		}
+		out[k.Type][k.Name] = tot.Last
+	}
+	return out
+}
+
+// visitCandidatesLocked returns the robot's points. Caller holds r.mu.
+func (r *Robot) visitCandidatesLocked() []visitCandidate {
+	var out []visitCandidate
+	for _, t := range rosbridge.NavPointTypes {
+		for _, p := range *r.pointCollection(t) {
+			out = append(out, visitCandidate{key: visitKey{t, p.Name}, x: p.WorldXM, y: p.WorldYM})
+		}
+	}
+	return out
+}
+
+// ──────────────────────────── Visit monitor
+
+// visitRadii returns the detection radius and hysteresis in force.
+func (m *Manager) visitRadii() (radius, hysteresis float64) {
+	radius, hysteresis = m.VisitRadiusM, m.VisitHysteresisM
+	if radius <= 0 {
+		radius = DefaultVisitRadiusM
+	}
+	if hysteresis <= 0 {
+		hysteresis = DefaultVisitHysteresisM
+	}
+	return radius, hysteresis
+}
+
+// RunVisitMonitor compares robot poses with their points every interval
+// until ctx is cancelled.
+func (m *Manager) RunVisitMonitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		for _, r := range m.GetAllRobots() {
+			m.checkVisits(r)
+		}
+	}
+}
+
+// checkVisits records a visit if r's pose entered a point.
+func (m *Manager) checkVisits(r *Robot) {
+	if !r.IsConnected() {
+		return
+	}
+	pose, _, err := r.CurrentMapPose(visitPoseMaxAge)
+	if err != nil {
+		return
+	}
+	radius, hysteresis := m.visitRadii()
+	r.mu.Lock()
+	k, dist, ok := r.visits.det.step(r.visitCandidatesLocked(), pose.X, pose.Y, radius, hysteresis)
+	var v PointVisit
+	if ok {
+		v = PointVisit{RobotID: r.ID, Type: k.Type, Name: k.Name, At: time.Now(), Pose: pose, Map: r.currentMap, DistanceM: dist, Source: VisitProximity}
+		r.visits.add(v)
+	}
+	r.mu.Unlock()
+	if ok {
+		m.Broadcast(BroadcastMsg{Type: "point_visited", RobotID: r.ID, Data: v})
+	}
+}
+
+// navArrival records a visit to the point a succeeded goal left r at,
+// if it stopped within the radius of one.
+func (m *Manager) navArrival(r *Robot) {
+	radius, _ := m.visitRadii()
+	p, ok := r.NearestPoint(radius, visitPoseMaxAge)
+	if !ok {
+		return
+	}
+	pose, _, err := r.CurrentMapPose(visitPoseMaxAge)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	k := visitKey{p.Type, p.Name}
+	ok = r.visits.det.arrive(k)
+	var v PointVisit
+	if ok {
+		v = PointVisit{RobotID: r.ID, Type: k.Type, Name: k.Name, At: time.Now(), Pose: pose, Map: r.currentMap, DistanceM: p.DistanceM, Source: VisitNavStatus}
+		r.visits.add(v)
+	}
+	r.mu.Unlock()
+	if ok {
+		m.Broadcast(BroadcastMsg{Type: "point_visited", RobotID: r.ID, Data: v})
+	}
+}
+
+// ──────────────────────────── Visit store
+
+// visitFile is the on-disk form of a robot's visits.
+type visitFile struct {
+	Version int              `json:"version"`
+	Robot   string           `json:"robot"`
+	SavedAt time.Time        `json:"saved_at"`
+	Points  []visitFilePoint `json:"points,omitempty"`
+	Visits  []PointVisit     `json:"visits,omitempty"`
+}
+
+// visitFilePoint is a point's all-time counters on disk.
+type visitFilePoint struct {
+	Type rosbridge.PointType `json:"type"`
+	Name string              `json:"name"`
+	visitTotals
+}
+
+// VisitStore keeps visit files under Dir.
+type VisitStore struct {
+	Dir string
+}
+
+// NewVisitStore returns a store rooted at dir.
+func NewVisitStore(dir string) *VisitStore {
+	return &VisitStore{Dir: dir}
+}
+
+// Path returns the visit file of the robot with store key key.
+func (s *VisitStore) Path(key string) string {
+	return filepath.Join(s.Dir, safeFileName(key)+".json")
+}
+
+func (s *VisitStore) load(key string) (f visitFile, ok bool, err error) {
+	data, err := os.ReadFile(s.Path(key))
+	if os.IsNotExist(err) {
+		return visitFile{}, false, nil
+	}
+	if err != nil {
+		return visitFile{}, false, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return visitFile{}, false, err
+	}
+	if f.Version > visitFileVersion {
+		return visitFile{}, false, fmt.Errorf("schema version %d is newer than %d", f.Version, visitFileVersion)
+	}
+	return f, true, nil
+}
+
+func (s *VisitStore) write(f visitFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path(f.Robot), data)
+}
+
+// restoreVisits loads r's visits, if saved. Called by AddRobot.
+func (m *Manager) restoreVisits(r *Robot) {
+	key := r.StoreKey()
+	f, ok, err := m.Visits.load(key)
+	if err != nil {
+		log.Printf("[visits] %s: not restored: %v", key, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	totals := make(map[visitKey]visitTotals, len(f.Points))
+	for _, p := range f.Points {
+		totals[visitKey{p.Type, p.Name}] = p.visitTotals
+	}
+	history := f.Visits
+	if n := len(history) - VisitHistoryMax; n > 0 {
+		history = history[n:]
+	}
+	for i := range history {
+		history[i].RobotID = r.ID // IDs are given out anew each run
+	}
+	r.mu.Lock()
+	r.visits.totals = totals
+	r.visits.history = history
+	r.mu.Unlock()
+}
+
+// saveVisits writes r's visits if they changed since last saved.
+func (m *Manager) saveVisits(r *Robot) {
+	r.mu.Lock()
+	if !r.visits.dirty {
+		r.mu.Unlock()
+		return
+	}
+	f := visitFile{Version: visitFileVersion, Robot: r.StoreKey(), SavedAt: time.Now(),
+		Visits: append([]PointVisit(nil), r.visits.history...)}
+	for k, tot := range r.visits.totals {
+		f.Points = append(f.Points, visitFilePoint{Type: k.Type, Name: k.Name, visitTotals: tot})
+	}
+	r.visits.dirty = false
+	r.mu.Unlock()
+
+	sort.Slice(f.Points, func(i, j int) bool {
+		if f.Points[i].Type != f.Points[j].Type {
+			return f.Points[i].Type < f.Points[j].Type
+		}
+		return f.Points[i].Name < f.Points[j].Name
+	})
+	if err := m.Visits.write(f); err != nil {
+		log.Printf("[visits] %s: %v", f.Robot, err)
+		r.mu.Lock()
+		r.visits.dirty = true
+		r.mu.Unlock()
+	}
+}
+
+// RunVisitPersistence saves changed visits every interval until ctx is
+// cancelled. Without a store it returns at once.
+func (m *Manager) RunVisitPersistence(ctx context.Context, interval time.Duration) {
+	if m.Visits == nil {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		m.FlushVisits()
+	}
+}
+
+// FlushVisits saves every robot's changed visits now.
+func (m *Manager) FlushVisits() {
+	if m.Visits == nil {
+		return
+	}
+	for _, r := range m.GetAllRobots() {
+		m.saveVisits(r)
+	}
+}
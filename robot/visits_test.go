@@ -0,0 +1,171 @@
+package robot
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"rom_go_app/rosbridge"
+)
+
+// TestVisitDetector walks a robot along a line through a waypoint at the
+// origin, with the default 0.5 m radius and 0.3 m hysteresis.
+func TestVisitDetector(t *testing.T) {
+	dock := visitKey{rosbridge.PointWaypoint, "dock"}
+	desk := visitKey{rosbridge.PointService, "desk"}
+	points := []visitCandidate{{key: dock}, {key: desk, x: 3}}
+	var d visitDetector
+	visits := 0
+	walk := func(xs ...float64) {
+		t.Helper()
+		for _, x := range xs {
+			if k, dist, ok := d.step(points, x, 0, DefaultVisitRadiusM, DefaultVisitHysteresisM); ok {
+				visits++
+				if k != dock || dist != math.Abs(x) {
+					t.Errorf("at %v: visit %v at %v m", x, k, dist)
+				}
+			}
+		}
+	}
+
+	walk(-2, -1, -0.6)
+	if visits != 0 {
+		t.Fatalf("%d visits outside the radius", visits)
+	}
+	walk(-0.5)
+	if visits != 1 {
+		t.Fatalf("%d visits on entering the radius", visits)
+	}
+	// Hovering at the edge, in and out of the radius but within the
+	// hysteresis, is the same visit
+	walk(-0.6, -0.45, -0.79, -0.5, 0, 0.7, 0.4)
+	if visits != 1 {
+		t.Errorf("%d visits hovering at the edge", visits)
+	}
+	// Out beyond radius+hysteresis, then back in
+	walk(0.81, 0.6, 0.5)
+	if visits != 2 {
+		t.Errorf("%d visits after leaving and coming back", visits)
+	}
+
+	// A goal arriving where proximity already counted is not a visit
+	if d.arrive(dock) {
+		t.Error("goal arrival counted twice")
+	}
+	// The other way round: a goal arrives, then the pose comes in range
+	d.step(points, 1.5, 0, DefaultVisitRadiusM, DefaultVisitHysteresisM)
+	if !d.arrive(desk) {
+		t.Error("goal arrival not counted")
+	}
+	if _, _, ok := d.step(points, 2.6, 0, DefaultVisitRadiusM, DefaultVisitHysteresisM); ok {
+		t.Error("proximity counted a goal arrival again")
+	}
+
+	// The nearest of overlapping points is visited
+	d = visitDetector{}
+	overlap := []visitCandidate{{key: dock}, {key: desk, x: 0.6}}
+	if k, _, ok := d.step(overlap, 0.35, 0, DefaultVisitRadiusM, DefaultVisitHysteresisM); !ok || k != desk {
+		t.Errorf("overlapping points: %v, %v", k, ok)
+	}
+
+	// A point removed while the robot is at it releases the detector
+	if _, _, ok := d.step(overlap[:1], 0.35, 0, DefaultVisitRadiusM, DefaultVisitHysteresisM); !ok {
+		t.Error("no visit to the remaining point after the other was removed")
+	}
+}
+
+func TestVisitReport(t *testing.T) {
+	now := time.Date(2026, 5, 4, 15, 0, 0, 0, time.Local)
+	var tr visitTracker
+	visit := func(typ rosbridge.PointType, name string, at time.Time) {
+		tr.add(PointVisit{Type: typ, Name: name, At: at, Source: VisitProximity})
+	}
+	visit(rosbridge.PointWaypoint, "dock", now.Add(-30*time.Hour))
+	visit(rosbridge.PointWaypoint, "dock", now.Add(-2*time.Hour))
+	visit(rosbridge.PointService, "desk", now.Add(-90*time.Minute))
+	visit(rosbridge.PointWaypoint, "dock", now.Add(-time.Hour))
+
+	rep := tr.report(VisitQuery{}, now)
+	if len(rep.Visits) != 4 || !rep.Visits[0].At.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("visits %+v", rep.Visits)
+	}
+	if len(rep.Points) != 2 {
+		t.Fatalf("points %+v", rep.Points)
+	}
+	if p := rep.Points[0]; p.Name != "dock" || p.Count != 3 || p.Today != 2 || p.Total != 3 || !p.LastVisited.Equal(now.Add(-time.Hour)) {
+		t.Errorf("dock %+v", p)
+	}
+	if p := rep.Points[1]; p.Name != "desk" || p.Count != 1 || p.Today != 1 {
+		t.Errorf("desk %+v", p)
+	}
+
+	// Filters narrow the visits and counts; totals stay all-time
+	rep = tr.report(VisitQuery{Type: rosbridge.PointWaypoint, Since: now.Add(-3 * time.Hour), Until: now.Add(-90 * time.Minute)}, now)
+	if len(rep.Visits) != 1 || len(rep.Points) != 1 || rep.Points[0].Count != 1 || rep.Points[0].Total != 3 {
+		t.Errorf("filtered %+v", rep)
+	}
+	rep = tr.report(VisitQuery{Name: "dock", Limit: 2}, now)
+	if len(rep.Visits) != 2 || rep.Points[0].Count != 3 {
+		t.Errorf("limited %+v", rep)
+	}
+	if rep := tr.report(VisitQuery{Name: "lift"}, now); rep.Visits == nil || rep.Points == nil || len(rep.Visits)+len(rep.Points) != 0 {
+		t.Errorf("no match %+v", rep)
+	}
+}
+
+func TestVisitHistoryBound(t *testing.T) {
+	var tr visitTracker
+	t0 := time.Now()
+	for i := 0; i < VisitHistoryMax+5; i++ {
+		tr.add(PointVisit{Type: rosbridge.PointWaypoint, Name: "dock", At: t0.Add(time.Duration(i) * time.Second)})
+	}
+	if len(tr.history) != VisitHistoryMax || !tr.history[0].At.Equal(t0.Add(5*time.Second)) {
+		t.Errorf("history %d, oldest %v", len(tr.history), tr.history[0].At.Sub(t0))
+	}
+	if tot := tr.totals[visitKey{rosbridge.PointWaypoint, "dock"}]; tot.Total != VisitHistoryMax+5 {
+		t.Errorf("total %d", tot.Total)
+	}
+}
+
+// TestVisitStore saves a robot's visits through the manager and adds the
+// robot again.
+func TestVisitStore(t *testing.T) {
+	m := NewManager()
+	m.Visits = NewVisitStore(t.TempDir())
+	r, _ := m.AddRobot("amr", "", "127.0.0.1", 9)
+	at := time.Now().Add(-time.Minute).Round(0)
+	r.mu.Lock()
+	r.visits.add(PointVisit{RobotID: r.ID, Type: rosbridge.PointPatrol, Name: "p1", At: at, Source: VisitNavStatus})
+	r.mu.Unlock()
+
+	m.FlushVisits()
+	path := m.Visits.Path("amr")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.FlushVisits() // unchanged: not written again
+	if again, _ := os.Stat(path); !again.ModTime().Equal(info.ModTime()) {
+		t.Error("unchanged visits rewritten")
+	}
+	m.RemoveRobot(r.ID)
+
+	r, _ = m.AddRobot("amr", "", "127.0.0.1", 9)
+	rep := r.VisitReport(VisitQuery{})
+	if len(rep.Visits) != 1 || rep.Visits[0].RobotID != r.ID || !rep.Visits[0].At.Equal(at) || len(rep.Points) != 1 || rep.Points[0].Total != 1 {
+		t.Errorf("restored %+v", rep)
+	}
+	if last := r.LastVisits()[rosbridge.PointPatrol]["p1"]; !last.Equal(at) {
+		t.Errorf("last visit %v", last)
+	}
+	m.RemoveRobot(r.ID)
+
+	// A newer schema is left alone
+	os.WriteFile(path, []byte(`{"version": 2, "robot": "amr", "visits": [{"name": "p1"}]}`), 0644)
+	r, _ = m.AddRobot("amr", "", "127.0.0.1", 9)
+	defer m.RemoveRobot(r.ID)
+	if rep := r.VisitReport(VisitQuery{}); len(rep.Visits) != 0 {
+		t.Errorf("newer schema read: %+v", rep.Visits)
+	}
+}
@@ -0,0 +1,144 @@
+package rosbridge
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ──────────────────────────── Bandwidth accounting
+//
+// Every websocket frame read or written is counted with atomic adds, and
+// publish payloads are attributed to their topic. Sizes are websocket
+// payload bytes (after CBOR, before decoding); framing and TCP/IP
+// overhead are not included.
+//
+// Counters live as long as the Client — they are cumulative across
+// reconnects and across both connections when split is enabled, and only
+// reset when the robot is removed. Connections counts successful dials so
+// reconnect churn is visible next to the byte totals.
+
+const (
+	bandwidthWindow      = time.Minute
+	bandwidthSampleEvery = 5 * time.Second
+)
+
+type bandwidth struct {
+	since       time.Time
+	bytesIn     atomic.Uint64
+	bytesOut    atomic.Uint64
+	msgsIn      atomic.Uint64
+	msgsOut     atomic.Uint64
+	connections atomic.Uint64
+	topics      sync.Map // topic → *topicCounter
+
+	mu      sync.Mutex
+	samples []bandwidthSample // oldest first; samples[0] is the rate baseline
+}
+
+type topicCounter struct {
+	bytes atomic.Uint64
+	msgs  atomic.Uint64
+}
+
+type bandwidthSample struct {
+	at      time.Time
+	in, out uint64
+	topics  map[string]uint64
+}
+
+// BandwidthStats is a snapshot of a client's traffic. Rates are bytes per
+// second over the last WindowSeconds (about a minute).
+type BandwidthStats struct {
+	Since         time.Time        `json:"since"`
+	Connections   uint64           `json:"connections"`
+	BytesIn       uint64           `json:"bytes_in"`
+	BytesOut      uint64           `json:"bytes_out"`
+	MessagesIn    uint64           `json:"messages_in"`
+	MessagesOut   uint64           `json:"messages_out"`
+	RateIn        float64          `json:"rate_in_bps"`
+	RateOut       float64          `json:"rate_out_bps"`
+	WindowSeconds float64          `json:"window_seconds"`
+	Topics        []TopicBandwidth `json:"topics"`
+}
+
+// TopicBandwidth is the inbound traffic of one subscribed topic.
+type TopicBandwidth struct {
+	Topic    string  `json:"topic"`
+	Bytes    uint64  `json:"bytes"`
+	Messages uint64  `json:"messages"`
+	Rate     float64 `json:"rate_bps"`
+}
+
+func (b *bandwidth) read(n int) {
+	b.bytesIn.Add(uint64(n))
+	b.msgsIn.Add(1)
+}
+
+func (b *bandwidth) wrote(n int) {
+	b.bytesOut.Add(uint64(n))
+	b.msgsOut.Add(1)
+}
+
+func (b *bandwidth) topic(topic string, n int) {
+	v, ok := b.topics.Load(topic)
+	if !ok {
+		v, _ = b.topics.LoadOrStore(topic, &topicCounter{})
+	}
+	tc := v.(*topicCounter)
+	tc.bytes.Add(uint64(n))
+	tc.msgs.Add(1)
+}
+
+// stats snapshots the counters and computes rates against the oldest
+// sample no older than the window. A sample is recorded at most every
+// bandwidthSampleEvery, so rates need stats to be called periodically
+// (the manager's bandwidth report does this).
+func (b *bandwidth) stats() BandwidthStats {
+	now := time.Now()
+	st := BandwidthStats{
+		Since:       b.since,
+		Connections: b.connections.Load(),
+		BytesIn:     b.bytesIn.Load(),
+		BytesOut:    b.bytesOut.Load(),
+		MessagesIn:  b.msgsIn.Load(),
+		MessagesOut: b.msgsOut.Load(),
+		Topics:      []TopicBandwidth{},
+	}
+	cur := bandwidthSample{at: now, in: st.BytesIn, out: st.BytesOut, topics: map[string]uint64{}}
+	b.topics.Range(func(k, v interface{}) bool {
+		tc := v.(*topicCounter)
+		t := TopicBandwidth{Topic: k.(string), Bytes: tc.bytes.Load(), Messages: tc.msgs.Load()}
+		cur.topics[t.Topic] = t.Bytes
+		st.Topics = append(st.Topics, t)
+		return true
+	})
+	sort.Slice(st.Topics, func(i, j int) bool { return st.Topics[i].Topic < st.Topics[j].Topic })
+
+	b.mu.Lock()
+	if n := len(b.samples); n == 0 || now.Sub(b.samples[n-1].at) >= bandwidthSampleEvery {
+		b.samples = append(b.samples, cur)
+	}
+	for len(b.samples) > 1 && now.Sub(b.samples[0].at) > bandwidthWindow {
+		b.samples = b.samples[1:]
+	}
+	base := b.samples[0]
+	b.mu.Unlock()
+
+	if elapsed := now.Sub(base.at).Seconds(); elapsed >= 1 {
+		st.WindowSeconds = elapsed
+		st.RateIn = float64(st.BytesIn-base.in) / elapsed
+		st.RateOut = float64(st.BytesOut-base.out) / elapsed
+		for i := range st.Topics {
+			t := &st.Topics[i]
+			t.Rate = float64(t.Bytes-base.topics[t.Topic]) / elapsed
+		}
+	}
+	return st
+}
+
+// Bandwidth returns the client's traffic counters and rolling rates.
+func (c *Client) Bandwidth() BandwidthStats {
+	return c.bw.stats()
+}
@@ -0,0 +1,167 @@
+package rosbridge
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const bandwidthPublish = `{"op":"publish","topic":"/battery","msg":{"data":87.5}}`
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func topicBytes(st BandwidthStats, topic string) (uint64, uint64) {
+	for _, tb := range st.Topics {
+		if tb.Topic == topic {
+			return tb.Bytes, tb.Messages
+		}
+	}
+	return 0, 0
+}
+
+// TestBandwidthPersistsAcrossReconnects checks the documented policy:
+// counters are cumulative for the life of the Client, Connections counts
+// each successful dial, and Since does not move on reconnect.
+func TestBandwidthPersistsAcrossReconnects(t *testing.T) {
+	s := newFakeServer(t)
+	s.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		conn.WriteMessage(websocket.TextMessage, []byte(bandwidthPublish))
+		serveCalls(idx, conn, s)
+	}
+	c := s.client(t, "")
+
+	before := c.Bandwidth()
+	if before.BytesIn != 0 || before.BytesOut != 0 || before.Connections != 0 || len(before.Topics) != 0 {
+		t.Fatalf("new client has traffic: %+v", before)
+	}
+
+	session := func(n uint64) BandwidthStats {
+		t.Helper()
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, "publish", func() bool { _, msgs := topicBytes(c.Bandwidth(), "/battery"); return msgs == n })
+		if _, err := c.CallService("/ping", nil, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		return c.Bandwidth()
+	}
+
+	first := session(1)
+	if first.Connections != 1 {
+		t.Errorf("connections = %d, want 1", first.Connections)
+	}
+	if b, _ := topicBytes(first, "/battery"); b != uint64(len(bandwidthPublish)) {
+		t.Errorf("topic bytes = %d, want %d", b, len(bandwidthPublish))
+	}
+	if first.BytesOut == 0 || first.MessagesOut == 0 {
+		t.Errorf("service call not counted as outbound: %+v", first)
+	}
+	// The publish and the service response
+	if first.MessagesIn < 2 || first.BytesIn < uint64(len(bandwidthPublish)) {
+		t.Errorf("inbound = %d msgs / %d bytes", first.MessagesIn, first.BytesIn)
+	}
+
+	c.Disconnect()
+	afterDisconnect := c.Bandwidth()
+	if afterDisconnect.BytesIn != first.BytesIn || afterDisconnect.BytesOut != first.BytesOut {
+		t.Errorf("disconnect changed the counters: %+v → %+v", first, afterDisconnect)
+	}
+
+	second := session(2)
+	if second.Connections != 2 {
+		t.Errorf("connections = %d, want 2", second.Connections)
+	}
+	if !second.Since.Equal(first.Since) {
+		t.Errorf("since moved on reconnect: %v → %v", first.Since, second.Since)
+	}
+	if second.BytesIn < 2*uint64(len(bandwidthPublish)) || second.BytesIn <= first.BytesIn {
+		t.Errorf("bytes in not cumulative: %d then %d", first.BytesIn, second.BytesIn)
+	}
+	if second.BytesOut <= first.BytesOut || second.MessagesOut <= first.MessagesOut {
+		t.Errorf("bytes out not cumulative: %d then %d", first.BytesOut, second.BytesOut)
+	}
+	if b, msgs := topicBytes(second, "/battery"); msgs != 2 || b != 2*uint64(len(bandwidthPublish)) {
+		t.Errorf("topic = %d msgs / %d bytes, want 2 / %d", msgs, b, 2*len(bandwidthPublish))
+	}
+}
+
+func TestBandwidthNewClientStartsAtZero(t *testing.T) {
+	// A removed and re-added robot gets a new Client, which is how the
+	// counters reset.
+	c := NewClient("", "127.0.0.1", 9)
+	defer c.Close()
+	st := c.Bandwidth()
+	if st.BytesIn != 0 || st.BytesOut != 0 || st.MessagesIn != 0 || st.MessagesOut != 0 || st.Connections != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+	if st.Since.IsZero() || time.Since(st.Since) > time.Minute {
+		t.Errorf("since = %v", st.Since)
+	}
+	if st.Topics == nil {
+		t.Error("topics should encode as [] rather than null")
+	}
+}
+
+func TestBandwidthRates(t *testing.T) {
+	now := time.Now()
+	b := &bandwidth{since: now.Add(-2 * time.Minute)}
+	b.samples = []bandwidthSample{
+		{at: now.Add(-90 * time.Second), in: 0, out: 0, topics: map[string]uint64{}},               // outside the window
+		{at: now.Add(-30 * time.Second), in: 1000, out: 300, topics: map[string]uint64{"/a": 600}}, // baseline
+	}
+	b.bytesIn.Add(4000)
+	b.bytesOut.Add(600)
+	b.topic("/a", 1500)
+	b.topic("/a", 1500)
+	b.topic("/b", 900)
+
+	st := b.stats()
+	near := func(got, want float64) bool { return math.Abs(got-want) < want*0.01 }
+	if !near(st.WindowSeconds, 30) {
+		t.Fatalf("window = %v, want about 30s (old sample dropped)", st.WindowSeconds)
+	}
+	if !near(st.RateIn, 3000.0/30) || !near(st.RateOut, 300.0/30) {
+		t.Errorf("rates = %v in / %v out", st.RateIn, st.RateOut)
+	}
+	want := map[string]float64{"/a": 2400.0 / 30, "/b": 900.0 / 30}
+	if len(st.Topics) != 2 || st.Topics[0].Topic != "/a" || st.Topics[1].Topic != "/b" {
+		t.Fatalf("topics = %+v", st.Topics)
+	}
+	for _, tb := range st.Topics {
+		if !near(tb.Rate, want[tb.Topic]) {
+			t.Errorf("%s rate = %v, want %v", tb.Topic, tb.Rate, want[tb.Topic])
+		}
+	}
+	if st.Topics[0].Messages != 2 || st.Topics[0].Bytes != 3000 {
+		t.Errorf("/a = %+v", st.Topics[0])
+	}
+
+	// The stats call records a sample, but not more often than
+	// bandwidthSampleEvery.
+	b.stats()
+	if n := len(b.samples); n != 2 {
+		t.Errorf("%d samples after two quick stats calls, want 2", n)
+	}
+}
+
+func TestBandwidthNoRateWithoutHistory(t *testing.T) {
+	b := &bandwidth{since: time.Now()}
+	b.bytesIn.Add(500)
+	st := b.stats()
+	if st.RateIn != 0 || st.WindowSeconds != 0 {
+		t.Errorf("first sample produced a rate: %+v", st)
+	}
+}
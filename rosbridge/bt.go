@@ -0,0 +1,143 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ──────────────────────────── Behavior tree templates & preview
+//
+// construct_yaml_and_bt builds a behavior tree from the points it is
+// sent, after one of its templates. add_<name> requests may name the
+// template in bt_template; without it the robot picks its default. A
+// robot lists the templates it has as capabilities bt_<template>, and
+// bt_preview when it answers preview_bt requests: the same payload as
+// add_<name> plus points_type, answered with the generated tree instead
+// of storing or running it:
+//
+//	{"result": true, "values": {"bt": "<root>…</root>", "format": "xml"}}
+//
+// The tree may also come as bt_xml, xml or yaml, or as a bare string.
+
+// Behavior tree templates of current firmware.
+const (
+	BTSequential = "sequential"
+	BTRoundRobin = "round_robin"
+	BTWithRetry  = "with_retry"
+)
+
+// BTTemplates are the templates assumed on robots that don't report
+// capabilities.
+var BTTemplates = []string{BTSequential, BTRoundRobin, BTWithRetry}
+
+// CapBTPreview is the capability of robots answering preview_bt.
+const CapBTPreview = "bt_preview"
+
+// BTTemplateCapability is the capability a robot lists for template t.
+func BTTemplateCapability(t string) string { return "bt_" + t }
+
+// ErrBTPreviewUnsupported is returned when the robot's answer to
+// preview_bt carries no tree: firmware without the request.
+var ErrBTPreviewUnsupported = errors.New("robot firmware has no behavior tree preview")
+
+// BT preview formats.
+const (
+	BTFormatXML  = "xml"
+	BTFormatYAML = "yaml"
+)
+
+// BTPreview is a behavior tree the robot generated without running it.
+// Content is cut to the requested size; Bytes is its full size.
+type BTPreview struct {
+	Format    string `json:"format"` // xml or yaml
+	Content   string `json:"content"`
+	Bytes     int    `json:"bytes"`
+	Truncated bool   `json:"truncated"`
+}
+
+// GetBTPreview asks the robot which behavior tree it would build from
+// pts of type t with template (empty: its default), keeping at most
+// maxBytes of it (0: all).
+func (c *Client) GetBTPreview(t PointType, pts []NavigationPoint, template string, maxBytes int) (*BTPreview, error) {
+	name, err := t.navWireName()
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{
+		"request_string": "preview_bt",
+		"points_type":    name,
+		name:             WaypointToJSON(pts),
+	}
+	if template != "" {
+		args["bt_template"] = template
+	}
+	raw, err := c.CallService("/construct_yaml_and_bt", args, 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBTPreview(raw, maxBytes)
+}
+
+// ParseBTPreview decodes a preview_bt response, truncating the tree to
+// maxBytes (0: no limit) at a character boundary.
+func ParseBTPreview(raw json.RawMessage, maxBytes int) (*BTPreview, error) {
+	var resp struct {
+		Result *bool           `json:"result"`
+		Values json.RawMessage `json:"values"`
+	}
+	json.Unmarshal(raw, &resp)
+	if resp.Result != nil && !*resp.Result {
+		return nil, ErrBTPreviewUnsupported
+	}
+	body := resp.Values
+	if len(body) == 0 {
+		body = raw
+	}
+
+	var p BTPreview
+	var s string
+	if json.Unmarshal(body, &s) == nil {
+		p.Content = s
+	} else {
+		var v struct {
+			BT     string `json:"bt"`
+			BTXML  string `json:"bt_xml"`
+			XML    string `json:"xml"`
+			YAML   string `json:"yaml"`
+			Format string `json:"format"`
+		}
+		json.Unmarshal(body, &v)
+		switch {
+		case v.BT != "":
+			p.Content, p.Format = v.BT, strings.ToLower(v.Format)
+		case v.BTXML != "":
+			p.Content, p.Format = v.BTXML, BTFormatXML
+		case v.XML != "":
+			p.Content, p.Format = v.XML, BTFormatXML
+		case v.YAML != "":
+			p.Content, p.Format = v.YAML, BTFormatYAML
+		}
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		return nil, ErrBTPreviewUnsupported
+	}
+	if p.Format != BTFormatXML && p.Format != BTFormatYAML {
+		p.Format = BTFormatYAML
+		if strings.HasPrefix(strings.TrimSpace(p.Content), "<") {
+			p.Format = BTFormatXML
+		}
+	}
+
+	p.Bytes = len(p.Content)
+	if maxBytes > 0 && len(p.Content) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(p.Content[cut]) {
+			cut--
+		}
+		p.Content, p.Truncated = p.Content[:cut], true
+	}
+	return &p, nil
+}
@@ -0,0 +1,104 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ──────────────────────────── Capability discovery
+//
+// Newer firmware reports its software version and the features it has in
+// the which_name handshake (software_version, capabilities). Firmware
+// that doesn't may answer a which_tasks request named "get_capabilities"
+// instead, with response_settings holding a {"software_version",
+// "capabilities"} object, a JSON array of names, or names separated by
+// newlines or commas. Legacy firmware does neither and is assumed to
+// support everything.
+
+// DefaultCapabilitiesRequest is the which_tasks task_name asking for the
+// robot's capabilities.
+const DefaultCapabilitiesRequest = "get_capabilities"
+
+// Capabilities a robot may report. Point collections use
+// PointType.Capability.
+const (
+	CapWaypoints     = "waypoints"
+	CapServicePoints = "service_points"
+	CapPatrolPoints  = "patrol_points"
+	CapPathPoints    = "path_points"
+	CapWallObstacles = "wall_obstacles"
+	CapMapping       = "mapping"
+	CapRemapping     = "remapping"
+	CapMapSave       = "map_save"
+	CapMapSelect     = "map_select"
+	CapTasks         = "tasks"     // which_tasks requests
+	CapHolonomic     = "holonomic" // the base strafes (cmd_vel linear.y)
+)
+
+// ErrCapabilitiesUnsupported is returned when the robot answered the
+// capabilities request without a capability list.
+var ErrCapabilitiesUnsupported = errors.New("robot does not report its capabilities")
+
+// Capability is the capability covering point collection t.
+func (t PointType) Capability() string {
+	switch t {
+	case PointWaypoint:
+		return CapWaypoints
+	case PointService:
+		return CapServicePoints
+	case PointPatrol:
+		return CapPatrolPoints
+	case PointPath:
+		return CapPathPoints
+	case PointWall:
+		return CapWallObstacles
+	}
+	return ""
+}
+
+// ParseCapabilities decodes a capabilities response payload into the
+// software version (empty if not given) and capability names.
+func ParseCapabilities(payload string) (version string, caps []string, err error) {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		return "", nil, ErrCapabilitiesUnsupported
+	}
+
+	var names []string
+	switch {
+	case strings.HasPrefix(payload, "{"):
+		var obj struct {
+			SoftwareVersion string   `json:"software_version"`
+			Capabilities    []string `json:"capabilities"`
+		}
+		if json.Unmarshal([]byte(payload), &obj) != nil || obj.Capabilities == nil {
+			return "", nil, ErrCapabilitiesUnsupported
+		}
+		version, names = obj.SoftwareVersion, obj.Capabilities
+	case strings.HasPrefix(payload, "["):
+		if json.Unmarshal([]byte(payload), &names) != nil {
+			return "", nil, ErrCapabilitiesUnsupported
+		}
+	default:
+		names = strings.FieldsFunc(payload, func(r rune) bool { return r == '\n' || r == ',' })
+	}
+	return strings.TrimSpace(version), NormalizeCapabilities(names), nil
+}
+
+// NormalizeCapabilities lowercases and trims names and drops blanks and
+// duplicates. The result is non-nil: an empty list is a robot that
+// reported no optional features, not an unknown one.
+func NormalizeCapabilities(names []string) []string {
+	out := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	return out
+}
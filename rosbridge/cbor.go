@@ -0,0 +1,288 @@
+package rosbridge
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ──────────────────────────── Minimal CBOR decoder
+//
+// rosbridge sends subscriptions with "compression": "cbor" as binary
+// frames. This decoder covers what rosbridge emits: definite-length items,
+// floats, and RFC 8746 typed arrays for numeric ROS arrays. Decoded values
+// use JSON-compatible Go types (map[string]interface{}, []interface{},
+// float64, string, bool, nil) so the result can go through the normal
+// JSON parsing path. NaN/Inf become nil, matching rosbridge's JSON output.
+
+var errCBORShort = errors.New("cbor: unexpected end of data")
+
+// DecodeCBOR decodes a single CBOR item.
+func DecodeCBOR(data []byte) (interface{}, error) {
+	d := cborDecoder{buf: data}
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+type cborDecoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *cborDecoder) need(n int) error {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return errCBORShort
+	}
+	return nil
+}
+
+func (d *cborDecoder) readArg(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		if err := d.need(1); err != nil {
+			return 0, err
+		}
+		v := d.buf[d.pos]
+		d.pos++
+		return uint64(v), nil
+	case info == 25:
+		if err := d.need(2); err != nil {
+			return 0, err
+		}
+		v := binary.BigEndian.Uint16(d.buf[d.pos:])
+		d.pos += 2
+		return uint64(v), nil
+	case info == 26:
+		if err := d.need(4); err != nil {
+			return 0, err
+		}
+		v := binary.BigEndian.Uint32(d.buf[d.pos:])
+		d.pos += 4
+		return uint64(v), nil
+	case info == 27:
+		if err := d.need(8); err != nil {
+			return 0, err
+		}
+		v := binary.BigEndian.Uint64(d.buf[d.pos:])
+		d.pos += 8
+		return v, nil
+	}
+	return 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+}
+
+func (d *cborDecoder) decode() (interface{}, error) {
+	if err := d.need(1); err != nil {
+		return nil, err
+	}
+	ib := d.buf[d.pos]
+	d.pos++
+	major, info := ib>>5, ib&0x1f
+
+	if major == 7 {
+		return d.decodeSimple(info)
+	}
+
+	arg, err := d.readArg(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		return float64(arg), nil
+	case 1:
+		return -1 - float64(arg), nil
+	case 2:
+		return d.readBytes(arg)
+	case 3:
+		b, err := d.readBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4:
+		if arg > uint64(len(d.buf)) {
+			return nil, errCBORShort
+		}
+		out := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case 5:
+		if arg > uint64(len(d.buf)) {
+			return nil, errCBORShort
+		}
+		out := make(map[string]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprint(k)] = v
+		}
+		return out, nil
+	case 6:
+		return d.decodeTag(arg)
+	}
+	return nil, fmt.Errorf("cbor: unsupported major type %d", major)
+}
+
+func (d *cborDecoder) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.buf)) {
+		return nil, errCBORShort
+	}
+	if err := d.need(int(n)); err != nil {
+		return nil, err
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *cborDecoder) decodeSimple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		if err := d.need(2); err != nil {
+			return nil, err
+		}
+		v := halfToFloat(binary.BigEndian.Uint16(d.buf[d.pos:]))
+		d.pos += 2
+		return jsonFloat(v), nil
+	case 26:
+		if err := d.need(4); err != nil {
+			return nil, err
+		}
+		v := math.Float32frombits(binary.BigEndian.Uint32(d.buf[d.pos:]))
+		d.pos += 4
+		return jsonFloat(float64(v)), nil
+	case 27:
+		if err := d.need(8); err != nil {
+			return nil, err
+		}
+		v := math.Float64frombits(binary.BigEndian.Uint64(d.buf[d.pos:]))
+		d.pos += 8
+		return jsonFloat(v), nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
+
+// decodeTag handles RFC 8746 typed arrays; other tags pass their content
+// through unchanged.
+func (d *cborDecoder) decodeTag(tag uint64) (interface{}, error) {
+	if tag < 64 || tag > 87 {
+		return d.decode()
+	}
+
+	content, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := content.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cbor: typed array tag %d without byte string", tag)
+	}
+
+	var size int
+	var read func([]byte) float64
+	be, le := binary.BigEndian, binary.LittleEndian
+	switch tag {
+	case 64, 68:
+		size, read = 1, func(p []byte) float64 { return float64(p[0]) }
+	case 72:
+		size, read = 1, func(p []byte) float64 { return float64(int8(p[0])) }
+	case 65:
+		size, read = 2, func(p []byte) float64 { return float64(be.Uint16(p)) }
+	case 69:
+		size, read = 2, func(p []byte) float64 { return float64(le.Uint16(p)) }
+	case 66:
+		size, read = 4, func(p []byte) float64 { return float64(be.Uint32(p)) }
+	case 70:
+		size, read = 4, func(p []byte) float64 { return float64(le.Uint32(p)) }
+	case 67:
+		size, read = 8, func(p []byte) float64 { return float64(be.Uint64(p)) }
+	case 71:
+		size, read = 8, func(p []byte) float64 { return float64(le.Uint64(p)) }
+	case 73:
+		size, read = 2, func(p []byte) float64 { return float64(int16(be.Uint16(p))) }
+	case 77:
+		size, read = 2, func(p []byte) float64 { return float64(int16(le.Uint16(p))) }
+	case 74:
+		size, read = 4, func(p []byte) float64 { return float64(int32(be.Uint32(p))) }
+	case 78:
+		size, read = 4, func(p []byte) float64 { return float64(int32(le.Uint32(p))) }
+	case 75:
+		size, read = 8, func(p []byte) float64 { return float64(int64(be.Uint64(p))) }
+	case 79:
+		size, read = 8, func(p []byte) float64 { return float64(int64(le.Uint64(p))) }
+	case 81:
+		size, read = 4, func(p []byte) float64 { return float64(math.Float32frombits(be.Uint32(p))) }
+	case 85:
+		size, read = 4, func(p []byte) float64 { return float64(math.Float32frombits(le.Uint32(p))) }
+	case 82:
+		size, read = 8, func(p []byte) float64 { return math.Float64frombits(be.Uint64(p)) }
+	case 86:
+		size, read = 8, func(p []byte) float64 { return math.Float64frombits(le.Uint64(p)) }
+	default:
+		return nil, fmt.Errorf("cbor: unsupported typed array tag %d", tag)
+	}
+
+	if len(b)%size != 0 {
+		return nil, fmt.Errorf("cbor: typed array length %d not a multiple of %d", len(b), size)
+	}
+	out := make([]interface{}, len(b)/size)
+	for i := range out {
+		out[i] = jsonFloat(read(b[i*size:]))
+	}
+	return out, nil
+}
+
+// jsonFloat maps NaN/Inf to nil, since encoding/json rejects them.
+func jsonFloat(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
+
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
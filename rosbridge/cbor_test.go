@@ -0,0 +1,128 @@
+package rosbridge
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Publish frames as rosbridge's CBOR encoder sends them: float64 scalars
+// (float32 for the grid resolution), and numeric arrays as RFC 8746
+// typed arrays — little-endian float32 ranges (tag 85, the last one
+// +Inf) and int8 grid cells (tag 72).
+const (
+	laserFrameCBOR = "a3626f70677075626c69736865746f706963652f7363616e636d7367aa66686561646572a2657374616d70a2637365631a6553f100676e616e6f7365631a1dcd6500686672616d655f6964656c6173657269616e676c655f6d696efbbff800000000000069616e676c655f6d6178fb3ff80000000000006f616e676c655f696e6372656d656e74fb3ff80000000000006e74696d655f696e6372656d656e74fb0000000000000000697363616e5f74696d65fb3fb999999999999a6972616e67655f6d696efb3fb999999999999a6972616e67655f6d6178fb40280000000000006672616e676573d8554c0000803f000020400000807f6b696e74656e736974696573d85540"
+	mapFrameCBOR   = "a3626f70677075626c69736865746f706963642f6d6170636d7367a366686561646572a2657374616d70a2637365631a6553f100676e616e6f7365631a1dcd6500686672616d655f6964636d617064696e666fa56d6d61705f6c6f61645f74696d65a2637365631a6553f100676e616e6f7365631a1dcd65006a7265736f6c7574696f6efa3d4ccccd657769647468036668656967687401666f726967696ea268706f736974696f6ea36178fbbff00000000000006179fb4000000000000000617afb00000000000000006b6f7269656e746174696f6ea46178fb00000000000000006179fb0000000000000000617afb00000000000000006177fb3ff00000000000006464617461d84843ff0064"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"uint", "1864", 100.0},
+		{"negative", "3818", -25.0},
+		{"uint32", "1a6553f100", 1700000000.0},
+		{"half", "f93e00", 1.5},
+		{"half subnormal", "f90001", 5.960464477539063e-08},
+		{"half infinity", "f97c00", nil},
+		{"float32", "fa3fc00000", 1.5},
+		{"float64 NaN", "fb7ff8000000000000", nil},
+		{"true", "f5", true},
+		{"null", "f6", nil},
+		{"text", "6461626364", "abcd"},
+		{"bytes", "43010203", []byte{1, 2, 3}},
+		{"array", "8301f4f6", []interface{}{1.0, false, nil}},
+		{"map", "a2616101616282f5f4", map[string]interface{}{"a": 1.0, "b": []interface{}{true, false}}},
+		{"int8 typed array", "d84843ff0064", []interface{}{-1.0, 0.0, 100.0}},
+		{"uint16 LE typed array", "d8454401000001", []interface{}{1.0, 256.0}},
+		{"float64 LE typed array", "d856480000000000000840", []interface{}{3.0}},
+		{"other tag passes through", "c11a6553f100", 1700000000.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCBOR(mustHex(t, tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"empty":                  "",
+		"truncated uint":         "19ff",
+		"truncated text":         "6461",
+		"huge array":             "9b00000000ffffffff",
+		"indefinite array":       "9f01ff",
+		"typed array odd length": "d845430100ff",
+		"typed array not bytes":  "d84801",
+		"truncated frame":        laserFrameCBOR[:len(laserFrameCBOR)-10],
+	} {
+		t.Run(name, func(t *testing.T) {
+			b, _ := hex.DecodeString(in)
+			if v, err := DecodeCBOR(b); err == nil {
+				t.Errorf("decoded %#v, want an error", v)
+			}
+		})
+	}
+}
+
+func TestCBORPublishFrames(t *testing.T) {
+	c := NewClient("", "127.0.0.1", 9)
+	defer c.Close()
+	c.SubscribeLaser("")
+	c.SubscribeMap("")
+
+	scans := make(chan LaserData, 1)
+	maps := make(chan MapData, 1)
+	c.AddLaserHandler(func(d LaserData) { scans <- d })
+	c.AddMapHandler(func(d MapData) { maps <- d })
+
+	c.handleFrame(websocket.BinaryMessage, mustHex(t, laserFrameCBOR))
+	c.handleFrame(websocket.BinaryMessage, mustHex(t, mapFrameCBOR))
+
+	select {
+	case scan := <-scans:
+		want := LaserData{FrameID: "laser", AngleMin: -1.5, AngleMax: 1.5, AngleIncrement: 1.5,
+			RangeMin: 0.1, RangeMax: 12, Ranges: []float64{1, 2.5, 0}}
+		if !reflect.DeepEqual(scan, want) {
+			t.Errorf("scan = %+v\nwant   %+v", scan, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no laser scan from the CBOR frame")
+	}
+
+	select {
+	case m := <-maps:
+		if m.Width != 3 || m.Height != 1 || m.OriginX != -1 || m.OriginY != 2 ||
+			m.Resolution != float64(float32(0.05)) || !reflect.DeepEqual(m.Data, []int8{-1, 0, 100}) {
+			t.Errorf("map = %+v", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no map from the CBOR frame")
+	}
+
+	for _, tb := range c.Bandwidth().Topics {
+		if tb.Topic == "/scan" && tb.Bytes != uint64(len(laserFrameCBOR)/2) {
+			t.Errorf("laser bytes = %d, want the CBOR frame size %d", tb.Bytes, len(laserFrameCBOR)/2)
+		}
+	}
+}
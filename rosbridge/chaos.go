@@ -0,0 +1,163 @@
+package rosbridge
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ──────────────────────────── Chaos (fault injection)
+//
+// A debugging aid for the throttling and reconnect paths: a Conn shim
+// that drops messages, delays them and cuts the connection periodically,
+// so bad Wi-Fi can be reproduced at a desk. Settings are read on every
+// message and can change while the connection is up. The HTTP layer only
+// lets them be set when the chaos config flag is on.
+
+// Conn is the message-level connection the client talks through;
+// *websocket.Conn implements it, and so do the chaos shim and test
+// fakes.
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// Chaos are fault-injection settings. The zero value injects nothing.
+type Chaos struct {
+	DropRate         float64 `json:"drop_rate"`          // 0..1, per message and direction
+	AddedLatencyMs   int     `json:"added_latency_ms"`   // delay before each message
+	DisconnectEveryS int     `json:"disconnect_every_s"` // close the connection after this long; 0 = never
+}
+
+// Active reports whether any fault is configured.
+func (ch Chaos) Active() bool {
+	return ch.DropRate > 0 || ch.AddedLatencyMs > 0 || ch.DisconnectEveryS > 0
+}
+
+// Validate checks the ranges.
+func (ch Chaos) Validate() error {
+	switch {
+	case ch.DropRate < 0 || ch.DropRate > 1:
+		return fmt.Errorf("drop_rate must be in [0, 1]")
+	case ch.AddedLatencyMs < 0 || ch.AddedLatencyMs > 10000:
+		return fmt.Errorf("added_latency_ms must be in [0, 10000]")
+	case ch.DisconnectEveryS < 0:
+		return fmt.Errorf("disconnect_every_s must not be negative")
+	}
+	return nil
+}
+
+// ChaosSettings holds Chaos for concurrent readers.
+type ChaosSettings struct {
+	v atomic.Pointer[Chaos]
+}
+
+// Get returns the current settings.
+func (s *ChaosSettings) Get() Chaos {
+	if p := s.v.Load(); p != nil {
+		return *p
+	}
+	return Chaos{}
+}
+
+// Set replaces the settings.
+func (s *ChaosSettings) Set(ch Chaos) {
+	s.v.Store(&ch)
+}
+
+// errChaosDisconnect is returned once the shim has cut the connection.
+var errChaosDisconnect = errors.New("chaos: injected disconnect")
+
+// chaosConn injects the faults of settings into conn.
+type chaosConn struct {
+	conn     Conn
+	settings *ChaosSettings
+	opened   time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	cut       atomic.Bool
+}
+
+// NewChaosConn wraps conn. With inactive settings it only forwards.
+func NewChaosConn(conn Conn, settings *ChaosSettings) Conn {
+	c := &chaosConn{conn: conn, settings: settings, opened: time.Now(), closed: make(chan struct{})}
+	go c.watchDisconnect()
+	return c
+}
+
+// watchDisconnect closes the connection when it has been up for
+// DisconnectEveryS, so a blocked reader sees the drop too.
+func (c *chaosConn) watchDisconnect() {
+	t := time.NewTicker(250 * time.Millisecond)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-t.C:
+			every := c.settings.Get().DisconnectEveryS
+			if every > 0 && time.Since(c.opened) >= time.Duration(every)*time.Second {
+				c.cut.Store(true)
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// inject applies latency and reports whether the message is dropped.
+func (c *chaosConn) inject() bool {
+	ch := c.settings.Get()
+	if ch.AddedLatencyMs > 0 {
+		time.Sleep(time.Duration(ch.AddedLatencyMs) * time.Millisecond)
+	}
+	return ch.DropRate > 0 && rand.Float64() < ch.DropRate
+}
+
+func (c *chaosConn) ReadMessage() (int, []byte, error) {
+	for {
+		mt, p, err := c.conn.ReadMessage()
+		if err != nil {
+			if c.cut.Load() {
+				err = errChaosDisconnect
+			}
+			return mt, p, err
+		}
+		if !c.inject() {
+			return mt, p, nil
+		}
+	}
+}
+
+func (c *chaosConn) WriteMessage(mt int, data []byte) error {
+	if c.cut.Load() {
+		return errChaosDisconnect
+	}
+	if c.inject() {
+		return nil // silently lost, as on a bad link
+	}
+	return c.conn.WriteMessage(mt, data)
+}
+
+func (c *chaosConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.conn.Close()
+}
+
+// ──────────────────────────── Client hooks
+
+// SetChaos sets the fault injection for this robot's connections; it
+// applies to open connections immediately.
+func (c *Client) SetChaos(ch Chaos) {
+	c.chaos.Set(ch)
+}
+
+// GetChaos returns the fault injection settings.
+func (c *Client) GetChaos() Chaos {
+	return c.chaos.Get()
+}
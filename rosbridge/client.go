@@ -1,11 +1,18 @@
 package rosbridge
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,34 +21,90 @@ import (
 // Client manages a WebSocket connection to a rosbridge_server.
 type Client struct {
 	mu   sync.Mutex
-	conn *websocket.Conn
+	conn Conn
 	ns   string // robot namespace prefix
 	host string
 	port int
 
 	connected    bool
 	reconnecting bool
-	stopCh       chan struct{}
-
-	// Subscribed topic names (full, with namespace)
-	topicMap      string
-	topicCmdVel   string
-	topicTF       string
-	topicOdom     string
-	topicCtrlOdom string
-	topicLaser    string
-	topicMapBfp   string
+	noReconnect  bool        // one-shot clients (Probe)
+	rc           reconnector // see reconnect.go
+
+	// Optional second connection carrying subscriptions (data plane) so
+	// streaming topics don't delay service calls on the control plane.
+	split         bool
+	dataMu        sync.Mutex
+	dataConn      Conn
+	dataConnected bool
+	subscribed    bool // SubscribeAllTopics ran; replayed on data reconnect
+
+	// Subscriptions sent on subsConn, and those waiting for a connection
+	// to send them on (see subscriptions.go)
+	subsMu      sync.Mutex
+	subs        map[string]subscription
+	subsConn    Conn
+	subsPending map[string]pendingSub
+
+	// One websocket shared with other clients of the same server (see
+	// shared.go); rules out the data plane.
+	shared bool
+
+	// Reverse mode: the robot's agent connects to the server and the
+	// client is handed its connection instead of dialing (see reverse.go).
+	reverse bool
+	agent   Conn // the agent connection waiting to be taken by connect
+
+	// Subscribed topic names (see topicNames)
+	topics atomic.Pointer[topicNames]
+
+	// Robot-side subscription throttling (ms, keyed by Topic*) and CBOR
+	throttles map[string]int
+	useCBOR   bool
+
+	// Reduced subscriptions while nobody looks at the robot (see idle.go)
+	idle       bool
+	idleTopics IdleTopics
 
 	// cmd_vel publishing
 	cmdVelEnabled bool
 	desiredTwist  TwistData
 	lastTwist     TwistData
+	cmdVel        CmdVelOptions
 	cmdVelTicker  *time.Ticker
+	cmdVelStop    chan struct{}
 
 	// Stored TF for map→odom
 	globalMapOdom TransformStamped
 
-	// Callbacks — set by the robot layer
+	// Every transform seen on /tf and /tf_static (see frame_tree.go)
+	frames frameTree
+
+	// Fault injection wrapped around every connection (see chaos.go)
+	chaos ChaosSettings
+
+	// Event handlers (see hooks.go)
+	hooks clientHooks
+
+	// Map save progress topic while a save runs (see map_save.go)
+	topicSaveProg atomic.Pointer[string]
+
+	// Optional amcl_pose topic (see localization.go)
+	topicAMCL atomic.Pointer[string]
+
+	// Optional incident marker topic (see markers.go)
+	topicMarker atomic.Pointer[string]
+
+	// Topics subscribed for unparsed delivery (see raw_topics.go)
+	raw rawTopics
+
+	// Applied to every scan before the laser handlers see it
+	laserFilter atomic.Pointer[func(LaserData) LaserData]
+
+	// Single-callback form of the events, run before the handlers.
+	//
+	// Deprecated: setting one replaces the previous callback; use the
+	// Add*Handler methods.
 	OnMap          func(MapData)
 	OnTwist        func(TwistData)
 	OnTF           func(TFData)
@@ -49,59 +112,195 @@ type Client struct {
 	OnCtrlOdom     func(OdomData)
 	OnLaser        func(LaserData)
 	OnMapBfp       func(Pose2D)
+	OnNavStatus    func(NavStatus)
 	OnConnected    func()
 	OnDisconnected func()
 
-	// Service response channels
+	// OnCmdVelPublished fires with exactly what was published on cmd_vel.
+	//
+	// Deprecated: use AddCmdVelPublishedHandler.
+	OnCmdVelPublished func(TwistData)
+
+	// OnStatus fires for every rosbridge op:"status" message.
+	//
+	// Deprecated: use AddStatusHandler.
+	OnStatus func(StatusMessage)
+
+	// Service calls in flight, by call ID (see nextServiceID)
 	svcMu      sync.Mutex
-	svcPending map[string]chan json.RawMessage
+	svcPending map[string]svcCall
+	svcPrefix  string
+	svcSeq     atomic.Uint64
+
+	// rosbridge status tracking: op ID → topic, errors per topic
+	statusMu    sync.Mutex
+	opTopics    map[string]string
+	topicHealth map[string]*TopicHealth
+
+	// Last publish (unix ns) of each topic in topicHealth, as
+	// *atomic.Int64; set on every publish without taking statusMu.
+	topicAlive sync.Map
+
+	// Traffic counters (atomic, see bandwidth.go)
+	bw bandwidth
+
+	// Received frames waiting for their handlers (see inbox.go)
+	inbox [numInboundClasses]inboundQueue
+
+	// Fragmented messages being reassembled (see fragments.go)
+	frags *fragmentBuffer
+
+	// Robot clock offset from header stamps (see clock_skew.go)
+	skew clockSkew
+
+	// OnClockSkew fires when the offset crosses its threshold or jumps.
+	//
+	// Deprecated: use AddClockSkewHandler.
+	OnClockSkew func(ClockSkewEvent)
+}
+
+// svcReply is a service response, or the error rosbridge reported for it.
+type svcReply struct {
+	raw json.RawMessage
+	err error
+}
+
+// svcCall is a pending service call. ch has room for one reply; later
+// ones are dropped.
+type svcCall struct {
+	service string
+	ch      chan svcReply
+}
+
+// ErrNotConnected is returned by calls made, or pending, while the
+// rosbridge connection is down.
+var ErrNotConnected = errors.New("rosbridge not connected")
+
+// Debug reports whether diagnostics too chatty for normal operation are
+// logged; nil logs none. Set once at startup.
+var Debug func() bool
+
+// debugf logs like log.Printf when Debug says so.
+func debugf(format string, args ...interface{}) {
+	if Debug != nil && Debug() {
+		log.Printf(format, args...)
+	}
 }
 
 // NewClient creates a new rosbridge client.
 func NewClient(ns, host string, port int) *Client {
 	c := &Client{
-		ns:         ns,
-		host:       host,
-		port:       port,
-		stopCh:     make(chan struct{}),
-		svcPending: make(map[string]chan json.RawMessage),
+		ns:          ns,
+		host:        host,
+		port:        port,
+		cmdVel:      DefaultCmdVelOptions,
+		rc:          reconnector{policy: DefaultReconnectPolicy},
+		svcPending:  make(map[string]svcCall),
+		svcPrefix:   randomPrefix(),
+		throttles:   make(map[string]int, len(DefaultThrottles)),
+		opTopics:    make(map[string]string),
+		topicHealth: make(map[string]*TopicHealth),
+		frags:       newFragmentBuffer(),
+	}
+	for k, v := range DefaultThrottles {
+		c.throttles[k] = v
 	}
+	c.bw.since = time.Now()
+	c.initInbox()
 	return c
 }
 
-// Connect dials the rosbridge WebSocket server.
+// ErrClientClosed is returned by Connect after Close.
+var ErrClientClosed = errors.New("rosbridge client closed")
+
+// Connect dials the rosbridge WebSocket server. An explicit connect
+// resumes a suspended or disconnected client and restarts the reconnect
+// attempt count.
 func (c *Client) Connect() error {
+	c.mu.Lock()
+	c.rc.stopped = false
+	c.rc.suspended = false
+	c.rc.attempts = 0
+	c.stopTimerLocked()
+	c.mu.Unlock()
+	return c.connect()
+}
+
+// connect dials once; on failure the reconnect policy schedules the next
+// attempt.
+func (c *Client) connect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	return c.connectLocked()
+}
 
+// connectLocked is connect with c.mu held.
+func (c *Client) connectLocked() error {
+	if c.rc.closed {
+		return ErrClientClosed
+	}
 	if c.connected {
 		return nil
 	}
 
-	url := fmt.Sprintf("ws://%s:%d", c.host, c.port)
-	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
-	conn, _, err := dialer.Dial(url, nil)
+	dial := c.dial
+	switch {
+	case c.reverse:
+		dial = c.takeAgent
+	case c.shared:
+		dial = c.dialShared
+	}
+	conn, err := dial()
 	if err != nil {
 		go c.scheduleReconnect()
-		return fmt.Errorf("dial %s: %w", url, err)
+		return err
 	}
 
 	c.conn = conn
 	c.connected = true
-	go c.readLoop()
+	c.rc.attempts = 0
+	c.rc.holdUntil = time.Time{}
+	c.bw.connections.Add(1)
+	go c.readLoop(conn)
 	c.startCmdVelPublisher()
+	if c.split && !c.shared && !c.reverse {
+		go c.connectData()
+	}
 
-	if c.OnConnected != nil {
-		go c.OnConnected()
+	go c.emitConnected()
+	if c.reverse {
+		log.Printf("[rosbridge] Agent connected from %s (ns=%s)", c.host, c.ns)
+	} else {
+		log.Printf("[rosbridge] Connected to %s:%d (ns=%s)", c.host, c.port, c.ns)
 	}
-	log.Printf("[rosbridge] Connected to %s (ns=%s)", url, c.ns)
 	return nil
 }
 
-// Disconnect closes the connection.
+func (c *Client) dial() (Conn, error) {
+	conn, err := c.dialWebsocket()
+	if err != nil {
+		return nil, err
+	}
+	return NewChaosConn(conn, &c.chaos), nil
+}
+
+func (c *Client) dialWebsocket() (Conn, error) {
+	addr := net.JoinHostPort(c.host, strconv.Itoa(c.port))
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	conn, _, err := dialer.Dial("ws://"+addr, nil)
+	if err != nil {
+		return nil, &DialError{Category: classifyDial(err), Addr: addr, Err: err}
+	}
+	return conn, nil
+}
+
+// Disconnect closes the connection and cancels any pending reconnect
+// until the next Connect.
 func (c *Client) Disconnect() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.rc.stopped = true
+	c.stopTimerLocked()
 	c.disconnect()
 }
 
@@ -111,21 +310,15 @@ func (c *Client) disconnect() {
 	}
 	c.connected = false
 
-	if c.cmdVelTicker != nil {
-		c.cmdVelTicker.Stop()
-	}
-	select {
-	case c.stopCh <- struct{}{}:
-	default:
-	}
+	c.stopCmdVelPublisher()
 
 	if c.conn != nil {
 		c.conn.Close()
 	}
+	c.closeData()
+	c.failPendingCalls()
 
-	if c.OnDisconnected != nil {
-		go c.OnDisconnected()
-	}
+	go c.emitDisconnected()
 	log.Printf("[rosbridge] Disconnected (ns=%s)", c.ns)
 }
 
@@ -140,85 +333,262 @@ func (c *Client) send(data []byte) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if !c.connected || c.conn == nil {
-		return fmt.Errorf("not connected")
+		return ErrNotConnected
+	}
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
 	}
-	return c.conn.WriteMessage(websocket.TextMessage, data)
+	c.bw.wrote(len(data))
+	return nil
 }
 
 // ──────────────────────────── Topic subscriptions
 
+// Topic keys used for per-topic subscription settings.
+const (
+	TopicMap      = "map"
+	TopicCmdVel   = "cmd_vel"
+	TopicTF       = "tf"
+	TopicTFStatic = "tf_static"
+	TopicOdom     = "odom"
+	TopicCtrlOdom = "ctrl_odom"
+	TopicLaser    = "laser"
+	TopicMapBfp   = "map_bfp"
+	TopicNavStat  = "nav_status"
+)
+
+// TopicKeys lists every subscription key in subscribe order.
+var TopicKeys = []string{TopicMap, TopicTF, TopicTFStatic, TopicOdom, TopicCtrlOdom, TopicLaser, TopicMapBfp, TopicCmdVel, TopicNavStat}
+
+// NavAction is the Nav2 action the go_all_* behaviour trees run through;
+// its status topic reports lap progress and its cancel service stops them.
+const NavAction = "/navigate_through_poses"
+
+// DefaultThrottles are the robot-side throttle rates (ms) applied to new
+// clients. High-rate topics are cut down before they cross the robot's
+// Wi-Fi; unlisted topics are not throttled.
+var DefaultThrottles = map[string]int{
+	TopicMap:   1000,
+	TopicLaser: 200,
+	TopicOdom:  100,
+}
+
+// SetThrottles updates per-topic throttle rates in ms; 0 disables
+// throttling for that topic. Takes effect on the next (re)subscribe.
+func (c *Client) SetThrottles(rates map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range rates {
+		if v < 0 {
+			v = 0
+		}
+		c.throttles[k] = v
+	}
+}
+
+// Throttles returns a copy of the per-topic throttle rates.
+func (c *Client) Throttles() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]int, len(c.throttles))
+	for k, v := range c.throttles {
+		out[k] = v
+	}
+	return out
+}
+
+// SetCBOR enables "compression": "cbor" on subscriptions. Only use it with
+// rosbridge servers that have CBOR support. Takes effect on the next
+// (re)subscribe.
+func (c *Client) SetCBOR(enabled bool) {
+	c.mu.Lock()
+	c.useCBOR = enabled
+	c.mu.Unlock()
+}
+
+// CBOREnabled reports whether subscriptions request CBOR compression.
+func (c *Client) CBOREnabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.useCBOR
+}
+
+func (c *Client) subscribeOptions(key string) SubscribeOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var o SubscribeOptions
+	rate := c.throttles[key]
+	if c.idle {
+		rate = max(rate, c.idleTopics.Throttles[key])
+	}
+	if rate > 0 {
+		// Keep only the newest message while throttled.
+		o.ThrottleRate = rate
+		o.QueueLength = 1
+	}
+	if c.useCBOR {
+		o.Compression = "cbor"
+	}
+	return o
+}
+
+// topicNames are the standard topics' full names, with namespace. The
+// read loop and the inbox workers match every publish against them, so
+// they are swapped as a whole rather than locked.
+type topicNames struct {
+	Map, CmdVel, TF, TFStatic, Odom, CtrlOdom, Laser, MapBfp, NavStat string
+}
+
+var noTopics topicNames
+
+// topicNames returns the current standard topic names.
+func (c *Client) topicNames() *topicNames {
+	if t := c.topics.Load(); t != nil {
+		return t
+	}
+	return &noTopics
+}
+
+// setTopic updates one or more standard topic names.
+func (c *Client) setTopic(set func(*topicNames)) {
+	for {
+		old := c.topics.Load()
+		next := *c.topicNames()
+		set(&next)
+		if c.topics.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// Resubscribe re-issues all subscriptions so new throttle / compression
+// settings take effect. No-op while disconnected.
+func (c *Client) Resubscribe() {
+	if !c.IsConnected() {
+		return
+	}
+	c.UnsubscribeAll()
+	c.SubscribeAllTopics()
+}
+
 func (c *Client) SubscribeMap(topic string) {
 	if topic == "" {
 		topic = "/map"
 	}
-	c.topicMap = c.ns + topic
-	c.send(SubscribeMsg(c.topicMap, TypeOccupancyGrid))
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.Map = full })
+	c.subscribe(full, TypeOccupancyGrid, TopicMap)
 }
 
 func (c *Client) SubscribeCmdVel(topic string) {
 	if topic == "" {
 		topic = "/diff_controller/cmd_vel_unstamped"
 	}
-	c.topicCmdVel = c.ns + topic
-	c.send(SubscribeMsg(c.topicCmdVel, TypeTwist))
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.CmdVel = full })
+	c.subscribe(full, TypeTwist, TopicCmdVel)
 }
 
 func (c *Client) SubscribeTF(topic string) {
 	if topic == "" {
 		topic = "/tf"
 	}
-	c.topicTF = c.ns + topic
-	c.send(SubscribeMsg(c.topicTF, TypeTFMessage))
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.TF = full })
+	c.subscribe(full, TypeTFMessage, TopicTF)
+}
+
+// SubscribeTFStatic subscribes to static transforms; they only feed the
+// frame tree.
+func (c *Client) SubscribeTFStatic(topic string) {
+	if topic == "" {
+		topic = "/tf_static"
+	}
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.TFStatic = full })
+	c.subscribe(full, TypeTFMessage, TopicTFStatic)
 }
 
 func (c *Client) SubscribeOdom(topic string) {
 	if topic == "" {
 		topic = "/odom"
 	}
-	c.topicOdom = c.ns + topic
-	c.send(SubscribeMsg(c.topicOdom, TypeOdometry))
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.Odom = full })
+	c.subscribe(full, TypeOdometry, TopicOdom)
 }
 
 func (c *Client) SubscribeControllerOdom(topic string) {
 	if topic == "" {
 		topic = "/diff_controller/odom"
 	}
-	c.topicCtrlOdom = c.ns + topic
-	c.send(SubscribeMsg(c.topicCtrlOdom, TypeOdometry))
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.CtrlOdom = full })
+	c.subscribe(full, TypeOdometry, TopicCtrlOdom)
 }
 
 func (c *Client) SubscribeLaser(topic string) {
 	if topic == "" {
 		topic = "/scan"
 	}
-	c.topicLaser = c.ns + topic
-	c.send(SubscribeMsg(c.topicLaser, TypeLaserScan))
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.Laser = full })
+	c.subscribe(full, TypeLaserScan, TopicLaser)
 }
 
 func (c *Client) SubscribeMapBfp(topic string) {
 	if topic == "" {
 		topic = "/map_bfp_publisher"
 	}
-	c.topicMapBfp = c.ns + topic
-	c.send(SubscribeMsg(c.topicMapBfp, ""))
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.MapBfp = full })
+	c.subscribe(full, "", TopicMapBfp)
+}
+
+// SubscribeNavStatus subscribes to the navigation action's goal status.
+func (c *Client) SubscribeNavStatus(topic string) {
+	if topic == "" {
+		topic = NavAction + "/_action/status"
+	}
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.NavStat = full })
+	c.subscribe(full, TypeGoalStatus, TopicNavStat)
 }
 
 // SubscribeAllTopics subscribes to all standard topics.
 func (c *Client) SubscribeAllTopics() {
+	c.mu.Lock()
+	c.subscribed = true
+	c.mu.Unlock()
 	c.SubscribeMap("")
 	c.SubscribeTF("")
+	c.SubscribeTFStatic("")
 	c.SubscribeOdom("")
 	c.SubscribeControllerOdom("")
 	c.SubscribeLaser("")
 	c.SubscribeMapBfp("")
 	c.SubscribeCmdVel("")
+	c.SubscribeNavStatus("")
+	c.subscribeAMCLPose()
+	c.subscribeMarker()
+	c.subscribeRawTopics()
+	c.replayPendingSubs()
 }
 
 func (c *Client) UnsubscribeAll() {
-	topics := []string{c.topicMap, c.topicCmdVel, c.topicTF, c.topicOdom, c.topicCtrlOdom, c.topicLaser, c.topicMapBfp}
+	t := c.topicNames()
+	topics := []string{t.Map, t.CmdVel, t.TF, t.TFStatic, t.Odom, t.CtrlOdom, t.Laser, t.MapBfp, t.NavStat}
+	for _, p := range []*string{c.topicAMCL.Load(), c.topicMarker.Load()} {
+		if p != nil {
+			topics = append(topics, *p)
+		}
+	}
+	for t := range c.rawSubscriptions() {
+		topics = append(topics, t)
+	}
 	for _, t := range topics {
 		if t != "" {
-			c.send(UnsubscribeMsg(t))
+			c.unsubscribe(t)
 		}
 	}
 }
@@ -231,6 +601,13 @@ func (c *Client) SetDesiredCmdVel(twist TwistData) {
 	c.mu.Unlock()
 }
 
+// DesiredCmdVel returns the twist the publisher is sending.
+func (c *Client) DesiredCmdVel() TwistData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.desiredTwist
+}
+
 func (c *Client) SetCmdVelEnabled(enabled bool) {
 	c.mu.Lock()
 	c.cmdVelEnabled = enabled
@@ -238,25 +615,40 @@ func (c *Client) SetCmdVelEnabled(enabled bool) {
 }
 
 func (c *Client) SetCmdVelTopic(topic string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.topicCmdVel = c.ns + topic
+	full := c.ns + topic
+	c.setTopic(func(t *topicNames) { t.CmdVel = full })
 }
 
+// startCmdVelPublisher starts the publish loop (c.mu held). Each loop
+// owns its ticker and stop channel, so a loop still finishing a tick
+// when the connection drops can't outlive it.
 func (c *Client) startCmdVelPublisher() {
-	c.cmdVelTicker = time.NewTicker(50 * time.Millisecond) // 20 Hz
+	c.stopCmdVelPublisher()
+	ticker := time.NewTicker(c.cmdVel.interval())
+	stop := make(chan struct{})
+	c.cmdVelTicker, c.cmdVelStop = ticker, stop
 	go func() {
 		for {
 			select {
-			case <-c.stopCh:
+			case <-stop:
 				return
-			case <-c.cmdVelTicker.C:
+			case <-ticker.C:
 				c.publishCmdVelTick()
 			}
 		}
 	}()
 }
 
+// stopCmdVelPublisher ends the publish loop (c.mu held).
+func (c *Client) stopCmdVelPublisher() {
+	if c.cmdVelTicker == nil {
+		return
+	}
+	c.cmdVelTicker.Stop()
+	close(c.cmdVelStop)
+	c.cmdVelTicker, c.cmdVelStop = nil, nil
+}
+
 func (c *Client) publishCmdVelTick() {
 	c.mu.Lock()
 	if !c.connected || !c.cmdVelEnabled {
@@ -266,16 +658,11 @@ func (c *Client) publishCmdVelTick() {
 
 	desired := c.desiredTwist
 	last := c.lastTwist
-	topic := c.topicCmdVel
+	topic := c.topicNames().CmdVel
+	opts := c.cmdVel
 	c.mu.Unlock()
 
-	if topic == "" {
-		return
-	}
-
-	// Only publish on change
-	if desired.LinearX == last.LinearX && desired.AngularZ == last.AngularZ &&
-		desired.LinearY == last.LinearY {
+	if topic == "" || !opts.publish(desired, last) {
 		return
 	}
 
@@ -283,23 +670,28 @@ func (c *Client) publishCmdVelTick() {
 		"linear":  map[string]float64{"x": desired.LinearX, "y": desired.LinearY, "z": 0},
 		"angular": map[string]float64{"x": 0, "y": 0, "z": desired.AngularZ},
 	}
-	c.send(PublishMsg(topic, msg))
+	if err := c.send(PublishMsg(topic, msg)); err != nil {
+		return
+	}
 
 	c.mu.Lock()
 	c.lastTwist = desired
 	c.mu.Unlock()
+
+	emit(c.OnCmdVelPublished, &c.hooks.cmdVelPublish, desired)
 }
 
 // ──────────────────────────── Service calls
 
 // CallService sends a service call and waits for response (with timeout).
+// Calls pending when the connection drops fail with ErrNotConnected.
 func (c *Client) CallService(service string, args interface{}, timeout time.Duration) (json.RawMessage, error) {
-	id := fmt.Sprintf("svc_%s_%d", service, time.Now().UnixMilli())
+	id := c.nextServiceID(service)
 	fullService := c.ns + service
 
-	ch := make(chan json.RawMessage, 1)
+	ch := make(chan svcReply, 1)
 	c.svcMu.Lock()
-	c.svcPending[id] = ch
+	c.svcPending[id] = svcCall{service: service, ch: ch}
 	c.svcMu.Unlock()
 
 	defer func() {
@@ -314,12 +706,66 @@ func (c *Client) CallService(service string, args interface{}, timeout time.Dura
 
 	select {
 	case resp := <-ch:
-		return resp, nil
+		return resp.raw, resp.err
 	case <-time.After(timeout):
 		return nil, fmt.Errorf("service call %s timed out", service)
 	}
 }
 
+// Service call IDs are svc_<prefix>_<seq>_<service>: the random
+// per-client prefix keeps IDs apart across clients sharing a rosbridge
+// server (and across app restarts), the counter within one client. The
+// service name is kept so replies can be attributed after the call is
+// gone.
+func (c *Client) nextServiceID(service string) string {
+	return fmt.Sprintf("svc_%s_%d_%s", c.svcPrefix, c.svcSeq.Add(1), service)
+}
+
+// serviceFromID returns the service name embedded in a call ID.
+func serviceFromID(id string) string {
+	parts := strings.SplitN(strings.TrimPrefix(id, "svc_"), "_", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+func randomPrefix() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", uint32(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(b)
+}
+
+// deliverServiceReply hands r to the pending call id, if any. It never
+// blocks: a call that already has a reply keeps the first one.
+func (c *Client) deliverServiceReply(id string, r svcReply) bool {
+	c.svcMu.Lock()
+	call, ok := c.svcPending[id]
+	c.svcMu.Unlock()
+	if ok {
+		select {
+		case call.ch <- r:
+		default:
+		}
+	}
+	return ok
+}
+
+// failPendingCalls fails every call in flight with ErrNotConnected; their
+// replies can no longer arrive on this connection.
+func (c *Client) failPendingCalls() {
+	c.svcMu.Lock()
+	defer c.svcMu.Unlock()
+	for _, call := range c.svcPending {
+		select {
+		case call.ch <- svcReply{err: fmt.Errorf("service call %s: %w", call.service, ErrNotConnected)}:
+		default:
+		}
+	}
+}
+
 // Handshake calls /which_name and returns robot namespace + status.
 func (c *Client) Handshake() (*HandshakeResponse, error) {
 	args := WhichMapsArgs("handshake", "", "", "*#5447972162718281828459#")
@@ -364,8 +810,13 @@ func (c *Client) RequestRemappingMode() (json.RawMessage, error) {
 
 // RequestWhichMaps asks the robot what maps it has.
 func (c *Client) RequestWhichMaps() (*WhichMapsResponse, error) {
+	return c.RequestWhichMapsTimeout(10 * time.Second)
+}
+
+// RequestWhichMapsTimeout is RequestWhichMaps waiting up to timeout.
+func (c *Client) RequestWhichMapsTimeout(timeout time.Duration) (*WhichMapsResponse, error) {
 	args := WhichMapsArgs("which_maps", "", "", "")
-	raw, err := c.CallService("/which_maps", args, 10*time.Second)
+	raw, err := c.CallService("/which_maps", args, timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -384,8 +835,14 @@ func (c *Client) RequestWhichMaps() (*WhichMapsResponse, error) {
 
 // SaveMap saves the current map with the given name.
 func (c *Client) SaveMap(name string) (json.RawMessage, error) {
+	return c.SaveMapTimeout(name, 30*time.Second)
+}
+
+// SaveMapTimeout is SaveMap waiting up to timeout; large maps take
+// longer than a minute on some robots.
+func (c *Client) SaveMapTimeout(name string, timeout time.Duration) (json.RawMessage, error) {
 	args := WhichMapsArgs("save_map", name, "", "")
-	return c.CallService("/which_maps", args, 30*time.Second)
+	return c.CallService("/which_maps", args, timeout)
 }
 
 // SelectMap selects/opens a map by name.
@@ -396,77 +853,150 @@ func (c *Client) SelectMap(name string) (json.RawMessage, error) {
 
 // ──────────────────────────── construct_yaml_and_bt service calls
 
-func (c *Client) sendNavPoints(requestString string, pointsKey string, points interface{}) (json.RawMessage, error) {
+// sendNavPoints uploads points; btTemplate, if set, picks the behavior
+// tree template (see bt.go).
+func (c *Client) sendNavPoints(requestString string, pointsKey string, points interface{}, btTemplate string) (json.RawMessage, error) {
 	args := map[string]interface{}{
 		"request_string": requestString,
 		pointsKey:        points,
 	}
+	if btTemplate != "" {
+		args["bt_template"] = btTemplate
+	}
 	return c.CallService("/construct_yaml_and_bt", args, 15*time.Second)
 }
 
-func (c *Client) AddWaypoints(pts []NavigationPoint) (json.RawMessage, error) {
-	return c.sendNavPoints("add_waypoints", "waypoints", WaypointToJSON(pts))
+// AddPoints replaces the robot's collection of type t (a navigable type)
+// and returns what the robot kept.
+func (c *Client) AddPoints(t PointType, pts []NavigationPoint) (*NavAck, error) {
+	return c.AddPointsWithTemplate(t, pts, "")
 }
 
-func (c *Client) AddServicePoints(pts []NavigationPoint) (json.RawMessage, error) {
-	return c.sendNavPoints("add_servicepoints", "servicepoints", WaypointToJSON(pts))
+// AddPointsWithTemplate is AddPoints building the behavior tree after
+// btTemplate (empty: the robot's default).
+func (c *Client) AddPointsWithTemplate(t PointType, pts []NavigationPoint, btTemplate string) (*NavAck, error) {
+	name, err := t.navWireName()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.sendNavPoints("add_"+name, name, WaypointToJSON(pts), btTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNavAck(raw, len(pts))
 }
 
-func (c *Client) AddPatrolPoints(pts []NavigationPoint) (json.RawMessage, error) {
-	return c.sendNavPoints("add_patrolpoints", "patrolpoints", WaypointToJSON(pts))
+func (c *Client) AddWaypoints(pts []NavigationPoint) (*NavAck, error) {
+	return c.AddPoints(PointWaypoint, pts)
 }
 
-func (c *Client) AddPathPoints(pts []NavigationPoint) (json.RawMessage, error) {
-	return c.sendNavPoints("add_pathpoints", "pathpoints", WaypointToJSON(pts))
+func (c *Client) AddServicePoints(pts []NavigationPoint) (*NavAck, error) {
+	return c.AddPoints(PointService, pts)
 }
 
-func (c *Client) SaveWallObstacles(walls []WallObstacle) (json.RawMessage, error) {
-	return c.sendNavPoints("save_obstacles", "obstacles", WallObstaclesToJSON(walls))
+func (c *Client) AddPatrolPoints(pts []NavigationPoint) (*NavAck, error) {
+	return c.AddPoints(PointPatrol, pts)
 }
 
-func (c *Client) ClearWallObstacles() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "clear_obstacles"}
-	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
+func (c *Client) AddPathPoints(pts []NavigationPoint) (*NavAck, error) {
+	return c.AddPoints(PointPath, pts)
 }
 
-func (c *Client) GetWaypoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "get_waypoints"}
-	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
+func (c *Client) SaveWallObstacles(walls []WallObstacle) (*NavAck, error) {
+	raw, err := c.sendNavPoints("save_"+PointWall.wireName(), PointWall.wireName(), WallObstaclesToJSON(walls), "")
+	if err != nil {
+		return nil, err
+	}
+	return ParseNavAck(raw, len(walls))
 }
 
-func (c *Client) GetServicePoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "get_servicepoints"}
+func (c *Client) ClearWallObstacles() (json.RawMessage, error) {
+	args := map[string]interface{}{"request_string": "clear_" + PointWall.wireName()}
 	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
 }
 
-func (c *Client) GetPatrolPoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "get_patrolpoints"}
+// GetPoints asks the robot for its collection of type t.
+func (c *Client) GetPoints(t PointType) (json.RawMessage, error) {
+	name, err := t.navWireName()
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{"request_string": "get_" + name}
 	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
 }
 
-func (c *Client) GetPathPoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "get_pathpoints"}
-	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
-}
+func (c *Client) GetWaypoints() (json.RawMessage, error)     { return c.GetPoints(PointWaypoint) }
+func (c *Client) GetServicePoints() (json.RawMessage, error) { return c.GetPoints(PointService) }
+func (c *Client) GetPatrolPoints() (json.RawMessage, error)  { return c.GetPoints(PointPatrol) }
+func (c *Client) GetPathPoints() (json.RawMessage, error)    { return c.GetPoints(PointPath) }
 
-func (c *Client) GoAllWaypoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "go_all_waypoints"}
+// GoAll makes the robot navigate through its collection of type t.
+func (c *Client) GoAll(t PointType) (json.RawMessage, error) {
+	name, err := t.navWireName()
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{"request_string": "go_all_" + name}
 	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
 }
 
-func (c *Client) GoAllServicePoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "go_all_servicepoints"}
-	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
-}
+func (c *Client) GoAllWaypoints() (json.RawMessage, error)     { return c.GoAll(PointWaypoint) }
+func (c *Client) GoAllServicePoints() (json.RawMessage, error) { return c.GoAll(PointService) }
+func (c *Client) GoAllPatrolPoints() (json.RawMessage, error)  { return c.GoAll(PointPatrol) }
+func (c *Client) GoAllPathPoints() (json.RawMessage, error)    { return c.GoAll(PointPath) }
 
-func (c *Client) GoAllPatrolPoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "go_all_patrolpoints"}
-	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
+// CancelNavigation cancels every goal of the navigation action (a zero
+// goal ID and stamp match all goals).
+func (c *Client) CancelNavigation() (json.RawMessage, error) {
+	args := map[string]interface{}{
+		"goal_info": map[string]interface{}{
+			"goal_id": map[string]interface{}{"uuid": make([]int, 16)},
+			"stamp":   map[string]int{"sec": 0, "nanosec": 0},
+		},
+	}
+	return c.CallService(NavAction+"/_action/cancel_goal", args, 10*time.Second)
 }
 
-func (c *Client) GoAllPathPoints() (json.RawMessage, error) {
-	args := map[string]interface{}{"request_string": "go_all_pathpoints"}
-	return c.CallService("/construct_yaml_and_bt", args, 10*time.Second)
+// ErrGoalRejected is returned when the navigation action refuses a goal.
+var ErrGoalRejected = errors.New("robot rejected the navigation goal")
+
+// SendNavGoal sends a single map-frame pose to the navigation action.
+// Progress arrives on the action status topic like go-all goals.
+func (c *Client) SendNavGoal(p Pose2D) error {
+	uuid := make([]int, 16)
+	var b [16]byte
+	rand.Read(b[:])
+	for i, v := range b {
+		uuid[i] = int(v)
+	}
+	args := map[string]interface{}{
+		"goal_id": map[string]interface{}{"uuid": uuid},
+		"goal": map[string]interface{}{
+			"poses": []map[string]interface{}{{
+				"header": map[string]interface{}{"frame_id": "map"},
+				"pose": map[string]interface{}{
+					"position":    map[string]float64{"x": p.X, "y": p.Y, "z": 0},
+					"orientation": map[string]float64{"x": 0, "y": 0, "z": math.Sin(p.Theta / 2), "w": math.Cos(p.Theta / 2)},
+				},
+			}},
+			"behavior_tree": "",
+		},
+	}
+	raw, err := c.CallService(NavAction+"/_action/send_goal", args, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Result *bool `json:"result"`
+		Values struct {
+			Accepted *bool `json:"accepted"`
+		} `json:"values"`
+	}
+	json.Unmarshal(raw, &resp)
+	if (resp.Result != nil && !*resp.Result) || (resp.Values.Accepted != nil && !*resp.Values.Accepted) {
+		return ErrGoalRejected
+	}
+	return nil
 }
 
 // ──────────────────────────── which_tasks service calls
@@ -512,7 +1042,13 @@ func (c *Client) SendVoiceCommand(cmd string) (*WhichTaskResponse, error) {
 
 // RequestWhichMapsNames returns just the map names as a string slice.
 func (c *Client) RequestWhichMapsNames() ([]string, error) {
-	resp, err := c.RequestWhichMaps()
+	return c.RequestWhichMapsNamesTimeout(10 * time.Second)
+}
+
+// RequestWhichMapsNamesTimeout is RequestWhichMapsNames waiting up to
+// timeout.
+func (c *Client) RequestWhichMapsNamesTimeout(timeout time.Duration) ([]string, error) {
+	resp, err := c.RequestWhichMapsTimeout(timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -524,33 +1060,62 @@ func (c *Client) RequestWhichMapsNames() ([]string, error) {
 
 // ──────────────────────────── Read loop — parse incoming messages
 
-func (c *Client) readLoop() {
+func (c *Client) readLoop(conn Conn) {
 	for {
-		_, msg, err := c.conn.ReadMessage()
+		msgType, msg, err := conn.ReadMessage()
 		if err != nil {
+			conn.Close()
 			c.mu.Lock()
-			wasConnected := c.connected
-			c.connected = false
+			// A replaced connection (see AttachAgent) no longer counts
+			wasConnected := c.connected && c.conn == conn
+			if wasConnected {
+				c.connected = false
+			}
 			c.mu.Unlock()
 
 			if wasConnected {
-				if c.OnDisconnected != nil {
-					go c.OnDisconnected()
-				}
+				c.failPendingCalls()
+				go c.emitDisconnected()
 				go c.scheduleReconnect()
 			}
 			return
 		}
-		c.handleMessage(msg)
+		c.bw.read(len(msg))
+		c.handleFrame(msgType, msg)
 	}
 }
 
-func (c *Client) handleMessage(raw []byte) {
+// handleFrame decodes CBOR binary frames to the JSON envelope before
+// dispatching. Publish traffic is attributed to its topic with the frame's
+// wire size.
+func (c *Client) handleFrame(msgType int, msg []byte) {
+	size := len(msg)
+	if msgType == websocket.BinaryMessage {
+		v, err := DecodeCBOR(msg)
+		if err != nil {
+			log.Printf("[rosbridge] CBOR decode (ns=%s): %v", c.ns, err)
+			return
+		}
+		if msg, err = json.Marshal(v); err != nil {
+			return
+		}
+	}
+	c.handleMessage(msg, size)
+}
+
+// handleMessage decodes the envelope and queues the frame for its
+// handler (see inbox.go).
+func (c *Client) handleMessage(raw []byte, size int) {
 	var envelope struct {
 		Op    string          `json:"op"`
 		Topic string          `json:"topic"`
 		ID    string          `json:"id"`
 		Msg   json.RawMessage `json:"msg"`
+
+		// op:"fragment" (see fragments.go)
+		Data  string `json:"data"`
+		Num   int    `json:"num"`
+		Total int    `json:"total"`
 	}
 	if err := json.Unmarshal(raw, &envelope); err != nil {
 		return
@@ -558,45 +1123,60 @@ func (c *Client) handleMessage(raw []byte) {
 
 	switch envelope.Op {
 	case "publish":
-		c.handlePublish(envelope.Topic, envelope.Msg)
-	case "service_response":
-		c.handleServiceResponse(envelope.ID, raw)
+		c.bw.topic(envelope.Topic, size)
+		c.markTopicAlive(envelope.Topic)
+		c.enqueue(c.publishClass(envelope.Topic), inboundFrame{op: envelope.Op, topic: envelope.Topic, msg: envelope.Msg})
+	case "service_response", "status":
+		c.enqueue(classControl, inboundFrame{op: envelope.Op, id: envelope.ID, raw: raw})
+	case "fragment":
+		c.handleFragment(envelope.ID, envelope.Num, envelope.Total, envelope.Data, size)
 	}
 }
 
 func (c *Client) handlePublish(topic string, msg json.RawMessage) {
+	t := c.topicNames()
 	switch topic {
-	case c.topicMap:
+	case t.Map:
 		c.parseMap(msg)
-	case c.topicCmdVel:
+	case t.CmdVel:
 		c.parseTwist(msg)
-	case c.topicTF:
-		c.parseTF(msg)
-	case c.topicOdom:
+	case t.TF:
+		c.parseTF(msg, false)
+	case t.TFStatic:
+		c.parseTF(msg, true)
+	case t.Odom:
 		c.parseOdom(msg, false)
-	case c.topicCtrlOdom:
+	case t.CtrlOdom:
 		c.parseOdom(msg, true)
-	case c.topicLaser:
+	case t.Laser:
 		c.parseLaser(msg)
-	case c.topicMapBfp:
+	case t.MapBfp:
 		c.parseMapBfp(msg)
+	case t.NavStat:
+		c.parseNavStatus(msg)
+	default:
+		if p := c.topicSaveProg.Load(); p != nil && *p == topic {
+			c.parseMapSaveProgress(msg)
+		} else if c.isAMCLPoseTopic(topic) {
+			c.parseAMCLPose(msg)
+		} else if c.isMarkerTopic(topic) {
+			c.parseMarker(msg)
+		}
 	}
+	c.dispatchRaw(topic, msg)
 }
 
 func (c *Client) handleServiceResponse(id string, raw []byte) {
-	c.svcMu.Lock()
-	ch, ok := c.svcPending[id]
-	c.svcMu.Unlock()
-	if ok {
-		// Extract the full response object
-		ch <- json.RawMessage(raw)
+	if !c.deliverServiceReply(id, svcReply{raw: json.RawMessage(raw)}) {
+		// Usually a reply arriving after the caller timed out
+		debugf("[rosbridge] late service_response for %s (id=%s, ns=%s) dropped", serviceFromID(id), id, c.ns)
 	}
 }
 
 // ──────────────────────────── Message parsers
 
 func (c *Client) parseMap(msg json.RawMessage) {
-	if c.OnMap == nil {
+	if !wanted(c.OnMap, &c.hooks.mapData) {
 		return
 	}
 
@@ -610,6 +1190,7 @@ func (c *Client) parseMap(msg json.RawMessage) {
 					X float64 `json:"x"`
 					Y float64 `json:"y"`
 				} `json:"position"`
+				Orientation Quaternion `json:"orientation"`
 			} `json:"origin"`
 		} `json:"info"`
 		Data []int `json:"data"`
@@ -626,18 +1207,19 @@ func (c *Client) parseMap(msg json.RawMessage) {
 		data[i] = int8(v)
 	}
 
-	c.OnMap(MapData{
+	emit(c.OnMap, &c.hooks.mapData, MapData{
 		Width:      grid.Info.Width,
 		Height:     grid.Info.Height,
 		Resolution: grid.Info.Resolution,
 		OriginX:    grid.Info.Origin.Position.X,
 		OriginY:    grid.Info.Origin.Position.Y,
+		OriginYaw:  grid.Info.Origin.Orientation.Yaw(),
 		Data:       data,
 	})
 }
 
 func (c *Client) parseTwist(msg json.RawMessage) {
-	if c.OnTwist == nil {
+	if !wanted(c.OnTwist, &c.hooks.twist) {
 		return
 	}
 	var m struct {
@@ -647,17 +1229,13 @@ func (c *Client) parseTwist(msg json.RawMessage) {
 	if err := json.Unmarshal(msg, &m); err != nil {
 		return
 	}
-	c.OnTwist(TwistData{
+	emit(c.OnTwist, &c.hooks.twist, TwistData{
 		LinearX: m.Linear.X, LinearY: m.Linear.Y, LinearZ: m.Linear.Z,
 		AngularX: m.Angular.X, AngularY: m.Angular.Y, AngularZ: m.Angular.Z,
 	})
 }
 
-func (c *Client) parseTF(msg json.RawMessage) {
-	if c.OnTF == nil {
-		return
-	}
-
+func (c *Client) parseTF(msg json.RawMessage, static bool) {
 	var tfMsg struct {
 		Transforms []struct {
 			Header struct {
@@ -674,6 +1252,14 @@ func (c *Client) parseTF(msg json.RawMessage) {
 		return
 	}
 
+	now := time.Now()
+	for _, t := range tfMsg.Transforms {
+		c.frames.record(t.Header.FrameID, t.ChildFrameID, static, t.Transform.Translation, t.Transform.Rotation, now)
+	}
+	if static || !wanted(c.OnTF, &c.hooks.tf) {
+		return
+	}
+
 	var tfData TFData
 	emitTF := false
 
@@ -681,7 +1267,7 @@ func (c *Client) parseTF(msg json.RawMessage) {
 		parent := t.Header.FrameID
 		child := t.ChildFrameID
 
-		if parent == "map" && child == "odom" {
+		if parent == FrameMap && child == FrameOdom {
 			c.mu.Lock()
 			c.globalMapOdom = TransformStamped{}
 			c.globalMapOdom.Transform.Translation = t.Transform.Translation
@@ -695,7 +1281,7 @@ func (c *Client) parseTF(msg json.RawMessage) {
 			tfData.MapOdomRy = t.Transform.Rotation.Y
 			tfData.MapOdomRz = t.Transform.Rotation.Z
 			tfData.MapOdomRw = t.Transform.Rotation.W
-		} else if parent == "odom" && child == "base_footprint" {
+		} else if parent == FrameOdom && child == FrameBase {
 			c.mu.Lock()
 			mo := c.globalMapOdom
 			c.mu.Unlock()
@@ -724,7 +1310,7 @@ func (c *Client) parseTF(msg json.RawMessage) {
 	}
 
 	if emitTF {
-		c.OnTF(tfData)
+		emit(c.OnTF, &c.hooks.tf, tfData)
 	}
 }
 
@@ -733,28 +1319,26 @@ func (c *Client) parseOdom(msg json.RawMessage, isController bool) {
 	if err := json.Unmarshal(msg, &odom); err != nil {
 		return
 	}
+	c.observeStamp(odom.Header.Stamp)
 	data := OdomFromMsg(odom)
 
 	if isController {
-		if c.OnCtrlOdom != nil {
-			c.OnCtrlOdom(data)
-		}
+		emit(c.OnCtrlOdom, &c.hooks.ctrlOdom, data)
 	} else {
-		if c.OnOdom != nil {
-			c.OnOdom(data)
-		}
+		emit(c.OnOdom, &c.hooks.odom, data)
 	}
 }
 
 func (c *Client) parseLaser(msg json.RawMessage) {
-	if c.OnLaser == nil {
-		return
-	}
 	var scan LaserScan
 	if err := json.Unmarshal(msg, &scan); err != nil {
 		return
 	}
-	c.OnLaser(LaserData{
+	c.observeStamp(scan.Header.Stamp)
+	if !wanted(c.OnLaser, &c.hooks.laser) {
+		return
+	}
+	data := LaserData{
 		FrameID:        scan.Header.FrameID,
 		AngleMin:       scan.AngleMin,
 		AngleMax:       scan.AngleMax,
@@ -762,30 +1346,68 @@ func (c *Client) parseLaser(msg json.RawMessage) {
 		RangeMin:       scan.RangeMin,
 		RangeMax:       scan.RangeMax,
 		Ranges:         scan.Ranges,
-	})
+	}
+	if f := c.laserFilter.Load(); f != nil {
+		data = (*f)(data)
+	}
+	emit(c.OnLaser, &c.hooks.laser, data)
+}
+
+// SetLaserFilter sets a function every scan passes through before any
+// laser handler sees it (nil removes it). It receives the raw scan and
+// must not modify its Ranges in place.
+func (c *Client) SetLaserFilter(fn func(LaserData) LaserData) {
+	if fn == nil {
+		c.laserFilter.Store(nil)
+		return
+	}
+	c.laserFilter.Store(&fn)
 }
 
 func (c *Client) parseMapBfp(msg json.RawMessage) {
-	if c.OnMapBfp == nil {
+	if !wanted(c.OnMapBfp, &c.hooks.mapBfp) {
 		return
 	}
 	var p Pose2D
 	if err := json.Unmarshal(msg, &p); err != nil {
 		return
 	}
-	c.OnMapBfp(p)
+	emit(c.OnMapBfp, &c.hooks.mapBfp, p)
 }
 
-// ──────────────────────────── Reconnect logic
-
-func (c *Client) scheduleReconnect() {
-	time.Sleep(3 * time.Second)
-	c.mu.Lock()
-	if c.connected {
-		c.mu.Unlock()
+// parseNavStatus reports the newest goal of a GoalStatusArray. The goal
+// UUID is kept in its wire form (an int array, or base64 under CBOR); it
+// is only compared, never decoded.
+func (c *Client) parseNavStatus(msg json.RawMessage) {
+	if !wanted(c.OnNavStatus, &c.hooks.navStatus) {
 		return
 	}
-	c.mu.Unlock()
-	log.Printf("[rosbridge] Reconnecting to %s:%d ...", c.host, c.port)
-	c.Connect()
+	var arr struct {
+		StatusList []struct {
+			GoalInfo struct {
+				GoalID struct {
+					UUID json.RawMessage `json:"uuid"`
+				} `json:"goal_id"`
+				Stamp Stamp `json:"stamp"`
+			} `json:"goal_info"`
+			Status int `json:"status"`
+		} `json:"status_list"`
+	}
+	if err := json.Unmarshal(msg, &arr); err != nil || len(arr.StatusList) == 0 {
+		return
+	}
+
+	newest := 0
+	for i, s := range arr.StatusList {
+		a, b := s.GoalInfo.Stamp, arr.StatusList[newest].GoalInfo.Stamp
+		if a.Sec > b.Sec || (a.Sec == b.Sec && a.NanosecValue() >= b.NanosecValue()) {
+			newest = i
+		}
+	}
+	s := arr.StatusList[newest]
+	emit(c.OnNavStatus, &c.hooks.navStatus, NavStatus{
+		GoalID: string(s.GoalInfo.GoalID.UUID),
+		Status: s.Status,
+		State:  goalStateNames[s.Status],
+	})
 }
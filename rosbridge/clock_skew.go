@@ -0,0 +1,192 @@
+package rosbridge
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ──────────────────────────── Clock skew
+//
+// Odometry and laser headers are stamped with the robot's clock. The
+// difference between a stamp and the time the frame arrived here is the
+// robot's clock offset plus transport latency; latency is small next to
+// the offsets worth reporting (seconds), so it is not corrected for.
+// Messages with zero stamps are ignored.
+//
+// The estimate is an exponential moving average. A sustained step (every
+// sample of a run far from the estimate, in the same direction) is taken
+// as a clock jump: the estimate is reset to the new offset and reported,
+// rather than drifting over for tens of samples. Far samples that do not
+// make a run (isolated outliers) are dropped, whichever side they are on.
+
+const (
+	skewAlpha       = 0.05 // EWMA weight of a new sample
+	skewJumpSamples = 10   // consecutive off-estimate samples that make a jump
+	skewRecoverFrac = 0.8  // |skew| below warn × this clears the warning
+)
+
+// Clock skew event reasons.
+const (
+	SkewExceeded  = "exceeded"
+	SkewJump      = "jump"
+	SkewRecovered = "recovered"
+)
+
+// ClockSkewEvent is raised when the skew crosses the warning threshold
+// (either way) or jumps.
+type ClockSkewEvent struct {
+	Reason string  `json:"reason"`
+	SkewMs float64 `json:"skew_ms"`
+	PrevMs float64 `json:"prev_ms"`
+	Msg    string  `json:"msg"`
+}
+
+// ClockSkewStats is the current skew estimate; positive means the robot's
+// clock is ahead.
+type ClockSkewStats struct {
+	Valid     bool      `json:"valid"`
+	SkewMs    float64   `json:"skew_ms"`
+	Samples   uint64    `json:"samples"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Exceeded  bool      `json:"exceeded"`
+}
+
+type clockSkew struct {
+	mu       sync.Mutex
+	warnMs   float64 // 0 disables threshold events
+	jumpMs   float64 // 0 disables jump detection
+	valid    bool
+	estimate float64
+	samples  uint64
+	updated  time.Time
+	exceeded bool
+	run      []float64 // current run of off-estimate samples
+}
+
+// Time returns the stamp as a wall-clock time.
+func (s Stamp) Time() time.Time {
+	return time.Unix(int64(s.Sec), int64(s.NanosecValue()))
+}
+
+// IsZero reports whether the stamp is unset.
+func (s Stamp) IsZero() bool {
+	return s.Sec == 0 && s.NanosecValue() == 0
+}
+
+// observe adds the offset of a stamp received at 'at' and returns the
+// events it caused.
+func (k *clockSkew) observe(stamp Stamp, at time.Time) []ClockSkewEvent {
+	if stamp.IsZero() {
+		return nil
+	}
+	sample := float64(stamp.Time().Sub(at)) / float64(time.Millisecond)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.samples++
+	k.updated = at
+	if !k.valid {
+		k.valid = true
+		k.estimate = sample
+		return k.checkThreshold(nil)
+	}
+
+	var events []ClockSkewEvent
+	if k.jumpMs > 0 && math.Abs(sample-k.estimate) > k.jumpMs {
+		// A far sample on the other side starts a new run
+		if len(k.run) > 0 && (sample > k.estimate) != (k.run[0] > k.estimate) {
+			k.run = k.run[:0]
+		}
+		k.run = append(k.run, sample)
+		if len(k.run) < skewJumpSamples {
+			return nil // hold the estimate until the run decides
+		}
+		var sum float64
+		for _, v := range k.run {
+			sum += v
+		}
+		prev := k.estimate
+		k.estimate = sum / float64(len(k.run))
+		k.run = k.run[:0]
+		events = append(events, ClockSkewEvent{
+			Reason: SkewJump,
+			SkewMs: k.estimate,
+			PrevMs: prev,
+			Msg:    fmt.Sprintf("robot clock jumped by %s (now %s off)", formatSkew(k.estimate-prev), formatSkew(k.estimate)),
+		})
+		return k.checkThreshold(events)
+	}
+
+	k.run = k.run[:0]
+	k.estimate += skewAlpha * (sample - k.estimate)
+	return k.checkThreshold(events)
+}
+
+// checkThreshold appends an exceeded/recovered event when the estimate
+// crosses the warning threshold. Caller holds k.mu.
+func (k *clockSkew) checkThreshold(events []ClockSkewEvent) []ClockSkewEvent {
+	if k.warnMs <= 0 {
+		return events
+	}
+	abs := math.Abs(k.estimate)
+	switch {
+	case !k.exceeded && abs > k.warnMs:
+		k.exceeded = true
+		events = append(events, ClockSkewEvent{
+			Reason: SkewExceeded,
+			SkewMs: k.estimate,
+			Msg:    fmt.Sprintf("robot clock is %s off (threshold %s)", formatSkew(k.estimate), time.Duration(k.warnMs)*time.Millisecond),
+		})
+	case k.exceeded && abs < k.warnMs*skewRecoverFrac:
+		k.exceeded = false
+		events = append(events, ClockSkewEvent{
+			Reason: SkewRecovered,
+			SkewMs: k.estimate,
+			Msg:    fmt.Sprintf("robot clock back within threshold (%s off)", formatSkew(k.estimate)),
+		})
+	}
+	return events
+}
+
+func (k *clockSkew) stats() ClockSkewStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return ClockSkewStats{
+		Valid:     k.valid,
+		SkewMs:    k.estimate,
+		Samples:   k.samples,
+		UpdatedAt: k.updated,
+		Exceeded:  k.exceeded,
+	}
+}
+
+// formatSkew renders milliseconds as e.g. "+2.35 s" or "-180 ms".
+func formatSkew(ms float64) string {
+	if math.Abs(ms) >= 1000 {
+		return fmt.Sprintf("%+.2f s", ms/1000)
+	}
+	return fmt.Sprintf("%+.0f ms", ms)
+}
+
+// SetClockSkewLimits sets the warning threshold and the step size that
+// counts as a jump; zero disables either.
+func (c *Client) SetClockSkewLimits(warn, jump time.Duration) {
+	c.skew.mu.Lock()
+	c.skew.warnMs = float64(warn) / float64(time.Millisecond)
+	c.skew.jumpMs = float64(jump) / float64(time.Millisecond)
+	c.skew.mu.Unlock()
+}
+
+// ClockSkew returns the robot clock offset estimate.
+func (c *Client) ClockSkew() ClockSkewStats {
+	return c.skew.stats()
+}
+
+// observeStamp feeds a message header into the skew estimate.
+func (c *Client) observeStamp(stamp Stamp) {
+	for _, ev := range c.skew.observe(stamp, time.Now()) {
+		emit(c.OnClockSkew, &c.hooks.clockSkew, ev)
+	}
+}
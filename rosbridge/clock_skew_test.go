@@ -0,0 +1,258 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStampDecoding(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want time.Time
+		zero bool
+	}{
+		{"ROS2 nanosec", `{"sec":1700000000,"nanosec":250000000}`, time.Unix(1700000000, 250000000), false},
+		{"ROS1 nsec", `{"secs":0,"sec":1700000000,"nsec":750000000}`, time.Unix(1700000000, 750000000), false},
+		{"whole second", `{"sec":1700000000}`, time.Unix(1700000000, 0), false},
+		{"nanosec wins over nsec", `{"sec":10,"nanosec":5,"nsec":9}`, time.Unix(10, 5), false},
+		{"sub-second only", `{"sec":0,"nsec":1}`, time.Unix(0, 1), false},
+		{"zero ROS2", `{"sec":0,"nanosec":0}`, time.Unix(0, 0), true},
+		{"zero ROS1", `{"sec":0,"nsec":0}`, time.Unix(0, 0), true},
+		{"missing", `{}`, time.Unix(0, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Stamp
+			if err := json.Unmarshal([]byte(tt.raw), &s); err != nil {
+				t.Fatal(err)
+			}
+			if s.IsZero() != tt.zero {
+				t.Errorf("IsZero = %v, want %v", s.IsZero(), tt.zero)
+			}
+			if !s.Time().Equal(tt.want) {
+				t.Errorf("Time = %v, want %v", s.Time(), tt.want)
+			}
+		})
+	}
+}
+
+// stampAt returns the stamp a robot whose clock is offset ahead would
+// put on a message sent at t.
+func stampAt(t time.Time, offset time.Duration) Stamp {
+	r := t.Add(offset)
+	return Stamp{Sec: int(r.Unix()), Nanosec: r.Nanosecond()}
+}
+
+func rosOneStampAt(t time.Time, offset time.Duration) Stamp {
+	r := t.Add(offset)
+	return Stamp{Sec: int(r.Unix()), Nsec: r.Nanosecond()}
+}
+
+func TestClockSkewIgnoresZeroStamps(t *testing.T) {
+	k := &clockSkew{warnMs: 2000, jumpMs: 1000}
+	if ev := k.observe(Stamp{}, time.Now()); ev != nil {
+		t.Errorf("events for a zero stamp: %v", ev)
+	}
+	if st := k.stats(); st.Valid || st.Samples != 0 {
+		t.Errorf("zero stamp counted: %+v", st)
+	}
+}
+
+func TestClockSkewEstimate(t *testing.T) {
+	tests := []struct {
+		name   string
+		stamp  func(time.Time, time.Duration) Stamp
+		offset time.Duration
+	}{
+		{"ROS2 ahead", stampAt, 1500 * time.Millisecond},
+		{"ROS2 behind", stampAt, -300 * time.Millisecond},
+		{"ROS1 ahead", rosOneStampAt, 1500 * time.Millisecond},
+		{"ROS1 behind", rosOneStampAt, -300 * time.Millisecond},
+		{"in sync", stampAt, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &clockSkew{warnMs: 2000, jumpMs: 1000}
+			at := time.Unix(1700000000, 123456789)
+			for i := 0; i < 50; i++ {
+				at = at.Add(20 * time.Millisecond)
+				if ev := k.observe(tt.stamp(at, tt.offset), at); ev != nil {
+					t.Fatalf("sample %d: unexpected events %v", i, ev)
+				}
+			}
+			st := k.stats()
+			want := float64(tt.offset) / float64(time.Millisecond)
+			if !st.Valid || st.Samples != 50 || math.Abs(st.SkewMs-want) > 0.01 {
+				t.Errorf("stats = %+v, want skew %v ms", st, want)
+			}
+			if !st.UpdatedAt.Equal(at) {
+				t.Errorf("updated = %v, want %v", st.UpdatedAt, at)
+			}
+		})
+	}
+}
+
+func TestClockSkewThreshold(t *testing.T) {
+	k := &clockSkew{warnMs: 2000}
+	at := time.Unix(1700000000, 0)
+
+	ev := k.observe(stampAt(at, -3*time.Second), at)
+	if len(ev) != 1 || ev[0].Reason != SkewExceeded || ev[0].SkewMs != -3000 {
+		t.Fatalf("events = %+v, want one exceeded at -3000", ev)
+	}
+	if !k.stats().Exceeded {
+		t.Error("exceeded not recorded")
+	}
+
+	// Still off: no repeated warnings
+	for i := 0; i < 5; i++ {
+		at = at.Add(time.Second)
+		if ev := k.observe(stampAt(at, -3*time.Second), at); ev != nil {
+			t.Fatalf("repeated warning %v", ev)
+		}
+	}
+
+	// Without jump detection the estimate drifts back; recovery comes
+	// once it is below 80% of the threshold, not at the threshold itself.
+	var recovered []ClockSkewEvent
+	for i := 0; i < 200 && recovered == nil; i++ {
+		at = at.Add(time.Second)
+		recovered = k.observe(stampAt(at, 0), at)
+		if recovered == nil && math.Abs(k.stats().SkewMs) < 2000*skewRecoverFrac {
+			t.Fatalf("no recovery at %v ms", k.stats().SkewMs)
+		}
+	}
+	if len(recovered) != 1 || recovered[0].Reason != SkewRecovered {
+		t.Fatalf("events = %+v, want recovered", recovered)
+	}
+	if got := math.Abs(recovered[0].SkewMs); got >= 2000*skewRecoverFrac || got < 2000*skewRecoverFrac-200 {
+		t.Errorf("recovered at %v ms", recovered[0].SkewMs)
+	}
+}
+
+func TestClockSkewJump(t *testing.T) {
+	k := &clockSkew{warnMs: 2000, jumpMs: 1000}
+	at := time.Unix(1700000000, 0)
+	for i := 0; i < 20; i++ {
+		at = at.Add(50 * time.Millisecond)
+		k.observe(rosOneStampAt(at, 100*time.Millisecond), at)
+	}
+
+	// Isolated outliers (a late frame, a stamp from before a restart) do
+	// not move the estimate
+	for _, off := range []time.Duration{5 * time.Second, -4 * time.Second, 100 * time.Millisecond} {
+		at = at.Add(50 * time.Millisecond)
+		if ev := k.observe(rosOneStampAt(at, off), at); ev != nil {
+			t.Fatalf("outlier %v: events %v", off, ev)
+		}
+	}
+	if got := k.stats().SkewMs; math.Abs(got-100) > 1 {
+		t.Fatalf("outliers moved the estimate to %v", got)
+	}
+
+	// A sustained step is a jump, reported once with the old estimate
+	var events []ClockSkewEvent
+	for i := 0; i < skewJumpSamples; i++ {
+		at = at.Add(50 * time.Millisecond)
+		ev := k.observe(rosOneStampAt(at, 5*time.Second), at)
+		if i < skewJumpSamples-1 && ev != nil {
+			t.Fatalf("sample %d of the run: events %v", i, ev)
+		}
+		events = append(events, ev...)
+	}
+	if len(events) != 2 || events[0].Reason != SkewJump || events[1].Reason != SkewExceeded {
+		t.Fatalf("events = %+v, want jump then exceeded", events)
+	}
+	if math.Abs(events[0].SkewMs-5000) > 1 || math.Abs(events[0].PrevMs-100) > 1 {
+		t.Errorf("jump = %+v", events[0])
+	}
+	if got := k.stats().SkewMs; math.Abs(got-5000) > 1 {
+		t.Errorf("estimate after jump = %v", got)
+	}
+}
+
+func TestClockSkewJumpNeedsOneDirection(t *testing.T) {
+	k := &clockSkew{jumpMs: 1000}
+	at := time.Unix(1700000000, 0)
+	k.observe(stampAt(at, 0), at)
+	// Alternating far samples never form a run
+	for i := 0; i < 3*skewJumpSamples; i++ {
+		at = at.Add(50 * time.Millisecond)
+		off := 3 * time.Second
+		if i%2 == 1 {
+			off = -off
+		}
+		if ev := k.observe(stampAt(at, off), at); ev != nil {
+			t.Fatalf("sample %d: events %v", i, ev)
+		}
+	}
+}
+
+// TestParseStampsFeedSkew drives parseOdom and parseLaser with synthetic
+// messages in both stamp formats.
+func TestParseStampsFeedSkew(t *testing.T) {
+	const ahead = 3 * time.Second
+	header := func(ros1 bool, zero bool) string {
+		if zero {
+			return `{"stamp":{"sec":0,"nanosec":0},"frame_id":"base_link"}`
+		}
+		r := time.Now().Add(ahead)
+		field := "nanosec"
+		if ros1 {
+			field = "nsec"
+		}
+		return fmt.Sprintf(`{"stamp":{"sec":%d,"%s":%d},"frame_id":"base_link"}`, r.Unix(), field, r.Nanosecond())
+	}
+	odom := func(h string) json.RawMessage {
+		return json.RawMessage(`{"header":` + h + `,"pose":{"pose":{"position":{"x":1},"orientation":{"w":1}}}}`)
+	}
+	laser := func(h string) json.RawMessage {
+		return json.RawMessage(`{"header":` + h + `,"angle_min":-1,"angle_max":1,"angle_increment":0.5,"ranges":[1,2,3,4,5]}`)
+	}
+
+	for _, ros1 := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ros1=%v", ros1), func(t *testing.T) {
+			c := NewClient("", "127.0.0.1", 9)
+			defer c.Close()
+			c.SetClockSkewLimits(2*time.Second, 0)
+			var events []ClockSkewEvent
+			c.OnClockSkew = func(ev ClockSkewEvent) { events = append(events, ev) }
+
+			c.parseOdom(odom(header(ros1, true)), false)
+			c.parseLaser(laser(header(ros1, true)))
+			if st := c.ClockSkew(); st.Valid {
+				t.Fatalf("zero stamps counted: %+v", st)
+			}
+
+			c.parseOdom(odom(header(ros1, false)), false)
+			c.parseOdom(odom(header(ros1, false)), true)
+			c.parseLaser(laser(header(ros1, false)))
+			st := c.ClockSkew()
+			if st.Samples != 3 || math.Abs(st.SkewMs-3000) > 250 {
+				t.Errorf("stats = %+v, want 3 samples about +3000 ms", st)
+			}
+			if len(events) != 1 || events[0].Reason != SkewExceeded {
+				t.Errorf("events = %+v, want one exceeded", events)
+			}
+		})
+	}
+}
+
+func TestFormatSkew(t *testing.T) {
+	tests := map[float64]string{
+		2345:  "+2.35 s",
+		-1000: "-1.00 s",
+		-180:  "-180 ms",
+		0:     "+0 ms",
+		999.4: "+999 ms",
+	}
+	for ms, want := range tests {
+		if got := formatSkew(ms); got != want {
+			t.Errorf("formatSkew(%v) = %q, want %q", ms, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package rosbridge
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ──────────────────────────── cmd_vel publishing options
+//
+// The desired twist is published on a ticker. By default a tick publishes
+// only when the command changed since the last publish, at 20 Hz. Bases
+// that use a message timeout as their safety want the command repeated:
+// with ChangeOnly off a non-zero command is republished every tick, and
+// with KeepAlive on a zero command is too.
+
+// CmdVelOptions controls how often and when cmd_vel is published.
+type CmdVelOptions struct {
+	RateHz     float64 `json:"rate_hz"`
+	ChangeOnly bool    `json:"change_only"` // skip ticks where a moving command is unchanged
+	KeepAlive  bool    `json:"keep_alive"`  // keep publishing zeros while idle
+}
+
+// Publish rate bounds (Hz).
+const (
+	MinCmdVelRateHz = 1
+	MaxCmdVelRateHz = 100
+)
+
+// DefaultCmdVelOptions publishes changes only, at 20 Hz.
+var DefaultCmdVelOptions = CmdVelOptions{RateHz: 20, ChangeOnly: true}
+
+// Validate checks the publish rate.
+func (o CmdVelOptions) Validate() error {
+	if math.IsNaN(o.RateHz) || o.RateHz < MinCmdVelRateHz || o.RateHz > MaxCmdVelRateHz {
+		return fmt.Errorf("cmd_vel rate must be between %d and %d Hz", MinCmdVelRateHz, MaxCmdVelRateHz)
+	}
+	return nil
+}
+
+// interval returns the publish period.
+func (o CmdVelOptions) interval() time.Duration {
+	return time.Duration(float64(time.Second) / o.RateHz)
+}
+
+// publish reports whether a tick should publish desired, given the last
+// published twist.
+func (o CmdVelOptions) publish(desired, last TwistData) bool {
+	switch {
+	case desired != last:
+		return true
+	case desired == TwistData{}:
+		return o.KeepAlive
+	default:
+		return !o.ChangeOnly
+	}
+}
+
+// SetCmdVelOptions sets the publish rate and idle behaviour. A running
+// publisher picks up the new rate on its next tick.
+func (c *Client) SetCmdVelOptions(o CmdVelOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cmdVel = o
+	if c.cmdVelTicker != nil {
+		c.cmdVelTicker.Reset(o.interval())
+	}
+	return nil
+}
+
+// CmdVelOptions returns the publish options.
+func (c *Client) CmdVelOptions() CmdVelOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cmdVel
+}
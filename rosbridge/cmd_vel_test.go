@@ -0,0 +1,154 @@
+package rosbridge
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingConn counts the messages written to it.
+type countingConn struct{ writes atomic.Int32 }
+
+func (c *countingConn) ReadMessage() (int, []byte, error) { select {} }
+func (c *countingConn) WriteMessage(int, []byte) error    { c.writes.Add(1); return nil }
+func (c *countingConn) Close() error                      { return nil }
+
+// publishingClient returns a client that publishes cmd_vel to a counting
+// connection without dialing.
+func publishingClient(t *testing.T, o CmdVelOptions) (*Client, *countingConn) {
+	t.Helper()
+	c := NewClient("/r1", "127.0.0.1", 9)
+	t.Cleanup(func() {
+		c.mu.Lock()
+		c.stopCmdVelPublisher()
+		c.mu.Unlock()
+	})
+	if err := c.SetCmdVelOptions(o); err != nil {
+		t.Fatal(err)
+	}
+	conn := new(countingConn)
+	c.SetCmdVelTopic("/cmd_vel")
+	c.mu.Lock()
+	c.conn, c.connected, c.cmdVelEnabled = conn, true, true
+	c.mu.Unlock()
+	return c, conn
+}
+
+// TestCmdVelPublishCounts counts publishes over ten ticks for every
+// combination of the idle options, idle and moving.
+func TestCmdVelPublishCounts(t *testing.T) {
+	moving := TwistData{LinearX: 0.2, AngularZ: 0.1}
+	for _, tc := range []struct {
+		changeOnly, keepAlive bool
+		idle, moving, stop    int32
+	}{
+		{true, false, 0, 1, 1},   // the default: changes only
+		{true, true, 10, 1, 10},  // zeros kept alive, moving changes only
+		{false, false, 0, 10, 1}, // moving repeated, idle quiet
+		{false, true, 10, 10, 10},
+	} {
+		c, conn := publishingClient(t, CmdVelOptions{RateHz: 20, ChangeOnly: tc.changeOnly, KeepAlive: tc.keepAlive})
+		var published []TwistData
+		c.OnCmdVelPublished = func(tw TwistData) { published = append(published, tw) }
+		ticks := func(n int) int32 {
+			before := conn.writes.Load()
+			for i := 0; i < n; i++ {
+				c.publishCmdVelTick()
+			}
+			return conn.writes.Load() - before
+		}
+
+		name := func(phase string) string {
+			return phase + " change_only=" + map[bool]string{true: "on", false: "off"}[tc.changeOnly] +
+				" keep_alive=" + map[bool]string{true: "on", false: "off"}[tc.keepAlive]
+		}
+		if n := ticks(10); n != tc.idle {
+			t.Errorf("%s: %d publishes, want %d", name("idle"), n, tc.idle)
+		}
+		c.SetDesiredCmdVel(moving)
+		if n := ticks(10); n != tc.moving {
+			t.Errorf("%s: %d publishes, want %d", name("moving"), n, tc.moving)
+		}
+		if len(published) == 0 || published[len(published)-1] != moving {
+			t.Errorf("%s: published %v", name("moving"), published)
+		}
+		// The stop is always published, once at least
+		c.SetDesiredCmdVel(TwistData{})
+		if n := ticks(10); n != tc.stop {
+			t.Errorf("%s: %d publishes, want %d", name("stopping"), n, tc.stop)
+		}
+	}
+}
+
+func TestCmdVelNoPublishWhenDisabled(t *testing.T) {
+	c, conn := publishingClient(t, CmdVelOptions{RateHz: 20, KeepAlive: true})
+	c.SetCmdVelEnabled(false)
+	c.publishCmdVelTick()
+	c.mu.Lock()
+	c.cmdVelEnabled, c.connected = true, false
+	c.mu.Unlock()
+	c.publishCmdVelTick()
+	if n := conn.writes.Load(); n != 0 {
+		t.Errorf("%d publishes while disabled or disconnected", n)
+	}
+}
+
+// TestCmdVelRate runs the publish loop with keep-alive on, so every tick
+// publishes, and changes the rate while it runs.
+func TestCmdVelRate(t *testing.T) {
+	c, conn := publishingClient(t, CmdVelOptions{RateHz: 50, KeepAlive: true})
+	c.mu.Lock()
+	c.startCmdVelPublisher()
+	c.mu.Unlock()
+
+	count := func(d time.Duration) int32 {
+		before := conn.writes.Load()
+		time.Sleep(d)
+		return conn.writes.Load() - before
+	}
+	// 25 ticks expected; loose bounds for slow machines
+	if n := count(500 * time.Millisecond); n < 12 || n > 30 {
+		t.Errorf("50 Hz: %d publishes in 500 ms", n)
+	}
+	if err := c.SetCmdVelOptions(CmdVelOptions{RateHz: 10, KeepAlive: true}); err != nil {
+		t.Fatal(err)
+	}
+	count(150 * time.Millisecond) // the tick in flight
+	if n := count(500 * time.Millisecond); n < 2 || n > 7 {
+		t.Errorf("10 Hz: %d publishes in 500 ms", n)
+	}
+}
+
+// TestCmdVelPublisherRestart checks restarting the loop leaves no
+// goroutines behind.
+func TestCmdVelPublisherRestart(t *testing.T) {
+	c, _ := publishingClient(t, DefaultCmdVelOptions)
+	restart := func() {
+		c.mu.Lock()
+		c.startCmdVelPublisher()
+		c.mu.Unlock()
+	}
+	restart()
+	time.Sleep(10 * time.Millisecond)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		restart()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after 50 restarts, %d before", after, before)
+	}
+}
+
+func TestCmdVelOptionsValidate(t *testing.T) {
+	for rate, ok := range map[float64]bool{1: true, 20: true, 100: true, 0: false, 0.5: false, 101: false, -5: false} {
+		if err := (CmdVelOptions{RateHz: rate}).Validate(); (err == nil) != ok {
+			t.Errorf("rate %v: %v", rate, err)
+		}
+	}
+	c := NewClient("", "127.0.0.1", 9)
+	if err := c.SetCmdVelOptions(CmdVelOptions{RateHz: 500}); err == nil || c.CmdVelOptions() != DefaultCmdVelOptions {
+		t.Errorf("invalid options applied: %v %+v", err, c.CmdVelOptions())
+	}
+}
@@ -0,0 +1,137 @@
+package rosbridge
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ──────────────────────────── Split connections
+//
+// With split enabled the client opens a second websocket to the same
+// rosbridge server. Subscribe/unsubscribe ops (and therefore all topic
+// traffic) use this data plane; service calls and publishes stay on the
+// control plane. Each plane reconnects on its own.
+
+const dataReconnectDelay = 3 * time.Second
+
+// SetSplit enables or disables the separate data-plane connection. Takes
+// effect on the next Connect.
+func (c *Client) SetSplit(enabled bool) {
+	c.mu.Lock()
+	c.split = enabled
+	c.mu.Unlock()
+}
+
+// SplitEnabled reports whether the data plane is enabled.
+func (c *Client) SplitEnabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.split
+}
+
+// DataConnected reports whether the data-plane connection is up. Always
+// false when split is disabled.
+func (c *Client) DataConnected() bool {
+	c.dataMu.Lock()
+	defer c.dataMu.Unlock()
+	return c.dataConnected
+}
+
+// sendData sends a subscription op on the data plane, or on the control
+// connection when split is disabled. While the data plane is down it
+// fails; subscribe keeps such ops pending until the plane is back.
+func (c *Client) sendData(data []byte) error {
+	c.mu.Lock()
+	split := c.split && !c.shared
+	c.mu.Unlock()
+	if !split {
+		return c.send(data)
+	}
+	c.dataMu.Lock()
+	defer c.dataMu.Unlock()
+	if !c.dataConnected || c.dataConn == nil {
+		return fmt.Errorf("data connection down")
+	}
+	if err := c.dataConn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	c.bw.wrote(len(data))
+	return nil
+}
+
+// connectData dials the data plane, retrying while the control plane is
+// up, then replays subscriptions.
+func (c *Client) connectData() {
+	for {
+		c.mu.Lock()
+		wanted := c.connected && c.split && !c.shared
+		c.mu.Unlock()
+		if !wanted || c.DataConnected() {
+			return
+		}
+
+		conn, err := c.dial()
+		if err == nil {
+			c.dataMu.Lock()
+			c.dataConn = conn
+			c.dataConnected = true
+			c.dataMu.Unlock()
+			c.bw.connections.Add(1)
+			go c.dataReadLoop(conn)
+			log.Printf("[rosbridge] Data connection up (ns=%s)", c.ns)
+
+			c.mu.Lock()
+			replay := c.subscribed
+			c.mu.Unlock()
+			if replay {
+				c.SubscribeAllTopics()
+			}
+			return
+		}
+		if c.noReconnect {
+			return
+		}
+		log.Printf("[rosbridge] Data connection (ns=%s): %v", c.ns, err)
+		time.Sleep(dataReconnectDelay)
+	}
+}
+
+func (c *Client) dataReadLoop(conn Conn) {
+	for {
+		msgType, msg, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close()
+			c.dataMu.Lock()
+			wasConnected := c.dataConnected && c.dataConn == conn
+			if wasConnected {
+				c.dataConnected = false
+			}
+			c.dataMu.Unlock()
+
+			if wasConnected && !c.noReconnect {
+				log.Printf("[rosbridge] Data connection lost (ns=%s), reconnecting", c.ns)
+				go func() {
+					time.Sleep(dataReconnectDelay)
+					c.connectData()
+				}()
+			}
+			return
+		}
+		c.bw.read(len(msg))
+		c.handleFrame(msgType, msg)
+	}
+}
+
+// closeData closes the data plane without triggering its reconnect.
+func (c *Client) closeData() {
+	c.dataMu.Lock()
+	defer c.dataMu.Unlock()
+	c.dataConnected = false
+	if c.dataConn != nil {
+		c.dataConn.Close()
+		c.dataConn = nil
+	}
+}
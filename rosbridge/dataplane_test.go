@@ -0,0 +1,127 @@
+package rosbridge
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestSplitServiceCallsWhileDataSaturated has the fake server stream
+// large scans on the data plane, each write held back, while service
+// calls go over the control plane.
+func TestSplitServiceCallsWhileDataSaturated(t *testing.T) {
+	s := newFakeServer(t)
+	scan := `{"op":"publish","topic":"/scan","msg":{"ranges":[` + strings.Repeat("1.0,", 200000) + `1.0]}}`
+	s.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		if idx == 0 {
+			serveCalls(idx, conn, s)
+			return
+		}
+		// Data plane: stream scans, each write held back as on a
+		// congested link
+		go func() {
+			for {
+				time.Sleep(20 * time.Millisecond)
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(scan)); err != nil {
+					return
+				}
+			}
+		}()
+		for {
+			if _, ok := s.read(idx, conn); !ok {
+				return
+			}
+		}
+	}
+
+	c := s.client(t, "")
+	c.SetSplit(true)
+	var scans atomic.Int64
+	c.AddLaserHandler(func(LaserData) { scans.Add(1) })
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.DataConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("data plane never connected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.SubscribeAllTopics()
+	for scans.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no scans on the data plane")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for i := 0; i < 10; i++ {
+		start := time.Now()
+		if _, err := c.CallService("/which_name", map[string]string{}, time.Second); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if d := time.Since(start); d > 500*time.Millisecond {
+			t.Errorf("call %d took %s", i, d)
+		}
+	}
+
+	for _, op := range s.received("subscribe") {
+		if op.Conn != 1 {
+			t.Errorf("subscribe %s on connection %d, want the data plane", op.Topic, op.Conn)
+		}
+	}
+	for _, op := range s.received("call_service") {
+		if op.Conn != 0 {
+			t.Errorf("call %s on connection %d, want the control plane", op.Service, op.Conn)
+		}
+	}
+	if !c.IsConnected() || !c.DataConnected() {
+		t.Error("a plane went down")
+	}
+}
+
+func TestSubscribePendingUntilConnected(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.client(t, "")
+
+	c.subscribe("/map_save_progress", TypeFloat32, "")
+	c.unsubscribe("/dropped")
+	c.subscribe("/dropped", TypeString, "")
+	c.unsubscribe("/dropped")
+	if n := len(s.received("subscribe")); n != 0 {
+		t.Fatalf("%d subscribes sent while disconnected", n)
+	}
+
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	c.SubscribeAllTopics()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var topics []string
+		for _, op := range s.received("subscribe") {
+			topics = append(topics, op.Topic)
+		}
+		got := strings.Join(topics, " ")
+		if strings.Contains(got, "/map_save_progress") {
+			if strings.Contains(got, "/dropped") {
+				t.Errorf("unsubscribed topic replayed: %s", got)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending subscribe never sent: %s", got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.subsMu.Lock()
+	pending := len(c.subsPending)
+	c.subsMu.Unlock()
+	if pending != 0 {
+		t.Errorf("%d subscribes still pending", pending)
+	}
+}
@@ -0,0 +1,83 @@
+package rosbridge
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/gorilla/websocket"
+)
+
+// ──────────────────────────── Dial failures
+//
+// "dial tcp 10.0.0.7:9090: connect: connection refused" tells an operator
+// little; a dial failure is returned as a DialError with a category saying
+// what to check.
+
+// Dial failure categories.
+const (
+	DialRefused      = "refused"       // nothing listening on the port
+	DialTimeout      = "timeout"       // no answer in time
+	DialDNS          = "dns"           // the host name doesn't resolve
+	DialUnreachable  = "unreachable"   // no route to the host
+	DialNotRosbridge = "not_rosbridge" // answered, but not as a WebSocket
+	DialOther        = "other"
+)
+
+// DialError is a failed dial of a rosbridge server.
+type DialError struct {
+	Category string
+	Addr     string // host:port
+	Err      error
+}
+
+func (e *DialError) Error() string {
+	switch e.Category {
+	case DialRefused:
+		return fmt.Sprintf("connection to %s refused: is rosbridge running on that port?", e.Addr)
+	case DialTimeout:
+		return fmt.Sprintf("no answer from %s: check the address and that the robot is on the network", e.Addr)
+	case DialDNS:
+		return fmt.Sprintf("cannot resolve the host of %s: check its name", e.Addr)
+	case DialUnreachable:
+		return fmt.Sprintf("no route to %s: check the network", e.Addr)
+	case DialNotRosbridge:
+		return fmt.Sprintf("%s answered, but not as a rosbridge WebSocket server: check the port", e.Addr)
+	}
+	return fmt.Sprintf("dial %s: %v", e.Addr, e.Err)
+}
+
+func (e *DialError) Unwrap() error { return e.Err }
+
+// DialErrorCategory returns the category of a dial failure, DialOther
+// for other errors.
+func DialErrorCategory(err error) string {
+	var de *DialError
+	if errors.As(err, &de) {
+		return de.Category
+	}
+	return DialOther
+}
+
+// classifyDial returns the category of a websocket dial error.
+func classifyDial(err error) string {
+	var dns *net.DNSError
+	var ne net.Error
+	switch {
+	case errors.As(err, &dns):
+		if dns.IsTimeout {
+			return DialTimeout
+		}
+		return DialDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return DialRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return DialUnreachable
+	case errors.Is(err, websocket.ErrBadHandshake):
+		return DialNotRosbridge
+	case errors.As(err, &ne) && ne.Timeout():
+		return DialTimeout
+	}
+	return DialOther
+}
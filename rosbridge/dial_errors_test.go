@@ -0,0 +1,57 @@
+package rosbridge
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyDial(t *testing.T) {
+	connect := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}
+	}
+	for _, c := range []struct {
+		err  error
+		want string
+	}{
+		{&net.OpError{Op: "dial", Err: &net.DNSError{Name: "amr", IsNotFound: true}}, DialDNS},
+		{&net.DNSError{Name: "amr", IsTimeout: true}, DialTimeout},
+		{connect(syscall.ECONNREFUSED), DialRefused},
+		{connect(syscall.EHOSTUNREACH), DialUnreachable},
+		{connect(syscall.ENETUNREACH), DialUnreachable},
+		{websocket.ErrBadHandshake, DialNotRosbridge},
+		{&net.OpError{Op: "dial", Err: timeoutError{}}, DialTimeout},
+		{errors.New("tls: handshake failure"), DialOther},
+	} {
+		if got := classifyDial(c.err); got != c.want {
+			t.Errorf("%v: %s, want %s", c.err, got, c.want)
+		}
+	}
+}
+
+func TestDialError(t *testing.T) {
+	err := fmt.Errorf("connect: %w", &DialError{Category: DialRefused, Addr: "10.0.0.7:9090", Err: syscall.ECONNREFUSED})
+	if DialErrorCategory(err) != DialRefused || !errors.Is(err, syscall.ECONNREFUSED) || !strings.Contains(err.Error(), "is rosbridge running") {
+		t.Errorf("wrapped: %v", err)
+	}
+	if DialErrorCategory(errors.New("boom")) != DialOther || DialErrorCategory(nil) != DialOther {
+		t.Error("plain errors have a category")
+	}
+	other := &DialError{Category: DialOther, Addr: "10.0.0.7:9090", Err: errors.New("boom")}
+	if other.Error() != "dial 10.0.0.7:9090: boom" {
+		t.Errorf("other: %v", other)
+	}
+}
@@ -0,0 +1,118 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer is a rosbridge server for tests. Each accepted connection
+// gets its index (0 first); serve runs one connection's session, by
+// default answering every service call with an empty result.
+type fakeServer struct {
+	srv *httptest.Server
+
+	mu    sync.Mutex
+	conns int
+	ops   []fakeOp
+
+	serve func(idx int, conn *websocket.Conn, s *fakeServer)
+}
+
+// fakeOp is an op received by the fake server.
+type fakeOp struct {
+	Conn    int
+	Op      string `json:"op"`
+	ID      string `json:"id"`
+	Topic   string `json:"topic"`
+	Service string `json:"service"`
+
+	ThrottleRate int `json:"throttle_rate"`
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	s := &fakeServer{serve: serveCalls}
+	up := websocket.Upgrader{}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.mu.Lock()
+		idx := s.conns
+		s.conns++
+		s.mu.Unlock()
+		s.serve(idx, conn, s)
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// serveCalls records ops and answers service calls until the
+// connection closes.
+func serveCalls(idx int, conn *websocket.Conn, s *fakeServer) {
+	var writeMu sync.Mutex
+	for {
+		op, ok := s.read(idx, conn)
+		if !ok {
+			return
+		}
+		if op.Op == "call_service" {
+			writeMu.Lock()
+			conn.WriteJSON(map[string]interface{}{
+				"op": "service_response", "id": op.ID, "service": op.Service,
+				"values": map[string]interface{}{}, "result": true,
+			})
+			writeMu.Unlock()
+		}
+	}
+}
+
+// read reads and records the next op.
+func (s *fakeServer) read(idx int, conn *websocket.Conn) (fakeOp, bool) {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return fakeOp{}, false
+	}
+	op := fakeOp{Conn: idx}
+	json.Unmarshal(data, &op)
+	s.mu.Lock()
+	s.ops = append(s.ops, op)
+	s.mu.Unlock()
+	return op, true
+}
+
+// received returns the ops of kind op received so far.
+func (s *fakeServer) received(op string) []fakeOp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []fakeOp
+	for _, o := range s.ops {
+		if o.Op == op {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// client returns a client for the server, closed with the test.
+func (s *fakeServer) client(t *testing.T, ns string) *Client {
+	t.Helper()
+	host, port, err := net.SplitHostPort(s.srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	c := NewClient(ns, host, p)
+	c.noReconnect = true
+	t.Cleanup(c.Close)
+	return c
+}
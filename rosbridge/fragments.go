@@ -0,0 +1,184 @@
+package rosbridge
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ──────────────────────────── Fragmented messages
+//
+// rosbridge splits a message longer than its fragment size (a big
+// occupancy grid, typically) into op:"fragment" frames: the JSON text of
+// the message cut into pieces, each with the message id, its index num
+// and the total count. Fragments are buffered by id and the message is
+// handled like any other frame once all of them arrived, in whatever
+// order. A set still incomplete after fragmentTimeout is discarded, as
+// are the oldest sets when the buffered text would exceed
+// fragmentMaxBytes; both are counted in FragmentStats. A fragment
+// announcing more than fragmentMaxParts pieces, or more pieces than the
+// buffer could hold bytes, is rejected before anything is allocated.
+
+const (
+	fragmentTimeout  = 10 * time.Second
+	fragmentMaxBytes = 64 << 20
+	fragmentMaxParts = 1 << 20
+)
+
+// FragmentStats counts fragmented messages. Counters are cumulative for
+// the client's lifetime.
+type FragmentStats struct {
+	Pending      int    `json:"pending"`       // incomplete messages buffered
+	PendingBytes int    `json:"pending_bytes"` // their text so far
+	Reassembled  uint64 `json:"reassembled"`
+	Expired      uint64 `json:"expired"`  // incomplete after the timeout
+	Overflow     uint64 `json:"overflow"` // discarded to stay within the buffer limit
+	Invalid      uint64 `json:"invalid"`  // fragments with a bad index or total
+}
+
+// fragmentSet is one message being reassembled.
+type fragmentSet struct {
+	parts    []string
+	got      []bool
+	have     int
+	bytes    int // text received
+	wireSize int // frame sizes, for the bandwidth counters
+	started  time.Time
+}
+
+type fragmentBuffer struct {
+	mu      sync.Mutex
+	sets    map[string]*fragmentSet
+	bytes   int
+	timeout time.Duration
+	max     int
+
+	reassembled, expired, overflow, invalid uint64
+}
+
+func newFragmentBuffer() *fragmentBuffer {
+	return &fragmentBuffer{sets: map[string]*fragmentSet{}, timeout: fragmentTimeout, max: fragmentMaxBytes}
+}
+
+// add buffers fragment num of total for message id. Once the set is
+// complete it returns the message and the summed wire size of its frames.
+func (b *fragmentBuffer) add(id string, num, total int, data string, wireSize int, now time.Time) ([]byte, int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked(now)
+
+	if total <= 0 || total > fragmentMaxParts || total > b.max || num < 0 || num >= total {
+		b.invalid++
+		return nil, 0, false
+	}
+	s := b.sets[id]
+	if s != nil && len(s.parts) != total {
+		// The id was reused for another message; the old one can't complete.
+		b.dropLocked(id)
+		b.invalid++
+		s = nil
+	}
+	if s == nil {
+		s = &fragmentSet{parts: make([]string, total), got: make([]bool, total), started: now}
+		b.sets[id] = s
+	}
+	if s.got[num] {
+		return nil, 0, false // duplicate
+	}
+	s.parts[num], s.got[num] = data, true
+	s.have++
+	s.bytes += len(data)
+	s.wireSize += wireSize
+	b.bytes += len(data)
+
+	if s.have == total {
+		b.dropLocked(id)
+		b.reassembled++
+		return []byte(strings.Join(s.parts, "")), s.wireSize, true
+	}
+	for b.bytes > b.max {
+		oldest := ""
+		for sid, o := range b.sets {
+			if oldest == "" || o.started.Before(b.sets[oldest].started) {
+				oldest = sid
+			}
+		}
+		b.dropLocked(oldest)
+		b.overflow++
+	}
+	return nil, 0, false
+}
+
+// expireLocked discards the sets older than the timeout. Caller holds b.mu.
+func (b *fragmentBuffer) expireLocked(now time.Time) {
+	for id, s := range b.sets {
+		if now.Sub(s.started) > b.timeout {
+			b.dropLocked(id)
+			b.expired++
+		}
+	}
+}
+
+func (b *fragmentBuffer) dropLocked(id string) {
+	if s, ok := b.sets[id]; ok {
+		b.bytes -= s.bytes
+		delete(b.sets, id)
+	}
+}
+
+func (b *fragmentBuffer) stats(now time.Time) FragmentStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked(now)
+	return FragmentStats{
+		Pending:      len(b.sets),
+		PendingBytes: b.bytes,
+		Reassembled:  b.reassembled,
+		Expired:      b.expired,
+		Overflow:     b.overflow,
+		Invalid:      b.invalid,
+	}
+}
+
+// Fragments returns the fragmented message counters.
+func (c *Client) Fragments() FragmentStats {
+	return c.frags.stats(time.Now())
+}
+
+// handleFragment buffers a fragment frame and handles the message it
+// completes.
+func (c *Client) handleFragment(id string, num, total int, data string, size int) {
+	if msg, wire, ok := c.frags.add(id, num, total, data, size, time.Now()); ok {
+		c.handleMessage(msg, wire)
+	}
+}
+
+// ──────────────────────────── Sending fragmented messages
+
+var fragmentSeq atomic.Uint64
+
+// PublishFragmented publishes data on topic, split into fragments of at
+// most fragmentSize characters of message text when it is longer, for
+// payloads beyond the rosbridge server's message size limit. The
+// fragments are written back to back on the control connection.
+func (c *Client) PublishFragmented(topic string, data interface{}, fragmentSize int) error {
+	id := fmt.Sprintf("%sfrag-%d", c.svcPrefix, fragmentSeq.Add(1))
+	frames := FragmentMsg(PublishMsg(topic, data), id, fragmentSize)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.connected || c.conn == nil {
+		return ErrNotConnected
+	}
+	for _, f := range frames {
+		if err := c.conn.WriteMessage(websocket.TextMessage, f); err != nil {
+			return err
+		}
+		c.bw.wrote(len(f))
+	}
+	return nil
+}
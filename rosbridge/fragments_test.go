@@ -0,0 +1,227 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// fragmentParts decodes FragmentMsg frames.
+func fragmentParts(t *testing.T, frames [][]byte) []struct {
+	Op, ID, Data string
+	Num, Total   int
+} {
+	t.Helper()
+	parts := make([]struct {
+		Op, ID, Data string
+		Num, Total   int
+	}, len(frames))
+	for i, f := range frames {
+		if err := json.Unmarshal(f, &parts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return parts
+}
+
+func TestFragmentMsg(t *testing.T) {
+	msg := []byte(`{"op":"publish","topic":"/say","msg":{"data":"` + strings.Repeat("héllo wörld ✓ ", 20) + `"}}`)
+	frames := FragmentMsg(msg, "f1", 16)
+	var text strings.Builder
+	for i, p := range fragmentParts(t, frames) {
+		if p.Op != "fragment" || p.ID != "f1" || p.Num != i || p.Total != len(frames) {
+			t.Fatalf("fragment %d: %+v", i, p)
+		}
+		if len(p.Data) > 16 || !utf8.ValidString(p.Data) {
+			t.Errorf("fragment %d cut inside a character: %q", i, p.Data)
+		}
+		text.WriteString(p.Data)
+	}
+	if text.String() != string(msg) {
+		t.Error("fragments don't join up to the message")
+	}
+	if frames := FragmentMsg(msg, "f2", len(msg)); len(frames) != 1 || string(frames[0]) != string(msg) {
+		t.Error("a message that fits was fragmented")
+	}
+}
+
+func TestFragmentBuffer(t *testing.T) {
+	b := newFragmentBuffer()
+	t0 := time.Now()
+	msg := `{"op":"publish","topic":"/a","msg":{"data":"0123456789"}}`
+	var parts []string
+	for i := 0; i < len(msg); i += 7 {
+		parts = append(parts, msg[i:min(i+7, len(msg))])
+	}
+
+	// Shuffled, with a duplicate
+	order := rand.New(rand.NewSource(1)).Perm(len(parts))
+	order = append([]int{order[1]}, order...)
+	var got []byte
+	for i, n := range order {
+		out, wire, ok := b.add("m1", n, len(parts), parts[n], 100, t0)
+		if ok != (i == len(order)-1) {
+			t.Fatalf("fragment %d of %d: complete %v", i, len(order), ok)
+		}
+		if ok {
+			got = out
+			if wire != 100*len(parts) {
+				t.Errorf("wire size %d, want the %d frames'", wire, len(parts))
+			}
+		}
+	}
+	if string(got) != msg {
+		t.Errorf("reassembled %s", got)
+	}
+	if st := b.stats(t0); st.Pending != 0 || st.PendingBytes != 0 || st.Reassembled != 1 {
+		t.Errorf("after reassembly: %+v", st)
+	}
+
+	// Abandoned: expired after the timeout, and a late fragment starts over
+	b.add("m2", 0, 2, "ab", 10, t0)
+	if st := b.stats(t0.Add(fragmentTimeout)); st.Pending != 1 || st.Expired != 0 {
+		t.Errorf("within the timeout: %+v", st)
+	}
+	if st := b.stats(t0.Add(fragmentTimeout + time.Millisecond)); st.Pending != 0 || st.PendingBytes != 0 || st.Expired != 1 {
+		t.Errorf("after the timeout: %+v", st)
+	}
+	if _, _, ok := b.add("m2", 1, 2, "cd", 10, t0.Add(fragmentTimeout+time.Second)); ok {
+		t.Error("completed an expired message")
+	}
+
+	// Bad indices and absurd totals, and an id reused for a message of
+	// another length
+	for _, f := range [][2]int{{2, 2}, {-1, 2}, {0, 0}, {0, 1e12}, {0, fragmentMaxParts + 1}} {
+		b.add("m3", f[0], f[1], "x", 1, t0)
+	}
+	b.add("m4", 0, 3, "abc", 3, t0)
+	b.add("m4", 1, 2, "de", 2, t0)
+	out, _, ok := b.add("m4", 0, 2, "ab", 2, t0)
+	if !ok || string(out) != "abde" {
+		t.Errorf("reused id: %q, %v", out, ok)
+	}
+	if st := b.stats(t0); st.Invalid != 6 {
+		t.Errorf("invalid %d, want 6", st.Invalid)
+	}
+
+	// More pieces than the buffer could hold bytes
+	small := newFragmentBuffer()
+	small.max = 100
+	small.add("m5", 0, 101, "x", 1, t0)
+	if st := small.stats(t0); st.Invalid != 1 || st.Pending != 0 {
+		t.Errorf("total over the byte limit: %+v", st)
+	}
+}
+
+func TestFragmentBufferOverflow(t *testing.T) {
+	b := newFragmentBuffer()
+	b.max = 100
+	t0 := time.Now()
+	b.add("old", 0, 2, strings.Repeat("a", 60), 60, t0)
+	b.add("new", 0, 2, strings.Repeat("b", 60), 60, t0.Add(time.Millisecond))
+	if st := b.stats(t0); st.Pending != 1 || st.PendingBytes != 60 || st.Overflow != 1 {
+		t.Errorf("over the limit: %+v", st)
+	}
+	if _, _, ok := b.add("old", 1, 2, "a", 1, t0); ok {
+		t.Error("completed a discarded message")
+	}
+	if out, _, ok := b.add("new", 1, 2, "b", 1, t0); !ok || len(out) != 61 {
+		t.Error("the newest message was discarded")
+	}
+	if st := b.stats(t0); st.Pending != 1 || st.PendingBytes != 1 {
+		t.Errorf("the discarded message's late fragment isn't buffered anew: %+v", st)
+	}
+
+	// One message bigger than the limit goes too, after the older ones
+	b.add("huge", 0, 3, strings.Repeat("c", 101), 101, t0.Add(2*time.Millisecond))
+	if st := b.stats(t0); st.Pending != 0 || st.PendingBytes != 0 || st.Overflow != 3 {
+		t.Errorf("oversized message: %+v", st)
+	}
+}
+
+// TestFragmentedMap feeds a map in reverse-order fragments through the
+// client: the map handler gets it and bandwidth counts every frame.
+func TestFragmentedMap(t *testing.T) {
+	c := inboxClient(t)
+	maps := make(chan MapData, 1)
+	c.AddMapHandler(func(m MapData) { maps <- m })
+
+	frames := FragmentMsg(mapFrame(20000, 19999), "map-1", 1000)
+	if len(frames) < 10 {
+		t.Fatalf("only %d fragments", len(frames))
+	}
+	wire := 0
+	for i := len(frames) - 1; i >= 0; i-- {
+		c.feed(frames[i])
+		wire += len(frames[i])
+	}
+	select {
+	case m := <-maps:
+		if m.Width != 20000 || len(m.Data) != 20000 {
+			t.Errorf("map %dx%d, %d cells", m.Width, m.Height, len(m.Data))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reassembled map not handled")
+	}
+	if bytes, msgs := topicBytes(c.Bandwidth(), "/r1/map"); bytes != uint64(wire) || msgs != 1 {
+		t.Errorf("bandwidth %d bytes in %d messages, want %d in 1", bytes, msgs, wire)
+	}
+	if st := c.Fragments(); st.Reassembled != 1 || st.Pending != 0 {
+		t.Errorf("fragments %+v", st)
+	}
+}
+
+// TestPublishFragmented checks the server receives a publish as
+// fragments that join up to it.
+func TestPublishFragmented(t *testing.T) {
+	s := newFakeServer(t)
+	frames := make(chan []byte, 100)
+	s.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			frames <- data
+		}
+	}
+	c := s.client(t, "")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	payload := map[string]string{"data": strings.Repeat("x", 500)}
+	if err := c.PublishFragmented("/big", payload, 64); err != nil {
+		t.Fatal(err)
+	}
+
+	var text strings.Builder
+	for n, total := 0, 1; n < total; n++ {
+		select {
+		case f := <-frames:
+			var p struct {
+				Op, Data   string
+				Num, Total int
+			}
+			json.Unmarshal(f, &p)
+			if p.Op != "fragment" {
+				n-- // subscriptions and the like
+				continue
+			}
+			if p.Num != n {
+				t.Fatalf("fragment %d sent as %d", n, p.Num)
+			}
+			total = p.Total
+			text.WriteString(p.Data)
+		case <-time.After(2 * time.Second):
+			t.Fatal("fragments not received")
+		}
+	}
+	if text.String() != string(PublishMsg("/big", payload)) {
+		t.Errorf("fragments join up to %s", text.String())
+	}
+}
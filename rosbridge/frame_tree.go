@@ -0,0 +1,189 @@
+package rosbridge
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ──────────────────────────── TF frame tree
+//
+// Every transform seen on /tf and /tf_static is recorded as a
+// parent→child edge with its latest value, so "which frames does this
+// robot publish?" can be answered without ROS tooling. A TF tree gives
+// each child exactly one parent, so edges are keyed by child frame.
+
+// Frames the app relies on for the robot pose (map→odom→base_footprint).
+const (
+	FrameMap  = "map"
+	FrameOdom = "odom"
+	FrameBase = "base_footprint"
+)
+
+const (
+	maxFrames    = 256             // edges kept; further children are counted and dropped
+	tfStaleAfter = 5 * time.Second // dynamic edges older than this are stale
+)
+
+// FrameEdge is one parent→child transform.
+type FrameEdge struct {
+	Parent      string     `json:"parent"`
+	Child       string     `json:"child"`
+	Static      bool       `json:"static"` // from /tf_static; never stale
+	Translation Vector3    `json:"translation"`
+	Rotation    Quaternion `json:"rotation"`
+	LastSeen    time.Time  `json:"last_seen"`
+	AgeSec      float64    `json:"age_sec"`
+	Stale       bool       `json:"stale"`
+	Count       uint64     `json:"count"` // messages carrying this edge
+}
+
+// FrameCheck reports whether a frame the app needs was seen.
+type FrameCheck struct {
+	Role  string `json:"role"` // map, odom or base
+	Frame string `json:"frame"`
+	Found bool   `json:"found"`
+	Stale bool   `json:"stale"` // found, but only on stale edges
+}
+
+// FrameTree is a snapshot of the recorded transforms.
+type FrameTree struct {
+	Edges   []FrameEdge  `json:"edges"`   // sorted parent-first (depth), then by child
+	Roots   []string     `json:"roots"`   // frames that are nobody's child
+	Frames  int          `json:"frames"`  // distinct frame names
+	Dropped uint64       `json:"dropped"` // transforms ignored because the cap was reached
+	Checks  []FrameCheck `json:"checks"`  // map/odom/base presence
+	// PoseChain is true when map→…→odom→…→base_footprint is connected
+	// by non-stale edges.
+	PoseChain bool `json:"pose_chain"`
+}
+
+// Missing returns the required frames that were not found.
+func (t FrameTree) Missing() []string {
+	var out []string
+	for _, c := range t.Checks {
+		if !c.Found {
+			out = append(out, c.Frame)
+		}
+	}
+	return out
+}
+
+type frameTree struct {
+	mu      sync.Mutex
+	edges   map[string]*FrameEdge // by child frame
+	dropped uint64
+}
+
+// record stores one transform.
+func (f *frameTree) record(parent, child string, static bool, tr Vector3, rot Quaternion, at time.Time) {
+	if parent == "" || child == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.edges == nil {
+		f.edges = make(map[string]*FrameEdge)
+	}
+	e, ok := f.edges[child]
+	if !ok {
+		if len(f.edges) >= maxFrames {
+			f.dropped++
+			return
+		}
+		e = &FrameEdge{Child: child}
+		f.edges[child] = e
+	}
+	e.Parent = parent
+	e.Static = static
+	e.Translation = tr
+	e.Rotation = rot
+	e.LastSeen = at
+	e.Count++
+}
+
+func (f *frameTree) snapshot(now time.Time) FrameTree {
+	f.mu.Lock()
+	edges := make([]FrameEdge, 0, len(f.edges))
+	for _, e := range f.edges {
+		edges = append(edges, *e)
+	}
+	dropped := f.dropped
+	f.mu.Unlock()
+
+	t := FrameTree{Edges: edges, Roots: []string{}, Dropped: dropped}
+	byChild := make(map[string]FrameEdge, len(edges))
+	frames := make(map[string]bool)
+	for i := range t.Edges {
+		e := &t.Edges[i]
+		e.AgeSec = now.Sub(e.LastSeen).Seconds()
+		e.Stale = !e.Static && now.Sub(e.LastSeen) > tfStaleAfter
+		byChild[e.Child] = *e
+		frames[e.Parent], frames[e.Child] = true, true
+	}
+	t.Frames = len(frames)
+	for name := range frames {
+		if _, ok := byChild[name]; !ok {
+			t.Roots = append(t.Roots, name)
+		}
+	}
+	sort.Strings(t.Roots)
+
+	// Depth is bounded by the edge count, which also stops on cycles.
+	depth := make(map[string]int, len(edges))
+	for child := range byChild {
+		d, frame := 0, child
+		for e, ok := byChild[frame]; ok && d <= len(edges); e, ok = byChild[frame] {
+			d++
+			frame = e.Parent
+		}
+		depth[child] = d
+	}
+	sort.Slice(t.Edges, func(i, j int) bool {
+		di, dj := depth[t.Edges[i].Child], depth[t.Edges[j].Child]
+		if di != dj {
+			return di < dj
+		}
+		return t.Edges[i].Child < t.Edges[j].Child
+	})
+
+	for _, req := range []struct{ role, frame string }{
+		{"map", FrameMap}, {"odom", FrameOdom}, {"base", FrameBase},
+	} {
+		c := FrameCheck{Role: req.role, Frame: req.frame, Found: frames[req.frame]}
+		if c.Found {
+			c.Stale = true
+			for _, e := range t.Edges {
+				if (e.Parent == req.frame || e.Child == req.frame) && !e.Stale {
+					c.Stale = false
+					break
+				}
+			}
+		}
+		t.Checks = append(t.Checks, c)
+	}
+	t.PoseChain = chainFresh(byChild, FrameBase, FrameOdom, len(edges)) &&
+		chainFresh(byChild, FrameOdom, FrameMap, len(edges))
+	return t
+}
+
+// chainFresh reports whether ancestor is reachable from frame through
+// non-stale edges.
+func chainFresh(byChild map[string]FrameEdge, frame, ancestor string, limit int) bool {
+	for i := 0; i <= limit; i++ {
+		e, ok := byChild[frame]
+		if !ok || e.Stale {
+			return false
+		}
+		if e.Parent == ancestor {
+			return true
+		}
+		frame = e.Parent
+	}
+	return false
+}
+
+// GetFrameTree returns the transforms seen on /tf and /tf_static.
+func (c *Client) GetFrameTree() FrameTree {
+	return c.frames.snapshot(time.Now())
+}
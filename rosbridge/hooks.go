@@ -0,0 +1,154 @@
+package rosbridge
+
+import "sync"
+
+// ──────────────────────────── Event handlers
+//
+// Every client event has a list of handlers, run in registration order
+// on the goroutine that raised the event (connect and disconnect run on
+// their own goroutine, one per event). Layers add their own handler
+// instead of replacing and chaining a single callback, so the robot's
+// state update and the manager's broadcast both fire exactly once.
+//
+// The On* fields are the older single-callback form. They still work and
+// run before the registered handlers, but setting one replaces whatever
+// was there; new code should use the Add*Handler methods.
+
+// hooks is the handler list of one event.
+type hooks[T any] struct {
+	mu  sync.RWMutex
+	fns []func(T)
+}
+
+func (h *hooks[T]) add(fn func(T)) {
+	h.mu.Lock()
+	h.fns = append(h.fns, fn)
+	h.mu.Unlock()
+}
+
+func (h *hooks[T]) empty() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.fns) == 0
+}
+
+func (h *hooks[T]) fire(v T) {
+	h.mu.RLock()
+	fns := h.fns
+	h.mu.RUnlock()
+	for _, fn := range fns {
+		fn(v)
+	}
+}
+
+// emit runs the legacy callback, if set, then the handlers.
+func emit[T any](legacy func(T), h *hooks[T], v T) {
+	if legacy != nil {
+		legacy(v)
+	}
+	h.fire(v)
+}
+
+// wanted reports whether an event has any receiver, so parsing can be
+// skipped when not.
+func wanted[T any](legacy func(T), h *hooks[T]) bool {
+	return legacy != nil || !h.empty()
+}
+
+// clientHooks are the handler lists of a Client.
+type clientHooks struct {
+	connected     hooks[struct{}]
+	disconnected  hooks[struct{}]
+	mapData       hooks[MapData]
+	twist         hooks[TwistData]
+	tf            hooks[TFData]
+	odom          hooks[OdomData]
+	ctrlOdom      hooks[OdomData]
+	laser         hooks[LaserData]
+	mapBfp        hooks[Pose2D]
+	navStatus     hooks[NavStatus]
+	status        hooks[StatusMessage]
+	clockSkew     hooks[ClockSkewEvent]
+	cmdVelPublish hooks[TwistData]
+	suspended     hooks[ReconnectStatus]
+	saveProgress  hooks[float64]
+	amclPose      hooks[PoseVariance]
+	marker        hooks[string]
+}
+
+// AddConnectHandler runs fn after every (re)connect.
+func (c *Client) AddConnectHandler(fn func()) {
+	c.hooks.connected.add(func(struct{}) { fn() })
+}
+
+// AddDisconnectHandler runs fn after every connection loss or
+// Disconnect.
+func (c *Client) AddDisconnectHandler(fn func()) {
+	c.hooks.disconnected.add(func(struct{}) { fn() })
+}
+
+// AddMapHandler receives every occupancy grid.
+func (c *Client) AddMapHandler(fn func(MapData)) { c.hooks.mapData.add(fn) }
+
+// AddTwistHandler receives the subscribed cmd_vel.
+func (c *Client) AddTwistHandler(fn func(TwistData)) { c.hooks.twist.add(fn) }
+
+// AddTFHandler receives map→odom→base_footprint updates.
+func (c *Client) AddTFHandler(fn func(TFData)) { c.hooks.tf.add(fn) }
+
+// AddOdomHandler receives odometry.
+func (c *Client) AddOdomHandler(fn func(OdomData)) { c.hooks.odom.add(fn) }
+
+// AddCtrlOdomHandler receives controller odometry.
+func (c *Client) AddCtrlOdomHandler(fn func(OdomData)) { c.hooks.ctrlOdom.add(fn) }
+
+// AddLaserHandler receives laser scans.
+func (c *Client) AddLaserHandler(fn func(LaserData)) { c.hooks.laser.add(fn) }
+
+// AddMapBfpHandler receives the robot's map-frame pose.
+func (c *Client) AddMapBfpHandler(fn func(Pose2D)) { c.hooks.mapBfp.add(fn) }
+
+// AddMapSaveProgressHandler receives the map save percentage (0–100)
+// while SubscribeMapSaveProgress is active.
+func (c *Client) AddMapSaveProgressHandler(fn func(float64)) { c.hooks.saveProgress.add(fn) }
+
+// AddAMCLPoseHandler receives the variance of every amcl_pose message
+// while SetAMCLPoseTopic is set.
+func (c *Client) AddAMCLPoseHandler(fn func(PoseVariance)) { c.hooks.amclPose.add(fn) }
+
+// AddMarkerHandler receives the label of every message on the marker
+// topic while SetMarkerTopic is set.
+func (c *Client) AddMarkerHandler(fn func(string)) { c.hooks.marker.add(fn) }
+
+// AddNavStatusHandler receives the newest navigation goal status.
+func (c *Client) AddNavStatusHandler(fn func(NavStatus)) { c.hooks.navStatus.add(fn) }
+
+// AddStatusHandler receives every rosbridge op:"status" message.
+func (c *Client) AddStatusHandler(fn func(StatusMessage)) { c.hooks.status.add(fn) }
+
+// AddClockSkewHandler receives clock skew threshold crossings and jumps.
+func (c *Client) AddClockSkewHandler(fn func(ClockSkewEvent)) { c.hooks.clockSkew.add(fn) }
+
+// AddCmdVelPublishedHandler receives exactly what was published on
+// cmd_vel.
+func (c *Client) AddCmdVelPublishedHandler(fn func(TwistData)) { c.hooks.cmdVelPublish.add(fn) }
+
+// AddSuspendedHandler runs fn when the reconnect policy gives up.
+func (c *Client) AddSuspendedHandler(fn func(ReconnectStatus)) { c.hooks.suspended.add(fn) }
+
+// emitConnected runs the connect handlers; called on its own goroutine.
+func (c *Client) emitConnected() {
+	if c.OnConnected != nil {
+		c.OnConnected()
+	}
+	c.hooks.connected.fire(struct{}{})
+}
+
+// emitDisconnected runs the disconnect handlers; called on its own
+// goroutine.
+func (c *Client) emitDisconnected() {
+	if c.OnDisconnected != nil {
+		c.OnDisconnected()
+	}
+	c.hooks.disconnected.fire(struct{}{})
+}
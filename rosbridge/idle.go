@@ -0,0 +1,62 @@
+package rosbridge
+
+import "reflect"
+
+// ──────────────────────────── Idle subscriptions
+//
+// A robot nobody is looking at doesn't need its map and scans streamed.
+// While idle a client leaves the topics in IdleTopics.Drop unsubscribed
+// and subscribes the others no faster than IdleTopics.Throttles; the
+// connection itself stays up, so service calls, cmd_vel and the light
+// topics (pose, navigation status) keep working. Reconnects while idle
+// subscribe the reduced set too.
+
+// IdleTopics is the subscription set of an idle client, by topic key
+// (Topic*).
+type IdleTopics struct {
+	Drop      []string       `json:"drop"`      // not subscribed while idle
+	Throttles map[string]int `json:"throttles"` // minimum rate (ms) while idle
+}
+
+// DefaultIdleTopics drops the map and scans and slows pose updates to
+// 1 Hz.
+var DefaultIdleTopics = IdleTopics{
+	Drop:      []string{TopicMap, TopicLaser},
+	Throttles: map[string]int{TopicTF: 1000, TopicOdom: 1000, TopicCtrlOdom: 1000},
+}
+
+// SetIdle switches between the full and the idle subscription set and
+// re-subscribes so it takes effect at once. Changing the topics of an
+// idle client re-subscribes too.
+func (c *Client) SetIdle(idle bool, t IdleTopics) {
+	c.mu.Lock()
+	changed := c.idle != idle || (idle && !reflect.DeepEqual(c.idleTopics, t))
+	c.idle = idle
+	c.idleTopics = t
+	c.mu.Unlock()
+	if changed {
+		c.Resubscribe()
+	}
+}
+
+// Idle reports whether the idle subscription set is in use.
+func (c *Client) Idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.idle
+}
+
+// idleDrops reports whether the topic is left out while idle.
+func (c *Client) idleDrops(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.idle {
+		return false
+	}
+	for _, k := range c.idleTopics.Drop {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package rosbridge
+
+import "testing"
+
+// TestIdleSubscriptions switches a connected client between the full and
+// the idle subscription set, and reconnects it while idle.
+func TestIdleSubscriptions(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.client(t, "/r1")
+	c.AddConnectHandler(c.SubscribeAllTopics)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	c.SubscribeAllTopics()
+	waitFor(t, "subscriptions", func() bool { return len(c.Subscriptions()) > 0 })
+	names := c.topicNames()
+	full := len(c.Subscriptions())
+	settle(t, s, "subscribe", full)
+	subs := func() map[string]Subscription {
+		out := map[string]Subscription{}
+		for _, sub := range c.Subscriptions() {
+			out[sub.Topic] = sub
+		}
+		return out
+	}
+
+	c.SetIdle(true, DefaultIdleTopics)
+	if !c.Idle() {
+		t.Fatal("not idle")
+	}
+	settle(t, s, "unsubscribe", 2)
+	unsubs := subscribesPerConn(s, "unsubscribe")[0]
+	if unsubs[names.Map] != 1 || unsubs[names.Laser] != 1 {
+		t.Errorf("idle: unsubscribed %v, want the map and scan", unsubs)
+	}
+	idle := subs()
+	if _, ok := idle[names.Map]; ok {
+		t.Error("idle: map still subscribed")
+	}
+	if _, ok := idle[names.Laser]; ok {
+		t.Error("idle: scan still subscribed")
+	}
+	for _, topic := range []string{names.TF, names.Odom} {
+		if idle[topic].ThrottleRate < 1000 {
+			t.Errorf("idle: %s at %d ms, want at least 1000", topic, idle[topic].ThrottleRate)
+		}
+	}
+	var tfRate int
+	for _, op := range s.received("subscribe") {
+		if op.Topic == names.TF {
+			tfRate = op.ThrottleRate
+		}
+	}
+	if tfRate != 1000 {
+		t.Errorf("idle: server saw tf last subscribed at %d ms", tfRate)
+	}
+
+	// The same set again changes nothing
+	before := len(s.received("subscribe")) + len(s.received("unsubscribe"))
+	c.SetIdle(true, DefaultIdleTopics)
+	if after := len(s.received("subscribe")) + len(s.received("unsubscribe")); after != before {
+		t.Errorf("unchanged idle set: %d more ops", after-before)
+	}
+
+	// Awake: the full set, at the normal rates
+	c.SetIdle(false, IdleTopics{})
+	waitFor(t, "the map", func() bool { _, ok := subs()[names.Map]; return ok })
+	active := subs()
+	if len(active) != full {
+		t.Errorf("awake: %d subscriptions, want %d", len(active), full)
+	}
+	for _, topic := range []string{names.TF, names.Odom} {
+		if active[topic].ThrottleRate >= 1000 {
+			t.Errorf("awake: %s still at %d ms", topic, active[topic].ThrottleRate)
+		}
+	}
+
+	// Reconnecting while idle subscribes the reduced set
+	c.SetIdle(true, DefaultIdleTopics)
+	c.Disconnect()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the second connection", func() bool { return len(subscribesPerConn(s, "subscribe")[1]) > 0 })
+	settle(t, s, "subscribe", len(s.received("subscribe")))
+	second := subscribesPerConn(s, "subscribe")[1]
+	if second[names.Map] != 0 || second[names.Laser] != 0 || second[names.TF] != 1 {
+		t.Errorf("reconnected while idle: %v", second)
+	}
+}
@@ -0,0 +1,184 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// ──────────────────────────── Inbound queues
+//
+// The read loops only decode a frame's envelope and queue it; parsing and
+// the event handlers run on a worker per message class, so a slow map
+// handler (which locks the robot and fans out to every browser) doesn't
+// hold up the TF and odometry arriving behind the map. The classes:
+//   - control: service responses and status messages;
+//   - pose: TF and odometry;
+//   - events: every other topic;
+//   - bulk: the map and laser scans, keep-latest: a frame replaces the
+//     unhandled one of the same topic, which counts as dropped.
+//
+// Only bulk drops. The other queues hold up to their capacity, after
+// which the read loop waits for the worker, pushing back on the socket.
+// A queue's worker starts when a frame is queued and ends once the queue
+// is empty. Frames keep their order within a class, not across classes.
+
+type inboundClass int
+
+const (
+	classControl inboundClass = iota
+	classPose
+	classEvents
+	classBulk
+	numInboundClasses
+)
+
+var inboundClassNames = [numInboundClasses]string{"control", "pose", "events", "bulk"}
+
+// inboundCapacity is how many frames each class may queue; bulk holds at
+// most one per topic.
+var inboundCapacity = [numInboundClasses]int{256, 1024, 256, 0}
+
+// inboundFrame is a decoded envelope waiting for its handler.
+type inboundFrame struct {
+	op    string
+	topic string
+	id    string
+	msg   json.RawMessage // publish payload
+	raw   []byte          // whole frame, for service responses and status
+}
+
+type inboundQueue struct {
+	mu       sync.Mutex
+	room     *sync.Cond // signalled when a frame is taken
+	frames   []inboundFrame
+	capacity int  // 0: keep-latest per topic
+	running  bool // a worker is draining the queue
+
+	peak         int
+	handled      uint64
+	dropped      uint64
+	waits        uint64
+	queuedTopics map[string]bool // keep-latest: topics with a queued frame
+}
+
+// InboundQueueStats is the state of one inbound queue. Counters are
+// cumulative for the client's lifetime.
+type InboundQueueStats struct {
+	Class    string `json:"class"`
+	Depth    int    `json:"depth"`
+	Peak     int    `json:"peak"`
+	Capacity int    `json:"capacity"` // 0: one frame per topic, older ones dropped
+	Handled  uint64 `json:"handled"`
+	Dropped  uint64 `json:"dropped"` // bulk frames replaced before being handled
+	Waits    uint64 `json:"waits"`   // frames the read loop held until there was room
+}
+
+func (c *Client) initInbox() {
+	for i := range c.inbox {
+		q := &c.inbox[i]
+		q.room = sync.NewCond(&q.mu)
+		q.capacity = inboundCapacity[i]
+		if q.capacity == 0 {
+			q.queuedTopics = make(map[string]bool)
+		}
+	}
+}
+
+// publishClass returns the queue for a topic's messages.
+func (c *Client) publishClass(topic string) inboundClass {
+	t := c.topicNames()
+	switch topic {
+	case t.TF, t.TFStatic, t.Odom, t.CtrlOdom:
+		return classPose
+	case t.Map, t.Laser:
+		return classBulk
+	}
+	return classEvents
+}
+
+// enqueue queues f on class, waiting while a bounded queue is full.
+func (c *Client) enqueue(class inboundClass, f inboundFrame) {
+	q := &c.inbox[class]
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.capacity == 0 {
+		if q.queuedTopics[f.topic] {
+			for i := range q.frames {
+				if q.frames[i].topic == f.topic {
+					q.frames[i] = f
+					break
+				}
+			}
+			q.dropped++
+			return
+		}
+		q.queuedTopics[f.topic] = true
+	} else if len(q.frames) >= q.capacity {
+		q.waits++
+		for len(q.frames) >= q.capacity {
+			q.room.Wait()
+		}
+	}
+	q.frames = append(q.frames, f)
+	q.peak = max(q.peak, len(q.frames))
+	if !q.running {
+		q.running = true
+		go c.drain(q)
+	}
+}
+
+// drain handles q's frames until it is empty.
+func (c *Client) drain(q *inboundQueue) {
+	for {
+		q.mu.Lock()
+		if len(q.frames) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		f := q.frames[0]
+		q.frames[0] = inboundFrame{}
+		q.frames = q.frames[1:]
+		if q.queuedTopics != nil {
+			delete(q.queuedTopics, f.topic)
+		}
+		q.handled++
+		q.room.Broadcast()
+		q.mu.Unlock()
+
+		c.dispatch(f)
+	}
+}
+
+// dispatch runs the handler for a queued frame.
+func (c *Client) dispatch(f inboundFrame) {
+	switch f.op {
+	case "publish":
+		c.handlePublish(f.topic, f.msg)
+	case "service_response":
+		c.handleServiceResponse(f.id, f.raw)
+	case "status":
+		c.handleStatus(f.raw)
+	}
+}
+
+// InboundQueues returns the state of the client's inbound queues.
+func (c *Client) InboundQueues() []InboundQueueStats {
+	out := make([]InboundQueueStats, 0, numInboundClasses)
+	for i := range c.inbox {
+		q := &c.inbox[i]
+		q.mu.Lock()
+		out = append(out, InboundQueueStats{
+			Class:    inboundClassNames[i],
+			Depth:    len(q.frames),
+			Peak:     q.peak,
+			Capacity: q.capacity,
+			Handled:  q.handled,
+			Dropped:  q.dropped,
+			Waits:    q.waits,
+		})
+		q.mu.Unlock()
+	}
+	return out
+}
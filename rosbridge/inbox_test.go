@@ -0,0 +1,265 @@
+package rosbridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestTopicNamesRace resubscribes and renames topics while odometry and
+// TF flood in, so the read loop and the inbox workers match publishes
+// against topic names that are being replaced. Run with -race.
+func TestTopicNamesRace(t *testing.T) {
+	s := newFakeServer(t)
+	s.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		go func() {
+			for {
+				if _, ok := s.read(idx, conn); !ok {
+					return
+				}
+			}
+		}()
+		odom := map[string]interface{}{"op": "publish", "topic": "/r1/odom", "msg": map[string]interface{}{
+			"pose": map[string]interface{}{"pose": map[string]interface{}{"orientation": map[string]float64{"w": 1}}},
+		}}
+		tf := map[string]interface{}{"op": "publish", "topic": "/r1/tf", "msg": map[string]interface{}{"transforms": []interface{}{}}}
+		for {
+			if conn.WriteJSON(odom) != nil || conn.WriteJSON(tf) != nil {
+				return
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+	c := s.client(t, "/r1")
+	var odoms atomic.Int32
+	c.AddOdomHandler(func(OdomData) { odoms.Add(1) })
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	c.SubscribeAllTopics()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for _, fn := range []func(){
+		c.Resubscribe,
+		func() { c.SubscribeTF("/tf") },
+		func() { c.SetCmdVelTopic("/cmd_vel") },
+		func() { c.publishClass("/r1/odom"); c.isStandardTopic("/r1/map") },
+	} {
+		wg.Add(1)
+		go func(fn func()) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					fn()
+				}
+			}
+		}(fn)
+	}
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	c.SubscribeOdom("")
+	before := odoms.Load()
+	waitFor(t, "odometry", func() bool { return odoms.Load() > before })
+}
+
+// TestPublishClassFollowsTopicNames checks that a renamed topic moves to
+// its class queue with it.
+func TestPublishClassFollowsTopicNames(t *testing.T) {
+	c := NewClient("/r1", "127.0.0.1", 9)
+	defer c.Close()
+	if got := c.publishClass("/r1/odom"); got != classEvents {
+		t.Errorf("unsubscribed odom in class %s", inboundClassNames[got])
+	}
+
+	c.SubscribeOdom("/wheel_odom")
+	c.SubscribeMap("")
+	tests := map[string]inboundClass{
+		"/r1/wheel_odom": classPose,
+		"/r1/odom":       classEvents,
+		"/r1/map":        classBulk,
+		"/map":           classEvents,
+	}
+	for topic, want := range tests {
+		if got := c.publishClass(topic); got != want {
+			t.Errorf("%s in class %s, want %s", topic, inboundClassNames[got], inboundClassNames[want])
+		}
+	}
+	if !c.isStandardTopic("/r1/wheel_odom") || c.isStandardTopic("/r1/odom") {
+		t.Error("isStandardTopic does not follow the rename")
+	}
+}
+
+// inboxClient is a client with the map and odometry subscribed, fed
+// frames through handleMessage as its read loop would.
+func inboxClient(t testing.TB) *Client {
+	c := NewClient("/r1", "127.0.0.1", 9)
+	t.Cleanup(c.Close)
+	c.SubscribeOdom("")
+	c.SubscribeMap("")
+	return c
+}
+
+func odomFrame(x float64) []byte {
+	return []byte(fmt.Sprintf(`{"op":"publish","topic":"/r1/odom","msg":{"pose":{"pose":{"position":{"x":%g},"orientation":{"w":1}}}}}`, x))
+}
+
+func mapFrame(width, cells int) []byte {
+	return []byte(fmt.Sprintf(`{"op":"publish","topic":"/r1/map","msg":{"info":{"width":%d,"height":1,"resolution":0.05},"data":[%s0]}}`,
+		width, strings.Repeat("0,", cells)))
+}
+
+func (c *Client) feed(frame []byte) { c.handleMessage(frame, len(frame)) }
+
+func queueStats(c *Client, class inboundClass) InboundQueueStats {
+	return c.InboundQueues()[class]
+}
+
+// TestInboxKeepLatest blocks the map handler: the maps arriving meanwhile
+// collapse to the newest, and odometry keeps flowing past them.
+func TestInboxKeepLatest(t *testing.T) {
+	c := inboxClient(t)
+	release := make(chan struct{})
+	maps := make(chan int, 10)
+	c.AddMapHandler(func(m MapData) {
+		maps <- m.Width
+		<-release
+	})
+	var odoms atomic.Int32
+	c.AddOdomHandler(func(OdomData) { odoms.Add(1) })
+
+	c.feed(mapFrame(1, 0))
+	if got := <-maps; got != 1 {
+		t.Fatalf("first map %d", got)
+	}
+	for w := 2; w <= 5; w++ {
+		c.feed(mapFrame(w, 0))
+	}
+	for i := 0; i < 50; i++ {
+		c.feed(odomFrame(float64(i)))
+	}
+	waitFor(t, "odometry behind a stuck map", func() bool { return odoms.Load() == 50 })
+	if st := queueStats(c, classBulk); st.Depth != 1 || st.Dropped != 3 || st.Handled != 1 {
+		t.Errorf("bulk %+v, want 1 queued, 3 dropped", st)
+	}
+
+	close(release)
+	if got := <-maps; got != 5 {
+		t.Errorf("queued map %d, want the newest", got)
+	}
+	select {
+	case w := <-maps:
+		t.Errorf("map %d handled after the newest", w)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if st := queueStats(c, classPose); st.Dropped != 0 || st.Handled != 50 {
+		t.Errorf("pose %+v", st)
+	}
+}
+
+// TestInboxBackpressure fills the pose queue behind a stuck handler: the
+// read loop waits instead of dropping, and order is kept.
+func TestInboxBackpressure(t *testing.T) {
+	c := inboxClient(t)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var seen []float64
+	c.AddOdomHandler(func(o OdomData) {
+		<-release
+		mu.Lock()
+		seen = append(seen, o.PosX)
+		mu.Unlock()
+	})
+
+	n := inboundCapacity[classPose] + 10
+	fed := make(chan struct{})
+	go func() {
+		for i := 0; i < n; i++ {
+			c.feed(odomFrame(float64(i)))
+		}
+		close(fed)
+	}()
+	// One frame is with the handler, capacity more are queued
+	waitFor(t, "a full queue", func() bool { return queueStats(c, classPose).Depth == inboundCapacity[classPose] })
+	select {
+	case <-fed:
+		t.Fatal("the read loop did not wait for room")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-fed
+	waitFor(t, "every frame", func() bool { mu.Lock(); defer mu.Unlock(); return len(seen) == n })
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, x := range seen {
+		if x != float64(i) {
+			t.Fatalf("frame %d handled as %v", i, x)
+		}
+	}
+	if st := queueStats(c, classPose); st.Dropped != 0 || st.Waits == 0 || st.Peak != inboundCapacity[classPose] {
+		t.Errorf("pose %+v", st)
+	}
+}
+
+// BenchmarkOdomLatencyUnderMapFlood measures how long odometry waits for
+// its handler while 20 large maps a second arrive for a map handler that
+// takes 50 ms each, and reports the 99th percentile and maximum.
+func BenchmarkOdomLatencyUnderMapFlood(b *testing.B) {
+	c := inboxClient(b)
+	c.AddMapHandler(func(MapData) { time.Sleep(50 * time.Millisecond) })
+	var mu sync.Mutex
+	var sent []time.Time
+	var latencies []time.Duration
+	c.AddOdomHandler(func(o OdomData) {
+		mu.Lock()
+		latencies = append(latencies, time.Since(sent[int(o.PosX)]))
+		mu.Unlock()
+	})
+
+	bigMap := mapFrame(500, 500*500-1)
+	stop := make(chan struct{})
+	flooded := make(chan struct{})
+	go func() {
+		defer close(flooded)
+		tick := time.NewTicker(50 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			c.feed(bigMap)
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mu.Lock()
+		sent = append(sent, time.Now())
+		mu.Unlock()
+		c.feed(odomFrame(float64(i)))
+		time.Sleep(time.Millisecond)
+	}
+	b.StopTimer()
+	close(stop)
+	<-flooded
+
+	waitFor(b, "odometry", func() bool { mu.Lock(); defer mu.Unlock(); return len(latencies) == b.N })
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-µs")
+	b.ReportMetric(float64(latencies[len(latencies)-1].Microseconds()), "max-µs")
+	b.ReportMetric(float64(queueStats(c, classBulk).Dropped), "maps-dropped")
+}
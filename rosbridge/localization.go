@@ -0,0 +1,114 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// ──────────────────────────── Pose covariance
+//
+// Odometry and AMCL poses carry a 6×6 row-major covariance over
+// (x, y, z, roll, pitch, yaw). Only the planar diagonal is kept: it is
+// what grows when localization degrades. The amcl_pose subscription is
+// optional (SetAMCLPoseTopic); without it the odometry covariance is all
+// there is.
+
+// PoseVariance is the x, y (m²) and yaw (rad²) variance of a pose.
+type PoseVariance struct {
+	X   float64 `json:"var_x"`
+	Y   float64 `json:"var_y"`
+	Yaw float64 `json:"var_yaw"`
+}
+
+// VarianceFromCovariance returns the planar diagonal of a 6×6
+// covariance, or nil if it has another size.
+func VarianceFromCovariance(cov []float64) *PoseVariance {
+	if len(cov) != 36 {
+		return nil
+	}
+	return &PoseVariance{X: cov[0], Y: cov[7], Yaw: cov[35]}
+}
+
+// SetAMCLPoseTopic sets the PoseWithCovarianceStamped topic (without
+// namespace) subscribed with the standard topics; empty turns it off.
+// Takes effect at the next (re)subscribe.
+func (c *Client) SetAMCLPoseTopic(topic string) {
+	if topic == "" {
+		c.topicAMCL.Store(nil)
+		return
+	}
+	full := c.ns + topic
+	c.topicAMCL.Store(&full)
+}
+
+// subscribeAMCLPose subscribes to the configured amcl_pose topic, if any.
+func (c *Client) subscribeAMCLPose() {
+	if p := c.topicAMCL.Load(); p != nil {
+		c.subscribe(*p, TypePoseWithCovariance, "")
+	}
+}
+
+func (c *Client) isAMCLPoseTopic(topic string) bool {
+	p := c.topicAMCL.Load()
+	return p != nil && *p == topic
+}
+
+func (c *Client) parseAMCLPose(msg json.RawMessage) {
+	var m struct {
+		Header Header             `json:"header"`
+		Pose   PoseWithCovariance `json:"pose"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return
+	}
+	c.observeStamp(m.Header.Stamp)
+	if v := VarianceFromCovariance(m.Pose.Covariance); v != nil {
+		c.hooks.amclPose.fire(*v)
+	}
+}
+
+// DefaultInitialPoseTopic is where localization (AMCL) takes pose
+// estimates, as RViz's "2D Pose Estimate" publishes them.
+const DefaultInitialPoseTopic = "/initialpose"
+
+// initialPoseCovariance is RViz's estimate spread: 0.5 m in x and y,
+// about 15° in yaw.
+var initialPoseCovariance = [36]float64{0: 0.25, 7: 0.25, 35: 0.06853891945200942}
+
+// PublishInitialPose publishes a map-frame pose estimate on topic
+// (without namespace) as a PoseWithCovarianceStamped, re-initializing
+// localization there. The topic is advertised first, since nothing else
+// publishes on it while the app runs.
+func (c *Client) PublishInitialPose(topic string, p Pose2D) error {
+	topic = c.ns + topic
+	if err := c.send(AdvertiseMsg(topic, TypePoseWithCovariance, c.nextOpID("advertise", topic))); err != nil {
+		return err
+	}
+	msg := map[string]interface{}{
+		"header": map[string]interface{}{"frame_id": "map"},
+		"pose": map[string]interface{}{
+			"pose": map[string]interface{}{
+				"position":    map[string]float64{"x": p.X, "y": p.Y, "z": 0},
+				"orientation": map[string]float64{"x": 0, "y": 0, "z": math.Sin(p.Theta / 2), "w": math.Cos(p.Theta / 2)},
+			},
+			"covariance": initialPoseCovariance,
+		},
+	}
+	return c.send(PublishMsg(topic, msg))
+}
+
+// DefaultGlobalLocalizationService is AMCL's std_srvs/Empty service
+// scattering the particles over the whole map.
+const DefaultGlobalLocalizationService = "/reinitialize_global_localization"
+
+// GlobalRelocalize calls service (without namespace; empty for
+// DefaultGlobalLocalizationService) with no arguments, so localization
+// forgets the pose it had and searches the whole map again.
+func (c *Client) GlobalRelocalize(service string) error {
+	if service == "" {
+		service = DefaultGlobalLocalizationService
+	}
+	_, err := c.CallService(service, map[string]interface{}{}, 10*time.Second)
+	return err
+}
@@ -0,0 +1,37 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// ──────────────────────────── Map save progress
+//
+// Robots that report save progress publish a std_msgs/Float32 percentage
+// (0–100) on a topic of their own. It is only subscribed while a save
+// runs, so it is not part of SubscribeAllTopics.
+
+// SubscribeMapSaveProgress subscribes to the namespaced progress topic
+// until UnsubscribeMapSaveProgress.
+func (c *Client) SubscribeMapSaveProgress(topic string) {
+	full := c.ns + topic
+	c.topicSaveProg.Store(&full)
+	c.subscribe(full, TypeFloat32, "")
+}
+
+// UnsubscribeMapSaveProgress ends the progress subscription, if any.
+func (c *Client) UnsubscribeMapSaveProgress() {
+	if p := c.topicSaveProg.Swap(nil); p != nil {
+		c.unsubscribe(*p)
+	}
+}
+
+func (c *Client) parseMapSaveProgress(msg json.RawMessage) {
+	var m struct {
+		Data float64 `json:"data"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil || math.IsNaN(m.Data) {
+		return
+	}
+	c.hooks.saveProgress.fire(math.Max(0, math.Min(100, m.Data)))
+}
@@ -0,0 +1,43 @@
+package rosbridge
+
+import "encoding/json"
+
+// ──────────────────────────── Robot-side incident markers
+//
+// Onboard software can flag a moment worth finding later by publishing a
+// std_msgs/String label on the marker topic. The subscription is optional
+// (SetMarkerTopic) and light, so it is kept while the robot is idle.
+
+// SetMarkerTopic sets the std_msgs/String topic (without namespace)
+// subscribed with the standard topics; empty turns it off. Takes effect
+// at the next (re)subscribe.
+func (c *Client) SetMarkerTopic(topic string) {
+	if topic == "" {
+		c.topicMarker.Store(nil)
+		return
+	}
+	full := c.ns + topic
+	c.topicMarker.Store(&full)
+}
+
+// subscribeMarker subscribes to the configured marker topic, if any.
+func (c *Client) subscribeMarker() {
+	if p := c.topicMarker.Load(); p != nil {
+		c.subscribe(*p, TypeString, "")
+	}
+}
+
+func (c *Client) isMarkerTopic(topic string) bool {
+	p := c.topicMarker.Load()
+	return p != nil && *p == topic
+}
+
+func (c *Client) parseMarker(msg json.RawMessage) {
+	var m struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return
+	}
+	c.hooks.marker.fire(m.Data)
+}
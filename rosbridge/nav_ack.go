@@ -0,0 +1,91 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ──────────────────────────── construct_yaml_and_bt upload acknowledgment
+//
+// Robots that validate uploads answer add_*/save_* requests with what
+// they kept:
+//
+//	{"accepted": 3, "rejected": [{"name": "dock", "reason": "duplicate"}],
+//	 "file_path": "/maps/office/waypoints.yaml"}
+//
+// rejected may also be a list of bare names. Older firmware answers with
+// a bare status; such an ack is not Verified and counts every point as
+// accepted, as the app always assumed.
+
+// RejectedPoint is a point the robot refused.
+type RejectedPoint struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// NavAck is the robot's answer to a point upload.
+type NavAck struct {
+	Sent     int             `json:"sent"`
+	Accepted int             `json:"accepted"`
+	Rejected []RejectedPoint `json:"rejected"`
+	FilePath string          `json:"file_path,omitempty"`
+	// Verified is false when the response didn't say what was kept.
+	Verified bool `json:"verified"`
+}
+
+// Partial reports whether the robot refused some of the points.
+func (a *NavAck) Partial() bool { return len(a.Rejected) > 0 }
+
+// navAckValues is the acknowledgment as the robot sends it.
+type navAckValues struct {
+	Accepted *int            `json:"accepted"`
+	Rejected json.RawMessage `json:"rejected"`
+	FilePath string          `json:"file_path"`
+}
+
+// ParseNavAck decodes the service response to an upload of sent points.
+// It fails only when rosbridge reports the call itself failed.
+func ParseNavAck(raw json.RawMessage, sent int) (*NavAck, error) {
+	var resp struct {
+		Result *bool           `json:"result"`
+		Values json.RawMessage `json:"values"`
+	}
+	json.Unmarshal(raw, &resp)
+	if resp.Result != nil && !*resp.Result {
+		return nil, fmt.Errorf("robot refused the upload: %s", resp.Values)
+	}
+
+	ack := &NavAck{Sent: sent, Accepted: sent, Rejected: []RejectedPoint{}}
+	body := resp.Values
+	if len(body) == 0 {
+		// Fallback: direct parse
+		body = raw
+	}
+	var v navAckValues
+	if err := json.Unmarshal(body, &v); err != nil || (v.Accepted == nil && len(v.Rejected) == 0) {
+		return ack, nil
+	}
+
+	if len(v.Rejected) > 0 && string(v.Rejected) != "null" {
+		var points []RejectedPoint
+		var names []string
+		switch {
+		case json.Unmarshal(v.Rejected, &points) == nil:
+			ack.Rejected = append(ack.Rejected, points...)
+		case json.Unmarshal(v.Rejected, &names) == nil:
+			for _, n := range names {
+				ack.Rejected = append(ack.Rejected, RejectedPoint{Name: n})
+			}
+		default:
+			return ack, nil
+		}
+	}
+	ack.Verified = true
+	ack.FilePath = v.FilePath
+	if v.Accepted != nil {
+		ack.Accepted = *v.Accepted
+	} else {
+		ack.Accepted = max(sent-len(ack.Rejected), 0)
+	}
+	return ack, nil
+}
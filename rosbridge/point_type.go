@@ -0,0 +1,107 @@
+package rosbridge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ──────────────────────────── Point types
+//
+// The HTTP API names collections waypoint/service_point/patrol_point/
+// path_point/wall, while construct_yaml_and_bt and the robot's YAML use
+// waypoints/servicepoints/patrolpoints/pathpoints/obstacles. PointType is
+// the one spelling used inside the app; ParsePointType accepts all of
+// them at the edges.
+
+// PointType names a navigation point collection.
+type PointType string
+
+// Point types.
+const (
+	PointWaypoint PointType = "waypoint"
+	PointService  PointType = "service_point"
+	PointPatrol   PointType = "patrol_point"
+	PointPath     PointType = "path_point"
+	PointWall     PointType = "wall"
+)
+
+// NavPointTypes are the navigable collections (every type but walls), in
+// display order.
+var NavPointTypes = []PointType{PointWaypoint, PointService, PointPatrol, PointPath}
+
+// ErrInvalidPointType is returned for a type name no spelling matches,
+// and for operations a type doesn't support (walls can't be navigated).
+var ErrInvalidPointType = errors.New("invalid point type")
+
+// pointTypeSpellings maps a type name, lowercased with separators and a
+// plural s removed, to its type.
+var pointTypeSpellings = map[string]PointType{
+	"waypoint":     PointWaypoint,
+	"servicepoint": PointService,
+	"patrolpoint":  PointPatrol,
+	"pathpoint":    PointPath,
+	"wall":         PointWall,
+	"wallobstacle": PointWall,
+	"obstacle":     PointWall,
+}
+
+// ParsePointType accepts the API form (service_point), the robot's form
+// (servicepoint, servicepoints) and variants in case, separators (-, _,
+// space) and plural.
+func ParsePointType(s string) (PointType, error) {
+	key := strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(s)))
+	if t, ok := pointTypeSpellings[key]; ok {
+		return t, nil
+	}
+	if t, ok := pointTypeSpellings[strings.TrimSuffix(key, "s")]; ok {
+		return t, nil
+	}
+	return "", fmt.Errorf("%w %q (want waypoint, service_point, patrol_point, path_point or wall)", ErrInvalidPointType, s)
+}
+
+// Navigable reports whether t is a point collection the robot can go
+// through (not walls).
+func (t PointType) Navigable() bool {
+	return t != PointWall && t.Valid()
+}
+
+// Valid reports whether t is one of the point type constants.
+func (t PointType) Valid() bool {
+	switch t {
+	case PointWaypoint, PointService, PointPatrol, PointPath, PointWall:
+		return true
+	}
+	return false
+}
+
+// wireName is the collection's name in construct_yaml_and_bt requests
+// (add_<name>, get_<name>, go_all_<name>) and their payload key.
+func (t PointType) wireName() string {
+	switch t {
+	case PointWaypoint:
+		return "waypoints"
+	case PointService:
+		return "servicepoints"
+	case PointPatrol:
+		return "patrolpoints"
+	case PointPath:
+		return "pathpoints"
+	case PointWall:
+		return "obstacles"
+	}
+	return ""
+}
+
+// navWireName is wireName for navigable types, or an error.
+func (t PointType) navWireName() (string, error) {
+	if !t.Navigable() {
+		return "", fmt.Errorf("%w %q for this request", ErrInvalidPointType, string(t))
+	}
+	return t.wireName(), nil
+}
@@ -0,0 +1,60 @@
+package rosbridge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePointType(t *testing.T) {
+	for want, spellings := range map[PointType][]string{
+		PointWaypoint: {"waypoint", "waypoints", "Waypoint", "WAYPOINTS", "way-point", " way_points "},
+		PointService:  {"service_point", "servicepoint", "servicepoints", "Service Points", "service-point"},
+		PointPatrol:   {"patrol_point", "patrolpoints", "PatrolPoint", "patrol points"},
+		PointPath:     {"path_point", "pathpoint", "pathpoints", "Path_Points"},
+		PointWall:     {"wall", "walls", "obstacle", "obstacles", "wall_obstacles", "WallObstacle"},
+	} {
+		for _, s := range spellings {
+			got, err := ParsePointType(s)
+			if err != nil || got != want {
+				t.Errorf("ParsePointType(%q) = %q, %v; want %q", s, got, err, want)
+			}
+		}
+	}
+
+	for _, s := range []string{"", " ", "point", "waypointss", "services", "way point x", "s"} {
+		if got, err := ParsePointType(s); !errors.Is(err, ErrInvalidPointType) {
+			t.Errorf("ParsePointType(%q) = %q, %v; want ErrInvalidPointType", s, got, err)
+		}
+	}
+}
+
+func TestPointTypeWireNames(t *testing.T) {
+	for _, tc := range []struct {
+		t         PointType
+		wire      string
+		navigable bool
+	}{
+		{PointWaypoint, "waypoints", true},
+		{PointService, "servicepoints", true},
+		{PointPatrol, "patrolpoints", true},
+		{PointPath, "pathpoints", true},
+		{PointWall, "obstacles", false},
+		{PointType("bogus"), "", false},
+	} {
+		if got := tc.t.wireName(); got != tc.wire {
+			t.Errorf("%q wire name %q, want %q", tc.t, got, tc.wire)
+		}
+		if tc.t.Navigable() != tc.navigable {
+			t.Errorf("%q navigable %v", tc.t, tc.t.Navigable())
+		}
+		if _, err := tc.t.navWireName(); (err == nil) != tc.navigable {
+			t.Errorf("%q navWireName: %v", tc.t, err)
+		}
+		// The robot's spelling parses back
+		if tc.wire != "" {
+			if back, err := ParsePointType(tc.wire); err != nil || back != tc.t {
+				t.Errorf("ParsePointType(%q) = %q, %v", tc.wire, back, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProbeResult is what a one-shot Probe learned about a rosbridge server.
+type ProbeResult struct {
+	Namespace string  `json:"namespace"`
+	Diameter  float64 `json:"diameter"`
+}
+
+// Probe connects to a rosbridge server once (no reconnect), locates the
+// robot's which_name service through rosapi and performs the handshake.
+// If rosapi is unavailable it falls back to an un-namespaced /which_name.
+func Probe(host string, port int, timeout time.Duration) (*ProbeResult, error) {
+	c := NewClient("", host, port)
+	c.noReconnect = true
+	if err := c.Connect(); err != nil {
+		return nil, err
+	}
+	defer c.Disconnect()
+
+	if ns, err := c.findWhichNameNamespace(timeout); err == nil {
+		c.ns = ns
+	}
+
+	args := WhichMapsArgs("handshake", "", "", "*#5447972162718281828459#")
+	raw, err := c.CallService("/which_name", args, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	var resp struct {
+		Values HandshakeResponse `json:"values"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("parse handshake: %w", err)
+	}
+
+	ns := resp.Values.RobotNamespace
+	if ns == "" {
+		ns = c.ns
+	}
+	return &ProbeResult{
+		Namespace: strings.TrimPrefix(ns, "/"),
+		Diameter:  resp.Values.RobotDiameter,
+	}, nil
+}
+
+// findWhichNameNamespace lists services via rosapi and returns the
+// namespace prefix of the first */which_name service.
+func (c *Client) findWhichNameNamespace(timeout time.Duration) (string, error) {
+	raw, err := c.CallService("/rosapi/services", map[string]interface{}{}, timeout)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Values struct {
+			Services []string `json:"services"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return "", err
+	}
+	for _, svc := range resp.Values.Services {
+		if strings.HasSuffix(svc, "/which_name") {
+			return strings.TrimSuffix(svc, "/which_name"), nil
+		}
+	}
+	return "", fmt.Errorf("no which_name service")
+}
@@ -1,16 +1,42 @@
 package rosbridge
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
 
 // ──────────────────────────── Rosbridge JSON protocol helpers
 
+// SubscribeOptions are the optional rosbridge subscribe fields applied on
+// the robot side. Zero values are omitted so rosbridge uses its defaults.
+type SubscribeOptions struct {
+	ThrottleRate int    // minimum ms between messages
+	QueueLength  int    // messages buffered while throttled
+	Compression  string // "" or "cbor"
+	ID           string // echoed back in rosbridge status messages
+}
+
 // SubscribeMsg creates a rosbridge subscribe message.
-func SubscribeMsg(topic, msgType string) []byte {
+func SubscribeMsg(topic, msgType string, opts ...SubscribeOptions) []byte {
 	msg := map[string]interface{}{
 		"op":    "subscribe",
 		"topic": topic,
 		"type":  msgType,
 	}
+	for _, o := range opts {
+		if o.ThrottleRate > 0 {
+			msg["throttle_rate"] = o.ThrottleRate
+		}
+		if o.QueueLength > 0 {
+			msg["queue_length"] = o.QueueLength
+		}
+		if o.Compression != "" {
+			msg["compression"] = o.Compression
+		}
+		if o.ID != "" {
+			msg["id"] = o.ID
+		}
+	}
 	b, _ := json.Marshal(msg)
 	return b
 }
@@ -25,6 +51,22 @@ func UnsubscribeMsg(topic string) []byte {
 	return b
 }
 
+// AdvertiseMsg creates a rosbridge advertise message, declaring the type
+// of a topic before publishing on it. A non-empty id is echoed in status
+// messages about the op.
+func AdvertiseMsg(topic, msgType, id string) []byte {
+	msg := map[string]interface{}{
+		"op":    "advertise",
+		"topic": topic,
+		"type":  msgType,
+	}
+	if id != "" {
+		msg["id"] = id
+	}
+	b, _ := json.Marshal(msg)
+	return b
+}
+
 // PublishMsg creates a rosbridge publish message.
 func PublishMsg(topic string, data interface{}) []byte {
 	msg := map[string]interface{}{
@@ -36,6 +78,35 @@ func PublishMsg(topic string, data interface{}) []byte {
 	return b
 }
 
+// FragmentMsg splits a rosbridge message into op:"fragment" frames
+// carrying at most size bytes of its text each, cut between UTF-8
+// characters; a message that fits is returned as the only frame.
+func FragmentMsg(msg []byte, id string, size int) [][]byte {
+	if size <= 0 || len(msg) <= size {
+		return [][]byte{msg}
+	}
+	var parts []string
+	for len(msg) > 0 {
+		n := min(size, len(msg))
+		for n < len(msg) && n > 1 && !utf8.RuneStart(msg[n]) {
+			n--
+		}
+		parts = append(parts, string(msg[:n]))
+		msg = msg[n:]
+	}
+	frames := make([][]byte, len(parts))
+	for i, p := range parts {
+		frames[i], _ = json.Marshal(map[string]interface{}{
+			"op":    "fragment",
+			"id":    id,
+			"data":  p,
+			"num":   i,
+			"total": len(parts),
+		})
+	}
+	return frames
+}
+
 // CallServiceMsg creates a rosbridge call_service message.
 func CallServiceMsg(service string, args interface{}, id string) []byte {
 	msg := map[string]interface{}{
@@ -56,6 +127,11 @@ const (
 	TypeTFMessage     = "tf2_msgs/msg/TFMessage"
 	TypeLaserScan     = "sensor_msgs/msg/LaserScan"
 	TypeTwist         = "geometry_msgs/msg/Twist"
+	TypeGoalStatus    = "action_msgs/msg/GoalStatusArray"
+	TypeFloat32       = "std_msgs/msg/Float32"
+	TypeString        = "std_msgs/msg/String"
+
+	TypePoseWithCovariance = "geometry_msgs/msg/PoseWithCovarianceStamped"
 )
 
 // ──────────────────────────── which_maps service args builder
@@ -92,6 +168,20 @@ func WaypointToJSON(pts []NavigationPoint) []map[string]interface{} {
 			"world_y_m":       p.WorldYM,
 			"world_theta_rad": p.WorldThetaRad,
 		}
+		// Approach parameters only when set, so firmware that predates
+		// them sees the original point shape.
+		if p.MaxSpeedMPS != 0 {
+			result[i]["max_speed_mps"] = p.MaxSpeedMPS
+		}
+		if p.DwellSec != 0 {
+			result[i]["dwell_sec"] = p.DwellSec
+		}
+		if p.YawToleranceRad != 0 {
+			result[i]["yaw_tolerance_rad"] = p.YawToleranceRad
+		}
+		if p.OnArrivalTask != "" {
+			result[i]["on_arrival_task"] = p.OnArrivalTask
+		}
 	}
 	return result
 }
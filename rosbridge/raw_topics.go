@@ -0,0 +1,153 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// ──────────────────────────── Raw topic subscriptions
+//
+// Topics the client doesn't parse itself can be subscribed to with
+// SubscribeRaw, which hands each message over exactly as rosbridge sent
+// it. Handlers of a topic share one subscription, made when the first is
+// added and dropped with the last; it is replayed on reconnect like the
+// standard topics and kept while the robot idles, since someone asked for
+// it. A standard topic (odometry, scan, ...) is already subscribed, so
+// raw handlers on it just receive its messages too.
+
+// RawHandler receives a topic's message payload unparsed.
+type RawHandler func(msg json.RawMessage)
+
+// rawTopic is one raw-subscribed topic and its handlers by ID.
+type rawTopic struct {
+	msgType  string
+	handlers map[uint64]RawHandler
+}
+
+// rawTopics are the client's raw subscriptions by full topic name.
+type rawTopics struct {
+	mu     sync.RWMutex
+	topics map[string]*rawTopic
+	nextID uint64
+}
+
+// RawTopicName returns topic (without namespace) as subscribed: prefixed
+// with the robot namespace.
+func (c *Client) RawTopicName(topic string) string {
+	return c.ns + topic
+}
+
+// SubscribeRaw calls fn with every message of topic (without namespace);
+// msgType may be empty when rosbridge can tell the type itself. The
+// returned function removes fn, unsubscribing once no handler is left.
+// The first handler's type is the one subscribed.
+func (c *Client) SubscribeRaw(topic, msgType string, fn RawHandler) (stop func()) {
+	full := c.RawTopicName(topic)
+	c.raw.mu.Lock()
+	if c.raw.topics == nil {
+		c.raw.topics = make(map[string]*rawTopic)
+	}
+	t := c.raw.topics[full]
+	first := t == nil
+	if first {
+		t = &rawTopic{msgType: msgType, handlers: make(map[uint64]RawHandler)}
+		c.raw.topics[full] = t
+	}
+	c.raw.nextID++
+	id := c.raw.nextID
+	t.handlers[id] = fn
+	c.raw.mu.Unlock()
+
+	if first && !c.isStandardTopic(full) && c.IsConnected() {
+		c.subscribe(full, msgType, "")
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { c.removeRaw(full, id) })
+	}
+}
+
+func (c *Client) removeRaw(full string, id uint64) {
+	c.raw.mu.Lock()
+	t := c.raw.topics[full]
+	if t == nil {
+		c.raw.mu.Unlock()
+		return
+	}
+	delete(t.handlers, id)
+	last := len(t.handlers) == 0
+	if last {
+		delete(c.raw.topics, full)
+	}
+	c.raw.mu.Unlock()
+
+	if last && !c.isStandardTopic(full) {
+		c.unsubscribe(full)
+	}
+}
+
+// RawTopics returns the raw-subscribed topics (full names) with their
+// handler counts.
+func (c *Client) RawTopics() map[string]int {
+	c.raw.mu.RLock()
+	defer c.raw.mu.RUnlock()
+	out := make(map[string]int, len(c.raw.topics))
+	for name, t := range c.raw.topics {
+		out[name] = len(t.handlers)
+	}
+	return out
+}
+
+// subscribeRawTopics (re)subscribes the raw topics; part of
+// SubscribeAllTopics.
+func (c *Client) subscribeRawTopics() {
+	for topic, msgType := range c.rawSubscriptions() {
+		c.subscribe(topic, msgType, "")
+	}
+}
+
+// rawSubscriptions returns the raw topics needing their own subscription,
+// with their types.
+func (c *Client) rawSubscriptions() map[string]string {
+	c.raw.mu.RLock()
+	defer c.raw.mu.RUnlock()
+	out := make(map[string]string, len(c.raw.topics))
+	for name, t := range c.raw.topics {
+		if !c.isStandardTopic(name) {
+			out[name] = t.msgType
+		}
+	}
+	return out
+}
+
+// isStandardTopic reports whether topic is one the client subscribes to
+// for itself.
+func (c *Client) isStandardTopic(topic string) bool {
+	t := c.topicNames()
+	switch topic {
+	case t.Map, t.CmdVel, t.TF, t.TFStatic, t.Odom, t.CtrlOdom, t.Laser, t.MapBfp, t.NavStat:
+		return true
+	}
+	if p := c.topicSaveProg.Load(); p != nil && *p == topic {
+		return true
+	}
+	return c.isAMCLPoseTopic(topic) || c.isMarkerTopic(topic)
+}
+
+// dispatchRaw hands msg to the raw handlers of topic, if any.
+func (c *Client) dispatchRaw(topic string, msg json.RawMessage) {
+	c.raw.mu.RLock()
+	t := c.raw.topics[topic]
+	var fns []RawHandler
+	if t != nil {
+		fns = make([]RawHandler, 0, len(t.handlers))
+		for _, fn := range t.handlers {
+			fns = append(fns, fn)
+		}
+	}
+	c.raw.mu.RUnlock()
+	for _, fn := range fns {
+		fn(msg)
+	}
+}
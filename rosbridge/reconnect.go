@@ -0,0 +1,212 @@
+package rosbridge
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ──────────────────────────── Reconnect policy
+//
+// A lost or failed connection is retried after a delay that doubles from
+// InitialDelayMs up to MaxDelayMs. After MaxAttempts failed attempts, or
+// at once if the policy is disabled, the client is suspended: nothing is
+// dialed until Connect is called explicitly. Disconnect and Close cancel
+// a pending attempt.
+//
+// While the robot is expected to be down (it acknowledged a reboot; see
+// ExpectDowntime) the client keeps retrying at the policy's delays
+// whatever its limits, so a robot that reboots comes back on its own;
+// the next successful connect ends the hold.
+
+// Connection states reported by ReconnectStatus.
+const (
+	ConnConnected    = "connected"
+	ConnReconnecting = "reconnecting" // an attempt is scheduled
+	ConnSuspended    = "suspended"    // gave up; needs an explicit Connect
+	ConnDisconnected = "disconnected" // never connected, or disconnected on request
+)
+
+// ReconnectPolicy controls automatic reconnection.
+type ReconnectPolicy struct {
+	Enabled        bool  `json:"enabled"`
+	InitialDelayMs int64 `json:"initial_delay_ms"`
+	MaxDelayMs     int64 `json:"max_delay_ms"`
+	MaxAttempts    int   `json:"max_attempts"` // 0 = unlimited
+}
+
+// DefaultReconnectPolicy retries forever, starting after 3 s and backing
+// off to 30 s.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	Enabled:        true,
+	InitialDelayMs: 3000,
+	MaxDelayMs:     30000,
+}
+
+// Validate checks the delays and attempt limit.
+func (p ReconnectPolicy) Validate() error {
+	switch {
+	case p.InitialDelayMs <= 0:
+		return fmt.Errorf("reconnect initial delay must be positive")
+	case p.MaxDelayMs < p.InitialDelayMs:
+		return fmt.Errorf("reconnect max delay must be at least the initial delay")
+	case p.MaxAttempts < 0:
+		return fmt.Errorf("reconnect max attempts must not be negative")
+	}
+	return nil
+}
+
+// delay returns the wait before attempt n (1-based).
+func (p ReconnectPolicy) delay(n int) time.Duration {
+	d := p.InitialDelayMs
+	for i := 1; i < n && d < p.MaxDelayMs; i++ {
+		d *= 2
+	}
+	return time.Duration(min(d, p.MaxDelayMs)) * time.Millisecond
+}
+
+// ReconnectStatus is the policy with the reconnect loop's progress.
+type ReconnectStatus struct {
+	Policy      ReconnectPolicy `json:"policy"`
+	State       string          `json:"state"`
+	Attempts    int             `json:"attempts"` // since the last successful or explicit connect
+	NextAttempt *time.Time      `json:"next_attempt,omitempty"`
+	// DowntimeUntil is the end of an expected downtime the client
+	// retries through.
+	DowntimeUntil *time.Time `json:"downtime_until,omitempty"`
+}
+
+// reconnector is the reconnect loop's state, guarded by Client.mu.
+type reconnector struct {
+	policy    ReconnectPolicy
+	attempts  int
+	timer     *time.Timer
+	next      time.Time
+	suspended bool
+	stopped   bool      // Disconnect was called; no retries until Connect
+	closed    bool      // Close was called; never again
+	holdUntil time.Time // retry regardless of the policy until then
+}
+
+// stopTimerLocked cancels a pending attempt. Caller holds c.mu.
+func (c *Client) stopTimerLocked() {
+	if c.rc.timer != nil {
+		c.rc.timer.Stop()
+		c.rc.timer = nil
+	}
+	c.rc.next = time.Time{}
+}
+
+// SetReconnectPolicy replaces the policy. A pending attempt is
+// rescheduled under the new delays; a suspended client stays suspended.
+func (c *Client) SetReconnectPolicy(p ReconnectPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.rc.policy = p
+	pending := c.rc.timer != nil
+	if pending {
+		c.stopTimerLocked()
+		c.rc.attempts--
+	}
+	c.mu.Unlock()
+	if pending {
+		c.scheduleReconnect()
+	}
+	return nil
+}
+
+// ReconnectPolicy returns the current policy.
+func (c *Client) ReconnectPolicy() ReconnectPolicy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rc.policy
+}
+
+// ReconnectStatus returns the policy, connection state and attempt count.
+func (c *Client) ReconnectStatus() ReconnectStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := ReconnectStatus{Policy: c.rc.policy, Attempts: c.rc.attempts}
+	if time.Now().Before(c.rc.holdUntil) {
+		until := c.rc.holdUntil
+		st.DowntimeUntil = &until
+	}
+	switch {
+	case c.connected:
+		st.State = ConnConnected
+	case c.rc.timer != nil:
+		st.State = ConnReconnecting
+		next := c.rc.next
+		st.NextAttempt = &next
+	case c.reverse:
+		st.State = ConnAwaitingAgent
+	case c.rc.suspended:
+		st.State = ConnSuspended
+	default:
+		st.State = ConnDisconnected
+	}
+	return st
+}
+
+// Close disconnects and cancels reconnection for good; used when the
+// robot is removed.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rc.closed = true
+	c.stopTimerLocked()
+	c.disconnect()
+}
+
+// scheduleReconnect arms the next attempt, or suspends the client when
+// the policy allows no more.
+func (c *Client) scheduleReconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rc := &c.rc
+	if c.noReconnect || c.reverse || c.connected || rc.closed || rc.stopped || rc.suspended || rc.timer != nil {
+		return
+	}
+	p := rc.policy
+	held := time.Now().Before(rc.holdUntil)
+	if !held && (!p.Enabled || (p.MaxAttempts > 0 && rc.attempts >= p.MaxAttempts)) {
+		rc.suspended = true
+		log.Printf("[rosbridge] Reconnect to %s:%d suspended after %d attempts", c.host, c.port, rc.attempts)
+		go c.hooks.suspended.fire(ReconnectStatus{Policy: p, State: ConnSuspended, Attempts: rc.attempts})
+		return
+	}
+	rc.attempts++
+	d := p.delay(rc.attempts)
+	rc.next = time.Now().Add(d)
+	rc.timer = time.AfterFunc(d, c.reconnectNow)
+}
+
+// ExpectDowntime keeps the client retrying until until, whatever the
+// policy, instead of suspending: the robot is expected to drop the
+// connection and come back. Connecting ends it; a zero time cancels it.
+func (c *Client) ExpectDowntime(until time.Time) {
+	c.mu.Lock()
+	c.rc.holdUntil = until
+	c.mu.Unlock()
+}
+
+// reconnectNow runs a scheduled attempt.
+func (c *Client) reconnectNow() {
+	c.mu.Lock()
+	c.rc.timer = nil
+	c.rc.next = time.Time{}
+	skip := c.connected || c.rc.closed || c.rc.stopped || c.rc.suspended
+	attempt, limit := c.rc.attempts, c.rc.policy.MaxAttempts
+	c.mu.Unlock()
+	if skip {
+		return
+	}
+	if limit > 0 {
+		log.Printf("[rosbridge] Reconnecting to %s:%d (attempt %d/%d) ...", c.host, c.port, attempt, limit)
+	} else {
+		log.Printf("[rosbridge] Reconnecting to %s:%d (attempt %d) ...", c.host, c.port, attempt)
+	}
+	c.connect()
+}
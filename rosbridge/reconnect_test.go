@@ -0,0 +1,134 @@
+package rosbridge
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// restart serves s's handler again on addr, the address it had before
+// being closed.
+func (s *fakeServer) restart(t *testing.T, addr string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("relisten on %s: %v", addr, err)
+	}
+	s.srv = httptest.NewUnstartedServer(s.srv.Config.Handler)
+	s.srv.Listener = ln
+	s.srv.Start()
+	t.Cleanup(s.srv.Close)
+}
+
+// trackConns has s keep its connections in live, so the test can drop
+// them: the HTTP server doesn't close hijacked connections.
+func trackConns(s *fakeServer) *[]*websocket.Conn {
+	var live []*websocket.Conn
+	s.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		s.mu.Lock()
+		live = append(live, conn)
+		s.mu.Unlock()
+		serveCalls(idx, conn, s)
+	}
+	return &live
+}
+
+// drop closes the server's connections, and the server too if stop.
+func (s *fakeServer) drop(live *[]*websocket.Conn, stop bool) {
+	if stop {
+		s.srv.Listener.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range *live {
+		c.Close()
+	}
+	*live = nil
+}
+
+func (s *fakeServer) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// TestReconnectSuspension loses the server: the client backs off for
+// its two attempts, stays suspended while the server is back, and
+// resumes on an explicit Connect. A disabled policy suspends at once.
+func TestReconnectSuspension(t *testing.T) {
+	s := newFakeServer(t)
+	live := trackConns(s)
+	c := s.client(t, "")
+	c.noReconnect = false
+	suspended := make(chan ReconnectStatus, 4)
+	c.AddSuspendedHandler(func(st ReconnectStatus) { suspended <- st })
+	if err := c.SetReconnectPolicy(ReconnectPolicy{Enabled: true, InitialDelayMs: 20, MaxDelayMs: 40, MaxAttempts: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := s.srv.Listener.Addr().String()
+	lost := time.Now()
+	s.drop(live, true)
+	select {
+	case st := <-suspended:
+		if st.Attempts != 2 {
+			t.Errorf("suspended after %d attempts, want 2", st.Attempts)
+		}
+		// 20 ms, then 40 ms
+		if d := time.Since(lost); d < 60*time.Millisecond {
+			t.Errorf("suspended %s after the loss, before the backoff", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("not suspended")
+	}
+	if st := c.ReconnectStatus(); st.State != ConnSuspended || st.Attempts != 2 || st.NextAttempt != nil {
+		t.Errorf("status %+v", st)
+	}
+
+	s.restart(t, addr)
+	conns := s.connCount()
+	time.Sleep(100 * time.Millisecond)
+	if n := s.connCount(); n != conns || c.ReconnectStatus().State != ConnSuspended {
+		t.Errorf("suspended client dialled: %d connections, state %s", n-conns, c.ReconnectStatus().State)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if st := c.ReconnectStatus(); st.State != ConnConnected || st.Attempts != 0 {
+		t.Errorf("after Connect %+v", st)
+	}
+
+	// A dropped connection is redialled while the server is up
+	s.drop(live, false)
+	waitFor(t, "redial", func() bool { return s.connCount() > conns+1 && c.ReconnectStatus().State == ConnConnected })
+	if st := c.ReconnectStatus(); st.Attempts != 0 {
+		t.Errorf("attempts after reconnecting %+v", st)
+	}
+
+	c.SetReconnectPolicy(ReconnectPolicy{InitialDelayMs: 20, MaxDelayMs: 40})
+	s.drop(live, true)
+	select {
+	case st := <-suspended:
+		if st.Attempts != 0 {
+			t.Errorf("disabled policy made %d attempts", st.Attempts)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("disabled policy not suspended")
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	p := ReconnectPolicy{Enabled: true, InitialDelayMs: 3000, MaxDelayMs: 20000}
+	want := []time.Duration{3 * time.Second, 6 * time.Second, 12 * time.Second, 20 * time.Second, 20 * time.Second}
+	for i, w := range want {
+		if d := p.delay(i + 1); d != w {
+			t.Errorf("attempt %d: %s, want %s", i+1, d, w)
+		}
+	}
+}
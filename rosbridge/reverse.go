@@ -0,0 +1,83 @@
+package rosbridge
+
+import (
+	"errors"
+	"log"
+	"net"
+)
+
+// ──────────────────────────── Reverse connections
+//
+// A robot behind NAT can't be dialed, but a small agent on it can dial
+// out: it connects to the server's robot gateway and then speaks the
+// rosbridge protocol over that websocket, relaying to the robot's local
+// rosbridge server. A Client in reverse mode doesn't dial either; the
+// gateway hands it the agent's connection with AttachAgent and everything
+// above (subscriptions, service calls, cmd_vel) runs over it unchanged.
+// The agent decides when to reconnect, so a lost connection isn't
+// retried; the client waits for the agent to come back. A reverse client
+// has no data plane and doesn't share its connection.
+
+// ErrAwaitingAgent is returned when connecting a reverse client whose
+// agent isn't connected.
+var ErrAwaitingAgent = errors.New("waiting for the robot's agent to connect")
+
+// ConnAwaitingAgent is the ReconnectStatus state of a reverse client
+// without its agent.
+const ConnAwaitingAgent = "awaiting_agent"
+
+// SetReverse puts the client in reverse mode (or back). Takes effect on
+// the next Connect.
+func (c *Client) SetReverse(enabled bool) {
+	c.mu.Lock()
+	c.reverse = enabled
+	c.mu.Unlock()
+}
+
+// ReverseEnabled reports whether the client waits for an agent instead
+// of dialing.
+func (c *Client) ReverseEnabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reverse
+}
+
+// AttachAgent connects a reverse client over conn, the agent's
+// connection from addr. A connection already up is closed first: the
+// agent reconnected and the old one is stale.
+func (c *Client) AttachAgent(conn Conn, addr string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.reverse {
+		return errors.New("client is not in reverse mode")
+	}
+	if c.rc.closed {
+		return ErrClientClosed
+	}
+	if c.connected {
+		// Replaced without a disconnect event, which could otherwise
+		// arrive after the connect event below.
+		c.connected = false
+		c.stopCmdVelPublisher()
+		c.conn.Close()
+		c.failPendingCalls()
+		log.Printf("[rosbridge] Agent reconnected, replacing its connection (ns=%s)", c.ns)
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	c.agent, c.host = conn, addr
+	c.rc.stopped, c.rc.suspended = false, false
+	return c.connectLocked()
+}
+
+// takeAgent is the reverse client's dial: the waiting agent connection.
+// Caller holds c.mu.
+func (c *Client) takeAgent() (Conn, error) {
+	conn := c.agent
+	if conn == nil {
+		return nil, ErrAwaitingAgent
+	}
+	c.agent = nil
+	return NewChaosConn(conn, &c.chaos), nil
+}
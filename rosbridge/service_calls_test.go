@@ -0,0 +1,244 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serveEcho answers every service call with its own ID and service,
+// from a goroutine per call with a random delay, so replies come back
+// out of order and interleaved.
+func serveEcho(idx int, conn *websocket.Conn, s *fakeServer) {
+	var writeMu sync.Mutex
+	for {
+		op, ok := s.read(idx, conn)
+		if !ok {
+			return
+		}
+		if op.Op != "call_service" {
+			continue
+		}
+		go func(op fakeOp) {
+			time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			conn.WriteJSON(map[string]interface{}{
+				"op": "service_response", "id": op.ID, "service": op.Service,
+				"values": map[string]string{"id": op.ID, "service": op.Service}, "result": true,
+			})
+		}(op)
+	}
+}
+
+func TestParallelServiceCalls(t *testing.T) {
+	s := newFakeServer(t)
+	s.serve = serveEcho
+	c := s.client(t, "/r1")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two services, so that same-service calls land in the same
+	// millisecond as well as different ones
+	const n = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		service := "/get_waypoints"
+		if i%2 == 1 {
+			service = "/get_servicepoints"
+		}
+		wg.Add(1)
+		go func(service string) {
+			defer wg.Done()
+			raw, err := c.CallService(service, nil, 5*time.Second)
+			if err != nil {
+				errs <- err
+				return
+			}
+			var resp struct {
+				Values struct {
+					ID      string `json:"id"`
+					Service string `json:"service"`
+				} `json:"values"`
+			}
+			if err := json.Unmarshal(raw, &resp); err != nil {
+				errs <- err
+				return
+			}
+			if resp.Values.Service != "/r1"+service || serviceFromID(resp.Values.ID) != service {
+				errs <- fmt.Errorf("%s got the reply of %s (%s)", service, resp.Values.Service, resp.Values.ID)
+			}
+		}(service)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Each call had its own ID, and every reply found its caller
+	seen := map[string]bool{}
+	for _, op := range s.received("call_service") {
+		if seen[op.ID] {
+			t.Errorf("ID %s used twice", op.ID)
+		}
+		seen[op.ID] = true
+	}
+	if len(seen) != n {
+		t.Errorf("server saw %d calls, want %d", len(seen), n)
+	}
+	c.svcMu.Lock()
+	pending := len(c.svcPending)
+	c.svcMu.Unlock()
+	if pending != 0 {
+		t.Errorf("%d calls still pending", pending)
+	}
+}
+
+func TestServiceIDs(t *testing.T) {
+	a := NewClient("", "127.0.0.1", 9)
+	b := NewClient("", "127.0.0.1", 9)
+	defer a.Close()
+	defer b.Close()
+
+	ids := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		for _, c := range []*Client{a, b} {
+			id := c.nextServiceID("/which_maps")
+			if ids[id] {
+				t.Fatalf("duplicate ID %s", id)
+			}
+			ids[id] = true
+		}
+	}
+	if a.svcPrefix == b.svcPrefix || a.svcPrefix == "" {
+		t.Errorf("prefixes %q and %q", a.svcPrefix, b.svcPrefix)
+	}
+
+	tests := map[string]string{
+		a.nextServiceID("/get_waypoints"):      "/get_waypoints",
+		a.nextServiceID("/robot_a/which_maps"): "/robot_a/which_maps",
+		"svc_ab12_7_/odom_reset":               "/odom_reset",
+		"advertise:/initialpose":               "",
+		"svc_only":                             "",
+	}
+	for id, want := range tests {
+		if got := serviceFromID(id); got != want {
+			t.Errorf("serviceFromID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+// TestPendingCallsFailOnDisconnect has the server take calls without
+// answering, then drop the connection: the callers must fail at once
+// with ErrNotConnected, not wait out their timeouts.
+func TestPendingCallsFailOnDisconnect(t *testing.T) {
+	s := newFakeServer(t)
+	received := make(chan struct{}, 10)
+	drop := make(chan struct{})
+	s.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		go func() {
+			<-drop
+			conn.Close()
+		}()
+		for {
+			op, ok := s.read(idx, conn)
+			if !ok {
+				return
+			}
+			if op.Op == "call_service" {
+				received <- struct{}{}
+			}
+		}
+	}
+	c := s.client(t, "")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 5
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := c.CallService("/slow", nil, 30*time.Second)
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-received
+	}
+	start := time.Now()
+	close(drop)
+	for i := 0; i < n; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrNotConnected) {
+				t.Errorf("err = %v, want ErrNotConnected", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("pending call not failed on disconnect")
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("calls failed after %v", d)
+	}
+
+	// Calls made while disconnected fail straight away too
+	if _, err := c.CallService("/slow", nil, 30*time.Second); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("call while disconnected: %v", err)
+	}
+}
+
+// TestLateServiceResponse answers a call only after it timed out: the
+// reply is dropped, and the next call to the same service still gets
+// its own answer.
+func TestLateServiceResponse(t *testing.T) {
+	s := newFakeServer(t)
+	s.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		var late string
+		for {
+			op, ok := s.read(idx, conn)
+			if !ok {
+				return
+			}
+			if op.Op != "call_service" {
+				continue
+			}
+			if late == "" {
+				late = op.ID // hold the first call's reply back
+				continue
+			}
+			for _, id := range []string{late, op.ID} {
+				conn.WriteJSON(map[string]interface{}{
+					"op": "service_response", "id": id, "service": op.Service,
+					"values": map[string]string{"id": id}, "result": true,
+				})
+			}
+		}
+	}
+	c := s.client(t, "")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.CallService("/get_waypoints", nil, 50*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("first call: %v, want a timeout", err)
+	}
+	raw, err := c.CallService("/get_waypoints", nil, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := s.received("call_service")
+	if len(calls) != 2 || !strings.Contains(string(raw), calls[1].ID) {
+		t.Errorf("second call got %s; calls %+v", raw, calls)
+	}
+}
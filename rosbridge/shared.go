@@ -0,0 +1,341 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// ──────────────────────────── Shared connections
+//
+// Robots served by one rosbridge server under different namespaces (a
+// simulation, a gateway in front of several robots) can share a single
+// websocket. A Client in shared mode doesn't dial: it attaches to the
+// pool's connection for its host:port, opening it if none is up. The pool
+// reads every frame once and routes it to the attached clients:
+//   - publishes by the namespace prefix of the topic; topics outside
+//     every attached namespace go to clients without one;
+//   - service responses by the caller's service ID prefix;
+//   - status messages by their op ID, to everyone if it names neither;
+//   - anything else to everyone.
+//
+// Losing the connection fails every attached client, which then
+// reconnects on its own policy. The socket is closed when the last client
+// detaches. CBOR frames are decoded by the pool, so shared clients count
+// their decoded size as traffic.
+
+// ErrSharedClosed is returned when attaching to a shared connection that
+// was lost meanwhile.
+var ErrSharedClosed = errors.New("shared rosbridge connection closed")
+
+// sharedFrameBuffer is how many routed frames an attached client may lag
+// behind before it holds up the others.
+const sharedFrameBuffer = 64
+
+// sharedPool holds the shared connections by host:port.
+type sharedPool struct {
+	mu    sync.Mutex
+	conns map[string]*sharedConn
+}
+
+var pool = &sharedPool{conns: make(map[string]*sharedConn)}
+
+// sharedConn is one websocket and the clients attached to it.
+type sharedConn struct {
+	key   string
+	conn  Conn
+	err   error         // dial error, set before ready closes
+	ready chan struct{} // closed once the dial finished
+
+	wmu    sync.Mutex // serializes writes
+	mu     sync.Mutex
+	taps   map[*sharedTap]struct{}
+	closed bool
+}
+
+// sharedTap is a client's view of a shared connection; it implements
+// Conn. Close detaches it.
+type sharedTap struct {
+	sc        *sharedConn
+	ns        string // namespace without surrounding slashes
+	svcPrefix string // see nextServiceID
+
+	frames chan sharedFrame
+	done   chan struct{}
+	once   sync.Once
+	err    error // why done was closed
+}
+
+type sharedFrame struct {
+	msgType int
+	data    []byte
+}
+
+// dialShared attaches c to the shared connection for its host:port.
+// Fault injection applies to the client's tap, not the shared socket.
+func (c *Client) dialShared() (Conn, error) {
+	key := net.JoinHostPort(c.host, strconv.Itoa(c.port))
+	t, err := pool.attach(key, c.dialWebsocket, c.ns, c.svcPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return NewChaosConn(t, &c.chaos), nil
+}
+
+// attach returns a tap on the connection for key, dialing it if needed.
+func (p *sharedPool) attach(key string, dial func() (Conn, error), ns, svcPrefix string) (*sharedTap, error) {
+	p.mu.Lock()
+	sc := p.conns[key]
+	if sc == nil {
+		sc = &sharedConn{key: key, ready: make(chan struct{}), taps: make(map[*sharedTap]struct{})}
+		p.conns[key] = sc
+		p.mu.Unlock()
+
+		sc.conn, sc.err = dial()
+		if sc.err != nil {
+			p.remove(sc)
+		} else {
+			go sc.readLoop()
+			log.Printf("[rosbridge] Shared connection to %s opened", key)
+		}
+		close(sc.ready)
+	} else {
+		p.mu.Unlock()
+	}
+
+	<-sc.ready
+	if sc.err != nil {
+		return nil, sc.err
+	}
+	t := &sharedTap{
+		sc:        sc,
+		ns:        strings.Trim(ns, "/"),
+		svcPrefix: svcPrefix,
+		frames:    make(chan sharedFrame, sharedFrameBuffer),
+		done:      make(chan struct{}),
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed {
+		return nil, ErrSharedClosed
+	}
+	sc.taps[t] = struct{}{}
+	return t, nil
+}
+
+// remove forgets sc so the next attach dials again.
+func (p *sharedPool) remove(sc *sharedConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[sc.key] == sc {
+		delete(p.conns, sc.key)
+	}
+}
+
+// users returns how many clients are attached to the connection for key.
+func (p *sharedPool) users(key string) int {
+	p.mu.Lock()
+	sc := p.conns[key]
+	p.mu.Unlock()
+	if sc == nil {
+		return 0
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return len(sc.taps)
+}
+
+func (sc *sharedConn) readLoop() {
+	for {
+		msgType, msg, err := sc.conn.ReadMessage()
+		if err != nil {
+			sc.fail(err)
+			return
+		}
+		if msgType == websocket.BinaryMessage {
+			v, err := DecodeCBOR(msg)
+			if err != nil {
+				log.Printf("[rosbridge] CBOR decode (shared %s): %v", sc.key, err)
+				continue
+			}
+			if msg, err = json.Marshal(v); err != nil {
+				continue
+			}
+			msgType = websocket.TextMessage
+		}
+		for _, t := range sc.route(msg) {
+			t.deliver(sharedFrame{msgType, msg})
+		}
+	}
+}
+
+// route returns the taps a frame is for.
+func (sc *sharedConn) route(msg []byte) []*sharedTap {
+	var envelope struct {
+		Op    string `json:"op"`
+		Topic string `json:"topic"`
+		ID    string `json:"id"`
+	}
+	if json.Unmarshal(msg, &envelope) != nil {
+		return nil
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	switch envelope.Op {
+	case "publish":
+		return sc.byTopicLocked(envelope.Topic)
+	case "service_response":
+		return sc.byServiceIDLocked(envelope.ID)
+	case "status":
+		switch {
+		case strings.HasPrefix(envelope.ID, "svc_"):
+			return sc.byServiceIDLocked(envelope.ID)
+		case strings.Count(envelope.ID, ":") >= 2:
+			// op:topic:seq, see nextOpID
+			return sc.byTopicLocked(envelope.ID[strings.Index(envelope.ID, ":")+1 : strings.LastIndex(envelope.ID, ":")])
+		}
+	}
+	return sc.allLocked()
+}
+
+func (sc *sharedConn) byTopicLocked(topic string) []*sharedTap {
+	topic = strings.TrimPrefix(topic, "/")
+	var out, global []*sharedTap
+	for t := range sc.taps {
+		switch {
+		case t.ns == "":
+			global = append(global, t)
+		case strings.HasPrefix(topic, t.ns+"/"):
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		return global
+	}
+	return out
+}
+
+func (sc *sharedConn) byServiceIDLocked(id string) []*sharedTap {
+	for t := range sc.taps {
+		if strings.HasPrefix(id, "svc_"+t.svcPrefix+"_") {
+			return []*sharedTap{t}
+		}
+	}
+	return nil
+}
+
+func (sc *sharedConn) allLocked() []*sharedTap {
+	out := make([]*sharedTap, 0, len(sc.taps))
+	for t := range sc.taps {
+		out = append(out, t)
+	}
+	return out
+}
+
+// fail closes the connection after an error and fails every tap.
+func (sc *sharedConn) fail(err error) {
+	sc.mu.Lock()
+	taps := sc.allLocked()
+	wasOpen := !sc.closed
+	sc.closed = true
+	sc.taps = make(map[*sharedTap]struct{})
+	sc.mu.Unlock()
+
+	pool.remove(sc)
+	sc.conn.Close()
+	if wasOpen {
+		log.Printf("[rosbridge] Shared connection to %s lost (%d clients): %v", sc.key, len(taps), err)
+	}
+	for _, t := range taps {
+		t.close(err)
+	}
+}
+
+// detach removes t; the last tap closes the connection.
+func (sc *sharedConn) detach(t *sharedTap) {
+	sc.mu.Lock()
+	delete(sc.taps, t)
+	last := len(sc.taps) == 0 && !sc.closed
+	if last {
+		sc.closed = true
+	}
+	sc.mu.Unlock()
+
+	if last {
+		pool.remove(sc)
+		sc.conn.Close()
+		log.Printf("[rosbridge] Shared connection to %s closed", sc.key)
+	}
+}
+
+// deliver queues a frame for the tap's reader, waiting while its buffer
+// is full unless the tap is closed.
+func (t *sharedTap) deliver(f sharedFrame) {
+	select {
+	case t.frames <- f:
+	case <-t.done:
+	}
+}
+
+func (t *sharedTap) close(err error) {
+	t.once.Do(func() {
+		t.err = err
+		close(t.done)
+	})
+}
+
+func (t *sharedTap) ReadMessage() (int, []byte, error) {
+	select {
+	case f := <-t.frames:
+		return f.msgType, f.data, nil
+	case <-t.done:
+		return 0, nil, t.err
+	}
+}
+
+func (t *sharedTap) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+	}
+	t.sc.wmu.Lock()
+	defer t.sc.wmu.Unlock()
+	return t.sc.conn.WriteMessage(messageType, data)
+}
+
+// Close detaches the tap from the shared connection.
+func (t *sharedTap) Close() error {
+	t.close(net.ErrClosed)
+	t.sc.detach(t)
+	return nil
+}
+
+// SetShared makes the client share one connection with the other shared
+// clients of its rosbridge server. Takes effect on the next Connect; a
+// shared client has no separate data plane.
+func (c *Client) SetShared(enabled bool) {
+	c.mu.Lock()
+	c.shared = enabled
+	c.mu.Unlock()
+}
+
+// SharedEnabled reports whether the client uses a shared connection.
+func (c *Client) SharedEnabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.shared
+}
+
+// SharedUsers returns how many clients use the shared connection to the
+// client's rosbridge server (0 when there is none).
+func (c *Client) SharedUsers() int {
+	return pool.users(net.JoinHostPort(c.host, strconv.Itoa(c.port)))
+}
@@ -0,0 +1,260 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sharedServer is a fake server whose connections the test can publish
+// on; it answers service calls like serveCalls.
+type sharedServer struct {
+	*fakeServer
+
+	mu      sync.Mutex
+	writers map[int]*websocket.Conn
+	closed  map[int]chan struct{}
+}
+
+func newSharedServer(t *testing.T) *sharedServer {
+	ss := &sharedServer{fakeServer: newFakeServer(t), writers: map[int]*websocket.Conn{}, closed: map[int]chan struct{}{}}
+	ss.serve = func(idx int, conn *websocket.Conn, s *fakeServer) {
+		done := make(chan struct{})
+		ss.mu.Lock()
+		ss.writers[idx] = conn
+		ss.closed[idx] = done
+		ss.mu.Unlock()
+		defer close(done)
+		for {
+			op, ok := s.read(idx, conn)
+			if !ok {
+				return
+			}
+			if op.Op == "call_service" {
+				ss.write(idx, map[string]interface{}{
+					"op": "service_response", "id": op.ID, "service": op.Service,
+					"values": map[string]string{"service": op.Service}, "result": true,
+				})
+			}
+		}
+	}
+	return ss
+}
+
+func (ss *sharedServer) write(idx int, v interface{}) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.writers[idx].WriteJSON(v)
+}
+
+func (ss *sharedServer) publish(idx int, topic string) {
+	ss.write(idx, map[string]interface{}{"op": "publish", "topic": topic, "msg": map[string]float64{"data": 1}})
+}
+
+// connClosed reports whether the server side of connection idx ended.
+func (ss *sharedServer) connClosed(idx int) bool {
+	ss.mu.Lock()
+	done := ss.closed[idx]
+	ss.mu.Unlock()
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ss *sharedServer) connections() int {
+	ss.fakeServer.mu.Lock()
+	defer ss.fakeServer.mu.Unlock()
+	return ss.conns
+}
+
+func sharedClient(t *testing.T, ss *sharedServer, ns string) *Client {
+	t.Helper()
+	c := ss.client(t, ns)
+	c.SetShared(true)
+	return c
+}
+
+func TestSharedPoolRefcount(t *testing.T) {
+	ss := newSharedServer(t)
+	a := sharedClient(t, ss, "/a")
+	b := sharedClient(t, ss, "/b")
+	key := net.JoinHostPort(a.host, strconv.Itoa(a.port))
+
+	if a.SharedUsers() != 0 {
+		t.Fatalf("users before connecting = %d", a.SharedUsers())
+	}
+	for _, c := range []*Client{a, b} {
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := ss.connections(); n != 1 {
+		t.Fatalf("%d websockets for two shared clients", n)
+	}
+	if a.SharedUsers() != 2 || pool.users(key) != 2 {
+		t.Fatalf("users = %d", a.SharedUsers())
+	}
+
+	// Removing one robot keeps the socket for the other
+	a.Disconnect()
+	if b.SharedUsers() != 1 {
+		t.Errorf("users after one detached = %d", b.SharedUsers())
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ss.connClosed(0) {
+		t.Fatal("socket closed while a client still uses it")
+	}
+	if _, err := b.CallService("/ping", nil, time.Second); err != nil {
+		t.Fatalf("remaining client: %v", err)
+	}
+
+	// Detaching twice must not drop the count for others
+	a.Disconnect()
+	if b.SharedUsers() != 1 {
+		t.Errorf("users after a repeated disconnect = %d", b.SharedUsers())
+	}
+
+	// The last one closes it
+	b.Disconnect()
+	waitFor(t, "socket close", func() bool { return ss.connClosed(0) })
+	if b.SharedUsers() != 0 {
+		t.Errorf("users after both detached = %d", b.SharedUsers())
+	}
+
+	// And the next attach dials again
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if n := ss.connections(); n != 2 {
+		t.Errorf("connections = %d, want a new one", n)
+	}
+	if a.SharedUsers() != 1 {
+		t.Errorf("users = %d", a.SharedUsers())
+	}
+}
+
+func TestSharedPoolRouting(t *testing.T) {
+	ss := newSharedServer(t)
+	a := sharedClient(t, ss, "/a")
+	b := sharedClient(t, ss, "/b")
+	g := sharedClient(t, ss, "") // no namespace
+	var gotA, gotB, gotG atomic.Int32
+	for _, c := range []*Client{a, b, g} {
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.SubscribeRaw("/battery", "std_msgs/msg/Float32", func(json.RawMessage) { gotA.Add(1) })
+	b.SubscribeRaw("/battery", "std_msgs/msg/Float32", func(json.RawMessage) { gotB.Add(1) })
+	g.SubscribeRaw("/battery", "std_msgs/msg/Float32", func(json.RawMessage) { gotG.Add(1) })
+	waitFor(t, "subscriptions", func() bool { return len(ss.received("subscribe")) == 3 })
+
+	ss.publish(0, "/a/battery")
+	ss.publish(0, "/a/battery")
+	ss.publish(0, "/b/battery")
+	ss.publish(0, "/battery")
+	ss.publish(0, "/ab/battery") // not under /a/
+	waitFor(t, "publishes", func() bool { return gotA.Load() == 2 && gotB.Load() == 1 && gotG.Load() == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if gotA.Load() != 2 || gotB.Load() != 1 || gotG.Load() != 1 {
+		t.Errorf("handlers ran a=%d b=%d global=%d, want 2 1 1", gotA.Load(), gotB.Load(), gotG.Load())
+	}
+
+	// Each client only saw its own topics
+	want := map[*Client][]string{a: {"/a/battery"}, b: {"/b/battery"}, g: {"/ab/battery", "/battery"}}
+	for c, topics := range want {
+		var got []string
+		for _, tb := range c.Bandwidth().Topics {
+			got = append(got, tb.Topic)
+		}
+		if len(got) != len(topics) {
+			t.Errorf("ns %q received %v, want %v", c.ns, got, topics)
+			continue
+		}
+		for i := range got {
+			if got[i] != topics[i] {
+				t.Errorf("ns %q received %v, want %v", c.ns, got, topics)
+			}
+		}
+	}
+
+	// Service responses go to the caller only
+	var wg sync.WaitGroup
+	for _, c := range []*Client{a, b, g} {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(c *Client) {
+				defer wg.Done()
+				raw, err := c.CallService("/which_name", nil, 2*time.Second)
+				if err != nil {
+					t.Errorf("ns %q: %v", c.ns, err)
+					return
+				}
+				if want := `"service":"` + c.ns + `/which_name"`; !strings.Contains(string(raw), want) {
+					t.Errorf("ns %q got %s", c.ns, raw)
+				}
+			}(c)
+		}
+	}
+	wg.Wait()
+
+	// A status for a subscribe names its topic and reaches its owner only
+	var subID string
+	for _, op := range ss.received("subscribe") {
+		if op.Topic == "/b/battery" {
+			subID = op.ID
+		}
+	}
+	ss.write(0, map[string]interface{}{"op": "status", "level": "error", "id": subID, "msg": "no such type"})
+	waitFor(t, "status", func() bool { return len(b.TopicHealth()) > 0 })
+	time.Sleep(20 * time.Millisecond)
+	if len(a.TopicHealth()) != 0 || len(g.TopicHealth()) != 0 {
+		t.Errorf("status reached other clients: a=%v g=%v", a.TopicHealth(), g.TopicHealth())
+	}
+}
+
+func TestSharedConnectionLossNotifiesAll(t *testing.T) {
+	ss := newSharedServer(t)
+	a := sharedClient(t, ss, "/a")
+	b := sharedClient(t, ss, "/b")
+	var downA, downB atomic.Int32
+	a.AddDisconnectHandler(func() { downA.Add(1) })
+	b.AddDisconnectHandler(func() { downB.Add(1) })
+	for _, c := range []*Client{a, b} {
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ss.mu.Lock()
+	ss.writers[0].Close()
+	ss.mu.Unlock()
+
+	waitFor(t, "both disconnected", func() bool { return downA.Load() == 1 && downB.Load() == 1 })
+	if a.IsConnected() || b.IsConnected() {
+		t.Error("client still connected after the shared socket dropped")
+	}
+	if a.SharedUsers() != 0 {
+		t.Errorf("users after loss = %d", a.SharedUsers())
+	}
+
+	// Both can come back over one new socket
+	for _, c := range []*Client{a, b} {
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := ss.connections(); n != 2 || a.SharedUsers() != 2 {
+		t.Errorf("after reconnect: %d connections, %d users", n, a.SharedUsers())
+	}
+}
@@ -0,0 +1,163 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ──────────────────────────── rosbridge status messages
+//
+// rosbridge reports failed operations (unknown message type, missing
+// service, ...) with op:"status" messages instead of failing the original
+// op. Subscribe and advertise ops carry an ID so status reports can be
+// traced back to the topic they concern.
+
+// StatusMessage is a parsed rosbridge op:"status" message.
+type StatusMessage struct {
+	Level   string    `json:"level"`
+	Msg     string    `json:"msg"`
+	ID      string    `json:"id,omitempty"`
+	ForOp   string    `json:"for_op,omitempty"`  // op reported on (subscribe, advertise), resolved from ID
+	Topic   string    `json:"topic,omitempty"`   // resolved from ID or text
+	Service string    `json:"service,omitempty"` // set for service call IDs
+	Time    time.Time `json:"time"`
+}
+
+// IsError reports whether the status is an error (rather than a warning
+// or info).
+func (s StatusMessage) IsError() bool {
+	return s.Level == "error"
+}
+
+// TopicHealth tracks status errors for one subscribed topic. Failing
+// means the last error came after the topic's last publish.
+type TopicHealth struct {
+	Topic       string    `json:"topic"`
+	Errors      int       `json:"errors"`
+	Warnings    int       `json:"warnings"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+	Failing     bool      `json:"failing"`
+}
+
+// Problem describes a failing topic for health reports, or "" if healthy.
+func (h TopicHealth) Problem() string {
+	if !h.Failing {
+		return ""
+	}
+	return fmt.Sprintf("subscription to %s failing: %s", h.Topic, h.LastError)
+}
+
+var opSeq atomic.Uint64
+
+// nextOpID returns a unique rosbridge op ID for op on topic and remembers
+// which topic it belongs to.
+func (c *Client) nextOpID(op, topic string) string {
+	id := fmt.Sprintf("%s:%s:%d", op, topic, opSeq.Add(1))
+	c.statusMu.Lock()
+	// Only the latest op per topic is tracked; resubscribes replace it.
+	for old, t := range c.opTopics {
+		if t == topic && strings.HasPrefix(old, op+":") {
+			delete(c.opTopics, old)
+		}
+	}
+	c.opTopics[id] = topic
+	c.statusMu.Unlock()
+	return id
+}
+
+func (c *Client) handleStatus(raw []byte) {
+	var st StatusMessage
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return
+	}
+	st.Time = time.Now()
+
+	c.statusMu.Lock()
+	if topic, ok := c.opTopics[st.ID]; ok {
+		st.Topic = topic
+		st.ForOp, _, _ = strings.Cut(st.ID, ":")
+	} else if st.ID == "" {
+		st.Topic = c.topicInText(st.Msg)
+	}
+	// Topics we only publish on never publish back, so a failed
+	// advertise is reported but not counted against the subscription.
+	if st.Topic != "" && st.ForOp != "advertise" {
+		h := c.topicHealth[st.Topic]
+		if h == nil {
+			h = &TopicHealth{Topic: st.Topic}
+			c.topicHealth[st.Topic] = h
+			c.topicAlive.Store(st.Topic, new(atomic.Int64))
+		}
+		switch st.Level {
+		case "error":
+			h.Errors++
+			h.LastError = st.Msg
+			h.LastErrorAt = st.Time
+		case "warning":
+			h.Warnings++
+		}
+	}
+	c.statusMu.Unlock()
+
+	// Service call IDs: fail the pending call right away instead of
+	// letting it run into its timeout.
+	if strings.HasPrefix(st.ID, "svc_") {
+		st.Service = serviceFromID(st.ID)
+		if st.IsError() {
+			c.deliverServiceReply(st.ID, svcReply{err: fmt.Errorf("rosbridge: %s", st.Msg)})
+		}
+	}
+
+	emit(c.OnStatus, &c.hooks.status, st)
+}
+
+// topicInText finds one of our subscribed topics mentioned in a status
+// text, for status messages that arrive without an ID. Caller holds
+// statusMu.
+func (c *Client) topicInText(msg string) string {
+	best := ""
+	for _, t := range c.opTopics {
+		if len(t) > len(best) && strings.Contains(msg, t) {
+			best = t
+		}
+	}
+	return best
+}
+
+// markTopicAlive records a publish on a topic that has reported status
+// errors, which clears its failing state. It runs for every publish, so
+// it only touches the topic's atomic timestamp.
+func (c *Client) markTopicAlive(topic string) {
+	if v, ok := c.topicAlive.Load(topic); ok {
+		v.(*atomic.Int64).Store(time.Now().UnixNano())
+	}
+}
+
+// lastAlive returns the unix ns of the topic's last publish seen since it
+// first reported an error, or 0.
+func (c *Client) lastAlive(topic string) int64 {
+	if v, ok := c.topicAlive.Load(topic); ok {
+		return v.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// TopicHealth returns status error counts for every topic that has
+// reported one, sorted by topic.
+func (c *Client) TopicHealth() []TopicHealth {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	out := make([]TopicHealth, 0, len(c.topicHealth))
+	for _, h := range c.topicHealth {
+		th := *h
+		th.Failing = h.Errors > 0 && h.LastErrorAt.UnixNano() > c.lastAlive(h.Topic)
+		out = append(out, th)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Topic < out[j].Topic })
+	return out
+}
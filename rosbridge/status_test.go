@@ -0,0 +1,117 @@
+package rosbridge
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// opID returns the ID of the latest op on topic.
+func opID(t *testing.T, c *Client, op, topic string) string {
+	t.Helper()
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	for id, tp := range c.opTopics {
+		if tp == topic && strings.HasPrefix(id, op+":") {
+			return id
+		}
+	}
+	t.Fatalf("no %s op for %s", op, topic)
+	return ""
+}
+
+func TestHandleStatus(t *testing.T) {
+	c := NewClient("/robot1", "127.0.0.1", 9)
+	defer c.Close()
+	c.SubscribeLaser("")
+	c.SubscribeOdom("")
+	c.PublishInitialPose(DefaultInitialPoseTopic, Pose2D{})
+
+	var got []StatusMessage
+	c.AddStatusHandler(func(st StatusMessage) { got = append(got, st) })
+
+	scanID := opID(t, c, "subscribe", "/robot1/scan")
+	poseID := opID(t, c, "advertise", "/robot1/initialpose")
+	svcID := c.nextServiceID("/robot1/which_tasks")
+
+	// Status payloads as rosbridge sends them (ROS 2 and ROS 1 servers)
+	samples := []string{
+		`{"op": "status", "level": "error", "msg": "Unable to import msg class LaserScn from package sensor_msgs. Caused by module 'sensor_msgs.msg' has no attribute 'LaserScn'", "id": "` + scanID + `"}`,
+		`{"op": "status", "level": "error", "msg": "advertise: Unable to load the manifest for package geometry_msg. Caused by: geometry_msg", "id": "` + poseID + `"}`,
+		`{"op": "status", "level": "error", "msg": "call_service InvalidServiceException: Service /robot1/which_tasks does not exist", "id": "` + svcID + `"}`,
+		`{"op": "status", "level": "warning", "msg": "Could not process inbound connection: topic types do not match: [nav_msgs/Odometry] vs. [geometry_msgs/Twist] for /robot1/odom"}`,
+		`{"op": "status", "level": "info", "msg": "Subscribed to /robot1/scan"}`,
+	}
+	for _, s := range samples {
+		c.handleStatus([]byte(s))
+	}
+
+	want := []struct{ level, op, topic, service string }{
+		{"error", "subscribe", "/robot1/scan", ""},
+		{"error", "advertise", "/robot1/initialpose", ""},
+		{"error", "", "", "/robot1/which_tasks"},
+		{"warning", "", "/robot1/odom", ""},
+		{"info", "", "/robot1/scan", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d status messages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		st := got[i]
+		if st.Level != w.level || st.ForOp != w.op || st.Topic != w.topic || st.Service != w.service {
+			t.Errorf("status %d = %+v, want %+v", i, st, w)
+		}
+	}
+
+	health := c.TopicHealth()
+	if len(health) != 2 {
+		t.Fatalf("health = %+v, want scan and odom only", health)
+	}
+	odom, scan := health[0], health[1]
+	if !scan.Failing || scan.Errors != 1 || !strings.Contains(scan.Problem(), "subscription to /robot1/scan failing: Unable to import") {
+		t.Errorf("scan = %+v (%q)", scan, scan.Problem())
+	}
+	if odom.Failing || odom.Warnings != 1 || odom.Problem() != "" {
+		t.Errorf("odom = %+v", odom)
+	}
+
+	// A publish clears the failure; the next error brings it back
+	c.markTopicAlive("/robot1/scan")
+	if h := c.TopicHealth()[1]; h.Failing {
+		t.Errorf("still failing after a publish: %+v", h)
+	}
+	c.handleStatus([]byte(samples[0]))
+	if h := c.TopicHealth()[1]; !h.Failing || h.Errors != 2 {
+		t.Errorf("after another error: %+v", h)
+	}
+}
+
+func TestMarkTopicAliveConcurrent(t *testing.T) {
+	c := NewClient("", "127.0.0.1", 9)
+	defer c.Close()
+	c.SubscribeLaser("")
+	id := opID(t, c, "subscribe", "/scan")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				c.markTopicAlive("/scan")
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c.handleStatus([]byte(fmt.Sprintf(`{"op":"status","level":"error","msg":"e%d","id":%q}`, i, id)))
+				c.TopicHealth()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if h := c.TopicHealth(); len(h) != 1 || h[0].Errors != 200 {
+		t.Errorf("health = %+v, want 200 errors", h)
+	}
+}
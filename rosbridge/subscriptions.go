@@ -0,0 +1,160 @@
+package rosbridge
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// ──────────────────────────── Subscription set
+//
+// The client remembers every subscription it has sent, with its type and
+// options, together with the connection that carried it (the data plane
+// when split). rosbridge keeps one subscription per subscribe op, so a
+// topic subscribed twice is delivered twice; SubscribeAllTopics runs on
+// connect and again when the data plane comes up, and both can reach the
+// same connection. Subscribing a tracked topic with the same type and
+// options therefore sends nothing, and with different ones unsubscribes
+// first. The set goes with its connection: once that is closed or
+// replaced the set is empty, and a reconnect subscribes each topic once.
+//
+// A subscribe op that can't be sent (the connection, or with split the
+// data plane, is down) is kept pending and sent again by the next
+// SubscribeAllTopics, which runs whenever a connection comes up; that way
+// topics outside the standard set, like map save progress, aren't lost.
+
+// subscription is a subscribe op rosbridge has accepted from the client.
+type subscription struct {
+	msgType string
+	opts    SubscribeOptions // without ID
+	since   time.Time
+}
+
+// pendingSub is a subscribe op waiting for a connection.
+type pendingSub struct {
+	msgType, key string
+}
+
+// Subscription is a topic subscribed on the client's current connection.
+type Subscription struct {
+	Topic        string    `json:"topic"`
+	Type         string    `json:"type"`
+	ThrottleRate int       `json:"throttle_rate,omitempty"`
+	QueueLength  int       `json:"queue_length,omitempty"`
+	Compression  string    `json:"compression,omitempty"`
+	Since        time.Time `json:"since"`
+}
+
+// subscribe sends a subscribe op tagged with an ID so rosbridge status
+// errors can be attributed to the topic, unless the topic is already
+// subscribed with the same type and options.
+func (c *Client) subscribe(topic, msgType, key string) {
+	if c.idleDrops(key) {
+		return
+	}
+	opts := c.subscribeOptions(key)
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	c.syncSubsLocked()
+	if s, ok := c.subs[topic]; ok {
+		if s.msgType == msgType && s.opts == opts {
+			return
+		}
+		delete(c.subs, topic)
+		c.sendData(UnsubscribeMsg(topic))
+	}
+	sent := opts
+	sent.ID = c.nextOpID("subscribe", topic)
+	if err := c.sendData(SubscribeMsg(topic, msgType, sent)); err != nil {
+		if _, ok := c.subsPending[topic]; !ok {
+			log.Printf("[rosbridge] Subscribe %s deferred until connected (ns=%s): %v", topic, c.ns, err)
+		}
+		if c.subsPending == nil {
+			c.subsPending = make(map[string]pendingSub)
+		}
+		c.subsPending[topic] = pendingSub{msgType: msgType, key: key}
+		return
+	}
+	delete(c.subsPending, topic)
+	c.subs[topic] = subscription{msgType: msgType, opts: opts, since: time.Now()}
+}
+
+// replayPendingSubs sends the subscribe ops that failed while the
+// connection was down.
+func (c *Client) replayPendingSubs() {
+	c.subsMu.Lock()
+	pending := c.subsPending
+	c.subsPending = nil
+	c.subsMu.Unlock()
+	for topic, p := range pending {
+		c.subscribe(topic, p.msgType, p.key)
+	}
+}
+
+// unsubscribe sends an unsubscribe op if the topic is subscribed, and
+// drops a pending subscribe for it. A failed unsubscribe needs no retry:
+// the connection it didn't reach is gone, and its subscriptions with it.
+func (c *Client) unsubscribe(topic string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	delete(c.subsPending, topic)
+	c.syncSubsLocked()
+	if _, ok := c.subs[topic]; !ok {
+		return
+	}
+	delete(c.subs, topic)
+	c.sendData(UnsubscribeMsg(topic))
+}
+
+// syncSubsLocked empties the set when the connection it was sent on is
+// no longer the one subscriptions go out on.
+func (c *Client) syncSubsLocked() {
+	conn := c.dataConnection()
+	if conn != c.subsConn || conn == nil {
+		c.subs = make(map[string]subscription)
+		c.subsConn = conn
+	}
+}
+
+// dataConnection returns the connection subscription ops go out on, nil
+// while it is down.
+func (c *Client) dataConnection() Conn {
+	c.mu.Lock()
+	split := c.split && !c.shared
+	conn, connected := c.conn, c.connected
+	c.mu.Unlock()
+	if !connected {
+		return nil
+	}
+	if !split {
+		return conn
+	}
+	c.dataMu.Lock()
+	defer c.dataMu.Unlock()
+	if !c.dataConnected {
+		return nil
+	}
+	return c.dataConn
+}
+
+// Subscriptions returns the topics subscribed on the current connection,
+// sorted by topic; none while disconnected.
+func (c *Client) Subscriptions() []Subscription {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	c.syncSubsLocked()
+	out := make([]Subscription, 0, len(c.subs))
+	for topic, s := range c.subs {
+		out = append(out, Subscription{
+			Topic:        topic,
+			Type:         s.msgType,
+			ThrottleRate: s.opts.ThrottleRate,
+			QueueLength:  s.opts.QueueLength,
+			Compression:  s.opts.Compression,
+			Since:        s.since,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Topic < out[j].Topic })
+	return out
+}
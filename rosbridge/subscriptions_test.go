@@ -0,0 +1,114 @@
+package rosbridge
+
+import (
+	"testing"
+	"time"
+)
+
+// subscribesPerConn counts the server's subscribe ops by connection and
+// topic, and unsubscribes likewise.
+func subscribesPerConn(s *fakeServer, op string) map[int]map[string]int {
+	out := map[int]map[string]int{}
+	for _, o := range s.received(op) {
+		if out[o.Conn] == nil {
+			out[o.Conn] = map[string]int{}
+		}
+		out[o.Conn][o.Topic]++
+	}
+	return out
+}
+
+// settle waits until the server has seen n ops of kind op, then a little
+// longer so a duplicate would have arrived too.
+func settle(t *testing.T, s *fakeServer, op string, n int) {
+	t.Helper()
+	waitFor(t, op+" ops", func() bool { return len(s.received(op)) >= n })
+	time.Sleep(50 * time.Millisecond)
+}
+
+// TestSubscribeOncePerConnection subscribes on connect, as the robot does,
+// and again redundantly, across a disconnect and reconnect: rosbridge
+// must see exactly one subscribe op per topic per connection.
+func TestSubscribeOncePerConnection(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.client(t, "/r1")
+	c.AddConnectHandler(c.SubscribeAllTopics)
+
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	c.SubscribeAllTopics()
+	c.SubscribeOdom("")
+	waitFor(t, "subscriptions", func() bool { return len(c.Subscriptions()) > 0 })
+	want := len(c.Subscriptions())
+	settle(t, s, "subscribe", want)
+
+	c.Disconnect()
+	if subs := c.Subscriptions(); len(subs) != 0 {
+		t.Errorf("subscriptions while disconnected: %+v", subs)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	c.SubscribeAllTopics()
+	settle(t, s, "subscribe", 2*want)
+
+	perConn := subscribesPerConn(s, "subscribe")
+	if len(perConn) != 2 {
+		t.Fatalf("subscribes on %d connections, want 2", len(perConn))
+	}
+	for conn, topics := range perConn {
+		if len(topics) != want {
+			t.Errorf("connection %d: %d topics subscribed, want %d", conn, len(topics), want)
+		}
+		for topic, n := range topics {
+			if n != 1 {
+				t.Errorf("connection %d: %s subscribed %d times", conn, topic, n)
+			}
+		}
+	}
+	if n := len(s.received("unsubscribe")); n != 0 {
+		t.Errorf("%d unsubscribes", n)
+	}
+
+	// What the client reports matches what the server saw last
+	for _, sub := range c.Subscriptions() {
+		if perConn[1][sub.Topic] != 1 {
+			t.Errorf("client lists %s, not subscribed on the connection", sub.Topic)
+		}
+	}
+}
+
+// TestSubscribeChangedOptions resubscribes a topic whose throttle rate
+// changed: one unsubscribe, then one subscribe with the new rate.
+func TestSubscribeChangedOptions(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.client(t, "/r1")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	c.SubscribeOdom("")
+	settle(t, s, "subscribe", 1)
+
+	c.SetThrottles(map[string]int{TopicOdom: 500})
+	c.SubscribeOdom("")
+	c.SubscribeOdom("")
+	settle(t, s, "subscribe", 2)
+
+	if got := subscribesPerConn(s, "subscribe")[0]["/r1/odom"]; got != 2 {
+		t.Errorf("odom subscribed %d times, want 2", got)
+	}
+	if got := subscribesPerConn(s, "unsubscribe")[0]["/r1/odom"]; got != 1 {
+		t.Errorf("odom unsubscribed %d times, want 1", got)
+	}
+	subs := c.Subscriptions()
+	if len(subs) != 1 || subs[0].Topic != "/r1/odom" || subs[0].ThrottleRate != 500 {
+		t.Errorf("subscriptions = %+v", subs)
+	}
+
+	c.UnsubscribeAll()
+	settle(t, s, "unsubscribe", 2)
+	if subs := c.Subscriptions(); len(subs) != 0 {
+		t.Errorf("subscriptions after UnsubscribeAll: %+v", subs)
+	}
+}
@@ -0,0 +1,104 @@
+package rosbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ──────────────────────────── Task catalog discovery
+//
+// Robots that support it answer a which_tasks request named "list_tasks"
+// with the tasks they know in response_settings: a JSON array of
+// {name, description, takes_settings} objects, a JSON array of names, or
+// one name per line or comma. Older firmware answers with an empty
+// payload or an error.
+
+// DefaultTaskDiscoveryRequest is the which_tasks task_name asking for the
+// catalog.
+const DefaultTaskDiscoveryRequest = "list_tasks"
+
+// ErrTaskDiscoveryUnsupported is returned when the robot answered the
+// discovery request without a task list.
+var ErrTaskDiscoveryUnsupported = errors.New("robot does not list its tasks")
+
+// TaskInfo describes one which_tasks task.
+type TaskInfo struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	TakesSettings bool   `json:"takes_settings"`
+}
+
+// ListTasks asks the robot for its task catalog using the discovery task
+// name request (DefaultTaskDiscoveryRequest if empty).
+func (c *Client) ListTasks(request string) ([]TaskInfo, error) {
+	if request == "" {
+		request = DefaultTaskDiscoveryRequest
+	}
+	resp, err := c.RequestTask(request, "")
+	if err != nil {
+		return nil, err
+	}
+	return ParseTaskList(resp.ResponseSettings)
+}
+
+// ParseTaskList decodes a discovery response payload.
+func ParseTaskList(payload string) ([]TaskInfo, error) {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		return nil, ErrTaskDiscoveryUnsupported
+	}
+
+	var tasks []TaskInfo
+	if strings.HasPrefix(payload, "[") || strings.HasPrefix(payload, "{") {
+		var wrapped struct {
+			Tasks json.RawMessage `json:"tasks"`
+		}
+		raw := []byte(payload)
+		if json.Unmarshal(raw, &wrapped) == nil && len(wrapped.Tasks) > 0 {
+			raw = wrapped.Tasks
+		}
+		var names []string
+		switch {
+		case json.Unmarshal(raw, &tasks) == nil:
+		case json.Unmarshal(raw, &names) == nil:
+			for _, n := range names {
+				tasks = append(tasks, TaskInfo{Name: n})
+			}
+		default:
+			return nil, ErrTaskDiscoveryUnsupported
+		}
+	} else {
+		for _, n := range strings.FieldsFunc(payload, func(r rune) bool { return r == '\n' || r == ',' }) {
+			tasks = append(tasks, TaskInfo{Name: n})
+		}
+	}
+
+	out := tasks[:0]
+	seen := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		t.Name = strings.TrimSpace(t.Name)
+		if t.Name == "" || seen[t.Name] {
+			continue
+		}
+		seen[t.Name] = true
+		out = append(out, t)
+	}
+	if len(out) == 0 {
+		return nil, ErrTaskDiscoveryUnsupported
+	}
+	return out, nil
+}
+
+// ParseTaskSpecs converts "name" or "name:description" entries (the
+// STATIC_TASKS form) to TaskInfo, skipping empty names.
+func ParseTaskSpecs(specs []string) []TaskInfo {
+	var out []TaskInfo
+	for _, spec := range specs {
+		name, desc, _ := strings.Cut(spec, ":")
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, TaskInfo{Name: name, Description: strings.TrimSpace(desc)})
+		}
+	}
+	return out
+}
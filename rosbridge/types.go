@@ -85,6 +85,7 @@ type MapData struct {
 	Resolution float64 `json:"resolution"`
 	OriginX    float64 `json:"origin_x"`
 	OriginY    float64 `json:"origin_y"`
+	OriginYaw  float64 `json:"origin_yaw"` // rotation of the grid's x axis in the map frame, radians
 	Data       []int8  `json:"data"`
 }
 
@@ -122,6 +123,10 @@ type OdomData struct {
 	LinearX      float64 `json:"linear_x"`
 	LinearY      float64 `json:"linear_y"`
 	AngularZ     float64 `json:"angular_z"`
+
+	// Variance is the pose covariance diagonal (see localization.go);
+	// absent when the message carries no 6×6 covariance.
+	Variance *PoseVariance `json:"variance,omitempty"`
 }
 
 func OdomFromMsg(o Odometry) OdomData {
@@ -139,6 +144,7 @@ func OdomFromMsg(o Odometry) OdomData {
 		LinearX:      o.Twist.Twist.Linear.X,
 		LinearY:      o.Twist.Twist.Linear.Y,
 		AngularZ:     o.Twist.Twist.Angular.Z,
+		Variance:     VarianceFromCovariance(o.Pose.Covariance),
 	}
 }
 
@@ -218,6 +224,18 @@ type NavigationPoint struct {
 	WorldXM       float64 `json:"world_x_m"`
 	WorldYM       float64 `json:"world_y_m"`
 	WorldThetaRad float64 `json:"world_theta_rad"`
+
+	// Optional approach parameters; zero means "robot default" and the
+	// field is not sent to the robot.
+	MaxSpeedMPS     float64 `json:"max_speed_mps,omitempty"`
+	DwellSec        float64 `json:"dwell_sec,omitempty"`
+	YawToleranceRad float64 `json:"yaw_tolerance_rad,omitempty"`
+	OnArrivalTask   string  `json:"on_arrival_task,omitempty"`
+}
+
+// HasApproach reports whether any approach parameter is set.
+func (p NavigationPoint) HasApproach() bool {
+	return p.MaxSpeedMPS != 0 || p.DwellSec != 0 || p.YawToleranceRad != 0 || p.OnArrivalTask != ""
 }
 
 type WallObstacle struct {
@@ -231,6 +249,46 @@ type WallObstacle struct {
 	WorldYMEnd    float64 `json:"world_y_m_end"`
 }
 
+// ──────────────────────────── Navigation goal status
+
+// Goal states from action_msgs/msg/GoalStatus.
+const (
+	GoalUnknown   = 0
+	GoalAccepted  = 1
+	GoalExecuting = 2
+	GoalCanceling = 3
+	GoalSucceeded = 4
+	GoalCanceled  = 5
+	GoalAborted   = 6
+)
+
+var goalStateNames = map[int]string{
+	GoalUnknown:   "unknown",
+	GoalAccepted:  "accepted",
+	GoalExecuting: "executing",
+	GoalCanceling: "canceling",
+	GoalSucceeded: "succeeded",
+	GoalCanceled:  "canceled",
+	GoalAborted:   "aborted",
+}
+
+// NavStatus is the state of the most recent navigation goal.
+type NavStatus struct {
+	GoalID string `json:"goal_id"`
+	Status int    `json:"status"`
+	State  string `json:"state"`
+}
+
+// Active reports whether the goal is still running.
+func (s NavStatus) Active() bool {
+	return s.Status == GoalAccepted || s.Status == GoalExecuting || s.Status == GoalCanceling
+}
+
+// Terminal reports whether the goal has finished.
+func (s NavStatus) Terminal() bool {
+	return s.Status == GoalSucceeded || s.Status == GoalCanceled || s.Status == GoalAborted
+}
+
 // ──────────────────────────── Service response types
 
 type WhichMapsResponse struct {
@@ -242,6 +300,15 @@ type HandshakeResponse struct {
 	RobotNamespace string  `json:"robot_namespace"`
 	Status         int     `json:"status"`
 	RobotDiameter  float64 `json:"robot_diameter"`
+
+	// RobotFootprint is the outline as [[x, y], ...] in the base frame;
+	// not sent by current robots.
+	RobotFootprint [][2]float64 `json:"robot_footprint,omitempty"`
+
+	// SoftwareVersion and Capabilities are reported by newer firmware
+	// (see capabilities.go); Capabilities is nil when not sent.
+	SoftwareVersion string   `json:"software_version,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
 }
 
 type WhichTaskResponse struct {
@@ -249,3 +316,13 @@ type WhichTaskResponse struct {
 	Status           int    `json:"status"`
 	ResponseSettings string `json:"response_settings"`
 }
+
+// TaskAccepted is the which_tasks status of a task the robot accepted;
+// any other status is a refusal, explained in response_settings. Robots
+// that leave status out report it as accepted.
+const TaskAccepted = 0
+
+// Refused reports whether the robot refused the task.
+func (r *WhichTaskResponse) Refused() bool {
+	return r != nil && r.Status != TaskAccepted
+}
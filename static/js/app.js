@@ -4,6 +4,8 @@
 const App = (() => {
     let currentMode = 'navigation';
     let keysDown = {};
+    let holonomic = false; // current robot strafes (q/e keys)
+    let btTemplate = '';   // chosen behavior tree template ('' = robot default)
 
     function init() {
         MapCanvas.init();
@@ -14,6 +16,10 @@ const App = (() => {
         WS.connect();
 
         // Register WebSocket handlers
+        WS.on('map_meta', (msg) => {
+            MapCanvas.updateMapMeta(msg.data);
+        });
+
         WS.on('map', (msg) => {
             MapCanvas.updateMap(msg.data);
         });
@@ -51,7 +57,40 @@ const App = (() => {
         });
 
         WS.on('status', (msg) => {
+            holonomic = !!(msg.data && msg.data.holonomic);
+            showRatios(msg.data);
             updateStatusBadge(msg.data);
+            MapCanvas.setRobotConfig(msg.data);
+        });
+
+        WS.on('robot_config', (msg) => MapCanvas.setRobotConfig(msg.data));
+
+        // Uploads, queued ones included, update the collections' sync marks
+        WS.on('nav_send', () => refreshNavPoints());
+
+        WS.on('pending_destructive_action', (msg) => {
+            const p = msg.data;
+            const what = p.target ? `${p.action} ${p.target}` : p.action;
+            const dialog = document.querySelector(
+                `#dialog-overlay [data-confirm-robot="${p.robot_id}"][data-confirm-action="${p.action}"]`);
+            // The requesting tab has its dialog open (or opening)
+            const open = !document.getElementById('dialog-overlay').classList.contains('hidden');
+            if (p.state === 'pending' && !open) {
+                Notify.warn(`Robot ${p.robot_id}: ${what} requested, awaiting confirmation (${Math.round(p.countdown_ms / 1000)} s)`);
+            } else if (p.state === 'expired' && dialog) {
+                hideDialog();
+                Notify.warn(`Robot ${p.robot_id}: ${what} not confirmed in time`);
+            }
+        });
+
+        WS.on('settings_changed', (msg) => showRatios(msg.data));
+        WS.on('ratio_rejected', (msg) => Notify.warn(`Speed not changed: ${msg.data.reason}`));
+
+        WS.on('home', (msg) => {
+            const ev = msg.data.event;
+            if (ev === 'started') Notify.info('Going home');
+            else if (ev === 'succeeded') Notify.success('Arrived home');
+            else Notify.warn(`Go home ${ev}`);
         });
 
         WS.on('robot_added', () => {
@@ -73,19 +112,251 @@ const App = (() => {
             updateConnBadge(true);
         });
 
+        // A robot powering off or rebooting on request drops as planned
         WS.on('robot_disconnected', (msg) => {
-            Notify.warn(`Robot ${msg.robot_id} disconnected`);
+            const state = msg.data?.state;
+            if (state === 'shutting_down' || state === 'rebooting') Notify.info(`Robot ${msg.robot_id} disconnected (${state.replace('_', ' ')})`);
+            else Notify.warn(`Robot ${msg.robot_id} disconnected`);
             refreshRobotList();
             updateConnBadge(false);
+            setStale(true);
+        });
+
+        // Connection progress of an added robot; failures also arrive as
+        // a toast
+        WS.on('connect_phase', () => refreshRobotList());
+
+        // Power transitions arrive as toasts from the server; the list
+        // badges the state
+        WS.on('power_state', () => refreshRobotList());
+
+        WS.on('localization_quality', (msg) => {
+            const l = msg.data || {};
+            if (l.quality === 'poor') Notify.warn(`Robot ${msg.robot_id} localization is poor — it may be lost`);
+            else if (l.previous === 'poor') Notify.info(`Robot ${msg.robot_id} localization recovered (${l.quality})`);
+        });
+
+        // Idle robots are badged in the list
+        WS.on('robot_activity', () => refreshRobotList());
+
+        WS.on('move_progress', (msg) => {
+            const p = msg.data || {};
+            const bar = document.getElementById('move-progress');
+            if (bar) {
+                bar.classList.toggle('hidden', p.state !== 'running');
+                bar.value = Math.round((p.progress || 0) * 100);
+            }
+            if (p.state === 'done') Notify.success('Move complete');
+            else if (p.state === 'aborted') Notify.warn(`Move aborted: ${p.reason}`);
+        });
+
+        // Relocalization rotations share the move progress bar; the
+        // outcome arrives as a toast from the server.
+        WS.on('relocalize', (msg) => {
+            const e = msg.data || {};
+            const bar = document.getElementById('move-progress');
+            if (bar) {
+                bar.classList.toggle('hidden', e.state !== 'rotating');
+                bar.value = Math.round((e.progress || 0) * 100);
+            }
+        });
+
+        // Point visits are rare enough to redraw the panel's last visits
+        WS.on('point_visited', (msg) => {
+            const v = msg.data || {};
+            Notify.info(`Robot ${msg.robot_id} reached ${v.type} "${v.name}"`);
+            refreshNavPoints();
+        });
+
+        WS.on('patrol', (msg) => {
+            const e = msg.data || {};
+            const p = e.patrol || {};
+            if (e.event === 'lap_completed') Notify.info(`Patrol lap ${p.laps_completed} complete`);
+            else if (e.event === 'finished' && p.state === 'aborted') Notify.warn(`Patrol aborted: ${p.reason}`);
+            else if (e.event === 'finished') Notify.success(`Patrol ${p.state} after ${p.laps_completed} laps`);
+        });
+
+        WS.on('mapping_session', (msg) => {
+            const s = msg.data || {};
+            if (s.state === 'finished') Notify.success(`Map "${s.map_name}" saved (${(s.stats?.area_m2 || 0).toFixed(1)} m²)`);
+            else if (s.state === 'failed') Notify.error(`Mapping failed: ${s.error}`);
+            else if (s.state === 'aborted') Notify.info('Mapping aborted');
+        });
+
+        // Sent every 5 s while mapping; the stabilization notice arrives
+        // as an info toast
+        WS.on('mapping_progress', (msg) => {
+            const p = msg.data || {};
+            const badge = document.getElementById('mapping-progress');
+            if (!badge) return;
+            badge.classList.toggle('hidden', !p.active);
+            const growth = `+${(p.growth_m2_per_min || 0).toFixed(1)} m²/min`;
+            badge.textContent = `Mapped ${(p.area_m2 || 0).toFixed(0)} m² · ${p.stabilized ? 'stable' : growth}`;
+            badge.title = `${p.free_cells} free, ${p.occupied_cells} occupied cells; ` +
+                `${(p.bounds_width_m || 0).toFixed(1)} × ${(p.bounds_height_m || 0).toFixed(1)} m`;
+        });
+
+        WS.on('map_save', (msg) => {
+            const op = msg.data || {};
+            const badge = document.getElementById('map-save-status');
+            if (badge) {
+                badge.classList.toggle('hidden', op.state !== 'saving');
+                const pct = op.percent != null ? `${Math.round(op.percent)}% · ` : '';
+                badge.textContent = `Saving "${op.map_name}" ${pct}${Math.round(op.elapsed_sec || 0)} s`;
+            }
+            // Failures arrive as an error toast
+            if (op.state === 'saved') Notify.success(`Map "${op.map_name}" saved`);
+        });
+
+        WS.on('clock_skew', (msg) => {
+            const ev = msg.data || {};
+            const text = `Robot ${msg.robot_id}: ${ev.msg}`;
+            if (ev.reason === 'recovered') Notify.info(text);
+            else Notify.warn(text);
+        });
+
+        // Joystick input is refused while the robot drives itself; offer
+        // to take over (cancels navigation) at most every few seconds.
+        let lastRejectPrompt = 0;
+        WS.on('joystick_rejected', (msg) => {
+            const now = Date.now();
+            if (now - lastRejectPrompt < 5000) return;
+            lastRejectPrompt = now;
+            const driver = msg.data?.driver;
+            if (driver) {
+                if (confirm(`Robot is being driven by ${driver.driver.label}. Take over?`)) WS.requestTakeover();
+                else Notify.warn(`Joystick ignored: ${driver.driver.label} is driving`);
+                lastRejectPrompt = Date.now();
+                return;
+            }
+            const a = msg.data?.autonomy || {};
+            const why = a.patrolling ? 'patrolling' : a.navigating ? 'navigating' : 'autonomy-locked';
+            if (confirm(`Joystick ignored: robot is ${why}. Take over manually? This cancels navigation.`)) {
+                WS.send({ type: 'take_over', robot_id: msg.robot_id });
+                WS.setOverride(true);
+            } else {
+                Notify.warn(`Joystick ignored: robot is ${why}`);
+            }
+            lastRejectPrompt = Date.now();
+        });
+
+        WS.on('autonomy', (msg) => {
+            const a = msg.data || {};
+            if (!a.taken_over) WS.setOverride(false);
+            if (a.locked && a.manual_lock) Notify.info(`Robot ${msg.robot_id} autonomy-locked: joystick disabled`);
+        });
+
+        // Someone is driving with the joystick: show who and what, and
+        // tell a driver whose robot was taken over.
+        WS.on('manual_control', (msg) => {
+            const c = msg.data || {};
+            const me = WS.getServerHello()?.client_id;
+            const badge = document.getElementById('manual-control-status');
+            if (badge) {
+                badge.classList.toggle('hidden', !c.active || c.driver.id === me);
+                const strafe = c.linear_y ? ` · ${c.linear_y.toFixed(2)} m/s ↔` : '';
+                badge.textContent = `🕹 ${c.driver.label} · ${c.linear_x.toFixed(2)} m/s${strafe} · ${c.angular_z.toFixed(2)} rad/s`;
+            }
+            if (c.taken_from && c.taken_from.id === me) Notify.warn(`${c.driver.label} took over robot ${msg.robot_id}`);
+        });
+
+        // Control lease: who may drive. The holder is asked to answer
+        // requests; everyone sees the holder in the badge.
+        WS.on('control_lease', (msg) => {
+            const ev = msg.data || {};
+            const lease = ev.lease || {};
+            const me = WS.getServerHello()?.client_id;
+            const badge = document.getElementById('control-lease-status');
+            if (badge) {
+                badge.classList.toggle('hidden', !lease.holder);
+                badge.textContent = lease.holder ? `🎮 ${lease.holder.id === me ? 'You' : lease.holder.label} in control` : '';
+            }
+            const btn = document.getElementById('control-lease-btn');
+            if (btn) btn.textContent = lease.holder?.id === me ? '🎮 Release' : '🎮 Control';
+
+            if (ev.event === 'requested' && lease.holder?.id === me) {
+                const type = confirm(`${ev.driver.label} asks to drive robot ${msg.robot_id}. Grant control?`) ? 'grant_control' : 'deny_control';
+                WS.send({ type, robot_id: msg.robot_id, data: { client_id: ev.driver.id } });
+            } else if (ev.event === 'granted' && ev.driver.id === me) {
+                Notify.success(`You have control of robot ${msg.robot_id}`);
+            } else if (ev.event === 'denied' && ev.driver.id === me) {
+                Notify.warn(`Control of robot ${msg.robot_id} was denied`);
+            } else if (ev.event === 'released' && ev.driver.id === me && ev.reason !== 'released') {
+                Notify.warn(`Control of robot ${msg.robot_id} ended (${ev.reason})`);
+            }
+        });
+
+        WS.on('control_rejected', (msg) => Notify.warn(msg.data?.reason || 'Control refused'));
+
+        WS.on('fleet_proximity', (msg) => {
+            const p = msg.data || {};
+            const text = `Robots ${p.robot_a} and ${p.robot_b} are ${p.distance_m.toFixed(2)} m apart`;
+            if (p.state === 'critical') Notify.error(text);
+            else if (p.state === 'warning') Notify.warn(text);
+            else Notify.info(`Robots ${p.robot_a} and ${p.robot_b} clear of each other`);
+        });
+
+        WS.on('estop', (msg) => {
+            if (msg.data?.engaged) Notify.error(`E-stop engaged on robot ${msg.robot_id}`);
+            else Notify.info(`E-stop released on robot ${msg.robot_id}`);
         });
 
-        WS.on('robot_switched', () => {
+        // The robot's map changed (open or floor switch): its points did too
+        WS.on('floor', () => refreshNavPoints());
+
+        WS.on('robot_discovered', (msg) => {
+            const c = msg.data || {};
+            Notify.info(`New robot found: ${c.name} (${c.ip}:${c.port})`);
+        });
+
+        WS.on('rosbridge_status', (msg) => {
+            const st = msg.data || {};
+            const subject = st.topic || st.service || `robot ${msg.robot_id}`;
+            const text = `${subject}: ${st.msg}`;
+            if (st.level === 'error') Notify.error(text);
+            else Notify.warn(text);
+        });
+
+        // Failures of background operations; the hello replays the last
+        // minute's, so skip any already shown
+        const seenToasts = new Set();
+        function showToast(n) {
+            if (!n || seenToasts.has(n.id)) return;
+            seenToasts.add(n.id);
+            const text = n.robot_id ? `Robot ${n.robot_id}: ${n.message}` : n.message;
+            if (n.level === 'error') Notify.error(text);
+            else if (n.level === 'warn') Notify.warn(text);
+            else Notify.info(text);
+        }
+
+        WS.on('toast', (msg) => showToast(msg.data));
+
+        WS.on('hello', (msg) => (msg.data?.recent_errors || []).forEach(showToast));
+
+        // Also sent when removing the current robot picked another one;
+        // an empty robot_id means none are left.
+        WS.on('robot_switched', (msg) => {
             refreshRobotList();
-            WS.send({ type: 'request_map' });
-            WS.send({ type: 'request_status' });
+            updateRobotCount();
+            if (msg.robot_id) {
+                WS.send({ type: 'request_map' });
+                WS.send({ type: 'request_status' });
+            } else {
+                MapCanvas.clear();
+            }
+            htmx.ajax('GET', '/partial/settings', { target: '#settings-content', swap: 'innerHTML' });
             refreshNavPoints();
         });
 
+        // The points panel is re-rendered on every change; keep the
+        // chosen behavior tree template
+        document.body.addEventListener('htmx:afterSwap', () => {
+            const sel = document.getElementById('bt-template');
+            if (!sel) return;
+            if ([...sel.options].some(o => o.value === btTemplate)) sel.value = btTemplate;
+            sel.onchange = () => { btTemplate = sel.value; };
+        });
+
         // Keyboard shortcuts
         document.addEventListener('keydown', onKeyDown);
         document.addEventListener('keyup', onKeyUp);
@@ -117,6 +388,7 @@ const App = (() => {
     function updateStatusBadge(data) {
         if (!data) return;
         updateConnBadge(data.connected);
+        setStale(!!data.data_stale);
         const freq = document.getElementById('freq-badge');
         if (freq && data.tf_hz !== undefined) {
             const hz = data.tf_hz || data.odom_hz || 0;
@@ -124,6 +396,13 @@ const App = (() => {
         }
     }
 
+    // Greys out the pose and speed overlay while they are last known
+    // values rather than live ones.
+    function setStale(stale) {
+        const overlay = document.getElementById('info-overlay');
+        if (overlay) overlay.classList.toggle('stale', stale);
+    }
+
     function updateConnBadge(connected) {
         const badge = document.getElementById('conn-badge');
         if (badge) {
@@ -220,6 +499,217 @@ const App = (() => {
         htmx.ajax('GET', '/partial/nav_points', { target: '#nav-points-content', swap: 'innerHTML' });
     }
 
+    // ──────────── Teach by driving ────────────
+
+    function addPointHere(type) {
+        const name = document.getElementById('pt-name')?.value.trim();
+        if (!name) {
+            Notify.warn('Enter a point name first');
+            return;
+        }
+        const body = new URLSearchParams({ type, name });
+        for (const [field, id] of [['max_speed_mps', 'pt-speed'], ['dwell_sec', 'pt-dwell'],
+                                   ['yaw_tolerance_rad', 'pt-yaw-tol'], ['on_arrival_task', 'pt-task']]) {
+            const v = document.getElementById(id)?.value.trim();
+            if (v) body.append(field, v);
+        }
+        fetch('/api/nav/add_here', { method: 'POST', body })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) {
+                Notify.error(data.error);
+                return;
+            }
+            Notify.success(`${name} added at (${data.x.toFixed(2)}, ${data.y.toFixed(2)})`);
+            hideDialog();
+            refreshNavPoints();
+        });
+    }
+
+    // ──────────── Floors ────────────
+
+    // Opens the floor's map on the robot; its points replace the panel.
+    function switchFloor(floor) {
+        if (!floor) return;
+        fetch('/api/robots/floor', { method: 'POST', body: new URLSearchParams({ floor }) })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) {
+                Notify.error(data.error);
+            } else {
+                Notify.success(`Floor ${data.active_floor}: map ${data.current_map}`);
+                WS.send({ type: 'request_map' });
+            }
+            refreshNavPoints();
+        });
+    }
+
+    // Resumes a robot whose reconnect policy gave up.
+    function connectRobot(id) {
+        fetch(`/api/robots/connect?id=${encodeURIComponent(id)}`, { method: 'POST' })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) Notify.error(`Connect failed: ${data.error}`);
+        });
+    }
+
+    // ──────────── Send points ────────────
+
+    // Uploads a collection and reports what the robot kept.
+    function sendPoints(type) {
+        const body = new URLSearchParams({ type });
+        if (btTemplate && type !== 'wall') body.set('bt_template', btTemplate);
+        fetch('/api/nav/send', { method: 'POST', body })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) {
+                Notify.error(`Send failed: ${data.error}`);
+            } else if (data.status === 'partial') {
+                const names = data.rejected.map(p => p.reason ? `${p.name} (${p.reason})` : p.name).join(', ');
+                Notify.warn(`Robot kept ${data.accepted} of ${data.sent} points; rejected ${names}`);
+            } else if (data.status === 'queued') {
+                Notify.info('Robot disconnected; the points will be sent once it reconnects');
+            } else if (data.status === 'unverified') {
+                Notify.info(`Sent ${data.sent} points (robot did not confirm)`);
+            } else {
+                Notify.success(`Robot kept all ${data.accepted} points`);
+            }
+        });
+    }
+
+    // Shows the behavior tree the robot would build from a collection
+    // in the read-only panel below the points.
+    function previewBT(type) {
+        const params = new URLSearchParams({ type });
+        if (btTemplate) params.set('bt_template', btTemplate);
+        fetch('/api/nav/bt_preview?' + params)
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) return Notify.error(`Behavior tree preview: ${data.error}`);
+            const panel = document.getElementById('bt-preview-panel');
+            if (!panel) return;
+            panel.classList.remove('hidden');
+            panel.open = true;
+            document.getElementById('bt-preview').textContent = data.content;
+            const cut = data.truncated ? ` · truncated, ${Math.round(data.bytes / 1024)} KiB in full` : '';
+            setEl('bt-preview-info', `${type} · ${data.template || 'default'} · ${data.format}${cut}`);
+        });
+    }
+
+    // ──────────── Home ────────────
+
+    function setHomeHere() {
+        fetch('/api/robots/home', { method: 'POST', body: new URLSearchParams({ here: '1' }) })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) Notify.error(`Set home failed: ${data.error}`);
+            else Notify.success(`Home set on ${data.home.map}`);
+        });
+    }
+
+    function goHome() {
+        fetch('/api/robots/go_home', { method: 'POST' })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) Notify.error(`Go home failed: ${data.error}`);
+        });
+    }
+
+    // Flags the current moment for later review; the toast comes back
+    // through the notice broadcast.
+    function markIncident() {
+        const label = prompt('Mark incident — what happened? (optional)', '');
+        if (label === null) return;
+        fetch('/api/robots/mark', { method: 'POST', body: new URLSearchParams({ label }) })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) Notify.error(`Marking incident failed: ${data.error}`);
+        });
+    }
+
+    // ──────────── Go all ────────────
+
+    // Runs a collection; when the server refuses because the robot is far
+    // from the first point (or its pose is stale), offer to force it.
+    function goAll(type, force) {
+        const body = new URLSearchParams({ type });
+        if (force) body.append('force', 'true');
+        fetch('/api/nav/go', { method: 'POST', body })
+        .then(r => r.json())
+        .then(data => {
+            if (!data.error) {
+                Notify.success('Go all sent');
+                return;
+            }
+            if (data.forceable && !force && confirm(`${data.error}\n\nGo anyway?`)) {
+                goAll(type, true);
+                return;
+            }
+            Notify.error(data.error);
+        });
+    }
+
+    // ──────────── Discovery ────────────
+
+    function discoverRobots(refresh, register) {
+        const results = document.getElementById('discover-results');
+        const subnets = document.getElementById('discover-subnets')?.value.trim() || '';
+        if (results) results.textContent = 'Scanning…';
+
+        let body = `refresh=${refresh ? 1 : 0}&register=${register ? 1 : 0}`;
+        if (subnets) body += `&subnets=${encodeURIComponent(subnets)}`;
+
+        fetch('/api/robots/discover', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+            body
+        })
+        .then(r => r.json())
+        .then(data => {
+            if (data.error) {
+                if (results) results.textContent = '';
+                Notify.error(data.error);
+                return;
+            }
+            const seen = new Set();
+            const cands = [...(data.scan?.candidates || []), ...(data.mdns || [])]
+                .filter(c => !seen.has(`${c.ip}:${c.port}`) && seen.add(`${c.ip}:${c.port}`));
+            renderCandidates(cands);
+            if (data.registered?.length) {
+                Notify.success(`Registered ${data.registered.length} robot(s)`);
+                refreshRobotList();
+                updateRobotCount();
+            }
+        })
+        .catch(() => Notify.error('Discovery failed'));
+    }
+
+    function renderCandidates(cands) {
+        const results = document.getElementById('discover-results');
+        if (!results) return;
+        results.innerHTML = '';
+        if (!cands.length) {
+            results.textContent = 'No robots found';
+            return;
+        }
+        for (const c of cands) {
+            const item = document.createElement('div');
+            item.className = 'map-item';
+            const label = c.namespace ? `${c.name} (/${c.namespace})` : c.name;
+            item.textContent = `${label} — ${c.ip}:${c.port}${c.registered ? ' ✓' : ''}`;
+            if (!c.registered) item.onclick = () => useCandidate(c);
+            results.appendChild(item);
+        }
+    }
+
+    function useCandidate(c) {
+        const set = (id, v) => { const el = document.getElementById(id); if (el && v) el.value = v; };
+        set('ns', c.namespace);
+        set('rname', c.name);
+        set('rip', c.ip);
+        set('rport', c.port);
+    }
+
     // ──────────── Map actions ────────────
 
     function openMap(name) {
@@ -256,18 +746,77 @@ const App = (() => {
         const ar = document.getElementById('setting-angular-ratio')?.value || '1.0';
         const radius = document.getElementById('setting-radius')?.value || '0.30';
 
+        let body = `linear_vel_ratio=${lr}&angular_vel_ratio=${ar}&radius=${radius}`;
+        for (const topic of ['map', 'laser', 'odom']) {
+            const el = document.getElementById(`setting-throttle-${topic}`);
+            if (el && el.value !== '') body += `&throttle_${topic}=${el.value}`;
+        }
+        const footprint = document.getElementById('setting-footprint');
+        if (footprint) body += `&footprint=${encodeURIComponent(footprint.value.trim() || '[]')}`;
+        const scanMask = document.getElementById('setting-scan-mask');
+        if (scanMask) body += `&scan_mask=${encodeURIComponent(scanMask.value.trim() || '[]')}`;
+        const extraTopics = document.getElementById('setting-extra-topics');
+        if (extraTopics) body += `&extra_topics=${encodeURIComponent(extraTopics.value.trim() || '[]')}`;
+        const cbor = document.getElementById('setting-cbor');
+        if (cbor) body += `&cbor=${cbor.checked ? 1 : 0}`;
+        const split = document.getElementById('setting-split');
+        if (split) body += `&split_connections=${split.checked ? 1 : 0}`;
+        const shared = document.getElementById('setting-shared');
+        if (shared) body += `&shared_connection=${shared.checked ? 1 : 0}`;
+        const holonomicSetting = document.getElementById('setting-holonomic');
+        if (holonomicSetting) body += `&holonomic=${holonomicSetting.checked ? 1 : 0}`;
+        const invert = document.getElementById('setting-invert');
+        if (invert) {
+            body += `&occupied_threshold=${document.getElementById('setting-occupied-threshold').value}`;
+            body += `&free_threshold=${document.getElementById('setting-free-threshold').value}`;
+            body += `&invert=${invert.checked ? 1 : 0}`;
+            body += `&palette=${encodeURIComponent(document.getElementById('setting-palette').value)}`;
+        }
+        const reconnect = document.getElementById('setting-reconnect-enabled');
+        if (reconnect) {
+            body += `&reconnect_enabled=${reconnect.checked ? 1 : 0}`;
+            body += `&reconnect_initial_delay_ms=${document.getElementById('setting-reconnect-initial').value}`;
+            body += `&reconnect_max_delay_ms=${document.getElementById('setting-reconnect-max').value}`;
+            body += `&reconnect_max_attempts=${document.getElementById('setting-reconnect-attempts').value}`;
+        }
+        const cmdVelRate = document.getElementById('setting-cmdvel-rate');
+        if (cmdVelRate) {
+            body += `&cmdvel_rate_hz=${cmdVelRate.value}`;
+            body += `&cmdvel_change_only=${document.getElementById('setting-cmdvel-change-only').checked ? 1 : 0}`;
+            body += `&cmdvel_keep_alive=${document.getElementById('setting-cmdvel-keep-alive').checked ? 1 : 0}`;
+        }
+        const offline = document.getElementById('setting-offline-queue');
+        if (offline) {
+            body += `&offline_queue=${offline.checked ? 1 : 0}`;
+            body += `&offline_queue_max=${document.getElementById('setting-offline-queue-max').value}`;
+            body += `&offline_queue_ttl_s=${document.getElementById('setting-offline-queue-ttl').value}`;
+        }
+        const unique = document.getElementById('setting-unique-names');
+        if (unique) body += `&enforce_global_unique_names=${unique.checked ? 1 : 0}`;
+
         fetch('/api/robots/settings', {
             method: 'POST',
             headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
-            body: `linear_vel_ratio=${lr}&angular_vel_ratio=${ar}&radius=${radius}`
+            body
         })
         .then(r => r.json())
         .then(data => {
-            if (data.error) Notify.error(data.error);
+            if (data.conflicts) {
+                Notify.error(`${data.error}: ${data.conflicts.map(c => c.name).join(', ')}`);
+            } else if (data.error) Notify.error(data.error);
+            else if (data.pending) Notify.info('Settings saved; sending them to the robot once it reconnects');
             else Notify.success('Settings saved');
         });
     }
 
+    // Display units are a cookie read by the partial handlers; the API
+    // itself always speaks SI.
+    function setUnits(system) {
+        document.cookie = `units=${system}; path=/; max-age=31536000; SameSite=Lax`;
+        htmx.ajax('GET', '/partial/settings', { target: '#settings-content', swap: 'innerHTML' });
+        refreshNavPoints();
+    }
+
     // ──────────── Placement mode (for map toolbar) ────────────
 
     function setPlacementMode(mode) {
@@ -292,6 +841,12 @@ const App = (() => {
                 WS.sendJoystick(0, 0.5); e.preventDefault(); break;
             case 'd': case 'ArrowRight':
                 WS.sendJoystick(0, -0.5); e.preventDefault(); break;
+            case 'q':
+                if (holonomic) { WS.sendJoystick(0, 0, 0.3); e.preventDefault(); }
+                break;
+            case 'e':
+                if (holonomic) { WS.sendJoystick(0, 0, -0.3); e.preventDefault(); }
+                break;
             case ' ':
                 WS.sendStop();
                 e.preventDefault();
@@ -307,6 +862,7 @@ const App = (() => {
         delete keysDown[e.key];
         // Stop robot when movement key is released
         const movementKeys = ['w', 's', 'a', 'd', 'ArrowUp', 'ArrowDown', 'ArrowLeft', 'ArrowRight'];
+        if (holonomic) movementKeys.push('q', 'e');
         if (movementKeys.includes(e.key)) {
             // Check if any other movement key is still held
             const stillHeld = movementKeys.some(k => keysDown[k]);
@@ -318,14 +874,56 @@ const App = (() => {
 
     // ──────────── Zoom/view delegates ────────────
 
+    // ──────────── Velocity ratios ────────────
+
+    // showRatios shows the current robot's ratios on the teleop panel and
+    // the settings sliders.
+    function showRatios(s) {
+        if (!s || s.linear_vel_ratio === undefined) return;
+        for (const [key, label, slider, value] of [
+            ['linear_vel_ratio', 'ratio-linear', 'setting-linear-ratio', 'linear-val'],
+            ['angular_vel_ratio', 'ratio-angular', 'setting-angular-ratio', 'angular-val'],
+        ]) {
+            const v = Number(s[key]).toFixed(2);
+            const el = document.getElementById(label);
+            if (el) el.textContent = v;
+            const sl = document.getElementById(slider);
+            if (sl && document.activeElement !== sl) {
+                sl.value = v;
+                const out = document.getElementById(value);
+                if (out) out.textContent = v;
+            }
+        }
+    }
+
+    // adjustRatio steps a ratio; it applies at once, also to a drive in
+    // progress, and stops at the configured bounds.
+    function adjustRatio(ratio, delta) {
+        WS.send({ type: 'adjust_ratio', data: { ratio, delta } });
+    }
+
+    // Releases the current robot's control lease if this connection holds
+    // it, and asks for it otherwise.
+    function toggleControlLease() {
+        fetch('/api/robots/control')
+        .then(r => r.json())
+        .then(lease => {
+            if (lease.error) return Notify.error(lease.error);
+            const mine = lease.holder && lease.holder.id === WS.getServerHello()?.client_id;
+            WS.send({ type: mine ? 'release_control' : 'request_control' });
+            if (!mine && lease.holder) Notify.info(`Asked ${lease.holder.label} for control`);
+        });
+    }
+
     function zoomIn()    { MapCanvas.zoomIn(); }
     function zoomOut()   { MapCanvas.zoomOut(); }
     function resetView() { MapCanvas.resetView(); }
 
     return {
-        init, setMode, showSection, switchRobot, openMap, saveSettings,
+        init, setMode, showSection, switchRobot, openMap, saveSettings, setUnits,
         setPlacementMode, zoomIn, zoomOut, resetView, refreshNavPoints,
-        fetchMapList, updateRobotCount
+        fetchMapList, updateRobotCount, discoverRobots, addPointHere, sendPoints, previewBT, setHomeHere, goHome, markIncident, goAll,
+        switchFloor, connectRobot, adjustRatio, toggleControlLease
     };
 })();
 
@@ -341,6 +939,32 @@ function hideDialog() {
     overlay.innerHTML = '';
 }
 
+// Counts down a destructive action's confirm dialog; it closes when the
+// token expires.
+function startConfirmCountdown() {
+    const el = document.querySelector('#dialog-overlay .confirm-countdown');
+    if (!el) return;
+    const end = Date.now() + Number(el.dataset.ms);
+    const timer = setInterval(() => {
+        const left = Math.max(0, Math.ceil((end - Date.now()) / 1000));
+        if (!document.body.contains(el) || left === 0) {
+            clearInterval(timer);
+            if (document.body.contains(el)) hideDialog();
+            return;
+        }
+        el.textContent = left;
+    }, 250);
+}
+
+// Voids a destructive action's token and closes its dialog.
+function cancelDestructive(robotId, token) {
+    hideDialog();
+    fetch('/api/robots/destructive/cancel', {
+        method: 'POST',
+        body: new URLSearchParams({ id: robotId, token: token })
+    }).catch(() => {});
+}
+
 // ──────────── Global mode setter (called from top bar buttons) ────────────
 function setMode(mode) {
     App.setMode(mode);
@@ -0,0 +1,24 @@
+// ─────────────────────────────────────────────────
+// Base path — the app mounted under a URL prefix
+// ─────────────────────────────────────────────────
+// Scripts and templates use root paths ("/api/...", "/partial/...").
+// Behind a proxy that mounts the app at BASE_PATH, they are prefixed
+// here, for fetch and for htmx (hx-* attributes and htmx.ajax).
+const BasePath = (() => {
+    const meta = document.querySelector('meta[name="base-path"]');
+    const base = (meta && meta.content) || '';
+
+    function url(path) {
+        if (!base || typeof path !== 'string' || !path.startsWith('/') || path.startsWith('//')) return path;
+        if (path === base || path.startsWith(base + '/')) return path;
+        return base + path;
+    }
+
+    if (base) {
+        const fetch = window.fetch.bind(window);
+        window.fetch = (input, init) => fetch(url(input), init);
+        document.addEventListener('htmx:configRequest', (e) => { e.detail.path = url(e.detail.path); });
+    }
+
+    return { base, url };
+})();
@@ -4,9 +4,11 @@
 const MapCanvas = (() => {
     let canvas, ctx;
     let mapImage = null;         // ImageData for the OccupancyGrid
-    let mapInfo = null;          // { width, height, resolution, originX, originY }
+    let mapInfo = null;          // { width, height, resolution, originX, originY, originYaw }
     let robotPose = null;        // { x, y, theta }
+    let robotShape = { radius: 0.3, footprint: null, scanMask: null }; // m; footprint [[x, y], ...] in base frame; scanMask [[start, end], ...] rad
     let laserPoints = [];        // [{x,y}, ...]
+    let homePose = null;         // { x, y, theta, map }
     let navPoints = {            // keyed by type
         waypoint: [],
         service_point: [],
@@ -31,6 +33,8 @@ const MapCanvas = (() => {
         robot: '#00d4ff',
         robotDir: '#00ff88',
         laser: 'rgba(255, 100, 100, 0.4)',
+        scanMask: 'rgba(160, 160, 160, 0.25)',
+        home: '#ff66cc',
         waypoint: '#ffcc00',
         service_point: '#00ccff',
         patrol_point: '#ff6600',
@@ -39,6 +43,13 @@ const MapCanvas = (() => {
         grid: 'rgba(255,255,255,0.05)'
     };
 
+    // Map palettes selectable via the robot's render hints
+    const PALETTES = {
+        default:       { free: [45, 45, 68],    occupied: [160, 160, 160], unknown: [22, 33, 62], shadeOccupied: true },
+        grayscale:     { free: [254, 254, 254], occupied: [0, 0, 0],       unknown: [205, 205, 205] },
+        high_contrast: { free: [0, 0, 0],       occupied: [255, 255, 0],   unknown: [40, 40, 40] }
+    };
+
     function init() {
         canvas = document.getElementById('map-canvas');
         ctx = canvas.getContext('2d');
@@ -77,37 +88,45 @@ const MapCanvas = (() => {
             height: mapData.height,
             resolution: mapData.resolution || 0.05,
             originX: mapData.origin_x || 0,
-            originY: mapData.origin_y || 0
+            originY: mapData.origin_y || 0,
+            originYaw: mapData.origin_yaw || 0
         };
 
-        // Create image from occupancy grid
+        // Create image from occupancy grid, classified with the robot's
+        // render hints so the canvas agrees with the server-side export.
+        // Invert only swaps the free and occupied colors.
+        const hints = mapData.render_hints || {};
+        const occThresh = hints.occupied_threshold ?? 65;
+        const freeThresh = hints.free_threshold ?? 25;
+        const palette = PALETTES[hints.palette] || PALETTES.default;
         const imgData = ctx.createImageData(mapData.width, mapData.height);
-        const data = mapData.data || [];
+        const data = mapData.encoding === 'base64_rle'
+            ? decodeRLE(mapData.data, mapData.width * mapData.height)
+            : (mapData.data || []);
 
         for (let i = 0; i < data.length; i++) {
             const val = data[i];
             const idx = i * 4;
+            let color;
 
-            if (val === -1 || val === 255) {
-                // Unknown
-                imgData.data[idx]     = 22;
-                imgData.data[idx + 1] = 33;
-                imgData.data[idx + 2] = 62;
-                imgData.data[idx + 3] = 255;
-            } else if (val === 0) {
-                // Free
-                imgData.data[idx]     = 45;
-                imgData.data[idx + 1] = 45;
-                imgData.data[idx + 2] = 68;
-                imgData.data[idx + 3] = 255;
+            if (val < 0 || val > 100) {
+                color = palette.unknown;
             } else {
-                // Occupied (higher = more certain)
-                const brightness = Math.min(255, val * 2.5);
-                imgData.data[idx]     = brightness;
-                imgData.data[idx + 1] = brightness;
-                imgData.data[idx + 2] = brightness;
-                imgData.data[idx + 3] = 255;
+                if (val >= occThresh) {
+                    // Occupied (higher = more certain)
+                    const b = Math.min(255, palette.occupied[0] + (val - occThresh) * 2);
+                    color = hints.invert ? palette.free
+                        : palette.shadeOccupied ? [b, b, b] : palette.occupied;
+                } else if (val <= freeThresh) {
+                    color = hints.invert ? palette.occupied : palette.free;
+                } else {
+                    color = palette.unknown;
+                }
             }
+            imgData.data[idx]     = color[0];
+            imgData.data[idx + 1] = color[1];
+            imgData.data[idx + 2] = color[2];
+            imgData.data[idx + 3] = 255;
         }
 
         // Create offscreen canvas for the map image
@@ -123,6 +142,43 @@ const MapCanvas = (() => {
         }
     }
 
+    // Sizes the view from a map_meta announcement, before the grid itself
+    // arrives; a grid of other dimensions drops the now misplaced image.
+    function updateMapMeta(meta) {
+        if (!meta || !meta.width || !meta.height) return;
+        if (mapInfo && (mapInfo.width !== meta.width || mapInfo.height !== meta.height)) {
+            mapImage = null;
+        }
+
+        mapInfo = {
+            width: meta.width,
+            height: meta.height,
+            resolution: meta.resolution || 0.05,
+            originX: meta.origin_x || 0,
+            originY: meta.origin_y || 0,
+            originYaw: meta.origin_yaw || 0
+        };
+
+        if (viewScale === 1 && viewX === 0 && viewY === 0) {
+            autoFit();
+        }
+    }
+
+    // Decode base64_rle grids: (int8 value, uint8 count) byte pairs.
+    function decodeRLE(b64, size) {
+        const bytes = atob(b64 || '');
+        const out = new Int8Array(size);
+        let pos = 0;
+        for (let i = 0; i + 1 < bytes.length && pos < size; i += 2) {
+            let val = bytes.charCodeAt(i);
+            if (val > 127) val -= 256;
+            const count = bytes.charCodeAt(i + 1);
+            out.fill(val, pos, Math.min(size, pos + count));
+            pos += count;
+        }
+        return out;
+    }
+
     function autoFit() {
         if (!mapInfo) return;
         const mapPixelW = mapInfo.width;
@@ -167,19 +223,40 @@ const MapCanvas = (() => {
     }
 
     // ──────────── World ↔ Pixel conversions ────────────
+    // Same conventions as the server's MapTransform (GET /api/maps/transform):
+    // image pixels from the top-left corner, y down, grid axes rotated by
+    // the origin's yaw.
 
     function worldToMap(wx, wy) {
         if (!mapInfo) return { x: 0, y: 0 };
-        const mx = (wx - mapInfo.originX) / mapInfo.resolution;
-        const my = mapInfo.height - (wy - mapInfo.originY) / mapInfo.resolution;
+        const dx = wx - mapInfo.originX, dy = wy - mapInfo.originY;
+        const c = Math.cos(mapInfo.originYaw), s = Math.sin(mapInfo.originYaw);
+        const mx = (c * dx + s * dy) / mapInfo.resolution;
+        const my = mapInfo.height - (-s * dx + c * dy) / mapInfo.resolution;
         return { x: mx, y: my };
     }
 
     function mapToWorld(mx, my) {
         if (!mapInfo) return { x: 0, y: 0 };
-        const wx = mx * mapInfo.resolution + mapInfo.originX;
-        const wy = (mapInfo.height - my) * mapInfo.resolution + mapInfo.originY;
-        return { x: wx, y: wy };
+        const gx = mx * mapInfo.resolution, gy = (mapInfo.height - my) * mapInfo.resolution;
+        const c = Math.cos(mapInfo.originYaw), s = Math.sin(mapInfo.originYaw);
+        return { x: mapInfo.originX + c * gx - s * gy, y: mapInfo.originY + s * gx + c * gy };
+    }
+
+    // Canvas angle of a world heading (canvas Y is inverted)
+    function mapAngle(theta) {
+        return -(theta - (mapInfo ? mapInfo.originYaw : 0));
+    }
+
+    // Compares the local conversion of world (x, y) with the server's,
+    // from the browser console: MapCanvas.checkTransform(1.5, -2).
+    async function checkTransform(x, y) {
+        const local = worldToMap(x, y);
+        const res = await fetch(`/api/maps/transform?x=${x}&y=${y}`);
+        const server = await res.json();
+        if (!res.ok) return server;
+        const dx = local.x - server.image.px, dy = local.y - server.image.py;
+        return { local, server: server.image, agree: Math.hypot(dx, dy) < 1e-6, cell: server.cell };
     }
 
     function screenToMap(sx, sy) {
@@ -225,15 +302,45 @@ const MapCanvas = (() => {
         drawNavPoints('patrol_point', COLORS.patrol_point);
         drawNavPoints('service_point', COLORS.service_point);
         drawNavPoints('waypoint', COLORS.waypoint);
+        drawHome();
 
         // Draw robot
         if (robotPose && mapInfo) {
             const rp = worldToMap(robotPose.x, robotPose.y);
-            const radius = 0.3 / mapInfo.resolution; // robot radius in pixels
+            const radius = robotShape.radius / mapInfo.resolution; // robot radius in pixels
+            const angle = mapAngle(robotPose.theta);
+
+            // Masked scan sectors: grey wedges around the robot
+            if (robotShape.scanMask) {
+                const r = radius * 3;
+                ctx.fillStyle = COLORS.scanMask;
+                for (let [start, end] of robotShape.scanMask) {
+                    if (start > end) end += Math.PI * 2; // wraps through ±π
+                    ctx.beginPath();
+                    ctx.moveTo(rp.x, rp.y);
+                    // canvas angles run clockwise: negate and swap
+                    ctx.arc(rp.x, rp.y, r, angle - end, angle - start);
+                    ctx.closePath();
+                    ctx.fill();
+                }
+            }
 
-            // Robot circle
+            // Robot outline: footprint polygon rotated by yaw, else a circle
             ctx.beginPath();
-            ctx.arc(rp.x, rp.y, radius, 0, Math.PI * 2);
+            if (robotShape.footprint) {
+                const cos = Math.cos(angle), sin = Math.sin(angle);
+                robotShape.footprint.forEach(([fx, fy], i) => {
+                    // base frame → canvas: y flips, then rotate by yaw
+                    const px = fx / mapInfo.resolution, py = -fy / mapInfo.resolution;
+                    const x = rp.x + px * cos - py * sin;
+                    const y = rp.y + px * sin + py * cos;
+                    if (i === 0) ctx.moveTo(x, y);
+                    else ctx.lineTo(x, y);
+                });
+                ctx.closePath();
+            } else {
+                ctx.arc(rp.x, rp.y, radius, 0, Math.PI * 2);
+            }
             ctx.fillStyle = 'rgba(0, 212, 255, 0.3)';
             ctx.fill();
             ctx.strokeStyle = COLORS.robot;
@@ -242,7 +349,6 @@ const MapCanvas = (() => {
 
             // Direction arrow
             const dirLen = radius * 1.5;
-            const angle = -robotPose.theta; // canvas Y is inverted
             ctx.beginPath();
             ctx.moveTo(rp.x, rp.y);
             ctx.lineTo(rp.x + dirLen * Math.cos(angle), rp.y + dirLen * Math.sin(angle));
@@ -254,6 +360,29 @@ const MapCanvas = (() => {
         ctx.restore();
     }
 
+    // House-shaped marker at the home pose, with a heading tick.
+    function drawHome() {
+        if (!homePose || !mapInfo) return;
+        const mp = worldToMap(homePose.x, homePose.y);
+        const r = 6 / viewScale;
+        ctx.beginPath();
+        ctx.moveTo(mp.x, mp.y - r);
+        ctx.lineTo(mp.x + r, mp.y - r * 0.2);
+        ctx.lineTo(mp.x + r * 0.7, mp.y + r);
+        ctx.lineTo(mp.x - r * 0.7, mp.y + r);
+        ctx.lineTo(mp.x - r, mp.y - r * 0.2);
+        ctx.closePath();
+        ctx.strokeStyle = COLORS.home;
+        ctx.lineWidth = 2 / viewScale;
+        ctx.stroke();
+
+        const angle = mapAngle(homePose.theta);
+        ctx.beginPath();
+        ctx.moveTo(mp.x, mp.y);
+        ctx.lineTo(mp.x + 2 * r * Math.cos(angle), mp.y + 2 * r * Math.sin(angle));
+        ctx.stroke();
+    }
+
     function drawNavPoints(type, color) {
         const pts = navPoints[type];
         if (!pts || pts.length === 0) return;
@@ -316,7 +445,7 @@ const MapCanvas = (() => {
                 const dLen = r * 2;
                 ctx.beginPath();
                 ctx.moveTo(mp.x, mp.y);
-                ctx.lineTo(mp.x + dLen * Math.cos(-theta), mp.y + dLen * Math.sin(-theta));
+                ctx.lineTo(mp.x + dLen * Math.cos(mapAngle(theta)), mp.y + dLen * Math.sin(mapAngle(theta)));
                 ctx.strokeStyle = color;
                 ctx.lineWidth = 1 / viewScale;
                 ctx.stroke();
@@ -425,13 +554,38 @@ const MapCanvas = (() => {
 
     // ──────────── Public API ────────────
 
+    // Radius, footprint, scan mask and home from the robot snapshot or a
+    // robot_config broadcast.
+    function setRobotConfig(cfg) {
+        if (!cfg) return;
+        if (cfg.radius > 0) robotShape.radius = cfg.radius;
+        robotShape.footprint = cfg.footprint && cfg.footprint.length >= 3 ? cfg.footprint : null;
+        robotShape.scanMask = cfg.scan_mask && cfg.scan_mask.length ? cfg.scan_mask : null;
+        homePose = cfg.home || null;
+    }
+
+    // Forgets the map, robot and points, when no robot is selected.
+    function clear() {
+        mapImage = null;
+        mapInfo = null;
+        robotPose = null;
+        laserPoints = [];
+        homePose = null;
+        robotShape = { radius: 0.3, footprint: null, scanMask: null };
+        for (const type in navPoints) navPoints[type] = [];
+    }
+
     return {
         init,
+        clear,
         updateMap,
+        updateMapMeta,
         updateRobotPose,
+        setRobotConfig,
         updateLaser,
         updateNavPoints,
         autoFit,
+        checkTransform,
 
         zoomIn()  { viewScale *= 1.2; },
         zoomOut() { viewScale /= 1.2; },
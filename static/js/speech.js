@@ -65,11 +65,17 @@ const Speech = (() => {
             if (data.error) {
                 if (statusEl) statusEl.textContent = 'Error';
                 Notify.error(data.error);
+            } else if (data.status === 'low_confidence') {
+                if (statusEl) statusEl.textContent = 'Not understood';
+                if (resultEl) resultEl.textContent = `(${data.raw_text}?)`;
+                Notify.warn(`Not sent — low confidence (${Math.round(data.confidence * 100)}%)`);
             } else {
                 if (statusEl) statusEl.textContent = 'Done';
-                if (resultEl) resultEl.textContent = data.text || '(empty)';
+                // Show the canonical command when a phrase was mapped
+                const sent = data.command && data.command !== data.text ? data.command : '';
+                if (resultEl) resultEl.textContent = sent ? `${data.text} → ${sent}` : (data.text || '(empty)');
                 if (data.text) {
-                    Notify.success(`Voice: "${data.text}"`);
+                    Notify.success(`Voice: "${sent || data.text}"`);
                 }
             }
         } catch (err) {
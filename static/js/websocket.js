@@ -2,13 +2,43 @@
 // WebSocket client — browser ↔ Go server
 // ─────────────────────────────────────────────────
 const WS = (() => {
+    // Protocol version spoken by this frontend (see handlers/ws_protocol.go)
+    const PROTOCOL_VERSION = 3;
+
     let ws = null;
+    let serverHello = null;
     let reconnectTimer = null;
     const handlers = {};
 
+    // Last seq seen per "robot_id/type" stream; frames with a lower seq
+    // are stale (see frame_fields in the server hello).
+    let lastSeq = {};
+
+    // Reports whether msg is older than a frame already handled.
+    function stale(msg) {
+        if (!msg.seq) return false;
+        const key = `${msg.robot_id || ''}/${msg.type}`;
+        if (msg.seq < (lastSeq[key] || 0)) return true;
+        lastSeq[key] = msg.seq;
+        return false;
+    }
+
+    function resetStreams(seqs) {
+        lastSeq = {};
+        for (const [robotID, types] of Object.entries(seqs || {})) {
+            for (const [type, seq] of Object.entries(types)) lastSeq[`${robotID}/${type}`] = seq;
+        }
+    }
+
     function connect() {
-        const proto = location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const url = `${proto}//${location.host}/ws`;
+        // The server hands out the URL as the client reaches it (scheme
+        // and host from the proxy headers, BASE_PATH included); an https
+        // page always needs wss.
+        const meta = document.querySelector('meta[name="ws-scheme"]');
+        const secure = location.protocol === 'https:' || (meta && meta.content === 'wss');
+        const proto = secure ? 'wss:' : 'ws:';
+        const given = document.querySelector('meta[name="ws-url"]')?.content;
+        const url = given ? given.replace(/^wss?:/, proto) : `${proto}//${location.host}/ws`;
         ws = new WebSocket(url);
 
         ws.onopen = () => {
@@ -16,7 +46,8 @@ const WS = (() => {
             if (reconnectTimer) { clearInterval(reconnectTimer); reconnectTimer = null; }
             document.getElementById('conn-badge').textContent = 'WS Connected';
             document.getElementById('conn-badge').classList.add('connected');
-            // Request initial state
+            // Declare our protocol version, then request initial state
+            send({ type: 'hello', data: { version: PROTOCOL_VERSION, encoding: 'base64_rle' } });
             send({ type: 'request_map' });
             send({ type: 'request_status' });
         };
@@ -24,8 +55,17 @@ const WS = (() => {
         ws.onmessage = (ev) => {
             try {
                 const msg = JSON.parse(ev.data);
+                if (msg.type === 'hello') serverHello = msg.data;
+                if (msg.type === 'stream_reset') resetStreams(msg.data?.seqs);
+                if (stale(msg)) return;
+                if (msg.type === 'upgrade_required') {
+                    Notify.show('This page is out of date — please reload to get the latest version.', 'warn', 0);
+                }
                 const fn = handlers[msg.type];
                 if (fn) fn(msg);
+                // Extra topics (custom:<alias>) are for page scripts:
+                // WS.on('custom', ...) receives all of them
+                else if (msg.type.startsWith('custom:') && handlers.custom) handlers.custom(msg);
             } catch (e) {
                 console.warn('[ws] parse error:', e);
             }
@@ -63,16 +103,52 @@ const WS = (() => {
         handlers[type] = callback;
     }
 
-    function sendJoystick(linearX, angularZ) {
-        send({
-            type: 'joystick',
-            data: { linear_x: linearX, angular_z: angularZ }
-        });
+    // Set after the operator takes over from autonomy; cleared when the
+    // server reports the take-over has ended.
+    let override = false;
+
+    // Set when the operator confirmed taking the robot from another
+    // connection; sent with the next joystick message only.
+    let takeover = false;
+
+    // linearY (strafe, positive to the left) only moves holonomic robots.
+    function sendJoystick(linearX, angularZ, linearY = 0) {
+        const data = { linear_x: linearX, angular_z: angularZ };
+        if (linearY) data.linear_y = linearY;
+        if (override) data.override = true;
+        if (takeover) data.takeover = true;
+        takeover = false;
+        send({ type: 'joystick', data });
+    }
+
+    function setOverride(enabled) {
+        override = enabled;
+    }
+
+    function requestTakeover() {
+        takeover = true;
     }
 
     function sendStop() {
         send({ type: 'stop' });
     }
 
-    return { connect, send, on, sendJoystick, sendStop };
+    function getServerHello() {
+        return serverHello;
+    }
+
+    // Debugging with DEBUG_TOPIC_TAP on: WS.tap('/odom') logs a robot's
+    // raw /odom messages (the current robot's by default) to the console
+    // until WS.untap('/odom') or the tap expires.
+    function tap(topic, type = '', robotId = '') {
+        on('raw_topic', (msg) => console.log(`[tap ${msg.robot_id}${msg.data.topic}]`, msg.data));
+        on('topic_tap', (msg) => console.info(`[tap ${msg.robot_id}${msg.data.topic}] ${msg.data.state}`, msg.data.reason || ''));
+        send({ type: 'tap_topic', robot_id: robotId, data: { topic, type } });
+    }
+
+    function untap(topic, robotId = '') {
+        send({ type: 'untap_topic', robot_id: robotId, data: { topic } });
+    }
+
+    return { connect, send, on, sendJoystick, sendStop, setOverride, requestTakeover, getServerHello, tap, untap };
 })();
@@ -0,0 +1,160 @@
+// Package tlscert provides the self-signed certificate used when the app
+// serves HTTPS without a certificate of its own.
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File names inside the certificate directory.
+const (
+	CertFileName = "cert.pem"
+	KeyFileName  = "key.pem"
+)
+
+// Validity of a generated certificate. Apple platforms reject TLS server
+// certificates valid for more than 825 days, even untrusted ones.
+const validity = 825 * 24 * time.Hour
+
+// renewBefore is how long before expiry a stored certificate is replaced.
+const renewBefore = 30 * 24 * time.Hour
+
+// EnsureSelfSigned returns the certificate and key paths in dir, generating
+// a self-signed pair when there is none or the stored one is unusable or
+// about to expire. The pair is reused across restarts so the fingerprint
+// browsers were told to trust stays stable. hosts are extra DNS names or
+// IPs for the certificate; localhost, the hostname and the addresses of
+// the local interfaces are always included.
+func EnsureSelfSigned(dir string, hosts []string) (certFile, keyFile string, generated bool, err error) {
+	certFile = filepath.Join(dir, CertFileName)
+	keyFile = filepath.Join(dir, KeyFileName)
+
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		if cert, err := x509.ParseCertificate(pair.Certificate[0]); err == nil &&
+			time.Until(cert.NotAfter) > renewBefore {
+			return certFile, keyFile, false, nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", false, err
+	}
+	certPEM, keyPEM, err := generate(append(defaultHosts(), hosts...))
+	if err != nil {
+		return "", "", false, err
+	}
+	if err := writeFile(keyFile, keyPEM, 0o600); err != nil {
+		return "", "", false, err
+	}
+	if err := writeFile(certFile, certPEM, 0o644); err != nil {
+		return "", "", false, err
+	}
+	return certFile, keyFile, true, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the first certificate in
+// certFile, formatted as browsers show it (AA:BB:...).
+func Fingerprint(certFile string) (string, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("%s: no certificate", certFile)
+	}
+	sum := sha256.Sum256(block.Bytes)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// generate creates an ECDSA P-256 self-signed certificate for hosts.
+func generate(hosts []string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"ROM Dynamics"}, CommonName: "rom_go_app self-signed"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// defaultHosts are the names a browser on the local network may use.
+func defaultHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+		if !strings.Contains(name, ".") {
+			hosts = append(hosts, name+".local")
+		}
+	}
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok && !ipn.IP.IsLoopback() && !ipn.IP.IsLinkLocalUnicast() {
+			hosts = append(hosts, ipn.IP.String())
+		}
+	}
+	return hosts
+}
+
+// writeFile writes data atomically with perm.
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,89 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+	certFile, keyFile, generated, err := EnsureSelfSigned(dir, []string{"robots.example", "10.1.2.3", "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !generated || certFile != filepath.Join(dir, CertFileName) || keyFile != filepath.Join(dir, KeyFileName) {
+		t.Errorf("first call: %s %s generated %v", certFile, keyFile, generated)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file: %v %v", info.Mode(), err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "robots.example", "10.1.2.3", "127.0.0.1", "::1"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("certificate doesn't cover %s: %v", host, err)
+		}
+	}
+	if d := cert.NotAfter.Sub(cert.NotBefore); d > validity+time.Hour || d < validity {
+		t.Errorf("valid for %v", d)
+	}
+	seen := map[string]int{}
+	for _, name := range cert.DNSNames {
+		seen[name]++
+	}
+	if seen["localhost"] != 1 {
+		t.Errorf("DNS names %v", cert.DNSNames)
+	}
+
+	// Reused across restarts: the fingerprint browsers trust stays
+	fp, err := Fingerprint(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fp) != 32*3-1 || strings.ToUpper(fp) != fp {
+		t.Errorf("fingerprint %q", fp)
+	}
+	if _, _, generated, err := EnsureSelfSigned(dir, nil); err != nil || generated {
+		t.Errorf("second call: generated %v, %v", generated, err)
+	}
+	if again, _ := Fingerprint(certFile); again != fp {
+		t.Errorf("fingerprint changed: %s, was %s", again, fp)
+	}
+
+	// An unusable pair is replaced
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, generated, err := EnsureSelfSigned(dir, nil); err != nil || !generated {
+		t.Errorf("broken key: generated %v, %v", generated, err)
+	}
+	if again, _ := Fingerprint(certFile); again == fp {
+		t.Error("broken pair kept")
+	}
+}
+
+func TestFingerprintNoCertificate(t *testing.T) {
+	dir := t.TempDir()
+	_, keyFile, _, err := EnsureSelfSigned(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Fingerprint(keyFile); err == nil {
+		t.Error("fingerprint of a key")
+	}
+	if _, err := Fingerprint(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("fingerprint of a missing file")
+	}
+}
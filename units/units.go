@@ -0,0 +1,127 @@
+// Package units converts and formats the SI values the robot reports for
+// display in the operator's preferred unit system. APIs keep SI values;
+// conversion happens only when rendering or when a client asks for
+// converted fields alongside.
+package units
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+// System is a unit system preference.
+type System string
+
+// Unit systems.
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial"
+)
+
+// Parse returns the system named by s; anything unrecognised is metric.
+func Parse(s string) System {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "imperial", "us", "ft":
+		return Imperial
+	}
+	return Metric
+}
+
+// Valid reports whether s names a unit system.
+func Valid(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "metric", "si", "m", "imperial", "us", "ft":
+		return true
+	}
+	return false
+}
+
+// Conversion factors.
+const (
+	metersPerFoot = 0.3048
+	mpsPerMph     = 0.44704
+	metersPerMile = 1609.344
+)
+
+// MetersToFeet converts meters to feet.
+func MetersToFeet(m float64) float64 { return m / metersPerFoot }
+
+// FeetToMeters converts feet to meters.
+func FeetToMeters(ft float64) float64 { return ft * metersPerFoot }
+
+// MpsToMph converts meters per second to miles per hour.
+func MpsToMph(mps float64) float64 { return mps / mpsPerMph }
+
+// MphToMps converts miles per hour to meters per second.
+func MphToMps(mph float64) float64 { return mph * mpsPerMph }
+
+// RadToDeg converts radians to degrees.
+func RadToDeg(rad float64) float64 { return rad * 180 / math.Pi }
+
+// DegToRad converts degrees to radians.
+func DegToRad(deg float64) float64 { return deg * math.Pi / 180 }
+
+// Length formats meters: "1.25 m", or "4.10 ft" / "11.8 in" (under a foot).
+func (s System) Length(m float64) string {
+	if s != Imperial {
+		return fmt.Sprintf("%.2f m", m)
+	}
+	ft := MetersToFeet(m)
+	if math.Abs(ft) < 1 {
+		return fmt.Sprintf("%.1f in", ft*12)
+	}
+	return fmt.Sprintf("%.2f ft", ft)
+}
+
+// Distance formats a distance traveled: "850 m" or "12.3 km", "920 ft"
+// or "7.6 mi" (from a tenth of a mile).
+func (s System) Distance(m float64) string {
+	if s != Imperial {
+		if math.Abs(m) < 1000 {
+			return fmt.Sprintf("%.0f m", m)
+		}
+		return fmt.Sprintf("%.1f km", m/1000)
+	}
+	if mi := m / metersPerMile; math.Abs(mi) >= 0.1 {
+		return fmt.Sprintf("%.1f mi", mi)
+	}
+	return fmt.Sprintf("%.0f ft", MetersToFeet(m))
+}
+
+// Speed formats meters per second: "0.50 m/s" or "1.12 mph".
+func (s System) Speed(mps float64) string {
+	if s != Imperial {
+		return fmt.Sprintf("%.2f m/s", mps)
+	}
+	return fmt.Sprintf("%.2f mph", MpsToMph(mps))
+}
+
+// Angle formats radians: "1.57 rad" or "90.0°".
+func (s System) Angle(rad float64) string {
+	if s != Imperial {
+		return fmt.Sprintf("%.2f rad", rad)
+	}
+	return fmt.Sprintf("%.1f°", RadToDeg(rad))
+}
+
+// AngularSpeed formats radians per second: "0.50 rad/s" or "28.6°/s".
+func (s System) AngularSpeed(radps float64) string {
+	if s != Imperial {
+		return fmt.Sprintf("%.2f rad/s", radps)
+	}
+	return fmt.Sprintf("%.1f°/s", RadToDeg(radps))
+}
+
+// FuncMap holds the template formatting functions, each taking the
+// System first: {{length .Units .Radius}}.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"length":       System.Length,
+		"distance":     System.Distance,
+		"speed":        System.Speed,
+		"angle":        System.Angle,
+		"angularSpeed": System.AngularSpeed,
+	}
+}
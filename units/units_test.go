@@ -0,0 +1,110 @@
+package units
+
+import (
+	"bytes"
+	"html/template"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  System
+		valid bool
+	}{
+		{"imperial", Imperial, true},
+		{" Imperial ", Imperial, true},
+		{"US", Imperial, true},
+		{"ft", Imperial, true},
+		{"metric", Metric, true},
+		{"si", Metric, true},
+		{"m", Metric, true},
+		{"", Metric, false},
+		{"furlongs", Metric, false},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.in); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := Valid(tt.in); got != tt.valid {
+			t.Errorf("Valid(%q) = %v, want %v", tt.in, got, tt.valid)
+		}
+	}
+}
+
+func TestConversions(t *testing.T) {
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	tests := []struct {
+		name     string
+		to, back func(float64) float64
+		in, want float64
+	}{
+		{"m→ft", MetersToFeet, FeetToMeters, 0.3048, 1},
+		{"m→ft 10", MetersToFeet, FeetToMeters, 10, 32.808398950131235},
+		{"m/s→mph", MpsToMph, MphToMps, 0.44704, 1},
+		{"m/s→mph 1", MpsToMph, MphToMps, 1, 2.2369362920544025},
+		{"rad→deg", RadToDeg, DegToRad, math.Pi, 180},
+		{"rad→deg negative", RadToDeg, DegToRad, -math.Pi / 2, -90},
+		{"zero", MetersToFeet, FeetToMeters, 0, 0},
+	}
+	for _, tt := range tests {
+		got := tt.to(tt.in)
+		if !near(got, tt.want) {
+			t.Errorf("%s: %v → %v, want %v", tt.name, tt.in, got, tt.want)
+		}
+		if back := tt.back(got); !near(back, tt.in) {
+			t.Errorf("%s: round trip %v → %v", tt.name, tt.in, back)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	tests := []struct {
+		name   string
+		format func(System, float64) string
+		in     float64
+		metric string
+		imp    string
+	}{
+		{"length", System.Length, 1.25, "1.25 m", "4.10 ft"},
+		{"short length", System.Length, 0.3, "0.30 m", "11.8 in"},
+		{"negative length", System.Length, -2, "-2.00 m", "-6.56 ft"},
+		{"distance", System.Distance, 850, "850 m", "0.5 mi"},
+		{"long distance", System.Distance, 12345, "12.3 km", "7.7 mi"},
+		{"short distance", System.Distance, 100, "100 m", "328 ft"},
+		{"speed", System.Speed, 0.5, "0.50 m/s", "1.12 mph"},
+		{"angle", System.Angle, math.Pi / 2, "1.57 rad", "90.0°"},
+		{"angular speed", System.AngularSpeed, 0.5, "0.50 rad/s", "28.6°/s"},
+	}
+	for _, tt := range tests {
+		if got := tt.format(Metric, tt.in); got != tt.metric {
+			t.Errorf("%s metric: %q, want %q", tt.name, got, tt.metric)
+		}
+		if got := tt.format(Imperial, tt.in); got != tt.imp {
+			t.Errorf("%s imperial: %q, want %q", tt.name, got, tt.imp)
+		}
+		// Anything but imperial renders metric
+		if got := tt.format(System("bogus"), tt.in); got != tt.metric {
+			t.Errorf("%s unknown system: %q, want %q", tt.name, got, tt.metric)
+		}
+	}
+}
+
+func TestFuncMap(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(FuncMap()).Parse(
+		`{{length .U .V}}|{{distance .U .V}}|{{speed .U .V}}|{{angle .U .V}}|{{angularSpeed .U .V}}`))
+	tests := map[System]string{
+		Metric:   "1.00 m|1 m|1.00 m/s|1.00 rad|1.00 rad/s",
+		Imperial: "3.28 ft|3 ft|2.24 mph|57.3°|57.3°/s",
+	}
+	for u, want := range tests {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, map[string]interface{}{"U": u, "V": 1.0}); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != want {
+			t.Errorf("%s: %q, want %q", u, b.String(), want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package version
+
+// Build information, injected at link time via:
+//
+//	go build -ldflags "-X rom_go_app/version.Version=v1.2.0 -X rom_go_app/version.Commit=abc123"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = ""
+)
+
+// Info is the JSON-friendly build information.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time,omitempty"`
+}
+
+// Get returns the current build information.
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+}
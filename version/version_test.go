@@ -0,0 +1,23 @@
+package version
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	if got := Get(); got != (Info{Version: "dev", Commit: "unknown"}) {
+		t.Errorf("unset: %+v", got)
+	}
+	b, _ := json.Marshal(Get())
+	if string(b) != `{"version":"dev","commit":"unknown"}` {
+		t.Errorf("unset JSON %s", b)
+	}
+
+	// As set by -ldflags -X
+	defer func(v, c, bt string) { Version, Commit, BuildTime = v, c, bt }(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "v1.2.0", "abc123", "2024-05-01T10:00:00Z"
+	if got := Get(); got != (Info{Version: "v1.2.0", Commit: "abc123", BuildTime: "2024-05-01T10:00:00Z"}) {
+		t.Errorf("set: %+v", got)
+	}
+}
@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ──────────────────────────── Delivery
+//
+// Emit never blocks: events are queued for one worker goroutine, and an
+// event arriving while the queue is full is dropped and logged. Each
+// matching endpoint gets its own POST; a failed one (transport error or
+// non-2xx answer) is retried after RetryInitialDelay, doubling up to
+// RetryMaxDelay, until MaxAttempts. Every attempt is kept in a short log
+// for GET /api/webhooks/deliveries.
+//
+// The body is the Event as JSON. With a secret the request carries
+// X-Webhook-Signature: sha256=<hex HMAC-SHA256 of the body>.
+
+// Delivery limits.
+const (
+	QueueSize         = 256
+	MaxAttempts       = 5
+	RetryInitialDelay = 2 * time.Second
+	RetryMaxDelay     = time.Minute
+	RequestTimeout    = 10 * time.Second
+	DeliveryLogSize   = 200
+)
+
+// Event is the JSON body posted to endpoints.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"event"`
+	RobotID   string      `json:"robot_id,omitempty"`
+	RobotName string      `json:"robot_name,omitempty"`
+	Time      time.Time   `json:"time"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// Delivery is one POST attempt.
+type Delivery struct {
+	EventID    string     `json:"event_id"`
+	Event      string     `json:"event"`
+	WebhookID  string     `json:"webhook_id"`
+	URL        string     `json:"url"`
+	Attempt    int        `json:"attempt"`
+	Time       time.Time  `json:"time"`
+	DurationMs int64      `json:"duration_ms"`
+	StatusCode int        `json:"status_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	OK         bool       `json:"ok"`
+	NextRetry  *time.Time `json:"next_retry,omitempty"` // nil once delivered or given up
+}
+
+// job is one event for one endpoint.
+type job struct {
+	webhookID string
+	event     Event
+	body      []byte
+	attempt   int
+}
+
+// retryDelay returns the wait after failed attempt n (1-based).
+func retryDelay(n int) time.Duration {
+	d := RetryInitialDelay
+	for i := 1; i < n && d < RetryMaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, RetryMaxDelay)
+}
+
+// Emit queues ev for every endpoint that wants it. It fills in the
+// event's ID and time when unset.
+func (s *Service) Emit(ev Event) {
+	if ev.ID == "" {
+		ev.ID = newEventID()
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("[webhook] encode %s event: %v", ev.Type, err)
+		return
+	}
+
+	s.mu.Lock()
+	var targets []Endpoint
+	for _, e := range s.endpoints {
+		if e.Wants(ev.Type) {
+			targets = append(targets, e)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, e := range targets {
+		s.enqueue(job{webhookID: e.ID, event: ev, body: body, attempt: 1}, e.URL)
+	}
+}
+
+// enqueue hands j to the worker, or records it as dropped.
+func (s *Service) enqueue(j job, url string) {
+	select {
+	case s.queue <- j:
+	default:
+		log.Printf("[webhook] queue full, dropped %s event %s for webhook %s", j.event.Type, j.event.ID, j.webhookID)
+		s.record(Delivery{
+			EventID: j.event.ID, Event: j.event.Type, WebhookID: j.webhookID, URL: url,
+			Attempt: j.attempt, Time: time.Now().UTC(), Error: "delivery queue full; dropped",
+		})
+	}
+}
+
+// Run delivers queued events until ctx ends.
+func (s *Service) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.queue:
+			s.deliver(ctx, j)
+		}
+	}
+}
+
+// deliver makes one attempt and schedules the next if it failed.
+func (s *Service) deliver(ctx context.Context, j job) {
+	s.mu.Lock()
+	e, ok := s.endpoints[j.webhookID]
+	s.mu.Unlock()
+	if !ok || !e.Enabled {
+		return // removed or disabled since the event was queued
+	}
+
+	d := s.post(ctx, e, j)
+	if !d.OK && j.attempt < MaxAttempts && ctx.Err() == nil {
+		wait := s.backoff(j.attempt)
+		next := d.Time.Add(wait)
+		d.NextRetry = &next
+		j.attempt++
+		time.AfterFunc(wait, func() { s.enqueue(j, e.URL) })
+	}
+	if !d.OK {
+		state := "giving up"
+		if d.NextRetry != nil {
+			state = "retrying in " + d.NextRetry.Sub(d.Time).String()
+		}
+		log.Printf("[webhook] %s event %s to webhook %s (attempt %d) failed: %s; %s",
+			j.event.Type, j.event.ID, e.ID, d.Attempt, d.Error, state)
+	}
+	s.record(d)
+}
+
+// post sends j's body to e once.
+func (s *Service) post(ctx context.Context, e Endpoint, j job) Delivery {
+	d := Delivery{
+		EventID: j.event.ID, Event: j.event.Type, WebhookID: e.ID, URL: e.URL,
+		Attempt: j.attempt, Time: time.Now().UTC(),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(j.body))
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rom_go_app-webhook")
+	req.Header.Set("X-Webhook-Event", j.event.Type)
+	req.Header.Set("X-Webhook-Delivery", j.event.ID)
+	if e.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", Sign(e.Secret, j.body))
+	}
+
+	resp, err := s.client.Do(req)
+	d.DurationMs = time.Since(d.Time).Milliseconds()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	d.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		d.Error = fmt.Sprintf("receiver answered %s", resp.Status)
+		return d
+	}
+	d.OK = true
+	return d
+}
+
+// Test posts a test event to one endpoint, enabled or not, once and
+// returns the attempt.
+func (s *Service) Test(ctx context.Context, id string) (Delivery, error) {
+	s.mu.Lock()
+	e, ok := s.endpoints[id]
+	s.mu.Unlock()
+	if !ok {
+		return Delivery{}, ErrNotFound
+	}
+	ev := Event{ID: newEventID(), Type: EventTest, Time: time.Now().UTC(),
+		Data: map[string]string{"message": "Test event from rom_go_app"}}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return Delivery{}, err
+	}
+	d := s.post(ctx, e, job{webhookID: id, event: ev, body: body, attempt: 1})
+	s.record(d)
+	return d, nil
+}
+
+// Sign returns the X-Webhook-Signature value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) record(d Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, d)
+	if n := len(s.deliveries) - DeliveryLogSize; n > 0 {
+		s.deliveries = append(s.deliveries[:0:0], s.deliveries[n:]...)
+	}
+}
+
+// Deliveries returns recent attempts, newest first, for one endpoint or
+// all ("").
+func (s *Service) Deliveries(webhookID string) []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Delivery{}
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		if d := s.deliveries[i]; webhookID == "" || d.WebhookID == webhookID {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func newEventID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a webhook endpoint answering each request with the next
+// status in codes (the last repeating), recording what it got.
+type receiver struct {
+	mu    sync.Mutex
+	codes []int
+	reqs  []received
+	srv   *httptest.Server
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, codes ...int) *receiver {
+	rc := &receiver{codes: codes}
+	rc.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		code := rc.codes[min(len(rc.reqs), len(rc.codes)-1)]
+		rc.reqs = append(rc.reqs, received{r.Header.Clone(), body})
+		rc.mu.Unlock()
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(rc.srv.Close)
+	return rc
+}
+
+func (rc *receiver) requests() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received{}, rc.reqs...)
+}
+
+// waitFor polls cond for up to two seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runService starts the delivery worker with retries after 10 ms.
+func runService(t *testing.T, s *Service) {
+	t.Helper()
+	s.backoff = func(int) time.Duration { return 10 * time.Millisecond }
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Run(ctx)
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"estop"}`)
+	sig := Sign("key", body)
+	// echo -n '{"event":"estop"}' | openssl dgst -sha256 -hmac key
+	if sig != "sha256=2a553a2c3e4cdb33795ae9a46651d9b05ecaec05b76c062f329ec31d664156c1" {
+		t.Fatalf("signature %q", sig)
+	}
+	if Sign("key", body) != sig || Sign("other", body) == sig || Sign("key", append(body, ' ')) == sig {
+		t.Error("signature doesn't follow the key and body")
+	}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	s := newTestService(t)
+	signed, _ := s.Add(Endpoint{URL: rc.srv.URL + "/signed", Secret: "s3cret", Enabled: true})
+	s.Add(Endpoint{URL: rc.srv.URL + "/plain", Enabled: true})
+	runService(t, s)
+
+	s.Emit(Event{Type: EventEStop, RobotID: "1", Data: map[string]bool{"engaged": true}})
+	waitFor(t, "two deliveries", func() bool { return len(rc.requests()) == 2 })
+
+	for _, req := range rc.requests() {
+		var ev Event
+		if err := json.Unmarshal(req.body, &ev); err != nil || ev.Type != EventEStop || ev.RobotID != "1" || ev.ID == "" || ev.Time.IsZero() {
+			t.Errorf("body %s: %v", req.body, err)
+		}
+		if req.header.Get("X-Webhook-Event") != EventEStop || req.header.Get("X-Webhook-Delivery") != ev.ID ||
+			req.header.Get("Content-Type") != "application/json" {
+			t.Errorf("headers %v", req.header)
+		}
+		got := req.header.Get("X-Webhook-Signature")
+		if got == "" {
+			continue
+		}
+		// What a receiver does: recompute over the raw body
+		if !hmac.Equal([]byte(got), []byte(Sign("s3cret", req.body))) {
+			t.Errorf("signature %s doesn't verify", got)
+		}
+	}
+	sigs := 0
+	for _, req := range rc.requests() {
+		if req.header.Get("X-Webhook-Signature") != "" {
+			sigs++
+		}
+	}
+	if sigs != 1 {
+		t.Errorf("%d signed requests, want only the endpoint with a secret", sigs)
+	}
+
+	d, err := s.Test(context.Background(), signed.ID)
+	if err != nil || !d.OK || d.Event != EventTest || d.StatusCode != http.StatusOK {
+		t.Errorf("test event: %+v %v", d, err)
+	}
+	if _, err := s.Test(context.Background(), "99"); err != ErrNotFound {
+		t.Errorf("test to unknown: %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+	for i, d := range want {
+		if got := retryDelay(i + 1); got != d {
+			t.Errorf("after attempt %d: %v, want %v", i+1, got, d)
+		}
+	}
+}
+
+func TestDeliverRetry(t *testing.T) {
+	rc := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent)
+	s := newTestService(t)
+	e, _ := s.Add(Endpoint{URL: rc.srv.URL, Enabled: true})
+	runService(t, s)
+
+	s.Emit(Event{Type: EventDisconnected, RobotID: "1"})
+	waitFor(t, "the third attempt", func() bool { return len(s.Deliveries(e.ID)) == 3 })
+
+	ds := s.Deliveries(e.ID) // newest first
+	for i, d := range ds {
+		attempt := 3 - i
+		if d.Attempt != attempt || d.OK != (attempt == 3) || (d.NextRetry != nil) == d.OK {
+			t.Errorf("attempt %d: %+v", attempt, d)
+		}
+	}
+	if ds[2].StatusCode != http.StatusInternalServerError || ds[2].Error == "" || ds[0].StatusCode != http.StatusNoContent {
+		t.Errorf("deliveries %+v", ds)
+	}
+	reqs := rc.requests()
+	if len(reqs) != 3 || string(reqs[0].body) != string(reqs[2].body) {
+		t.Errorf("%d requests; retried body differs", len(reqs))
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	rc := newReceiver(t, http.StatusServiceUnavailable)
+	s := newTestService(t)
+	e, _ := s.Add(Endpoint{URL: rc.srv.URL, Enabled: true})
+	runService(t, s)
+	var mu sync.Mutex
+	var waits []int
+	s.backoff = func(n int) time.Duration {
+		mu.Lock()
+		waits = append(waits, n)
+		mu.Unlock()
+		return time.Millisecond
+	}
+
+	s.Emit(Event{Type: EventEStop})
+	waitFor(t, "every attempt", func() bool { return len(s.Deliveries(e.ID)) == MaxAttempts })
+	time.Sleep(50 * time.Millisecond)
+	if n := len(rc.requests()); n != MaxAttempts {
+		t.Errorf("%d requests, want %d", n, MaxAttempts)
+	}
+	if last := s.Deliveries(e.ID)[0]; last.NextRetry != nil || last.Attempt != MaxAttempts {
+		t.Errorf("last attempt %+v", last)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(waits) != MaxAttempts-1 || waits[0] != 1 || waits[len(waits)-1] != MaxAttempts-1 {
+		t.Errorf("backoff asked after attempts %v", waits)
+	}
+}
+
+func TestEmitFilter(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	s := newTestService(t)
+	estop, _ := s.Add(Endpoint{URL: rc.srv.URL + "/estop", Events: []string{EventEStop}, Enabled: true})
+	all, _ := s.Add(Endpoint{URL: rc.srv.URL + "/all", Enabled: true})
+	off, _ := s.Add(Endpoint{URL: rc.srv.URL + "/off"})
+	runService(t, s)
+
+	s.Emit(Event{Type: EventEStop})
+	s.Emit(Event{Type: EventBatteryLow})
+	s.Emit(Event{Type: EventGoalReached})
+	waitFor(t, "four deliveries", func() bool { return len(rc.requests()) == 4 })
+	time.Sleep(20 * time.Millisecond)
+
+	if n := len(s.Deliveries(estop.ID)); n != 1 || s.Deliveries(estop.ID)[0].Event != EventEStop {
+		t.Errorf("filtered endpoint: %+v", s.Deliveries(estop.ID))
+	}
+	if n := len(s.Deliveries(all.ID)); n != 3 {
+		t.Errorf("unfiltered endpoint: %d deliveries", n)
+	}
+	if n := len(s.Deliveries(off.ID)); n != 0 {
+		t.Errorf("disabled endpoint: %d deliveries", n)
+	}
+
+	// Disabled: no longer sent to
+	s.Update(all.ID, func(e *Endpoint) { e.Enabled = false })
+	s.Emit(Event{Type: EventEStop})
+	waitFor(t, "the e-stop", func() bool { return len(s.Deliveries(estop.ID)) == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if n := len(s.Deliveries(all.ID)); n != 3 {
+		t.Errorf("disabled endpoint delivered to: %d deliveries", n)
+	}
+}
+
+func TestEmitQueueFull(t *testing.T) {
+	s := newTestService(t)
+	e, _ := s.Add(Endpoint{URL: "http://127.0.0.1:9/", Enabled: true})
+	for i := 0; i < QueueSize+3; i++ { // no worker running
+		s.Emit(Event{Type: EventEStop})
+	}
+	ds := s.Deliveries(e.ID)
+	if len(ds) != 3 || ds[0].Error == "" || ds[0].OK {
+		t.Errorf("dropped deliveries %+v", ds)
+	}
+}
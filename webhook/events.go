@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// ──────────────────────────── Events from the robot manager
+//
+// Events are derived from the manager's broadcasts. A browser's
+// subscription drops frames when it falls behind, which would lose a
+// goal_reached; Follow uses a broadcast handler instead, which sees
+// every frame and only queues the few it acts on. Deriving and posting
+// happen on Follow's goroutine, so a slow receiver never holds up the
+// data path.
+
+// Arrival matching: a succeeded goal names the navigation point within
+// ArrivalRadiusM of the robot's pose.
+const (
+	ArrivalRadiusM = 0.5
+	arrivalPoseAge = 3 * time.Second
+)
+
+// Battery defaults: battery_low is sent once the charge reported on the
+// "battery" extra topic falls below 20 %, and again only after it has
+// risen BatteryRearmPct above that.
+const (
+	DefaultBatteryAlias  = "battery"
+	DefaultBatteryLowPct = 20
+	BatteryRearmPct      = 5
+)
+
+// GoalReached is the goal_reached event data.
+type GoalReached struct {
+	GoalID string           `json:"goal_id"`
+	Point  *robot.PointNear `json:"point,omitempty"` // nil when no point is near
+}
+
+// BatteryLow is the battery_low event data.
+type BatteryLow struct {
+	Percent      float64 `json:"percent"`
+	ThresholdPct float64 `json:"threshold_pct"`
+}
+
+// Follow turns mgr's broadcasts into events until ctx ends.
+func (s *Service) Follow(ctx context.Context, mgr *robot.Manager) {
+	q := &followQueue{ready: make(chan struct{}, 1)}
+	remove := mgr.AddBroadcastHandler(func(msg robot.BroadcastMsg) {
+		if s.follows(msg.Type) {
+			q.push(msg)
+		}
+	})
+	defer remove()
+
+	f := newFollower(s, mgr)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.ready:
+			for _, msg := range q.take() {
+				if ev, ok := f.event(msg); ok {
+					s.Emit(ev)
+				}
+			}
+		}
+	}
+}
+
+// follows reports whether broadcasts of msgType can make an event.
+func (s *Service) follows(msgType string) bool {
+	switch msgType {
+	case "nav_status", "mode", "estop", "robot_disconnected", "robot_removed":
+		return true
+	}
+	return s.BatteryLowPct > 0 && msgType == robot.ExtraTopicPrefix+s.BatteryAlias
+}
+
+// followQueue holds the broadcasts Follow acts on until its loop takes
+// them. It is unbounded: those frames are few, and the broadcast handler
+// filling it must not block.
+type followQueue struct {
+	mu    sync.Mutex
+	msgs  []robot.BroadcastMsg
+	ready chan struct{} // signalled when msgs becomes non-empty
+}
+
+func (q *followQueue) push(msg robot.BroadcastMsg) {
+	q.mu.Lock()
+	q.msgs = append(q.msgs, msg)
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *followQueue) take() []robot.BroadcastMsg {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	msgs := q.msgs
+	q.msgs = nil
+	return msgs
+}
+
+// follower keeps the state events are derived with; used by one
+// goroutine.
+type follower struct {
+	s       *Service
+	mgr     *robot.Manager
+	reached map[string]string // robot ID → last goal reported
+	low     map[string]bool   // robot ID → battery_low sent, not recharged since
+}
+
+func newFollower(s *Service, mgr *robot.Manager) *follower {
+	return &follower{s: s, mgr: mgr, reached: map[string]string{}, low: map[string]bool{}}
+}
+
+// event returns the event msg makes, if any.
+func (f *follower) event(msg robot.BroadcastMsg) (Event, bool) {
+	ev := Event{RobotID: msg.RobotID}
+	switch msg.Type {
+	case "nav_status":
+		st, ok := msg.Data.(rosbridge.NavStatus)
+		if !ok || st.Status != rosbridge.GoalSucceeded || st.GoalID == "" || f.reached[msg.RobotID] == st.GoalID {
+			return Event{}, false
+		}
+		f.reached[msg.RobotID] = st.GoalID
+		data := GoalReached{GoalID: st.GoalID}
+		if rb := f.mgr.GetRobot(msg.RobotID); rb != nil {
+			if p, ok := rb.NearestPoint(ArrivalRadiusM, arrivalPoseAge); ok {
+				data.Point = &p
+			}
+		}
+		ev.Type, ev.Data = EventGoalReached, data
+	case "mode":
+		ev.Type, ev.Data = EventModeChanged, msg.Data
+	case "estop":
+		ev.Type, ev.Data = EventEStop, msg.Data
+	case "robot_disconnected":
+		ev.Type = EventDisconnected
+	case "robot_removed":
+		delete(f.reached, msg.RobotID)
+		delete(f.low, msg.RobotID)
+		return Event{}, false
+	default:
+		v, ok := msg.Data.(robot.ExtraTopicValue)
+		if !ok || !f.s.follows(msg.Type) {
+			return Event{}, false
+		}
+		pct, ok := batteryPercent(v)
+		threshold := f.s.BatteryLowPct
+		switch {
+		case !ok:
+			return Event{}, false
+		case pct >= threshold+BatteryRearmPct:
+			delete(f.low, msg.RobotID)
+			return Event{}, false
+		case pct >= threshold || f.low[msg.RobotID]:
+			return Event{}, false
+		}
+		f.low[msg.RobotID] = true
+		ev.Type, ev.Data = EventBatteryLow, BatteryLow{Percent: pct, ThresholdPct: threshold}
+	}
+	if rb := f.mgr.GetRobot(msg.RobotID); rb != nil {
+		ev.RobotName = rb.Name
+	}
+	return ev, true
+}
+
+// batteryPercent reads the charge (0..100) from a battery topic message:
+// a sensor_msgs/BatteryState, whose percentage runs 0..1, or a
+// std_msgs/Float32 or Float64 holding percent.
+func batteryPercent(v robot.ExtraTopicValue) (float64, bool) {
+	var m struct {
+		Percentage *float64 `json:"percentage"`
+		Data       *float64 `json:"data"`
+	}
+	if v.Msg == nil || json.Unmarshal(v.Msg, &m) != nil {
+		return 0, false // oversize, or NaN for an unknown charge
+	}
+	var pct float64
+	switch {
+	case m.Percentage != nil:
+		pct = *m.Percentage * 100
+	case m.Data != nil:
+		pct = *m.Data
+	default:
+		return 0, false
+	}
+	return pct, pct >= 0 && pct <= 100
+}
@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"rom_go_app/robot"
+	"rom_go_app/rosbridge"
+)
+
+// nextEvent returns the next event queued for delivery; no worker runs.
+func nextEvent(t *testing.T, s *Service) Event {
+	t.Helper()
+	select {
+	case j := <-s.queue:
+		return j.event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func battery(msg string) robot.ExtraTopicValue {
+	return robot.ExtraTopicValue{Alias: "battery", Msg: json.RawMessage(msg)}
+}
+
+// TestFollowMissesNothing fills a stalled browser's subscription so the
+// manager drops frames, and checks every event still comes through.
+func TestFollowMissesNothing(t *testing.T) {
+	mgr := robot.NewManager()
+	rb, err := mgr.AddRobot("", "alpha", "127.0.0.1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+	stalled := mgr.Subscribe()
+	defer mgr.Unsubscribe(stalled)
+
+	s := newTestService(t)
+	s.Add(Endpoint{URL: "http://127.0.0.1:9/", Enabled: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		s.Follow(ctx, mgr)
+		close(done)
+	}()
+	// Follow has registered its handler once it sees a broadcast
+	waitFor(t, "Follow to start", func() bool {
+		mgr.Broadcast(robot.BroadcastMsg{Type: "estop", RobotID: rb.ID})
+		select {
+		case <-s.queue:
+			return true
+		default:
+			return false
+		}
+	})
+	time.Sleep(20 * time.Millisecond) // the probes still in flight
+	for len(s.queue) > 0 {
+		<-s.queue
+	}
+
+	for i := 0; i < 500; i++ {
+		mgr.Broadcast(robot.BroadcastMsg{Type: "odom", RobotID: rb.ID})
+	}
+	succeeded := func(goal string) robot.BroadcastMsg {
+		return robot.BroadcastMsg{Type: "nav_status", RobotID: rb.ID, Data: rosbridge.NavStatus{GoalID: goal, Status: rosbridge.GoalSucceeded}}
+	}
+	mgr.Broadcast(succeeded("g1"))
+	mgr.Broadcast(succeeded("g1")) // repeated status
+	mgr.Broadcast(robot.BroadcastMsg{Type: "nav_status", RobotID: rb.ID, Data: rosbridge.NavStatus{GoalID: "g2", Status: rosbridge.GoalExecuting}})
+	mgr.Broadcast(succeeded("g2"))
+	mgr.Broadcast(robot.BroadcastMsg{Type: "mode", RobotID: rb.ID, Data: "mapping"})
+	mgr.Broadcast(robot.BroadcastMsg{Type: "robot_disconnected", RobotID: rb.ID})
+	mgr.Broadcast(robot.BroadcastMsg{Type: robot.ExtraTopicPrefix + "battery", RobotID: rb.ID, Data: battery(`{"data": 12}`)})
+
+	for _, want := range []string{EventGoalReached, EventGoalReached, EventModeChanged, EventDisconnected, EventBatteryLow} {
+		ev := nextEvent(t, s)
+		if ev.Type != want || ev.RobotID != rb.ID || ev.RobotName != "alpha" {
+			t.Errorf("got %+v, want %s", ev, want)
+		}
+	}
+	if len(s.queue) != 0 {
+		t.Errorf("%d more events", len(s.queue))
+	}
+	if len(stalled) != cap(stalled) {
+		t.Errorf("the stalled subscriber has %d of %d frames; nothing was dropped", len(stalled), cap(stalled))
+	}
+
+	cancel()
+	<-done
+	mgr.Broadcast(succeeded("g3"))
+	time.Sleep(20 * time.Millisecond)
+	if len(s.queue) != 0 {
+		t.Error("events after Follow returned")
+	}
+}
+
+func TestBatteryLow(t *testing.T) {
+	mgr := robot.NewManager()
+	s := newTestService(t)
+	f := newFollower(s, mgr)
+	send := func(robotID string, v robot.ExtraTopicValue) (BatteryLow, bool) {
+		ev, ok := f.event(robot.BroadcastMsg{Type: robot.ExtraTopicPrefix + v.Alias, RobotID: robotID, Data: v})
+		if ok && ev.Type != EventBatteryLow {
+			t.Fatalf("event %+v", ev)
+		}
+		data, _ := ev.Data.(BatteryLow)
+		return data, ok
+	}
+
+	for _, step := range []struct {
+		msg  string
+		want bool
+	}{
+		{`{"data": 50}`, false},
+		{`{"data": 25}`, false},
+		{`{"data": 19.5}`, true},
+		{`{"data": 15}`, false}, // once
+		{`{"data": 22}`, false}, // back above, not by the rearm margin
+		{`{"data": 18}`, false},
+		{`{"data": 25}`, false},                        // rearmed
+		{`{"percentage": 0.1, "voltage": 11.2}`, true}, // BatteryState
+		{`{"percentage": 0.6}`, false},
+		{`{"percentage": NaN}`, false}, // unknown charge
+		{`{"voltage": 11.2}`, false},
+		{`{"data": 150}`, false},
+		{`{"data": -1}`, false},
+		{`{"data": 5}`, true},
+	} {
+		data, ok := send("1", battery(step.msg))
+		if ok != step.want {
+			t.Errorf("%s: event %v, want %v", step.msg, ok, step.want)
+		}
+		if ok && (data.ThresholdPct != DefaultBatteryLowPct || data.Percent > DefaultBatteryLowPct) {
+			t.Errorf("%s: %+v", step.msg, data)
+		}
+	}
+
+	// Per robot; removing one forgets it was low
+	if _, ok := send("2", battery(`{"data": 10}`)); !ok {
+		t.Error("second robot: no event")
+	}
+	f.event(robot.BroadcastMsg{Type: "robot_removed", RobotID: "2"})
+	if _, ok := send("2", battery(`{"data": 10}`)); !ok {
+		t.Error("re-added robot: no event")
+	}
+	if _, ok := send("1", robot.ExtraTopicValue{Alias: "battery", Oversize: true}); ok {
+		t.Error("oversize value: event")
+	}
+
+	// Other aliases, and disabled
+	if _, ok := send("3", robot.ExtraTopicValue{Alias: "sonar", Msg: json.RawMessage(`{"data": 1}`)}); ok {
+		t.Error("other alias: event")
+	}
+	s.BatteryAlias = "power"
+	if _, ok := send("3", robot.ExtraTopicValue{Alias: "power", Msg: json.RawMessage(`{"data": 1}`)}); !ok {
+		t.Error("configured alias: no event")
+	}
+	s.BatteryLowPct = 0
+	if _, ok := send("4", robot.ExtraTopicValue{Alias: "power", Msg: json.RawMessage(`{"data": 0}`)}); ok || s.follows(robot.ExtraTopicPrefix+"power") {
+		t.Error("disabled: event")
+	}
+}
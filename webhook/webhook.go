@@ -0,0 +1,254 @@
+// Package webhook posts robot events to external HTTP endpoints.
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EventGoalReached  = "goal_reached"       // navigation goal succeeded; names the point arrived at
+	EventModeChanged  = "mode_changed"       // navigation / mapping / remapping switch
+	EventEStop        = "estop"              // software e-stop engaged or released
+	EventDisconnected = "robot_disconnected" // rosbridge connection lost
+	EventBatteryLow   = "battery_low"        // battery charge fell below BatteryLowPct
+	EventTest         = "test"               // sent by POST /api/webhooks/test only
+)
+
+// Events lists the event types an endpoint may subscribe to.
+var Events = []string{EventGoalReached, EventModeChanged, EventEStop, EventDisconnected, EventBatteryLow}
+
+// ErrNotFound is returned for an unknown endpoint ID.
+var ErrNotFound = errors.New("webhook not found")
+
+// Endpoint is a receiver of event POSTs.
+type Endpoint struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret keys the HMAC-SHA256 body signature; never returned by the
+	// API, which reports HasSecret instead.
+	Secret    string    `json:"secret,omitempty"`
+	HasSecret bool      `json:"has_secret"`
+	Events    []string  `json:"events"` // empty: every event
+	Enabled   bool      `json:"enabled"`
+	Created   time.Time `json:"created"`
+}
+
+// Validate checks the URL and event names.
+func (e Endpoint) Validate() error {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook url must be an absolute http(s) URL")
+	}
+	for _, ev := range e.Events {
+		if !knownEvent(ev) {
+			return fmt.Errorf("unknown webhook event %q", ev)
+		}
+	}
+	return nil
+}
+
+// Wants reports whether the endpoint receives events of type ev.
+func (e Endpoint) Wants(ev string) bool {
+	if !e.Enabled {
+		return false
+	}
+	if ev == EventTest || len(e.Events) == 0 {
+		return true
+	}
+	for _, x := range e.Events {
+		if x == ev {
+			return true
+		}
+	}
+	return false
+}
+
+// public returns e without its secret.
+func (e Endpoint) public() Endpoint {
+	e.HasSecret = e.Secret != ""
+	e.Secret = ""
+	e.Events = append([]string{}, e.Events...)
+	return e
+}
+
+func knownEvent(ev string) bool {
+	for _, x := range Events {
+		if x == ev {
+			return true
+		}
+	}
+	return false
+}
+
+// Service keeps the endpoints, persisted as JSON in one file, and
+// delivers events to them (see deliver.go).
+type Service struct {
+	path   string
+	client *http.Client
+
+	// BatteryAlias is the extra topic (see robot/extra_topics.go)
+	// carrying a robot's battery charge; BatteryLowPct is the charge
+	// below which battery_low is sent (<= 0: never). Set before Follow.
+	BatteryAlias  string
+	BatteryLowPct float64
+
+	mu         sync.Mutex
+	endpoints  map[string]Endpoint
+	nextID     int
+	deliveries []Delivery // newest last, at most DeliveryLogSize
+
+	queue   chan job
+	backoff func(attempt int) time.Duration // retryDelay; shortened by tests
+}
+
+// NewService loads the endpoints saved at path; a missing file starts
+// with none.
+func NewService(path string) (*Service, error) {
+	s := &Service{
+		path:      path,
+		client:    &http.Client{Timeout: RequestTimeout},
+		endpoints: map[string]Endpoint{},
+		nextID:    1,
+		queue:     make(chan job, QueueSize),
+		backoff:   retryDelay,
+
+		BatteryAlias:  DefaultBatteryAlias,
+		BatteryLowPct: DefaultBatteryLowPct,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Endpoint
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("webhooks file %s: %w", path, err)
+	}
+	for _, e := range list {
+		s.endpoints[e.ID] = e
+		if n, err := strconv.Atoi(e.ID); err == nil && n >= s.nextID {
+			s.nextID = n + 1
+		}
+	}
+	return s, nil
+}
+
+// List returns the endpoints by ID, without secrets.
+func (s *Service) List() []Endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Endpoint, 0, len(s.endpoints))
+	for _, e := range s.endpoints {
+		out = append(out, e.public())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].ID)
+		b, _ := strconv.Atoi(out[j].ID)
+		return a < b
+	})
+	return out
+}
+
+// Get returns an endpoint without its secret.
+func (s *Service) Get(id string) (Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.endpoints[id]
+	if !ok {
+		return Endpoint{}, ErrNotFound
+	}
+	return e.public(), nil
+}
+
+// Add validates, stores and saves a new endpoint.
+func (s *Service) Add(e Endpoint) (Endpoint, error) {
+	if err := e.Validate(); err != nil {
+		return Endpoint{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = strconv.Itoa(s.nextID)
+	e.Created = time.Now().UTC()
+	e.HasSecret = false
+	s.endpoints[e.ID] = e
+	if err := s.saveLocked(); err != nil {
+		delete(s.endpoints, e.ID)
+		return Endpoint{}, err
+	}
+	s.nextID++
+	return e.public(), nil
+}
+
+// Update replaces an endpoint's settings with update applied to them.
+func (s *Service) Update(id string, update func(*Endpoint)) (Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.endpoints[id]
+	if !ok {
+		return Endpoint{}, ErrNotFound
+	}
+	e := old
+	e.Events = append([]string{}, old.Events...)
+	update(&e)
+	e.ID, e.Created, e.HasSecret = old.ID, old.Created, false
+	if err := e.Validate(); err != nil {
+		return Endpoint{}, err
+	}
+	s.endpoints[id] = e
+	if err := s.saveLocked(); err != nil {
+		s.endpoints[id] = old
+		return Endpoint{}, err
+	}
+	return e.public(), nil
+}
+
+// Remove deletes an endpoint; queued retries to it are dropped.
+func (s *Service) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.endpoints[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.endpoints, id)
+	if err := s.saveLocked(); err != nil {
+		s.endpoints[id] = old
+		return err
+	}
+	return nil
+}
+
+// saveLocked writes the endpoints, secrets included, readable by the
+// owner only. Caller holds s.mu.
+func (s *Service) saveLocked() error {
+	list := make([]Endpoint, 0, len(s.endpoints))
+	for _, e := range s.endpoints {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
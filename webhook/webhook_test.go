@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	s, err := NewService(filepath.Join(t.TempDir(), "webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestEndpointValidate(t *testing.T) {
+	for _, tc := range []struct {
+		e  Endpoint
+		ok bool
+	}{
+		{Endpoint{URL: "https://example.com/hook"}, true},
+		{Endpoint{URL: "http://10.0.0.5:8000/x", Events: []string{EventEStop, EventBatteryLow}}, true},
+		{Endpoint{URL: "ftp://example.com/hook"}, false},
+		{Endpoint{URL: "/relative"}, false},
+		{Endpoint{URL: "https://"}, false},
+		{Endpoint{URL: "https://example.com", Events: []string{"reboot"}}, false},
+		{Endpoint{URL: "https://example.com", Events: []string{EventTest}}, false}, // sent on request only
+	} {
+		if err := tc.e.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.e, err)
+		}
+	}
+}
+
+func TestEndpointWants(t *testing.T) {
+	all := Endpoint{Enabled: true}
+	some := Endpoint{Enabled: true, Events: []string{EventEStop, EventBatteryLow}}
+	off := Endpoint{Events: []string{EventEStop}}
+	for _, ev := range Events {
+		if !all.Wants(ev) {
+			t.Errorf("no filter: %s not wanted", ev)
+		}
+		if some.Wants(ev) != (ev == EventEStop || ev == EventBatteryLow) {
+			t.Errorf("filtered: %s wanted %v", ev, some.Wants(ev))
+		}
+		if off.Wants(ev) {
+			t.Errorf("disabled: %s wanted", ev)
+		}
+	}
+	if !some.Wants(EventTest) || off.Wants(EventTest) {
+		t.Error("the test event goes to every enabled endpoint")
+	}
+}
+
+func TestServicePersistence(t *testing.T) {
+	s := newTestService(t)
+	a, err := s.Add(Endpoint{URL: "https://a.example/hook", Secret: "s3cret", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != "1" || a.Secret != "" || !a.HasSecret {
+		t.Errorf("added %+v: secret returned or not reported", a)
+	}
+	if _, err := s.Add(Endpoint{URL: "nope"}); err == nil {
+		t.Error("invalid endpoint added")
+	}
+	b, _ := s.Add(Endpoint{URL: "https://b.example/hook", Events: []string{EventEStop}})
+
+	if _, err := s.Update(a.ID, func(e *Endpoint) { e.Events = []string{"bogus"} }); err == nil {
+		t.Error("invalid update applied")
+	}
+	if got, _ := s.Get(a.ID); len(got.Events) != 0 {
+		t.Errorf("refused update kept: %+v", got)
+	}
+	if got, err := s.Update(b.ID, func(e *Endpoint) { e.ID, e.Enabled = "99", true }); err != nil || got.ID != b.ID || !got.Enabled {
+		t.Errorf("update: %+v %v", got, err)
+	}
+	if _, err := s.Update("7", func(*Endpoint) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown update: %v", err)
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("file %v mode %v", err, info.Mode())
+	}
+	var saved []Endpoint
+	data, _ := os.ReadFile(s.path)
+	if json.Unmarshal(data, &saved) != nil || len(saved) != 2 || saved[0].Secret != "s3cret" {
+		t.Errorf("saved %s", data)
+	}
+
+	// Reloaded: IDs continue after the highest
+	if err := s.Remove(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removed twice: %v", err)
+	}
+	s2, err := NewService(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := s2.List(); len(list) != 1 || list[0].ID != b.ID || list[0].Events[0] != EventEStop {
+		t.Errorf("reloaded %+v", list)
+	}
+	if c, _ := s2.Add(Endpoint{URL: "https://c.example/hook"}); c.ID != "3" {
+		t.Errorf("next ID %q", c.ID)
+	}
+
+	os.WriteFile(s.path, []byte("{"), 0600)
+	if _, err := NewService(s.path); err == nil {
+		t.Error("corrupt file loaded")
+	}
+}